@@ -0,0 +1,24 @@
+"""Python client for the Parity coordinator API."""
+
+from .client import APIError, ParityClient
+from .fl import FederatedLearning
+from .models import TERMINAL_STATUSES, Task, TaskMetrics, TaskResult
+from .webhook import NONCE_HEADER, SIGNATURE_HEADER, TIMESTAMP_HEADER, verify_signature
+
+PROTOCOL_VERSION = "v1"
+__version__ = "1.0.0"
+
+__all__ = [
+    "APIError",
+    "FederatedLearning",
+    "NONCE_HEADER",
+    "ParityClient",
+    "PROTOCOL_VERSION",
+    "SIGNATURE_HEADER",
+    "TERMINAL_STATUSES",
+    "TIMESTAMP_HEADER",
+    "Task",
+    "TaskMetrics",
+    "TaskResult",
+    "verify_signature",
+]
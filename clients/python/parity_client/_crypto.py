@@ -0,0 +1,123 @@
+"""Keccak-256 and secp256k1 public key recovery, enough to check the server's
+Ethereum-style signatures without third-party packages."""
+
+from typing import Optional, Tuple
+
+_ROUND_CONSTANTS = (
+    0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+    0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+    0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+    0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+    0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+    0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+)
+
+# Rotation of lane x + 5y
+_ROTATIONS = (
+    0, 1, 62, 28, 27,
+    36, 44, 6, 55, 20,
+    3, 10, 43, 25, 39,
+    41, 45, 15, 21, 8,
+    18, 2, 61, 56, 14,
+)
+
+_MASK = (1 << 64) - 1
+_RATE = 136
+
+
+def _rol(value: int, shift: int) -> int:
+    return ((value << shift) | (value >> (64 - shift))) & _MASK if shift else value
+
+
+def _keccak_f(a):
+    for rc in _ROUND_CONSTANTS:
+        c = [a[x] ^ a[x + 5] ^ a[x + 10] ^ a[x + 15] ^ a[x + 20] for x in range(5)]
+        d = [c[(x - 1) % 5] ^ _rol(c[(x + 1) % 5], 1) for x in range(5)]
+        a = [a[i] ^ d[i % 5] for i in range(25)]
+        b = [0] * 25
+        for x in range(5):
+            for y in range(5):
+                b[y + 5 * ((2 * x + 3 * y) % 5)] = _rol(a[x + 5 * y], _ROTATIONS[x + 5 * y])
+        a = [b[i] ^ (~b[(i + 1) % 5 + 5 * (i // 5)] & b[(i + 2) % 5 + 5 * (i // 5)]) for i in range(25)]
+        a[0] ^= rc
+    return a
+
+
+def keccak256(data: bytes) -> bytes:
+    """Keccak-256 as used by Ethereum, which pads differently from SHA3-256."""
+    padded = bytearray(data)
+    padded.append(0x01)
+    while len(padded) % _RATE:
+        padded.append(0)
+    padded[-1] |= 0x80
+
+    state = [0] * 25
+    for offset in range(0, len(padded), _RATE):
+        for i in range(_RATE // 8):
+            start = offset + 8 * i
+            state[i] ^= int.from_bytes(padded[start:start + 8], "little")
+        state = _keccak_f(state)
+    return b"".join(lane.to_bytes(8, "little") for lane in state[:4])
+
+
+_P = 0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F
+_N = 0xFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141
+_G = (
+    0x79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798,
+    0x483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8,
+)
+
+Point = Optional[Tuple[int, int]]
+
+
+def _add(p: Point, q: Point) -> Point:
+    if p is None:
+        return q
+    if q is None:
+        return p
+    if p[0] == q[0]:
+        if (p[1] + q[1]) % _P == 0:
+            return None
+        slope = 3 * p[0] * p[0] * pow(2 * p[1], -1, _P) % _P
+    else:
+        slope = (q[1] - p[1]) * pow(q[0] - p[0], -1, _P) % _P
+    x = (slope * slope - p[0] - q[0]) % _P
+    return x, (slope * (p[0] - x) - p[1]) % _P
+
+
+def _multiply(k: int, p: Point) -> Point:
+    result = None
+    while k:
+        if k & 1:
+            result = _add(result, p)
+        p = _add(p, p)
+        k >>= 1
+    return result
+
+
+def recover_address(digest: bytes, signature: bytes) -> Optional[str]:
+    """Returns the 0x address whose key made the 65-byte [R || S || V]
+    signature over digest, or None when no key could have."""
+    if len(signature) != 65:
+        return None
+    r = int.from_bytes(signature[:32], "big")
+    s = int.from_bytes(signature[32:64], "big")
+    v = signature[64]
+    if v >= 27:
+        v -= 27
+    if v not in (0, 1) or not 0 < r < _N or not 0 < s < _N:
+        return None
+
+    y = pow((r * r * r + 7) % _P, (_P + 1) // 4, _P)
+    if (y * y - r * r * r - 7) % _P:
+        return None
+    if y & 1 != v:
+        y = _P - y
+
+    e = int.from_bytes(digest, "big")
+    r_inverse = pow(r, -1, _N)
+    point = _add(_multiply(s * r_inverse % _N, (r, y)), _multiply(-e * r_inverse % _N, _G))
+    if point is None:
+        return None
+    public_key = point[0].to_bytes(32, "big") + point[1].to_bytes(32, "big")
+    return "0x" + keccak256(public_key)[12:].hex()
@@ -0,0 +1,144 @@
+"""HTTP client for task submission, status polling and result retrieval."""
+
+import json
+import time
+import urllib.error
+import urllib.request
+from typing import Any, Dict, List, Optional
+
+from .models import Task, TaskMetrics, TaskResult
+
+DEFAULT_TIMEOUT = 30.0
+DEFAULT_POLL_INTERVAL = 2.0
+
+
+class APIError(Exception):
+    """Raised when the coordinator answers with a non-success status."""
+
+    def __init__(self, status: int, body: str):
+        super().__init__(f"parity api returned status {status}: {body}")
+        self.status = status
+        self.body = body
+
+
+class ParityClient:
+    def __init__(
+        self,
+        base_url: str,
+        device_id: Optional[str] = None,
+        timeout: float = DEFAULT_TIMEOUT,
+    ):
+        base_url = base_url.rstrip("/")
+        if base_url.endswith("/api"):
+            base_url = base_url[: -len("/api")]
+        self.base_url = base_url
+        self.device_id = device_id
+        self.timeout = timeout
+
+        from .fl import FederatedLearning
+
+        self.fl = FederatedLearning(self)
+
+    def create_task(
+        self,
+        title: str,
+        type: str,
+        config: Dict[str, Any],
+        description: str = "",
+        environment: Optional[Dict[str, Any]] = None,
+        reward: float = 0.0,
+        labels: Optional[Dict[str, str]] = None,
+        creator_address: str = "",
+    ) -> Task:
+        payload: Dict[str, Any] = {"title": title, "type": type, "config": config}
+        if description:
+            payload["description"] = description
+        if environment:
+            payload["environment"] = environment
+        if reward:
+            payload["reward"] = reward
+        if labels:
+            payload["labels"] = labels
+        if creator_address:
+            payload["creator_address"] = creator_address
+        return Task.from_dict(self.request("POST", "/tasks", payload))
+
+    def list_tasks(self) -> List[Task]:
+        return [Task.from_dict(task) for task in self.request("GET", "/tasks") or []]
+
+    def estimate_task(
+        self,
+        type: str,
+        image_size_mb: float = 0.0,
+        expected_runtime_seconds: float = 0.0,
+        model: str = "",
+    ) -> Dict[str, Any]:
+        payload: Dict[str, Any] = {"type": type}
+        if image_size_mb:
+            payload["image_size_mb"] = image_size_mb
+        if expected_runtime_seconds:
+            payload["expected_runtime_seconds"] = expected_runtime_seconds
+        if model:
+            payload["model"] = model
+        return self.request("POST", "/tasks/estimate", payload)
+
+    def get_task(self, task_id: str) -> Task:
+        return Task.from_dict(self.request("GET", f"/tasks/{task_id}"))
+
+    def get_result(self, task_id: str) -> TaskResult:
+        return TaskResult.from_dict(self.request("GET", f"/tasks/{task_id}/result"))
+
+    def get_metrics(self, task_id: str) -> TaskMetrics:
+        return TaskMetrics.from_dict(self.request("GET", f"/tasks/{task_id}/metrics"))
+
+    def get_receipt(self, task_id: str) -> Dict[str, Any]:
+        return self.request("GET", f"/tasks/{task_id}/receipt")
+
+    def wait_for_task(
+        self,
+        task_id: str,
+        interval: float = DEFAULT_POLL_INTERVAL,
+        timeout: Optional[float] = None,
+    ) -> Task:
+        deadline = None if timeout is None else time.monotonic() + timeout
+        while True:
+            task = self.get_task(task_id)
+            if task.is_terminal:
+                return task
+            if deadline is not None and time.monotonic() >= deadline:
+                raise TimeoutError(f"task {task_id} did not finish within {timeout}s")
+            time.sleep(interval)
+
+    def run_task(
+        self,
+        title: str,
+        type: str,
+        config: Dict[str, Any],
+        interval: float = DEFAULT_POLL_INTERVAL,
+        timeout: Optional[float] = None,
+        **kwargs: Any,
+    ) -> TaskResult:
+        task = self.create_task(title=title, type=type, config=config, **kwargs)
+        self.wait_for_task(task.id, interval=interval, timeout=timeout)
+        return self.get_result(task.id)
+
+    def request(self, method: str, path: str, body: Optional[Dict[str, Any]] = None) -> Any:
+        url = f"{self.base_url}/api/v1{path}"
+        data = None
+        headers = {"Accept": "application/json"}
+        if body is not None:
+            data = json.dumps(body).encode("utf-8")
+            headers["Content-Type"] = "application/json"
+        if self.device_id:
+            headers["X-Device-ID"] = self.device_id
+
+        req = urllib.request.Request(url, data=data, headers=headers, method=method)
+        try:
+            with urllib.request.urlopen(req, timeout=self.timeout) as resp:
+                raw = resp.read()
+        except urllib.error.HTTPError as err:
+            raise APIError(err.code, err.read().decode("utf-8", "replace").strip()) from err
+
+        if not raw:
+            return None
+        return json.loads(raw)
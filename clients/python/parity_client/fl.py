@@ -0,0 +1,79 @@
+"""Helpers for federated learning sessions."""
+
+import time
+from typing import TYPE_CHECKING, Any, Dict, Optional
+
+if TYPE_CHECKING:
+    from .client import ParityClient
+
+SESSION_TERMINAL_STATUSES = ("completed", "failed", "cancelled")
+
+
+class FederatedLearning:
+    def __init__(self, client: "ParityClient"):
+        self._client = client
+
+    def create_session(
+        self,
+        name: str,
+        model_type: str,
+        total_rounds: int,
+        min_participants: int,
+        dataset_cid: str,
+        model_config: Dict[str, Any],
+        data_format: str = "csv",
+        split_strategy: str = "random",
+        learning_rate: float = 0.01,
+        batch_size: int = 32,
+        local_epochs: int = 5,
+        aggregation_method: str = "federated_averaging",
+        description: str = "",
+        creator_address: str = "",
+        **training_data: Any,
+    ) -> Dict[str, Any]:
+        payload: Dict[str, Any] = {
+            "name": name,
+            "description": description,
+            "model_type": model_type,
+            "total_rounds": total_rounds,
+            "min_participants": min_participants,
+            "creator_address": creator_address,
+            "training_data": {
+                "dataset_cid": dataset_cid,
+                "data_format": data_format,
+                "split_strategy": split_strategy,
+                **training_data,
+            },
+            "config": {
+                "aggregation_method": aggregation_method,
+                "learning_rate": learning_rate,
+                "batch_size": batch_size,
+                "local_epochs": local_epochs,
+                "model_config": model_config,
+            },
+        }
+        return self._client.request("POST", "/federated-learning/sessions", payload)
+
+    def get_session(self, session_id: str) -> Dict[str, Any]:
+        return self._client.request("GET", f"/federated-learning/sessions/{session_id}")
+
+    def start_session(self, session_id: str) -> Any:
+        return self._client.request("POST", f"/federated-learning/sessions/{session_id}/start", {})
+
+    def get_model(self, session_id: str) -> Dict[str, Any]:
+        return self._client.request("GET", f"/federated-learning/sessions/{session_id}/model")
+
+    def wait_for_session(
+        self,
+        session_id: str,
+        interval: float = 5.0,
+        timeout: Optional[float] = None,
+    ) -> Dict[str, Any]:
+        deadline = None if timeout is None else time.monotonic() + timeout
+        while True:
+            session = self.get_session(session_id)
+            if session.get("status") in SESSION_TERMINAL_STATUSES:
+                return session
+            if deadline is not None and time.monotonic() >= deadline:
+                raise TimeoutError(f"session {session_id} did not finish within {timeout}s")
+            time.sleep(interval)
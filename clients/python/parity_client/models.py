@@ -0,0 +1,77 @@
+"""Typed views over the API payloads defined in api/openapi.yaml."""
+
+from dataclasses import dataclass, field, fields
+from typing import Any, Dict, Optional
+
+TERMINAL_STATUSES = ("completed", "failed", "cancelled")
+
+
+def _from_dict(cls, data: Dict[str, Any]):
+    known = {f.name for f in fields(cls)}
+    return cls(**{k: v for k, v in (data or {}).items() if k in known})
+
+
+@dataclass
+class Task:
+    id: str = ""
+    title: str = ""
+    description: str = ""
+    type: str = ""
+    status: str = ""
+    config: Any = None
+    labels: Dict[str, str] = field(default_factory=dict)
+    reward: float = 0.0
+    creator_address: str = ""
+    runner_id: str = ""
+    created_at: Optional[str] = None
+    completed_at: Optional[str] = None
+
+    @classmethod
+    def from_dict(cls, data: Dict[str, Any]) -> "Task":
+        return _from_dict(cls, data)
+
+    @property
+    def is_terminal(self) -> bool:
+        return self.status in TERMINAL_STATUSES
+
+
+@dataclass
+class TaskResult:
+    task_id: str = ""
+    device_id: str = ""
+    output: str = ""
+    error: str = ""
+    exit_code: int = 0
+    execution_time: int = 0
+    result_hash: str = ""
+    reward: float = 0.0
+    cpu_seconds: float = 0.0
+    memory_gb_hours: float = 0.0
+    peak_memory_gb: float = 0.0
+    storage_gb: float = 0.0
+    network_data_gb: float = 0.0
+    prompt_tokens: int = 0
+    response_tokens: int = 0
+    inference_time_ms: int = 0
+
+    @classmethod
+    def from_dict(cls, data: Dict[str, Any]) -> "TaskResult":
+        return _from_dict(cls, data)
+
+
+@dataclass
+class TaskMetrics:
+    task_id: str = ""
+    device_id: str = ""
+    creator_address: str = ""
+    execution_time_ms: int = 0
+    cpu_seconds: float = 0.0
+    estimated_cycles: int = 0
+    memory_gb_hours: float = 0.0
+    peak_memory_gb: float = 0.0
+    storage_gb: float = 0.0
+    network_data_gb: float = 0.0
+
+    @classmethod
+    def from_dict(cls, data: Dict[str, Any]) -> "TaskMetrics":
+        return _from_dict(cls, data)
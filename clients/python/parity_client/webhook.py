@@ -0,0 +1,73 @@
+"""Verification of webhook deliveries signed with the server identity key
+(X-Parity-Server-Signature)."""
+
+import time
+from typing import Dict, Optional
+
+from ._crypto import keccak256, recover_address
+
+SIGNATURE_HEADER = "X-Parity-Server-Signature"
+TIMESTAMP_HEADER = "X-Parity-Server-Timestamp"
+NONCE_HEADER = "X-Parity-Delivery-Nonce"
+
+# How far a signature timestamp may be from the local clock, which bounds how
+# long a captured delivery can be replayed
+MAX_CLOCK_SKEW = 300
+
+# Nonces of accepted deliveries by signature time, kept while a replay of them
+# would still pass the clock skew check
+_seen_nonces: Dict[str, int] = {}
+
+
+def _digest(timestamp: str, device_id: str, nonce: str, payload: bytes) -> bytes:
+    signed = keccak256(
+        b"webhook\n"
+        + timestamp.encode("utf-8")
+        + b"\n"
+        + device_id.encode("utf-8")
+        + b"\n"
+        + nonce.encode("utf-8")
+        + b"\n"
+        + payload
+    )
+    return keccak256(b"\x19Ethereum Signed Message:\n32" + signed)
+
+
+def verify_signature(
+    payload: bytes,
+    timestamp: Optional[str],
+    signature: Optional[str],
+    nonce: Optional[str],
+    server_address: str,
+    device_id: str,
+    now: Optional[float] = None,
+    seen: Optional[Dict[str, int]] = None,
+) -> bool:
+    """Reports whether the signature, timestamp and nonce headers of a delivery
+    were made over payload for device_id, the recipient, by server_address, the
+    address the server publishes at /api/v1/identity. Each nonce is accepted
+    once; seen holds the accepted nonces and defaults to one shared by the
+    process."""
+    if not timestamp or not signature or not nonce:
+        return False
+    try:
+        signed_at = int(timestamp)
+        raw = bytes.fromhex(signature[2:] if signature.startswith("0x") else signature)
+    except ValueError:
+        return False
+    current = time.time() if now is None else now
+    if abs(current - signed_at) > MAX_CLOCK_SKEW:
+        return False
+
+    signer = recover_address(_digest(timestamp, device_id, nonce, payload), raw)
+    if signer is None or signer.lower() != server_address.lower():
+        return False
+
+    if seen is None:
+        seen = _seen_nonces
+    for old in [n for n, at in seen.items() if current - at > MAX_CLOCK_SKEW]:
+        del seen[old]
+    if nonce in seen:
+        return False
+    seen[nonce] = signed_at
+    return True
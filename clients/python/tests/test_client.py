@@ -0,0 +1,133 @@
+import json
+import threading
+import unittest
+from http.server import BaseHTTPRequestHandler, HTTPServer
+
+from parity_client import APIError, ParityClient
+from parity_client.webhook import verify_signature
+
+TASK_ID = "6f1c2d3e-0000-4000-8000-000000000001"
+
+
+class Coordinator(BaseHTTPRequestHandler):
+    """Serves a task that is running on the first poll and completed after."""
+
+    polls = 0
+    requests = []
+
+    def log_message(self, *args):
+        pass
+
+    def reply(self, status, body):
+        data = json.dumps(body).encode("utf-8")
+        self.send_response(status)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+
+    def do_POST(self):
+        length = int(self.headers.get("Content-Length", 0))
+        body = json.loads(self.rfile.read(length))
+        Coordinator.requests.append((self.path, self.headers.get("X-Device-ID"), body))
+        if self.path != "/api/v1/tasks":
+            self.reply(404, {"error": "not found"})
+            return
+        self.reply(201, {"id": TASK_ID, "title": body["title"], "type": body["type"], "status": "pending"})
+
+    def do_GET(self):
+        if self.path == f"/api/v1/tasks/{TASK_ID}":
+            Coordinator.polls += 1
+            status = "running" if Coordinator.polls == 1 else "completed"
+            self.reply(200, {"id": TASK_ID, "status": status, "unknown_field": True})
+        elif self.path == f"/api/v1/tasks/{TASK_ID}/result":
+            self.reply(200, {"task_id": TASK_ID, "output": "ok\n", "exit_code": 0})
+        else:
+            self.reply(404, {"error": "Task not found"})
+
+
+class ParityClientTest(unittest.TestCase):
+    def setUp(self):
+        Coordinator.polls = 0
+        Coordinator.requests = []
+        self.server = HTTPServer(("127.0.0.1", 0), Coordinator)
+        threading.Thread(target=self.server.serve_forever, daemon=True).start()
+        self.client = ParityClient(f"http://127.0.0.1:{self.server.server_port}/api", device_id="device-1")
+
+    def tearDown(self):
+        self.server.shutdown()
+        self.server.server_close()
+
+    def test_run_task_polls_until_terminal_and_returns_result(self):
+        result = self.client.run_task(
+            "hello", "command", {"command": ["echo", "ok"]}, interval=0.01, timeout=5, labels={"team": "a"}
+        )
+
+        self.assertEqual(result.task_id, TASK_ID)
+        self.assertEqual(result.output, "ok\n")
+        self.assertEqual(Coordinator.polls, 2)
+        path, device_id, body = Coordinator.requests[0]
+        self.assertEqual(path, "/api/v1/tasks")
+        self.assertEqual(device_id, "device-1")
+        self.assertEqual(body, {"title": "hello", "type": "command", "config": {"command": ["echo", "ok"]}, "labels": {"team": "a"}})
+
+    def test_error_status_raises_api_error(self):
+        with self.assertRaises(APIError) as ctx:
+            self.client.get_task("missing")
+        self.assertEqual(ctx.exception.status, 404)
+        self.assertIn("Task not found", ctx.exception.body)
+
+
+class WebhookSignatureTest(unittest.TestCase):
+    # Signed by the server identity key 4c0883a6...362318 at TIMESTAMP for a
+    # delivery to DEVICE with NONCE
+    PAYLOAD = b'{"type":"task_completed","payload":{"id":"123"}}'
+    SERVER = "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"
+    TIMESTAMP = "1760000000"
+    DEVICE = "integrator-1"
+    NONCE = "9f86d081884c7d659a2feaa0c55ad015"
+    SIGNATURE = (
+        "0x27718b1a35e01cf45aa4235b5175bdfafc819cf1bba2c4836a1d3251305bc77e"
+        "633e281cdd398202b2a09313c7450b1204de5b84147ea34c56ad422eb7c4573201"
+    )
+
+    def verify(
+        self,
+        payload=PAYLOAD,
+        timestamp=TIMESTAMP,
+        signature=SIGNATURE,
+        nonce=NONCE,
+        server=SERVER,
+        device=DEVICE,
+        now=1760000000,
+        seen=None,
+    ):
+        return verify_signature(
+            payload, timestamp, signature, nonce, server, device, now=now, seen={} if seen is None else seen
+        )
+
+    def test_verify_signature(self):
+        self.assertTrue(self.verify())
+        self.assertTrue(self.verify(server=self.SERVER.lower()))
+
+    def test_rejects_tampered_or_foreign_deliveries(self):
+        self.assertFalse(self.verify(payload=self.PAYLOAD + b" "))
+        self.assertFalse(self.verify(timestamp="1760000001", now=1760000001))
+        self.assertFalse(self.verify(server="0x0000000000000000000000000000000000000001"))
+        self.assertFalse(self.verify(device="integrator-2"))
+        self.assertFalse(self.verify(nonce="0" * 32))
+        self.assertFalse(self.verify(nonce=None))
+        self.assertFalse(self.verify(signature=None))
+        self.assertFalse(self.verify(signature="0xzz"))
+
+    def test_rejects_stale_deliveries(self):
+        self.assertFalse(self.verify(now=1760000000 + 301))
+
+    def test_rejects_replayed_deliveries(self):
+        seen = {}
+        self.assertTrue(self.verify(seen=seen))
+        self.assertFalse(self.verify(now=1760000100, seen=seen))
+
+
+if __name__ == "__main__":
+    unittest.main()
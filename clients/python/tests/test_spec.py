@@ -0,0 +1,92 @@
+"""Checks the client against api/openapi.yaml, so the two cannot drift apart:
+every operation has a client method calling its path, and the models have
+exactly the properties of their schemas."""
+
+import dataclasses
+import inspect
+import os
+import re
+import unittest
+
+from parity_client import ParityClient, Task, TaskMetrics, TaskResult
+
+try:
+    import yaml
+except ImportError:
+    yaml = None
+
+SPEC_PATH = os.path.join(os.path.dirname(__file__), "..", "..", "..", "api", "openapi.yaml")
+
+# How each operation is called through the client, with its path parameters
+# set to the parameter's name
+OPERATIONS = {
+    "createTask": lambda c: c.create_task(title="t", type="command", config={}),
+    "listTasks": lambda c: c.list_tasks(),
+    "estimateTask": lambda c: c.estimate_task(type="docker"),
+    "getTask": lambda c: c.get_task("taskId"),
+    "getTaskResult": lambda c: c.get_result("taskId"),
+    "getTaskMetrics": lambda c: c.get_metrics("taskId"),
+    "getTaskReceipt": lambda c: c.get_receipt("taskId"),
+    "createFLSession": lambda c: c.fl.create_session(
+        name="n", model_type="neural_network", total_rounds=1, min_participants=1, dataset_cid="cid", model_config={}
+    ),
+    "getFLSession": lambda c: c.fl.get_session("sessionId"),
+    "startFLSession": lambda c: c.fl.start_session("sessionId"),
+    "getFLModel": lambda c: c.fl.get_model("sessionId"),
+}
+
+MODELS = {"Task": Task, "TaskResult": TaskResult, "TaskMetrics": TaskMetrics}
+
+
+class RecordingClient(ParityClient):
+    def __init__(self):
+        super().__init__("http://coordinator")
+        self.calls = []
+
+    def request(self, method, path, body=None):
+        self.calls.append((method, path))
+        return {}
+
+
+@unittest.skipUnless(yaml, "PyYAML is needed to read the API contract")
+class SpecTest(unittest.TestCase):
+    @classmethod
+    def setUpClass(cls):
+        with open(SPEC_PATH, encoding="utf-8") as f:
+            cls.spec = yaml.safe_load(f)
+
+    def operations(self):
+        for path, item in self.spec["paths"].items():
+            for method, operation in item.items():
+                yield operation["operationId"], method.upper(), re.sub(r"\{(\w+)\}", r"\1", path)
+
+    def test_every_operation_is_implemented(self):
+        spec_operations = {operation_id for operation_id, _, _ in self.operations()}
+        self.assertEqual(spec_operations, set(OPERATIONS), "client operations differ from api/openapi.yaml")
+
+        for operation_id, method, path in self.operations():
+            with self.subTest(operation_id):
+                client = RecordingClient()
+                OPERATIONS[operation_id](client)
+                self.assertEqual(client.calls, [(method, path)])
+
+    def test_models_match_schemas(self):
+        schemas = self.spec["components"]["schemas"]
+        for name, model in MODELS.items():
+            with self.subTest(name):
+                fields = {f.name for f in dataclasses.fields(model)}
+                self.assertEqual(fields, set(schemas[name]["properties"]))
+
+    def test_create_task_takes_every_request_property(self):
+        properties = set(self.spec["components"]["schemas"]["CreateTaskRequest"]["properties"])
+        parameters = set(inspect.signature(ParityClient.create_task).parameters) - {"self"}
+        self.assertEqual(parameters, properties)
+
+    def test_estimate_task_takes_every_class_property(self):
+        properties = set(self.spec["components"]["schemas"]["TaskClass"]["properties"])
+        parameters = set(inspect.signature(ParityClient.estimate_task).parameters) - {"self"}
+        self.assertEqual(parameters, properties)
+
+
+if __name__ == "__main__":
+    unittest.main()
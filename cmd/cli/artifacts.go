@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/artifacts"
+)
+
+func openArtifactStore() (*artifacts.Store, error) {
+	dir, err := artifacts.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return artifacts.NewStore(dir, 0), nil
+}
+
+func ExecuteArtifactsList() error {
+	store, err := openArtifactStore()
+	if err != nil {
+		return err
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No task artifacts stored. Set RUNNER_ARTIFACTS_RETENTION to keep them.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK ID\tTYPE\tSTATUS\tEXIT CODE\tSAVED\tSIZE")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			entry.TaskID, entry.Type, entry.Status, entry.ExitCode,
+			entry.SavedAt.Local().Format(time.DateTime), formatSize(entry.Size))
+	}
+	return w.Flush()
+}
+
+// ExecuteArtifactsGet lists the stored files of a task, or writes one of them to stdout
+func ExecuteArtifactsGet(taskID, name string) error {
+	store, err := openArtifactStore()
+	if err != nil {
+		return err
+	}
+
+	if name != "" {
+		f, err := store.Open(taskID, name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(os.Stdout, f)
+		return err
+	}
+
+	entry, err := store.Get(taskID)
+	if err != nil {
+		return err
+	}
+	files, err := store.Files(taskID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Task:      %s\n", entry.TaskID)
+	fmt.Printf("Type:      %s\n", entry.Type)
+	fmt.Printf("Status:    %s (exit code %d)\n", entry.Status, entry.ExitCode)
+	if entry.Error != "" {
+		fmt.Printf("Error:     %s\n", entry.Error)
+	}
+	fmt.Printf("Saved:     %s\n", entry.SavedAt.Local().Format(time.DateTime))
+	fmt.Printf("Directory: %s\n", entry.Path)
+	fmt.Println("Files:")
+	for _, file := range files {
+		fmt.Printf("  %s\n", file)
+	}
+	return nil
+}
+
+func ExecuteArtifactsRemove(taskIDs []string, all bool) error {
+	logger := gologger.Get().With().Str("component", "artifacts").Logger()
+
+	store, err := openArtifactStore()
+	if err != nil {
+		return err
+	}
+
+	if all {
+		entries, err := store.List()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			taskIDs = append(taskIDs, entry.TaskID)
+		}
+	}
+
+	for _, taskID := range taskIDs {
+		if err := store.Remove(taskID); err != nil {
+			if errors.Is(err, artifacts.ErrNotFound) {
+				logger.Warn().Str("task_id", taskID).Msg("No artifacts stored for task")
+				continue
+			}
+			return err
+		}
+		logger.Info().Str("task_id", taskID).Msg("Task artifacts removed")
+	}
+	return nil
+}
+
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
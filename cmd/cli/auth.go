@@ -1,13 +1,21 @@
 package cli
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/keyfile"
+	"github.com/theblitlabs/parity-runner/internal/signer"
 	"github.com/theblitlabs/parity-runner/internal/utils"
 )
 
@@ -23,7 +31,30 @@ func RunAuth() {
 				Type:        utils.FlagTypeString,
 				Shorthand:   "k",
 				Description: "Private key in hex format",
-				Required:    true,
+			},
+			"signer": {
+				Type:        utils.FlagTypeString,
+				Description: "Sign with a ledger, trezor or clef remote signer instead of a private key",
+			},
+			"signer-url": {
+				Type:        utils.FlagTypeString,
+				Description: "Endpoint of the remote signer, an IPC path or http(s) URL (--signer clef)",
+			},
+			"derivation-path": {
+				Type:        utils.FlagTypeString,
+				Description: "Derivation path of the hardware wallet account (default: " + signer.DefaultPath + ")",
+			},
+			"chain": {
+				Type:        utils.FlagTypeString,
+				Description: "Chain of the chain registry to use, such as base or polygon (default: BLOCKCHAIN_CHAIN)",
+			},
+			"server-identity": {
+				Type:        utils.FlagTypeString,
+				Description: "Server identity address to pin, obtained out of band",
+			},
+			"keychain": {
+				Type:        utils.FlagTypeBool,
+				Description: "Store the keystore passphrase in the OS keychain so the runner starts without asking for it",
 			},
 		},
 		RunFunc: func(cmd *cobra.Command, args []string) error {
@@ -32,19 +63,49 @@ func RunAuth() {
 			if err != nil {
 				return fmt.Errorf("failed to get private key flag: %w", err)
 			}
+			serverIdentity, err := cmd.Flags().GetString("server-identity")
+			if err != nil {
+				return fmt.Errorf("failed to get server identity flag: %w", err)
+			}
+			signerType, err := cmd.Flags().GetString("signer")
+			if err != nil {
+				return fmt.Errorf("failed to get signer flag: %w", err)
+			}
 
-			return ExecuteAuth(privateKey)
+			if signerType != "" {
+				if privateKey != "" {
+					return fmt.Errorf("pass either --private-key or --signer, not both")
+				}
+				signerURL, err := cmd.Flags().GetString("signer-url")
+				if err != nil {
+					return fmt.Errorf("failed to get signer URL flag: %w", err)
+				}
+				path, err := cmd.Flags().GetString("derivation-path")
+				if err != nil {
+					return fmt.Errorf("failed to get derivation path flag: %w", err)
+				}
+				return ExecuteSignerAuth(signer.Config{Type: signerType, URL: signerURL, Path: path}, serverIdentity)
+			}
+			keychain, err := cmd.Flags().GetBool("keychain")
+			if err != nil {
+				return fmt.Errorf("failed to get keychain flag: %w", err)
+			}
+			return ExecuteAuth(privateKey, serverIdentity, keychain)
 		},
 	}, logger)
 
 	utils.ExecuteCommand(cmd, logger)
 }
 
-func ExecuteAuth(privateKey string) error {
+// ExecuteAuth saves the wallet key, encrypted with a passphrase, and pins the
+// identity of the configured server. serverIdentity, when set, is the address
+// to pin instead of trusting what the server publishes. With keychain the
+// passphrase is also stored in the OS keychain.
+func ExecuteAuth(privateKey, serverIdentity string, keychain bool) error {
 	logger := log.With().Str("component", "auth").Logger()
 
 	if privateKey == "" {
-		return fmt.Errorf("private key is required")
+		return fmt.Errorf("private key is required, or pass --signer to use a hardware wallet or remote signer")
 	}
 
 	cfg, err := utils.GetConfig()
@@ -58,14 +119,29 @@ func ExecuteAuth(privateKey string) error {
 		return fmt.Errorf("invalid private key - must be 64 hex characters without 0x prefix")
 	}
 
-	_, err = crypto.HexToECDSA(privateKey)
+	key, err := crypto.HexToECDSA(privateKey)
 	if err != nil {
 		return fmt.Errorf("invalid private key format: %w", err)
 	}
 
-	if err := utils.SavePrivateKey(privateKey); err != nil {
+	passphrase, err := utils.NewKeyPassphrase()
+	if err != nil {
+		return err
+	}
+	if err := utils.SavePrivateKey(privateKey, passphrase); err != nil {
 		return fmt.Errorf("failed to save private key: %w", err)
 	}
+	// With RUNNER_SECRET_BACKEND=keychain the key itself is in the secret
+	// store and there is no passphrase to keep
+	if keychain && passphrase != "" {
+		if err := keyfile.StorePassphrase(crypto.PubkeyToAddress(key.PublicKey).Hex(), passphrase); err != nil {
+			return err
+		}
+		logger.Info().Msg("Keystore passphrase stored in the OS keychain")
+	}
+	if err := utils.RemoveSignerConfig(); err != nil {
+		return err
+	}
 
 	utils.ResetClient()
 
@@ -76,8 +152,130 @@ func ExecuteAuth(privateKey string) error {
 
 	logger.Info().
 		Str("address", client.Address().Hex()).
-		Str("keystore", fmt.Sprintf("%s/%s", utils.KeystoreDirName, utils.KeystoreFileName)).
+		Str("chain", utils.ChainLabel(cfg)).
+		Str("keystore", keyLocation()).
 		Msg("Wallet authenticated successfully")
 
+	return pinServerIdentity(cfg.Runner.ServerURL, serverIdentity)
+}
+
+// keyLocation describes where SavePrivateKey put the wallet key
+func keyLocation() string {
+	if store, err := utils.GetSecretStore(); err == nil && store != nil {
+		return store.Name()
+	}
+	return fmt.Sprintf("%s/%s", utils.KeystoreDirName, utils.KeystoreFileName)
+}
+
+// ExecuteSignerAuth sets the runner up to sign with a hardware wallet or
+// remote signer, so no key is kept on disk. The signer is opened once to learn
+// and confirm its address.
+func ExecuteSignerAuth(config signer.Config, serverIdentity string) error {
+	logger := log.With().Str("component", "auth").Logger()
+
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	config.Type = strings.ToLower(config.Type)
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if config.Type != signer.TypeClef && config.URL != "" {
+		return fmt.Errorf("--signer-url only applies to --signer %s", signer.TypeClef)
+	}
+	if config.Type == signer.TypeClef && config.Path != "" {
+		return fmt.Errorf("--derivation-path only applies to hardware wallets")
+	}
+
+	switch config.Type {
+	case signer.TypeClef:
+		logger.Info().Str("signer_url", config.URL).Msg("Connecting to the remote signer, approve the request there")
+	default:
+		logger.Info().Msgf("Looking for a %s, unlock it and open its Ethereum app", config.Type)
+	}
+	s, err := signer.Open(config, trezorPIN)
+	if err != nil {
+		return err
+	}
+	config.Address = s.Address().Hex()
+
+	if config.Type == signer.TypeTrezor {
+		logger.Warn().Msg("A Trezor signs transactions but not messages, so the runner will not sign receipts, manifests or benchmark reports")
+	}
+
+	if err := utils.SaveSignerConfig(config); err != nil {
+		return err
+	}
+	utils.ResetClient()
+
+	if key, err := utils.GetPrivateKey(); err == nil && key != nil {
+		logger.Warn().
+			Str("keystore", fmt.Sprintf("%s/%s", utils.KeystoreDirName, utils.KeystoreFileName)).
+			Msg("A private key is still stored on disk; delete the keystore once you no longer need it")
+	}
+
+	logger.Info().
+		Str("address", config.Address).
+		Str("signer", config.String()).
+		Str("chain", utils.ChainLabel(cfg)).
+		Msg("Wallet authenticated successfully")
+
+	return pinServerIdentity(cfg.Runner.ServerURL, serverIdentity)
+}
+
+// trezorPIN asks for the PIN of a Trezor, entered as the positions of its
+// digits on the grid the device shows
+func trezorPIN() (string, error) {
+	fmt.Print("Enter the Trezor PIN as positions on the grid shown by the device (7 8 9 on the top row): ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read PIN: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func pinServerIdentity(serverURL, expected string) error {
+	logger := log.With().Str("component", "auth").Logger()
+
+	path, err := identity.DefaultPinPath()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	published, fetchErr := identity.Fetch(ctx, &http.Client{}, serverURL)
+
+	address := published
+	if expected != "" {
+		verifier, err := identity.NewVerifier(expected)
+		if err != nil {
+			return err
+		}
+		address = verifier.Address()
+		if fetchErr != nil {
+			logger.Warn().Err(fetchErr).Msg("Could not confirm the server identity with the server; pinning the given address")
+		} else if published != address {
+			return fmt.Errorf("server at %s identifies as %s, not %s", serverURL, published, address)
+		}
+	} else if fetchErr != nil {
+		logger.Warn().Err(fetchErr).Str("server_url", serverURL).Msg("Server identity not pinned; task deliveries will not be verified")
+		return nil
+	}
+
+	previous, err := identity.LoadPin(path)
+	if err != nil {
+		return err
+	}
+	if expected == "" && previous != nil && previous.ServerURL == serverURL && previous.Address != address {
+		return fmt.Errorf("server at %s now identifies as %s instead of the pinned %s; pass --server-identity to accept the new identity", serverURL, address, previous.Address)
+	}
+
+	if err := identity.SavePin(path, &identity.Pin{ServerURL: serverURL, Address: address, PinnedAt: time.Now().UTC()}); err != nil {
+		return err
+	}
+	logger.Info().Str("server_url", serverURL).Str("server_identity", address).Msg("Server identity pinned")
 	return nil
 }
@@ -14,6 +14,12 @@ func RunBalance() {
 	cmd := utils.CreateCommand(utils.CommandConfig{
 		Use:   "balance",
 		Short: "Check token balances and stake status",
+		Flags: map[string]utils.Flag{
+			"chain": {
+				Type:        utils.FlagTypeString,
+				Description: "Chain of the chain registry to use, such as base or polygon (default: BLOCKCHAIN_CHAIN)",
+			},
+		},
 		RunFunc: func(cmd *cobra.Command, args []string) error {
 			return executeBalance()
 		},
@@ -33,12 +39,17 @@ func executeBalance() error {
 		return err
 	}
 
-	client, err := utils.NewClient(cfg)
+	client, err := utils.NewReadOnlyClient(cfg)
 	if err != nil {
 		return err
 	}
+	address, err := utils.GetWalletAddress()
+	if err != nil {
+		return err
+	}
+	walletAddress := common.HexToAddress(address)
 
-	walletBalance, err := client.GetBalance(client.Address())
+	walletBalance, err := client.GetBalance(walletAddress)
 	if err != nil {
 		utils.HandleContextFatal(logger, ctx, err,
 			"Operation timed out while getting wallet balance",
@@ -46,12 +57,10 @@ func executeBalance() error {
 		return err
 	}
 
-	tokenSymbol := cfg.Blockchain.TokenSymbol
-	if tokenSymbol == "" {
-		tokenSymbol = "TOKEN"
-	}
+	tokenSymbol := utils.TokenLabel(cfg)
 	logger.Info().
-		Str("wallet_address", client.Address().Hex()).
+		Str("wallet_address", walletAddress.Hex()).
+		Str("chain", utils.ChainLabel(cfg)).
 		Str("balance", walletBalance.String()+" "+tokenSymbol).
 		Msg("Wallet token balance")
 
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// ExecuteBenchSandbox measures the container overhead of the configured runtime
+// and, with save set, keeps the result for the runner to publish when it
+// registers
+func ExecuteBenchSandbox(config docker.BenchConfig, save bool) error {
+	logger := gologger.Get().With().Str("component", "bench").Logger()
+
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	runtime, err := sandbox.ParseRuntime(cfg.Runner.ContainerRuntime)
+	if err != nil {
+		return err
+	}
+
+	bench, err := docker.NewBench(sandbox.RuntimeEngine(runtime), config)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := bench.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Engine: %s\n", result.Engine)
+	fmt.Printf("Image:  %s\n\n", result.Image)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINERS\tCREATE\tSTART\tWAIT\tREMOVE\tSECCOMP\tSTATS SAMPLE\tSTATS SLOWDOWN")
+	for _, run := range result.Runs {
+		fmt.Fprintf(w, "%d\t%.1f ms\t%.1f ms\t%.1f ms\t%.1f ms\t%+.1f ms\t%.1f ms\t%+.1f%%\n",
+			run.Containers, run.CreateMs, run.StartMs, run.WaitMs, run.RemoveMs,
+			run.SeccompOverheadMs, run.StatsSampleMs, run.StatsSlowdown*100)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !save {
+		return nil
+	}
+	if err := docker.SaveBenchmark(result); err != nil {
+		return err
+	}
+	logger.Info().Msg("Benchmark saved, the runner publishes it when it registers")
+	return nil
+}
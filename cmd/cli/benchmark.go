@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/benchmark"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// BenchmarkOptions choose what ExecuteBenchmark does with the report besides
+// printing it
+type BenchmarkOptions struct {
+	NoGPU  bool
+	Save   bool
+	Submit bool
+}
+
+// ExecuteBenchmark scores this machine's hardware, signs the report with the
+// runner's wallet key and, as asked, keeps it for the runner to send when it
+// registers and submits it to the server right away
+func ExecuteBenchmark(config benchmark.Config, opts BenchmarkOptions) error {
+	logger := gologger.Get().With().Str("component", "benchmark").Logger()
+
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	deviceID, err := utils.GetDeviceID()
+	if err != nil {
+		return fmt.Errorf("failed to get device ID: %w", err)
+	}
+	walletSigner, err := utils.GetSigner()
+	if err != nil {
+		return fmt.Errorf("failed to load wallet signer, run auth first: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !opts.NoGPU {
+		runtime, err := sandbox.ParseRuntime(cfg.Runner.ContainerRuntime)
+		if err != nil {
+			return err
+		}
+		config.Runtime = sandbox.RuntimeEngine(runtime).Command
+		if config.GPUs, err = sandbox.DetectGPUs(ctx, runtime); err != nil {
+			logger.Warn().Err(err).Msg("GPUs unavailable to containers, benchmarking without them")
+		}
+	}
+
+	logger.Info().Msg("Benchmarking CPU, memory and disk")
+	report, err := benchmark.Run(ctx, config)
+	if err != nil {
+		return err
+	}
+	report.DeviceID = deviceID
+	if err := benchmark.SignWith(report, walletSigner); err != nil {
+		return err
+	}
+
+	if err := printBenchmark(report); err != nil {
+		return err
+	}
+
+	if opts.Save {
+		if err := benchmark.Save(report); err != nil {
+			return err
+		}
+		logger.Info().Msg("Benchmark saved, the runner sends it when it registers")
+	}
+	if opts.Submit {
+		if err := submitBenchmark(ctx, cfg.Runner.ServerURL, report); err != nil {
+			return err
+		}
+		logger.Info().Str("server_url", cfg.Runner.ServerURL).Msg("Benchmark submitted")
+	}
+	return nil
+}
+
+func printBenchmark(report *models.BenchmarkReport) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PART\tMEASURED\tSCORE")
+	fmt.Fprintf(w, "CPU\t%.0f MB/s on 1 core, %.0f MB/s on %d\t%.1f\n",
+		report.CPU.SingleCoreMBps, report.CPU.MultiCoreMBps, report.CPU.Cores, report.Scores.CPU)
+	fmt.Fprintf(w, "Memory\t%.1f GB/s copy\t%.1f\n", report.Memory.CopyGBps, report.Scores.Memory)
+	fmt.Fprintf(w, "Disk\t%.0f MB/s write, %.0f MB/s read\t%.1f\n",
+		report.Disk.WriteMBps, report.Disk.ReadMBps, report.Scores.Disk)
+	if report.GPU != nil {
+		fmt.Fprintf(w, "GPU\t%.0f GFLOP/s on %d x %s\t%.1f\n",
+			report.GPU.GFLOPS, report.GPU.Count, report.GPU.Model, report.Scores.GPU)
+	}
+	fmt.Fprintf(w, "Overall\t\t%.1f\n", report.Scores.Overall)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nThe reference machine scores 1000. Servers that weigh rewards by benchmark pay this runner %.0f%% of each reward.\n",
+		report.Scores.RewardWeight()*100)
+	return nil
+}
+
+func submitBenchmark(ctx context.Context, serverURL string, report *models.BenchmarkReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal benchmark report: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, serverURL+"/api/v1/runners/benchmarks", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Device-ID", report.DeviceID)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to submit benchmark: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("server rejected benchmark: %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
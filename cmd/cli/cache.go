@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/datacache"
+)
+
+func openDatasetCache() (*datacache.Cache, error) {
+	dir, err := datacache.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return datacache.New(dir, 0), nil
+}
+
+func ExecuteCacheList() error {
+	cache, err := openDatasetCache()
+	if err != nil {
+		return err
+	}
+
+	entries, err := cache.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No datasets cached in %s\n", cache.Dir())
+		return nil
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CID\tSIZE\tADDED\tLAST USED")
+	for _, entry := range entries {
+		total += entry.Size
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.CID, formatSize(entry.Size),
+			entry.AddedAt.Local().Format(time.DateTime), entry.LastUsed.Local().Format(time.DateTime))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d datasets, %s in %s\n", len(entries), formatSize(total), cache.Dir())
+	return nil
+}
+
+// ExecuteCacheVerify checks every cached dataset against its recorded hash and
+// removes the corrupt ones
+func ExecuteCacheVerify() error {
+	logger := gologger.Get().With().Str("component", "cache").Logger()
+
+	cache, err := openDatasetCache()
+	if err != nil {
+		return err
+	}
+	entries, err := cache.List()
+	if err != nil {
+		return err
+	}
+
+	var corrupt int
+	for _, entry := range entries {
+		err := cache.Verify(entry.CID)
+		switch {
+		case err == nil:
+			logger.Info().Str("cid", entry.CID).Msg("Dataset intact")
+		case errors.Is(err, datacache.ErrCorrupt):
+			corrupt++
+			logger.Warn().Err(err).Str("cid", entry.CID).Msg("Corrupt dataset removed")
+		case errors.Is(err, datacache.ErrNotFound):
+		default:
+			return err
+		}
+	}
+	if corrupt > 0 {
+		return fmt.Errorf("%d of %d cached datasets were corrupt", corrupt, len(entries))
+	}
+	return nil
+}
+
+// ExecuteCachePurge removes the given datasets, or all of them when no CID is given
+func ExecuteCachePurge(cids []string) error {
+	logger := gologger.Get().With().Str("component", "cache").Logger()
+
+	cache, err := openDatasetCache()
+	if err != nil {
+		return err
+	}
+
+	if len(cids) == 0 {
+		count, freed, err := cache.Purge()
+		if err != nil {
+			return err
+		}
+		logger.Info().Int("datasets", count).Str("freed", formatSize(freed)).Msg("Dataset cache purged")
+		return nil
+	}
+
+	for _, cid := range cids {
+		if err := cache.Remove(cid); err != nil {
+			return err
+		}
+		logger.Info().Str("cid", cid).Msg("Cached dataset removed")
+	}
+	return nil
+}
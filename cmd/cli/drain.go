@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/runner"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	runnerPIDFileName    = "runner.pid"
+	drainRequestFileName = "drain-request"
+	// drainRequestInterval is how often the runner looks for a drain request
+	drainRequestInterval = 2 * time.Second
+)
+
+func runnerFile(name string) (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, name), nil
+}
+
+// watchDrain records the runner's PID for 'parity-runner drain' and drains
+// and exits the runner when asked to, by SIGUSR1 or a drain request. The
+// returned function removes the PID file again.
+func watchDrain(runnerService *runner.Service, cfg *config.Config) func() {
+	logger := gologger.Get().With().Str("component", "cli").Logger()
+
+	pidFile, err := runnerFile(runnerPIDFileName)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(pidFile), 0o700)
+	}
+	if err == nil {
+		err = os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o600)
+	}
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to write PID file, 'parity-runner drain' will not find this runner")
+	}
+	requestFile, _ := runnerFile(drainRequestFileName)
+	// A request left by a runner that exited before seeing it is stale
+	_ = os.Remove(requestFile)
+
+	release := func() {
+		_ = os.Remove(pidFile)
+	}
+
+	signals := make(chan os.Signal, 1)
+	if len(drainSignals) > 0 {
+		signal.Notify(signals, drainSignals...)
+	}
+	go func() {
+		ticker := time.NewTicker(drainRequestInterval)
+		defer ticker.Stop()
+		for {
+			timeout := drainTimeout(runnerService, cfg)
+			select {
+			case sig := <-signals:
+				logger.Info().Str("signal", sig.String()).Msg("Drain signal received")
+			case <-ticker.C:
+				requested, ok := readDrainRequest(requestFile)
+				if !ok {
+					continue
+				}
+				if requested > 0 {
+					timeout = requested
+				}
+				logger.Info().Msg("Drain requested")
+			}
+			drainAndExit(runnerService, timeout, release)
+		}
+	}()
+	return release
+}
+
+// drainTimeout is RUNNER_DRAIN_TIMEOUT, or the watchdog's drain timeout
+func drainTimeout(runnerService *runner.Service, cfg *config.Config) time.Duration {
+	if cfg.Runner.DrainTimeout > 0 {
+		return cfg.Runner.DrainTimeout
+	}
+	return runnerService.DrainTimeout()
+}
+
+// readDrainRequest consumes a drain request, which holds the timeout to use or
+// nothing for the configured one
+func readDrainRequest(path string) (time.Duration, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	_ = os.Remove(path)
+	timeout, err := time.ParseDuration(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, true
+	}
+	return timeout, true
+}
+
+// drainAndExit lets running tasks finish for up to timeout, stops the runner
+// and exits. Exiting on a drain is not a failure, even when tasks were still
+// running at the timeout.
+func drainAndExit(runnerService *runner.Service, timeout time.Duration, release func()) {
+	logger := gologger.Get().With().Str("component", "cli").Logger()
+
+	logger.Info().Dur("timeout", timeout).Msg("Draining the runner before exiting")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if err := runnerService.Drain(ctx); err != nil {
+		logger.Warn().Err(err).Msg("Drain timed out, stopping anyway")
+	}
+	cancel()
+
+	shutdownCtx, shutdownCancel := utils.WithTimeout()
+	defer shutdownCancel()
+	if err := runnerService.Stop(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("Error during runner service shutdown")
+	}
+	release()
+	logger.Info().Msg("Runner drained and stopped")
+	os.Exit(0)
+}
+
+// ExecuteDrain asks the runner of this instance to stop taking tasks, finish
+// the ones it runs within timeout, or RUNNER_DRAIN_TIMEOUT when zero, and
+// exit. With wait it returns once the runner has exited.
+func ExecuteDrain(timeout time.Duration, wait bool) error {
+	logger := gologger.Get().With().Str("component", "drain").Logger()
+
+	pidFile, err := runnerFile(runnerPIDFileName)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(pidFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("no runner is running for this instance")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read PID file: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid PID file %s: %w", pidFile, err)
+	}
+	if !processAlive(pid) {
+		_ = os.Remove(pidFile)
+		return fmt.Errorf("runner %d is no longer running", pid)
+	}
+
+	requestFile, err := runnerFile(drainRequestFileName)
+	if err != nil {
+		return err
+	}
+	var request string
+	if timeout > 0 {
+		request = timeout.String()
+	}
+	if err := os.WriteFile(requestFile, []byte(request), 0o600); err != nil {
+		return fmt.Errorf("failed to request drain: %w", err)
+	}
+	logger.Info().Int("pid", pid).Msg("Drain requested, the runner stops taking tasks")
+	if !wait {
+		return nil
+	}
+
+	// The runner removes its PID file as it exits
+	for processAlive(pid) {
+		if _, err := os.Stat(pidFile); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	logger.Info().Int("pid", pid).Msg("Runner drained and exited")
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package cli
+
+import "os"
+
+// drainSignals is empty where there is no SIGUSR1; 'parity-runner drain' still
+// works through its request file
+var drainSignals []os.Signal
+
+// processAlive relies on FindProcess, which opens the process on Windows and
+// fails when it has exited
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+	return true
+}
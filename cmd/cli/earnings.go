@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/federation"
+	"github.com/theblitlabs/parity-runner/internal/ledger"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// ExecuteEarnings prints what every configured coordinator reports this runner
+// has earned, and the totals across them
+func ExecuteEarnings() error {
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	coordinators, err := federation.ParseCoordinators(cfg.Runner.ServerURL, cfg.Runner.Federation.Coordinators)
+	if err != nil {
+		return err
+	}
+	deviceID, err := utils.GetDeviceID()
+	if err != nil {
+		return fmt.Errorf("failed to get device ID: %w", err)
+	}
+
+	ctx, cancel := utils.WithTimeout()
+	defer cancel()
+	summary := federation.FetchEarnings(ctx, &http.Client{Timeout: 15 * time.Second}, coordinators, deviceID)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COORDINATOR\tPAID\tPAYOUTS\tPENDING")
+	for _, report := range summary.Coordinators {
+		if report.Earnings == nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t(%s)\n", report.Coordinator, report.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%.4f\t%d\t%.4f\n", report.Coordinator,
+			report.Earnings.PaidTotal, report.Earnings.PaidCount, report.Earnings.PendingTotal)
+	}
+	fmt.Fprintf(w, "TOTAL\t%.4f\t%d\t%.4f\n", summary.PaidTotal, summary.PaidCount, summary.PendingTotal)
+	return w.Flush()
+}
+
+// EarningsReportOptions selects the earnings report to export
+type EarningsReportOptions struct {
+	// Wallet defaults to the wallet of this runner
+	Wallet string
+	// Month is YYYY-MM, the current month when empty
+	Month  string
+	Format string
+	// Output is the file to write, stdout when empty
+	Output string
+}
+
+// ExecuteEarningsReport exports what every runner of a wallet was paid in a
+// month by all configured coordinators, one row per reward
+func ExecuteEarningsReport(opts EarningsReportOptions) error {
+	if opts.Format != "csv" && opts.Format != "json" {
+		return fmt.Errorf("unknown report format %q, expected csv or json", opts.Format)
+	}
+	month, err := models.ParseReportMonth(opts.Month, time.Now())
+	if err != nil {
+		return err
+	}
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	coordinators, err := federation.ParseCoordinators(cfg.Runner.ServerURL, cfg.Runner.Federation.Coordinators)
+	if err != nil {
+		return err
+	}
+	wallet := opts.Wallet
+	if wallet == "" {
+		if wallet, err = utils.GetWalletAddress(); err != nil {
+			return fmt.Errorf("failed to get wallet address, pass --wallet: %w", err)
+		}
+	}
+
+	ctx, cancel := utils.WithTimeout()
+	defer cancel()
+	report, err := federation.FetchEarningsReport(ctx, &http.Client{Timeout: 30 * time.Second}, coordinators, wallet, month)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if opts.Output != "" {
+		file, err := os.Create(opts.Output)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if opts.Format == "csv" {
+		err = report.WriteCSV(out)
+	} else {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write earnings report: %w", err)
+	}
+
+	if opts.Output != "" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DEVICE\tPAYOUTS\tAMOUNT\tFIAT")
+		for _, device := range report.Devices {
+			fmt.Fprintf(w, "%s\t%d\t%.4f\t%.2f %s\n", device.DeviceID, device.Payouts, device.Amount, device.FiatValue, report.Currency)
+		}
+		fmt.Fprintf(w, "TOTAL\t%d\t%.4f\t%.2f %s\n", report.Payouts, report.TotalAmount, report.TotalFiatValue, report.Currency)
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if report.Unpriced > 0 {
+			fmt.Printf("%d payouts have no fiat value, no token price was known when they were paid\n", report.Unpriced)
+		}
+	}
+	return nil
+}
+
+// LedgerOptions selects the part of the local earnings ledger to show or export
+type LedgerOptions struct {
+	// Since and Until are YYYY-MM-DD dates in UTC, Until exclusive. Empty
+	// leaves that side open.
+	Since string
+	Until string
+	// Sync first fills in the payouts coordinators report for unpaid tasks
+	Sync bool
+}
+
+func (o LedgerOptions) bounds() (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if o.Since != "" {
+		if from, err = time.Parse(time.DateOnly, o.Since); err != nil {
+			return from, to, fmt.Errorf("invalid --since %q, expected YYYY-MM-DD", o.Since)
+		}
+	}
+	if o.Until != "" {
+		if to, err = time.Parse(time.DateOnly, o.Until); err != nil {
+			return from, to, fmt.Errorf("invalid --until %q, expected YYYY-MM-DD", o.Until)
+		}
+	}
+	return from, to, nil
+}
+
+// loadLedger reads the ledger entries selected by opts, syncing payouts first
+// when asked to
+func loadLedger(opts LedgerOptions) ([]ledger.Entry, error) {
+	from, to, err := opts.bounds()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ledger.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	store := ledger.NewStore(dir)
+	if opts.Sync {
+		if err := syncLedger(store); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	return ledger.Filter(entries, from, to), nil
+}
+
+// syncLedger fetches the payouts of this runner from every coordinator for
+// each month since the oldest unpaid task, and records them in the ledger
+func syncLedger(store *ledger.Store) error {
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+	var oldest time.Time
+	for _, entry := range entries {
+		if entry.Status == models.TaskStatusCompleted && !entry.Paid() && (oldest.IsZero() || entry.CompletedAt.Before(oldest)) {
+			oldest = entry.CompletedAt
+		}
+	}
+	if oldest.IsZero() {
+		return nil
+	}
+
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	coordinators, err := federation.ParseCoordinators(cfg.Runner.ServerURL, cfg.Runner.Federation.Coordinators)
+	if err != nil {
+		return err
+	}
+	wallet, err := utils.GetWalletAddress()
+	if err != nil {
+		return fmt.Errorf("failed to get wallet address: %w", err)
+	}
+	deviceID, err := utils.GetDeviceID()
+	if err != nil {
+		return fmt.Errorf("failed to get device ID: %w", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	var rewards []models.PaidReward
+	now := time.Now().UTC()
+	for month := time.Date(oldest.Year(), oldest.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(now); month = month.AddDate(0, 1, 0) {
+		ctx, cancel := utils.WithTimeout()
+		report, err := federation.FetchEarningsReport(ctx, client, coordinators, wallet, month)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to fetch payouts of %s: %w", month.Format("2006-01"), err)
+		}
+		for _, reward := range report.Rewards {
+			if reward.DeviceID == deviceID {
+				rewards = append(rewards, reward)
+			}
+		}
+	}
+
+	updated, err := store.Reconcile(rewards)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Found payouts for %d tasks\n", updated)
+	return nil
+}
+
+// ExecuteLedger prints the local earnings ledger summarized by day or week
+func ExecuteLedger(period string, opts LedgerOptions) error {
+	p, err := ledger.ParsePeriod(period)
+	if err != nil {
+		return err
+	}
+	entries, err := loadLedger(opts)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No tasks recorded")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.ToUpper(string(p))+"\tTASKS\tCOMPLETED\tFAILED\tREWARD\tPAID\tUNPAID\tCPU SECONDS\tRUN TIME")
+	var total ledger.Summary
+	for _, summary := range ledger.Summarize(entries, p) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.4f\t%.4f\t%d\t%.1f\t%s\n", summary.Start.Format(time.DateOnly),
+			summary.Tasks, summary.Completed, summary.Failed, summary.Reward, summary.Paid, summary.Unpaid,
+			summary.CPUSeconds, time.Duration(summary.ExecutionTime)*time.Millisecond)
+		total.Tasks += summary.Tasks
+		total.Completed += summary.Completed
+		total.Failed += summary.Failed
+		total.Reward += summary.Reward
+		total.Paid += summary.Paid
+		total.Unpaid += summary.Unpaid
+		total.CPUSeconds += summary.CPUSeconds
+		total.ExecutionTime += summary.ExecutionTime
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%.4f\t%.4f\t%d\t%.1f\t%s\n", total.Tasks, total.Completed, total.Failed,
+		total.Reward, total.Paid, total.Unpaid, total.CPUSeconds, time.Duration(total.ExecutionTime)*time.Millisecond)
+	return w.Flush()
+}
+
+// ExecuteLedgerExport writes the local earnings ledger as CSV, one row per
+// task, to output or stdout when empty
+func ExecuteLedgerExport(output string, opts LedgerOptions) error {
+	entries, err := loadLedger(opts)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := ledger.WriteCSV(out, entries); err != nil {
+		return fmt.Errorf("failed to write ledger export: %w", err)
+	}
+	if output != "" {
+		fmt.Printf("Exported %d tasks to %s\n", len(entries), output)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	walletsdk "github.com/theblitlabs/go-wallet-sdk"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const faucetConfirmTimeout = 3 * time.Minute
+
+// ExecuteFaucet requests test tokens for the runner wallet and optionally stakes
+// part of them. It only works on testnet so it can never touch real funds.
+func ExecuteFaucet(stakeAmount float64) error {
+	logger := gologger.Get().With().Str("component", "faucet").Logger()
+
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return err
+	}
+	if !cfg.IsTestnet() {
+		return fmt.Errorf("the faucet is only available with --network testnet")
+	}
+
+	client, err := utils.NewClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create wallet client: %w", err)
+	}
+
+	deviceID, err := utils.GetDeviceID()
+	if err != nil {
+		return fmt.Errorf("failed to get device ID: %w", err)
+	}
+
+	token, err := walletsdk.NewParityToken(common.HexToAddress(cfg.Blockchain.TokenAddress), client)
+	if err != nil {
+		return fmt.Errorf("failed to create token contract: %w", err)
+	}
+
+	before, err := token.BalanceOf(&bind.CallOpts{}, client.Address())
+	if err != nil {
+		return fmt.Errorf("failed to check token balance: %w", err)
+	}
+
+	tokenLabel := utils.TokenLabel(cfg)
+	faucetURL := utils.FaucetURL(cfg)
+	logger.Info().
+		Str("wallet", client.Address().Hex()).
+		Str("faucet", faucetURL).
+		Msg("Requesting test tokens...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), faucetConfirmTimeout)
+	defer cancel()
+
+	grant, err := utils.RequestFaucet(ctx, faucetURL, client.Address().Hex(), deviceID)
+	if err != nil {
+		return err
+	}
+
+	logger.Info().
+		Str("amount", fmt.Sprintf("%g %s", grant.Amount, tokenLabel)).
+		Str("tx_hash", grant.TxHash).
+		Msg("Faucet transfer submitted - waiting for the tokens to arrive...")
+
+	balance, err := waitForBalanceAbove(ctx, token, client.Address(), before)
+	if err != nil {
+		return err
+	}
+
+	logger.Info().
+		Str("balance", utils.FormatEther(balance)+" "+tokenLabel).
+		Msg("Test tokens received")
+
+	if stakeAmount <= 0 {
+		return nil
+	}
+	return executeStake(stakeAmount, false)
+}
+
+func waitForBalanceAbove(ctx context.Context, token *walletsdk.ParityToken, address common.Address, previous *big.Int) (*big.Int, error) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		balance, err := token.BalanceOf(&bind.CallOpts{Context: ctx}, address)
+		if err == nil && balance.Cmp(previous) > 0 {
+			return balance, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("test tokens did not arrive within %s, check the faucet transaction", faucetConfirmTimeout)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/keyfile"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// ExecuteKeystoreMigrate encrypts a wallet key older runners stored in plain
+// text. With keychain the passphrase is stored in the OS keychain as well,
+// which also works for a keystore that is already encrypted. When
+// RUNNER_SECRET_BACKEND is keychain the key is moved out of the keystore file
+// into the OS secret store instead.
+func ExecuteKeystoreMigrate(keychain bool) error {
+	logger := gologger.Get().With().Str("component", "keystore").Logger()
+
+	ks, err := utils.GetKeystore()
+	if err != nil {
+		return err
+	}
+	address, err := ks.Address()
+	if err != nil {
+		return err
+	}
+	encrypted, err := ks.Encrypted()
+	if err != nil {
+		return err
+	}
+
+	store, err := utils.GetSecretStore()
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		var passphrase string
+		if encrypted {
+			if passphrase, err = keyfile.Passphrase(address.Hex()); err != nil {
+				return err
+			}
+		}
+		key, err := ks.Load(passphrase)
+		if err != nil {
+			return err
+		}
+		// SavePrivateKey removes the keystore file once the key is stored
+		if err := utils.SavePrivateKey(common.Bytes2Hex(crypto.FromECDSA(key)), ""); err != nil {
+			return fmt.Errorf("failed to move wallet key: %w", err)
+		}
+		logger.Info().Str("address", address.Hex()).Str("store", store.Name()).Msg("Wallet key moved to the OS secret store")
+		return nil
+	}
+
+	var passphrase string
+	if encrypted {
+		logger.Info().Str("keystore", ks.Path()).Msg("The wallet key is already encrypted")
+		if !keychain {
+			return nil
+		}
+		if passphrase, err = keyfile.Passphrase(address.Hex()); err != nil {
+			return err
+		}
+		// Only a passphrase that opens the key is worth keeping
+		if _, err := ks.Load(passphrase); err != nil {
+			return err
+		}
+	} else {
+		if passphrase, err = keyfile.NewPassphrase(); err != nil {
+			return err
+		}
+		if _, err := ks.Migrate(passphrase); err != nil {
+			return err
+		}
+		logger.Info().Str("keystore", ks.Path()).Str("address", address.Hex()).Msg("Wallet key encrypted")
+	}
+
+	if keychain {
+		if err := keyfile.StorePassphrase(address.Hex(), passphrase); err != nil {
+			return err
+		}
+		logger.Info().Msg("Keystore passphrase stored in the OS keychain")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/receipt"
+)
+
+func ExecuteReceiptVerify(path, runnerAddress, serverAddress string) error {
+	logger := gologger.Get().With().Str("component", "receipt").Logger()
+
+	executionReceipt, err := loadReceipt(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(receipt.Format(executionReceipt))
+
+	signers := receipt.Signers{Runner: runnerAddress, Server: serverAddress}
+	if err := receipt.VerifySigners(executionReceipt, signers); err != nil {
+		logger.Error().Err(err).Str("task_id", executionReceipt.TaskID.String()).Msg("Receipt verification failed")
+		return fmt.Errorf("receipt verification failed: %w", err)
+	}
+
+	if signers.Runner == "" && signers.Server == "" {
+		// Anyone can sign a receipt with their own key and name themselves in it
+		logger.Warn().
+			Str("task_id", executionReceipt.TaskID.String()).
+			Msg("No --runner-address or --server-address given, the signers named in the receipt were not checked")
+	}
+
+	if executionReceipt.ServerSignature == "" {
+		logger.Warn().
+			Str("task_id", executionReceipt.TaskID.String()).
+			Str("runner", executionReceipt.RunnerAddress).
+			Msg("Runner signature valid, receipt has no server countersignature")
+		return nil
+	}
+
+	logger.Info().
+		Str("task_id", executionReceipt.TaskID.String()).
+		Str("runner", executionReceipt.RunnerAddress).
+		Str("server", executionReceipt.ServerAddress).
+		Msg("Receipt signatures are valid")
+	return nil
+}
+
+func ExecuteReceiptShow(ref string) error {
+	executionReceipt, err := loadReceipt(ref)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(receipt.Format(executionReceipt))
+	return nil
+}
+
+// loadReceipt accepts either a receipt file path or the ID of a task receipt stored locally
+func loadReceipt(ref string) (*models.ExecutionReceipt, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return receipt.Load(ref)
+	}
+
+	dir, err := receipt.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return receipt.Load(filepath.Join(dir, ref+".json"))
+}
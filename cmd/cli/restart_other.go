@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cli
+
+import "fmt"
+
+// restartProcess is not supported here; the runner exits and is expected to be
+// restarted by its service manager
+func restartProcess() error {
+	return fmt.Errorf("restarting in place is not supported on this platform")
+}
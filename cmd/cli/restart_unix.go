@@ -0,0 +1,19 @@
+//go:build unix
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// restartProcess replaces the runner with a fresh copy of itself, keeping the
+// PID so service managers do not notice
+func restartProcess() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find runner executable: %w", err)
+	}
+	return syscall.Exec(executable, os.Args, os.Environ())
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/outbox"
+	"github.com/theblitlabs/parity-runner/internal/runner"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+func openOutbox() (*outbox.Store, error) {
+	dir, err := outbox.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return outbox.NewStore(dir), nil
+}
+
+// ExecuteResultsList lists the results waiting in the outbox and the dead
+// letters
+func ExecuteResultsList() error {
+	store, err := openOutbox()
+	if err != nil {
+		return err
+	}
+	queued, err := store.List()
+	if err != nil {
+		return err
+	}
+	dead, err := store.Dead()
+	if err != nil {
+		return err
+	}
+	if len(queued) == 0 && len(dead) == 0 {
+		fmt.Println("No undelivered task results.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK ID\tSTATUS\tQUEUED\tATTEMPTS\tSTATE\tLAST ERROR")
+	for _, entry := range append(queued, dead...) {
+		state := "pending"
+		if entry.Reason != "" {
+			state = "dead (" + entry.Reason + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			entry.TaskID, entry.Status, entry.QueuedAt.Local().Format(time.DateTime),
+			entry.Attempts, state, entry.LastError)
+	}
+	return w.Flush()
+}
+
+// ExecuteResultsFlush delivers the results in the outbox now rather than on
+// the runner's next attempt, after queueing the dead letters again when
+// withDead is set
+func ExecuteResultsFlush(withDead bool) error {
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	store, err := openOutbox()
+	if err != nil {
+		return err
+	}
+
+	if withDead {
+		revived, err := store.Revive()
+		if err != nil {
+			return err
+		}
+		if revived > 0 {
+			fmt.Printf("Queued %d dead letters again\n", revived)
+		}
+	}
+
+	// A result the operator asked to deliver is offered however old it is
+	report, err := store.Deliver(runner.NewHTTPTaskClient(cfg.Runner.ServerURL), 0, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Delivered %d results, %d still pending, %d rejected\n", report.Delivered, report.Pending, report.Rejected)
+	if report.Pending > 0 {
+		return fmt.Errorf("%d results could not be delivered, the server may be unreachable", report.Pending)
+	}
+	return nil
+}
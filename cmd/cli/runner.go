@@ -13,8 +13,11 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/theblitlabs/gologger"
 
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/execution/llm"
 	"github.com/theblitlabs/parity-runner/internal/runner"
 	"github.com/theblitlabs/parity-runner/internal/utils"
+	"github.com/theblitlabs/parity-runner/internal/watchdog"
 )
 
 func checkPortAvailable(port int) error {
@@ -26,6 +29,36 @@ func checkPortAvailable(port int) error {
 	return nil
 }
 
+// resolveWebhookPort keeps the configured webhook port when it is free. Named instances
+// and a port of 0 fall back to the next free port so several runners can share a host.
+// With a randomized webhook endpoint a random free port is always used.
+func resolveWebhookPort(cfg *config.Config) error {
+	if cfg.Runner.WebhookPort > 0 && utils.Instance() == "" && !cfg.Runner.WebhookRandomize {
+		return checkPortAvailable(cfg.Runner.WebhookPort)
+	}
+
+	preferred := cfg.Runner.WebhookPort
+	if cfg.Runner.WebhookRandomize {
+		preferred = 0
+	}
+
+	port, err := utils.FindAvailablePort(preferred)
+	if err != nil {
+		return err
+	}
+
+	if port != cfg.Runner.WebhookPort {
+		logger := gologger.Get().With().Str("component", "cli").Logger()
+		logger.Info().
+			Str("instance", utils.Instance()).
+			Int("configured_port", cfg.Runner.WebhookPort).
+			Int("port", port).
+			Msg("Selected free webhook port")
+		cfg.Runner.WebhookPort = port
+	}
+	return nil
+}
+
 func checkServerConnectivity(serverURL string) error {
 	client := &http.Client{
 		Timeout: 5 * time.Second,
@@ -71,6 +104,65 @@ func waitForServerConnectivity(ctx context.Context, serverURL string, retryInter
 	}
 }
 
+// restartOnEscalation restarts the runner once one of its subsystems keeps
+// dying after the supervisor restarted it, or once the watchdog finds it
+// leaking, after letting running tasks finish
+func restartOnEscalation(runnerService *runner.Service) {
+	logger := gologger.Get().With().Str("component", "cli").Logger()
+
+	runnerService.OnEscalate(func(name string, err error) {
+		logger.Error().Err(err).Str("subsystem", name).Msg("Subsystem keeps failing, restarting the runner")
+		restartRunner(runnerService, utils.DefaultTimeout)
+	})
+	runnerService.OnWatchdogBreach(func(breach watchdog.Breach) {
+		logger.Error().
+			Strs("exceeded", breach.Exceeded).
+			Str("diagnostics", breach.Diagnostics).
+			Dur("drain_timeout", runnerService.DrainTimeout()).
+			Msg("Runner resource use keeps growing, draining tasks and restarting the runner")
+
+		// No new tasks are taken while the running ones finish
+		ctx, cancel := context.WithTimeout(context.Background(), runnerService.DrainTimeout())
+		if err := runnerService.Drain(ctx); err != nil {
+			logger.Warn().Err(err).Msg("Drain timed out, restarting anyway")
+		}
+		cancel()
+		restartRunner(runnerService, utils.DefaultTimeout)
+	})
+}
+
+// restartRunner stops the runner, waiting up to timeout for running tasks, and
+// replaces the process with a fresh one
+func restartRunner(runnerService *runner.Service, timeout time.Duration) {
+	logger := gologger.Get().With().Str("component", "cli").Logger()
+
+	shutdownCtx, shutdownCancel := utils.WithCustomTimeout(timeout)
+	defer shutdownCancel()
+	if err := runnerService.Stop(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("Error during runner service shutdown")
+	}
+
+	if err := restartProcess(); err != nil {
+		logger.Error().Err(err).Msg("Failed to restart the runner, exiting")
+	}
+	os.Exit(1)
+}
+
+// removeStaleOllama removes the Ollama container an earlier LLM run of this
+// instance left behind, since a plain runner serves no LLM tasks
+func removeStaleOllama(ctx context.Context) {
+	logger := gologger.Get().With().Str("component", "cli").Logger()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	removed, err := llm.NewOllamaManager("", nil).RemoveStaleContainer(ctx)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to remove stale Ollama container")
+	} else if removed {
+		logger.Info().Msg("Removed Ollama container left by an earlier LLM run")
+	}
+}
+
 func RunRunner() {
 	logger := gologger.Get().With().Str("component", "cli").Logger()
 
@@ -102,7 +194,7 @@ func executeRunner() error {
 		return err
 	}
 
-	if err := checkPortAvailable(cfg.Runner.WebhookPort); err != nil {
+	if err := resolveWebhookPort(cfg); err != nil {
 		logger.Fatal().Err(err).Int("port", cfg.Runner.WebhookPort).Msg("Webhook port is not available")
 		return err
 	}
@@ -121,6 +213,8 @@ func executeRunner() error {
 	}
 
 	runnerService.SetHeartbeatInterval(cfg.Runner.HeartbeatInterval)
+
+	removeStaleOllama(ctx)
 	logger.Debug().Dur("interval", cfg.Runner.HeartbeatInterval).Msg("Configured heartbeat interval")
 
 	deviceID, err := utils.GetDeviceID()
@@ -136,12 +230,14 @@ func executeRunner() error {
 		return err
 	}
 
+	restartOnEscalation(runnerService)
 	if err := runnerService.Start(); err != nil {
 		logger.Fatal().Err(err).Msg("Failed to start runner service")
 		return err
 	}
 
 	logger.Info().Msg("Runner service started successfully")
+	releasePID := watchDrain(runnerService, cfg)
 
 	// Signal handling with force exit capability
 	signalCount := 0
@@ -171,6 +267,7 @@ func executeRunner() error {
 					} else {
 						logger.Info().Msg("Runner service stopped successfully")
 					}
+					releasePID()
 					os.Exit(0)
 				}()
 
@@ -224,7 +321,7 @@ func executeRunnerWithLLM(models []string, ollamaURL string, autoInstall bool) e
 		return err
 	}
 
-	if err := checkPortAvailable(cfg.Runner.WebhookPort); err != nil {
+	if err := resolveWebhookPort(cfg); err != nil {
 		logger.Fatal().Err(err).Int("port", cfg.Runner.WebhookPort).Msg("Webhook port is not available")
 		return err
 	}
@@ -282,7 +379,10 @@ func executeRunnerWithLLM(models []string, ollamaURL string, autoInstall bool) e
 				logger.Warn().Err(err).Msg("Failed to set model capabilities")
 			}
 		}
+		runnerService.SetModelInstaller(llmHandler.InstallModels)
+		runnerService.Supervise(llmHandler.Subsystem())
 	}
+	restartOnEscalation(runnerService)
 
 	if err := runnerService.Start(); err != nil {
 		logger.Fatal().Err(err).Msg("Failed to start runner service")
@@ -293,6 +393,7 @@ func executeRunnerWithLLM(models []string, ollamaURL string, autoInstall bool) e
 		Strs("models", models).
 		Str("ollama_url", ollamaURL).
 		Msg("Runner service with LLM capabilities started successfully")
+	releasePID := watchDrain(runnerService, cfg)
 
 	// Signal handling with force exit capability
 	signalCount := 0
@@ -322,6 +423,7 @@ func executeRunnerWithLLM(models []string, ollamaURL string, autoInstall bool) e
 					} else {
 						logger.Info().Msg("Runner service stopped successfully")
 					}
+					releasePID()
 					os.Exit(0)
 				}()
 
@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	walletsdk "github.com/theblitlabs/go-wallet-sdk"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/gas"
+	"github.com/theblitlabs/parity-runner/internal/server"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// coordinator is the task server runners connect to: the REST API under
+// /api/v1, the gRPC API when SERVER_GRPC_PORT is set, the chain gateway
+// that checks stakes and pays rewards when BLOCKCHAIN_RPC is set, and the
+// canary monitor when SERVER_CANARY_* names a canary image or model
+type coordinator struct {
+	server     *server.Server
+	controller *server.RunnerController
+	gateway    *server.ChainGateway
+	canaries   *server.CanaryMonitor
+
+	// stakeEvents watches the stake wallet contract so cached stakes are
+	// dropped as soon as they change, nil without BLOCKCHAIN_STAKE_WALLET_ADDRESS
+	stakeEvents func(ctx context.Context) <-chan server.StakeEvent
+}
+
+// newCoordinator assembles the server from the SERVER_* and BLOCKCHAIN_* settings
+func newCoordinator(cfg *config.Config) (*coordinator, error) {
+	logger := gologger.Get().With().Str("component", "server").Logger()
+
+	controller := server.NewRunnerController(nil)
+
+	timeouts, err := server.TimeoutPolicyFromConfig(cfg.Server.TaskTimeouts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid task timeouts: %w", err)
+	}
+	controller.SetTimeoutPolicy(timeouts)
+	controller.SetSLOs(server.SLOsFromConfig(cfg.Server.SLO))
+
+	pricing, err := server.PricingFromConfig(cfg.Server.Pricing)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pricing settings: %w", err)
+	}
+	controller.SetPricing(pricing)
+
+	stakePolicy, err := server.StakePolicyFromConfig(cfg.Server.StakePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stake policy: %w", err)
+	}
+
+	privacy, err := server.ResultPrivacyFromConfig(cfg.Server.Privacy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid result privacy settings: %w", err)
+	}
+	controller.SetResultPrivacy(privacy)
+	controller.SetOperatorToken(cfg.Server.AdminToken)
+
+	if hook := cfg.Server.ResultHook; hook.URL != "" {
+		controller.RegisterResultHook(server.NewWebhookResultHook("webhook", hook.URL, hook.Timeout, hook.FailClosed))
+	}
+
+	var key *ecdsa.PrivateKey
+	if cfg.Server.PrivateKey != "" {
+		key, err = crypto.HexToECDSA(strings.TrimPrefix(cfg.Server.PrivateKey, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SERVER_PRIVATE_KEY: %w", err)
+		}
+		controller.SetServerIdentity(key)
+		controller.SetReceiptSigner(key)
+		logger.Info().Str("address", crypto.PubkeyToAddress(key.PublicKey).Hex()).Msg("Signing responses with the server key")
+	} else {
+		logger.Warn().Msg("SERVER_PRIVATE_KEY is not set, responses go unsigned and rewards are not paid")
+	}
+
+	srv := server.NewServer(cfg)
+	srv.RegisterController(controller)
+	if cfg.Server.GRPCPort != "" {
+		srv.SetGRPCServer(server.NewGRPCServer(controller))
+	}
+
+	c := &coordinator{server: srv, controller: controller}
+	quarantine := server.QuarantineFromConfig(cfg.Server.Quarantine)
+	if templates := server.CanaryTemplatesFromConfig(cfg.Server.Canary); len(templates) > 0 {
+		c.canaries, err = server.NewCanaryMonitor(controller, server.CanaryConfig{
+			Interval:   cfg.Server.Canary.Interval,
+			Templates:  templates,
+			Quarantine: quarantine,
+			Alert: func(deviceID, taskID, reason string) {
+				logger.Warn().Str("device_id", deviceID).Str("task_id", taskID).Str("reason", reason).Msg("Runner failed a canary")
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid canary settings: %w", err)
+		}
+	} else if quarantine != nil {
+		logger.Warn().Msg("SERVER_QUARANTINE_THRESHOLD is set without canaries, runners are not quarantined")
+	}
+
+	if cfg.Blockchain.RPC == "" {
+		logger.Warn().Msg("BLOCKCHAIN_RPC is not set, stakes are not checked and rewards are not paid")
+		return c, nil
+	}
+
+	client, err := utils.NewReadOnlyClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rpc := server.NewRPCChain(client)
+	chain, err := newServerChain(cfg, rpc, client, key)
+	if err != nil {
+		return nil, err
+	}
+	c.gateway = server.NewChainGateway(chain, server.ChainGatewayConfigFromConfig(cfg.Server.Chain))
+
+	queueDir := cfg.Server.Chain.PayoutQueueDir
+	if queueDir == "" {
+		dataDir, err := utils.DataDir()
+		if err != nil {
+			return nil, err
+		}
+		queueDir = filepath.Join(dataDir, payoutQueueDirName)
+	}
+	if err := c.gateway.SetPayoutStore(context.Background(), server.NewFilePayoutStore(queueDir)); err != nil {
+		return nil, err
+	}
+	if cfg.Blockchain.StakeWalletAddress != "" {
+		contract := common.HexToAddress(cfg.Blockchain.StakeWalletAddress)
+		interval := cfg.Server.Chain.StakeWatchInterval
+		if interval <= 0 {
+			interval = defaultStakeWatchInterval
+		}
+		c.stakeEvents = func(ctx context.Context) <-chan server.StakeEvent {
+			return rpc.StakeEvents(ctx, contract, interval)
+		}
+	}
+
+	var minStake *big.Int
+	if cfg.Server.MinStake > 0 {
+		minStake = amountWei(cfg.Server.MinStake)
+	}
+	controller.SetChainGateway(c.gateway, minStake)
+	if stakePolicy != nil {
+		if err := controller.SetStakePolicy(*stakePolicy); err != nil {
+			return nil, fmt.Errorf("invalid stake policy: %w", err)
+		}
+	}
+	srv.SetChainGateway(c.gateway)
+	return c, nil
+}
+
+// payoutQueueDirName is where failed payouts are kept in the data directory
+// when SERVER_CHAIN_PAYOUT_QUEUE_DIR is not set
+const payoutQueueDirName = "payouts"
+
+// defaultStakeWatchInterval is how often the stake wallet contract is polled
+// when SERVER_CHAIN_STAKE_WATCH_INTERVAL is not set
+const defaultStakeWatchInterval = 15 * time.Second
+
+// newServerChain reads stakes through the chain RPC and, with a server key and
+// a stake wallet contract, pays rewards through that contract
+func newServerChain(cfg *config.Config, rpc *server.RPCChain, client *walletsdk.Client, key *ecdsa.PrivateKey) (server.Chain, error) {
+	if key == nil || cfg.Blockchain.StakeWalletAddress == "" {
+		return rpc, nil
+	}
+
+	return server.NewGasManagedChain(rpc, client, common.HexToAddress(cfg.Blockchain.StakeWalletAddress), key,
+		big.NewInt(cfg.Blockchain.ChainID), gas.FromConfig(cfg.Blockchain.Gas))
+}
+
+// run serves until ctx is done or the HTTP server fails
+func (c *coordinator) run(ctx context.Context) error {
+	logger := gologger.Get().With().Str("component", "server").Logger()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if c.gateway != nil {
+		go c.gateway.Run(ctx)
+		if c.stakeEvents != nil {
+			go c.gateway.WatchStakeEvents(ctx, c.stakeEvents(ctx))
+		}
+	}
+	if c.canaries != nil {
+		c.canaries.Start()
+		defer c.canaries.Stop()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.server.Start()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, shutdownCancel := utils.WithTimeout()
+	defer shutdownCancel()
+	if err := c.server.Stop(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("Error during server shutdown")
+		return err
+	}
+	return nil
+}
+
+// ExecuteServer runs the task server until it is interrupted
+func ExecuteServer() error {
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	c, err := newCoordinator(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return c.run(ctx)
+}
@@ -0,0 +1,952 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/messaging/heartbeat"
+	"github.com/theblitlabs/parity-runner/internal/runner"
+	"github.com/theblitlabs/parity-runner/internal/server"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+	"github.com/theblitlabs/parity-runner/pkg/client"
+)
+
+// testServerConfig serves on a free local port. The chain RPC points at a
+// closed port, so the chain is down for the whole test.
+func testServerConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	port, err := utils.FindAvailablePort(0)
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &config.Config{
+		Server: config.ServerConfig{
+			Host:       "127.0.0.1",
+			Port:       strconv.Itoa(port),
+			PrivateKey: hex.EncodeToString(crypto.FromECDSA(key)),
+			Chain:      config.ChainConfig{PayoutQueueDir: t.TempDir()},
+		},
+		Blockchain: config.BlockchainConfig{
+			RPC:     "http://127.0.0.1:1",
+			ChainID: 1337,
+		},
+	}
+}
+
+// startTestServer runs the server command's coordinator and returns its URL
+func startTestServer(t *testing.T, cfg *config.Config) (string, *coordinator) {
+	t.Helper()
+
+	c, err := newCoordinator(cfg)
+	if err != nil {
+		t.Fatalf("newCoordinator() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("server stopped with error: %v", err)
+		}
+	})
+
+	baseURL := fmt.Sprintf("http://%s:%s", cfg.Server.Host, cfg.Server.Port)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(baseURL + "/health")
+		if err == nil {
+			resp.Body.Close()
+			return baseURL, c
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not come up: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// runTestTask creates a task with the SDK and runs it through the runner's
+// task client as device runner-1
+func runTestTask(t *testing.T, baseURL string, cfg *config.Config) *client.TaskResult {
+	t.Helper()
+	t.Setenv("RUNNER_DEVICE_ID", "runner-1")
+
+	ctx := context.Background()
+	sdk := client.New(baseURL, client.WithDeviceID("creator-1"))
+	task, err := sdk.CreateTask(ctx, client.CreateTaskRequest{
+		Title:  "hello",
+		Type:   client.TaskTypeCommand,
+		Config: json.RawMessage(`{"command":["echo","hello"]}`),
+		Reward: 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateTask() error = %v", err)
+	}
+
+	tasks := newTestTaskClient(t, baseURL, cfg)
+	fetched, err := tasks.FetchTask()
+	if err != nil {
+		t.Fatalf("FetchTask() error = %v", err)
+	}
+	if fetched.ID != task.ID {
+		t.Fatalf("fetched task %s, want %s", fetched.ID, task.ID)
+	}
+	if err := tasks.SaveTaskResult(task.ID.String(), &models.TaskResult{DeviceID: "runner-1", Output: "hello", ExitCode: 0}); err != nil {
+		t.Fatalf("SaveTaskResult() error = %v", err)
+	}
+
+	result, err := sdk.GetTaskResult(ctx, task.ID.String())
+	if err != nil {
+		t.Fatalf("GetTaskResult() error = %v", err)
+	}
+	return result
+}
+
+// newTestTaskClient is the task client a runner uses, pinned to the server key
+func newTestTaskClient(t *testing.T, baseURL string, cfg *config.Config) *runner.HTTPTaskClient {
+	t.Helper()
+
+	key, err := crypto.HexToECDSA(cfg.Server.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := identity.NewVerifier(crypto.PubkeyToAddress(key.PublicKey).Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Runners are configured with the server URL ending in /api
+	tasks := runner.NewHTTPTaskClient(baseURL + "/api")
+	tasks.SetServerVerifier(verifier)
+	return tasks
+}
+
+type testHealth struct {
+	Status string `json:"status"`
+	Chain  struct {
+		PendingPayouts int `json:"pending_payouts"`
+	} `json:"chain"`
+}
+
+func TestServerRunsTaskEndToEnd(t *testing.T) {
+	cfg := testServerConfig(t)
+	baseURL, _ := startTestServer(t, cfg)
+
+	result := runTestTask(t, baseURL, cfg)
+	if result.Output != "hello" {
+		t.Fatalf("result output = %q, want hello", result.Output)
+	}
+
+	// The chain is down: the server stays up, reports it and queues the reward
+	var health testHealth
+	getJSON(t, baseURL+"/health", &health)
+	if health.Status != "degraded" || health.Chain.PendingPayouts != 1 {
+		t.Fatalf("health = %+v, want degraded with one pending payout", health)
+	}
+}
+
+func TestServerResultHookVetoesPayout(t *testing.T) {
+	hooked := make(chan models.TaskResult, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result models.TaskResult
+		if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+			t.Errorf("hook got an undecodable result: %v", err)
+		}
+		hooked <- result
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"veto":true,"reason":"score below threshold"}`)
+	}))
+	defer hook.Close()
+
+	cfg := testServerConfig(t)
+	cfg.Server.ResultHook = config.ResultHookConfig{URL: hook.URL, Timeout: time.Second}
+	baseURL, _ := startTestServer(t, cfg)
+
+	result := runTestTask(t, baseURL, cfg)
+
+	select {
+	case got := <-hooked:
+		if got.TaskID != result.TaskID || got.Output != "hello" {
+			t.Fatalf("hook got result %s %q, want %s hello", got.TaskID, got.Output, result.TaskID)
+		}
+	default:
+		t.Fatal("result hook was not called")
+	}
+
+	var health testHealth
+	getJSON(t, baseURL+"/health", &health)
+	if health.Chain.PendingPayouts != 0 {
+		t.Fatalf("pending payouts = %d, want none after a veto", health.Chain.PendingPayouts)
+	}
+}
+
+func TestServerChecksLLMCanaries(t *testing.T) {
+	cfg := testServerConfig(t)
+	cfg.Server.Canary = config.CanaryConfig{Interval: time.Hour, LLMModel: "llama3"}
+	baseURL, c := startTestServer(t, cfg)
+	if c.canaries == nil {
+		t.Fatal("canary monitor is not running")
+	}
+	t.Setenv("RUNNER_DEVICE_ID", "runner-1")
+	tasks := newTestTaskClient(t, baseURL, cfg)
+
+	// An honest runner asks the model, whose answer repeats the prompt's code
+	if _, err := c.canaries.Inject(); err != nil {
+		t.Fatalf("Inject() error = %v", err)
+	}
+	canary, err := tasks.FetchTask()
+	if err != nil {
+		t.Fatalf("FetchTask() error = %v", err)
+	}
+	var prompt struct {
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+	}
+	if err := json.Unmarshal(canary.Config, &prompt); err != nil {
+		t.Fatal(err)
+	}
+	if canary.Type != models.TaskTypeLLM || prompt.Model != "llama3" || !strings.HasSuffix(prompt.Prompt, canary.Nonce) {
+		t.Fatalf("canary = %s %s, want an llm prompt for llama3 ending in its nonce", canary.Type, canary.Config)
+	}
+	if err := tasks.CompletePrompt(canary.ID, canary.Nonce, 12, 8, 40); err != nil {
+		t.Fatalf("CompletePrompt() error = %v", err)
+	}
+	if result, ok := c.controller.GetTaskResult(canary.ID.String()); !ok || result.Output != canary.Nonce || result.DeviceID != "runner-1" {
+		t.Fatalf("stored result = %+v, want the answer from runner-1", result)
+	}
+
+	// A runner that answers without running the model fails the canary
+	if _, err := c.canaries.Inject(); err != nil {
+		t.Fatalf("Inject() error = %v", err)
+	}
+	canary, err = tasks.FetchTask()
+	if err != nil {
+		t.Fatalf("FetchTask() error = %v", err)
+	}
+	body := strings.NewReader(`{"response":"ok"}`)
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/api/v1/llm/prompts/"+canary.ID.String()+"/complete", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Device-ID", "runner-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var outcome struct {
+		PayoutApproved bool   `json:"payout_approved"`
+		VetoReason     string `json:"veto_reason"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&outcome); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || outcome.PayoutApproved || !strings.Contains(outcome.VetoReason, canary.Nonce) {
+		t.Fatalf("status %d, outcome %+v, want the payout vetoed for the missing nonce", resp.StatusCode, outcome)
+	}
+}
+
+type idleRunner struct{}
+
+func (idleRunner) HandleTask(*models.Task) error { return nil }
+func (idleRunner) IsProcessing() bool            { return false }
+
+type fixedHostMetrics struct{ cpu float64 }
+
+func (m fixedHostMetrics) GetHostMetrics() models.HostMetrics {
+	return models.HostMetrics{CPUUsage: m.cpu}
+}
+
+func TestServerStatsCoverRunnerHeartbeatsAndTasks(t *testing.T) {
+	cfg := testServerConfig(t)
+	baseURL, _ := startTestServer(t, cfg)
+
+	hb := heartbeat.NewHeartbeatService(heartbeat.HeartbeatConfig{
+		ServerURL:    baseURL,
+		DeviceID:     "runner-1",
+		BaseInterval: time.Hour,
+		MaxRetries:   1,
+	}, idleRunner{}, fixedHostMetrics{cpu: 42})
+	if err := hb.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer hb.Stop()
+
+	runTestTask(t, baseURL, cfg)
+
+	var overview server.StatsOverview
+	getJSON(t, baseURL+"/api/v1/stats/overview", &overview)
+	if overview.RunnersOnline != 1 || overview.AvgRunnerCPUUsage != 42 {
+		t.Fatalf("overview = %+v, want the runner's heartbeat counted", overview)
+	}
+	if overview.TasksCompleted != 1 || overview.QueueDepth != 0 {
+		t.Fatalf("overview = %+v, want one completed task and an empty queue", overview)
+	}
+}
+
+func TestServerEnforcesPricingFloor(t *testing.T) {
+	cfg := testServerConfig(t)
+	cfg.Server.Pricing = config.PricingConfig{BaseReward: 2, ModelRewards: "llama3=3", EnforceFloor: true}
+	baseURL, _ := startTestServer(t, cfg)
+
+	ctx := context.Background()
+	sdk := client.New(baseURL, client.WithDeviceID("creator-1"))
+	suggestion, err := sdk.EstimateTask(ctx, client.TaskClass{Type: client.TaskTypeLLM, Model: "llama3"})
+	if err != nil {
+		t.Fatalf("EstimateTask() error = %v", err)
+	}
+	if suggestion.SuggestedMinReward != 5 || !suggestion.FloorEnforced {
+		t.Fatalf("suggestion = %+v, want an enforced minimum of 5", suggestion)
+	}
+
+	prompt := client.CreateTaskRequest{
+		Title:  "prompt",
+		Type:   client.TaskTypeLLM,
+		Config: json.RawMessage(`{"model":"llama3","prompt":"hi"}`),
+		Reward: 4,
+	}
+	var apiErr *client.APIError
+	if _, err := sdk.CreateTask(ctx, prompt); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("CreateTask() below the floor error = %v, want 422", err)
+	}
+	prompt.Reward = 5
+	if _, err := sdk.CreateTask(ctx, prompt); err != nil {
+		t.Fatalf("CreateTask() at the floor error = %v", err)
+	}
+}
+
+// fakeChainNode is a chain RPC whose every eth_blockNumber mines a block and
+// whose stake contract logs once logged is set. Transactions are mined as
+// soon as they are sent; stake reads fail.
+type fakeChainNode struct {
+	logged atomic.Bool
+	head   atomic.Uint64
+
+	mu   sync.Mutex
+	sent []*types.Transaction
+}
+
+func (n *fakeChainNode) transactions() []*types.Transaction {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*types.Transaction{}, n.sent...)
+}
+
+func (n *fakeChainNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	zero := common.Hash{}.Hex()
+	bloom := "0x" + strings.Repeat("00", 256)
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	switch req.Method {
+	case "eth_blockNumber":
+		response["result"] = fmt.Sprintf("0x%x", n.head.Add(1))
+	case "eth_getBlockByNumber":
+		response["result"] = map[string]interface{}{
+			"number":           fmt.Sprintf("0x%x", n.head.Add(1)),
+			"hash":             common.Hash{4}.Hex(),
+			"parentHash":       zero,
+			"sha3Uncles":       zero,
+			"miner":            common.Address{}.Hex(),
+			"stateRoot":        zero,
+			"transactionsRoot": zero,
+			"receiptsRoot":     zero,
+			"logsBloom":        bloom,
+			"difficulty":       "0x0",
+			"gasLimit":         "0x1c9c380",
+			"gasUsed":          "0x0",
+			"timestamp":        fmt.Sprintf("0x%x", time.Now().Unix()),
+			"extraData":        "0x",
+			"mixHash":          zero,
+			"nonce":            "0x0000000000000000",
+		}
+	case "eth_getLogs":
+		logs := []map[string]interface{}{}
+		if n.logged.Load() {
+			logs = append(logs, map[string]interface{}{
+				"address":          testStakeWallet,
+				"topics":           []string{common.Hash{1}.Hex()},
+				"data":             "0x",
+				"blockNumber":      fmt.Sprintf("0x%x", n.head.Load()),
+				"blockHash":        common.Hash{2}.Hex(),
+				"transactionHash":  common.Hash{3}.Hex(),
+				"transactionIndex": "0x0",
+				"logIndex":         "0x0",
+			})
+		}
+		response["result"] = logs
+	case "eth_estimateGas":
+		response["result"] = "0x186a0"
+	case "eth_gasPrice":
+		response["result"] = "0x3b9aca00"
+	case "eth_getTransactionCount":
+		n.mu.Lock()
+		response["result"] = fmt.Sprintf("0x%x", len(n.sent))
+		n.mu.Unlock()
+	case "eth_sendRawTransaction":
+		var raw hexutil.Bytes
+		tx := new(types.Transaction)
+		if err := json.Unmarshal(req.Params[0], &raw); err != nil || tx.UnmarshalBinary(raw) != nil {
+			response["error"] = map[string]interface{}{"code": -32602, "message": "invalid transaction"}
+			break
+		}
+		n.mu.Lock()
+		n.sent = append(n.sent, tx)
+		n.mu.Unlock()
+		response["result"] = tx.Hash().Hex()
+	case "eth_getTransactionReceipt":
+		var hash common.Hash
+		_ = json.Unmarshal(req.Params[0], &hash)
+		response["result"] = map[string]interface{}{
+			"transactionHash":   hash.Hex(),
+			"transactionIndex":  "0x0",
+			"blockHash":         common.Hash{5}.Hex(),
+			"blockNumber":       fmt.Sprintf("0x%x", n.head.Load()),
+			"status":            "0x1",
+			"cumulativeGasUsed": "0x186a0",
+			"gasUsed":           "0x186a0",
+			"logsBloom":         bloom,
+			"logs":              []interface{}{},
+		}
+	default:
+		response["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+const testStakeWallet = "0x00000000000000000000000000000000000000Aa"
+
+func TestServerDropsCachedStakesWhenTheStakeContractChanges(t *testing.T) {
+	node := &fakeChainNode{}
+	rpc := httptest.NewServer(node)
+	defer rpc.Close()
+
+	cfg := testServerConfig(t)
+	cfg.Blockchain.RPC = rpc.URL
+	cfg.Blockchain.StakeWalletAddress = testStakeWallet
+	cfg.Server.Chain.StakeFreshTTL = time.Hour
+	cfg.Server.Chain.StakeWatchInterval = 10 * time.Millisecond
+	_, c := startTestServer(t, cfg)
+
+	ctx := context.Background()
+	c.gateway.ObserveStakeEvent(server.StakeEvent{DeviceID: "runner-1", Balance: big.NewInt(5)})
+	if balance, cached, _ := c.gateway.StakeBalance(ctx, "runner-1"); !cached || balance.Int64() != 5 {
+		t.Fatalf("StakeBalance() = %v (cached %v), want the cached 5", balance, cached)
+	}
+
+	node.logged.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, cached, _ := c.gateway.StakeBalance(ctx, "runner-1"); !cached {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cached stake was still served after the stake contract logged a change")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestServerQueuesFailedPayoutsAcrossRestarts(t *testing.T) {
+	node := &fakeChainNode{}
+	rpc := httptest.NewServer(node)
+	defer rpc.Close()
+
+	// Without BLOCKCHAIN_STAKE_WALLET_ADDRESS the chain is up but cannot pay
+	cfg := testServerConfig(t)
+	cfg.Blockchain.RPC = rpc.URL
+	cfg.Server.Chain.SettleInterval = 10 * time.Millisecond
+	cfg.Server.Chain.RetryBackoff = time.Millisecond
+	cfg.Server.Chain.AlertAfterAttempts = 2
+	baseURL, c := startTestServer(t, cfg)
+	result := runTestTask(t, baseURL, cfg)
+
+	var earnings server.RunnerEarnings
+	getJSON(t, baseURL+"/api/v1/earnings/runner-1", &earnings)
+	if len(earnings.Pending) != 1 || earnings.Pending[0].TaskID != result.TaskID.String() || earnings.PendingTotal != 1 {
+		t.Fatalf("earnings = %+v, want the task's reward pending", earnings)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Server.Chain.PayoutQueueDir, result.TaskID.String()+".json")); err != nil {
+		t.Fatalf("queued payout was not persisted: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.gateway.FailingPayouts() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("payout failing on every retry never raised an alert")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if metrics := getText(t, baseURL+"/metrics"); !strings.Contains(metrics, "parity_payouts_failing 1") {
+		t.Fatalf("/metrics does not report the failing payout:\n%s", metrics)
+	}
+
+	restarted, err := newCoordinator(cfg)
+	if err != nil {
+		t.Fatalf("newCoordinator() after restart error = %v", err)
+	}
+	pending := restarted.gateway.PendingPayouts()
+	if len(pending) != 1 || pending[0].TaskID != result.TaskID.String() || pending[0].Attempts < 2 {
+		t.Fatalf("restored payouts = %+v, want the failing payout with its attempts", pending)
+	}
+}
+
+func TestServerPaysRewardsInBatches(t *testing.T) {
+	node := &fakeChainNode{}
+	rpc := httptest.NewServer(node)
+	defer rpc.Close()
+
+	cfg := testServerConfig(t)
+	cfg.Blockchain.RPC = rpc.URL
+	cfg.Blockchain.StakeWalletAddress = testStakeWallet
+	cfg.Server.Chain.SettleInterval = 10 * time.Millisecond
+	cfg.Server.Chain.BatchSize = 2
+	cfg.Server.Chain.BatchInterval = time.Hour
+	baseURL, _ := startTestServer(t, cfg)
+
+	first := runTestTask(t, baseURL, cfg)
+	second := runTestTask(t, baseURL, cfg)
+
+	var report struct {
+		Reconciliation server.PayoutReconciliation `json:"reconciliation"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		getJSON(t, baseURL+"/api/v1/chain/payouts/reconciliation", &report)
+		if len(report.Reconciliation.Settled) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch was never settled: %+v", report.Reconciliation)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	reconciliation := report.Reconciliation
+	batch := reconciliation.Settled[0]
+	if !reconciliation.Reconciled || reconciliation.SettledCount != 2 || len(batch.TaskIDs) != 2 || batch.Total != 2 {
+		t.Fatalf("reconciliation = %+v, want both rewards settled in one batch", reconciliation)
+	}
+	for _, taskID := range []string{first.TaskID.String(), second.TaskID.String()} {
+		if !strings.Contains(strings.Join(batch.TaskIDs, ","), taskID) {
+			t.Fatalf("batch %v does not pay task %s", batch.TaskIDs, taskID)
+		}
+	}
+
+	sent := node.transactions()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d transactions, want the batch in one", len(sent))
+	}
+	tx := sent[0]
+	selector := crypto.Keccak256([]byte("distributeRewardsBatch(bytes32,string[],uint256[])"))[:4]
+	if *tx.To() != common.HexToAddress(testStakeWallet) || !bytes.HasPrefix(tx.Data(), selector) || tx.Hash().Hex() != batch.TxHash {
+		t.Fatalf("transaction to %s with data %x is not the batch call", tx.To(), tx.Data())
+	}
+}
+
+func TestServerScalesRequiredStakeWithStakePolicy(t *testing.T) {
+	cfg := testServerConfig(t)
+	cfg.Server.MinStake = 1
+	cfg.Server.StakePolicy = config.StakePolicyConfig{RewardMultiplier: 2, Types: "llm=3"}
+	baseURL, c := startTestServer(t, cfg)
+	t.Setenv("RUNNER_DEVICE_ID", "runner-1")
+
+	sdk := client.New(baseURL, client.WithDeviceID("creator-1"))
+	task, err := sdk.CreateTask(context.Background(), client.CreateTaskRequest{
+		Title:  "prompt",
+		Type:   client.TaskTypeLLM,
+		Config: json.RawMessage(`{"model":"llama3","prompt":"hi"}`),
+		Reward: 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateTask() error = %v", err)
+	}
+
+	// (1 token minimum + 2 x 1 token reward) x 3 for an LLM task
+	required := new(big.Int).Mul(big.NewInt(9), big.NewInt(1e18))
+	tasks := newTestTaskClient(t, baseURL, cfg)
+	c.gateway.ObserveStakeEvent(server.StakeEvent{DeviceID: "runner-1", Balance: new(big.Int).Sub(required, big.NewInt(1))})
+	if err := tasks.StartTask(task.ID.String()); err == nil || !strings.Contains(err.Error(), required.String()) {
+		t.Fatalf("StartTask() with too little stake error = %v, want the %s wei requirement", err, required)
+	}
+
+	c.gateway.ObserveStakeEvent(server.StakeEvent{DeviceID: "runner-1", Balance: required})
+	if err := tasks.StartTask(task.ID.String()); err != nil {
+		t.Fatalf("StartTask() with enough stake error = %v", err)
+	}
+	var started models.Task
+	getJSON(t, baseURL+"/api/v1/tasks/"+task.ID.String(), &started)
+	if started.RequiredStake != required.String() {
+		t.Fatalf("required_stake = %q, want %s", started.RequiredStake, required)
+	}
+}
+
+func TestServerQuarantinesRunnersThatFailCanaries(t *testing.T) {
+	cfg := testServerConfig(t)
+	cfg.Server.Canary = config.CanaryConfig{Interval: time.Hour, DockerImage: "alpine"}
+	cfg.Server.Quarantine = config.QuarantineConfig{Threshold: 1, ProbationCanaries: 1}
+	cfg.Server.AdminToken = "operator-secret"
+	baseURL, c := startTestServer(t, cfg)
+	t.Setenv("RUNNER_DEVICE_ID", "runner-1")
+
+	notices := make(chan string, 1)
+	runnerWebhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message struct {
+			Type string `json:"type"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&message)
+		notices <- message.Type
+	}))
+	defer runnerWebhook.Close()
+	postJSON(t, baseURL+"/api/v1/runners", "runner-1", `{"wallet_address":"0x00000000000000000000000000000000000000bb","webhook":"`+runnerWebhook.URL+`"}`, http.StatusOK)
+
+	if _, err := c.canaries.Inject(); err != nil {
+		t.Fatalf("Inject() error = %v", err)
+	}
+	tasks := newTestTaskClient(t, baseURL, cfg)
+	canary, err := tasks.FetchTask()
+	if err != nil {
+		t.Fatalf("FetchTask() error = %v", err)
+	}
+	if err := tasks.SaveTaskResult(canary.ID.String(), &models.TaskResult{DeviceID: "runner-1", Output: "made up", ExitCode: 0}); err != nil {
+		t.Fatalf("SaveTaskResult() error = %v", err)
+	}
+	select {
+	case notice := <-notices:
+		if notice != "quarantine" {
+			t.Fatalf("runner was sent a %q notice, want quarantine", notice)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner was not told it was quarantined")
+	}
+
+	sdk := client.New(baseURL, client.WithDeviceID("creator-1"))
+	paid, err := sdk.CreateTask(context.Background(), client.CreateTaskRequest{
+		Title:  "hello",
+		Type:   client.TaskTypeCommand,
+		Config: json.RawMessage(`{"command":["echo","hello"]}`),
+		Reward: 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateTask() error = %v", err)
+	}
+	if err := tasks.StartTask(paid.ID.String()); err == nil || !strings.Contains(err.Error(), "quarantined") {
+		t.Fatalf("StartTask() of paid work while quarantined error = %v", err)
+	}
+
+	postJSON(t, baseURL+"/api/v1/quarantine/runner-1/appeal", "runner-1", `{"message":"bad disk, replaced"}`, http.StatusOK)
+	var record server.QuarantineRecord
+	getJSON(t, baseURL+"/api/v1/quarantine/runner-1", &record)
+	if record.Appeal == nil || record.Appeal.Message != "bad disk, replaced" || record.CanariesRequired != 1 {
+		t.Fatalf("quarantine record = %+v, want the appeal and one probation canary", record)
+	}
+
+	postJSON(t, baseURL+"/api/v1/quarantine/runner-1/release", "runner-1", `{"reason":"releasing myself"}`, http.StatusUnauthorized)
+	postOperatorJSON(t, baseURL+"/api/v1/quarantine/runner-1/release", "operator-secret", `{"reason":"appeal upheld"}`, http.StatusOK)
+	if err := tasks.StartTask(paid.ID.String()); err != nil {
+		t.Fatalf("StartTask() after release error = %v", err)
+	}
+}
+
+// postJSON posts body as deviceID and checks the response status
+func postJSON(t *testing.T, url, deviceID, body string, want int) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if deviceID != "" {
+		req.Header.Set("X-Device-ID", deviceID)
+	}
+	checkStatus(t, req, want)
+}
+
+// postOperatorJSON posts body with the operator token and checks the response status
+func postOperatorJSON(t *testing.T, url, token, body string, want int) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	checkStatus(t, req, want)
+}
+
+func checkStatus(t *testing.T, req *http.Request, want int) {
+	t.Helper()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", req.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		message, _ := io.ReadAll(resp.Body)
+		t.Fatalf("POST %s: status %d, want %d: %s", req.URL, resp.StatusCode, want, message)
+	}
+}
+
+func TestServerExportsSLOBurnRates(t *testing.T) {
+	cfg := testServerConfig(t)
+	// Every assignment is late, and the one result fails
+	cfg.Server.SLO = config.SLOConfig{AssignmentLatency: time.Nanosecond, AssignmentObjective: 0.5, CompletionObjective: 0.5}
+	baseURL, _ := startTestServer(t, cfg)
+	t.Setenv("RUNNER_DEVICE_ID", "runner-1")
+
+	sdk := client.New(baseURL, client.WithDeviceID("creator-1"))
+	task, err := sdk.CreateTask(context.Background(), client.CreateTaskRequest{
+		Title:  "hello",
+		Type:   client.TaskTypeCommand,
+		Config: json.RawMessage(`{"command":["false"]}`),
+		Reward: 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateTask() error = %v", err)
+	}
+	tasks := newTestTaskClient(t, baseURL, cfg)
+	if err := tasks.StartTask(task.ID.String()); err != nil {
+		t.Fatalf("StartTask() error = %v", err)
+	}
+	if err := tasks.SaveTaskResult(task.ID.String(), &models.TaskResult{DeviceID: "runner-1", Error: "exit status 1", ExitCode: 1}); err != nil {
+		t.Fatalf("SaveTaskResult() error = %v", err)
+	}
+
+	metrics := getText(t, baseURL+"/metrics")
+	for _, want := range []string{
+		`parity_slo_objective{slo="assignment_latency"} 0.5`,
+		`parity_slo_bad_events_total{slo="assignment_latency"} 1`,
+		`parity_slo_bad_events_total{slo="completion_rate"} 1`,
+		`parity_slo_events_total{slo="payout_latency"} 0`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Fatalf("/metrics is missing %s:\n%s", want, metrics)
+		}
+	}
+	// All bad against a 50% error budget burns it twice over
+	burning := false
+	for _, line := range strings.Split(metrics, "\n") {
+		if strings.HasPrefix(line, `parity_slo_burn_rate{slo="completion_rate",`) && strings.HasSuffix(line, " 2") {
+			burning = true
+		}
+	}
+	if !burning {
+		t.Fatalf("/metrics has no completion burn rate of 2:\n%s", metrics)
+	}
+
+	rules := getText(t, baseURL+"/api/v1/slo/rules")
+	for _, slo := range []string{server.SLOAssignmentLatency, server.SLOCompletionRate, server.SLOPayoutLatency} {
+		if !strings.Contains(rules, `slo="`+slo+`"`) {
+			t.Fatalf("alerting rules do not cover %s:\n%s", slo, rules)
+		}
+	}
+}
+
+func getText(t *testing.T, url string) string {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d, %v", url, resp.StatusCode, err)
+	}
+	return string(body)
+}
+
+func TestServerScrubsAndEncryptsResults(t *testing.T) {
+	hooked := make(chan string, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result models.TaskResult
+		_ = json.NewDecoder(r.Body).Decode(&result)
+		hooked <- result.Output
+		fmt.Fprint(w, `{}`)
+	}))
+	defer hook.Close()
+
+	cfg := testServerConfig(t)
+	cfg.Server.ResultHook = config.ResultHookConfig{URL: hook.URL, Timeout: time.Second}
+	cfg.Server.Privacy = config.PrivacyConfig{
+		EncryptionKey:   base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)),
+		EncryptionKeyID: "test-key",
+		ScrubRules:      "email",
+	}
+	baseURL, c := startTestServer(t, cfg)
+	t.Setenv("RUNNER_DEVICE_ID", "runner-1")
+
+	sdk := client.New(baseURL, client.WithDeviceID("creator-1"))
+	task, err := sdk.CreateTask(context.Background(), client.CreateTaskRequest{
+		Title:  "report",
+		Type:   client.TaskTypeCommand,
+		Config: json.RawMessage(`{"command":["cat","report.txt"]}`),
+		Reward: 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateTask() error = %v", err)
+	}
+	tasks := newTestTaskClient(t, baseURL, cfg)
+	if err := tasks.StartTask(task.ID.String()); err != nil {
+		t.Fatalf("StartTask() error = %v", err)
+	}
+	if err := tasks.SaveTaskResult(task.ID.String(), &models.TaskResult{DeviceID: "runner-1", Output: "contact alice@example.com for access"}); err != nil {
+		t.Fatalf("SaveTaskResult() error = %v", err)
+	}
+
+	const scrubbed = "contact [REDACTED:email] for access"
+	select {
+	case output := <-hooked:
+		if output != scrubbed {
+			t.Fatalf("result hook got %q, want the scrubbed output", output)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("result hook was not called")
+	}
+
+	stored, ok := c.controller.GetTaskResult(task.ID.String())
+	if !ok || stored.Output != "" || stored.Sealed == nil || stored.Sealed.KeyID != "test-key" {
+		t.Fatalf("stored result = %+v, want the output sealed with test-key", stored)
+	}
+	if bytes.Contains(stored.Sealed.Ciphertext, []byte("contact")) {
+		t.Fatal("stored ciphertext contains the plaintext")
+	}
+
+	result, err := sdk.GetTaskResult(context.Background(), task.ID.String())
+	if err != nil {
+		t.Fatalf("GetTaskResult() error = %v", err)
+	}
+	if result.Output != scrubbed {
+		t.Fatalf("GetTaskResult() output = %q, want %q", result.Output, scrubbed)
+	}
+}
+
+func getJSON(t *testing.T, url string, out interface{}) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+}
+
+func TestServerRunsExperimentsThroughTheSDK(t *testing.T) {
+	cfg := testServerConfig(t)
+	baseURL, _ := startTestServer(t, cfg)
+	t.Setenv("RUNNER_DEVICE_ID", "runner-1")
+
+	ctx := context.Background()
+	shard := func(n int) client.CreateTaskRequest {
+		return client.CreateTaskRequest{
+			Title:  fmt.Sprintf("shard %d", n),
+			Type:   client.TaskTypeCommand,
+			Config: json.RawMessage(fmt.Sprintf(`{"command":["train","--shard","%d"]}`, n)),
+			Reward: 1,
+		}
+	}
+
+	sdk := client.New(baseURL, client.WithDeviceID("creator-1"))
+	created, err := sdk.CreateExperiment(ctx, client.CreateExperimentRequest{
+		Name:  "sweep",
+		Tasks: []client.CreateTaskRequest{shard(0), shard(1), shard(2)},
+	})
+	if err != nil {
+		t.Fatalf("CreateExperiment() error = %v", err)
+	}
+	if len(created.TaskIDs) != 3 || created.Progress.Pending != 3 {
+		t.Fatalf("CreateExperiment() = %+v, want three pending tasks", created)
+	}
+	experimentID := created.Experiment.ID.String()
+
+	tasks := newTestTaskClient(t, baseURL, cfg)
+	succeeded, failed := created.TaskIDs[0].String(), created.TaskIDs[1].String()
+	for _, taskID := range []string{succeeded, failed} {
+		if err := tasks.StartTask(taskID); err != nil {
+			t.Fatalf("StartTask(%s) error = %v", taskID, err)
+		}
+	}
+	if err := tasks.SaveTaskResult(succeeded, &models.TaskResult{DeviceID: "runner-1", Output: "done", ExecutionTime: 1500}); err != nil {
+		t.Fatalf("SaveTaskResult() error = %v", err)
+	}
+	if err := tasks.SaveTaskResult(failed, &models.TaskResult{DeviceID: "runner-1", Error: "out of memory", ExitCode: 137, ExecutionTime: 500}); err != nil {
+		t.Fatalf("SaveTaskResult() error = %v", err)
+	}
+
+	summary, err := sdk.GetExperiment(ctx, experimentID)
+	if err != nil {
+		t.Fatalf("GetExperiment() error = %v", err)
+	}
+	if p := summary.Progress; p.Completed != 1 || p.Failed != 1 || p.Pending != 1 {
+		t.Fatalf("progress = %+v, want one completed, one failed and one pending", p)
+	}
+	if summary.Usage.TaskCount != 2 || summary.Usage.TotalExecutionTimeMs != 2000 {
+		t.Fatalf("usage = %+v, want both results combined", summary.Usage)
+	}
+
+	added, err := sdk.AddExperimentTasks(ctx, experimentID, []client.CreateTaskRequest{shard(3)})
+	if err != nil {
+		t.Fatalf("AddExperimentTasks() error = %v", err)
+	}
+	if added.Progress.Total != 4 || added.Progress.Pending != 2 {
+		t.Fatalf("AddExperimentTasks() progress = %+v, want two of four pending", added.Progress)
+	}
+
+	cancelled, err := sdk.CancelExperiment(ctx, experimentID)
+	if err != nil {
+		t.Fatalf("CancelExperiment() error = %v", err)
+	}
+	if cancelled.Experiment.Status != models.ExperimentStatusCancelled || cancelled.Progress.Cancelled != 2 || cancelled.Progress.Pending != 0 {
+		t.Fatalf("CancelExperiment() = %+v, want the two queued tasks cancelled", cancelled)
+	}
+	if _, err := sdk.AddExperimentTasks(ctx, experimentID, []client.CreateTaskRequest{shard(4)}); err == nil {
+		t.Fatal("AddExperimentTasks() on a cancelled experiment succeeded")
+	}
+}
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/daemon"
+	"github.com/theblitlabs/parity-runner/internal/execution/task"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	serviceBaseName    = "parity-runner"
+	serviceLogDirName  = "logs"
+	serviceLogFileName = "runner.log"
+)
+
+// ServiceOptions are the flags of service install and of the service process
+// it sets up
+type ServiceOptions struct {
+	// LogMode is passed on to the runner as --log
+	LogMode string
+	// LogFile is where the runner's output goes, logs/runner.log in the data
+	// directory when empty
+	LogFile string
+	// LogMaxSize is the size, such as "10m", at which the log is rotated
+	LogMaxSize string
+	// LogFiles is how many rotated logs are kept
+	LogFiles int
+}
+
+// serviceName tells apart the services of several instances and networks
+func serviceName() string {
+	name := serviceBaseName
+	if utils.Network() == config.NetworkTestnet {
+		name += "-" + config.NetworkTestnet
+	}
+	return utils.InstanceScoped(name)
+}
+
+func serviceManager() (daemon.Manager, error) {
+	return daemon.New(serviceName())
+}
+
+// ExecuteServiceInstall installs the runner as a service that starts at boot,
+// running with the network, instance and config selected for this command
+func ExecuteServiceInstall(opts ServiceOptions) error {
+	logger := gologger.WithComponent("service")
+
+	m, err := serviceManager()
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find runner executable: %w", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return fmt.Errorf("failed to resolve runner executable: %w", err)
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	logFile := opts.LogFile
+	if logFile == "" {
+		dataDir, err := utils.DataDir()
+		if err != nil {
+			return err
+		}
+		logFile = filepath.Join(dataDir, serviceLogDirName, serviceLogFileName)
+	}
+	if logFile, err = filepath.Abs(logFile); err != nil {
+		return fmt.Errorf("failed to resolve log file: %w", err)
+	}
+	if _, err := task.ParseMemory(opts.LogMaxSize); err != nil {
+		return fmt.Errorf("invalid log size %q: %w", opts.LogMaxSize, err)
+	}
+
+	// Both the wrapper and the runner need these to find the service name
+	// and the runner's data
+	var scope []string
+	if network := utils.Network(); network != "" {
+		scope = append(scope, "--network", network)
+	}
+	if instance := utils.Instance(); instance != "" {
+		scope = append(scope, "--instance", instance)
+	}
+
+	// The runner the service starts, behind -- so the wrapper leaves its
+	// flags alone
+	runnerArgs := append([]string{"--log", opts.LogMode}, scope...)
+	if configPath, err := filepath.Abs(utils.GetConfigPath()); err == nil {
+		if _, err := os.Stat(configPath); err == nil {
+			runnerArgs = append(runnerArgs, "--config-path", configPath)
+		}
+	}
+
+	args := append([]string{"service", "run"}, scope...)
+	args = append(args,
+		"--log-file", logFile,
+		"--log-max-size", opts.LogMaxSize,
+		"--log-files", fmt.Sprint(opts.LogFiles),
+		"--",
+	)
+	cfg := daemon.Config{
+		Name:        serviceName(),
+		DisplayName: "Parity Runner",
+		Description: "Parity Runner, runs compute tasks for the Parity network",
+		Executable:  executable,
+		Args:        append(args, runnerArgs...),
+		WorkingDir:  workingDir,
+	}
+	if instance := utils.Instance(); instance != "" {
+		cfg.DisplayName += " (" + instance + ")"
+	}
+	if err := m.Install(cfg); err != nil {
+		return err
+	}
+
+	logger.Info().
+		Str("service", cfg.Name).
+		Str("path", m.Path()).
+		Str("log", logFile).
+		Msg("Service installed, start it with 'parity-runner service start'")
+	if runtime.GOOS == "linux" && os.Geteuid() != 0 {
+		logger.Info().Msg("User services only start at boot with lingering enabled: loginctl enable-linger $USER")
+	}
+	return nil
+}
+
+// ExecuteServiceUninstall stops and removes the service
+func ExecuteServiceUninstall() error {
+	m, err := serviceManager()
+	if err != nil {
+		return err
+	}
+	if err := m.Uninstall(); err != nil {
+		return err
+	}
+	logger := gologger.WithComponent("service")
+	logger.Info().Str("service", serviceName()).Msg("Service uninstalled")
+	return nil
+}
+
+// ExecuteServiceStart starts the installed service
+func ExecuteServiceStart() error {
+	m, err := serviceManager()
+	if err != nil {
+		return err
+	}
+	if err := m.Start(); err != nil {
+		return err
+	}
+	logger := gologger.WithComponent("service")
+	logger.Info().Str("service", serviceName()).Msg("Service started")
+	return nil
+}
+
+// ExecuteServiceStop stops the service, waiting for the runner to finish
+// running tasks as far as the service manager allows
+func ExecuteServiceStop() error {
+	m, err := serviceManager()
+	if err != nil {
+		return err
+	}
+	if err := m.Stop(); err != nil {
+		return err
+	}
+	logger := gologger.WithComponent("service")
+	logger.Info().Str("service", serviceName()).Msg("Service stopped")
+	return nil
+}
+
+// ExecuteServiceStatus prints whether the service is installed and running
+func ExecuteServiceStatus() error {
+	m, err := serviceManager()
+	if err != nil {
+		return err
+	}
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Service:  %s\n", serviceName())
+	fmt.Printf("Status:   %s\n", status)
+	if status != daemon.StatusNotInstalled {
+		fmt.Printf("Defined:  %s\n", m.Path())
+	}
+	return nil
+}
+
+// ExecuteServiceRun is what the installed service runs: the runner started
+// with args, its output written to a log rotated by size
+func ExecuteServiceRun(opts ServiceOptions, args []string) error {
+	if opts.LogFile == "" {
+		return errors.New("--log-file is required")
+	}
+	maxSize, err := task.ParseMemory(opts.LogMaxSize)
+	if err != nil {
+		return fmt.Errorf("invalid log size %q: %w", opts.LogMaxSize, err)
+	}
+	logFile, err := daemon.OpenLogFile(opts.LogFile, maxSize, opts.LogFiles)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find runner executable: %w", err)
+	}
+	err = daemon.RunService(serviceName(), func(ctx context.Context) error {
+		return daemon.Run(ctx, executable, args, logFile)
+	})
+	if err != nil {
+		// Leave the reason next to the runner's own output
+		fmt.Fprintf(logFile, "parity-runner service: %v\n", err)
+	}
+	return err
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 
 func RunStake() {
 	var amount float64
+	var topUp bool
 
 	logger := gologger.Get().With().Str("component", "stake").Logger()
 	logger.Info().Msg("Starting staking process...")
@@ -32,6 +34,14 @@ func RunStake() {
 				DefaultFloat64: 1.0,
 				Required:       true,
 			},
+			"chain": {
+				Type:        utils.FlagTypeString,
+				Description: "Chain of the chain registry to use, such as base or polygon (default: BLOCKCHAIN_CHAIN)",
+			},
+			"top-up": {
+				Type:        utils.FlagTypeBool,
+				Description: "Add to the device's existing stake",
+			},
 		},
 		RunFunc: func(cmd *cobra.Command, args []string) error {
 			var err error
@@ -40,18 +50,26 @@ func RunStake() {
 				return err
 			}
 
+			topUp, err = cmd.Flags().GetBool("top-up")
+			if err != nil {
+				return err
+			}
+
 			logger.Info().
 				Float64("amount", amount).
+				Bool("top_up", topUp).
 				Msg("Processing stake request")
 
-			return executeStake(amount)
+			return executeStake(amount, topUp)
 		},
 	}, logger)
 
 	utils.ExecuteCommand(cmd, logger)
 }
 
-func executeStake(amount float64) error {
+// executeStake stakes amount for this device. With topUp the device must
+// already be staked by this wallet, and amount is added to that stake.
+func executeStake(amount float64, topUp bool) error {
 	logger := gologger.Get().With().Str("component", "stake").Logger()
 
 	cfg, err := utils.GetConfig()
@@ -81,8 +99,42 @@ func executeStake(amount float64) error {
 	logger.Info().
 		Str("device_id", deviceID).
 		Str("wallet", client.Address().Hex()).
+		Str("chain", utils.ChainLabel(cfg)).
 		Msg("Device verified successfully")
 
+	tokenSymbol := utils.TokenLabel(cfg)
+	amountToStake := amountWei(amount)
+
+	if topUp {
+		stakeInfo, err := client.GetStakeInfo(deviceID)
+		if err != nil {
+			logger.Fatal().
+				Err(err).
+				Str("device_id", deviceID).
+				Msg("Failed to get stake info - please try again")
+			return err
+		}
+		if !stakeInfo.Exists {
+			logger.Fatal().
+				Str("device_id", deviceID).
+				Msg("Device has no stake to top up - please stake without --top-up first")
+			return fmt.Errorf("device %s has no stake to top up", deviceID)
+		}
+		if stakeInfo.WalletAddress != client.Address() {
+			logger.Fatal().
+				Str("device_id", deviceID).
+				Str("staked_by", stakeInfo.WalletAddress.Hex()).
+				Msg("Device was staked by another wallet - please top up with that wallet")
+			return fmt.Errorf("device %s was staked by %s", deviceID, stakeInfo.WalletAddress.Hex())
+		}
+
+		logger.Info().
+			Str("current_stake", utils.FormatEther(stakeInfo.Amount)+" "+tokenSymbol).
+			Str("top_up", utils.FormatEther(amountToStake)+" "+tokenSymbol).
+			Str("new_stake", utils.FormatEther(new(big.Int).Add(stakeInfo.Amount, amountToStake))+" "+tokenSymbol).
+			Msg("Topping up existing stake")
+	}
+
 	tokenAddr := common.HexToAddress(cfg.Blockchain.TokenAddress)
 	stakeWalletAddr := common.HexToAddress(cfg.Blockchain.StakeWalletAddress)
 
@@ -106,11 +158,6 @@ func executeStake(amount float64) error {
 		return err
 	}
 
-	amountToStake := amountWei(amount)
-	tokenSymbol := cfg.Blockchain.TokenSymbol
-	if tokenSymbol == "" {
-		tokenSymbol = "TOKEN"
-	}
 	if balance.Cmp(amountToStake) < 0 {
 		logger.Fatal().
 			Str("current_balance", utils.FormatEther(balance)+" "+tokenSymbol).
@@ -232,6 +279,14 @@ func executeStake(amount float64) error {
 			Str("wallet", client.Address().Hex()).
 			Uint64("block_number", receipt.BlockNumber.Uint64()).
 			Msg("Stake transaction confirmed successfully! Your device is now registered and ready to process tasks.")
+
+		if topUp {
+			if stakeInfo, err := client.GetStakeInfo(deviceID); err == nil {
+				logger.Info().
+					Str("total_stake", utils.FormatEther(stakeInfo.Amount)+" "+tokenSymbol).
+					Msg("Stake topped up")
+			}
+		}
 	} else {
 		logger.Error().
 			Str("tx_hash", tx.Hash().Hex()).
@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/artifacts"
+	"github.com/theblitlabs/parity-runner/internal/events"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/journal"
+	"github.com/theblitlabs/parity-runner/internal/ledger"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
+	"github.com/theblitlabs/parity-runner/internal/receipt"
+	"github.com/theblitlabs/parity-runner/internal/state"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// EnvStatePassphrase holds the passphrase of state bundles when no passphrase
+// file is given
+const EnvStatePassphrase = "PARITY_STATE_PASSPHRASE"
+
+// StateOptions are the flags of the state commands
+type StateOptions struct {
+	PassphraseFile string
+	// NoArtifacts leaves stored task artifacts out of an export
+	NoArtifacts bool
+	// Force lets an import replace another wallet's key and a different config
+	Force bool
+}
+
+func statePassphrase(file string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		if passphrase := strings.TrimRight(string(data), "\r\n"); passphrase != "" {
+			return passphrase, nil
+		}
+		return "", errors.New("passphrase file is empty")
+	}
+	if passphrase := os.Getenv(EnvStatePassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+	return "", fmt.Errorf("pass --passphrase-file or set %s to protect the wallet key", EnvStatePassphrase)
+}
+
+// statePaths are the parts of the data directory that move with a runner:
+// undelivered results, tasks held across restarts, receipts, the earnings
+// ledger, task execution logs, task artifacts and the pinned server identity.
+// Benchmarks and caches describe the old hardware and stay behind.
+func statePaths(dataDir string, withArtifacts bool) ([]string, error) {
+	dirs := []func() (string, error){outbox.DefaultDir, journal.DefaultDir, receipt.DefaultDir, ledger.DefaultDir, events.DefaultDir, identity.DefaultPinPath}
+	if withArtifacts {
+		dirs = append(dirs, artifacts.DefaultDir)
+	}
+
+	var paths []string
+	for _, dir := range dirs {
+		path, err := dir()
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(dataDir, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate %s: %w", path, err)
+		}
+		paths = append(paths, rel)
+	}
+	return paths, nil
+}
+
+// ExecuteStateExport writes this runner's identity and pending work to a bundle
+// at path, for ExecuteStateImport to restore on another machine
+func ExecuteStateExport(path string, opts StateOptions) error {
+	logger := gologger.Get().With().Str("component", "state").Logger()
+
+	passphrase, err := statePassphrase(opts.PassphraseFile)
+	if err != nil {
+		return err
+	}
+	key, err := utils.GetPrivateKey()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return errors.New("no wallet key to export, run auth first")
+	}
+	deviceID, err := utils.GetDeviceID()
+	if err != nil {
+		return fmt.Errorf("failed to get device ID: %w", err)
+	}
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return err
+	}
+	paths, err := statePaths(dataDir, !opts.NoArtifacts)
+	if err != nil {
+		return err
+	}
+
+	config, err := os.ReadFile(utils.GetConfigPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	manifest, err := state.Export(f, &state.State{
+		Key:      key,
+		DeviceID: deviceID,
+		Network:  utils.Network(),
+		Config:   config,
+		DataDir:  dataDir,
+		Paths:    paths,
+	}, passphrase)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write bundle: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	logger.Info().
+		Str("path", path).
+		Str("wallet", manifest.WalletAddress).
+		Str("device_id", manifest.DeviceID).
+		Int("files", manifest.Files).
+		Msg("Runner state exported, stop this runner before starting the imported one")
+	return nil
+}
+
+// ExecuteStateImport restores a bundle written by ExecuteStateExport, making
+// this machine the runner it was exported from
+func ExecuteStateImport(path string, opts StateOptions) error {
+	logger := gologger.Get().With().Str("component", "state").Logger()
+
+	passphrase, err := statePassphrase(opts.PassphraseFile)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	bundle, err := state.Open(f, passphrase)
+	if err != nil {
+		return err
+	}
+	manifest := bundle.Manifest
+	if manifest.Network != "" && manifest.Network != utils.Network() {
+		return fmt.Errorf("bundle is from %s, import it with --network %s", manifest.Network, manifest.Network)
+	}
+
+	if address, err := utils.GetWalletAddress(); err == nil && !opts.Force {
+		if address != manifest.WalletAddress {
+			return fmt.Errorf("this machine holds the key of %s, pass --force to replace it with %s", address, manifest.WalletAddress)
+		}
+	}
+	configPath := utils.GetConfigPath()
+	if bundle.Config != nil && !opts.Force {
+		if existing, err := os.ReadFile(configPath); err == nil && !bytes.Equal(existing, bundle.Config) {
+			return fmt.Errorf("%s differs from the bundled config, pass --force to replace it", configPath)
+		}
+	}
+
+	// The key is kept encrypted on this machine too, under its own passphrase
+	// or in the OS secret store
+	keystorePassphrase, err := utils.NewKeyPassphrase()
+	if err != nil {
+		return err
+	}
+	if err := utils.SavePrivateKey(common.Bytes2Hex(crypto.FromECDSA(bundle.Key)), keystorePassphrase); err != nil {
+		return fmt.Errorf("failed to save wallet key: %w", err)
+	}
+	if err := utils.RemoveSignerConfig(); err != nil {
+		return err
+	}
+	if manifest.DeviceID != "" {
+		if err := utils.SaveDeviceID(manifest.DeviceID); err != nil {
+			return err
+		}
+	}
+	if bundle.Config != nil {
+		if err := os.WriteFile(configPath, bundle.Config, 0o600); err != nil {
+			return fmt.Errorf("failed to write config: %w", err)
+		}
+	}
+
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return err
+	}
+	files, err := bundle.Extract(dataDir)
+	if err != nil {
+		return err
+	}
+
+	logger.Info().
+		Str("wallet", manifest.WalletAddress).
+		Str("device_id", manifest.DeviceID).
+		Int("files", files).
+		Time("exported_at", manifest.ExportedAt).
+		Msg("Runner state imported, queued results are delivered when the runner starts")
+	return nil
+}
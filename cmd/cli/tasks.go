@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/events"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+	"github.com/theblitlabs/parity-runner/pkg/client"
+)
+
+// ExecuteTaskHistory replays the execution log of a task, read from the local
+// event log or from a result file it was attached to, and checks its hash chain
+func ExecuteTaskHistory(ref string, asJSON bool) error {
+	history, err := loadExecutionLog(ref)
+	if err != nil {
+		return err
+	}
+	verifyErr := events.Verify(history)
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(history); err != nil {
+			return fmt.Errorf("failed to write task history: %w", err)
+		}
+	} else {
+		if err := printExecutionLog(history); err != nil {
+			return err
+		}
+		if verifyErr == nil {
+			fmt.Printf("\n%d events, hash chain intact\n", len(history))
+		}
+	}
+	if verifyErr != nil {
+		return fmt.Errorf("task history failed verification: %w", verifyErr)
+	}
+	return nil
+}
+
+// loadExecutionLog accepts a task result file or the ID of a task run on this
+// runner
+func loadExecutionLog(ref string) (models.ExecutionLog, error) {
+	if _, err := os.Stat(ref); err == nil {
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read task result: %w", err)
+		}
+		var result models.TaskResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse task result: %w", err)
+		}
+		if len(result.ExecutionLog) == 0 {
+			return nil, fmt.Errorf("%s has no execution log attached", ref)
+		}
+		return result.ExecutionLog, nil
+	}
+
+	dir, err := events.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return events.NewStore(dir).Load(ref)
+}
+
+func printExecutionLog(history models.ExecutionLog) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tTIME\tELAPSED\tEVENT\tDETAILS")
+	for _, event := range history {
+		elapsed := event.Time.Sub(history[0].Time).Round(time.Millisecond)
+		fmt.Fprintf(w, "%d\t%s\t+%s\t%s\t%s\n",
+			event.Seq, event.Time.Local().Format(time.DateTime), elapsed, event.Type, formatDetails(event.Details))
+	}
+	return w.Flush()
+}
+
+// formatDetails lists details as key=value, sorted by key
+func formatDetails(details map[string]string) string {
+	keys := make([]string, 0, len(details))
+	for key, value := range details {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + "=" + details[key]
+	}
+	return strings.Join(parts, " ")
+}
+
+// ExecuteTaskCancel asks the server to cancel a task created from this
+// device. A running task stays running until its runner has stopped it.
+func ExecuteTaskCancel(taskID string) error {
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	deviceID, err := utils.GetDeviceID()
+	if err != nil {
+		return fmt.Errorf("failed to get device ID: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	task, err := client.New(cfg.Runner.ServerURL, client.WithDeviceID(deviceID)).CancelTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+
+	if task.Status == models.TaskStatusCancelled {
+		fmt.Printf("Task %s cancelled\n", taskID)
+	} else {
+		fmt.Printf("Task %s is %s, its runner was told to stop it\n", taskID, task.Status)
+	}
+	return nil
+}
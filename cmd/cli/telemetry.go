@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/capability"
+	"github.com/theblitlabs/parity-runner/internal/gpu"
+	"github.com/theblitlabs/parity-runner/internal/telemetry"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// ExecuteTelemetryPreview prints the report the runner would send next, with
+// whether telemetry is on on stderr, so the report itself can be piped
+func ExecuteTelemetryPreview() error {
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	dir, err := telemetry.DefaultDir()
+	if err != nil {
+		return err
+	}
+	collector, err := telemetry.NewCollector(dir)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	gpus, _ := gpu.Detect(ctx)
+	hardware := telemetry.Classify(capability.Measure(ctx, capability.Options{GPUs: gpus}))
+	reporter := telemetry.NewReporter(collector, cfg.Runner.Telemetry.Endpoint, cfg.Runner.ServerURL, hardware, cfg.Runner.Telemetry.Interval)
+
+	switch {
+	case telemetry.Disabled():
+		fmt.Fprintf(os.Stderr, "Telemetry is off: turned off by %s or DO_NOT_TRACK. Nothing is collected or sent.\n", telemetry.EnvDisable)
+	case !cfg.Runner.Telemetry.Enabled:
+		fmt.Fprintln(os.Stderr, "Telemetry is off: set RUNNER_TELEMETRY_ENABLED=true to opt in. Nothing is collected or sent.")
+	default:
+		interval := cfg.Runner.Telemetry.Interval
+		if interval <= 0 {
+			interval = telemetry.DefaultInterval
+		}
+		fmt.Fprintf(os.Stderr, "Telemetry is on: this report is sent to %s, every %s.\n", reporter.Endpoint(), interval)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(collector.Report(hardware, time.Now()))
+}
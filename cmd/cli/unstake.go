@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	walletsdk "github.com/theblitlabs/go-wallet-sdk"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/federation"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// stakeWalletUnstakeABI is the part of the stake wallet contract that releases
+// stake. Unstaked tokens can be withdrawn once unstakeCooldown seconds passed.
+const stakeWalletUnstakeABI = `[
+	{"type":"function","name":"unstake","stateMutability":"nonpayable",
+	 "inputs":[{"name":"deviceID","type":"string"},{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"unstakeCooldown","stateMutability":"view",
+	 "inputs":[],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+// UnstakeOptions select how much stake to release
+type UnstakeOptions struct {
+	// Amount is in tokens
+	Amount float64
+	// Force unstakes although results are still undelivered or rewards unpaid
+	Force bool
+}
+
+// ExecuteUnstake releases part or all of this device's stake to the wallet that
+// staked it. Results still waiting in the outbox and rewards coordinators have
+// not paid yet block it unless opts.Force is set, since they may not be paid
+// to a device without stake.
+func ExecuteUnstake(opts UnstakeOptions) error {
+	logger := gologger.Get().With().Str("component", "unstake").Logger()
+
+	amount, err := unstakeAmount(opts.Amount)
+	if err != nil {
+		return err
+	}
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	client, err := utils.NewClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create wallet client: %w", err)
+	}
+	deviceID, err := utils.GetDeviceID()
+	if err != nil {
+		return fmt.Errorf("failed to get device ID: %w", err)
+	}
+
+	stakeInfo, err := client.GetStakeInfo(deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to get stake info: %w", err)
+	}
+	tokenSymbol := utils.TokenLabel(cfg)
+	remaining, err := remainingStake(deviceID, stakeInfo, client.Address(), amount, tokenSymbol)
+	if err != nil {
+		return err
+	}
+
+	blockers := pendingWork(cfg.Runner.ServerURL, cfg.Runner.Federation.Coordinators, deviceID)
+	if err := checkPendingWork(deviceID, blockers, opts.Force); err != nil {
+		return err
+	}
+	if len(blockers) > 0 {
+		logger.Warn().Strs("pending", blockers).Msg("Unstaking with pending work, it may not be paid")
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(stakeWalletUnstakeABI))
+	if err != nil {
+		return fmt.Errorf("failed to parse stake wallet ABI: %w", err)
+	}
+	stakeWalletAddr := common.HexToAddress(cfg.Blockchain.StakeWalletAddress)
+	stakeWallet := bind.NewBoundContract(stakeWalletAddr, parsed, client, client, client)
+
+	var cooldown []interface{}
+	if err := stakeWallet.Call(&bind.CallOpts{}, &cooldown, "unstakeCooldown"); err != nil {
+		logger.Warn().Err(err).Msg("Failed to read the unstake cooldown from the stake wallet")
+	} else if seconds, ok := cooldown[0].(*big.Int); ok {
+		logger.Info().
+			Str("cooldown", (time.Duration(seconds.Int64())*time.Second).String()).
+			Str("available_after", time.Now().Add(time.Duration(seconds.Int64())*time.Second).UTC().Format(time.RFC3339)).
+			Msg("Unstaked tokens can be withdrawn once the cooldown has passed")
+	}
+
+	logger.Info().
+		Str("device_id", deviceID).
+		Str("amount", utils.FormatEther(amount)+" "+tokenSymbol).
+		Str("remaining_stake", utils.FormatEther(remaining)+" "+tokenSymbol).
+		Msg("Submitting unstake transaction...")
+
+	txOpts, err := client.GetTransactOpts()
+	if err != nil {
+		return fmt.Errorf("failed to prepare transaction: %w", err)
+	}
+	tx, err := stakeWallet.Transact(txOpts, "unstake", deviceID, amount)
+	if err != nil {
+		return fmt.Errorf("failed to submit unstake transaction: %w", err)
+	}
+	logger.Info().
+		Str("tx_hash", tx.Hash().Hex()).
+		Msg("Unstake transaction submitted - waiting for confirmation...")
+
+	ctx, cancel := utils.WithCustomTimeout(5 * time.Minute)
+	defer cancel()
+	receipt, err := bind.WaitMined(ctx, client, tx)
+	if err != nil {
+		return fmt.Errorf("failed to confirm unstake transaction %s, check its status: %w", tx.Hash().Hex(), err)
+	}
+	if receipt.Status != 1 {
+		return fmt.Errorf("unstake transaction %s reverted", tx.Hash().Hex())
+	}
+
+	logger.Info().
+		Str("tx_hash", tx.Hash().Hex()).
+		Uint64("block_number", receipt.BlockNumber.Uint64()).
+		Uint64("gas_used", receipt.GasUsed).
+		Str("amount", utils.FormatEther(amount)+" "+tokenSymbol).
+		Str("remaining_stake", utils.FormatEther(remaining)+" "+tokenSymbol).
+		Msg("Unstake transaction confirmed")
+	if remaining.Sign() == 0 {
+		logger.Warn().Msg("The device has no stake left and will not receive tasks until it is staked again")
+	}
+	return nil
+}
+
+// unstakeAmount converts the tokens to unstake to wei
+func unstakeAmount(tokens float64) (*big.Int, error) {
+	if math.IsNaN(tokens) || math.IsInf(tokens, 0) || tokens <= 0 {
+		return nil, fmt.Errorf("amount to unstake must be positive")
+	}
+	amount := amountWei(tokens)
+	if amount.Sign() <= 0 {
+		return nil, fmt.Errorf("amount to unstake is less than one wei")
+	}
+	return amount, nil
+}
+
+// remainingStake is the stake the device keeps after wallet unstakes amount
+// from it. Only the wallet that staked the device can unstake, and no more than
+// it staked.
+func remainingStake(deviceID string, stake walletsdk.StakeInfo, wallet common.Address, amount *big.Int, tokenSymbol string) (*big.Int, error) {
+	if !stake.Exists || stake.Amount == nil {
+		return nil, fmt.Errorf("device %s has no stake", deviceID)
+	}
+	if stake.WalletAddress != wallet {
+		return nil, fmt.Errorf("device %s was staked by %s, unstake with that wallet", deviceID, stake.WalletAddress.Hex())
+	}
+	if amount.Cmp(stake.Amount) > 0 {
+		return nil, fmt.Errorf("cannot unstake %s %s, the device has %s %s staked", utils.FormatEther(amount), tokenSymbol, utils.FormatEther(stake.Amount), tokenSymbol)
+	}
+	return new(big.Int).Sub(stake.Amount, amount), nil
+}
+
+// checkPendingWork keeps the stake locked while blockers remain, unless force
+// is set
+func checkPendingWork(deviceID string, blockers []string, force bool) error {
+	if len(blockers) > 0 && !force {
+		return fmt.Errorf("device %s still has %s; wait for them to settle or pass --force", deviceID, strings.Join(blockers, " and "))
+	}
+	return nil
+}
+
+// pendingWork describes what the device could lose by unstaking now: results
+// in the outbox and rewards the coordinators report as not yet paid.
+// Coordinators that cannot be reached are skipped.
+func pendingWork(serverURL, federated, deviceID string) []string {
+	var pending []string
+
+	if dir, err := outbox.DefaultDir(); err == nil {
+		if entries, err := outbox.NewStore(dir).List(); err == nil && len(entries) > 0 {
+			pending = append(pending, fmt.Sprintf("%d undelivered results", len(entries)))
+		}
+	}
+
+	coordinators, err := federation.ParseCoordinators(serverURL, federated)
+	if err != nil {
+		return pending
+	}
+	ctx, cancel := utils.WithTimeout()
+	defer cancel()
+	summary := federation.FetchEarnings(ctx, &http.Client{Timeout: 15 * time.Second}, coordinators, deviceID)
+	if summary.PendingTotal > 0 {
+		pending = append(pending, fmt.Sprintf("%.4f in unpaid rewards", summary.PendingTotal))
+	}
+	return pending
+}
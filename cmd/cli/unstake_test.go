@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	walletsdk "github.com/theblitlabs/go-wallet-sdk"
+)
+
+func TestUnstakeAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  float64
+		want    *big.Int
+		wantErr string
+	}{
+		{"whole tokens", 5, new(big.Int).Mul(big.NewInt(5), big.NewInt(1e18)), ""},
+		{"fraction", 0.5, big.NewInt(5e17), ""},
+		{"zero", 0, nil, "must be positive"},
+		{"negative", -1, nil, "must be positive"},
+		{"not a number", math.NaN(), nil, "must be positive"},
+		{"infinite", math.Inf(1), nil, "must be positive"},
+		{"below one wei", 1e-20, nil, "less than one wei"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unstakeAmount(tt.tokens)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("unstakeAmount(%v) = %v, %v, want error %q", tt.tokens, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got.Cmp(tt.want) != 0 {
+				t.Fatalf("unstakeAmount(%v) = %v, %v, want %v", tt.tokens, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteUnstakeRejectsInvalidAmountBeforeLoadingConfig(t *testing.T) {
+	if err := ExecuteUnstake(UnstakeOptions{Amount: -2}); err == nil || !strings.Contains(err.Error(), "must be positive") {
+		t.Fatalf("ExecuteUnstake() = %v, want an invalid amount error", err)
+	}
+}
+
+func TestRemainingStake(t *testing.T) {
+	staker := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	staked := walletsdk.StakeInfo{Exists: true, Amount: big.NewInt(10), DeviceID: "device-1", WalletAddress: staker}
+
+	tests := []struct {
+		name    string
+		stake   walletsdk.StakeInfo
+		wallet  common.Address
+		amount  int64
+		want    int64
+		wantErr string
+	}{
+		{"part", staked, staker, 4, 6, ""},
+		{"all", staked, staker, 10, 0, ""},
+		{"more than staked", staked, staker, 11, 0, "cannot unstake"},
+		{"another wallet", staked, other, 1, 0, "unstake with that wallet"},
+		{"no stake", walletsdk.StakeInfo{}, staker, 1, 0, "has no stake"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := remainingStake("device-1", tt.stake, tt.wallet, big.NewInt(tt.amount), "PRTY")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("remainingStake() = %v, %v, want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got.Int64() != tt.want {
+				t.Fatalf("remainingStake() = %v, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPendingWorkKeepsStakeLocked(t *testing.T) {
+	blockers := []string{"2 undelivered results", "1.5000 in unpaid rewards"}
+
+	err := checkPendingWork("device-1", blockers, false)
+	if err == nil || !strings.Contains(err.Error(), "2 undelivered results and 1.5000 in unpaid rewards") || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("checkPendingWork() = %v, want the pending work named", err)
+	}
+	if err := checkPendingWork("device-1", blockers, true); err != nil {
+		t.Fatalf("checkPendingWork() with force = %v", err)
+	}
+	if err := checkPendingWork("device-1", nil, false); err != nil {
+		t.Fatalf("checkPendingWork() without pending work = %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/provenance"
+)
+
+// ExecuteVersion prints the build provenance of this binary
+func ExecuteVersion(jsonOutput bool) error {
+	build := provenance.Current()
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(build)
+	}
+
+	fmt.Printf("parity-runner %s\n", build.RunnerVersion)
+	fmt.Printf("  commit:      %s\n", valueOr(build.Commit, "unknown"))
+	if build.Dirty {
+		fmt.Println("  modified:    yes, built from a tree with local changes")
+	}
+	fmt.Printf("  builder:     %s\n", valueOr(build.Builder, "unknown"))
+	fmt.Printf("  attestation: %s\n", valueOr(build.Attestation, "none"))
+	fmt.Printf("  go:          %s\n", build.GoVersion)
+	if signer, err := provenance.Signer(build); err != nil {
+		fmt.Println("  release:     not a signed release")
+	} else {
+		fmt.Printf("  release:     signed by %s\n", signer.Hex())
+	}
+	return nil
+}
+
+// VersionSignOptions is the release to sign
+type VersionSignOptions struct {
+	Version     string
+	Commit      string
+	Builder     string
+	Attestation string
+	// KeyFile holds the hex release key
+	KeyFile string
+}
+
+// ExecuteVersionSign prints the release key's signature over the provenance of
+// a release, to be embedded in its binaries at build time
+func ExecuteVersionSign(opts VersionSignOptions) error {
+	if _, err := provenance.CompareVersions(opts.Version, opts.Version); err != nil {
+		return err
+	}
+	if opts.Commit == "" {
+		return fmt.Errorf("commit is required")
+	}
+	key, err := crypto.LoadECDSA(opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load release key: %w", err)
+	}
+
+	build := &models.BuildProvenance{
+		RunnerVersion: opts.Version,
+		Commit:        opts.Commit,
+		Builder:       opts.Builder,
+		Attestation:   opts.Attestation,
+	}
+	if err := provenance.Sign(build, key); err != nil {
+		return err
+	}
+	fmt.Println(build.Signature)
+	return nil
+}
+
+func valueOr(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
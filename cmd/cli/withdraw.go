@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/gas"
+	"github.com/theblitlabs/parity-runner/internal/rewards"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// WithdrawOptions select how much of the accrued rewards to withdraw
+type WithdrawOptions struct {
+	// Amount is in tokens, every accrued reward when zero
+	Amount float64
+	// MinAmount skips the withdrawal while less than it has accrued, so the
+	// command can run from cron without paying gas for dust
+	MinAmount float64
+	DryRun    bool
+	// ConfirmTimeout and Retries override BLOCKCHAIN_GAS_CONFIRM_TIMEOUT and
+	// BLOCKCHAIN_GAS_RETRIES when set
+	ConfirmTimeout time.Duration
+	Retries        int
+}
+
+// ExecuteWithdraw withdraws the rewards the stake wallet accrued for this
+// runner's device to the wallet that staked it
+func ExecuteWithdraw(opts WithdrawOptions) error {
+	logger := gologger.Get().With().Str("component", "withdraw").Logger()
+
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	// Withdrawals are signed by the runner's signer, which may be a hardware
+	// wallet, so the client only reads
+	client, err := utils.NewReadOnlyClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create wallet client: %w", err)
+	}
+	deviceID, err := utils.GetDeviceID()
+	if err != nil {
+		return fmt.Errorf("failed to get device ID: %w", err)
+	}
+	walletSigner, err := utils.GetSigner()
+	if err != nil {
+		return fmt.Errorf("failed to load wallet signer, run parity-runner auth first: %w", err)
+	}
+
+	stakeInfo, err := client.GetStakeInfo(deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to get stake info: %w", err)
+	}
+	if !stakeInfo.Exists {
+		return fmt.Errorf("device %s has no stake, so it has no rewards to withdraw", deviceID)
+	}
+	if stakeInfo.WalletAddress != walletSigner.Address() {
+		return fmt.Errorf("device %s was staked by %s, withdraw with that wallet", deviceID, stakeInfo.WalletAddress.Hex())
+	}
+
+	gasConfig := gas.FromConfig(cfg.Blockchain.Gas)
+	if opts.ConfirmTimeout > 0 {
+		gasConfig.ConfirmTimeout = opts.ConfirmTimeout
+	}
+	if opts.Retries > 0 {
+		gasConfig.Retries = opts.Retries
+	}
+	withdrawer, err := rewards.NewWithdrawerWithSigner(client, common.HexToAddress(cfg.Blockchain.StakeWalletAddress), walletSigner,
+		big.NewInt(cfg.Blockchain.ChainID), gasConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := utils.WithTimeout()
+	accrued, err := withdrawer.Accrued(ctx, deviceID)
+	cancel()
+	if err != nil {
+		return err
+	}
+	tokenSymbol := utils.TokenLabel(cfg)
+	logger.Info().
+		Str("device_id", deviceID).
+		Str("accrued", utils.FormatEther(accrued)+" "+tokenSymbol).
+		Msg("Accrued rewards")
+
+	amount := accrued
+	if opts.Amount > 0 {
+		amount = amountWei(opts.Amount)
+		if amount.Cmp(accrued) > 0 {
+			return fmt.Errorf("cannot withdraw %s %s, only %s %s has accrued", utils.FormatEther(amount), tokenSymbol, utils.FormatEther(accrued), tokenSymbol)
+		}
+	}
+	switch {
+	case accrued.Sign() == 0:
+		logger.Info().Msg("No rewards to withdraw")
+		return nil
+	case opts.MinAmount > 0 && accrued.Cmp(amountWei(opts.MinAmount)) < 0:
+		logger.Info().
+			Str("min_amount", utils.FormatEther(amountWei(opts.MinAmount))+" "+tokenSymbol).
+			Msg("Less than the minimum has accrued, not withdrawing")
+		return nil
+	case opts.DryRun:
+		logger.Info().
+			Str("amount", utils.FormatEther(amount)+" "+tokenSymbol).
+			Str("wallet", walletSigner.Address().Hex()).
+			Msg("Dry run, no withdrawal sent")
+		return nil
+	}
+
+	ctx, cancel = utils.WithCustomTimeout(withdrawer.MaxWait() + utils.DefaultTimeout)
+	defer cancel()
+
+	logger.Info().
+		Str("amount", utils.FormatEther(amount)+" "+tokenSymbol).
+		Str("wallet", walletSigner.Address().Hex()).
+		Msg("Withdrawing rewards...")
+	receipt, err := withdrawer.Withdraw(ctx, deviceID, amount)
+	if errors.Is(err, rewards.ErrReverted) {
+		return fmt.Errorf("%w, check the transaction in a block explorer", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info().
+		Str("tx_hash", receipt.TxHash.Hex()).
+		Uint64("block_number", receipt.BlockNumber.Uint64()).
+		Uint64("gas_used", receipt.GasUsed).
+		Str("amount", utils.FormatEther(amount)+" "+tokenSymbol).
+		Str("wallet", walletSigner.Address().Hex()).
+		Msg("Rewards withdrawn")
+	return nil
+}
@@ -10,12 +10,18 @@ import (
 	"github.com/theblitlabs/gologger"
 
 	"github.com/theblitlabs/parity-runner/cmd/cli"
+	"github.com/theblitlabs/parity-runner/internal/benchmark"
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker"
+	"github.com/theblitlabs/parity-runner/internal/hooks"
 	"github.com/theblitlabs/parity-runner/internal/utils"
 )
 
 var (
 	logMode    string
 	configPath string
+	instance   string
+	network    string
 )
 
 var rootCmd = &cobra.Command{
@@ -31,6 +37,26 @@ var rootCmd = &cobra.Command{
 			gologger.InitWithMode(gologger.LogModePretty)
 		}
 
+		if instance != "" {
+			if err := utils.SetInstance(instance); err != nil {
+				log.Fatal().Err(err).Msg("Invalid instance name")
+			}
+		}
+
+		if network != "" {
+			if err := utils.SetNetwork(network); err != nil {
+				log.Fatal().Err(err).Msg("Invalid network")
+			}
+		}
+		if flag := cmd.Flags().Lookup("chain"); flag != nil && flag.Changed {
+			if err := utils.SetChain(flag.Value.String()); err != nil {
+				log.Fatal().Err(err).Msg("Invalid chain")
+			}
+		}
+		if utils.Network() == config.NetworkTestnet {
+			log.Warn().Msg("Running on testnet: tokens and rewards have no real value")
+		}
+
 		// Load configuration
 		if configPath != "" {
 			if _, err := utils.GetConfigWithPath(configPath); err != nil {
@@ -46,8 +72,27 @@ var rootCmd = &cobra.Command{
 func main() {
 	rootCmd.AddCommand(authCmd)
 	rootCmd.AddCommand(stakeCmd)
+	rootCmd.AddCommand(unstakeCmd)
 	rootCmd.AddCommand(runnerCmd)
+	rootCmd.AddCommand(serverCmd)
 	rootCmd.AddCommand(balanceCmd)
+	rootCmd.AddCommand(receiptCmd)
+	rootCmd.AddCommand(withdrawCmd)
+	rootCmd.AddCommand(faucetCmd)
+	rootCmd.AddCommand(artifactsCmd)
+	rootCmd.AddCommand(tasksCmd)
+	rootCmd.AddCommand(resultsCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(earningsCmd)
+	rootCmd.AddCommand(telemetryCmd)
+	rootCmd.AddCommand(benchCmd)
+	rootCmd.AddCommand(benchmarkCmd)
+	rootCmd.AddCommand(stateCmd)
+	rootCmd.AddCommand(keystoreCmd)
+	rootCmd.AddCommand(serviceCmd)
+	rootCmd.AddCommand(drainCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(hookPluginCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -63,6 +108,134 @@ var authCmd = &cobra.Command{
 	},
 }
 
+var earningsCmd = &cobra.Command{
+	Use:   "earnings",
+	Short: "Show earnings reported by every coordinator this runner works for",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteEarnings(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to fetch earnings")
+		}
+	},
+}
+
+var earningsReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Export a month of rewards paid to every runner of a wallet, for accounting",
+	Example: `  # Export last month's payouts of this runner's wallet as CSV
+  parity-runner earnings report --month 2026-09 --format csv --output earnings-2026-09.csv
+
+  # Report on another wallet running several runners
+  parity-runner earnings report --wallet 0x52908400098527886E0F7030069857D2E4169EE7 --format json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts cli.EarningsReportOptions
+		opts.Wallet, _ = cmd.Flags().GetString("wallet")
+		opts.Month, _ = cmd.Flags().GetString("month")
+		opts.Format, _ = cmd.Flags().GetString("format")
+		opts.Output, _ = cmd.Flags().GetString("output")
+		if err := cli.ExecuteEarningsReport(opts); err != nil {
+			log.Fatal().Err(err).Msg("Failed to export earnings report")
+		}
+	},
+}
+
+var earningsLedgerCmd = &cobra.Command{
+	Use:   "ledger",
+	Short: "Summarize the tasks this runner executed and what they paid, by day or week",
+	Example: `  # Weekly totals, after looking up payouts not yet recorded
+  parity-runner earnings ledger --period week --sync
+
+  # Daily totals of September
+  parity-runner earnings ledger --since 2026-09-01 --until 2026-10-01`,
+	Run: func(cmd *cobra.Command, args []string) {
+		period, _ := cmd.Flags().GetString("period")
+		if err := cli.ExecuteLedger(period, ledgerOptions(cmd)); err != nil {
+			log.Fatal().Err(err).Msg("Failed to show earnings ledger")
+		}
+	},
+}
+
+var earningsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the tasks this runner executed, with rewards, payouts and resource usage, as CSV",
+	Example: `  # The tax year 2025
+  parity-runner earnings export --since 2025-01-01 --until 2026-01-01 --sync --output tasks-2025.csv`,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		if err := cli.ExecuteLedgerExport(output, ledgerOptions(cmd)); err != nil {
+			log.Fatal().Err(err).Msg("Failed to export earnings ledger")
+		}
+	},
+}
+
+// ledgerOptions reads the flags shared by the earnings ledger commands
+func ledgerOptions(cmd *cobra.Command) cli.LedgerOptions {
+	var opts cli.LedgerOptions
+	opts.Since, _ = cmd.Flags().GetString("since")
+	opts.Until, _ = cmd.Flags().GetString("until")
+	opts.Sync, _ = cmd.Flags().GetBool("sync")
+	return opts
+}
+
+var telemetryCmd = &cobra.Command{
+	Use:   "telemetry",
+	Short: "Inspect the anonymous usage telemetry this runner sends when opted in",
+}
+
+var telemetryPreviewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Print the exact telemetry report the runner would send next",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteTelemetryPreview(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to preview telemetry")
+		}
+	},
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show the version and build provenance of this binary",
+	Run: func(cmd *cobra.Command, args []string) {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		if err := cli.ExecuteVersion(jsonOutput); err != nil {
+			log.Fatal().Err(err).Msg("Failed to show version")
+		}
+	},
+}
+
+var versionSignCmd = &cobra.Command{
+	Use:    "sign",
+	Short:  "Sign the provenance of a release with the release key",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts cli.VersionSignOptions
+		opts.Version, _ = cmd.Flags().GetString("version")
+		opts.Commit, _ = cmd.Flags().GetString("commit")
+		opts.Builder, _ = cmd.Flags().GetString("builder")
+		opts.Attestation, _ = cmd.Flags().GetString("attestation")
+		opts.KeyFile, _ = cmd.Flags().GetString("key-file")
+		if err := cli.ExecuteVersionSign(opts); err != nil {
+			log.Fatal().Err(err).Msg("Failed to sign release provenance")
+		}
+	},
+}
+
+// hookPluginCmd runs a plugin hook in a child process of the runner, so a hook
+// that hangs can be killed
+var hookPluginCmd = &cobra.Command{
+	Use:    hooks.PluginCommand + " <plugin> <stage>",
+	Short:  "Call a hook plugin with the hook context read from stdin",
+	Hidden: true,
+	Args:   cobra.ExactArgs(2),
+	// The hook's stderr is its rejection reason, so nothing else may log there
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := hooks.RunPlugin(args[0], args[1], os.Stdin); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
 var balanceCmd = &cobra.Command{
 	Use:   "balance",
 	Short: "Check token balances and stake status",
@@ -81,8 +254,24 @@ var runnerCmd = &cobra.Command{
   parity-runner runner --models llama2,mistral,codellama
   
   # Start runner with custom Ollama URL
-  parity-runner runner --ollama-url http://localhost:11434 --models llama2`,
+  parity-runner runner --ollama-url http://localhost:11434 --models llama2
+
+  # Start a second runner on the same host
+  parity-runner runner --instance gpu1 --ollama-url http://localhost:11435
+
+  # Checkpoint Docker task processes with CRIU and share the checkpoints over IPFS
+  parity-runner runner --checkpoint-mode criu --checkpoint-upload
+
+  # Bid for auctioned tasks at 0.02 per CPU hour and 0.5 per GPU hour plus 20%
+  parity-runner runner --bid --price-per-cpu-hour 0.02 --price-per-gpu-hour 0.5 --bid-margin 0.2`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := applyCheckpointFlags(cmd); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load config")
+		}
+		if err := applyBiddingFlags(cmd); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load config")
+		}
+
 		models, _ := cmd.Flags().GetStringSlice("models")
 		ollamaURL, _ := cmd.Flags().GetString("ollama-url")
 		autoInstall, _ := cmd.Flags().GetBool("auto-install")
@@ -96,27 +285,695 @@ var runnerCmd = &cobra.Command{
 var stakeCmd = &cobra.Command{
 	Use:   "stake",
 	Short: "Stake tokens in the network",
+	Example: `  # Stake 10 tokens for this runner
+  parity-runner stake --amount 10
+
+  # Add 5 tokens to the existing stake
+  parity-runner stake --amount 5 --top-up`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cli.RunStake()
 	},
 }
 
+var unstakeCmd = &cobra.Command{
+	Use:   "unstake",
+	Short: "Release part or all of this runner's stake",
+	Args:  cobra.NoArgs,
+	Example: `  # Unstake 5 tokens once results are delivered and rewards paid
+  parity-runner unstake --amount 5`,
+	Run: func(cmd *cobra.Command, args []string) {
+		amount, _ := cmd.Flags().GetFloat64("amount")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if err := cli.ExecuteUnstake(cli.UnstakeOptions{Amount: amount, Force: force}); err != nil {
+			log.Fatal().Err(err).Msg("Unstake failed")
+		}
+	},
+}
+
+var withdrawCmd = &cobra.Command{
+	Use:   "withdraw",
+	Short: "Withdraw the rewards accrued on-chain for this runner",
+	Example: `  # Withdraw everything accrued to the staking wallet
+  parity-runner withdraw
+
+  # Show what has accrued without sending a transaction
+  parity-runner withdraw --dry-run
+
+  # From cron: withdraw only once at least 5 tokens have accrued
+  parity-runner withdraw --min-amount 5`,
+	Run: func(cmd *cobra.Command, args []string) {
+		amount, _ := cmd.Flags().GetFloat64("amount")
+		minAmount, _ := cmd.Flags().GetFloat64("min-amount")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		confirmTimeout, _ := cmd.Flags().GetDuration("confirm-timeout")
+		retries, _ := cmd.Flags().GetInt("retries")
+
+		opts := cli.WithdrawOptions{
+			Amount:         amount,
+			MinAmount:      minAmount,
+			DryRun:         dryRun,
+			ConfirmTimeout: confirmTimeout,
+			Retries:        retries,
+		}
+		if err := cli.ExecuteWithdraw(opts); err != nil {
+			log.Fatal().Err(err).Msg("Withdrawal failed")
+		}
+	},
+}
+
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Start a task server for runners to connect to",
+	Example: `  # Serve the API on SERVER_HOST:SERVER_PORT under /api/v1
+  parity-runner server
+
+  # Check stakes and pay rewards on chain as well
+  SERVER_PRIVATE_KEY=... SERVER_MIN_STAKE=10 parity-runner server`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteServer(); err != nil {
+			log.Fatal().Err(err).Msg("Server failed")
+		}
+	},
+}
+
+var faucetCmd = &cobra.Command{
+	Use:   "faucet",
+	Short: "Request testnet tokens and optionally stake them",
+	Example: `  # Get test tokens for this runner's wallet
+  parity-runner faucet --network testnet
+
+  # Get test tokens and stake 10 of them
+  parity-runner faucet --network testnet --stake 10`,
+	Run: func(cmd *cobra.Command, args []string) {
+		stakeAmount, _ := cmd.Flags().GetFloat64("stake")
+
+		if err := cli.ExecuteFaucet(stakeAmount); err != nil {
+			log.Fatal().Err(err).Msg("Faucet request failed")
+		}
+	},
+}
+
+var receiptCmd = &cobra.Command{
+	Use:   "receipt",
+	Short: "Inspect and verify execution receipts",
+}
+
+var receiptVerifyCmd = &cobra.Command{
+	Use:   "verify <receipt-file|task-id>",
+	Short: "Verify the runner and server signatures of an execution receipt",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runnerAddress, _ := cmd.Flags().GetString("runner-address")
+		serverAddress, _ := cmd.Flags().GetString("server-address")
+		if err := cli.ExecuteReceiptVerify(args[0], runnerAddress, serverAddress); err != nil {
+			log.Fatal().Err(err).Msg("Receipt verification failed")
+		}
+	},
+}
+
+var receiptShowCmd = &cobra.Command{
+	Use:   "show <receipt-file|task-id>",
+	Short: "Print an execution receipt",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteReceiptShow(args[0]); err != nil {
+			log.Fatal().Err(err).Msg("Failed to show receipt")
+		}
+	},
+}
+
+var artifactsCmd = &cobra.Command{
+	Use:   "artifacts",
+	Short: "Inspect what recent tasks left on this runner",
+}
+
+var artifactsListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List tasks with stored artifacts, newest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteArtifactsList(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to list task artifacts")
+		}
+	},
+}
+
+var artifactsGetCmd = &cobra.Command{
+	Use:   "get <task-id> [file]",
+	Short: "Show the stored files of a task, or print one of them",
+	Example: `  # List what was kept for a task
+  parity-runner artifacts get 3f0c1a52-8c1e-4a4e-9a37-5d1f7e0b2c11
+
+  # Print its output and a file from its workspace
+  parity-runner artifacts get 3f0c1a52-8c1e-4a4e-9a37-5d1f7e0b2c11 output.log
+  parity-runner artifacts get 3f0c1a52-8c1e-4a4e-9a37-5d1f7e0b2c11 workspace/results.csv`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var file string
+		if len(args) == 2 {
+			file = args[1]
+		}
+		if err := cli.ExecuteArtifactsGet(args[0], file); err != nil {
+			log.Fatal().Err(err).Msg("Failed to get task artifacts")
+		}
+	},
+}
+
+var artifactsRemoveCmd = &cobra.Command{
+	Use:   "rm <task-id>...",
+	Short: "Remove the stored artifacts of tasks",
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		if len(args) == 0 && !all {
+			log.Fatal().Msg("Pass the task IDs to remove, or --all")
+		}
+		if err := cli.ExecuteArtifactsRemove(args, all); err != nil {
+			log.Fatal().Err(err).Msg("Failed to remove task artifacts")
+		}
+	},
+}
+
+var tasksCmd = &cobra.Command{
+	Use:   "tasks",
+	Short: "Inspect tasks this runner executed and cancel tasks created here",
+}
+
+var tasksHistoryCmd = &cobra.Command{
+	Use:   "history <task-id|result-file>",
+	Short: "Replay the execution log of a task and check its hash chain",
+	Example: `  # Show what the runner did for a task
+  parity-runner tasks history 3f0c1a52-8c1e-4a4e-9a37-5d1f7e0b2c11
+
+  # Check the log attached to a submitted result
+  parity-runner tasks history result.json --json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if err := cli.ExecuteTaskHistory(args[0], asJSON); err != nil {
+			log.Fatal().Err(err).Msg("Failed to show task history")
+		}
+	},
+}
+
+var tasksCancelCmd = &cobra.Command{
+	Use:   "cancel <task-id>",
+	Short: "Cancel a task created from this device",
+	Long: `Cancel a task created from this device. A queued task is withdrawn, a
+running one is stopped gracefully by its runner and reported as cancelled.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteTaskCancel(args[0]); err != nil {
+			log.Fatal().Err(err).Msg("Failed to cancel task")
+		}
+	},
+}
+
+var resultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Inspect and deliver task results the server has not accepted",
+}
+
+var resultsListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List undelivered results and dead letters, oldest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteResultsList(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to list undelivered results")
+		}
+	},
+}
+
+var resultsFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Deliver undelivered results to the server now",
+	Long: `Deliver the results in the outbox to the server now, without waiting for the
+runner to try again. Results the server rejects become dead letters; pass --dead
+to offer the dead letters again as well.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		withDead, _ := cmd.Flags().GetBool("dead")
+		if err := cli.ExecuteResultsFlush(withDead); err != nil {
+			log.Fatal().Err(err).Msg("Failed to flush undelivered results")
+		}
+	},
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect the datasets federated learning tasks keep on this runner",
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List cached datasets, most recently used first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteCacheList(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to list cached datasets")
+		}
+	},
+}
+
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check cached datasets against their hashes and remove corrupt ones",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteCacheVerify(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to verify cached datasets")
+		}
+	},
+}
+
+var cachePurgeCmd = &cobra.Command{
+	Use:   "purge [cid]...",
+	Short: "Remove cached datasets, all of them when no CID is given",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteCachePurge(args); err != nil {
+			log.Fatal().Err(err).Msg("Failed to purge cached datasets")
+		}
+	},
+}
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure what this runner adds to the tasks it runs",
+}
+
+var benchSandboxCmd = &cobra.Command{
+	Use:   "sandbox",
+	Short: "Measure container lifecycle, seccomp and stats collection overhead",
+	Example: `  # Measure with 1, 4 and 16 containers at once and publish the result
+  parity-runner bench sandbox
+
+  # Measure more container counts with another image, without publishing
+  parity-runner bench sandbox --containers 1,8,32 --image busybox:latest --no-save`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := docker.DefaultBenchConfig()
+		config.Image, _ = cmd.Flags().GetString("image")
+		config.ContainerCounts, _ = cmd.Flags().GetIntSlice("containers")
+		config.Iterations, _ = cmd.Flags().GetInt("iterations")
+		noSave, _ := cmd.Flags().GetBool("no-save")
+
+		if err := cli.ExecuteBenchSandbox(config, !noSave); err != nil {
+			log.Fatal().Err(err).Msg("Sandbox benchmark failed")
+		}
+	},
+}
+
+var benchmarkCmd = &cobra.Command{
+	Use:   "benchmark",
+	Short: "Score this machine's CPU, memory, disk and GPUs in a signed report",
+	Example: `  # Benchmark and save the report for the runner to send when it registers
+  parity-runner benchmark
+
+  # Benchmark for longer and submit the report to the server right away
+  parity-runner benchmark --cpu-duration 10s --submit`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := benchmark.DefaultConfig()
+		config.CPUDuration, _ = cmd.Flags().GetDuration("cpu-duration")
+		config.DiskPath, _ = cmd.Flags().GetString("disk-path")
+		diskMB, _ := cmd.Flags().GetInt64("disk-mb")
+		config.DiskBytes = diskMB << 20
+		config.GPUImage, _ = cmd.Flags().GetString("gpu-image")
+
+		var opts cli.BenchmarkOptions
+		opts.NoGPU, _ = cmd.Flags().GetBool("no-gpu")
+		opts.Submit, _ = cmd.Flags().GetBool("submit")
+		noSave, _ := cmd.Flags().GetBool("no-save")
+		opts.Save = !noSave
+
+		if err := cli.ExecuteBenchmark(config, opts); err != nil {
+			log.Fatal().Err(err).Msg("Benchmark failed")
+		}
+	},
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Move this runner's identity, pending results and history to another machine",
+}
+
+var stateExportCmd = &cobra.Command{
+	Use:   "export <bundle>",
+	Short: "Write the wallet key, device ID, config, queued results and task history to a bundle",
+	Example: `  # On the old machine, with the runner stopped
+  parity-runner state export runner.state --passphrase-file ~/passphrase
+
+  # On the new machine
+  parity-runner state import runner.state --passphrase-file ~/passphrase`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteStateExport(args[0], stateOptions(cmd)); err != nil {
+			log.Fatal().Err(err).Msg("Failed to export runner state")
+		}
+	},
+}
+
+var stateImportCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Make this machine the runner a bundle was exported from",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteStateImport(args[0], stateOptions(cmd)); err != nil {
+			log.Fatal().Err(err).Msg("Failed to import runner state")
+		}
+	},
+}
+
+var keystoreCmd = &cobra.Command{
+	Use:   "keystore",
+	Short: "Manage the encrypted wallet keystore",
+}
+
+var keystoreMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Encrypt a wallet key stored in plain text by an older runner",
+	Example: `  parity-runner keystore migrate
+  PARITY_KEYSTORE_PASSPHRASE=... parity-runner keystore migrate --keychain`,
+	Run: func(cmd *cobra.Command, args []string) {
+		keychain, _ := cmd.Flags().GetBool("keychain")
+		if err := cli.ExecuteKeystoreMigrate(keychain); err != nil {
+			log.Fatal().Err(err).Msg("Failed to migrate keystore")
+		}
+	},
+}
+
+var drainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Stop taking tasks, let running ones finish and exit",
+	Long: `Ask the runner of this instance to tell the server it is busy, stop taking
+tasks and exit once the tasks it runs have finished, or once the timeout
+passed. Sending the runner SIGUSR1 does the same with RUNNER_DRAIN_TIMEOUT.`,
+	Example: `  parity-runner drain
+  parity-runner --instance gpu1 drain --timeout 30m`,
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		noWait, _ := cmd.Flags().GetBool("no-wait")
+		if err := cli.ExecuteDrain(timeout, !noWait); err != nil {
+			log.Fatal().Err(err).Msg("Failed to drain runner")
+		}
+	},
+}
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Run the runner as a service that starts at boot",
+	Long: `Install and control the runner as a systemd unit on Linux, a launchd
+service on macOS or a Windows service. As root or administrator a system
+service is installed, otherwise one of the current user where the platform
+has them. The service writes the runner's output to a log that is rotated by
+size.`,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the runner as a service started at boot",
+	Example: `  parity-runner service install
+  parity-runner --instance gpu1 --network testnet service install --log-max-size 50m`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteServiceInstall(serviceOptions(cmd)); err != nil {
+			log.Fatal().Err(err).Msg("Failed to install service")
+		}
+	},
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop and remove the service",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteServiceUninstall(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to uninstall service")
+		}
+	},
+}
+
+var serviceStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the installed service",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteServiceStart(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to start service")
+		}
+	},
+}
+
+var serviceStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the service",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteServiceStop(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to stop service")
+		}
+	},
+}
+
+var serviceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the service is installed and running",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteServiceStatus(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to get service status")
+		}
+	},
+}
+
+var serviceRunCmd = &cobra.Command{
+	Use:    "run -- [runner flags]",
+	Short:  "Run the runner with its output going to a rotated log, as the installed service does",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cli.ExecuteServiceRun(serviceOptions(cmd), args); err != nil {
+			log.Fatal().Err(err).Msg("Service failed")
+		}
+	},
+}
+
+func serviceOptions(cmd *cobra.Command) cli.ServiceOptions {
+	opts := cli.ServiceOptions{LogMode: "prod"}
+	// The runner logs as asked with --log, structured by default as the log
+	// goes to a file
+	if flag := cmd.Flags().Lookup("log"); flag != nil && flag.Changed {
+		opts.LogMode = logMode
+	}
+	opts.LogFile, _ = cmd.Flags().GetString("log-file")
+	opts.LogMaxSize, _ = cmd.Flags().GetString("log-max-size")
+	opts.LogFiles, _ = cmd.Flags().GetInt("log-files")
+	return opts
+}
+
+func stateOptions(cmd *cobra.Command) cli.StateOptions {
+	var opts cli.StateOptions
+	opts.PassphraseFile, _ = cmd.Flags().GetString("passphrase-file")
+	if cmd.Flags().Lookup("no-artifacts") != nil {
+		opts.NoArtifacts, _ = cmd.Flags().GetBool("no-artifacts")
+	}
+	if cmd.Flags().Lookup("force") != nil {
+		opts.Force, _ = cmd.Flags().GetBool("force")
+	}
+	return opts
+}
+
+// applyCheckpointFlags lets the runner flags override the checkpoint settings of the config file
+func applyCheckpointFlags(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	if !flags.Changed("checkpoint-mode") && !flags.Changed("checkpoint-upload") {
+		return nil
+	}
+
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return err
+	}
+	if flags.Changed("checkpoint-mode") {
+		cfg.Runner.Checkpoint.Mode, _ = flags.GetString("checkpoint-mode")
+	}
+	if flags.Changed("checkpoint-upload") {
+		cfg.Runner.Checkpoint.Upload, _ = flags.GetBool("checkpoint-upload")
+	}
+	return nil
+}
+
+// applyBiddingFlags lets the runner flags override the bidding settings of the config file
+func applyBiddingFlags(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	prices := map[string]float64{}
+	for _, name := range []string{"bid-base", "price-per-cpu-hour", "price-per-gb-hour", "price-per-gpu-hour", "bid-margin"} {
+		if flags.Changed(name) {
+			prices[name], _ = flags.GetFloat64(name)
+		}
+	}
+	if !flags.Changed("bid") && len(prices) == 0 {
+		return nil
+	}
+
+	cfg, err := utils.GetConfig()
+	if err != nil {
+		return err
+	}
+	bidding := &cfg.Runner.Bidding
+	if flags.Changed("bid") {
+		bidding.Enabled, _ = flags.GetBool("bid")
+	}
+	if price, ok := prices["bid-base"]; ok {
+		bidding.Base = price
+	}
+	if price, ok := prices["price-per-cpu-hour"]; ok {
+		bidding.PerCPUHour = price
+	}
+	if price, ok := prices["price-per-gb-hour"]; ok {
+		bidding.PerGBHour = price
+	}
+	if price, ok := prices["price-per-gpu-hour"]; ok {
+		bidding.PerGPUHour = price
+	}
+	if margin, ok := prices["bid-margin"]; ok {
+		bidding.Margin = margin
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVar(&logMode, "log", "pretty", "Log mode: debug, pretty, info, prod, test")
 	rootCmd.PersistentFlags().StringVar(&configPath, "config-path", "", "Path to configuration file")
+	rootCmd.PersistentFlags().StringVar(&network, "network", "", "Network to join: mainnet or testnet (env: PARITY_NETWORK)")
+	rootCmd.PersistentFlags().StringVar(&instance, "instance", "", "Name of this runner instance when running several on one host (env: PARITY_INSTANCE)")
 
-	authCmd.Flags().String("private-key", "", "Private key in hex format")
-	if err := authCmd.MarkFlagRequired("private-key"); err != nil {
-		log.Error().Err(err).Msg("Failed to mark private-key flag as required")
+	// Wallet commands act on the chain selected with --chain
+	for _, cmd := range []*cobra.Command{authCmd, stakeCmd, unstakeCmd, balanceCmd, withdrawCmd} {
+		cmd.Flags().String("chain", "", "Chain of the chain registry to use, such as base or polygon (default: BLOCKCHAIN_CHAIN)")
 	}
 
+	authCmd.Flags().String("private-key", "", "Private key in hex format")
+	authCmd.Flags().String("signer", "", "Sign with a ledger, trezor or clef remote signer instead of a private key")
+	authCmd.Flags().String("signer-url", "", "Endpoint of the remote signer, an IPC path or http(s) URL (--signer clef)")
+	authCmd.Flags().String("derivation-path", "", "Derivation path of the hardware wallet account (default: m/44'/60'/0'/0/0)")
+	authCmd.Flags().String("server-identity", "", "Server identity address to pin, obtained out of band")
+	authCmd.Flags().Bool("keychain", false, "Store the keystore passphrase in the OS keychain so the runner starts without asking for it")
+
 	stakeCmd.Flags().Float64("amount", 1.0, "Amount of tokens to stake")
+	withdrawCmd.Flags().Float64("amount", 0, "Amount of tokens to withdraw (default: everything accrued)")
+	withdrawCmd.Flags().Float64("min-amount", 0, "Skip the withdrawal while less than this has accrued")
+	withdrawCmd.Flags().Bool("dry-run", false, "Show the accrued rewards without withdrawing them")
+	withdrawCmd.Flags().Duration("confirm-timeout", 0, "How long to wait for each transaction to be mined before replacing it (default: BLOCKCHAIN_GAS_CONFIRM_TIMEOUT or 2m)")
+	withdrawCmd.Flags().Int("retries", 0, "How many times to replace a transaction that is not mined with higher fees (default: BLOCKCHAIN_GAS_RETRIES or 3)")
+	stakeCmd.Flags().Bool("top-up", false, "Add to the device's existing stake")
 	if err := stakeCmd.MarkFlagRequired("amount"); err != nil {
 		log.Error().Err(err).Msg("Failed to mark amount flag as required")
 	}
+	unstakeCmd.Flags().Float64("amount", 0, "Amount of tokens to unstake")
+	unstakeCmd.Flags().Bool("force", false, "Unstake although results are undelivered or rewards unpaid")
+	if err := unstakeCmd.MarkFlagRequired("amount"); err != nil {
+		log.Error().Err(err).Msg("Failed to mark amount flag as required")
+	}
 
 	// LLM-related flags for runner command
 	runnerCmd.Flags().StringSlice("models", []string{"llama2"}, "Comma-separated list of models to load")
 	runnerCmd.Flags().String("ollama-url", "http://localhost:11434", "Ollama server URL")
 	runnerCmd.Flags().Bool("auto-install", true, "Automatically install Ollama if not found")
+	runnerCmd.Flags().String("checkpoint-mode", "", "How checkpointed Docker tasks are saved: snapshot or criu (env: RUNNER_CHECKPOINT_MODE)")
+	runnerCmd.Flags().Bool("checkpoint-upload", false, "Add CRIU checkpoints to IPFS and report their CIDs in task results (env: RUNNER_CHECKPOINT_UPLOAD)")
+	runnerCmd.Flags().Bool("bid", false, "Bid for tasks on servers that auction them (env: RUNNER_BIDDING_ENABLED)")
+	runnerCmd.Flags().Float64("bid-base", 0, "Flat price bid for every task (env: RUNNER_BIDDING_BASE)")
+	runnerCmd.Flags().Float64("price-per-cpu-hour", 0, "Price bid per reserved CPU hour (env: RUNNER_BIDDING_PER_CPU_HOUR)")
+	runnerCmd.Flags().Float64("price-per-gb-hour", 0, "Price bid per reserved GB of memory per hour (env: RUNNER_BIDDING_PER_GB_HOUR)")
+	runnerCmd.Flags().Float64("price-per-gpu-hour", 0, "Price bid per reserved GPU hour (env: RUNNER_BIDDING_PER_GPU_HOUR)")
+	runnerCmd.Flags().Float64("bid-margin", 0, "Margin added to the cost of a task as a share of it, e.g. 0.2 (env: RUNNER_BIDDING_MARGIN)")
+
+	faucetCmd.Flags().Float64("stake", 0, "Amount of received test tokens to stake")
+
+	earningsReportCmd.Flags().String("wallet", "", "Wallet whose runners to report on (default: this runner's wallet)")
+	earningsReportCmd.Flags().String("month", "", "Month to report as YYYY-MM, in UTC (default: the current month)")
+	earningsReportCmd.Flags().String("format", "csv", "Report format: csv or json")
+	earningsReportCmd.Flags().String("output", "", "File to write the report to (default: stdout)")
+	earningsCmd.AddCommand(earningsReportCmd)
+	for _, cmd := range []*cobra.Command{earningsLedgerCmd, earningsExportCmd} {
+		cmd.Flags().String("since", "", "First day to include as YYYY-MM-DD, in UTC")
+		cmd.Flags().String("until", "", "Day to stop before as YYYY-MM-DD, in UTC")
+		cmd.Flags().Bool("sync", false, "Look up payouts of unpaid tasks from every coordinator first")
+		earningsCmd.AddCommand(cmd)
+	}
+	earningsLedgerCmd.Flags().String("period", "day", "Period to add tasks up over: day or week")
+	earningsExportCmd.Flags().String("output", "", "File to write the CSV to (default: stdout)")
+
+	telemetryCmd.AddCommand(telemetryPreviewCmd)
+
+	versionCmd.Flags().Bool("json", false, "Print the provenance as JSON")
+	versionSignCmd.Flags().String("version", "", "Release version, such as v1.4.0")
+	versionSignCmd.Flags().String("commit", "", "Git commit the release is built from")
+	versionSignCmd.Flags().String("builder", "", "Identity of the build system")
+	versionSignCmd.Flags().String("attestation", "", "Reference to the SLSA provenance attestation of the release")
+	versionSignCmd.Flags().String("key-file", "", "File holding the hex release key")
+	for _, name := range []string{"version", "commit", "key-file"} {
+		if err := versionSignCmd.MarkFlagRequired(name); err != nil {
+			log.Error().Err(err).Str("flag", name).Msg("Failed to mark flag as required")
+		}
+	}
+	versionCmd.AddCommand(versionSignCmd)
+
+	receiptVerifyCmd.Flags().String("runner-address", "", "Address the receipt must be signed by")
+	receiptVerifyCmd.Flags().String("server-address", "", "Address the receipt must be countersigned by")
+	receiptCmd.AddCommand(receiptVerifyCmd)
+	receiptCmd.AddCommand(receiptShowCmd)
+
+	artifactsRemoveCmd.Flags().Bool("all", false, "Remove the artifacts of every task")
+	artifactsCmd.AddCommand(artifactsListCmd)
+	artifactsCmd.AddCommand(artifactsGetCmd)
+	artifactsCmd.AddCommand(artifactsRemoveCmd)
+
+	tasksHistoryCmd.Flags().Bool("json", false, "Print the events as JSON")
+	tasksCmd.AddCommand(tasksHistoryCmd)
+	tasksCmd.AddCommand(tasksCancelCmd)
+
+	resultsFlushCmd.Flags().Bool("dead", false, "Offer the dead letters again too")
+	resultsCmd.AddCommand(resultsListCmd)
+	resultsCmd.AddCommand(resultsFlushCmd)
+
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cacheVerifyCmd)
+	cacheCmd.AddCommand(cachePurgeCmd)
+
+	defaults := docker.DefaultBenchConfig()
+	benchSandboxCmd.Flags().String("image", defaults.Image, "Image the benchmark containers run, it must have true, sleep and sh")
+	benchSandboxCmd.Flags().IntSlice("containers", defaults.ContainerCounts, "Numbers of containers to run at once, one run each")
+	benchSandboxCmd.Flags().Int("iterations", defaults.Iterations, "Lifecycles measured per container in each run")
+	benchSandboxCmd.Flags().Bool("no-save", false, "Print the result without publishing it in the runner registration")
+	benchCmd.AddCommand(benchSandboxCmd)
+
+	benchmarkDefaults := benchmark.DefaultConfig()
+	benchmarkCmd.Flags().Duration("cpu-duration", benchmarkDefaults.CPUDuration, "How long the single and multi core CPU runs each last")
+	benchmarkCmd.Flags().String("disk-path", benchmarkDefaults.DiskPath, "Directory the disk benchmark writes to, on the filesystem tasks use")
+	benchmarkCmd.Flags().Int64("disk-mb", benchmarkDefaults.DiskBytes>>20, "Size of the file the disk benchmark writes, in MiB")
+	benchmarkCmd.Flags().String("gpu-image", benchmarkDefaults.GPUImage, "Image with the CUDA n-body sample the GPU benchmark runs")
+	benchmarkCmd.Flags().Bool("no-gpu", false, "Skip the GPU benchmark")
+	benchmarkCmd.Flags().Bool("no-save", false, "Do not keep the report for the runner to send when it registers")
+	benchmarkCmd.Flags().Bool("submit", false, "Submit the report to the server")
+
+	stateCmd.PersistentFlags().String("passphrase-file", "", "File holding the passphrase that encrypts the wallet key (env: "+cli.EnvStatePassphrase+")")
+	stateExportCmd.Flags().Bool("no-artifacts", false, "Leave stored task artifacts out of the bundle")
+	stateImportCmd.Flags().Bool("force", false, "Replace another wallet's key and a different config file")
+	stateCmd.AddCommand(stateExportCmd)
+	stateCmd.AddCommand(stateImportCmd)
+
+	keystoreMigrateCmd.Flags().Bool("keychain", false, "Also store the passphrase in the OS keychain")
+	keystoreCmd.AddCommand(keystoreMigrateCmd)
+
+	drainCmd.Flags().Duration("timeout", 0, "How long running tasks may take to finish (default: RUNNER_DRAIN_TIMEOUT)")
+	drainCmd.Flags().Bool("no-wait", false, "Return once the drain is requested instead of when the runner exits")
+
+	for _, cmd := range []*cobra.Command{serviceInstallCmd, serviceRunCmd} {
+		cmd.Flags().String("log-file", "", "Log file of the service (default: logs/runner.log in the runner's data directory)")
+		cmd.Flags().String("log-max-size", "10m", "Size at which the log file is rotated")
+		cmd.Flags().Int("log-files", 5, "Rotated log files to keep")
+	}
+	serviceCmd.AddCommand(serviceInstallCmd)
+	serviceCmd.AddCommand(serviceUninstallCmd)
+	serviceCmd.AddCommand(serviceStartCmd)
+	serviceCmd.AddCommand(serviceStopCmd)
+	serviceCmd.AddCommand(serviceStatusCmd)
+	serviceCmd.AddCommand(serviceRunCmd)
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestAuthAcceptsSignerWithoutPrivateKey(t *testing.T) {
+	if !authCmd.HasParent() {
+		rootCmd.AddCommand(authCmd)
+	}
+	run := authCmd.Run
+	defer func() { authCmd.Run = run }()
+
+	got := map[string]string{}
+	authCmd.Run = func(cmd *cobra.Command, args []string) {
+		for _, name := range []string{"signer", "signer-url", "derivation-path", "private-key"} {
+			got[name], _ = cmd.Flags().GetString(name)
+		}
+	}
+
+	rootCmd.SetArgs([]string{"auth", "--log", "test", "--signer", "clef", "--signer-url", "http://127.0.0.1:8550"})
+	defer rootCmd.SetArgs(nil)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("auth --signer without --private-key = %v", err)
+	}
+	if got["signer"] != "clef" || got["signer-url"] != "http://127.0.0.1:8550" || got["private-key"] != "" {
+		t.Fatalf("auth flags = %v, want the clef signer and no private key", got)
+	}
+
+	rootCmd.SetArgs([]string{"auth", "--log", "test", "--signer", "ledger", "--derivation-path", "m/44'/60'/0'/0/1"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("auth --signer ledger = %v", err)
+	}
+	if got["derivation-path"] != "m/44'/60'/0'/0/1" {
+		t.Fatalf("derivation path = %q", got["derivation-path"])
+	}
+}
+
+func TestUnstakeTakesNoArguments(t *testing.T) {
+	if err := unstakeCmd.Args(unstakeCmd, []string{"5"}); err == nil {
+		t.Fatal("unstake accepted the amount as an argument, want --amount")
+	}
+	if err := unstakeCmd.Args(unstakeCmd, nil); err != nil {
+		t.Fatalf("unstake without arguments = %v", err)
+	}
+	if flag := unstakeCmd.Flags().Lookup("amount"); flag == nil || flag.DefValue != "0" {
+		t.Fatalf("unstake --amount flag = %+v, want one defaulting to 0", flag)
+	}
+}
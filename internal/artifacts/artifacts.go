@@ -0,0 +1,299 @@
+// Package artifacts keeps what recent tasks left behind on the runner: the task,
+// its result and output, and for Docker tasks the container's working directory.
+// Entries older than the retention period are pruned.
+package artifacts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	artifactsDirName = "artifacts"
+
+	metadataFile = "artifact.json"
+	taskFile     = "task.json"
+	resultFile   = "result.json"
+	outputFile   = "output.log"
+	// WorkspaceDir is the entry subdirectory holding the task's working directory
+	WorkspaceDir = "workspace"
+)
+
+// ErrNotFound is returned for tasks without stored artifacts
+var ErrNotFound = errors.New("no artifacts stored for task")
+
+// Entry describes the artifacts stored for one task
+type Entry struct {
+	TaskID   string            `json:"task_id"`
+	Type     models.TaskType   `json:"type"`
+	Status   models.TaskStatus `json:"status"`
+	ExitCode int               `json:"exit_code"`
+	Error    string            `json:"error,omitempty"`
+	SavedAt  time.Time         `json:"saved_at"`
+	// Size and Path are filled in when listing
+	Size int64  `json:"-"`
+	Path string `json:"-"`
+}
+
+type Store struct {
+	dir       string
+	retention time.Duration
+}
+
+func DefaultDir() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, artifactsDirName), nil
+}
+
+// NewStore keeps artifacts under dir for retention. A zero retention keeps them
+// until they are removed.
+func NewStore(dir string, retention time.Duration) *Store {
+	return &Store{dir: dir, retention: retention}
+}
+
+func (s *Store) entryDir(taskID string) (string, error) {
+	if _, err := uuid.Parse(taskID); err != nil {
+		return "", fmt.Errorf("invalid task ID %q", taskID)
+	}
+	return filepath.Join(s.dir, taskID), nil
+}
+
+// WorkspacePath is where the working directory of a task is copied to
+func (s *Store) WorkspacePath(taskID string) (string, error) {
+	dir, err := s.entryDir(taskID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, WorkspaceDir), nil
+}
+
+// Save records a finished task. A workspace copied earlier is kept.
+func (s *Store) Save(task *models.Task, status models.TaskStatus, result *models.TaskResult) (string, error) {
+	dir, err := s.entryDir(task.ID.String())
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create artifacts directory: %w", err)
+	}
+
+	entry := Entry{
+		TaskID:  task.ID.String(),
+		Type:    task.Type,
+		Status:  status,
+		SavedAt: time.Now().UTC(),
+	}
+	files := map[string]interface{}{taskFile: task}
+	if result != nil {
+		entry.ExitCode = result.ExitCode
+		entry.Error = result.Error
+		files[resultFile] = result
+		if err := os.WriteFile(filepath.Join(dir, outputFile), []byte(result.Output), 0o600); err != nil {
+			return "", fmt.Errorf("failed to write task output: %w", err)
+		}
+	}
+	files[metadataFile] = entry
+
+	for name, value := range files {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return dir, nil
+}
+
+// Get returns the stored entry of a task
+func (s *Store) Get(taskID string) (*Entry, error) {
+	dir, err := s.entryDir(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, metadataFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifacts of task %s: %w", taskID, err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse artifacts of task %s: %w", taskID, err)
+	}
+	entry.Path = dir
+	entry.Size, _ = dirSize(dir)
+	return &entry, nil
+}
+
+// List returns the stored entries, newest first
+func (s *Store) List() ([]Entry, error) {
+	dirs, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifacts directory: %w", err)
+	}
+
+	var entries []Entry
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entry, err := s.Get(d.Name())
+		if err != nil {
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].SavedAt.After(entries[j].SavedAt)
+	})
+	return entries, nil
+}
+
+// Files lists the stored files of a task relative to its entry directory
+func (s *Store) Files(taskID string) ([]string, error) {
+	entry, err := s.Get(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = filepath.WalkDir(entry.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(entry.Path, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts of task %s: %w", taskID, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Open opens one stored file of a task, named as Files reports it
+func (s *Store) Open(taskID, name string) (*os.File, error) {
+	entry, err := s.Get(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("invalid artifact name %q", name)
+	}
+
+	f, err := os.Open(filepath.Join(entry.Path, clean))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open artifact %s of task %s: %w", name, taskID, err)
+	}
+	return f, nil
+}
+
+// Remove deletes the artifacts of a task
+func (s *Store) Remove(taskID string) error {
+	dir, err := s.entryDir(taskID)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove artifacts of task %s: %w", taskID, err)
+	}
+	return nil
+}
+
+// Prune removes entries saved more than the retention period before now and
+// returns how many were removed. Workspaces whose task was never saved, e.g.
+// because the runner stopped mid-task, age from their last modification.
+func (s *Store) Prune(now time.Time) (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+
+	dirs, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read artifacts directory: %w", err)
+	}
+
+	removed := 0
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+
+		var savedAt time.Time
+		entry, err := s.Get(d.Name())
+		switch {
+		case err == nil:
+			savedAt = entry.SavedAt
+		case errors.Is(err, ErrNotFound):
+			if info, err := d.Info(); err == nil {
+				savedAt = info.ModTime()
+			}
+		}
+		if savedAt.IsZero() || now.Sub(savedAt) <= s.retention {
+			continue
+		}
+
+		if err := s.Remove(d.Name()); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
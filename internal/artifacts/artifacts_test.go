@@ -0,0 +1,118 @@
+package artifacts
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestStoreSavesAndListsTasks(t *testing.T) {
+	store := NewStore(t.TempDir(), time.Hour)
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeDocker
+	workspace, err := store.WorkspacePath(task.ID.String())
+	if err != nil {
+		t.Fatalf("WorkspacePath() error = %v", err)
+	}
+	if err := os.MkdirAll(workspace, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "model.bin"), []byte("weights"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &models.TaskResult{TaskID: task.ID, Output: "trained", ExitCode: 3, Error: "exit status 3"}
+	if _, err := store.Save(task, models.TaskStatusFailed, result); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].TaskID != task.ID.String() || entries[0].ExitCode != 3 || entries[0].Status != models.TaskStatusFailed {
+		t.Fatalf("List() = %+v", entries)
+	}
+
+	files, err := store.Files(task.ID.String())
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	want := []string{"artifact.json", "output.log", "result.json", "task.json", "workspace/model.bin"}
+	if len(files) != len(want) {
+		t.Fatalf("Files() = %v, want %v", files, want)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Fatalf("Files() = %v, want %v", files, want)
+		}
+	}
+
+	f, err := store.Open(task.ID.String(), "output.log")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	output, _ := io.ReadAll(f)
+	f.Close()
+	if string(output) != "trained" {
+		t.Fatalf("output.log = %q, want trained", output)
+	}
+
+	if _, err := store.Open(task.ID.String(), "../../etc/passwd"); err == nil {
+		t.Fatal("expected an error for a name outside the task directory")
+	}
+	if _, err := store.Get("../escape"); err == nil {
+		t.Fatal("expected an error for an invalid task ID")
+	}
+}
+
+func TestStorePrunesExpiredTasks(t *testing.T) {
+	store := NewStore(t.TempDir(), time.Hour)
+
+	old := models.NewTask()
+	recent := models.NewTask()
+	for _, task := range []*models.Task{old, recent} {
+		if _, err := store.Save(task, models.TaskStatusCompleted, &models.TaskResult{TaskID: task.ID}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	// A workspace left behind by a task that was never saved
+	orphan := models.NewTask()
+	workspace, _ := store.WorkspacePath(orphan.ID.String())
+	if err := os.MkdirAll(workspace, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Dir(workspace), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, _ := store.Get(old.ID.String())
+	if _, err := store.Prune(entry.SavedAt.Add(30 * time.Minute)); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if entries, _ := store.List(); len(entries) != 2 {
+		t.Fatalf("Prune() before expiry left %d entries, want 2", len(entries))
+	}
+	if _, err := os.Stat(workspace); !os.IsNotExist(err) {
+		t.Fatal("expected the stale workspace to be removed")
+	}
+
+	removed, err := store.Prune(entry.SavedAt.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("Prune() removed %d entries, want 2", removed)
+	}
+	if _, err := store.Get(old.ID.String()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() after prune error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Package attestation lets runners deployed in a trusted execution environment
+// prove where a result was computed. In an AMD SEV-SNP or Intel TDX confidential
+// VM quotes come from the kernel's configfs-tsm interface; in an Intel SGX
+// enclave run by Gramine they come from its /dev/attestation files. Either way
+// the hardware signs a quote whose report data binds it to the task and result.
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	// tsmReportDir is where configfs-tsm creates reports, Linux 6.7 and later
+	tsmReportDir = "sys/kernel/config/tsm/report"
+	sevGuestDev  = "dev/sev-guest"
+	tdxGuestDev  = "dev/tdx_guest"
+	// gramineDir holds the attestation pseudo-files of a Gramine SGX enclave
+	gramineDir = "dev/attestation"
+)
+
+// ErrUnavailable is returned by Detect when the runner is not in a TEE
+var ErrUnavailable = errors.New("runner is not running in a trusted execution environment")
+
+// Attester produces quotes for the TEE the runner runs in. Quotes are made one
+// at a time, since Gramine has a single report data file per enclave.
+type Attester struct {
+	technology models.TEETechnology
+	root       string
+	mu         sync.Mutex
+}
+
+// Detect finds the TEE the runner runs in
+func Detect() (*Attester, error) {
+	return detect("/")
+}
+
+func detect(root string) (*Attester, error) {
+	exists := func(path string) bool {
+		_, err := os.Stat(filepath.Join(root, path))
+		return err == nil
+	}
+
+	if exists(filepath.Join(gramineDir, "quote")) {
+		return &Attester{technology: models.TEESGX, root: root}, nil
+	}
+
+	var technology models.TEETechnology
+	switch {
+	case exists(sevGuestDev):
+		technology = models.TEESEVSNP
+	case exists(tdxGuestDev):
+		technology = models.TEETDX
+	default:
+		return nil, ErrUnavailable
+	}
+	if !exists(tsmReportDir) {
+		return nil, fmt.Errorf("%s guest without configfs-tsm at /%s, which needs Linux 6.7 and configfs mounted", technology, tsmReportDir)
+	}
+	return &Attester{technology: technology, root: root}, nil
+}
+
+func (a *Attester) Technology() models.TEETechnology {
+	return a.technology
+}
+
+// Attest quotes the result of a task that ran in the TEE. The result needs its
+// hash, which is what the quote binds.
+func (a *Attester) Attest(task *models.Task, result *models.TaskResult) (*models.AttestationQuote, error) {
+	reportData := models.AttestationReportData(task.ID, task.Nonce, result.ResultHash)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	quote := &models.AttestationQuote{Version: models.AttestationVersion, Technology: a.technology}
+	var err error
+	if a.technology == models.TEESGX {
+		quote.Quote, err = a.gramineQuote(reportData)
+	} else {
+		quote.Quote, quote.Provider, err = a.tsmQuote(reportData)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s quote: %w", a.technology, err)
+	}
+	return quote, nil
+}
+
+// tsmQuote creates a configfs-tsm report, which the kernel generates when its
+// outblob is read, and removes it again
+func (a *Attester) tsmQuote(reportData [64]byte) ([]byte, string, error) {
+	dir, err := os.MkdirTemp(filepath.Join(a.root, tsmReportDir), "parity-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.Remove(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "inblob"), reportData[:], 0o600); err != nil {
+		return nil, "", err
+	}
+	quote, err := os.ReadFile(filepath.Join(dir, "outblob"))
+	if err != nil {
+		return nil, "", err
+	}
+	provider, err := os.ReadFile(filepath.Join(dir, "provider"))
+	if err != nil {
+		return nil, "", err
+	}
+	return quote, strings.TrimSpace(string(provider)), nil
+}
+
+func (a *Attester) gramineQuote(reportData [64]byte) ([]byte, error) {
+	dir := filepath.Join(a.root, gramineDir)
+	if err := os.WriteFile(filepath.Join(dir, "user_report_data"), reportData[:], 0o600); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(dir, "quote"))
+}
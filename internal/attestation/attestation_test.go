@@ -0,0 +1,77 @@
+package attestation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func touch(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	if _, err := detect(t.TempDir()); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("detect() on a plain host = %v, want ErrUnavailable", err)
+	}
+
+	root := t.TempDir()
+	touch(t, filepath.Join(root, tdxGuestDev), nil)
+	if _, err := detect(root); err == nil {
+		t.Fatal("a TDX guest without configfs-tsm should be refused")
+	}
+	if err := os.MkdirAll(filepath.Join(root, tsmReportDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	attester, err := detect(root)
+	if err != nil || attester.Technology() != models.TEETDX {
+		t.Fatalf("detect() = %v, %v", attester, err)
+	}
+
+	root = t.TempDir()
+	touch(t, filepath.Join(root, gramineDir, "quote"), nil)
+	if attester, err := detect(root); err != nil || attester.Technology() != models.TEESGX {
+		t.Fatalf("detect() in gramine = %v, %v", attester, err)
+	}
+}
+
+// TestAttestGramineBindsResult fakes the enclave by writing the report data into
+// an SGX quote the way the quoting enclave would
+func TestAttestGramineBindsResult(t *testing.T) {
+	root := t.TempDir()
+	touch(t, filepath.Join(root, gramineDir, "quote"), nil)
+	attester := &Attester{technology: models.TEESGX, root: root}
+
+	task := &models.Task{ID: uuid.New(), Nonce: "nonce"}
+	result := &models.TaskResult{ResultHash: "abc"}
+	reportData := models.AttestationReportData(task.ID, task.Nonce, result.ResultHash)
+	quote := make([]byte, 48+384)
+	copy(quote[48+320:], reportData[:])
+	touch(t, filepath.Join(root, gramineDir, "quote"), quote)
+
+	attestation, err := attester.Attest(task, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(filepath.Join(root, gramineDir, "user_report_data"))
+	if err != nil || string(written) != string(reportData[:]) {
+		t.Fatalf("user_report_data = %x, %v", written, err)
+	}
+	if err := attestation.VerifyBinding(task.ID, task.Nonce, result.ResultHash); err != nil {
+		t.Fatal(err)
+	}
+	if err := attestation.VerifyBinding(task.ID, task.Nonce, "other"); err == nil {
+		t.Fatal("quote should not verify for another result")
+	}
+}
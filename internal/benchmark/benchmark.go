@@ -0,0 +1,244 @@
+// Package benchmark runs the micro-benchmarks of `parity-runner benchmark`,
+// which score a runner's CPU, memory, disk and GPUs against a reference machine
+// so the server can match tasks to runners and weigh their rewards.
+package benchmark
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	megabyte = 1 << 20
+	gigabyte = 1 << 30
+
+	hashBlockBytes = megabyte
+	diskBlockBytes = 4 * megabyte
+)
+
+// The reference machine, which scores 1000 on every part: an eight core server
+// without SHA extensions, dual channel DDR4, a SATA SSD and a datacenter GPU of
+// the T4 class
+const (
+	referenceSingleCoreMBps = 500
+	referenceMultiCoreMBps  = 4000
+	referenceMemoryGBps     = 10
+	referenceDiskWriteMBps  = 400
+	referenceDiskReadMBps   = 500
+	referenceGPUGFLOPS      = 4000
+)
+
+// Config sets how long and on how much data each benchmark runs
+type Config struct {
+	// CPUDuration is how long the single and the multi core runs each last
+	CPUDuration time.Duration
+	// MemoryDuration is how long buffers of MemoryBytes are copied for
+	MemoryDuration time.Duration
+	MemoryBytes    int64
+	// DiskPath is the directory the disk benchmark writes to, on the
+	// filesystem tasks use. The file is read back right after it is written, so
+	// reads come from the page cache unless DiskBytes exceeds free memory.
+	DiskPath  string
+	DiskBytes int64
+	// GPUs are benchmarked with the CUDA n-body sample in GPUImage, run in
+	// containers of Runtime. The GPU benchmark is skipped without GPUs.
+	GPUs      []models.GPUInfo
+	Runtime   string
+	GPUImage  string
+	GPUBodies int
+}
+
+// DefaultConfig runs for about ten seconds without a GPU
+func DefaultConfig() Config {
+	return Config{
+		CPUDuration:    3 * time.Second,
+		MemoryDuration: 2 * time.Second,
+		MemoryBytes:    256 * megabyte,
+		DiskPath:       os.TempDir(),
+		DiskBytes:      512 * megabyte,
+		Runtime:        "docker",
+		GPUImage:       "nvcr.io/nvidia/k8s/cuda-sample:nbody",
+		GPUBodies:      256000,
+	}
+}
+
+// Run benchmarks the machine and scores it. The report is neither signed nor
+// given a device ID.
+func Run(ctx context.Context, config Config) (*models.BenchmarkReport, error) {
+	if config.CPUDuration <= 0 || config.MemoryDuration <= 0 || config.MemoryBytes <= 0 || config.DiskBytes <= 0 {
+		return nil, errors.New("benchmark durations and sizes must be positive")
+	}
+
+	report := &models.BenchmarkReport{
+		Version:    models.BenchmarkVersion,
+		MeasuredAt: time.Now().UTC(),
+	}
+
+	cores := runtime.NumCPU()
+	report.CPU = models.CPUBenchmark{
+		Cores:          cores,
+		SingleCoreMBps: hashThroughput(ctx, 1, config.CPUDuration),
+		MultiCoreMBps:  hashThroughput(ctx, cores, config.CPUDuration),
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	report.Memory = models.MemoryBenchmark{
+		BufferBytes: config.MemoryBytes,
+		CopyGBps:    copyThroughput(ctx, config.MemoryBytes, config.MemoryDuration),
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	disk, err := diskThroughput(ctx, config.DiskPath, config.DiskBytes)
+	if err != nil {
+		return nil, err
+	}
+	report.Disk = *disk
+
+	if len(config.GPUs) > 0 {
+		gpu, err := gpuThroughput(ctx, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to benchmark GPUs: %w", err)
+		}
+		report.GPU = gpu
+	}
+
+	report.Scores = Score(report)
+	return report, nil
+}
+
+// Score rates the measurements of a report against the reference machine. CPU
+// and disk scores are the geometric means of their two measurements, so neither
+// can make up for the other.
+func Score(report *models.BenchmarkReport) models.BenchmarkScores {
+	relative := func(measured, reference float64) float64 {
+		return 1000 * measured / reference
+	}
+	round := func(score float64) float64 {
+		return math.Round(score*10) / 10
+	}
+
+	cpu := math.Sqrt(relative(report.CPU.SingleCoreMBps, referenceSingleCoreMBps) *
+		relative(report.CPU.MultiCoreMBps, referenceMultiCoreMBps))
+	memory := relative(report.Memory.CopyGBps, referenceMemoryGBps)
+	disk := math.Sqrt(relative(report.Disk.WriteMBps, referenceDiskWriteMBps) *
+		relative(report.Disk.ReadMBps, referenceDiskReadMBps))
+
+	scores := models.BenchmarkScores{
+		CPU:     round(cpu),
+		Memory:  round(memory),
+		Disk:    round(disk),
+		Overall: round(0.5*cpu + 0.25*memory + 0.25*disk),
+	}
+	if report.GPU != nil {
+		scores.GPU = round(relative(report.GPU.GFLOPS, referenceGPUGFLOPS))
+	}
+	return scores
+}
+
+// hashThroughput hashes megabyte blocks with SHA-256 on the given number of
+// goroutines for the duration and returns the combined rate in MB/s
+func hashThroughput(ctx context.Context, workers int, duration time.Duration) float64 {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total int64
+	)
+	began := time.Now()
+	deadline := began.Add(duration)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			block := make([]byte, hashBlockBytes)
+			var hashed int64
+			for time.Now().Before(deadline) && ctx.Err() == nil {
+				sum := sha256.Sum256(block)
+				block[0] = sum[0]
+				hashed += hashBlockBytes
+			}
+			mu.Lock()
+			total += hashed
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return float64(total) / megabyte / time.Since(began).Seconds()
+}
+
+// copyThroughput copies a buffer of size bytes into another for the duration
+// and returns the rate in GB/s
+func copyThroughput(ctx context.Context, size int64, duration time.Duration) float64 {
+	src := make([]byte, size)
+	dst := make([]byte, size)
+	for i := range src {
+		src[i] = byte(i)
+	}
+
+	var copied int64
+	began := time.Now()
+	for time.Since(began) < duration && ctx.Err() == nil {
+		copied += int64(copy(dst, src))
+	}
+	return float64(copied) / gigabyte / time.Since(began).Seconds()
+}
+
+// diskThroughput writes a file of size bytes in dir, syncs it and reads it back
+func diskThroughput(ctx context.Context, dir string, size int64) (*models.DiskBenchmark, error) {
+	file, err := os.CreateTemp(dir, "parity-benchmark-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create disk benchmark file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	block := make([]byte, diskBlockBytes)
+	for i := range block {
+		block[i] = byte(i * 31)
+	}
+
+	began := time.Now()
+	for written := int64(0); written < size; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := file.Write(block[:min(int64(len(block)), size-written)])
+		if err != nil {
+			return nil, fmt.Errorf("failed to write disk benchmark file: %w", err)
+		}
+		written += int64(n)
+	}
+	if err := file.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync disk benchmark file: %w", err)
+	}
+	writeSeconds := time.Since(began).Seconds()
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind disk benchmark file: %w", err)
+	}
+	began = time.Now()
+	read, err := io.CopyBuffer(io.Discard, file, block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disk benchmark file: %w", err)
+	}
+	readSeconds := time.Since(began).Seconds()
+
+	return &models.DiskBenchmark{
+		FileBytes: size,
+		WriteMBps: float64(size) / megabyte / writeSeconds,
+		ReadMBps:  float64(read) / megabyte / readSeconds,
+	}, nil
+}
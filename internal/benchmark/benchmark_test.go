@@ -0,0 +1,126 @@
+package benchmark
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func quickConfig(t *testing.T) Config {
+	config := DefaultConfig()
+	config.CPUDuration = 20 * time.Millisecond
+	config.MemoryDuration = 20 * time.Millisecond
+	config.MemoryBytes = 1 << 20
+	config.DiskPath = t.TempDir()
+	config.DiskBytes = 1 << 20
+	return config
+}
+
+func TestRunScoresEveryPart(t *testing.T) {
+	report, err := Run(context.Background(), quickConfig(t))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.CPU.SingleCoreMBps <= 0 || report.CPU.MultiCoreMBps <= 0 || report.Memory.CopyGBps <= 0 {
+		t.Fatalf("report = %+v, want CPU and memory measured", report)
+	}
+	if report.Disk.WriteMBps <= 0 || report.Disk.ReadMBps <= 0 {
+		t.Fatalf("disk = %+v, want it measured", report.Disk)
+	}
+	if report.GPU != nil || report.Scores.GPU != 0 {
+		t.Fatalf("report has a GPU benchmark without GPUs")
+	}
+	if report.Scores.Overall <= 0 || report.Scores != Score(report) {
+		t.Fatalf("scores = %+v", report.Scores)
+	}
+}
+
+func TestScoreOfReferenceMachine(t *testing.T) {
+	report := &models.BenchmarkReport{
+		CPU:    models.CPUBenchmark{SingleCoreMBps: referenceSingleCoreMBps, MultiCoreMBps: referenceMultiCoreMBps},
+		Memory: models.MemoryBenchmark{CopyGBps: referenceMemoryGBps},
+		Disk:   models.DiskBenchmark{WriteMBps: referenceDiskWriteMBps, ReadMBps: referenceDiskReadMBps},
+		GPU:    &models.GPUBenchmark{GFLOPS: 2 * referenceGPUGFLOPS},
+	}
+	want := models.BenchmarkScores{CPU: 1000, Memory: 1000, Disk: 1000, GPU: 2000, Overall: 1000}
+	if got := Score(report); got != want {
+		t.Fatalf("Score() = %+v, want %+v", got, want)
+	}
+	if weight := want.RewardWeight(); weight != 1 {
+		t.Fatalf("RewardWeight() = %v, want 1", weight)
+	}
+	if weight := (models.BenchmarkScores{Overall: 100}).RewardWeight(); weight != models.MinRewardWeight {
+		t.Fatalf("RewardWeight() of a slow machine = %v, want %v", weight, models.MinRewardWeight)
+	}
+}
+
+func TestGPUBenchmarkParsesNBody(t *testing.T) {
+	original := run
+	defer func() { run = original }()
+	var called []string
+	run = func(ctx context.Context, name string, args ...string) (string, error) {
+		called = append([]string{name}, args...)
+		return "> Compute 8.9 CUDA device: [NVIDIA L4]\n" +
+			"256000 bodies, total time for 10 iterations: 4527.210 ms\n" +
+			"= 144757.340 billion interactions per second\n" +
+			"= 2895.147 single-precision GFLOP/s at 20 flops per interaction\n", nil
+	}
+
+	config := DefaultConfig()
+	config.Runtime = "podman"
+	config.GPUs = []models.GPUInfo{{Index: 0, UUID: "GPU-1", Model: "NVIDIA L4"}}
+	result, err := gpuThroughput(context.Background(), config)
+	if err != nil {
+		t.Fatalf("gpuThroughput() error = %v", err)
+	}
+	if result.GFLOPS != 2895.147 || result.Count != 1 || result.Model != "NVIDIA L4" {
+		t.Fatalf("result = %+v", result)
+	}
+	if command := strings.Join(called, " "); !strings.Contains(command, "--device nvidia.com/gpu=GPU-1") {
+		t.Fatalf("command = %s, want the GPU passed as a CDI device", command)
+	}
+}
+
+func TestSignedReportVerifiesAndSurvivesSave(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Run(context.Background(), quickConfig(t))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	report.DeviceID = "device-1"
+	if err := Sign(report, key); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if err := Save(report); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Load()
+	if err != nil || loaded == nil {
+		t.Fatalf("Load() = %v, %v", loaded, err)
+	}
+	if err := Verify(loaded); err != nil {
+		t.Fatalf("Verify() of the saved report error = %v", err)
+	}
+
+	inflated := *loaded
+	inflated.Scores.Overall *= 2
+	if err := Verify(&inflated); err == nil {
+		t.Fatal("Verify() accepted a report with altered scores")
+	}
+	faster := *loaded
+	faster.CPU.MultiCoreMBps *= 2
+	faster.Scores = Score(&faster)
+	if err := Verify(&faster); err == nil {
+		t.Fatal("Verify() accepted a report with altered measurements")
+	}
+}
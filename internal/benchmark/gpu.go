@@ -0,0 +1,59 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/gpu"
+)
+
+// run executes a command and returns its combined output. It is a variable so
+// tests can stand in for the container runtime.
+var run = func(ctx context.Context, name string, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%s failed: %w", name, err)
+	}
+	return string(output), nil
+}
+
+var nbodyGFLOPS = regexp.MustCompile(`([0-9.]+) single-precision GFLOP/s`)
+
+// gpuThroughput runs the n-body sample on every GPU of the config at once
+func gpuThroughput(ctx context.Context, config Config) (*models.GPUBenchmark, error) {
+	args := []string{"run", "--rm"}
+	if config.Runtime == "podman" {
+		for _, device := range gpu.CDIDevices(config.GPUs) {
+			args = append(args, "--device", device)
+		}
+	} else {
+		args = append(args, "--gpus", gpu.DockerArg(config.GPUs))
+	}
+	args = append(args, config.GPUImage,
+		"nbody", "-gpu", "-benchmark",
+		"-numbodies="+strconv.Itoa(config.GPUBodies),
+		"-numdevices="+strconv.Itoa(len(config.GPUs)))
+
+	output, err := run(ctx, config.Runtime, args...)
+	if err != nil {
+		return nil, err
+	}
+	match := nbodyGFLOPS.FindStringSubmatch(output)
+	if match == nil {
+		return nil, fmt.Errorf("no GFLOP/s in n-body output %q", output)
+	}
+	gflops, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid n-body GFLOP/s %q", match[1])
+	}
+
+	return &models.GPUBenchmark{
+		Model:  config.GPUs[0].Model,
+		Count:  len(config.GPUs),
+		GFLOPS: gflops,
+	}, nil
+}
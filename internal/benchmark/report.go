@@ -0,0 +1,131 @@
+package benchmark
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/signer"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const reportFile = "hardware-benchmark.json"
+
+// Sign sets the wallet address of the key on the report and signs it
+func Sign(report *models.BenchmarkReport, key *ecdsa.PrivateKey) error {
+	if key == nil {
+		return fmt.Errorf("runner signing key is required")
+	}
+	return SignWith(report, signer.FromKey(key))
+}
+
+// SignWith sets the wallet address of the signer on the report and signs it
+func SignWith(report *models.BenchmarkReport, s signer.Signer) error {
+	if s == nil {
+		return fmt.Errorf("runner signer is required")
+	}
+
+	report.WalletAddress = s.Address().Hex()
+
+	digest, err := digestOf(report)
+	if err != nil {
+		return err
+	}
+
+	signature, err := s.SignDigest(digest)
+	if err != nil {
+		return fmt.Errorf("failed to sign benchmark report: %w", err)
+	}
+
+	report.Signature = hexutil.Encode(signature)
+	return nil
+}
+
+// Verify checks that the report was signed by its wallet address and that its
+// scores follow from its measurements
+func Verify(report *models.BenchmarkReport) error {
+	if report.Signature == "" {
+		return fmt.Errorf("benchmark report is not signed")
+	}
+	if report.Version != models.BenchmarkVersion {
+		return fmt.Errorf("unsupported benchmark version %d", report.Version)
+	}
+
+	digest, err := digestOf(report)
+	if err != nil {
+		return err
+	}
+
+	if err := signer.Verify(digest, report.Signature, report.WalletAddress); err != nil {
+		return fmt.Errorf("invalid benchmark report signature: %w", err)
+	}
+
+	if Score(report) != report.Scores {
+		return errors.New("benchmark scores do not match the measurements")
+	}
+	return nil
+}
+
+func digestOf(report *models.BenchmarkReport) ([]byte, error) {
+	unsigned := *report
+	unsigned.Signature = ""
+	payload, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal benchmark report payload: %w", err)
+	}
+	return crypto.Keccak256(payload), nil
+}
+
+func reportPath() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, reportFile), nil
+}
+
+// Save keeps a signed report for the runner to send when it registers
+func Save(report *models.BenchmarkReport) error {
+	path, err := reportPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode benchmark report: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save benchmark report: %w", err)
+	}
+	return nil
+}
+
+// Load returns the saved report, or nil if the runner was never benchmarked
+func Load() (*models.BenchmarkReport, error) {
+	path, err := reportPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark report: %w", err)
+	}
+	var report models.BenchmarkReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid benchmark report: %w", err)
+	}
+	return &report, nil
+}
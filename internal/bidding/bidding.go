@@ -0,0 +1,139 @@
+// Package bidding prices tasks from what they reserve on the runner and bids
+// for them on servers that auction tasks instead of handing them to whichever
+// runner claims first.
+package bidding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	executiontask "github.com/theblitlabs/parity-runner/internal/execution/task"
+)
+
+// Pricing is what the runner charges for its resources. Tasks are priced for
+// the CPUs, memory and GPUs they reserve over their timeout, or Runtime when
+// they set none, plus Base; Margin is added on top as a share of that cost.
+type Pricing struct {
+	Base       float64
+	PerCPUHour float64
+	// PerGBHour is charged per GB of memory
+	PerGBHour  float64
+	PerGPUHour float64
+	Margin     float64
+	Runtime    time.Duration
+}
+
+// Quote is what the runner would bid on a task
+type Quote struct {
+	Runtime time.Duration `json:"runtime"`
+	Cost    float64       `json:"cost"`
+	Price   float64       `json:"price"`
+}
+
+// Covered reports whether the task's reward pays the quoted price
+func (q Quote) Covered(task *models.Task) bool {
+	return q.Price <= task.Reward
+}
+
+// defaultReservation is what a task that requests nothing is priced for
+var defaultReservation = executiontask.Reservation{CPUs: 1}
+
+// Quote prices a task
+func (p Pricing) Quote(task *models.Task) (Quote, error) {
+	reservation, err := executiontask.ReservationFor(task, defaultReservation)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	runtime := p.Runtime
+	var config models.TaskConfig
+	if len(task.Config) > 0 && json.Unmarshal(task.Config, &config) == nil {
+		if timeout, err := time.ParseDuration(config.Resources.Timeout); err == nil && timeout > 0 {
+			runtime = timeout
+		}
+	}
+	if max := task.MaxDuration(); max > 0 && (runtime <= 0 || runtime > max) {
+		runtime = max
+	}
+
+	gpus := 0
+	if task.GPU != nil {
+		gpus = task.GPU.Count
+	}
+	hours := runtime.Hours()
+	cost := p.Base + hours*(reservation.CPUs*p.PerCPUHour+
+		float64(reservation.MemoryBytes)/(1<<30)*p.PerGBHour+
+		float64(gpus)*p.PerGPUHour)
+
+	return Quote{
+		Runtime: runtime,
+		Cost:    roundPrice(cost),
+		Price:   roundPrice(cost * (1 + p.Margin)),
+	}, nil
+}
+
+func roundPrice(value float64) float64 {
+	return math.Round(value*1e8) / 1e8
+}
+
+// Bidder bids on tasks and waits for the server to award them
+type Bidder struct {
+	pricing Pricing
+	client  *Client
+	// PollInterval is how often the auction is checked until it closes
+	PollInterval time.Duration
+	// MaxWait bounds how long a bid waits for the auction to close
+	MaxWait time.Duration
+}
+
+func NewBidder(pricing Pricing, client *Client) *Bidder {
+	return &Bidder{
+		pricing:      pricing,
+		client:       client,
+		PollInterval: time.Second,
+		MaxWait:      2 * time.Minute,
+	}
+}
+
+func (b *Bidder) Quote(task *models.Task) (Quote, error) {
+	return b.pricing.Quote(task)
+}
+
+// Win bids the quoted price on the task and waits for the auction to close. It
+// reports true when the runner won the task, or when the server does not
+// auction it, so the task is claimed as usual.
+func (b *Bidder) Win(ctx context.Context, task *models.Task, quote Quote) (models.BidStatus, bool, error) {
+	taskID := task.ID.String()
+	status, err := b.client.SubmitBid(ctx, taskID, quote.Price)
+	if errors.Is(err, ErrNoAuction) {
+		return models.BidStatus{}, true, nil
+	}
+	if errors.Is(err, ErrAuctionClosed) {
+		return status, false, nil
+	}
+	if err != nil {
+		return models.BidStatus{}, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, b.MaxWait)
+	defer cancel()
+	for status.State == models.BidOpen {
+		wait := b.PollInterval
+		if untilClose := time.Until(status.ClosesAt); untilClose > wait {
+			wait = untilClose
+		}
+		select {
+		case <-ctx.Done():
+			return status, false, ctx.Err()
+		case <-time.After(wait):
+		}
+		if status, err = b.client.Status(ctx, taskID); err != nil {
+			return status, false, err
+		}
+	}
+	return status, status.State == models.BidWon, nil
+}
@@ -0,0 +1,72 @@
+package bidding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestQuotePricesReservedResources(t *testing.T) {
+	pricing := Pricing{Base: 0.01, PerCPUHour: 0.04, PerGBHour: 0.01, PerGPUHour: 0.5, Margin: 0.25, Runtime: 30 * time.Minute}
+
+	task := &models.Task{
+		Config: json.RawMessage(`{"resources":{"cpu_shares":2048,"memory":"4g","timeout":"2h"}}`),
+		GPU:    &models.GPURequirements{Count: 1},
+	}
+	quote, err := pricing.Quote(task)
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+	// 0.01 + 2h * (2 * 0.04 + 4 * 0.01 + 0.5)
+	if quote.Runtime != 2*time.Hour || quote.Cost != 1.25 || quote.Price != 1.5625 {
+		t.Fatalf("Quote() = %+v, want 2h costing 1.25 and bid at 1.5625", quote)
+	}
+
+	// Tasks that request nothing get a CPU for the default runtime
+	quote, _ = pricing.Quote(&models.Task{})
+	if quote.Runtime != 30*time.Minute || quote.Cost != 0.03 {
+		t.Fatalf("Quote() of a bare task = %+v", quote)
+	}
+	// The server's maximum duration caps the runtime
+	quote, _ = pricing.Quote(&models.Task{MaxDurationSecs: 600})
+	if quote.Runtime != 10*time.Minute {
+		t.Fatalf("Quote() runtime = %s, want the 10m maximum", quote.Runtime)
+	}
+}
+
+func TestBidderWaitsForTheAward(t *testing.T) {
+	taskID := uuid.New()
+	polls := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/runners/tasks/"+taskID.String()+"/bids" || r.Header.Get("X-Device-ID") != "device-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		status := models.BidStatus{TaskID: taskID.String(), State: models.BidOpen, Price: 0.5, ClosesAt: time.Now()}
+		if r.Method == http.MethodGet && polls.Add(1) >= 2 {
+			status.State = models.BidWon
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	}))
+	defer server.Close()
+
+	bidder := NewBidder(Pricing{}, NewClient(server.URL, "device-1"))
+	bidder.PollInterval = time.Millisecond
+	status, won, err := bidder.Win(context.Background(), &models.Task{ID: taskID}, Quote{Price: 0.5})
+	if err != nil || !won || status.State != models.BidWon {
+		t.Fatalf("Win() = %+v, %v, %v, want won", status, won, err)
+	}
+
+	// Servers without auctions leave the task to be claimed as usual
+	if _, won, err := bidder.Win(context.Background(), &models.Task{ID: uuid.New()}, Quote{Price: 0.5}); err != nil || !won {
+		t.Fatalf("Win() without an auction = %v, %v, want the task taken", won, err)
+	}
+}
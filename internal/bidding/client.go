@@ -0,0 +1,113 @@
+package bidding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+)
+
+var (
+	// ErrNoAuction is returned when the server does not auction the task, or
+	// does not auction tasks at all
+	ErrNoAuction = errors.New("task is not auctioned")
+	// ErrAuctionClosed is returned for bids that arrive after the task was
+	// awarded
+	ErrAuctionClosed = errors.New("auction has closed")
+)
+
+// maxBidResponse bounds the body read from the bid API
+const maxBidResponse = 1 << 20
+
+// Client talks to the bid API of the server
+type Client struct {
+	serverURL string
+	deviceID  string
+	client    *http.Client
+	verifier  *identity.Verifier
+}
+
+func NewClient(serverURL, deviceID string) *Client {
+	return &Client{
+		serverURL: strings.TrimSuffix(strings.TrimSuffix(serverURL, "/"), "/api"),
+		deviceID:  deviceID,
+		client:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// SetServerVerifier makes the client reject answers that were not signed by
+// the pinned server identity
+func (c *Client) SetServerVerifier(verifier *identity.Verifier) {
+	c.verifier = verifier
+}
+
+// SubmitBid offers to run the task for price. When the auction has already
+// closed, the status comes with ErrAuctionClosed.
+func (c *Client) SubmitBid(ctx context.Context, taskID string, price float64) (models.BidStatus, error) {
+	body, err := json.Marshal(map[string]float64{"price": price})
+	if err != nil {
+		return models.BidStatus{}, fmt.Errorf("failed to marshal bid: %w", err)
+	}
+	return c.do(ctx, http.MethodPost, taskID, body)
+}
+
+// Status returns where the runner's bid on the task stands
+func (c *Client) Status(ctx context.Context, taskID string) (models.BidStatus, error) {
+	return c.do(ctx, http.MethodGet, taskID, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, taskID string, body []byte) (models.BidStatus, error) {
+	url := fmt.Sprintf("%s/api/v1/runners/tasks/%s/bids", c.serverURL, taskID)
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return models.BidStatus{}, fmt.Errorf("failed to create bid request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Device-ID", c.deviceID)
+	if err := identity.SetNonce(req); err != nil {
+		return models.BidStatus{}, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return models.BidStatus{}, fmt.Errorf("failed to reach bid API: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxBidResponse))
+	if err != nil {
+		return models.BidStatus{}, fmt.Errorf("failed to read bid response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return models.BidStatus{}, ErrNoAuction
+	case http.StatusConflict:
+		var closed struct {
+			Status models.BidStatus `json:"status"`
+		}
+		_ = json.Unmarshal(respBody, &closed)
+		return closed.Status, ErrAuctionClosed
+	default:
+		return models.BidStatus{}, fmt.Errorf("bid API returned %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	}
+
+	if c.verifier != nil {
+		if err := c.verifier.VerifyResponse(resp, respBody); err != nil {
+			return models.BidStatus{}, fmt.Errorf("server response failed identity check: %w", err)
+		}
+	}
+	var status models.BidStatus
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return models.BidStatus{}, fmt.Errorf("invalid bid response: %w", err)
+	}
+	return status, nil
+}
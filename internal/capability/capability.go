@@ -0,0 +1,131 @@
+// Package capability measures what a runner offers when it starts: its cores,
+// memory, GPUs, free disk, download bandwidth from the server and the task
+// types it runs. The profile is sent with the runner's registration so the
+// server can match tasks to it.
+package capability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// DefaultBandwidthBytes is how much is downloaded to measure bandwidth
+const DefaultBandwidthBytes = 8 << 20
+
+const bandwidthTimeout = 30 * time.Second
+
+// Options are what Measure cannot find out on its own
+type Options struct {
+	TaskTypes []models.TaskType
+	GPUs      []models.GPUInfo
+	// Region is reported as configured
+	Region string
+	// DiskPath is on the filesystem tasks use, the user's home directory when
+	// empty
+	DiskPath string
+	// ServerURL is downloaded from to measure bandwidth; bandwidth is not
+	// measured when it is empty
+	ServerURL      string
+	BandwidthBytes int64
+	Client         *http.Client
+}
+
+// Measure profiles the runner. What cannot be measured is left at zero, so a
+// runner still registers when, say, the server's bandwidth endpoint is down.
+func Measure(ctx context.Context, opts Options) *models.CapabilityProfile {
+	log := gologger.WithComponent("capability")
+
+	profile := &models.CapabilityProfile{
+		CPUCores:   runtime.NumCPU(),
+		GPUs:       opts.GPUs,
+		TaskTypes:  opts.TaskTypes,
+		Region:     opts.Region,
+		MeasuredAt: time.Now().UTC(),
+	}
+
+	if memory, err := mem.VirtualMemory(); err == nil {
+		profile.MemoryBytes = int64(memory.Total)
+	} else {
+		log.Warn().Err(err).Msg("Failed to read memory size")
+	}
+
+	diskPath := opts.DiskPath
+	if diskPath == "" {
+		diskPath = "/"
+		if home, err := os.UserHomeDir(); err == nil {
+			diskPath = home
+		}
+	}
+	if usage, err := disk.Usage(diskPath); err == nil {
+		profile.DiskBytes = int64(usage.Total)
+		profile.DiskFreeBytes = int64(usage.Free)
+	} else {
+		log.Warn().Err(err).Str("path", diskPath).Msg("Failed to read disk size")
+	}
+
+	if opts.ServerURL != "" {
+		size := opts.BandwidthBytes
+		if size <= 0 {
+			size = DefaultBandwidthBytes
+		}
+		client := opts.Client
+		if client == nil {
+			client = &http.Client{Timeout: bandwidthTimeout}
+		}
+		url := fmt.Sprintf("%s/api/v1/runners/bandwidth?bytes=%d", opts.ServerURL, size)
+		if mbps, err := MeasureBandwidth(ctx, client, url); err == nil {
+			profile.BandwidthMbps = mbps
+		} else {
+			log.Warn().Err(err).Msg("Failed to measure bandwidth")
+		}
+	}
+
+	log.Info().
+		Int("cpu_cores", profile.CPUCores).
+		Int64("memory_bytes", profile.MemoryBytes).
+		Int("gpus", len(profile.GPUs)).
+		Int64("disk_free_bytes", profile.DiskFreeBytes).
+		Float64("bandwidth_mbps", profile.BandwidthMbps).
+		Msg("Measured capability profile")
+	return profile
+}
+
+// MeasureBandwidth downloads url and returns the rate in megabits per second
+func MeasureBandwidth(ctx context.Context, client *http.Client, url string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bandwidth request: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download bandwidth sample: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("bandwidth endpoint returned status %d", resp.StatusCode)
+	}
+
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download bandwidth sample: %w", err)
+	}
+	elapsed := time.Since(start).Seconds()
+	if n == 0 || elapsed <= 0 {
+		return 0, errors.New("bandwidth sample was empty")
+	}
+	return float64(n) * 8 / elapsed / 1e6, nil
+}
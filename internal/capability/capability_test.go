@@ -0,0 +1,54 @@
+package capability
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestMeasure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/runners/bandwidth" {
+			http.NotFound(w, r)
+			return
+		}
+		n, _ := strconv.Atoi(r.URL.Query().Get("bytes"))
+		_, _ = w.Write([]byte(strings.Repeat("x", n)))
+	}))
+	defer server.Close()
+
+	profile := Measure(context.Background(), Options{
+		TaskTypes:      []models.TaskType{models.TaskTypeCommand},
+		DiskPath:       t.TempDir(),
+		ServerURL:      server.URL,
+		BandwidthBytes: 1 << 16,
+	})
+
+	if profile.CPUCores <= 0 || profile.MemoryBytes <= 0 {
+		t.Fatalf("unexpected cores %d and memory %d", profile.CPUCores, profile.MemoryBytes)
+	}
+	if profile.DiskBytes <= 0 || profile.DiskFreeBytes > profile.DiskBytes {
+		t.Fatalf("unexpected free disk %d of %d", profile.DiskFreeBytes, profile.DiskBytes)
+	}
+	if profile.BandwidthMbps <= 0 {
+		t.Fatalf("bandwidth = %v, want a measurement", profile.BandwidthMbps)
+	}
+	if !profile.Supports(models.TaskTypeCommand) || profile.Supports(models.TaskTypeDocker) {
+		t.Fatalf("task types = %v", profile.TaskTypes)
+	}
+}
+
+func TestMeasureWithoutBandwidthEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	profile := Measure(context.Background(), Options{DiskPath: t.TempDir(), ServerURL: server.URL})
+	if profile.BandwidthMbps != 0 {
+		t.Fatalf("bandwidth = %v, want none", profile.BandwidthMbps)
+	}
+}
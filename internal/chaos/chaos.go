@@ -0,0 +1,114 @@
+// Package chaos injects faults into a runner so the server's retry, lease and
+// reassignment handling can be exercised against realistic failures. It is meant
+// for test environments; a nil Injector injects nothing.
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is returned by operations failed on purpose
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Config sets how often each fault is injected. Rates are probabilities between
+// 0 and 1. Heartbeats are held back by a random delay of up to HeartbeatDelay,
+// and killed containers are killed a random time of up to ContainerKillAfter
+// after they start. A zero Seed seeds from the clock.
+type Config struct {
+	WebhookDropRate    float64
+	HeartbeatDelay     time.Duration
+	ContainerKillRate  float64
+	ContainerKillAfter time.Duration
+	ResultFailureRate  float64
+	Seed               int64
+}
+
+type Injector struct {
+	config Config
+	mu     sync.Mutex
+	rand   *rand.Rand
+}
+
+// New returns an injector for config, or nil when config injects no faults
+func New(config Config) (*Injector, error) {
+	rates := map[string]float64{
+		"webhook drop rate":   config.WebhookDropRate,
+		"container kill rate": config.ContainerKillRate,
+		"result failure rate": config.ResultFailureRate,
+	}
+	for name, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	if config.HeartbeatDelay < 0 || config.ContainerKillAfter < 0 {
+		return nil, errors.New("chaos delays cannot be negative")
+	}
+
+	if config.WebhookDropRate == 0 && config.HeartbeatDelay == 0 &&
+		config.ContainerKillRate == 0 && config.ResultFailureRate == 0 {
+		return nil, nil
+	}
+
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{config: config, rand: rand.New(rand.NewSource(seed))}, nil
+}
+
+// Enabled reports whether any fault is injected
+func (i *Injector) Enabled() bool {
+	return i != nil
+}
+
+// DropDelivery reports whether a task delivery should be dropped as if it never arrived
+func (i *Injector) DropDelivery() bool {
+	return i != nil && i.roll(i.config.WebhookDropRate)
+}
+
+// HeartbeatDelay is how long to hold back the next heartbeat
+func (i *Injector) HeartbeatDelay() time.Duration {
+	if i == nil {
+		return 0
+	}
+	return i.upTo(i.config.HeartbeatDelay)
+}
+
+// ContainerKill reports whether a started container should be killed and after how long
+func (i *Injector) ContainerKill() (time.Duration, bool) {
+	if i == nil || !i.roll(i.config.ContainerKillRate) {
+		return 0, false
+	}
+	return i.upTo(i.config.ContainerKillAfter), true
+}
+
+// FailResultSubmission returns ErrInjected when a result submission should fail
+func (i *Injector) FailResultSubmission() error {
+	if i != nil && i.roll(i.config.ResultFailureRate) {
+		return fmt.Errorf("result submission failed: %w", ErrInjected)
+	}
+	return nil
+}
+
+func (i *Injector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rand.Float64() < rate
+}
+
+func (i *Injector) upTo(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return time.Duration(i.rand.Int63n(int64(max) + 1))
+}
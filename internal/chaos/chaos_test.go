@@ -0,0 +1,79 @@
+package chaos
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewValidatesConfig(t *testing.T) {
+	if _, err := New(Config{WebhookDropRate: 1.5}); err == nil {
+		t.Fatal("expected an error for a rate above 1")
+	}
+	if _, err := New(Config{HeartbeatDelay: -time.Second}); err == nil {
+		t.Fatal("expected an error for a negative delay")
+	}
+
+	injector, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if injector.Enabled() {
+		t.Fatal("expected no injector without faults")
+	}
+}
+
+func TestNilInjectorInjectsNothing(t *testing.T) {
+	var injector *Injector
+
+	if injector.DropDelivery() {
+		t.Fatal("nil injector dropped a delivery")
+	}
+	if delay := injector.HeartbeatDelay(); delay != 0 {
+		t.Fatalf("HeartbeatDelay() = %v, want 0", delay)
+	}
+	if _, kill := injector.ContainerKill(); kill {
+		t.Fatal("nil injector killed a container")
+	}
+	if err := injector.FailResultSubmission(); err != nil {
+		t.Fatalf("FailResultSubmission() error = %v", err)
+	}
+}
+
+func TestInjectorAppliesRates(t *testing.T) {
+	injector, err := New(Config{
+		WebhookDropRate:    0.3,
+		HeartbeatDelay:     time.Second,
+		ContainerKillRate:  1,
+		ContainerKillAfter: 5 * time.Second,
+		ResultFailureRate:  1,
+		Seed:               42,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	dropped := 0
+	for i := 0; i < 1000; i++ {
+		if injector.DropDelivery() {
+			dropped++
+		}
+	}
+	if dropped < 200 || dropped > 400 {
+		t.Fatalf("dropped %d of 1000 deliveries, want about 300", dropped)
+	}
+
+	for i := 0; i < 100; i++ {
+		if delay := injector.HeartbeatDelay(); delay < 0 || delay > time.Second {
+			t.Fatalf("HeartbeatDelay() = %v, want at most 1s", delay)
+		}
+		after, kill := injector.ContainerKill()
+		if !kill || after > 5*time.Second {
+			t.Fatalf("ContainerKill() = %v, %v, want a kill within 5s", after, kill)
+		}
+	}
+
+	if err := injector.FailResultSubmission(); !errors.Is(err, ErrInjected) {
+		t.Fatalf("FailResultSubmission() error = %v, want ErrInjected", err)
+	}
+}
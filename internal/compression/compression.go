@@ -0,0 +1,114 @@
+// Package compression negotiates how runners compress result submissions. The
+// server lists the encodings it accepts when a runner registers, and the runner
+// sends large results with the first of its own preferences on that list.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	Zstd     = "zstd"
+	Gzip     = "gzip"
+	Identity = "identity"
+
+	// MinBytes is the smallest body worth compressing
+	MinBytes = 1 << 10
+)
+
+// ErrTooLarge is returned when a body decompresses to more than allowed
+var ErrTooLarge = errors.New("decompressed body exceeds the size limit")
+
+// Supported are the encodings the server accepts, in the order runners should
+// prefer them
+var Supported = []string{Zstd, Gzip, Identity}
+
+// Negotiate picks the best encoding the server advertised. Servers that
+// advertise nothing get uncompressed bodies.
+func Negotiate(advertised []string) string {
+	for _, encoding := range Supported {
+		for _, offered := range advertised {
+			if strings.EqualFold(strings.TrimSpace(offered), encoding) {
+				return encoding
+			}
+		}
+	}
+	return Identity
+}
+
+// Encode compresses data for a Content-Encoding header
+func Encode(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	switch encoding {
+	case Identity, "":
+		return data, nil
+	case Gzip:
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+	case Zstd:
+		writer, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writer.Write(data); err != nil {
+			writer.Close()
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode reads a body sent with the given Content-Encoding, refusing to
+// decompress more than maxBytes
+func Decode(encoding string, r io.Reader, maxBytes int64) ([]byte, error) {
+	var decoded io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case Identity, "":
+		decoded = r
+	case Gzip:
+		reader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer reader.Close()
+		decoded = reader
+	case Zstd:
+		reader, err := zstd.NewReader(r, zstd.WithDecoderMaxMemory(uint64(maxBytes)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid zstd body: %w", err)
+		}
+		defer reader.Close()
+		decoded = reader
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(decoded, maxBytes+1))
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return nil, ErrTooLarge
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s body: %w", encoding, err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,44 @@
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		advertised []string
+		want       string
+	}{
+		{[]string{"identity", "gzip", "zstd"}, Zstd},
+		{[]string{"GZIP"}, Gzip},
+		{[]string{"br"}, Identity},
+		{nil, Identity},
+	} {
+		if got := Negotiate(tc.advertised); got != tc.want {
+			t.Errorf("Negotiate(%v) = %s, want %s", tc.advertised, got, tc.want)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	data := bytes.Repeat([]byte("epoch 1 loss 0.25\n"), 4096)
+
+	for _, encoding := range Supported {
+		encoded, err := Encode(encoding, data)
+		if err != nil {
+			t.Fatalf("Encode(%s) error = %v", encoding, err)
+		}
+		if encoding != Identity && len(encoded) >= len(data)/10 {
+			t.Errorf("%s only shrank %d bytes to %d", encoding, len(data), len(encoded))
+		}
+		decoded, err := Decode(encoding, bytes.NewReader(encoded), int64(len(data)))
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Fatalf("Decode(%s) = %d bytes, %v", encoding, len(decoded), err)
+		}
+		if _, err := Decode(encoding, bytes.NewReader(encoded), int64(len(data)-1)); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("Decode(%s) over the limit error = %v, want ErrTooLarge", encoding, err)
+		}
+	}
+}
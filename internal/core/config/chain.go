@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Chain is an EVM network the runner can stake on and be paid on
+type Chain struct {
+	Name               string `json:"name"`
+	ChainID            int64  `json:"chain_id"`
+	RPC                string `json:"rpc"`
+	TokenAddress       string `json:"token_address,omitempty"`
+	StakeWalletAddress string `json:"stake_wallet_address,omitempty"`
+	TokenSymbol        string `json:"token_symbol,omitempty"`
+	NetworkName        string `json:"network_name"`
+	Testnet            bool   `json:"testnet,omitempty"`
+}
+
+// knownChains have built-in chain IDs and public RPCs. Token and stake wallet
+// addresses depend on the deployment and always come from the config.
+var knownChains = []Chain{
+	{Name: "ethereum", ChainID: 1, RPC: "https://ethereum-rpc.publicnode.com", NetworkName: "Ethereum"},
+	{Name: "polygon", ChainID: 137, RPC: "https://polygon-rpc.com", NetworkName: "Polygon"},
+	{Name: "base", ChainID: 8453, RPC: "https://mainnet.base.org", NetworkName: "Base"},
+	{Name: "arbitrum", ChainID: 42161, RPC: "https://arb1.arbitrum.io/rpc", NetworkName: "Arbitrum One"},
+	{Name: "sepolia", ChainID: 11155111, RPC: "https://rpc.sepolia.org", NetworkName: "Sepolia", Testnet: true},
+	{Name: "base-sepolia", ChainID: 84532, RPC: "https://sepolia.base.org", NetworkName: "Base Sepolia", Testnet: true},
+	{Name: "arbitrum-sepolia", ChainID: 421614, RPC: "https://sepolia-rollup.arbitrum.io/rpc", NetworkName: "Arbitrum Sepolia", Testnet: true},
+	{Name: "polygon-amoy", ChainID: 80002, RPC: "https://rpc-amoy.polygon.technology", NetworkName: "Polygon Amoy", Testnet: true},
+}
+
+// ParseChainName normalizes a chain name; the empty name selects the chain
+// configured with the plain BLOCKCHAIN_ settings
+func ParseChainName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return "", fmt.Errorf("invalid chain name %q: use lowercase letters, digits and dashes", name)
+		}
+	}
+	return name, nil
+}
+
+// chainKey is the config key prefix of a chain's settings, such as
+// BLOCKCHAIN_BASE_SEPOLIA_ for base-sepolia
+func chainKey(name string) string {
+	return "BLOCKCHAIN_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+// loadChains builds the registry from the known chains and the chains listed in
+// BLOCKCHAIN_CHAINS, each set up with BLOCKCHAIN_<NAME>_RPC, _CHAIN_ID,
+// _TOKEN_ADDRESS, _STAKE_WALLET_ADDRESS, _TOKEN_SYMBOL and _NETWORK_NAME. A known
+// chain with the chain ID of the plain BLOCKCHAIN_ settings inherits the
+// addresses configured there.
+func loadChains(v *viper.Viper, legacy BlockchainConfig) (map[string]Chain, error) {
+	chains := make(map[string]Chain, len(knownChains))
+	for _, chain := range knownChains {
+		chains[chain.Name] = chain
+	}
+	for _, name := range strings.Split(v.GetString("BLOCKCHAIN_CHAINS"), ",") {
+		name, err := ParseChainName(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := chains[name]; name != "" && !ok {
+			chains[name] = Chain{Name: name}
+		}
+	}
+
+	for name, chain := range chains {
+		key := chainKey(name)
+		if rpc := v.GetString(key + "RPC"); rpc != "" {
+			chain.RPC = rpc
+		}
+		if chainID := v.GetInt64(key + "CHAIN_ID"); chainID != 0 {
+			chain.ChainID = chainID
+		}
+		chain.TokenAddress = v.GetString(key + "TOKEN_ADDRESS")
+		chain.StakeWalletAddress = v.GetString(key + "STAKE_WALLET_ADDRESS")
+		chain.TokenSymbol = v.GetString(key + "TOKEN_SYMBOL")
+		if networkName := v.GetString(key + "NETWORK_NAME"); networkName != "" {
+			chain.NetworkName = networkName
+		}
+
+		if legacy.ChainID != 0 && legacy.ChainID == chain.ChainID {
+			if chain.TokenAddress == "" {
+				chain.TokenAddress = legacy.TokenAddress
+			}
+			if chain.StakeWalletAddress == "" {
+				chain.StakeWalletAddress = legacy.StakeWalletAddress
+			}
+		}
+		if chain.ChainID == 0 || chain.RPC == "" {
+			return nil, fmt.Errorf("chain %s needs %sCHAIN_ID and %sRPC", name, key, key)
+		}
+		chains[name] = chain
+	}
+	return chains, nil
+}
+
+// ChainNames lists the chains of the registry in order of name
+func (c *Config) ChainNames() []string {
+	names := make([]string, 0, len(c.Blockchain.Chains))
+	for name := range c.Blockchain.Chains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseChain makes name the chain the runner stakes and is paid on, replacing
+// the plain BLOCKCHAIN_ settings
+func (c *Config) UseChain(name string) error {
+	chain, ok := c.Blockchain.Chains[name]
+	if !ok {
+		return fmt.Errorf("unknown chain %q, configured chains: %s", name, strings.Join(c.ChainNames(), ", "))
+	}
+	if chain.TokenAddress == "" {
+		return fmt.Errorf("chain %s has no token address, set %sTOKEN_ADDRESS", name, chainKey(name))
+	}
+	if c.IsTestnet() && !chain.Testnet {
+		return fmt.Errorf("chain %s is not a testnet and cannot be used with --network %s", name, NetworkTestnet)
+	}
+
+	c.Blockchain.Chain = name
+	c.Blockchain.RPC = chain.RPC
+	c.Blockchain.ChainID = chain.ChainID
+	c.Blockchain.TokenAddress = chain.TokenAddress
+	c.Blockchain.StakeWalletAddress = chain.StakeWalletAddress
+	c.Blockchain.NetworkName = chain.NetworkName
+	c.Blockchain.Testnet = chain.Testnet
+	if chain.TokenSymbol != "" {
+		c.Blockchain.TokenSymbol = chain.TokenSymbol
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoadChainsAddsCustomChainsAndInheritsLegacyAddresses(t *testing.T) {
+	v := viper.New()
+	v.Set("BLOCKCHAIN_CHAINS", "Optimism")
+	v.Set("BLOCKCHAIN_OPTIMISM_CHAIN_ID", 10)
+	v.Set("BLOCKCHAIN_OPTIMISM_RPC", "https://mainnet.optimism.io")
+	v.Set("BLOCKCHAIN_OPTIMISM_TOKEN_ADDRESS", "0xop")
+	v.Set("BLOCKCHAIN_BASE_SEPOLIA_RPC", "https://base-sepolia.example")
+	legacy := BlockchainConfig{ChainID: 11155111, TokenAddress: "0xtoken", StakeWalletAddress: "0xstake"}
+
+	chains, err := loadChains(v, legacy)
+	if err != nil {
+		t.Fatalf("loadChains() error = %v", err)
+	}
+	if optimism := chains["optimism"]; optimism.ChainID != 10 || optimism.TokenAddress != "0xop" {
+		t.Fatalf("optimism = %+v", optimism)
+	}
+	if sepolia := chains["sepolia"]; sepolia.TokenAddress != "0xtoken" || sepolia.StakeWalletAddress != "0xstake" {
+		t.Fatalf("sepolia = %+v, want the addresses of the plain settings", sepolia)
+	}
+	if baseSepolia := chains["base-sepolia"]; baseSepolia.RPC != "https://base-sepolia.example" || baseSepolia.TokenAddress != "" {
+		t.Fatalf("base-sepolia = %+v", baseSepolia)
+	}
+
+	v.Set("BLOCKCHAIN_CHAINS", "optimism,zora")
+	if _, err := loadChains(v, legacy); err == nil || !strings.Contains(err.Error(), "BLOCKCHAIN_ZORA_CHAIN_ID") {
+		t.Fatalf("loadChains() with an incomplete chain error = %v", err)
+	}
+}
+
+func TestUseChainOverlaysTheBlockchainSettings(t *testing.T) {
+	cfg := &Config{Network: NetworkTestnet}
+	cfg.Blockchain.TokenSymbol = "PRTY"
+	cfg.Blockchain.Chains = map[string]Chain{
+		"base":         {Name: "base", ChainID: 8453, RPC: "https://mainnet.base.org", TokenAddress: "0xbase"},
+		"base-sepolia": {Name: "base-sepolia", ChainID: 84532, RPC: "https://sepolia.base.org", TokenAddress: "0xtoken", StakeWalletAddress: "0xstake", NetworkName: "Base Sepolia", Testnet: true},
+		"polygon-amoy": {Name: "polygon-amoy", ChainID: 80002, RPC: "https://rpc-amoy.polygon.technology", Testnet: true},
+	}
+
+	if err := cfg.UseChain("base"); err == nil {
+		t.Fatal("UseChain() accepted a mainnet chain on the testnet network")
+	}
+	if err := cfg.UseChain("polygon-amoy"); err == nil {
+		t.Fatal("UseChain() accepted a chain without a token address")
+	}
+	if err := cfg.UseChain("linea"); err == nil {
+		t.Fatal("UseChain() accepted an unknown chain")
+	}
+
+	if err := cfg.UseChain("base-sepolia"); err != nil {
+		t.Fatalf("UseChain() error = %v", err)
+	}
+	bc := cfg.Blockchain
+	if bc.Chain != "base-sepolia" || bc.ChainID != 84532 || bc.RPC != "https://sepolia.base.org" ||
+		bc.TokenAddress != "0xtoken" || bc.StakeWalletAddress != "0xstake" || !bc.Testnet || bc.TokenSymbol != "PRTY" {
+		t.Fatalf("blockchain config = %+v", bc)
+	}
+}
@@ -13,13 +13,140 @@ type Config struct {
 	Server     ServerConfig     `mapstructure:"SERVER"`
 	Blockchain BlockchainConfig `mapstructure:"BLOCKCHAIN"`
 	Runner     RunnerConfig     `mapstructure:"RUNNER"`
+	Network    string           `mapstructure:"-"`
 }
 
 type ServerConfig struct {
-	Host      string          `mapstructure:"HOST"`
-	Port      string          `mapstructure:"PORT"`
-	Endpoint  string          `mapstructure:"ENDPOINT"`
-	Websocket WebsocketConfig `mapstructure:"WEBSOCKET"`
+	Host         string            `mapstructure:"HOST"`
+	Port         string            `mapstructure:"PORT"`
+	GRPCPort     string            `mapstructure:"GRPC_PORT"`
+	Endpoint     string            `mapstructure:"ENDPOINT"`
+	Websocket    WebsocketConfig   `mapstructure:"WEBSOCKET"`
+	SLO          SLOConfig         `mapstructure:"SLO"`
+	TaskTimeouts TaskTimeoutConfig `mapstructure:"TASK_TIMEOUTS"`
+	Privacy      PrivacyConfig     `mapstructure:"PRIVACY"`
+	ResultHook   ResultHookConfig  `mapstructure:"RESULT_HOOK"`
+	Canary       CanaryConfig      `mapstructure:"CANARY"`
+	Quarantine   QuarantineConfig  `mapstructure:"QUARANTINE"`
+	Pricing      PricingConfig     `mapstructure:"PRICING"`
+	Chain        ChainConfig       `mapstructure:"CHAIN"`
+	StakePolicy  StakePolicyConfig `mapstructure:"STAKE_POLICY"`
+	// PrivateKey is the hex key the server signs responses, receipts and
+	// webhooks with and pays rewards from. Without it responses go unsigned
+	// and payouts stay queued.
+	PrivateKey string `mapstructure:"PRIVATE_KEY"`
+	// MinStake is the stake, in tokens, a runner needs to start a task
+	MinStake float64 `mapstructure:"MIN_STAKE"`
+	// AdminToken is the bearer token of operator routes such as fleet apply
+	// and quarantine release. Without it those routes are refused.
+	AdminToken string `mapstructure:"ADMIN_TOKEN"`
+}
+
+// PrivacyConfig protects stored task results. EncryptionKey is a base64 AES-256
+// key that seals result outputs; ScrubRules lists built-in rules (email, phone,
+// ipv4, credit_card, ssn) applied to outputs before they are stored.
+type PrivacyConfig struct {
+	EncryptionKey   string `mapstructure:"ENCRYPTION_KEY"`
+	EncryptionKeyID string `mapstructure:"ENCRYPTION_KEY_ID"`
+	ScrubRules      string `mapstructure:"SCRUB_RULES"`
+	ScrubPattern    string `mapstructure:"SCRUB_PATTERN"`
+}
+
+// ResultHookConfig posts every saved result to URL before its reward is paid.
+// The endpoint can veto the payout; with FailClosed an unreachable endpoint
+// vetoes it too.
+type ResultHookConfig struct {
+	URL        string        `mapstructure:"URL"`
+	Timeout    time.Duration `mapstructure:"TIMEOUT"`
+	FailClosed bool          `mapstructure:"FAIL_CLOSED"`
+}
+
+// CanaryConfig injects known-answer tasks every Interval to catch runners that
+// return results without doing the work. A Docker canary runs DockerImage and
+// an LLM canary asks LLMModel; with neither set no canaries are injected.
+type CanaryConfig struct {
+	Interval    time.Duration `mapstructure:"INTERVAL"`
+	DockerImage string        `mapstructure:"DOCKER_IMAGE"`
+	LLMModel    string        `mapstructure:"LLM_MODEL"`
+}
+
+// QuarantineConfig quarantines runners that fail Threshold canaries within
+// Window, offering them only canaries until they have spent Probation in
+// quarantine and passed ProbationCanaries in a row. It needs canaries; with
+// Threshold zero no runner is quarantined.
+type QuarantineConfig struct {
+	Threshold         int           `mapstructure:"THRESHOLD"`
+	Window            time.Duration `mapstructure:"WINDOW"`
+	Probation         time.Duration `mapstructure:"PROBATION"`
+	ProbationCanaries int           `mapstructure:"PROBATION_CANARIES"`
+}
+
+// PricingConfig tunes the reward suggestions of the estimate endpoint, with
+// zero values keeping the defaults. ModelRewards is a comma-separated list such
+// as "llama3=0.05". With EnforceFloor, tasks paying less than the suggestion
+// for their class are refused.
+type PricingConfig struct {
+	BaseReward          float64       `mapstructure:"BASE_REWARD"`
+	PerRuntimeSecond    float64       `mapstructure:"PER_RUNTIME_SECOND"`
+	PerImageGB          float64       `mapstructure:"PER_IMAGE_GB"`
+	ModelRewards        string        `mapstructure:"MODEL_REWARDS"`
+	Window              time.Duration `mapstructure:"WINDOW"`
+	MaxDemandMultiplier float64       `mapstructure:"MAX_DEMAND_MULTIPLIER"`
+	EnforceFloor        bool          `mapstructure:"ENFORCE_FLOOR"`
+}
+
+// ChainConfig tunes how the server talks to the chain, with zero values keeping
+// the defaults. A stake is trusted for StakeFreshTTL and may stand in for an
+// unreachable chain for StakeCacheTTL. The stake wallet contract is polled
+// every StakeWatchInterval and cached stakes are dropped when it changes.
+// Failed payouts are kept in PayoutQueueDir and retried every SettleInterval,
+// backing off from RetryBackoff to MaxRetryBackoff, and raise an alert after
+// AlertAfterAttempts failures. With BatchSize above 1, payouts are paid
+// BatchSize at a time in one transaction, or fewer once the oldest has waited
+// BatchInterval.
+type ChainConfig struct {
+	StakeFreshTTL      time.Duration `mapstructure:"STAKE_FRESH_TTL"`
+	StakeCacheTTL      time.Duration `mapstructure:"STAKE_CACHE_TTL"`
+	StakeWatchInterval time.Duration `mapstructure:"STAKE_WATCH_INTERVAL"`
+	PayoutQueueDir     string        `mapstructure:"PAYOUT_QUEUE_DIR"`
+	SettleInterval     time.Duration `mapstructure:"SETTLE_INTERVAL"`
+	RetryBackoff       time.Duration `mapstructure:"RETRY_BACKOFF"`
+	MaxRetryBackoff    time.Duration `mapstructure:"MAX_RETRY_BACKOFF"`
+	AlertAfterAttempts int           `mapstructure:"ALERT_AFTER_ATTEMPTS"`
+	BatchSize          int           `mapstructure:"BATCH_SIZE"`
+	BatchInterval      time.Duration `mapstructure:"BATCH_INTERVAL"`
+}
+
+// StakePolicyConfig scales the stake a runner needs to start a task. The
+// requirement is MinStake plus RewardMultiplier times the task's reward, times
+// the multipliers of the task's type, size and the runner's reputation tier.
+// Types and Tiers are comma-separated lists such as "llm=0.5,docker=2"; Sizes
+// such as "10m=1,1h=2,6h=4" match the task's estimated duration in order.
+// With nothing set the flat MinStake applies.
+type StakePolicyConfig struct {
+	RewardMultiplier float64 `mapstructure:"REWARD_MULTIPLIER"`
+	Types            string  `mapstructure:"TYPES"`
+	Sizes            string  `mapstructure:"SIZES"`
+	Tiers            string  `mapstructure:"TIERS"`
+}
+
+// TaskTimeoutConfig caps how long a task may run. Types and Namespaces are
+// comma-separated lists such as "docker=1h,llm=10m"; a namespace limit takes
+// precedence over the limit for the task type.
+type TaskTimeoutConfig struct {
+	Default    time.Duration `mapstructure:"DEFAULT"`
+	Types      string        `mapstructure:"TYPES"`
+	Namespaces string        `mapstructure:"NAMESPACES"`
+}
+
+// SLOConfig sets the service level objectives the server exports burn rates for.
+// Objectives are the fraction of events that must be good, e.g. 0.99.
+type SLOConfig struct {
+	AssignmentLatency   time.Duration `mapstructure:"ASSIGNMENT_LATENCY"`
+	AssignmentObjective float64       `mapstructure:"ASSIGNMENT_OBJECTIVE"`
+	CompletionObjective float64       `mapstructure:"COMPLETION_OBJECTIVE"`
+	PayoutLatency       time.Duration `mapstructure:"PAYOUT_LATENCY"`
+	PayoutObjective     float64       `mapstructure:"PAYOUT_OBJECTIVE"`
 }
 
 type WebsocketConfig struct {
@@ -36,6 +163,31 @@ type BlockchainConfig struct {
 	TokenSymbol        string `mapstructure:"TOKEN_SYMBOL"`
 	TokenName          string `mapstructure:"TOKEN_NAME"`
 	NetworkName        string `mapstructure:"NETWORK_NAME"`
+	FaucetURL          string `mapstructure:"FAUCET_URL"`
+	// Chain names the registry chain in use, whose settings replace the ones
+	// above. Empty uses the settings above as they are.
+	Chain string `mapstructure:"CHAIN"`
+	// Chains is the chain registry, see loadChains
+	Chains map[string]Chain `mapstructure:"-"`
+	// Testnet is set when the chain in use is a test network
+	Testnet bool `mapstructure:"-"`
+	// Gas prices the transactions sent for payouts and withdrawals
+	Gas GasConfig `mapstructure:"GAS"`
+}
+
+// GasConfig caps and tunes transaction fees. Zero values use the defaults of
+// the gas package.
+type GasConfig struct {
+	// MaxFeeGwei caps the fee per gas, base fee and tip together. Zero leaves it
+	// uncapped.
+	MaxFeeGwei float64 `mapstructure:"MAX_FEE_GWEI"`
+	// MaxPriorityFeeGwei caps the tip per gas. Zero leaves it uncapped.
+	MaxPriorityFeeGwei float64       `mapstructure:"MAX_PRIORITY_FEE_GWEI"`
+	BaseFeeMultiplier  float64       `mapstructure:"BASE_FEE_MULTIPLIER"`
+	Headroom           float64       `mapstructure:"HEADROOM"`
+	BumpPercent        int           `mapstructure:"BUMP_PERCENT"`
+	ConfirmTimeout     time.Duration `mapstructure:"CONFIRM_TIMEOUT"`
+	Retries            int           `mapstructure:"RETRIES"`
 }
 
 type DatabaseConfig struct {
@@ -47,12 +199,256 @@ type SchedulerConfig struct {
 }
 
 type RunnerConfig struct {
-	ServerURL         string        `mapstructure:"SERVER_URL"`
-	WebhookPort       int           `mapstructure:"WEBHOOK_PORT"`
-	HeartbeatInterval time.Duration `mapstructure:"HEARTBEAT_INTERVAL"`
-	ExecutionTimeout  time.Duration `mapstructure:"EXECUTION_TIMEOUT"`
-	Docker            DockerConfig  `mapstructure:"DOCKER"`
-	Tunnel            TunnelConfig  `mapstructure:"TUNNEL"`
+	ServerURL          string           `mapstructure:"SERVER_URL"`
+	GRPCAddress        string           `mapstructure:"GRPC_ADDRESS"`
+	WebhookPort        int              `mapstructure:"WEBHOOK_PORT"`
+	WebhookRandomize   bool             `mapstructure:"WEBHOOK_RANDOMIZE"`
+	Dispatch           string           `mapstructure:"DISPATCH"`
+	HeartbeatInterval  time.Duration    `mapstructure:"HEARTBEAT_INTERVAL"`
+	ExecutionTimeout   time.Duration    `mapstructure:"EXECUTION_TIMEOUT"`
+	MaxConcurrentTasks int              `mapstructure:"MAX_CONCURRENT_TASKS"`
+	WorkerPool         WorkerPoolConfig `mapstructure:"WORKER_POOL"`
+	AcceptLabels       string           `mapstructure:"ACCEPT_LABELS"`
+	Policy             PolicyConfig     `mapstructure:"POLICY"`
+	ContainerRuntime   string           `mapstructure:"CONTAINER_RUNTIME"`
+	// SecretBackend is where the wallet key is kept: "file", an encrypted
+	// keystore under ~/.parity, or "keychain", the secret store of the OS
+	SecretBackend string `mapstructure:"SECRET_BACKEND"`
+	// DrainTimeout is how long a draining runner waits for running tasks
+	// before it exits, the watchdog's drain timeout when zero
+	DrainTimeout time.Duration `mapstructure:"DRAIN_TIMEOUT"`
+	// Region is shown to task creators with the tasks this runner claims
+	Region string `mapstructure:"REGION"`
+	// GangAddress is the host name or IP other members of a gang task reach
+	// this runner at, the address of the default route when empty
+	GangAddress  string             `mapstructure:"GANG_ADDRESS"`
+	Docker       DockerConfig       `mapstructure:"DOCKER"`
+	Wasm         WasmConfig         `mapstructure:"WASM"`
+	Firecracker  FirecrackerConfig  `mapstructure:"FIRECRACKER"`
+	TEE          TEEConfig          `mapstructure:"TEE"`
+	Tunnel       TunnelConfig       `mapstructure:"TUNNEL"`
+	Hooks        HooksConfig        `mapstructure:"HOOKS"`
+	Idle         IdleConfig         `mapstructure:"IDLE"`
+	Energy       EnergyConfig       `mapstructure:"ENERGY"`
+	Bidding      BiddingConfig      `mapstructure:"BIDDING"`
+	Chaos        ChaosConfig        `mapstructure:"CHAOS"`
+	Checkpoint   CheckpointConfig   `mapstructure:"CHECKPOINT"`
+	Artifacts    ArtifactsConfig    `mapstructure:"ARTIFACTS"`
+	DatasetCache DatasetCacheConfig `mapstructure:"DATASET_CACHE"`
+	IPFS         IPFSConfig         `mapstructure:"IPFS"`
+	ResultUpload ResultUploadConfig `mapstructure:"RESULT_UPLOAD"`
+	Federation   FederationConfig   `mapstructure:"FEDERATION"`
+	Supervisor   SupervisorConfig   `mapstructure:"SUPERVISOR"`
+	Telemetry    TelemetryConfig    `mapstructure:"TELEMETRY"`
+	Watchdog     WatchdogConfig     `mapstructure:"WATCHDOG"`
+	Diagnostics  DiagnosticsConfig  `mapstructure:"DIAGNOSTICS"`
+	LogStream    LogStreamConfig    `mapstructure:"LOG_STREAM"`
+}
+
+// LogStreamConfig controls pushing the output of running Docker tasks to the
+// server, batched every Interval, one second when zero
+type LogStreamConfig struct {
+	Disabled bool          `mapstructure:"DISABLED"`
+	Interval time.Duration `mapstructure:"INTERVAL"`
+}
+
+// DiagnosticsConfig shapes the diagnostic bundle attached to the result of a
+// failed task: LogLines of the container's log, 200 when zero, in at most
+// MaxBytes of JSON, 64 KiB when zero
+type DiagnosticsConfig struct {
+	Disabled bool `mapstructure:"DISABLED"`
+	LogLines int  `mapstructure:"LOG_LINES"`
+	MaxBytes int  `mapstructure:"MAX_BYTES"`
+}
+
+// WatchdogConfig limits the runner's own goroutines, heap and open files. When
+// one of them stays over its limit for Breaches checks in a row, diagnostics are
+// written and the runner stops taking tasks, waits up to DrainTimeout for
+// running ones and restarts. Zero values use the defaults.
+type WatchdogConfig struct {
+	Enabled       bool          `mapstructure:"ENABLED"`
+	Interval      time.Duration `mapstructure:"INTERVAL"`
+	MaxGoroutines int           `mapstructure:"MAX_GOROUTINES"`
+	MaxHeapMB     int           `mapstructure:"MAX_HEAP_MB"`
+	MaxOpenFiles  int           `mapstructure:"MAX_OPEN_FILES"`
+	Breaches      int           `mapstructure:"BREACHES"`
+	DrainTimeout  time.Duration `mapstructure:"DRAIN_TIMEOUT"`
+}
+
+// TelemetryConfig opts into sending anonymous usage statistics every Interval,
+// a day when zero, to Endpoint, or the telemetry API of the server when empty.
+// PARITY_NO_TELEMETRY or DO_NOT_TRACK turn telemetry off regardless.
+type TelemetryConfig struct {
+	Enabled  bool          `mapstructure:"ENABLED"`
+	Endpoint string        `mapstructure:"ENDPOINT"`
+	Interval time.Duration `mapstructure:"INTERVAL"`
+}
+
+// SupervisorConfig controls how the webhook server, heartbeat, tunnel and Ollama
+// are restarted when they die. A subsystem restarted MaxRestarts times within
+// Window restarts the whole runner. Zero values use the defaults.
+type SupervisorConfig struct {
+	CheckInterval time.Duration `mapstructure:"CHECK_INTERVAL"`
+	BaseBackoff   time.Duration `mapstructure:"BASE_BACKOFF"`
+	MaxBackoff    time.Duration `mapstructure:"MAX_BACKOFF"`
+	MaxRestarts   int           `mapstructure:"MAX_RESTARTS"`
+	Window        time.Duration `mapstructure:"WINDOW"`
+}
+
+// FederationConfig lists coordinators the runner takes work from besides
+// SERVER_URL, as comma separated URLs with an optional #<weight> suffix. Their
+// available tasks are polled every PollInterval and up to QueueSize of them
+// queued per coordinator.
+type FederationConfig struct {
+	Coordinators string        `mapstructure:"COORDINATORS"`
+	PollInterval time.Duration `mapstructure:"POLL_INTERVAL"`
+	QueueSize    int           `mapstructure:"QUEUE_SIZE"`
+}
+
+// ArtifactsConfig keeps the task, result and output of finished tasks on the
+// runner for Retention, which must be positive to keep anything. With Workspace
+// the working directory of Docker task containers is kept as well.
+type ArtifactsConfig struct {
+	Retention time.Duration `mapstructure:"RETENTION"`
+	Workspace bool          `mapstructure:"WORKSPACE"`
+}
+
+// IPFSConfig picks where the runner fetches task data and datasets and adds
+// uploaded checkpoints. Backend is "gateway" (fetch only), "kubo" (a node's RPC
+// API), "pinata" or "web3storage" (pinning services, which need Token); empty
+// keeps using IPFS_GATEWAY_URL and IPFS_API_URL. GatewayURL and APIURL override
+// the backend's endpoints.
+type IPFSConfig struct {
+	Backend    string `mapstructure:"BACKEND"`
+	GatewayURL string `mapstructure:"GATEWAY_URL"`
+	APIURL     string `mapstructure:"API_URL"`
+	Token      string `mapstructure:"TOKEN"`
+}
+
+// ResultUploadConfig makes the runner add task outputs larger than Threshold,
+// e.g. "4m", to its IPFS backend and submit only their CID and hash. Docker
+// tasks also get an output directory whose files are uploaded. Empty turns
+// uploads off.
+type ResultUploadConfig struct {
+	Threshold string `mapstructure:"THRESHOLD"`
+}
+
+// DatasetCacheConfig bounds the disk cache of datasets federated learning tasks
+// download from IPFS. An empty MaxSize keeps up to 10g and "0" turns the cache
+// off.
+type DatasetCacheConfig struct {
+	MaxSize string `mapstructure:"MAX_SIZE"`
+}
+
+// CheckpointConfig controls how checkpointed Docker tasks are saved. Mode
+// "snapshot", the default, commits the container filesystem; "criu" also saves
+// the process memory with docker checkpoint, which needs CRIU and the Docker
+// daemon's experimental features. With Upload, CRIU checkpoints are added to
+// IPFS and their CIDs reported in the task result.
+type CheckpointConfig struct {
+	Mode   string `mapstructure:"MODE"`
+	Upload bool   `mapstructure:"UPLOAD"`
+}
+
+// ChaosConfig injects faults for testing how the network copes with unreliable
+// runners. Rates are probabilities between 0 and 1; everything is off by default.
+type ChaosConfig struct {
+	WebhookDropRate    float64       `mapstructure:"WEBHOOK_DROP_RATE"`
+	HeartbeatDelay     time.Duration `mapstructure:"HEARTBEAT_DELAY"`
+	ContainerKillRate  float64       `mapstructure:"CONTAINER_KILL_RATE"`
+	ContainerKillAfter time.Duration `mapstructure:"CONTAINER_KILL_AFTER"`
+	ResultFailureRate  float64       `mapstructure:"RESULT_FAILURE_RATE"`
+	Seed               int64         `mapstructure:"SEED"`
+}
+
+// PolicyConfig limits the work a runner accepts. Each list field is
+// comma-separated: TaskTypes of task types, TrustedCreators of creator wallet
+// addresses, TrustedNamespaces of task namespaces, ImageRegistries of the
+// registries Docker task images may come from and BlockedImageTags of image
+// tags to refuse. RequireImageDigest only accepts images pinned to a digest.
+// BlockedImages are repository patterns to refuse and WorkingHours windows
+// such as "mon-fri 09:00-17:00" in local time. Empty lists accept everything.
+type PolicyConfig struct {
+	TaskTypes          string `mapstructure:"TASK_TYPES"`
+	TrustedCreators    string `mapstructure:"TRUSTED_CREATORS"`
+	TrustedNamespaces  string `mapstructure:"TRUSTED_NAMESPACES"`
+	ImageRegistries    string `mapstructure:"IMAGE_REGISTRIES"`
+	RequireImageDigest bool   `mapstructure:"REQUIRE_IMAGE_DIGEST"`
+	BlockedImageTags   string `mapstructure:"BLOCKED_IMAGE_TAGS"`
+	// MinRewards are type=reward pairs such as "docker=0.01,llm:llama3:8b=0.05"
+	MinRewards string `mapstructure:"MIN_REWARDS"`
+	// ImageSignatureKeys are comma separated cosign public keys, files or KMS
+	// URIs. ImageSignatureIdentity, a regular expression, and
+	// ImageSignatureIssuer accept keyless signatures as well.
+	ImageSignatureKeys     string `mapstructure:"IMAGE_SIGNATURE_KEYS"`
+	ImageSignatureIdentity string `mapstructure:"IMAGE_SIGNATURE_IDENTITY"`
+	ImageSignatureIssuer   string `mapstructure:"IMAGE_SIGNATURE_ISSUER"`
+	BlockedImages          string `mapstructure:"BLOCKED_IMAGES"`
+	// MaxDuration refuses tasks that may run longer or set no timeout
+	MaxDuration  time.Duration `mapstructure:"MAX_DURATION"`
+	WorkingHours string        `mapstructure:"WORKING_HOURS"`
+}
+
+// WorkerPoolConfig bounds what concurrent tasks may reserve in total. CPUs
+// defaults to the host's CPU count and an empty Memory leaves memory unreserved.
+// Tasks that do not request resources reserve TaskCPUs and TaskMemory.
+type WorkerPoolConfig struct {
+	QueueSize  int     `mapstructure:"QUEUE_SIZE"`
+	CPUs       float64 `mapstructure:"CPUS"`
+	Memory     string  `mapstructure:"MEMORY"`
+	TaskCPUs   float64 `mapstructure:"TASK_CPUS"`
+	TaskMemory string  `mapstructure:"TASK_MEMORY"`
+}
+
+// IdleConfig restricts a desktop runner to taking tasks while the host is idle
+type IdleConfig struct {
+	Enabled       bool          `mapstructure:"ENABLED"`
+	After         time.Duration `mapstructure:"AFTER"`
+	MinBattery    int           `mapstructure:"MIN_BATTERY"`
+	AllowMetered  bool          `mapstructure:"ALLOW_METERED"`
+	CheckInterval time.Duration `mapstructure:"CHECK_INTERVAL"`
+}
+
+// EnergyConfig declines tasks that pay less than the electricity they would use
+// while power costs PeakPrice or more. TokenPrice, which turns it on, is what a
+// reward token is worth in the currency of the prices per kWh. Prices come from
+// PriceURL when set, a JSON number or an object with the price in PriceField,
+// and otherwise from Tariff, comma separated HH:MM-HH:MM=price windows in local
+// time, with Price outside them. TaskKWh are class=kWh pairs; classes without
+// one are estimated from Watts and how long their tasks took.
+type EnergyConfig struct {
+	TokenPrice   float64       `mapstructure:"TOKEN_PRICE"`
+	PeakPrice    float64       `mapstructure:"PEAK_PRICE"`
+	Tariff       string        `mapstructure:"TARIFF"`
+	Price        float64       `mapstructure:"PRICE"`
+	PriceURL     string        `mapstructure:"PRICE_URL"`
+	PriceField   string        `mapstructure:"PRICE_FIELD"`
+	PriceRefresh time.Duration `mapstructure:"PRICE_REFRESH"`
+	Watts        float64       `mapstructure:"WATTS"`
+	TaskKWh      string        `mapstructure:"TASK_KWH"`
+}
+
+// BiddingConfig makes the runner bid for tasks on servers that auction them.
+// Tasks are priced at Base plus the CPUs, GB of memory and GPUs they reserve
+// for their timeout, or Runtime when they set none, with Margin added as a
+// share of that cost. Tasks whose reward does not cover the price are skipped.
+type BiddingConfig struct {
+	Enabled    bool          `mapstructure:"ENABLED"`
+	Base       float64       `mapstructure:"BASE"`
+	PerCPUHour float64       `mapstructure:"PER_CPU_HOUR"`
+	PerGBHour  float64       `mapstructure:"PER_GB_HOUR"`
+	PerGPUHour float64       `mapstructure:"PER_GPU_HOUR"`
+	Margin     float64       `mapstructure:"MARGIN"`
+	Runtime    time.Duration `mapstructure:"RUNTIME"`
+}
+
+// HooksConfig lists comma separated scripts or Go plugins (.so) run at each task lifecycle stage
+type HooksConfig struct {
+	PreClaim    string        `mapstructure:"PRE_CLAIM"`
+	PreExecute  string        `mapstructure:"PRE_EXECUTE"`
+	PostExecute string        `mapstructure:"POST_EXECUTE"`
+	PreSubmit   string        `mapstructure:"PRE_SUBMIT"`
+	Timeout     time.Duration `mapstructure:"TIMEOUT"`
 }
 
 type TunnelConfig struct {
@@ -63,15 +459,55 @@ type TunnelConfig struct {
 	Secret    string `mapstructure:"SECRET"`
 }
 
+// DockerConfig limits task containers. Host runs them on another Docker
+// daemon, such as tcp://gpu-box:2376, checked with the TLS certificates in
+// CertPath when TLSVerify is set. Without it DOCKER_HOST is used.
 type DockerConfig struct {
 	MemoryLimit string        `mapstructure:"MEMORY_LIMIT"`
 	CPULimit    string        `mapstructure:"CPU_LIMIT"`
 	Timeout     time.Duration `mapstructure:"TIMEOUT"`
+	Host        string        `mapstructure:"HOST"`
+	TLSVerify   bool          `mapstructure:"TLS_VERIFY"`
+	CertPath    string        `mapstructure:"CERT_PATH"`
+	// GCInterval is how often task containers and files no task uses are
+	// removed after the sweep at startup. Zero uses an hour, a negative value
+	// only sweeps at startup.
+	GCInterval time.Duration `mapstructure:"GC_INTERVAL"`
+	// FaketimeLibrary is the path of libfaketime.so.1, which pins the clock of
+	// deterministic tasks
+	FaketimeLibrary string `mapstructure:"FAKETIME_LIBRARY"`
+}
+
+// WasmConfig caps the linear memory and fuel, counted in function calls, of
+// wasm tasks. Tasks may ask for less.
+type WasmConfig struct {
+	MemoryLimit string `mapstructure:"MEMORY_LIMIT"`
+	Fuel        uint64 `mapstructure:"FUEL"`
+}
+
+// FirecrackerConfig runs Docker tasks that ask for VM isolation in Firecracker
+// microVMs, booting KernelImage with RootFS. Runners without it skip those tasks.
+type FirecrackerConfig struct {
+	Enabled     bool   `mapstructure:"ENABLED"`
+	Binary      string `mapstructure:"BINARY"`
+	KernelImage string `mapstructure:"KERNEL_IMAGE"`
+	RootFS      string `mapstructure:"ROOTFS"`
+	VCPUs       int    `mapstructure:"VCPUS"`
+	MemoryMiB   int    `mapstructure:"MEMORY_MIB"`
+}
+
+// TEEConfig runs tasks that ask for TEE isolation and attests their results. The
+// runner has to be deployed in a SEV-SNP or TDX confidential VM or a Gramine
+// SGX enclave, which it detects.
+type TEEConfig struct {
+	Enabled bool `mapstructure:"ENABLED"`
 }
 
 type ConfigManager struct {
 	config     *Config
 	configPath string
+	network    string
+	chain      string
 	mutex      sync.RWMutex
 }
 
@@ -84,6 +520,7 @@ func GetConfigManager() *ConfigManager {
 	once.Do(func() {
 		instance = &ConfigManager{
 			configPath: ".env",
+			network:    NetworkMainnet,
 		}
 	})
 	return instance
@@ -96,6 +533,34 @@ func (cm *ConfigManager) SetConfigPath(path string) {
 	cm.config = nil
 }
 
+// SetNetwork selects the network whose defaults apply to the next load
+func (cm *ConfigManager) SetNetwork(network string) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	cm.network = network
+	cm.config = nil
+}
+
+func (cm *ConfigManager) GetNetwork() string {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.network
+}
+
+// SetChain selects the registry chain of the next load over BLOCKCHAIN_CHAIN
+func (cm *ConfigManager) SetChain(chain string) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	cm.chain = chain
+	cm.config = nil
+}
+
+func (cm *ConfigManager) GetChain() string {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.chain
+}
+
 func (cm *ConfigManager) GetConfig() (*Config, error) {
 	cm.mutex.RLock()
 	if cm.config != nil {
@@ -112,13 +577,17 @@ func (cm *ConfigManager) GetConfig() (*Config, error) {
 	}
 
 	var err error
-	cm.config, err = loadConfigFile(cm.configPath)
+	cm.config, err = loadConfigFile(cm.configPath, cm.network, cm.chain)
 	return cm.config, err
 }
 
-func loadConfigFile(path string) (*Config, error) {
+func loadConfigFile(path, network, chain string) (*Config, error) {
 	v := viper.New()
 
+	for key, value := range networkDefaults[network] {
+		v.SetDefault(key, value)
+	}
+
 	v.SetConfigFile(path)
 	v.SetEnvPrefix("")
 	v.AutomaticEnv()
@@ -129,14 +598,79 @@ func loadConfigFile(path string) (*Config, error) {
 	}
 
 	v.SetDefault("SERVER", map[string]interface{}{
-		"HOST":     v.GetString("SERVER_HOST"),
-		"PORT":     v.GetString("SERVER_PORT"),
-		"ENDPOINT": v.GetString("SERVER_ENDPOINT"),
+		"HOST":        v.GetString("SERVER_HOST"),
+		"PORT":        v.GetString("SERVER_PORT"),
+		"GRPC_PORT":   v.GetString("SERVER_GRPC_PORT"),
+		"ENDPOINT":    v.GetString("SERVER_ENDPOINT"),
+		"PRIVATE_KEY": v.GetString("SERVER_PRIVATE_KEY"),
+		"MIN_STAKE":   v.GetFloat64("SERVER_MIN_STAKE"),
+		"ADMIN_TOKEN": v.GetString("SERVER_ADMIN_TOKEN"),
 		"WEBSOCKET": map[string]interface{}{
 			"WRITE_WAIT":       v.GetDuration("SERVER_WEBSOCKET_WRITE_WAIT"),
 			"PONG_WAIT":        v.GetDuration("SERVER_WEBSOCKET_PONG_WAIT"),
 			"MAX_MESSAGE_SIZE": v.GetInt64("SERVER_WEBSOCKET_MAX_MESSAGE_SIZE"),
 		},
+		"SLO": map[string]interface{}{
+			"ASSIGNMENT_LATENCY":   v.GetDuration("SERVER_SLO_ASSIGNMENT_LATENCY"),
+			"ASSIGNMENT_OBJECTIVE": v.GetFloat64("SERVER_SLO_ASSIGNMENT_OBJECTIVE"),
+			"COMPLETION_OBJECTIVE": v.GetFloat64("SERVER_SLO_COMPLETION_OBJECTIVE"),
+			"PAYOUT_LATENCY":       v.GetDuration("SERVER_SLO_PAYOUT_LATENCY"),
+			"PAYOUT_OBJECTIVE":     v.GetFloat64("SERVER_SLO_PAYOUT_OBJECTIVE"),
+		},
+		"TASK_TIMEOUTS": map[string]interface{}{
+			"DEFAULT":    v.GetDuration("SERVER_TASK_TIMEOUTS_DEFAULT"),
+			"TYPES":      v.GetString("SERVER_TASK_TIMEOUTS_TYPES"),
+			"NAMESPACES": v.GetString("SERVER_TASK_TIMEOUTS_NAMESPACES"),
+		},
+		"PRIVACY": map[string]interface{}{
+			"ENCRYPTION_KEY":    v.GetString("SERVER_PRIVACY_ENCRYPTION_KEY"),
+			"ENCRYPTION_KEY_ID": v.GetString("SERVER_PRIVACY_ENCRYPTION_KEY_ID"),
+			"SCRUB_RULES":       v.GetString("SERVER_PRIVACY_SCRUB_RULES"),
+			"SCRUB_PATTERN":     v.GetString("SERVER_PRIVACY_SCRUB_PATTERN"),
+		},
+		"RESULT_HOOK": map[string]interface{}{
+			"URL":         v.GetString("SERVER_RESULT_HOOK_URL"),
+			"TIMEOUT":     v.GetDuration("SERVER_RESULT_HOOK_TIMEOUT"),
+			"FAIL_CLOSED": v.GetBool("SERVER_RESULT_HOOK_FAIL_CLOSED"),
+		},
+		"CANARY": map[string]interface{}{
+			"INTERVAL":     v.GetDuration("SERVER_CANARY_INTERVAL"),
+			"DOCKER_IMAGE": v.GetString("SERVER_CANARY_DOCKER_IMAGE"),
+			"LLM_MODEL":    v.GetString("SERVER_CANARY_LLM_MODEL"),
+		},
+		"QUARANTINE": map[string]interface{}{
+			"THRESHOLD":          v.GetInt("SERVER_QUARANTINE_THRESHOLD"),
+			"WINDOW":             v.GetDuration("SERVER_QUARANTINE_WINDOW"),
+			"PROBATION":          v.GetDuration("SERVER_QUARANTINE_PROBATION"),
+			"PROBATION_CANARIES": v.GetInt("SERVER_QUARANTINE_PROBATION_CANARIES"),
+		},
+		"PRICING": map[string]interface{}{
+			"BASE_REWARD":           v.GetFloat64("SERVER_PRICING_BASE_REWARD"),
+			"PER_RUNTIME_SECOND":    v.GetFloat64("SERVER_PRICING_PER_RUNTIME_SECOND"),
+			"PER_IMAGE_GB":          v.GetFloat64("SERVER_PRICING_PER_IMAGE_GB"),
+			"MODEL_REWARDS":         v.GetString("SERVER_PRICING_MODEL_REWARDS"),
+			"WINDOW":                v.GetDuration("SERVER_PRICING_WINDOW"),
+			"MAX_DEMAND_MULTIPLIER": v.GetFloat64("SERVER_PRICING_MAX_DEMAND_MULTIPLIER"),
+			"ENFORCE_FLOOR":         v.GetBool("SERVER_PRICING_ENFORCE_FLOOR"),
+		},
+		"STAKE_POLICY": map[string]interface{}{
+			"REWARD_MULTIPLIER": v.GetFloat64("SERVER_STAKE_POLICY_REWARD_MULTIPLIER"),
+			"TYPES":             v.GetString("SERVER_STAKE_POLICY_TYPES"),
+			"SIZES":             v.GetString("SERVER_STAKE_POLICY_SIZES"),
+			"TIERS":             v.GetString("SERVER_STAKE_POLICY_TIERS"),
+		},
+		"CHAIN": map[string]interface{}{
+			"STAKE_FRESH_TTL":      v.GetDuration("SERVER_CHAIN_STAKE_FRESH_TTL"),
+			"STAKE_CACHE_TTL":      v.GetDuration("SERVER_CHAIN_STAKE_CACHE_TTL"),
+			"STAKE_WATCH_INTERVAL": v.GetDuration("SERVER_CHAIN_STAKE_WATCH_INTERVAL"),
+			"PAYOUT_QUEUE_DIR":     v.GetString("SERVER_CHAIN_PAYOUT_QUEUE_DIR"),
+			"SETTLE_INTERVAL":      v.GetDuration("SERVER_CHAIN_SETTLE_INTERVAL"),
+			"RETRY_BACKOFF":        v.GetDuration("SERVER_CHAIN_RETRY_BACKOFF"),
+			"MAX_RETRY_BACKOFF":    v.GetDuration("SERVER_CHAIN_MAX_RETRY_BACKOFF"),
+			"ALERT_AFTER_ATTEMPTS": v.GetInt("SERVER_CHAIN_ALERT_AFTER_ATTEMPTS"),
+			"BATCH_SIZE":           v.GetInt("SERVER_CHAIN_BATCH_SIZE"),
+			"BATCH_INTERVAL":       v.GetDuration("SERVER_CHAIN_BATCH_INTERVAL"),
+		},
 	})
 
 	v.SetDefault("BLOCKCHAIN", map[string]interface{}{
@@ -147,17 +681,80 @@ func loadConfigFile(path string) (*Config, error) {
 		"TOKEN_SYMBOL":         v.GetString("BLOCKCHAIN_TOKEN_SYMBOL"),
 		"TOKEN_NAME":           v.GetString("BLOCKCHAIN_TOKEN_NAME"),
 		"NETWORK_NAME":         v.GetString("BLOCKCHAIN_NETWORK_NAME"),
+		"FAUCET_URL":           v.GetString("BLOCKCHAIN_FAUCET_URL"),
+		"CHAIN":                v.GetString("BLOCKCHAIN_CHAIN"),
+		"GAS": map[string]interface{}{
+			"MAX_FEE_GWEI":          v.GetFloat64("BLOCKCHAIN_GAS_MAX_FEE_GWEI"),
+			"MAX_PRIORITY_FEE_GWEI": v.GetFloat64("BLOCKCHAIN_GAS_MAX_PRIORITY_FEE_GWEI"),
+			"BASE_FEE_MULTIPLIER":   v.GetFloat64("BLOCKCHAIN_GAS_BASE_FEE_MULTIPLIER"),
+			"HEADROOM":              v.GetFloat64("BLOCKCHAIN_GAS_HEADROOM"),
+			"BUMP_PERCENT":          v.GetInt("BLOCKCHAIN_GAS_BUMP_PERCENT"),
+			"CONFIRM_TIMEOUT":       v.GetDuration("BLOCKCHAIN_GAS_CONFIRM_TIMEOUT"),
+			"RETRIES":               v.GetInt("BLOCKCHAIN_GAS_RETRIES"),
+		},
 	})
 
 	v.SetDefault("RUNNER", map[string]interface{}{
-		"SERVER_URL":         v.GetString("RUNNER_SERVER_URL"),
-		"WEBHOOK_PORT":       v.GetInt("RUNNER_WEBHOOK_PORT"),
-		"HEARTBEAT_INTERVAL": v.GetDuration("RUNNER_HEARTBEAT_INTERVAL"),
-		"EXECUTION_TIMEOUT":  v.GetDuration("RUNNER_EXECUTION_TIMEOUT"),
+		"SERVER_URL":           v.GetString("RUNNER_SERVER_URL"),
+		"GRPC_ADDRESS":         v.GetString("RUNNER_GRPC_ADDRESS"),
+		"WEBHOOK_PORT":         v.GetInt("RUNNER_WEBHOOK_PORT"),
+		"WEBHOOK_RANDOMIZE":    v.GetBool("RUNNER_WEBHOOK_RANDOMIZE"),
+		"DISPATCH":             v.GetString("RUNNER_DISPATCH"),
+		"HEARTBEAT_INTERVAL":   v.GetDuration("RUNNER_HEARTBEAT_INTERVAL"),
+		"EXECUTION_TIMEOUT":    v.GetDuration("RUNNER_EXECUTION_TIMEOUT"),
+		"MAX_CONCURRENT_TASKS": v.GetInt("RUNNER_MAX_CONCURRENT_TASKS"),
+		"WORKER_POOL": map[string]interface{}{
+			"QUEUE_SIZE":  v.GetInt("RUNNER_WORKER_POOL_QUEUE_SIZE"),
+			"CPUS":        v.GetFloat64("RUNNER_WORKER_POOL_CPUS"),
+			"MEMORY":      v.GetString("RUNNER_WORKER_POOL_MEMORY"),
+			"TASK_CPUS":   v.GetFloat64("RUNNER_WORKER_POOL_TASK_CPUS"),
+			"TASK_MEMORY": v.GetString("RUNNER_WORKER_POOL_TASK_MEMORY"),
+		},
+		"ACCEPT_LABELS": v.GetString("RUNNER_ACCEPT_LABELS"),
+		"REGION":        v.GetString("RUNNER_REGION"),
+		"POLICY": map[string]interface{}{
+			"TASK_TYPES":               v.GetString("RUNNER_POLICY_TASK_TYPES"),
+			"TRUSTED_CREATORS":         v.GetString("RUNNER_POLICY_TRUSTED_CREATORS"),
+			"TRUSTED_NAMESPACES":       v.GetString("RUNNER_POLICY_TRUSTED_NAMESPACES"),
+			"IMAGE_REGISTRIES":         v.GetString("RUNNER_POLICY_IMAGE_REGISTRIES"),
+			"REQUIRE_IMAGE_DIGEST":     v.GetBool("RUNNER_POLICY_REQUIRE_IMAGE_DIGEST"),
+			"BLOCKED_IMAGE_TAGS":       v.GetString("RUNNER_POLICY_BLOCKED_IMAGE_TAGS"),
+			"MIN_REWARDS":              v.GetString("RUNNER_POLICY_MIN_REWARDS"),
+			"IMAGE_SIGNATURE_KEYS":     v.GetString("RUNNER_POLICY_IMAGE_SIGNATURE_KEYS"),
+			"IMAGE_SIGNATURE_IDENTITY": v.GetString("RUNNER_POLICY_IMAGE_SIGNATURE_IDENTITY"),
+			"IMAGE_SIGNATURE_ISSUER":   v.GetString("RUNNER_POLICY_IMAGE_SIGNATURE_ISSUER"),
+			"BLOCKED_IMAGES":           v.GetString("RUNNER_POLICY_BLOCKED_IMAGES"),
+			"MAX_DURATION":             v.GetDuration("RUNNER_POLICY_MAX_DURATION"),
+			"WORKING_HOURS":            v.GetString("RUNNER_POLICY_WORKING_HOURS"),
+		},
+		"CONTAINER_RUNTIME": v.GetString("RUNNER_CONTAINER_RUNTIME"),
+		"GANG_ADDRESS":      v.GetString("RUNNER_GANG_ADDRESS"),
+		"SECRET_BACKEND":    v.GetString("RUNNER_SECRET_BACKEND"),
+		"DRAIN_TIMEOUT":     v.GetDuration("RUNNER_DRAIN_TIMEOUT"),
 		"DOCKER": map[string]interface{}{
-			"MEMORY_LIMIT": v.GetString("RUNNER_DOCKER_MEMORY_LIMIT"),
-			"CPU_LIMIT":    v.GetString("RUNNER_DOCKER_CPU_LIMIT"),
-			"TIMEOUT":      v.GetDuration("RUNNER_DOCKER_TIMEOUT"),
+			"MEMORY_LIMIT":     v.GetString("RUNNER_DOCKER_MEMORY_LIMIT"),
+			"CPU_LIMIT":        v.GetString("RUNNER_DOCKER_CPU_LIMIT"),
+			"TIMEOUT":          v.GetDuration("RUNNER_DOCKER_TIMEOUT"),
+			"HOST":             v.GetString("RUNNER_DOCKER_HOST"),
+			"TLS_VERIFY":       v.GetBool("RUNNER_DOCKER_TLS_VERIFY"),
+			"CERT_PATH":        v.GetString("RUNNER_DOCKER_CERT_PATH"),
+			"GC_INTERVAL":      v.GetDuration("RUNNER_DOCKER_GC_INTERVAL"),
+			"FAKETIME_LIBRARY": v.GetString("RUNNER_DOCKER_FAKETIME_LIBRARY"),
+		},
+		"WASM": map[string]interface{}{
+			"MEMORY_LIMIT": v.GetString("RUNNER_WASM_MEMORY_LIMIT"),
+			"FUEL":         v.GetUint64("RUNNER_WASM_FUEL"),
+		},
+		"FIRECRACKER": map[string]interface{}{
+			"ENABLED":      v.GetBool("RUNNER_FIRECRACKER_ENABLED"),
+			"BINARY":       v.GetString("RUNNER_FIRECRACKER_BINARY"),
+			"KERNEL_IMAGE": v.GetString("RUNNER_FIRECRACKER_KERNEL_IMAGE"),
+			"ROOTFS":       v.GetString("RUNNER_FIRECRACKER_ROOTFS"),
+			"VCPUS":        v.GetInt("RUNNER_FIRECRACKER_VCPUS"),
+			"MEMORY_MIB":   v.GetInt("RUNNER_FIRECRACKER_MEMORY_MIB"),
+		},
+		"TEE": map[string]interface{}{
+			"ENABLED": v.GetBool("RUNNER_TEE_ENABLED"),
 		},
 		"TUNNEL": map[string]interface{}{
 			"ENABLED":    v.GetBool("RUNNER_TUNNEL_ENABLED"),
@@ -166,6 +763,103 @@ func loadConfigFile(path string) (*Config, error) {
 			"PORT":       v.GetInt("RUNNER_TUNNEL_PORT"),
 			"SECRET":     v.GetString("RUNNER_TUNNEL_SECRET"),
 		},
+		"HOOKS": map[string]interface{}{
+			"PRE_CLAIM":    v.GetString("RUNNER_HOOKS_PRE_CLAIM"),
+			"PRE_EXECUTE":  v.GetString("RUNNER_HOOKS_PRE_EXECUTE"),
+			"POST_EXECUTE": v.GetString("RUNNER_HOOKS_POST_EXECUTE"),
+			"PRE_SUBMIT":   v.GetString("RUNNER_HOOKS_PRE_SUBMIT"),
+			"TIMEOUT":      v.GetDuration("RUNNER_HOOKS_TIMEOUT"),
+		},
+		"IDLE": map[string]interface{}{
+			"ENABLED":        v.GetBool("RUNNER_IDLE_ENABLED"),
+			"AFTER":          v.GetDuration("RUNNER_IDLE_AFTER"),
+			"MIN_BATTERY":    v.GetInt("RUNNER_IDLE_MIN_BATTERY"),
+			"ALLOW_METERED":  v.GetBool("RUNNER_IDLE_ALLOW_METERED"),
+			"CHECK_INTERVAL": v.GetDuration("RUNNER_IDLE_CHECK_INTERVAL"),
+		},
+		"ENERGY": map[string]interface{}{
+			"TOKEN_PRICE":   v.GetFloat64("RUNNER_ENERGY_TOKEN_PRICE"),
+			"PEAK_PRICE":    v.GetFloat64("RUNNER_ENERGY_PEAK_PRICE"),
+			"TARIFF":        v.GetString("RUNNER_ENERGY_TARIFF"),
+			"PRICE":         v.GetFloat64("RUNNER_ENERGY_PRICE"),
+			"PRICE_URL":     v.GetString("RUNNER_ENERGY_PRICE_URL"),
+			"PRICE_FIELD":   v.GetString("RUNNER_ENERGY_PRICE_FIELD"),
+			"PRICE_REFRESH": v.GetDuration("RUNNER_ENERGY_PRICE_REFRESH"),
+			"WATTS":         v.GetFloat64("RUNNER_ENERGY_WATTS"),
+			"TASK_KWH":      v.GetString("RUNNER_ENERGY_TASK_KWH"),
+		},
+		"BIDDING": map[string]interface{}{
+			"ENABLED":      v.GetBool("RUNNER_BIDDING_ENABLED"),
+			"BASE":         v.GetFloat64("RUNNER_BIDDING_BASE"),
+			"PER_CPU_HOUR": v.GetFloat64("RUNNER_BIDDING_PER_CPU_HOUR"),
+			"PER_GB_HOUR":  v.GetFloat64("RUNNER_BIDDING_PER_GB_HOUR"),
+			"PER_GPU_HOUR": v.GetFloat64("RUNNER_BIDDING_PER_GPU_HOUR"),
+			"MARGIN":       v.GetFloat64("RUNNER_BIDDING_MARGIN"),
+			"RUNTIME":      v.GetDuration("RUNNER_BIDDING_RUNTIME"),
+		},
+		"CHAOS": map[string]interface{}{
+			"WEBHOOK_DROP_RATE":    v.GetFloat64("RUNNER_CHAOS_WEBHOOK_DROP_RATE"),
+			"HEARTBEAT_DELAY":      v.GetDuration("RUNNER_CHAOS_HEARTBEAT_DELAY"),
+			"CONTAINER_KILL_RATE":  v.GetFloat64("RUNNER_CHAOS_CONTAINER_KILL_RATE"),
+			"CONTAINER_KILL_AFTER": v.GetDuration("RUNNER_CHAOS_CONTAINER_KILL_AFTER"),
+			"RESULT_FAILURE_RATE":  v.GetFloat64("RUNNER_CHAOS_RESULT_FAILURE_RATE"),
+			"SEED":                 v.GetInt64("RUNNER_CHAOS_SEED"),
+		},
+		"CHECKPOINT": map[string]interface{}{
+			"MODE":   v.GetString("RUNNER_CHECKPOINT_MODE"),
+			"UPLOAD": v.GetBool("RUNNER_CHECKPOINT_UPLOAD"),
+		},
+		"ARTIFACTS": map[string]interface{}{
+			"RETENTION": v.GetDuration("RUNNER_ARTIFACTS_RETENTION"),
+			"WORKSPACE": v.GetBool("RUNNER_ARTIFACTS_WORKSPACE"),
+		},
+		"DATASET_CACHE": map[string]interface{}{
+			"MAX_SIZE": v.GetString("RUNNER_DATASET_CACHE_MAX_SIZE"),
+		},
+		"IPFS": map[string]interface{}{
+			"BACKEND":     v.GetString("RUNNER_IPFS_BACKEND"),
+			"GATEWAY_URL": v.GetString("RUNNER_IPFS_GATEWAY_URL"),
+			"API_URL":     v.GetString("RUNNER_IPFS_API_URL"),
+			"TOKEN":       v.GetString("RUNNER_IPFS_TOKEN"),
+		},
+		"RESULT_UPLOAD": map[string]interface{}{
+			"THRESHOLD": v.GetString("RUNNER_RESULT_UPLOAD_THRESHOLD"),
+		},
+		"FEDERATION": map[string]interface{}{
+			"COORDINATORS":  v.GetString("RUNNER_FEDERATION_COORDINATORS"),
+			"POLL_INTERVAL": v.GetDuration("RUNNER_FEDERATION_POLL_INTERVAL"),
+			"QUEUE_SIZE":    v.GetInt("RUNNER_FEDERATION_QUEUE_SIZE"),
+		},
+		"SUPERVISOR": map[string]interface{}{
+			"CHECK_INTERVAL": v.GetDuration("RUNNER_SUPERVISOR_CHECK_INTERVAL"),
+			"BASE_BACKOFF":   v.GetDuration("RUNNER_SUPERVISOR_BASE_BACKOFF"),
+			"MAX_BACKOFF":    v.GetDuration("RUNNER_SUPERVISOR_MAX_BACKOFF"),
+			"MAX_RESTARTS":   v.GetInt("RUNNER_SUPERVISOR_MAX_RESTARTS"),
+			"WINDOW":         v.GetDuration("RUNNER_SUPERVISOR_WINDOW"),
+		},
+		"TELEMETRY": map[string]interface{}{
+			"ENABLED":  v.GetBool("RUNNER_TELEMETRY_ENABLED"),
+			"ENDPOINT": v.GetString("RUNNER_TELEMETRY_ENDPOINT"),
+			"INTERVAL": v.GetDuration("RUNNER_TELEMETRY_INTERVAL"),
+		},
+		"WATCHDOG": map[string]interface{}{
+			"ENABLED":        v.GetBool("RUNNER_WATCHDOG_ENABLED"),
+			"INTERVAL":       v.GetDuration("RUNNER_WATCHDOG_INTERVAL"),
+			"MAX_GOROUTINES": v.GetInt("RUNNER_WATCHDOG_MAX_GOROUTINES"),
+			"MAX_HEAP_MB":    v.GetInt("RUNNER_WATCHDOG_MAX_HEAP_MB"),
+			"MAX_OPEN_FILES": v.GetInt("RUNNER_WATCHDOG_MAX_OPEN_FILES"),
+			"BREACHES":       v.GetInt("RUNNER_WATCHDOG_BREACHES"),
+			"DRAIN_TIMEOUT":  v.GetDuration("RUNNER_WATCHDOG_DRAIN_TIMEOUT"),
+		},
+		"DIAGNOSTICS": map[string]interface{}{
+			"DISABLED":  v.GetBool("RUNNER_DIAGNOSTICS_DISABLED"),
+			"LOG_LINES": v.GetInt("RUNNER_DIAGNOSTICS_LOG_LINES"),
+			"MAX_BYTES": v.GetInt("RUNNER_DIAGNOSTICS_MAX_BYTES"),
+		},
+		"LOG_STREAM": map[string]interface{}{
+			"DISABLED": v.GetBool("RUNNER_LOG_STREAM_DISABLED"),
+			"INTERVAL": v.GetDuration("RUNNER_LOG_STREAM_INTERVAL"),
+		},
 	})
 
 	var config Config
@@ -178,6 +872,29 @@ func loadConfigFile(path string) (*Config, error) {
 		config.Runner.HeartbeatInterval = 30 * time.Second
 	}
 
+	config.Network = network
+	if config.Network == "" {
+		config.Network = NetworkMainnet
+	}
+	if err := config.validateNetwork(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if config.Blockchain.Chains, err = loadChains(v, config.Blockchain); err != nil {
+		return nil, err
+	}
+	if chain == "" {
+		if chain, err = ParseChainName(config.Blockchain.Chain); err != nil {
+			return nil, err
+		}
+	}
+	if chain != "" {
+		if err := config.UseChain(chain); err != nil {
+			return nil, err
+		}
+	}
+
 	return &config, nil
 }
 
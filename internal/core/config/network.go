@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	NetworkMainnet = "mainnet"
+	NetworkTestnet = "testnet"
+)
+
+// networkDefaults sit below the config file and environment, so a testnet runner
+// works with little more than contract addresses configured
+var networkDefaults = map[string]map[string]interface{}{
+	NetworkTestnet: {
+		"BLOCKCHAIN_RPC":          "https://rpc.sepolia.org",
+		"BLOCKCHAIN_CHAIN_ID":     11155111,
+		"BLOCKCHAIN_TOKEN_SYMBOL": "tPRTY",
+		"BLOCKCHAIN_TOKEN_NAME":   "Parity Test Token",
+		"BLOCKCHAIN_NETWORK_NAME": "Sepolia",
+	},
+}
+
+// ParseNetwork normalizes a network name, treating an empty name as mainnet
+func ParseNetwork(name string) (string, error) {
+	switch network := strings.ToLower(strings.TrimSpace(name)); network {
+	case "", NetworkMainnet:
+		return NetworkMainnet, nil
+	case NetworkTestnet:
+		return NetworkTestnet, nil
+	default:
+		return "", fmt.Errorf("unknown network %q: use %s or %s", name, NetworkMainnet, NetworkTestnet)
+	}
+}
+
+// mainnetChainID is Ethereum mainnet, which a testnet config must never point at
+const mainnetChainID = 1
+
+func (c *Config) IsTestnet() bool {
+	return c.Network == NetworkTestnet
+}
+
+// validateNetwork stops a testnet runner from signing transactions on mainnet
+// because of a copied config file
+func (c *Config) validateNetwork() error {
+	if c.IsTestnet() && c.Blockchain.ChainID == mainnetChainID {
+		return fmt.Errorf("testnet configuration uses mainnet chain ID %d", c.Blockchain.ChainID)
+	}
+	return nil
+}
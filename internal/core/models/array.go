@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// DefaultArrayAttempts is how often an index is run before it fails for good
+	DefaultArrayAttempts = 3
+	maxArraySize         = 100000
+)
+
+// ArrayConfig runs a Docker task once for every index from 0 to Size-1, the
+// usual way to fan out embarrassingly parallel work. Each index runs as a task
+// of its own that learns its index from PARITY_ARRAY_INDEX. The server only
+// creates the tasks of the indices it queues, so large arrays cost nothing
+// until they run.
+type ArrayConfig struct {
+	Size int `json:"size"`
+	// Parallelism caps how many indices are queued or running at once, all of
+	// them when zero
+	Parallelism int `json:"parallelism,omitempty"`
+	MaxAttempts int `json:"max_attempts,omitempty"`
+}
+
+func (a *ArrayConfig) Validate() error {
+	if a.Size < 1 || a.Size > maxArraySize {
+		return fmt.Errorf("array size must be between 1 and %d", maxArraySize)
+	}
+	if a.Parallelism < 0 {
+		return errors.New("array parallelism cannot be negative")
+	}
+	if a.MaxAttempts < 0 {
+		return errors.New("array max_attempts cannot be negative")
+	}
+	return nil
+}
+
+// Slots is how many indices may be queued or running at once
+func (a *ArrayConfig) Slots() int {
+	if a.Parallelism > 0 && a.Parallelism < a.Size {
+		return a.Parallelism
+	}
+	return a.Size
+}
+
+func (a *ArrayConfig) Attempts() int {
+	if a.MaxAttempts > 0 {
+		return a.MaxAttempts
+	}
+	return DefaultArrayAttempts
+}
+
+type ArrayIndexState string
+
+const (
+	// ArrayIndexPending has no task yet. It gets one when a slot frees up.
+	ArrayIndexPending   ArrayIndexState = "pending"
+	ArrayIndexQueued    ArrayIndexState = "queued"
+	ArrayIndexRunning   ArrayIndexState = "running"
+	ArrayIndexCompleted ArrayIndexState = "completed"
+	// ArrayIndexFailed failed on every attempt. It runs again when retried.
+	ArrayIndexFailed ArrayIndexState = "failed"
+)
+
+// ArrayIndex is one index of an array and the task of its latest attempt
+type ArrayIndex struct {
+	Index    int             `json:"index"`
+	State    ArrayIndexState `json:"state"`
+	Attempts int             `json:"attempts"`
+	TaskID   string          `json:"task_id,omitempty"`
+	DeviceID string          `json:"device_id,omitempty"`
+	ExitCode int             `json:"exit_code,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// ArrayStatus is the progress of an array. Indices are only listed on request.
+type ArrayStatus struct {
+	ArrayID       string                  `json:"array_id"`
+	Size          int                     `json:"size"`
+	Counts        map[ArrayIndexState]int `json:"counts"`
+	Done          bool                    `json:"done"`
+	FailedIndices []int                   `json:"failed_indices,omitempty"`
+	Indices       []ArrayIndex            `json:"indices,omitempty"`
+}
+
+// ArrayRetry runs failed indices of an array again, every failed index when
+// Indices is empty
+type ArrayRetry struct {
+	Indices []int `json:"indices,omitempty"`
+}
+
+// ArrayEnv tells the task of an index which index it runs
+func ArrayEnv(arrayID string, index, size int) map[string]string {
+	return map[string]string{
+		"PARITY_ARRAY_ID":    arrayID,
+		"PARITY_ARRAY_INDEX": strconv.Itoa(index),
+		"PARITY_ARRAY_SIZE":  strconv.Itoa(size),
+	}
+}
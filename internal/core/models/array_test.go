@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestArrayConfigValidate(t *testing.T) {
+	valid := ArrayConfig{Size: 1000, Parallelism: 50}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid array refused: %v", err)
+	}
+	if valid.Slots() != 50 || valid.Attempts() != DefaultArrayAttempts {
+		t.Errorf("slots = %d, attempts = %d", valid.Slots(), valid.Attempts())
+	}
+	if unlimited := (ArrayConfig{Size: 10}); unlimited.Slots() != 10 {
+		t.Errorf("slots without parallelism = %d, want the array size", unlimited.Slots())
+	}
+
+	for name, config := range map[string]ArrayConfig{
+		"empty":       {Size: 0},
+		"too large":   {Size: maxArraySize + 1},
+		"parallelism": {Size: 2, Parallelism: -1},
+		"attempts":    {Size: 2, MaxAttempts: -1},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: expected the array to be refused", name)
+		}
+	}
+}
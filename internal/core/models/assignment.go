@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// ReputationTier buckets a runner's reputation for showing to task creators
+type ReputationTier string
+
+const (
+	// ReputationTierNew runners have reported fewer than reputationMinResults results
+	ReputationTierNew      ReputationTier = "new"
+	ReputationTierStandard ReputationTier = "standard"
+	ReputationTierTrusted  ReputationTier = "trusted"
+	// ReputationTierProbation runners are in quarantine
+	ReputationTierProbation ReputationTier = "probation"
+)
+
+const (
+	reputationMinResults  = 10
+	trustedMinSuccessRate = 0.95
+)
+
+func (r RunnerReputation) Tier() ReputationTier {
+	switch {
+	case r.Quarantined:
+		return ReputationTierProbation
+	case r.Completed+r.Failed < reputationMinResults:
+		return ReputationTierNew
+	case r.SuccessRate >= trustedMinSuccessRate:
+		return ReputationTierTrusted
+	default:
+		return ReputationTierStandard
+	}
+}
+
+// TaskAssignment is who holds the claim on a running task, as shown to its
+// creator. Runner is a pseudonym that differs per task, so creators learn
+// neither the runner's device ID nor which of their tasks it ran.
+//
+// The claim lasts until LeaseExpiresAt and is extended whenever the runner
+// reports it is busy. Once it lapses the creator can revoke it and the task is
+// queued again.
+type TaskAssignment struct {
+	Runner         string         `json:"runner"`
+	ReputationTier ReputationTier `json:"reputation_tier"`
+	Region         string         `json:"region,omitempty"`
+	ClaimedAt      time.Time      `json:"claimed_at"`
+	LastProgressAt time.Time      `json:"last_progress_at"`
+	LeaseExpiresAt time.Time      `json:"lease_expires_at"`
+}
+
+// Lapsed reports whether the runner let the claim expire without progress
+func (a *TaskAssignment) Lapsed(now time.Time) bool {
+	return now.After(a.LeaseExpiresAt)
+}
+
+// RunnerTaskState is what the server tells a runner about a task it held
+// before it restarted
+type RunnerTaskState struct {
+	TaskID string     `json:"task_id"`
+	Status TaskStatus `json:"status"`
+	// Assigned is set while the asking runner holds the claim on the task
+	Assigned bool `json:"assigned"`
+}
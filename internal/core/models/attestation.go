@@ -0,0 +1,116 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// TEETechnology is the trusted execution environment a runner runs tasks in
+type TEETechnology string
+
+const (
+	// TEESEVSNP and TEETDX are confidential VMs: every Docker and wasm task of a
+	// runner deployed in one runs inside the VM
+	TEESEVSNP TEETechnology = "sev-snp"
+	TEETDX    TEETechnology = "tdx"
+	// TEESGX is a Gramine enclave, which the runner itself runs in. Containers
+	// are outside of it, so only wasm tasks can run in the enclave.
+	TEESGX TEETechnology = "sgx"
+)
+
+// AttestationVersion names how the report data of a quote is derived
+const AttestationVersion = 1
+
+// Runs reports whether tasks of the type run inside the TEE
+func (t TEETechnology) Runs(taskType TaskType) bool {
+	switch t {
+	case TEESEVSNP, TEETDX:
+		return taskType == TaskTypeDocker || taskType == TaskTypeWasm
+	case TEESGX:
+		return taskType == TaskTypeWasm
+	default:
+		return false
+	}
+}
+
+// reportDataOffset is where the 64 bytes of report data sit in a quote: the
+// REPORT_DATA of a SEV-SNP attestation report, the REPORTDATA of the TD report
+// in a TDX quote and the report_data of the enclave report in an SGX quote
+func (t TEETechnology) reportDataOffset() (int, bool) {
+	switch t {
+	case TEESEVSNP:
+		return 0x50, true
+	case TEETDX:
+		return 48 + 520, true
+	case TEESGX:
+		return 48 + 320, true
+	default:
+		return 0, false
+	}
+}
+
+// AttestationQuote is the remote attestation of a result that ran in a TEE. The
+// hardware signs the quote, and its report data binds it to the task and result.
+type AttestationQuote struct {
+	Version    int           `json:"version"`
+	Technology TEETechnology `json:"technology"`
+	Quote      []byte        `json:"quote"`
+	// Provider is the kernel's name for the quote provider, such as sev_guest
+	Provider string `json:"provider,omitempty"`
+	// Attested is set by the server once the quote's signature was verified
+	// against the vendor's certificate chain. Anyone can write the report
+	// data of an unverified quote, so it proves nothing.
+	Attested bool `json:"attested"`
+}
+
+// AttestationReportData is what the quote of a result carries as report data, so
+// it cannot be replayed for another task, nonce or result
+func AttestationReportData(taskID uuid.UUID, nonce, resultHash string) [64]byte {
+	return sha512.Sum512([]byte(fmt.Sprintf("parity-attestation-v%d\n%s\n%s\n%s", AttestationVersion, taskID, nonce, resultHash)))
+}
+
+// VerifyBinding checks that the report data of the quote names the result of
+// the task. It is only a consistency check: the bytes are compared at a fixed
+// offset and the quote's signature is not checked, which needs the vendor's
+// certificate chain, so it does not make the quote attested.
+func (q *AttestationQuote) VerifyBinding(taskID uuid.UUID, nonce, resultHash string) error {
+	if q.Version != AttestationVersion {
+		return fmt.Errorf("unsupported attestation version %d", q.Version)
+	}
+	offset, ok := q.Technology.reportDataOffset()
+	if !ok {
+		return fmt.Errorf("unknown TEE technology %q", q.Technology)
+	}
+	if len(q.Quote) < offset+64 {
+		return fmt.Errorf("%s quote is too short", q.Technology)
+	}
+	want := AttestationReportData(taskID, nonce, resultHash)
+	if !bytes.Equal(q.Quote[offset:offset+64], want[:]) {
+		return errors.New("quote was not made for this result")
+	}
+	return nil
+}
+
+func (q AttestationQuote) Value() (driver.Value, error) {
+	return json.Marshal(q)
+}
+
+func (q *AttestationQuote) Scan(value interface{}) error {
+	if value == nil {
+		*q = AttestationQuote{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, q)
+}
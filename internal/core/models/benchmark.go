@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// BenchmarkVersion is the version of the benchmark suite. Scores of different
+// versions are not comparable.
+const BenchmarkVersion = 1
+
+// CPUBenchmark is the SHA-256 throughput of one core and of all cores
+type CPUBenchmark struct {
+	Cores          int     `json:"cores"`
+	SingleCoreMBps float64 `json:"single_core_mbps"`
+	MultiCoreMBps  float64 `json:"multi_core_mbps"`
+}
+
+// MemoryBenchmark is the rate a buffer larger than the CPU caches is copied at
+type MemoryBenchmark struct {
+	BufferBytes int64   `json:"buffer_bytes"`
+	CopyGBps    float64 `json:"copy_gbps"`
+}
+
+// DiskBenchmark is the sequential throughput of the filesystem tasks use. Writes
+// are synced before they are timed as done.
+type DiskBenchmark struct {
+	FileBytes int64   `json:"file_bytes"`
+	WriteMBps float64 `json:"write_mbps"`
+	ReadMBps  float64 `json:"read_mbps"`
+}
+
+// GPUBenchmark is the single precision rate of the CUDA n-body sample on the
+// runner's GPUs
+type GPUBenchmark struct {
+	Model  string  `json:"model"`
+	Count  int     `json:"count"`
+	GFLOPS float64 `json:"gflops"`
+}
+
+// BenchmarkScores rate each part against a reference machine that scores 1000.
+// Overall weighs CPU, memory and disk; the GPU is scored on its own so hosts
+// without one are not marked down.
+type BenchmarkScores struct {
+	CPU     float64 `json:"cpu"`
+	Memory  float64 `json:"memory"`
+	Disk    float64 `json:"disk"`
+	GPU     float64 `json:"gpu,omitempty"`
+	Overall float64 `json:"overall"`
+}
+
+// MinRewardWeight is the share of a task's reward paid to the slowest runners
+// when payouts are weighted by benchmark score
+const MinRewardWeight = 0.5
+
+// RewardWeight is the share of a task's reward a runner with these scores is
+// paid when payouts are weighted. It follows the overall score up to the
+// reference machine, which is paid in full, and never drops below
+// MinRewardWeight.
+func (s BenchmarkScores) RewardWeight() float64 {
+	return min(max(s.Overall/1000, MinRewardWeight), 1)
+}
+
+// BenchmarkReport is the result of `parity-runner benchmark`, signed with the
+// runner's wallet key
+type BenchmarkReport struct {
+	Version       int             `json:"version"`
+	DeviceID      string          `json:"device_id"`
+	WalletAddress string          `json:"wallet_address"`
+	CPU           CPUBenchmark    `json:"cpu"`
+	Memory        MemoryBenchmark `json:"memory"`
+	Disk          DiskBenchmark   `json:"disk"`
+	GPU           *GPUBenchmark   `json:"gpu,omitempty"`
+	Scores        BenchmarkScores `json:"scores"`
+	MeasuredAt    time.Time       `json:"measured_at"`
+	Signature     string          `json:"signature,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// BidState is where a runner's bid on a task stands
+type BidState string
+
+const (
+	// BidOpen bids wait for the auction to close
+	BidOpen BidState = "open"
+	BidWon  BidState = "won"
+	BidLost BidState = "lost"
+)
+
+// Bid is the price a runner asks to run a task, at most the task's reward
+type Bid struct {
+	TaskID      string    `json:"task_id"`
+	DeviceID    string    `json:"device_id"`
+	Price       float64   `json:"price"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// BidStatus is an auction as one bidder sees it. The winning price is only
+// shown once the auction has closed.
+type BidStatus struct {
+	TaskID       string    `json:"task_id"`
+	State        BidState  `json:"state"`
+	Price        float64   `json:"price"`
+	WinningPrice float64   `json:"winning_price,omitempty"`
+	Bids         int       `json:"bids"`
+	ClosesAt     time.Time `json:"closes_at"`
+}
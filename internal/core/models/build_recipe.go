@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// commitPattern matches a full git commit ID, SHA-1 or SHA-256
+var commitPattern = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+type BuildRecipeKind string
+
+const (
+	BuildRecipeNix        BuildRecipeKind = "nix"
+	BuildRecipeDockerfile BuildRecipeKind = "dockerfile"
+)
+
+// BuildRecipe points at the source a task image is reproducibly built from. Sampled
+// runners rebuild the image and compare its digest with the pulled image before
+// executing, which catches images that were tampered with after the build.
+type BuildRecipe struct {
+	Kind       BuildRecipeKind `json:"kind"`
+	Repository string          `json:"repository"`
+	// Revision is the full commit ID the image is built from
+	Revision string `json:"revision"`
+	// Context and Dockerfile are relative to the repository root (dockerfile recipes)
+	Context    string `json:"context,omitempty"`
+	Dockerfile string `json:"dockerfile,omitempty"`
+	// Attribute is the flake output producing an image tarball (nix recipes)
+	Attribute string `json:"attribute,omitempty"`
+	// SourceDateEpoch pins embedded timestamps for dockerfile builds
+	SourceDateEpoch int64 `json:"source_date_epoch,omitempty"`
+	// ExpectedDigest is the image ID the recipe is known to produce
+	ExpectedDigest string `json:"expected_digest,omitempty"`
+	// VerifyFraction is the share of runners that rebuild before executing (0-1)
+	VerifyFraction float64 `json:"verify_fraction,omitempty"`
+}
+
+func (r *BuildRecipe) Validate() error {
+	if r.Repository == "" {
+		return errors.New("build recipe repository is required")
+	}
+	if strings.HasPrefix(r.Repository, "-") {
+		return fmt.Errorf("invalid build recipe repository %q", r.Repository)
+	}
+	if !commitPattern.MatchString(r.Revision) {
+		return fmt.Errorf("build recipe revision must be a full hex commit id, got %q", r.Revision)
+	}
+	// The rebuild reads these inside the cloned source, never outside it
+	if r.Context != "" && !filepath.IsLocal(r.Context) {
+		return fmt.Errorf("build recipe context %q must be a path inside the repository", r.Context)
+	}
+	if r.Dockerfile != "" && !filepath.IsLocal(r.Dockerfile) {
+		return fmt.Errorf("build recipe dockerfile %q must be a path inside the context", r.Dockerfile)
+	}
+	if r.VerifyFraction < 0 || r.VerifyFraction > 1 {
+		return fmt.Errorf("build recipe verify fraction must be between 0 and 1, got %v", r.VerifyFraction)
+	}
+
+	switch r.Kind {
+	case BuildRecipeNix:
+		if r.Attribute == "" {
+			return errors.New("nix build recipe requires an attribute")
+		}
+	case BuildRecipeDockerfile:
+	default:
+		return fmt.Errorf("unsupported build recipe kind: %s", r.Kind)
+	}
+	return nil
+}
+
+// NormalizeDigest strips the algorithm prefix so image IDs can be compared
+func NormalizeDigest(digest string) string {
+	return strings.TrimPrefix(strings.TrimSpace(digest), "sha256:")
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+)
+
+// CapabilityProfile is what a runner measured about itself at startup and sent
+// with its registration. Disk sizes are of the filesystem tasks use, and
+// BandwidthMbps is the download rate from the server, zero when not measured.
+type CapabilityProfile struct {
+	CPUCores      int        `json:"cpu_cores"`
+	MemoryBytes   int64      `json:"memory_bytes"`
+	GPUs          []GPUInfo  `json:"gpus,omitempty"`
+	DiskBytes     int64      `json:"disk_bytes"`
+	DiskFreeBytes int64      `json:"disk_free_bytes"`
+	BandwidthMbps float64    `json:"bandwidth_mbps,omitempty"`
+	TaskTypes     []TaskType `json:"task_types"`
+	// Region is where the operator says the runner is, such as eu-west
+	Region string `json:"region,omitempty"`
+	// BenchmarkScore is the overall score of the runner's signed benchmark
+	// report. The server replaces it with the score of the report it verified.
+	BenchmarkScore float64   `json:"benchmark_score,omitempty"`
+	MeasuredAt     time.Time `json:"measured_at"`
+}
+
+// Supports reports whether the runner runs tasks of the type
+func (p *CapabilityProfile) Supports(taskType TaskType) bool {
+	return slices.Contains(p.TaskTypes, taskType)
+}
+
+// CapabilityRequirements are the least a runner's capability profile must
+// offer to be given a task. Runners without a profile are not given tasks with
+// requirements.
+type CapabilityRequirements struct {
+	MinCPUCores      int     `json:"min_cpu_cores,omitempty"`
+	MinMemoryBytes   int64   `json:"min_memory_bytes,omitempty"`
+	MinDiskFreeBytes int64   `json:"min_disk_free_bytes,omitempty"`
+	MinBandwidthMbps float64 `json:"min_bandwidth_mbps,omitempty"`
+	// MinBenchmarkScore is the least overall score of `parity-runner benchmark`
+	MinBenchmarkScore float64 `json:"min_benchmark_score,omitempty"`
+}
+
+func (r CapabilityRequirements) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *CapabilityRequirements) Scan(value interface{}) error {
+	if value == nil {
+		*r = CapabilityRequirements{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, r)
+}
+
+func (r *CapabilityRequirements) Validate() error {
+	if r.MinCPUCores < 0 || r.MinMemoryBytes < 0 || r.MinDiskFreeBytes < 0 || r.MinBandwidthMbps < 0 || r.MinBenchmarkScore < 0 {
+		return errors.New("capability requirements cannot be negative")
+	}
+	return nil
+}
+
+// SatisfiedBy reports whether a runner with the profile meets the requirements
+func (r *CapabilityRequirements) SatisfiedBy(p *CapabilityProfile) bool {
+	if p == nil {
+		return false
+	}
+	return p.CPUCores >= r.MinCPUCores &&
+		p.MemoryBytes >= r.MinMemoryBytes &&
+		p.DiskFreeBytes >= r.MinDiskFreeBytes &&
+		p.BandwidthMbps >= r.MinBandwidthMbps &&
+		p.BenchmarkScore >= r.MinBenchmarkScore
+}
+
+// RunnerReputation is how reliably a runner has completed the tasks it was
+// given. SuccessRate is zero until it reports a result.
+type RunnerReputation struct {
+	Completed   int     `json:"completed"`
+	Failed      int     `json:"failed"`
+	SuccessRate float64 `json:"success_rate"`
+	Quarantined bool    `json:"quarantined,omitempty"`
+}
+
+// RunnerSummary is a registered runner as listed to users
+type RunnerSummary struct {
+	DeviceID     string             `json:"device_id"`
+	Online       bool               `json:"online"`
+	Capabilities *CapabilityProfile `json:"capabilities,omitempty"`
+	Reputation   RunnerReputation   `json:"reputation"`
+}
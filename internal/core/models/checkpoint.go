@@ -0,0 +1,43 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// CheckpointSummary is attached to the result of a checkpointed Docker task. CIDs
+// lists the uploaded checkpoints of the attempt, oldest first, so another runner
+// can resume from the last one.
+type CheckpointSummary struct {
+	Mode    string   `json:"mode"`
+	Attempt int      `json:"attempt"`
+	Resumed bool     `json:"resumed"`
+	CIDs    []string `json:"cids,omitempty"`
+}
+
+// LatestCID is the CID of the most recent uploaded checkpoint, if any
+func (s *CheckpointSummary) LatestCID() string {
+	if s == nil || len(s.CIDs) == 0 {
+		return ""
+	}
+	return s.CIDs[len(s.CIDs)-1]
+}
+
+func (s CheckpointSummary) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *CheckpointSummary) Scan(value interface{}) error {
+	if value == nil {
+		*s = CheckpointSummary{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, s)
+}
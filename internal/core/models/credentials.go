@@ -0,0 +1,148 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type CredentialBrokerKind string
+
+const (
+	// CredentialBrokerAWSSTS assumes an IAM role with STS and hands the task the
+	// session credentials
+	CredentialBrokerAWSSTS CredentialBrokerKind = "aws_sts"
+	// CredentialBrokerOIDC runs the OAuth 2.0 client credentials grant against
+	// an OIDC provider and hands the task the access token
+	CredentialBrokerOIDC CredentialBrokerKind = "oidc"
+)
+
+var (
+	brokerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+	envPrefixPattern  = regexp.MustCompile(`^[A-Z][A-Z0-9_]*_$`)
+)
+
+// CredentialBroker is an exchange a creator registers so that their tasks get
+// short-lived credentials to the creator's cloud resources. The long-lived
+// secrets of the exchange stay on the server, tasks only see what it mints.
+type CredentialBroker struct {
+	Name           string `json:"name"`
+	CreatorAddress string `json:"creator_address"`
+	// OwnerDeviceID is the device that registered the broker. Only it can
+	// replace, list or remove the broker, and only its tasks can use it.
+	OwnerDeviceID string               `json:"owner_device_id"`
+	Kind          CredentialBrokerKind `json:"kind"`
+	// MaxTTLSeconds caps the lifetime of minted credentials, which is otherwise
+	// the task's maximum duration
+	MaxTTLSeconds int64           `json:"max_ttl_seconds,omitempty"`
+	AWS           *AWSSTSExchange `json:"aws,omitempty"`
+	OIDC          *OIDCExchange   `json:"oidc,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// AWSSTSExchange assumes RoleARN with the keys of a principal allowed to. Policy
+// is an IAM policy document that narrows the session further.
+type AWSSTSExchange struct {
+	RoleARN         string `json:"role_arn"`
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	ExternalID      string `json:"external_id,omitempty"`
+	Policy          string `json:"policy,omitempty"`
+	// Endpoint overrides the regional STS endpoint
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// OIDCExchange gets an access token for Audience and Scope from TokenURL
+type OIDCExchange struct {
+	TokenURL     string `json:"token_url"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Scope        string `json:"scope,omitempty"`
+	Audience     string `json:"audience,omitempty"`
+}
+
+func (b *CredentialBroker) Validate() error {
+	if !brokerNamePattern.MatchString(b.Name) {
+		return errors.New("broker name must be lowercase letters, digits, - and _")
+	}
+	if b.CreatorAddress == "" {
+		return errors.New("creator_address is required")
+	}
+	if b.MaxTTLSeconds < 0 {
+		return errors.New("max_ttl_seconds cannot be negative")
+	}
+
+	switch b.Kind {
+	case CredentialBrokerAWSSTS:
+		if b.AWS == nil || b.OIDC != nil {
+			return errors.New("aws_sts brokers need aws and no oidc settings")
+		}
+		if !strings.HasPrefix(b.AWS.RoleARN, "arn:") {
+			return errors.New("aws role_arn must be an ARN")
+		}
+		if b.AWS.Region == "" && b.AWS.Endpoint == "" {
+			return errors.New("aws region is required")
+		}
+		if b.AWS.AccessKeyID == "" || b.AWS.SecretAccessKey == "" {
+			return errors.New("aws access_key_id and secret_access_key are required")
+		}
+	case CredentialBrokerOIDC:
+		if b.OIDC == nil || b.AWS != nil {
+			return errors.New("oidc brokers need oidc and no aws settings")
+		}
+		if !strings.HasPrefix(b.OIDC.TokenURL, "https://") {
+			return errors.New("oidc token_url must be an https URL")
+		}
+		if b.OIDC.ClientID == "" || b.OIDC.ClientSecret == "" {
+			return errors.New("oidc client_id and client_secret are required")
+		}
+	default:
+		return fmt.Errorf("unsupported broker kind %q", b.Kind)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the broker without its secrets
+func (b *CredentialBroker) Redacted() *CredentialBroker {
+	redacted := *b
+	if b.AWS != nil {
+		aws := *b.AWS
+		aws.SecretAccessKey = ""
+		redacted.AWS = &aws
+	}
+	if b.OIDC != nil {
+		oidc := *b.OIDC
+		oidc.ClientSecret = ""
+		redacted.OIDC = &oidc
+	}
+	return &redacted
+}
+
+// CredentialRequest asks for credentials from one of the creator's brokers.
+// EnvPrefix is put in front of the variable names the credentials are injected
+// as, so a task can use two brokers of the same kind.
+type CredentialRequest struct {
+	Broker    string `json:"broker"`
+	EnvPrefix string `json:"env_prefix,omitempty"`
+}
+
+func (r CredentialRequest) Validate() error {
+	if !brokerNamePattern.MatchString(r.Broker) {
+		return fmt.Errorf("invalid credential broker name %q", r.Broker)
+	}
+	if r.EnvPrefix != "" && !envPrefixPattern.MatchString(r.EnvPrefix) {
+		return fmt.Errorf("invalid env_prefix %q, expected upper case ending in _", r.EnvPrefix)
+	}
+	return nil
+}
+
+// TaskCredentials are credentials minted for one task. Env holds the variables
+// they are injected into the task container as.
+type TaskCredentials struct {
+	Broker    string            `json:"broker"`
+	Env       map[string]string `json:"env"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
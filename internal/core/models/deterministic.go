@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// DeterministicHashVersion names how the result hash of deterministic tasks
+	// is computed. Only results hashed the same way can be compared.
+	DeterministicHashVersion = 1
+	// DefaultDeterministicEpoch is 2000-01-01T00:00:00Z
+	DefaultDeterministicEpoch int64 = 946684800
+)
+
+// DeterministicConfig runs a Docker task the same way on every runner, so that
+// runners which agree on its result hash agree on its result. The image must be
+// pinned to a digest, the container has no network, its clock starts at Epoch
+// and its environment is normalized. The result hash then covers only the exit
+// code, the normalized output and the contents of the files the task produced.
+type DeterministicConfig struct {
+	// Epoch is the Unix time the task's clock starts at,
+	// DefaultDeterministicEpoch when zero
+	Epoch int64 `json:"epoch,omitempty"`
+}
+
+func (c *DeterministicConfig) StartTime() time.Time {
+	if c.Epoch == 0 {
+		return time.Unix(DefaultDeterministicEpoch, 0).UTC()
+	}
+	return time.Unix(c.Epoch, 0).UTC()
+}
+
+// validateDeterministic refuses the settings of a Docker task that make it
+// depend on the runner or the network
+func (c *TaskConfig) validateDeterministic() error {
+	if c.Deterministic.Epoch < 0 {
+		return errors.New("deterministic epoch must not be negative")
+	}
+	if _, digest, ok := strings.Cut(c.ImageName, "@"); !ok || !imageDigestPattern.MatchString(digest) {
+		return errors.New("deterministic tasks need an image pinned with @sha256:")
+	}
+	switch {
+	case c.DockerImageURL != "":
+		return errors.New("deterministic tasks cannot load their image from a URL")
+	case c.Egress != nil, c.DNS != nil, len(c.Credentials) > 0:
+		return errors.New("deterministic tasks run without network access")
+	case c.Gang != nil:
+		return errors.New("deterministic tasks cannot run as a gang")
+	}
+	return nil
+}
+
+// DeterministicSummary records how a deterministic task was run. Results are
+// only comparable when their summaries match, and ClockPinned is false on
+// runners without libfaketime, whose tasks saw the real time.
+type DeterministicSummary struct {
+	HashVersion int    `json:"hash_version"`
+	ImageDigest string `json:"image_digest"`
+	Epoch       int64  `json:"epoch"`
+	ClockPinned bool   `json:"clock_pinned"`
+}
+
+func (s DeterministicSummary) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *DeterministicSummary) Scan(value interface{}) error {
+	if value == nil {
+		*s = DeterministicSummary{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, s)
+}
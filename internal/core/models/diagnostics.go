@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Failure classes of a diagnostic bundle. They refine the classes telemetry
+// counts with what the container's state tells about the failure.
+const (
+	DiagnosticExitCode   = "exit_code"
+	DiagnosticTimeout    = "timeout"
+	DiagnosticAborted    = "aborted"
+	DiagnosticExecution  = "execution"
+	DiagnosticOOMKilled  = "oom_killed"
+	DiagnosticKilled     = "killed"
+	DiagnosticSubmission = "submission"
+)
+
+// ContainerDiagnostics is the part of a failed task container's inspect output
+// that explains how it ended. The container's environment is left out.
+type ContainerDiagnostics struct {
+	Image        string    `json:"image,omitempty"`
+	Status       string    `json:"status"`
+	ExitCode     int       `json:"exit_code"`
+	OOMKilled    bool      `json:"oom_killed"`
+	Error        string    `json:"error,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	RestartCount int       `json:"restart_count,omitempty"`
+	MemoryLimit  int64     `json:"memory_limit,omitempty"`
+	NanoCPUs     int64     `json:"nano_cpus,omitempty"`
+}
+
+// MetricsSample is the container's resource usage ElapsedMs after it started
+type MetricsSample struct {
+	ElapsedMs  int64   `json:"elapsed_ms"`
+	CPUPercent float64 `json:"cpu_percent"`
+	MemoryGB   float64 `json:"memory_gb"`
+}
+
+// RunnerEnvironment fingerprints the runner a task failed on
+type RunnerEnvironment struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit,omitempty"`
+	GoVersion string   `json:"go_version"`
+	OS        string   `json:"os"`
+	Arch      string   `json:"arch"`
+	Kernel    string   `json:"kernel,omitempty"`
+	CPUs      int      `json:"cpus"`
+	Runtime   string   `json:"runtime,omitempty"`
+	GPUs      []string `json:"gpus,omitempty"`
+}
+
+// FailureDiagnostics is attached to the result of a failed task, so its creator
+// and the runner's operator see why it failed without going through the logs
+// of both machines. Task environment values, credentials and anything that looks
+// like a secret are redacted before it leaves the runner.
+type FailureDiagnostics struct {
+	Class       string                `json:"class"`
+	Error       string                `json:"error,omitempty"`
+	LogTail     []string              `json:"log_tail,omitempty"`
+	Container   *ContainerDiagnostics `json:"container,omitempty"`
+	Metrics     []MetricsSample       `json:"metrics,omitempty"`
+	Environment RunnerEnvironment     `json:"environment"`
+	// Redacted counts the values replaced by [REDACTED]
+	Redacted int `json:"redacted,omitempty"`
+	// Truncated is set when log lines or samples were dropped to stay under
+	// the size limit
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (d FailureDiagnostics) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *FailureDiagnostics) Scan(value interface{}) error {
+	if value == nil {
+		*d = FailureDiagnostics{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, d)
+}
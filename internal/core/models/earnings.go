@@ -0,0 +1,163 @@
+package models
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportMonthLayout is how report months are written, e.g. 2026-09
+const reportMonthLayout = "2006-01"
+
+// PaidReward is a reward that reached a runner's wallet. TokenPrice and
+// FiatValue are in Currency at the time of payout, and absent when no price
+// was known then.
+type PaidReward struct {
+	PaidAt        time.Time `json:"paid_at"`
+	TaskID        string    `json:"task_id"`
+	TaskType      TaskType  `json:"task_type,omitempty"`
+	DeviceID      string    `json:"device_id"`
+	WalletAddress string    `json:"wallet_address"`
+	Amount        float64   `json:"amount"`
+	TxHash        string    `json:"tx_hash,omitempty"`
+	// ChainID is the chain TxHash is on, the coordinator's default chain when
+	// zero
+	ChainID    int64    `json:"chain_id,omitempty"`
+	TokenPrice *float64 `json:"token_price,omitempty"`
+	FiatValue  *float64 `json:"fiat_value,omitempty"`
+	Currency   string   `json:"currency,omitempty"`
+	// Coordinator is set when reports of several coordinators are merged
+	Coordinator string `json:"coordinator,omitempty"`
+}
+
+// DeviceEarnings adds up the rewards of one runner in a report
+type DeviceEarnings struct {
+	DeviceID  string  `json:"device_id"`
+	Payouts   int     `json:"payouts"`
+	Amount    float64 `json:"amount"`
+	FiatValue float64 `json:"fiat_value"`
+}
+
+// EarningsReport is what the runners of one wallet were paid in a calendar
+// month (UTC). Fiat totals leave out rewards paid while no price was known,
+// counted in Unpriced, and are only given when every priced reward is in the
+// same currency.
+type EarningsReport struct {
+	WalletAddress  string           `json:"wallet_address"`
+	Month          string           `json:"month"`
+	Currency       string           `json:"currency,omitempty"`
+	Payouts        int              `json:"payouts"`
+	TotalAmount    float64          `json:"total_amount"`
+	TotalFiatValue float64          `json:"total_fiat_value"`
+	Unpriced       int              `json:"unpriced"`
+	Devices        []DeviceEarnings `json:"devices"`
+	Rewards        []PaidReward     `json:"rewards"`
+}
+
+// ParseReportMonth reads a month such as 2026-09, the current month when empty
+func ParseReportMonth(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		now = now.UTC()
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	month, err := time.Parse(reportMonthLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", value)
+	}
+	return month, nil
+}
+
+// NewEarningsReport builds the report of the wallet's rewards paid in month
+func NewEarningsReport(walletAddress string, month time.Time, rewards []PaidReward) *EarningsReport {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	report := &EarningsReport{
+		WalletAddress: walletAddress,
+		Month:         start.Format(reportMonthLayout),
+		Devices:       []DeviceEarnings{},
+		Rewards:       []PaidReward{},
+	}
+
+	devices := make(map[string]*DeviceEarnings)
+	currencies := make(map[string]bool)
+	for _, reward := range rewards {
+		if !strings.EqualFold(reward.WalletAddress, walletAddress) || reward.PaidAt.Before(start) || !reward.PaidAt.Before(end) {
+			continue
+		}
+		report.Rewards = append(report.Rewards, reward)
+		report.Payouts++
+		report.TotalAmount += reward.Amount
+
+		device, ok := devices[reward.DeviceID]
+		if !ok {
+			device = &DeviceEarnings{DeviceID: reward.DeviceID}
+			devices[reward.DeviceID] = device
+		}
+		device.Payouts++
+		device.Amount += reward.Amount
+		if reward.FiatValue == nil {
+			report.Unpriced++
+			continue
+		}
+		currencies[reward.Currency] = true
+		device.FiatValue += *reward.FiatValue
+		report.TotalFiatValue += *reward.FiatValue
+	}
+
+	if len(currencies) == 1 {
+		for currency := range currencies {
+			report.Currency = currency
+		}
+	} else {
+		report.TotalFiatValue = 0
+		for _, device := range devices {
+			device.FiatValue = 0
+		}
+	}
+	for _, device := range devices {
+		report.Devices = append(report.Devices, *device)
+	}
+	sort.Slice(report.Devices, func(i, j int) bool { return report.Devices[i].DeviceID < report.Devices[j].DeviceID })
+	sort.SliceStable(report.Rewards, func(i, j int) bool { return report.Rewards[i].PaidAt.Before(report.Rewards[j].PaidAt) })
+	return report
+}
+
+// WriteCSV writes one row per reward, oldest first
+func (r *EarningsReport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	header := []string{"paid_at", "task_id", "task_type", "device_id", "wallet_address", "amount", "tx_hash", "token_price", "fiat_value", "currency", "coordinator"}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, reward := range r.Rewards {
+		row := []string{
+			reward.PaidAt.UTC().Format(time.RFC3339),
+			reward.TaskID,
+			string(reward.TaskType),
+			reward.DeviceID,
+			reward.WalletAddress,
+			strconv.FormatFloat(reward.Amount, 'f', 8, 64),
+			reward.TxHash,
+			formatOptional(reward.TokenPrice),
+			formatOptional(reward.FiatValue),
+			reward.Currency,
+			reward.Coordinator,
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatOptional(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+func TestNewEarningsReportFiltersByWalletAndMonth(t *testing.T) {
+	price := 2.0
+	fiat := func(amount float64) *float64 {
+		value := amount * price
+		return &value
+	}
+	september := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	rewards := []PaidReward{
+		{PaidAt: september.Add(48 * time.Hour), TaskID: "b", DeviceID: "gpu-box", WalletAddress: "0xABC", Amount: 3, TokenPrice: &price, FiatValue: fiat(3), Currency: "EUR"},
+		{PaidAt: september.Add(time.Hour), TaskID: "a", DeviceID: "laptop", WalletAddress: "0xabc", Amount: 1, TokenPrice: &price, FiatValue: fiat(1), Currency: "EUR"},
+		{PaidAt: september.Add(72 * time.Hour), TaskID: "c", DeviceID: "laptop", WalletAddress: "0xabc", Amount: 2},
+		{PaidAt: september.Add(time.Hour), TaskID: "other-wallet", DeviceID: "laptop", WalletAddress: "0xdef", Amount: 5},
+		{PaidAt: september.Add(-time.Second), TaskID: "august", DeviceID: "laptop", WalletAddress: "0xabc", Amount: 7},
+		{PaidAt: september.AddDate(0, 1, 0), TaskID: "october", DeviceID: "laptop", WalletAddress: "0xabc", Amount: 7},
+	}
+
+	month, err := ParseReportMonth("2026-09", time.Now())
+	if err != nil {
+		t.Fatalf("ParseReportMonth() error = %v", err)
+	}
+	report := NewEarningsReport("0xabc", month, rewards)
+	if report.Month != "2026-09" || report.Payouts != 3 || report.TotalAmount != 6 || report.Unpriced != 1 {
+		t.Fatalf("report = %+v, want three September payouts of 0xabc", report)
+	}
+	if report.Currency != "EUR" || report.TotalFiatValue != 8 {
+		t.Fatalf("fiat total = %v %s, want 8 EUR", report.TotalFiatValue, report.Currency)
+	}
+	if len(report.Devices) != 2 || report.Devices[0].DeviceID != "gpu-box" || report.Devices[1].Amount != 3 || report.Devices[1].Payouts != 2 {
+		t.Fatalf("devices = %+v", report.Devices)
+	}
+	if report.Rewards[0].TaskID != "a" || report.Rewards[2].TaskID != "c" {
+		t.Fatalf("rewards are not oldest first: %+v", report.Rewards)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 4 {
+		t.Fatalf("csv rows = %v, %v", rows, err)
+	}
+	if rows[1][0] != "2026-09-01T01:00:00Z" || rows[1][8] != "2" || rows[3][8] != "" {
+		t.Fatalf("csv rows = %v", rows)
+	}
+}
+
+func TestNewEarningsReportLeavesOutMixedCurrencyTotals(t *testing.T) {
+	value := 1.0
+	now := time.Now().UTC()
+	report := NewEarningsReport("0xabc", now, []PaidReward{
+		{PaidAt: now, WalletAddress: "0xabc", DeviceID: "a", Amount: 1, FiatValue: &value, Currency: "USD"},
+		{PaidAt: now, WalletAddress: "0xabc", DeviceID: "a", Amount: 1, FiatValue: &value, Currency: "EUR"},
+	})
+	if report.Currency != "" || report.TotalFiatValue != 0 || report.Devices[0].FiatValue != 0 {
+		t.Fatalf("report = %+v, want no fiat total across currencies", report)
+	}
+	if _, err := ParseReportMonth("2026-13", now); err == nil {
+		t.Fatal("ParseReportMonth() accepted month 13")
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// EgressPolicy restricts where a task container may connect. Entries are an IP,
+// CIDR or hostname with an optional port ("10.0.0.0/8", "api.example.com:443").
+// Deny entries win over allow entries; a non-empty allow list blocks everything else.
+type EgressPolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+	// Audit records outbound connections even when no rules are set
+	Audit bool `json:"audit,omitempty"`
+}
+
+func (p *EgressPolicy) HasRules() bool {
+	return p != nil && (len(p.Allow) > 0 || len(p.Deny) > 0)
+}
+
+type EgressConnection struct {
+	Protocol    string `json:"protocol"`
+	Destination string `json:"destination"`
+	Port        int    `json:"port,omitempty"`
+	Count       int    `json:"count"`
+	Allowed     bool   `json:"allowed"`
+}
+
+// EgressSummary is attached to a task result so creators can see every outbound
+// connection the container attempted
+type EgressSummary struct {
+	Enforced    bool               `json:"enforced"`
+	Total       int                `json:"total"`
+	Blocked     int                `json:"blocked"`
+	Connections []EgressConnection `json:"connections"`
+}
+
+func (s EgressSummary) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *EgressSummary) Scan(value interface{}) error {
+	if value == nil {
+		*s = EgressSummary{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, s)
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ExecutionEventType is a step the runner took executing a task
+type ExecutionEventType string
+
+const (
+	ExecutionEventReceived         ExecutionEventType = "task_received"
+	ExecutionEventImagePulled      ExecutionEventType = "image_pulled"
+	ExecutionEventSecurityVerified ExecutionEventType = "security_verified"
+	ExecutionEventContainerStarted ExecutionEventType = "container_started"
+	ExecutionEventCompleted        ExecutionEventType = "completed"
+	ExecutionEventFailed           ExecutionEventType = "failed"
+	ExecutionEventCancelled        ExecutionEventType = "cancelled"
+	ExecutionEventRetrying         ExecutionEventType = "retrying"
+	ExecutionEventResultSubmitted  ExecutionEventType = "result_submitted"
+	ExecutionEventResultQueued     ExecutionEventType = "result_queued"
+)
+
+// ExecutionEvent is an entry of the runner's own log of a task. Each event
+// carries the hash of the one before it, so events cannot be left out of or
+// changed in a log attached to a result without breaking the chain.
+type ExecutionEvent struct {
+	Seq      int                `json:"seq"`
+	Type     ExecutionEventType `json:"type"`
+	Time     time.Time          `json:"time"`
+	Details  map[string]string  `json:"details,omitempty"`
+	PrevHash string             `json:"prev_hash,omitempty"`
+	Hash     string             `json:"hash"`
+}
+
+// ExecutionLog is the runner's events of a task, oldest first
+type ExecutionLog []ExecutionEvent
+
+func (l ExecutionLog) Value() (driver.Value, error) {
+	return json.Marshal(l)
+}
+
+func (l *ExecutionLog) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, l)
+}
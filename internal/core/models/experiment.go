@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ExperimentStatus string
+
+const (
+	ExperimentStatusRunning   ExperimentStatus = "running"
+	ExperimentStatusCompleted ExperimentStatus = "completed"
+	ExperimentStatusCancelled ExperimentStatus = "cancelled"
+)
+
+// Experiment groups the tasks of one piece of work, e.g. one task per dataset
+// shard, so creators can follow and cancel them together
+type Experiment struct {
+	ID             uuid.UUID        `json:"id" gorm:"type:uuid;primaryKey"`
+	Name           string           `json:"name" gorm:"type:varchar(255)"`
+	Description    string           `json:"description" gorm:"type:text"`
+	CreatorAddress string           `json:"creator_address" gorm:"type:varchar(42);index"`
+	Labels         Labels           `json:"labels,omitempty" gorm:"type:jsonb"`
+	Status         ExperimentStatus `json:"status" gorm:"type:varchar(20)"`
+	CreatedAt      time.Time        `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt      time.Time        `json:"updated_at" gorm:"type:timestamp"`
+	CancelledAt    *time.Time       `json:"cancelled_at,omitempty" gorm:"type:timestamp"`
+}
+
+func NewExperiment(name string) *Experiment {
+	return &Experiment{
+		ID:        uuid.New(),
+		Name:      name,
+		Status:    ExperimentStatusRunning,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func (e *Experiment) Validate() error {
+	if e.Name == "" {
+		return errors.New("experiment name is required")
+	}
+	return nil
+}
+
+// ExperimentProgress counts an experiment's tasks by state
+type ExperimentProgress struct {
+	Total     int `json:"total"`
+	Pending   int `json:"pending"`
+	Running   int `json:"running"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+}
+
+// Done reports whether every task has reached a final state
+func (p ExperimentProgress) Done() bool {
+	return p.Total > 0 && p.Pending == 0 && p.Running == 0
+}
+
+// ExperimentSummary is the aggregate view of an experiment returned by the API
+type ExperimentSummary struct {
+	Experiment *Experiment          `json:"experiment"`
+	Progress   ExperimentProgress   `json:"progress"`
+	Usage      ResourceUsageSummary `json:"usage"`
+	TaskIDs    []uuid.UUID          `json:"task_ids"`
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RunnerSettings is the part of a runner's configuration an operator can manage
+// from the server. Runners report it in every heartbeat. Empty TaskTypes accept
+// every type.
+type RunnerSettings struct {
+	AcceptLabels       string     `json:"accept_labels"`
+	MaxConcurrentTasks int        `json:"max_concurrent_tasks"`
+	TaskTypes          []TaskType `json:"task_types"`
+	Models             []string   `json:"models"`
+}
+
+// FleetSettings is the desired state of some RunnerSettings. Fields left nil are
+// not managed and stay as the runner has them.
+type FleetSettings struct {
+	AcceptLabels       *string     `json:"accept_labels,omitempty"`
+	MaxConcurrentTasks *int        `json:"max_concurrent_tasks,omitempty"`
+	TaskTypes          *[]TaskType `json:"task_types,omitempty"`
+	Models             *[]string   `json:"models,omitempty"`
+}
+
+func (s FleetSettings) Validate() error {
+	if s.AcceptLabels != nil {
+		if _, err := ParseLabelSelector(*s.AcceptLabels); err != nil {
+			return fmt.Errorf("invalid accept_labels: %w", err)
+		}
+	}
+	if s.MaxConcurrentTasks != nil && *s.MaxConcurrentTasks < 1 {
+		return errors.New("max_concurrent_tasks must be at least 1")
+	}
+	if s.TaskTypes != nil {
+		names := make([]string, len(*s.TaskTypes))
+		for i, taskType := range *s.TaskTypes {
+			names[i] = string(taskType)
+		}
+		if _, err := ParseRunnerPolicy(strings.Join(names, ","), "", ""); err != nil {
+			return err
+		}
+	}
+	if s.Models != nil {
+		for _, model := range *s.Models {
+			if strings.TrimSpace(model) == "" {
+				return errors.New("model names cannot be empty")
+			}
+		}
+	}
+	return nil
+}
+
+// Over returns s with the fields it leaves unmanaged taken from base
+func (s FleetSettings) Over(base FleetSettings) FleetSettings {
+	if s.AcceptLabels == nil {
+		s.AcceptLabels = base.AcceptLabels
+	}
+	if s.MaxConcurrentTasks == nil {
+		s.MaxConcurrentTasks = base.MaxConcurrentTasks
+	}
+	if s.TaskTypes == nil {
+		s.TaskTypes = base.TaskTypes
+	}
+	if s.Models == nil {
+		s.Models = base.Models
+	}
+	return s
+}
+
+// SettingDrift is a managed setting whose reported value is not the desired one
+type SettingDrift struct {
+	Setting string `json:"setting"`
+	Desired string `json:"desired"`
+	Actual  string `json:"actual"`
+}
+
+// Drift lists the managed settings actual differs in. Task types and models are
+// compared as sets, models named without a tag as :latest, and accept labels by
+// their canonical form.
+func (s FleetSettings) Drift(actual RunnerSettings) []SettingDrift {
+	var drift []SettingDrift
+	compare := func(setting, desired, actual string) {
+		if desired != actual {
+			drift = append(drift, SettingDrift{Setting: setting, Desired: desired, Actual: actual})
+		}
+	}
+
+	if s.AcceptLabels != nil {
+		compare("accept_labels", canonicalSelector(*s.AcceptLabels), canonicalSelector(actual.AcceptLabels))
+	}
+	if s.MaxConcurrentTasks != nil {
+		compare("max_concurrent_tasks", strconv.Itoa(*s.MaxConcurrentTasks), strconv.Itoa(actual.MaxConcurrentTasks))
+	}
+	if s.TaskTypes != nil {
+		compare("task_types", canonicalSet(*s.TaskTypes), canonicalSet(actual.TaskTypes))
+	}
+	if s.Models != nil {
+		compare("models", canonicalSet(taggedModels(*s.Models)), canonicalSet(taggedModels(actual.Models)))
+	}
+	return drift
+}
+
+// taggedModels adds the implicit :latest tag to model names without one
+func taggedModels(names []string) []string {
+	tagged := make([]string, len(names))
+	for i, name := range names {
+		if name = strings.TrimSpace(name); name != "" && !strings.Contains(name, ":") {
+			name += ":latest"
+		}
+		tagged[i] = name
+	}
+	return tagged
+}
+
+func canonicalSelector(selector string) string {
+	parsed, err := ParseLabelSelector(selector)
+	if err != nil {
+		return selector
+	}
+	return parsed.String()
+}
+
+func canonicalSet[T ~string](values []T) string {
+	set := make([]string, 0, len(values))
+	for _, value := range values {
+		if v := strings.ToLower(strings.TrimSpace(string(value))); v != "" && !slices.Contains(set, v) {
+			set = append(set, v)
+		}
+	}
+	slices.Sort(set)
+	return strings.Join(set, ",")
+}
+
+// FleetDocument is the desired state of a runner fleet. Every runner gets
+// Defaults, overridden by its entry in Runners, which is keyed by device ID.
+type FleetDocument struct {
+	Defaults FleetSettings            `json:"defaults"`
+	Runners  map[string]FleetSettings `json:"runners,omitempty"`
+}
+
+func (d *FleetDocument) Validate() error {
+	if err := d.Defaults.Validate(); err != nil {
+		return fmt.Errorf("defaults: %w", err)
+	}
+	for deviceID, settings := range d.Runners {
+		if deviceID == "" {
+			return errors.New("runner entries need a device ID")
+		}
+		if err := settings.Validate(); err != nil {
+			return fmt.Errorf("runner %s: %w", deviceID, err)
+		}
+	}
+	return nil
+}
+
+// For returns the desired settings of a runner
+func (d *FleetDocument) For(deviceID string) FleetSettings {
+	return d.Runners[deviceID].Over(d.Defaults)
+}
+
+// FleetDirective asks a runner to apply settings. Generation increases with every
+// document the server accepts, so a runner can tell a directive it already
+// acted on from a new one.
+type FleetDirective struct {
+	Generation int64         `json:"generation"`
+	Settings   FleetSettings `json:"settings"`
+}
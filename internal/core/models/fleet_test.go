@@ -0,0 +1,75 @@
+package models
+
+import "testing"
+
+func TestFleetDocumentForOverridesDefaults(t *testing.T) {
+	four, eight := 4, 8
+	labels := "gpu=true"
+	document := FleetDocument{
+		Defaults: FleetSettings{MaxConcurrentTasks: &four, AcceptLabels: &labels},
+		Runners:  map[string]FleetSettings{"big": {MaxConcurrentTasks: &eight}},
+	}
+
+	if got := *document.For("big").MaxConcurrentTasks; got != 8 {
+		t.Errorf("big max_concurrent_tasks = %d, want 8", got)
+	}
+	if got := *document.For("big").AcceptLabels; got != labels {
+		t.Errorf("big accept_labels = %q, want the default", got)
+	}
+	if got := *document.For("other").MaxConcurrentTasks; got != 4 {
+		t.Errorf("other max_concurrent_tasks = %d, want 4", got)
+	}
+	if document.For("other").Models != nil {
+		t.Error("models should stay unmanaged")
+	}
+}
+
+func TestFleetSettingsDrift(t *testing.T) {
+	two := 2
+	labels := "zone=eu, gpu=true"
+	taskTypes := []TaskType{TaskTypeDocker, TaskTypeLLM}
+	modelSet := []string{"llama3", "mistral:7b"}
+	desired := FleetSettings{AcceptLabels: &labels, MaxConcurrentTasks: &two, TaskTypes: &taskTypes, Models: &modelSet}
+
+	inSync := RunnerSettings{
+		AcceptLabels:       "gpu=true,zone=eu",
+		MaxConcurrentTasks: 2,
+		TaskTypes:          []TaskType{TaskTypeLLM, TaskTypeDocker},
+		Models:             []string{"mistral:7b", "llama3:latest"},
+	}
+	if drift := desired.Drift(inSync); len(drift) != 0 {
+		t.Fatalf("Drift() = %+v, want none", drift)
+	}
+
+	drifted := inSync
+	drifted.MaxConcurrentTasks = 1
+	drifted.Models = []string{"llama3"}
+	drift := desired.Drift(drifted)
+	if len(drift) != 2 || drift[0].Setting != "max_concurrent_tasks" || drift[1].Setting != "models" {
+		t.Fatalf("Drift() = %+v, want max_concurrent_tasks and models", drift)
+	}
+	if drift[1].Desired != "llama3:latest,mistral:7b" || drift[1].Actual != "llama3:latest" {
+		t.Errorf("models drift = %+v", drift[1])
+	}
+
+	if drift := (FleetSettings{}).Drift(drifted); len(drift) != 0 {
+		t.Errorf("unmanaged settings drifted: %+v", drift)
+	}
+}
+
+func TestFleetDocumentValidate(t *testing.T) {
+	zero := 0
+	empty := []string{" "}
+	for name, document := range map[string]FleetDocument{
+		"concurrency": {Defaults: FleetSettings{MaxConcurrentTasks: &zero}},
+		"models":      {Runners: map[string]FleetSettings{"device-1": {Models: &empty}}},
+		"device":      {Runners: map[string]FleetSettings{"": {}}},
+	} {
+		if err := document.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if err := (&FleetDocument{}).Validate(); err != nil {
+		t.Errorf("empty document: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultGangStartWindow is how long the members of a gang have to start once
+	// the first one did
+	DefaultGangStartWindow = 5 * time.Minute
+	// DefaultGangPort is the port members listen on for their peers
+	DefaultGangPort = 29500
+	// DefaultGangAttempts is how often a gang is scheduled before it fails for good
+	DefaultGangAttempts = 3
+	maxGangSize         = 64
+)
+
+// GangConfig makes a Docker task run on Size runners at once, for distributed
+// training or multi-party computation. Every member runs the same container and
+// learns its rank and the endpoints of its peers from the environment.
+type GangConfig struct {
+	Size int `json:"size"`
+	// StartWindowSeconds bounds how long the first members wait for the rest.
+	// When it passes, or a member drops, the whole gang is scheduled again.
+	StartWindowSeconds int64 `json:"start_window_seconds,omitempty"`
+	Port               int   `json:"port,omitempty"`
+	MaxAttempts        int   `json:"max_attempts,omitempty"`
+	// Channel gives the members an encrypted channel to each other, served by
+	// the runners on the port after Port, for exchanging masked gradients or
+	// shards without going through the coordinator
+	Channel bool `json:"channel,omitempty"`
+}
+
+func (g *GangConfig) Validate() error {
+	if g.Size < 2 || g.Size > maxGangSize {
+		return fmt.Errorf("gang size must be between 2 and %d", maxGangSize)
+	}
+	if g.StartWindowSeconds < 0 {
+		return errors.New("gang start window cannot be negative")
+	}
+	if g.Port < 0 || g.Port > 65535 {
+		return fmt.Errorf("invalid gang port %d", g.Port)
+	}
+	if g.MaxAttempts < 0 {
+		return errors.New("gang max_attempts cannot be negative")
+	}
+	if g.Channel && g.ListenPort() == 65535 {
+		return errors.New("gang channel needs the port after the gang port")
+	}
+	return nil
+}
+
+func (g *GangConfig) StartWindow() time.Duration {
+	if g.StartWindowSeconds > 0 {
+		return time.Duration(g.StartWindowSeconds) * time.Second
+	}
+	return DefaultGangStartWindow
+}
+
+func (g *GangConfig) ListenPort() int {
+	if g.Port > 0 {
+		return g.Port
+	}
+	return DefaultGangPort
+}
+
+// ChannelPort is where runners accept channel connections from other members
+func (g *GangConfig) ChannelPort() int {
+	return g.ListenPort() + 1
+}
+
+func (g *GangConfig) Attempts() int {
+	if g.MaxAttempts > 0 {
+		return g.MaxAttempts
+	}
+	return DefaultGangAttempts
+}
+
+type GangState string
+
+const (
+	// GangForming waits for every member to start and report its endpoint
+	GangForming GangState = "forming"
+	// GangRunning has every member started and the peers distributed
+	GangRunning   GangState = "running"
+	GangCompleted GangState = "completed"
+	// GangFailed ends an attempt. The gang is scheduled again with new member
+	// tasks until it runs out of attempts.
+	GangFailed GangState = "failed"
+)
+
+// GangJoin is what a runner reports when its member starts. The channel
+// fields are only set for gangs with a channel.
+type GangJoin struct {
+	Endpoint        string `json:"endpoint"`
+	ChannelEndpoint string `json:"channel_endpoint,omitempty"`
+	// ChannelKey is the base64 X25519 public key of the member for this task
+	ChannelKey string `json:"channel_key,omitempty"`
+}
+
+// GangMember is one rank of a gang. Each member is a task of its own.
+type GangMember struct {
+	Rank            int    `json:"rank"`
+	TaskID          string `json:"task_id"`
+	DeviceID        string `json:"device_id,omitempty"`
+	Endpoint        string `json:"endpoint,omitempty"`
+	ChannelEndpoint string `json:"channel_endpoint,omitempty"`
+	ChannelKey      string `json:"channel_key,omitempty"`
+	Done            bool   `json:"done,omitempty"`
+}
+
+// GangStatus is an attempt of a gang. Members asking for it get their own rank
+// in Self.
+type GangStatus struct {
+	GangID   string       `json:"gang_id"`
+	Attempt  int          `json:"attempt"`
+	State    GangState    `json:"state"`
+	Size     int          `json:"size"`
+	Port     int          `json:"port"`
+	Members  []GangMember `json:"members"`
+	Self     *GangMember  `json:"self,omitempty"`
+	StartBy  *time.Time   `json:"start_by,omitempty"`
+	Reason   string       `json:"reason,omitempty"`
+	Attempts int          `json:"attempts"`
+}
+
+// Peers are the endpoints of all members ordered by rank
+func (s *GangStatus) Peers() []string {
+	peers := make([]string, len(s.Members))
+	for _, member := range s.Members {
+		if member.Rank >= 0 && member.Rank < len(peers) {
+			peers[member.Rank] = member.Endpoint
+		}
+	}
+	return peers
+}
+
+// Env is how a member container learns about its gang
+func (s *GangStatus) Env() []string {
+	env := []string{
+		"PARITY_GANG_ID=" + s.GangID,
+		"PARITY_GANG_SIZE=" + strconv.Itoa(s.Size),
+		"PARITY_GANG_PORT=" + strconv.Itoa(s.Port),
+		"PARITY_GANG_PEERS=" + strings.Join(s.Peers(), ","),
+	}
+	if s.Self != nil {
+		env = append(env, "PARITY_GANG_RANK="+strconv.Itoa(s.Self.Rank))
+	}
+	return env
+}
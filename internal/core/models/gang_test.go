@@ -0,0 +1,52 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGangConfigValidate(t *testing.T) {
+	valid := GangConfig{Size: 4, StartWindowSeconds: 120, Port: 29400}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid gang refused: %v", err)
+	}
+	if valid.ListenPort() != 29400 || valid.Attempts() != DefaultGangAttempts {
+		t.Errorf("port = %d, attempts = %d", valid.ListenPort(), valid.Attempts())
+	}
+
+	for name, config := range map[string]GangConfig{
+		"single member": {Size: 1},
+		"too large":     {Size: maxGangSize + 1},
+		"bad port":      {Size: 2, Port: 70000},
+		"negative":      {Size: 2, StartWindowSeconds: -1},
+		"no channel":    {Size: 2, Port: 65535, Channel: true},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: expected the gang to be refused", name)
+		}
+	}
+}
+
+func TestGangStatusEnv(t *testing.T) {
+	status := &GangStatus{
+		GangID: "g",
+		Size:   2,
+		Port:   DefaultGangPort,
+		Members: []GangMember{
+			{Rank: 1, Endpoint: "10.0.0.2:29500"},
+			{Rank: 0, Endpoint: "10.0.0.1:29500"},
+		},
+	}
+	status.Self = &status.Members[0]
+
+	want := []string{
+		"PARITY_GANG_ID=g",
+		"PARITY_GANG_SIZE=2",
+		"PARITY_GANG_PORT=29500",
+		"PARITY_GANG_PEERS=10.0.0.1:29500,10.0.0.2:29500",
+		"PARITY_GANG_RANK=1",
+	}
+	if got := status.Env(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Env() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// GPUInfo describes one GPU of a runner as reported in its heartbeat
+type GPUInfo struct {
+	Index    int    `json:"index"`
+	UUID     string `json:"uuid,omitempty"`
+	Model    string `json:"model"`
+	MemoryMB int64  `json:"memory_mb"`
+}
+
+// GPURequirements is what a task needs from the runner's GPUs. Count defaults to
+// one GPU, Model matches a part of the GPU name such as "A100" and MinMemoryMB is
+// the VRAM each GPU must have.
+type GPURequirements struct {
+	Count       int    `json:"count,omitempty"`
+	Model       string `json:"model,omitempty"`
+	MinMemoryMB int64  `json:"min_memory_mb,omitempty"`
+}
+
+func (r GPURequirements) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *GPURequirements) Scan(value interface{}) error {
+	if value == nil {
+		*r = GPURequirements{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, r)
+}
+
+func (r *GPURequirements) Validate() error {
+	if r.Count < 0 {
+		return errors.New("gpu count cannot be negative")
+	}
+	if r.MinMemoryMB < 0 {
+		return errors.New("gpu memory cannot be negative")
+	}
+	return nil
+}
+
+func (r *GPURequirements) count() int {
+	if r.Count <= 0 {
+		return 1
+	}
+	return r.Count
+}
+
+// Select picks the GPUs the task runs on, in index order, and reports whether
+// enough of them meet the requirements
+func (r *GPURequirements) Select(gpus []GPUInfo) ([]GPUInfo, bool) {
+	want := r.count()
+	selected := make([]GPUInfo, 0, want)
+	for _, gpu := range gpus {
+		if r.Model != "" && !strings.Contains(strings.ToLower(gpu.Model), strings.ToLower(r.Model)) {
+			continue
+		}
+		if gpu.MemoryMB < r.MinMemoryMB {
+			continue
+		}
+		selected = append(selected, gpu)
+		if len(selected) == want {
+			return selected, true
+		}
+	}
+	return nil, false
+}
+
+// SatisfiedBy reports whether a runner with these GPUs can run the task
+func (r *GPURequirements) SatisfiedBy(gpus []GPUInfo) bool {
+	_, ok := r.Select(gpus)
+	return ok
+}
@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+func TestGPURequirementsSelect(t *testing.T) {
+	gpus := []GPUInfo{
+		{Index: 0, Model: "NVIDIA GeForce RTX 3060", MemoryMB: 12288},
+		{Index: 1, Model: "NVIDIA A100-SXM4-40GB", MemoryMB: 40960},
+		{Index: 2, Model: "NVIDIA A100-SXM4-80GB", MemoryMB: 81920},
+	}
+
+	cases := []struct {
+		name    string
+		req     GPURequirements
+		indexes []int
+	}{
+		{name: "any gpu", req: GPURequirements{}, indexes: []int{0}},
+		{name: "model", req: GPURequirements{Model: "a100"}, indexes: []int{1}},
+		{name: "memory", req: GPURequirements{Count: 2, MinMemoryMB: 40000}, indexes: []int{1, 2}},
+		{name: "too many", req: GPURequirements{Count: 4}},
+		{name: "not enough memory", req: GPURequirements{MinMemoryMB: 100000}},
+	}
+
+	for _, tc := range cases {
+		selected, ok := tc.req.Select(gpus)
+		if ok != (len(tc.indexes) > 0) {
+			t.Fatalf("%s: expected ok=%v, got %v", tc.name, len(tc.indexes) > 0, ok)
+		}
+		if len(selected) != len(tc.indexes) {
+			t.Fatalf("%s: expected %d GPUs, got %d", tc.name, len(tc.indexes), len(selected))
+		}
+		for i, gpu := range selected {
+			if gpu.Index != tc.indexes[i] {
+				t.Fatalf("%s: expected GPU %d at %d, got %d", tc.name, tc.indexes[i], i, gpu.Index)
+			}
+		}
+	}
+
+	if (&GPURequirements{}).SatisfiedBy(nil) {
+		t.Fatal("expected a runner without GPUs not to satisfy a GPU task")
+	}
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var ErrImageNotAllowed = errors.New("image not allowed by runner policy")
+
+var imageDigestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+const (
+	dockerHubRegistry = "docker.io"
+	defaultImageTag   = "latest"
+)
+
+// ImagePolicy restricts the images Docker tasks may run. When Registries is
+// set, images must come from one of them. RequireDigest only accepts images
+// pinned with @sha256:, and BlockedTags refuses tags such as latest on images
+// that are not. BlockedImages refuses repositories whatever their tag or
+// digest. An empty policy accepts every image.
+type ImagePolicy struct {
+	Registries    map[string]bool
+	RequireDigest bool
+	BlockedTags   map[string]bool
+	BlockedImages BlockedImages
+}
+
+// BlockedImages are repository patterns such as "alpine" or
+// "ghcr.io/untrusted/*", matched with path.Match against the full repository
+// name. Patterns without a registry refer to Docker Hub.
+type BlockedImages []string
+
+// ParseBlockedImages reads comma separated repository patterns
+func ParseBlockedImages(list string) (BlockedImages, error) {
+	var blocked BlockedImages
+	for _, pattern := range splitList(list) {
+		if strings.Contains(pattern, "@") {
+			return nil, fmt.Errorf("invalid blocked image %q, expected a repository without a digest", pattern)
+		}
+		pattern = repositoryName(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid blocked image %q: %w", pattern, err)
+		}
+		blocked = append(blocked, pattern)
+	}
+	return blocked, nil
+}
+
+// Match returns the pattern blocking the image, if any
+func (b BlockedImages) Match(image string) (string, bool) {
+	repository := repositoryName(image)
+	for _, pattern := range b {
+		if ok, _ := path.Match(pattern, repository); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// repositoryName is the image without tag or digest, qualified the way docker
+// resolves it, for example docker.io/library/alpine
+func repositoryName(image string) string {
+	name, _, _ := strings.Cut(image, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	registry := dockerHubRegistry
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		registry = normalizeRegistry(first)
+		name = rest
+	}
+	if registry == dockerHubRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	return registry + "/" + name
+}
+
+// ParseImagePolicy builds a policy from comma separated registries and tags
+func ParseImagePolicy(registries string, requireDigest bool, blockedTags string) (ImagePolicy, error) {
+	policy := ImagePolicy{RequireDigest: requireDigest}
+
+	for _, registry := range splitList(registries) {
+		if strings.Contains(registry, "/") {
+			return ImagePolicy{}, fmt.Errorf("invalid image registry %q, expected a host such as ghcr.io", registry)
+		}
+		if policy.Registries == nil {
+			policy.Registries = make(map[string]bool)
+		}
+		policy.Registries[normalizeRegistry(registry)] = true
+	}
+
+	for _, tag := range splitList(blockedTags) {
+		tag = strings.TrimPrefix(tag, ":")
+		if policy.BlockedTags == nil {
+			policy.BlockedTags = make(map[string]bool)
+		}
+		policy.BlockedTags[tag] = true
+	}
+
+	return policy, nil
+}
+
+// ImageReference is an image name split the way docker resolves it. Images
+// without a registry come from Docker Hub, and images without a tag or digest
+// are tagged latest.
+type ImageReference struct {
+	Registry string
+	Tag      string
+	Digest   string
+}
+
+func ParseImageReference(image string) (ImageReference, error) {
+	var ref ImageReference
+	name, digest, pinned := strings.Cut(image, "@")
+	if pinned {
+		if !imageDigestPattern.MatchString(digest) {
+			return ref, fmt.Errorf("invalid image digest %q", digest)
+		}
+		ref.Digest = digest
+	}
+	if name == "" {
+		return ref, errors.New("image name required")
+	}
+
+	ref.Registry = dockerHubRegistry
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry = normalizeRegistry(first)
+		name = rest
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+	} else if ref.Digest == "" {
+		ref.Tag = defaultImageTag
+	}
+	return ref, nil
+}
+
+func normalizeRegistry(registry string) string {
+	registry = strings.ToLower(registry)
+	if registry == "index.docker.io" || registry == "registry-1.docker.io" {
+		return dockerHubRegistry
+	}
+	return registry
+}
+
+// Check returns an error wrapping ErrImageNotAllowed when the policy rejects
+// the image
+func (p ImagePolicy) Check(image string) error {
+	if p.IsZero() {
+		return nil
+	}
+	ref, err := ParseImageReference(image)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrImageNotAllowed, err)
+	}
+	if len(p.Registries) > 0 && !p.Registries[ref.Registry] {
+		return fmt.Errorf("%w: registry %s of %s is not allowed", ErrImageNotAllowed, ref.Registry, image)
+	}
+	if pattern, blocked := p.BlockedImages.Match(image); blocked {
+		return fmt.Errorf("%w: %s is blocked by %s", ErrImageNotAllowed, image, pattern)
+	}
+	if ref.Digest != "" {
+		return nil
+	}
+	if p.RequireDigest {
+		return fmt.Errorf("%w: %s is not pinned to a digest", ErrImageNotAllowed, image)
+	}
+	if p.BlockedTags[ref.Tag] {
+		return fmt.Errorf("%w: tag %s of %s is blocked", ErrImageNotAllowed, ref.Tag, image)
+	}
+	return nil
+}
+
+func (p ImagePolicy) IsZero() bool {
+	return len(p.Registries) == 0 && !p.RequireDigest && len(p.BlockedTags) == 0 && len(p.BlockedImages) == 0
+}
+
+func (p ImagePolicy) String() string {
+	var parts []string
+	if len(p.Registries) > 0 {
+		parts = append(parts, "registries="+joinKeys(p.Registries))
+	}
+	if p.RequireDigest {
+		parts = append(parts, "digests=required")
+	}
+	if len(p.BlockedTags) > 0 {
+		parts = append(parts, "blocked_tags="+joinKeys(p.BlockedTags))
+	}
+	if len(p.BlockedImages) > 0 {
+		parts = append(parts, "blocked_images="+strings.Join(p.BlockedImages, ","))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testImageDigest = "sha256:" + strings.Repeat("ab", 32)
+
+func TestParseImageReference(t *testing.T) {
+	cases := map[string]ImageReference{
+		"ubuntu":                              {Registry: "docker.io", Tag: "latest"},
+		"library/python:3.12":                 {Registry: "docker.io", Tag: "3.12"},
+		"index.docker.io/acme/app:1":          {Registry: "docker.io", Tag: "1"},
+		"GHCR.io/acme/app@" + testImageDigest: {Registry: "ghcr.io", Digest: testImageDigest},
+		"localhost:5000/app":                  {Registry: "localhost:5000", Tag: "latest"},
+		"registry.local:5000/team/app:v2@" + testImageDigest: {Registry: "registry.local:5000", Tag: "v2", Digest: testImageDigest},
+	}
+	for image, want := range cases {
+		got, err := ParseImageReference(image)
+		if err != nil {
+			t.Fatalf("%s: %v", image, err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %+v, want %+v", image, got, want)
+		}
+	}
+
+	if _, err := ParseImageReference("app@sha256:short"); err == nil {
+		t.Fatal("expected an error for a malformed digest")
+	}
+}
+
+func TestImagePolicyCheck(t *testing.T) {
+	policy, err := ParseImagePolicy("ghcr.io, index.docker.io", false, ":latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		image string
+		want  bool
+	}{
+		{"ghcr.io/acme/app:1.2", true},
+		{"python:3.12", true},
+		{"python", false},
+		{"quay.io/acme/app:1", false},
+		{"ghcr.io/acme/app:latest@" + testImageDigest, true},
+	}
+	for _, tc := range cases {
+		err := policy.Check(tc.image)
+		if got := err == nil; got != tc.want {
+			t.Fatalf("%s: expected allowed=%v, got error %v", tc.image, tc.want, err)
+		}
+		if err != nil && !errors.Is(err, ErrImageNotAllowed) {
+			t.Fatalf("%s: expected ErrImageNotAllowed, got %v", tc.image, err)
+		}
+	}
+
+	policy.RequireDigest = true
+	if err := policy.Check("ghcr.io/acme/app:1.2"); err == nil {
+		t.Fatal("expected a tagged image to be refused when digests are required")
+	}
+	if err := policy.Check("ghcr.io/acme/app@" + testImageDigest); err != nil {
+		t.Fatalf("pinned image refused: %v", err)
+	}
+
+	if _, err := ParseImagePolicy("ghcr.io/acme", false, ""); err == nil {
+		t.Fatal("expected an error for a registry with a path")
+	}
+}
+
+func TestImagePolicyBlocksRepositories(t *testing.T) {
+	blocked, err := ParseBlockedImages("alpine, ghcr.io/untrusted/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := ImagePolicy{BlockedImages: blocked}
+
+	cases := map[string]bool{
+		"alpine":                     false,
+		"docker.io/library/alpine:3": false,
+		"alpine@" + testImageDigest:  false,
+		"ghcr.io/untrusted/app:1":    false,
+		"ghcr.io/trusted/app:1":      true,
+		"acme/alpine":                true,
+	}
+	for image, want := range cases {
+		err := policy.Check(image)
+		if got := err == nil; got != want {
+			t.Fatalf("%s: expected allowed=%v, got error %v", image, want, err)
+		}
+	}
+
+	if _, err := ParseBlockedImages("alpine@" + testImageDigest); err == nil {
+		t.Fatal("expected an error for a blocked image with a digest")
+	}
+}
+
+func TestRunnerPolicyChecksDockerImages(t *testing.T) {
+	policy := RunnerPolicy{Images: ImagePolicy{RequireDigest: true}}
+	config, _ := json.Marshal(TaskConfig{ImageName: "python:3.12"})
+
+	err := policy.Check(&Task{Type: TaskTypeDocker, Config: config})
+	if !errors.Is(err, ErrTaskNotAllowed) || !errors.Is(err, ErrImageNotAllowed) {
+		t.Fatalf("expected the image to be refused, got %v", err)
+	}
+	var violation *PolicyViolation
+	if !errors.As(err, &violation) || violation.Rule != PolicyRuleImage {
+		t.Fatalf("expected an image rule violation, got %v", err)
+	}
+	if err := policy.Check(&Task{Type: TaskTypeLLM}); err != nil {
+		t.Fatalf("image policy applied to an llm task: %v", err)
+	}
+}
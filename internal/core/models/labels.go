@@ -0,0 +1,119 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Labels are arbitrary key/value pairs attached to a task and used for routing.
+type Labels map[string]string
+
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return json.Marshal(map[string]string{})
+	}
+	return json.Marshal(map[string]string(l))
+}
+
+func (l *Labels) Scan(value interface{}) error {
+	if value == nil {
+		*l = Labels{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, l)
+}
+
+type LabelOperator string
+
+const (
+	LabelOperatorEquals    LabelOperator = "="
+	LabelOperatorNotEquals LabelOperator = "!="
+)
+
+type LabelRequirement struct {
+	Key      string
+	Operator LabelOperator
+	Value    string
+}
+
+func (r LabelRequirement) Matches(labels Labels) bool {
+	value, exists := labels[r.Key]
+	switch r.Operator {
+	case LabelOperatorEquals:
+		return exists && value == r.Value
+	case LabelOperatorNotEquals:
+		return !exists || value != r.Value
+	default:
+		return false
+	}
+}
+
+func (r LabelRequirement) String() string {
+	return r.Key + string(r.Operator) + r.Value
+}
+
+// LabelSelector is a conjunction of label requirements, e.g. "team=ml, tier!=experimental".
+// An empty selector matches every task.
+type LabelSelector []LabelRequirement
+
+// ParseLabelSelector parses a comma separated list of key=value and key!=value requirements
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	var requirements LabelSelector
+
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		operator := LabelOperatorEquals
+		key, value, found := strings.Cut(part, string(LabelOperatorNotEquals))
+		if found {
+			operator = LabelOperatorNotEquals
+		} else if key, value, found = strings.Cut(part, string(LabelOperatorEquals)); !found {
+			return nil, fmt.Errorf("invalid label requirement %q: expected key=value or key!=value", part)
+		}
+
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if key == "" {
+			return nil, fmt.Errorf("invalid label requirement %q: key is required", part)
+		}
+
+		requirements = append(requirements, LabelRequirement{
+			Key:      key,
+			Operator: operator,
+			Value:    value,
+		})
+	}
+
+	return requirements, nil
+}
+
+func (s LabelSelector) Matches(labels Labels) bool {
+	for _, requirement := range s {
+		if !requirement.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s LabelSelector) String() string {
+	parts := make([]string, len(s))
+	for i, requirement := range s {
+		parts[i] = requirement.String()
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,51 @@
+package models
+
+import "testing"
+
+func TestParseLabelSelector(t *testing.T) {
+	selector, err := ParseLabelSelector("team=ml, tier!=experimental")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	if len(selector) != 2 {
+		t.Fatalf("expected 2 requirements, got %d", len(selector))
+	}
+
+	cases := []struct {
+		name   string
+		labels Labels
+		want   bool
+	}{
+		{name: "matching team", labels: Labels{"team": "ml", "tier": "prod"}, want: true},
+		{name: "missing tier", labels: Labels{"team": "ml"}, want: true},
+		{name: "experimental tier", labels: Labels{"team": "ml", "tier": "experimental"}, want: false},
+		{name: "other team", labels: Labels{"team": "web"}, want: false},
+		{name: "no labels", labels: nil, want: false},
+	}
+
+	for _, tc := range cases {
+		if got := selector.Matches(tc.labels); got != tc.want {
+			t.Fatalf("%s: expected match=%v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestParseLabelSelectorEmptyMatchesEverything(t *testing.T) {
+	selector, err := ParseLabelSelector("  ")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	if !selector.Matches(Labels{"team": "ml"}) || !selector.Matches(nil) {
+		t.Fatal("expected empty selector to match all label sets")
+	}
+}
+
+func TestParseLabelSelectorRejectsInvalidRequirement(t *testing.T) {
+	for _, input := range []string{"team", "=ml", "team=ml,tier"} {
+		if _, err := ParseLabelSelector(input); err == nil {
+			t.Fatalf("expected error for selector %q", input)
+		}
+	}
+}
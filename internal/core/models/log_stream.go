@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+const (
+	LogStreamStdout = "stdout"
+	LogStreamStderr = "stderr"
+)
+
+// LogChunk is a piece of a running task's output the runner pushes to the
+// server. Seq numbers the chunks of a task from 1, so the server can drop the
+// ones a retried request delivers again.
+type LogChunk struct {
+	Seq    int64     `json:"seq"`
+	Stream string    `json:"stream"`
+	Data   string    `json:"data"`
+	Time   time.Time `json:"time"`
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ManifestVersion = 1
+	// ManifestPath is where a runner's webhook server serves its manifest
+	ManifestPath = "/manifest"
+)
+
+// RunnerManifest is a runner's signed statement of what it runs and for how
+// much. It is served by the runner and sent with its registration and
+// heartbeats, so the server matches and prices tasks from one document. The
+// runner signs everything except Signature with its wallet key.
+type RunnerManifest struct {
+	Version       int                `json:"version"`
+	DeviceID      string             `json:"device_id"`
+	WalletAddress string             `json:"wallet_address"`
+	TaskTypes     []TaskType         `json:"task_types"`
+	Models        []ModelCapability  `json:"models,omitempty"`
+	Hardware      RunnerHardware     `json:"hardware"`
+	MinRewards    MinRewards         `json:"min_rewards,omitempty"`
+	Availability  RunnerAvailability `json:"availability"`
+	IssuedAt      time.Time          `json:"issued_at"`
+	Signature     string             `json:"signature,omitempty"`
+}
+
+type RunnerHardware struct {
+	CPUs        int       `json:"cpus"`
+	MemoryBytes int64     `json:"memory_bytes"`
+	GPUs        []GPUInfo `json:"gpus,omitempty"`
+	// Runtime is the container engine Docker tasks run in, empty when the
+	// runner has none
+	Runtime     string `json:"runtime,omitempty"`
+	VMIsolation bool   `json:"vm_isolation,omitempty"`
+	// TEE is the trusted execution environment the runner runs in, if any
+	TEE TEETechnology `json:"tee,omitempty"`
+}
+
+// RunnerAvailability is how much work a runner takes when the manifest is
+// issued. Accepting is false while it takes no work at all, such as an idle-mode
+// runner whose user is present. Slots are the tasks it can start right away.
+type RunnerAvailability struct {
+	Accepting bool `json:"accepting"`
+	Slots     int  `json:"slots"`
+	Running   int  `json:"running"`
+}
+
+// Accepts reports whether the runner runs tasks of the type
+func (m *RunnerManifest) Accepts(taskType TaskType) bool {
+	return slices.Contains(m.TaskTypes, taskType)
+}
+
+// MinReward is the lowest reward a runner takes for a task type, or for one
+// model of it when Model is set
+type MinReward struct {
+	Type   TaskType `json:"type"`
+	Model  string   `json:"model,omitempty"`
+	Reward float64  `json:"reward"`
+}
+
+type MinRewards []MinReward
+
+// ParseMinRewards parses comma separated type=reward pairs. A type followed by
+// :model prices one model, as in llm:llama3:8b=0.05.
+func ParseMinRewards(list string) (MinRewards, error) {
+	var rewards MinRewards
+	for _, item := range splitList(list) {
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid minimum reward %q, expected type=reward", item)
+		}
+		class := item[:i]
+		reward, err := strconv.ParseFloat(strings.TrimSpace(item[i+1:]), 64)
+		if err != nil || reward < 0 {
+			return nil, fmt.Errorf("invalid minimum reward %q", item)
+		}
+		name, model, _ := strings.Cut(strings.TrimSpace(class), ":")
+		taskType, err := parseTaskType(name)
+		if err != nil {
+			return nil, err
+		}
+		rewards = append(rewards, MinReward{Type: taskType, Model: model, Reward: reward})
+	}
+	return rewards, nil
+}
+
+// For returns the minimum reward for a task class. An entry for the model wins
+// over one for the whole type.
+func (r MinRewards) For(taskType TaskType, model string) float64 {
+	var min float64
+	for _, reward := range r {
+		if reward.Type != taskType {
+			continue
+		}
+		if reward.Model == "" {
+			min = reward.Reward
+		} else if model != "" && reward.Model == model {
+			return reward.Reward
+		}
+	}
+	return min
+}
+
+// ForTask returns the minimum reward for the class of the task
+func (r MinRewards) ForTask(task *Task) float64 {
+	if len(r) == 0 {
+		return 0
+	}
+	return r.For(task.Type, TaskModel(task))
+}
+
+func (r MinRewards) String() string {
+	parts := make([]string, 0, len(r))
+	for _, reward := range r {
+		class := string(reward.Type)
+		if reward.Model != "" {
+			class += ":" + reward.Model
+		}
+		parts = append(parts, class+"="+strconv.FormatFloat(reward.Reward, 'f', -1, 64))
+	}
+	return strings.Join(parts, ",")
+}
+
+// TaskModel is the LLM model a task asks for, empty for other tasks
+func TaskModel(task *Task) string {
+	var config struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return ""
+	}
+	return config.Model
+}
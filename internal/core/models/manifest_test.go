@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMinRewards(t *testing.T) {
+	rewards, err := ParseMinRewards("docker=0.01, llm=0.02, llm:llama3:8b=0.05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewards.String() != "docker=0.01,llm=0.02,llm:llama3:8b=0.05" {
+		t.Fatalf("rewards = %s", rewards)
+	}
+
+	cases := []struct {
+		taskType TaskType
+		model    string
+		want     float64
+	}{
+		{TaskTypeDocker, "", 0.01},
+		{TaskTypeLLM, "mistral", 0.02},
+		{TaskTypeLLM, "llama3:8b", 0.05},
+		{TaskTypeWasm, "", 0},
+	}
+	for _, tc := range cases {
+		if got := rewards.For(tc.taskType, tc.model); got != tc.want {
+			t.Errorf("For(%s, %q) = %v, want %v", tc.taskType, tc.model, got, tc.want)
+		}
+	}
+
+	for _, invalid := range []string{"docker", "docker=abc", "docker=-1", "shell=0.1"} {
+		if _, err := ParseMinRewards(invalid); err == nil {
+			t.Errorf("ParseMinRewards(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestRunnerPolicyRefusesTasksBelowMinReward(t *testing.T) {
+	rewards, err := ParseMinRewards("llm=0.02,llm:llama3:8b=0.05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := RunnerPolicy{MinRewards: rewards}
+
+	if err := policy.Check(&Task{Type: TaskTypeLLM, Reward: 0.03, Config: []byte(`{"model":"mistral"}`)}); err != nil {
+		t.Fatalf("task paying above the minimum refused: %v", err)
+	}
+	err = policy.Check(&Task{Type: TaskTypeLLM, Reward: 0.03, Config: []byte(`{"model":"llama3:8b"}`)})
+	if !errors.Is(err, ErrTaskNotAllowed) {
+		t.Fatalf("task paying below the model minimum = %v, want ErrTaskNotAllowed", err)
+	}
+	if err := policy.Check(&Task{Type: TaskTypeDocker}); err != nil {
+		t.Fatalf("task type without a minimum refused: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import "github.com/google/uuid"
+
+// TaskMetrics is the resource usage recorded for a single task execution
+type TaskMetrics struct {
+	TaskID          uuid.UUID `json:"task_id"`
+	DeviceID        string    `json:"device_id"`
+	CreatorAddress  string    `json:"creator_address"`
+	ExecutionTimeMs int64     `json:"execution_time_ms"`
+	CPUSeconds      float64   `json:"cpu_seconds"`
+	EstimatedCycles uint64    `json:"estimated_cycles"`
+	MemoryGBHours   float64   `json:"memory_gb_hours"`
+	PeakMemoryGB    float64   `json:"peak_memory_gb"`
+	StorageGB       float64   `json:"storage_gb"`
+	NetworkDataGB   float64   `json:"network_data_gb"`
+}
+
+func MetricsFromResult(result *TaskResult) TaskMetrics {
+	return TaskMetrics{
+		TaskID:          result.TaskID,
+		DeviceID:        result.DeviceID,
+		CreatorAddress:  result.CreatorAddress,
+		ExecutionTimeMs: result.ExecutionTime,
+		CPUSeconds:      result.CPUSeconds,
+		EstimatedCycles: result.EstimatedCycles,
+		MemoryGBHours:   result.MemoryGBHours,
+		PeakMemoryGB:    result.PeakMemoryGB,
+		StorageGB:       result.StorageGB,
+		NetworkDataGB:   result.NetworkDataGB,
+	}
+}
+
+// ResourceUsageSummary aggregates task metrics for a runner or creator
+type ResourceUsageSummary struct {
+	TaskCount            int     `json:"task_count"`
+	TotalExecutionTimeMs int64   `json:"total_execution_time_ms"`
+	TotalCPUSeconds      float64 `json:"total_cpu_seconds"`
+	TotalCycles          uint64  `json:"total_estimated_cycles"`
+	TotalMemoryGBHours   float64 `json:"total_memory_gb_hours"`
+	MaxPeakMemoryGB      float64 `json:"max_peak_memory_gb"`
+	TotalStorageGB       float64 `json:"total_storage_gb"`
+	TotalNetworkDataGB   float64 `json:"total_network_data_gb"`
+}
+
+func (s *ResourceUsageSummary) Add(metrics TaskMetrics) {
+	s.TaskCount++
+	s.TotalExecutionTimeMs += metrics.ExecutionTimeMs
+	s.TotalCPUSeconds += metrics.CPUSeconds
+	s.TotalCycles += metrics.EstimatedCycles
+	s.TotalMemoryGBHours += metrics.MemoryGBHours
+	s.TotalStorageGB += metrics.StorageGB
+	s.TotalNetworkDataGB += metrics.NetworkDataGB
+	if metrics.PeakMemoryGB > s.MaxPeakMemoryGB {
+		s.MaxPeakMemoryGB = metrics.PeakMemoryGB
+	}
+}
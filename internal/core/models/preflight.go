@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PreflightConfig is the task a preflight task checks. A runner validates its
+// config against the executor, pulls its image and fetches the content it
+// references without running it.
+type PreflightConfig struct {
+	Type            TaskType           `json:"type"`
+	Config          json.RawMessage    `json:"config"`
+	Environment     *EnvironmentConfig `json:"environment,omitempty"`
+	GPU             *GPURequirements   `json:"gpu,omitempty"`
+	IsolationLevel  IsolationLevel     `json:"isolation_level,omitempty"`
+	MaxDurationSecs int64              `json:"max_duration_seconds,omitempty"`
+}
+
+func (p *PreflightConfig) Validate() error {
+	switch p.Type {
+	case "":
+		return errors.New("preflight needs the type of the task it checks")
+	case TaskTypePreflight:
+		return errors.New("a preflight task cannot check another preflight task")
+	}
+	if len(p.Config) == 0 {
+		return errors.New("preflight needs the config of the task it checks")
+	}
+	return nil
+}
+
+// Task returns the checked task as it would be dispatched
+func (p *PreflightConfig) Task(nonce string) *Task {
+	task := NewTask()
+	task.Title = "preflight"
+	task.Type = p.Type
+	task.Config = p.Config
+	task.Environment = p.Environment
+	task.GPU = p.GPU
+	task.IsolationLevel = p.IsolationLevel
+	task.MaxDurationSecs = p.MaxDurationSecs
+	task.Nonce = nonce
+	return task
+}
+
+// NewPreflightTask creates the preflight task that checks task. It is offered
+// to the runners the task itself is.
+func NewPreflightTask(task *Task) (*Task, error) {
+	config, err := json.Marshal(TaskConfig{Preflight: &PreflightConfig{
+		Type:            task.Type,
+		Config:          task.Config,
+		Environment:     task.Environment,
+		GPU:             task.GPU,
+		IsolationLevel:  task.IsolationLevel,
+		MaxDurationSecs: task.MaxDurationSecs,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preflight config: %w", err)
+	}
+
+	preflight := NewTask()
+	preflight.Title = "Preflight: " + task.Title
+	preflight.Type = TaskTypePreflight
+	preflight.Config = config
+	preflight.Labels = task.Labels
+	preflight.CreatorAddress = task.CreatorAddress
+	preflight.CreatorDeviceID = task.CreatorDeviceID
+	preflight.Nonce = task.Nonce
+	return preflight, nil
+}
+
+type PreflightStatus string
+
+const (
+	PreflightPassed PreflightStatus = "passed"
+	PreflightFailed PreflightStatus = "failed"
+	// PreflightSkipped checks could not run on the runner that ran the preflight,
+	// for example because it has no container runtime
+	PreflightSkipped PreflightStatus = "skipped"
+)
+
+type PreflightCheck struct {
+	Name       string          `json:"name"`
+	Status     PreflightStatus `json:"status"`
+	Detail     string          `json:"detail,omitempty"`
+	DurationMs int64           `json:"duration_ms"`
+}
+
+// PreflightReport is the output of a preflight task. Ready is set when no check
+// failed.
+type PreflightReport struct {
+	Ready    bool             `json:"ready"`
+	TaskType TaskType         `json:"task_type"`
+	Checks   []PreflightCheck `json:"checks"`
+}
+
+// Problems lists the failed checks
+func (r *PreflightReport) Problems() []string {
+	var problems []string
+	for _, check := range r.Checks {
+		if check.Status == PreflightFailed {
+			problems = append(problems, check.Name+": "+check.Detail)
+		}
+	}
+	return problems
+}
+
+// ParsePreflightReport reads the report from a preflight task's result
+func ParsePreflightReport(result *TaskResult) (*PreflightReport, error) {
+	var report PreflightReport
+	if err := json.Unmarshal([]byte(strings.TrimSpace(result.Output)), &report); err != nil {
+		if result.Error != "" {
+			return nil, errors.New(result.Error)
+		}
+		return nil, fmt.Errorf("invalid preflight report: %w", err)
+	}
+	return &report, nil
+}
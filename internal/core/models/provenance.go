@@ -0,0 +1,39 @@
+package models
+
+import "fmt"
+
+const (
+	// ProvenanceVersion names how the signed statement of a build is derived
+	ProvenanceVersion = 2
+	// VersionPath is where a runner's webhook server serves its build provenance
+	VersionPath = "/version"
+)
+
+// BuildProvenance describes the build a runner binary came from, as the binary
+// reports it. Release builds carry a signature by a release key over the
+// version, commit, tree state, builder and attestation. The signature shows
+// what was released, not that the binary reporting it is that release.
+type BuildProvenance struct {
+	RunnerVersion string `json:"runner_version"`
+	Commit        string `json:"commit,omitempty"`
+	// Dirty is set when the binary was built from a tree with local changes
+	Dirty   bool   `json:"dirty,omitempty"`
+	Builder string `json:"builder,omitempty"`
+	// Attestation references the SLSA provenance of the release, such as the
+	// URL of its in-toto attestation
+	Attestation string `json:"attestation,omitempty"`
+	GoVersion   string `json:"go_version,omitempty"`
+	// Signature is the release key's signature over Statement, empty for
+	// builds that were not released
+	Signature string `json:"signature,omitempty"`
+}
+
+// Statement is what a release key signs for the build
+func (p *BuildProvenance) Statement() []byte {
+	return []byte(fmt.Sprintf("parity-provenance-v%d\n%s\n%s\n%t\n%s\n%s", ProvenanceVersion, p.RunnerVersion, p.Commit, p.Dirty, p.Builder, p.Attestation))
+}
+
+// Signed reports whether the build claims to be a signed release
+func (p *BuildProvenance) Signed() bool {
+	return p != nil && p.Signature != ""
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const ReceiptVersion = 1
+
+type ReceiptMetrics struct {
+	CPUSeconds      float64 `json:"cpu_seconds"`
+	EstimatedCycles uint64  `json:"estimated_cycles"`
+	MemoryGBHours   float64 `json:"memory_gb_hours"`
+	StorageGB       float64 `json:"storage_gb"`
+	NetworkDataGB   float64 `json:"network_data_gb"`
+}
+
+// ExecutionReceipt is a compact, signed proof that a runner executed a task.
+// The runner signs everything except the signature fields; the server countersigns
+// the runner-signed receipt.
+type ExecutionReceipt struct {
+	Version         int            `json:"version"`
+	TaskID          uuid.UUID      `json:"task_id"`
+	TaskType        TaskType       `json:"task_type"`
+	ImageDigest     string         `json:"image_digest,omitempty"`
+	ModelDigest     string         `json:"model_digest,omitempty"`
+	InputHash       string         `json:"input_hash"`
+	OutputHash      string         `json:"output_hash"`
+	ExitCode        int            `json:"exit_code"`
+	ExecutionTimeMs int64          `json:"execution_time_ms"`
+	Metrics         ReceiptMetrics `json:"metrics"`
+	DeviceID        string         `json:"device_id"`
+	RunnerAddress   string         `json:"runner_address"`
+	IssuedAt        time.Time      `json:"issued_at"`
+	RunnerSignature string         `json:"runner_signature,omitempty"`
+	ServerAddress   string         `json:"server_address,omitempty"`
+	ServerSignature string         `json:"server_signature,omitempty"`
+}
+
+func (r ExecutionReceipt) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *ExecutionReceipt) Scan(value interface{}) error {
+	if value == nil {
+		*r = ExecutionReceipt{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, r)
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// OutputUploadName names the upload that replaces a result's inline output
+	OutputUploadName = "output"
+	// OutputsBundleName names the gzipped tar of a Docker task's declared
+	// output paths
+	OutputsBundleName = "outputs.tar.gz"
+	maxOutputPaths    = 16
+)
+
+// ResultUpload is a part of a result sent besides its output: task output too
+// large to post, a file the task wrote to its output directory, or the bundle
+// of its declared outputs. It is on IPFS under CID, or small enough to be
+// inline in Data.
+type ResultUpload struct {
+	// Name is OutputUploadName, OutputsBundleName or the path of the file in
+	// the output directory
+	Name   string `json:"name"`
+	CID    string `json:"cid,omitempty"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	Data   []byte `json:"data,omitempty"`
+}
+
+// ResultUploads lists the uploads of a result
+type ResultUploads struct {
+	Files []ResultUpload `json:"files"`
+}
+
+// Output is the upload holding the task output, if it was uploaded
+func (u *ResultUploads) Output() (ResultUpload, bool) {
+	if u == nil {
+		return ResultUpload{}, false
+	}
+	for _, file := range u.Files {
+		if file.Name == OutputUploadName {
+			return file, true
+		}
+	}
+	return ResultUpload{}, false
+}
+
+func (u *ResultUploads) Validate() error {
+	seen := make(map[string]bool, len(u.Files))
+	for _, file := range u.Files {
+		if file.Name == "" || strings.HasPrefix(file.Name, "/") || strings.Contains(file.Name, "..") {
+			return fmt.Errorf("invalid upload name %q", file.Name)
+		}
+		if seen[file.Name] {
+			return fmt.Errorf("duplicate upload %q", file.Name)
+		}
+		seen[file.Name] = true
+		if (file.CID == "") == (file.Data == nil) {
+			return fmt.Errorf("upload %q needs either a cid or inline data", file.Name)
+		}
+		if !sha256Pattern.MatchString(file.SHA256) {
+			return fmt.Errorf("upload %q has an invalid sha256", file.Name)
+		}
+		if file.Size < 0 {
+			return fmt.Errorf("upload %q has a negative size", file.Name)
+		}
+		if file.Data != nil {
+			sum := sha256.Sum256(file.Data)
+			if int64(len(file.Data)) != file.Size || hex.EncodeToString(sum[:]) != strings.ToLower(file.SHA256) {
+				return fmt.Errorf("inline data of upload %q does not match its size and sha256", file.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func validateOutputPaths(paths []string) error {
+	if len(paths) > maxOutputPaths {
+		return fmt.Errorf("at most %d outputs can be declared", maxOutputPaths)
+	}
+	seen := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		if !path.IsAbs(p) || path.Clean(p) != p || p == "/" {
+			return fmt.Errorf("output %q must be a clean absolute path below /", p)
+		}
+		if seen[p] {
+			return fmt.Errorf("output %q is declared twice", p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+func (u ResultUploads) Value() (driver.Value, error) {
+	return json.Marshal(u)
+}
+
+func (u *ResultUploads) Scan(value interface{}) error {
+	if value == nil {
+		*u = ResultUploads{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, u)
+}
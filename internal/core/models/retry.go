@@ -0,0 +1,125 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrImagePull is returned when a task's image could not be pulled, which is
+// usually the registry or the network and worth another attempt
+var ErrImagePull = errors.New("image pull failed")
+
+const (
+	maxTaskRetries = 10
+	// DefaultRetryBackoff is how long the first retry waits when the policy
+	// sets no backoff
+	DefaultRetryBackoff = 10 * time.Second
+	// DefaultMaxRetryBackoff caps the wait between attempts when the policy
+	// sets no max_backoff
+	DefaultMaxRetryBackoff = 5 * time.Minute
+)
+
+// Causes of a transient failure, for which a task is run again
+const (
+	RetryCauseImagePull = "image_pull"
+	RetryCauseOOMKilled = "oom_killed"
+	RetryCauseExitCode  = "exit_code"
+)
+
+// RetryPolicy has the runner run a Docker task again when an attempt fails for
+// a reason that may not recur: its image could not be pulled, its container
+// was killed for running out of memory, or it exited with one of
+// RetryableExitCodes. Any other failure is taken to be the task's own and
+// reported at once. The first retry waits Backoff, each one after that twice
+// as long, up to MaxBackoff.
+type RetryPolicy struct {
+	MaxRetries         int    `json:"max_retries"`
+	RetryableExitCodes []int  `json:"retryable_exit_codes,omitempty"`
+	Backoff            string `json:"backoff,omitempty"`
+	MaxBackoff         string `json:"max_backoff,omitempty"`
+}
+
+func (p *RetryPolicy) Validate() error {
+	if p.MaxRetries < 0 || p.MaxRetries > maxTaskRetries {
+		return fmt.Errorf("retry max_retries must be between 0 and %d", maxTaskRetries)
+	}
+	for _, code := range p.RetryableExitCodes {
+		if code < 1 || code > 255 {
+			return fmt.Errorf("invalid retryable exit code %d", code)
+		}
+	}
+	for name, value := range map[string]string{"backoff": p.Backoff, "max_backoff": p.MaxBackoff} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid retry %s %q", name, value)
+		}
+	}
+	return nil
+}
+
+// Delay is how long to wait before the retry-th retry, counting from 1
+func (p *RetryPolicy) Delay(retry int) time.Duration {
+	backoff, err := time.ParseDuration(p.Backoff)
+	if err != nil || backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	maxBackoff, err := time.ParseDuration(p.MaxBackoff)
+	if err != nil || maxBackoff <= 0 {
+		maxBackoff = DefaultMaxRetryBackoff
+	}
+	delay := backoff
+	for i := 1; i < retry && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// RetryableExit reports whether the task exiting with code is worth another
+// attempt
+func (p *RetryPolicy) RetryableExit(code int) bool {
+	for _, retryable := range p.RetryableExitCodes {
+		if code == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// TaskAttempt is one run of a task with a retry policy. Cause is set when the
+// attempt failed for a transient reason, BackoffMs when another attempt
+// followed after that long.
+type TaskAttempt struct {
+	Attempt    int       `json:"attempt"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Error      string    `json:"error,omitempty"`
+	Cause      string    `json:"cause,omitempty"`
+	BackoffMs  int64     `json:"backoff_ms,omitempty"`
+}
+
+// TaskAttempts are the runs of a task, the first one first
+type TaskAttempts []TaskAttempt
+
+func (a TaskAttempts) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+func (a *TaskAttempts) Scan(value interface{}) error {
+	if value == nil {
+		*a = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, a)
+}
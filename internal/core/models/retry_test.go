@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyValidate(t *testing.T) {
+	valid := RetryPolicy{MaxRetries: 3, RetryableExitCodes: []int{75}, Backoff: "30s", MaxBackoff: "2m"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid policy refused: %v", err)
+	}
+	if !valid.RetryableExit(75) || valid.RetryableExit(1) {
+		t.Errorf("retryable exit codes = %v", valid.RetryableExitCodes)
+	}
+
+	for name, policy := range map[string]RetryPolicy{
+		"negative":    {MaxRetries: -1},
+		"too many":    {MaxRetries: maxTaskRetries + 1},
+		"exit code":   {MaxRetries: 1, RetryableExitCodes: []int{0}},
+		"backoff":     {MaxRetries: 1, Backoff: "soon"},
+		"max backoff": {MaxRetries: 1, MaxBackoff: "-1s"},
+	} {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("%s: expected the policy to be refused", name)
+		}
+	}
+}
+
+func TestRetryPolicyDelayDoublesUpToTheMaximum(t *testing.T) {
+	policy := RetryPolicy{Backoff: "30s", MaxBackoff: "2m"}
+	for retry, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 8: 2 * time.Minute} {
+		if got := policy.Delay(retry); got != want {
+			t.Errorf("Delay(%d) = %s, want %s", retry, got, want)
+		}
+	}
+	if got := (&RetryPolicy{}).Delay(1); got != DefaultRetryBackoff {
+		t.Errorf("default delay = %s, want %s", got, DefaultRetryBackoff)
+	}
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrTaskNotAllowed = errors.New("task not allowed by runner policy")
+
+// PolicyRule names the rule of the runner policy that refused a task
+type PolicyRule string
+
+const (
+	PolicyRuleTaskType     PolicyRule = "task_type"
+	PolicyRuleImage        PolicyRule = "image"
+	PolicyRuleMinReward    PolicyRule = "min_reward"
+	PolicyRuleMaxDuration  PolicyRule = "max_duration"
+	PolicyRuleWorkingHours PolicyRule = "working_hours"
+	PolicyRuleCreator      PolicyRule = "creator"
+)
+
+// PolicyViolation is the error the runner policy refuses a task with. It wraps
+// ErrTaskNotAllowed and the error of the rule, if any.
+type PolicyViolation struct {
+	Rule   PolicyRule
+	Detail string
+	err    error
+}
+
+func (v *PolicyViolation) Error() string {
+	return ErrTaskNotAllowed.Error() + ": " + v.Detail
+}
+
+func (v *PolicyViolation) Unwrap() []error {
+	if v.err == nil {
+		return []error{ErrTaskNotAllowed}
+	}
+	return []error{ErrTaskNotAllowed, v.err}
+}
+
+func violation(rule PolicyRule, format string, args ...any) *PolicyViolation {
+	return &PolicyViolation{Rule: rule, Detail: fmt.Sprintf(format, args...)}
+}
+
+var walletAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// RunnerPolicy is the work a runner operator accepts. TaskTypes limits the kinds
+// of task. When TrustedCreators or TrustedNamespaces are set, a task must come
+// from one of the creator wallets or carry one of the namespaces. Images limits
+// the images of Docker tasks and MinRewards refuses tasks paying less than the
+// operator asks for their class. MaxDuration refuses tasks that may run longer,
+// including those that set no limit, and WorkingHours refuses tasks arriving
+// outside its windows. Empty settings accept everything.
+type RunnerPolicy struct {
+	TaskTypes         map[TaskType]bool
+	TrustedCreators   map[string]bool
+	TrustedNamespaces map[string]bool
+	Images            ImagePolicy
+	MinRewards        MinRewards
+	MaxDuration       time.Duration
+	WorkingHours      WorkingHours
+}
+
+// ParseRunnerPolicy builds a policy from comma separated task types, creator
+// wallet addresses and namespaces
+func ParseRunnerPolicy(taskTypes, creators, namespaces string) (RunnerPolicy, error) {
+	var policy RunnerPolicy
+
+	for _, name := range splitList(taskTypes) {
+		taskType, err := parseTaskType(name)
+		if err != nil {
+			return RunnerPolicy{}, err
+		}
+		if policy.TaskTypes == nil {
+			policy.TaskTypes = make(map[TaskType]bool)
+		}
+		policy.TaskTypes[taskType] = true
+	}
+
+	for _, address := range splitList(creators) {
+		if !walletAddressPattern.MatchString(address) {
+			return RunnerPolicy{}, fmt.Errorf("invalid creator wallet address %q", address)
+		}
+		if policy.TrustedCreators == nil {
+			policy.TrustedCreators = make(map[string]bool)
+		}
+		policy.TrustedCreators[strings.ToLower(address)] = true
+	}
+
+	for _, namespace := range splitList(namespaces) {
+		if policy.TrustedNamespaces == nil {
+			policy.TrustedNamespaces = make(map[string]bool)
+		}
+		policy.TrustedNamespaces[namespace] = true
+	}
+
+	return policy, nil
+}
+
+func parseTaskType(name string) (TaskType, error) {
+	taskType := TaskType(strings.ToLower(name))
+	switch taskType {
+	case TaskTypeDocker, TaskTypeCommand, TaskTypeLLM, TaskTypeFederatedLearning, TaskTypeWasm, TaskTypePreflight:
+		return taskType, nil
+	}
+	return "", fmt.Errorf("unknown task type %q", name)
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Check returns a *PolicyViolation when the policy rejects the task now
+func (p RunnerPolicy) Check(task *Task) error {
+	return p.CheckAt(task, time.Now())
+}
+
+// CheckAt returns a *PolicyViolation when the policy rejects the task arriving
+// at the given time
+func (p RunnerPolicy) CheckAt(task *Task, at time.Time) error {
+	if len(p.TaskTypes) > 0 && !p.TaskTypes[task.Type] {
+		return violation(PolicyRuleTaskType, "%s tasks are not accepted", task.Type)
+	}
+	if !p.WorkingHours.Contains(at) {
+		return violation(PolicyRuleWorkingHours, "tasks are only accepted during %s", p.WorkingHours)
+	}
+	if task.Type == TaskTypeDocker && !p.Images.IsZero() {
+		var config TaskConfig
+		if err := json.Unmarshal(task.Config, &config); err != nil {
+			return violation(PolicyRuleImage, "invalid task config: %v", err)
+		}
+		if err := p.Images.Check(config.ImageName); err != nil {
+			v := violation(PolicyRuleImage, "%v", err)
+			v.err = err
+			return v
+		}
+	}
+	if min := p.MinRewards.ForTask(task); task.Reward < min {
+		return violation(PolicyRuleMinReward, "reward %.8f is below the minimum of %.8f", task.Reward, min)
+	}
+	if p.MaxDuration > 0 {
+		duration := task.EstimatedDuration()
+		if duration <= 0 {
+			return violation(PolicyRuleMaxDuration, "task sets no timeout, the runner accepts at most %s", p.MaxDuration)
+		}
+		if duration > p.MaxDuration {
+			return violation(PolicyRuleMaxDuration, "task may run %s, the runner accepts at most %s", duration, p.MaxDuration)
+		}
+	}
+
+	if len(p.TrustedCreators) == 0 && len(p.TrustedNamespaces) == 0 {
+		return nil
+	}
+	if p.TrustedCreators[strings.ToLower(task.CreatorAddress)] {
+		return nil
+	}
+	if namespace := task.Namespace(); namespace != "" && p.TrustedNamespaces[namespace] {
+		return nil
+	}
+	return violation(PolicyRuleCreator, "creator %q and namespace %q are not trusted", task.CreatorAddress, task.Namespace())
+}
+
+func (p RunnerPolicy) IsZero() bool {
+	return len(p.TaskTypes) == 0 && len(p.TrustedCreators) == 0 && len(p.TrustedNamespaces) == 0 && p.Images.IsZero() && len(p.MinRewards) == 0 &&
+		p.MaxDuration <= 0 && len(p.WorkingHours) == 0
+}
+
+func (p RunnerPolicy) String() string {
+	var parts []string
+	if len(p.TaskTypes) > 0 {
+		parts = append(parts, "types="+joinKeys(p.TaskTypes))
+	}
+	if len(p.TrustedCreators) > 0 {
+		parts = append(parts, "creators="+joinKeys(p.TrustedCreators))
+	}
+	if len(p.TrustedNamespaces) > 0 {
+		parts = append(parts, "namespaces="+joinKeys(p.TrustedNamespaces))
+	}
+	if !p.Images.IsZero() {
+		parts = append(parts, p.Images.String())
+	}
+	if len(p.MinRewards) > 0 {
+		parts = append(parts, "min_rewards="+p.MinRewards.String())
+	}
+	if p.MaxDuration > 0 {
+		parts = append(parts, "max_duration="+p.MaxDuration.String())
+	}
+	if len(p.WorkingHours) > 0 {
+		parts = append(parts, "working_hours="+p.WorkingHours.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func joinKeys[K ~string](set map[K]bool) string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, string(key))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
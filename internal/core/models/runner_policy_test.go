@@ -0,0 +1,125 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+const trustedCreator = "0x52908400098527886E0F7030069857D2E4169EE7"
+
+func TestRunnerPolicyCheck(t *testing.T) {
+	policy, err := ParseRunnerPolicy("docker, llm", trustedCreator, "research")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		task *Task
+		want bool
+	}{
+		{name: "trusted creator", task: &Task{Type: TaskTypeDocker, CreatorAddress: "0x52908400098527886e0f7030069857d2e4169ee7"}, want: true},
+		{name: "trusted namespace", task: &Task{Type: TaskTypeLLM, Labels: Labels{NamespaceLabel: "research"}}, want: true},
+		{name: "command task", task: &Task{Type: TaskTypeCommand, CreatorAddress: trustedCreator}, want: false},
+		{name: "untrusted creator", task: &Task{Type: TaskTypeDocker, CreatorAddress: "0x2222222222222222222222222222222222222222"}, want: false},
+		{name: "untrusted namespace", task: &Task{Type: TaskTypeDocker, Labels: Labels{NamespaceLabel: "other"}}, want: false},
+	}
+
+	for _, tc := range cases {
+		err := policy.Check(tc.task)
+		if got := err == nil; got != tc.want {
+			t.Fatalf("%s: expected allowed=%v, got error %v", tc.name, tc.want, err)
+		}
+		if err != nil && !errors.Is(err, ErrTaskNotAllowed) {
+			t.Fatalf("%s: expected ErrTaskNotAllowed, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestRunnerPolicyEmptyAllowsEverything(t *testing.T) {
+	policy, err := ParseRunnerPolicy("", " ", "")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if !policy.IsZero() {
+		t.Fatal("expected empty policy")
+	}
+	if err := policy.Check(&Task{Type: TaskTypeCommand}); err != nil {
+		t.Fatalf("expected empty policy to allow all tasks, got %v", err)
+	}
+}
+
+func TestParseRunnerPolicyRejectsInvalidEntries(t *testing.T) {
+	if _, err := ParseRunnerPolicy("docker,shell", "", ""); err == nil {
+		t.Fatal("expected error for unknown task type")
+	}
+	if _, err := ParseRunnerPolicy("", "0x1234", ""); err == nil {
+		t.Fatal("expected error for invalid creator address")
+	}
+}
+
+func TestRunnerPolicyNamesTheRefusingRule(t *testing.T) {
+	hours, err := ParseWorkingHours("mon-fri 09:00-17:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := RunnerPolicy{MaxDuration: time.Hour, WorkingHours: hours}
+	monday := time.Date(2026, 10, 12, 10, 0, 0, 0, time.Local)
+	config, _ := json.Marshal(TaskConfig{Resources: ResourceConfig{Timeout: "30m"}})
+
+	cases := []struct {
+		name string
+		task *Task
+		at   time.Time
+		want PolicyRule
+	}{
+		{name: "within limits", task: &Task{Type: TaskTypeDocker, Config: config}, at: monday},
+		{name: "capped by max duration", task: &Task{Type: TaskTypeDocker, MaxDurationSecs: 600}, at: monday},
+		{name: "no timeout", task: &Task{Type: TaskTypeDocker}, at: monday, want: PolicyRuleMaxDuration},
+		{name: "too long", task: &Task{Type: TaskTypeDocker, MaxDurationSecs: 7200}, at: monday, want: PolicyRuleMaxDuration},
+		{name: "after hours", task: &Task{Type: TaskTypeDocker, Config: config}, at: monday.Add(8 * time.Hour), want: PolicyRuleWorkingHours},
+		{name: "weekend", task: &Task{Type: TaskTypeDocker, Config: config}, at: monday.AddDate(0, 0, -1), want: PolicyRuleWorkingHours},
+	}
+	for _, tc := range cases {
+		err := policy.CheckAt(tc.task, tc.at)
+		var violation *PolicyViolation
+		switch {
+		case tc.want == "" && err != nil:
+			t.Fatalf("%s: unexpected refusal %v", tc.name, err)
+		case tc.want != "" && !errors.As(err, &violation):
+			t.Fatalf("%s: expected a policy violation, got %v", tc.name, err)
+		case tc.want != "" && violation.Rule != tc.want:
+			t.Fatalf("%s: rule = %s, want %s", tc.name, violation.Rule, tc.want)
+		}
+	}
+}
+
+func TestParseWorkingHours(t *testing.T) {
+	hours, err := ParseWorkingHours("fri-mon 22:00-06:00, 12:00-13:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	friday := time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)
+	cases := map[time.Duration]bool{
+		23 * time.Hour:                true,  // Friday night
+		24*time.Hour + 5*time.Hour:    true,  // Saturday morning, started Friday
+		4*24*time.Hour + 5*time.Hour:  true,  // Tuesday morning, started Monday
+		5*24*time.Hour + 5*time.Hour:  false, // Wednesday morning
+		5*24*time.Hour + 12*time.Hour: true,  // Midday, every day
+		5*24*time.Hour + 23*time.Hour: false, // Wednesday night
+	}
+	for offset, want := range cases {
+		at := friday.Add(offset)
+		if got := hours.Contains(at); got != want {
+			t.Fatalf("%s: Contains() = %v, want %v", at, got, want)
+		}
+	}
+
+	for _, invalid := range []string{"9-17", "someday 09:00-17:00", "mon-fri 09:00"} {
+		if _, err := ParseWorkingHours(invalid); err == nil {
+			t.Fatalf("expected an error for %q", invalid)
+		}
+	}
+}
@@ -38,3 +38,104 @@ func NewRunner(deviceID, walletAddress string) *Runner {
 		UpdatedAt:     time.Now(),
 	}
 }
+
+// HostMetrics is the resource usage of a runner's host reported in heartbeats.
+// Memory and disk are in bytes, CPUUsage is a percentage of all cores and the
+// load averages cover 1, 5 and 15 minutes.
+type HostMetrics struct {
+	MemoryUsage int64   `json:"memory_usage"`
+	MemoryTotal int64   `json:"memory_total"`
+	CPUUsage    float64 `json:"cpu_usage"`
+	DiskUsage   int64   `json:"disk_usage"`
+	DiskTotal   int64   `json:"disk_total"`
+	Load1       float64 `json:"load_1"`
+	Load5       float64 `json:"load_5"`
+	Load15      float64 `json:"load_15"`
+}
+
+// RunnerRegistration is what a runner sends when it registers with the server
+type RunnerRegistration struct {
+	WalletAddress     string             `json:"wallet_address"`
+	Status            RunnerStatus       `json:"status"`
+	Webhook           string             `json:"webhook,omitempty"`
+	WebhookToken      string             `json:"webhook_token,omitempty"`
+	ModelCapabilities []ModelCapability  `json:"model_capabilities,omitempty"`
+	AcceptLabels      string             `json:"accept_labels,omitempty"`
+	SandboxBenchmark  *SandboxBenchmark  `json:"sandbox_benchmark,omitempty"`
+	Manifest          *RunnerManifest    `json:"manifest,omitempty"`
+	Capabilities      *CapabilityProfile `json:"capabilities,omitempty"`
+	Benchmark         *BenchmarkReport   `json:"benchmark,omitempty"`
+	// SettlementChainID is the chain the runner wants its rewards paid on, the
+	// coordinator's default chain when zero
+	SettlementChainID int64 `json:"settlement_chain_id,omitempty"`
+	// Build is the provenance of the runner binary
+	Build *BuildProvenance `json:"build,omitempty"`
+}
+
+// ModelCapability is an LLM a runner can serve
+type ModelCapability struct {
+	ModelName string `json:"model_name"`
+	IsLoaded  bool   `json:"is_loaded"`
+	MaxTokens int    `json:"max_tokens"`
+}
+
+// SandboxBenchmark is the container overhead a runner measured with
+// `parity-runner bench sandbox`, one run per number of concurrent containers
+type SandboxBenchmark struct {
+	Engine     string                `json:"engine"`
+	Image      string                `json:"image"`
+	MeasuredAt time.Time             `json:"measured_at"`
+	Runs       []SandboxBenchmarkRun `json:"runs"`
+}
+
+// SandboxBenchmarkRun holds the means per container, in milliseconds, of
+// Containers containers going through their lifecycle at once.
+// SeccompOverheadMs is how much longer create, start and wait took with the
+// task seccomp profile than unconfined. StatsSampleMs is one stats sample of one
+// container and StatsSlowdown how much longer, as a fraction, a CPU-bound
+// workload ran while its stats were polled.
+type SandboxBenchmarkRun struct {
+	Containers        int     `json:"containers"`
+	CreateMs          float64 `json:"create_ms"`
+	StartMs           float64 `json:"start_ms"`
+	WaitMs            float64 `json:"wait_ms"`
+	RemoveMs          float64 `json:"remove_ms"`
+	SeccompOverheadMs float64 `json:"seccomp_overhead_ms"`
+	StatsSampleMs     float64 `json:"stats_sample_ms"`
+	StatsSlowdown     float64 `json:"stats_slowdown"`
+}
+
+// Heartbeat is the periodic status report of a runner. Uptime is in seconds.
+type Heartbeat struct {
+	WalletAddress string       `json:"wallet_address"`
+	Status        RunnerStatus `json:"status"`
+	Timestamp     int64        `json:"timestamp"`
+	Uptime        int64        `json:"uptime"`
+	PublicIP      string       `json:"public_ip,omitempty"`
+	GPUs          []GPUInfo    `json:"gpus,omitempty"`
+	HostMetrics
+	Settings   *RunnerSettings   `json:"settings,omitempty"`
+	Subsystems []SubsystemHealth `json:"subsystems,omitempty"`
+	Manifest   *RunnerManifest   `json:"manifest,omitempty"`
+}
+
+type SubsystemState string
+
+const (
+	SubsystemHealthy    SubsystemState = "healthy"
+	SubsystemRestarting SubsystemState = "restarting"
+	// SubsystemFailed subsystems kept failing after restarts, so the whole runner
+	// is restarted
+	SubsystemFailed SubsystemState = "failed"
+)
+
+// SubsystemHealth is the state of a runner subsystem its supervisor watches,
+// such as the webhook server or the tunnel. Restarts counts the restarts since
+// the runner started.
+type SubsystemHealth struct {
+	Name        string         `json:"name"`
+	State       SubsystemState `json:"state"`
+	Restarts    int            `json:"restarts"`
+	LastError   string         `json:"last_error,omitempty"`
+	LastRestart *time.Time     `json:"last_restart,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// SealedPayload is result content encrypted at rest. Ciphertext is sealed with a
+// per-result data key, which is stored wrapped by the deployment key KeyID.
+type SealedPayload struct {
+	Algorithm  string `json:"algorithm"`
+	KeyID      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (p SealedPayload) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *SealedPayload) Scan(value interface{}) error {
+	if value == nil {
+		*p = SealedPayload{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, p)
+}
@@ -4,14 +4,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type (
-	TaskStatus string
-	TaskType   string
+	TaskStatus     string
+	TaskType       string
+	IsolationLevel string
 )
 
 const (
@@ -19,6 +23,8 @@ const (
 	TaskStatusRunning   TaskStatus = "running"
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
+	// TaskStatusCancelled is a task its creator cancelled before it finished
+	TaskStatusCancelled TaskStatus = "cancelled"
 )
 
 const (
@@ -26,6 +32,18 @@ const (
 	TaskTypeCommand           TaskType = "command"
 	TaskTypeLLM               TaskType = "llm"
 	TaskTypeFederatedLearning TaskType = "federated_learning"
+	TaskTypeWasm              TaskType = "wasm"
+	TaskTypePreflight         TaskType = "preflight"
+)
+
+// Isolation levels a Docker task can ask for. Container, the default, runs it in a
+// seccomp-confined container; VM runs it in a microVM with its own kernel. TEE,
+// which wasm tasks can ask for as well, runs it in a trusted execution
+// environment and attaches its attestation quote to the result.
+const (
+	IsolationContainer IsolationLevel = "container"
+	IsolationVM        IsolationLevel = "vm"
+	IsolationTEE       IsolationLevel = "tee"
 )
 
 type TaskConfig struct {
@@ -34,17 +52,223 @@ type TaskConfig struct {
 	Resources      ResourceConfig    `json:"resources,omitempty"`
 	DockerImageURL string            `json:"docker_image_url,omitempty"`
 	ImageName      string            `json:"image_name,omitempty"`
+	Build          *BuildRecipe      `json:"build,omitempty"`
+	Checkpoint     *CheckpointConfig `json:"checkpoint,omitempty"`
+	Egress         *EgressPolicy     `json:"egress,omitempty"`
+	DNS            *DNSConfig        `json:"dns,omitempty"`
+	Wasm           *WasmConfig       `json:"wasm,omitempty"`
+	Preflight      *PreflightConfig  `json:"preflight,omitempty"`
+	// Credentials are minted by the creator's brokers when the task starts and
+	// injected into its container only
+	Credentials []CredentialRequest `json:"credentials,omitempty"`
+	// Gang runs the task on several runners at once
+	Gang *GangConfig `json:"gang,omitempty"`
+	// Array runs the task once per index
+	Array *ArrayConfig `json:"array,omitempty"`
+	// Outputs are absolute paths in the container that are copied out when the
+	// task finishes and attached to the result as one bundle
+	Outputs []string `json:"outputs,omitempty"`
+	// Inputs are fetched from IPFS before the task starts and mounted read-only
+	// into its container
+	Inputs []InputMount `json:"inputs,omitempty"`
+	// Data is handed to the task as a file. Large payloads are stored on IPFS and
+	// referenced by DataCID, with DataSHA256 checked by the runner after download.
+	Data       string `json:"data,omitempty"`
+	DataCID    string `json:"data_cid,omitempty"`
+	DataSHA256 string `json:"data_sha256,omitempty"`
+	// Deterministic runs the task so that every runner produces the same result
+	Deterministic *DeterministicConfig `json:"deterministic,omitempty"`
+	// Retry runs the task again when an attempt fails for a transient reason
+	Retry *RetryPolicy `json:"retry,omitempty"`
+}
+
+const (
+	maxInputMounts = 16
+	// MaxInputBytes bounds each input a task mounts
+	MaxInputBytes int64 = 1 << 30
+)
+
+// InputMount is content on IPFS mounted as a read-only file at Path. When SHA256
+// is set the runner checks the content against it.
+type InputMount struct {
+	CID    string `json:"cid"`
+	SHA256 string `json:"sha256,omitempty"`
+	Path   string `json:"path"`
+}
+
+func validateInputs(inputs []InputMount) error {
+	if len(inputs) > maxInputMounts {
+		return fmt.Errorf("at most %d inputs can be mounted", maxInputMounts)
+	}
+	paths := make(map[string]bool, len(inputs))
+	for _, input := range inputs {
+		if input.CID == "" {
+			return errors.New("input cid is required")
+		}
+		if input.SHA256 != "" && !sha256Pattern.MatchString(input.SHA256) {
+			return fmt.Errorf("input %s has an invalid sha256", input.CID)
+		}
+		if !path.IsAbs(input.Path) || path.Clean(input.Path) != input.Path || input.Path == "/" {
+			return fmt.Errorf("input path %q must be a clean absolute path below /", input.Path)
+		}
+		if paths[input.Path] {
+			return fmt.Errorf("input path %q is used twice", input.Path)
+		}
+		paths[input.Path] = true
+	}
+	return nil
+}
+
+// CheckpointConfig opts a Docker task into resumable execution. The task keeps its
+// progress under Path, which survives runner restarts, and the runner snapshots the
+// container filesystem every Interval so a new attempt resumes from the last one.
+// ResumeCID names an uploaded CRIU checkpoint, reported in an earlier result, that
+// a runner without local state for the task restores from.
+type CheckpointConfig struct {
+	Enabled     bool   `json:"enabled"`
+	Path        string `json:"path,omitempty"`
+	Interval    string `json:"interval,omitempty"`
+	MaxAttempts int    `json:"max_attempts,omitempty"`
+	ResumeCID   string `json:"resume_cid,omitempty"`
+}
+
+// DNSConfig controls the resolv.conf of a Docker task container. With None set the
+// container has no working resolver and only the names in Hosts resolve.
+type DNSConfig struct {
+	Servers []string          `json:"servers,omitempty"`
+	Search  []string          `json:"search,omitempty"`
+	Options []string          `json:"options,omitempty"`
+	None    bool              `json:"none,omitempty"`
+	Hosts   map[string]string `json:"hosts,omitempty"`
+}
+
+func (d *DNSConfig) Validate() error {
+	if d.None && len(d.Servers) > 0 {
+		return errors.New("dns servers cannot be set when dns is disabled")
+	}
+	for _, server := range d.Servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid dns server %q", server)
+		}
+	}
+	for _, domain := range d.Search {
+		if domain == "" || strings.ContainsAny(domain, " \t\n") {
+			return fmt.Errorf("invalid dns search domain %q", domain)
+		}
+	}
+	for _, option := range d.Options {
+		if option == "" || strings.ContainsAny(option, " \t\n") {
+			return fmt.Errorf("invalid dns option %q", option)
+		}
+	}
+	for host, ip := range d.Hosts {
+		if host == "" || strings.ContainsAny(host, " :\t\n") {
+			return fmt.Errorf("invalid host name %q", host)
+		}
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid address %q for host %s", ip, host)
+		}
+	}
+	return nil
 }
 
 func (c *TaskConfig) Validate(taskType TaskType) error {
+	if len(c.Credentials) > 0 && taskType != TaskTypeDocker {
+		return errors.New("credentials are only supported for docker tasks")
+	}
+	if c.Gang != nil && taskType != TaskTypeDocker {
+		return errors.New("gangs are only supported for docker tasks")
+	}
+	if c.Array != nil && taskType != TaskTypeDocker {
+		return errors.New("arrays are only supported for docker tasks")
+	}
+	if len(c.Outputs) > 0 && taskType != TaskTypeDocker {
+		return errors.New("outputs are only supported for docker tasks")
+	}
+	if len(c.Inputs) > 0 && taskType != TaskTypeDocker {
+		return errors.New("inputs are only supported for docker tasks")
+	}
+	if c.Deterministic != nil && taskType != TaskTypeDocker {
+		return errors.New("deterministic execution is only supported for docker tasks")
+	}
+	if c.Retry != nil && taskType != TaskTypeDocker {
+		return errors.New("retries are only supported for docker tasks")
+	}
+
 	switch taskType {
 	case TaskTypeDocker:
 		if c.ImageName == "" {
 			return errors.New("image name is required for Docker tasks")
 		}
+		if c.Build != nil {
+			if err := c.Build.Validate(); err != nil {
+				return err
+			}
+		}
+		if c.DNS != nil {
+			if err := c.DNS.Validate(); err != nil {
+				return err
+			}
+		}
+		brokers := make(map[string]bool, len(c.Credentials))
+		for _, request := range c.Credentials {
+			if err := request.Validate(); err != nil {
+				return err
+			}
+			if brokers[request.Broker] {
+				return fmt.Errorf("credentials from broker %q are requested twice", request.Broker)
+			}
+			brokers[request.Broker] = true
+		}
+		if c.Gang != nil {
+			if err := c.Gang.Validate(); err != nil {
+				return err
+			}
+		}
+		if c.Array != nil {
+			if c.Gang != nil {
+				return errors.New("a task cannot be both an array and a gang")
+			}
+			if err := c.Array.Validate(); err != nil {
+				return err
+			}
+		}
+		if c.Retry != nil {
+			if c.Gang != nil {
+				return errors.New("members of a gang task cannot be retried")
+			}
+			if err := c.Retry.Validate(); err != nil {
+				return err
+			}
+		}
+		if err := validateOutputPaths(c.Outputs); err != nil {
+			return err
+		}
+		if err := validateInputs(c.Inputs); err != nil {
+			return err
+		}
+		if c.Deterministic != nil {
+			if err := c.validateDeterministic(); err != nil {
+				return err
+			}
+		}
 	case TaskTypeCommand:
 	case TaskTypeLLM:
 	case TaskTypeFederatedLearning:
+	case TaskTypeWasm:
+		if c.Wasm == nil {
+			return errors.New("wasm module is required for wasm tasks")
+		}
+		if err := c.Wasm.Validate(); err != nil {
+			return err
+		}
+	case TaskTypePreflight:
+		if c.Preflight == nil {
+			return errors.New("preflight tasks need the task to check")
+		}
+		if err := c.Preflight.Validate(); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unsupported task type: %s", taskType)
 	}
@@ -65,14 +289,56 @@ type Task struct {
 	Status          TaskStatus         `json:"status" gorm:"type:varchar(50)"`
 	Config          json.RawMessage    `json:"config" gorm:"type:jsonb"`
 	Environment     *EnvironmentConfig `json:"environment" gorm:"type:jsonb"`
+	Labels          Labels             `json:"labels,omitempty" gorm:"type:jsonb"`
+	ExperimentID    *uuid.UUID         `json:"experiment_id,omitempty" gorm:"type:uuid;index"`
+	MaxDurationSecs int64              `json:"max_duration_seconds,omitempty" gorm:"type:bigint"`
+	GPU             *GPURequirements   `json:"gpu,omitempty" gorm:"type:jsonb"`
+	IsolationLevel  IsolationLevel     `json:"isolation_level,omitempty" gorm:"type:varchar(20)"`
 	Reward          float64            `json:"reward,omitempty" gorm:"type:decimal(20,8)"`
 	CreatorAddress  string             `json:"creator_address" gorm:"type:varchar(42)"`
-	CreatorDeviceID string             `json:"creator_device_id" gorm:"type:varchar(255)"`
 	RunnerID        string             `json:"runner_id" gorm:"type:varchar(255)"`
 	Nonce           string             `json:"nonce" gorm:"type:varchar(64);not null"`
 	CreatedAt       time.Time          `json:"created_at" gorm:"type:timestamp"`
 	UpdatedAt       time.Time          `json:"updated_at" gorm:"type:timestamp"`
 	CompletedAt     *time.Time         `json:"completed_at" gorm:"type:timestamp"`
+
+	// Requirements are matched against the capability profiles of runners
+	Requirements *CapabilityRequirements `json:"requirements,omitempty" gorm:"type:jsonb"`
+	// Assignment is only set on tasks shown to their creator while running
+	Assignment *TaskAssignment `json:"assignment,omitempty" gorm:"-"`
+	// RequiredStake is the stake in wei the runner that started the task had to
+	// hold, when the server enforces one
+	RequiredStake string `json:"required_stake,omitempty" gorm:"type:varchar(78)"`
+	// CreatorDeviceID is the device the task was submitted from, taken from the
+	// X-Device-ID header. It authorizes acting on the task, so it is never read
+	// from or shown in JSON.
+	CreatorDeviceID string `json:"-" gorm:"type:varchar(255)"`
+}
+
+// NamespaceLabel is the label that places a task in a namespace for policy purposes
+const NamespaceLabel = "namespace"
+
+func (t *Task) Namespace() string {
+	return t.Labels[NamespaceLabel]
+}
+
+// MaxDuration is the longest the task may run as set by the server policy, or 0
+// when the task is not limited
+func (t *Task) MaxDuration() time.Duration {
+	return time.Duration(t.MaxDurationSecs) * time.Second
+}
+
+// EstimatedDuration is the longest the task is expected to run: the timeout of
+// its config, capped by MaxDuration, or 0 when neither is set
+func (t *Task) EstimatedDuration() time.Duration {
+	estimate := t.MaxDuration()
+	var config TaskConfig
+	if len(t.Config) > 0 && json.Unmarshal(t.Config, &config) == nil {
+		if timeout, err := time.ParseDuration(config.Resources.Timeout); err == nil && timeout > 0 && (estimate <= 0 || timeout < estimate) {
+			estimate = timeout
+		}
+	}
+	return estimate
 }
 
 func NewTask() *Task {
@@ -106,5 +372,65 @@ func (t *Task) Validate() error {
 		return errors.New("docker environment configuration is required for docker tasks")
 	}
 
+	if t.GPU != nil {
+		if t.Type != TaskTypeDocker {
+			return errors.New("gpu requirements are only supported for docker tasks")
+		}
+		if err := t.GPU.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if t.Requirements != nil {
+		if err := t.Requirements.Validate(); err != nil {
+			return err
+		}
+	}
+
+	switch t.IsolationLevel {
+	case "", IsolationContainer:
+	case IsolationVM:
+		if t.Type != TaskTypeDocker {
+			return errors.New("vm isolation is only supported for docker tasks")
+		}
+		if t.GPU != nil {
+			return errors.New("vm isolation cannot be combined with gpu requirements")
+		}
+		if len(config.Credentials) > 0 {
+			return errors.New("vm isolated tasks have no network to use credentials with")
+		}
+		if config.Gang != nil {
+			return errors.New("vm isolated tasks have no network to reach their gang with")
+		}
+		if len(config.Outputs) > 0 {
+			return errors.New("outputs cannot be copied out of vm isolated tasks")
+		}
+		if len(config.Inputs) > 0 {
+			return errors.New("inputs cannot be mounted into vm isolated tasks")
+		}
+		if config.Deterministic != nil {
+			return errors.New("vm isolated tasks cannot run in deterministic mode")
+		}
+	case IsolationTEE:
+		if t.Type != TaskTypeDocker && t.Type != TaskTypeWasm {
+			return errors.New("tee isolation is only supported for docker and wasm tasks")
+		}
+		if t.GPU != nil {
+			return errors.New("tee isolation cannot be combined with gpu requirements")
+		}
+	default:
+		return fmt.Errorf("unknown isolation level %q", t.IsolationLevel)
+	}
+
 	return nil
 }
+
+// RequiresVM reports whether the task must run in a microVM
+func (t *Task) RequiresVM() bool {
+	return t.IsolationLevel == IsolationVM
+}
+
+// RequiresTEE reports whether the task must run in a trusted execution environment
+func (t *Task) RequiresTEE() bool {
+	return t.IsolationLevel == IsolationTEE
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+type TaskEventType string
+
+const (
+	TaskEventQueued            TaskEventType = "queued"
+	TaskEventStarted           TaskEventType = "started"
+	TaskEventResultSubmitted   TaskEventType = "result_submitted"
+	TaskEventCredentialsIssued TaskEventType = "credentials_issued"
+	TaskEventCredentialsDenied TaskEventType = "credentials_denied"
+	TaskEventGangScheduled     TaskEventType = "gang_scheduled"
+	TaskEventGangRunning       TaskEventType = "gang_running"
+	TaskEventGangCompleted     TaskEventType = "gang_completed"
+	TaskEventGangFailed        TaskEventType = "gang_failed"
+	TaskEventArrayScheduled    TaskEventType = "array_scheduled"
+	TaskEventArrayIndexFailed  TaskEventType = "array_index_failed"
+	TaskEventArrayRetried      TaskEventType = "array_retried"
+	TaskEventArrayCompleted    TaskEventType = "array_completed"
+	TaskEventAssignmentRevoked TaskEventType = "assignment_revoked"
+	TaskEventCancelled         TaskEventType = "cancelled"
+	TaskEventReleased          TaskEventType = "released"
+)
+
+// TaskEvent is an entry of a task's audit log on the server
+type TaskEvent struct {
+	Type     TaskEventType `json:"type"`
+	Time     time.Time     `json:"time"`
+	DeviceID string        `json:"device_id,omitempty"`
+	Detail   string        `json:"detail,omitempty"`
+}
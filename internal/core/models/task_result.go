@@ -23,6 +23,7 @@ type TaskResult struct {
 	ResultHash          string    `json:"result_hash" gorm:"type:varchar(64)"`
 	ImageHashVerified   string    `json:"image_hash_verified" gorm:"type:varchar(64)"`
 	CommandHashVerified string    `json:"command_hash_verified" gorm:"type:varchar(64)"`
+	BuildVerified       bool      `json:"build_verified,omitempty" gorm:"type:boolean;default:false"`
 	CreatedAt           time.Time `json:"created_at" gorm:"type:timestamp with time zone;default:now()"`
 	CreatorDeviceID     string    `json:"creator_device_id" gorm:"type:text"`
 	SolverDeviceID      string    `json:"solver_device_id" gorm:"type:text"`
@@ -30,6 +31,7 @@ type TaskResult struct {
 	CPUSeconds          float64   `json:"cpu_seconds" gorm:"type:decimal(20,8);default:0"`
 	EstimatedCycles     uint64    `json:"estimated_cycles" gorm:"type:bigint;not null;default:0"`
 	MemoryGBHours       float64   `json:"memory_gb_hours" gorm:"type:decimal(20,8);default:0"`
+	PeakMemoryGB        float64   `json:"peak_memory_gb" gorm:"type:decimal(20,8);default:0"`
 	StorageGB           float64   `json:"storage_gb" gorm:"type:decimal(20,8);default:0"`
 	NetworkDataGB       float64   `json:"network_data_gb" gorm:"type:decimal(20,8);default:0"`
 
@@ -37,6 +39,36 @@ type TaskResult struct {
 	PromptTokens   int   `json:"prompt_tokens,omitempty" gorm:"type:int;default:0"`
 	ResponseTokens int   `json:"response_tokens,omitempty" gorm:"type:int;default:0"`
 	InferenceTime  int64 `json:"inference_time_ms,omitempty" gorm:"type:bigint;default:0"`
+
+	// FuelUsed is the number of function calls a wasm task made
+	FuelUsed uint64 `json:"fuel_used,omitempty" gorm:"type:bigint;default:0"`
+
+	Receipt *ExecutionReceipt `json:"receipt,omitempty" gorm:"type:jsonb"`
+	Egress  *EgressSummary    `json:"egress,omitempty" gorm:"type:jsonb"`
+	// Checkpoint is set for Docker tasks that opted into checkpointing
+	Checkpoint *CheckpointSummary `json:"checkpoint,omitempty" gorm:"type:jsonb"`
+	// Sealed holds the encrypted output when the deployment encrypts results at
+	// rest, in which case Output is empty
+	Sealed *SealedPayload `json:"sealed,omitempty" gorm:"type:jsonb"`
+	// Uploads are parts of the result the runner added to IPFS. When the output
+	// was uploaded, Output is empty.
+	Uploads *ResultUploads `json:"uploads,omitempty" gorm:"type:jsonb"`
+	// Deterministic is set for tasks run in deterministic mode, whose ResultHash
+	// is comparable across runners
+	Deterministic *DeterministicSummary `json:"deterministic,omitempty" gorm:"type:jsonb"`
+	// Attestation is set for tasks that ran in a trusted execution environment
+	Attestation *AttestationQuote `json:"attestation,omitempty" gorm:"type:jsonb"`
+	// Diagnostics is set for tasks that failed
+	Diagnostics *FailureDiagnostics `json:"diagnostics,omitempty" gorm:"type:jsonb"`
+	// ExecutionLog is the runner's log of the task up to the submission of
+	// this result, kept to settle disputes over what the runner did
+	ExecutionLog ExecutionLog `json:"execution_log,omitempty" gorm:"type:jsonb"`
+	// Attempts are the runs of a task with a retry policy, the last one being
+	// what this result reports
+	Attempts TaskAttempts `json:"attempts,omitempty" gorm:"type:jsonb"`
+	// Status is how the task ended: completed, failed, or cancelled by its
+	// creator
+	Status TaskStatus `json:"status,omitempty" gorm:"type:varchar(50)"`
 }
 
 func (r *TaskResult) Clean() {
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTaskValidateIsolationLevel(t *testing.T) {
+	newTask := func(taskType TaskType, level IsolationLevel) *Task {
+		task := NewTask()
+		task.Title = "isolated"
+		task.Type = taskType
+		task.Config = json.RawMessage(`{"command":"true","image_name":"alpine"}`)
+		task.Environment = &EnvironmentConfig{Type: "docker"}
+		task.IsolationLevel = level
+		return task
+	}
+
+	for _, level := range []IsolationLevel{"", IsolationContainer, IsolationVM} {
+		if err := newTask(TaskTypeDocker, level).Validate(); err != nil {
+			t.Errorf("docker task with isolation %q: %v", level, err)
+		}
+	}
+	if err := newTask(TaskTypeCommand, IsolationVM).Validate(); err == nil {
+		t.Error("expected vm isolation to be refused for command tasks")
+	}
+	if err := newTask(TaskTypeDocker, "kernel").Validate(); err == nil {
+		t.Error("expected an unknown isolation level to be refused")
+	}
+
+	gpuTask := newTask(TaskTypeDocker, IsolationVM)
+	gpuTask.GPU = &GPURequirements{Count: 1}
+	if err := gpuTask.Validate(); err == nil {
+		t.Error("expected vm isolation with gpus to be refused")
+	}
+}
+
+func TestTaskValidateCredentials(t *testing.T) {
+	newTask := func(taskType TaskType, config string) *Task {
+		task := NewTask()
+		task.Title = "upload"
+		task.Type = taskType
+		task.Config = json.RawMessage(config)
+		task.Environment = &EnvironmentConfig{Type: "docker"}
+		return task
+	}
+
+	valid := newTask(TaskTypeDocker, `{"image_name":"alpine","credentials":[{"broker":"s3-upload","env_prefix":"UPLOAD_"}]}`)
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid credential request refused: %v", err)
+	}
+
+	refused := map[string]*Task{
+		"command task": newTask(TaskTypeCommand, `{"command":"true","credentials":[{"broker":"s3-upload"}]}`),
+		"bad prefix":   newTask(TaskTypeDocker, `{"image_name":"alpine","credentials":[{"broker":"s3-upload","env_prefix":"upload"}]}`),
+		"bad broker":   newTask(TaskTypeDocker, `{"image_name":"alpine","credentials":[{"broker":"S3 Upload"}]}`),
+		"twice":        newTask(TaskTypeDocker, `{"image_name":"alpine","credentials":[{"broker":"s3-upload"},{"broker":"s3-upload","env_prefix":"B_"}]}`),
+		"vm isolation": newTask(TaskTypeDocker, `{"image_name":"alpine","credentials":[{"broker":"s3-upload"}]}`),
+	}
+	refused["vm isolation"].IsolationLevel = IsolationVM
+	for name, task := range refused {
+		if err := task.Validate(); err == nil {
+			t.Errorf("%s: expected the credential request to be refused", name)
+		}
+	}
+}
+
+func TestTaskValidateInputsAndOutputs(t *testing.T) {
+	newTask := func(taskType TaskType, config string) *Task {
+		task := NewTask()
+		task.Title = "process"
+		task.Type = taskType
+		task.Config = json.RawMessage(config)
+		task.Environment = &EnvironmentConfig{Type: "docker"}
+		return task
+	}
+
+	if err := newTask(TaskTypeDocker, `{"image_name":"alpine","outputs":["/app/results","/tmp/model.pt"]}`).Validate(); err != nil {
+		t.Fatalf("valid outputs refused: %v", err)
+	}
+	if err := newTask(TaskTypeDocker, `{"image_name":"alpine","inputs":[{"cid":"bafyrows","path":"/data/rows.csv"}]}`).Validate(); err != nil {
+		t.Fatalf("valid inputs refused: %v", err)
+	}
+
+	refused := map[string]*Task{
+		"command task": newTask(TaskTypeCommand, `{"command":"true","outputs":["/out"]}`),
+		"relative":     newTask(TaskTypeDocker, `{"image_name":"alpine","outputs":["out"]}`),
+		"unclean":      newTask(TaskTypeDocker, `{"image_name":"alpine","outputs":["/app/../etc"]}`),
+		"root":         newTask(TaskTypeDocker, `{"image_name":"alpine","outputs":["/"]}`),
+		"twice":        newTask(TaskTypeDocker, `{"image_name":"alpine","outputs":["/out","/out"]}`),
+		"vm isolation": newTask(TaskTypeDocker, `{"image_name":"alpine","outputs":["/out"]}`),
+		"input no cid": newTask(TaskTypeDocker, `{"image_name":"alpine","inputs":[{"path":"/data"}]}`),
+		"input hash":   newTask(TaskTypeDocker, `{"image_name":"alpine","inputs":[{"cid":"bafyrows","sha256":"abc","path":"/data"}]}`),
+		"input path":   newTask(TaskTypeDocker, `{"image_name":"alpine","inputs":[{"cid":"bafyrows","path":"data"}]}`),
+		"input twice":  newTask(TaskTypeDocker, `{"image_name":"alpine","inputs":[{"cid":"bafya","path":"/data"},{"cid":"bafyb","path":"/data"}]}`),
+		"input in vm":  newTask(TaskTypeDocker, `{"image_name":"alpine","inputs":[{"cid":"bafyrows","path":"/data"}]}`),
+	}
+	refused["vm isolation"].IsolationLevel = IsolationVM
+	refused["input in vm"].IsolationLevel = IsolationVM
+	for name, task := range refused {
+		if err := task.Validate(); err == nil {
+			t.Errorf("%s: expected the task to be refused", name)
+		}
+	}
+}
+
+func TestTaskValidateDeterministic(t *testing.T) {
+	const pinned = "ghcr.io/acme/sim@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	newTask := func(config string) *Task {
+		task := NewTask()
+		task.Title = "simulate"
+		task.Type = TaskTypeDocker
+		task.Config = json.RawMessage(config)
+		task.Environment = &EnvironmentConfig{Type: "docker"}
+		return task
+	}
+
+	if err := newTask(`{"image_name":"` + pinned + `","deterministic":{"epoch":1700000000},"outputs":["/out"]}`).Validate(); err != nil {
+		t.Fatalf("valid deterministic task refused: %v", err)
+	}
+
+	refused := map[string]*Task{
+		"unpinned":   newTask(`{"image_name":"ghcr.io/acme/sim:1","deterministic":{}}`),
+		"image url":  newTask(`{"image_name":"` + pinned + `","docker_image_url":"https://example.com/sim.tar","deterministic":{}}`),
+		"egress":     newTask(`{"image_name":"` + pinned + `","egress":{"audit":true},"deterministic":{}}`),
+		"gang":       newTask(`{"image_name":"` + pinned + `","gang":{"size":2},"deterministic":{}}`),
+		"epoch":      newTask(`{"image_name":"` + pinned + `","deterministic":{"epoch":-1}}`),
+		"vm":         newTask(`{"image_name":"` + pinned + `","deterministic":{}}`),
+		"not docker": {Title: "x", Type: TaskTypeCommand, Config: json.RawMessage(`{"command":"true","deterministic":{}}`)},
+	}
+	refused["vm"].IsolationLevel = IsolationVM
+	for name, task := range refused {
+		if err := task.Validate(); err == nil {
+			t.Errorf("%s: expected the task to be refused", name)
+		}
+	}
+}
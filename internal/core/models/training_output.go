@@ -0,0 +1,194 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// TrainingOutputVersion is the schema version of the training output written
+// by this runner. It changes only when fields are moved, renamed or retyped;
+// added fields keep the version, and readers ignore fields they do not know.
+const TrainingOutputVersion = 1
+
+var ErrUnsupportedTrainingOutput = errors.New("unsupported training output schema version")
+
+// Model families the metrics of a training output are keyed by
+const (
+	ModelFamilyRandomForest       = "random_forest"
+	ModelFamilyGradientBoosting   = "gradient_boosting"
+	ModelFamilyLogisticRegression = "logistic_regression"
+	ModelFamilyKMeans             = "kmeans"
+)
+
+// TrainingOutput is the JSON output of a federated learning task
+type TrainingOutput struct {
+	SchemaVersion int                  `json:"schema_version"`
+	SessionID     string               `json:"session_id"`
+	RoundID       string               `json:"round_id"`
+	Gradients     map[string][]float64 `json:"gradients"`
+	Weights       map[string][]float64 `json:"weights,omitempty"`
+	Loss          float64              `json:"loss"`
+	Accuracy      float64              `json:"accuracy"`
+	DataSize      int                  `json:"data_size"`
+	// TrainingTime is in milliseconds
+	TrainingTime int64            `json:"training_time"`
+	Metadata     TrainingMetadata `json:"metadata"`
+	// Metrics are the model specific results, keyed by model family
+	Metrics map[string]json.RawMessage `json:"metrics,omitempty"`
+}
+
+// TrainingMetadata describes how a model was trained
+type TrainingMetadata struct {
+	ModelType     string                 `json:"model_type"`
+	Epochs        int                    `json:"epochs"`
+	BatchSize     int                    `json:"batch_size"`
+	LearningRate  float64                `json:"learning_rate"`
+	DatasetCID    string                 `json:"dataset_cid"`
+	DataFormat    string                 `json:"data_format"`
+	FeatureCount  int                    `json:"feature_count"`
+	SampleCount   int                    `json:"sample_count"`
+	PartitionInfo map[string]interface{} `json:"partition_info,omitempty"`
+	// ModelSpecific holds the model config of the task, keyed by model family
+	ModelSpecific map[string]map[string]interface{} `json:"model_specific,omitempty"`
+}
+
+type RandomForestMetrics struct {
+	FeatureImportance map[int]float64 `json:"feature_importance"`
+	OOBError          float64         `json:"oob_error"`
+	TreeCount         int             `json:"tree_count"`
+}
+
+type GradientBoostingMetrics struct {
+	FeatureImportance map[int]float64 `json:"feature_importance"`
+	BestIteration     int             `json:"best_iteration"`
+	TreeCount         int             `json:"tree_count"`
+	Objective         string          `json:"objective"`
+}
+
+type KMeansMetrics struct {
+	Centroids    [][]float64 `json:"centroids"`
+	ClusterSizes []float64   `json:"cluster_sizes"`
+	Inertia      float64     `json:"inertia"`
+	Iterations   int         `json:"iterations"`
+}
+
+// SetMetrics stores the metrics of a model family
+func (o *TrainingOutput) SetMetrics(family string, metrics interface{}) error {
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s metrics: %w", family, err)
+	}
+	if o.Metrics == nil {
+		o.Metrics = make(map[string]json.RawMessage)
+	}
+	o.Metrics[family] = data
+	return nil
+}
+
+// metrics decodes the metrics of a model family into target, false when the
+// output carries none
+func (o *TrainingOutput) metrics(family string, target interface{}) (bool, error) {
+	data, ok := o.Metrics[family]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return false, fmt.Errorf("invalid %s metrics: %w", family, err)
+	}
+	return true, nil
+}
+
+func (o *TrainingOutput) RandomForestMetrics() (*RandomForestMetrics, bool, error) {
+	var metrics RandomForestMetrics
+	ok, err := o.metrics(ModelFamilyRandomForest, &metrics)
+	return &metrics, ok, err
+}
+
+func (o *TrainingOutput) GradientBoostingMetrics() (*GradientBoostingMetrics, bool, error) {
+	var metrics GradientBoostingMetrics
+	ok, err := o.metrics(ModelFamilyGradientBoosting, &metrics)
+	return &metrics, ok, err
+}
+
+func (o *TrainingOutput) KMeansMetrics() (*KMeansMetrics, bool, error) {
+	var metrics KMeansMetrics
+	ok, err := o.metrics(ModelFamilyKMeans, &metrics)
+	return &metrics, ok, err
+}
+
+// trainingOutputMigrations upgrade a raw training output from the version
+// they are keyed by to the next one
+var trainingOutputMigrations = map[int]func(raw map[string]json.RawMessage) error{
+	0: migrateTrainingOutputV0,
+}
+
+// ParseTrainingOutput reads a training output of any version this runner
+// knows, migrated to TrainingOutputVersion. Outputs written before versioning
+// count as version 0.
+func ParseTrainingOutput(data []byte) (*TrainingOutput, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid training output: %w", err)
+	}
+
+	version := 0
+	if value, ok := raw["schema_version"]; ok {
+		if err := json.Unmarshal(value, &version); err != nil {
+			return nil, fmt.Errorf("invalid training output schema version: %w", err)
+		}
+	}
+	if version < 0 || version > TrainingOutputVersion {
+		return nil, fmt.Errorf("%w: %d, this runner reads up to %d", ErrUnsupportedTrainingOutput, version, TrainingOutputVersion)
+	}
+	for ; version < TrainingOutputVersion; version++ {
+		if err := trainingOutputMigrations[version](raw); err != nil {
+			return nil, fmt.Errorf("failed to migrate training output from version %d: %w", version, err)
+		}
+	}
+	raw["schema_version"], _ = json.Marshal(TrainingOutputVersion)
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal migrated training output: %w", err)
+	}
+	var output TrainingOutput
+	if err := json.Unmarshal(migrated, &output); err != nil {
+		return nil, fmt.Errorf("invalid training output: %w", err)
+	}
+	if output.SessionID == "" || output.RoundID == "" {
+		return nil, errors.New("training output has no session_id or round_id")
+	}
+	return &output, nil
+}
+
+// migrateTrainingOutputV0 moves the model metrics that version 0 kept at the
+// top level, such as rf_metrics, under metrics
+func migrateTrainingOutputV0(raw map[string]json.RawMessage) error {
+	legacy := map[string]string{
+		"rf_metrics":     ModelFamilyRandomForest,
+		"gb_metrics":     ModelFamilyGradientBoosting,
+		"kmeans_metrics": ModelFamilyKMeans,
+	}
+	metrics := make(map[string]json.RawMessage)
+	if value, ok := raw["metrics"]; ok {
+		if err := json.Unmarshal(value, &metrics); err != nil {
+			return fmt.Errorf("invalid metrics: %w", err)
+		}
+	}
+	for key, family := range legacy {
+		if value, ok := raw[key]; ok {
+			metrics[family] = value
+			delete(raw, key)
+		}
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+	value, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+	raw["metrics"] = value
+	return nil
+}
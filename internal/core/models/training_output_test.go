@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseTrainingOutputMigratesUnversionedOutput(t *testing.T) {
+	legacy := []byte(`{
+		"session_id": "s1",
+		"round_id": "r1",
+		"gradients": {"layer_weights": [0.1, 0.2]},
+		"loss": 0.5,
+		"data_size": 10,
+		"metadata": {"model_type": "random_forest", "epochs": 3},
+		"rf_metrics": {"feature_importance": {"0": 0.7, "1": 0.3}, "oob_error": 0.1, "tree_count": 5},
+		"added_later": true
+	}`)
+
+	output, err := ParseTrainingOutput(legacy)
+	if err != nil {
+		t.Fatalf("ParseTrainingOutput() error = %v", err)
+	}
+	if output.SchemaVersion != TrainingOutputVersion || output.Metadata.Epochs != 3 || output.DataSize != 10 {
+		t.Fatalf("unexpected output %+v", output)
+	}
+	metrics, ok, err := output.RandomForestMetrics()
+	if err != nil || !ok {
+		t.Fatalf("RandomForestMetrics() = %v, %v", ok, err)
+	}
+	if metrics.TreeCount != 5 || metrics.FeatureImportance[0] != 0.7 {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+	if _, ok, _ := output.KMeansMetrics(); ok {
+		t.Fatal("expected no k-means metrics")
+	}
+
+	// A current output reads back the same
+	data, _ := json.Marshal(output)
+	again, err := ParseTrainingOutput(data)
+	if err != nil {
+		t.Fatalf("ParseTrainingOutput() of current version error = %v", err)
+	}
+	if metrics, _, _ := again.RandomForestMetrics(); metrics.OOBError != 0.1 {
+		t.Fatalf("metrics lost on round trip: %+v", metrics)
+	}
+}
+
+func TestParseTrainingOutputRejectsNewerVersions(t *testing.T) {
+	_, err := ParseTrainingOutput([]byte(`{"schema_version": 99, "session_id": "s1", "round_id": "r1"}`))
+	if !errors.Is(err, ErrUnsupportedTrainingOutput) {
+		t.Fatalf("expected ErrUnsupportedTrainingOutput, got %v", err)
+	}
+	if _, err := ParseTrainingOutput([]byte(`{"schema_version": 1}`)); err == nil {
+		t.Fatal("expected an error for an output without session")
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+)
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// WasmConfig describes a WebAssembly task. The module is either given inline,
+// base64 encoded in JSON, or stored on IPFS under ModuleCID and checked against
+// ModuleSHA256. It runs under WASI with Args, Stdin and the task's Env, and
+// Entrypoint defaults to _start. Fuel caps the number of function calls the
+// module may make; zero leaves only the runner's limit.
+type WasmConfig struct {
+	Module       []byte   `json:"module,omitempty"`
+	ModuleCID    string   `json:"module_cid,omitempty"`
+	ModuleSHA256 string   `json:"module_sha256,omitempty"`
+	Entrypoint   string   `json:"entrypoint,omitempty"`
+	Args         []string `json:"args,omitempty"`
+	Stdin        string   `json:"stdin,omitempty"`
+	Fuel         uint64   `json:"fuel,omitempty"`
+}
+
+func (w *WasmConfig) Validate() error {
+	switch {
+	case len(w.Module) == 0 && w.ModuleCID == "":
+		return errors.New("wasm module or module_cid is required")
+	case len(w.Module) > 0 && w.ModuleCID != "":
+		return errors.New("wasm module and module_cid cannot both be set")
+	case w.ModuleCID != "" && !sha256Pattern.MatchString(w.ModuleSHA256):
+		return errors.New("module_sha256 must be the hex SHA-256 of module_cid")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// WorkingHours are the local time windows a runner takes tasks in. Outside of
+// them every task is refused; no windows accept tasks at any time.
+type WorkingHours []HoursWindow
+
+// HoursWindow runs from Start to End after midnight on its days. Windows that
+// end before they start run past midnight into the next day.
+type HoursWindow struct {
+	// Days holds the weekdays the window starts on, all days when empty
+	Days  map[time.Weekday]bool
+	Start time.Duration
+	End   time.Duration
+	text  string
+}
+
+// ParseWorkingHours reads comma separated windows such as
+// "mon-fri 09:00-17:00, sat 10:00-14:00" or "22:00-06:00"
+func ParseWorkingHours(list string) (WorkingHours, error) {
+	var hours WorkingHours
+	for _, item := range splitList(list) {
+		window := HoursWindow{text: item}
+		span := item
+		if days, clock, ok := strings.Cut(item, " "); ok {
+			var err error
+			if window.Days, err = parseWeekdays(days); err != nil {
+				return nil, fmt.Errorf("invalid working hours %q: %w", item, err)
+			}
+			span = strings.TrimSpace(clock)
+		}
+
+		from, to, ok := strings.Cut(span, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid working hours %q, expected [days] HH:MM-HH:MM", item)
+		}
+		var err error
+		if window.Start, err = parseClock(from); err != nil {
+			return nil, fmt.Errorf("invalid working hours %q: %w", item, err)
+		}
+		if window.End, err = parseClock(to); err != nil {
+			return nil, fmt.Errorf("invalid working hours %q: %w", item, err)
+		}
+		hours = append(hours, window)
+	}
+	return hours, nil
+}
+
+// parseWeekdays reads a day such as "sat" or a range such as "mon-fri", which
+// may wrap around the week
+func parseWeekdays(value string) (map[time.Weekday]bool, error) {
+	first, last, isRange := strings.Cut(strings.ToLower(value), "-")
+	from, ok := weekdays[first]
+	if !ok {
+		return nil, fmt.Errorf("unknown day %q", first)
+	}
+	to := from
+	if isRange {
+		if to, ok = weekdays[last]; !ok {
+			return nil, fmt.Errorf("unknown day %q", last)
+		}
+	}
+
+	days := map[time.Weekday]bool{from: true}
+	for day := from; day != to; {
+		day = (day + 1) % 7
+		days[day] = true
+	}
+	return days, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// Contains reports whether the local time of at falls in one of the windows
+func (h WorkingHours) Contains(at time.Time) bool {
+	if len(h) == 0 {
+		return true
+	}
+	at = at.Local()
+	offset := time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute + time.Duration(at.Second())*time.Second
+	for _, window := range h {
+		if window.contains(at.Weekday(), offset) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w HoursWindow) contains(day time.Weekday, offset time.Duration) bool {
+	startsOn := func(day time.Weekday) bool {
+		return len(w.Days) == 0 || w.Days[day]
+	}
+	switch {
+	case w.Start == w.End:
+		return startsOn(day)
+	case w.Start < w.End:
+		return startsOn(day) && offset >= w.Start && offset < w.End
+	default:
+		return (startsOn(day) && offset >= w.Start) || (startsOn((day+6)%7) && offset < w.End)
+	}
+}
+
+func (h WorkingHours) String() string {
+	parts := make([]string, len(h))
+	for i, window := range h {
+		parts[i] = window.text
+	}
+	return strings.Join(parts, ",")
+}
@@ -1,5 +1,26 @@
 package ports
 
+import "github.com/theblitlabs/parity-runner/internal/core/models"
+
 type MetricsProvider interface {
 	GetSystemMetrics() (memory int64, cpu float64)
 }
+
+// HostMetricsProvider reports the usage of the whole host, for heartbeats
+type HostMetricsProvider interface {
+	GetHostMetrics() models.HostMetrics
+}
+
+// FleetMember reports the runner settings a fleet document manages and applies
+// the directives the server answers heartbeats with. ApplyFleetDirective must not
+// block the heartbeat.
+type FleetMember interface {
+	FleetSettings() models.RunnerSettings
+	ApplyFleetDirective(directive models.FleetDirective)
+}
+
+// ManifestProvider builds the runner's signed manifest with its current
+// availability
+type ManifestProvider interface {
+	Manifest() (*models.RunnerManifest, error)
+}
@@ -0,0 +1,90 @@
+// Package credentials mints short-lived credentials for tasks from the
+// exchanges their creators registered, and carries them to the container
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	// DefaultTTL is the lifetime of credentials for tasks without a maximum
+	// duration
+	DefaultTTL = time.Hour
+	// minSTSTTL and maxSTSTTL are the session durations STS accepts
+	minSTSTTL = 15 * time.Minute
+	maxSTSTTL = 12 * time.Hour
+)
+
+// Exchange mints credentials for one task. session names the task in the
+// provider's own audit log.
+type Exchange interface {
+	Mint(ctx context.Context, session string, ttl time.Duration) (*models.TaskCredentials, error)
+}
+
+// NewExchange returns the exchange of a validated broker
+func NewExchange(broker *models.CredentialBroker, client *http.Client) (Exchange, error) {
+	if client == nil {
+		client = newPublicClient()
+	}
+	switch broker.Kind {
+	case models.CredentialBrokerAWSSTS:
+		return &stsExchange{config: *broker.AWS, client: client, now: time.Now}, nil
+	case models.CredentialBrokerOIDC:
+		return &oidcExchange{config: *broker.OIDC, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported broker kind %q", broker.Kind)
+	}
+}
+
+// TTL is how long credentials for task should live: its maximum duration,
+// capped by the broker
+func TTL(broker *models.CredentialBroker, task *models.Task) time.Duration {
+	ttl := task.MaxDuration()
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if limit := time.Duration(broker.MaxTTLSeconds) * time.Second; limit > 0 && ttl > limit {
+		ttl = limit
+	}
+	return ttl
+}
+
+// Prefixed returns the credentials with their variable names prefixed
+func Prefixed(credentials *models.TaskCredentials, prefix string) *models.TaskCredentials {
+	if prefix == "" {
+		return credentials
+	}
+	prefixed := *credentials
+	prefixed.Env = make(map[string]string, len(credentials.Env))
+	for name, value := range credentials.Env {
+		prefixed.Env[prefix+name] = value
+	}
+	return &prefixed
+}
+
+type contextKey struct{}
+
+// WithTaskCredentials hands credentials to the executor that runs the task
+func WithTaskCredentials(ctx context.Context, credentials []models.TaskCredentials) context.Context {
+	return context.WithValue(ctx, contextKey{}, credentials)
+}
+
+// Env returns the variables of the credentials in ctx as NAME=value, sorted by
+// name
+func Env(ctx context.Context) []string {
+	credentials, _ := ctx.Value(contextKey{}).([]models.TaskCredentials)
+	var env []string
+	for _, c := range credentials {
+		for name, value := range c.Env {
+			env = append(env, name+"="+value)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,203 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const assumeRoleResponse = `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIATEMP</AccessKeyId>
+      <SecretAccessKey>temp-secret</SecretAccessKey>
+      <SessionToken>temp-token</SessionToken>
+      <Expiration>2026-01-02T03:19:05Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`
+
+func TestSTSExchangeAssumesRole(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{
+			"Action":          "AssumeRole",
+			"RoleArn":         "arn:aws:iam::123456789012:role/upload",
+			"RoleSessionName": "parity-task",
+			"DurationSeconds": "900",
+			"ExternalId":      "creator",
+		}
+		for key, value := range want {
+			if got := r.PostForm.Get(key); got != value {
+				t.Errorf("%s = %q, want %q", key, got, value)
+			}
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDLONG/20260102/eu-west-1/sts/aws4_request") {
+			t.Errorf("Authorization = %q", auth)
+		}
+		if r.Header.Get("X-Amz-Date") != "20260102T030405Z" {
+			t.Errorf("X-Amz-Date = %q", r.Header.Get("X-Amz-Date"))
+		}
+		w.Write([]byte(assumeRoleResponse))
+	}))
+	defer server.Close()
+
+	exchange := &stsExchange{
+		config: models.AWSSTSExchange{
+			RoleARN:         "arn:aws:iam::123456789012:role/upload",
+			Region:          "eu-west-1",
+			AccessKeyID:     "AKIDLONG",
+			SecretAccessKey: "long-secret",
+			ExternalID:      "creator",
+			Endpoint:        server.URL,
+		},
+		client: server.Client(),
+		now:    func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+
+	// Durations below what STS accepts are raised to its minimum
+	creds, err := exchange.Mint(context.Background(), "parity-task", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Mint() error = %v", err)
+	}
+	if creds.Env["AWS_ACCESS_KEY_ID"] != "ASIATEMP" || creds.Env["AWS_SESSION_TOKEN"] != "temp-token" || creds.Env["AWS_REGION"] != "eu-west-1" {
+		t.Errorf("env = %v", creds.Env)
+	}
+	if want := time.Date(2026, 1, 2, 3, 19, 5, 0, time.UTC); !creds.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", creds.ExpiresAt, want)
+	}
+}
+
+func TestSTSExchangeReportsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<ErrorResponse><Error><Code>AccessDenied</Code><Message>not allowed</Message></Error></ErrorResponse>`))
+	}))
+	defer server.Close()
+
+	exchange := &stsExchange{
+		config: models.AWSSTSExchange{RoleARN: "arn:aws:iam::1:role/x", AccessKeyID: "a", SecretAccessKey: "b", Endpoint: server.URL},
+		client: server.Client(),
+		now:    time.Now,
+	}
+	_, err := exchange.Mint(context.Background(), "parity-task", time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied: not allowed") {
+		t.Fatalf("Mint() error = %v", err)
+	}
+}
+
+func TestOIDCExchangeGetsToken(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" {
+			t.Errorf("basic auth = %q:%q", id, secret)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("audience") != "api" {
+			t.Errorf("form = %v", r.PostForm)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "short-lived", "expires_in": 600})
+	}))
+	defer server.Close()
+
+	broker := &models.CredentialBroker{
+		Kind: models.CredentialBrokerOIDC,
+		OIDC: &models.OIDCExchange{TokenURL: server.URL, ClientID: "client", ClientSecret: "secret", Audience: "api"},
+	}
+	exchange, err := NewExchange(broker, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	creds, err := exchange.Mint(context.Background(), "parity-task", time.Hour)
+	if err != nil {
+		t.Fatalf("Mint() error = %v", err)
+	}
+	if creds.Env["ACCESS_TOKEN"] != "short-lived" || creds.Env["ACCESS_TOKEN_TYPE"] != "Bearer" {
+		t.Errorf("env = %v", creds.Env)
+	}
+	if lifetime := creds.ExpiresAt.Sub(before); lifetime < 9*time.Minute || lifetime > 11*time.Minute {
+		t.Errorf("credentials live %v, want the provider's 10m", lifetime)
+	}
+}
+
+func TestTTLIsCappedByBroker(t *testing.T) {
+	task := models.NewTask()
+	task.MaxDurationSecs = 7200
+
+	if got := TTL(&models.CredentialBroker{}, task); got != 2*time.Hour {
+		t.Errorf("TTL = %v, want the task's 2h", got)
+	}
+	if got := TTL(&models.CredentialBroker{MaxTTLSeconds: 1800}, task); got != 30*time.Minute {
+		t.Errorf("TTL = %v, want the broker's 30m", got)
+	}
+	if got := TTL(&models.CredentialBroker{}, models.NewTask()); got != DefaultTTL {
+		t.Errorf("TTL = %v, want the default", got)
+	}
+}
+
+func TestEnvFromContext(t *testing.T) {
+	issued := []models.TaskCredentials{
+		*Prefixed(&models.TaskCredentials{Env: map[string]string{"ACCESS_TOKEN": "t"}}, "API_"),
+		{Env: map[string]string{"AWS_SESSION_TOKEN": "s", "AWS_ACCESS_KEY_ID": "k"}},
+	}
+	got := Env(WithTaskCredentials(context.Background(), issued))
+	want := []string{"API_ACCESS_TOKEN=t", "AWS_ACCESS_KEY_ID=k", "AWS_SESSION_TOKEN=s"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Env() = %v, want %v", got, want)
+	}
+	if env := Env(context.Background()); len(env) != 0 {
+		t.Errorf("Env() without credentials = %v", env)
+	}
+}
+
+func TestCheckBrokerRefusesInternalSTSEndpoints(t *testing.T) {
+	broker := func(region, endpoint string) *models.CredentialBroker {
+		return &models.CredentialBroker{
+			Kind: models.CredentialBrokerAWSSTS,
+			AWS:  &models.AWSSTSExchange{RoleARN: "arn:aws:iam::1:role/x", Region: region, Endpoint: endpoint},
+		}
+	}
+	if err := CheckBroker(broker("eu-west-1", "")); err != nil {
+		t.Fatalf("CheckBroker() regional endpoint error = %v", err)
+	}
+	for _, b := range []*models.CredentialBroker{
+		broker("x@127.0.0.1/", ""),
+		broker("", "https://192.168.1.10/"),
+		broker("", "http://sts.example.com/"),
+	} {
+		if err := CheckBroker(b); err == nil {
+			t.Errorf("CheckBroker(%+v) = nil, want an error", b.AWS)
+		}
+	}
+}
+
+func TestPublicClientDoesNotDialLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the exchange reached a loopback server")
+	}))
+	defer server.Close()
+
+	resp, err := newPublicClient().Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Get() error = nil, want the loopback address refused")
+	}
+	if !errors.Is(err, errInternalAddress) {
+		t.Fatalf("Get() error = %v, want errInternalAddress", err)
+	}
+}
@@ -0,0 +1,97 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+var regionPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+var errInternalAddress = errors.New("exchange address is loopback, link-local or private")
+
+// CheckBroker refuses brokers whose exchange the server should not be made to
+// call: anything but https, and hosts that are loopback, link-local or private.
+// Names that resolve to such addresses are refused when they are dialled.
+func CheckBroker(broker *models.CredentialBroker) error {
+	switch broker.Kind {
+	case models.CredentialBrokerAWSSTS:
+		if broker.AWS.Region != "" && !regionPattern.MatchString(broker.AWS.Region) {
+			return fmt.Errorf("invalid aws region %q", broker.AWS.Region)
+		}
+		exchange := stsExchange{config: *broker.AWS}
+		return checkEndpoint(exchange.endpoint())
+	case models.CredentialBrokerOIDC:
+		return checkEndpoint(broker.OIDC.TokenURL)
+	default:
+		return fmt.Errorf("unsupported broker kind %q", broker.Kind)
+	}
+}
+
+func checkEndpoint(rawURL string) error {
+	endpoint, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid exchange URL: %w", err)
+	}
+	if endpoint.Scheme != "https" || endpoint.Host == "" || endpoint.User != nil {
+		return fmt.Errorf("exchange URL %q must be an https URL", rawURL)
+	}
+	host := strings.ToLower(strings.TrimSuffix(endpoint.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errInternalAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return errInternalAddress
+	}
+	return nil
+}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// newPublicClient returns the client exchanges are called with by default. It
+// only connects to public addresses and follows redirects that pass
+// checkEndpoint, so a broker cannot reach the server's own network through DNS
+// or a redirect.
+func newPublicClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%w: %s", errInternalAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return checkEndpoint(req.URL.String())
+		},
+	}
+}
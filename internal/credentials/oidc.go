@@ -0,0 +1,96 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// oidcExchange gets an access token with the OAuth 2.0 client credentials
+// grant. The provider decides how long the token lives, ttl only applies when
+// it does not say.
+type oidcExchange struct {
+	config models.OIDCExchange
+	client *http.Client
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type tokenError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oidcExchange) Mint(ctx context.Context, session string, ttl time.Duration) (*models.TaskCredentials, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if e.config.Scope != "" {
+		form.Set("scope", e.config.Scope)
+	}
+	if e.config.Audience != "" {
+		form.Set("audience", e.config.Audience)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(e.config.ClientID), url.QueryEscape(e.config.ClientSecret))
+
+	requestedAt := time.Now()
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var tokenErr tokenError
+		if json.Unmarshal(data, &tokenErr) == nil && tokenErr.Error != "" {
+			return nil, fmt.Errorf("token request refused: %s: %s", tokenErr.Error, tokenErr.Description)
+		}
+		return nil, fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access token")
+	}
+
+	expiresAt := requestedAt.Add(ttl)
+	if token.ExpiresIn > 0 {
+		expiresAt = requestedAt.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	expiresAt = expiresAt.UTC().Truncate(time.Second)
+	tokenType := token.TokenType
+	if tokenType == "" {
+		tokenType = "Bearer"
+	}
+	return &models.TaskCredentials{
+		Env: map[string]string{
+			"ACCESS_TOKEN":            token.AccessToken,
+			"ACCESS_TOKEN_TYPE":       tokenType,
+			"ACCESS_TOKEN_EXPIRES_AT": expiresAt.Format(time.RFC3339),
+		},
+		ExpiresAt: expiresAt,
+	}, nil
+}
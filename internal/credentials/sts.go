@@ -0,0 +1,174 @@
+package credentials
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	stsVersion     = "2011-06-15"
+	stsService     = "sts"
+	stsContentType = "application/x-www-form-urlencoded; charset=utf-8"
+	// stsDefaultRegion signs requests to a custom endpoint given without region
+	stsDefaultRegion = "us-east-1"
+)
+
+// stsExchange assumes a role with the AssumeRole call of AWS STS, signed with
+// Signature Version 4
+type stsExchange struct {
+	config models.AWSSTSExchange
+	client *http.Client
+	now    func() time.Time
+}
+
+type stsResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleResult>Credentials"`
+}
+
+type stsError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+func (e *stsExchange) region() string {
+	if e.config.Region != "" {
+		return e.config.Region
+	}
+	return stsDefaultRegion
+}
+
+func (e *stsExchange) endpoint() string {
+	if e.config.Endpoint != "" {
+		return e.config.Endpoint
+	}
+	return "https://sts." + e.config.Region + ".amazonaws.com/"
+}
+
+func (e *stsExchange) Mint(ctx context.Context, session string, ttl time.Duration) (*models.TaskCredentials, error) {
+	ttl = min(max(ttl, minSTSTTL), maxSTSTTL)
+
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {stsVersion},
+		"RoleArn":         {e.config.RoleARN},
+		"RoleSessionName": {session},
+		"DurationSeconds": {strconv.Itoa(int(ttl.Seconds()))},
+	}
+	if e.config.ExternalID != "" {
+		form.Set("ExternalId", e.config.ExternalID)
+	}
+	if e.config.Policy != "" {
+		form.Set("Policy", e.config.Policy)
+	}
+	body := form.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint(), strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create STS request: %w", err)
+	}
+	req.Header.Set("Content-Type", stsContentType)
+	e.sign(req, body)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("STS request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read STS response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var stsErr stsError
+		if xml.Unmarshal(data, &stsErr) == nil && stsErr.Code != "" {
+			return nil, fmt.Errorf("STS refused to assume role: %s: %s", stsErr.Code, stsErr.Message)
+		}
+		return nil, fmt.Errorf("STS request failed with status %d", resp.StatusCode)
+	}
+
+	var result stsResponse
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid STS response: %w", err)
+	}
+	creds := result.Credentials
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" || creds.SessionToken == "" {
+		return nil, fmt.Errorf("STS response has no credentials")
+	}
+
+	env := map[string]string{
+		"AWS_ACCESS_KEY_ID":         creds.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY":     creds.SecretAccessKey,
+		"AWS_SESSION_TOKEN":         creds.SessionToken,
+		"AWS_CREDENTIAL_EXPIRATION": creds.Expiration.UTC().Format(time.RFC3339),
+	}
+	if e.config.Region != "" {
+		env["AWS_REGION"] = e.config.Region
+	}
+	return &models.TaskCredentials{Env: env, ExpiresAt: creds.Expiration.UTC()}, nil
+}
+
+// sign adds the Signature Version 4 headers of the request with body
+func (e *stsExchange) sign(req *http.Request, body string) {
+	now := e.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalHeaders := "content-type:" + stsContentType + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	const signedHeaders = "content-type;host;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		hexSHA256(body),
+	}, "\n")
+
+	scope := date + "/" + e.region() + "/" + stsService + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+e.config.SecretAccessKey), date)
+	key = hmacSHA256(key, e.region())
+	key = hmacSHA256(key, stsService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		e.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,61 @@
+// Package daemon installs the runner as a service of the OS service manager,
+// systemd on Linux, launchd on macOS and the Service Control Manager on
+// Windows, so it starts at boot and is restarted when it fails.
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Status is the state of an installed service
+type Status string
+
+const (
+	StatusNotInstalled Status = "not installed"
+	StatusStopped      Status = "stopped"
+	StatusRunning      Status = "running"
+)
+
+var (
+	ErrNotInstalled = errors.New("service is not installed")
+	ErrUnsupported  = errors.New("services are not supported on this platform")
+)
+
+var nameRex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Config describes the service to install
+type Config struct {
+	// Name identifies the service to the service manager
+	Name        string
+	DisplayName string
+	Description string
+	// Executable and Args are the command the service runs
+	Executable string
+	Args       []string
+	WorkingDir string
+}
+
+// Manager installs and controls one service
+type Manager interface {
+	// Install writes the service definition and enables it at boot
+	Install(cfg Config) error
+	// Uninstall stops the service and removes its definition
+	Uninstall() error
+	Start() error
+	Stop() error
+	Status() (Status, error)
+	// Path is where the service is defined, a file or a registry key
+	Path() string
+}
+
+// New returns the manager of the service name. System services are used when
+// running as root or administrator, services of the logged in user otherwise
+// where the platform has them.
+func New(name string) (Manager, error) {
+	if !nameRex.MatchString(name) {
+		return nil, fmt.Errorf("invalid service name %q", name)
+	}
+	return newManager(name)
+}
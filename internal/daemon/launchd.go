@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/xml"
+)
+
+// launchdPlist renders the property list of cfg, started at load and kept
+// alive by launchd
+func launchdPlist(cfg Config) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+
+	key := func(name string) {
+		b.WriteString("\t<key>")
+		b.WriteString(name)
+		b.WriteString("</key>\n")
+	}
+	str := func(indent, value string) {
+		b.WriteString(indent + "<string>")
+		_ = xml.EscapeText(&b, []byte(value))
+		b.WriteString("</string>\n")
+	}
+
+	key("Label")
+	str("\t", cfg.Name)
+	key("ProgramArguments")
+	b.WriteString("\t<array>\n")
+	for _, arg := range append([]string{cfg.Executable}, cfg.Args...) {
+		str("\t\t", arg)
+	}
+	b.WriteString("\t</array>\n")
+	if cfg.WorkingDir != "" {
+		key("WorkingDirectory")
+		str("\t", cfg.WorkingDir)
+	}
+	key("RunAtLoad")
+	b.WriteString("\t<true/>\n")
+	key("KeepAlive")
+	b.WriteString("\t<true/>\n")
+	// Give the runner time to let running tasks finish when stopped
+	key("ExitTimeOut")
+	b.WriteString("\t<integer>900</integer>\n")
+	key("ThrottleInterval")
+	b.WriteString("\t<integer>10</integer>\n")
+
+	b.WriteString("</dict>\n</plist>\n")
+	return b.Bytes()
+}
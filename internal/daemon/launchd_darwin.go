@@ -0,0 +1,108 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const systemLaunchdDir = "/Library/LaunchDaemons"
+
+// launchd manages a launch agent of the user, or a launch daemon when run as
+// root
+type launchd struct {
+	name string
+	path string
+}
+
+func newManager(name string) (Manager, error) {
+	m := &launchd{name: name}
+	if os.Geteuid() == 0 {
+		m.path = filepath.Join(systemLaunchdDir, name+".plist")
+	} else {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		m.path = filepath.Join(homeDir, "Library", "LaunchAgents", name+".plist")
+	}
+	return m, nil
+}
+
+func (m *launchd) Path() string {
+	return m.path
+}
+
+func launchctl(args ...string) (string, error) {
+	output, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("launchctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+func (m *launchd) installed() bool {
+	_, err := os.Stat(m.path)
+	return err == nil
+}
+
+// Install writes the property list; launchd loads it at the next boot or
+// login, or when the service is started
+func (m *launchd) Install(cfg Config) error {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create launchd directory: %w", err)
+	}
+	if err := os.WriteFile(m.path, launchdPlist(cfg), 0o644); err != nil {
+		return fmt.Errorf("failed to write property list: %w", err)
+	}
+	return nil
+}
+
+func (m *launchd) Uninstall() error {
+	if !m.installed() {
+		return ErrNotInstalled
+	}
+	if status, err := m.Status(); err == nil && status == StatusRunning {
+		if err := m.Stop(); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(m.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove property list: %w", err)
+	}
+	return nil
+}
+
+func (m *launchd) Start() error {
+	if !m.installed() {
+		return ErrNotInstalled
+	}
+	_, err := launchctl("load", m.path)
+	return err
+}
+
+// Stop unloads the service, which launchd loads again at the next boot or
+// login
+func (m *launchd) Stop() error {
+	if !m.installed() {
+		return ErrNotInstalled
+	}
+	_, err := launchctl("unload", m.path)
+	return err
+}
+
+func (m *launchd) Status() (Status, error) {
+	if !m.installed() {
+		return StatusNotInstalled, nil
+	}
+	// list fails for services that are not loaded and shows a PID for
+	// running ones
+	output, err := launchctl("list", m.name)
+	if err != nil || !strings.Contains(output, `"PID" = `) {
+		return StatusStopped, nil
+	}
+	return StatusRunning, nil
+}
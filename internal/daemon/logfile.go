@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// LogFile is a log that rotates itself: once it would grow past maxSize it is
+// renamed to path.1, older files move up one number and the oldest beyond
+// keep is removed
+type LogFile struct {
+	path    string
+	maxSize int64
+	keep    int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenLogFile opens path for appending, creating it and its directory
+func OpenLogFile(path string, maxSize int64, keep int) (*LogFile, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("log file size limit must be positive")
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	l := &LogFile{path: path, maxSize: maxSize, keep: keep}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *LogFile) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first when p would take the file past its limit.
+// A single write larger than the limit still goes into one file.
+func (l *LogFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+	if l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+func (l *LogFile) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	l.file = nil
+
+	if l.keep == 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove log file: %w", err)
+		}
+		return l.open()
+	}
+
+	_ = os.Remove(l.backup(l.keep))
+	for i := l.keep - 1; i >= 1; i-- {
+		if err := os.Rename(l.backup(i), l.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	if err := os.Rename(l.path, l.backup(1)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return l.open()
+}
+
+func (l *LogFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
+
+// Close closes the current file
+func (l *LogFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
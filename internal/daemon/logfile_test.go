@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogFileRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "runner.log")
+	log, err := OpenLogFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("OpenLogFile() error = %v", err)
+	}
+	defer log.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := log.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v; want %q", filepath.Base(file), data, err, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than 2 rotated logs kept")
+	}
+}
+
+func TestLogFileAppendsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runner.log")
+	for _, line := range []string{"one\n", "two\n"} {
+		log, err := OpenLogFile(path, 1<<20, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := log.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		log.Close()
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\ntwo\n" {
+		t.Fatalf("log = %q, want both lines", data)
+	}
+}
+
+func TestUnitsQuoteTheCommand(t *testing.T) {
+	cfg := Config{
+		Name:        "parity-runner",
+		Description: "Parity Runner",
+		Executable:  "/opt/parity runner/parity-runner",
+		Args:        []string{"service", "run", "--log-file", "/var/log/100%/$HOME.log"},
+		WorkingDir:  "/srv/parity",
+	}
+
+	unit := systemdUnit(cfg, false)
+	for _, want := range []string{
+		`ExecStart="/opt/parity runner/parity-runner" "service" "run" "--log-file" "/var/log/100%%/$$HOME.log"`,
+		"WantedBy=multi-user.target",
+		"After=network-online.target docker.service",
+		"Restart=always",
+		"WorkingDirectory=/srv/parity\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("system unit misses %q:\n%s", want, unit)
+		}
+	}
+	if unit := systemdUnit(cfg, true); !strings.Contains(unit, "WantedBy=default.target") || strings.Contains(unit, "docker.service") {
+		t.Errorf("user unit should start with the user's session:\n%s", unit)
+	}
+	if unit := systemdUnit(Config{Executable: "/bin/true", WorkingDir: "/srv/100% $HOME"}, false); !strings.Contains(unit, "WorkingDirectory=/srv/100%% $HOME\n") {
+		t.Errorf("working directory should only escape specifiers:\n%s", unit)
+	}
+
+	cfg.Args = append(cfg.Args, "--config-path", "/etc/parity/<a&b>.env")
+	plist := string(launchdPlist(cfg))
+	for _, want := range []string{
+		"<string>/opt/parity runner/parity-runner</string>",
+		"<string>/etc/parity/&lt;a&amp;b&gt;.env</string>",
+		"<key>KeepAlive</key>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("property list misses %q:\n%s", want, plist)
+		}
+	}
+}
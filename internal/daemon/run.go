@@ -0,0 +1,52 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// Run runs executable with its output going to out until it exits, which is
+// always an error, or until ctx is cancelled. Cancelling asks the process to
+// stop and waits for it, leaving any time limit on stopping to the service
+// manager.
+func Run(ctx context.Context, executable string, args []string, out io.Writer) error {
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", executable, err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		// Only a stop request should end the service, so the service
+		// manager restarts a runner that exits by itself
+		if err == nil {
+			return errors.New("runner exited")
+		}
+		return exitError(err)
+	case <-ctx.Done():
+		if err := stopProcess(cmd.Process); err != nil {
+			return fmt.Errorf("failed to stop %s: %w", executable, err)
+		}
+		// Exiting on the stop request is not a failure
+		if err := <-done; err != nil && !isStopExit(err) {
+			return exitError(err)
+		}
+		return nil
+	}
+}
+
+func exitError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("runner exited with status %d", exitErr.ExitCode())
+	}
+	return err
+}
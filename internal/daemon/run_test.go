@@ -0,0 +1,28 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunRestartsOnExitAndStopsOnCancel(t *testing.T) {
+	var out bytes.Buffer
+	err := Run(context.Background(), "sh", []string{"-c", "echo started"}, &out)
+	if err == nil {
+		t.Fatal("Run() of a runner that exits by itself should fail so it is restarted")
+	}
+	if !strings.Contains(out.String(), "started") {
+		t.Fatalf("output = %q, want the runner's output", out.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if err := Run(ctx, "sleep", []string{"30"}, &out); err != nil {
+		t.Fatalf("Run() stopped on request error = %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// RunService calls run with a context that is cancelled when the service
+// manager asks the service to stop
+func RunService(name string, run func(ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return run(ctx)
+}
+
+func stopProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
+
+func isStopExit(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGTERM
+}
@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// RunService calls run under the Service Control Manager when started as a
+// Windows service, cancelling its context when the service is stopped, and
+// on an interrupt otherwise
+func RunService(name string, run func(ctx context.Context) error) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return run(ctx)
+	}
+
+	h := &handler{run: run}
+	if err := svc.Run(name, h); err != nil {
+		return err
+	}
+	return h.err
+}
+
+type handler struct {
+	run func(ctx context.Context) error
+	err error
+}
+
+func (h *handler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- h.run(ctx) }()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case h.err = <-done:
+			status <- svc.Status{State: svc.StopPending}
+			if h.err != nil {
+				// A non-zero exit code makes the recovery actions restart it
+				return false, 1
+			}
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
+
+// stopProcess kills the runner, as a process without a console cannot be
+// sent an interrupt
+func stopProcess(process *os.Process) error {
+	return process.Kill()
+}
+
+func isStopExit(error) bool {
+	return true
+}
@@ -0,0 +1,168 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// stopTimeout is how long Stop waits for the service to report it stopped
+const stopTimeout = 15 * time.Minute
+
+// scm manages a service of the Windows Service Control Manager, which needs
+// an administrator
+type scm struct {
+	name string
+}
+
+func newManager(name string) (Manager, error) {
+	return &scm{name: name}, nil
+}
+
+func (m *scm) Path() string {
+	return `HKLM\SYSTEM\CurrentControlSet\Services\` + m.name
+}
+
+func (m *scm) open() (*mgr.Mgr, *mgr.Service, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	service, err := manager.OpenService(m.name)
+	if err != nil {
+		manager.Disconnect()
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return nil, nil, ErrNotInstalled
+		}
+		return nil, nil, fmt.Errorf("failed to open service: %w", err)
+	}
+	return manager, service, nil
+}
+
+func (m *scm) Install(cfg Config) error {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer manager.Disconnect()
+
+	config := mgr.Config{
+		DisplayName: cfg.DisplayName,
+		Description: cfg.Description,
+		StartType:   mgr.StartAutomatic,
+		// Start once the network and Docker are likely up
+		DelayedAutoStart: true,
+	}
+	service, err := manager.OpenService(m.name)
+	if err == nil {
+		// Reinstalling updates the command of the existing service
+		config.BinaryPathName = windows.ComposeCommandLine(append([]string{cfg.Executable}, cfg.Args...))
+		err = service.UpdateConfig(config)
+	} else {
+		service, err = manager.CreateService(m.name, cfg.Executable, config, cfg.Args...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to install service: %w", err)
+	}
+	defer service.Close()
+
+	// Restart the runner when it fails, waiting longer after repeated failures
+	actions := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+	}
+	if err := service.SetRecoveryActions(actions, uint32((24 * time.Hour).Seconds())); err != nil {
+		return fmt.Errorf("failed to set recovery actions: %w", err)
+	}
+	// The service stops with an exit code rather than crashing when the
+	// runner fails
+	if err := service.SetRecoveryActionsOnNonCrashFailures(true); err != nil {
+		return fmt.Errorf("failed to set recovery actions: %w", err)
+	}
+	return nil
+}
+
+func (m *scm) Uninstall() error {
+	if err := m.Stop(); err != nil && !errors.Is(err, ErrNotInstalled) {
+		return err
+	}
+	manager, service, err := m.open()
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+	defer service.Close()
+
+	if err := service.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+	return nil
+}
+
+func (m *scm) Start() error {
+	manager, service, err := m.open()
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+	defer service.Close()
+
+	if err := service.Start(); err != nil && !errors.Is(err, windows.ERROR_SERVICE_ALREADY_RUNNING) {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+	return nil
+}
+
+func (m *scm) Stop() error {
+	manager, service, err := m.open()
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+	defer service.Close()
+
+	status, err := service.Control(svc.Stop)
+	if errors.Is(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+	deadline := time.Now().Add(stopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service did not stop within %s", stopTimeout)
+		}
+		time.Sleep(500 * time.Millisecond)
+		if status, err = service.Query(); err != nil {
+			return fmt.Errorf("failed to query service: %w", err)
+		}
+	}
+	return nil
+}
+
+func (m *scm) Status() (Status, error) {
+	manager, service, err := m.open()
+	if errors.Is(err, ErrNotInstalled) {
+		return StatusNotInstalled, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer manager.Disconnect()
+	defer service.Close()
+
+	status, err := service.Query()
+	if err != nil {
+		return "", fmt.Errorf("failed to query service: %w", err)
+	}
+	if status.State == svc.Stopped {
+		return StatusStopped, nil
+	}
+	return StatusRunning, nil
+}
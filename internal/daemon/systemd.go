@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// systemdUnit renders the unit of cfg. User units start with the user's
+// systemd instance rather than at boot, unless lingering is enabled.
+func systemdUnit(cfg Config, user bool) string {
+	after := "network-online.target"
+	target := "default.target"
+	if !user {
+		// Docker tasks need the daemon, which user units cannot order after
+		after += " docker.service"
+		target = "multi-user.target"
+	}
+
+	command := make([]string, 0, len(cfg.Args)+1)
+	for _, arg := range append([]string{cfg.Executable}, cfg.Args...) {
+		command = append(command, systemdQuote(arg))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", systemdEscape(cfg.Description))
+	fmt.Fprintf(&b, "Wants=network-online.target\n")
+	fmt.Fprintf(&b, "After=%s\n", after)
+	fmt.Fprintf(&b, "\n[Service]\n")
+	fmt.Fprintf(&b, "Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(command, " "))
+	if cfg.WorkingDir != "" {
+		// Paths are not quoted and expand specifiers but not variables
+		fmt.Fprintf(&b, "WorkingDirectory=%s\n", strings.ReplaceAll(cfg.WorkingDir, "%", "%%"))
+	}
+	fmt.Fprintf(&b, "Restart=always\n")
+	fmt.Fprintf(&b, "RestartSec=10\n")
+	// Only the wrapper is signalled so it can stop the runner, which may
+	// take a while to let running tasks finish
+	fmt.Fprintf(&b, "KillMode=mixed\n")
+	fmt.Fprintf(&b, "TimeoutStopSec=15min\n")
+	fmt.Fprintf(&b, "\n[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=%s\n", target)
+	return b.String()
+}
+
+// systemdQuote quotes a word of a command line, escaping specifiers and
+// variables systemd would otherwise expand
+func systemdQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+	return `"` + systemdEscape(s) + `"`
+}
+
+func systemdEscape(s string) string {
+	return strings.NewReplacer("%", "%%", "$", "$$", "\n", " ").Replace(s)
+}
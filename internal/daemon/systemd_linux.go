@@ -0,0 +1,111 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const systemSystemdDir = "/etc/systemd/system"
+
+type systemd struct {
+	name string
+	user bool
+	path string
+}
+
+func newManager(name string) (Manager, error) {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return nil, fmt.Errorf("%w: systemctl not found", ErrUnsupported)
+	}
+	m := &systemd{name: name, user: os.Geteuid() != 0}
+	if m.user {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find user config directory: %w", err)
+		}
+		m.path = filepath.Join(configDir, "systemd", "user", name+".service")
+	} else {
+		m.path = filepath.Join(systemSystemdDir, name+".service")
+	}
+	return m, nil
+}
+
+func (m *systemd) Path() string {
+	return m.path
+}
+
+func (m *systemd) systemctl(args ...string) (string, error) {
+	if m.user {
+		args = append([]string{"--user"}, args...)
+	}
+	output, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+func (m *systemd) installed() bool {
+	_, err := os.Stat(m.path)
+	return err == nil
+}
+
+func (m *systemd) Install(cfg Config) error {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create unit directory: %w", err)
+	}
+	if err := os.WriteFile(m.path, []byte(systemdUnit(cfg, m.user)), 0o644); err != nil {
+		return fmt.Errorf("failed to write unit: %w", err)
+	}
+	if _, err := m.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	_, err := m.systemctl("enable", m.name+".service")
+	return err
+}
+
+func (m *systemd) Uninstall() error {
+	if !m.installed() {
+		return ErrNotInstalled
+	}
+	if _, err := m.systemctl("disable", "--now", m.name+".service"); err != nil {
+		return err
+	}
+	if err := os.Remove(m.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove unit: %w", err)
+	}
+	_, err := m.systemctl("daemon-reload")
+	return err
+}
+
+func (m *systemd) Start() error {
+	if !m.installed() {
+		return ErrNotInstalled
+	}
+	_, err := m.systemctl("start", m.name+".service")
+	return err
+}
+
+func (m *systemd) Stop() error {
+	if !m.installed() {
+		return ErrNotInstalled
+	}
+	_, err := m.systemctl("stop", m.name+".service")
+	return err
+}
+
+func (m *systemd) Status() (Status, error) {
+	if !m.installed() {
+		return StatusNotInstalled, nil
+	}
+	// is-active exits non-zero for every state but active
+	output, _ := m.systemctl("is-active", m.name+".service")
+	if strings.TrimSpace(output) == "active" {
+		return StatusRunning, nil
+	}
+	return StatusStopped, nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !windows
+
+package daemon
+
+func newManager(string) (Manager, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,298 @@
+// Package datacache keeps datasets downloaded from IPFS on disk, keyed by CID,
+// so that every federated learning round does not download them again. The
+// least recently used datasets are evicted when the cache outgrows its size.
+package datacache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	datasetsDirName = "datasets"
+	dataSuffix      = ".data"
+	metadataSuffix  = ".json"
+	// DefaultMaxBytes is the size of the cache when none is configured
+	DefaultMaxBytes int64 = 10 << 30
+)
+
+var (
+	// ErrNotFound is returned for datasets that are not cached
+	ErrNotFound = errors.New("dataset not cached")
+	// ErrCorrupt is returned for cached datasets whose content no longer
+	// matches the hash recorded when they were stored. They are removed.
+	ErrCorrupt = errors.New("cached dataset is corrupt")
+)
+
+// Entry describes a cached dataset
+type Entry struct {
+	CID      string    `json:"cid"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	AddedAt  time.Time `json:"added_at"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+type Cache struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+	now      func() time.Time
+}
+
+// DefaultDir is shared by every instance and network on the host, since a CID
+// names the same content everywhere
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, utils.KeystoreDirName, datasetsDirName), nil
+}
+
+// New keeps at most maxBytes of datasets under dir
+func New(dir string, maxBytes int64) *Cache {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	return &Cache{dir: dir, maxBytes: maxBytes, now: time.Now}
+}
+
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+func (c *Cache) MaxBytes() int64 {
+	return c.maxBytes
+}
+
+func (c *Cache) paths(cid string) (string, string, error) {
+	if err := ipfs.ValidateCID(cid); err != nil {
+		return "", "", err
+	}
+	base := filepath.Join(c.dir, cid)
+	return base + dataSuffix, base + metadataSuffix, nil
+}
+
+// Get returns a cached dataset after checking it against the hash recorded
+// when it was stored
+func (c *Cache) Get(cid string) ([]byte, error) {
+	dataPath, metadataPath, err := c.paths(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, err := readEntry(metadataPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		c.removeLocked(cid)
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	data, err := os.ReadFile(dataPath)
+	if errors.Is(err, os.ErrNotExist) {
+		c.removeLocked(cid)
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached dataset %s: %w", cid, err)
+	}
+	if int64(len(data)) != entry.Size || ipfs.SHA256Hex(data) != entry.SHA256 {
+		c.removeLocked(cid)
+		return nil, fmt.Errorf("%w: %s does not match its recorded hash", ErrCorrupt, cid)
+	}
+
+	entry.LastUsed = c.now().UTC()
+	if err := writeFileAtomic(metadataPath, entry); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Put stores a dataset and evicts the least recently used others until the
+// cache fits its size again. Datasets larger than the whole cache are not
+// stored.
+func (c *Cache) Put(cid string, data []byte) error {
+	dataPath, metadataPath, err := c.paths(cid)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > c.maxBytes {
+		return fmt.Errorf("dataset %s is %d bytes, larger than the %d byte cache", cid, len(data), c.maxBytes)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create dataset cache: %w", err)
+	}
+	now := c.now().UTC()
+	entry := &Entry{CID: cid, Size: int64(len(data)), SHA256: ipfs.SHA256Hex(data), AddedAt: now, LastUsed: now}
+	if err := writeFileAtomic(dataPath, data); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(metadataPath, entry); err != nil {
+		os.Remove(dataPath)
+		return err
+	}
+	return c.evictLocked(cid)
+}
+
+// evictLocked removes the least recently used datasets other than keep until
+// the cache fits
+func (c *Cache) evictLocked(keep string) error {
+	entries, err := c.listLocked()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	// entries are most recently used first
+	for i := len(entries) - 1; i >= 0 && total > c.maxBytes; i-- {
+		if entries[i].CID == keep {
+			continue
+		}
+		if err := c.removeLocked(entries[i].CID); err != nil {
+			return err
+		}
+		total -= entries[i].Size
+	}
+	return nil
+}
+
+// List returns the cached datasets, most recently used first
+func (c *Cache) List() ([]Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.listLocked()
+}
+
+func (c *Cache) listLocked() ([]Entry, error) {
+	files, err := os.ReadDir(c.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset cache: %w", err)
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, metadataSuffix) {
+			continue
+		}
+		entry, err := readEntry(filepath.Join(c.dir, name))
+		if err != nil || entry.CID != strings.TrimSuffix(name, metadataSuffix) {
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsed.After(entries[j].LastUsed) })
+	return entries, nil
+}
+
+// Verify checks a cached dataset against its recorded hash and removes it if
+// it does not match
+func (c *Cache) Verify(cid string) error {
+	_, err := c.Get(cid)
+	return err
+}
+
+// Remove deletes a cached dataset
+func (c *Cache) Remove(cid string) error {
+	if _, _, err := c.paths(cid); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.removeLocked(cid)
+}
+
+func (c *Cache) removeLocked(cid string) error {
+	base := filepath.Join(c.dir, cid)
+	for _, path := range []string{base + metadataSuffix, base + dataSuffix} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove cached dataset %s: %w", cid, err)
+		}
+	}
+	return nil
+}
+
+// Purge removes every cached dataset and returns how many bytes it freed
+func (c *Cache) Purge() (int, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.listLocked()
+	if err != nil {
+		return 0, 0, err
+	}
+	var freed int64
+	for i, entry := range entries {
+		if err := c.removeLocked(entry.CID); err != nil {
+			return i, freed, err
+		}
+		freed += entry.Size
+	}
+	return len(entries), freed, nil
+}
+
+func readEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid cache metadata %s: %w", filepath.Base(path), err)
+	}
+	return &entry, nil
+}
+
+// writeFileAtomic writes raw bytes or the JSON of value so that concurrent
+// runners never read a partial file
+func writeFileAtomic(path string, value interface{}) error {
+	data, ok := value.([]byte)
+	if !ok {
+		var err error
+		if data, err = json.MarshalIndent(value, "", "  "); err != nil {
+			return fmt.Errorf("failed to marshal cache metadata: %w", err)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write dataset cache: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write dataset cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package datacache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, maxBytes int64) *Cache {
+	t.Helper()
+
+	cache := New(t.TempDir(), maxBytes)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return cache
+}
+
+func TestCacheGetVerifiesContent(t *testing.T) {
+	cache := newTestCache(t, 1024)
+
+	if _, err := cache.Get("bafydata"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() before Put = %v, want ErrNotFound", err)
+	}
+	if err := cache.Put("bafydata", []byte("a,b\n1,2\n")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := cache.Get("bafydata")
+	if err != nil || !bytes.Equal(data, []byte("a,b\n1,2\n")) {
+		t.Fatalf("Get() = %q, %v", data, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(cache.Dir(), "bafydata"+dataSuffix), []byte("a,b\n9,9\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Get("bafydata"); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Get() of tampered dataset = %v, want ErrCorrupt", err)
+	}
+	if entries, _ := cache.List(); len(entries) != 0 {
+		t.Fatal("corrupt dataset was kept")
+	}
+	if _, err := cache.Get("../escape"); err == nil {
+		t.Fatal("Get() accepted a path as CID")
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newTestCache(t, 10)
+	four := []byte("1234")
+
+	for _, cid := range []string{"bafyone", "bafytwo"} {
+		if err := cache.Put(cid, four); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// bafyone is used again, so bafytwo is the least recently used
+	if _, err := cache.Get("bafyone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Put("bafythree", four); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := cache.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].CID != "bafythree" || entries[1].CID != "bafyone" {
+		t.Fatalf("entries = %+v, want bafythree and bafyone", entries)
+	}
+	if err := cache.Put("bafyhuge", make([]byte, 11)); err == nil {
+		t.Fatal("Put() stored a dataset larger than the cache")
+	}
+
+	count, freed, err := cache.Purge()
+	if err != nil || count != 2 || freed != 8 {
+		t.Fatalf("Purge() = %d, %d, %v", count, freed, err)
+	}
+}
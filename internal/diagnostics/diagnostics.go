@@ -0,0 +1,228 @@
+// Package diagnostics assembles the bundle attached to the result of a failed
+// task: the end of the container's log, how the container ended, its resource
+// usage over time, a fingerprint of the runner and a class of the failure.
+//
+// Bundles leave the runner, so the values of the task's environment and
+// credentials, and anything in the text that looks like a secret, are replaced
+// by [REDACTED]. Bundles over the size limit keep fewer metrics samples and lose
+// their oldest log lines first.
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/provenance"
+)
+
+const (
+	// DefaultLogLines is how many lines of the container's log a bundle keeps
+	DefaultLogLines = 200
+	// DefaultMaxBytes is the size limit of a bundle's JSON
+	DefaultMaxBytes = 64 << 10
+
+	// Redacted replaces secrets in a bundle
+	Redacted = "[REDACTED]"
+
+	// minSecretLength keeps short environment values such as "1" or "true"
+	// from redacting half the log
+	minSecretLength = 6
+	maxErrorLength  = 4 << 10
+	// minSamples of the metrics timeline are kept before log lines are dropped
+	minSamples = 60
+)
+
+// Config shapes the bundles of a runner. Zero values use the defaults.
+type Config struct {
+	Disabled bool
+	LogLines int
+	MaxBytes int
+	// Runtime is the container runtime named in the fingerprint
+	Runtime string
+	GPUs    []models.GPUInfo
+}
+
+func (c Config) withDefaults() Config {
+	if c.LogLines <= 0 {
+		c.LogLines = DefaultLogLines
+	}
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = DefaultMaxBytes
+	}
+	return c
+}
+
+// Lines is how many log lines a bundle keeps
+func (c Config) Lines() int {
+	return c.withDefaults().LogLines
+}
+
+// secretPatterns match credentials whatever variable they came from. The
+// first group of each is kept.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(\b[a-z0-9_.-]*(?:password|passwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credential)[a-z0-9_.-]*["']?\s*[=:]\s*)("[^"]*"|'[^']*'|[^\s,;&]+)`),
+	regexp.MustCompile(`(?i)(\bbearer\s+)[a-z0-9._~+/=-]+`),
+	regexp.MustCompile(`(://)[^/\s:@]+:[^/\s@]+@`),
+	regexp.MustCompile(`()\b0x[0-9a-fA-F]{64}\b`),
+	regexp.MustCompile(`()\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+}
+
+// Tail returns the last n lines of logs
+func Tail(logs string, n int) []string {
+	lines := strings.Split(strings.TrimRight(logs, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// Secrets returns the values of NAME=value variables long enough to redact
+func Secrets(env []string) []string {
+	var secrets []string
+	for _, variable := range env {
+		_, value, ok := strings.Cut(variable, "=")
+		if ok && len(value) >= minSecretLength {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+// Scrub replaces the secrets and anything that looks like a credential in the
+// text of d
+func Scrub(d *models.FailureDiagnostics, secrets []string) {
+	scrub := func(text string) string {
+		for _, secret := range secrets {
+			if n := strings.Count(text, secret); n > 0 {
+				d.Redacted += n
+				text = strings.ReplaceAll(text, secret, Redacted)
+			}
+		}
+		for _, pattern := range secretPatterns {
+			text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+				if strings.Contains(match, Redacted) {
+					return match
+				}
+				d.Redacted++
+				return pattern.ReplaceAllString(match, "${1}"+Redacted)
+			})
+		}
+		return text
+	}
+
+	d.Error = scrub(d.Error)
+	for i, line := range d.LogTail {
+		d.LogTail[i] = scrub(line)
+	}
+	if d.Container != nil {
+		d.Container.Error = scrub(d.Container.Error)
+	}
+}
+
+// Finish completes a bundle before it is attached to a result: the class is
+// refined with the container's state, the runner is fingerprinted, secrets are
+// redacted and the bundle is cut to the size limit.
+func Finish(d *models.FailureDiagnostics, config Config, secrets []string) {
+	config = config.withDefaults()
+	d.Class = classify(d)
+	d.Environment = Fingerprint(config.Runtime, config.GPUs)
+	if len(d.Error) > maxErrorLength {
+		d.Error = d.Error[:maxErrorLength]
+		d.Truncated = true
+	}
+	if len(d.LogTail) > config.LogLines {
+		d.LogTail = d.LogTail[len(d.LogTail)-config.LogLines:]
+		d.Truncated = true
+	}
+	Scrub(d, secrets)
+	limit(d, config.MaxBytes)
+}
+
+// classify tells OOM kills and other kills apart from plain non-zero exits
+func classify(d *models.FailureDiagnostics) string {
+	if d.Container == nil {
+		return d.Class
+	}
+	switch {
+	case d.Container.OOMKilled:
+		return models.DiagnosticOOMKilled
+	case d.Class == models.DiagnosticExitCode && d.Container.ExitCode == 137:
+		return models.DiagnosticKilled
+	default:
+		return d.Class
+	}
+}
+
+// limit thins the metrics timeline down to minSamples, then drops the oldest
+// log lines, then thins the timeline further, until the JSON of d fits in
+// maxBytes
+func limit(d *models.FailureDiagnostics, maxBytes int) {
+	for {
+		data, err := json.Marshal(d)
+		if err != nil || len(data) <= maxBytes {
+			return
+		}
+		switch {
+		case len(d.Metrics) > minSamples:
+			d.Metrics = thin(d.Metrics)
+		case len(d.LogTail) > 0:
+			d.LogTail = d.LogTail[(len(d.LogTail)+3)/4:]
+		case len(d.Metrics) > 0:
+			d.Metrics = thin(d.Metrics)
+		case len(d.Error) > 256:
+			d.Error = d.Error[:256]
+		default:
+			return
+		}
+		d.Truncated = true
+	}
+}
+
+// thin keeps every other sample, ending with the last
+func thin(samples []models.MetricsSample) []models.MetricsSample {
+	if len(samples) == 1 {
+		return nil
+	}
+	kept := make([]models.MetricsSample, 0, len(samples)/2)
+	for i := (len(samples) - 1) % 2; i < len(samples); i += 2 {
+		kept = append(kept, samples[i])
+	}
+	return kept
+}
+
+// Fingerprint describes the runner: its release, platform, kernel, CPUs,
+// container runtime and GPU models. GPU serials are left out.
+func Fingerprint(containerRuntime string, gpus []models.GPUInfo) models.RunnerEnvironment {
+	build := provenance.Current()
+	environment := models.RunnerEnvironment{
+		Version:   build.RunnerVersion,
+		Commit:    build.Commit,
+		GoVersion: build.GoVersion,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Kernel:    kernelRelease(),
+		CPUs:      runtime.NumCPU(),
+		Runtime:   containerRuntime,
+	}
+	for _, gpu := range gpus {
+		environment.GPUs = append(environment.GPUs, fmt.Sprintf("%s (%d MiB)", gpu.Model, gpu.MemoryMB))
+	}
+	return environment
+}
+
+// kernelRelease is the Linux kernel release, empty on other systems
+func kernelRelease() string {
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(release))
+}
@@ -0,0 +1,164 @@
+// Package energy weighs what a task pays against the electricity it would use,
+// so a runner can decline work that costs more to run than it earns while
+// power is expensive.
+package energy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// durationWeight is how much each finished task moves the expected duration of
+// its class
+const durationWeight = 0.2
+
+// Config prices the electricity tasks use. Prices are per kWh, in the currency
+// TokenPrice values a reward token in.
+type Config struct {
+	Prices     PriceSource
+	TokenPrice float64
+	// PeakPrice is the price from which tasks are weighed; cheaper power takes
+	// every task. Zero weighs tasks at every price.
+	PeakPrice float64
+	// Watts is what the host draws running a task. With how long tasks of a
+	// class took so far, it estimates the energy of classes TaskEnergy lacks.
+	Watts      float64
+	TaskEnergy TaskEnergy
+}
+
+// TaskEnergy is the kWh a task of a class uses, by task type or by type:model
+type TaskEnergy map[string]float64
+
+// ParseTaskEnergy parses comma separated class=kWh pairs, where a class is a
+// task type optionally followed by :model, as in "docker=0.05,llm:llama3:70b=0.02"
+func ParseTaskEnergy(list string) (TaskEnergy, error) {
+	energy := make(TaskEnergy)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid task energy %q, expected type=kWh", item)
+		}
+		kwh, err := strconv.ParseFloat(strings.TrimSpace(item[i+1:]), 64)
+		if err != nil || kwh < 0 {
+			return nil, fmt.Errorf("invalid task energy %q", item)
+		}
+		energy[strings.ToLower(strings.TrimSpace(item[:i]))] = kwh
+	}
+	return energy, nil
+}
+
+// classes are the keys a task is estimated by, its model before its type
+func classes(task *models.Task) []string {
+	taskType := string(task.Type)
+	if model := models.TaskModel(task); model != "" {
+		return []string{taskType + ":" + strings.ToLower(model), taskType}
+	}
+	return []string{taskType}
+}
+
+// Estimate is what a task would cost in electricity and what it pays, in the
+// same currency
+type Estimate struct {
+	KWh   float64 `json:"kwh"`
+	Price float64 `json:"price_per_kwh"`
+	Cost  float64 `json:"cost"`
+	Value float64 `json:"value"`
+}
+
+// Economical reports whether the task pays at least what it costs
+func (e Estimate) Economical() bool {
+	return e.Value >= e.Cost
+}
+
+// Scheduler decides whether tasks are worth their electricity
+type Scheduler struct {
+	config Config
+
+	mu        sync.Mutex
+	durations map[string]time.Duration
+}
+
+func NewScheduler(config Config) *Scheduler {
+	return &Scheduler{config: config, durations: make(map[string]time.Duration)}
+}
+
+// Observe records how long a task took, which estimates the energy of later
+// tasks of its class
+func (s *Scheduler) Observe(task *models.Task, took time.Duration) {
+	if took <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, class := range classes(task) {
+		previous, ok := s.durations[class]
+		if !ok {
+			s.durations[class] = took
+			continue
+		}
+		s.durations[class] = previous + time.Duration(durationWeight*float64(took-previous))
+	}
+}
+
+// kwh estimates the energy of a task, false when nothing is known of its class
+func (s *Scheduler) kwh(task *models.Task) (float64, bool) {
+	keys := classes(task)
+	for _, class := range keys {
+		if kwh, ok := s.config.TaskEnergy[class]; ok {
+			return kwh, true
+		}
+	}
+	if s.config.Watts <= 0 {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, class := range keys {
+		if took, ok := s.durations[class]; ok {
+			return s.config.Watts / 1000 * took.Hours(), true
+		}
+	}
+	return 0, false
+}
+
+// Estimate prices a task at the given time. It is false when the energy of the
+// task's class is unknown.
+func (s *Scheduler) Estimate(ctx context.Context, task *models.Task, at time.Time) (Estimate, bool, error) {
+	kwh, ok := s.kwh(task)
+	if !ok {
+		return Estimate{}, false, nil
+	}
+	price, err := s.config.Prices.Price(ctx, at)
+	if err != nil {
+		return Estimate{}, false, err
+	}
+	return Estimate{
+		KWh:   kwh,
+		Price: price,
+		Cost:  kwh * price,
+		Value: task.Reward * s.config.TokenPrice,
+	}, true, nil
+}
+
+// Declines reports whether the task should be declined as uneconomical: power
+// is at or above the peak price and the task pays less than it costs. Tasks
+// that cannot be priced are taken.
+func (s *Scheduler) Declines(ctx context.Context, task *models.Task, at time.Time) (Estimate, bool, error) {
+	estimate, ok, err := s.Estimate(ctx, task, at)
+	if err != nil || !ok {
+		return estimate, false, err
+	}
+	if estimate.Price < s.config.PeakPrice {
+		return estimate, false, nil
+	}
+	return estimate, !estimate.Economical(), nil
+}
@@ -0,0 +1,123 @@
+package energy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2026, 3, 2, hour, minute, 0, 0, time.Local)
+}
+
+func TestTariffWindows(t *testing.T) {
+	tariff, err := ParseTariff("07:00-17:00=0.25, 17:00-21:00=0.40, 23:00-06:00=0.08", 0.15)
+	if err != nil {
+		t.Fatalf("ParseTariff() error = %v", err)
+	}
+
+	for _, tc := range []struct {
+		at   time.Time
+		want float64
+	}{
+		{at(9, 30), 0.25},
+		{at(17, 0), 0.40},
+		{at(22, 0), 0.15},
+		{at(23, 30), 0.08},
+		{at(2, 0), 0.08},
+		{at(6, 30), 0.15},
+	} {
+		if got, _ := tariff.Price(context.Background(), tc.at); got != tc.want {
+			t.Errorf("Price(%s) = %v, want %v", tc.at.Format("15:04"), got, tc.want)
+		}
+	}
+
+	for _, invalid := range []string{"07:00=0.2", "7-17=0.2", "07:00-17:00=cheap", "25:00-01:00=0.1"} {
+		if _, err := ParseTariff(invalid, 0); err == nil {
+			t.Errorf("ParseTariff(%q) accepted an invalid window", invalid)
+		}
+	}
+}
+
+func TestPriceAPICachesAndFallsBack(t *testing.T) {
+	requests := 0
+	up := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if !up {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]float64{"eur_per_kwh": 0.31})
+	}))
+	defer server.Close()
+
+	api := &PriceAPI{
+		URL:      server.URL,
+		Field:    "eur_per_kwh",
+		Refresh:  5 * time.Minute,
+		Fallback: Tariff{Default: 0.2},
+	}
+	now := at(12, 0)
+	if price, err := api.Price(context.Background(), now); err != nil || price != 0.31 {
+		t.Fatalf("Price() = %v, %v, want 0.31", price, err)
+	}
+	if price, _ := api.Price(context.Background(), now.Add(time.Minute)); price != 0.31 || requests != 1 {
+		t.Fatalf("cached Price() = %v after %d requests, want 0.31 after 1", price, requests)
+	}
+
+	up = false
+	if price, err := api.Price(context.Background(), now.Add(10*time.Minute)); err != nil || price != 0.2 {
+		t.Fatalf("Price() with the API down = %v, %v, want the fallback 0.2", price, err)
+	}
+
+	if price, err := parsePrice([]byte("-0.02"), ""); err != nil || price != -0.02 {
+		t.Fatalf("parsePrice() of a bare number = %v, %v", price, err)
+	}
+}
+
+func TestSchedulerDeclinesUneconomicalTasksAtPeak(t *testing.T) {
+	tariff, _ := ParseTariff("17:00-21:00=0.40", 0.10)
+	energy, err := ParseTaskEnergy("docker=0.5, llm:llama3:70b=0.2")
+	if err != nil {
+		t.Fatalf("ParseTaskEnergy() error = %v", err)
+	}
+	scheduler := NewScheduler(Config{
+		Prices:     tariff,
+		TokenPrice: 2,
+		PeakPrice:  0.30,
+		Watts:      300,
+		TaskEnergy: energy,
+	})
+	ctx := context.Background()
+
+	// 0.5 kWh costs 0.20 at peak and 0.05 off peak; the reward is worth 0.10
+	docker := &models.Task{Type: models.TaskTypeDocker, Reward: 0.05}
+	if estimate, declined, _ := scheduler.Declines(ctx, docker, at(18, 0)); !declined || estimate.Cost != 0.2 {
+		t.Fatalf("Declines() at peak = %+v, %v, want declined at a cost of 0.2", estimate, declined)
+	}
+	if _, declined, _ := scheduler.Declines(ctx, docker, at(10, 0)); declined {
+		t.Fatal("Declines() off peak declined a task")
+	}
+
+	llm := &models.Task{Type: models.TaskTypeLLM, Reward: 0.05, Config: json.RawMessage(`{"model":"llama3:70b"}`)}
+	if estimate, declined, _ := scheduler.Declines(ctx, llm, at(18, 0)); declined || estimate.KWh != 0.2 {
+		t.Fatalf("Declines() of the model = %+v, %v, want its own energy and taken", estimate, declined)
+	}
+
+	// Commands have no configured energy until one has run
+	command := &models.Task{Type: models.TaskTypeCommand, Reward: 0.01}
+	if _, priced, _ := scheduler.Estimate(ctx, command, at(18, 0)); priced {
+		t.Fatal("Estimate() priced a class nothing is known of")
+	}
+	scheduler.Observe(command, time.Hour)
+	estimate, declined, _ := scheduler.Declines(ctx, command, at(18, 0))
+	if estimate.KWh != 0.3 || !declined {
+		t.Fatalf("Declines() after an hour-long command = %+v, %v, want 0.3 kWh and declined", estimate, declined)
+	}
+}
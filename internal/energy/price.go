@@ -0,0 +1,176 @@
+package energy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriceSource tells what a kWh of electricity costs at a time
+type PriceSource interface {
+	Price(ctx context.Context, at time.Time) (float64, error)
+}
+
+// Window is a daily period, in local time, with its own price. A window whose
+// end is not after its start runs past midnight.
+type Window struct {
+	Start time.Duration
+	End   time.Duration
+	Price float64
+}
+
+func (w Window) contains(offset time.Duration) bool {
+	if w.Start < w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// Tariff prices electricity by time of day. Times outside every window cost
+// Default.
+type Tariff struct {
+	Windows []Window
+	Default float64
+}
+
+// ParseTariff parses comma separated HH:MM-HH:MM=price windows, such as
+// "07:00-17:00=0.25,17:00-21:00=0.40"
+func ParseTariff(windows string, defaultPrice float64) (Tariff, error) {
+	tariff := Tariff{Default: defaultPrice}
+	for _, item := range strings.Split(windows, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		span, price, ok := strings.Cut(item, "=")
+		if !ok {
+			return Tariff{}, fmt.Errorf("invalid tariff window %q, expected HH:MM-HH:MM=price", item)
+		}
+		from, to, ok := strings.Cut(span, "-")
+		if !ok {
+			return Tariff{}, fmt.Errorf("invalid tariff window %q, expected HH:MM-HH:MM=price", item)
+		}
+
+		var window Window
+		var err error
+		if window.Start, err = parseClock(from); err != nil {
+			return Tariff{}, fmt.Errorf("invalid tariff window %q: %w", item, err)
+		}
+		if window.End, err = parseClock(to); err != nil {
+			return Tariff{}, fmt.Errorf("invalid tariff window %q: %w", item, err)
+		}
+		if window.Price, err = strconv.ParseFloat(strings.TrimSpace(price), 64); err != nil {
+			return Tariff{}, fmt.Errorf("invalid price in tariff window %q", item)
+		}
+		tariff.Windows = append(tariff.Windows, window)
+	}
+	return tariff, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// Price returns the price of the first window holding the local time of at
+func (t Tariff) Price(_ context.Context, at time.Time) (float64, error) {
+	at = at.Local()
+	offset := time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute + time.Duration(at.Second())*time.Second
+	for _, window := range t.Windows {
+		if window.contains(offset) {
+			return window.Price, nil
+		}
+	}
+	return t.Default, nil
+}
+
+// maxPriceResponse bounds the body read from a price API
+const maxPriceResponse = 1 << 20
+
+// PriceAPI fetches the current price from a URL that returns a JSON number or
+// an object with the price in Field; spot prices may be negative. Prices are
+// kept for Refresh. When the API cannot be reached, Fallback is asked instead.
+type PriceAPI struct {
+	URL      string
+	Field    string
+	Refresh  time.Duration
+	Fallback PriceSource
+	Client   *http.Client
+
+	mu        sync.Mutex
+	price     float64
+	fetchedAt time.Time
+}
+
+func (a *PriceAPI) Price(ctx context.Context, at time.Time) (float64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.fetchedAt.IsZero() && at.Sub(a.fetchedAt) < a.Refresh {
+		return a.price, nil
+	}
+	price, err := a.fetch(ctx)
+	if err != nil {
+		if a.Fallback != nil {
+			return a.Fallback.Price(ctx, at)
+		}
+		return 0, err
+	}
+	a.price, a.fetchedAt = price, at
+	return price, nil
+}
+
+func (a *PriceAPI) fetch(ctx context.Context) (float64, error) {
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.URL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create price request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch electricity price: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("price API returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPriceResponse))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read electricity price: %w", err)
+	}
+	return parsePrice(body, a.Field)
+}
+
+func parsePrice(body []byte, field string) (float64, error) {
+	var price float64
+	if err := json.Unmarshal(body, &price); err == nil {
+		return price, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		return 0, fmt.Errorf("invalid price response: %w", err)
+	}
+	if field == "" {
+		field = "price"
+	}
+	raw, ok := object[field]
+	if !ok {
+		return 0, fmt.Errorf("price response has no %q field", field)
+	}
+	if err := json.Unmarshal(raw, &price); err != nil {
+		return 0, fmt.Errorf("price field %q is not a number", field)
+	}
+	return price, nil
+}
@@ -0,0 +1,204 @@
+// Package events keeps a local, append-only log of what the runner did for
+// each task: when it was received, the image pulled and verified, the
+// container started, the outcome and the submission of the result.
+//
+// Every task has its own file of JSON lines. Events are chained by hash so the
+// log attached to a result can be checked for gaps and edits when a task's
+// execution is disputed.
+package events
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const eventsDirName = "events"
+
+// ErrNoEvents is returned for tasks that have no log on this runner
+var ErrNoEvents = errors.New("no events recorded for task")
+
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func DefaultDir() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, eventsDirName), nil
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// path only accepts task IDs so a task cannot name a file outside the store
+func (s *Store) path(taskID string) (string, error) {
+	id, err := uuid.Parse(taskID)
+	if err != nil {
+		return "", fmt.Errorf("invalid task ID %q: %w", taskID, err)
+	}
+	return filepath.Join(s.dir, id.String()+".jsonl"), nil
+}
+
+// Record appends an event to the log of a task, chained to the last event
+// already in it
+func (s *Store) Record(taskID string, eventType models.ExecutionEventType, details map[string]string) (models.ExecutionEvent, error) {
+	path, err := s.path(taskID)
+	if err != nil {
+		return models.ExecutionEvent{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := load(path)
+	if err != nil && !errors.Is(err, ErrNoEvents) {
+		return models.ExecutionEvent{}, err
+	}
+	event := models.ExecutionEvent{
+		Seq:     len(existing) + 1,
+		Type:    eventType,
+		Time:    time.Now().UTC(),
+		Details: details,
+	}
+	if len(existing) > 0 {
+		event.PrevHash = existing[len(existing)-1].Hash
+	}
+	event.Hash = Hash(event)
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return models.ExecutionEvent{}, fmt.Errorf("failed to marshal task event: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return models.ExecutionEvent{}, fmt.Errorf("failed to create events directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return models.ExecutionEvent{}, fmt.Errorf("failed to open task events: %w", err)
+	}
+	defer file.Close()
+	// A line cut short by a crash is ended first so the event gets its own
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return models.ExecutionEvent{}, fmt.Errorf("failed to write task event: %w", err)
+	}
+	return event, file.Sync()
+}
+
+// Load returns the events of a task, oldest first
+func (s *Store) Load(taskID string) (models.ExecutionLog, error) {
+	path, err := s.path(taskID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return load(path)
+}
+
+// load reads a task's log. A line cut short by a crash while it was written is
+// skipped; Verify then reports the gap it leaves.
+func load(path string) (models.ExecutionLog, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoEvents
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open task events: %w", err)
+	}
+	defer file.Close()
+
+	var events models.ExecutionLog
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event models.ExecutionEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task events: %w", err)
+	}
+	return events, nil
+}
+
+// Hash is the hash of an event's content and of the event before it
+func Hash(event models.ExecutionEvent) string {
+	event.Hash = ""
+	// Marshalling a struct of strings, a time and a string map cannot fail
+	data, _ := json.Marshal(event)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks that events are numbered from 1 without gaps and that each is
+// chained to the one before it with an intact hash
+func Verify(events models.ExecutionLog) error {
+	prev := ""
+	for i, event := range events {
+		if event.Seq != i+1 {
+			return fmt.Errorf("event %d is missing", i+1)
+		}
+		if event.PrevHash != prev {
+			return fmt.Errorf("event %d is not chained to event %d", event.Seq, event.Seq-1)
+		}
+		if Hash(event) != event.Hash {
+			return fmt.Errorf("event %d was modified", event.Seq)
+		}
+		prev = event.Hash
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+type taskLog struct {
+	store  *Store
+	taskID string
+}
+
+// WithTask hands the log of a task to the executor that runs it
+func WithTask(ctx context.Context, store *Store, taskID string) context.Context {
+	if store == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, taskLog{store: store, taskID: taskID})
+}
+
+// Record adds an event to the log of the task in ctx, if it has one. Failing
+// to record is logged but does not fail the task.
+func Record(ctx context.Context, eventType models.ExecutionEventType, details map[string]string) {
+	l, ok := ctx.Value(contextKey{}).(taskLog)
+	if !ok {
+		return
+	}
+	if _, err := l.store.Record(l.taskID, eventType, details); err != nil {
+		log := gologger.WithComponent("events")
+		log.Warn().Err(err).Str("task_id", l.taskID).Str("event", string(eventType)).Msg("Failed to record task event")
+	}
+}
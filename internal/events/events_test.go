@@ -0,0 +1,73 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestStoreChainsEventsAndVerifyDetectsTampering(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	taskID := uuid.NewString()
+
+	if _, err := store.Load(taskID); !errors.Is(err, ErrNoEvents) {
+		t.Fatalf("Load() of an unknown task error = %v, want ErrNoEvents", err)
+	}
+
+	ctx := WithTask(context.Background(), store, taskID)
+	Record(ctx, models.ExecutionEventReceived, map[string]string{"nonce": "n"})
+	Record(ctx, models.ExecutionEventImagePulled, map[string]string{"image": "alpine"})
+	Record(context.Background(), models.ExecutionEventFailed, nil)
+	Record(ctx, models.ExecutionEventCompleted, map[string]string{"exit_code": "0"})
+
+	history, err := store.Load(taskID)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(history) != 3 || history[2].Type != models.ExecutionEventCompleted || history[2].PrevHash != history[1].Hash {
+		t.Fatalf("events = %+v, want 3 chained events", history)
+	}
+	if err := Verify(history); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	edited := append(models.ExecutionLog(nil), history...)
+	edited[1].Details = map[string]string{"image": "evil"}
+	if err := Verify(edited); err == nil || !strings.Contains(err.Error(), "event 2 was modified") {
+		t.Fatalf("Verify() of an edited event error = %v", err)
+	}
+	if err := Verify(models.ExecutionLog{history[0], history[2]}); err == nil {
+		t.Fatal("Verify() of a log with a removed event succeeded")
+	}
+
+	// A line cut short by a crash is skipped and the log continues after it
+	file, err := os.OpenFile(filepath.Join(dir, taskID+".jsonl"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"seq":4,"ty`)
+	file.Close()
+	if history, err := store.Load(taskID); err != nil || len(history) != 3 {
+		t.Fatalf("Load() with a torn line = %d events, %v; want 3", len(history), err)
+	}
+	Record(ctx, models.ExecutionEventResultSubmitted, nil)
+	history, err = store.Load(taskID)
+	if err != nil || len(history) != 4 || Verify(history) != nil {
+		t.Fatalf("Load() after the torn line = %+v, %v; want 4 chained events", history, err)
+	}
+}
+
+func TestStoreRejectsInvalidTaskIDs(t *testing.T) {
+	store := NewStore(t.TempDir())
+	if _, err := store.Record("../../etc/passwd", models.ExecutionEventReceived, nil); err == nil {
+		t.Fatal("Record() accepted a path as task ID")
+	}
+}
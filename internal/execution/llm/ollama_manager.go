@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,6 +14,8 @@ import (
 	"time"
 
 	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/utils"
 )
 
 type OllamaManager struct {
@@ -34,14 +37,20 @@ func NewOllamaManager(baseURL string, models []string) *OllamaManager {
 	homeDir, _ := os.UserHomeDir()
 	modelVolume := filepath.Join(homeDir, ".ollama")
 
+	// Publish the container on the port from the URL so instances can run side by side
+	port := "11434"
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Port() != "" {
+		port = parsed.Port()
+	}
+
 	return &OllamaManager{
 		baseURL:       baseURL,
 		models:        models,
 		executor:      NewOllamaExecutor(baseURL),
-		containerName: "ollama-runner",
+		containerName: utils.InstanceScoped("ollama-runner"),
 		dockerImage:   "ollama/ollama:latest",
 		modelVolume:   modelVolume,
-		port:          "11434",
+		port:          port,
 	}
 }
 
@@ -163,6 +172,7 @@ func (m *OllamaManager) StartOllama(ctx context.Context) error {
 		"-v", fmt.Sprintf("%s:/root/.ollama", m.modelVolume),
 		"--restart", "unless-stopped",
 	}
+	dockerArgs = append(dockerArgs, utils.LabelArgs(utils.ResourceLabels(""))...)
 
 	// Add GPU support if available (NVIDIA)
 	if m.isNvidiaRuntimeAvailable(ctx) {
@@ -222,6 +232,11 @@ func (m *OllamaManager) getContainerLogs(ctx context.Context) error {
 	return nil
 }
 
+// SetModels replaces the models EnsureModelsAvailable pulls
+func (m *OllamaManager) SetModels(models []string) {
+	m.models = models
+}
+
 func (m *OllamaManager) EnsureModelsAvailable(ctx context.Context) error {
 	log := gologger.WithComponent("ollama_manager")
 
@@ -544,3 +559,20 @@ func (m *OllamaManager) CleanupOllama(ctx context.Context) error {
 	log.Info().Msg("Ollama container cleanup completed")
 	return nil
 }
+
+// RemoveStaleContainer removes the Ollama container of this runner instance,
+// running or not, and reports whether there was one. Runners that serve no LLM
+// tasks use it to clean up after an earlier LLM run.
+func (m *OllamaManager) RemoveStaleContainer(ctx context.Context) (bool, error) {
+	cmd := exec.CommandContext(ctx, "docker", "ps", "-a", "--filter", fmt.Sprintf("name=^%s$", m.containerName), "--format", "{{.Names}}")
+	output, err := cmd.CombinedOutput()
+	if err != nil || strings.TrimSpace(string(output)) != m.containerName {
+		return false, nil
+	}
+
+	removeOutput, err := exec.CommandContext(ctx, "docker", "rm", "-f", m.containerName).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("failed to remove container: %w, output: %s", err, string(removeOutput))
+	}
+	return true, nil
+}
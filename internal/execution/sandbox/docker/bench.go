@@ -0,0 +1,408 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const sandboxBenchmarkFile = "sandbox-benchmark.json"
+
+// BenchConfig decides what the sandbox benchmark measures
+type BenchConfig struct {
+	// Image must have true, sleep and sh, like busybox based images do
+	Image string
+	// ContainerCounts are the numbers of containers run at once, one run each
+	ContainerCounts []int
+	// Iterations is how often each lifecycle is repeated in a run
+	Iterations int
+	// StatsSamples is how many stats samples are timed per container
+	StatsSamples int
+	// Workload is the number of iterations of the CPU-bound shell loop that is
+	// timed with and without stats polling
+	Workload int
+}
+
+func DefaultBenchConfig() BenchConfig {
+	return BenchConfig{
+		Image:           "alpine:latest",
+		ContainerCounts: []int{1, 4, 16},
+		Iterations:      3,
+		StatsSamples:    5,
+		Workload:        2000000,
+	}
+}
+
+// Bench measures what running a task in a container costs on top of the task
+// itself. Containers get the limits and seccomp profile tasks get.
+type Bench struct {
+	engine     Engine
+	config     BenchConfig
+	images     *ImageManager
+	containers *ContainerManager
+}
+
+func NewBench(engine Engine, config BenchConfig) (*Bench, error) {
+	defaults := DefaultBenchConfig()
+	if config.Image == "" {
+		config.Image = defaults.Image
+	}
+	if len(config.ContainerCounts) == 0 {
+		config.ContainerCounts = defaults.ContainerCounts
+	}
+	for _, count := range config.ContainerCounts {
+		if count <= 0 {
+			return nil, fmt.Errorf("invalid container count %d", count)
+		}
+	}
+	if config.Iterations <= 0 {
+		config.Iterations = defaults.Iterations
+	}
+	if config.StatsSamples <= 0 {
+		config.StatsSamples = defaults.StatsSamples
+	}
+	if config.Workload <= 0 {
+		config.Workload = defaults.Workload
+	}
+
+	limits := &ExecutorConfig{}
+	limits.applyDefaults()
+	containers, err := NewContainerManager(engine, limits.MemoryLimit, limits.CPULimit)
+	if err != nil {
+		return nil, err
+	}
+	return &Bench{
+		engine:     engine,
+		config:     config,
+		images:     NewImageManager(engine),
+		containers: containers,
+	}, nil
+}
+
+// Run pulls the benchmark image if needed and measures every container count
+func (b *Bench) Run(ctx context.Context) (*models.SandboxBenchmark, error) {
+	log := gologger.WithComponent("docker.bench")
+
+	if err := b.images.EnsureImageAvailable(ctx, b.config.Image, ""); err != nil {
+		return nil, fmt.Errorf("failed to prepare benchmark image: %w", err)
+	}
+
+	result := &models.SandboxBenchmark{
+		Engine:     b.engine.Command,
+		Image:      b.config.Image,
+		MeasuredAt: time.Now().UTC(),
+	}
+	for _, count := range b.config.ContainerCounts {
+		log.Info().Int("containers", count).Msg("Benchmarking sandbox")
+		run, err := b.RunCount(ctx, count)
+		if err != nil {
+			return nil, fmt.Errorf("benchmark with %d containers failed: %w", count, err)
+		}
+		result.Runs = append(result.Runs, *run)
+	}
+	return result, nil
+}
+
+// RunCount measures count containers at once
+func (b *Bench) RunCount(ctx context.Context, count int) (*models.SandboxBenchmarkRun, error) {
+	var confined, unconfined []lifecycle
+	for i := 0; i < b.config.Iterations; i++ {
+		runs, err := b.lifecycles(ctx, count, []string{"true"})
+		if err != nil {
+			return nil, err
+		}
+		confined = append(confined, runs...)
+
+		runs, err = b.lifecycles(ctx, count, []string{"true"}, withoutSeccomp())
+		if err != nil {
+			return nil, err
+		}
+		unconfined = append(unconfined, runs...)
+	}
+
+	sample, err := b.statsSample(ctx, count)
+	if err != nil {
+		return nil, fmt.Errorf("stats sampling failed: %w", err)
+	}
+	slowdown, err := b.statsSlowdown(ctx, count)
+	if err != nil {
+		return nil, fmt.Errorf("stats polling workload failed: %w", err)
+	}
+
+	return &models.SandboxBenchmarkRun{
+		Containers:        count,
+		CreateMs:          meanMs(confined, func(l lifecycle) time.Duration { return l.create }),
+		StartMs:           meanMs(confined, func(l lifecycle) time.Duration { return l.start }),
+		WaitMs:            meanMs(confined, func(l lifecycle) time.Duration { return l.wait }),
+		RemoveMs:          meanMs(confined, func(l lifecycle) time.Duration { return l.remove }),
+		SeccompOverheadMs: meanMs(confined, lifecycle.run) - meanMs(unconfined, lifecycle.run),
+		StatsSampleMs:     durationMs(sample),
+		StatsSlowdown:     slowdown,
+	}, nil
+}
+
+// lifecycle is how long one container took in each step
+type lifecycle struct {
+	create, start, wait, remove time.Duration
+}
+
+// run is the time from creating the container until its command exited
+func (l lifecycle) run() time.Duration {
+	return l.create + l.start + l.wait
+}
+
+func meanMs(lifecycles []lifecycle, step func(lifecycle) time.Duration) float64 {
+	if len(lifecycles) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, l := range lifecycles {
+		total += step(l)
+	}
+	return durationMs(total / time.Duration(len(lifecycles)))
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// lifecycle creates, starts, waits for and removes one container running command
+func (b *Bench) lifecycle(ctx context.Context, command []string, opts ...ContainerOption) (lifecycle, error) {
+	var l lifecycle
+
+	began := time.Now()
+	containerID, err := b.containers.CreateContainer(ctx, b.config.Image, "/", nil, command, opts...)
+	if err != nil {
+		return l, err
+	}
+	l.create = time.Since(began)
+
+	began = time.Now()
+	if err := b.containers.StartContainer(ctx, containerID); err != nil {
+		_ = b.containers.RemoveContainer(context.Background(), containerID)
+		return l, err
+	}
+	l.start = time.Since(began)
+
+	began = time.Now()
+	exitCode, err := b.containers.WaitForContainer(ctx, containerID)
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("benchmark container exited with code %d", exitCode)
+	}
+	if err != nil {
+		_ = b.containers.RemoveContainer(context.Background(), containerID)
+		return l, err
+	}
+	l.wait = time.Since(began)
+
+	began = time.Now()
+	if err := b.containers.RemoveContainer(ctx, containerID); err != nil {
+		return l, err
+	}
+	l.remove = time.Since(began)
+	return l, nil
+}
+
+// lifecycles runs count containers through their lifecycle at once
+func (b *Bench) lifecycles(ctx context.Context, count int, command []string, opts ...ContainerOption) ([]lifecycle, error) {
+	lifecycles := make([]lifecycle, count)
+	errs := make([]error, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lifecycles[i], errs[i] = b.lifecycle(ctx, command, opts...)
+		}(i)
+	}
+	wg.Wait()
+	return lifecycles, errors.Join(errs...)
+}
+
+// createContainers creates count containers running command. The returned
+// function removes them.
+func (b *Bench) createContainers(ctx context.Context, count int, command []string) ([]string, func(), error) {
+	var containerIDs []string
+	remove := func() {
+		for _, containerID := range containerIDs {
+			_ = b.containers.RemoveContainer(context.Background(), containerID)
+		}
+	}
+	for i := 0; i < count; i++ {
+		containerID, err := b.containers.CreateContainer(ctx, b.config.Image, "/", nil, command)
+		if err != nil {
+			remove()
+			return nil, nil, err
+		}
+		containerIDs = append(containerIDs, containerID)
+	}
+	return containerIDs, remove, nil
+}
+
+// statsSample is the mean time of one stats sample while count idle containers
+// are sampled at once, the way the resource monitors of count tasks sample them
+func (b *Bench) statsSample(ctx context.Context, count int) (time.Duration, error) {
+	containerIDs, remove, err := b.createContainers(ctx, count, []string{"sleep", "3600"})
+	if err != nil {
+		return 0, err
+	}
+	defer remove()
+
+	monitors := make([]*ResourceMonitor, len(containerIDs))
+	for i, containerID := range containerIDs {
+		if err := b.containers.StartContainer(ctx, containerID); err != nil {
+			return 0, err
+		}
+		if monitors[i], err = NewResourceMetrics(b.engine, containerID); err != nil {
+			return 0, err
+		}
+	}
+
+	startTime := time.Now()
+	var mu sync.Mutex
+	var total time.Duration
+	for i := 0; i < b.config.StatsSamples; i++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		var wg sync.WaitGroup
+		for _, monitor := range monitors {
+			wg.Add(1)
+			go func(monitor *ResourceMonitor) {
+				defer wg.Done()
+				began := time.Now()
+				monitor.collectMetrics(startTime)
+				mu.Lock()
+				total += time.Since(began)
+				mu.Unlock()
+			}(monitor)
+		}
+		wg.Wait()
+	}
+	return total / time.Duration(b.config.StatsSamples*len(monitors)), nil
+}
+
+// statsSlowdown is how much longer count containers took to run the CPU-bound
+// workload while their stats were polled, as a fraction of the time they took
+// without
+func (b *Bench) statsSlowdown(ctx context.Context, count int) (float64, error) {
+	plain, err := b.workload(ctx, count, false)
+	if err != nil {
+		return 0, err
+	}
+	polled, err := b.workload(ctx, count, true)
+	if err != nil {
+		return 0, err
+	}
+	if plain <= 0 {
+		return 0, nil
+	}
+	return polled.Seconds()/plain.Seconds() - 1, nil
+}
+
+// workload runs the shell loop in count containers at once and returns the time
+// until all of them finished
+func (b *Bench) workload(ctx context.Context, count int, polled bool) (time.Duration, error) {
+	loop := "i=0; while [ $i -lt " + strconv.Itoa(b.config.Workload) + " ]; do i=$((i+1)); done"
+	containerIDs, remove, err := b.createContainers(ctx, count, []string{"sh", "-c", loop})
+	if err != nil {
+		return 0, err
+	}
+	defer remove()
+
+	if polled {
+		for _, containerID := range containerIDs {
+			monitor, err := NewResourceMetrics(b.engine, containerID)
+			if err != nil {
+				return 0, err
+			}
+			if err := monitor.Start(ctx); err != nil {
+				return 0, err
+			}
+			defer monitor.Stop()
+		}
+	}
+
+	began := time.Now()
+	errs := make([]error, len(containerIDs))
+	var wg sync.WaitGroup
+	for i, containerID := range containerIDs {
+		wg.Add(1)
+		go func(i int, containerID string) {
+			defer wg.Done()
+			if errs[i] = b.containers.StartContainer(ctx, containerID); errs[i] != nil {
+				return
+			}
+			exitCode, err := b.containers.WaitForContainer(ctx, containerID)
+			if err == nil && exitCode != 0 {
+				err = fmt.Errorf("workload container exited with code %d", exitCode)
+			}
+			errs[i] = err
+		}(i, containerID)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return 0, err
+	}
+	return time.Since(began), nil
+}
+
+func sandboxBenchmarkPath() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, sandboxBenchmarkFile), nil
+}
+
+// SaveBenchmark keeps a benchmark result for the runner to publish when it
+// registers
+func SaveBenchmark(result *models.SandboxBenchmark) error {
+	path, err := sandboxBenchmarkPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sandbox benchmark: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save sandbox benchmark: %w", err)
+	}
+	return nil
+}
+
+// LoadBenchmark returns the saved benchmark result, or nil if the sandbox was
+// never benchmarked
+func LoadBenchmark() (*models.SandboxBenchmark, error) {
+	path, err := sandboxBenchmarkPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sandbox benchmark: %w", err)
+	}
+	var result models.SandboxBenchmark
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid sandbox benchmark: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,112 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// newTestBench skips unless Docker and the benchmark image are available
+func newTestBench(b *testing.B) *Bench {
+	b.Helper()
+	ctx := context.Background()
+	if _, err := DockerEngine.run(ctx, "version"); err != nil {
+		b.Skip("requires Docker")
+	}
+	bench, err := NewBench(DockerEngine, BenchConfig{})
+	if err != nil {
+		b.Fatalf("NewBench() error = %v", err)
+	}
+	if _, err := DockerEngine.ImageID(ctx, bench.config.Image); err != nil {
+		if err := bench.images.PullImage(ctx, bench.config.Image); err != nil {
+			b.Skipf("benchmark image unavailable: %v", err)
+		}
+	}
+	return bench
+}
+
+func benchmarkLifecycle(b *testing.B, opts ...ContainerOption) {
+	bench := newTestBench(b)
+	for _, count := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("containers=%d", count), func(b *testing.B) {
+			var create, start, wait, remove time.Duration
+			for i := 0; i < b.N; i++ {
+				lifecycles, err := bench.lifecycles(context.Background(), count, []string{"true"}, opts...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, l := range lifecycles {
+					create, start, wait, remove = create+l.create, start+l.start, wait+l.wait, remove+l.remove
+				}
+			}
+			containers := float64(b.N * count)
+			b.ReportMetric(durationMs(create)/containers, "create-ms/container")
+			b.ReportMetric(durationMs(start)/containers, "start-ms/container")
+			b.ReportMetric(durationMs(wait)/containers, "wait-ms/container")
+			b.ReportMetric(durationMs(remove)/containers, "remove-ms/container")
+		})
+	}
+}
+
+func BenchmarkContainerLifecycle(b *testing.B) {
+	benchmarkLifecycle(b)
+}
+
+func BenchmarkContainerLifecycleUnconfined(b *testing.B) {
+	benchmarkLifecycle(b, withoutSeccomp())
+}
+
+func BenchmarkStatsSample(b *testing.B) {
+	bench := newTestBench(b)
+	bench.config.StatsSamples = 1
+	for _, count := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("containers=%d", count), func(b *testing.B) {
+			var total time.Duration
+			for i := 0; i < b.N; i++ {
+				sample, err := bench.statsSample(context.Background(), count)
+				if err != nil {
+					b.Fatal(err)
+				}
+				total += sample
+			}
+			b.ReportMetric(durationMs(total)/float64(b.N), "sample-ms/container")
+		})
+	}
+}
+
+func TestBenchmarkSaveLoad(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if got, err := LoadBenchmark(); err != nil || got != nil {
+		t.Fatalf("LoadBenchmark() before saving = %v, %v, want nil", got, err)
+	}
+
+	result := &models.SandboxBenchmark{
+		Engine:     "docker",
+		Image:      "alpine:latest",
+		MeasuredAt: time.Now().UTC().Truncate(time.Second),
+		Runs: []models.SandboxBenchmarkRun{
+			{Containers: 1, CreateMs: 60, StartMs: 250, WaitMs: 20, RemoveMs: 40, SeccompOverheadMs: 3, StatsSampleMs: 2100, StatsSlowdown: 0.05},
+		},
+	}
+	if err := SaveBenchmark(result); err != nil {
+		t.Fatalf("SaveBenchmark() error = %v", err)
+	}
+	got, err := LoadBenchmark()
+	if err != nil {
+		t.Fatalf("LoadBenchmark() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, result) {
+		t.Fatalf("LoadBenchmark() = %+v, want %+v", got, result)
+	}
+}
+
+func TestNewBenchRejectsInvalidCounts(t *testing.T) {
+	if _, err := NewBench(DockerEngine, BenchConfig{ContainerCounts: []int{4, 0}}); err == nil {
+		t.Fatal("expected an error for a container count of 0")
+	}
+}
@@ -0,0 +1,436 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	// CheckpointModeSnapshot saves the container filesystem with docker commit
+	CheckpointModeSnapshot = "snapshot"
+	// CheckpointModeCRIU also saves the container's processes with docker checkpoint
+	CheckpointModeCRIU = "criu"
+
+	checkpointsDirName        = "checkpoints"
+	defaultCheckpointPath     = "/checkpoint"
+	defaultCheckpointInterval = 5 * time.Minute
+	defaultCheckpointAttempts = 3
+	checkpointImageRepo       = "parity-checkpoint"
+)
+
+// RestartMetadata is stored on the runner for every checkpointed task so that an
+// attempt started after a restart can pick up where the previous one stopped
+type RestartMetadata struct {
+	TaskID           string    `json:"task_id"`
+	BaseImage        string    `json:"base_image"`
+	Volume           string    `json:"volume"`
+	SnapshotImage    string    `json:"snapshot_image,omitempty"`
+	Attempts         int       `json:"attempts"`
+	LastCheckpointAt time.Time `json:"last_checkpoint_at,omitempty"`
+	// CRIUCheckpoint names the latest process checkpoint in the task's CRIU directory
+	CRIUCheckpoint string   `json:"criu_checkpoint,omitempty"`
+	CheckpointCIDs []string `json:"checkpoint_cids,omitempty"`
+}
+
+// ParseCheckpointMode validates a runner checkpoint mode; empty means snapshot
+func ParseCheckpointMode(mode string) (string, error) {
+	switch mode {
+	case "", CheckpointModeSnapshot:
+		return CheckpointModeSnapshot, nil
+	case CheckpointModeCRIU:
+		return CheckpointModeCRIU, nil
+	default:
+		return "", fmt.Errorf("unknown checkpoint mode %q, expected %s or %s", mode, CheckpointModeSnapshot, CheckpointModeCRIU)
+	}
+}
+
+// CheckpointStore keeps restart metadata under the runner data directory. With
+// criu set, sessions also checkpoint the container's processes, and with upload
+// set those checkpoints are added to content.
+type CheckpointStore struct {
+	engine  Engine
+	dir     string
+	criu    bool
+	upload  bool
+	content checkpointContent
+}
+
+func NewCheckpointStore(dir string) *CheckpointStore {
+	if dir == "" {
+		dataDir, err := utils.DataDir()
+		if err == nil {
+			dir = filepath.Join(dataDir, checkpointsDirName)
+		}
+	}
+	return &CheckpointStore{engine: DockerEngine, dir: dir}
+}
+
+func (s *CheckpointStore) path(taskID string) string {
+	return filepath.Join(s.dir, taskID+".json")
+}
+
+// criuDir holds the process checkpoints of a task, one directory per checkpoint
+func (s *CheckpointStore) criuDir(taskID string) string {
+	return filepath.Join(s.dir, taskID+"-criu")
+}
+
+func (s *CheckpointStore) Load(taskID string) (*RestartMetadata, error) {
+	data, err := os.ReadFile(s.path(taskID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read restart metadata: %w", err)
+	}
+
+	var meta RestartMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse restart metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+func (s *CheckpointStore) Save(meta *RestartMetadata) error {
+	if s.dir == "" {
+		return fmt.Errorf("no checkpoint directory available")
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal restart metadata: %w", err)
+	}
+
+	tmp := s.path(meta.TaskID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write restart metadata: %w", err)
+	}
+	return os.Rename(tmp, s.path(meta.TaskID))
+}
+
+func (s *CheckpointStore) Delete(taskID string) error {
+	if err := os.Remove(s.path(taskID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove restart metadata: %w", err)
+	}
+	return nil
+}
+
+// CheckpointSession tracks one attempt of a checkpointed task
+type CheckpointSession struct {
+	store      *CheckpointStore
+	meta       *RestartMetadata
+	startImage string
+	path       string
+	interval   time.Duration
+	resumed    bool
+	mu         sync.Mutex
+	stopCh     chan struct{}
+	doneCh     chan struct{}
+
+	// saveMu serializes checkpoints taken on the interval and before a timeout
+	saveMu sync.Mutex
+	saves  int
+	// restoreFrom is the CRIU checkpoint the container is started from, and
+	// volumeSeed a directory with the volume contents of a downloaded checkpoint
+	restoreFrom string
+	volumeSeed  string
+}
+
+// BeginCheckpoint prepares the volume and restart metadata for a task attempt. A
+// previous attempt's snapshot, if any, becomes the image for this attempt.
+func (s *CheckpointStore) BeginCheckpoint(ctx context.Context, taskID, image string, config *models.CheckpointConfig) (*CheckpointSession, error) {
+	interval := defaultCheckpointInterval
+	if config.Interval != "" {
+		parsed, err := time.ParseDuration(config.Interval)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid checkpoint interval %q", config.Interval)
+		}
+		interval = parsed
+	}
+
+	path := config.Path
+	if path == "" {
+		path = defaultCheckpointPath
+	}
+
+	maxAttempts := config.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultCheckpointAttempts
+	}
+
+	meta, err := s.Load(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	resumed := meta != nil && meta.BaseImage == image
+	if !resumed {
+		meta = &RestartMetadata{
+			TaskID:    taskID,
+			BaseImage: image,
+			Volume:    utils.InstanceScoped("parity-ckpt-" + taskID),
+		}
+	}
+
+	if meta.Attempts >= maxAttempts {
+		return nil, fmt.Errorf("task %s exhausted %d checkpointed attempts", taskID, maxAttempts)
+	}
+	meta.Attempts++
+
+	volumeArgs := append([]string{"volume", "create"}, utils.LabelArgs(utils.ResourceLabels(taskID))...)
+	if _, err := s.engine.run(ctx, append(volumeArgs, meta.Volume)...); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint volume: %w", err)
+	}
+
+	var restoreFrom, volumeSeed string
+	if s.criu {
+		switch {
+		case resumed && meta.CRIUCheckpoint != "":
+			if _, err := os.Stat(filepath.Join(s.criuDir(taskID), meta.CRIUCheckpoint)); err == nil {
+				restoreFrom = meta.CRIUCheckpoint
+			}
+		case !resumed && config.ResumeCID != "":
+			restoreFrom, volumeSeed, err = s.fetchCheckpoint(ctx, taskID, config.ResumeCID)
+			if err != nil {
+				log := gologger.WithComponent("docker.checkpoint")
+				log.Warn().Err(err).Str("task_id", taskID).Str("cid", config.ResumeCID).
+					Msg("Failed to download checkpoint, starting the task from the beginning")
+			} else {
+				resumed = true
+				meta.CRIUCheckpoint = restoreFrom
+			}
+		}
+	}
+
+	if err := s.Save(meta); err != nil {
+		return nil, err
+	}
+
+	startImage := meta.BaseImage
+	if resumed && meta.SnapshotImage != "" {
+		startImage = meta.SnapshotImage
+	}
+
+	return &CheckpointSession{
+		store:       s,
+		meta:        meta,
+		startImage:  startImage,
+		path:        path,
+		interval:    interval,
+		resumed:     resumed,
+		restoreFrom: restoreFrom,
+		volumeSeed:  volumeSeed,
+	}, nil
+}
+
+// Image is the image this attempt runs: the last snapshot when resuming, otherwise the task image
+func (c *CheckpointSession) Image() string {
+	return c.startImage
+}
+
+func (c *CheckpointSession) Resumed() bool {
+	return c.resumed
+}
+
+// Mode is the checkpoint mode of the attempt
+func (c *CheckpointSession) Mode() string {
+	if c.store.criu {
+		return CheckpointModeCRIU
+	}
+	return CheckpointModeSnapshot
+}
+
+// Summary describes the attempt's checkpoints for the task result
+func (c *CheckpointSession) Summary() *models.CheckpointSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &models.CheckpointSummary{
+		Mode:    c.Mode(),
+		Attempt: c.meta.Attempts,
+		Resumed: c.resumed,
+		CIDs:    append([]string(nil), c.meta.CheckpointCIDs...),
+	}
+}
+
+func (c *CheckpointSession) Env() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return []string{
+		"PARITY_CHECKPOINT_DIR=" + c.path,
+		"PARITY_ATTEMPT=" + strconv.Itoa(c.meta.Attempts),
+		"PARITY_RESUMED=" + strconv.FormatBool(c.resumed),
+	}
+}
+
+func (c *CheckpointSession) ContainerOptions() []ContainerOption {
+	return []ContainerOption{WithVolume(c.meta.Volume, c.path)}
+}
+
+// StartSnapshots commits the running container every interval so the next attempt
+// can reuse its filesystem layers
+func (c *CheckpointSession) StartSnapshots(containerID string) {
+	c.stopCh = make(chan struct{})
+	c.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(c.doneCh)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.snapshot(containerID); err != nil {
+					log := gologger.WithComponent("docker.checkpoint")
+					log.Warn().Err(err).Str("task_id", c.meta.TaskID).Msg("Failed to snapshot container")
+				}
+			case <-c.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// SaveOnTimeout returns the context to wait for the container with. It ends with
+// ctx, but when ctx runs out of time a final checkpoint is saved first so the next
+// attempt does not lose the progress made since the last interval.
+func (c *CheckpointSession) SaveOnTimeout(ctx context.Context, containerID string) (context.Context, context.CancelFunc) {
+	waitCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				if err := c.snapshot(containerID); err != nil {
+					log := gologger.WithComponent("docker.checkpoint")
+					log.Warn().Err(err).Str("task_id", c.meta.TaskID).Msg("Failed to checkpoint timed out container")
+				}
+			}
+			cancel()
+		case <-waitCtx.Done():
+		}
+	}()
+	return waitCtx, cancel
+}
+
+func (c *CheckpointSession) snapshot(containerID string) error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	c.mu.Lock()
+	c.saves++
+	tag := fmt.Sprintf("%s:%s-%d", checkpointImageRepo, c.meta.TaskID, c.meta.Attempts)
+	name := fmt.Sprintf("attempt%d-%d", c.meta.Attempts, c.saves)
+	c.mu.Unlock()
+
+	// The processes are checkpointed before the commit so the filesystem the
+	// restore starts from is never older than their memory
+	var processCheckpoint string
+	if c.store.criu {
+		if err := c.checkpointProcesses(ctx, containerID, name); err != nil {
+			log := gologger.WithComponent("docker.checkpoint")
+			log.Warn().Err(err).Str("task_id", c.meta.TaskID).Msg("Failed to checkpoint container processes, saving the filesystem only")
+		} else {
+			processCheckpoint = name
+		}
+	}
+
+	// The labels let garbage collection find snapshots no task resumes from
+	commitArgs := []string{"commit"}
+	labels := utils.LabelArgs(utils.ResourceLabels(c.meta.TaskID))
+	for i := 1; i < len(labels); i += 2 {
+		commitArgs = append(commitArgs, "--change", "LABEL "+labels[i])
+	}
+	if _, err := c.store.engine.run(ctx, append(commitArgs, containerID, tag)...); err != nil {
+		if processCheckpoint != "" {
+			_ = os.RemoveAll(filepath.Join(c.store.criuDir(c.meta.TaskID), processCheckpoint))
+		}
+		return fmt.Errorf("container commit failed: %w", err)
+	}
+
+	c.mu.Lock()
+	previous := c.meta.SnapshotImage
+	previousProcess := c.meta.CRIUCheckpoint
+	c.meta.SnapshotImage = tag
+	if processCheckpoint != "" {
+		c.meta.CRIUCheckpoint = processCheckpoint
+	}
+	c.meta.LastCheckpointAt = time.Now()
+	meta := *c.meta
+	c.mu.Unlock()
+
+	if err := c.store.Save(&meta); err != nil {
+		return err
+	}
+
+	// Keep only the latest snapshot; the image this attempt started from is still in use
+	if previous != "" && previous != tag && previous != c.startImage {
+		_, _ = c.store.engine.run(ctx, "image", "rm", previous)
+	}
+	if processCheckpoint != "" && previousProcess != "" && previousProcess != processCheckpoint {
+		_ = os.RemoveAll(filepath.Join(c.store.criuDir(meta.TaskID), previousProcess))
+	}
+
+	log := gologger.WithComponent("docker.checkpoint")
+	log.Debug().Str("task_id", meta.TaskID).Str("image", tag).Str("criu_checkpoint", processCheckpoint).Msg("Container checkpoint saved")
+
+	if processCheckpoint != "" && c.store.upload {
+		if err := c.uploadCheckpoint(containerID, processCheckpoint); err != nil {
+			log.Warn().Err(err).Str("task_id", meta.TaskID).Msg("Failed to upload checkpoint")
+		}
+	}
+	return nil
+}
+
+func (c *CheckpointSession) StopSnapshots() {
+	if c.stopCh == nil {
+		return
+	}
+	close(c.stopCh)
+	<-c.doneCh
+	c.stopCh = nil
+}
+
+// Complete removes the volume, snapshots and restart metadata once the task finished
+func (c *CheckpointSession) Complete(ctx context.Context) {
+	log := gologger.WithComponent("docker.checkpoint")
+	c.StopSnapshots()
+
+	c.mu.Lock()
+	meta := *c.meta
+	c.mu.Unlock()
+
+	for _, image := range []string{meta.SnapshotImage, c.startImage} {
+		if image == "" || image == meta.BaseImage {
+			continue
+		}
+		if _, err := c.store.engine.run(ctx, "image", "rm", image); err != nil {
+			log.Debug().Err(err).Str("image", image).Msg("Failed to remove checkpoint image")
+		}
+	}
+	if _, err := c.store.engine.run(ctx, "volume", "rm", "--force", meta.Volume); err != nil {
+		log.Debug().Err(err).Str("volume", meta.Volume).Msg("Failed to remove checkpoint volume")
+	}
+	if err := os.RemoveAll(c.store.criuDir(meta.TaskID)); err != nil {
+		log.Debug().Err(err).Str("task_id", meta.TaskID).Msg("Failed to remove CRIU checkpoints")
+	}
+	if err := c.store.Delete(meta.TaskID); err != nil {
+		log.Debug().Err(err).Str("task_id", meta.TaskID).Msg("Failed to remove restart metadata")
+	}
+}
@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckpointStoreRoundTrip(t *testing.T) {
+	store := NewCheckpointStore(t.TempDir())
+
+	meta, err := store.Load("task-1")
+	if err != nil || meta != nil {
+		t.Fatalf("Load() on empty store = %v, %v; want nil, nil", meta, err)
+	}
+
+	saved := &RestartMetadata{
+		TaskID:           "task-1",
+		BaseImage:        "alpine:3.19",
+		Volume:           "parity-ckpt-task-1",
+		SnapshotImage:    "parity-checkpoint:task-1-1",
+		Attempts:         1,
+		LastCheckpointAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := store.Load("task-1")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.SnapshotImage != saved.SnapshotImage || loaded.Attempts != 1 || !loaded.LastCheckpointAt.Equal(saved.LastCheckpointAt) {
+		t.Fatalf("Load() = %+v, want %+v", loaded, saved)
+	}
+
+	if err := store.Delete("task-1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if meta, _ := store.Load("task-1"); meta != nil {
+		t.Fatal("expected restart metadata to be removed")
+	}
+}
+
+func TestCheckpointSessionEnvDescribesAttempt(t *testing.T) {
+	session := &CheckpointSession{
+		meta:       &RestartMetadata{TaskID: "task-1", Attempts: 2, Volume: "vol"},
+		startImage: "parity-checkpoint:task-1-1",
+		path:       "/work/ckpt",
+		resumed:    true,
+	}
+
+	env := session.Env()
+	want := []string{"PARITY_CHECKPOINT_DIR=/work/ckpt", "PARITY_ATTEMPT=2", "PARITY_RESUMED=true"}
+	for i := range want {
+		if env[i] != want[i] {
+			t.Fatalf("Env()[%d] = %q, want %q", i, env[i], want[i])
+		}
+	}
+
+	var options containerOptions
+	for _, opt := range session.ContainerOptions() {
+		opt(&options)
+	}
+	if args := options.args(); len(args) != 2 || args[1] != "vol:/work/ckpt" {
+		t.Fatalf("container args = %v, want checkpoint volume mount", args)
+	}
+}
+
+func TestParseCheckpointMode(t *testing.T) {
+	for input, want := range map[string]string{"": CheckpointModeSnapshot, "snapshot": CheckpointModeSnapshot, "criu": CheckpointModeCRIU} {
+		if got, err := ParseCheckpointMode(input); err != nil || got != want {
+			t.Fatalf("ParseCheckpointMode(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseCheckpointMode("docker"); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+}
+
+func TestCheckpointSessionSummary(t *testing.T) {
+	session := &CheckpointSession{
+		store:   &CheckpointStore{criu: true},
+		meta:    &RestartMetadata{TaskID: "task-1", Attempts: 2, CheckpointCIDs: []string{"bafya", "bafyb"}},
+		resumed: true,
+	}
+
+	summary := session.Summary()
+	if summary.Mode != CheckpointModeCRIU || summary.Attempt != 2 || !summary.Resumed || summary.LatestCID() != "bafyb" {
+		t.Fatalf("Summary() = %+v", summary)
+	}
+}
@@ -5,15 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/theblitlabs/gologger"
 
-	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker/executils"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
 )
 
 type SeccompProfile struct {
@@ -26,6 +29,7 @@ type SeccompProfile struct {
 }
 
 type ContainerManager struct {
+	engine         Engine
 	memoryLimit    string
 	cpuLimit       string
 	seccompProfile string
@@ -83,7 +87,9 @@ func writeSeccompProfileToTempFile() (string, error) {
 	log := gologger.WithComponent("docker.container")
 
 	tmpDir := os.TempDir()
-	seccompPath := filepath.Join(tmpDir, "seccomp-profile-"+strconv.FormatInt(time.Now().UnixNano(), 10)+".json")
+	// The PID in the name lets garbage collection tell the profiles of runners
+	// that are gone from the ones still in use
+	seccompPath := filepath.Join(tmpDir, fmt.Sprintf("%s%d-%d.json", seccompProfilePrefix, os.Getpid(), time.Now().UnixNano()))
 
 	profile, err := createSeccompProfile()
 	if err != nil {
@@ -111,7 +117,7 @@ func writeSeccompProfileToTempFile() (string, error) {
 	return seccompPath, nil
 }
 
-func NewContainerManager(memoryLimit, cpuLimit string) (*ContainerManager, error) {
+func NewContainerManager(engine Engine, memoryLimit, cpuLimit string) (*ContainerManager, error) {
 	log := gologger.WithComponent("docker.container")
 
 	seccompPath, err := writeSeccompProfileToTempFile()
@@ -128,6 +134,7 @@ func NewContainerManager(memoryLimit, cpuLimit string) (*ContainerManager, error
 	log.Debug().Str("seccomp_profile", seccompPath).Msg("Container manager initialized with seccomp profile")
 
 	return &ContainerManager{
+		engine:         engine,
 		memoryLimit:    memoryLimit,
 		cpuLimit:       cpuLimit,
 		seccompProfile: seccompPath,
@@ -152,7 +159,7 @@ type containerStateSnapshot struct {
 }
 
 func (cm *ContainerManager) inspectContainerState(ctx context.Context, containerID string) (*containerStateSnapshot, error) {
-	output, err := executils.ExecCommand(ctx, "docker", "inspect", "--format={{.State.Status}}::{{.State.Running}}::{{.State.ExitCode}}", containerID)
+	output, err := cm.engine.run(ctx, "inspect", "--format={{.State.Status}}::{{.State.Running}}::{{.State.ExitCode}}", containerID)
 	if err != nil {
 		return nil, err
 	}
@@ -179,7 +186,194 @@ func (cm *ContainerManager) inspectContainerState(ctx context.Context, container
 	}, nil
 }
 
-func (cm *ContainerManager) CreateContainer(ctx context.Context, image string, workdir string, envVars []string, command []string) (string, error) {
+// ContainerOption adjusts the docker create arguments for a single container
+type ContainerOption func(*containerOptions)
+
+type containerOptions struct {
+	volumes []string
+	binds   []bindMount
+	network string
+	dns     *models.DNSConfig
+	gpus    string
+	devices []string
+	envFile string
+	ports   []int
+	labels  map[string]string
+	// hostname replaces the container ID as the host name
+	hostname string
+	// unconfined drops the seccomp profile, for measuring what it costs
+	unconfined bool
+}
+
+// WithVolume mounts a named docker volume at target inside the container
+func WithVolume(name, target string) ContainerOption {
+	return func(o *containerOptions) {
+		o.volumes = append(o.volumes, name+":"+target)
+	}
+}
+
+// maxCopyInBytes bounds the mounts copied into a container on a remote host,
+// both their files and the compressed archive
+const maxCopyInBytes = 2 << 30
+
+// bindMount is a host path mounted into a container. Containers on a remote
+// host get a copy of it instead.
+type bindMount struct {
+	source, target string
+	readOnly       bool
+}
+
+func (b bindMount) volume() string {
+	if b.readOnly {
+		return b.source + ":" + b.target + ":ro"
+	}
+	return b.source + ":" + b.target
+}
+
+// WithReadOnlyMount bind mounts a host path read-only at target inside the container
+func WithReadOnlyMount(source, target string) ContainerOption {
+	return func(o *containerOptions) {
+		o.binds = append(o.binds, bindMount{source, target, true})
+	}
+}
+
+// WithMount bind mounts a host path read-write at target inside the container
+func WithMount(source, target string) ContainerOption {
+	return func(o *containerOptions) {
+		o.binds = append(o.binds, bindMount{source, target, false})
+	}
+}
+
+// WithNetwork attaches the container to a docker network instead of the default bridge
+func WithNetwork(name string) ContainerOption {
+	return func(o *containerOptions) {
+		o.network = name
+	}
+}
+
+// WithDNS replaces the container's resolver configuration
+func WithDNS(config *models.DNSConfig) ContainerOption {
+	return func(o *containerOptions) {
+		o.dns = config
+	}
+}
+
+// WithGPUs passes host GPUs through to the container; devices is a docker --gpus
+// value such as "all" or a quoted device list
+func WithGPUs(devices string) ContainerOption {
+	return func(o *containerOptions) {
+		o.gpus = devices
+	}
+}
+
+// WithDevices adds host devices to the container, including CDI devices such as
+// nvidia.com/gpu=0
+func WithDevices(devices ...string) ContainerOption {
+	return func(o *containerOptions) {
+		o.devices = append(o.devices, devices...)
+	}
+}
+
+// WithEnvFile reads environment variables from a file, for values that must not
+// show up in the docker command line
+func WithEnvFile(path string) ContainerOption {
+	return func(o *containerOptions) {
+		o.envFile = path
+	}
+}
+
+// WithPublishedPort makes a container port reachable on the same host port
+func WithPublishedPort(port int) ContainerOption {
+	return func(o *containerOptions) {
+		o.ports = append(o.ports, port)
+	}
+}
+
+// WithTask labels the container with the task it runs, so containers a crashed
+// runner left behind can be found and removed
+func WithTask(taskID string) ContainerOption {
+	return func(o *containerOptions) {
+		o.labels = utils.ResourceLabels(taskID)
+	}
+}
+
+func withHostname(name string) ContainerOption {
+	return func(o *containerOptions) {
+		o.hostname = name
+	}
+}
+
+// withoutSeccomp runs the container without the task seccomp profile. Only the
+// sandbox benchmark uses it, tasks always get the profile.
+func withoutSeccomp() ContainerOption {
+	return func(o *containerOptions) {
+		o.unconfined = true
+	}
+}
+
+// disabledDNSServer is a loopback address nothing listens on inside the container
+const disabledDNSServer = "127.0.0.1"
+
+func (o *containerOptions) args() []string {
+	var args []string
+	if o.network != "" {
+		args = append(args, "--network", o.network)
+	}
+	for _, volume := range o.volumes {
+		args = append(args, "--volume", volume)
+	}
+	for _, bind := range o.binds {
+		args = append(args, "--volume", bind.volume())
+	}
+	if o.dns != nil {
+		args = append(args, dnsArgs(o.dns)...)
+	}
+	if o.gpus != "" {
+		args = append(args, "--gpus", o.gpus)
+	}
+	for _, device := range o.devices {
+		args = append(args, "--device", device)
+	}
+	if o.envFile != "" {
+		args = append(args, "--env-file", o.envFile)
+	}
+	for _, port := range o.ports {
+		args = append(args, "--publish", fmt.Sprintf("%d:%d", port, port))
+	}
+	if o.hostname != "" {
+		args = append(args, "--hostname", o.hostname)
+	}
+	return append(args, utils.LabelArgs(o.labels)...)
+}
+
+func dnsArgs(config *models.DNSConfig) []string {
+	var args []string
+	servers := config.Servers
+	if config.None {
+		servers = []string{disabledDNSServer}
+	}
+	for _, server := range servers {
+		args = append(args, "--dns", server)
+	}
+	for _, domain := range config.Search {
+		args = append(args, "--dns-search", domain)
+	}
+	for _, option := range config.Options {
+		args = append(args, "--dns-option", option)
+	}
+
+	hosts := make([]string, 0, len(config.Hosts))
+	for host := range config.Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		args = append(args, "--add-host", host+":"+config.Hosts[host])
+	}
+	return args
+}
+
+func (cm *ContainerManager) CreateContainer(ctx context.Context, image string, workdir string, envVars []string, command []string, opts ...ContainerOption) (string, error) {
 	log := gologger.WithComponent("docker.container")
 
 	createArgs := []string{
@@ -199,31 +393,76 @@ func (cm *ContainerManager) CreateContainer(ctx context.Context, image string, w
 		return "", fmt.Errorf("seccomp profile file not found: %w", err)
 	}
 
-	createArgs = append(createArgs, "--security-opt", "seccomp="+cm.seccompProfile)
-	log.Debug().Str("seccomp_profile", cm.seccompProfile).Msg("Using seccomp profile")
+	// Containers not created for a task still carry the runner's labels
+	options := containerOptions{labels: utils.ResourceLabels("")}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.unconfined {
+		createArgs = append(createArgs, "--security-opt", "seccomp=unconfined")
+	} else {
+		createArgs = append(createArgs, "--security-opt", "seccomp="+cm.seccompProfile)
+		log.Debug().Str("seccomp_profile", cm.seccompProfile).Msg("Using seccomp profile")
+	}
 
 	for _, env := range envVars {
 		createArgs = append(createArgs, "-e", env)
 	}
+	if options.dns != nil {
+		if err := options.dns.Validate(); err != nil {
+			return "", fmt.Errorf("invalid dns configuration: %w", err)
+		}
+	}
+	// A remote daemon cannot see host paths, so they are copied into the
+	// container before it starts
+	var copies []bindMount
+	if cm.engine.Remote() {
+		copies, options.binds = options.binds, nil
+	}
+	createArgs = append(createArgs, options.args()...)
 
 	createArgs = append(createArgs, image)
 	createArgs = append(createArgs, command...)
 
-	output, err := executils.ExecCommand(ctx, "docker", createArgs...)
+	output, err := cm.engine.run(ctx, createArgs...)
 	if err != nil {
 		log.Error().Err(err).Str("args", strings.Join(createArgs, " ")).Msg("Container creation failed")
 		return "", fmt.Errorf("container creation failed: %w", err)
 	}
 
 	containerID := strings.TrimSpace(string(output))
+	if len(copies) > 0 {
+		if err := cm.copyIn(ctx, containerID, copies); err != nil {
+			_, _ = cm.engine.run(context.Background(), "rm", "-f", containerID)
+			return "", err
+		}
+	}
 	log.Debug().Str("container", containerID).Msg("Container created")
 	return containerID, nil
 }
 
+// copyIn puts the host paths of binds into a created container. They go in as
+// one tar stream so that the parent directories of their targets are created.
+func (cm *ContainerManager) copyIn(ctx context.Context, containerID string, binds []bindMount) error {
+	trees := make([]archiveTree, len(binds))
+	for i, bind := range binds {
+		trees[i] = archiveTree{strings.TrimPrefix(bind.target, "/"), bind.source}
+	}
+	archive, err := writeArchive(trees, maxCopyInBytes, maxCopyInBytes)
+	if err != nil {
+		return fmt.Errorf("failed to archive container mounts: %w", err)
+	}
+	if _, err := cm.engine.runWithInput(ctx, bytes.NewReader(archive), "cp", "-", containerID+":/"); err != nil {
+		return fmt.Errorf("failed to copy mounts into container: %w", err)
+	}
+	return nil
+}
+
 func (cm *ContainerManager) StartContainer(ctx context.Context, containerID string) error {
 	log := gologger.WithComponent("docker.container")
 
-	if _, err := executils.ExecCommand(ctx, "docker", "start", containerID); err != nil {
+	if _, err := cm.engine.run(ctx, "start", containerID); err != nil {
 		log.Error().Err(err).Str("container", containerID).Msg("Container start failed")
 		return fmt.Errorf("container start failed: %w", err)
 	}
@@ -231,6 +470,15 @@ func (cm *ContainerManager) StartContainer(ctx context.Context, containerID stri
 	return nil
 }
 
+// StartContainerFromCheckpoint starts a created container by restoring the
+// processes of a CRIU checkpoint kept in checkpointDir
+func (cm *ContainerManager) StartContainerFromCheckpoint(ctx context.Context, containerID, checkpointDir, checkpoint string) error {
+	if _, err := cm.engine.run(ctx, "start", "--checkpoint", checkpoint, "--checkpoint-dir", checkpointDir, containerID); err != nil {
+		return fmt.Errorf("container restore failed: %w", err)
+	}
+	return nil
+}
+
 func (cm *ContainerManager) StopContainer(ctx context.Context, containerID string, timeout time.Duration) error {
 	log := gologger.WithComponent("docker.container")
 
@@ -239,7 +487,7 @@ func (cm *ContainerManager) StopContainer(ctx context.Context, containerID strin
 		timeoutSecs = 1
 	}
 
-	if _, err := executils.ExecCommand(ctx, "docker", "stop", "-t", strconv.Itoa(timeoutSecs), containerID); err != nil {
+	if _, err := cm.engine.run(ctx, "stop", "-t", strconv.Itoa(timeoutSecs), containerID); err != nil {
 		log.Warn().Err(err).Str("container", containerID).Msg("Container stop failed")
 		return fmt.Errorf("container stop failed: %w", err)
 	}
@@ -247,6 +495,14 @@ func (cm *ContainerManager) StopContainer(ctx context.Context, containerID strin
 	return nil
 }
 
+// KillContainer stops a container immediately with SIGKILL
+func (cm *ContainerManager) KillContainer(ctx context.Context, containerID string) error {
+	if _, err := cm.engine.run(ctx, "kill", containerID); err != nil {
+		return fmt.Errorf("container kill failed: %w", err)
+	}
+	return nil
+}
+
 func (cm *ContainerManager) WaitForContainer(ctx context.Context, containerID string) (int, error) {
 	log := gologger.WithComponent("docker.container")
 
@@ -254,7 +510,7 @@ func (cm *ContainerManager) WaitForContainer(ctx context.Context, containerID st
 	errChan := make(chan error, 1)
 
 	go func() {
-		waitOutput, err := executils.ExecCommand(ctx, "docker", "wait", containerID)
+		waitOutput, err := cm.engine.run(ctx, "wait", containerID)
 		if err != nil {
 			errChan <- fmt.Errorf("container wait failed: %w", err)
 			return
@@ -302,7 +558,7 @@ func (cm *ContainerManager) WaitForContainer(ctx context.Context, containerID st
 func (cm *ContainerManager) GetContainerLogs(ctx context.Context, containerID string) (string, error) {
 	log := gologger.WithComponent("docker.container")
 
-	logs, err := executils.ExecCommand(ctx, "docker", "logs", containerID)
+	logs, err := cm.engine.run(ctx, "logs", containerID)
 	if err != nil {
 		log.Error().Err(err).Str("container", containerID).Msg("Log fetch failed")
 		return "", fmt.Errorf("log fetch failed: %w", err)
@@ -311,10 +567,22 @@ func (cm *ContainerManager) GetContainerLogs(ctx context.Context, containerID st
 	return formatContainerOutput(logs), nil
 }
 
+// FollowLogs copies the container's output to stdout and stderr as it is
+// written, until the container stops or ctx is done
+func (cm *ContainerManager) FollowLogs(ctx context.Context, containerID string, stdout, stderr io.Writer) error {
+	cmd := cm.engine.command(ctx, "logs", "--follow", containerID)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to follow container logs: %w", err)
+	}
+	return nil
+}
+
 func (cm *ContainerManager) RemoveContainer(ctx context.Context, containerID string) error {
 	log := gologger.WithComponent("docker.container")
 
-	if _, err := executils.ExecCommand(ctx, "docker", "rm", "-f", containerID); err != nil {
+	if _, err := cm.engine.run(ctx, "rm", "-f", containerID); err != nil {
 		log.Debug().Err(err).Str("container", containerID).Msg("Container removal failed")
 		return fmt.Errorf("container removal failed: %w", err)
 	}
@@ -348,7 +616,7 @@ func (cm *ContainerManager) preVerifyContainer(ctx context.Context, containerID
 	if state.Status == "created" {
 		log.Debug().Str("container", containerID).
 			Msg("Container in 'created' state, attempting to start")
-		_, startErr := executils.ExecCommand(ctx, "docker", "start", containerID)
+		_, startErr := cm.engine.run(ctx, "start", containerID)
 		if startErr != nil {
 			log.Warn().Err(startErr).Str("container", containerID).
 				Msg("Failed to start container in pre-verification")
@@ -401,7 +669,7 @@ func (cm *ContainerManager) preVerifyContainer(ctx context.Context, containerID
 		return true
 	}
 
-	inspectOutput, inspectErr := executils.ExecCommand(ctx, "docker", "inspect", containerID)
+	inspectOutput, inspectErr := cm.engine.run(ctx, "inspect", containerID)
 	if inspectErr == nil {
 		log.Debug().Str("container", containerID).Str("inspect_output", string(inspectOutput)).
 			Msg("Container inspection details from pre-verification")
@@ -504,7 +772,7 @@ func (cm *ContainerManager) waitForContainerRunning(ctx context.Context, contain
 func (cm *ContainerManager) handleStatusError(ctx context.Context, containerID string, statusErr error, attempt int, maxRetries int, retryDelay time.Duration, isLastAttempt bool) bool {
 	log := gologger.WithComponent("docker.container")
 
-	inspectOutput, inspectErr := executils.ExecCommand(ctx, "docker", "inspect", containerID)
+	inspectOutput, inspectErr := cm.engine.run(ctx, "inspect", containerID)
 	if inspectErr == nil {
 		log.Debug().Str("container", containerID).Str("inspect_output", string(inspectOutput)).
 			Msg("Container inspection details")
@@ -513,7 +781,7 @@ func (cm *ContainerManager) handleStatusError(ctx context.Context, containerID s
 			Msg("Failed to get detailed container inspection")
 	}
 
-	stateOutput, stateErr := executils.ExecCommand(ctx, "docker", "inspect", "--format={{.State.Status}}", containerID)
+	stateOutput, stateErr := cm.engine.run(ctx, "inspect", "--format={{.State.Status}}", containerID)
 	if stateErr == nil {
 		containerState := strings.TrimSpace(string(stateOutput))
 		log.Debug().Str("container", containerID).Str("container_state", containerState).
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestDNSArgs(t *testing.T) {
+	config := &models.DNSConfig{
+		Servers: []string{"10.0.0.53"},
+		Search:  []string{"corp.internal"},
+		Options: []string{"ndots:1"},
+		Hosts:   map[string]string{"registry.local": "10.0.0.5", "api.local": "10.0.0.6"},
+	}
+
+	want := []string{
+		"--dns", "10.0.0.53",
+		"--dns-search", "corp.internal",
+		"--dns-option", "ndots:1",
+		"--add-host", "api.local:10.0.0.6",
+		"--add-host", "registry.local:10.0.0.5",
+	}
+	if got := dnsArgs(config); !reflect.DeepEqual(got, want) {
+		t.Fatalf("dnsArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestDNSArgsNone(t *testing.T) {
+	config := &models.DNSConfig{None: true, Hosts: map[string]string{"data.local": "192.168.1.10"}}
+
+	want := []string{"--dns", disabledDNSServer, "--add-host", "data.local:192.168.1.10"}
+	if got := dnsArgs(config); !reflect.DeepEqual(got, want) {
+		t.Fatalf("dnsArgs() = %v, want %v", got, want)
+	}
+
+	config.Servers = []string{"1.1.1.1"}
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected an error when servers are set with dns disabled")
+	}
+}
+
+func TestContainerOptionsGPUs(t *testing.T) {
+	var options containerOptions
+	WithGPUs(`"device=0,2"`)(&options)
+
+	want := []string{"--gpus", `"device=0,2"`}
+	if got := options.args(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("args() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,334 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+)
+
+const (
+	// maxCheckpointArchive bounds an uploaded or downloaded checkpoint, which
+	// holds the container's memory and the checkpoint volume
+	maxCheckpointArchive = 1 << 30
+	// maxCheckpointExtracted bounds what a downloaded archive may unpack to
+	maxCheckpointExtracted = 4 * maxCheckpointArchive
+
+	checkpointTransferTimeout = 10 * time.Minute
+)
+
+// checkpointNamePattern matches the names docker accepts for checkpoints
+var checkpointNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// checkpointContent stores uploaded checkpoints, e.g. an ipfs.Backend
+type checkpointContent interface {
+	Add(ctx context.Context, data []byte) (string, error)
+	Fetch(ctx context.Context, cid string, maxBytes int64, expectedSHA256 string) ([]byte, error)
+}
+
+// criuAvailable reports whether the Docker daemon can checkpoint containers,
+// which requires its experimental features
+func criuAvailable(ctx context.Context, engine Engine) error {
+	if !engine.CRIU {
+		return fmt.Errorf("%s cannot checkpoint container processes", engine.Command)
+	}
+	output, err := engine.run(ctx, "version", "--format", "{{.Server.Experimental}}")
+	if err != nil {
+		return fmt.Errorf("failed to query docker daemon: %w", err)
+	}
+	if strings.TrimSpace(string(output)) != "true" {
+		return errors.New("docker checkpoint requires the daemon's experimental features")
+	}
+	return nil
+}
+
+// checkpointProcesses saves the memory and process state of a running container
+// with CRIU, leaving the container running
+func (c *CheckpointSession) checkpointProcesses(ctx context.Context, containerID, name string) error {
+	dir := c.store.criuDir(c.meta.TaskID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create CRIU checkpoint directory: %w", err)
+	}
+	if _, err := c.store.engine.run(ctx, "checkpoint", "create", "--leave-running", "--checkpoint-dir", dir, containerID, name); err != nil {
+		return fmt.Errorf("docker checkpoint failed: %w", err)
+	}
+	return nil
+}
+
+// Start starts the container. When the attempt resumes from a CRIU checkpoint
+// its processes are restored; a failed restore starts the container afresh from
+// the filesystem snapshot.
+func (c *CheckpointSession) Start(ctx context.Context, containers *ContainerManager, containerID string) error {
+	log := gologger.WithComponent("docker.checkpoint")
+
+	if c.volumeSeed != "" {
+		defer os.RemoveAll(c.volumeSeed)
+		if _, err := c.store.engine.run(ctx, "cp", c.volumeSeed+"/.", containerID+":"+c.path); err != nil {
+			return fmt.Errorf("failed to restore checkpoint volume: %w", err)
+		}
+	}
+
+	if c.restoreFrom != "" {
+		err := containers.StartContainerFromCheckpoint(ctx, containerID, c.store.criuDir(c.meta.TaskID), c.restoreFrom)
+		if err == nil {
+			log.Info().Str("task_id", c.meta.TaskID).Str("checkpoint", c.restoreFrom).Msg("Restored container processes from checkpoint")
+			return nil
+		}
+		log.Warn().Err(err).Str("task_id", c.meta.TaskID).Msg("Failed to restore container processes, starting from the filesystem snapshot")
+	}
+
+	return containers.StartContainer(ctx, containerID)
+}
+
+// uploadCheckpoint adds a CRIU checkpoint and the checkpoint volume to the
+// content store, recording the CID in the restart metadata
+func (c *CheckpointSession) uploadCheckpoint(containerID, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointTransferTimeout)
+	defer cancel()
+
+	staging, err := os.MkdirTemp("", "parity-ckpt-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	volumeDir := filepath.Join(staging, "volume")
+	if _, err := c.store.engine.run(ctx, "cp", containerID+":"+c.path+"/.", volumeDir); err != nil {
+		return fmt.Errorf("failed to copy checkpoint volume: %w", err)
+	}
+
+	archive, err := writeCheckpointArchive(name, filepath.Join(c.store.criuDir(c.meta.TaskID), name), volumeDir)
+	if err != nil {
+		return err
+	}
+
+	cid, err := c.store.content.Add(ctx, archive)
+	if err != nil {
+		return fmt.Errorf("failed to upload checkpoint: %w", err)
+	}
+
+	c.mu.Lock()
+	c.meta.CheckpointCIDs = append(c.meta.CheckpointCIDs, cid)
+	meta := *c.meta
+	c.mu.Unlock()
+
+	log := gologger.WithComponent("docker.checkpoint")
+	log.Info().Str("task_id", meta.TaskID).Str("cid", cid).Int("bytes", len(archive)).Msg("Checkpoint uploaded")
+	return c.store.Save(&meta)
+}
+
+// fetchCheckpoint downloads an uploaded checkpoint for a task that has no local
+// state. It returns the CRIU checkpoint name and a directory with the volume contents.
+func (s *CheckpointStore) fetchCheckpoint(ctx context.Context, taskID, cid string) (string, string, error) {
+	if err := ipfs.ValidateCID(cid); err != nil {
+		return "", "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkpointTransferTimeout)
+	defer cancel()
+
+	archive, err := s.content.Fetch(ctx, cid, maxCheckpointArchive, "")
+	if err != nil {
+		return "", "", err
+	}
+
+	volumeDir, err := os.MkdirTemp("", "parity-ckpt-volume-")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create volume directory: %w", err)
+	}
+
+	criuDir := s.criuDir(taskID)
+	name, err := unpackCheckpointArchive(archive, criuDir, volumeDir)
+	if err != nil {
+		os.RemoveAll(volumeDir)
+		os.RemoveAll(criuDir)
+		return "", "", err
+	}
+	return name, volumeDir, nil
+}
+
+// writeCheckpointArchive packs a CRIU checkpoint directory under criu/<name> and
+// the checkpoint volume under volume/ into a gzipped tar
+func writeCheckpointArchive(name, checkpointDir, volumeDir string) ([]byte, error) {
+	archive, err := writeArchive([]archiveTree{
+		{path.Join("criu", name), checkpointDir},
+		{"volume", volumeDir},
+	}, maxCheckpointArchive, maxCheckpointExtracted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive checkpoint: %w", err)
+	}
+	return archive, nil
+}
+
+// archiveTree is a file or directory packed under prefix
+type archiveTree struct {
+	prefix, dir string
+}
+
+// writeArchive packs trees into a gzipped tar of at most limit bytes. The
+// files packed may hold at most fileLimit bytes together, checked before each
+// one is read.
+func writeArchive(trees []archiveTree, limit int, fileLimit int64) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	var packed int64
+
+	for _, tree := range trees {
+		err := filepath.Walk(tree.dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			// Links and devices are left out so an archive can only create plain files
+			if !info.Mode().IsRegular() && !info.IsDir() {
+				return nil
+			}
+
+			if info.Mode().IsRegular() {
+				if info.Size() > fileLimit-packed {
+					return fmt.Errorf("archived files exceed %d bytes", fileLimit)
+				}
+				packed += info.Size()
+			}
+
+			rel, err := filepath.Rel(tree.dir, file)
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = path.Join(tree.prefix, filepath.ToSlash(rel))
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			// A file that grew since it was measured is cut at the size in its header
+			if _, err := io.Copy(tw, io.LimitReader(f, info.Size())); err != nil {
+				return err
+			}
+			if buf.Len() > limit {
+				return fmt.Errorf("archive exceeds %d bytes", limit)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unpackCheckpointArchive extracts an archive written by writeCheckpointArchive,
+// returning the name of the CRIU checkpoint it holds
+func unpackCheckpointArchive(archive []byte, criuDir, volumeDir string) (string, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return "", fmt.Errorf("invalid checkpoint archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var name string
+	var extracted int64
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid checkpoint archive: %w", err)
+		}
+
+		clean := path.Clean(header.Name)
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return "", fmt.Errorf("invalid checkpoint entry %q", header.Name)
+		}
+
+		root, rest, _ := strings.Cut(clean, "/")
+		var target string
+		switch root {
+		case "criu":
+			checkpoint, _, _ := strings.Cut(rest, "/")
+			if checkpoint == "" {
+				continue
+			}
+			if !checkpointNamePattern.MatchString(checkpoint) {
+				return "", fmt.Errorf("invalid checkpoint name %q", checkpoint)
+			}
+			if name != "" && checkpoint != name {
+				return "", errors.New("checkpoint archive holds more than one checkpoint")
+			}
+			name = checkpoint
+			target = filepath.Join(criuDir, filepath.FromSlash(rest))
+		case "volume":
+			target = filepath.Join(volumeDir, filepath.FromSlash(rest))
+		default:
+			return "", fmt.Errorf("unexpected checkpoint entry %q", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return "", fmt.Errorf("failed to extract checkpoint: %w", err)
+			}
+		case tar.TypeReg:
+			extracted += header.Size
+			if extracted > maxCheckpointExtracted {
+				return "", fmt.Errorf("checkpoint expands to more than %d bytes", int64(maxCheckpointExtracted))
+			}
+			if err := extractFile(tr, target, header); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if name == "" {
+		return "", errors.New("checkpoint archive holds no CRIU checkpoint")
+	}
+	return name, nil
+}
+
+func extractFile(r io.Reader, target string, header *tar.Header) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return fmt.Errorf("failed to extract checkpoint: %w", err)
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&0o777)
+	if err != nil {
+		return fmt.Errorf("failed to extract checkpoint: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.CopyN(f, r, header.Size); err != nil {
+		return fmt.Errorf("failed to extract checkpoint: %w", err)
+	}
+	return nil
+}
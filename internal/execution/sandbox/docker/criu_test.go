@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeCheckpointContent struct {
+	blobs map[string][]byte
+}
+
+func (f *fakeCheckpointContent) Add(_ context.Context, data []byte) (string, error) {
+	cid := "bafy" + string(rune('a'+len(f.blobs)))
+	f.blobs[cid] = data
+	return cid, nil
+}
+
+func (f *fakeCheckpointContent) Fetch(_ context.Context, cid string, _ int64, _ string) ([]byte, error) {
+	return f.blobs[cid], nil
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckpointArchiveRoundTrip(t *testing.T) {
+	source := t.TempDir()
+	writeTestFile(t, filepath.Join(source, "criu", "pages-1.img"), "memory")
+	writeTestFile(t, filepath.Join(source, "volume", "state", "epoch"), "7")
+
+	archive, err := writeCheckpointArchive("attempt1-3", filepath.Join(source, "criu"), filepath.Join(source, "volume"))
+	if err != nil {
+		t.Fatalf("writeCheckpointArchive() error = %v", err)
+	}
+
+	content := &fakeCheckpointContent{blobs: map[string][]byte{}}
+	cid, _ := content.Add(context.Background(), archive)
+	store := &CheckpointStore{dir: t.TempDir(), criu: true, content: content}
+
+	name, volumeDir, err := store.fetchCheckpoint(context.Background(), "task-1", cid)
+	if err != nil {
+		t.Fatalf("fetchCheckpoint() error = %v", err)
+	}
+	defer os.RemoveAll(volumeDir)
+
+	if name != "attempt1-3" {
+		t.Fatalf("checkpoint name = %q, want attempt1-3", name)
+	}
+	if data, err := os.ReadFile(filepath.Join(store.criuDir("task-1"), name, "pages-1.img")); err != nil || string(data) != "memory" {
+		t.Fatalf("restored checkpoint file = %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(volumeDir, "state", "epoch")); err != nil || string(data) != "7" {
+		t.Fatalf("restored volume file = %q, %v", data, err)
+	}
+}
+
+func TestUnpackCheckpointArchiveRejectsEscapingPaths(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"criu/attempt1-1/pages.img", "volume/../../outside"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+
+	dir := t.TempDir()
+	if _, err := unpackCheckpointArchive(buf.Bytes(), filepath.Join(dir, "criu"), filepath.Join(dir, "volume")); err == nil {
+		t.Fatal("expected an error for an entry outside the archive")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "outside")); err == nil {
+		t.Fatal("archive entry was written outside the target directory")
+	}
+}
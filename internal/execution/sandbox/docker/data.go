@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/datacache"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+)
+
+const taskDataMountPath = "/parity/input"
+
+// stageTaskData writes the task's inline or IPFS referenced data to a temporary
+// directory that is mounted read-only into the container. The caller removes it.
+func (e *DockerExecutor) stageTaskData(ctx context.Context, config *models.TaskConfig) (string, error) {
+	data := []byte(config.Data)
+	if config.Data == "" {
+		fetched, err := e.content.Fetch(ctx, config.DataCID, ipfs.DefaultMaxFetchBytes, config.DataSHA256)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve data_cid: %w", err)
+		}
+		data = fetched
+	}
+
+	dir, err := os.MkdirTemp("", "parity-data-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0o755); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to set data directory permissions: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data"), data, 0o644); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to write task data: %w", err)
+	}
+	return dir, nil
+}
+
+// stageInputs fetches the task's inputs into a temporary directory and returns
+// the read-only mounts that put each at its path. The caller removes the
+// directory.
+func (e *DockerExecutor) stageInputs(ctx context.Context, inputs []models.InputMount) (string, []ContainerOption, error) {
+	dir, err := os.MkdirTemp("", "parity-inputs-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create inputs directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0o755); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("failed to set inputs directory permissions: %w", err)
+	}
+
+	opts := make([]ContainerOption, 0, len(inputs))
+	for i, input := range inputs {
+		data, err := e.fetchInput(ctx, input)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", nil, err
+		}
+		file := filepath.Join(dir, strconv.Itoa(i))
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			os.RemoveAll(dir)
+			return "", nil, fmt.Errorf("failed to write input %s: %w", input.CID, err)
+		}
+		opts = append(opts, WithReadOnlyMount(file, input.Path))
+	}
+	return dir, opts, nil
+}
+
+// fetchInput returns an input from the dataset cache when it holds a copy
+// matching the expected hash, and downloads and caches it otherwise
+func (e *DockerExecutor) fetchInput(ctx context.Context, input models.InputMount) ([]byte, error) {
+	log := gologger.WithComponent("docker")
+
+	cache := e.config.InputCache
+	if cache != nil {
+		data, err := cache.Get(input.CID)
+		if err == nil && (input.SHA256 == "" || strings.EqualFold(ipfs.SHA256Hex(data), input.SHA256)) {
+			log.Debug().Str("cid", input.CID).Int("bytes", len(data)).Msg("Loaded task input from cache")
+			return data, nil
+		}
+		if err != nil && !errors.Is(err, datacache.ErrNotFound) {
+			log.Warn().Err(err).Str("cid", input.CID).Msg("Ignoring cached task input")
+		}
+	}
+
+	data, err := e.content.Fetch(ctx, input.CID, models.MaxInputBytes, input.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch input %s: %w", input.CID, err)
+	}
+	if cache != nil {
+		if err := cache.Put(input.CID, data); err != nil {
+			log.Warn().Err(err).Str("cid", input.CID).Msg("Failed to cache task input")
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/datacache"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+)
+
+const testInputCID = "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"
+
+func TestStageInputsUsesCache(t *testing.T) {
+	content := &fakeCheckpointContent{blobs: map[string][]byte{testInputCID: []byte("rows")}}
+	cache := datacache.New(t.TempDir(), 1<<20)
+	executor := &DockerExecutor{config: &ExecutorConfig{InputCache: cache}, content: content}
+	inputs := []models.InputMount{{CID: testInputCID, SHA256: ipfs.SHA256Hex([]byte("rows")), Path: "/data/rows.csv"}}
+
+	dir, opts, err := executor.stageInputs(context.Background(), inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var mounts containerOptions
+	for _, opt := range opts {
+		opt(&mounts)
+	}
+	if args := mounts.args(); len(args) != 2 || args[1] != filepath.Join(dir, "0")+":/data/rows.csv:ro" {
+		t.Fatalf("args() = %v", args)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "0")); err != nil || string(data) != "rows" {
+		t.Fatalf("staged input = %q, %v", data, err)
+	}
+
+	// A second task is served from the cache
+	delete(content.blobs, testInputCID)
+	data, err := executor.fetchInput(context.Background(), inputs[0])
+	if err != nil || string(data) != "rows" {
+		t.Fatalf("cached input = %q, %v", data, err)
+	}
+}
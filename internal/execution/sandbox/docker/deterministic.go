@@ -0,0 +1,118 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	// faketimeMountPath is where the runner's libfaketime appears in the
+	// containers of deterministic tasks
+	faketimeMountPath     = "/opt/parity/libfaketime.so.1"
+	deterministicHostname = "parity-task"
+	faketimeLayout        = "2006-01-02 15:04:05"
+)
+
+// deterministicRun sets up a task in deterministic mode: no network, a fixed
+// host name and locale, and a clock that starts at the task's epoch when the
+// runner has libfaketime. The monotonic clock is left alone so timeouts inside
+// the task still work.
+func (e *DockerExecutor) deterministicRun(config *models.TaskConfig) ([]string, []ContainerOption, *models.DeterministicSummary) {
+	start := config.Deterministic.StartTime()
+	_, digest, _ := strings.Cut(config.ImageName, "@")
+	summary := &models.DeterministicSummary{
+		HashVersion: models.DeterministicHashVersion,
+		ImageDigest: digest,
+		Epoch:       start.Unix(),
+	}
+
+	env := []string{
+		"TZ=UTC",
+		"LANG=C",
+		"LC_ALL=C",
+		"PYTHONHASHSEED=0",
+		"SOURCE_DATE_EPOCH=" + strconv.FormatInt(start.Unix(), 10),
+	}
+	opts := []ContainerOption{WithNetwork("none"), withHostname(deterministicHostname)}
+	if e.config.FaketimeLibrary != "" {
+		env = append(env,
+			"LD_PRELOAD="+faketimeMountPath,
+			"FAKETIME=@"+start.Format(faketimeLayout),
+			"FAKETIME_DONT_FAKE_MONOTONIC=1",
+		)
+		opts = append(opts, WithReadOnlyMount(e.config.FaketimeLibrary, faketimeMountPath))
+		summary.ClockPinned = true
+	}
+	return env, opts, summary
+}
+
+// deterministicResultHash hashes what a deterministic task produced, leaving out
+// what differs between runners that ran it the same way: timestamps, ownership,
+// the layout of the outputs bundle and line endings. files maps the path of
+// every output file to the SHA-256 of its contents.
+func deterministicResultHash(output string, exitCode int, files map[string]string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "parity-deterministic-v%d\nexit %d\n", models.DeterministicHashVersion, exitCode)
+	output = normalizeOutput(output)
+	fmt.Fprintf(h, "output %d\n%s\n", len(output), output)
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		fmt.Fprintf(h, "file %s %s\n", p, files[p])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeOutput drops carriage returns and trailing whitespace
+func normalizeOutput(output string) string {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// treeDigests adds the SHA-256 of every regular file in trees to digests,
+// keyed by its path in the outputs bundle
+func treeDigests(trees []archiveTree, digests map[string]string) error {
+	for _, tree := range trees {
+		err := filepath.Walk(tree.dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(tree.dir, file)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			h := sha256.New()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+			digests[path.Join(tree.prefix, filepath.ToSlash(rel))] = hex.EncodeToString(h.Sum(nil))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
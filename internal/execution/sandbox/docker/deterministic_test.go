@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestDeterministicResultHashNormalizesOutput(t *testing.T) {
+	files := map[string]string{"outputs/a.txt": "aa", "outputs/b.txt": "bb"}
+	want := deterministicResultHash("line 1\nline 2\n", 0, files)
+
+	if got := deterministicResultHash("line 1  \r\nline 2\r\n\n", 0, map[string]string{"outputs/b.txt": "bb", "outputs/a.txt": "aa"}); got != want {
+		t.Error("line endings, trailing whitespace and file order should not change the hash")
+	}
+	if deterministicResultHash("line 1\nline 2\n", 1, files) == want {
+		t.Error("exit code should change the hash")
+	}
+	if deterministicResultHash("line 1\nline 2\n", 0, map[string]string{"outputs/a.txt": "aa"}) == want {
+		t.Error("missing file should change the hash")
+	}
+}
+
+func TestTreeDigests(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "x"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	digests := make(map[string]string)
+	if err := treeDigests([]archiveTree{{prefix: "outputs/0", dir: dir}}, digests); err != nil {
+		t.Fatal(err)
+	}
+	const sha = "2d711642b726b04401627ca9fbac32f5c8530fb1903cc4db02258717921a4881"
+	if len(digests) != 1 || digests["outputs/0/sub/x"] != sha {
+		t.Fatalf("digests = %v", digests)
+	}
+}
+
+func TestDeterministicRunPinsClockOnlyWithFaketime(t *testing.T) {
+	config := &models.TaskConfig{
+		ImageName:     "alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		Deterministic: &models.DeterministicConfig{Epoch: 1700000000},
+	}
+
+	executor := &DockerExecutor{config: &ExecutorConfig{}}
+	env, opts, summary := executor.deterministicRun(config)
+	var options containerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.network != "none" || options.hostname != deterministicHostname {
+		t.Fatalf("network = %q, hostname = %q", options.network, options.hostname)
+	}
+	if !slices.Contains(env, "SOURCE_DATE_EPOCH=1700000000") || slices.Contains(env, "LD_PRELOAD="+faketimeMountPath) {
+		t.Fatalf("env = %q", env)
+	}
+	if summary.ClockPinned || summary.Epoch != 1700000000 || summary.ImageDigest != "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef" {
+		t.Fatalf("summary = %+v", summary)
+	}
+
+	executor.config.FaketimeLibrary = "/usr/lib/faketime/libfaketime.so.1"
+	env, _, summary = executor.deterministicRun(config)
+	if !summary.ClockPinned || !slices.Contains(env, "FAKETIME=@2023-11-14 22:13:20") {
+		t.Fatalf("env = %q, summary = %+v", env, summary)
+	}
+}
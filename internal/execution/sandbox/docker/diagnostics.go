@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/diagnostics"
+)
+
+// containerInspect is the part of the engine's inspect output diagnostics use
+type containerInspect struct {
+	RestartCount int `json:"RestartCount"`
+	State        struct {
+		Status     string `json:"Status"`
+		ExitCode   int    `json:"ExitCode"`
+		OOMKilled  bool   `json:"OOMKilled"`
+		Error      string `json:"Error"`
+		StartedAt  string `json:"StartedAt"`
+		FinishedAt string `json:"FinishedAt"`
+	} `json:"State"`
+	Config struct {
+		Image string `json:"Image"`
+	} `json:"Config"`
+	HostConfig struct {
+		Memory   int64 `json:"Memory"`
+		NanoCpus int64 `json:"NanoCpus"`
+	} `json:"HostConfig"`
+}
+
+// parseInspect reads how a container ended from its inspect output
+func parseInspect(output []byte) (*models.ContainerDiagnostics, error) {
+	var inspect containerInspect
+	if err := json.Unmarshal(output, &inspect); err != nil {
+		return nil, fmt.Errorf("failed to parse container inspect output: %w", err)
+	}
+	state := &models.ContainerDiagnostics{
+		Image:        inspect.Config.Image,
+		Status:       inspect.State.Status,
+		ExitCode:     inspect.State.ExitCode,
+		OOMKilled:    inspect.State.OOMKilled,
+		Error:        inspect.State.Error,
+		RestartCount: inspect.RestartCount,
+		MemoryLimit:  inspect.HostConfig.Memory,
+		NanoCPUs:     inspect.HostConfig.NanoCpus,
+	}
+	if startedAt, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt); err == nil {
+		state.StartedAt = startedAt.UTC()
+	}
+	if finishedAt, err := time.Parse(time.RFC3339Nano, inspect.State.FinishedAt); err == nil {
+		state.FinishedAt = finishedAt.UTC()
+	}
+	return state, nil
+}
+
+// InspectDiagnostics reads how a container ended
+func (cm *ContainerManager) InspectDiagnostics(ctx context.Context, containerID string) (*models.ContainerDiagnostics, error) {
+	output, err := cm.engine.run(ctx, "inspect", "--format", "{{json .}}", containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	return parseInspect(output)
+}
+
+// diagnose collects the diagnostics of a failed task container before it is
+// removed: the end of its log, its inspect state and the monitor's timeline.
+// Values of secrets are redacted; the runner completes the bundle.
+func (e *DockerExecutor) diagnose(ctx context.Context, taskID, containerID, class string, monitor *ResourceMonitor, secrets []string) *models.FailureDiagnostics {
+	log := gologger.WithComponent("docker")
+	bundle := &models.FailureDiagnostics{Class: class}
+
+	logs, err := e.engine.run(ctx, "logs", "--tail", strconv.Itoa(e.config.Diagnostics.Lines()), containerID)
+	if err != nil {
+		log.Debug().Err(err).Str("task_id", taskID).Msg("Failed to read container log for diagnostics")
+	} else {
+		bundle.LogTail = diagnostics.Tail(formatContainerOutput(logs), e.config.Diagnostics.Lines())
+	}
+
+	if bundle.Container, err = e.containerMgr.InspectDiagnostics(ctx, containerID); err != nil {
+		log.Debug().Err(err).Str("task_id", taskID).Msg("Failed to inspect container for diagnostics")
+	}
+	if monitor != nil {
+		bundle.Metrics = monitor.Timeline()
+	}
+
+	diagnostics.Scrub(bundle, secrets)
+	return bundle
+}
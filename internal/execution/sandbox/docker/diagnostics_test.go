@@ -0,0 +1,48 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestParseInspectLeavesTheEnvironmentOut(t *testing.T) {
+	output := []byte(`{
+		"RestartCount": 0,
+		"State": {"Status": "exited", "ExitCode": 137, "OOMKilled": true, "Error": "",
+			"StartedAt": "2024-05-01T12:00:00.5Z", "FinishedAt": "2024-05-01T12:03:00Z"},
+		"Config": {"Image": "trainer:latest", "Env": ["API_TOKEN=secret"]},
+		"HostConfig": {"Memory": 8589934592, "NanoCpus": 4000000000}
+	}`)
+
+	state, err := parseInspect(output)
+	if err != nil {
+		t.Fatalf("parseInspect() error = %v", err)
+	}
+	want := models.ContainerDiagnostics{
+		Image:       "trainer:latest",
+		Status:      "exited",
+		ExitCode:    137,
+		OOMKilled:   true,
+		StartedAt:   time.Date(2024, 5, 1, 12, 0, 0, 5e8, time.UTC),
+		FinishedAt:  time.Date(2024, 5, 1, 12, 3, 0, 0, time.UTC),
+		MemoryLimit: 8 << 30,
+		NanoCPUs:    4e9,
+	}
+	if *state != want {
+		t.Fatalf("parseInspect() = %+v, want %+v", *state, want)
+	}
+}
+
+func TestResourceMonitorTimelineKeepsTheLatestSamples(t *testing.T) {
+	monitor := &ResourceMonitor{}
+	for i := 0; i < maxTimelineSamples+10; i++ {
+		monitor.record(models.MetricsSample{ElapsedMs: int64(i)})
+	}
+
+	timeline := monitor.Timeline()
+	if len(timeline) != maxTimelineSamples || timeline[0].ElapsedMs != 10 || timeline[len(timeline)-1].ElapsedMs != maxTimelineSamples+9 {
+		t.Fatalf("timeline holds %d samples from %d to %d", len(timeline), timeline[0].ElapsedMs, timeline[len(timeline)-1].ElapsedMs)
+	}
+}
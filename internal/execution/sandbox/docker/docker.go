@@ -4,20 +4,46 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/theblitlabs/gologger"
 
+	"github.com/theblitlabs/parity-runner/internal/artifacts"
+	"github.com/theblitlabs/parity-runner/internal/chaos"
 	"github.com/theblitlabs/parity-runner/internal/core/models"
-	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker/executils"
+	"github.com/theblitlabs/parity-runner/internal/credentials"
+	"github.com/theblitlabs/parity-runner/internal/datacache"
+	"github.com/theblitlabs/parity-runner/internal/diagnostics"
+	"github.com/theblitlabs/parity-runner/internal/events"
+	"github.com/theblitlabs/parity-runner/internal/gang"
+	"github.com/theblitlabs/parity-runner/internal/gpu"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+	"github.com/theblitlabs/parity-runner/internal/logstream"
+	"github.com/theblitlabs/parity-runner/internal/peerchannel"
 	"github.com/theblitlabs/parity-runner/internal/utils"
 )
 
+// channelMountPath is where the peer channel socket of a gang member appears
+const channelMountPath = "/parity/channel"
+
 type DockerExecutor struct {
-	config       *ExecutorConfig
-	imageManager *ImageManager
-	containerMgr *ContainerManager
+	engine        Engine
+	config        *ExecutorConfig
+	imageManager  *ImageManager
+	containerMgr  *ContainerManager
+	buildVerifier *BuildVerifier
+	checkpoints   *CheckpointStore
+	content       ipfs.Backend
+	// active are the tasks running in this executor, whose containers garbage
+	// collection keeps
+	mu     sync.Mutex
+	active map[string]bool
 }
 
 type ExecutorConfig struct {
@@ -25,6 +51,55 @@ type ExecutorConfig struct {
 	CPULimit         string        `mapstructure:"cpu_limit"`
 	Timeout          time.Duration `mapstructure:"timeout"`
 	ExecutionTimeout time.Duration `mapstructure:"execution_timeout"`
+	// GPUs are the host GPUs tasks with GPU requirements are given
+	GPUs  []models.GPUInfo `mapstructure:"-"`
+	Chaos *chaos.Injector  `mapstructure:"-"`
+	// CheckpointMode is CheckpointModeSnapshot or CheckpointModeCRIU, and
+	// CheckpointUpload adds CRIU checkpoints to IPFS
+	CheckpointMode   string `mapstructure:"-"`
+	CheckpointUpload bool   `mapstructure:"-"`
+	// Workspaces, when set, keeps the working directory of every task container
+	Workspaces *artifacts.Store `mapstructure:"-"`
+	// Content fetches task data and stores uploaded checkpoints. Without it
+	// IPFS_GATEWAY_URL and IPFS_API_URL are used.
+	Content ipfs.Backend `mapstructure:"-"`
+	// InputCache, when set, keeps the inputs tasks mount so they are not
+	// downloaded for every task
+	InputCache *datacache.Cache `mapstructure:"-"`
+	// OutputUpload gives tasks a directory in PARITY_OUTPUT_DIR whose files
+	// are added to Content and listed in the result's uploads
+	OutputUpload bool `mapstructure:"-"`
+	// Host, when set, runs task containers on that Docker daemon instead of
+	// the local one
+	Host RemoteHost `mapstructure:"-"`
+	// ImagePolicy is checked before a task's image is pulled or loaded
+	ImagePolicy models.ImagePolicy `mapstructure:"-"`
+	// ImageSignatures, when set, only runs images one of them signed
+	ImageSignatures ImageSignatures `mapstructure:"-"`
+	// FaketimeLibrary is the host's libfaketime.so.1, preloaded into the
+	// containers of deterministic tasks to start their clock at a fixed time
+	FaketimeLibrary string `mapstructure:"-"`
+	// Diagnostics sets how much of the log of a failed task container is kept
+	Diagnostics diagnostics.Config `mapstructure:"-"`
+}
+
+// applyDefaults fills in limits the runner configuration left unset
+func (c *ExecutorConfig) applyDefaults() {
+	if c.MemoryLimit == "" {
+		c.MemoryLimit = "8g"
+	}
+	if c.CPULimit == "" {
+		c.CPULimit = "8.0"
+		if runtime.GOMAXPROCS(0) < 8 {
+			c.CPULimit = fmt.Sprintf("%.1f", float64(runtime.GOMAXPROCS(0)))
+		}
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 15 * time.Minute
+	}
+	if c.ExecutionTimeout <= 0 {
+		c.ExecutionTimeout = 25 * time.Minute
+	}
 }
 
 func extractStringSlice(value interface{}) []string {
@@ -45,33 +120,89 @@ func extractStringSlice(value interface{}) []string {
 }
 
 func NewDockerExecutor(config *ExecutorConfig) (*DockerExecutor, error) {
+	return NewExecutorWithEngine(config, DockerEngine)
+}
+
+// NewExecutorWithEngine creates an executor that runs tasks with another
+// docker-compatible CLI
+func NewExecutorWithEngine(config *ExecutorConfig, engine Engine) (*DockerExecutor, error) {
 	log := gologger.WithComponent("docker")
 
-	if _, err := executils.ExecCommand(context.Background(), "docker", "version"); err != nil {
-		log.Error().Err(err).Msg("Docker not available")
-		return nil, fmt.Errorf("docker not available: %w", err)
+	engine, err := engine.OnHost(config.Host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host: %w", err)
+	}
+	if _, err := engine.run(context.Background(), "version"); err != nil {
+		log.Error().Err(err).Str("engine", engine.Command).Msg("Container engine not available")
+		return nil, fmt.Errorf("%s not available: %w", engine.Command, err)
 	}
+	config.applyDefaults()
 
 	log.Debug().
+		Str("engine", engine.Command).
+		Str("host", engine.Host.Address).
 		Str("mem", config.MemoryLimit).
 		Str("cpu", config.CPULimit).
 		Dur("timeout", config.Timeout).
 		Dur("execution_timeout", config.ExecutionTimeout).
 		Msg("Executor initialized")
 
-	containerMgr, err := NewContainerManager(config.MemoryLimit, config.CPULimit)
+	containerMgr, err := NewContainerManager(engine, config.MemoryLimit, config.CPULimit)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to initialize container manager with seccomp profile")
 		return nil, fmt.Errorf("failed to initialize security: %w", err)
 	}
 
+	var content ipfs.Backend = ipfs.NewClientFromEnv()
+	if config.Content != nil {
+		content = config.Content
+	}
+	checkpoints := NewCheckpointStore("")
+	checkpoints.engine = engine
+	checkpoints.content = content
+	if config.CheckpointMode == CheckpointModeCRIU {
+		if err := criuAvailable(context.Background(), engine); err != nil {
+			log.Warn().Err(err).Msg("CRIU checkpoints unavailable, checkpointing the container filesystem only")
+		} else {
+			checkpoints.criu = true
+			checkpoints.upload = config.CheckpointUpload
+		}
+	}
+
+	imageManager := NewImageManager(engine)
+	imageManager.SetPolicy(config.ImagePolicy)
+	if err := imageManager.VerifySignatures(config.ImageSignatures); err != nil {
+		return nil, err
+	}
+	if config.FaketimeLibrary != "" {
+		if _, err := os.Stat(config.FaketimeLibrary); err != nil {
+			log.Warn().Err(err).Msg("libfaketime not found, deterministic tasks will see the real time")
+			config.FaketimeLibrary = ""
+		}
+	}
+
 	return &DockerExecutor{
-		config:       config,
-		imageManager: NewImageManager(),
-		containerMgr: containerMgr,
+		engine:        engine,
+		config:        config,
+		imageManager:  imageManager,
+		containerMgr:  containerMgr,
+		buildVerifier: NewBuildVerifier(engine),
+		checkpoints:   checkpoints,
+		content:       content,
 	}, nil
 }
 
+// PrepareImage pulls or loads image like a task would and returns its ID
+func (e *DockerExecutor) PrepareImage(ctx context.Context, image, imageURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+
+	if err := e.imageManager.EnsureImageAvailable(ctx, image, imageURL); err != nil {
+		return "", fmt.Errorf("image preparation failed: %w", err)
+	}
+	return e.engine.ImageID(ctx, image)
+}
+
 func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*models.TaskResult, error) {
 	log := gologger.WithComponent("docker")
 	startTime := time.Now()
@@ -100,6 +231,33 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
+	if config.Deterministic != nil {
+		if err := config.Validate(task.Type); err != nil {
+			log.Error().
+				Err(err).
+				Str("task_id", task.ID.String()).
+				Msg("Invalid deterministic task")
+			return nil, fmt.Errorf("invalid deterministic task: %w", err)
+		}
+	}
+
+	var gpuOpt ContainerOption
+	if task.GPU != nil {
+		gpus, ok := task.GPU.Select(e.config.GPUs)
+		if !ok {
+			log.Error().
+				Str("task_id", task.ID.String()).
+				Int("available_gpus", len(e.config.GPUs)).
+				Msg("Runner does not have the GPUs the task requires")
+			return nil, fmt.Errorf("runner does not have the GPUs the task requires")
+		}
+		if e.engine.CDI {
+			gpuOpt = WithDevices(gpu.CDIDevices(gpus)...)
+		} else {
+			gpuOpt = WithGPUs(gpu.DockerArg(gpus))
+		}
+	}
+
 	image := config.ImageName
 	if image == "" {
 		log.Error().
@@ -126,7 +284,7 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 	}
 
 	// Verify image hash
-	imageHashVerified, err := utils.VerifyImageHash(image)
+	imageHashVerified, err := e.engine.ImageID(setupCtx, image)
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -136,6 +294,23 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 		return nil, fmt.Errorf("image hash verification failed: %w", err)
 	}
 	result.ImageHashVerified = imageHashVerified
+	events.Record(ctx, models.ExecutionEventImagePulled, map[string]string{
+		"image":      image,
+		"image_hash": imageHashVerified,
+	})
+
+	if config.Build != nil {
+		verified, err := e.verifyBuild(ctx, task, config.Build, imageHashVerified)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("task_id", task.ID.String()).
+				Str("image", image).
+				Msg("Reproducible build verification failed")
+			return nil, fmt.Errorf("reproducible build verification failed: %w", err)
+		}
+		result.BuildVerified = verified
+	}
 
 	// Verify command hash if task has command
 	var commandHashVerified string
@@ -175,6 +350,9 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 			}
 		}
 	}
+	// Values of the task's own variables and credentials are redacted from
+	// the diagnostics of a failed run
+	secrets := append(diagnostics.Secrets(envVars[1:]), diagnostics.Secrets(credentials.Env(ctx))...)
 
 	log.Debug().
 		Str("task_id", task.ID.String()).
@@ -194,7 +372,162 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 			Msg("Using default command from image")
 	}
 
-	containerID, err := e.containerMgr.CreateContainer(setupCtx, image, workdir, envVars, command)
+	var containerOpts []ContainerOption
+	var checkpoint *CheckpointSession
+	if config.Checkpoint != nil && config.Checkpoint.Enabled {
+		checkpoint, err = e.checkpoints.BeginCheckpoint(setupCtx, task.ID.String(), image, config.Checkpoint)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("task_id", task.ID.String()).
+				Msg("Failed to prepare task checkpoint")
+			return nil, fmt.Errorf("checkpoint setup failed: %w", err)
+		}
+
+		log.Info().
+			Str("task_id", task.ID.String()).
+			Str("image", checkpoint.Image()).
+			Str("mode", checkpoint.Mode()).
+			Bool("resumed", checkpoint.Resumed()).
+			Msg("Checkpointing enabled for task")
+
+		image = checkpoint.Image()
+		envVars = append(envVars, checkpoint.Env()...)
+		containerOpts = append(containerOpts, checkpoint.ContainerOptions()...)
+	}
+
+	if config.DNS != nil {
+		containerOpts = append(containerOpts, WithDNS(config.DNS))
+	}
+
+	if gpuOpt != nil {
+		containerOpts = append(containerOpts, gpuOpt)
+	}
+
+	// outputDigests collects the output files of deterministic tasks for the
+	// result hash
+	var outputDigests map[string]string
+	if config.Deterministic != nil {
+		env, opts, summary := e.deterministicRun(&config)
+		envVars = append(envVars, env...)
+		containerOpts = append(containerOpts, opts...)
+		result.Deterministic = summary
+		outputDigests = make(map[string]string)
+		log.Info().
+			Str("task_id", task.ID.String()).
+			Int64("epoch", summary.Epoch).
+			Bool("clock_pinned", summary.ClockPinned).
+			Msg("Running task in deterministic mode")
+	}
+
+	if members := gang.FromContext(ctx); members != nil {
+		envVars = append(envVars, members.Env()...)
+		containerOpts = append(containerOpts, WithPublishedPort(members.Port))
+		log.Info().
+			Str("task_id", task.ID.String()).
+			Str("gang_id", members.GangID).
+			Int("rank", members.Self.Rank).
+			Int("port", members.Port).
+			Msg("Running gang member")
+	}
+	if dir := gang.ChannelDir(ctx); dir != "" {
+		if e.engine.Remote() {
+			return nil, fmt.Errorf("gang channels need a local docker host")
+		}
+		envVars = append(envVars, "PARITY_CHANNEL_SOCKET="+channelMountPath+"/"+peerchannel.SocketName)
+		containerOpts = append(containerOpts, WithMount(dir, channelMountPath))
+	}
+
+	if config.Data != "" || config.DataCID != "" {
+		dataDir, err := e.stageTaskData(setupCtx, &config)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("task_id", task.ID.String()).
+				Msg("Failed to stage task data")
+			return nil, fmt.Errorf("task data setup failed: %w", err)
+		}
+		defer os.RemoveAll(dataDir)
+		envVars = append(envVars, "PARITY_DATA_FILE="+taskDataMountPath+"/data")
+		containerOpts = append(containerOpts, WithReadOnlyMount(dataDir, taskDataMountPath))
+	}
+
+	if len(config.Inputs) > 0 {
+		inputsDir, inputOpts, err := e.stageInputs(setupCtx, config.Inputs)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("task_id", task.ID.String()).
+				Msg("Failed to stage task inputs")
+			return nil, fmt.Errorf("task inputs setup failed: %w", err)
+		}
+		defer os.RemoveAll(inputsDir)
+		containerOpts = append(containerOpts, inputOpts...)
+		log.Info().
+			Str("task_id", task.ID.String()).
+			Int("inputs", len(config.Inputs)).
+			Msg("Task inputs mounted")
+	}
+
+	var outputDir string
+	if e.config.OutputUpload {
+		outputDir, err = prepareOutputDir()
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("task_id", task.ID.String()).
+				Msg("Failed to prepare output directory")
+			return nil, fmt.Errorf("output directory setup failed: %w", err)
+		}
+		defer os.RemoveAll(outputDir)
+		envVars = append(envVars, "PARITY_OUTPUT_DIR="+outputMountPath)
+		containerOpts = append(containerOpts, WithMount(outputDir, outputMountPath))
+	}
+
+	var egress *EgressGuard
+	// Disabled DNS also drops queries sent straight to an outside resolver
+	blockDNS := config.DNS != nil && config.DNS.None
+	if blockDNS || (config.Egress != nil && (config.Egress.HasRules() || config.Egress.Audit)) {
+		egress, err = PrepareEgress(setupCtx, e.engine, task.ID.String(), config.Egress, blockDNS)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("task_id", task.ID.String()).
+				Msg("Failed to prepare egress policy")
+			return nil, fmt.Errorf("egress setup failed: %w", err)
+		}
+		// Registered before the container removal so it runs after it
+		defer egress.Close(context.Background())
+		containerOpts = append(containerOpts, egress.ContainerOptions()...)
+	}
+
+	// Brokered credentials go through a file so they stay out of the logged
+	// environment and the docker command line
+	var credentialFile string
+	if env := credentials.Env(ctx); len(env) > 0 {
+		credentialFile, err = writeEnvFile(env)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("task_id", task.ID.String()).
+				Msg("Failed to pass task credentials")
+			return nil, fmt.Errorf("task credentials setup failed: %w", err)
+		}
+		containerOpts = append(containerOpts, WithEnvFile(credentialFile))
+		log.Info().
+			Str("task_id", task.ID.String()).
+			Int("variables", len(env)).
+			Msg("Passing brokered credentials to container")
+	}
+
+	containerOpts = append(containerOpts, WithTask(task.ID.String()))
+	e.trackTask(task.ID.String())
+	defer e.untrackTask(task.ID.String())
+
+	containerID, err := e.containerMgr.CreateContainer(setupCtx, image, workdir, envVars, command, containerOpts...)
+	if credentialFile != "" {
+		os.Remove(credentialFile)
+	}
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -219,7 +552,12 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 		}
 	}()
 
-	if err := e.containerMgr.StartContainer(setupCtx, containerID); err != nil {
+	if checkpoint != nil {
+		err = checkpoint.Start(setupCtx, e.containerMgr, containerID)
+	} else {
+		err = e.containerMgr.StartContainer(setupCtx, containerID)
+	}
+	if err != nil {
 		log.Error().
 			Err(err).
 			Str("task_id", task.ID.String()).
@@ -227,57 +565,40 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 			Msg("Failed to start container")
 		return nil, fmt.Errorf("container start failed: %w", err)
 	}
+	events.Record(ctx, models.ExecutionEventContainerStarted, map[string]string{
+		"container_id": containerID,
+		"image":        image,
+	})
+	if stream := logstream.FromContext(ctx); stream != nil {
+		stopFollowing := e.followLogs(ctx, task, containerID, stream)
+		defer stopFollowing()
+	}
 
 	log.Info().
 		Str("task_id", task.ID.String()).
 		Str("container_id", containerID).
 		Msg("Container started successfully")
-	securityCtx, securityCancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer securityCancel()
-
-	log.Info().
-		Str("task_id", task.ID.String()).
-		Str("container_id", containerID).
-		Msg("Verifying container security (required)")
-
-	isSecure, securityMsg, securityErr := e.containerMgr.TestSeccompProfile(securityCtx, containerID)
-	if securityErr != nil && (securityErr == context.DeadlineExceeded || strings.Contains(securityErr.Error(), "context")) {
-		log.Warn().
-			Str("task_id", task.ID.String()).
-			Str("container_id", containerID).
-			Msg("Security verification timed out, but continuing with execution")
-	} else if !isSecure || securityErr != nil {
-		log.Error().
-			Err(securityErr).
-			Str("task_id", task.ID.String()).
-			Str("container_id", containerID).
-			Str("security_status", securityMsg).
-			Msg("Container security verification failed - task execution will be aborted")
-
-		cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		_ = e.containerMgr.RemoveContainer(cleanupCtx, containerID)
-
-		return nil, fmt.Errorf("security verification failed: %s", securityMsg)
+	if err := e.verifySecurity(ctx, task, containerID, map[string]string{
+		"image_hash":         imageHashVerified,
+		"command_hash":       commandHashVerified,
+		"build_verified":     strconv.FormatBool(result.BuildVerified),
+		"signature_verified": strconv.FormatBool(!e.config.ImageSignatures.IsZero()),
+	}); err != nil {
+		return nil, err
 	}
 
-	log.Info().
-		Str("task_id", task.ID.String()).
-		Str("container_id", containerID).
-		Str("security_status", securityMsg).
-		Msg("Container security verified successfully")
-
-	execCtx, execCancel := context.WithTimeout(ctx, e.config.ExecutionTimeout)
+	executionTimeout := e.executionTimeout(task)
+	execCtx, execCancel := context.WithTimeout(ctx, executionTimeout)
 	defer execCancel()
 
 	log.Info().
 		Str("task_id", task.ID.String()).
 		Str("container_id", containerID).
-		Dur("timeout", e.config.ExecutionTimeout).
+		Dur("timeout", executionTimeout).
 		Msg("Container running, execution timeout started")
 
 	var metrics *ResourceMonitor
-	metrics, err = NewResourceMetrics(containerID)
+	metrics, err = NewResourceMetrics(e.engine, containerID)
 	if err == nil {
 		if err := metrics.Start(execCtx); err != nil {
 			log.Error().
@@ -296,16 +617,42 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 			Msg("Failed to initialize metrics collector")
 	}
 
-	exitCode, err := e.containerMgr.WaitForContainer(execCtx, containerID)
+	if egress != nil {
+		egress.StartAudit()
+	}
+
+	if checkpoint != nil {
+		checkpoint.StartSnapshots(containerID)
+		defer checkpoint.StopSnapshots()
+	}
+
+	if after, kill := e.config.Chaos.ContainerKill(); kill {
+		go e.killContainerAfter(execCtx, task, containerID, after)
+	}
+
+	waitCtx := execCtx
+	if checkpoint != nil {
+		var release context.CancelFunc
+		waitCtx, release = checkpoint.SaveOnTimeout(execCtx, containerID)
+		defer release()
+	}
+
+	exitCode, err := e.containerMgr.WaitForContainer(waitCtx, containerID)
+	if checkpoint != nil {
+		result.Checkpoint = checkpoint.Summary()
+		if err == nil && exitCode == 0 {
+			checkpoint.Complete(context.Background())
+		}
+	}
 	var isGracefulTimeout bool
 	if err != nil {
 		if execCtx.Err() == context.DeadlineExceeded {
 			log.Info().
 				Str("task_id", task.ID.String()).
 				Str("container_id", containerID).
-				Dur("timeout", e.config.ExecutionTimeout).
+				Dur("timeout", executionTimeout).
 				Msg("Task execution timed out, container stopped gracefully")
-			result.Error = fmt.Sprintf("task execution exceeded timeout of %s and was gracefully stopped", e.config.ExecutionTimeout)
+			result.Error = fmt.Sprintf("task execution exceeded timeout of %s and was gracefully stopped", executionTimeout)
 			isGracefulTimeout = true
 		} else {
 			log.Error().
@@ -325,6 +672,33 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 			Msg("Container execution completed")
 	}
 
+	// Attach metrics before any early return so failed and timed out runs are still accounted for
+	if metrics != nil {
+		applyContainerMetrics(result, metrics.GetMetrics())
+	}
+
+	// Diagnostics are read now, the container is removed on return
+	if err != nil || exitCode != 0 {
+		class := models.DiagnosticExitCode
+		if isGracefulTimeout {
+			class = models.DiagnosticTimeout
+		} else if err != nil {
+			class = models.DiagnosticExecution
+		}
+		diagnoseCtx, diagnoseCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		result.Diagnostics = e.diagnose(diagnoseCtx, task.ID.String(), containerID, class, metrics, secrets)
+		diagnoseCancel()
+	}
+
+	if egress != nil {
+		result.Egress = egress.Summary()
+		log.Info().
+			Str("task_id", task.ID.String()).
+			Int("connections", result.Egress.Total).
+			Int("blocked", result.Egress.Blocked).
+			Msg("Egress audit completed")
+	}
+
 	// Check for potential seccomp-related errors (exit code 255 often indicates a syscall was blocked)
 	if exitCode == 255 {
 		log.Warn().
@@ -339,6 +713,64 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 	cleanupCtx, cleanupCancel := context.WithTimeout(ctx, e.config.Timeout)
 	defer cleanupCancel()
 
+	if e.config.Workspaces != nil {
+		e.keepWorkspace(cleanupCtx, task, containerID, workdir)
+	}
+
+	if len(config.Outputs) > 0 {
+		bundle, err := e.collectOutputs(cleanupCtx, task, containerID, config.Outputs, outputDigests)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("task_id", task.ID.String()).
+				Msg("Failed to collect declared outputs")
+			return result, fmt.Errorf("output collection failed: %w", err)
+		}
+		if bundle != nil {
+			result.Uploads = &models.ResultUploads{Files: []models.ResultUpload{*bundle}}
+			log.Info().
+				Str("task_id", task.ID.String()).
+				Int64("bytes", bundle.Size).
+				Bool("inline", bundle.CID == "").
+				Msg("Declared outputs bundled")
+		}
+	}
+
+	if outputDir != "" {
+		if e.engine.Remote() {
+			if _, err := e.engine.run(cleanupCtx, "cp", containerID+":"+outputMountPath+"/.", outputDir); err != nil {
+				log.Error().
+					Err(err).
+					Str("task_id", task.ID.String()).
+					Msg("Failed to copy output files from container")
+				return result, fmt.Errorf("output upload failed: %w", err)
+			}
+		}
+		uploads, err := uploadOutputs(cleanupCtx, e.content, outputDir)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("task_id", task.ID.String()).
+				Msg("Failed to upload output files")
+			return result, fmt.Errorf("output upload failed: %w", err)
+		}
+		if uploads != nil {
+			log.Info().
+				Str("task_id", task.ID.String()).
+				Int("files", len(uploads.Files)).
+				Msg("Output files uploaded")
+			if outputDigests != nil {
+				for _, file := range uploads.Files {
+					outputDigests[strings.TrimPrefix(outputMountPath, "/")+"/"+file.Name] = file.SHA256
+				}
+			}
+			if result.Uploads != nil {
+				uploads.Files = append(result.Uploads.Files, uploads.Files...)
+			}
+			result.Uploads = uploads
+		}
+	}
+
 	logs, logsErr := e.containerMgr.GetContainerLogs(cleanupCtx, containerID)
 	if logsErr != nil {
 		log.Error().
@@ -371,13 +803,6 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 	}
 
 	if metrics != nil {
-		collectedMetrics := metrics.GetMetrics()
-		result.CPUSeconds = collectedMetrics.CPUSeconds
-		result.EstimatedCycles = collectedMetrics.EstimatedCycles
-		result.MemoryGBHours = collectedMetrics.MemoryGBHours
-		result.StorageGB = collectedMetrics.StorageGB
-		result.NetworkDataGB = collectedMetrics.NetworkDataGB
-
 		duration := time.Since(startTime).Round(time.Millisecond)
 		log.Info().
 			Str("task_id", task.ID.String()).
@@ -403,6 +828,9 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 		stderr = result.Error
 	}
 	result.ResultHash = utils.ComputeResultHash(result.Output, stderr, result.ExitCode)
+	if result.Deterministic != nil {
+		result.ResultHash = deterministicResultHash(result.Output, result.ExitCode, outputDigests)
+	}
 
 	log.Info().
 		Str("task_id", task.ID.String()).
@@ -412,6 +840,171 @@ func (e *DockerExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*m
 	return result, nil
 }
 
+// verifyBuild checks the pulled image against the task's build recipe. The expected
+// digest is always compared; a full rebuild only happens on sampled runners.
+// verifySecurity checks the seccomp profile of a started container, removing
+// the container when the check fails. The security_verified event, with details
+// and the check's outcome, is only recorded once the check passed: a check that
+// timed out lets the task run without it.
+func (e *DockerExecutor) verifySecurity(ctx context.Context, task *models.Task, containerID string, details map[string]string) error {
+	log := gologger.WithComponent("docker")
+	securityCtx, securityCancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer securityCancel()
+
+	log.Info().
+		Str("task_id", task.ID.String()).
+		Str("container_id", containerID).
+		Msg("Verifying container security (required)")
+
+	isSecure, securityMsg, securityErr := e.containerMgr.TestSeccompProfile(securityCtx, containerID)
+	if securityErr != nil && (securityErr == context.DeadlineExceeded || strings.Contains(securityErr.Error(), "context")) {
+		log.Warn().
+			Str("task_id", task.ID.String()).
+			Str("container_id", containerID).
+			Msg("Security verification timed out, but continuing with execution")
+		return nil
+	}
+	if !isSecure || securityErr != nil {
+		log.Error().
+			Err(securityErr).
+			Str("task_id", task.ID.String()).
+			Str("container_id", containerID).
+			Str("security_status", securityMsg).
+			Msg("Container security verification failed - task execution will be aborted")
+
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = e.containerMgr.RemoveContainer(cleanupCtx, containerID)
+
+		return fmt.Errorf("security verification failed: %s", securityMsg)
+	}
+
+	log.Info().
+		Str("task_id", task.ID.String()).
+		Str("container_id", containerID).
+		Str("security_status", securityMsg).
+		Msg("Container security verified successfully")
+	details["seccomp"] = securityMsg
+	events.Record(ctx, models.ExecutionEventSecurityVerified, details)
+	return nil
+}
+
+func (e *DockerExecutor) verifyBuild(ctx context.Context, task *models.Task, recipe *models.BuildRecipe, imageID string) (bool, error) {
+	log := gologger.WithComponent("docker")
+
+	if expected := models.NormalizeDigest(recipe.ExpectedDigest); expected != "" && expected != models.NormalizeDigest(imageID) {
+		return false, fmt.Errorf("task image %s does not match expected digest %s", imageID, expected)
+	}
+
+	if !e.buildVerifier.ShouldVerify(task.ID.String(), recipe) {
+		return false, nil
+	}
+
+	if !e.buildVerifier.ToolsAvailable(recipe.Kind) {
+		log.Warn().
+			Str("task_id", task.ID.String()).
+			Str("kind", string(recipe.Kind)).
+			Msg("Selected to verify build but the required build tools are missing, skipping rebuild")
+		return false, nil
+	}
+
+	if err := e.buildVerifier.Verify(ctx, recipe, imageID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// executionTimeout is the maximum duration the server set on the task, falling
+// back to the executor's configured timeout
+// killContainerAfter kills a running task container on behalf of the fault injector
+func (e *DockerExecutor) killContainerAfter(ctx context.Context, task *models.Task, containerID string, after time.Duration) {
+	log := gologger.WithComponent("docker")
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(after):
+	}
+
+	log.Warn().
+		Str("task_id", task.ID.String()).
+		Str("container_id", containerID).
+		Dur("after", after).
+		Msg("Chaos: killing container mid-run")
+	if err := e.containerMgr.KillContainer(ctx, containerID); err != nil {
+		log.Debug().Err(err).Str("container_id", containerID).Msg("Chaos container kill failed")
+	}
+}
+
+// followLogGrace is how long the output still being read is waited for once
+// the task is done
+const followLogGrace = 5 * time.Second
+
+// followLogs streams the container's output while it runs. The returned
+// function waits for the output written up to the container's exit, or gives
+// up on it after followLogGrace.
+func (e *DockerExecutor) followLogs(ctx context.Context, task *models.Task, containerID string, stream *logstream.Stream) func() {
+	log := gologger.WithComponent("docker")
+
+	followCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stdout := stream.Writer(models.LogStreamStdout)
+		stderr := stream.Writer(models.LogStreamStderr)
+		if err := e.containerMgr.FollowLogs(followCtx, containerID, stdout, stderr); err != nil {
+			log.Debug().Err(err).Str("task_id", task.ID.String()).Msg("Stopped streaming container logs")
+		}
+	}()
+
+	return func() {
+		select {
+		case <-done:
+		case <-time.After(followLogGrace):
+		}
+		cancel()
+		<-done
+	}
+}
+
+// keepWorkspace copies the container's working directory into the task's
+// artifacts. A workdir of / is skipped rather than copying the whole image.
+func (e *DockerExecutor) keepWorkspace(ctx context.Context, task *models.Task, containerID, workdir string) {
+	log := gologger.WithComponent("docker")
+	if workdir == "/" {
+		return
+	}
+
+	path, err := e.config.Workspaces.WorkspacePath(task.ID.String())
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o700)
+	}
+	if err == nil {
+		_, err = e.engine.run(ctx, "cp", containerID+":"+workdir+"/.", path)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("task_id", task.ID.String()).Str("workdir", workdir).Msg("Failed to keep task workspace")
+		return
+	}
+	log.Debug().Str("task_id", task.ID.String()).Str("path", path).Msg("Task workspace kept")
+}
+
+func (e *DockerExecutor) executionTimeout(task *models.Task) time.Duration {
+	if limit := task.MaxDuration(); limit > 0 {
+		return limit
+	}
+	return e.config.ExecutionTimeout
+}
+
+func applyContainerMetrics(result *models.TaskResult, metrics ContainerMetrics) {
+	result.CPUSeconds = metrics.CPUSeconds
+	result.EstimatedCycles = metrics.EstimatedCycles
+	result.MemoryGBHours = metrics.MemoryGBHours
+	result.PeakMemoryGB = metrics.PeakMemoryGB
+	result.StorageGB = metrics.StorageGB
+	result.NetworkDataGB = metrics.NetworkDataGB
+}
+
 func executionDurationMilliseconds(duration time.Duration) int64 {
 	if duration <= 0 {
 		return 0
@@ -424,3 +1017,28 @@ func executionDurationMilliseconds(duration time.Duration) int64 {
 
 	return milliseconds
 }
+
+// writeEnvFile writes NAME=value pairs to a file only the runner can read, in
+// the format of docker's --env-file
+func writeEnvFile(env []string) (string, error) {
+	for _, variable := range env {
+		if strings.ContainsAny(variable, "\r\n") {
+			name, _, _ := strings.Cut(variable, "=")
+			return "", fmt.Errorf("value of %s spans several lines", name)
+		}
+	}
+
+	file, err := os.CreateTemp("", "parity-env-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create env file: %w", err)
+	}
+	_, err = file.WriteString(strings.Join(env, "\n") + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write env file: %w", err)
+	}
+	return file.Name(), nil
+}
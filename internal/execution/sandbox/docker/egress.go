@@ -0,0 +1,492 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker/executils"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	egressChain         = "DOCKER-USER"
+	egressAuditInterval = 2 * time.Second
+	// dnsPort is dropped for both UDP and TCP when a task disables DNS
+	dnsPort = 53
+)
+
+// egressRule matches a destination network and, when port is set, a single port
+type egressRule struct {
+	network *net.IPNet
+	port    int
+}
+
+func (r egressRule) matches(ip net.IP, port int) bool {
+	if !r.network.Contains(ip) {
+		return false
+	}
+	return r.port == 0 || r.port == port
+}
+
+// parseEgressEntry turns "ip", "cidr" or "host" with an optional ":port" into rules.
+// Hostnames are resolved once, when the task starts.
+func parseEgressEntry(entry string) ([]egressRule, error) {
+	host := strings.TrimSpace(entry)
+	port := 0
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		parsed, err := strconv.Atoi(p)
+		if err != nil || parsed <= 0 || parsed > 65535 {
+			return nil, fmt.Errorf("invalid port in egress entry %q", entry)
+		}
+		host, port = h, parsed
+	}
+	if host == "" {
+		return nil, fmt.Errorf("empty egress entry")
+	}
+
+	if _, network, err := net.ParseCIDR(host); err == nil {
+		return []egressRule{{network: network, port: port}}, nil
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		resolved, err := net.LookupIP(host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve egress host %q: %w", host, err)
+		}
+		ips = resolved
+	}
+
+	rules := make([]egressRule, 0, len(ips))
+	for _, ip := range ips {
+		// Rules are enforced through iptables, so only IPv4 destinations apply
+		v4 := ip.To4()
+		if v4 == nil {
+			continue
+		}
+		rules = append(rules, egressRule{network: &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}, port: port})
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("egress entry %q has no IPv4 address", entry)
+	}
+	return rules, nil
+}
+
+func parseEgressRules(entries []string) ([]egressRule, error) {
+	var rules []egressRule
+	for _, entry := range entries {
+		parsed, err := parseEgressEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parsed...)
+	}
+	return rules, nil
+}
+
+type egressFlow struct {
+	protocol string
+	source   string
+	sport    int
+	dest     string
+	dport    int
+}
+
+// parseConntrackLine reads the original direction of a `conntrack -L` entry
+func parseConntrackLine(line string) (egressFlow, bool) {
+	var flow egressFlow
+	for _, field := range strings.Fields(line) {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			if flow.protocol == "" && (field == "tcp" || field == "udp" || field == "icmp" || field == "sctp") {
+				flow.protocol = field
+			}
+			continue
+		}
+		// The reply direction repeats every key; only the first occurrence is the original
+		switch key {
+		case "src":
+			if flow.source == "" {
+				flow.source = value
+			}
+		case "dst":
+			if flow.dest == "" {
+				flow.dest = value
+			}
+		case "sport":
+			if flow.sport == 0 {
+				flow.sport, _ = strconv.Atoi(value)
+			}
+		case "dport":
+			if flow.dport == 0 {
+				flow.dport, _ = strconv.Atoi(value)
+			}
+		}
+	}
+	return flow, flow.protocol != "" && flow.source != "" && flow.dest != ""
+}
+
+type egressKey struct {
+	protocol string
+	dest     string
+	port     int
+}
+
+// EgressGuard isolates a task on its own docker network, enforces the task's
+// egress policy with iptables and audits outbound connections: established
+// ones through conntrack and blocked attempts through NFLOG
+type EgressGuard struct {
+	engine   Engine
+	taskID   string
+	network  string
+	subnet   *net.IPNet
+	allow    []egressRule
+	deny     []egressRule
+	rules    [][]string
+	enforced bool
+	log      *egressLog
+	// blockDNS drops DNS queries to any resolver, for tasks with DNS disabled
+	blockDNS bool
+
+	mu        sync.Mutex
+	seen      map[egressFlow]struct{}
+	counts    map[egressKey]int
+	conntrack bool
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// PrepareEgress creates the task network and installs the policy rules. With
+// blockDNS, DNS queries are dropped whatever resolver they are sent to. When
+// the rules cannot be enforced the task is refused. The policy may be nil.
+func PrepareEgress(ctx context.Context, engine Engine, taskID string, policy *models.EgressPolicy, blockDNS bool) (*EgressGuard, error) {
+	if !engine.Firewall {
+		if policy == nil {
+			return nil, fmt.Errorf("disabling DNS is not supported with %s", engine.Command)
+		}
+		return nil, fmt.Errorf("egress policies are not supported with %s", engine.Command)
+	}
+	if policy == nil {
+		policy = &models.EgressPolicy{}
+	}
+	enforce := policy.HasRules() || blockDNS
+
+	allow, err := parseEgressRules(policy.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parseEgressRules(policy.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	if enforce {
+		if _, err := exec.LookPath("iptables"); err != nil {
+			return nil, fmt.Errorf("egress rules require iptables on the runner host")
+		}
+	}
+
+	g := &EgressGuard{
+		engine:   engine,
+		taskID:   taskID,
+		network:  utils.InstanceScoped("parity-egress-" + taskID),
+		allow:    allow,
+		deny:     deny,
+		blockDNS: blockDNS,
+		seen:     make(map[egressFlow]struct{}),
+		counts:   make(map[egressKey]int),
+	}
+
+	networkArgs := append([]string{"network", "create", "--driver", "bridge"}, utils.LabelArgs(utils.ResourceLabels(taskID))...)
+	if _, err := g.engine.run(ctx, append(networkArgs, g.network)...); err != nil {
+		return nil, fmt.Errorf("failed to create task network: %w", err)
+	}
+
+	output, err := g.engine.run(ctx, "network", "inspect", "-f", "{{range .IPAM.Config}}{{.Subnet}} {{end}}", g.network)
+	if err != nil {
+		g.Close(context.Background())
+		return nil, fmt.Errorf("failed to inspect task network: %w", err)
+	}
+	for _, cidr := range strings.Fields(string(output)) {
+		if _, subnet, err := net.ParseCIDR(cidr); err == nil && subnet.IP.To4() != nil {
+			g.subnet = subnet
+			break
+		}
+	}
+	if g.subnet == nil {
+		g.Close(context.Background())
+		return nil, fmt.Errorf("task network %s has no IPv4 subnet", g.network)
+	}
+
+	if enforce {
+		if g.log, err = openEgressLog(taskID); err != nil {
+			log := gologger.WithComponent("docker.egress")
+			log.Warn().Err(err).Str("task_id", taskID).Msg("Blocked egress attempts are not audited")
+		}
+		g.rules = g.ruleSpecs()
+		for i, spec := range g.rules {
+			args := append([]string{"-I", egressChain, strconv.Itoa(i + 1)}, spec...)
+			if _, err := executils.ExecCommand(ctx, "iptables", args...); err != nil {
+				g.rules = g.rules[:i]
+				g.Close(context.Background())
+				return nil, fmt.Errorf("failed to install egress rule: %w", err)
+			}
+		}
+		g.enforced = true
+	}
+
+	return g, nil
+}
+
+// ruleSpecs lists the iptables rules in evaluation order: DNS drops when DNS
+// is blocked, then denies, then allows, then a final drop when an allow list is
+// set. With an NFLOG group, each drop is preceded by the same match copying the
+// packet to the group.
+func (g *EgressGuard) ruleSpecs() [][]string {
+	comment := []string{"-m", "comment", "--comment", "parity-egress-" + g.taskID}
+	source := []string{"-s", g.subnet.String()}
+
+	withTarget := func(match []string, target string) [][]string {
+		match = append(append([]string{}, match...), comment...)
+		spec := append(append([]string{}, match...), "-j", target)
+		if target != "DROP" || g.log == nil {
+			return [][]string{spec}
+		}
+		return [][]string{append(append([]string{}, match...), egressLogArgs(g.log.group, g.taskID)...), spec}
+	}
+	ruleArgs := func(rule egressRule, target string) [][]string {
+		base := append(append([]string{}, source...), "-d", rule.network.String())
+		if rule.port == 0 {
+			return withTarget(base, target)
+		}
+		var specs [][]string
+		for _, proto := range []string{"tcp", "udp"} {
+			spec := append(append([]string{}, base...), "-p", proto, "--dport", strconv.Itoa(rule.port))
+			specs = append(specs, withTarget(spec, target)...)
+		}
+		return specs
+	}
+
+	var specs [][]string
+	if g.blockDNS {
+		for _, proto := range []string{"udp", "tcp"} {
+			spec := append(append([]string{}, source...), "-p", proto, "--dport", strconv.Itoa(dnsPort))
+			specs = append(specs, withTarget(spec, "DROP")...)
+		}
+	}
+	for _, rule := range g.deny {
+		specs = append(specs, ruleArgs(rule, "DROP")...)
+	}
+	if len(g.allow) > 0 {
+		for _, rule := range g.allow {
+			specs = append(specs, ruleArgs(rule, "ACCEPT")...)
+		}
+		specs = append(specs, withTarget(source, "DROP")...)
+	}
+	return specs
+}
+
+// Allows reports whether the policy lets the task reach ip:port
+func (g *EgressGuard) Allows(ip net.IP, port int) bool {
+	if g.blockDNS && port == dnsPort {
+		return false
+	}
+	for _, rule := range g.deny {
+		if rule.matches(ip, port) {
+			return false
+		}
+	}
+	if len(g.allow) == 0 {
+		return true
+	}
+	for _, rule := range g.allow {
+		if rule.matches(ip, port) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *EgressGuard) ContainerOptions() []ContainerOption {
+	return []ContainerOption{WithNetwork(g.network)}
+}
+
+// StartAudit polls conntrack for connections leaving the task network and
+// reads the attempts the policy rules dropped
+func (g *EgressGuard) StartAudit() {
+	log := gologger.WithComponent("docker.egress")
+	g.stopCh = make(chan struct{})
+
+	if _, err := exec.LookPath("conntrack"); err != nil {
+		log.Warn().Str("task_id", g.taskID).Msg("conntrack not installed, established egress connections are not audited")
+	} else {
+		g.conntrack = true
+		g.wg.Add(1)
+		go func() {
+			defer g.wg.Done()
+			ticker := time.NewTicker(egressAuditInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					g.poll()
+				case <-g.stopCh:
+					return
+				}
+			}
+		}()
+	}
+
+	if g.log != nil {
+		g.wg.Add(1)
+		go func() {
+			defer g.wg.Done()
+			for {
+				select {
+				case <-g.stopCh:
+					return
+				default:
+				}
+				flows, err := g.log.read()
+				if errors.Is(err, errEgressLogOverrun) {
+					log.Warn().Str("task_id", g.taskID).Msg("Egress log overran, some blocked attempts were not recorded")
+					continue
+				}
+				if err != nil {
+					log.Warn().Err(err).Str("task_id", g.taskID).Msg("Failed to read egress log, blocked attempts are no longer audited")
+					return
+				}
+				g.recordFlows(flows)
+			}
+		}()
+	}
+}
+
+func (g *EgressGuard) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	output, err := executils.ExecCommand(ctx, "conntrack", "-L", "-f", "ipv4")
+	if err != nil {
+		log := gologger.WithComponent("docker.egress")
+		log.Debug().Err(err).Str("task_id", g.taskID).Msg("Failed to read conntrack table")
+		return
+	}
+	g.record(string(output))
+}
+
+func (g *EgressGuard) record(table string) {
+	var flows []egressFlow
+	scanner := bufio.NewScanner(strings.NewReader(table))
+	for scanner.Scan() {
+		if flow, ok := parseConntrackLine(scanner.Text()); ok {
+			flows = append(flows, flow)
+		}
+	}
+	g.recordFlows(flows)
+}
+
+// recordFlows counts each flow leaving the task network once, however many
+// times conntrack lists it or the drop rules log its retransmissions
+func (g *EgressGuard) recordFlows(flows []egressFlow) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, flow := range flows {
+		src, dst := net.ParseIP(flow.source), net.ParseIP(flow.dest)
+		if src == nil || dst == nil || !g.subnet.Contains(src) || g.subnet.Contains(dst) {
+			continue
+		}
+		if _, dup := g.seen[flow]; dup {
+			continue
+		}
+		g.seen[flow] = struct{}{}
+		g.counts[egressKey{protocol: flow.protocol, dest: flow.dest, port: flow.dport}]++
+	}
+}
+
+// Summary stops the audit and returns every destination the task tried to reach
+func (g *EgressGuard) Summary() *models.EgressSummary {
+	if g.stopCh != nil {
+		close(g.stopCh)
+		g.wg.Wait()
+		g.stopCh = nil
+		if g.conntrack {
+			g.poll()
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	summary := &models.EgressSummary{
+		Enforced:    g.enforced,
+		Connections: make([]models.EgressConnection, 0, len(g.counts)),
+	}
+	for key, count := range g.counts {
+		allowed := g.Allows(net.ParseIP(key.dest), key.port)
+		summary.Connections = append(summary.Connections, models.EgressConnection{
+			Protocol:    key.protocol,
+			Destination: key.dest,
+			Port:        key.port,
+			Count:       count,
+			Allowed:     allowed,
+		})
+		summary.Total += count
+		if !allowed {
+			summary.Blocked += count
+		}
+	}
+	sort.Slice(summary.Connections, func(i, j int) bool {
+		a, b := summary.Connections[i], summary.Connections[j]
+		if a.Destination != b.Destination {
+			return a.Destination < b.Destination
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Protocol < b.Protocol
+	})
+	return summary
+}
+
+// Close removes the policy rules and the task network. The container must be
+// removed first or docker refuses to delete the network.
+func (g *EgressGuard) Close(ctx context.Context) {
+	log := gologger.WithComponent("docker.egress")
+	if g.stopCh != nil {
+		close(g.stopCh)
+		g.wg.Wait()
+		g.stopCh = nil
+	}
+	for _, spec := range g.rules {
+		args := append([]string{"-D", egressChain}, spec...)
+		if _, err := executils.ExecCommand(ctx, "iptables", args...); err != nil {
+			log.Warn().Err(err).Str("task_id", g.taskID).Msg("Failed to remove egress rule")
+		}
+	}
+	g.rules = nil
+	if g.log != nil {
+		g.log.Close()
+		g.log = nil
+	}
+
+	if _, err := g.engine.run(ctx, "network", "rm", g.network); err != nil {
+		log.Debug().Err(err).Str("network", g.network).Msg("Failed to remove task network")
+	}
+}
@@ -0,0 +1,135 @@
+package docker
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"net"
+	"strconv"
+)
+
+// Packets dropped in DOCKER-USER never get a confirmed conntrack entry, so
+// blocked attempts are copied to an NFLOG group before each drop rule and read
+// back over netlink. See linux/netfilter/nfnetlink_log.h for the layout.
+const (
+	nfnlSubsysULOG   = 4
+	nfulnlMsgPacket  = 0
+	nfulnlMsgConfig  = 1
+	nfulaPayload     = 9
+	nfulaCfgCmd      = 1
+	nfulaCfgMode     = 2
+	nfulnlCfgCmdBind = 1
+	nfulnlCopyPacket = 2
+
+	nlmsgHeaderLen = 16
+	nfgenHeaderLen = 4
+	nlattrTypeMask = 0x3fff
+
+	// egressLogCopyRange is enough of each packet for the IPv4 and port headers
+	egressLogCopyRange = 128
+
+	// Tasks take an NFLOG group in this range, starting from a hash of the
+	// task ID and moving on when another task on the host holds it
+	egressLogGroupBase  = 0x7000
+	egressLogGroupSpan  = 0x1000
+	egressLogGroupTries = 16
+)
+
+// errEgressLogOverrun reports that the kernel dropped logged packets because
+// the socket buffer was full
+var errEgressLogOverrun = errors.New("egress log overrun")
+
+// egressLogGroups lists the NFLOG groups to try for a task, in order
+func egressLogGroups(taskID string) []uint16 {
+	h := fnv.New32a()
+	h.Write([]byte(taskID))
+	start := h.Sum32() % egressLogGroupSpan
+
+	groups := make([]uint16, egressLogGroupTries)
+	for i := range groups {
+		groups[i] = uint16(egressLogGroupBase + (start+uint32(i))%egressLogGroupSpan)
+	}
+	return groups
+}
+
+// egressLogArgs is the iptables target that copies a packet to the task's group
+func egressLogArgs(group uint16, taskID string) []string {
+	return []string{"-j", "NFLOG", "--nflog-group", strconv.Itoa(int(group)), "--nflog-prefix", "parity-egress-" + taskID}
+}
+
+// parseNflogMessages returns the flows of the NFLOG packet messages in a
+// netlink read. Other messages, and packets that are not IPv4, are skipped.
+func parseNflogMessages(buf []byte) []egressFlow {
+	var flows []egressFlow
+	for len(buf) >= nlmsgHeaderLen {
+		length := int(binary.NativeEndian.Uint32(buf[0:4]))
+		if length < nlmsgHeaderLen || length > len(buf) {
+			break
+		}
+		msgType := binary.NativeEndian.Uint16(buf[4:6])
+		if msgType == nfnlSubsysULOG<<8|nfulnlMsgPacket && length >= nlmsgHeaderLen+nfgenHeaderLen {
+			if payload := nflogPayload(buf[nlmsgHeaderLen+nfgenHeaderLen : length]); payload != nil {
+				if flow, ok := parseIPv4Flow(payload); ok {
+					flows = append(flows, flow)
+				}
+			}
+		}
+		buf = buf[nlmsgAlign(length):]
+	}
+	return flows
+}
+
+// nflogPayload finds the packet among a message's attributes
+func nflogPayload(attrs []byte) []byte {
+	for len(attrs) >= 4 {
+		length := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		if length < 4 || length > len(attrs) {
+			return nil
+		}
+		if binary.NativeEndian.Uint16(attrs[2:4])&nlattrTypeMask == nfulaPayload {
+			return attrs[4:length]
+		}
+		if nlmsgAlign(length) >= len(attrs) {
+			return nil
+		}
+		attrs = attrs[nlmsgAlign(length):]
+	}
+	return nil
+}
+
+// parseIPv4Flow reads the addresses and ports of a raw IPv4 packet
+func parseIPv4Flow(packet []byte) (egressFlow, bool) {
+	if len(packet) < 20 || packet[0]>>4 != 4 {
+		return egressFlow{}, false
+	}
+	headerLen := int(packet[0]&0x0f) * 4
+	if headerLen < 20 || headerLen > len(packet) {
+		return egressFlow{}, false
+	}
+
+	flow := egressFlow{
+		source: net.IP(packet[12:16]).String(),
+		dest:   net.IP(packet[16:20]).String(),
+	}
+	switch packet[9] {
+	case 1:
+		flow.protocol = "icmp"
+	case 6:
+		flow.protocol = "tcp"
+	case 17:
+		flow.protocol = "udp"
+	case 132:
+		flow.protocol = "sctp"
+	default:
+		flow.protocol = strconv.Itoa(int(packet[9]))
+	}
+	if flow.protocol != "icmp" && len(packet) >= headerLen+4 {
+		flow.sport = int(binary.BigEndian.Uint16(packet[headerLen : headerLen+2]))
+		flow.dport = int(binary.BigEndian.Uint16(packet[headerLen+2 : headerLen+4]))
+	}
+	return flow, true
+}
+
+func nlmsgAlign(n int) int {
+	return (n + 3) &^ 3
+}
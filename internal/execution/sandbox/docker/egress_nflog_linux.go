@@ -0,0 +1,117 @@
+//go:build linux
+
+package docker
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// egressLog receives the packets the task's drop rules copy to its NFLOG group
+type egressLog struct {
+	fd    int
+	group uint16
+	buf   []byte
+}
+
+// openEgressLog binds the first free NFLOG group for the task
+func openEgressLog(taskID string) (*egressLog, error) {
+	var lastErr error
+	for _, group := range egressLogGroups(taskID) {
+		l, err := bindEgressLog(group)
+		if err == nil {
+			return l, nil
+		}
+		// The kernel answers EPERM for a group bound by another socket
+		if !errors.Is(err, unix.EBUSY) && !errors.Is(err, unix.EPERM) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("no free NFLOG group: %w", lastErr)
+}
+
+func bindEgressLog(group uint16) (*egressLog, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_NETFILTER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netfilter socket: %w", err)
+	}
+	l := &egressLog{fd: fd, group: group, buf: make([]byte, 64*1024)}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to bind netfilter socket: %w", err)
+	}
+
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode, egressLogCopyRange)
+	mode[4] = nfulnlCopyPacket
+	if err := l.configure(1, nfulaCfgCmd, []byte{nfulnlCfgCmdBind}); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err := l.configure(2, nfulaCfgMode, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	// Reads wake up regularly so the audit can stop
+	timeout := unix.NsecToTimeval(int64(500 * time.Millisecond))
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to set netfilter socket timeout: %w", err)
+	}
+	return l, nil
+}
+
+// configure sends one NFLOG config attribute for the group and waits for the ack
+func (l *egressLog) configure(seq uint32, attrType uint16, value []byte) error {
+	attrLen := 4 + len(value)
+	msg := make([]byte, nlmsgHeaderLen+nfgenHeaderLen+nlmsgAlign(attrLen))
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	binary.NativeEndian.PutUint16(msg[4:6], nfnlSubsysULOG<<8|nfulnlMsgConfig)
+	binary.NativeEndian.PutUint16(msg[6:8], unix.NLM_F_REQUEST|unix.NLM_F_ACK)
+	binary.NativeEndian.PutUint32(msg[8:12], seq)
+	msg[16] = unix.AF_UNSPEC
+	binary.BigEndian.PutUint16(msg[18:20], l.group)
+	binary.NativeEndian.PutUint16(msg[20:22], uint16(attrLen))
+	binary.NativeEndian.PutUint16(msg[22:24], attrType)
+	copy(msg[24:], value)
+
+	if err := unix.Sendto(l.fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("failed to configure NFLOG group %d: %w", l.group, err)
+	}
+	n, _, err := unix.Recvfrom(l.fd, l.buf, 0)
+	if err != nil {
+		return fmt.Errorf("failed to configure NFLOG group %d: %w", l.group, err)
+	}
+	if n < nlmsgHeaderLen+4 || binary.NativeEndian.Uint16(l.buf[4:6]) != unix.NLMSG_ERROR {
+		return fmt.Errorf("unexpected reply configuring NFLOG group %d", l.group)
+	}
+	if errno := int32(binary.NativeEndian.Uint32(l.buf[16:20])); errno != 0 {
+		return fmt.Errorf("failed to configure NFLOG group %d: %w", l.group, unix.Errno(-errno))
+	}
+	return nil
+}
+
+// read waits briefly for logged packets. It returns nothing when none arrived.
+func (l *egressLog) read() ([]egressFlow, error) {
+	n, _, err := unix.Recvfrom(l.fd, l.buf, 0)
+	if err != nil {
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			return nil, nil
+		}
+		if errors.Is(err, unix.ENOBUFS) {
+			return nil, errEgressLogOverrun
+		}
+		return nil, err
+	}
+	return parseNflogMessages(l.buf[:n]), nil
+}
+
+func (l *egressLog) Close() error {
+	return unix.Close(l.fd)
+}
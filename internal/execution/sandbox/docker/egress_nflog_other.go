@@ -0,0 +1,22 @@
+//go:build !linux
+
+package docker
+
+import "errors"
+
+// egressLog needs netfilter, so blocked attempts are only audited on Linux
+type egressLog struct {
+	group uint16
+}
+
+func openEgressLog(string) (*egressLog, error) {
+	return nil, errors.New("NFLOG is only available on Linux")
+}
+
+func (l *egressLog) read() ([]egressFlow, error) {
+	return nil, nil
+}
+
+func (l *egressLog) Close() error {
+	return nil
+}
@@ -0,0 +1,209 @@
+package docker
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+func newTestEgressGuard(t *testing.T, allow, deny []string) *EgressGuard {
+	t.Helper()
+
+	allowRules, err := parseEgressRules(allow)
+	if err != nil {
+		t.Fatalf("parseEgressRules(allow) error = %v", err)
+	}
+	denyRules, err := parseEgressRules(deny)
+	if err != nil {
+		t.Fatalf("parseEgressRules(deny) error = %v", err)
+	}
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/16")
+
+	return &EgressGuard{
+		taskID: "task-1",
+		subnet: subnet,
+		allow:  allowRules,
+		deny:   denyRules,
+		seen:   make(map[egressFlow]struct{}),
+		counts: make(map[egressKey]int),
+	}
+}
+
+func TestParseEgressEntry(t *testing.T) {
+	rules, err := parseEgressEntry("10.0.0.0/8:443")
+	if err != nil || len(rules) != 1 || rules[0].network.String() != "10.0.0.0/8" || rules[0].port != 443 {
+		t.Fatalf("parseEgressEntry(cidr:port) = %+v, %v", rules, err)
+	}
+
+	rules, err = parseEgressEntry("1.2.3.4")
+	if err != nil || len(rules) != 1 || rules[0].network.String() != "1.2.3.4/32" || rules[0].port != 0 {
+		t.Fatalf("parseEgressEntry(ip) = %+v, %v", rules, err)
+	}
+
+	if _, err := parseEgressEntry("1.2.3.4:99999"); err == nil {
+		t.Fatal("expected an error for an out of range port")
+	}
+}
+
+func TestEgressGuardAllows(t *testing.T) {
+	g := newTestEgressGuard(t, []string{"10.0.0.0/8", "1.1.1.1:53"}, []string{"10.9.0.0/16"})
+
+	cases := []struct {
+		ip   string
+		port int
+		want bool
+	}{
+		{"10.1.2.3", 443, true},
+		{"10.9.1.1", 443, false},
+		{"1.1.1.1", 53, true},
+		{"1.1.1.1", 443, false},
+		{"8.8.8.8", 53, false},
+	}
+	for _, tc := range cases {
+		if got := g.Allows(net.ParseIP(tc.ip), tc.port); got != tc.want {
+			t.Errorf("Allows(%s, %d) = %v, want %v", tc.ip, tc.port, got, tc.want)
+		}
+	}
+
+	open := newTestEgressGuard(t, nil, []string{"8.8.8.8"})
+	if !open.Allows(net.ParseIP("1.1.1.1"), 443) || open.Allows(net.ParseIP("8.8.8.8"), 53) {
+		t.Fatal("deny-only policy should allow everything except denied destinations")
+	}
+}
+
+func TestEgressRuleSpecsEndWithDrop(t *testing.T) {
+	g := newTestEgressGuard(t, []string{"1.1.1.1:53"}, []string{"8.8.8.8"})
+
+	specs := g.ruleSpecs()
+	if len(specs) != 4 {
+		t.Fatalf("len(ruleSpecs()) = %d, want 4 (deny, tcp+udp allow, drop)", len(specs))
+	}
+	if first := strings.Join(specs[0], " "); !strings.Contains(first, "-d 8.8.8.8/32") || !strings.HasSuffix(first, "-j DROP") {
+		t.Fatalf("first rule = %q, want the deny rule", first)
+	}
+	if last := strings.Join(specs[3], " "); strings.Contains(last, "-d ") || !strings.HasSuffix(last, "-j DROP") {
+		t.Fatalf("last rule = %q, want a catch-all drop", last)
+	}
+}
+
+func TestEgressRuleSpecsBlockDNS(t *testing.T) {
+	g := newTestEgressGuard(t, []string{"8.8.8.8"}, nil)
+	g.blockDNS = true
+
+	specs := g.ruleSpecs()
+	want := []string{
+		"-s 172.30.0.0/16 -p udp --dport 53 -m comment --comment parity-egress-task-1 -j DROP",
+		"-s 172.30.0.0/16 -p tcp --dport 53 -m comment --comment parity-egress-task-1 -j DROP",
+	}
+	if len(specs) != 4 {
+		t.Fatalf("len(ruleSpecs()) = %d, want 4 (udp and tcp DNS drops, allow, drop)", len(specs))
+	}
+	for i, rule := range want {
+		if got := strings.Join(specs[i], " "); got != rule {
+			t.Fatalf("rule %d = %q, want %q ahead of the allow list", i, got, rule)
+		}
+	}
+	if g.Allows(net.ParseIP("8.8.8.8"), 53) || !g.Allows(net.ParseIP("8.8.8.8"), 443) {
+		t.Fatal("an allowed resolver should still not be reachable on the DNS port")
+	}
+
+	// Without a policy only the DNS port is dropped, and drops are logged
+	open := newTestEgressGuard(t, nil, nil)
+	open.blockDNS = true
+	open.log = &egressLog{group: 0x7001}
+	specs = open.ruleSpecs()
+	if len(specs) != 4 || !strings.Contains(strings.Join(specs[0], " "), "-p udp --dport 53") || !strings.HasSuffix(strings.Join(specs[1], " "), "-j DROP") {
+		t.Fatalf("ruleSpecs() = %q, want logged udp and tcp DNS drops", specs)
+	}
+	if !open.Allows(net.ParseIP("1.1.1.1"), 443) {
+		t.Fatal("blocking DNS should not block other traffic")
+	}
+}
+
+func TestEgressSummaryFromConntrack(t *testing.T) {
+	g := newTestEgressGuard(t, []string{"93.184.216.34:443"}, nil)
+
+	table := strings.Join([]string{
+		"tcp      6 431999 ESTABLISHED src=172.30.0.2 dst=93.184.216.34 sport=40000 dport=443 src=93.184.216.34 dst=172.30.0.2 sport=443 dport=40000 [ASSURED] mark=0 use=1",
+		"tcp      6 119 SYN_SENT src=172.30.0.2 dst=203.0.113.9 sport=40001 dport=22 [UNREPLIED] src=203.0.113.9 dst=172.30.0.2 sport=22 dport=40001 mark=0 use=1",
+		"udp      17 29 src=172.17.0.5 dst=8.8.8.8 sport=5353 dport=53 src=8.8.8.8 dst=172.17.0.5 sport=53 dport=5353 mark=0 use=1",
+	}, "\n")
+	g.record(table)
+	// The same flow seen on a later poll is not counted twice
+	g.record(table)
+
+	summary := g.Summary()
+	if summary.Total != 2 || summary.Blocked != 1 || len(summary.Connections) != 2 {
+		t.Fatalf("Summary() = %+v, want 2 connections with 1 blocked", summary)
+	}
+	if c := summary.Connections[0]; c.Destination != "203.0.113.9" || c.Port != 22 || c.Allowed {
+		t.Fatalf("Connections[0] = %+v, want the blocked ssh attempt", c)
+	}
+	if c := summary.Connections[1]; c.Destination != "93.184.216.34" || !c.Allowed {
+		t.Fatalf("Connections[1] = %+v, want the allowed https connection", c)
+	}
+}
+
+func TestEgressRuleSpecsLogDrops(t *testing.T) {
+	g := newTestEgressGuard(t, []string{"1.1.1.1"}, []string{"8.8.8.8"})
+	g.log = &egressLog{group: 0x7001}
+
+	specs := g.ruleSpecs()
+	if len(specs) != 5 {
+		t.Fatalf("len(ruleSpecs()) = %d, want 5 (logged deny, allow, logged drop)", len(specs))
+	}
+	for _, i := range []int{0, 3} {
+		logged, dropped := strings.Join(specs[i], " "), strings.Join(specs[i+1], " ")
+		if !strings.HasSuffix(logged, "-j NFLOG --nflog-group 28673 --nflog-prefix parity-egress-task-1") {
+			t.Fatalf("rule %d = %q, want the NFLOG copy", i, logged)
+		}
+		if strings.TrimSuffix(dropped, " -j DROP") != strings.TrimSuffix(logged, " -j NFLOG --nflog-group 28673 --nflog-prefix parity-egress-task-1") {
+			t.Fatalf("rule %d = %q, want the drop with the same match as %q", i+1, dropped, logged)
+		}
+	}
+}
+
+// nflogPacket is an NFLOG packet message for a TCP SYN from src to dst
+func nflogPacket(src, dst string, sport, dport uint16) []byte {
+	packet := make([]byte, 24)
+	packet[0] = 0x45
+	packet[9] = 6
+	copy(packet[12:16], net.ParseIP(src).To4())
+	copy(packet[16:20], net.ParseIP(dst).To4())
+	binary.BigEndian.PutUint16(packet[20:22], sport)
+	binary.BigEndian.PutUint16(packet[22:24], dport)
+
+	attr := func(attrType uint16, value []byte) []byte {
+		b := make([]byte, nlmsgAlign(4+len(value)))
+		binary.NativeEndian.PutUint16(b[0:2], uint16(4+len(value)))
+		binary.NativeEndian.PutUint16(b[2:4], attrType)
+		copy(b[4:], value)
+		return b
+	}
+	body := append(attr(10, []byte("parity-egress-task-1\x00")), attr(nfulaPayload, packet)...)
+
+	msg := make([]byte, nlmsgHeaderLen+nfgenHeaderLen, nlmsgHeaderLen+nfgenHeaderLen+len(body))
+	msg = append(msg, body...)
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	binary.NativeEndian.PutUint16(msg[4:6], nfnlSubsysULOG<<8|nfulnlMsgPacket)
+	return msg
+}
+
+func TestEgressSummaryRecordsBlockedAttempts(t *testing.T) {
+	g := newTestEgressGuard(t, []string{"93.184.216.34:443"}, nil)
+
+	// The drop rules log the SYN and its retransmission; the attempt never
+	// reaches the conntrack table
+	syn := nflogPacket("172.30.0.2", "203.0.113.9", 40001, 22)
+	g.recordFlows(parseNflogMessages(append(append([]byte{}, syn...), syn...)))
+	g.record("tcp      6 431999 ESTABLISHED src=172.30.0.2 dst=93.184.216.34 sport=40000 dport=443 src=93.184.216.34 dst=172.30.0.2 sport=443 dport=40000 [ASSURED] mark=0 use=1")
+
+	summary := g.Summary()
+	if summary.Total != 2 || summary.Blocked != 1 {
+		t.Fatalf("Summary() = %+v, want 2 connections with 1 blocked", summary)
+	}
+	if c := summary.Connections[0]; c.Protocol != "tcp" || c.Destination != "203.0.113.9" || c.Port != 22 || c.Count != 1 || c.Allowed {
+		t.Fatalf("Connections[0] = %+v, want the blocked ssh attempt once", c)
+	}
+}
@@ -0,0 +1,210 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker/executils"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// Engine is a container CLI that takes the docker command line, such as docker
+// itself or podman. The flags record the features that are Docker specific.
+type Engine struct {
+	// Command is the CLI binary
+	Command string
+	// CRIU is set when containers can be checkpointed with `checkpoint create`
+	// and restored with `start --checkpoint`
+	CRIU bool
+	// Firewall is set when task networks are host bridges that egress rules can
+	// filter in the DOCKER-USER iptables chain
+	Firewall bool
+	// Buildx is set when task images can be rebuilt for build verification
+	Buildx bool
+	// CDI passes GPUs as CDI devices instead of with --gpus
+	CDI bool
+	// Host is the daemon the CLI talks to, the local one when unset
+	Host RemoteHost
+}
+
+// DockerEngine runs tasks with the docker CLI
+var DockerEngine = Engine{Command: "docker", CRIU: true, Firewall: true, Buildx: true}
+
+func (e Engine) run(ctx context.Context, args ...string) ([]byte, error) {
+	return executils.ExecCommand(ctx, e.Command, append(e.Host.args(), args...)...)
+}
+
+// command is the engine command of run, for callers that read its output
+// while it runs
+func (e Engine) command(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, e.Command, append(e.Host.args(), args...)...)
+}
+
+// runWithInput is run with stdin read from input
+func (e Engine) runWithInput(ctx context.Context, input io.Reader, args ...string) ([]byte, error) {
+	return executils.ExecCommandWithInput(ctx, input, e.Command, append(e.Host.args(), args...)...)
+}
+
+// Remote reports whether containers run on another machine, where host paths
+// cannot be mounted into them
+func (e Engine) Remote() bool {
+	return e.Host.Remote()
+}
+
+// OnHost returns the engine talking to the daemon at host. Process
+// checkpoints and egress rules need the daemon's host, so remote engines go
+// without them.
+func (e Engine) OnHost(host RemoteHost) (Engine, error) {
+	if host.Address == "" {
+		return e, nil
+	}
+	if e.Command != DockerEngine.Command {
+		return e, fmt.Errorf("remote container hosts need the docker CLI, not %s", e.Command)
+	}
+	if err := host.Validate(); err != nil {
+		return e, err
+	}
+	e.Host = host
+	if host.Remote() {
+		e.CRIU = false
+		e.Firewall = false
+	}
+	return e, nil
+}
+
+// RemoteHost is a Docker daemon the runner dispatches tasks to, such as
+// tcp://gpu-box:2376 or ssh://runner@gpu-box. With TLSVerify the daemon is
+// checked against ca.pem in CertPath and the runner presents cert.pem and
+// key.pem from it.
+type RemoteHost struct {
+	Address   string
+	TLSVerify bool
+	CertPath  string
+}
+
+// HostFromEnv reads the daemon from DOCKER_HOST, DOCKER_TLS_VERIFY and
+// DOCKER_CERT_PATH, as the docker CLI does
+func HostFromEnv() RemoteHost {
+	host := RemoteHost{
+		Address:   os.Getenv("DOCKER_HOST"),
+		TLSVerify: os.Getenv("DOCKER_TLS_VERIFY") != "",
+		CertPath:  os.Getenv("DOCKER_CERT_PATH"),
+	}
+	if host.TLSVerify && host.CertPath == "" {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			host.CertPath = filepath.Join(homeDir, ".docker")
+		}
+	}
+	return host
+}
+
+// Remote reports whether the daemon is reached over the network rather than a
+// local socket
+func (h RemoteHost) Remote() bool {
+	return h.Address != "" && !strings.HasPrefix(h.Address, "unix://") && !strings.HasPrefix(h.Address, "npipe://")
+}
+
+func (h RemoteHost) Validate() error {
+	scheme, _, ok := strings.Cut(h.Address, "://")
+	if !ok {
+		return fmt.Errorf("invalid docker host %q, expected a URL such as tcp://host:2376", h.Address)
+	}
+	switch scheme {
+	case "tcp", "ssh", "unix", "npipe":
+	default:
+		return fmt.Errorf("unsupported docker host scheme %q", scheme)
+	}
+	if !h.TLSVerify {
+		if h.CertPath != "" {
+			return errors.New("docker cert path is set without tls verify")
+		}
+		return nil
+	}
+	if scheme != "tcp" {
+		return fmt.Errorf("tls verify needs a tcp docker host, not %s", scheme)
+	}
+	if h.CertPath == "" {
+		return errors.New("tls verify needs a docker cert path")
+	}
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		if _, err := os.Stat(filepath.Join(h.CertPath, name)); err != nil {
+			return fmt.Errorf("docker tls certificates incomplete: %w", err)
+		}
+	}
+	return nil
+}
+
+// args are the global CLI flags that select the daemon
+func (h RemoteHost) args() []string {
+	if h.Address == "" {
+		return nil
+	}
+	args := []string{"--host", h.Address}
+	if h.TLSVerify {
+		args = append(args,
+			"--tlsverify",
+			"--tlscacert", filepath.Join(h.CertPath, "ca.pem"),
+			"--tlscert", filepath.Join(h.CertPath, "cert.pem"),
+			"--tlskey", filepath.Join(h.CertPath, "key.pem"),
+		)
+	}
+	return args
+}
+
+// ImageID is the ID of a local image without its sha256: prefix
+func (e Engine) ImageID(ctx context.Context, image string) (string, error) {
+	output, err := e.run(ctx, "image", "inspect", "--format={{.Id}}", image)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image: %w", err)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(output)), "sha256:"), nil
+}
+
+// ImageConfig is the part of an image's configuration a task runs with when it
+// does not set its own command
+type ImageConfig struct {
+	Entrypoint []string `json:"Entrypoint"`
+	Cmd        []string `json:"Cmd"`
+	Env        []string `json:"Env"`
+	WorkingDir string   `json:"WorkingDir"`
+}
+
+// InspectImage reads the configuration of a local image
+func (e Engine) InspectImage(ctx context.Context, image string) (*ImageConfig, error) {
+	output, err := e.run(ctx, "image", "inspect", "--format={{json .Config}}", image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image: %w", err)
+	}
+	var config ImageConfig
+	if err := json.Unmarshal(output, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse image configuration: %w", err)
+	}
+	return &config, nil
+}
+
+// ExportImage writes the filesystem of a local image to path as a tar archive
+func (e Engine) ExportImage(ctx context.Context, image, path string) error {
+	// The container is never started, the entrypoint only keeps create from
+	// failing on images without a command
+	args := append([]string{"create", "--entrypoint", "/bin/true"}, utils.LabelArgs(utils.ResourceLabels(""))...)
+	output, err := e.run(ctx, append(args, image)...)
+	if err != nil {
+		return fmt.Errorf("failed to create container from image: %w", err)
+	}
+	containerID := strings.TrimSpace(string(output))
+	defer func() {
+		_, _ = e.run(context.Background(), "rm", "-f", containerID)
+	}()
+
+	if _, err := e.run(ctx, "export", "--output", path, containerID); err != nil {
+		return fmt.Errorf("failed to export image filesystem: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package docker
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOnHostPassesTLSFlags(t *testing.T) {
+	certs := t.TempDir()
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		writeTestFile(t, filepath.Join(certs, name), "pem")
+	}
+
+	engine, err := DockerEngine.OnHost(RemoteHost{Address: "tcp://gpu-box:2376", TLSVerify: true, CertPath: certs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !engine.Remote() || engine.CRIU || engine.Firewall {
+		t.Fatalf("remote engine = %+v, want checkpoints and egress rules off", engine)
+	}
+	want := []string{
+		"--host", "tcp://gpu-box:2376",
+		"--tlsverify",
+		"--tlscacert", filepath.Join(certs, "ca.pem"),
+		"--tlscert", filepath.Join(certs, "cert.pem"),
+		"--tlskey", filepath.Join(certs, "key.pem"),
+	}
+	if got := engine.Host.args(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("args() = %v, want %v", got, want)
+	}
+
+	local, err := DockerEngine.OnHost(RemoteHost{Address: "unix:///run/user/1000/docker.sock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local.Remote() || !local.CRIU {
+		t.Fatal("a local socket should keep the engine's host features")
+	}
+}
+
+func TestOnHostRejectsInvalidHosts(t *testing.T) {
+	for name, host := range map[string]RemoteHost{
+		"no scheme":         {Address: "gpu-box:2376"},
+		"unknown scheme":    {Address: "http://gpu-box:2376"},
+		"tls without certs": {Address: "tcp://gpu-box:2376", TLSVerify: true},
+		"missing certs":     {Address: "tcp://gpu-box:2376", TLSVerify: true, CertPath: t.TempDir()},
+		"tls over ssh":      {Address: "ssh://gpu-box", TLSVerify: true, CertPath: t.TempDir()},
+	} {
+		if _, err := DockerEngine.OnHost(host); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	podman := Engine{Command: "podman"}
+	if _, err := podman.OnHost(RemoteHost{Address: "tcp://gpu-box:2375"}); err == nil {
+		t.Error("expected remote hosts to need the docker CLI")
+	}
+}
@@ -3,12 +3,19 @@ package executils
 import (
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 )
 
 func ExecCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return ExecCommandWithInput(ctx, nil, name, args...)
+}
+
+// ExecCommandWithInput is ExecCommand with stdin read from input
+func ExecCommandWithInput(ctx context.Context, input io.Reader, name string, args ...string) ([]byte, error) {
 	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = input
 	output, err := cmd.CombinedOutput()
 	// If there's an error, include the command and stderr for better debugging
 	if err != nil {
@@ -0,0 +1,202 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	seccompProfilePrefix = "seccomp-profile-"
+	// legacySeccompProfileAge is how old a profile named without the PID of its
+	// runner must be before it is removed
+	legacySeccompProfileAge = 24 * time.Hour
+)
+
+// GarbageReport counts what a garbage collection removed
+type GarbageReport struct {
+	Containers      int `json:"containers"`
+	Images          int `json:"images"`
+	SeccompProfiles int `json:"seccomp_profiles"`
+}
+
+func (r GarbageReport) Empty() bool {
+	return r.Containers == 0 && r.Images == 0 && r.SeccompProfiles == 0
+}
+
+func (e *DockerExecutor) trackTask(taskID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.active == nil {
+		e.active = make(map[string]bool)
+	}
+	e.active[taskID] = true
+}
+
+func (e *DockerExecutor) untrackTask(taskID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.active, taskID)
+}
+
+func (e *DockerExecutor) running(taskID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.active[taskID]
+}
+
+// CollectGarbage reconciles the daemon with what this runner instance is doing.
+// It removes task containers of the instance no task of this executor runs in,
+// checkpoint images no saved restart metadata resumes from, and the seccomp
+// profiles of runners that are gone. Run at startup, before any task, it clears
+// out everything a crashed run left behind.
+func (e *DockerExecutor) CollectGarbage(ctx context.Context) (GarbageReport, error) {
+	var report GarbageReport
+	var errs []error
+
+	containers, err := e.removeOrphanedContainers(ctx)
+	report.Containers = containers
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	images, err := e.removeOrphanedCheckpointImages(ctx)
+	report.Images = images
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if profile := e.containerMgr.seccompProfile; profile != "" {
+		report.SeccompProfiles = removeStaleSeccompProfiles(filepath.Dir(profile), profile, time.Now())
+	}
+	return report, errors.Join(errs...)
+}
+
+func (e *DockerExecutor) removeOrphanedContainers(ctx context.Context) (int, error) {
+	log := gologger.WithComponent("docker.gc")
+
+	// Containers without a task, such as the Ollama server, are not the executor's
+	output, err := e.engine.run(ctx, "ps", "-a", "-q", "--no-trunc",
+		"--filter", "label="+utils.LabelInstance+"="+utils.InstanceLabel(),
+		"--filter", "label="+utils.LabelTaskID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list task containers: %w", err)
+	}
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	args := append([]string{"inspect", "--format", `{{.Id}} {{index .Config.Labels "` + utils.LabelTaskID + `"}}`}, ids...)
+	output, err = e.engine.run(ctx, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect task containers: %w", err)
+	}
+
+	removed := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		containerID, taskID, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if containerID == "" || e.running(taskID) {
+			continue
+		}
+		// A task that finished in between has removed its container already
+		if _, err := e.engine.run(ctx, "rm", "-f", "-v", containerID); err != nil {
+			log.Debug().Err(err).Str("container_id", containerID).Msg("Failed to remove orphaned container")
+			continue
+		}
+		log.Info().Str("container_id", containerID).Str("task_id", taskID).Msg("Removed orphaned task container")
+		removed++
+	}
+	return removed, nil
+}
+
+// removeOrphanedCheckpointImages removes the snapshots of checkpointed tasks that
+// are neither running nor resumable. An image is resumable while the restart
+// metadata of its task names it.
+func (e *DockerExecutor) removeOrphanedCheckpointImages(ctx context.Context) (int, error) {
+	log := gologger.WithComponent("docker.gc")
+
+	output, err := e.engine.run(ctx, "images", "--filter", "label="+utils.LabelInstance+"="+utils.InstanceLabel(), "--format", "{{.Repository}}:{{.Tag}}")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list checkpoint images: %w", err)
+	}
+
+	removed := 0
+	for _, image := range strings.Fields(string(output)) {
+		repository, tag, _ := strings.Cut(image, ":")
+		i := strings.LastIndex(tag, "-")
+		if path.Base(repository) != checkpointImageRepo || i < 0 {
+			continue
+		}
+		taskID := tag[:i]
+		if e.running(taskID) {
+			continue
+		}
+		meta, err := e.checkpoints.Load(taskID)
+		if err != nil {
+			continue
+		}
+		if meta != nil && strings.HasSuffix(meta.SnapshotImage, ":"+tag) {
+			continue
+		}
+		if _, err := e.engine.run(ctx, "image", "rm", image); err != nil {
+			log.Debug().Err(err).Str("image", image).Msg("Failed to remove orphaned checkpoint image")
+			continue
+		}
+		log.Info().Str("image", image).Str("task_id", taskID).Msg("Removed orphaned checkpoint image")
+		removed++
+	}
+	return removed, nil
+}
+
+// removeStaleSeccompProfiles removes the seccomp profiles in dir written by
+// runner processes that have exited. current is the profile of this runner.
+func removeStaleSeccompProfiles(dir, current string, now time.Time) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, seccompProfilePrefix) || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		profile := filepath.Join(dir, name)
+		if profile == current {
+			continue
+		}
+
+		fields := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, seccompProfilePrefix), ".json"), "-")
+		switch len(fields) {
+		case 2:
+			pid, err := strconv.Atoi(fields[0])
+			if err != nil || processAlive(pid) {
+				continue
+			}
+		case 1:
+			// Written before profiles were named after their runner
+			info, err := entry.Info()
+			if err != nil || now.Sub(info.ModTime()) < legacySeccompProfileAge {
+				continue
+			}
+		default:
+			continue
+		}
+		if err := os.Remove(profile); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,139 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// fakeEngine is a docker CLI script that lists one container and one checkpoint
+// image per task and logs every command it is run with
+func fakeEngine(t *testing.T, tasks ...string) (Engine, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake engine is a shell script")
+	}
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "commands")
+
+	var ps, inspect, images strings.Builder
+	for i, task := range tasks {
+		fmt.Fprintf(&ps, "c%d\n", i)
+		fmt.Fprintf(&inspect, "c%d %s\n", i, task)
+		fmt.Fprintf(&images, "%s:%s-1\n", checkpointImageRepo, task)
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$*" >> %q
+case "$1" in
+ps) printf '%s' ;;
+inspect) printf '%s' ;;
+images) printf '%s' ;;
+esac
+`, logPath, ps.String(), inspect.String(), images.String())
+	command := filepath.Join(dir, "docker")
+	if err := os.WriteFile(command, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return Engine{Command: command}, logPath
+}
+
+func TestCollectGarbageKeepsRunningAndResumableTasks(t *testing.T) {
+	engine, logPath := fakeEngine(t, "running", "resumable", "orphan")
+	store := NewCheckpointStore(t.TempDir())
+	store.engine = engine
+	if err := store.Save(&RestartMetadata{TaskID: "resumable", SnapshotImage: checkpointImageRepo + ":resumable-1"}); err != nil {
+		t.Fatal(err)
+	}
+	executor := &DockerExecutor{
+		engine:       engine,
+		containerMgr: &ContainerManager{engine: engine},
+		checkpoints:  store,
+	}
+	executor.trackTask("running")
+
+	report, err := executor.CollectGarbage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Containers != 2 || report.Images != 1 {
+		t.Fatalf("report = %+v, want 2 containers and 1 image", report)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var removed []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if strings.HasPrefix(line, "rm ") || strings.HasPrefix(line, "image rm ") {
+			removed = append(removed, line)
+		}
+	}
+	want := []string{"rm -f -v c1", "rm -f -v c2", "image rm " + checkpointImageRepo + ":orphan-1"}
+	if strings.Join(removed, "|") != strings.Join(want, "|") {
+		t.Fatalf("removed %q, want %q", removed, want)
+	}
+	if !strings.Contains(string(data), "--filter label="+utils.LabelInstance+"=default --filter label="+utils.LabelTaskID+"\n") {
+		t.Fatal("task containers should be listed by the instance and task labels")
+	}
+}
+
+func TestRemoveStaleSeccompProfiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("processes cannot be looked up")
+	}
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Skip("true not available")
+	}
+
+	dir := t.TempDir()
+	write := func(name string, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		modified := time.Now().Add(-age)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	current := write(fmt.Sprintf("seccomp-profile-%d-1.json", os.Getpid()), 0)
+	alive := write(fmt.Sprintf("seccomp-profile-%d-2.json", os.Getpid()), 0)
+	dead := write(fmt.Sprintf("seccomp-profile-%d-3.json", exited.Process.Pid), 0)
+	legacyOld := write("seccomp-profile-4.json", 48*time.Hour)
+	legacyNew := write("seccomp-profile-5.json", time.Hour)
+	other := write("other-4.json", 48*time.Hour)
+
+	if removed := removeStaleSeccompProfiles(dir, current, time.Now()); removed != 2 {
+		t.Fatalf("removed %d profiles, want 2", removed)
+	}
+	for path, kept := range map[string]bool{current: true, alive: true, dead: false, legacyOld: false, legacyNew: true, other: true} {
+		if _, err := os.Stat(path); (err == nil) != kept {
+			t.Errorf("%s kept = %v, want %v", filepath.Base(path), err == nil, kept)
+		}
+	}
+}
+
+func TestWithTaskLabelsContainer(t *testing.T) {
+	var options containerOptions
+	WithTask("task-1")(&options)
+
+	labels := options.labels
+	if labels[utils.LabelTaskID] != "task-1" || labels[utils.LabelInstance] != "default" || labels[utils.LabelSession] != utils.Session() {
+		t.Fatalf("labels = %v", labels)
+	}
+	got := strings.Join(options.args(), " ")
+	if !strings.Contains(got, "--label "+utils.LabelInstance+"=default --label ") || !strings.HasSuffix(got, " --label "+utils.LabelTaskID+"=task-1") {
+		t.Fatalf("args() = %q", got)
+	}
+}
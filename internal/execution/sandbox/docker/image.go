@@ -7,26 +7,51 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"strings"
 
 	"github.com/theblitlabs/gologger"
 
-	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker/executils"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
 )
 
-type ImageManager struct{}
+type ImageManager struct {
+	engine     Engine
+	policy     models.ImagePolicy
+	signatures *signatureVerifier
+}
+
+func NewImageManager(engine Engine) *ImageManager {
+	return &ImageManager{engine: engine}
+}
 
-func NewImageManager() *ImageManager {
-	return &ImageManager{}
+// SetPolicy restricts the images the manager pulls or loads
+func (im *ImageManager) SetPolicy(policy models.ImagePolicy) {
+	im.policy = policy
+}
+
+// VerifySignatures makes the manager only pull images one of signers signed. It
+// needs the cosign CLI; empty signers turn verification off.
+func (im *ImageManager) VerifySignatures(signers ImageSignatures) error {
+	if signers.IsZero() {
+		im.signatures = nil
+		return nil
+	}
+	command, err := exec.LookPath("cosign")
+	if err != nil {
+		return fmt.Errorf("cosign is required to verify image signatures: %w", err)
+	}
+	im.signatures = &signatureVerifier{command: command, signers: signers}
+	return nil
 }
 
 func (im *ImageManager) PullImage(ctx context.Context, imageName string) error {
 	log := gologger.WithComponent("docker.image")
 
 	log.Info().Str("image", imageName).Msg("Pulling image from registry")
-	if _, err := executils.ExecCommand(ctx, "docker", "pull", imageName); err != nil {
+	if _, err := im.engine.run(ctx, "pull", imageName); err != nil {
 		log.Error().Err(err).Str("image", imageName).Msg("Pull failed")
-		return fmt.Errorf("image pull failed: %w", err)
+		return fmt.Errorf("%w: %w", models.ErrImagePull, err)
 	}
 
 	return nil
@@ -98,7 +123,7 @@ func (im *ImageManager) DownloadAndLoadImage(ctx context.Context, imageURL, imag
 		}
 
 		log.Info().Str("image", imageName).Msg("Loading Docker image")
-		if _, err := executils.ExecCommand(context.Background(), "docker", "load", "-i", tmpFile.Name()); err != nil {
+		if _, err := im.engine.run(context.Background(), "load", "-i", tmpFile.Name()); err != nil {
 			log.Error().Err(err).Msg("Failed to load Docker image")
 			return fmt.Errorf("failed to load Docker image: %w", err)
 		}
@@ -149,7 +174,7 @@ func (im *ImageManager) DownloadAndLoadImage(ctx context.Context, imageURL, imag
 	}
 
 	log.Info().Str("image", imageName).Msg("Loading Docker image")
-	if _, err := executils.ExecCommand(ctx, "docker", "load", "-i", tmpFile.Name()); err != nil {
+	if _, err := im.engine.run(ctx, "load", "-i", tmpFile.Name()); err != nil {
 		log.Error().Err(err).Msg("Failed to load Docker image")
 		return fmt.Errorf("failed to load Docker image: %w", err)
 	}
@@ -158,8 +183,43 @@ func (im *ImageManager) DownloadAndLoadImage(ctx context.Context, imageURL, imag
 }
 
 func (im *ImageManager) EnsureImageAvailable(ctx context.Context, imageName, imageURL string) error {
+	if err := im.policy.Check(imageName); err != nil {
+		return err
+	}
+	if im.signatures != nil {
+		return im.pullVerified(ctx, imageName, imageURL)
+	}
 	if imageURL != "" {
 		return im.DownloadAndLoadImage(ctx, imageURL, imageName)
 	}
 	return im.PullImage(ctx, imageName)
 }
+
+// pullVerified pulls the image by the digest its trusted signature was made for
+// and gives it the task's image name, so a tag moved after verification is not
+// what runs
+func (im *ImageManager) pullVerified(ctx context.Context, imageName, imageURL string) error {
+	log := gologger.WithComponent("docker.image")
+
+	if imageURL != "" {
+		return fmt.Errorf("%w: %s is loaded from a URL, its signature cannot be verified", models.ErrImageNotAllowed, imageName)
+	}
+	digest, err := im.signatures.verify(ctx, imageName)
+	if err != nil {
+		log.Error().Err(err).Str("image", imageName).Msg("Image signature verification failed")
+		return err
+	}
+	log.Info().Str("image", imageName).Str("digest", digest).Msg("Image signature verified")
+
+	pinned := imageRepository(imageName) + "@" + digest
+	if err := im.PullImage(ctx, pinned); err != nil {
+		return err
+	}
+	if strings.Contains(imageName, "@") {
+		return nil
+	}
+	if _, err := im.engine.run(ctx, "tag", pinned, imageName); err != nil {
+		return fmt.Errorf("failed to tag verified image: %w", err)
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/theblitlabs/gologger"
 
+	"github.com/theblitlabs/parity-runner/internal/core/models"
 	"github.com/theblitlabs/parity-runner/internal/core/ports"
 	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker/executils"
 )
@@ -20,25 +21,36 @@ type ContainerMetrics struct {
 	CPUSeconds      float64
 	EstimatedCycles uint64
 	MemoryGBHours   float64
+	PeakMemoryGB    float64
 	StorageGB       float64
 	NetworkDataGB   float64
 }
 
+const statsFormat = `{"cpu":"{{.CPUPerc}}", "memory":"{{.MemUsage}}", "netIO":"{{.NetIO}}", "blockIO":"{{.BlockIO}}"}`
+
 type ResourceMonitor struct {
+	engine         Engine
 	containerID    string
 	stopCh         chan struct{}
 	wg             sync.WaitGroup
 	metricsLock    sync.RWMutex
 	metrics        ContainerMetrics
 	lastNonZeroCPU float64
+	// timeline holds the most recent samples, for the diagnostics of tasks
+	// that fail
+	timeline []models.MetricsSample
 }
 
-func NewResourceMetrics(containerID string) (*ResourceMonitor, error) {
+// maxTimelineSamples keeps the last five minutes of one second samples
+const maxTimelineSamples = 300
+
+func NewResourceMetrics(engine Engine, containerID string) (*ResourceMonitor, error) {
 	if containerID == "" {
 		return nil, fmt.Errorf("container ID is required")
 	}
 
 	return &ResourceMonitor{
+		engine:      engine,
 		containerID: containerID,
 		stopCh:      make(chan struct{}),
 	}, nil
@@ -55,11 +67,7 @@ func (rc *ResourceMonitor) GetSystemMetrics() (memory int64, cpu float64) {
 func (rc *ResourceMonitor) Start(ctx context.Context) error {
 	log := gologger.WithComponent("docker.metrics")
 
-	statsCmd := fmt.Sprintf(`docker stats --no-stream --format `+
-		`'{"cpu":"{{.CPUPerc}}", "memory":"{{.MemUsage}}", "netIO":"{{.NetIO}}", "blockIO":"{{.BlockIO}}"}' %s`,
-		rc.containerID)
-
-	_, err := executils.ExecCommand(ctx, "sh", "-c", statsCmd)
+	_, err := rc.stats(ctx)
 	if err != nil {
 		return fmt.Errorf("cannot access container stats: %w", err)
 	}
@@ -87,6 +95,12 @@ func (rc *ResourceMonitor) Start(ctx context.Context) error {
 	return nil
 }
 
+// stats samples the container once. It goes through the engine so that
+// containers on a remote host are sampled there.
+func (rc *ResourceMonitor) stats(ctx context.Context) ([]byte, error) {
+	return rc.engine.run(ctx, "stats", "--no-stream", "--format", statsFormat, rc.containerID)
+}
+
 func (rc *ResourceMonitor) Stop() {
 	close(rc.stopCh)
 	rc.wg.Wait()
@@ -98,18 +112,31 @@ func (rc *ResourceMonitor) GetMetrics() ContainerMetrics {
 	return rc.metrics
 }
 
+// Timeline returns the most recent samples of the container's CPU and memory
+// usage, oldest first
+func (rc *ResourceMonitor) Timeline() []models.MetricsSample {
+	rc.metricsLock.RLock()
+	defer rc.metricsLock.RUnlock()
+	return append([]models.MetricsSample(nil), rc.timeline...)
+}
+
+// record adds a sample to the timeline, dropping the oldest when it is full.
+// The caller holds metricsLock.
+func (rc *ResourceMonitor) record(sample models.MetricsSample) {
+	if len(rc.timeline) == maxTimelineSamples {
+		rc.timeline = append(rc.timeline[:0], rc.timeline[1:]...)
+	}
+	rc.timeline = append(rc.timeline, sample)
+}
+
 func (rc *ResourceMonitor) collectMetrics(startTime time.Time) {
 	log := gologger.WithComponent("docker.metrics")
 
-	statusOut, err := executils.ExecCommand(context.Background(), "docker", "inspect", "--format", "{{.State.Status}}", rc.containerID)
+	statusOut, err := rc.engine.run(context.Background(), "inspect", "--format", "{{.State.Status}}", rc.containerID)
 	containerExists := err == nil
 	containerStatus := strings.TrimSpace(string(statusOut))
 
-	statsCmd := fmt.Sprintf(`docker stats --no-stream --format `+
-		`'{"cpu":"{{.CPUPerc}}", "memory":"{{.MemUsage}}", "netIO":"{{.NetIO}}", "blockIO":"{{.BlockIO}}"}' %s`,
-		rc.containerID)
-
-	statsOutput, err := executils.ExecCommand(context.Background(), "sh", "-c", statsCmd)
+	statsOutput, err := rc.stats(context.Background())
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to collect container stats")
 		return
@@ -135,7 +162,7 @@ func (rc *ResourceMonitor) collectMetrics(startTime time.Time) {
 	cpuStr := strings.TrimSuffix(stats.CPU, "%")
 
 	if cpuStr == "" || cpuStr == "0.00" {
-		cpuOutput, err := executils.ExecCommand(context.Background(), "docker", "stats", "--no-stream", "--format", "{{.CPUPerc}}", rc.containerID)
+		cpuOutput, err := rc.engine.run(context.Background(), "stats", "--no-stream", "--format", "{{.CPUPerc}}", rc.containerID)
 		if err == nil {
 			cpuStr = strings.TrimSuffix(strings.TrimSpace(string(cpuOutput)), "%")
 		}
@@ -148,7 +175,7 @@ func (rc *ResourceMonitor) collectMetrics(startTime time.Time) {
 					cpuStr = fmt.Sprintf("%.2f", rc.lastNonZeroCPU)
 				}
 			} else {
-				usageOutput, err := executils.ExecCommand(context.Background(), "docker", "exec", rc.containerID, "cat", "/sys/fs/cgroup/cpu/cpuacct.usage")
+				usageOutput, err := rc.engine.run(context.Background(), "exec", rc.containerID, "cat", "/sys/fs/cgroup/cpu/cpuacct.usage")
 				if err == nil {
 					usage := strings.TrimSpace(string(usageOutput))
 					if usageVal, err := strconv.ParseUint(usage, 10, 64); err == nil {
@@ -184,6 +211,7 @@ func (rc *ResourceMonitor) collectMetrics(startTime time.Time) {
 		log.Error().Err(err).Str("cpuStr", cpuStr).Msg("Failed to parse CPU percentage")
 	}
 
+	var memGB float64
 	if parts := strings.Split(stats.Memory, " / "); len(parts) >= 1 {
 		if memStr := parts[0]; memStr != "" {
 
@@ -191,7 +219,6 @@ func (rc *ResourceMonitor) collectMetrics(startTime time.Time) {
 			var unit string
 			if _, err := fmt.Sscanf(memStr, "%f%s", &mem, &unit); err == nil {
 
-				var memGB float64
 				switch strings.ToUpper(unit) {
 				case "B":
 					memGB = mem / (1024 * 1024 * 1024)
@@ -205,6 +232,9 @@ func (rc *ResourceMonitor) collectMetrics(startTime time.Time) {
 					memGB = mem * 1024
 				}
 				rc.metrics.MemoryGBHours = memGB * (time.Since(startTime).Hours())
+				if memGB > rc.metrics.PeakMemoryGB {
+					rc.metrics.PeakMemoryGB = memGB
+				}
 			}
 		}
 	}
@@ -223,6 +253,12 @@ func (rc *ResourceMonitor) collectMetrics(startTime time.Time) {
 		rc.metrics.StorageGB = float64(readBytes+writeBytes) / (1024 * 1024 * 1024)
 	}
 
+	rc.record(models.MetricsSample{
+		ElapsedMs:  time.Since(startTime).Milliseconds(),
+		CPUPercent: cpuPerc,
+		MemoryGB:   memGB,
+	})
+
 	log.Debug().
 		Str("container", rc.containerID).
 		Str("raw_cpu", stats.CPU).
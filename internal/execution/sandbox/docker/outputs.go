@@ -0,0 +1,245 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+)
+
+const (
+	outputMountPath = "/parity/output"
+	// maxOutputFiles and maxOutputFileBytes bound what a task may have
+	// uploaded from its output directory
+	maxOutputFiles           = 64
+	maxOutputFileBytes int64 = 1 << 30
+	// Bundles of declared outputs up to maxInlineBundleBytes are attached to
+	// the result itself, larger ones are added to IPFS. maxOutputBundleBytes
+	// bounds both the files copied out of the container and their bundle.
+	maxInlineBundleBytes = 1 << 20
+	maxOutputBundleBytes = 1 << 30
+)
+
+// errOutputsTooLarge fails a task whose declared outputs exceed maxOutputBundleBytes
+var errOutputsTooLarge = fmt.Errorf("declared outputs exceed %d bytes", maxOutputBundleBytes)
+
+// prepareOutputDir creates the directory a task writes files to upload into.
+// Containers may run as any user, so it is world writable. The caller removes
+// it.
+func prepareOutputDir() (string, error) {
+	dir, err := os.MkdirTemp("", "parity-output-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0o777); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to set output directory permissions: %w", err)
+	}
+	return dir, nil
+}
+
+// uploadOutputs adds the regular files a task left in its output directory
+// to IPFS. Symlinks and other special files are skipped.
+func uploadOutputs(ctx context.Context, content ipfs.Backend, dir string) (*models.ResultUploads, error) {
+	var uploads models.ResultUploads
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if len(uploads.Files) == maxOutputFiles {
+			return fmt.Errorf("task wrote more than %d output files", maxOutputFiles)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > maxOutputFileBytes {
+			return fmt.Errorf("output file %s is %d bytes, limit is %d", entry.Name(), info.Size(), maxOutputFileBytes)
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		cid, err := content.Add(ctx, data)
+		if err != nil {
+			return fmt.Errorf("failed to upload output file %s: %w", rel, err)
+		}
+		uploads.Files = append(uploads.Files, models.ResultUpload{
+			Name:   filepath.ToSlash(rel),
+			CID:    cid,
+			SHA256: ipfs.SHA256Hex(data),
+			Size:   int64(len(data)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(uploads.Files) == 0 {
+		return nil, nil
+	}
+	return &uploads, nil
+}
+
+// collectOutputs copies the task's declared output paths out of its finished
+// container and bundles them. Paths the task did not create are skipped. When
+// digests is not nil the SHA-256 of every file bundled is added to it.
+func (e *DockerExecutor) collectOutputs(ctx context.Context, task *models.Task, containerID string, paths []string, digests map[string]string) (*models.ResultUpload, error) {
+	log := gologger.WithComponent("docker")
+
+	dir, err := os.MkdirTemp("", "parity-outputs-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create outputs directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var trees []archiveTree
+	var copied int64
+	for i, p := range paths {
+		target := filepath.Join(dir, strconv.Itoa(i))
+		n, err := e.copyOut(ctx, containerID, p, target, maxOutputBundleBytes-copied)
+		if errors.Is(err, errOutputsTooLarge) {
+			return nil, err
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("task_id", task.ID.String()).Str("path", p).Msg("Declared output not found in container")
+			continue
+		}
+		copied += n
+		trees = append(trees, archiveTree{strings.TrimPrefix(p, "/"), target})
+	}
+	if len(trees) == 0 {
+		return nil, nil
+	}
+	if digests != nil {
+		if err := treeDigests(trees, digests); err != nil {
+			return nil, fmt.Errorf("failed to hash outputs: %w", err)
+		}
+	}
+	return bundleOutputs(ctx, e.content, trees)
+}
+
+// copyOut copies src out of a container to target, reading the tar stream of
+// docker cp so that at most budget bytes of files are written. Only regular
+// files and directories are copied. It returns the bytes written.
+func (e *DockerExecutor) copyOut(ctx context.Context, containerID, src, target string, budget int64) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := e.engine.command(ctx, "cp", containerID+":"+src, "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	written, extractErr := extractOutput(tar.NewReader(stdout), target, budget)
+	if extractErr != nil {
+		// Stops the copy instead of draining a stream that is not needed
+		cancel()
+	}
+	waitErr := cmd.Wait()
+	switch {
+	case extractErr != nil:
+		return written, extractErr
+	case waitErr != nil:
+		return written, fmt.Errorf("%w: %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return written, nil
+}
+
+// extractOutput writes the entries of a docker cp stream under target. The
+// stream's top entry, named after the copied path, becomes target itself.
+func extractOutput(tr *tar.Reader, target string, budget int64) (int64, error) {
+	var written int64
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return written, nil
+		}
+		if err != nil {
+			return written, fmt.Errorf("invalid output stream: %w", err)
+		}
+
+		clean := path.Clean(header.Name)
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return written, fmt.Errorf("invalid output entry %q", header.Name)
+		}
+		_, rest, _ := strings.Cut(clean, "/")
+		dest := filepath.Join(target, filepath.FromSlash(rest))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0o700); err != nil {
+				return written, err
+			}
+		case tar.TypeReg:
+			if header.Size > budget-written {
+				return written, errOutputsTooLarge
+			}
+			if err := os.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+				return written, err
+			}
+			f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&0o777)
+			if err != nil {
+				return written, err
+			}
+			n, err := io.Copy(f, io.LimitReader(tr, header.Size))
+			written += n
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+}
+
+// bundleOutputs packs copied outputs into one gzipped tar, inline when small
+// and on IPFS otherwise
+func bundleOutputs(ctx context.Context, content ipfs.Backend, trees []archiveTree) (*models.ResultUpload, error) {
+	bundle, err := writeArchive(trees, maxOutputBundleBytes, maxOutputBundleBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bundle outputs: %w", err)
+	}
+
+	upload := &models.ResultUpload{
+		Name:   models.OutputsBundleName,
+		SHA256: ipfs.SHA256Hex(bundle),
+		Size:   int64(len(bundle)),
+	}
+	if len(bundle) <= maxInlineBundleBytes {
+		upload.Data = bundle
+		return upload, nil
+	}
+	if upload.CID, err = content.Add(ctx, bundle); err != nil {
+		return nil, fmt.Errorf("failed to upload outputs bundle: %w", err)
+	}
+	return upload, nil
+}
@@ -0,0 +1,183 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+)
+
+func TestUploadOutputs(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "model.pt"), "weights")
+	writeTestFile(t, filepath.Join(dir, "logs", "train.log"), "epoch 1")
+	if err := os.Symlink("/etc/passwd", filepath.Join(dir, "passwd")); err != nil {
+		t.Fatal(err)
+	}
+
+	content := &fakeCheckpointContent{blobs: map[string][]byte{}}
+	uploads, err := uploadOutputs(context.Background(), content, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploads == nil || len(uploads.Files) != 2 {
+		t.Fatalf("uploads = %+v, want the two regular files", uploads)
+	}
+	for _, file := range uploads.Files {
+		data := content.blobs[file.CID]
+		if file.SHA256 != ipfs.SHA256Hex(data) || file.Size != int64(len(data)) {
+			t.Errorf("upload %+v does not describe its content", file)
+		}
+	}
+	if uploads.Files[0].Name != "logs/train.log" || uploads.Files[1].Name != "model.pt" {
+		t.Fatalf("upload names = %s, %s", uploads.Files[0].Name, uploads.Files[1].Name)
+	}
+	if err := uploads.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if uploads, err := uploadOutputs(context.Background(), content, t.TempDir()); err != nil || uploads != nil {
+		t.Fatalf("empty directory = %+v, %v, want no uploads", uploads, err)
+	}
+}
+
+func TestBundleOutputs(t *testing.T) {
+	source := t.TempDir()
+	writeTestFile(t, filepath.Join(source, "results", "summary.csv"), "rows,42")
+	writeTestFile(t, filepath.Join(source, "model.bin"), "weights")
+	trees := []archiveTree{
+		{"app/results", filepath.Join(source, "results")},
+		{"app/model.bin", filepath.Join(source, "model.bin")},
+	}
+
+	content := &fakeCheckpointContent{blobs: map[string][]byte{}}
+	bundle, err := bundleOutputs(context.Background(), content, trees)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Name != models.OutputsBundleName || bundle.CID != "" || bundle.Data == nil {
+		t.Fatalf("small bundle = %+v, want it inline", bundle)
+	}
+	if err := (&models.ResultUploads{Files: []models.ResultUpload{*bundle}}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	tr := tar.NewReader(gzipReader(t, bundle.Data))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	want := []string{"app/results", "app/results/summary.csv", "app/model.bin"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Fatalf("bundle entries = %v, want %v", names, want)
+	}
+
+	random := make([]byte, maxInlineBundleBytes+1)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(source, "large.bin"), string(random))
+	bundle, err = bundleOutputs(context.Background(), content, []archiveTree{{"large.bin", filepath.Join(source, "large.bin")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Data != nil || ipfs.SHA256Hex(content.blobs[bundle.CID]) != bundle.SHA256 {
+		t.Fatalf("large bundle = %s inline %d bytes, want it uploaded", bundle.CID, len(bundle.Data))
+	}
+}
+
+func gzipReader(t *testing.T, data []byte) io.Reader {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gz
+}
+
+// copyStream is a docker cp stream of the directory out holding files. Names
+// starting with .. are written as they are.
+func copyStream(t *testing.T, files map[string]string) *tar.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "out/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "../escape.txt"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		entry := "out/" + name
+		if strings.HasPrefix(name, "..") {
+			entry = name
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: entry, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return tar.NewReader(&buf)
+}
+
+func TestExtractOutputStopsAtTheBudget(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "0")
+	written, err := extractOutput(copyStream(t, map[string]string{"a.txt": "12345", "b.txt": "67890"}), target, 10)
+	if err != nil || written != 10 {
+		t.Fatalf("extractOutput() = %d, %v, want 10 bytes", written, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(target, "b.txt")); err != nil || string(data) != "67890" {
+		t.Fatalf("b.txt = %q, %v", data, err)
+	}
+
+	target = filepath.Join(t.TempDir(), "0")
+	written, err = extractOutput(copyStream(t, map[string]string{"a.txt": "12345", "b.txt": "67890"}), target, 8)
+	if !errors.Is(err, errOutputsTooLarge) || written != 5 {
+		t.Fatalf("extractOutput() over budget = %d, %v, want errOutputsTooLarge after 5 bytes", written, err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "b.txt")); !os.IsNotExist(err) {
+		t.Fatalf("the file over the budget was written: %v", err)
+	}
+
+	dir := t.TempDir()
+	if _, err := extractOutput(copyStream(t, map[string]string{"../escape.txt": "x"}), filepath.Join(dir, "0"), 10); err == nil || !strings.Contains(err.Error(), "invalid output entry") {
+		t.Fatalf("extractOutput() of an escaping entry = %v, want it refused", err)
+	}
+}
+
+func TestWriteArchiveChecksFileSizesFirst(t *testing.T) {
+	source := t.TempDir()
+	writeTestFile(t, filepath.Join(source, "small.txt"), "1234")
+	writeTestFile(t, filepath.Join(source, "large.txt"), strings.Repeat("x", 64))
+
+	if _, err := writeArchive([]archiveTree{{"small.txt", filepath.Join(source, "small.txt")}}, 1<<20, 4); err != nil {
+		t.Fatalf("writeArchive() within the limit = %v", err)
+	}
+	_, err := writeArchive([]archiveTree{{"out", source}}, 1<<20, 32)
+	if err == nil || !strings.Contains(err.Error(), "archived files exceed 32 bytes") {
+		t.Fatalf("writeArchive() over the file limit = %v", err)
+	}
+}
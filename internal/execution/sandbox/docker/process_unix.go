@@ -0,0 +1,15 @@
+//go:build unix
+
+package docker
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports whether a process with the PID exists. One owned by
+// another user still counts.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,186 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker/executils"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// BuildVerifier rebuilds task images from their reproducible build recipe and checks
+// that the result matches the image about to be executed
+type BuildVerifier struct {
+	engine   Engine
+	runnerID string
+}
+
+func NewBuildVerifier(engine Engine) *BuildVerifier {
+	runnerID, err := utils.GetDeviceID()
+	if err != nil {
+		runnerID = ""
+	}
+	return &BuildVerifier{engine: engine, runnerID: runnerID}
+}
+
+// ShouldVerify decides whether this runner rebuilds the image for a task. The choice
+// is derived from the task and runner IDs, so it is stable across retries and can be
+// recomputed by anyone auditing which runners were asked to verify.
+func (v *BuildVerifier) ShouldVerify(taskID string, recipe *models.BuildRecipe) bool {
+	if recipe == nil || recipe.VerifyFraction <= 0 {
+		return false
+	}
+	if recipe.VerifyFraction >= 1 {
+		return true
+	}
+
+	sum := sha256.Sum256([]byte(taskID + ":" + v.runnerID))
+	sample := float64(binary.BigEndian.Uint64(sum[:8])) / math.MaxUint64
+	return sample < recipe.VerifyFraction
+}
+
+// Verify rebuilds the recipe and compares the rebuilt image ID with the executed image
+// and, when set, the digest the recipe is expected to produce
+func (v *BuildVerifier) Verify(ctx context.Context, recipe *models.BuildRecipe, imageID string) error {
+	log := gologger.WithComponent("docker.build_verify")
+
+	// The recipe comes from the task, so it is checked again before any of it
+	// reaches git or the build
+	if err := recipe.Validate(); err != nil {
+		return fmt.Errorf("invalid build recipe: %w", err)
+	}
+
+	workDir, err := os.MkdirTemp("", "parity-build-*")
+	if err != nil {
+		return fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	srcDir := filepath.Join(workDir, "src")
+	if _, err := executils.ExecCommand(ctx, "git", "clone", "--quiet", "--", recipe.Repository, srcDir); err != nil {
+		return fmt.Errorf("failed to clone build source: %w", err)
+	}
+	// A plain -- before the revision would make git read it as a path
+	if _, err := executils.ExecCommand(ctx, "git", "-C", srcDir, "checkout", "--quiet", "--detach", "--end-of-options", recipe.Revision); err != nil {
+		return fmt.Errorf("failed to check out revision %s: %w", recipe.Revision, err)
+	}
+
+	log.Info().
+		Str("kind", string(recipe.Kind)).
+		Str("repository", recipe.Repository).
+		Str("revision", recipe.Revision).
+		Msg("Rebuilding task image from source")
+
+	var rebuiltID string
+	switch recipe.Kind {
+	case models.BuildRecipeNix:
+		rebuiltID, err = buildNixImage(ctx, v.engine, srcDir, recipe)
+	case models.BuildRecipeDockerfile:
+		rebuiltID, err = buildDockerfileImage(ctx, v.engine, srcDir, recipe)
+	default:
+		err = fmt.Errorf("unsupported build recipe kind: %s", recipe.Kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	if expected := models.NormalizeDigest(recipe.ExpectedDigest); expected != "" && rebuiltID != expected {
+		return fmt.Errorf("rebuilt image %s does not match expected digest %s", rebuiltID, expected)
+	}
+	if executed := models.NormalizeDigest(imageID); rebuiltID != executed {
+		return fmt.Errorf("rebuilt image %s does not match task image %s", rebuiltID, executed)
+	}
+
+	log.Info().Str("image_id", rebuiltID).Msg("Rebuilt image matches task image")
+	return nil
+}
+
+// ToolsAvailable reports whether the runner can rebuild images of the given kind
+func (v *BuildVerifier) ToolsAvailable(kind models.BuildRecipeKind) bool {
+	if !v.engine.Buildx {
+		return false
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return false
+	}
+	switch kind {
+	case models.BuildRecipeNix:
+		_, err := exec.LookPath("nix")
+		return err == nil
+	case models.BuildRecipeDockerfile:
+		return true
+	default:
+		return false
+	}
+}
+
+func buildNixImage(ctx context.Context, engine Engine, srcDir string, recipe *models.BuildRecipe) (string, error) {
+	output, err := executils.ExecCommand(ctx, "nix", "build", "--no-link", "--print-out-paths", srcDir+"#"+recipe.Attribute)
+	if err != nil {
+		return "", fmt.Errorf("nix build failed: %w", err)
+	}
+
+	tarball := strings.TrimSpace(string(output))
+	if idx := strings.LastIndex(tarball, "\n"); idx >= 0 {
+		tarball = tarball[idx+1:]
+	}
+
+	loaded, err := utils.LoadAndVerifyImage(tarball)
+	if err != nil {
+		return "", fmt.Errorf("failed to load nix built image: %w", err)
+	}
+
+	imageID, err := engine.ImageID(ctx, loaded)
+	if err != nil {
+		return "", err
+	}
+	return models.NormalizeDigest(imageID), nil
+}
+
+func buildDockerfileImage(ctx context.Context, engine Engine, srcDir string, recipe *models.BuildRecipe) (string, error) {
+	contextDir := filepath.Join(srcDir, recipe.Context)
+	dockerfile := recipe.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+
+	tag := "parity-verify:" + recipe.Revision
+	if len(recipe.Revision) > 12 {
+		tag = "parity-verify:" + recipe.Revision[:12]
+	}
+
+	args := []string{
+		"buildx", "build",
+		"--no-cache",
+		"--file", filepath.Join(contextDir, dockerfile),
+		"--output", "type=docker,name=" + tag + ",rewrite-timestamp=true",
+	}
+	if recipe.SourceDateEpoch > 0 {
+		args = append(args, "--build-arg", "SOURCE_DATE_EPOCH="+strconv.FormatInt(recipe.SourceDateEpoch, 10))
+	}
+	args = append(args, contextDir)
+
+	if _, err := engine.run(ctx, args...); err != nil {
+		return "", fmt.Errorf("docker build failed: %w", err)
+	}
+	defer func() {
+		_, _ = engine.run(context.Background(), "image", "rm", "--force", tag)
+	}()
+
+	imageID, err := engine.ImageID(ctx, tag)
+	if err != nil {
+		return "", err
+	}
+	return models.NormalizeDigest(imageID), nil
+}
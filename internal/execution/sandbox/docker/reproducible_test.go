@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestBuildVerifierSamplesFractionOfRunners(t *testing.T) {
+	recipe := &models.BuildRecipe{Kind: models.BuildRecipeDockerfile, VerifyFraction: 0.25}
+
+	selected := 0
+	const runners = 2000
+	for i := 0; i < runners; i++ {
+		verifier := &BuildVerifier{runnerID: fmt.Sprintf("runner-%d", i)}
+		if verifier.ShouldVerify("task-1", recipe) {
+			selected++
+		}
+		if verifier.ShouldVerify("task-1", recipe) != verifier.ShouldVerify("task-1", recipe) {
+			t.Fatal("expected sampling to be deterministic")
+		}
+	}
+
+	if selected < runners/5 || selected > runners*3/10 {
+		t.Fatalf("selected %d of %d runners, want roughly 25%%", selected, runners)
+	}
+
+	verifier := &BuildVerifier{runnerID: "runner-1"}
+	if verifier.ShouldVerify("task-1", &models.BuildRecipe{}) {
+		t.Fatal("expected no verification without a verify fraction")
+	}
+	if !verifier.ShouldVerify("task-1", &models.BuildRecipe{VerifyFraction: 1}) {
+		t.Fatal("expected every runner to verify with a fraction of 1")
+	}
+}
+
+func TestVerifyBuildRejectsUnexpectedImageDigest(t *testing.T) {
+	executor := &DockerExecutor{buildVerifier: &BuildVerifier{runnerID: "runner-1"}}
+	recipe := &models.BuildRecipe{
+		Kind:           models.BuildRecipeDockerfile,
+		Repository:     "https://example.com/repo.git",
+		Revision:       "4f2c1e9d0a7b5c3e8f6d2a1b0c9e8d7f6a5b4c3d",
+		ExpectedDigest: "sha256:expected",
+	}
+
+	_, err := executor.verifyBuild(context.Background(), models.NewTask(), recipe, "tampered")
+	if err == nil || !strings.Contains(err.Error(), "does not match expected digest") {
+		t.Fatalf("expected digest mismatch error, got %v", err)
+	}
+
+	verified, err := executor.verifyBuild(context.Background(), models.NewTask(), recipe, "expected")
+	if err != nil || verified {
+		t.Fatalf("verifyBuild() = %v, %v; want matching digest without rebuild", verified, err)
+	}
+}
+
+func TestVerifyRefusesRecipesReachingOutsideTheSource(t *testing.T) {
+	verifier := &BuildVerifier{runnerID: "runner-1"}
+	valid := models.BuildRecipe{
+		Kind:       models.BuildRecipeDockerfile,
+		Repository: "https://example.com/repo.git",
+		Revision:   "4f2c1e9d0a7b5c3e8f6d2a1b0c9e8d7f6a5b4c3d",
+	}
+
+	recipes := map[string]func(*models.BuildRecipe){
+		"escaping context":    func(r *models.BuildRecipe) { r.Context = "../../etc" },
+		"absolute context":    func(r *models.BuildRecipe) { r.Context = "/etc" },
+		"escaping dockerfile": func(r *models.BuildRecipe) { r.Dockerfile = "../Dockerfile" },
+		"branch revision":     func(r *models.BuildRecipe) { r.Revision = "main" },
+		"option revision":     func(r *models.BuildRecipe) { r.Revision = "--orphan=x" },
+		"option repository":   func(r *models.BuildRecipe) { r.Repository = "--upload-pack=touch /tmp/pwned" },
+	}
+	for name, mutate := range recipes {
+		recipe := valid
+		mutate(&recipe)
+		err := verifier.Verify(context.Background(), &recipe, "image")
+		if err == nil || !strings.Contains(err.Error(), "invalid build recipe") {
+			t.Fatalf("%s: Verify() error = %v, want the recipe refused before cloning", name, err)
+		}
+	}
+}
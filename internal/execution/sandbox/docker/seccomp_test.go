@@ -2,10 +2,13 @@ package docker
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/events"
 	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker/executils"
 )
 
@@ -55,7 +58,7 @@ func TestSeccompProfileGeneration(t *testing.T) {
 func TestContainerSecurityCheck(t *testing.T) {
 	t.Skip("Manual test only - requires Docker environment")
 
-	cm, err := NewContainerManager("128m", "0.5")
+	cm, err := NewContainerManager(DockerEngine, "128m", "0.5")
 	if err != nil {
 		t.Fatalf("Failed to create container manager: %v", err)
 	}
@@ -103,3 +106,26 @@ func TestContainerSecurityCheck(t *testing.T) {
 		t.Logf("Command execution was correctly blocked inside the container")
 	}
 }
+
+func TestFailedSecurityCheckRecordsNoVerification(t *testing.T) {
+	store := events.NewStore(t.TempDir())
+	task := models.NewTask()
+	ctx := events.WithTask(context.Background(), store, task.ID.String())
+
+	// A container manager without a seccomp profile fails the check
+	executor := &DockerExecutor{containerMgr: &ContainerManager{engine: Engine{Command: "true"}}}
+	err := executor.verifySecurity(ctx, task, "container-1", map[string]string{"image_hash": "sha256:abc"})
+	if err == nil || !strings.Contains(err.Error(), "security verification failed") {
+		t.Fatalf("verifySecurity() = %v, want a failed verification", err)
+	}
+
+	recorded, err := store.Load(task.ID.String())
+	if err != nil && !errors.Is(err, events.ErrNoEvents) {
+		t.Fatalf("Load() = %v", err)
+	}
+	for _, event := range recorded {
+		if event.Type == models.ExecutionEventSecurityVerified {
+			t.Fatalf("failed check recorded %+v", event)
+		}
+	}
+}
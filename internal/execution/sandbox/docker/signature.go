@@ -0,0 +1,134 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// ImageSignatures are the signers Docker task images must be signed by. Keys
+// are anything `cosign verify --key` takes, such as a public key file or a KMS
+// URI. Identity is a regular expression matched against the certificate
+// identity of keyless signatures, whose certificates Issuer must have issued.
+// An image signed by any one of them is accepted.
+type ImageSignatures struct {
+	Keys     []string
+	Identity string
+	Issuer   string
+}
+
+// ParseImageSignatures builds the signers from comma separated keys and a
+// keyless identity and issuer, which go together
+func ParseImageSignatures(keys, identity, issuer string) (ImageSignatures, error) {
+	signers := ImageSignatures{Identity: strings.TrimSpace(identity), Issuer: strings.TrimSpace(issuer)}
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			signers.Keys = append(signers.Keys, key)
+		}
+	}
+	if (signers.Identity == "") != (signers.Issuer == "") {
+		return ImageSignatures{}, errors.New("keyless image signatures need both an identity and an issuer")
+	}
+	if signers.Identity != "" {
+		if _, err := regexp.Compile(signers.Identity); err != nil {
+			return ImageSignatures{}, fmt.Errorf("invalid image signature identity: %w", err)
+		}
+	}
+	return signers, nil
+}
+
+func (s ImageSignatures) IsZero() bool {
+	return len(s.Keys) == 0 && s.Identity == ""
+}
+
+func (s ImageSignatures) String() string {
+	parts := make([]string, 0, 2)
+	if len(s.Keys) > 0 {
+		parts = append(parts, "keys="+strings.Join(s.Keys, ","))
+	}
+	if s.Identity != "" {
+		parts = append(parts, "identity="+s.Identity+" issuer="+s.Issuer)
+	}
+	return strings.Join(parts, " ")
+}
+
+// verifyArgs are the cosign verify arguments for each signer, the image aside
+func (s ImageSignatures) verifyArgs() [][]string {
+	var args [][]string
+	for _, key := range s.Keys {
+		args = append(args, []string{"verify", "--output", "json", "--key", key})
+	}
+	if s.Identity != "" {
+		args = append(args, []string{"verify", "--output", "json", "--certificate-identity-regexp", s.Identity, "--certificate-oidc-issuer", s.Issuer})
+	}
+	return args
+}
+
+// signatureVerifier checks image signatures with the cosign CLI
+type signatureVerifier struct {
+	command string
+	signers ImageSignatures
+}
+
+// verify returns the digest the image's trusted signature was made for, so
+// that exactly that image is pulled
+func (v *signatureVerifier) verify(ctx context.Context, image string) (string, error) {
+	var failures []string
+	for _, args := range v.signers.verifyArgs() {
+		digest, err := v.run(ctx, append(args, image)...)
+		if err == nil {
+			return digest, nil
+		}
+		failures = append(failures, err.Error())
+	}
+	return "", fmt.Errorf("%w: %s has no trusted signature: %s", models.ErrImageNotAllowed, image, strings.Join(failures, "; "))
+}
+
+func (v *signatureVerifier) run(ctx context.Context, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, v.command, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			lines := strings.Split(message, "\n")
+			return "", errors.New(lines[len(lines)-1])
+		}
+		return "", err
+	}
+
+	// Every payload is for the digest the reference resolved to
+	var payloads []struct {
+		Critical struct {
+			Image struct {
+				Digest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(output, &payloads); err != nil || len(payloads) == 0 {
+		return "", errors.New("cosign returned no signature payloads")
+	}
+	digest := payloads[0].Critical.Image.Digest
+	if !imageDigestPattern.MatchString(digest) {
+		return "", fmt.Errorf("cosign returned invalid digest %q", digest)
+	}
+	return digest, nil
+}
+
+var imageDigestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// imageRepository is the image name without its tag or digest
+func imageRepository(image string) string {
+	name, _, _ := strings.Cut(image, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return name
+}
@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const signedDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// fakeCosign accepts signatures made with trusted.pub only
+func fakeCosign(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake cosign is a shell script")
+	}
+	script := `#!/bin/sh
+case "$*" in
+*"--key trusted.pub"*) echo '[{"critical":{"image":{"docker-manifest-digest":"` + signedDigest + `"}}}]' ;;
+*) echo "Error: no matching signatures" >&2; exit 1 ;;
+esac
+`
+	command := filepath.Join(t.TempDir(), "cosign")
+	if err := os.WriteFile(command, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return command
+}
+
+func TestParseImageSignatures(t *testing.T) {
+	signers, err := ParseImageSignatures(" a.pub, awskms:///alias/parity ,", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signers.Keys) != 2 || signers.Keys[1] != "awskms:///alias/parity" {
+		t.Fatalf("keys = %q", signers.Keys)
+	}
+	if _, err := ParseImageSignatures("", "^https://github.com/acme/", ""); err == nil {
+		t.Error("identity without an issuer should be refused")
+	}
+	if _, err := ParseImageSignatures("", "(", "https://token.actions.githubusercontent.com"); err == nil {
+		t.Error("invalid identity regexp should be refused")
+	}
+	if signers, _ := ParseImageSignatures("", "", ""); !signers.IsZero() {
+		t.Error("empty settings should verify nothing")
+	}
+}
+
+func TestSignatureVerifierTriesEverySigner(t *testing.T) {
+	verifier := &signatureVerifier{
+		command: fakeCosign(t),
+		signers: ImageSignatures{Keys: []string{"other.pub", "trusted.pub"}},
+	}
+	digest, err := verifier.verify(context.Background(), "ghcr.io/acme/app:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != signedDigest {
+		t.Fatalf("digest = %s", digest)
+	}
+
+	verifier.signers.Keys = []string{"other.pub"}
+	_, err = verifier.verify(context.Background(), "ghcr.io/acme/app:1")
+	if !errors.Is(err, models.ErrImageNotAllowed) || !strings.Contains(err.Error(), "no matching signatures") {
+		t.Fatalf("unsigned image error = %v", err)
+	}
+}
+
+func TestPullVerifiedPullsSignedDigest(t *testing.T) {
+	engine, logPath := fakeEngine(t)
+	images := NewImageManager(engine)
+	images.signatures = &signatureVerifier{command: fakeCosign(t), signers: ImageSignatures{Keys: []string{"trusted.pub"}}}
+
+	if err := images.EnsureImageAvailable(context.Background(), "ghcr.io/acme/app:1", ""); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := "ghcr.io/acme/app@" + signedDigest
+	want := "pull " + pinned + "\ntag " + pinned + " ghcr.io/acme/app:1\n"
+	if string(data) != want {
+		t.Fatalf("commands = %q, want %q", data, want)
+	}
+
+	err = images.EnsureImageAvailable(context.Background(), "ghcr.io/acme/app:1", "https://example.com/app.tar")
+	if !errors.Is(err, models.ErrImageNotAllowed) {
+		t.Fatalf("image loaded from a URL = %v, want it refused", err)
+	}
+}
+
+func TestImageRepository(t *testing.T) {
+	for image, want := range map[string]string{
+		"alpine":                             "alpine",
+		"registry:5000/team/app:1":           "registry:5000/team/app",
+		"ghcr.io/acme/app:1@" + signedDigest: "ghcr.io/acme/app",
+	} {
+		if got := imageRepository(image); got != want {
+			t.Errorf("imageRepository(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
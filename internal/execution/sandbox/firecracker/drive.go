@@ -0,0 +1,330 @@
+package firecracker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker/executils"
+)
+
+const (
+	mkfsCommand = "mkfs.ext4"
+
+	runScriptPath = "/.parity/run"
+	taskDataPath  = "/parity/input/data"
+
+	startMarker = "parity-task-start"
+	exitMarker  = "parity-task-exit "
+
+	// driveHeadroom is free space left on a task drive for the task to write to
+	driveHeadroom = 256 << 20
+)
+
+// runSpec is what the guest runs, taken from the task with the image's
+// configuration filling in what the task leaves out
+type runSpec struct {
+	command []string
+	// taskCommand is the command the task set itself, if any
+	taskCommand []string
+	env         []string
+	workdir     string
+	data        []byte
+}
+
+func newRunSpec(task *models.Task, image *docker.ImageConfig) (*runSpec, error) {
+	spec := &runSpec{
+		env:     append(append([]string(nil), image.Env...), "TASK_NONCE="+task.Nonce),
+		workdir: image.WorkingDir,
+	}
+
+	var config map[string]interface{}
+	if task.Environment != nil {
+		config = task.Environment.Config
+	}
+	if command, ok := config["command"].([]interface{}); ok {
+		for _, arg := range command {
+			if s, ok := arg.(string); ok && s != "" {
+				spec.taskCommand = append(spec.taskCommand, s)
+			}
+		}
+	}
+	if workdir, ok := config["workdir"].(string); ok && workdir != "" {
+		spec.workdir = workdir
+	}
+	if env, ok := config["env"].([]interface{}); ok {
+		for _, v := range env {
+			if s, ok := v.(string); ok {
+				spec.env = append(spec.env, s)
+			}
+		}
+	}
+
+	spec.command = spec.taskCommand
+	if len(spec.command) == 0 {
+		spec.command = append(append([]string(nil), image.Entrypoint...), image.Cmd...)
+	}
+	if len(spec.command) == 0 {
+		return nil, errors.New("task and image set no command")
+	}
+	return spec, nil
+}
+
+// runScript is run by the shell of the root filesystem once the task drive is
+// mounted on /mnt. The markers around the task let its output be told apart from
+// the kernel's. A working directory other than / needs a shell in the image.
+func (s *runSpec) runScript() string {
+	var script strings.Builder
+	script.WriteString("mkdir -p /mnt/proc /mnt/sys /mnt/dev /mnt/tmp\n")
+	script.WriteString("mount -t proc proc /mnt/proc\n")
+	script.WriteString("mount -t sysfs sysfs /mnt/sys\n")
+	script.WriteString("mount -t devtmpfs devtmpfs /mnt/dev\n")
+	script.WriteString("echo " + startMarker + "\n")
+
+	script.WriteString("(\n")
+	for _, env := range s.env {
+		if strings.Contains(env, "=") {
+			script.WriteString("export " + shellQuote(env) + "\n")
+		}
+	}
+	if s.data != nil {
+		script.WriteString("export " + shellQuote("PARITY_DATA_FILE="+taskDataPath) + "\n")
+	}
+	command := s.command
+	if s.workdir != "" && s.workdir != "/" {
+		command = append([]string{"/bin/sh", "-c", `cd "$0" && exec "$@"`, s.workdir}, command...)
+	}
+	script.WriteString("exec chroot /mnt")
+	for _, arg := range command {
+		script.WriteString(" " + shellQuote(arg))
+	}
+	script.WriteString("\n)\n")
+
+	script.WriteString("echo \"" + exitMarker + "$?\"\n")
+	script.WriteString("sync\n")
+	script.WriteString("reboot -f\n")
+	return script.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// parseConsole returns the task's output from a VM console and its exit code.
+// ok is false when the guest stopped before reporting one.
+func parseConsole(console string) (output string, exitCode int, ok bool) {
+	console = strings.ReplaceAll(console, "\r\n", "\n")
+	if i := strings.Index(console, startMarker+"\n"); i >= 0 {
+		console = console[i+len(startMarker)+1:]
+	}
+
+	i := strings.LastIndex(console, exitMarker)
+	if i < 0 || (i > 0 && console[i-1] != '\n') {
+		return console, 0, false
+	}
+	line, _, _ := strings.Cut(console[i+len(exitMarker):], "\n")
+	exitCode, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		return console, 0, false
+	}
+	return console[:i], exitCode, true
+}
+
+// buildDrive exports image into an ext4 filesystem with the run script and task
+// data added and returns its path
+func (e *Executor) buildDrive(ctx context.Context, image, dir string, spec *runSpec) (string, error) {
+	archive := filepath.Join(dir, "image.tar")
+	if err := e.engine.ExportImage(ctx, image, archive); err != nil {
+		return "", err
+	}
+
+	root := filepath.Join(dir, "root")
+	size, err := extractArchive(archive, root)
+	if err != nil {
+		return "", fmt.Errorf("failed to unpack image: %w", err)
+	}
+	if err := os.Remove(archive); err != nil {
+		return "", fmt.Errorf("failed to remove image archive: %w", err)
+	}
+
+	files := map[string][]byte{runScriptPath: []byte(spec.runScript())}
+	if spec.data != nil {
+		files[taskDataPath] = spec.data
+	}
+	for path, content := range files {
+		target := filepath.Join(root, path)
+		if err := checkParents(root, target); err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		size += int64(len(content))
+	}
+
+	drive := filepath.Join(dir, "task.ext4")
+	file, err := os.Create(drive)
+	if err != nil {
+		return "", fmt.Errorf("failed to create task drive: %w", err)
+	}
+	size = (size+size/4+driveHeadroom)>>20<<20 + 1<<20
+	err = file.Truncate(size)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to size task drive: %w", err)
+	}
+
+	if _, err := executils.ExecCommand(ctx, mkfsCommand, "-q", "-F", "-i", "4096", "-d", root, drive); err != nil {
+		return "", fmt.Errorf("failed to build task drive: %w", err)
+	}
+	if err := os.RemoveAll(root); err != nil {
+		return "", fmt.Errorf("failed to remove unpacked image: %w", err)
+	}
+	return drive, nil
+}
+
+// extractArchive unpacks a filesystem tar into root and returns the bytes it
+// wrote. Device nodes are skipped, the guest mounts its own /dev.
+func extractArchive(path, root string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return 0, err
+	}
+
+	var size int64
+	reader := tar.NewReader(file)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		target := filepath.Join(root, filepath.Clean("/"+header.Name))
+		if target == root {
+			continue
+		}
+		if err := checkParents(root, target); err != nil {
+			return 0, err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return 0, err
+		}
+		// A later entry replaces an earlier one rather than writing through it
+		if info, err := os.Lstat(target); err == nil && (!info.IsDir() || header.Typeflag != tar.TypeDir) {
+			if err := os.Remove(target); err != nil {
+				return 0, err
+			}
+		}
+
+		mode := os.FileMode(header.Mode).Perm() | os.FileMode(header.Mode)&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return 0, err
+			}
+		case tar.TypeReg:
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+			if err != nil {
+				return 0, err
+			}
+			written, err := io.Copy(out, reader)
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return 0, err
+			}
+			size += written
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return 0, err
+			}
+			_ = os.Lchown(target, header.Uid, header.Gid)
+			continue
+		case tar.TypeLink:
+			source := filepath.Join(root, filepath.Clean("/"+header.Linkname))
+			if err := checkParents(root, source); err != nil {
+				return 0, err
+			}
+			if err := os.Link(source, target); err != nil {
+				return 0, err
+			}
+			continue
+		default:
+			continue
+		}
+
+		// Ownership is only kept when the runner runs as root
+		_ = os.Lchown(target, header.Uid, header.Gid)
+		if err := os.Chmod(target, mode); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// checkParents fails when a directory above target within root is a symlink, so
+// an archive cannot write outside root through a link it created earlier
+func checkParents(root, target string) error {
+	rel, err := filepath.Rel(root, filepath.Dir(target))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("path %s is outside the image", target)
+	}
+	if rel == "." {
+		return nil
+	}
+
+	dir := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("path %s in the image goes through a symlink", strings.TrimPrefix(target, root))
+		}
+	}
+	return nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the rest
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
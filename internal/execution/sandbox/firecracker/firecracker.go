@@ -0,0 +1,340 @@
+// Package firecracker runs Docker tasks that ask for VM isolation in Firecracker
+// microVMs. Each task boots its own kernel with the configured root filesystem,
+// read-only and shared between tasks, and gets the filesystem of its image as a
+// second drive that it is chrooted into. The VM has no network interface.
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	DefaultVCPUs     = 1
+	DefaultMemoryMiB = 512
+
+	kvmDevice = "/dev/kvm"
+	// maxConsoleBytes caps the console output kept from a VM
+	maxConsoleBytes = 4 << 20
+)
+
+// Config locates Firecracker and the guest kernel and root filesystem. The root
+// filesystem needs a shell with mount, chroot and reboot, which a busybox image
+// provides.
+type Config struct {
+	Binary      string
+	KernelImage string
+	RootFS      string
+	VCPUs       int
+	MemoryMiB   int
+	// Timeout bounds preparing the image drive, ExecutionTimeout running the VM
+	// when the task sets no maximum duration
+	Timeout          time.Duration
+	ExecutionTimeout time.Duration
+	// WorkDir holds the image drive of each running task
+	WorkDir string
+	// Content fetches task data referenced by CID, from IPFS_GATEWAY_URL when nil
+	Content ipfs.Backend
+	// ImagePolicy is checked before a task's image is pulled or loaded
+	ImagePolicy models.ImagePolicy
+	// ImageSignatures, when set, only runs images one of them signed
+	ImageSignatures docker.ImageSignatures
+}
+
+func (c *Config) applyDefaults() {
+	if c.Binary == "" {
+		c.Binary = "firecracker"
+	}
+	if c.VCPUs <= 0 {
+		c.VCPUs = DefaultVCPUs
+	}
+	if c.MemoryMiB <= 0 {
+		c.MemoryMiB = DefaultMemoryMiB
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 15 * time.Minute
+	}
+	if c.ExecutionTimeout <= 0 {
+		c.ExecutionTimeout = 25 * time.Minute
+	}
+	if c.WorkDir == "" {
+		c.WorkDir = os.TempDir()
+	}
+}
+
+// Executor runs Docker tasks in microVMs. Images are pulled and exported with a
+// container engine, which never runs the task itself.
+type Executor struct {
+	config  Config
+	engine  docker.Engine
+	images  *docker.ImageManager
+	content ipfs.Backend
+}
+
+// NewExecutor checks that the host can boot microVMs and creates an executor
+// that prepares images with engine
+func NewExecutor(config Config, engine docker.Engine) (*Executor, error) {
+	log := gologger.WithComponent("firecracker")
+	config.applyDefaults()
+
+	if err := checkHost(config); err != nil {
+		log.Error().Err(err).Msg("Firecracker isolation not available")
+		return nil, err
+	}
+
+	var content ipfs.Backend = ipfs.NewClientFromEnv()
+	if config.Content != nil {
+		content = config.Content
+	}
+
+	log.Info().
+		Str("kernel", config.KernelImage).
+		Str("rootfs", config.RootFS).
+		Int("vcpus", config.VCPUs).
+		Int("memory_mib", config.MemoryMiB).
+		Msg("Firecracker isolation enabled")
+
+	images := docker.NewImageManager(engine)
+	images.SetPolicy(config.ImagePolicy)
+	if err := images.VerifySignatures(config.ImageSignatures); err != nil {
+		return nil, err
+	}
+
+	return &Executor{
+		config:  config,
+		engine:  engine,
+		images:  images,
+		content: content,
+	}, nil
+}
+
+func checkHost(config Config) error {
+	if config.KernelImage == "" || config.RootFS == "" {
+		return errors.New("firecracker needs a kernel image and a root filesystem")
+	}
+	for _, path := range []string{config.KernelImage, config.RootFS} {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("firecracker guest image unavailable: %w", err)
+		}
+	}
+	if _, err := exec.LookPath(config.Binary); err != nil {
+		return fmt.Errorf("firecracker not available: %w", err)
+	}
+	if _, err := exec.LookPath(mkfsCommand); err != nil {
+		return fmt.Errorf("%s is needed to build task drives: %w", mkfsCommand, err)
+	}
+	kvm, err := os.OpenFile(kvmDevice, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("cannot use KVM: %w", err)
+	}
+	return kvm.Close()
+}
+
+// PrepareImage pulls or loads image like a task would and returns its ID
+func (e *Executor) PrepareImage(ctx context.Context, image, imageURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+
+	if err := e.images.EnsureImageAvailable(ctx, image, imageURL); err != nil {
+		return "", fmt.Errorf("image preparation failed: %w", err)
+	}
+	return e.engine.ImageID(ctx, image)
+}
+
+func (e *Executor) ExecuteTask(ctx context.Context, task *models.Task) (*models.TaskResult, error) {
+	log := gologger.WithComponent("firecracker")
+	startTime := time.Now()
+	result := models.NewTaskResult()
+	result.TaskID = task.ID
+
+	if err := utils.VerifyDrandNonce(task.Nonce); err != nil {
+		return nil, fmt.Errorf("invalid nonce format: %w", err)
+	}
+	if task.GPU != nil {
+		return nil, errors.New("gpu tasks cannot run in a microVM")
+	}
+
+	var config models.TaskConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	image := config.ImageName
+	if image == "" {
+		return nil, fmt.Errorf("image name required")
+	}
+
+	setupCtx, setupCancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer setupCancel()
+
+	if err := e.images.EnsureImageAvailable(setupCtx, image, config.DockerImageURL); err != nil {
+		return nil, fmt.Errorf("image preparation failed: %w", err)
+	}
+	imageID, err := e.engine.ImageID(setupCtx, image)
+	if err != nil {
+		return nil, fmt.Errorf("image hash verification failed: %w", err)
+	}
+	result.ImageHashVerified = imageID
+
+	imageConfig, err := e.engine.InspectImage(setupCtx, image)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := newRunSpec(task, imageConfig)
+	if err != nil {
+		return nil, err
+	}
+	if len(spec.taskCommand) > 0 {
+		result.CommandHashVerified = utils.ComputeCommandHash(spec.taskCommand)
+	}
+
+	dir, err := os.MkdirTemp(e.config.WorkDir, "parity-vm-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create VM directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if config.Data != "" || config.DataCID != "" {
+		data := []byte(config.Data)
+		if config.Data == "" {
+			if data, err = e.content.Fetch(setupCtx, config.DataCID, ipfs.DefaultMaxFetchBytes, config.DataSHA256); err != nil {
+				return nil, fmt.Errorf("failed to resolve data_cid: %w", err)
+			}
+		}
+		spec.data = data
+	}
+
+	drive, err := e.buildDrive(setupCtx, image, dir, spec)
+	if err != nil {
+		log.Error().Err(err).Str("task_id", task.ID.String()).Str("image", image).Msg("Failed to build task drive")
+		return nil, err
+	}
+
+	timeout := e.config.ExecutionTimeout
+	if limit := task.MaxDuration(); limit > 0 {
+		timeout = limit
+	}
+	execCtx, execCancel := context.WithTimeout(ctx, timeout)
+	defer execCancel()
+
+	log.Info().
+		Str("task_id", task.ID.String()).
+		Str("image", image).
+		Strs("command", spec.command).
+		Msg("Booting task microVM")
+
+	console, err := e.boot(execCtx, dir, drive)
+	result.ExecutionTime = executionDurationMilliseconds(time.Since(startTime))
+	if execCtx.Err() != nil {
+		return nil, fmt.Errorf("task microVM exceeded its time limit of %s", timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	output, exitCode, ok := parseConsole(console)
+	result.Output = output
+	result.ExitCode = exitCode
+	if !ok {
+		result.ExitCode = -1
+		result.Error = "task microVM stopped before the task finished"
+	} else if exitCode != 0 {
+		result.Error = fmt.Sprintf("task exited with code %d", exitCode)
+	}
+
+	log.Info().
+		Str("task_id", task.ID.String()).
+		Int("exit_code", result.ExitCode).
+		Int64("execution_time_ms", result.ExecutionTime).
+		Msg("Task microVM finished")
+	return result, nil
+}
+
+// vmConfig is the Firecracker configuration file of one VM
+type vmConfig struct {
+	BootSource struct {
+		KernelImagePath string `json:"kernel_image_path"`
+		BootArgs        string `json:"boot_args"`
+	} `json:"boot-source"`
+	Drives        []vmDrive `json:"drives"`
+	MachineConfig struct {
+		VCPUCount  int `json:"vcpu_count"`
+		MemSizeMiB int `json:"mem_size_mib"`
+	} `json:"machine-config"`
+	Logger struct {
+		LogPath string `json:"log_path"`
+		Level   string `json:"level"`
+	} `json:"logger"`
+}
+
+type vmDrive struct {
+	DriveID      string `json:"drive_id"`
+	PathOnHost   string `json:"path_on_host"`
+	IsRootDevice bool   `json:"is_root_device"`
+	IsReadOnly   bool   `json:"is_read_only"`
+}
+
+// bootArgs mount the task drive and hand over to its run script. reboot=k makes
+// Firecracker exit when the guest reboots.
+const bootArgs = `console=ttyS0 reboot=k panic=1 pci=off quiet ro init=/bin/sh -- -c "mount -t ext4 /dev/vdb /mnt && exec /bin/sh /mnt` + runScriptPath + `"`
+
+func (e *Executor) newVMConfig(drive, logPath string) vmConfig {
+	var config vmConfig
+	config.BootSource.KernelImagePath = e.config.KernelImage
+	config.BootSource.BootArgs = bootArgs
+	config.Drives = []vmDrive{
+		{DriveID: "rootfs", PathOnHost: e.config.RootFS, IsRootDevice: true, IsReadOnly: true},
+		{DriveID: "task", PathOnHost: drive},
+	}
+	config.MachineConfig.VCPUCount = e.config.VCPUs
+	config.MachineConfig.MemSizeMiB = e.config.MemoryMiB
+	config.Logger.LogPath = logPath
+	config.Logger.Level = "Warning"
+	return config
+}
+
+// boot runs a VM until the guest shuts down and returns its console output
+func (e *Executor) boot(ctx context.Context, dir, drive string) (string, error) {
+	logPath := filepath.Join(dir, "firecracker.log")
+	if err := os.WriteFile(logPath, nil, 0o600); err != nil {
+		return "", fmt.Errorf("failed to create firecracker log: %w", err)
+	}
+
+	data, err := json.Marshal(e.newVMConfig(drive, logPath))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode VM config: %w", err)
+	}
+	configPath := filepath.Join(dir, "vm.json")
+	if err := os.WriteFile(configPath, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write VM config: %w", err)
+	}
+
+	console := &limitedBuffer{limit: maxConsoleBytes}
+	cmd := exec.CommandContext(ctx, e.config.Binary, "--no-api", "--config-file", configPath)
+	cmd.Stdout = console
+	cmd.Stderr = console
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		vmLog, _ := os.ReadFile(logPath)
+		return "", fmt.Errorf("firecracker failed: %w: %s", err, vmLog)
+	}
+	return console.String(), nil
+}
+
+func executionDurationMilliseconds(duration time.Duration) int64 {
+	if duration <= 0 {
+		return 0
+	}
+	return max(duration.Milliseconds(), 1)
+}
@@ -0,0 +1,155 @@
+package firecracker
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker"
+)
+
+func TestParseConsole(t *testing.T) {
+	console := "[    0.1] kernel noise\r\nparity-task-start\r\nhello\r\nworld\r\nparity-task-exit 3\r\n[    1.2] reboot: Restarting system\r\n"
+	output, exitCode, ok := parseConsole(console)
+	if !ok || exitCode != 3 || output != "hello\nworld\n" {
+		t.Fatalf("parseConsole() = %q, %d, %v", output, exitCode, ok)
+	}
+
+	if _, _, ok := parseConsole("parity-task-start\nKernel panic\n"); ok {
+		t.Fatal("a console without an exit marker should not report an exit code")
+	}
+}
+
+func TestNewRunSpec(t *testing.T) {
+	image := &docker.ImageConfig{
+		Entrypoint: []string{"/entry"},
+		Cmd:        []string{"serve"},
+		Env:        []string{"PATH=/usr/bin:/bin"},
+		WorkingDir: "/app",
+	}
+
+	spec, err := newRunSpec(&models.Task{Nonce: "n"}, image)
+	if err != nil {
+		t.Fatalf("newRunSpec() error = %v", err)
+	}
+	if !slices.Equal(spec.command, []string{"/entry", "serve"}) || spec.taskCommand != nil || spec.workdir != "/app" {
+		t.Fatalf("spec from image = %+v", spec)
+	}
+
+	task := &models.Task{Nonce: "n", Environment: &models.EnvironmentConfig{Type: "docker", Config: map[string]interface{}{
+		"command": []interface{}{"echo", "it's"},
+		"workdir": "/",
+		"env":     []interface{}{"MODE=test"},
+	}}}
+	spec, err = newRunSpec(task, image)
+	if err != nil {
+		t.Fatalf("newRunSpec() error = %v", err)
+	}
+	if !slices.Equal(spec.command, []string{"echo", "it's"}) || !slices.Equal(spec.env, []string{"PATH=/usr/bin:/bin", "TASK_NONCE=n", "MODE=test"}) {
+		t.Fatalf("spec from task = %+v", spec)
+	}
+
+	script := spec.runScript()
+	for _, want := range []string{`export 'MODE=test'`, `exec chroot /mnt 'echo' 'it'\''s'`, `echo "parity-task-exit $?"`, "reboot -f"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("run script is missing %q:\n%s", want, script)
+		}
+	}
+
+	if _, err := newRunSpec(&models.Task{}, &docker.ImageConfig{}); err == nil {
+		t.Fatal("expected an error when neither task nor image set a command")
+	}
+}
+
+type archiveEntry struct {
+	tar.Header
+	body string
+}
+
+func writeArchive(t *testing.T, entries []archiveEntry) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "image.tar")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := tar.NewWriter(file)
+	for _, entry := range entries {
+		entry.Size = int64(len(entry.body))
+		if err := writer.WriteHeader(&entry.Header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractArchive(t *testing.T) {
+	archive := writeArchive(t, []archiveEntry{
+		{Header: tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0o755}},
+		{Header: tar.Header{Name: "bin/tool", Typeflag: tar.TypeReg, Mode: 0o755}, body: "#!/bin/sh\n"},
+		{Header: tar.Header{Name: "bin/alias", Typeflag: tar.TypeSymlink, Linkname: "tool"}},
+		{Header: tar.Header{Name: "../escape", Typeflag: tar.TypeReg, Mode: 0o644}, body: "x"},
+	})
+	root := filepath.Join(t.TempDir(), "root")
+
+	size, err := extractArchive(archive, root)
+	if err != nil {
+		t.Fatalf("extractArchive() error = %v", err)
+	}
+	if size != int64(len("#!/bin/sh\n")+1) {
+		t.Errorf("size = %d", size)
+	}
+	if info, err := os.Stat(filepath.Join(root, "bin/tool")); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("bin/tool = %v, %v", info, err)
+	}
+	if link, err := os.Readlink(filepath.Join(root, "bin/alias")); err != nil || link != "tool" {
+		t.Errorf("bin/alias = %q, %v", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escape")); err != nil {
+		t.Errorf("../escape should land inside root: %v", err)
+	}
+}
+
+func TestExtractArchiveRefusesWritingThroughSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	archive := writeArchive(t, []archiveEntry{
+		{Header: tar.Header{Name: "etc", Typeflag: tar.TypeSymlink, Linkname: outside}},
+		{Header: tar.Header{Name: "etc/passwd", Typeflag: tar.TypeReg, Mode: 0o644}, body: "root::0:0::/:/bin/sh\n"},
+	})
+
+	if _, err := extractArchive(archive, filepath.Join(t.TempDir(), "root")); err == nil {
+		t.Fatal("expected a file below a symlink to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "passwd")); err == nil {
+		t.Fatal("the archive wrote outside the root")
+	}
+}
+
+func TestNewVMConfig(t *testing.T) {
+	config := Config{KernelImage: "/vm/vmlinux", RootFS: "/vm/rootfs.ext4"}
+	config.applyDefaults()
+	vm := (&Executor{config: config}).newVMConfig("/tmp/task.ext4", "/tmp/fc.log")
+
+	if vm.MachineConfig.VCPUCount != DefaultVCPUs || vm.MachineConfig.MemSizeMiB != DefaultMemoryMiB {
+		t.Errorf("machine config = %+v", vm.MachineConfig)
+	}
+	if len(vm.Drives) != 2 || !vm.Drives[0].IsRootDevice || !vm.Drives[0].IsReadOnly || vm.Drives[1].IsReadOnly {
+		t.Errorf("drives = %+v", vm.Drives)
+	}
+	if !strings.Contains(vm.BootSource.BootArgs, "/mnt"+runScriptPath) {
+		t.Errorf("boot args %q do not run the task script", vm.BootSource.BootArgs)
+	}
+}
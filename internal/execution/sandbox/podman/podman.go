@@ -0,0 +1,102 @@
+// Package podman runs Docker tasks with Podman, which many Linux hosts use
+// rootless instead of Docker. Podman takes the docker command line, so tasks go
+// through the docker sandbox with the podman engine.
+package podman
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker/executils"
+)
+
+// Engine drives the podman CLI. Process checkpoints, egress policies and image
+// rebuilds for build verification need Docker; checkpointed tasks fall back to
+// filesystem snapshots. GPUs are passed as CDI devices.
+var Engine = docker.Engine{Command: "podman", CDI: true}
+
+// Info is the part of `podman info` the runner checks
+type Info struct {
+	Host struct {
+		CgroupVersion     string   `json:"cgroupVersion"`
+		CgroupControllers []string `json:"cgroupControllers"`
+		Security          struct {
+			Rootless bool `json:"rootless"`
+		} `json:"security"`
+	} `json:"host"`
+	Version struct {
+		Version string `json:"Version"`
+	} `json:"version"`
+}
+
+// Rootless reports whether Podman runs without root privileges
+func (i *Info) Rootless() bool {
+	return i.Host.Security.Rootless
+}
+
+// CheckLimits fails when Podman cannot apply the memory and CPU limits every
+// task container is created with. Rootless Podman needs cgroup v2 with the cpu
+// and memory controllers delegated to the user.
+func (i *Info) CheckLimits() error {
+	if !i.Rootless() {
+		return nil
+	}
+	if i.Host.CgroupVersion != "v2" {
+		return fmt.Errorf("rootless podman needs cgroup v2 to limit task resources, found %s", i.Host.CgroupVersion)
+	}
+	for _, controller := range []string{"cpu", "memory"} {
+		if !slices.Contains(i.Host.CgroupControllers, controller) {
+			return fmt.Errorf("rootless podman cannot limit task resources, delegate the %s cgroup controller to the runner user", controller)
+		}
+	}
+	return nil
+}
+
+func parseInfo(data []byte) (*Info, error) {
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse podman info: %w", err)
+	}
+	return &info, nil
+}
+
+// GetInfo queries the local Podman installation
+func GetInfo(ctx context.Context) (*Info, error) {
+	output, err := executils.ExecCommand(ctx, Engine.Command, "info", "--format", "json")
+	if err != nil {
+		return nil, fmt.Errorf("podman not available: %w", err)
+	}
+	return parseInfo(output)
+}
+
+// NewExecutor checks that Podman can run task containers and creates an
+// executor for it
+func NewExecutor(config *docker.ExecutorConfig) (*docker.DockerExecutor, error) {
+	log := gologger.WithComponent("podman")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	info, err := GetInfo(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Podman not available")
+		return nil, err
+	}
+	if err := info.CheckLimits(); err != nil {
+		log.Error().Err(err).Msg("Podman cannot run task containers")
+		return nil, err
+	}
+
+	log.Info().
+		Str("version", info.Version.Version).
+		Bool("rootless", info.Rootless()).
+		Msg("Running Docker tasks with Podman")
+
+	return docker.NewExecutorWithEngine(config, Engine)
+}
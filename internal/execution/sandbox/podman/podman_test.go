@@ -0,0 +1,61 @@
+package podman
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckLimits(t *testing.T) {
+	cases := []struct {
+		name string
+		info string
+		want string
+	}{
+		{
+			name: "rootful",
+			info: `{"host":{"cgroupVersion":"v1","security":{"rootless":false}}}`,
+		},
+		{
+			name: "rootless with delegated controllers",
+			info: `{"host":{"cgroupVersion":"v2","cgroupControllers":["cpu","memory","pids"],"security":{"rootless":true}},"version":{"Version":"5.0.2"}}`,
+		},
+		{
+			name: "rootless on cgroup v1",
+			info: `{"host":{"cgroupVersion":"v1","security":{"rootless":true}}}`,
+			want: "cgroup v2",
+		},
+		{
+			name: "rootless without memory controller",
+			info: `{"host":{"cgroupVersion":"v2","cgroupControllers":["cpu","pids"],"security":{"rootless":true}}}`,
+			want: "memory cgroup controller",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := parseInfo([]byte(tc.info))
+			if err != nil {
+				t.Fatalf("parseInfo() error = %v", err)
+			}
+			err = info.CheckLimits()
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("CheckLimits() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("CheckLimits() error = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestEngineLeavesOutDockerOnlyFeatures(t *testing.T) {
+	if Engine.CRIU || Engine.Firewall || Engine.Buildx {
+		t.Fatalf("Engine = %+v, want no Docker-only features", Engine)
+	}
+	if !Engine.CDI {
+		t.Fatal("Engine should pass GPUs as CDI devices")
+	}
+}
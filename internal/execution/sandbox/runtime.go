@@ -0,0 +1,88 @@
+// Package sandbox selects the container runtime that runs Docker tasks
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/firecracker"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/podman"
+	"github.com/theblitlabs/parity-runner/internal/gpu"
+)
+
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+)
+
+// ContainerRuntime runs Docker tasks in containers
+type ContainerRuntime interface {
+	ExecuteTask(ctx context.Context, task *models.Task) (*models.TaskResult, error)
+}
+
+// ImagePreparer is implemented by runtimes that can make a task's image
+// available without running it. PrepareImage returns the image ID.
+type ImagePreparer interface {
+	PrepareImage(ctx context.Context, image, imageURL string) (string, error)
+}
+
+// GarbageCollector is implemented by runtimes that can remove the containers and
+// files earlier runs of the runner left behind
+type GarbageCollector interface {
+	CollectGarbage(ctx context.Context) (docker.GarbageReport, error)
+}
+
+// ParseRuntime validates a configured runtime name, defaulting to Docker
+func ParseRuntime(name string) (string, error) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "":
+		return RuntimeDocker, nil
+	case RuntimeDocker, RuntimePodman:
+		return name, nil
+	default:
+		return "", fmt.Errorf("unknown container runtime %q, expected %q or %q", name, RuntimeDocker, RuntimePodman)
+	}
+}
+
+// NewRuntime creates the named container runtime
+func NewRuntime(name string, config *docker.ExecutorConfig) (ContainerRuntime, error) {
+	var executor *docker.DockerExecutor
+	var err error
+	switch name {
+	case RuntimeDocker:
+		executor, err = docker.NewDockerExecutor(config)
+	case RuntimePodman:
+		executor, err = podman.NewExecutor(config)
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return executor, nil
+}
+
+// RuntimeEngine is the CLI the named runtime runs containers with
+func RuntimeEngine(name string) docker.Engine {
+	if name == RuntimePodman {
+		return podman.Engine
+	}
+	return docker.DockerEngine
+}
+
+// NewVMRuntime creates the Firecracker runtime for tasks that ask for VM
+// isolation. Their images are pulled and exported with the named runtime's CLI.
+func NewVMRuntime(name string, config firecracker.Config) (ContainerRuntime, error) {
+	return firecracker.NewExecutor(config, RuntimeEngine(name))
+}
+
+// DetectGPUs lists the GPUs containers of the named runtime can be given
+func DetectGPUs(ctx context.Context, name string) ([]models.GPUInfo, error) {
+	if name == RuntimePodman {
+		return gpu.DetectCDI(ctx)
+	}
+	return gpu.Detect(ctx)
+}
@@ -0,0 +1,21 @@
+package sandbox
+
+import "testing"
+
+func TestParseRuntime(t *testing.T) {
+	cases := map[string]string{
+		"":         RuntimeDocker,
+		"docker":   RuntimeDocker,
+		" Podman ": RuntimePodman,
+	}
+	for input, want := range cases {
+		got, err := ParseRuntime(input)
+		if err != nil || got != want {
+			t.Errorf("ParseRuntime(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	if _, err := ParseRuntime("containerd"); err == nil {
+		t.Error("ParseRuntime(containerd) succeeded, want error")
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"time"
 
@@ -15,37 +14,56 @@ import (
 
 	"github.com/theblitlabs/parity-runner/internal/core/models"
 	"github.com/theblitlabs/parity-runner/internal/execution/llm"
-	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox"
 	"github.com/theblitlabs/parity-runner/internal/execution/training"
+	"github.com/theblitlabs/parity-runner/internal/execution/wasm"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
 )
 
 type Executor struct {
 	ollamaExecutor *llm.OllamaExecutor
-	dockerExecutor *docker.DockerExecutor
+	containers     sandbox.ContainerRuntime
+	vms            sandbox.ContainerRuntime
+	content        ipfs.Backend
+	wasmLimits     wasm.Limits
 }
 
-func NewExecutor() *Executor {
-	// Detect available CPUs and set reasonable limits
-	cpuLimit := "8.0" // Default safe value
-	if runtime.GOMAXPROCS(0) < 8 {
-		cpuLimit = fmt.Sprintf("%.1f", float64(runtime.GOMAXPROCS(0)))
+// NewExecutor runs Docker tasks on containers, which may be nil when the runner
+// has no container runtime
+func NewExecutor(containers sandbox.ContainerRuntime) *Executor {
+	return &Executor{
+		ollamaExecutor: llm.NewOllamaExecutor("http://localhost:11434"),
+		containers:     containers,
+		content:        ipfs.NewClientFromEnv(),
+		wasmLimits:     wasm.DefaultLimits(),
 	}
+}
 
-	dockerExecutor, err := docker.NewDockerExecutor(&docker.ExecutorConfig{
-		MemoryLimit:      "8g", // Reduced from 16g to be more reasonable
-		CPULimit:         cpuLimit,
-		Timeout:          15 * time.Minute,
-		ExecutionTimeout: 25 * time.Minute,
-	})
-	if err != nil {
-		log := gologger.WithComponent("task_executor")
-		log.Error().Err(err).Msg("Failed to create Docker executor")
-	}
+// SetWasmLimits sets the most memory and fuel a wasm task may use. Tasks can ask
+// for less but not more.
+func (e *Executor) SetWasmLimits(limits wasm.Limits) {
+	e.wasmLimits = limits
+}
 
-	return &Executor{
-		ollamaExecutor: llm.NewOllamaExecutor("http://localhost:11434"),
-		dockerExecutor: dockerExecutor,
+// SetContent sets where prompts, data and wasm modules referenced by CID are
+// fetched from
+func (e *Executor) SetContent(content ipfs.Backend) {
+	e.content = content
+}
+
+// SetVMRuntime runs Docker tasks that ask for VM isolation with vms
+func (e *Executor) SetVMRuntime(vms sandbox.ContainerRuntime) {
+	e.vms = vms
+}
+
+// TaskTypes are the kinds of task the executor runs. Docker tasks need a
+// container runtime.
+func (e *Executor) TaskTypes() []models.TaskType {
+	types := []models.TaskType{models.TaskTypeCommand, models.TaskTypeLLM, models.TaskTypeFederatedLearning, models.TaskTypeWasm, models.TaskTypePreflight}
+	if e.containers != nil {
+		types = append(types, models.TaskTypeDocker)
 	}
+	return types
 }
 
 func (e *Executor) ExecuteTask(ctx context.Context, task *models.Task) (*models.TaskResult, error) {
@@ -68,6 +86,10 @@ func (e *Executor) ExecuteTask(ctx context.Context, task *models.Task) (*models.
 		return e.executeFederatedLearningTask(ctx, task)
 	case models.TaskTypeDocker:
 		return e.executeDockerTask(ctx, task)
+	case models.TaskTypeWasm:
+		return e.executeWasmTask(ctx, task)
+	case models.TaskTypePreflight:
+		return e.executePreflightTask(ctx, task)
 	default:
 		return nil, fmt.Errorf("unsupported task type: %s", task.Type)
 	}
@@ -94,6 +116,10 @@ func (e *Executor) executeCommand(ctx context.Context, task *models.Task) (*mode
 	if config.Timeout == 0 {
 		config.Timeout = 300 // 5 minutes default
 	}
+	// The server's maximum duration wins over a longer command timeout
+	if limit := int(task.MaxDuration() / time.Second); limit > 0 && config.Timeout > limit {
+		config.Timeout = limit
+	}
 
 	// Create command context with timeout
 	cmdCtx, cancel := context.WithTimeout(ctx, time.Duration(config.Timeout)*time.Second)
@@ -162,8 +188,10 @@ func (e *Executor) executeLLMTask(ctx context.Context, task *models.Task) (*mode
 
 	// Extract model and prompt from task
 	var config struct {
-		Model  string `json:"model"`
-		Prompt string `json:"prompt"`
+		Model        string `json:"model"`
+		Prompt       string `json:"prompt"`
+		PromptCID    string `json:"prompt_cid"`
+		PromptSHA256 string `json:"prompt_sha256"`
 	}
 
 	if err := json.Unmarshal(task.Config, &config); err != nil {
@@ -176,6 +204,13 @@ func (e *Executor) executeLLMTask(ctx context.Context, task *models.Task) (*mode
 	}
 
 	prompt := config.Prompt
+	if prompt == "" && config.PromptCID != "" {
+		data, err := e.content.Fetch(ctx, config.PromptCID, ipfs.DefaultMaxFetchBytes, config.PromptSHA256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve prompt_cid: %w", err)
+		}
+		prompt = string(data)
+	}
 	if prompt == "" {
 		return nil, fmt.Errorf("prompt is required for LLM task")
 	}
@@ -218,50 +253,27 @@ func (e *Executor) executeFederatedLearningTask(ctx context.Context, task *model
 		Str("task_id", task.ID.String()).
 		Msg("Starting federated learning task execution")
 
-	var config struct {
-		SessionID       string                 `json:"session_id"`
-		RoundID         string                 `json:"round_id"`
-		ModelType       string                 `json:"model_type"`
-		DatasetCID      string                 `json:"dataset_cid"`
-		DataFormat      string                 `json:"data_format"`
-		ModelConfig     map[string]interface{} `json:"model_config"`
-		TrainConfig     map[string]interface{} `json:"train_config"`
-		PartitionConfig map[string]interface{} `json:"partition_config"`
-		OutputFormat    string                 `json:"output_format"`
-	}
-
-	if err := json.Unmarshal(task.Config, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse federated learning config: %w", err)
-	}
-
-	// Validate required fields
-	if config.ModelType == "" {
-		return nil, fmt.Errorf("model_type is required")
-	}
-	if config.DatasetCID == "" {
-		return nil, fmt.Errorf("dataset_cid is required")
-	}
-	if config.DataFormat == "" {
-		return nil, fmt.Errorf("data_format is required")
-	}
-	if config.SessionID == "" {
-		return nil, fmt.Errorf("session_id is required")
-	}
-	if config.RoundID == "" {
-		return nil, fmt.Errorf("round_id is required")
+	config, err := parseFederatedLearningConfig(task)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create appropriate trainer based on model type
 	var trainer training.Trainer
-	var err error
 
 	switch config.ModelType {
 	case "neural_network":
 		trainer, err = training.NewNeuralNetworkTrainer(config.ModelConfig)
 	case "linear_regression":
 		trainer, err = training.NewLinearRegressionTrainer(config.ModelConfig)
+	case "logistic_regression":
+		trainer, err = training.NewLogisticRegressionTrainer(config.ModelConfig)
 	case "random_forest":
 		trainer, err = training.NewRandomForestTrainer(config.ModelConfig)
+	case "gradient_boosting":
+		trainer, err = training.NewGradientBoostingTrainer(config.ModelConfig)
+	case "kmeans":
+		trainer, err = training.NewKMeansTrainer(config.ModelConfig)
 	default:
 		return nil, fmt.Errorf("unsupported model type: %s", config.ModelType)
 	}
@@ -311,6 +323,12 @@ func (e *Executor) executeFederatedLearningTask(ctx context.Context, task *model
 		} else if rfTrainer, ok := trainer.(*training.RandomForestTrainer); ok {
 			// Random forest trainer supports partitioned data loading
 			features, labels, err = rfTrainer.LoadPartitionedData(ctx, config.DatasetCID, config.DataFormat, partitionConfig)
+		} else if gbTrainer, ok := trainer.(*training.GradientBoostingTrainer); ok {
+			features, labels, err = gbTrainer.LoadPartitionedData(ctx, config.DatasetCID, config.DataFormat, partitionConfig)
+		} else if logTrainer, ok := trainer.(*training.LogisticRegressionTrainer); ok {
+			features, labels, err = logTrainer.LoadPartitionedData(ctx, config.DatasetCID, config.DataFormat, partitionConfig)
+		} else if kmTrainer, ok := trainer.(*training.KMeansTrainer); ok {
+			features, labels, err = kmTrainer.LoadPartitionedData(ctx, config.DatasetCID, config.DataFormat, partitionConfig)
 		} else {
 			// Fallback for other trainer types
 			features, labels, err = trainer.LoadData(ctx, config.DatasetCID, config.DataFormat)
@@ -346,28 +364,9 @@ func (e *Executor) executeFederatedLearningTask(ctx context.Context, task *model
 		Int("features_per_sample", len(features[0])).
 		Msg("Training data loaded successfully")
 
-	// Extract training parameters - all values must be provided
-	var epochs, batchSize int
-	var learningRate float64
-	var hasEpochs, hasBatchSize, hasLearningRate bool
-
-	if config.TrainConfig != nil {
-		if e, ok := config.TrainConfig["epochs"].(float64); ok {
-			epochs = int(e)
-			hasEpochs = true
-		}
-		if b, ok := config.TrainConfig["batch_size"].(float64); ok {
-			batchSize = int(b)
-			hasBatchSize = true
-		}
-		if lr, ok := config.TrainConfig["learning_rate"].(float64); ok {
-			learningRate = lr
-			hasLearningRate = true
-		}
-	}
-
-	if !hasEpochs || !hasBatchSize || !hasLearningRate || epochs <= 0 || batchSize <= 0 || learningRate <= 0 {
-		return nil, fmt.Errorf("training configuration is incomplete - epochs (%d), batch_size (%d), and learning_rate (%f) must all be provided and positive", epochs, batchSize, learningRate)
+	epochs, batchSize, learningRate, err := config.trainParams()
+	if err != nil {
+		return nil, err
 	}
 
 	// Train the model
@@ -387,6 +386,15 @@ func (e *Executor) executeFederatedLearningTask(ctx context.Context, task *model
 	} else if rfTrainer, ok := trainer.(*training.RandomForestTrainer); ok {
 		weightsMap = rfTrainer.GetModelWeights()
 		gradientsMap = rfTrainer.GetGradients()
+	} else if gbTrainer, ok := trainer.(*training.GradientBoostingTrainer); ok {
+		weightsMap = gbTrainer.GetModelWeights()
+		gradientsMap = gbTrainer.GetGradients()
+	} else if logTrainer, ok := trainer.(*training.LogisticRegressionTrainer); ok {
+		weightsMap = logTrainer.GetModelWeights()
+		gradientsMap = logTrainer.GetGradients()
+	} else if kmTrainer, ok := trainer.(*training.KMeansTrainer); ok {
+		weightsMap = kmTrainer.GetModelWeights()
+		gradientsMap = kmTrainer.GetGradients()
 	} else {
 		// Fallback: convert gradients array to map format
 		gradientsMap = map[string][]float64{
@@ -401,49 +409,74 @@ func (e *Executor) executeFederatedLearningTask(ctx context.Context, task *model
 	var output string
 	switch config.OutputFormat {
 	case "json":
-		outputData := map[string]interface{}{
-			"session_id":    config.SessionID,
-			"round_id":      config.RoundID,
-			"gradients":     gradientsMap,
-			"weights":       weightsMap,
-			"loss":          loss,
-			"accuracy":      accuracy,
-			"data_size":     len(features),
-			"training_time": 1000, // Placeholder training time in ms
-			"metadata": map[string]interface{}{
-				"model_type":     config.ModelType,
-				"epochs":         epochs,
-				"batch_size":     batchSize,
-				"learning_rate":  learningRate,
-				"dataset_cid":    config.DatasetCID,
-				"data_format":    config.DataFormat,
-				"feature_count":  len(features[0]),
-				"sample_count":   len(features),
-				"partition_info": config.PartitionConfig,
+		trainingOutput := models.TrainingOutput{
+			SchemaVersion: models.TrainingOutputVersion,
+			SessionID:     config.SessionID,
+			RoundID:       config.RoundID,
+			Gradients:     gradientsMap,
+			Weights:       weightsMap,
+			Loss:          loss,
+			Accuracy:      accuracy,
+			DataSize:      len(features),
+			TrainingTime:  1000, // Placeholder training time in ms
+			Metadata: models.TrainingMetadata{
+				ModelType:     config.ModelType,
+				Epochs:        epochs,
+				BatchSize:     batchSize,
+				LearningRate:  learningRate,
+				DatasetCID:    config.DatasetCID,
+				DataFormat:    config.DataFormat,
+				FeatureCount:  len(features[0]),
+				SampleCount:   len(features),
+				PartitionInfo: config.PartitionConfig,
 			},
 		}
+		modelSpecific := func(family string, keys ...string) {
+			settings := make(map[string]interface{}, len(keys))
+			for _, key := range keys {
+				settings[key] = config.ModelConfig[key]
+			}
+			trainingOutput.Metadata.ModelSpecific = map[string]map[string]interface{}{family: settings}
+		}
 
-		// Add random forest specific metadata
+		// Add model specific metrics and settings
+		var metricsErr error
 		if rfTrainer, ok := trainer.(*training.RandomForestTrainer); ok {
-			outputData["rf_metrics"] = map[string]interface{}{
-				"feature_importance": rfTrainer.GetFeatureImportance(),
-				"oob_error":          rfTrainer.GetOOBError(),
-				"tree_count":         len(rfTrainer.GetTrees()),
-			}
-			outputData["metadata"].(map[string]interface{})["model_specific"] = map[string]interface{}{
-				"random_forest": map[string]interface{}{
-					"num_trees":         config.ModelConfig["num_trees"],
-					"max_depth":         config.ModelConfig["max_depth"],
-					"min_samples_split": config.ModelConfig["min_samples_split"],
-					"min_samples_leaf":  config.ModelConfig["min_samples_leaf"],
-					"max_features":      config.ModelConfig["max_features"],
-					"subsample":         config.ModelConfig["subsample"],
-					"bootstrap_samples": config.ModelConfig["bootstrap_samples"],
-					"oob_score":         config.ModelConfig["oob_score"],
-				},
-			}
+			metricsErr = trainingOutput.SetMetrics(models.ModelFamilyRandomForest, models.RandomForestMetrics{
+				FeatureImportance: rfTrainer.GetFeatureImportance(),
+				OOBError:          rfTrainer.GetOOBError(),
+				TreeCount:         len(rfTrainer.GetTrees()),
+			})
+			modelSpecific(models.ModelFamilyRandomForest, "num_trees", "max_depth", "min_samples_split", "min_samples_leaf",
+				"max_features", "subsample", "bootstrap_samples", "oob_score")
+		}
+		if gbTrainer, ok := trainer.(*training.GradientBoostingTrainer); ok {
+			metricsErr = trainingOutput.SetMetrics(models.ModelFamilyGradientBoosting, models.GradientBoostingMetrics{
+				FeatureImportance: gbTrainer.GetFeatureImportance(),
+				BestIteration:     gbTrainer.GetBestIteration(),
+				TreeCount:         len(gbTrainer.GetTrees()),
+				Objective:         gbTrainer.GetObjective(),
+			})
+			modelSpecific(models.ModelFamilyGradientBoosting, "num_rounds", "max_depth", "learning_rate", "subsample",
+				"lambda", "gamma", "early_stopping_rounds")
+		}
+		if logTrainer, ok := trainer.(*training.LogisticRegressionTrainer); ok {
+			modelSpecific(models.ModelFamilyLogisticRegression, "num_classes", "penalty", "alpha", "l1_ratio")
+			trainingOutput.Metadata.ModelSpecific[models.ModelFamilyLogisticRegression]["non_zero_coefficients"] = logTrainer.GetNonZeroCoefficients()
 		}
-		outputBytes, err := json.MarshalIndent(outputData, "", "  ")
+		if kmTrainer, ok := trainer.(*training.KMeansTrainer); ok {
+			metricsErr = trainingOutput.SetMetrics(models.ModelFamilyKMeans, models.KMeansMetrics{
+				Centroids:    kmTrainer.GetCentroids(),
+				ClusterSizes: kmTrainer.GetClusterSizes(),
+				Inertia:      kmTrainer.GetInertia(),
+				Iterations:   kmTrainer.GetIterations(),
+			})
+			modelSpecific(models.ModelFamilyKMeans, "num_clusters", "init", "n_init", "tolerance")
+		}
+		if metricsErr != nil {
+			return nil, metricsErr
+		}
+		outputBytes, err := json.MarshalIndent(trainingOutput, "", "  ")
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal output: %w", err)
 		}
@@ -462,6 +495,70 @@ func (e *Executor) executeFederatedLearningTask(ctx context.Context, task *model
 	}, nil
 }
 
+type federatedLearningConfig struct {
+	SessionID       string                 `json:"session_id"`
+	RoundID         string                 `json:"round_id"`
+	ModelType       string                 `json:"model_type"`
+	DatasetCID      string                 `json:"dataset_cid"`
+	DataFormat      string                 `json:"data_format"`
+	ModelConfig     map[string]interface{} `json:"model_config"`
+	TrainConfig     map[string]interface{} `json:"train_config"`
+	PartitionConfig map[string]interface{} `json:"partition_config"`
+	OutputFormat    string                 `json:"output_format"`
+}
+
+func parseFederatedLearningConfig(task *models.Task) (*federatedLearningConfig, error) {
+	var config federatedLearningConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse federated learning config: %w", err)
+	}
+
+	// Validate required fields
+	if config.ModelType == "" {
+		return nil, fmt.Errorf("model_type is required")
+	}
+	if config.DatasetCID == "" {
+		return nil, fmt.Errorf("dataset_cid is required")
+	}
+	if config.DataFormat == "" {
+		return nil, fmt.Errorf("data_format is required")
+	}
+	if config.SessionID == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+	if config.RoundID == "" {
+		return nil, fmt.Errorf("round_id is required")
+	}
+	return &config, nil
+}
+
+// trainParams extracts the training parameters - all values must be provided
+func (c *federatedLearningConfig) trainParams() (int, int, float64, error) {
+	var epochs, batchSize int
+	var learningRate float64
+	var hasEpochs, hasBatchSize, hasLearningRate bool
+
+	if c.TrainConfig != nil {
+		if e, ok := c.TrainConfig["epochs"].(float64); ok {
+			epochs = int(e)
+			hasEpochs = true
+		}
+		if b, ok := c.TrainConfig["batch_size"].(float64); ok {
+			batchSize = int(b)
+			hasBatchSize = true
+		}
+		if lr, ok := c.TrainConfig["learning_rate"].(float64); ok {
+			learningRate = lr
+			hasLearningRate = true
+		}
+	}
+
+	if !hasEpochs || !hasBatchSize || !hasLearningRate || epochs <= 0 || batchSize <= 0 || learningRate <= 0 {
+		return 0, 0, 0, fmt.Errorf("training configuration is incomplete - epochs (%d), batch_size (%d), and learning_rate (%f) must all be provided and positive", epochs, batchSize, learningRate)
+	}
+	return epochs, batchSize, learningRate, nil
+}
+
 // Helper functions to safely extract values from maps
 func getStringFromMap(m map[string]interface{}, key, defaultValue string) string {
 	if val, ok := m[key].(string); ok {
@@ -495,11 +592,17 @@ func (e *Executor) executeDockerTask(ctx context.Context, task *models.Task) (*m
 		Str("task_id", task.ID.String()).
 		Msg("Executing Docker task")
 
-	if e.dockerExecutor == nil {
-		return nil, fmt.Errorf("docker executor not available")
+	if task.RequiresVM() {
+		if e.vms == nil {
+			return nil, fmt.Errorf("vm isolation not available")
+		}
+		return e.vms.ExecuteTask(ctx, task)
+	}
+	if e.containers == nil {
+		return nil, fmt.Errorf("container runtime not available")
 	}
 
-	return e.dockerExecutor.ExecuteTask(ctx, task)
+	return e.containers.ExecuteTask(ctx, task)
 }
 
 func executionDurationMilliseconds(duration time.Duration) int64 {
@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/wasm"
 )
 
 func TestExecuteCommandRecordsExecutionTime(t *testing.T) {
@@ -34,3 +35,78 @@ func TestExecutionDurationMillisecondsRoundsSubMillisecondWorkUp(t *testing.T) {
 		t.Fatalf("executionDurationMilliseconds(500us) = %d, want 1", got)
 	}
 }
+
+func TestWasmTaskLimitsTakeTheLowerOfRunnerAndTask(t *testing.T) {
+	executor := &Executor{wasmLimits: wasm.Limits{MemoryBytes: 64 << 20, Fuel: 1000}}
+
+	limits, err := executor.wasmTaskLimits(&models.TaskConfig{
+		Resources: models.ResourceConfig{Memory: "1g"},
+		Wasm:      &models.WasmConfig{Fuel: 10},
+	})
+	if err != nil {
+		t.Fatalf("wasmTaskLimits() error = %v", err)
+	}
+	if limits.MemoryBytes != 64<<20 || limits.Fuel != 10 {
+		t.Fatalf("wasmTaskLimits() = %+v, want 64 MiB of memory and 10 fuel", limits)
+	}
+}
+
+func TestExecuteWasmTask(t *testing.T) {
+	executor := &Executor{wasmLimits: wasm.DefaultLimits()}
+	// a module whose _start returns straight away
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00,
+		0x01, 0x04, 0x01, 0x60, 0x00, 0x00,
+		0x03, 0x02, 0x01, 0x00,
+		0x07, 0x0a, 0x01, 0x06, '_', 's', 't', 'a', 'r', 't', 0x00, 0x00,
+		0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b}
+	config, err := json.Marshal(models.TaskConfig{Wasm: &models.WasmConfig{Module: module}})
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+
+	result, err := executor.ExecuteTask(context.Background(), &models.Task{
+		ID:     uuid.New(),
+		Type:   models.TaskTypeWasm,
+		Config: config,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteTask() error = %v", err)
+	}
+	if result.ExitCode != 0 || result.Error != "" {
+		t.Fatalf("ExecuteTask() = exit code %d, error %q, want a clean exit", result.ExitCode, result.Error)
+	}
+}
+
+type recordingRuntime struct {
+	name  string
+	tasks []*models.Task
+}
+
+func (r *recordingRuntime) ExecuteTask(ctx context.Context, task *models.Task) (*models.TaskResult, error) {
+	r.tasks = append(r.tasks, task)
+	return &models.TaskResult{TaskID: task.ID, Output: r.name}, nil
+}
+
+func TestExecuteDockerTaskRunsVMTasksInVMs(t *testing.T) {
+	containers := &recordingRuntime{name: "container"}
+	executor := &Executor{containers: containers}
+	task := &models.Task{ID: uuid.New(), Type: models.TaskTypeDocker, IsolationLevel: models.IsolationVM}
+
+	if _, err := executor.ExecuteTask(context.Background(), task); err == nil {
+		t.Fatal("expected a VM task to fail without a VM runtime")
+	}
+	if len(containers.tasks) != 0 {
+		t.Fatal("a VM task must not fall back to a container")
+	}
+
+	executor.SetVMRuntime(&recordingRuntime{name: "vm"})
+	result, err := executor.ExecuteTask(context.Background(), task)
+	if err != nil || result.Output != "vm" {
+		t.Fatalf("ExecuteTask() = %+v, %v, want the VM runtime", result, err)
+	}
+
+	task.IsolationLevel = models.IsolationContainer
+	if result, err := executor.ExecuteTask(context.Background(), task); err != nil || result.Output != "container" {
+		t.Fatalf("ExecuteTask() = %+v, %v, want the container runtime", result, err)
+	}
+}
@@ -0,0 +1,264 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+var (
+	ErrQueueFull        = errors.New("task queue is full")
+	ErrExceedsCapacity  = errors.New("task needs more resources than the runner has")
+	ErrPoolStopped      = errors.New("worker pool is stopped")
+	ErrAlreadySubmitted = errors.New("task is already queued or running")
+)
+
+// Reservation is the share of the host a task holds while it runs
+type Reservation struct {
+	CPUs        float64 `json:"cpus"`
+	MemoryBytes int64   `json:"memory_bytes"`
+}
+
+func (r Reservation) fits(used, capacity Reservation) bool {
+	if capacity.CPUs > 0 && used.CPUs+r.CPUs > capacity.CPUs {
+		return false
+	}
+	if capacity.MemoryBytes > 0 && used.MemoryBytes+r.MemoryBytes > capacity.MemoryBytes {
+		return false
+	}
+	return true
+}
+
+// PoolConfig sizes the worker pool. Capacity is what tasks may reserve in total,
+// a zero field leaving that resource unlimited. Tasks that do not request
+// resources reserve Default. QueueSize is how many accepted tasks may wait for a
+// worker.
+type PoolConfig struct {
+	MaxConcurrent int
+	QueueSize     int
+	Capacity      Reservation
+	Default       Reservation
+}
+
+type poolJob struct {
+	task        *models.Task
+	reservation Reservation
+	run         func()
+}
+
+// Pool runs up to MaxConcurrent tasks at once. Accepted tasks wait in a FIFO
+// queue until a worker is free and their reservation fits in what is left of
+// the capacity; the queue is not reordered so large tasks are not starved.
+type Pool struct {
+	config  PoolConfig
+	mu      sync.Mutex
+	queue   []poolJob
+	running map[string]Reservation
+	used    Reservation
+	stopped bool
+	idle    *sync.Cond
+}
+
+func NewPool(config PoolConfig) *Pool {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 1
+	}
+	if config.QueueSize < 0 {
+		config.QueueSize = 0
+	}
+	pool := &Pool{
+		config:  config,
+		running: make(map[string]Reservation),
+	}
+	pool.idle = sync.NewCond(&pool.mu)
+	return pool
+}
+
+// ReservationFor is what the task asks for in its resources, falling back to def
+// for anything it leaves out. CPU shares follow Docker, 1024 shares to a CPU.
+func ReservationFor(task *models.Task, def Reservation) (Reservation, error) {
+	reservation := def
+
+	var config models.TaskConfig
+	if len(task.Config) == 0 || json.Unmarshal(task.Config, &config) != nil {
+		return reservation, nil
+	}
+	if config.Resources.CPUShares > 0 {
+		reservation.CPUs = float64(config.Resources.CPUShares) / 1024
+	}
+	if config.Resources.Memory != "" {
+		memory, err := ParseMemory(config.Resources.Memory)
+		if err != nil {
+			return Reservation{}, fmt.Errorf("invalid memory request %q: %w", config.Resources.Memory, err)
+		}
+		reservation.MemoryBytes = memory
+	}
+	return reservation, nil
+}
+
+// ParseMemory parses Docker style sizes such as "512m" or "8g"
+func ParseMemory(size string) (int64, error) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	size = strings.TrimSuffix(strings.TrimSuffix(size, "ib"), "b")
+	if size == "" {
+		return 0, errors.New("empty size")
+	}
+
+	multiplier := int64(1)
+	switch size[len(size)-1] {
+	case 'k':
+		multiplier = 1 << 10
+	case 'm':
+		multiplier = 1 << 20
+	case 'g':
+		multiplier = 1 << 30
+	case 't':
+		multiplier = 1 << 40
+	}
+	if multiplier > 1 {
+		size = size[:len(size)-1]
+	}
+
+	value, err := strconv.ParseFloat(size, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// Submit queues run for the task. It fails without queueing when the queue is
+// full or the task could never fit in the pool's capacity.
+func (p *Pool) Submit(task *models.Task, run func()) error {
+	reservation, err := ReservationFor(task, p.config.Default)
+	if err != nil {
+		return err
+	}
+	if !reservation.fits(Reservation{}, p.config.Capacity) {
+		return fmt.Errorf("%w: needs %.2f CPUs and %d bytes of memory", ErrExceedsCapacity, reservation.CPUs, reservation.MemoryBytes)
+	}
+
+	taskID := task.ID.String()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return ErrPoolStopped
+	}
+	if _, ok := p.running[taskID]; ok {
+		return ErrAlreadySubmitted
+	}
+	for _, job := range p.queue {
+		if job.task.ID == task.ID {
+			return ErrAlreadySubmitted
+		}
+	}
+	// The queue never holds more than QueueSize, whether its tasks wait for a
+	// worker or for capacity; only a task that starts at once skips it
+	startsNow := len(p.queue) == 0 && len(p.running) < p.config.MaxConcurrent && reservation.fits(p.used, p.config.Capacity)
+	if !startsNow && len(p.queue) >= p.config.QueueSize {
+		return ErrQueueFull
+	}
+
+	p.queue = append(p.queue, poolJob{task: task, reservation: reservation, run: run})
+	p.schedule()
+	return nil
+}
+
+// schedule starts queued jobs in order while the one at the head fits. It must be
+// called with p.mu held.
+func (p *Pool) schedule() {
+	for len(p.queue) > 0 && len(p.running) < p.config.MaxConcurrent {
+		job := p.queue[0]
+		if !job.reservation.fits(p.used, p.config.Capacity) {
+			return
+		}
+		p.queue = p.queue[1:]
+
+		taskID := job.task.ID.String()
+		p.running[taskID] = job.reservation
+		p.used.CPUs += job.reservation.CPUs
+		p.used.MemoryBytes += job.reservation.MemoryBytes
+
+		go p.execute(taskID, job)
+	}
+}
+
+func (p *Pool) execute(taskID string, job poolJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			log := gologger.WithComponent("worker_pool")
+			log.Error().Interface("panic", r).Str("task_id", taskID).Msg("Task worker panicked")
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.running, taskID)
+		p.used.CPUs -= job.reservation.CPUs
+		p.used.MemoryBytes -= job.reservation.MemoryBytes
+		if !p.stopped {
+			p.schedule()
+		}
+		p.idle.Broadcast()
+	}()
+
+	job.run()
+}
+
+// Stop drops queued tasks and waits for running ones to finish
+func (p *Pool) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopped = true
+	p.queue = nil
+	for len(p.running) > 0 {
+		p.idle.Wait()
+	}
+}
+
+// SetMaxConcurrent changes how many tasks run at once. Lowering it lets running
+// tasks finish and holds queued ones back until enough of them have.
+func (p *Pool) SetMaxConcurrent(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config.MaxConcurrent = max(n, 1)
+	if !p.stopped {
+		p.schedule()
+	}
+}
+
+// PoolStatus is a snapshot of the pool for status reporting
+type PoolStatus struct {
+	MaxConcurrent int         `json:"max_concurrent"`
+	Running       int         `json:"running"`
+	Queued        int         `json:"queued"`
+	Reserved      Reservation `json:"reserved"`
+	Capacity      Reservation `json:"capacity"`
+}
+
+func (p *Pool) Status() PoolStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStatus{
+		MaxConcurrent: p.config.MaxConcurrent,
+		Running:       len(p.running),
+		Queued:        len(p.queue),
+		Reserved:      p.used,
+		Capacity:      p.config.Capacity,
+	}
+}
+
+// Full reports whether a new task would be refused
+func (p *Pool) Full() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.running) >= p.config.MaxConcurrent && len(p.queue) >= p.config.QueueSize
+}
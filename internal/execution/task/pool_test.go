@@ -0,0 +1,218 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func poolTask(resources string) *models.Task {
+	return &models.Task{
+		ID:     uuid.New(),
+		Type:   models.TaskTypeDocker,
+		Config: json.RawMessage(`{"resources":` + resources + `}`),
+	}
+}
+
+// blockingRun returns a run function that reports its start and then waits to
+// be released
+func blockingRun(started chan<- string, release <-chan struct{}, name string) func() {
+	return func() {
+		started <- name
+		<-release
+	}
+}
+
+func expectStarted(t *testing.T, started <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-started:
+		if got != want {
+			t.Fatalf("started %s, want %s", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s did not start", want)
+	}
+}
+
+func expectNotStarted(t *testing.T, started <-chan string) {
+	t.Helper()
+	select {
+	case got := <-started:
+		t.Fatalf("%s started while it should be queued", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPoolLimitsConcurrencyAndQueues(t *testing.T) {
+	pool := NewPool(PoolConfig{MaxConcurrent: 2, QueueSize: 1, Default: Reservation{CPUs: 1}})
+	started := make(chan string, 4)
+	release := make(chan struct{})
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err := pool.Submit(poolTask(`{}`), blockingRun(started, release, name)); err != nil {
+			t.Fatalf("Submit(%s) error = %v", name, err)
+		}
+	}
+	if err := pool.Submit(poolTask(`{}`), func() {}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit() beyond the queue error = %v, want ErrQueueFull", err)
+	}
+
+	first, second := <-started, <-started
+	if first+second != "ab" && first+second != "ba" {
+		t.Fatalf("started %s and %s, want a and b", first, second)
+	}
+	expectNotStarted(t, started)
+	if status := pool.Status(); status.Running != 2 || status.Queued != 1 {
+		t.Fatalf("Status() = %+v, want 2 running and 1 queued", status)
+	}
+
+	release <- struct{}{}
+	expectStarted(t, started, "c")
+	close(release)
+	pool.Stop()
+}
+
+func TestPoolSetMaxConcurrentStartsQueuedTasks(t *testing.T) {
+	pool := NewPool(PoolConfig{MaxConcurrent: 1, QueueSize: 1})
+	started := make(chan string, 2)
+	release := make(chan struct{})
+
+	for _, name := range []string{"a", "b"} {
+		if err := pool.Submit(poolTask(`{}`), blockingRun(started, release, name)); err != nil {
+			t.Fatalf("Submit(%s) error = %v", name, err)
+		}
+	}
+	expectStarted(t, started, "a")
+	expectNotStarted(t, started)
+
+	pool.SetMaxConcurrent(2)
+	expectStarted(t, started, "b")
+	if status := pool.Status(); status.MaxConcurrent != 2 || status.Running != 2 {
+		t.Fatalf("Status() = %+v, want 2 of 2 running", status)
+	}
+	close(release)
+	pool.Stop()
+}
+
+func TestPoolReservesResources(t *testing.T) {
+	pool := NewPool(PoolConfig{
+		MaxConcurrent: 4,
+		QueueSize:     4,
+		Capacity:      Reservation{CPUs: 4, MemoryBytes: 8 << 30},
+		Default:       Reservation{CPUs: 1},
+	})
+	started := make(chan string, 4)
+	release := make(chan struct{})
+
+	if err := pool.Submit(poolTask(`{"cpu_shares":3072,"memory":"6g"}`), blockingRun(started, release, "large")); err != nil {
+		t.Fatalf("Submit(large) error = %v", err)
+	}
+	expectStarted(t, started, "large")
+
+	// Only 2g of memory is left, so the next task waits and holds its place in line
+	if err := pool.Submit(poolTask(`{"memory":"4g"}`), blockingRun(started, release, "medium")); err != nil {
+		t.Fatalf("Submit(medium) error = %v", err)
+	}
+	if err := pool.Submit(poolTask(`{"memory":"1g"}`), blockingRun(started, release, "small")); err != nil {
+		t.Fatalf("Submit(small) error = %v", err)
+	}
+	expectNotStarted(t, started)
+	if reserved := pool.Status().Reserved; reserved.CPUs != 3 || reserved.MemoryBytes != 6<<30 {
+		t.Fatalf("reserved = %+v, want 3 CPUs and 6g", reserved)
+	}
+
+	release <- struct{}{}
+	if first, second := <-started, <-started; first+second != "mediumsmall" && first+second != "smallmedium" {
+		t.Fatalf("started %s and %s, want medium and small", first, second)
+	}
+
+	if err := pool.Submit(poolTask(`{"cpu_shares":8192}`), func() {}); !errors.Is(err, ErrExceedsCapacity) {
+		t.Fatalf("Submit() above capacity error = %v, want ErrExceedsCapacity", err)
+	}
+	close(release)
+	pool.Stop()
+}
+
+func TestPoolBoundsTasksWaitingForCapacity(t *testing.T) {
+	pool := NewPool(PoolConfig{
+		MaxConcurrent: 4,
+		QueueSize:     1,
+		Capacity:      Reservation{CPUs: 2},
+		Default:       Reservation{CPUs: 2},
+	})
+	started := make(chan string, 4)
+	release := make(chan struct{})
+
+	if err := pool.Submit(poolTask(`{}`), blockingRun(started, release, "a")); err != nil {
+		t.Fatalf("Submit(a) error = %v", err)
+	}
+	expectStarted(t, started, "a")
+
+	// Workers are free but the capacity is taken, so tasks wait in the queue
+	// and are refused once it is full
+	if err := pool.Submit(poolTask(`{}`), blockingRun(started, release, "b")); err != nil {
+		t.Fatalf("Submit(b) error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := pool.Submit(poolTask(`{}`), func() {}); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("Submit() blocked on capacity error = %v, want ErrQueueFull", err)
+		}
+	}
+	if status := pool.Status(); status.Running != 1 || status.Queued != 1 {
+		t.Fatalf("Status() = %+v, want 1 running and 1 queued", status)
+	}
+
+	release <- struct{}{}
+	expectStarted(t, started, "b")
+	close(release)
+	pool.Stop()
+}
+
+func TestPoolWithoutQueueStartsTasksThatFit(t *testing.T) {
+	pool := NewPool(PoolConfig{MaxConcurrent: 1})
+	release := make(chan struct{})
+
+	if err := pool.Submit(poolTask(`{}`), func() { <-release }); err != nil {
+		t.Fatalf("Submit() to an idle pool error = %v", err)
+	}
+	if err := pool.Submit(poolTask(`{}`), func() {}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit() to a busy pool without a queue error = %v, want ErrQueueFull", err)
+	}
+	close(release)
+	pool.Stop()
+}
+
+func TestPoolRejectsDuplicateTask(t *testing.T) {
+	pool := NewPool(PoolConfig{MaxConcurrent: 1, QueueSize: 1})
+	release := make(chan struct{})
+	task := poolTask(`{}`)
+
+	if err := pool.Submit(task, func() { <-release }); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if err := pool.Submit(task, func() {}); !errors.Is(err, ErrAlreadySubmitted) {
+		t.Fatalf("Submit() of a running task error = %v, want ErrAlreadySubmitted", err)
+	}
+	close(release)
+	pool.Stop()
+	if err := pool.Submit(poolTask(`{}`), func() {}); !errors.Is(err, ErrPoolStopped) {
+		t.Fatalf("Submit() after Stop error = %v, want ErrPoolStopped", err)
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	for size, want := range map[string]int64{"512m": 512 << 20, "8g": 8 << 30, "2GB": 2 << 30, "1.5GiB": 3 << 29, "1024": 1024} {
+		if got, err := ParseMemory(size); err != nil || got != want {
+			t.Errorf("ParseMemory(%q) = %d, %v; want %d", size, got, err, want)
+		}
+	}
+	if _, err := ParseMemory("lots"); err == nil {
+		t.Error("ParseMemory(\"lots\") succeeded, want error")
+	}
+}
@@ -0,0 +1,305 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox"
+	"github.com/theblitlabs/parity-runner/internal/execution/training"
+	"github.com/theblitlabs/parity-runner/internal/execution/wasm"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+)
+
+// defaultPreflightTimeout bounds a preflight task, most of which is pulling the
+// image
+const defaultPreflightTimeout = 10 * time.Minute
+
+// errSkipped marks a check the runner cannot do, as opposed to one the task fails
+type errSkipped string
+
+func (e errSkipped) Error() string {
+	return string(e)
+}
+
+// preflight collects the checks of one preflight task
+type preflight struct {
+	report models.PreflightReport
+}
+
+// run records the outcome of one check. A check that returns errSkipped is
+// skipped, any other error fails it.
+func (p *preflight) run(name string, check func() (string, error)) bool {
+	startedAt := time.Now()
+	detail, err := check()
+	result := models.PreflightCheck{
+		Name:       name,
+		Status:     models.PreflightPassed,
+		Detail:     detail,
+		DurationMs: time.Since(startedAt).Milliseconds(),
+	}
+
+	var skipped errSkipped
+	switch {
+	case errors.As(err, &skipped):
+		result.Status = models.PreflightSkipped
+		result.Detail = skipped.Error()
+	case err != nil:
+		result.Status = models.PreflightFailed
+		result.Detail = err.Error()
+	}
+	p.report.Checks = append(p.report.Checks, result)
+	return result.Status == models.PreflightPassed
+}
+
+// executePreflightTask checks the task in its config the way this runner would
+// run it, short of running it: the config is validated, the image pulled and the
+// content it references fetched and parsed. Problems are reported in the output
+// rather than returned, so the creator gets all of them at once.
+func (e *Executor) executePreflightTask(ctx context.Context, task *models.Task) (*models.TaskResult, error) {
+	startedAt := time.Now()
+	log := gologger.WithComponent("task_executor")
+
+	var config models.TaskConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse preflight config: %w", err)
+	}
+	if err := config.Validate(models.TaskTypePreflight); err != nil {
+		return nil, err
+	}
+
+	timeout := defaultPreflightTimeout
+	if limit := task.MaxDuration(); limit > 0 && timeout > limit {
+		timeout = limit
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	target := config.Preflight.Task(task.Nonce)
+	p := &preflight{report: models.PreflightReport{TaskType: target.Type}}
+
+	log.Info().
+		Str("task_id", task.ID.String()).
+		Str("target_type", string(target.Type)).
+		Msg("Running preflight checks")
+
+	var targetConfig models.TaskConfig
+	if p.run("config", func() (string, error) {
+		if err := target.Validate(); err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal(target.Config, &targetConfig); err != nil {
+			return "", err
+		}
+		return "", e.checkExecutorConfig(target, &targetConfig)
+	}) {
+		switch target.Type {
+		case models.TaskTypeDocker:
+			e.preflightDocker(ctx, p, target, &targetConfig)
+		case models.TaskTypeWasm:
+			e.preflightWasm(ctx, p, &targetConfig)
+		case models.TaskTypeFederatedLearning:
+			e.preflightFederatedLearning(ctx, p, target)
+		case models.TaskTypeLLM:
+			e.preflightLLM(ctx, p, target)
+		}
+	}
+
+	problems := p.report.Problems()
+	p.report.Ready = len(problems) == 0
+	output, err := json.Marshal(p.report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preflight report: %w", err)
+	}
+
+	result := &models.TaskResult{
+		TaskID:        task.ID,
+		Output:        string(output),
+		ExecutionTime: executionDurationMilliseconds(time.Since(startedAt)),
+		CreatedAt:     time.Now(),
+	}
+	if !p.report.Ready {
+		result.ExitCode = 1
+		result.Error = "preflight failed: " + strings.Join(problems, "; ")
+	}
+
+	log.Info().
+		Str("task_id", task.ID.String()).
+		Bool("ready", p.report.Ready).
+		Int("problems", len(problems)).
+		Msg("Preflight checks finished")
+	return result, nil
+}
+
+// checkExecutorConfig catches what the executor would reject once the task runs
+// but task validation lets through
+func (e *Executor) checkExecutorConfig(target *models.Task, config *models.TaskConfig) error {
+	if config.Resources.Timeout != "" {
+		if timeout, err := time.ParseDuration(config.Resources.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout %q", config.Resources.Timeout)
+		}
+	}
+	if config.Resources.Memory != "" {
+		if _, err := ParseMemory(config.Resources.Memory); err != nil {
+			return fmt.Errorf("invalid memory request %q: %w", config.Resources.Memory, err)
+		}
+	}
+
+	switch target.Type {
+	case models.TaskTypeCommand:
+		var command struct {
+			Command string `json:"command"`
+		}
+		if err := json.Unmarshal(target.Config, &command); err != nil {
+			return fmt.Errorf("failed to parse command config: %w", err)
+		}
+		if len(strings.Fields(command.Command)) == 0 {
+			return fmt.Errorf("command is required")
+		}
+	case models.TaskTypeFederatedLearning:
+		flConfig, err := parseFederatedLearningConfig(target)
+		if err != nil {
+			return err
+		}
+		if _, _, _, err := flConfig.trainParams(); err != nil {
+			return err
+		}
+		if _, err := training.NewTrainer(flConfig.ModelType, flConfig.ModelConfig, nil); err != nil {
+			return fmt.Errorf("failed to create trainer: %w", err)
+		}
+	}
+	return nil
+}
+
+func (e *Executor) preflightDocker(ctx context.Context, p *preflight, target *models.Task, config *models.TaskConfig) {
+	runtime, isolation := e.containers, "container"
+	if target.RequiresVM() {
+		runtime, isolation = e.vms, "vm"
+	}
+
+	p.run("image", func() (string, error) {
+		preparer, ok := runtime.(sandbox.ImagePreparer)
+		if !ok {
+			return "", errSkipped("runner has no " + isolation + " runtime to pull the image with")
+		}
+		imageID, err := preparer.PrepareImage(ctx, config.ImageName, config.DockerImageURL)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s is %s", config.ImageName, imageID), nil
+	})
+
+	if config.DataCID != "" {
+		p.run("data", func() (string, error) {
+			data, err := e.content.Fetch(ctx, config.DataCID, ipfs.DefaultMaxFetchBytes, config.DataSHA256)
+			if err != nil {
+				return "", fmt.Errorf("failed to resolve data_cid: %w", err)
+			}
+			return fmt.Sprintf("%d bytes", len(data)), nil
+		})
+	}
+
+	if len(config.Inputs) > 0 {
+		p.run("inputs", func() (string, error) {
+			var total int
+			for _, input := range config.Inputs {
+				data, err := e.content.Fetch(ctx, input.CID, models.MaxInputBytes, input.SHA256)
+				if err != nil {
+					return "", fmt.Errorf("failed to fetch input %s: %w", input.CID, err)
+				}
+				total += len(data)
+			}
+			return fmt.Sprintf("%d inputs, %d bytes", len(config.Inputs), total), nil
+		})
+	}
+}
+
+func (e *Executor) preflightWasm(ctx context.Context, p *preflight, config *models.TaskConfig) {
+	module := config.Wasm.Module
+	if config.Wasm.ModuleCID != "" {
+		if !p.run("module", func() (string, error) {
+			var err error
+			if module, err = e.content.Fetch(ctx, config.Wasm.ModuleCID, ipfs.DefaultMaxFetchBytes, config.Wasm.ModuleSHA256); err != nil {
+				return "", fmt.Errorf("failed to resolve module_cid: %w", err)
+			}
+			return fmt.Sprintf("%d bytes", len(module)), nil
+		}) {
+			return
+		}
+	}
+
+	p.run("compile", func() (string, error) {
+		return "", wasm.Check(ctx, module, config.Wasm)
+	})
+}
+
+// preflightFederatedLearning loads the dataset the way training would and
+// reports its shape
+func (e *Executor) preflightFederatedLearning(ctx context.Context, p *preflight, target *models.Task) {
+	p.run("dataset", func() (string, error) {
+		config, err := parseFederatedLearningConfig(target)
+		if err != nil {
+			return "", err
+		}
+		trainer, err := training.NewTrainer(config.ModelType, config.ModelConfig, nil)
+		if err != nil {
+			return "", err
+		}
+		features, labels, err := trainer.LoadData(ctx, config.DatasetCID, config.DataFormat)
+		if err != nil {
+			return "", err
+		}
+		if len(features) == 0 || len(features) != len(labels) {
+			return "", fmt.Errorf("dataset has %d samples and %d labels", len(features), len(labels))
+		}
+
+		featureCount := len(features[0])
+		for i, sample := range features {
+			if len(sample) != featureCount {
+				return "", fmt.Errorf("sample %d has %d features, expected %d", i, len(sample), featureCount)
+			}
+		}
+		if inputSize := getIntFromMap(config.ModelConfig, "input_size", 0); inputSize > 0 && inputSize != featureCount {
+			return "", fmt.Errorf("model_config input_size is %d but the dataset has %d features", inputSize, featureCount)
+		}
+
+		classes := make(map[float64]bool)
+		for _, label := range labels {
+			classes[label] = true
+		}
+		return fmt.Sprintf("%d samples, %d features, %d distinct labels", len(features), featureCount, len(classes)), nil
+	})
+}
+
+func (e *Executor) preflightLLM(ctx context.Context, p *preflight, target *models.Task) {
+	var config struct {
+		Prompt       string `json:"prompt"`
+		PromptCID    string `json:"prompt_cid"`
+		PromptSHA256 string `json:"prompt_sha256"`
+	}
+	p.run("prompt", func() (string, error) {
+		if err := json.Unmarshal(target.Config, &config); err != nil {
+			return "", fmt.Errorf("failed to parse LLM task config: %w", err)
+		}
+		if config.Prompt != "" {
+			return fmt.Sprintf("%d bytes", len(config.Prompt)), nil
+		}
+		if config.PromptCID == "" {
+			return "", fmt.Errorf("prompt is required for LLM task")
+		}
+		data, err := e.content.Fetch(ctx, config.PromptCID, ipfs.DefaultMaxFetchBytes, config.PromptSHA256)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve prompt_cid: %w", err)
+		}
+		if len(data) == 0 {
+			return "", fmt.Errorf("prompt is required for LLM task")
+		}
+		return fmt.Sprintf("%d bytes", len(data)), nil
+	})
+}
@@ -0,0 +1,114 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+type preparingRuntime struct {
+	recordingRuntime
+	err error
+}
+
+func (r *preparingRuntime) PrepareImage(ctx context.Context, image, imageURL string) (string, error) {
+	return "sha256:abc", r.err
+}
+
+func runPreflight(t *testing.T, executor *Executor, target models.PreflightConfig) (*models.TaskResult, *models.PreflightReport) {
+	t.Helper()
+
+	config, err := json.Marshal(models.TaskConfig{Preflight: &target})
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	result, err := executor.ExecuteTask(context.Background(), &models.Task{ID: uuid.New(), Type: models.TaskTypePreflight, Config: config})
+	if err != nil {
+		t.Fatalf("ExecuteTask() error = %v", err)
+	}
+	report, err := models.ParsePreflightReport(result)
+	if err != nil {
+		t.Fatalf("ParsePreflightReport() error = %v", err)
+	}
+	return result, report
+}
+
+func checkStatus(report *models.PreflightReport, name string) models.PreflightStatus {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check.Status
+		}
+	}
+	return ""
+}
+
+func TestPreflightDockerTask(t *testing.T) {
+	containers := &preparingRuntime{}
+	executor := &Executor{containers: containers}
+	target := models.PreflightConfig{
+		Type:        models.TaskTypeDocker,
+		Config:      json.RawMessage(`{"image_name":"alpine:3"}`),
+		Environment: &models.EnvironmentConfig{Type: "docker"},
+	}
+
+	result, report := runPreflight(t, executor, target)
+	if !report.Ready || result.ExitCode != 0 || checkStatus(report, "image") != models.PreflightPassed {
+		t.Fatalf("report = %+v", report)
+	}
+	if len(containers.tasks) != 0 {
+		t.Fatal("preflight must not run the task")
+	}
+
+	containers.err = errors.New("manifest unknown")
+	result, report = runPreflight(t, executor, target)
+	if report.Ready || result.ExitCode != 1 || checkStatus(report, "image") != models.PreflightFailed {
+		t.Fatalf("report = %+v", report)
+	}
+
+	// A runner without microVMs cannot pull for a VM task, which is not the task's fault
+	target.IsolationLevel = models.IsolationVM
+	_, report = runPreflight(t, executor, target)
+	if !report.Ready || checkStatus(report, "image") != models.PreflightSkipped {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestPreflightRejectsInvalidConfigs(t *testing.T) {
+	executor := &Executor{}
+
+	_, report := runPreflight(t, executor, models.PreflightConfig{
+		Type:   models.TaskTypeFederatedLearning,
+		Config: json.RawMessage(`{"session_id":"s","round_id":"r","model_type":"neural_network","dataset_cid":"Qm","data_format":"csv","train_config":{"epochs":1,"batch_size":8,"learning_rate":0.01}}`),
+	})
+	if report.Ready || checkStatus(report, "config") != models.PreflightFailed || len(report.Checks) != 1 {
+		t.Fatalf("a neural network without hidden_size should fail the config check only: %+v", report)
+	}
+
+	_, report = runPreflight(t, executor, models.PreflightConfig{
+		Type:   models.TaskTypeCommand,
+		Config: json.RawMessage(`{"command":"  "}`),
+	})
+	if report.Ready || len(report.Problems()) != 1 {
+		t.Fatalf("an empty command should be reported: %+v", report)
+	}
+}
+
+func TestPreflightCompilesWasmModules(t *testing.T) {
+	// a module that exports no functions
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+
+	config, err := json.Marshal(models.TaskConfig{Wasm: &models.WasmConfig{Module: module}})
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+
+	_, report := runPreflight(t, &Executor{}, models.PreflightConfig{Type: models.TaskTypeWasm, Config: config})
+	if report.Ready || checkStatus(report, "compile") != models.PreflightFailed {
+		t.Fatalf("a module without _start should fail to compile: %+v", report)
+	}
+}
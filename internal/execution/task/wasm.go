@@ -0,0 +1,99 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/wasm"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+)
+
+const defaultWasmTimeout = 5 * time.Minute
+
+func (e *Executor) executeWasmTask(ctx context.Context, task *models.Task) (*models.TaskResult, error) {
+	startedAt := time.Now()
+	log := gologger.WithComponent("task_executor")
+
+	var config models.TaskConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse wasm config: %w", err)
+	}
+	if err := config.Validate(models.TaskTypeWasm); err != nil {
+		return nil, err
+	}
+
+	limits, err := e.wasmTaskLimits(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultWasmTimeout
+	if config.Resources.Timeout != "" {
+		if timeout, err = time.ParseDuration(config.Resources.Timeout); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", config.Resources.Timeout)
+		}
+	}
+	// The server's maximum duration wins over a longer task timeout
+	if limit := task.MaxDuration(); limit > 0 && timeout > limit {
+		timeout = limit
+	}
+
+	module := config.Wasm.Module
+	if config.Wasm.ModuleCID != "" {
+		if module, err = e.content.Fetch(ctx, config.Wasm.ModuleCID, ipfs.DefaultMaxFetchBytes, config.Wasm.ModuleSHA256); err != nil {
+			return nil, fmt.Errorf("failed to resolve module_cid: %w", err)
+		}
+	}
+
+	log.Info().
+		Str("task_id", task.ID.String()).
+		Int("module_bytes", len(module)).
+		Int64("memory_limit", limits.MemoryBytes).
+		Uint64("fuel", limits.Fuel).
+		Msg("Executing wasm task")
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	run, err := wasm.Run(runCtx, module, config.Wasm, config.Env, limits)
+	if err != nil {
+		if errors.Is(err, wasm.ErrTimeout) {
+			return nil, fmt.Errorf("wasm task timed out after %s", timeout)
+		}
+		return nil, err
+	}
+
+	return &models.TaskResult{
+		TaskID:        task.ID,
+		Output:        run.Output,
+		Error:         run.Error,
+		ExitCode:      run.ExitCode,
+		ExecutionTime: executionDurationMilliseconds(time.Since(startedAt)),
+		FuelUsed:      run.FuelUsed,
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+// wasmTaskLimits lowers the runner's limits to what the task asked for
+func (e *Executor) wasmTaskLimits(config *models.TaskConfig) (wasm.Limits, error) {
+	limits := e.wasmLimits
+	if config.Resources.Memory != "" {
+		memory, err := ParseMemory(config.Resources.Memory)
+		if err != nil {
+			return wasm.Limits{}, fmt.Errorf("invalid memory request %q: %w", config.Resources.Memory, err)
+		}
+		if memory > 0 && (limits.MemoryBytes <= 0 || memory < limits.MemoryBytes) {
+			limits.MemoryBytes = memory
+		}
+	}
+	if fuel := config.Wasm.Fuel; fuel > 0 && (limits.Fuel == 0 || fuel < limits.Fuel) {
+		limits.Fuel = fuel
+	}
+	return limits, nil
+}
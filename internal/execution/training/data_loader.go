@@ -1,21 +1,49 @@
 package training
 
 import (
+	"bytes"
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
-	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/datacache"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
 )
 
+// maxDatasetBytes bounds a dataset downloaded for training
+const maxDatasetBytes int64 = 1 << 30
+
+var (
+	datasetCache   atomic.Pointer[datacache.Cache]
+	datasetContent atomic.Pointer[ipfs.Backend]
+)
+
+// SetDatasetCache makes data loaders created afterwards keep downloaded
+// datasets in cache. A nil cache downloads them every time.
+func SetDatasetCache(cache *datacache.Cache) {
+	datasetCache.Store(cache)
+}
+
+// SetContentBackend makes data loaders created afterwards download datasets
+// from content instead of IPFS_GATEWAY_URL
+func SetContentBackend(content ipfs.Backend) {
+	datasetContent.Store(&content)
+}
+
 // DataLoader handles loading training data from IPFS
 type DataLoader struct {
-	ipfsGateway string
+	content ipfs.Backend
+	cache   *datacache.Cache
 }
 
 // PartitionConfig defines how to partition data for federated learning
@@ -28,13 +56,21 @@ type PartitionConfig struct {
 	OverlapRatio float64 `json:"overlap_ratio"` // Overlap between partitions (0.0 = no overlap, 0.1 = 10% overlap)
 }
 
-// NewDataLoader creates a new DataLoader instance
+// NewDataLoader creates a new DataLoader instance. Without a gateway datasets
+// come from the backend set with SetContentBackend.
 func NewDataLoader(ipfsGateway string) *DataLoader {
-	if ipfsGateway == "" {
-		ipfsGateway = "https://ipfs.io/ipfs/" // Default gateway
+	var content ipfs.Backend
+	switch configured := datasetContent.Load(); {
+	case ipfsGateway != "":
+		content = ipfs.NewGateway(ipfsGateway)
+	case configured != nil:
+		content = *configured
+	default:
+		content = ipfs.NewClientFromEnv()
 	}
 	return &DataLoader{
-		ipfsGateway: ipfsGateway,
+		content: content,
+		cache:   datasetCache.Load(),
 	}
 }
 
@@ -45,27 +81,19 @@ func (d *DataLoader) LoadData(ctx context.Context, cid string, format string) ([
 
 // LoadPartitionedData loads and partitions data for federated learning
 func (d *DataLoader) LoadPartitionedData(ctx context.Context, cid string, format string, partitionConfig *PartitionConfig) ([][]float64, []float64, error) {
-	url := d.ipfsGateway + cid
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := http.DefaultClient.Do(req)
+	data, err := d.fetch(ctx, cid)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to fetch data: %w", err)
+		return nil, nil, err
 	}
-	defer resp.Body.Close()
 
 	var features [][]float64
 	var labels []float64
 
 	switch strings.ToLower(format) {
 	case "csv":
-		features, labels, err = d.parseCSV(resp.Body)
+		features, labels, err = d.parseCSV(bytes.NewReader(data))
 	case "json":
-		features, labels, err = d.parseJSON(resp.Body)
+		features, labels, err = d.parseJSON(bytes.NewReader(data))
 	default:
 		return nil, nil, fmt.Errorf("unsupported data format: %s", format)
 	}
@@ -82,6 +110,35 @@ func (d *DataLoader) LoadPartitionedData(ctx context.Context, cid string, format
 	return features, labels, nil
 }
 
+// fetch returns the dataset from the cache when it holds an intact copy and
+// downloads it otherwise
+func (d *DataLoader) fetch(ctx context.Context, cid string) ([]byte, error) {
+	log := gologger.WithComponent("data_loader")
+
+	if d.cache != nil {
+		data, err := d.cache.Get(cid)
+		if err == nil {
+			log.Debug().Str("cid", cid).Int("bytes", len(data)).Msg("Loaded dataset from cache")
+			return data, nil
+		}
+		if !errors.Is(err, datacache.ErrNotFound) {
+			log.Warn().Err(err).Str("cid", cid).Msg("Ignoring cached dataset")
+		}
+	}
+
+	data, err := d.content.Fetch(ctx, cid, maxDatasetBytes, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch data: %w", err)
+	}
+
+	if d.cache != nil {
+		if err := d.cache.Put(cid, data); err != nil {
+			log.Warn().Err(err).Str("cid", cid).Msg("Failed to cache dataset")
+		}
+	}
+	return data, nil
+}
+
 func (d *DataLoader) partitionData(features [][]float64, labels []float64, config *PartitionConfig) ([][]float64, []float64, error) {
 	if config.TotalParts <= 0 || config.PartIndex < 0 || config.PartIndex >= config.TotalParts {
 		return nil, nil, fmt.Errorf("invalid partition configuration")
@@ -0,0 +1,549 @@
+package training
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	ObjectiveRegression = "regression"
+	ObjectiveBinary     = "binary"
+)
+
+// GradientBoostingTrainer trains an ensemble of regression trees the way
+// XGBoost does: each tree is fit to the first and second order gradients of the
+// loss at the current predictions, its leaves are regularized by lambda and
+// gamma, and its output is shrunk by the learning rate before it is added.
+type GradientBoostingTrainer struct {
+	config            *GradientBoostingConfig
+	trees             []*BoostedTree
+	baseScore         float64
+	numFeatures       int
+	bestIteration     int
+	weights           map[string][]float64
+	gradients         map[string][]float64
+	featureImportance map[int]float64
+	dataLoader        *DataLoader
+	rng               *rand.Rand
+}
+
+type GradientBoostingConfig struct {
+	NumRounds      int     `json:"num_rounds"`
+	MaxDepth       int     `json:"max_depth"`
+	MinChildWeight float64 `json:"min_child_weight"`
+	// LearningRate shrinks every tree. When unset the learning rate of the
+	// train config is used.
+	LearningRate    float64  `json:"learning_rate"`
+	Subsample       float64  `json:"subsample"`
+	ColsampleByTree float64  `json:"colsample_bytree"`
+	Lambda          *float64 `json:"lambda"`
+	Gamma           float64  `json:"gamma"`
+	// Objective is "regression" for squared error or "binary" for logistic loss
+	// on 0/1 labels. It is detected from the labels when unset.
+	Objective           string  `json:"objective"`
+	EarlyStoppingRounds int     `json:"early_stopping_rounds"`
+	ValidationFraction  float64 `json:"validation_fraction"`
+	RandomState         int64   `json:"random_state"`
+}
+
+// BoostedTree is one round of boosting. Leaf values already include the
+// learning rate.
+type BoostedTree struct {
+	Root *BoostedNode `json:"root"`
+}
+
+type BoostedNode struct {
+	FeatureIndex int          `json:"feature_index"`
+	Threshold    float64      `json:"threshold"`
+	Left         *BoostedNode `json:"left"`
+	Right        *BoostedNode `json:"right"`
+	Value        float64      `json:"value"`
+	IsLeaf       bool         `json:"is_leaf"`
+	Gain         float64      `json:"gain"`
+	Cover        float64      `json:"cover"`
+}
+
+func (t *BoostedTree) predict(sample []float64) float64 {
+	node := t.Root
+	for !node.IsLeaf {
+		if sample[node.FeatureIndex] < node.Threshold {
+			node = node.Left
+		} else {
+			node = node.Right
+		}
+	}
+	return node.Value
+}
+
+func NewGradientBoostingTrainer(config map[string]interface{}) (Trainer, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	var gbConfig GradientBoostingConfig
+	if err := json.Unmarshal(configBytes, &gbConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal gradient boosting config: %w", err)
+	}
+
+	if gbConfig.NumRounds <= 0 {
+		gbConfig.NumRounds = 100
+	}
+	if gbConfig.MaxDepth <= 0 {
+		gbConfig.MaxDepth = 6
+	}
+	if gbConfig.MinChildWeight <= 0 {
+		gbConfig.MinChildWeight = 1
+	}
+	if gbConfig.Subsample <= 0 || gbConfig.Subsample > 1 {
+		gbConfig.Subsample = 1
+	}
+	if gbConfig.ColsampleByTree <= 0 || gbConfig.ColsampleByTree > 1 {
+		gbConfig.ColsampleByTree = 1
+	}
+	if gbConfig.Lambda == nil {
+		lambda := 1.0
+		gbConfig.Lambda = &lambda
+	}
+	if *gbConfig.Lambda < 0 || gbConfig.Gamma < 0 {
+		return nil, fmt.Errorf("lambda and gamma must not be negative")
+	}
+	if gbConfig.Objective != "" && gbConfig.Objective != ObjectiveRegression && gbConfig.Objective != ObjectiveBinary {
+		return nil, fmt.Errorf("unsupported objective: %s", gbConfig.Objective)
+	}
+	if gbConfig.EarlyStoppingRounds > 0 && (gbConfig.ValidationFraction <= 0 || gbConfig.ValidationFraction >= 1) {
+		gbConfig.ValidationFraction = 0.1
+	}
+	if gbConfig.RandomState == 0 {
+		gbConfig.RandomState = time.Now().UnixNano()
+	}
+
+	return &GradientBoostingTrainer{
+		config:            &gbConfig,
+		weights:           make(map[string][]float64),
+		gradients:         make(map[string][]float64),
+		featureImportance: make(map[int]float64),
+		dataLoader:        NewDataLoader(""),
+		rng:               rand.New(rand.NewSource(gbConfig.RandomState)),
+	}, nil
+}
+
+func (gb *GradientBoostingTrainer) LoadData(ctx context.Context, datasetCID string, format string) ([][]float64, []float64, error) {
+	return gb.LoadPartitionedData(ctx, datasetCID, format, nil)
+}
+
+func (gb *GradientBoostingTrainer) LoadPartitionedData(ctx context.Context, datasetCID string, format string, partitionConfig *PartitionConfig) ([][]float64, []float64, error) {
+	if datasetCID == "" {
+		return nil, nil, fmt.Errorf("dataset CID is required")
+	}
+
+	features, labels, err := gb.dataLoader.LoadPartitionedData(ctx, datasetCID, format, partitionConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load data from IPFS: %w", err)
+	}
+	if err := validateTrainingData(features, labels); err != nil {
+		return nil, nil, err
+	}
+	return features, labels, nil
+}
+
+// Train adds up to NumRounds trees. With early stopping, the last
+// ValidationFraction of the samples is held out and training stops once the
+// validation loss has not improved for EarlyStoppingRounds rounds; the trees
+// after the best round are dropped. epochs and batchSize do not apply to
+// boosting.
+func (gb *GradientBoostingTrainer) Train(ctx context.Context, features [][]float64, labels []float64, epochs int, batchSize int, learningRate float64) ([]float64, float64, float64, error) {
+	if err := validateTrainingData(features, labels); err != nil {
+		return nil, 0, 0, err
+	}
+
+	if gb.config.Objective == "" {
+		gb.config.Objective = detectObjective(labels)
+	}
+	if gb.config.Objective == ObjectiveBinary {
+		for i, label := range labels {
+			if label != 0 && label != 1 {
+				return nil, 0, 0, fmt.Errorf("binary objective needs 0/1 labels, sample %d has %v", i, label)
+			}
+		}
+	}
+	eta := gb.config.LearningRate
+	if eta <= 0 {
+		eta = learningRate
+	}
+	if eta <= 0 {
+		return nil, 0, 0, fmt.Errorf("learning rate must be positive")
+	}
+
+	trainFeatures, trainLabels := features, labels
+	var validationFeatures [][]float64
+	var validationLabels []float64
+	if gb.config.EarlyStoppingRounds > 0 {
+		splitIndex := int(float64(len(features)) * (1 - gb.config.ValidationFraction))
+		if splitIndex > 0 && splitIndex < len(features) {
+			trainFeatures, trainLabels = features[:splitIndex], labels[:splitIndex]
+			validationFeatures, validationLabels = features[splitIndex:], labels[splitIndex:]
+		}
+	}
+
+	gb.numFeatures = len(features[0])
+	gb.baseScore = gb.initialScore(trainLabels)
+	gb.trees = make([]*BoostedTree, 0, gb.config.NumRounds)
+	gb.bestIteration = -1
+
+	trainPredictions := filled(len(trainFeatures), gb.baseScore)
+	validationPredictions := filled(len(validationFeatures), gb.baseScore)
+	grad := make([]float64, len(trainFeatures))
+	hess := make([]float64, len(trainFeatures))
+
+	bestLoss := math.Inf(1)
+	for round := 0; round < gb.config.NumRounds; round++ {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, 0, err
+		}
+
+		for i, prediction := range trainPredictions {
+			grad[i], hess[i] = gb.gradient(prediction, trainLabels[i])
+		}
+		tree := &BoostedTree{Root: gb.buildNode(trainFeatures, grad, hess, gb.sampleRows(len(trainFeatures)), gb.sampleColumns(), 0, eta)}
+		gb.trees = append(gb.trees, tree)
+
+		for i, sample := range trainFeatures {
+			trainPredictions[i] += tree.predict(sample)
+		}
+		if len(validationFeatures) == 0 {
+			continue
+		}
+
+		for i, sample := range validationFeatures {
+			validationPredictions[i] += tree.predict(sample)
+		}
+		loss := gb.loss(validationPredictions, validationLabels)
+		if loss < bestLoss {
+			bestLoss = loss
+			gb.bestIteration = round
+		} else if round-gb.bestIteration >= gb.config.EarlyStoppingRounds {
+			gb.trees = gb.trees[:gb.bestIteration+1]
+			break
+		}
+	}
+	if gb.bestIteration < 0 {
+		gb.bestIteration = len(gb.trees) - 1
+	}
+
+	gb.calculateFeatureImportance()
+	gb.updateWeightsAndGradients()
+
+	predictions := make([]float64, len(features))
+	for i, sample := range features {
+		predictions[i] = gb.predictRaw(sample)
+	}
+	return gb.flattenWeights(), gb.loss(predictions, labels), gb.accuracy(predictions, labels), nil
+}
+
+// detectObjective picks logistic loss when every label is 0 or 1
+func detectObjective(labels []float64) string {
+	for _, label := range labels {
+		if label != 0 && label != 1 {
+			return ObjectiveRegression
+		}
+	}
+	return ObjectiveBinary
+}
+
+func (gb *GradientBoostingTrainer) initialScore(labels []float64) float64 {
+	mean := 0.0
+	for _, label := range labels {
+		mean += label
+	}
+	mean /= float64(len(labels))
+
+	if gb.config.Objective == ObjectiveBinary {
+		p := math.Min(math.Max(mean, 1e-6), 1-1e-6)
+		return math.Log(p / (1 - p))
+	}
+	return mean
+}
+
+// gradient returns the first and second derivative of the loss at a raw
+// prediction
+func (gb *GradientBoostingTrainer) gradient(prediction, label float64) (float64, float64) {
+	if gb.config.Objective == ObjectiveBinary {
+		p := sigmoid(prediction)
+		return p - label, math.Max(p*(1-p), 1e-16)
+	}
+	return prediction - label, 1
+}
+
+func (gb *GradientBoostingTrainer) loss(predictions, labels []float64) float64 {
+	total := 0.0
+	for i, prediction := range predictions {
+		if gb.config.Objective == ObjectiveBinary {
+			p := math.Min(math.Max(sigmoid(prediction), 1e-15), 1-1e-15)
+			total -= labels[i]*math.Log(p) + (1-labels[i])*math.Log(1-p)
+		} else {
+			diff := prediction - labels[i]
+			total += diff * diff
+		}
+	}
+	return total / float64(len(predictions))
+}
+
+// accuracy is the share of correctly classified samples for the binary
+// objective and R² for regression
+func (gb *GradientBoostingTrainer) accuracy(predictions, labels []float64) float64 {
+	if gb.config.Objective == ObjectiveBinary {
+		correct := 0
+		for i, prediction := range predictions {
+			if (prediction >= 0) == (labels[i] == 1) {
+				correct++
+			}
+		}
+		return float64(correct) / float64(len(labels))
+	}
+
+	mean := 0.0
+	for _, label := range labels {
+		mean += label
+	}
+	mean /= float64(len(labels))
+
+	totalSS, residualSS := 0.0, 0.0
+	for i, prediction := range predictions {
+		residualSS += math.Pow(labels[i]-prediction, 2)
+		totalSS += math.Pow(labels[i]-mean, 2)
+	}
+	if totalSS == 0 {
+		return 0
+	}
+	return math.Max(0, 1-residualSS/totalSS)
+}
+
+func (gb *GradientBoostingTrainer) sampleRows(n int) []int {
+	if gb.config.Subsample >= 1 {
+		return gb.generateIndices(n)
+	}
+	size := max(int(float64(n)*gb.config.Subsample), 1)
+	rows := gb.rng.Perm(n)[:size]
+	sort.Ints(rows)
+	return rows
+}
+
+func (gb *GradientBoostingTrainer) sampleColumns() []int {
+	if gb.config.ColsampleByTree >= 1 {
+		return gb.generateIndices(gb.numFeatures)
+	}
+	size := max(int(float64(gb.numFeatures)*gb.config.ColsampleByTree), 1)
+	columns := gb.rng.Perm(gb.numFeatures)[:size]
+	sort.Ints(columns)
+	return columns
+}
+
+func (gb *GradientBoostingTrainer) generateIndices(n int) []int {
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// buildNode grows a tree greedily, splitting where the regularized gain
+//
+//	1/2 [G_L²/(H_L+λ) + G_R²/(H_R+λ) - G²/(H+λ)] - γ
+//
+// is largest and positive, and gives each leaf the weight -η G/(H+λ)
+func (gb *GradientBoostingTrainer) buildNode(features [][]float64, grad, hess []float64, rows, columns []int, depth int, eta float64) *BoostedNode {
+	lambda := *gb.config.Lambda
+	sumGrad, sumHess := 0.0, 0.0
+	for _, row := range rows {
+		sumGrad += grad[row]
+		sumHess += hess[row]
+	}
+	node := &BoostedNode{
+		IsLeaf: true,
+		Value:  -eta * sumGrad / (sumHess + lambda),
+		Cover:  sumHess,
+	}
+	if depth >= gb.config.MaxDepth || len(rows) < 2 || sumHess < 2*gb.config.MinChildWeight {
+		return node
+	}
+
+	parentScore := sumGrad * sumGrad / (sumHess + lambda)
+	bestGain := 0.0
+	bestFeature, bestPosition := -1, 0
+	var bestOrder []int
+	var bestThreshold float64
+
+	order := make([]int, len(rows))
+	for _, feature := range columns {
+		copy(order, rows)
+		sort.Slice(order, func(i, j int) bool {
+			return features[order[i]][feature] < features[order[j]][feature]
+		})
+
+		leftGrad, leftHess := 0.0, 0.0
+		for i := 0; i < len(order)-1; i++ {
+			leftGrad += grad[order[i]]
+			leftHess += hess[order[i]]
+			current, next := features[order[i]][feature], features[order[i+1]][feature]
+			if current == next {
+				continue
+			}
+			rightGrad, rightHess := sumGrad-leftGrad, sumHess-leftHess
+			if leftHess < gb.config.MinChildWeight || rightHess < gb.config.MinChildWeight {
+				continue
+			}
+
+			gain := 0.5*(leftGrad*leftGrad/(leftHess+lambda)+rightGrad*rightGrad/(rightHess+lambda)-parentScore) - gb.config.Gamma
+			if gain > bestGain {
+				bestGain = gain
+				bestFeature = feature
+				bestPosition = i + 1
+				bestThreshold = (current + next) / 2
+				bestOrder = append(bestOrder[:0], order...)
+			}
+		}
+	}
+	if bestFeature < 0 {
+		return node
+	}
+
+	node.IsLeaf = false
+	node.Value = 0
+	node.FeatureIndex = bestFeature
+	node.Threshold = bestThreshold
+	node.Gain = bestGain
+	node.Left = gb.buildNode(features, grad, hess, bestOrder[:bestPosition], columns, depth+1, eta)
+	node.Right = gb.buildNode(features, grad, hess, bestOrder[bestPosition:], columns, depth+1, eta)
+	return node
+}
+
+func (gb *GradientBoostingTrainer) predictRaw(sample []float64) float64 {
+	prediction := gb.baseScore
+	for _, tree := range gb.trees {
+		prediction += tree.predict(sample)
+	}
+	return prediction
+}
+
+// Predict returns the predicted value for regression and the probability of
+// label 1 for the binary objective
+func (gb *GradientBoostingTrainer) Predict(sample []float64) float64 {
+	prediction := gb.predictRaw(sample)
+	if gb.config.Objective == ObjectiveBinary {
+		return sigmoid(prediction)
+	}
+	return prediction
+}
+
+// calculateFeatureImportance sums the gain of the splits on each feature and
+// normalizes the totals to add up to one
+func (gb *GradientBoostingTrainer) calculateFeatureImportance() {
+	gb.featureImportance = make(map[int]float64)
+	total := 0.0
+	var visit func(node *BoostedNode)
+	visit = func(node *BoostedNode) {
+		if node == nil || node.IsLeaf {
+			return
+		}
+		gb.featureImportance[node.FeatureIndex] += node.Gain
+		total += node.Gain
+		visit(node.Left)
+		visit(node.Right)
+	}
+	for _, tree := range gb.trees {
+		visit(tree.Root)
+	}
+
+	if total > 0 {
+		for feature := range gb.featureImportance {
+			gb.featureImportance[feature] /= total
+		}
+	}
+}
+
+func (gb *GradientBoostingTrainer) updateWeightsAndGradients() {
+	treeWeights := make([]float64, 0)
+	for _, tree := range gb.trees {
+		treeWeights = append(treeWeights, gb.serializeNode(tree.Root)...)
+	}
+
+	gb.weights = map[string][]float64{
+		"trees":      treeWeights,
+		"base_score": {gb.baseScore},
+	}
+	// Trees are not averaged, so there are no gradients to share
+	gb.gradients = map[string][]float64{
+		"trees": make([]float64, len(treeWeights)),
+	}
+
+	importance := make([]float64, gb.numFeatures)
+	for feature, value := range gb.featureImportance {
+		importance[feature] = value
+	}
+	gb.weights["feature_importance"] = importance
+}
+
+// serializeNode writes a node in preorder as feature index, threshold, value,
+// cover and a leaf flag
+func (gb *GradientBoostingTrainer) serializeNode(node *BoostedNode) []float64 {
+	data := []float64{float64(node.FeatureIndex), node.Threshold, node.Value, node.Cover}
+	if node.IsLeaf {
+		return append(data, 1)
+	}
+	data = append(data, 0)
+	data = append(data, gb.serializeNode(node.Left)...)
+	return append(data, gb.serializeNode(node.Right)...)
+}
+
+func (gb *GradientBoostingTrainer) flattenWeights() []float64 {
+	var flattened []float64
+	for _, key := range []string{"base_score", "trees", "feature_importance"} {
+		flattened = append(flattened, gb.weights[key]...)
+	}
+	return flattened
+}
+
+func (gb *GradientBoostingTrainer) GetModelWeights() map[string][]float64 {
+	return gb.weights
+}
+
+func (gb *GradientBoostingTrainer) GetGradients() map[string][]float64 {
+	return gb.gradients
+}
+
+// GetFeatureImportance returns each feature's share of the total split gain
+func (gb *GradientBoostingTrainer) GetFeatureImportance() map[int]float64 {
+	return gb.featureImportance
+}
+
+// GetBestIteration returns the zero-based round with the lowest validation loss,
+// or the last round without early stopping
+func (gb *GradientBoostingTrainer) GetBestIteration() int {
+	return gb.bestIteration
+}
+
+func (gb *GradientBoostingTrainer) GetTrees() []*BoostedTree {
+	return gb.trees
+}
+
+func (gb *GradientBoostingTrainer) GetObjective() string {
+	return gb.config.Objective
+}
+
+func filled(n int, value float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
@@ -0,0 +1,101 @@
+package training
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func syntheticData(n int, label func(x []float64) float64) ([][]float64, []float64) {
+	rng := rand.New(rand.NewSource(1))
+	features := make([][]float64, n)
+	labels := make([]float64, n)
+	for i := range features {
+		features[i] = []float64{rng.Float64(), rng.Float64(), rng.Float64()}
+		labels[i] = label(features[i])
+	}
+	return features, labels
+}
+
+func TestGradientBoostingRegression(t *testing.T) {
+	features, labels := syntheticData(300, func(x []float64) float64 { return 4*x[0] + x[1]*x[1] })
+
+	trainer, err := NewGradientBoostingTrainer(map[string]interface{}{
+		"num_rounds":    50,
+		"max_depth":     3,
+		"learning_rate": 0.3,
+		"random_state":  1,
+	})
+	if err != nil {
+		t.Fatalf("NewGradientBoostingTrainer() error = %v", err)
+	}
+	_, loss, r2, err := trainer.Train(context.Background(), features, labels, 1, 32, 0.01)
+	if err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+	if r2 < 0.95 || loss > 0.05 {
+		t.Errorf("loss = %v, R² = %v", loss, r2)
+	}
+
+	gb := trainer.(*GradientBoostingTrainer)
+	if gb.GetObjective() != ObjectiveRegression || len(gb.GetTrees()) != 50 {
+		t.Errorf("objective = %s, trees = %d", gb.GetObjective(), len(gb.GetTrees()))
+	}
+	importance := gb.GetModelWeights()["feature_importance"]
+	if len(importance) != 3 || importance[0] <= importance[1] || importance[1] <= importance[2] {
+		t.Errorf("feature importance = %v", importance)
+	}
+}
+
+func TestGradientBoostingBinaryEarlyStopping(t *testing.T) {
+	features, labels := syntheticData(400, func(x []float64) float64 {
+		if x[0]+x[2] > 1 {
+			return 1
+		}
+		return 0
+	})
+
+	trainer, err := NewGradientBoostingTrainer(map[string]interface{}{
+		"num_rounds":            500,
+		"max_depth":             2,
+		"learning_rate":         0.5,
+		"early_stopping_rounds": 5,
+		"validation_fraction":   0.25,
+		"random_state":          1,
+	})
+	if err != nil {
+		t.Fatalf("NewGradientBoostingTrainer() error = %v", err)
+	}
+	_, _, accuracy, err := trainer.Train(context.Background(), features, labels, 1, 32, 0.01)
+	if err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+
+	gb := trainer.(*GradientBoostingTrainer)
+	if gb.GetObjective() != ObjectiveBinary {
+		t.Errorf("objective = %s, want binary", gb.GetObjective())
+	}
+	if accuracy < 0.9 {
+		t.Errorf("accuracy = %v", accuracy)
+	}
+	if trees := len(gb.GetTrees()); trees >= 500 || trees != gb.GetBestIteration()+1 {
+		t.Errorf("early stopping kept %d trees, best iteration %d", trees, gb.GetBestIteration())
+	}
+	if p := gb.Predict([]float64{0.9, 0.5, 0.9}); p < 0.5 {
+		t.Errorf("Predict() = %v for a positive sample", p)
+	}
+}
+
+func TestGradientBoostingRejectsBadConfig(t *testing.T) {
+	if _, err := NewGradientBoostingTrainer(map[string]interface{}{"objective": "poisson"}); err == nil {
+		t.Error("expected an unsupported objective to be rejected")
+	}
+	if _, err := NewGradientBoostingTrainer(map[string]interface{}{"lambda": -1}); err == nil {
+		t.Error("expected a negative lambda to be rejected")
+	}
+
+	trainer, _ := NewGradientBoostingTrainer(map[string]interface{}{"objective": "binary"})
+	if _, _, _, err := trainer.Train(context.Background(), [][]float64{{1}, {2}}, []float64{0, 2}, 1, 1, 0.1); err == nil {
+		t.Error("expected the binary objective to reject labels other than 0 and 1")
+	}
+}
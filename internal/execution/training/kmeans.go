@@ -0,0 +1,407 @@
+package training
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	InitKMeansPlusPlus = "k-means++"
+	InitRandom         = "random"
+)
+
+// KMeansTrainer clusters samples with Lloyd's algorithm. It is unsupervised:
+// labels are loaded with the dataset but not used for training. The centroids
+// are the model weights, so a federated session averages them across runners
+// weighted by cluster_sizes and sends them back as initial_centroids.
+type KMeansTrainer struct {
+	config       *KMeansConfig
+	centroids    [][]float64
+	clusterSizes []float64
+	inertia      float64
+	iterations   int
+	dataLoader   *DataLoader
+	rng          *rand.Rand
+	// lastGradients holds the weights after the last Train, like the other trainers
+	lastGradients map[string][]float64
+}
+
+// KMeansConfig sets the number of clusters and how they are seeded. Init is
+// "k-means++" or "random", NumInit the number of seedings tried, keeping the one
+// with the lowest inertia. InitialCentroids, when set, replace the seeding, for
+// example with the global centroids of the previous round. Training stops when
+// no centroid moves more than Tolerance times the mean feature variance.
+type KMeansConfig struct {
+	NumClusters      int         `json:"num_clusters"`
+	Init             string      `json:"init"`
+	NumInit          int         `json:"n_init"`
+	Tolerance        float64     `json:"tolerance"`
+	InitialCentroids [][]float64 `json:"initial_centroids"`
+	RandomState      int64       `json:"random_state"`
+}
+
+func NewKMeansTrainer(config map[string]interface{}) (*KMeansTrainer, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	var kmConfig KMeansConfig
+	if err := json.Unmarshal(configBytes, &kmConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal k-means config: %w", err)
+	}
+
+	if kmConfig.NumClusters <= 0 {
+		kmConfig.NumClusters = len(kmConfig.InitialCentroids)
+	}
+	if kmConfig.NumClusters <= 0 {
+		return nil, fmt.Errorf("num_clusters must be positive")
+	}
+	if len(kmConfig.InitialCentroids) > 0 && len(kmConfig.InitialCentroids) != kmConfig.NumClusters {
+		return nil, fmt.Errorf("initial_centroids has %d centroids but num_clusters is %d", len(kmConfig.InitialCentroids), kmConfig.NumClusters)
+	}
+	switch kmConfig.Init {
+	case "", "kmeans++":
+		kmConfig.Init = InitKMeansPlusPlus
+	case InitKMeansPlusPlus, InitRandom:
+	default:
+		return nil, fmt.Errorf("unsupported init: %s", kmConfig.Init)
+	}
+	if kmConfig.NumInit < 0 {
+		return nil, fmt.Errorf("n_init must not be negative")
+	}
+	if kmConfig.NumInit == 0 {
+		kmConfig.NumInit = 1
+	}
+	if kmConfig.Tolerance < 0 {
+		return nil, fmt.Errorf("tolerance must not be negative")
+	}
+	if kmConfig.Tolerance == 0 {
+		kmConfig.Tolerance = 1e-4
+	}
+	if kmConfig.RandomState == 0 {
+		kmConfig.RandomState = time.Now().UnixNano()
+	}
+
+	return &KMeansTrainer{
+		config:     &kmConfig,
+		dataLoader: NewDataLoader(""),
+		rng:        rand.New(rand.NewSource(kmConfig.RandomState)),
+	}, nil
+}
+
+// LoadData loads training data from IPFS
+func (t *KMeansTrainer) LoadData(ctx context.Context, datasetCID string, format string) ([][]float64, []float64, error) {
+	return t.LoadPartitionedData(ctx, datasetCID, format, nil)
+}
+
+func (t *KMeansTrainer) LoadPartitionedData(ctx context.Context, datasetCID string, format string, partitionConfig *PartitionConfig) ([][]float64, []float64, error) {
+	if datasetCID == "" {
+		return nil, nil, fmt.Errorf("dataset CID is required")
+	}
+
+	features, labels, err := t.dataLoader.LoadPartitionedData(ctx, datasetCID, format, partitionConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load data from IPFS: %w", err)
+	}
+	if err := validateFeatures(features); err != nil {
+		return nil, nil, err
+	}
+	return features, labels, nil
+}
+
+// Train runs up to epochs iterations of Lloyd's algorithm for each of the
+// NumInit seedings. Batch size and learning rate do not apply. The loss is the
+// inertia per sample and the accuracy the share of the variance the clusters
+// explain.
+func (t *KMeansTrainer) Train(ctx context.Context, features [][]float64, labels []float64, epochs int, batchSize int, learningRate float64) ([]float64, float64, float64, error) {
+	if err := validateFeatures(features); err != nil {
+		return nil, 0, 0, err
+	}
+	if epochs <= 0 {
+		return nil, 0, 0, fmt.Errorf("epochs must be positive")
+	}
+	k := t.config.NumClusters
+	if len(features) < k {
+		return nil, 0, 0, fmt.Errorf("%d samples cannot form %d clusters", len(features), k)
+	}
+	featureCount := len(features[0])
+	for i, centroid := range t.config.InitialCentroids {
+		if len(centroid) != featureCount {
+			return nil, 0, 0, fmt.Errorf("initial centroid %d has %d features, expected %d", i, len(centroid), featureCount)
+		}
+	}
+
+	totalSS := sumOfSquares(features)
+	tolerance := t.config.Tolerance * totalSS / float64(len(features)*featureCount)
+
+	runs := t.config.NumInit
+	if len(t.config.InitialCentroids) > 0 {
+		runs = 1
+	}
+	bestInertia := math.Inf(1)
+	for run := 0; run < runs; run++ {
+		var centroids [][]float64
+		switch {
+		case len(t.config.InitialCentroids) > 0:
+			centroids = copyRows(t.config.InitialCentroids)
+		case t.config.Init == InitRandom:
+			centroids = t.randomCentroids(features)
+		default:
+			centroids = t.plusPlusCentroids(features)
+		}
+
+		assignments, inertia, iterations, err := lloyd(ctx, features, centroids, epochs, tolerance)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if inertia < bestInertia {
+			bestInertia = inertia
+			t.centroids = centroids
+			t.inertia = inertia
+			t.iterations = iterations
+			t.clusterSizes = make([]float64, k)
+			for _, cluster := range assignments {
+				t.clusterSizes[cluster]++
+			}
+		}
+	}
+
+	loss := t.inertia / float64(len(features))
+	accuracy := 1.0
+	if totalSS > 0 {
+		accuracy = 1 - t.inertia/totalSS
+	}
+
+	// Store the current weights as gradients (for federated learning)
+	t.lastGradients = t.GetModelWeights()
+
+	return t.flattenWeights(), loss, accuracy, nil
+}
+
+// lloyd moves centroids to the mean of their samples until they settle. A
+// cluster left empty is reseeded with the sample furthest from its centroid.
+func lloyd(ctx context.Context, features [][]float64, centroids [][]float64, maxIterations int, tolerance float64) ([]int, float64, int, error) {
+	assignments := make([]int, len(features))
+	distances := make([]float64, len(features))
+	sums := make([][]float64, len(centroids))
+	for c := range sums {
+		sums[c] = make([]float64, len(features[0]))
+	}
+	counts := make([]int, len(centroids))
+
+	iterations := 0
+	for iterations < maxIterations {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, 0, err
+		}
+		iterations++
+
+		assign(features, centroids, assignments, distances)
+		for c := range sums {
+			clear(sums[c])
+		}
+		clear(counts)
+		for i, sample := range features {
+			cluster := assignments[i]
+			counts[cluster]++
+			for j, x := range sample {
+				sums[cluster][j] += x
+			}
+		}
+
+		shift := 0.0
+		for c := range centroids {
+			if counts[c] == 0 {
+				furthest := 0
+				for i, distance := range distances {
+					if distance > distances[furthest] {
+						furthest = i
+					}
+				}
+				distances[furthest] = 0
+				copy(sums[c], features[furthest])
+				counts[c] = 1
+			}
+			moved := make([]float64, len(sums[c]))
+			for j, sum := range sums[c] {
+				moved[j] = sum / float64(counts[c])
+			}
+			shift = math.Max(shift, squaredDistance(centroids[c], moved))
+			centroids[c] = moved
+		}
+		if shift <= tolerance {
+			break
+		}
+	}
+
+	inertia := assign(features, centroids, assignments, distances)
+	return assignments, inertia, iterations, nil
+}
+
+// assign writes the nearest centroid of each sample and the squared distance
+// to it, and returns the sum of those distances
+func assign(features [][]float64, centroids [][]float64, assignments []int, distances []float64) float64 {
+	inertia := 0.0
+	for i, sample := range features {
+		assignments[i], distances[i] = nearestCentroid(sample, centroids)
+		inertia += distances[i]
+	}
+	return inertia
+}
+
+func nearestCentroid(sample []float64, centroids [][]float64) (int, float64) {
+	best, bestDistance := 0, math.Inf(1)
+	for c, centroid := range centroids {
+		if distance := squaredDistance(sample, centroid); distance < bestDistance {
+			best, bestDistance = c, distance
+		}
+	}
+	return best, bestDistance
+}
+
+func squaredDistance(a, b []float64) float64 {
+	sum := 0.0
+	for j := range a {
+		d := a[j] - b[j]
+		sum += d * d
+	}
+	return sum
+}
+
+// randomCentroids picks NumClusters distinct samples
+func (t *KMeansTrainer) randomCentroids(features [][]float64) [][]float64 {
+	centroids := make([][]float64, t.config.NumClusters)
+	for c, idx := range t.rng.Perm(len(features))[:t.config.NumClusters] {
+		centroids[c] = append([]float64(nil), features[idx]...)
+	}
+	return centroids
+}
+
+// plusPlusCentroids seeds with k-means++: each centroid after the first is a
+// sample drawn with probability proportional to its squared distance from the
+// nearest centroid so far
+func (t *KMeansTrainer) plusPlusCentroids(features [][]float64) [][]float64 {
+	centroids := make([][]float64, 0, t.config.NumClusters)
+	centroids = append(centroids, append([]float64(nil), features[t.rng.Intn(len(features))]...))
+
+	distances := make([]float64, len(features))
+	for i, sample := range features {
+		distances[i] = squaredDistance(sample, centroids[0])
+	}
+	for len(centroids) < t.config.NumClusters {
+		total := 0.0
+		for _, distance := range distances {
+			total += distance
+		}
+
+		next := t.rng.Intn(len(features))
+		if total > 0 {
+			target := t.rng.Float64() * total
+			for i, distance := range distances {
+				target -= distance
+				if target < 0 {
+					next = i
+					break
+				}
+			}
+		}
+
+		centroid := append([]float64(nil), features[next]...)
+		centroids = append(centroids, centroid)
+		for i, sample := range features {
+			distances[i] = math.Min(distances[i], squaredDistance(sample, centroid))
+		}
+	}
+	return centroids
+}
+
+// sumOfSquares returns the total squared distance of the samples from their mean
+func sumOfSquares(features [][]float64) float64 {
+	mean := make([]float64, len(features[0]))
+	for _, sample := range features {
+		for j, x := range sample {
+			mean[j] += x
+		}
+	}
+	for j := range mean {
+		mean[j] /= float64(len(features))
+	}
+
+	total := 0.0
+	for _, sample := range features {
+		total += squaredDistance(sample, mean)
+	}
+	return total
+}
+
+func copyRows(rows [][]float64) [][]float64 {
+	copied := make([][]float64, len(rows))
+	for i, row := range rows {
+		copied[i] = append([]float64(nil), row...)
+	}
+	return copied
+}
+
+// Predict returns the index of the nearest centroid
+func (t *KMeansTrainer) Predict(sample []float64) float64 {
+	cluster, _ := nearestCentroid(sample, t.centroids)
+	return float64(cluster)
+}
+
+func (t *KMeansTrainer) flattenWeights() []float64 {
+	return t.GetModelWeights()["centroids"]
+}
+
+// GetModelWeights returns the centroids, num_clusters rows of one value per
+// feature, and the number of samples in each cluster
+func (t *KMeansTrainer) GetModelWeights() map[string][]float64 {
+	var centroids []float64
+	for _, centroid := range t.centroids {
+		centroids = append(centroids, centroid...)
+	}
+	return map[string][]float64{
+		"centroids":     centroids,
+		"cluster_sizes": append([]float64(nil), t.clusterSizes...),
+	}
+}
+
+// GetGradients returns the gradients from the last training step
+func (t *KMeansTrainer) GetGradients() map[string][]float64 {
+	if t.lastGradients == nil {
+		return map[string][]float64{
+			"centroids":     {},
+			"cluster_sizes": make([]float64, t.config.NumClusters),
+		}
+	}
+
+	gradientsCopy := make(map[string][]float64)
+	for key, values := range t.lastGradients {
+		gradientsCopy[key] = append([]float64(nil), values...)
+	}
+	return gradientsCopy
+}
+
+// GetCentroids returns a copy of the centroids
+func (t *KMeansTrainer) GetCentroids() [][]float64 {
+	return copyRows(t.centroids)
+}
+
+// GetInertia returns the sum of squared distances of the samples to their
+// centroid after the last Train
+func (t *KMeansTrainer) GetInertia() float64 {
+	return t.inertia
+}
+
+// GetIterations returns how many iterations the kept seeding ran
+func (t *KMeansTrainer) GetIterations() int {
+	return t.iterations
+}
+
+func (t *KMeansTrainer) GetClusterSizes() []float64 {
+	return append([]float64(nil), t.clusterSizes...)
+}
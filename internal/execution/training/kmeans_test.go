@@ -0,0 +1,108 @@
+package training
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// blobs returns n samples around each center
+func blobs(n int, centers [][]float64) [][]float64 {
+	rng := rand.New(rand.NewSource(1))
+	var features [][]float64
+	for _, center := range centers {
+		for i := 0; i < n; i++ {
+			sample := make([]float64, len(center))
+			for j, c := range center {
+				sample[j] = c + rng.NormFloat64()*0.3
+			}
+			features = append(features, sample)
+		}
+	}
+	return features
+}
+
+func TestKMeansFindsSeparatedClusters(t *testing.T) {
+	centers := [][]float64{{0, 0}, {10, 0}, {0, 10}}
+	features := blobs(50, centers)
+
+	for _, init := range []string{InitKMeansPlusPlus, InitRandom} {
+		t.Run(init, func(t *testing.T) {
+			trainer, err := NewKMeansTrainer(map[string]interface{}{
+				"num_clusters": 3,
+				"init":         init,
+				"n_init":       5,
+				"random_state": 1,
+			})
+			if err != nil {
+				t.Fatalf("NewKMeansTrainer() error = %v", err)
+			}
+			_, loss, accuracy, err := trainer.Train(context.Background(), features, make([]float64, len(features)), 100, 0, 0)
+			if err != nil {
+				t.Fatalf("Train() error = %v", err)
+			}
+			if accuracy < 0.99 || loss > 0.5 {
+				t.Errorf("loss = %v, accuracy = %v", loss, accuracy)
+			}
+
+			for _, center := range centers {
+				cluster := int(trainer.Predict(center))
+				if distance := math.Sqrt(squaredDistance(trainer.GetCentroids()[cluster], center)); distance > 0.2 {
+					t.Errorf("centroid for %v is %v away", center, distance)
+				}
+				if size := trainer.GetClusterSizes()[cluster]; size != 50 {
+					t.Errorf("cluster at %v has %v samples, want 50", center, size)
+				}
+			}
+
+			weights := trainer.GetModelWeights()
+			if len(weights["centroids"]) != 6 || len(weights["cluster_sizes"]) != 3 {
+				t.Errorf("weights = %v", weights)
+			}
+		})
+	}
+}
+
+func TestKMeansStartsFromInitialCentroids(t *testing.T) {
+	features := blobs(30, [][]float64{{0, 0}, {5, 5}})
+
+	trainer, err := NewKMeansTrainer(map[string]interface{}{
+		"initial_centroids": [][]float64{{4, 4}, {1, 1}},
+	})
+	if err != nil {
+		t.Fatalf("NewKMeansTrainer() error = %v", err)
+	}
+	if _, _, _, err := trainer.Train(context.Background(), features, make([]float64, len(features)), 50, 0, 0); err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+
+	// Cluster order follows the initial centroids
+	if trainer.Predict([]float64{5, 5}) != 0 || trainer.Predict([]float64{0, 0}) != 1 {
+		t.Errorf("centroids = %v", trainer.GetCentroids())
+	}
+	if trainer.GetIterations() >= 50 {
+		t.Errorf("iterations = %d, want convergence before the limit", trainer.GetIterations())
+	}
+}
+
+func TestKMeansRejectsInvalidConfigs(t *testing.T) {
+	for name, config := range map[string]map[string]interface{}{
+		"no clusters":       {},
+		"unknown init":      {"num_clusters": 2, "init": "forgy"},
+		"centroid mismatch": {"num_clusters": 3, "initial_centroids": [][]float64{{0}, {1}}},
+	} {
+		if _, err := NewKMeansTrainer(config); err == nil {
+			t.Errorf("%s: NewKMeansTrainer() succeeded", name)
+		}
+	}
+
+	trainer, err := NewKMeansTrainer(map[string]interface{}{"num_clusters": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	features := blobs(1, [][]float64{{0, 0}, {1, 1}})
+	if _, _, _, err := trainer.Train(context.Background(), features, make([]float64, len(features)), 10, 0, 0); err == nil {
+		t.Error("Train() with fewer samples than clusters succeeded")
+	}
+}
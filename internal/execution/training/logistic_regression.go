@@ -0,0 +1,385 @@
+package training
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	PenaltyNone       = "none"
+	PenaltyL1         = "l1"
+	PenaltyL2         = "l2"
+	PenaltyElasticNet = "elastic_net"
+)
+
+// LogisticRegressionTrainer implements binary and multinomial logistic
+// regression trained with mini-batch gradient descent. Labels are class indices
+// 0..NumClasses-1. Two classes use a single sigmoid output, more use softmax.
+type LogisticRegressionTrainer struct {
+	config       *LogisticRegressionConfig
+	inputSize    int
+	outputs      int
+	coefficients [][]float64 // One row of inputSize coefficients per output
+	intercepts   []float64
+	dataLoader   *DataLoader
+	rng          *rand.Rand
+	// lastGradients holds the weights after the last Train, like the other trainers
+	lastGradients map[string][]float64
+}
+
+// LogisticRegressionConfig sets the classes and the regularization. Penalty is
+// "none", "l1" (lasso), "l2" (ridge) or "elastic_net", which mixes the two with
+// L1Ratio. Alpha is the regularization strength. Intercepts are never
+// regularized.
+type LogisticRegressionConfig struct {
+	NumClasses  int     `json:"num_classes"`
+	Penalty     string  `json:"penalty"`
+	Alpha       float64 `json:"alpha"`
+	L1Ratio     float64 `json:"l1_ratio"`
+	RandomState int64   `json:"random_state"`
+}
+
+func NewLogisticRegressionTrainer(config map[string]interface{}) (*LogisticRegressionTrainer, error) {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	var lrConfig LogisticRegressionConfig
+	if err := json.Unmarshal(configBytes, &lrConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal logistic regression config: %w", err)
+	}
+
+	switch lrConfig.Penalty {
+	case "":
+		lrConfig.Penalty = PenaltyL2
+	case "lasso":
+		lrConfig.Penalty = PenaltyL1
+	case "ridge":
+		lrConfig.Penalty = PenaltyL2
+	case "elasticnet":
+		lrConfig.Penalty = PenaltyElasticNet
+	case PenaltyNone, PenaltyL1, PenaltyL2, PenaltyElasticNet:
+	default:
+		return nil, fmt.Errorf("unsupported penalty: %s", lrConfig.Penalty)
+	}
+	if lrConfig.Alpha < 0 {
+		return nil, fmt.Errorf("alpha must not be negative")
+	}
+	if lrConfig.Alpha == 0 && lrConfig.Penalty != PenaltyNone {
+		lrConfig.Alpha = 1e-4
+	}
+	if lrConfig.Penalty == PenaltyElasticNet {
+		if lrConfig.L1Ratio < 0 || lrConfig.L1Ratio > 1 {
+			return nil, fmt.Errorf("l1_ratio must be between 0 and 1")
+		}
+		if lrConfig.L1Ratio == 0 {
+			lrConfig.L1Ratio = 0.5
+		}
+	}
+	if lrConfig.NumClasses == 1 || lrConfig.NumClasses < 0 {
+		return nil, fmt.Errorf("num_classes must be at least 2")
+	}
+	if lrConfig.RandomState == 0 {
+		lrConfig.RandomState = time.Now().UnixNano()
+	}
+
+	return &LogisticRegressionTrainer{
+		config:     &lrConfig,
+		dataLoader: NewDataLoader(""),
+		rng:        rand.New(rand.NewSource(lrConfig.RandomState)),
+	}, nil
+}
+
+// LoadData loads training data from IPFS
+func (t *LogisticRegressionTrainer) LoadData(ctx context.Context, datasetCID string, format string) ([][]float64, []float64, error) {
+	return t.LoadPartitionedData(ctx, datasetCID, format, nil)
+}
+
+func (t *LogisticRegressionTrainer) LoadPartitionedData(ctx context.Context, datasetCID string, format string, partitionConfig *PartitionConfig) ([][]float64, []float64, error) {
+	if datasetCID == "" {
+		return nil, nil, fmt.Errorf("dataset CID is required")
+	}
+
+	features, labels, err := t.dataLoader.LoadPartitionedData(ctx, datasetCID, format, partitionConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load data from IPFS: %w", err)
+	}
+	if err := validateTrainingData(features, labels); err != nil {
+		return nil, nil, err
+	}
+	return features, labels, nil
+}
+
+// Train runs mini-batch gradient descent. L2 is applied through the gradient
+// and L1 with a soft-threshold after each step, which sets coefficients to
+// exactly zero.
+func (t *LogisticRegressionTrainer) Train(ctx context.Context, features [][]float64, labels []float64, epochs int, batchSize int, learningRate float64) ([]float64, float64, float64, error) {
+	if err := validateTrainingData(features, labels); err != nil {
+		return nil, 0, 0, err
+	}
+	if learningRate <= 0 {
+		return nil, 0, 0, fmt.Errorf("learning rate must be positive, got %f", learningRate)
+	}
+	if epochs <= 0 || batchSize <= 0 {
+		return nil, 0, 0, fmt.Errorf("epochs and batch size must be positive")
+	}
+
+	numClasses := t.config.NumClasses
+	for i, label := range labels {
+		if label < 0 || label != math.Trunc(label) {
+			return nil, 0, 0, fmt.Errorf("label at sample %d is not a class index: %v", i, label)
+		}
+		if t.config.NumClasses == 0 {
+			numClasses = max(numClasses, int(label)+1, 2)
+		} else if int(label) >= numClasses {
+			return nil, 0, 0, fmt.Errorf("label at sample %d is %v but num_classes is %d", i, label, numClasses)
+		}
+	}
+	t.initializeWeights(len(features[0]), numClasses)
+
+	l1, l2 := t.penalties()
+	numSamples := len(features)
+	batchSize = min(batchSize, numSamples)
+
+	gradCoefficients := make([][]float64, t.outputs)
+	for k := range gradCoefficients {
+		gradCoefficients[k] = make([]float64, t.inputSize)
+	}
+	gradIntercepts := make([]float64, t.outputs)
+	errs := make([]float64, t.outputs)
+
+	for epoch := 0; epoch < epochs; epoch++ {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, 0, err
+		}
+
+		indices := t.rng.Perm(numSamples)
+		for start := 0; start < numSamples; start += batchSize {
+			batch := indices[start:min(start+batchSize, numSamples)]
+			for k := range gradCoefficients {
+				clear(gradCoefficients[k])
+			}
+			clear(gradIntercepts)
+
+			for _, idx := range batch {
+				t.outputErrors(features[idx], int(labels[idx]), errs)
+				for k, e := range errs {
+					gradIntercepts[k] += e
+					for j, x := range features[idx] {
+						gradCoefficients[k][j] += e * x
+					}
+				}
+			}
+
+			scale := 1 / float64(len(batch))
+			for k := range t.coefficients {
+				t.intercepts[k] -= learningRate * gradIntercepts[k] * scale
+				for j := range t.coefficients[k] {
+					w := t.coefficients[k][j] - learningRate*(gradCoefficients[k][j]*scale+l2*t.coefficients[k][j])
+					t.coefficients[k][j] = softThreshold(w, learningRate*l1)
+				}
+			}
+		}
+	}
+
+	loss, accuracy := t.evaluate(features, labels)
+	if math.IsNaN(loss) || math.IsInf(loss, 0) {
+		return nil, 0, 0, fmt.Errorf("training produced NaN/Inf loss - lower the learning rate")
+	}
+
+	// Store the current weights as gradients (for federated learning)
+	t.lastGradients = t.GetModelWeights()
+
+	return t.flattenWeights(), loss, accuracy, nil
+}
+
+func (t *LogisticRegressionTrainer) initializeWeights(inputSize, numClasses int) {
+	outputs := numClasses
+	if numClasses == 2 {
+		outputs = 1
+	}
+	// Keep the weights of an earlier round when the shape is unchanged
+	if t.inputSize == inputSize && t.outputs == outputs && t.coefficients != nil {
+		return
+	}
+
+	t.inputSize = inputSize
+	t.outputs = outputs
+	t.coefficients = make([][]float64, outputs)
+	for k := range t.coefficients {
+		t.coefficients[k] = make([]float64, inputSize)
+	}
+	t.intercepts = make([]float64, outputs)
+}
+
+// penalties returns the L1 and L2 strength of the configured penalty
+func (t *LogisticRegressionTrainer) penalties() (float64, float64) {
+	switch t.config.Penalty {
+	case PenaltyL1:
+		return t.config.Alpha, 0
+	case PenaltyL2:
+		return 0, t.config.Alpha
+	case PenaltyElasticNet:
+		return t.config.Alpha * t.config.L1Ratio, t.config.Alpha * (1 - t.config.L1Ratio)
+	default:
+		return 0, 0
+	}
+}
+
+func softThreshold(w, threshold float64) float64 {
+	switch {
+	case w > threshold:
+		return w - threshold
+	case w < -threshold:
+		return w + threshold
+	default:
+		return 0
+	}
+}
+
+// probabilities returns P(label=1) for binary models and the softmax over the
+// classes for multinomial ones
+func (t *LogisticRegressionTrainer) probabilities(sample []float64) []float64 {
+	scores := make([]float64, t.outputs)
+	for k := range scores {
+		scores[k] = t.intercepts[k]
+		for j, x := range sample {
+			scores[k] += t.coefficients[k][j] * x
+		}
+	}
+	if t.outputs == 1 {
+		scores[0] = sigmoid(scores[0])
+		return scores
+	}
+
+	maxScore := scores[0]
+	for _, score := range scores[1:] {
+		maxScore = math.Max(maxScore, score)
+	}
+	sum := 0.0
+	for k, score := range scores {
+		scores[k] = math.Exp(score - maxScore)
+		sum += scores[k]
+	}
+	for k := range scores {
+		scores[k] /= sum
+	}
+	return scores
+}
+
+// outputErrors writes the derivative of the cross-entropy loss with respect to
+// each output's score
+func (t *LogisticRegressionTrainer) outputErrors(sample []float64, label int, errs []float64) {
+	probabilities := t.probabilities(sample)
+	if t.outputs == 1 {
+		errs[0] = probabilities[0] - float64(label)
+		return
+	}
+	for k, p := range probabilities {
+		errs[k] = p
+	}
+	errs[label]--
+}
+
+// evaluate returns the mean cross-entropy, penalty included, and the accuracy
+func (t *LogisticRegressionTrainer) evaluate(features [][]float64, labels []float64) (float64, float64) {
+	const eps = 1e-15
+	loss := 0.0
+	correct := 0
+	for i, sample := range features {
+		label := int(labels[i])
+		if t.outputs == 1 {
+			p := math.Min(math.Max(t.probabilities(sample)[0], eps), 1-eps)
+			if label == 1 {
+				loss -= math.Log(p)
+			} else {
+				loss -= math.Log(1 - p)
+			}
+		} else {
+			loss -= math.Log(math.Max(t.probabilities(sample)[label], eps))
+		}
+		if t.Predict(sample) == labels[i] {
+			correct++
+		}
+	}
+	loss /= float64(len(features))
+
+	l1, l2 := t.penalties()
+	for _, row := range t.coefficients {
+		for _, w := range row {
+			loss += l1*math.Abs(w) + 0.5*l2*w*w
+		}
+	}
+	return loss, float64(correct) / float64(len(features))
+}
+
+// Predict returns the most likely class
+func (t *LogisticRegressionTrainer) Predict(sample []float64) float64 {
+	probabilities := t.probabilities(sample)
+	if t.outputs == 1 {
+		if probabilities[0] >= 0.5 {
+			return 1
+		}
+		return 0
+	}
+
+	best := 0
+	for k, p := range probabilities {
+		if p > probabilities[best] {
+			best = k
+		}
+	}
+	return float64(best)
+}
+
+func (t *LogisticRegressionTrainer) flattenWeights() []float64 {
+	weights := t.GetModelWeights()
+	return append(weights["coefficients"], weights["intercepts"]...)
+}
+
+// GetModelWeights returns the coefficients, one row of inputSize values per
+// output, and the intercepts
+func (t *LogisticRegressionTrainer) GetModelWeights() map[string][]float64 {
+	coefficients := make([]float64, 0, t.outputs*t.inputSize)
+	for _, row := range t.coefficients {
+		coefficients = append(coefficients, row...)
+	}
+	return map[string][]float64{
+		"coefficients": coefficients,
+		"intercepts":   append([]float64(nil), t.intercepts...),
+	}
+}
+
+// GetGradients returns the gradients from the last training step
+func (t *LogisticRegressionTrainer) GetGradients() map[string][]float64 {
+	if t.lastGradients == nil {
+		return map[string][]float64{
+			"coefficients": make([]float64, t.outputs*t.inputSize),
+			"intercepts":   make([]float64, t.outputs),
+		}
+	}
+
+	gradientsCopy := make(map[string][]float64)
+	for key, values := range t.lastGradients {
+		gradientsCopy[key] = append([]float64(nil), values...)
+	}
+	return gradientsCopy
+}
+
+// GetNonZeroCoefficients counts the coefficients an L1 penalty left non-zero
+func (t *LogisticRegressionTrainer) GetNonZeroCoefficients() int {
+	count := 0
+	for _, row := range t.coefficients {
+		for _, w := range row {
+			if w != 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,108 @@
+package training
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLogisticRegressionBinary(t *testing.T) {
+	features, labels := syntheticData(400, func(x []float64) float64 {
+		if 2*x[0]-x[1] > 0.5 {
+			return 1
+		}
+		return 0
+	})
+
+	trainer, err := NewLogisticRegressionTrainer(map[string]interface{}{"penalty": "ridge", "random_state": 1})
+	if err != nil {
+		t.Fatalf("NewLogisticRegressionTrainer() error = %v", err)
+	}
+	_, loss, accuracy, err := trainer.Train(context.Background(), features, labels, 200, 32, 0.5)
+	if err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+	if accuracy < 0.95 || loss > 0.3 {
+		t.Errorf("loss = %v, accuracy = %v", loss, accuracy)
+	}
+
+	weights := trainer.GetModelWeights()
+	if len(weights["coefficients"]) != 3 || len(weights["intercepts"]) != 1 {
+		t.Fatalf("weights = %v", weights)
+	}
+	if weights["coefficients"][0] <= 0 || weights["coefficients"][1] >= 0 {
+		t.Errorf("coefficients = %v, want a positive first and negative second", weights["coefficients"])
+	}
+	if gradients := trainer.GetGradients(); len(gradients["coefficients"]) != 3 {
+		t.Errorf("gradients = %v", gradients)
+	}
+}
+
+func TestLogisticRegressionMultinomial(t *testing.T) {
+	features, labels := syntheticData(600, func(x []float64) float64 {
+		switch {
+		case x[0] < 0.33:
+			return 0
+		case x[0] < 0.66:
+			return 1
+		default:
+			return 2
+		}
+	})
+
+	trainer, err := NewLogisticRegressionTrainer(map[string]interface{}{"penalty": "none", "random_state": 1})
+	if err != nil {
+		t.Fatalf("NewLogisticRegressionTrainer() error = %v", err)
+	}
+	_, _, accuracy, err := trainer.Train(context.Background(), features, labels, 300, 32, 0.5)
+	if err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+	if accuracy < 0.85 {
+		t.Errorf("accuracy = %v", accuracy)
+	}
+	weights := trainer.GetModelWeights()
+	if len(weights["coefficients"]) != 9 || len(weights["intercepts"]) != 3 {
+		t.Errorf("weights = %v", weights)
+	}
+}
+
+func TestLogisticRegressionL1ZeroesIrrelevantFeatures(t *testing.T) {
+	features, labels := syntheticData(400, func(x []float64) float64 {
+		if x[0] > 0.5 {
+			return 1
+		}
+		return 0
+	})
+
+	trainer, err := NewLogisticRegressionTrainer(map[string]interface{}{"penalty": "l1", "alpha": 0.05, "random_state": 1})
+	if err != nil {
+		t.Fatalf("NewLogisticRegressionTrainer() error = %v", err)
+	}
+	if _, _, _, err := trainer.Train(context.Background(), features, labels, 100, 32, 0.5); err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+	coefficients := trainer.GetModelWeights()["coefficients"]
+	if coefficients[0] == 0 || coefficients[1] != 0 || coefficients[2] != 0 {
+		t.Errorf("coefficients = %v, want only the first non-zero", coefficients)
+	}
+	if trainer.GetNonZeroCoefficients() != 1 {
+		t.Errorf("non-zero coefficients = %d", trainer.GetNonZeroCoefficients())
+	}
+}
+
+func TestLogisticRegressionRejectsBadInput(t *testing.T) {
+	if _, err := NewLogisticRegressionTrainer(map[string]interface{}{"penalty": "l3"}); err == nil {
+		t.Error("expected an unsupported penalty to be rejected")
+	}
+	if _, err := NewLogisticRegressionTrainer(map[string]interface{}{"penalty": "elastic_net", "l1_ratio": 2}); err == nil {
+		t.Error("expected an l1_ratio above 1 to be rejected")
+	}
+
+	trainer, _ := NewLogisticRegressionTrainer(map[string]interface{}{"num_classes": 2})
+	if _, _, _, err := trainer.Train(context.Background(), [][]float64{{1}, {2}}, []float64{0, 2}, 1, 1, 0.1); err == nil {
+		t.Error("expected a label outside num_classes to be rejected")
+	}
+	if _, _, _, err := trainer.Train(context.Background(), [][]float64{{1}, {2}}, []float64{0, 0.5}, 1, 1, 0.1); err == nil {
+		t.Error("expected a fractional label to be rejected")
+	}
+}
@@ -3,6 +3,7 @@ package training
 import (
 	"context"
 	"fmt"
+	"math"
 )
 
 // TrainingResult contains the results of local training
@@ -37,9 +38,53 @@ func NewTrainer(modelType string, config map[string]interface{}, globalModel map
 		return NewNeuralNetworkTrainer(config)
 	case "linear_regression":
 		return NewLinearRegressionTrainer(config)
+	case "logistic_regression":
+		return NewLogisticRegressionTrainer(config)
 	case "random_forest":
 		return NewRandomForestTrainer(config)
+	case "gradient_boosting":
+		return NewGradientBoostingTrainer(config)
+	case "kmeans":
+		return NewKMeansTrainer(config)
 	default:
 		return nil, fmt.Errorf("unsupported model type: %s", modelType)
 	}
 }
+
+// validateTrainingData checks that every sample has the same number of features
+// and a finite label
+func validateTrainingData(features [][]float64, labels []float64) error {
+	if len(features) == 0 || len(labels) == 0 {
+		return fmt.Errorf("empty training data")
+	}
+	if len(features) != len(labels) {
+		return fmt.Errorf("feature and label count mismatch: %d features, %d labels", len(features), len(labels))
+	}
+	if err := validateFeatures(features); err != nil {
+		return err
+	}
+	for i, label := range labels {
+		if math.IsNaN(label) || math.IsInf(label, 0) {
+			return fmt.Errorf("invalid label at sample %d: %v", i, label)
+		}
+	}
+	return nil
+}
+
+// validateFeatures checks that every sample has the same number of features,
+// for trainers that do not use labels
+func validateFeatures(features [][]float64) error {
+	if len(features) == 0 {
+		return fmt.Errorf("empty training data")
+	}
+	featureDim := len(features[0])
+	if featureDim == 0 {
+		return fmt.Errorf("features have zero dimensions")
+	}
+	for i, feature := range features {
+		if len(feature) != featureDim {
+			return fmt.Errorf("inconsistent feature dimensions at sample %d: expected %d, got %d", i, featureDim, len(feature))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+// Package wasm runs WebAssembly tasks with wazero. Modules get WASI preview 1
+// with the arguments, environment and stdin of the task and nothing else: no
+// filesystem, no network, and fake clocks and random source, so a module given
+// the same input produces the same output on every runner.
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	pageSize = 64 << 10
+	// maxPages is the most linear memory a 32-bit module can address
+	maxPages = 1 << 16
+
+	// DefaultMemoryLimit caps the linear memory of a module
+	DefaultMemoryLimit int64 = 256 << 20
+	// DefaultFuel caps the function calls a module may make
+	DefaultFuel uint64 = 1 << 30
+
+	maxOutputBytes = 4 << 20
+)
+
+var (
+	ErrOutOfFuel = errors.New("module ran out of fuel")
+	ErrTimeout   = errors.New("module exceeded its time limit")
+)
+
+// Limits bound a module run. Fuel is counted per function call, so a loop that
+// calls nothing is bounded only by the context deadline.
+type Limits struct {
+	MemoryBytes int64
+	Fuel        uint64
+}
+
+func DefaultLimits() Limits {
+	return Limits{MemoryBytes: DefaultMemoryLimit, Fuel: DefaultFuel}
+}
+
+// Result is the outcome of a module that was instantiated. Output holds what it
+// wrote to stdout and stderr, cut off at a few megabytes.
+type Result struct {
+	Output   string
+	ExitCode int
+	FuelUsed uint64
+	Error    string
+}
+
+// Run compiles module and calls its entrypoint until it returns, exits, traps or
+// hits a limit. Errors are returned for modules that cannot be run at all and
+// for ctx ending; everything the module itself does is reported in the Result.
+func Run(ctx context.Context, module []byte, config *models.WasmConfig, env map[string]string, limits Limits) (*Result, error) {
+	pages := uint32(maxPages)
+	if limits.MemoryBytes > 0 && limits.MemoryBytes/pageSize < maxPages {
+		pages = uint32(max(limits.MemoryBytes/pageSize, 1))
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	runtime := wazero.NewRuntimeWithConfig(runCtx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(pages).
+		WithCloseOnContextDone(true))
+	defer runtime.Close(context.Background())
+
+	if _, err := wasi_snapshot_preview1.Instantiate(runCtx, runtime); err != nil {
+		return nil, fmt.Errorf("failed to instantiate WASI: %w", err)
+	}
+
+	meter := &fuelMeter{limit: limits.Fuel, cancel: cancel}
+	compiled, err := runtime.CompileModule(experimental.WithFunctionListenerFactory(runCtx, meter), module)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile wasm module: %w", err)
+	}
+
+	output := &limitedBuffer{limit: maxOutputBytes}
+	moduleConfig := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(append([]string{"task"}, config.Args...)...).
+		WithStdin(strings.NewReader(config.Stdin)).
+		WithStdout(output).
+		WithStderr(output)
+	for key, value := range env {
+		moduleConfig = moduleConfig.WithEnv(key, value)
+	}
+	if config.Entrypoint != "" {
+		moduleConfig = moduleConfig.WithStartFunctions()
+	}
+
+	mod, err := runtime.InstantiateModule(runCtx, compiled, moduleConfig)
+	if err == nil && config.Entrypoint != "" {
+		fn := mod.ExportedFunction(config.Entrypoint)
+		if fn == nil {
+			return nil, fmt.Errorf("wasm module does not export %q", config.Entrypoint)
+		}
+		_, err = fn.Call(runCtx)
+	}
+
+	result := &Result{Output: output.String(), FuelUsed: meter.used.Load()}
+	if err == nil {
+		return result, nil
+	}
+
+	var exitErr *sys.ExitError
+	switch {
+	case meter.exhausted.Load():
+		result.ExitCode = -1
+		result.Error = ErrOutOfFuel.Error()
+	case ctx.Err() != nil:
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrTimeout
+		}
+		return nil, ctx.Err()
+	case errors.As(err, &exitErr):
+		result.ExitCode = int(exitErr.ExitCode())
+		if result.ExitCode != 0 {
+			result.Error = fmt.Sprintf("module exited with code %d", result.ExitCode)
+		}
+	default:
+		result.ExitCode = -1
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+// Check compiles module and makes sure it exports the function a run would call,
+// without running it
+func Check(ctx context.Context, module []byte, config *models.WasmConfig) error {
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(context.Background())
+
+	compiled, err := runtime.CompileModule(ctx, module)
+	if err != nil {
+		return fmt.Errorf("failed to compile wasm module: %w", err)
+	}
+	entrypoint := config.Entrypoint
+	if entrypoint == "" {
+		entrypoint = "_start"
+	}
+	if _, ok := compiled.ExportedFunctions()[entrypoint]; !ok {
+		return fmt.Errorf("wasm module does not export %q", entrypoint)
+	}
+	return nil
+}
+
+// fuelMeter counts the function calls of a module and cancels the run once it
+// has made more than limit
+type fuelMeter struct {
+	limit     uint64
+	cancel    context.CancelFunc
+	used      atomic.Uint64
+	exhausted atomic.Bool
+}
+
+func (m *fuelMeter) NewFunctionListener(api.FunctionDefinition) experimental.FunctionListener {
+	return m
+}
+
+func (m *fuelMeter) Before(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {
+	if used := m.used.Add(1); m.limit > 0 && used > m.limit && !m.exhausted.Swap(true) {
+		m.cancel()
+	}
+}
+
+func (m *fuelMeter) After(context.Context, api.Module, api.FunctionDefinition, []uint64) {}
+
+func (m *fuelMeter) Abort(context.Context, api.Module, api.FunctionDefinition, error) {}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the rest
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
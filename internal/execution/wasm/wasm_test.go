@@ -0,0 +1,124 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// wasmModule assembles a module from its sections, each given as id followed by
+// its contents
+func wasmModule(sections ...[]byte) []byte {
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	for _, section := range sections {
+		module = append(module, section[0], byte(len(section)-1))
+		module = append(module, section[1:]...)
+	}
+	return module
+}
+
+func name(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+// helloModule writes "hello\n" to stdout and exits with code 3
+var helloModule = wasmModule(
+	// types: (i32 i32 i32 i32) -> i32, (i32) -> (), () -> ()
+	[]byte{0x01, 0x03, 0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f, 0x60, 0x01, 0x7f, 0x00, 0x60, 0x00, 0x00},
+	concat([]byte{0x02, 0x02},
+		name("wasi_snapshot_preview1"), name("fd_write"), []byte{0x00, 0x00},
+		name("wasi_snapshot_preview1"), name("proc_exit"), []byte{0x00, 0x01}),
+	[]byte{0x03, 0x01, 0x02},
+	[]byte{0x05, 0x01, 0x00, 0x01},
+	concat([]byte{0x07, 0x02}, name("memory"), []byte{0x02, 0x00}, name("_start"), []byte{0x00, 0x02}),
+	[]byte{0x0a, 0x01, 0x12, 0x00,
+		0x41, 0x01, 0x41, 0x00, 0x41, 0x01, 0x41, 0xe4, 0x00, 0x10, 0x00, 0x1a, // fd_write(1, iovec, 1, 100)
+		0x41, 0x03, 0x10, 0x01, // proc_exit(3)
+		0x0b},
+	concat([]byte{0x0b, 0x01, 0x00, 0x41, 0x00, 0x0b, 0x0e},
+		[]byte{0x08, 0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00}, []byte("hello\n")),
+)
+
+// callLoopModule calls an empty function forever
+var callLoopModule = wasmModule(
+	[]byte{0x01, 0x01, 0x60, 0x00, 0x00},
+	[]byte{0x03, 0x02, 0x00, 0x00},
+	concat([]byte{0x07, 0x01}, name("_start"), []byte{0x00, 0x00}),
+	[]byte{0x0a, 0x02,
+		0x09, 0x00, 0x03, 0x40, 0x10, 0x01, 0x0c, 0x00, 0x0b, 0x0b,
+		0x02, 0x00, 0x0b},
+)
+
+// spinModule loops forever without calling anything
+var spinModule = wasmModule(
+	[]byte{0x01, 0x01, 0x60, 0x00, 0x00},
+	[]byte{0x03, 0x01, 0x00},
+	concat([]byte{0x07, 0x01}, name("_start"), []byte{0x00, 0x00}),
+	[]byte{0x0a, 0x01, 0x07, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b},
+)
+
+func TestRunCapturesOutputAndExitCode(t *testing.T) {
+	result, err := Run(context.Background(), helloModule, &models.WasmConfig{}, nil, DefaultLimits())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Output != "hello\n" {
+		t.Errorf("Output = %q, want %q", result.Output, "hello\n")
+	}
+	if result.ExitCode != 3 || result.Error == "" {
+		t.Errorf("ExitCode = %d, Error = %q, want exit code 3 with an error", result.ExitCode, result.Error)
+	}
+	if result.FuelUsed == 0 {
+		t.Error("expected fuel to be used")
+	}
+}
+
+func TestRunStopsWhenOutOfFuel(t *testing.T) {
+	result, err := Run(context.Background(), callLoopModule, &models.WasmConfig{}, nil, Limits{Fuel: 1000})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Error != ErrOutOfFuel.Error() {
+		t.Fatalf("Error = %q, want %q", result.Error, ErrOutOfFuel)
+	}
+	if result.FuelUsed <= 1000 {
+		t.Errorf("FuelUsed = %d, want more than the limit", result.FuelUsed)
+	}
+}
+
+func TestRunStopsAtDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := Run(ctx, spinModule, &models.WasmConfig{}, nil, DefaultLimits()); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Run() error = %v, want ErrTimeout", err)
+	}
+}
+
+func TestRunEnforcesMemoryLimit(t *testing.T) {
+	// 16 pages of memory and nothing else
+	module := wasmModule([]byte{0x05, 0x01, 0x00, 0x10})
+	if _, err := Run(context.Background(), module, &models.WasmConfig{}, nil, Limits{MemoryBytes: 4 * pageSize}); err == nil {
+		t.Fatal("expected a module needing more memory than the limit to be rejected")
+	}
+	if _, err := Run(context.Background(), module, &models.WasmConfig{}, nil, Limits{MemoryBytes: 16 * pageSize}); err != nil {
+		t.Fatalf("Run() within the memory limit error = %v", err)
+	}
+}
+
+func TestRunMissingEntrypoint(t *testing.T) {
+	if _, err := Run(context.Background(), helloModule, &models.WasmConfig{Entrypoint: "missing"}, nil, DefaultLimits()); err == nil {
+		t.Fatal("expected a missing entrypoint to fail")
+	}
+}
@@ -0,0 +1,156 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// Earnings is what one coordinator reports a runner has been paid and is owed
+type Earnings struct {
+	DeviceID     string     `json:"device_id"`
+	PaidTotal    float64    `json:"paid_total"`
+	PaidCount    int        `json:"paid_count"`
+	LastPaidAt   *time.Time `json:"last_paid_at,omitempty"`
+	PendingTotal float64    `json:"pending_total"`
+}
+
+// CoordinatorEarnings is one coordinator's report, or why it could not be fetched
+type CoordinatorEarnings struct {
+	Coordinator string    `json:"coordinator"`
+	Earnings    *Earnings `json:"earnings,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// EarningsSummary adds up the earnings reported by every coordinator
+type EarningsSummary struct {
+	Coordinators []CoordinatorEarnings `json:"coordinators"`
+	PaidTotal    float64               `json:"paid_total"`
+	PaidCount    int                   `json:"paid_count"`
+	PendingTotal float64               `json:"pending_total"`
+}
+
+// FetchEarnings asks every coordinator for the earnings of deviceID. A
+// coordinator that cannot be reached is reported without failing the rest.
+func FetchEarnings(ctx context.Context, client *http.Client, coordinators []Coordinator, deviceID string) *EarningsSummary {
+	summary := &EarningsSummary{Coordinators: make([]CoordinatorEarnings, len(coordinators))}
+
+	var wg sync.WaitGroup
+	for i, coordinator := range coordinators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report := CoordinatorEarnings{Coordinator: coordinator.Name}
+			earnings, err := fetchEarnings(ctx, client, coordinator.ServerURL, deviceID)
+			if err != nil {
+				report.Error = err.Error()
+			} else {
+				report.Earnings = earnings
+			}
+			summary.Coordinators[i] = report
+		}()
+	}
+	wg.Wait()
+
+	for _, report := range summary.Coordinators {
+		if report.Earnings == nil {
+			continue
+		}
+		summary.PaidTotal += report.Earnings.PaidTotal
+		summary.PaidCount += report.Earnings.PaidCount
+		summary.PendingTotal += report.Earnings.PendingTotal
+	}
+	return summary
+}
+
+func fetchEarnings(ctx context.Context, client *http.Client, serverURL, deviceID string) (*Earnings, error) {
+	base := strings.TrimSuffix(strings.TrimSuffix(serverURL, "/"), "/api")
+	reqURL := fmt.Sprintf("%s/api/v1/earnings/%s", base, url.PathEscape(deviceID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create earnings request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch earnings: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("earnings request failed with status %d", resp.StatusCode)
+	}
+	var earnings Earnings
+	if err := json.NewDecoder(resp.Body).Decode(&earnings); err != nil {
+		return nil, fmt.Errorf("failed to decode earnings: %w", err)
+	}
+	return &earnings, nil
+}
+
+// FetchEarningsReport merges the earnings reports every coordinator keeps for
+// the wallet's runners in month. Unlike FetchEarnings it fails when any
+// coordinator cannot answer, since a report missing payouts is no use for
+// accounting.
+func FetchEarningsReport(ctx context.Context, client *http.Client, coordinators []Coordinator, walletAddress string, month time.Time) (*models.EarningsReport, error) {
+	reports := make([]*models.EarningsReport, len(coordinators))
+	errs := make([]error, len(coordinators))
+
+	var wg sync.WaitGroup
+	for i, coordinator := range coordinators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i], errs[i] = fetchEarningsReport(ctx, client, coordinator.ServerURL, walletAddress, month)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("coordinator %s: %w", coordinator.Name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var rewards []models.PaidReward
+	for i, report := range reports {
+		for _, reward := range report.Rewards {
+			if len(coordinators) > 1 {
+				reward.Coordinator = coordinators[i].Name
+			}
+			rewards = append(rewards, reward)
+		}
+	}
+	return models.NewEarningsReport(walletAddress, month, rewards), nil
+}
+
+func fetchEarningsReport(ctx context.Context, client *http.Client, serverURL, walletAddress string, month time.Time) (*models.EarningsReport, error) {
+	base := strings.TrimSuffix(strings.TrimSuffix(serverURL, "/"), "/api")
+	reqURL := fmt.Sprintf("%s/api/v1/wallets/%s/earnings?month=%s", base, url.PathEscape(walletAddress), month.Format("2006-01"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create earnings report request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch earnings report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("earnings report request failed with status %d", resp.StatusCode)
+	}
+	var report models.EarningsReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("failed to decode earnings report: %w", err)
+	}
+	return &report, nil
+}
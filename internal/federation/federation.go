@@ -0,0 +1,251 @@
+// Package federation lets one runner take work from several coordinator servers.
+// Every coordinator gets its own task queue, and queued tasks are started in
+// weighted fair-share order so no coordinator monopolizes the runner.
+package federation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+var (
+	ErrQueueFull          = errors.New("coordinator queue is full")
+	ErrUnknownCoordinator = errors.New("unknown coordinator")
+)
+
+// Coordinator is a server the runner registers with. Weight is its share of
+// the runner relative to the other coordinators.
+type Coordinator struct {
+	Name      string
+	ServerURL string
+	Weight    int
+}
+
+// ParseCoordinators returns the primary server followed by the coordinators in
+// list, a comma separated list of server URLs. A URL may end in #<weight>, e.g.
+// https://pool.example#2; the default weight is 1. Listing the primary server
+// only sets its weight.
+func ParseCoordinators(primary, list string) ([]Coordinator, error) {
+	coordinators := []Coordinator{{Name: coordinatorName(primary), ServerURL: primary, Weight: 1}}
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		serverURL, weight := entry, 1
+		if base, fragment, ok := strings.Cut(entry, "#"); ok {
+			n, err := strconv.Atoi(fragment)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid weight in coordinator %q", entry)
+			}
+			serverURL, weight = base, n
+		}
+		parsed, err := url.Parse(serverURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid coordinator URL %q", serverURL)
+		}
+
+		coordinator := Coordinator{Name: coordinatorName(serverURL), ServerURL: serverURL, Weight: weight}
+		duplicate := false
+		for i := range coordinators {
+			if coordinators[i].ServerURL == serverURL || coordinators[i].Name == coordinator.Name {
+				if i != 0 {
+					return nil, fmt.Errorf("coordinator %s is listed twice", coordinator.Name)
+				}
+				coordinators[i].Weight = weight
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			coordinators = append(coordinators, coordinator)
+		}
+	}
+	return coordinators, nil
+}
+
+func coordinatorName(serverURL string) string {
+	if parsed, err := url.Parse(serverURL); err == nil && parsed.Host != "" {
+		return parsed.Host
+	}
+	return serverURL
+}
+
+// QueueStats describes one coordinator's queue
+type QueueStats struct {
+	Coordinator string `json:"coordinator"`
+	Weight      int    `json:"weight"`
+	Queued      int    `json:"queued"`
+	Started     int    `json:"started"`
+}
+
+type queue struct {
+	coordinator Coordinator
+	tasks       []*models.Task
+	started     int
+}
+
+// Scheduler holds the tasks offered by each coordinator until the runner can
+// take them. Next hands out the task of the coordinator that has had the least
+// work for its weight.
+type Scheduler struct {
+	mu     sync.Mutex
+	queues []*queue
+	size   int
+	queued map[string]bool
+	ready  chan struct{}
+}
+
+// NewScheduler keeps up to size tasks queued per coordinator
+func NewScheduler(coordinators []Coordinator, size int) *Scheduler {
+	s := &Scheduler{
+		size:   max(size, 1),
+		queued: make(map[string]bool),
+		ready:  make(chan struct{}, 1),
+	}
+	for _, coordinator := range coordinators {
+		s.queues = append(s.queues, &queue{coordinator: coordinator})
+	}
+	return s
+}
+
+func (s *Scheduler) queue(name string) *queue {
+	for _, q := range s.queues {
+		if q.coordinator.Name == name {
+			return q
+		}
+	}
+	return nil
+}
+
+// Enqueue queues a task offered by a coordinator. A task that is already
+// queued is ignored.
+func (s *Scheduler) Enqueue(coordinator string, task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := s.queue(coordinator)
+	if q == nil {
+		return fmt.Errorf("%w %s", ErrUnknownCoordinator, coordinator)
+	}
+	id := task.ID.String()
+	if s.queued[id] {
+		return nil
+	}
+	if len(q.tasks) >= s.size {
+		return ErrQueueFull
+	}
+	q.tasks = append(q.tasks, task)
+	s.queued[id] = true
+	s.signal()
+	return nil
+}
+
+// Requeue puts back a task Next returned that the runner could not start, at
+// the front of its queue, and takes back the share it was charged
+func (s *Scheduler) Requeue(coordinator string, task *models.Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := s.queue(coordinator)
+	if q == nil {
+		return
+	}
+	q.tasks = append([]*models.Task{task}, q.tasks...)
+	q.started = max(q.started-1, 0)
+	s.queued[task.ID.String()] = true
+	s.signal()
+}
+
+// Record charges a coordinator for a task that reached the runner without
+// going through the queue, such as one pushed by webhook
+func (s *Scheduler) Record(coordinator string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q := s.queue(coordinator); q != nil {
+		q.started++
+	}
+}
+
+// Next waits for a queued task and returns it with the coordinator that
+// offered it
+func (s *Scheduler) Next(ctx context.Context) (string, *models.Task, error) {
+	for {
+		if name, task := s.pop(); task != nil {
+			return name, task, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		case <-s.ready:
+		}
+	}
+}
+
+func (s *Scheduler) pop() (string, *models.Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *queue
+	for _, q := range s.queues {
+		if len(q.tasks) == 0 {
+			continue
+		}
+		// started/weight < next.started/next.weight without dividing
+		if next == nil || q.started*next.coordinator.Weight < next.started*q.coordinator.Weight {
+			next = q
+		}
+	}
+	if next == nil {
+		return "", nil
+	}
+
+	task := next.tasks[0]
+	next.tasks = next.tasks[1:]
+	next.started++
+	delete(s.queued, task.ID.String())
+	if s.pending() {
+		s.signal()
+	}
+	return next.coordinator.Name, task
+}
+
+func (s *Scheduler) pending() bool {
+	for _, q := range s.queues {
+		if len(q.tasks) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scheduler) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) Stats() []QueueStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]QueueStats, len(s.queues))
+	for i, q := range s.queues {
+		stats[i] = QueueStats{
+			Coordinator: q.coordinator.Name,
+			Weight:      q.coordinator.Weight,
+			Queued:      len(q.tasks),
+			Started:     q.started,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,168 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestParseCoordinators(t *testing.T) {
+	coordinators, err := ParseCoordinators("https://main.example", "https://pool.example#3, https://main.example#2,http://lab.example:8080")
+	if err != nil {
+		t.Fatalf("ParseCoordinators() error = %v", err)
+	}
+
+	want := []Coordinator{
+		{Name: "main.example", ServerURL: "https://main.example", Weight: 2},
+		{Name: "pool.example", ServerURL: "https://pool.example", Weight: 3},
+		{Name: "lab.example:8080", ServerURL: "http://lab.example:8080", Weight: 1},
+	}
+	if len(coordinators) != len(want) {
+		t.Fatalf("ParseCoordinators() = %+v, want %+v", coordinators, want)
+	}
+	for i := range want {
+		if coordinators[i] != want[i] {
+			t.Fatalf("coordinator %d = %+v, want %+v", i, coordinators[i], want[i])
+		}
+	}
+
+	for _, list := range []string{"pool.example", "https://pool.example#0", "https://a.example,https://a.example"} {
+		if _, err := ParseCoordinators("https://main.example", list); err == nil {
+			t.Errorf("ParseCoordinators(%q) succeeded, want error", list)
+		}
+	}
+}
+
+func TestSchedulerSharesByWeight(t *testing.T) {
+	scheduler := NewScheduler([]Coordinator{
+		{Name: "a", Weight: 1},
+		{Name: "b", Weight: 2},
+	}, 10)
+	for i := 0; i < 6; i++ {
+		for _, name := range []string{"a", "b"} {
+			if err := scheduler.Enqueue(name, models.NewTask()); err != nil {
+				t.Fatalf("Enqueue(%s) error = %v", name, err)
+			}
+		}
+	}
+
+	started := map[string]int{}
+	for i := 0; i < 6; i++ {
+		name, _, err := scheduler.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		started[name]++
+	}
+	if started["a"] != 2 || started["b"] != 4 {
+		t.Fatalf("started %v, want a:2 b:4", started)
+	}
+}
+
+func TestSchedulerRecordAndRequeue(t *testing.T) {
+	scheduler := NewScheduler([]Coordinator{
+		{Name: "primary", Weight: 1},
+		{Name: "pool", Weight: 1},
+	}, 1)
+
+	task := models.NewTask()
+	if err := scheduler.Enqueue("pool", task); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if err := scheduler.Enqueue("pool", models.NewTask()); err != ErrQueueFull {
+		t.Fatalf("Enqueue() on a full queue error = %v, want ErrQueueFull", err)
+	}
+	if err := scheduler.Enqueue("unknown", models.NewTask()); err == nil {
+		t.Fatal("expected an unknown coordinator to be rejected")
+	}
+
+	scheduler.Record("primary")
+	name, got, err := scheduler.Next(context.Background())
+	if err != nil || name != "pool" || got != task {
+		t.Fatalf("Next() = %s, %v, %v", name, got, err)
+	}
+	scheduler.Requeue(name, got)
+
+	stats := scheduler.Stats()
+	if stats[0].Started != 1 || stats[1].Started != 0 || stats[1].Queued != 1 {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := scheduler.Next(ctx); err != nil {
+		t.Fatalf("Next() of requeued task error = %v", err)
+	}
+	if _, _, err := scheduler.Next(ctx); err == nil {
+		t.Fatal("expected Next() on empty queues to wait for the context")
+	}
+}
+
+func TestFetchEarningsAddsUpCoordinators(t *testing.T) {
+	paid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/earnings/device-1" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Earnings{DeviceID: "device-1", PaidTotal: 1.5, PaidCount: 3, PendingTotal: 0.5})
+	}))
+	defer paid.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	coordinators, err := ParseCoordinators(paid.URL, down.URL)
+	if err != nil {
+		t.Fatalf("ParseCoordinators() error = %v", err)
+	}
+	coordinators = append(coordinators, Coordinator{Name: "mirror", ServerURL: paid.URL + "/api", Weight: 1})
+
+	summary := FetchEarnings(context.Background(), http.DefaultClient, coordinators, "device-1")
+	if summary.PaidTotal != 3 || summary.PaidCount != 6 || summary.PendingTotal != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if summary.Coordinators[1].Error == "" || summary.Coordinators[1].Earnings != nil {
+		t.Fatalf("unreachable coordinator report = %+v", summary.Coordinators[1])
+	}
+}
+
+func TestFetchEarningsReportMergesCoordinators(t *testing.T) {
+	month := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	serve := func(taskID string, amount float64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/wallets/0xabc/earnings" || r.URL.Query().Get("month") != "2026-09" {
+				http.NotFound(w, r)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(models.NewEarningsReport("0xabc", month, []models.PaidReward{
+				{PaidAt: month.Add(time.Hour), TaskID: taskID, DeviceID: "device-1", WalletAddress: "0xabc", Amount: amount},
+			}))
+		}))
+	}
+	main, pool := serve("main-task", 1), serve("pool-task", 2)
+	defer main.Close()
+	defer pool.Close()
+
+	coordinators, err := ParseCoordinators(main.URL, pool.URL)
+	if err != nil {
+		t.Fatalf("ParseCoordinators() error = %v", err)
+	}
+	report, err := FetchEarningsReport(context.Background(), http.DefaultClient, coordinators, "0xabc", month)
+	if err != nil {
+		t.Fatalf("FetchEarningsReport() error = %v", err)
+	}
+	if report.Payouts != 2 || report.TotalAmount != 3 || report.Rewards[0].Coordinator == "" {
+		t.Fatalf("report = %+v", report)
+	}
+
+	pool.Close()
+	if _, err := FetchEarningsReport(context.Background(), http.DefaultClient, coordinators, "0xabc", month); err == nil {
+		t.Fatal("FetchEarningsReport() succeeded with a coordinator down")
+	}
+}
@@ -0,0 +1,37 @@
+// Package gang hands the membership of a gang task to the executor that runs
+// the member
+package gang
+
+import (
+	"context"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+type (
+	contextKey        struct{}
+	channelContextKey struct{}
+)
+
+// WithStatus hands the status of the formed gang to the executor
+func WithStatus(ctx context.Context, status *models.GangStatus) context.Context {
+	return context.WithValue(ctx, contextKey{}, status)
+}
+
+// FromContext returns the gang the task in ctx is a member of, or nil
+func FromContext(ctx context.Context) *models.GangStatus {
+	status, _ := ctx.Value(contextKey{}).(*models.GangStatus)
+	return status
+}
+
+// WithChannelDir hands the executor the directory holding the socket of the
+// member's peer channel, for mounting into the container
+func WithChannelDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, channelContextKey{}, dir)
+}
+
+// ChannelDir returns the peer channel directory of the task in ctx, or ""
+func ChannelDir(ctx context.Context) string {
+	dir, _ := ctx.Value(channelContextKey{}).(string)
+	return dir
+}
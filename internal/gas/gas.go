@@ -0,0 +1,481 @@
+// Package gas prices, sends and replaces contract transactions. Fees follow
+// EIP-1559 within configured caps, with a legacy gas price on chains without a
+// base fee. Gas limits are estimated with a safety margin, and a transaction
+// that is not mined in time is replaced under the same nonce with higher fees,
+// so at most one of its versions goes through. When Send gives up while
+// versions may still be mined it returns a PendingError, and the transaction
+// must be resumed under its nonce rather than sent again.
+package gas
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/signer"
+)
+
+var (
+	ErrReverted     = errors.New("transaction reverted")
+	ErrNotConfirmed = errors.New("transaction was not confirmed")
+	// ErrFeesAboveCap is returned when the network asks for more than the
+	// configured maximum fee, so the transaction could not be mined
+	ErrFeesAboveCap = errors.New("network fees are above the configured maximum")
+)
+
+const (
+	defaultBaseFeeMultiplier = 2
+	defaultGasHeadroom       = 0.2
+	defaultBumpPercent       = 25
+	defaultConfirmTimeout    = 2 * time.Minute
+	defaultRetries           = 3
+	defaultPollInterval      = 3 * time.Second
+	// minBumpPercent is the fee increase nodes require to accept a
+	// replacement transaction
+	minBumpPercent = 10
+)
+
+// Backend is the part of an Ethereum client sending transactions needs
+type Backend interface {
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Config tunes how transactions are priced and sent. Zero values use the
+// defaults.
+type Config struct {
+	// MaxFeePerGas caps the base fee and tip paid together, or the gas price on
+	// chains without EIP-1559, in wei. Nil leaves it uncapped.
+	MaxFeePerGas *big.Int
+	// MaxPriorityFeePerGas caps the tip, in wei. Nil leaves it uncapped.
+	MaxPriorityFeePerGas *big.Int
+	// BaseFeeMultiplier sets the fee cap to this multiple of the latest base fee
+	// plus the tip, 2 by default, which keeps a transaction valid through
+	// several full blocks
+	BaseFeeMultiplier float64
+	// GasHeadroom is added to the estimated gas as a share of it, 0.2 by default
+	GasHeadroom float64
+	// BumpPercent raises the fees of a replacement transaction, 25 by default.
+	// Nodes reject replacements under 10.
+	BumpPercent int
+	// ConfirmTimeout is how long a transaction may stay unmined before it is
+	// replaced, 2 minutes by default
+	ConfirmTimeout time.Duration
+	// Retries is how often a transaction is replaced, 3 by default
+	Retries int
+	// PollInterval is how often receipts are looked for, 3 seconds by default
+	PollInterval time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.BaseFeeMultiplier < 1 {
+		c.BaseFeeMultiplier = defaultBaseFeeMultiplier
+	}
+	if c.GasHeadroom <= 0 {
+		c.GasHeadroom = defaultGasHeadroom
+	}
+	if c.BumpPercent < minBumpPercent {
+		c.BumpPercent = defaultBumpPercent
+	}
+	if c.ConfirmTimeout <= 0 {
+		c.ConfirmTimeout = defaultConfirmTimeout
+	}
+	if c.Retries <= 0 {
+		c.Retries = defaultRetries
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	return c
+}
+
+// FromConfig converts the BLOCKCHAIN_GAS_ settings
+func FromConfig(settings config.GasConfig) Config {
+	return Config{
+		MaxFeePerGas:         gweiToWei(settings.MaxFeeGwei),
+		MaxPriorityFeePerGas: gweiToWei(settings.MaxPriorityFeeGwei),
+		BaseFeeMultiplier:    settings.BaseFeeMultiplier,
+		GasHeadroom:          settings.Headroom,
+		BumpPercent:          settings.BumpPercent,
+		ConfirmTimeout:       settings.ConfirmTimeout,
+		Retries:              settings.Retries,
+	}
+}
+
+func gweiToWei(gwei float64) *big.Int {
+	if gwei <= 0 {
+		return nil
+	}
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(1e9)).Int(nil)
+	return wei
+}
+
+// Fees are the gas prices of a transaction: TipCap and FeeCap on EIP-1559
+// chains, GasPrice on the others
+type Fees struct {
+	GasPrice *big.Int `json:"gas_price,omitempty"`
+	TipCap   *big.Int `json:"tip_cap,omitempty"`
+	FeeCap   *big.Int `json:"fee_cap,omitempty"`
+}
+
+// Pending is a transaction that may still be mined: its nonce, gas limit and
+// the fees of its latest version, and the hashes of every version sent
+type Pending struct {
+	Nonce  uint64        `json:"nonce"`
+	Gas    uint64        `json:"gas"`
+	Fees   Fees          `json:"fees"`
+	Hashes []common.Hash `json:"hashes"`
+}
+
+// PendingError is returned when Send or Resume gives up, with ErrNotConfirmed
+// or the context's error, after versions of the transaction were sent. Any of
+// them may still be mined, so the call is continued with Resume. Sending it
+// again would take a new nonce and could go through as well.
+type PendingError struct {
+	Pending Pending
+	Err     error
+}
+
+func (e *PendingError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PendingError) Unwrap() error {
+	return e.Err
+}
+
+// Sender signs and sends transactions from the account of its signer, one at
+// a time so their nonces do not collide
+type Sender struct {
+	backend Backend
+	signer  signer.Signer
+	from    common.Address
+	chainID *big.Int
+	config  Config
+
+	mu sync.Mutex
+}
+
+func NewSender(backend Backend, key *ecdsa.PrivateKey, chainID *big.Int, config Config) (*Sender, error) {
+	if key == nil {
+		return nil, errors.New("wallet key is required")
+	}
+	return NewSenderWithSigner(backend, signer.FromKey(key), chainID, config)
+}
+
+// NewSenderWithSigner sends transactions signed by s, such as a hardware wallet
+func NewSenderWithSigner(backend Backend, s signer.Signer, chainID *big.Int, config Config) (*Sender, error) {
+	if s == nil {
+		return nil, errors.New("wallet signer is required")
+	}
+	return &Sender{
+		backend: backend,
+		signer:  s,
+		from:    s.Address(),
+		chainID: chainID,
+		config:  config.withDefaults(),
+	}, nil
+}
+
+// From is the account transactions are sent from
+func (s *Sender) From() common.Address {
+	return s.from
+}
+
+// MaxWait is the longest Send waits for confirmations, with every replacement
+func (s *Sender) MaxWait() time.Duration {
+	return time.Duration(s.config.Retries+2) * s.config.ConfirmTimeout
+}
+
+// SuggestFees prices a transaction from the latest block within the caps. It
+// fails with ErrFeesAboveCap when the network asks for more than MaxFeePerGas.
+func (s *Sender) SuggestFees(ctx context.Context) (Fees, error) {
+	header, err := s.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return Fees{}, fmt.Errorf("failed to read latest block: %w", err)
+	}
+	maxFee := s.config.MaxFeePerGas
+
+	if header.BaseFee == nil {
+		price, err := s.backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return Fees{}, fmt.Errorf("failed to suggest gas price: %w", err)
+		}
+		if maxFee != nil && price.Cmp(maxFee) > 0 {
+			return Fees{}, fmt.Errorf("%w: gas price %s wei, maximum %s wei", ErrFeesAboveCap, price, maxFee)
+		}
+		return Fees{GasPrice: price}, nil
+	}
+
+	if maxFee != nil && header.BaseFee.Cmp(maxFee) > 0 {
+		return Fees{}, fmt.Errorf("%w: base fee %s wei, maximum %s wei", ErrFeesAboveCap, header.BaseFee, maxFee)
+	}
+	tip, err := s.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return Fees{}, fmt.Errorf("failed to suggest gas tip: %w", err)
+	}
+	tip = capAt(tip, s.config.MaxPriorityFeePerGas)
+	feeCap, _ := new(big.Float).Mul(new(big.Float).SetInt(header.BaseFee), big.NewFloat(s.config.BaseFeeMultiplier)).Int(nil)
+	feeCap = capAt(feeCap.Add(feeCap, tip), maxFee)
+	return Fees{TipCap: capAt(tip, feeCap), FeeCap: feeCap}, nil
+}
+
+// bump raises the fees for a replacement, within the caps. It reports false
+// when the caps leave too little room for nodes to accept a replacement.
+func (s *Sender) bump(f Fees) (Fees, bool) {
+	raise := func(value, max *big.Int) (*big.Int, bool) {
+		if value == nil {
+			return nil, true
+		}
+		bumped := capAt(percentOf(value, 100+s.config.BumpPercent), max)
+		return bumped, bumped.Cmp(percentOf(value, 100+minBumpPercent)) >= 0
+	}
+	price, priceOK := raise(f.GasPrice, s.config.MaxFeePerGas)
+	feeCap, feeCapOK := raise(f.FeeCap, s.config.MaxFeePerGas)
+	tip, tipOK := raise(f.TipCap, s.config.MaxPriorityFeePerGas)
+	if feeCap != nil {
+		tip = capAt(tip, feeCap)
+		tipOK = tipOK && tip.Cmp(percentOf(f.TipCap, 100+minBumpPercent)) >= 0
+	}
+	return Fees{GasPrice: price, TipCap: tip, FeeCap: feeCap}, priceOK && feeCapOK && tipOK
+}
+
+// percentOf is value times percent/100, rounded up
+func percentOf(value *big.Int, percent int) *big.Int {
+	result := new(big.Int).Mul(value, big.NewInt(int64(percent)))
+	result.Add(result, big.NewInt(99))
+	return result.Div(result, big.NewInt(100))
+}
+
+func capAt(value, max *big.Int) *big.Int {
+	if max != nil && value.Cmp(max) > 0 {
+		return new(big.Int).Set(max)
+	}
+	return value
+}
+
+func (s *Sender) sign(to common.Address, nonce, gas uint64, price Fees, data []byte) (*types.Transaction, error) {
+	var inner types.TxData
+	if price.GasPrice != nil {
+		inner = &types.LegacyTx{Nonce: nonce, GasPrice: price.GasPrice, Gas: gas, To: &to, Data: data}
+	} else {
+		inner = &types.DynamicFeeTx{ChainID: s.chainID, Nonce: nonce, GasTipCap: price.TipCap, GasFeeCap: price.FeeCap, Gas: gas, To: &to, Data: data}
+	}
+	tx, err := s.signer.SignTx(types.NewTx(inner), s.chainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// Send calls the contract at to with data and waits for the transaction to be
+// mined. A transaction not mined within ConfirmTimeout is replaced by one with
+// the same nonce and higher fees; once the fees reach the caps the last one is
+// waited for instead. The receipt is returned with ErrReverted when the
+// transaction failed on chain, and a *PendingError when it was sent but not
+// seen mined.
+func (s *Sender) Send(ctx context.Context, to common.Address, data []byte) (*types.Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	estimated, err := s.backend.EstimateGas(ctx, ethereum.CallMsg{From: s.from, To: &to, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas, the transaction would fail: %w", err)
+	}
+	gas := estimated + uint64(float64(estimated)*s.config.GasHeadroom)
+
+	nonce, err := s.backend.PendingNonceAt(ctx, s.from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account nonce: %w", err)
+	}
+	price, err := s.SuggestFees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.send(ctx, to, data, Pending{Nonce: nonce, Gas: gas, Fees: price})
+}
+
+// Resume continues a transaction Send or an earlier Resume gave up on with a
+// *PendingError. It returns the receipt of any version mined since, and
+// otherwise keeps waiting and replacing under the same nonce.
+func (s *Sender) Resume(ctx context.Context, to common.Address, data []byte, pending Pending) (*types.Receipt, error) {
+	if len(pending.Hashes) == 0 {
+		return nil, errors.New("no sent transaction to resume")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, hash := range pending.Hashes {
+		if receipt := s.receipt(ctx, hash); receipt != nil {
+			return checkReceipt(receipt)
+		}
+	}
+	return s.send(ctx, to, data, pending)
+}
+
+func (s *Sender) send(ctx context.Context, to common.Address, data []byte, pending Pending) (*types.Receipt, error) {
+	receipt, err := s.sendUnder(ctx, to, data, &pending)
+	if err != nil && len(pending.Hashes) > 0 && (errors.Is(err, ErrNotConfirmed) || ctx.Err() != nil) {
+		return nil, &PendingError{Pending: pending, Err: err}
+	}
+	return receipt, err
+}
+
+// sendUnder sends the transaction under the nonce of pending until a version
+// is mined, recording every version sent in pending. Versions sent before are
+// replaced first.
+func (s *Sender) sendUnder(ctx context.Context, to common.Address, data []byte, pending *Pending) (*types.Receipt, error) {
+	log := gologger.WithComponent("gas")
+
+	nonce, gas, price := pending.Nonce, pending.Gas, pending.Fees
+	for attempt := 0; attempt <= s.config.Retries; attempt++ {
+		if attempt > 0 || len(pending.Hashes) > 0 {
+			bumped, ok := s.bump(price)
+			if !ok && len(pending.Hashes) > 0 {
+				log.Warn().Uint64("nonce", nonce).Int("attempt", attempt+1).
+					Msg("Fees are at the configured maximum, waiting for the sent transaction instead of replacing it")
+				receipt, err := s.waitMined(ctx, pending.Hashes, s.config.ConfirmTimeout)
+				if err == nil {
+					return checkReceipt(receipt)
+				}
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				continue
+			}
+			price = bumped
+		}
+		tx, err := s.sign(to, nonce, gas, price, data)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.backend.SendTransaction(ctx, tx)
+		switch {
+		case err == nil, isAlreadyKnown(err):
+			pending.Hashes = append(pending.Hashes, tx.Hash())
+			pending.Fees = price
+			log.Info().Str("tx_hash", tx.Hash().Hex()).Uint64("nonce", nonce).Uint64("gas", gas).
+				Str("fee_cap", bigString(tx.GasFeeCap())).Str("tip_cap", bigString(tx.GasTipCap())).Int("attempt", attempt+1).
+				Msg("Transaction sent")
+		case isNonceTooLow(err) && len(pending.Hashes) > 0:
+			// An earlier attempt was mined in the meantime
+			return s.confirm(ctx, pending.Hashes)
+		case isNonceTooLow(err):
+			return nil, fmt.Errorf("failed to send transaction, nonce %d was used by another transaction: %w", nonce, err)
+		default:
+			log.Warn().Err(err).Int("attempt", attempt+1).Msg("Failed to send transaction")
+			if len(pending.Hashes) == 0 {
+				if err := sleep(ctx, s.config.PollInterval); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		receipt, err := s.waitMined(ctx, pending.Hashes, s.config.ConfirmTimeout)
+		if err == nil {
+			return checkReceipt(receipt)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Warn().Int("attempt", attempt+1).Dur("waited", s.config.ConfirmTimeout).Msg("Transaction not mined yet, replacing it with higher fees")
+	}
+	if len(pending.Hashes) == 0 {
+		return nil, fmt.Errorf("failed to send transaction after %d attempts", s.config.Retries+1)
+	}
+	return nil, fmt.Errorf("%w after %d attempts, check transactions %v", ErrNotConfirmed, s.config.Retries+1, pending.Hashes)
+}
+
+// confirm waits one more timeout for any of the sent transactions
+func (s *Sender) confirm(ctx context.Context, sent []common.Hash) (*types.Receipt, error) {
+	receipt, err := s.waitMined(ctx, sent, s.config.ConfirmTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return checkReceipt(receipt)
+}
+
+func checkReceipt(receipt *types.Receipt) (*types.Receipt, error) {
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return receipt, fmt.Errorf("%w in transaction %s", ErrReverted, receipt.TxHash.Hex())
+	}
+	return receipt, nil
+}
+
+// waitMined polls for the receipt of any of hashes until timeout. Errors other
+// than the receipt not being there yet, such as a dropped RPC connection, are
+// retried with the next poll.
+func (s *Sender) waitMined(ctx context.Context, hashes []common.Hash, timeout time.Duration) (*types.Receipt, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		for _, hash := range hashes {
+			if receipt := s.receipt(ctx, hash); receipt != nil {
+				return receipt, nil
+			}
+		}
+		if err := sleep(ctx, s.config.PollInterval); err != nil {
+			return nil, ErrNotConfirmed
+		}
+	}
+}
+
+// receipt looks up the receipt of hash, nil while it is not mined or cannot be
+// read
+func (s *Sender) receipt(ctx context.Context, hash common.Hash) *types.Receipt {
+	receipt, err := s.backend.TransactionReceipt(ctx, hash)
+	if err != nil || receipt == nil {
+		return nil
+	}
+	if receipt.TxHash == (common.Hash{}) {
+		receipt.TxHash = hash
+	}
+	return receipt
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func bigString(value *big.Int) string {
+	if value == nil {
+		return ""
+	}
+	return value.String()
+}
+
+func isNonceTooLow(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
+
+func isAlreadyKnown(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "already known") || strings.Contains(message, "known transaction")
+}
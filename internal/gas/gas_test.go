@@ -0,0 +1,162 @@
+package gas
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeBackend only mines the transactions in mined
+type fakeBackend struct {
+	mu      sync.Mutex
+	baseFee *big.Int
+	nonce   uint64
+	sent    []*types.Transaction
+	mined   map[common.Hash]bool
+}
+
+func (f *fakeBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 50_000, nil
+}
+
+func (f *fakeBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: f.baseFee}, nil
+}
+
+func (f *fakeBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(2), nil
+}
+
+func (f *fakeBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(100), nil
+}
+
+func (f *fakeBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.nonce, nil
+}
+
+func (f *fakeBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, tx)
+	return nil
+}
+
+func (f *fakeBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.mined[txHash] {
+		return nil, ethereum.NotFound
+	}
+	return &types.Receipt{TxHash: txHash, Status: types.ReceiptStatusSuccessful}, nil
+}
+
+func newTestSender(t *testing.T, backend Backend, config Config) *Sender {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	config.ConfirmTimeout = 20 * time.Millisecond
+	config.PollInterval = time.Millisecond
+	sender, err := NewSender(backend, key, big.NewInt(1337), config)
+	if err != nil {
+		t.Fatalf("NewSender() error = %v", err)
+	}
+	return sender
+}
+
+func TestSuggestFeesStaysWithinCaps(t *testing.T) {
+	ctx := context.Background()
+	sender := newTestSender(t, &fakeBackend{baseFee: big.NewInt(5)}, Config{
+		MaxFeePerGas:         big.NewInt(11),
+		MaxPriorityFeePerGas: big.NewInt(1),
+		BaseFeeMultiplier:    3,
+	})
+	fees, err := sender.SuggestFees(ctx)
+	if err != nil {
+		t.Fatalf("SuggestFees() error = %v", err)
+	}
+	if fees.TipCap.Int64() != 1 || fees.FeeCap.Int64() != 11 {
+		t.Fatalf("SuggestFees() = tip %v, fee cap %v; want 1 and 11", fees.TipCap, fees.FeeCap)
+	}
+
+	expensive := newTestSender(t, &fakeBackend{baseFee: big.NewInt(20)}, Config{MaxFeePerGas: big.NewInt(15)})
+	if _, err := expensive.SuggestFees(ctx); !errors.Is(err, ErrFeesAboveCap) {
+		t.Fatalf("SuggestFees() with the base fee above the cap = %v, want ErrFeesAboveCap", err)
+	}
+	legacy := newTestSender(t, &fakeBackend{}, Config{MaxFeePerGas: big.NewInt(99)})
+	if _, err := legacy.SuggestFees(ctx); !errors.Is(err, ErrFeesAboveCap) {
+		t.Fatalf("SuggestFees() with the gas price above the cap = %v, want ErrFeesAboveCap", err)
+	}
+}
+
+func TestSendStopsReplacingAtTheFeeCap(t *testing.T) {
+	backend := &fakeBackend{baseFee: big.NewInt(5)}
+	sender := newTestSender(t, backend, Config{MaxFeePerGas: big.NewInt(15), Retries: 3})
+
+	_, err := sender.Send(context.Background(), common.HexToAddress("0x5"), []byte{0x01})
+	if !errors.Is(err, ErrNotConfirmed) {
+		t.Fatalf("Send() never mined = %v, want ErrNotConfirmed", err)
+	}
+	// The replacement reaches the cap, after which nodes would reject another
+	if len(backend.sent) != 2 {
+		t.Fatalf("sent %d transactions, want the original and one capped replacement", len(backend.sent))
+	}
+	first, second := backend.sent[0], backend.sent[1]
+	if first.Gas() != 60_000 || first.GasFeeCap().Int64() != 12 || first.GasTipCap().Int64() != 2 {
+		t.Fatalf("first transaction gas = %d, fee cap %v, tip %v", first.Gas(), first.GasFeeCap(), first.GasTipCap())
+	}
+	if second.Nonce() != first.Nonce() || second.GasFeeCap().Int64() != 15 || second.GasTipCap().Int64() != 3 {
+		t.Fatalf("replacement nonce %d, fee cap %v, tip %v; want nonce %d at 15 and 3", second.Nonce(), second.GasFeeCap(), second.GasTipCap(), first.Nonce())
+	}
+}
+
+func TestResumeStaysOnTheNonceOfTheSentTransaction(t *testing.T) {
+	backend := &fakeBackend{baseFee: big.NewInt(5), mined: make(map[common.Hash]bool)}
+	sender := newTestSender(t, backend, Config{Retries: 1})
+	ctx := context.Background()
+	to := common.HexToAddress("0x5")
+
+	_, err := sender.Send(ctx, to, []byte{0x01})
+	var pending *PendingError
+	if !errors.As(err, &pending) || !errors.Is(err, ErrNotConfirmed) || len(pending.Pending.Hashes) != 2 {
+		t.Fatalf("Send() never mined = %v, want a PendingError with both versions", err)
+	}
+
+	// The node counts the sent versions, so a new Send would take nonce 1
+	backend.nonce = 1
+	_, err = sender.Resume(ctx, to, []byte{0x01}, pending.Pending)
+	if !errors.As(err, &pending) || len(pending.Pending.Hashes) != 4 {
+		t.Fatalf("Resume() never mined = %v, want a PendingError with all four versions", err)
+	}
+	for i, tx := range backend.sent {
+		if tx.Nonce() != 0 {
+			t.Fatalf("version %d sent with nonce %d, want 0", i+1, tx.Nonce())
+		}
+		if i > 0 && tx.GasFeeCap().Cmp(backend.sent[i-1].GasFeeCap()) <= 0 {
+			t.Fatalf("version %d fee cap %v does not replace %v", i+1, tx.GasFeeCap(), backend.sent[i-1].GasFeeCap())
+		}
+	}
+
+	backend.mu.Lock()
+	backend.mined[pending.Pending.Hashes[0]] = true
+	backend.mu.Unlock()
+	receipt, err := sender.Resume(ctx, to, []byte{0x01}, pending.Pending)
+	if err != nil || receipt.TxHash != pending.Pending.Hashes[0] {
+		t.Fatalf("Resume() after the first version was mined = %v, %v", receipt, err)
+	}
+	if len(backend.sent) != 4 {
+		t.Fatalf("sent %d transactions, want none after the first was mined", len(backend.sent))
+	}
+}
@@ -0,0 +1,135 @@
+// Package gpu finds the NVIDIA GPUs the runner can hand to container tasks
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// run executes a command and returns its output. It is a variable so tests can
+// stand in for nvidia-smi and docker.
+var run = func(ctx context.Context, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+	return string(output), nil
+}
+
+// Detect lists the GPUs Docker containers can use. GPUs are only reported when
+// Docker has the NVIDIA runtime, since tasks could not be given them otherwise; a
+// host without nvidia-smi has no GPUs.
+func Detect(ctx context.Context) ([]models.GPUInfo, error) {
+	gpus, err := nvidiaGPUs(ctx)
+	if err != nil || len(gpus) == 0 {
+		return nil, err
+	}
+
+	runtimes, err := run(ctx, "docker", "info", "--format", "{{json .Runtimes}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check docker runtimes: %w", err)
+	}
+	if !strings.Contains(runtimes, "nvidia") {
+		return nil, errors.New("found NVIDIA GPUs but docker has no nvidia runtime, install the NVIDIA Container Toolkit")
+	}
+	return gpus, nil
+}
+
+// DetectCDI lists the GPUs Podman containers can use, which requires a CDI
+// specification for them generated by the NVIDIA Container Toolkit
+func DetectCDI(ctx context.Context) ([]models.GPUInfo, error) {
+	gpus, err := nvidiaGPUs(ctx)
+	if err != nil || len(gpus) == 0 {
+		return nil, err
+	}
+
+	devices, err := run(ctx, "nvidia-ctk", "cdi", "list")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CDI devices: %w", err)
+	}
+	if !strings.Contains(devices, cdiKind+"=") {
+		return nil, errors.New("found NVIDIA GPUs but no CDI devices, run nvidia-ctk cdi generate")
+	}
+	return gpus, nil
+}
+
+func nvidiaGPUs(ctx context.Context) ([]models.GPUInfo, error) {
+	output, err := run(ctx, "nvidia-smi", "--query-gpu=index,uuid,name,memory.total", "--format=csv,noheader,nounits")
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseNvidiaSMI(output)
+}
+
+func parseNvidiaSMI(output string) ([]models.GPUInfo, error) {
+	var gpus []models.GPUInfo
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected nvidia-smi output %q", line)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid gpu index %q", fields[0])
+		}
+		memory, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gpu memory %q", fields[3])
+		}
+		gpus = append(gpus, models.GPUInfo{
+			Index:    index,
+			UUID:     fields[1],
+			Model:    fields[2],
+			MemoryMB: memory,
+		})
+	}
+	return gpus, nil
+}
+
+// cdiKind is the CDI device kind of NVIDIA GPUs
+const cdiKind = "nvidia.com/gpu"
+
+// DockerArg is the value of docker's --gpus flag that exposes exactly these GPUs
+func DockerArg(gpus []models.GPUInfo) string {
+	devices := make([]string, len(gpus))
+	for i, gpu := range gpus {
+		devices[i] = strconv.Itoa(gpu.Index)
+		if gpu.UUID != "" {
+			devices[i] = gpu.UUID
+		}
+	}
+	// The quotes keep docker from splitting the device list on its commas
+	return `"device=` + strings.Join(devices, ",") + `"`
+}
+
+// CDIDevices names these GPUs as CDI devices, the form Podman's --device takes
+func CDIDevices(gpus []models.GPUInfo) []string {
+	devices := make([]string, len(gpus))
+	for i, gpu := range gpus {
+		name := strconv.Itoa(gpu.Index)
+		if gpu.UUID != "" {
+			name = gpu.UUID
+		}
+		devices[i] = cdiKind + "=" + name
+	}
+	return devices
+}
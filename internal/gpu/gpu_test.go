@@ -0,0 +1,88 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const smiOutput = `0, GPU-5fd2a0f1-1c3e-4d4b-9a62-0c1f7b1c2a11, NVIDIA A100-SXM4-40GB, 40960
+1, GPU-8a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d, NVIDIA GeForce RTX 4090, 24564
+`
+
+func stubRun(t *testing.T, outputs map[string]string, errs map[string]error) {
+	t.Helper()
+	original := run
+	run = func(ctx context.Context, name string, args ...string) (string, error) {
+		if err := errs[name]; err != nil {
+			return "", err
+		}
+		return outputs[name], nil
+	}
+	t.Cleanup(func() { run = original })
+}
+
+func TestDetect(t *testing.T) {
+	stubRun(t, map[string]string{"nvidia-smi": smiOutput, "docker": `{"nvidia":{"path":"nvidia-container-runtime"},"runc":{"path":"runc"}}`}, nil)
+
+	gpus, err := Detect(context.Background())
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if len(gpus) != 2 {
+		t.Fatalf("Detect() found %d GPUs, want 2", len(gpus))
+	}
+	want := models.GPUInfo{Index: 1, UUID: "GPU-8a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d", Model: "NVIDIA GeForce RTX 4090", MemoryMB: 24564}
+	if gpus[1] != want {
+		t.Fatalf("gpus[1] = %+v, want %+v", gpus[1], want)
+	}
+}
+
+func TestDetectWithoutGPUs(t *testing.T) {
+	stubRun(t, nil, map[string]error{"nvidia-smi": fmt.Errorf("nvidia-smi failed: %w", exec.ErrNotFound)})
+
+	gpus, err := Detect(context.Background())
+	if err != nil || len(gpus) != 0 {
+		t.Fatalf("Detect() = %v, %v; want no GPUs and no error", gpus, err)
+	}
+}
+
+func TestDetectRequiresNvidiaRuntime(t *testing.T) {
+	stubRun(t, map[string]string{"nvidia-smi": smiOutput, "docker": `{"runc":{"path":"runc"}}`}, nil)
+
+	if _, err := Detect(context.Background()); err == nil {
+		t.Fatal("Detect() succeeded without the nvidia runtime, want error")
+	}
+}
+
+func TestDockerArg(t *testing.T) {
+	gpus := []models.GPUInfo{{Index: 0}, {Index: 2}}
+	if got, want := DockerArg(gpus), `"device=0,2"`; got != want {
+		t.Fatalf("DockerArg() = %s, want %s", got, want)
+	}
+}
+
+func TestDetectCDI(t *testing.T) {
+	stubRun(t, map[string]string{"nvidia-smi": smiOutput, "nvidia-ctk": "INFO Found 3 CDI devices\nnvidia.com/gpu=0\nnvidia.com/gpu=1\nnvidia.com/gpu=all\n"}, nil)
+
+	gpus, err := DetectCDI(context.Background())
+	if err != nil || len(gpus) != 2 {
+		t.Fatalf("DetectCDI() = %v, %v; want 2 GPUs", gpus, err)
+	}
+
+	stubRun(t, map[string]string{"nvidia-smi": smiOutput, "nvidia-ctk": "INFO Found 0 CDI devices\n"}, nil)
+	if _, err := DetectCDI(context.Background()); err == nil {
+		t.Fatal("DetectCDI() succeeded without CDI devices, want error")
+	}
+}
+
+func TestCDIDevices(t *testing.T) {
+	gpus := []models.GPUInfo{{Index: 0}, {Index: 1, UUID: "GPU-8a1b"}}
+	got := CDIDevices(gpus)
+	if len(got) != 2 || got[0] != "nvidia.com/gpu=0" || got[1] != "nvidia.com/gpu=GPU-8a1b" {
+		t.Fatalf("CDIDevices() = %v", got)
+	}
+}
@@ -0,0 +1,258 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"plugin"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+type Stage string
+
+const (
+	StagePreClaim    Stage = "pre-claim"
+	StagePreExecute  Stage = "pre-execute"
+	StagePostExecute Stage = "post-execute"
+	StagePreSubmit   Stage = "pre-submit"
+)
+
+// PluginSymbol is the function a Go plugin must export. It receives the stage and
+// the JSON encoded hook context; a non-nil error rejects the task.
+const PluginSymbol = "ParityHook"
+
+const defaultTimeout = 30 * time.Second
+
+// Context is the task context handed to every hook
+type Context struct {
+	Stage  Stage              `json:"stage"`
+	Task   *models.Task       `json:"task"`
+	Result *models.TaskResult `json:"result,omitempty"`
+}
+
+// Hook runs at a lifecycle stage of a task. Returning an error rejects the task at
+// that stage.
+type Hook interface {
+	Name() string
+	Run(ctx context.Context, hookCtx *Context) error
+}
+
+// ScriptHook runs a local executable with the hook context as JSON on stdin
+type ScriptHook struct {
+	Path    string
+	Timeout time.Duration
+}
+
+func (h *ScriptHook) Name() string {
+	return filepath.Base(h.Path)
+}
+
+func (h *ScriptHook) Run(ctx context.Context, hookCtx *Context) error {
+	payload, err := json.Marshal(hookCtx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hook context: %w", err)
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Path, string(hookCtx.Stage))
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(cmd.Environ(),
+		"PARITY_HOOK_STAGE="+string(hookCtx.Stage),
+		"PARITY_TASK_ID="+hookCtx.Task.ID.String(),
+		"PARITY_TASK_TYPE="+string(hookCtx.Task.Type),
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("hook script %s failed: %s", h.Name(), msg)
+		}
+		return fmt.Errorf("hook script %s failed: %w", h.Name(), err)
+	}
+
+	return nil
+}
+
+// PluginCommand is the hidden runner subcommand a plugin hook runs in. Plugins
+// are called in a child process so a hook that hangs can be killed when its
+// context is done or its timeout passes.
+const PluginCommand = "hook-plugin"
+
+// executable is the binary plugin hooks run PluginCommand of
+var executable = os.Executable
+
+// PluginHook calls the ParityHook function exported by a Go plugin (.so)
+type PluginHook struct {
+	path    string
+	Timeout time.Duration
+}
+
+// LoadPlugin checks that path is a plugin exporting PluginSymbol, so a broken
+// hook is reported at startup rather than when a task reaches it
+func LoadPlugin(path string) (*PluginHook, error) {
+	if _, err := lookupPlugin(path); err != nil {
+		return nil, err
+	}
+	return &PluginHook{path: path}, nil
+}
+
+func lookupPlugin(path string) (func(stage string, payload []byte) error, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hook plugin %s: %w", path, err)
+	}
+
+	sym, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("hook plugin %s does not export %s: %w", path, PluginSymbol, err)
+	}
+
+	fn, ok := sym.(func(stage string, payload []byte) error)
+	if !ok {
+		return nil, fmt.Errorf("hook plugin %s: %s has type %T, want func(string, []byte) error", path, PluginSymbol, sym)
+	}
+	return fn, nil
+}
+
+func (h *PluginHook) Name() string {
+	return filepath.Base(h.path)
+}
+
+func (h *PluginHook) Run(ctx context.Context, hookCtx *Context) error {
+	payload, err := json.Marshal(hookCtx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hook context: %w", err)
+	}
+	self, err := executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate runner binary for hook plugin %s: %w", h.Name(), err)
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, self, PluginCommand, h.path, string(hookCtx.Stage))
+	cmd.Stdin = bytes.NewReader(payload)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("hook plugin %s did not finish: %w", h.Name(), ctx.Err())
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("hook plugin %s rejected task: %s", h.Name(), msg)
+		}
+		return fmt.Errorf("hook plugin %s failed: %w", h.Name(), err)
+	}
+	return nil
+}
+
+// RunPlugin calls the hook function of the plugin at path with the context read
+// from payload. It is what PluginCommand runs in the child process.
+func RunPlugin(path, stage string, payload io.Reader) error {
+	fn, err := lookupPlugin(path)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(payload)
+	if err != nil {
+		return fmt.Errorf("failed to read hook context: %w", err)
+	}
+	return fn(stage, data)
+}
+
+// Registry holds the hooks configured for each stage
+type Registry struct {
+	hooks map[Stage][]Hook
+}
+
+func NewRegistry() *Registry {
+	return &Registry{hooks: make(map[Stage][]Hook)}
+}
+
+func (r *Registry) Register(stage Stage, hook Hook) {
+	r.hooks[stage] = append(r.hooks[stage], hook)
+}
+
+// RegisterPaths registers a comma separated list of hook paths for a stage. Paths
+// ending in .so are loaded as Go plugins, anything else is run as a script.
+func (r *Registry) RegisterPaths(stage Stage, paths string, timeout time.Duration) error {
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		if strings.HasSuffix(path, ".so") {
+			hook, err := LoadPlugin(path)
+			if err != nil {
+				return err
+			}
+			hook.Timeout = timeout
+			r.Register(stage, hook)
+			continue
+		}
+
+		if _, err := exec.LookPath(path); err != nil {
+			return fmt.Errorf("hook script %s is not executable: %w", path, err)
+		}
+		r.Register(stage, &ScriptHook{Path: path, Timeout: timeout})
+	}
+	return nil
+}
+
+func (r *Registry) Len() int {
+	if r == nil {
+		return 0
+	}
+	total := 0
+	for _, hooks := range r.hooks {
+		total += len(hooks)
+	}
+	return total
+}
+
+// Run executes the hooks of a stage in order and stops at the first rejection
+func (r *Registry) Run(ctx context.Context, stage Stage, task *models.Task, result *models.TaskResult) error {
+	if r == nil {
+		return nil
+	}
+
+	hookCtx := &Context{Stage: stage, Task: task, Result: result}
+	for _, hook := range r.hooks[stage] {
+		if err := hook.Run(ctx, hookCtx); err != nil {
+			log := gologger.WithComponent("hooks")
+			log.Warn().Err(err).
+				Str("stage", string(stage)).
+				Str("hook", hook.Name()).
+				Str("task_id", task.ID.String()).
+				Msg("Hook rejected task")
+			return fmt.Errorf("%s hook %s: %w", stage, hook.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatalf("failed to write hook script: %v", err)
+	}
+	return path
+}
+
+func TestScriptHookReceivesTaskContext(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "context.json")
+	script := writeScript(t, `echo "$PARITY_HOOK_STAGE" > "`+out+`.stage"; cat > "`+out+`"`)
+
+	registry := NewRegistry()
+	if err := registry.RegisterPaths(StagePreExecute, script, 0); err != nil {
+		t.Fatalf("failed to register hook: %v", err)
+	}
+
+	task := models.NewTask()
+	task.Title = "scan me"
+	if err := registry.Run(context.Background(), StagePreExecute, task, nil); err != nil {
+		t.Fatalf("unexpected hook error: %v", err)
+	}
+
+	stage, err := os.ReadFile(out + ".stage")
+	if err != nil || strings.TrimSpace(string(stage)) != string(StagePreExecute) {
+		t.Fatalf("hook stage = %q, err = %v", stage, err)
+	}
+	payload, err := os.ReadFile(out)
+	if err != nil || !strings.Contains(string(payload), task.ID.String()) {
+		t.Fatalf("hook did not receive the task context: %s", payload)
+	}
+
+	if err := registry.Run(context.Background(), StagePreClaim, task, nil); err != nil {
+		t.Fatalf("stages without hooks should pass: %v", err)
+	}
+}
+
+func TestScriptHookRejectsTaskOnNonZeroExit(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(StagePreClaim, &ScriptHook{Path: writeScript(t, "echo 'approval denied' >&2; exit 1")})
+
+	err := registry.Run(context.Background(), StagePreClaim, models.NewTask(), nil)
+	if err == nil || !strings.Contains(err.Error(), "approval denied") {
+		t.Fatalf("expected rejection with script stderr, got %v", err)
+	}
+}
+
+func TestPluginHookRunsInChildProcessWithTimeout(t *testing.T) {
+	original := executable
+	t.Cleanup(func() { executable = original })
+
+	args := filepath.Join(t.TempDir(), "args")
+	executable = func() (string, error) {
+		return writeScript(t, `echo "$@" > "`+args+`"; exec sleep 10`), nil
+	}
+
+	hook := &PluginHook{path: "/hooks/approve.so", Timeout: 100 * time.Millisecond}
+	started := time.Now()
+	err := hook.Run(context.Background(), &Context{Stage: StagePreExecute, Task: models.NewTask()})
+	if err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Fatalf("expected the hung plugin to time out, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("plugin hook was not stopped at its timeout, took %s", elapsed)
+	}
+	if got, _ := os.ReadFile(args); strings.TrimSpace(string(got)) != PluginCommand+" /hooks/approve.so "+string(StagePreExecute) {
+		t.Fatalf("plugin child args = %q", got)
+	}
+
+	executable = func() (string, error) {
+		return writeScript(t, "echo 'approval denied' >&2; exit 1"), nil
+	}
+	err = hook.Run(context.Background(), &Context{Stage: StagePreExecute, Task: models.NewTask()})
+	if err == nil || !strings.Contains(err.Error(), "approval denied") {
+		t.Fatalf("expected rejection with plugin stderr, got %v", err)
+	}
+}
@@ -0,0 +1,393 @@
+// Package identity lets runners check that task traffic comes from the server
+// they authenticated against. The server signs its responses, webhook deliveries
+// and WebSocket messages with a secp256k1 key; runners pin its address when they
+// run `auth` and reject anything that was not signed by it.
+package identity
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	SignatureHeader = "X-Parity-Server-Signature"
+	TimestampHeader = "X-Parity-Server-Timestamp"
+	// NonceHeader carries a value the runner picks for each request, which the
+	// server signs into its response so the response cannot be replayed as
+	// the answer to another request
+	NonceHeader    = "X-Parity-Request-Nonce"
+	DeviceIDHeader = "X-Device-ID"
+	// DeliveryNonceHeader carries a value the server picks for each webhook
+	// delivery and signs in, which the receiver remembers so a captured
+	// delivery cannot be replayed
+	DeliveryNonceHeader = "X-Parity-Delivery-Nonce"
+
+	// MaxClockSkew is how far a signature timestamp may be from the runner's
+	// clock, which bounds how long a captured message can be replayed
+	MaxClockSkew = 5 * time.Minute
+
+	// Path is where the server publishes its identity
+	Path = "/api/v1/identity"
+
+	pinFileName      = "server_identity.json"
+	maxIdentityBytes = 4 << 10
+)
+
+// Purpose separates the kinds of signed payloads, so a signature over one can
+// not be passed off as another
+type Purpose string
+
+const (
+	PurposeResponse Purpose = "response"
+	PurposeWebhook  Purpose = "webhook"
+	PurposeMessage  Purpose = "message"
+)
+
+// ErrUnsigned is returned when a payload carries no signature
+var ErrUnsigned = errors.New("payload is not signed by the server")
+
+// Document is what the server publishes at Path
+type Document struct {
+	Address string `json:"address"`
+}
+
+// Signer signs payloads with the server identity key
+type Signer struct {
+	key     *ecdsa.PrivateKey
+	address string
+	now     func() time.Time
+}
+
+func NewSigner(key *ecdsa.PrivateKey) *Signer {
+	return &Signer{
+		key:     key,
+		address: crypto.PubkeyToAddress(key.PublicKey).Hex(),
+		now:     time.Now,
+	}
+}
+
+func (s *Signer) Address() string {
+	return s.address
+}
+
+// Sign returns the timestamp and signature for body
+func (s *Signer) Sign(purpose Purpose, body []byte) (string, string, error) {
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	signature, err := crypto.Sign(digest(purpose, timestamp, body), s.key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to sign %s: %w", purpose, err)
+	}
+	return timestamp, hexutil.Encode(signature), nil
+}
+
+// SignHeader adds the timestamp and signature headers for body
+func (s *Signer) SignHeader(header http.Header, purpose Purpose, body []byte) error {
+	timestamp, signature, err := s.Sign(purpose, body)
+	if err != nil {
+		return err
+	}
+	header.Set(TimestampHeader, timestamp)
+	header.Set(SignatureHeader, signature)
+	return nil
+}
+
+// SignResponse adds the timestamp and signature headers for the response to
+// req with body
+func (s *Signer) SignResponse(header http.Header, req *http.Request, body []byte) error {
+	return s.SignHeader(header, PurposeResponse, ResponseBody(RequestOf(req), body))
+}
+
+// SignWebhook adds the nonce, timestamp and signature headers for a webhook
+// delivery of body to deviceID
+func (s *Signer) SignWebhook(header http.Header, deviceID string, body []byte) error {
+	nonce, err := NewNonce()
+	if err != nil {
+		return err
+	}
+	header.Set(DeliveryNonceHeader, nonce)
+	return s.SignHeader(header, PurposeWebhook, WebhookBody(deviceID, nonce, body))
+}
+
+// Verifier checks signatures against a pinned server address
+type Verifier struct {
+	address common.Address
+	now     func() time.Time
+
+	// nonces holds the webhook delivery nonces seen within MaxClockSkew, by
+	// their signature time
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func NewVerifier(address string) (*Verifier, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid server identity address %q", address)
+	}
+	return &Verifier{address: common.HexToAddress(address), now: time.Now, nonces: make(map[string]time.Time)}, nil
+}
+
+func (v *Verifier) Address() string {
+	return v.address.Hex()
+}
+
+func (v *Verifier) Verify(purpose Purpose, body []byte, timestamp, signature string) error {
+	if signature == "" || timestamp == "" {
+		return ErrUnsigned
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp %q", timestamp)
+	}
+	skew := v.now().Sub(time.Unix(unix, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > MaxClockSkew {
+		return fmt.Errorf("signature timestamp is %s off the local clock", skew.Round(time.Second))
+	}
+
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	publicKey, err := crypto.SigToPub(digest(purpose, timestamp, body), sig)
+	if err != nil {
+		return fmt.Errorf("failed to recover signer: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(*publicKey); signer != v.address {
+		return fmt.Errorf("%s was signed by %s, expected %s", purpose, signer.Hex(), v.address.Hex())
+	}
+	return nil
+}
+
+// VerifyHeader checks the signature headers set by SignHeader
+func (v *Verifier) VerifyHeader(header http.Header, purpose Purpose, body []byte) error {
+	return v.Verify(purpose, body, header.Get(TimestampHeader), header.Get(SignatureHeader))
+}
+
+// VerifyWebhook checks that a delivery was signed for deviceID and that its
+// nonce has not been seen before. Only nonces of valid signatures are
+// remembered, so forged deliveries cannot lock genuine ones out.
+func (v *Verifier) VerifyWebhook(header http.Header, deviceID string, body []byte) error {
+	nonce := header.Get(DeliveryNonceHeader)
+	if nonce == "" {
+		if header.Get(SignatureHeader) == "" {
+			return ErrUnsigned
+		}
+		return errors.New("webhook delivery carried no nonce")
+	}
+	if err := v.VerifyHeader(header, PurposeWebhook, WebhookBody(deviceID, nonce, body)); err != nil {
+		return err
+	}
+
+	// VerifyHeader accepted the timestamp, so it parses
+	unix, _ := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	signedAt := time.Unix(unix, 0)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	for seen, at := range v.nonces {
+		// Older signatures fail the clock skew check, so their nonces can go
+		if now.Sub(at) > MaxClockSkew {
+			delete(v.nonces, seen)
+		}
+	}
+	if _, replayed := v.nonces[nonce]; replayed {
+		return fmt.Errorf("webhook delivery nonce %s was already used", nonce)
+	}
+	v.nonces[nonce] = signedAt
+	return nil
+}
+
+// VerifyResponse checks that resp was signed for the request it answers, which
+// must have carried a nonce from SetNonce
+func (v *Verifier) VerifyResponse(resp *http.Response, body []byte) error {
+	if resp.Request == nil {
+		return errors.New("response has no request to check it against")
+	}
+	request := RequestOf(resp.Request)
+	if request.Nonce == "" {
+		return errors.New("request carried no nonce")
+	}
+	return v.VerifyHeader(resp.Header, PurposeResponse, ResponseBody(request, body))
+}
+
+// Request is what a signed response is bound to: the method and path of the
+// request, the device that sent it and its nonce
+type Request struct {
+	Method   string
+	Path     string
+	DeviceID string
+	Nonce    string
+}
+
+// RequestOf is what the response to req is bound to
+func RequestOf(req *http.Request) Request {
+	return Request{
+		Method:   req.Method,
+		Path:     req.URL.Path,
+		DeviceID: req.Header.Get(DeviceIDHeader),
+		Nonce:    req.Header.Get(NonceHeader),
+	}
+}
+
+// CallRequest is what the response to a gRPC call is bound to. A call is an
+// HTTP/2 POST to the full method name.
+func CallRequest(fullMethod, deviceID, nonce string) Request {
+	return Request{Method: http.MethodPost, Path: fullMethod, DeviceID: deviceID, Nonce: nonce}
+}
+
+// NewNonce returns a fresh request nonce
+func NewNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate request nonce: %w", err)
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// SetNonce gives req a fresh nonce for the server to sign into its response
+func SetNonce(req *http.Request) error {
+	nonce, err := NewNonce()
+	if err != nil {
+		return err
+	}
+	req.Header.Set(NonceHeader, nonce)
+	return nil
+}
+
+// ResponseBody is what is signed for a response to request
+func ResponseBody(request Request, body []byte) []byte {
+	binding := strings.Join([]string{request.Method, request.Path, request.DeviceID, request.Nonce}, "\n")
+	return append([]byte(binding+"\n"), body...)
+}
+
+// WebhookBody is what is signed for a webhook delivery to deviceID
+func WebhookBody(deviceID, nonce string, body []byte) []byte {
+	return append([]byte(deviceID+"\n"+nonce+"\n"), body...)
+}
+
+// MessageBody is what is signed for a WebSocket message, which carries its
+// signature inline
+func MessageBody(messageType string, payload []byte) []byte {
+	return append([]byte(messageType+"\n"), payload...)
+}
+
+func digest(purpose Purpose, timestamp string, body []byte) []byte {
+	payload := append([]byte(string(purpose)+"\n"+timestamp+"\n"), body...)
+	return accounts.TextHash(crypto.Keccak256(payload))
+}
+
+// Fetch retrieves the identity a server publishes. The document must be signed
+// by the address it names, which proves the server holds the key but not that
+// it is the right server; that is what pinning the address is for.
+func Fetch(ctx context.Context, client *http.Client, serverURL string) (string, error) {
+	base := strings.TrimSuffix(serverURL, "/")
+	base = strings.TrimSuffix(strings.TrimSuffix(base, "/api/v1"), "/api")
+	url := base + Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create identity request: %w", err)
+	}
+	if err := SetNonce(req); err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch server identity: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIdentityBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read server identity: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("server identity request failed with status %d", resp.StatusCode)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", fmt.Errorf("failed to parse server identity: %w", err)
+	}
+	verifier, err := NewVerifier(doc.Address)
+	if err != nil {
+		return "", err
+	}
+	if err := verifier.VerifyResponse(resp, body); err != nil {
+		return "", fmt.Errorf("server identity is not self-signed: %w", err)
+	}
+	return verifier.Address(), nil
+}
+
+// Pin is the server identity a runner accepted at auth time
+type Pin struct {
+	ServerURL string    `json:"server_url"`
+	Address   string    `json:"address"`
+	PinnedAt  time.Time `json:"pinned_at"`
+}
+
+func DefaultPinPath() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, pinFileName), nil
+}
+
+func SavePin(path string, pin *Pin) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	data, err := json.MarshalIndent(pin, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal server identity: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write server identity: %w", err)
+	}
+	return nil
+}
+
+// LoadPin reads the pinned identity, returning nil when none was pinned
+func LoadPin(path string) (*Pin, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server identity: %w", err)
+	}
+
+	var pin Pin
+	if err := json.Unmarshal(data, &pin); err != nil {
+		return nil, fmt.Errorf("failed to parse server identity: %w", err)
+	}
+	if !common.IsHexAddress(pin.Address) {
+		return nil, fmt.Errorf("pinned server identity has invalid address %q", pin.Address)
+	}
+	return &pin, nil
+}
@@ -0,0 +1,176 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newSigner(t *testing.T) *Signer {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return NewSigner(key)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	signer := newSigner(t)
+	verifier, err := NewVerifier(signer.Address())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	body := []byte(`[{"id":"task"}]`)
+	header := http.Header{}
+	if err := signer.SignHeader(header, PurposeResponse, body); err != nil {
+		t.Fatalf("SignHeader() error = %v", err)
+	}
+	if err := verifier.VerifyHeader(header, PurposeResponse, body); err != nil {
+		t.Fatalf("VerifyHeader() error = %v", err)
+	}
+
+	if err := verifier.VerifyHeader(header, PurposeResponse, []byte(`[{"id":"other"}]`)); err == nil {
+		t.Fatal("expected a tampered body to fail verification")
+	}
+	if err := verifier.VerifyHeader(header, PurposeWebhook, body); err == nil {
+		t.Fatal("expected a signature to be bound to its purpose")
+	}
+	if err := verifier.VerifyHeader(http.Header{}, PurposeResponse, body); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("VerifyHeader() of unsigned body error = %v, want ErrUnsigned", err)
+	}
+}
+
+func TestVerifyRejectsOtherSigner(t *testing.T) {
+	pinned := newSigner(t)
+	impostor := newSigner(t)
+	verifier, err := NewVerifier(pinned.Address())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	body := MessageBody("available_tasks", []byte(`{"id":"task"}`))
+	timestamp, signature, err := impostor.Sign(PurposeMessage, body)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if err := verifier.Verify(PurposeMessage, body, timestamp, signature); err == nil {
+		t.Fatal("expected a signature by another key to fail verification")
+	}
+}
+
+func TestVerifyRejectsStaleTimestamp(t *testing.T) {
+	signer := newSigner(t)
+	signer.now = func() time.Time { return time.Now().Add(-MaxClockSkew - time.Minute) }
+	verifier, err := NewVerifier(signer.Address())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	body := []byte(`{}`)
+	timestamp, signature, err := signer.Sign(PurposeWebhook, body)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if err := verifier.Verify(PurposeWebhook, body, timestamp, signature); err == nil {
+		t.Fatal("expected a stale signature to fail verification")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	signer := newSigner(t)
+	impostor := newSigner(t)
+	selfSigned := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != Path {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := json.Marshal(Document{Address: signer.Address()})
+		if selfSigned {
+			_ = signer.SignResponse(w.Header(), r, body)
+		} else {
+			_ = impostor.SignResponse(w.Header(), r, body)
+		}
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	address, err := Fetch(context.Background(), server.Client(), server.URL+"/api/v1")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if address != signer.Address() {
+		t.Fatalf("Fetch() = %s, want %s", address, signer.Address())
+	}
+
+	selfSigned = false
+	if _, err := Fetch(context.Background(), server.Client(), server.URL); err == nil {
+		t.Fatal("expected an identity signed by another key to be rejected")
+	}
+}
+
+func TestPinRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server_identity.json")
+
+	pin, err := LoadPin(path)
+	if err != nil || pin != nil {
+		t.Fatalf("LoadPin() of missing file = %v, %v, want nil, nil", pin, err)
+	}
+
+	want := &Pin{ServerURL: "https://parity.example", Address: newSigner(t).Address(), PinnedAt: time.Now().UTC().Truncate(time.Second)}
+	if err := SavePin(path, want); err != nil {
+		t.Fatalf("SavePin() error = %v", err)
+	}
+	pin, err = LoadPin(path)
+	if err != nil {
+		t.Fatalf("LoadPin() error = %v", err)
+	}
+	if *pin != *want {
+		t.Fatalf("LoadPin() = %+v, want %+v", pin, want)
+	}
+}
+
+func TestVerifyWebhookBindsDeviceAndNonce(t *testing.T) {
+	signer := newSigner(t)
+	verifier, err := NewVerifier(signer.Address())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	body := []byte(`{"type":"available_tasks"}`)
+	header := http.Header{}
+	if err := signer.SignWebhook(header, "device-1", body); err != nil {
+		t.Fatalf("SignWebhook() error = %v", err)
+	}
+	if err := verifier.VerifyWebhook(header, "device-2", body); err == nil {
+		t.Fatal("expected a delivery to be bound to its recipient")
+	}
+
+	forged := header.Clone()
+	forged.Set(DeliveryNonceHeader, "other")
+	if err := verifier.VerifyWebhook(forged, "device-1", body); err == nil {
+		t.Fatal("expected a delivery to be bound to its nonce")
+	}
+
+	if err := verifier.VerifyWebhook(header, "device-1", body); err != nil {
+		t.Fatalf("VerifyWebhook() error = %v", err)
+	}
+	if err := verifier.VerifyWebhook(header, "device-1", body); err == nil {
+		t.Fatal("expected a replayed delivery to fail verification")
+	}
+
+	unsigned := http.Header{}
+	if err := verifier.VerifyWebhook(unsigned, "device-1", body); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("VerifyWebhook() of unsigned delivery error = %v, want ErrUnsigned", err)
+	}
+}
@@ -0,0 +1,185 @@
+package idle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/hooks"
+)
+
+// Config decides when the host counts as idle enough to contribute compute
+type Config struct {
+	// IdleAfter is how long the user must have been away from keyboard and mouse
+	IdleAfter time.Duration
+	// MinBattery is the lowest charge, in percent, accepted while on battery power
+	MinBattery int
+	// AllowMetered accepts tasks on metered network connections
+	AllowMetered bool
+	// CheckInterval is how often the host state is sampled
+	CheckInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		IdleAfter:     5 * time.Minute,
+		MinBattery:    50,
+		CheckInterval: 15 * time.Second,
+	}
+}
+
+// Battery is the power state of the host. Present is false on machines without a battery.
+type Battery struct {
+	Present  bool
+	Percent  int
+	Charging bool
+}
+
+// Probe reads the host signals the monitor decides on
+type Probe interface {
+	IdleTime(ctx context.Context) (time.Duration, error)
+	Battery(ctx context.Context) (Battery, error)
+	Metered(ctx context.Context) (bool, error)
+}
+
+// State is the last evaluation of the host. Reason explains why it is not idle.
+type State struct {
+	Idle      bool      `json:"idle"`
+	Reason    string    `json:"reason,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Monitor samples the host and only lets the runner take tasks while it is idle.
+// When the user returns, OnBusy callbacks fire so running work can be stopped.
+type Monitor struct {
+	config Config
+	probe  Probe
+
+	mu     sync.Mutex
+	state  State
+	onBusy []func(reason string)
+}
+
+func NewMonitor(config Config, probe Probe) *Monitor {
+	defaults := DefaultConfig()
+	if config.IdleAfter <= 0 {
+		config.IdleAfter = defaults.IdleAfter
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaults.CheckInterval
+	}
+	if config.MinBattery < 0 || config.MinBattery > 100 {
+		config.MinBattery = defaults.MinBattery
+	}
+	if probe == nil {
+		probe = NewSystemProbe()
+	}
+	return &Monitor{config: config, probe: probe}
+}
+
+// OnBusy registers a callback run when the host stops being idle
+func (m *Monitor) OnBusy(fn func(reason string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onBusy = append(m.onBusy, fn)
+}
+
+func (m *Monitor) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// evaluate decides whether the host is idle. Signals that cannot be read do not
+// block contribution, except idle time, without which the user could be present.
+func (m *Monitor) evaluate(ctx context.Context) State {
+	state := State{CheckedAt: time.Now()}
+
+	idleFor, err := m.probe.IdleTime(ctx)
+	if err != nil {
+		state.Reason = fmt.Sprintf("user idle time unavailable: %v", err)
+		return state
+	}
+	if idleFor < m.config.IdleAfter {
+		state.Reason = fmt.Sprintf("user active %s ago", idleFor.Truncate(time.Second))
+		return state
+	}
+
+	if battery, err := m.probe.Battery(ctx); err == nil && battery.Present && !battery.Charging && battery.Percent < m.config.MinBattery {
+		state.Reason = fmt.Sprintf("on battery at %d%%", battery.Percent)
+		return state
+	}
+
+	if !m.config.AllowMetered {
+		if metered, err := m.probe.Metered(ctx); err == nil && metered {
+			state.Reason = "network connection is metered"
+			return state
+		}
+	}
+
+	state.Idle = true
+	return state
+}
+
+// Check samples the host once and fires OnBusy callbacks on an idle to busy transition
+func (m *Monitor) Check(ctx context.Context) State {
+	state := m.evaluate(ctx)
+
+	m.mu.Lock()
+	wasIdle := m.state.Idle
+	m.state = state
+	callbacks := append([]func(string){}, m.onBusy...)
+	m.mu.Unlock()
+
+	log := gologger.WithComponent("idle")
+	switch {
+	case wasIdle && !state.Idle:
+		log.Info().Str("reason", state.Reason).Msg("Host no longer idle, pausing contribution")
+		for _, fn := range callbacks {
+			fn(state.Reason)
+		}
+	case !wasIdle && state.Idle:
+		log.Info().Msg("Host idle, accepting tasks")
+	}
+	return state
+}
+
+// Run samples the host every CheckInterval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Hook returns a pre-claim hook that leaves tasks for other runners while the host is in use
+func (m *Monitor) Hook() hooks.Hook {
+	return &claimHook{monitor: m}
+}
+
+type claimHook struct {
+	monitor *Monitor
+}
+
+func (h *claimHook) Name() string {
+	return "idle-monitor"
+}
+
+func (h *claimHook) Run(ctx context.Context, hookCtx *hooks.Context) error {
+	// Re-check instead of trusting the last sample, the user may have just returned
+	state := h.monitor.Check(ctx)
+	if !state.Idle {
+		return fmt.Errorf("host is not idle: %s", state.Reason)
+	}
+	return nil
+}
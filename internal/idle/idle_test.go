@@ -0,0 +1,119 @@
+package idle
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeProbe struct {
+	idle    time.Duration
+	battery Battery
+	metered bool
+}
+
+func (p *fakeProbe) IdleTime(ctx context.Context) (time.Duration, error) {
+	return p.idle, nil
+}
+
+func (p *fakeProbe) Battery(ctx context.Context) (Battery, error) {
+	return p.battery, nil
+}
+
+func (p *fakeProbe) Metered(ctx context.Context) (bool, error) {
+	return p.metered, nil
+}
+
+func TestMonitorFiresOnBusyWhenUserReturns(t *testing.T) {
+	probe := &fakeProbe{idle: 10 * time.Minute}
+	monitor := NewMonitor(Config{IdleAfter: 5 * time.Minute, MinBattery: 50}, probe)
+
+	var reasons []string
+	monitor.OnBusy(func(reason string) { reasons = append(reasons, reason) })
+
+	ctx := context.Background()
+	if state := monitor.Check(ctx); !state.Idle {
+		t.Fatalf("Check() = %+v, want idle", state)
+	}
+
+	probe.idle = 3 * time.Second
+	if state := monitor.Check(ctx); state.Idle {
+		t.Fatal("Check() reported idle after user activity")
+	}
+	if len(reasons) != 1 || !strings.Contains(reasons[0], "user active") {
+		t.Fatalf("OnBusy reasons = %v, want one user activity reason", reasons)
+	}
+
+	// Staying busy must not fire the callback again
+	monitor.Check(ctx)
+	if len(reasons) != 1 {
+		t.Fatalf("OnBusy fired %d times, want 1", len(reasons))
+	}
+}
+
+func TestMonitorRespectsBatteryAndMetered(t *testing.T) {
+	ctx := context.Background()
+
+	probe := &fakeProbe{idle: time.Hour, battery: Battery{Present: true, Percent: 20}}
+	if state := NewMonitor(Config{MinBattery: 50}, probe).Check(ctx); state.Idle || !strings.Contains(state.Reason, "battery") {
+		t.Fatalf("Check() on low battery = %+v", state)
+	}
+
+	probe.battery.Charging = true
+	if state := NewMonitor(Config{MinBattery: 50}, probe).Check(ctx); !state.Idle {
+		t.Fatalf("Check() while charging = %+v, want idle", state)
+	}
+
+	probe.metered = true
+	if state := NewMonitor(Config{MinBattery: 50}, probe).Check(ctx); state.Idle {
+		t.Fatal("Check() on a metered connection reported idle")
+	}
+	if state := NewMonitor(Config{MinBattery: 50, AllowMetered: true}, probe).Check(ctx); !state.Idle {
+		t.Fatalf("Check() with AllowMetered = %+v, want idle", state)
+	}
+}
+
+func TestHookRejectsWhileBusy(t *testing.T) {
+	probe := &fakeProbe{idle: time.Second}
+	hook := NewMonitor(Config{}, probe).Hook()
+
+	if err := hook.Run(context.Background(), nil); err == nil {
+		t.Fatal("expected the hook to reject a claim while the user is active")
+	}
+
+	probe.idle = time.Hour
+	if err := hook.Run(context.Background(), nil); err != nil {
+		t.Fatalf("hook.Run() while idle error = %v", err)
+	}
+}
+
+func TestParsers(t *testing.T) {
+	if d, err := parseXprintidle("12500\n"); err != nil || d != 12500*time.Millisecond {
+		t.Fatalf("parseXprintidle() = %v, %v", d, err)
+	}
+
+	if d, err := parseIoregIdle(`    | |   "HIDIdleTime" = 4000000000`); err != nil || d != 4*time.Second {
+		t.Fatalf("parseIoregIdle() = %v, %v", d, err)
+	}
+
+	now := time.Unix(1700000600, 0)
+	output := "IdleHint=yes\nIdleSinceHint=" + "1700000000000000\n"
+	if d, err := parseLoginctlIdle(output, now); err != nil || d != 10*time.Minute {
+		t.Fatalf("parseLoginctlIdle() = %v, %v", d, err)
+	}
+	if d, err := parseLoginctlIdle("IdleHint=no\nIdleSinceHint=0\n", now); err != nil || d != 0 {
+		t.Fatalf("parseLoginctlIdle() when active = %v, %v", d, err)
+	}
+
+	battery := parsePmset("Now drawing from 'Battery Power'\n -InternalBattery-0 (id=1234)\t42%; discharging; 3:10 remaining present: true")
+	if !battery.Present || battery.Percent != 42 || battery.Charging {
+		t.Fatalf("parsePmset() = %+v", battery)
+	}
+
+	for output, want := range map[string]bool{"u 1\n": true, "u 3": true, "u 2": false, "u 4": false} {
+		if metered, err := parseNetworkManagerMetered(output); err != nil || metered != want {
+			t.Fatalf("parseNetworkManagerMetered(%q) = %v, %v; want %v", output, metered, err, want)
+		}
+	}
+}
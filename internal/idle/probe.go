@@ -0,0 +1,186 @@
+package idle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SystemProbe reads idle time, battery and network state from the operating system
+type SystemProbe struct {
+	powerSupplyDir string
+}
+
+func NewSystemProbe() *SystemProbe {
+	return &SystemProbe{powerSupplyDir: "/sys/class/power_supply"}
+}
+
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+	return string(output), nil
+}
+
+func (p *SystemProbe) IdleTime(ctx context.Context) (time.Duration, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		output, err := run(ctx, "ioreg", "-c", "IOHIDSystem")
+		if err != nil {
+			return 0, err
+		}
+		return parseIoregIdle(output)
+	case "linux":
+		// X11 sessions report through xprintidle, others through logind
+		if output, err := run(ctx, "xprintidle"); err == nil {
+			return parseXprintidle(output)
+		}
+		output, err := run(ctx, "loginctl", "show-session", "auto", "-p", "IdleHint", "-p", "IdleSinceHint")
+		if err != nil {
+			return 0, err
+		}
+		return parseLoginctlIdle(output, time.Now())
+	default:
+		return 0, fmt.Errorf("idle detection not supported on %s", runtime.GOOS)
+	}
+}
+
+func (p *SystemProbe) Battery(ctx context.Context) (Battery, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		output, err := run(ctx, "pmset", "-g", "batt")
+		if err != nil {
+			return Battery{}, err
+		}
+		return parsePmset(output), nil
+	case "linux":
+		return p.linuxBattery()
+	default:
+		return Battery{}, fmt.Errorf("battery detection not supported on %s", runtime.GOOS)
+	}
+}
+
+func (p *SystemProbe) linuxBattery() (Battery, error) {
+	supplies, err := os.ReadDir(p.powerSupplyDir)
+	if err != nil {
+		return Battery{}, fmt.Errorf("failed to read power supplies: %w", err)
+	}
+
+	read := func(supply, name string) string {
+		data, _ := os.ReadFile(filepath.Join(p.powerSupplyDir, supply, name))
+		return strings.TrimSpace(string(data))
+	}
+
+	for _, supply := range supplies {
+		if read(supply.Name(), "type") != "Battery" {
+			continue
+		}
+		percent, err := strconv.Atoi(read(supply.Name(), "capacity"))
+		if err != nil {
+			continue
+		}
+		status := read(supply.Name(), "status")
+		return Battery{
+			Present:  true,
+			Percent:  percent,
+			Charging: status == "Charging" || status == "Full" || status == "Not charging",
+		}, nil
+	}
+	return Battery{}, nil
+}
+
+func (p *SystemProbe) Metered(ctx context.Context) (bool, error) {
+	if runtime.GOOS != "linux" {
+		return false, nil
+	}
+	output, err := run(ctx, "busctl", "get-property", "org.freedesktop.NetworkManager",
+		"/org/freedesktop/NetworkManager", "org.freedesktop.NetworkManager", "Metered")
+	if err != nil {
+		return false, err
+	}
+	return parseNetworkManagerMetered(output)
+}
+
+func parseXprintidle(output string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected xprintidle output %q", strings.TrimSpace(output))
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+var ioregIdlePattern = regexp.MustCompile(`"HIDIdleTime"\s*=\s*(\d+)`)
+
+func parseIoregIdle(output string) (time.Duration, error) {
+	match := ioregIdlePattern.FindStringSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("HIDIdleTime not found")
+	}
+	ns, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid HIDIdleTime: %w", err)
+	}
+	return time.Duration(ns), nil
+}
+
+// parseLoginctlIdle reads IdleHint and IdleSinceHint, a realtime timestamp in microseconds
+func parseLoginctlIdle(output string, now time.Time) (time.Duration, error) {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			values[key] = value
+		}
+	}
+
+	if values["IdleHint"] != "yes" {
+		return 0, nil
+	}
+	since, err := strconv.ParseInt(values["IdleSinceHint"], 10, 64)
+	if err != nil || since <= 0 {
+		return 0, fmt.Errorf("invalid IdleSinceHint %q", values["IdleSinceHint"])
+	}
+	idle := now.Sub(time.UnixMicro(since))
+	if idle < 0 {
+		idle = 0
+	}
+	return idle, nil
+}
+
+var pmsetPercentPattern = regexp.MustCompile(`(\d+)%;\s*([a-zA-Z ]+)`)
+
+func parsePmset(output string) Battery {
+	match := pmsetPercentPattern.FindStringSubmatch(output)
+	if match == nil {
+		return Battery{}
+	}
+	percent, _ := strconv.Atoi(match[1])
+	state := strings.TrimSpace(match[2])
+	return Battery{
+		Present:  true,
+		Percent:  percent,
+		Charging: strings.Contains(output, "'AC Power'") || state == "charging" || state == "charged",
+	}
+}
+
+// parseNetworkManagerMetered reads NMMetered: 1 is yes and 3 is a guessed yes
+func parseNetworkManagerMetered(output string) (bool, error) {
+	fields := strings.Fields(output)
+	if len(fields) != 2 || fields[0] != "u" {
+		return false, fmt.Errorf("unexpected Metered property %q", strings.TrimSpace(output))
+	}
+	value, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return false, fmt.Errorf("invalid Metered value: %w", err)
+	}
+	return value == 1 || value == 3, nil
+}
@@ -0,0 +1,279 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// BackendDefault fetches through a gateway and adds to a local node, from
+	// IPFS_GATEWAY_URL and IPFS_API_URL
+	BackendDefault = ""
+	// BackendGateway only fetches, through a public or private gateway
+	BackendGateway = "gateway"
+	// BackendKubo fetches from and adds to a kubo node through its RPC API
+	BackendKubo = "kubo"
+	// BackendPinata adds through Pinata and fetches from its gateway
+	BackendPinata = "pinata"
+	// BackendWeb3Storage adds through web3.storage and fetches from its gateway
+	BackendWeb3Storage = "web3storage"
+
+	pinataAPIURL       = "https://api.pinata.cloud"
+	pinataGateway      = "https://gateway.pinata.cloud"
+	web3StorageAPIURL  = "https://api.web3.storage"
+	web3StorageGateway = "https://w3s.link"
+)
+
+// ErrReadOnly is returned by Add of backends that can only fetch
+var ErrReadOnly = errors.New("ipfs backend cannot add content")
+
+// BackendConfig selects a backend. GatewayURL and APIURL override the
+// backend's defaults; Token authenticates with pinning services.
+type BackendConfig struct {
+	Backend    string
+	GatewayURL string
+	APIURL     string
+	Token      string
+}
+
+// NewBackend creates the backend a runner is configured with
+func NewBackend(config BackendConfig) (Backend, error) {
+	switch strings.ToLower(config.Backend) {
+	case BackendDefault:
+		gateway, apiURL := config.GatewayURL, config.APIURL
+		if gateway == "" {
+			gateway = os.Getenv("IPFS_GATEWAY_URL")
+		}
+		if apiURL == "" {
+			apiURL = os.Getenv("IPFS_API_URL")
+		}
+		return NewClient(gateway, apiURL), nil
+	case BackendGateway:
+		return NewGateway(config.GatewayURL), nil
+	case BackendKubo:
+		return NewKubo(config.APIURL), nil
+	case BackendPinata:
+		if config.Token == "" {
+			return nil, errors.New("pinata needs an API token")
+		}
+		return NewPinningService(BackendPinata, config.APIURL, config.GatewayURL, config.Token), nil
+	case BackendWeb3Storage:
+		if config.Token == "" {
+			return nil, errors.New("web3.storage needs an API token")
+		}
+		return NewPinningService(BackendWeb3Storage, config.APIURL, config.GatewayURL, config.Token), nil
+	default:
+		return nil, fmt.Errorf("unknown ipfs backend %q", config.Backend)
+	}
+}
+
+// Gateway fetches content through an HTTP gateway
+type Gateway struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewGateway(gateway string) *Gateway {
+	if gateway == "" {
+		gateway = DefaultGateway
+	}
+	if !strings.HasSuffix(gateway, "/") {
+		gateway += "/"
+	}
+	// Gateways configured as a bare host serve content under /ipfs/
+	if !strings.HasSuffix(gateway, "/ipfs/") {
+		gateway += "ipfs/"
+	}
+	return &Gateway{url: gateway, httpClient: &http.Client{Timeout: 5 * time.Minute}}
+}
+
+func (g *Gateway) Fetch(ctx context.Context, cid string, maxBytes int64, expectedSHA256 string) ([]byte, error) {
+	if err := ValidateCID(cid); err != nil {
+		return nil, err
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxFetchBytes
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url+cid, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", cid, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: gateway returned status %d", cid, resp.StatusCode)
+	}
+	return readContent(resp, cid, maxBytes, expectedSHA256)
+}
+
+func (g *Gateway) Add(ctx context.Context, data []byte) (string, error) {
+	return "", fmt.Errorf("%w: gateways only serve content", ErrReadOnly)
+}
+
+// Kubo talks to the RPC API of a kubo node, usually the one on the runner's host
+type Kubo struct {
+	apiURL     string
+	httpClient *http.Client
+}
+
+func NewKubo(apiURL string) *Kubo {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &Kubo{apiURL: strings.TrimSuffix(apiURL, "/"), httpClient: &http.Client{Timeout: 5 * time.Minute}}
+}
+
+func (k *Kubo) Fetch(ctx context.Context, cid string, maxBytes int64, expectedSHA256 string) ([]byte, error) {
+	if err := ValidateCID(cid); err != nil {
+		return nil, err
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxFetchBytes
+	}
+
+	// The RPC API only accepts POST
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.apiURL+"/api/v0/cat?arg="+url.QueryEscape(cid), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", cid, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to fetch %s: status %d: %s", cid, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return readContent(resp, cid, maxBytes, expectedSHA256)
+}
+
+// Add stores content on the node, pinned, and returns its CID
+func (k *Kubo) Add(ctx context.Context, data []byte) (string, error) {
+	body, contentType, err := multipartFile(data)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.apiURL+"/api/v0/add?cid-version=1&pin=true", body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	var added struct {
+		Hash string `json:"Hash"`
+	}
+	if err := doAdd(k.httpClient, req, &added); err != nil {
+		return "", err
+	}
+	if added.Hash == "" {
+		return "", fmt.Errorf("ipfs node returned an empty CID")
+	}
+	return added.Hash, nil
+}
+
+// PinningService adds content through a pinning service's upload API, which
+// keeps it pinned, and fetches through the service's gateway
+type PinningService struct {
+	*Gateway
+	service    string
+	apiURL     string
+	token      string
+	httpClient *http.Client
+}
+
+// NewPinningService creates a BackendPinata or BackendWeb3Storage backend. Empty
+// URLs use the service's public endpoints.
+func NewPinningService(service, apiURL, gateway, token string) *PinningService {
+	if service == BackendPinata {
+		apiURL, gateway = withDefault(apiURL, pinataAPIURL), withDefault(gateway, pinataGateway)
+	} else {
+		apiURL, gateway = withDefault(apiURL, web3StorageAPIURL), withDefault(gateway, web3StorageGateway)
+	}
+	return &PinningService{
+		Gateway:    NewGateway(gateway),
+		service:    service,
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func withDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func (p *PinningService) Add(ctx context.Context, data []byte) (string, error) {
+	var req *http.Request
+	var err error
+	var added struct {
+		// Pinata answers with IpfsHash, web3.storage with cid
+		IpfsHash string `json:"IpfsHash"`
+		CID      string `json:"cid"`
+	}
+
+	if p.service == BackendPinata {
+		body, contentType, err := multipartFile(data)
+		if err != nil {
+			return "", err
+		}
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL+"/pinning/pinFileToIPFS", body); err != nil {
+			return "", fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+	} else {
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL+"/upload", bytes.NewReader(data)); err != nil {
+			return "", fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+
+	if err := doAdd(p.httpClient, req, &added); err != nil {
+		return "", fmt.Errorf("%s: %w", p.service, err)
+	}
+	cid := withDefault(added.IpfsHash, added.CID)
+	if cid == "" {
+		return "", fmt.Errorf("%s returned an empty CID", p.service)
+	}
+	return cid, nil
+}
+
+// doAdd sends an upload and decodes its JSON answer into response
+func doAdd(client *http.Client, req *http.Request, response interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to add content: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to add content: status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("failed to decode add response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	DefaultGateway = "https://ipfs.io/ipfs/"
+	DefaultAPIURL  = "http://localhost:5001"
+	// DefaultMaxFetchBytes bounds referenced prompts and datasets resolved by the runner
+	DefaultMaxFetchBytes int64 = 64 << 20
+)
+
+// Backend fetches and adds content by CID. Runners pick one with NewBackend.
+type Backend interface {
+	// Fetch downloads a CID, refusing content larger than maxBytes. When
+	// expectedSHA256 is set the content must hash to it, so a misbehaving
+	// gateway cannot swap the data.
+	Fetch(ctx context.Context, cid string, maxBytes int64, expectedSHA256 string) ([]byte, error)
+	// Add stores content and returns its CID
+	Add(ctx context.Context, data []byte) (string, error)
+}
+
+// Client fetches content by CID through an HTTP gateway and adds content through
+// the node's HTTP API
+type Client struct {
+	*Gateway
+	node *Kubo
+}
+
+func NewClient(gateway, apiURL string) *Client {
+	return &Client{Gateway: NewGateway(gateway), node: NewKubo(apiURL)}
+}
+
+// NewClientFromEnv uses IPFS_GATEWAY_URL and IPFS_API_URL when they are set
+func NewClientFromEnv() *Client {
+	return NewClient(os.Getenv("IPFS_GATEWAY_URL"), os.Getenv("IPFS_API_URL"))
+}
+
+// Add stores content on the IPFS node and returns its CID
+func (c *Client) Add(ctx context.Context, data []byte) (string, error) {
+	return c.node.Add(ctx, data)
+}
+
+// readContent reads the body of a successful fetch within maxBytes and checks
+// its hash
+func readContent(resp *http.Response, cid string, maxBytes int64, expectedSHA256 string) ([]byte, error) {
+	if resp.ContentLength > maxBytes {
+		return nil, fmt.Errorf("content %s is %d bytes, limit is %d", cid, resp.ContentLength, maxBytes)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", cid, err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("content %s exceeds limit of %d bytes", cid, maxBytes)
+	}
+
+	if expectedSHA256 != "" {
+		if sum := SHA256Hex(data); !strings.EqualFold(sum, expectedSHA256) {
+			return nil, fmt.Errorf("content %s hash mismatch: expected %s, got %s", cid, expectedSHA256, sum)
+		}
+	}
+
+	return data, nil
+}
+
+// multipartFile wraps data as the single file of a multipart form, the way
+// both the node API and pinning services take uploads
+func multipartFile(data []byte) (*bytes.Buffer, string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "blob")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, "", fmt.Errorf("failed to write form file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+// ValidateCID rejects values that cannot be a CID, such as paths that would let a
+// task config point the runner at arbitrary gateway URLs
+func ValidateCID(cid string) error {
+	if cid == "" {
+		return fmt.Errorf("cid is required")
+	}
+	for _, r := range cid {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return fmt.Errorf("invalid cid %q", cid)
+		}
+	}
+	return nil
+}
+
+func SHA256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,121 @@
+package ipfs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchChecksSizeAndHash(t *testing.T) {
+	content := []byte("a very long prompt")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ipfs/bafytest" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "")
+	ctx := context.Background()
+
+	data, err := client.Fetch(ctx, "bafytest", 1024, SHA256Hex(content))
+	if err != nil || string(data) != string(content) {
+		t.Fatalf("Fetch() = %q, %v", data, err)
+	}
+
+	if _, err := client.Fetch(ctx, "bafytest", 4, ""); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Fatalf("Fetch() over the size limit error = %v, want a limit error", err)
+	}
+
+	if _, err := client.Fetch(ctx, "bafytest", 1024, SHA256Hex([]byte("other"))); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Fatalf("Fetch() with wrong hash error = %v, want a hash mismatch", err)
+	}
+
+	if _, err := client.Fetch(ctx, "../etc/passwd", 1024, ""); err == nil {
+		t.Fatal("expected an invalid CID to be rejected")
+	}
+}
+
+func TestAddReturnsCID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/add" {
+			http.NotFound(w, r)
+			return
+		}
+		if _, _, err := r.FormFile("file"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Name":"blob","Hash":"bafyadded","Size":"5"}`))
+	}))
+	defer server.Close()
+
+	cid, err := NewClient("", server.URL).Add(context.Background(), []byte("hello"))
+	if err != nil || cid != "bafyadded" {
+		t.Fatalf("Add() = %q, %v; want bafyadded", cid, err)
+	}
+}
+
+func TestKuboFetchesThroughRPC(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v0/cat" || r.URL.Query().Get("arg") != "bafytest" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte("dataset"))
+	}))
+	defer server.Close()
+
+	backend, err := NewBackend(BackendConfig{Backend: BackendKubo, APIURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := backend.Fetch(context.Background(), "bafytest", 1024, SHA256Hex([]byte("dataset")))
+	if err != nil || string(data) != "dataset" {
+		t.Fatalf("Fetch() = %q, %v", data, err)
+	}
+}
+
+func TestPinningServicesAdd(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/pinning/pinFileToIPFS":
+			if _, _, err := r.FormFile("file"); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"IpfsHash":"bafypinata","PinSize":5}`))
+		case "/upload":
+			_, _ = w.Write([]byte(`{"cid":"bafyweb3"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for service, want := range map[string]string{BackendPinata: "bafypinata", BackendWeb3Storage: "bafyweb3"} {
+		backend, err := NewBackend(BackendConfig{Backend: service, APIURL: server.URL, Token: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cid, err := backend.Add(context.Background(), []byte("hello")); err != nil || cid != want {
+			t.Errorf("%s Add() = %q, %v; want %s", service, cid, err, want)
+		}
+	}
+
+	if _, err := NewBackend(BackendConfig{Backend: BackendPinata}); err == nil {
+		t.Error("pinata backend without a token was accepted")
+	}
+	if _, err := NewGateway("").Add(context.Background(), []byte("hello")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("gateway Add() error = %v, want ErrReadOnly", err)
+	}
+}
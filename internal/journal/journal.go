@@ -0,0 +1,178 @@
+// Package journal keeps the tasks a runner accepted until it has finished them,
+// so that a crash or reboot does not orphan them. On the next start the runner
+// asks the server what became of each one and resumes or releases it.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const journalDirName = "tasks"
+
+// State is how far the runner got with a task
+type State string
+
+const (
+	// StateQueued tasks were accepted and wait for a worker, the server still
+	// offers them to other runners
+	StateQueued State = "queued"
+	// StateRunning tasks were claimed from the server and started
+	StateRunning State = "running"
+)
+
+// Entry is a task the runner holds
+type Entry struct {
+	Task       *models.Task `json:"task"`
+	State      State        `json:"state"`
+	AcceptedAt time.Time    `json:"accepted_at"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+}
+
+// Resumable reports whether the task can pick up where it was stopped rather
+// than start over, which is worth keeping its claim for
+func (e Entry) Resumable() bool {
+	if e.Task == nil || e.Task.Type != models.TaskTypeDocker {
+		return false
+	}
+	var config models.TaskConfig
+	if err := json.Unmarshal(e.Task.Config, &config); err != nil {
+		return false
+	}
+	return config.Checkpoint != nil && config.Checkpoint.Enabled && config.Gang == nil
+}
+
+type Store struct {
+	dir string
+}
+
+func DefaultDir() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, journalDirName), nil
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) path(taskID string) (string, error) {
+	if _, err := uuid.Parse(taskID); err != nil {
+		return "", fmt.Errorf("invalid task ID %q", taskID)
+	}
+	return filepath.Join(s.dir, taskID+".json"), nil
+}
+
+func (s *Store) put(entry Entry) error {
+	path, err := s.path(entry.Task.ID.String())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create task journal directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	return nil
+}
+
+// Accept records a task the runner queued
+func (s *Store) Accept(task *models.Task, now time.Time) error {
+	return s.put(Entry{Task: task, State: StateQueued, AcceptedAt: now.UTC()})
+}
+
+// Start records that the runner claimed a task and started it. Tasks started
+// without being accepted first are recorded as well.
+func (s *Store) Start(task *models.Task, now time.Time) error {
+	entry, err := s.Get(task.ID.String())
+	if errors.Is(err, os.ErrNotExist) {
+		entry, err = &Entry{Task: task, AcceptedAt: now.UTC()}, nil
+	}
+	if err != nil {
+		return err
+	}
+	startedAt := now.UTC()
+	entry.State = StateRunning
+	entry.StartedAt = &startedAt
+	return s.put(*entry)
+}
+
+// Get returns the entry of a task, an os.ErrNotExist error when there is none
+func (s *Store) Get(taskID string) (*Entry, error) {
+	path, err := s.path(taskID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal entry: %w", err)
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse journal entry %s: %w", taskID, err)
+	}
+	return &entry, nil
+}
+
+// List returns the tasks the runner holds, the first accepted first
+func (s *Store) List() ([]Entry, error) {
+	files, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task journal: %w", err)
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		entry, err := s.Get(strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if entry.Task == nil {
+			return nil, fmt.Errorf("journal entry %s holds no task", file.Name())
+		}
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].AcceptedAt.Before(entries[j].AcceptedAt) })
+	return entries, nil
+}
+
+// Remove forgets a task the runner finished or gave up
+func (s *Store) Remove(taskID string) error {
+	path, err := s.path(taskID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove journal entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestStoreKeepsTasksUntilRemoved(t *testing.T) {
+	store := NewStore(t.TempDir())
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	queued := models.NewTask()
+	started := models.NewTask()
+	if err := store.Accept(queued, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Accept() = %v", err)
+	}
+	if err := store.Accept(started, now); err != nil {
+		t.Fatalf("Accept() = %v", err)
+	}
+	if err := store.Start(started, now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Task.ID != started.ID || entries[1].Task.ID != queued.ID {
+		t.Fatalf("List() = %+v, want the first accepted first", entries)
+	}
+	if entries[0].State != StateRunning || !entries[0].AcceptedAt.Equal(now) || entries[0].StartedAt == nil {
+		t.Fatalf("started entry = %+v, want running and accepted at %v", entries[0], now)
+	}
+	if entries[1].State != StateQueued {
+		t.Fatalf("queued entry state = %s, want queued", entries[1].State)
+	}
+
+	if err := store.Remove(started.ID.String()); err != nil {
+		t.Fatalf("Remove() = %v", err)
+	}
+	if _, err := store.Get(started.ID.String()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Get() after Remove = %v, want os.ErrNotExist", err)
+	}
+	if err := store.Remove(started.ID.String()); err != nil {
+		t.Fatalf("removing again = %v", err)
+	}
+}
+
+func TestEntryResumable(t *testing.T) {
+	config := func(c models.TaskConfig) json.RawMessage {
+		data, _ := json.Marshal(c)
+		return data
+	}
+	checkpoint := &models.CheckpointConfig{Enabled: true}
+
+	tests := []struct {
+		name     string
+		taskType models.TaskType
+		config   json.RawMessage
+		want     bool
+	}{
+		{"docker with checkpoints", models.TaskTypeDocker, config(models.TaskConfig{Checkpoint: checkpoint}), true},
+		{"docker without checkpoints", models.TaskTypeDocker, config(models.TaskConfig{}), false},
+		{"command", models.TaskTypeCommand, config(models.TaskConfig{Checkpoint: checkpoint}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := models.NewTask()
+			task.Type = tt.taskType
+			task.Config = tt.config
+			if got := (Entry{Task: task}).Resumable(); got != tt.want {
+				t.Fatalf("Resumable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Package keyfile keeps the runner's wallet key on disk, encrypted with a
+// passphrase in the Ethereum keystore (v3) format that geth and Clef read:
+// scrypt derives the encryption key and a MAC over the ciphertext rejects a
+// wrong passphrase. Key files written before, holding the key in plain text,
+// are still read until Migrate encrypts them.
+package keyfile
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+// scryptN is the cost of the key derivation protecting the key
+var scryptN = keystore.StandardScryptN
+
+var (
+	ErrNoKey           = errors.New("no wallet key stored")
+	ErrWrongPassphrase = errors.New("wrong keystore passphrase")
+)
+
+// contents is either format of a key file: the address and crypto of the
+// keystore format, or the plain text key of older runners
+type contents struct {
+	Address    string          `json:"address"`
+	Crypto     json.RawMessage `json:"crypto"`
+	PrivateKey string          `json:"private_key"`
+}
+
+// File is a key file at a path
+type File struct {
+	path string
+}
+
+func New(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Path() string {
+	return f.path
+}
+
+// Encrypted reports whether the stored key is encrypted
+func (f *File) Encrypted() (bool, error) {
+	_, c, err := f.read()
+	if err != nil {
+		return false, err
+	}
+	return len(c.Crypto) > 0, nil
+}
+
+// Address reads the wallet address of the stored key without decrypting it
+func (f *File) Address() (common.Address, error) {
+	_, c, err := f.read()
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(c.Crypto) > 0 {
+		if !common.IsHexAddress(c.Address) {
+			return common.Address{}, fmt.Errorf("keystore has no valid address")
+		}
+		return common.HexToAddress(c.Address), nil
+	}
+	key, err := plainKey(c.PrivateKey)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(key.PublicKey), nil
+}
+
+// Load returns the stored key, decrypting it with passphrase. A plain text key
+// is returned as is.
+func (f *File) Load(passphrase string) (*ecdsa.PrivateKey, error) {
+	data, c, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Crypto) == 0 {
+		return plainKey(c.PrivateKey)
+	}
+
+	key, err := keystore.DecryptKey(data, passphrase)
+	if errors.Is(err, keystore.ErrDecrypt) {
+		return nil, ErrWrongPassphrase
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt wallet key: %w", err)
+	}
+	return key.PrivateKey, nil
+}
+
+// Save encrypts key with passphrase and replaces the stored key
+func (f *File) Save(key *ecdsa.PrivateKey, passphrase string) error {
+	if key == nil {
+		return errors.New("wallet key is required")
+	}
+	if passphrase == "" {
+		return errors.New("passphrase is required")
+	}
+
+	encrypted, err := keystore.EncryptKey(&keystore.Key{
+		Id:         uuid.New(),
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}, passphrase, scryptN, keystore.StandardScryptP)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt wallet key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create keystore directory: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, encrypted, 0o600); err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to write keystore: %w", err)
+	}
+	return nil
+}
+
+// Migrate encrypts a plain text key in place with passphrase. It reports false
+// when the key was already encrypted.
+func (f *File) Migrate(passphrase string) (bool, error) {
+	encrypted, err := f.Encrypted()
+	if err != nil || encrypted {
+		return false, err
+	}
+	key, err := f.Load("")
+	if err != nil {
+		return false, err
+	}
+	if err := f.Save(key, passphrase); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *File) read() ([]byte, *contents, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+
+	var c contents
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse keystore: %w", err)
+	}
+	if len(c.Crypto) == 0 && c.PrivateKey == "" {
+		return nil, nil, fmt.Errorf("keystore %s holds no key", f.path)
+	}
+	return data, &c, nil
+}
+
+func plainKey(hexKey string) (*ecdsa.PrivateKey, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key in keystore: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,96 @@
+package keyfile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func init() {
+	scryptN = keystore.LightScryptN
+}
+
+func TestSaveWritesAGethKeystore(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := New(filepath.Join(t.TempDir(), "keystore.json"))
+	if err := file.Save(key, "correct horse"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(file.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(file.Path()); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("keystore mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	var v3 struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &v3); err != nil || v3.Version != 3 {
+		t.Fatalf("keystore version = %d, %v; want 3", v3.Version, err)
+	}
+	if strings.Contains(string(data), common.Bytes2Hex(crypto.FromECDSA(key))) {
+		t.Fatal("keystore holds the key in plain text")
+	}
+	gethKey, err := keystore.DecryptKey(data, "correct horse")
+	if err != nil || gethKey.Address != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("geth cannot read the keystore: %v", err)
+	}
+
+	loaded, err := file.Load("correct horse")
+	if err != nil || !loaded.Equal(key) {
+		t.Fatalf("Load() = %v, want the saved key", err)
+	}
+	if _, err := file.Load("wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Load() with a wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+	if address, err := file.Address(); err != nil || address != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("Address() = %s, %v", address.Hex(), err)
+	}
+}
+
+func TestMigrateEncryptsPlainTextKeys(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := New(filepath.Join(t.TempDir(), "keystore.json"))
+	if _, err := file.Encrypted(); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("Encrypted() without a file error = %v, want ErrNoKey", err)
+	}
+
+	plain := `{"private_key":"` + common.Bytes2Hex(crypto.FromECDSA(key)) + `"}`
+	if err := os.WriteFile(file.Path(), []byte(plain), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if encrypted, err := file.Encrypted(); err != nil || encrypted {
+		t.Fatalf("Encrypted() = %v, %v; want false", encrypted, err)
+	}
+	if loaded, err := file.Load(""); err != nil || !loaded.Equal(key) {
+		t.Fatalf("Load() of a plain text key error = %v", err)
+	}
+
+	if migrated, err := file.Migrate("s3cret"); err != nil || !migrated {
+		t.Fatalf("Migrate() = %v, %v; want true", migrated, err)
+	}
+	if migrated, err := file.Migrate("other"); err != nil || migrated {
+		t.Fatalf("second Migrate() = %v, %v; want false", migrated, err)
+	}
+	if loaded, err := file.Load("s3cret"); err != nil || !loaded.Equal(key) {
+		t.Fatalf("Load() after migrating error = %v", err)
+	}
+	if address, err := file.Address(); err != nil || address != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("Address() after migrating = %s, %v", address.Hex(), err)
+	}
+}
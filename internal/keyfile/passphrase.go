@@ -0,0 +1,100 @@
+package keyfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/theblitlabs/parity-runner/internal/secrets"
+)
+
+// EnvPassphrase holds the keystore passphrase for runners that cannot be
+// prompted, such as services
+const EnvPassphrase = "PARITY_KEYSTORE_PASSPHRASE"
+
+// Passphrase finds the passphrase of the key of address in
+// PARITY_KEYSTORE_PASSPHRASE, then the OS secret store, and otherwise asks for
+// it on the terminal
+func Passphrase(address string) (string, error) {
+	if passphrase := os.Getenv(EnvPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+	if store, err := secrets.Native(); err == nil {
+		if passphrase, err := store.Get(passphraseSecret(address)); err == nil && passphrase != "" {
+			return passphrase, nil
+		}
+	}
+	return prompt(fmt.Sprintf("Keystore passphrase for %s: ", address))
+}
+
+// NewPassphrase returns the passphrase to encrypt a key with, from
+// PARITY_KEYSTORE_PASSPHRASE or asked for twice on the terminal
+func NewPassphrase() (string, error) {
+	if passphrase := os.Getenv(EnvPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+	passphrase, err := prompt("New keystore passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("passphrase must not be empty")
+	}
+	repeated, err := prompt("Repeat the passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if repeated != passphrase {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// StorePassphrase keeps the passphrase of the key of address in the OS secret
+// store, where Passphrase finds it
+func StorePassphrase(address, passphrase string) error {
+	store, err := secrets.Native()
+	if err != nil {
+		return err
+	}
+	return store.Set(passphraseSecret(address), passphrase)
+}
+
+func passphraseSecret(address string) string {
+	return "keystore-passphrase:" + address
+}
+
+// prompt reads a line from the terminal without echoing it
+func prompt(message string) (string, error) {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return "", fmt.Errorf("no terminal to ask for the keystore passphrase on, set %s or store it in the OS secret store", EnvPassphrase)
+	}
+
+	fmt.Fprint(os.Stderr, message)
+	if echo(false) == nil {
+		defer func() {
+			_ = echo(true)
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// echo turns terminal echo on or off with stty; where there is no stty the
+// passphrase is echoed
+func echo(on bool) error {
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
@@ -0,0 +1,257 @@
+// Package ledger keeps a local record of every task this runner executed, what
+// it was offered for it and what it was paid, for the runner operator's own
+// accounting.
+//
+// The ledger is an append-only file of JSON lines. Updates, such as the
+// payment of a task found later, are appended as a new line for the task and
+// replace the earlier one when the ledger is read.
+package ledger
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	ledgerDirName  = "ledger"
+	ledgerFileName = "tasks.jsonl"
+)
+
+// Entry is one executed task
+type Entry struct {
+	TaskID      string            `json:"task_id"`
+	TaskType    models.TaskType   `json:"task_type"`
+	Status      models.TaskStatus `json:"status"`
+	CompletedAt time.Time         `json:"completed_at"`
+	// Reward is what the task offered
+	Reward float64 `json:"reward"`
+	// Submitted is false when the result went to the outbox instead of the
+	// server
+	Submitted bool `json:"submitted"`
+
+	ExecutionTimeMs int64   `json:"execution_time_ms"`
+	CPUSeconds      float64 `json:"cpu_seconds"`
+	MemoryGBHours   float64 `json:"memory_gb_hours"`
+	StorageGB       float64 `json:"storage_gb"`
+	NetworkDataGB   float64 `json:"network_data_gb"`
+
+	// PaidAmount, PaidAt and TxHash are filled in by Reconcile once a
+	// coordinator reports the payout
+	PaidAmount  float64    `json:"paid_amount,omitempty"`
+	PaidAt      *time.Time `json:"paid_at,omitempty"`
+	TxHash      string     `json:"tx_hash,omitempty"`
+	Coordinator string     `json:"coordinator,omitempty"`
+}
+
+// Paid reports whether a payout was found for the task
+func (e Entry) Paid() bool {
+	return e.PaidAt != nil
+}
+
+// NewEntry records a task that finished with status and result, which may be
+// nil for tasks that failed before producing one
+func NewEntry(task *models.Task, status models.TaskStatus, result *models.TaskResult, at time.Time) Entry {
+	entry := Entry{
+		TaskID:      task.ID.String(),
+		TaskType:    task.Type,
+		Status:      status,
+		CompletedAt: at.UTC(),
+		Reward:      task.Reward,
+	}
+	if result != nil {
+		entry.ExecutionTimeMs = result.ExecutionTime
+		entry.CPUSeconds = result.CPUSeconds
+		entry.MemoryGBHours = result.MemoryGBHours
+		entry.StorageGB = result.StorageGB
+		entry.NetworkDataGB = result.NetworkDataGB
+	}
+	return entry
+}
+
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func DefaultDir() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, ledgerDirName), nil
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) path() string {
+	return filepath.Join(s.dir, ledgerFileName)
+}
+
+// Record appends entries to the ledger, replacing earlier entries of the same
+// tasks
+func (s *Store) Record(entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	file, err := os.OpenFile(s.path(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer file.Close()
+
+	var lines []byte
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal ledger entry: %w", err)
+		}
+		lines = append(append(lines, data...), '\n')
+	}
+	if _, err := file.Write(lines); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	return file.Sync()
+}
+
+// List returns the latest entry of every task, oldest first. A line cut short
+// by a crash while it was written is skipped.
+func (s *Store) List() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer file.Close()
+
+	latest := make(map[string]Entry)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.TaskID == "" {
+			continue
+		}
+		latest[entry.TaskID] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(latest))
+	for _, entry := range latest {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].CompletedAt.Before(entries[j].CompletedAt) })
+	return entries, nil
+}
+
+// Reconcile fills in the payment of unpaid entries from the rewards the
+// coordinators report, and returns how many it updated
+func (s *Store) Reconcile(rewards []models.PaidReward) (int, error) {
+	entries, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+	paid := make(map[string]models.PaidReward, len(rewards))
+	for _, reward := range rewards {
+		paid[reward.TaskID] = reward
+	}
+
+	var updated []Entry
+	for _, entry := range entries {
+		reward, ok := paid[entry.TaskID]
+		if !ok || (entry.Paid() && entry.TxHash == reward.TxHash) {
+			continue
+		}
+		paidAt := reward.PaidAt.UTC()
+		entry.PaidAmount = reward.Amount
+		entry.PaidAt = &paidAt
+		entry.TxHash = reward.TxHash
+		entry.Coordinator = reward.Coordinator
+		updated = append(updated, entry)
+	}
+	return len(updated), s.Record(updated...)
+}
+
+// Filter returns the entries of tasks completed in [from, to). A zero bound
+// leaves that side open.
+func Filter(entries []Entry, from, to time.Time) []Entry {
+	var filtered []Entry
+	for _, entry := range entries {
+		if !from.IsZero() && entry.CompletedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !entry.CompletedAt.Before(to) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
+
+// WriteCSV writes one row per entry
+func WriteCSV(w io.Writer, entries []Entry) error {
+	out := csv.NewWriter(w)
+	header := []string{"completed_at", "task_id", "task_type", "status", "reward", "paid_amount", "paid_at", "tx_hash", "coordinator",
+		"execution_time_ms", "cpu_seconds", "memory_gb_hours", "storage_gb", "network_data_gb"}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		paidAt := ""
+		if entry.PaidAt != nil {
+			paidAt = entry.PaidAt.Format(time.RFC3339)
+		}
+		row := []string{
+			entry.CompletedAt.Format(time.RFC3339),
+			entry.TaskID,
+			string(entry.TaskType),
+			string(entry.Status),
+			formatFloat(entry.Reward),
+			formatFloat(entry.PaidAmount),
+			paidAt,
+			entry.TxHash,
+			entry.Coordinator,
+			strconv.FormatInt(entry.ExecutionTimeMs, 10),
+			formatFloat(entry.CPUSeconds),
+			formatFloat(entry.MemoryGBHours),
+			formatFloat(entry.StorageGB),
+			formatFloat(entry.NetworkDataGB),
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,81 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestLedgerReconcilesPayoutsAndSummarizes(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	monday := time.Date(2026, time.September, 7, 9, 0, 0, 0, time.UTC)
+
+	paid := &models.Task{ID: uuid.New(), Type: models.TaskTypeDocker, Reward: 2}
+	unpaid := &models.Task{ID: uuid.New(), Type: models.TaskTypeCommand, Reward: 1}
+	failed := &models.Task{ID: uuid.New(), Type: models.TaskTypeCommand, Reward: 5}
+	if err := store.Record(
+		NewEntry(paid, models.TaskStatusCompleted, &models.TaskResult{ExecutionTime: 1500, CPUSeconds: 3}, monday),
+		NewEntry(unpaid, models.TaskStatusCompleted, &models.TaskResult{CPUSeconds: 1}, monday.Add(26*time.Hour)),
+		NewEntry(failed, models.TaskStatusFailed, nil, monday.AddDate(0, 0, 7)),
+	); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	updated, err := store.Reconcile([]models.PaidReward{
+		{TaskID: paid.ID.String(), PaidAt: monday.Add(time.Hour), Amount: 1.8, TxHash: "0xabc"},
+		{TaskID: uuid.NewString(), PaidAt: monday, Amount: 9},
+	})
+	if err != nil || updated != 1 {
+		t.Fatalf("Reconcile() = %d, %v; want 1 updated", updated, err)
+	}
+	if updated, _ := store.Reconcile([]models.PaidReward{{TaskID: paid.ID.String(), PaidAt: monday.Add(time.Hour), Amount: 1.8, TxHash: "0xabc"}}); updated != 0 {
+		t.Fatalf("Reconcile() of a recorded payout updated %d entries", updated)
+	}
+
+	// A line cut short by a crash does not hide the rest of the ledger
+	file, err := os.OpenFile(filepath.Join(dir, ledgerFileName), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("failed to open ledger: %v", err)
+	}
+	_, _ = file.WriteString(`{"task_id":"` + unpaid.ID.String() + `","rew`)
+	file.Close()
+
+	entries, err := store.List()
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("List() = %+v, %v; want 3 entries", entries, err)
+	}
+	if entries[0].TxHash != "0xabc" || entries[0].PaidAmount != 1.8 || entries[0].ExecutionTimeMs != 1500 {
+		t.Fatalf("paid entry = %+v", entries[0])
+	}
+
+	weeks := Summarize(entries, PeriodWeek)
+	if len(weeks) != 2 || !weeks[0].Start.Equal(time.Date(2026, time.September, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("weeks = %+v, want two starting on Monday the 7th", weeks)
+	}
+	if week := weeks[0]; week.Completed != 2 || week.Reward != 3 || week.Paid != 1.8 || week.Unpaid != 1 || week.CPUSeconds != 4 {
+		t.Fatalf("first week = %+v", week)
+	}
+	if week := weeks[1]; week.Failed != 1 || week.Reward != 0 {
+		t.Fatalf("second week = %+v, want the failed task without reward", week)
+	}
+	if days := Summarize(entries, PeriodDay); len(days) != 3 {
+		t.Fatalf("days = %+v, want 3", days)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, Filter(entries, monday, monday.AddDate(0, 0, 7))); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 3 || rows[1][7] != "0xabc" || rows[1][6] != "2026-09-07T10:00:00Z" {
+		t.Fatalf("csv rows = %v, %v", rows, err)
+	}
+}
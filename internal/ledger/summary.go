@@ -0,0 +1,87 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// Period is the length of the intervals entries are summarized over
+type Period string
+
+const (
+	PeriodDay  Period = "day"
+	PeriodWeek Period = "week"
+)
+
+func ParsePeriod(value string) (Period, error) {
+	switch Period(value) {
+	case PeriodDay, PeriodWeek:
+		return Period(value), nil
+	default:
+		return "", fmt.Errorf("unknown period %q, expected day or week", value)
+	}
+}
+
+// Start is the start of the period that at lies in. Days are UTC days and weeks
+// start on Monday.
+func (p Period) Start(at time.Time) time.Time {
+	at = at.UTC()
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	if p == PeriodWeek {
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
+
+// Summary adds up the entries of one period
+type Summary struct {
+	Start     time.Time `json:"start"`
+	Tasks     int       `json:"tasks"`
+	Completed int       `json:"completed"`
+	Failed    int       `json:"failed"`
+	// Reward is what the completed tasks offered, Paid what was paid for
+	// them so far
+	Reward float64 `json:"reward"`
+	Paid   float64 `json:"paid"`
+	// Unpaid counts completed tasks no payout was found for
+	Unpaid        int     `json:"unpaid"`
+	CPUSeconds    float64 `json:"cpu_seconds"`
+	ExecutionTime int64   `json:"execution_time_ms"`
+}
+
+// Summarize groups entries by the period they were completed in, oldest
+// period first
+func Summarize(entries []Entry, period Period) []Summary {
+	byStart := make(map[time.Time]*Summary)
+	for _, entry := range entries {
+		start := period.Start(entry.CompletedAt)
+		summary, ok := byStart[start]
+		if !ok {
+			summary = &Summary{Start: start}
+			byStart[start] = summary
+		}
+		summary.Tasks++
+		summary.CPUSeconds += entry.CPUSeconds
+		summary.ExecutionTime += entry.ExecutionTimeMs
+		summary.Paid += entry.PaidAmount
+		if entry.Status != models.TaskStatusCompleted {
+			summary.Failed++
+			continue
+		}
+		summary.Completed++
+		summary.Reward += entry.Reward
+		if !entry.Paid() {
+			summary.Unpaid++
+		}
+	}
+
+	summaries := make([]Summary, 0, len(byStart))
+	for _, summary := range byStart {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Start.Before(summaries[j].Start) })
+	return summaries
+}
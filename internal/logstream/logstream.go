@@ -0,0 +1,243 @@
+// Package logstream pushes the output of running tasks to the server as it is
+// written, so task creators can follow a task instead of waiting for its
+// result.
+//
+// Output is buffered and sent in batches every interval. Batches the server
+// could not be reached for are sent again with the next one, up to a limit
+// past which the oldest output is dropped.
+package logstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	// DefaultInterval is how often output is sent when no interval is set
+	DefaultInterval = time.Second
+	// maxChunkBytes bounds the output merged into one chunk
+	maxChunkBytes = 16 << 10
+	// maxBatchBytes of pending output are sent without waiting for the interval
+	maxBatchBytes = 64 << 10
+	// maxPendingBytes is how much output waits for the server at most
+	maxPendingBytes = 1 << 20
+)
+
+// ErrRejected is returned by a SendFunc when the server will not take the
+// logs of the task, after which the stream stops sending
+var ErrRejected = errors.New("server does not accept task logs")
+
+// SendFunc delivers a batch of chunks to the server
+type SendFunc func(chunks []models.LogChunk) error
+
+// Stream collects the output of one task and sends it in the background
+type Stream struct {
+	taskID   string
+	send     SendFunc
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []models.LogChunk
+	size    int
+	seq     int64
+	// attempted is the last seq handed to send. Chunks up to it may have
+	// reached the server, so output is not merged into them.
+	attempted int64
+	dropped   int
+	rejected  bool
+	closed    bool
+
+	full chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New starts streaming the output of a task with send, every interval or
+// DefaultInterval when it is zero
+func New(taskID string, send SendFunc, interval time.Duration) *Stream {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	s := &Stream{
+		taskID:   taskID,
+		send:     send,
+		interval: interval,
+		full:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+// Writer returns a writer for one of the task's output streams, such as
+// models.LogStreamStdout
+func (s *Stream) Writer(stream string) io.Writer {
+	return writer{s: s, stream: stream}
+}
+
+type writer struct {
+	s      *Stream
+	stream string
+}
+
+// Write never fails, output that does not fit is dropped rather than slowing
+// the task down
+func (w writer) Write(p []byte) (int, error) {
+	w.s.write(w.stream, p)
+	return len(p), nil
+}
+
+func (s *Stream) write(stream string, p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.rejected {
+		return
+	}
+	if s.size+len(p) > maxPendingBytes {
+		s.dropped += len(p)
+		return
+	}
+	if n := len(s.pending); n > 0 {
+		last := &s.pending[n-1]
+		if last.Stream == stream && last.Seq > s.attempted && len(last.Data)+len(p) <= maxChunkBytes {
+			last.Data += string(p)
+			s.size += len(p)
+			s.signalFull()
+			return
+		}
+	}
+	s.seq++
+	s.pending = append(s.pending, models.LogChunk{Seq: s.seq, Stream: stream, Data: string(p), Time: time.Now().UTC()})
+	s.size += len(p)
+	s.signalFull()
+}
+
+func (s *Stream) signalFull() {
+	if s.size < maxBatchBytes {
+		return
+	}
+	select {
+	case s.full <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Stream) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			s.flush()
+			return
+		case <-ticker.C:
+		case <-s.full:
+		}
+		s.flush()
+	}
+}
+
+// flush sends the pending output, keeping it for the next flush when the
+// server could not be reached
+func (s *Stream) flush() {
+	s.mu.Lock()
+	if s.dropped > 0 {
+		s.seq++
+		s.pending = append(s.pending, models.LogChunk{
+			Seq:    s.seq,
+			Stream: models.LogStreamStderr,
+			Data:   fmt.Sprintf("[parity-runner: %d bytes of output dropped]\n", s.dropped),
+			Time:   time.Now().UTC(),
+		})
+		s.dropped = 0
+	}
+	batch := s.pending
+	s.pending = nil
+	s.size = 0
+	if len(batch) > 0 {
+		s.attempted = batch[len(batch)-1].Seq
+	}
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+	err := s.send(batch)
+	if err == nil {
+		return
+	}
+
+	log := gologger.WithComponent("logstream")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errors.Is(err, ErrRejected) {
+		log.Debug().Err(err).Str("task_id", s.taskID).Msg("Server does not take the task's logs, not streaming them")
+		s.rejected = true
+		s.pending = nil
+		s.size = 0
+		return
+	}
+	log.Debug().Err(err).Str("task_id", s.taskID).Msg("Failed to stream task logs, retrying with the next batch")
+
+	// The oldest output goes first when the server stays out of reach
+	s.pending = append(batch, s.pending...)
+	s.size = 0
+	for _, chunk := range s.pending {
+		s.size += len(chunk.Data)
+	}
+	for s.size > maxPendingBytes && len(s.pending) > 0 {
+		s.size -= len(s.pending[0].Data)
+		s.dropped += len(s.pending[0].Data)
+		s.pending = s.pending[1:]
+	}
+}
+
+// Close sends the output still pending and stops the stream. Close on a nil
+// stream does nothing.
+func (s *Stream) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.done)
+	s.wg.Wait()
+}
+
+type contextKey struct{}
+
+// WithStream hands the stream of a task to the executor that runs it
+func WithStream(ctx context.Context, stream *Stream) context.Context {
+	if stream == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, stream)
+}
+
+// FromContext returns the stream in ctx, nil when the task's output is not
+// streamed
+func FromContext(ctx context.Context) *Stream {
+	stream, _ := ctx.Value(contextKey{}).(*Stream)
+	return stream
+}
@@ -0,0 +1,129 @@
+package logstream
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// recorder is a SendFunc that keeps what it was sent and fails while failing
+// is set
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]models.LogChunk
+	err     error
+}
+
+func (r *recorder) send(chunks []models.LogChunk) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]models.LogChunk(nil), chunks...))
+	return r.err
+}
+
+func (r *recorder) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+func (r *recorder) sent() [][]models.LogChunk {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]models.LogChunk(nil), r.batches...)
+}
+
+func TestStreamMergesWritesAndFlushesOnClose(t *testing.T) {
+	r := &recorder{}
+	stream := New("task-1", r.send, time.Hour)
+
+	fmt.Fprint(stream.Writer(models.LogStreamStdout), "hello ")
+	fmt.Fprint(stream.Writer(models.LogStreamStdout), "world\n")
+	fmt.Fprint(stream.Writer(models.LogStreamStderr), "oops\n")
+	stream.Close()
+	stream.Close()
+
+	batches := r.sent()
+	if len(batches) != 1 {
+		t.Fatalf("batches = %+v, want one sent on close", batches)
+	}
+	chunks := batches[0]
+	if len(chunks) != 2 {
+		t.Fatalf("chunks = %+v, want stdout and stderr", chunks)
+	}
+	if chunks[0].Seq != 1 || chunks[0].Stream != models.LogStreamStdout || chunks[0].Data != "hello world\n" {
+		t.Errorf("chunks[0] = %+v", chunks[0])
+	}
+	if chunks[1].Seq != 2 || chunks[1].Stream != models.LogStreamStderr || chunks[1].Data != "oops\n" {
+		t.Errorf("chunks[1] = %+v", chunks[1])
+	}
+
+	// Output after Close goes nowhere
+	fmt.Fprint(stream.Writer(models.LogStreamStdout), "late\n")
+	if len(r.sent()) != 1 {
+		t.Errorf("output written after Close was sent")
+	}
+}
+
+func TestStreamRetriesFailedBatchesWithTheSameSequence(t *testing.T) {
+	r := &recorder{err: errors.New("connection refused")}
+	stream := New("task-1", r.send, time.Hour)
+	stdout := stream.Writer(models.LogStreamStdout)
+
+	fmt.Fprint(stdout, "first\n")
+	stream.flush()
+	r.setErr(nil)
+	// The failed chunk may have reached the server, so this must not be
+	// merged into it
+	fmt.Fprint(stdout, "second\n")
+	stream.Close()
+
+	batches := r.sent()
+	if len(batches) != 2 {
+		t.Fatalf("batches = %+v, want the failed one and its retry", batches)
+	}
+	retry := batches[1]
+	if len(retry) != 2 || retry[0].Seq != 1 || retry[0].Data != "first\n" || retry[1].Seq != 2 || retry[1].Data != "second\n" {
+		t.Fatalf("retry = %+v, want the failed chunk followed by the new one", retry)
+	}
+}
+
+func TestStreamStopsWhenRejected(t *testing.T) {
+	r := &recorder{err: fmt.Errorf("403 Forbidden: %w", ErrRejected)}
+	stream := New("task-1", r.send, time.Hour)
+	stdout := stream.Writer(models.LogStreamStdout)
+
+	fmt.Fprint(stdout, "first\n")
+	stream.flush()
+	fmt.Fprint(stdout, "second\n")
+	stream.Close()
+
+	if batches := r.sent(); len(batches) != 1 {
+		t.Fatalf("batches = %+v, want nothing sent after the rejection", batches)
+	}
+}
+
+func TestStreamDropsOutputPastTheLimitAndSaysSo(t *testing.T) {
+	r := &recorder{}
+	stream := New("task-1", r.send, time.Hour)
+	// Keep the background loop from flushing while the buffer fills
+	stream.mu.Lock()
+	stream.size = maxPendingBytes
+	stream.mu.Unlock()
+
+	fmt.Fprint(stream.Writer(models.LogStreamStdout), "lost\n")
+	stream.Close()
+
+	batches := r.sent()
+	if len(batches) != 1 || len(batches[0]) != 1 {
+		t.Fatalf("batches = %+v, want just the dropped note", batches)
+	}
+	note := batches[0][0]
+	if note.Stream != models.LogStreamStderr || note.Data != "[parity-runner: 5 bytes of output dropped]\n" {
+		t.Errorf("note = %+v", note)
+	}
+}
@@ -0,0 +1,72 @@
+package manifest
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/signer"
+)
+
+// Sign sets the wallet address of the key on the manifest and signs it
+func Sign(manifest *models.RunnerManifest, key *ecdsa.PrivateKey) error {
+	if key == nil {
+		return fmt.Errorf("runner signing key is required")
+	}
+	return SignWith(manifest, signer.FromKey(key))
+}
+
+// SignWith sets the wallet address of the signer on the manifest and signs it
+func SignWith(manifest *models.RunnerManifest, s signer.Signer) error {
+	if s == nil {
+		return fmt.Errorf("runner signer is required")
+	}
+
+	manifest.WalletAddress = s.Address().Hex()
+
+	digest, err := digestOf(manifest)
+	if err != nil {
+		return err
+	}
+
+	signature, err := s.SignDigest(digest)
+	if err != nil {
+		return fmt.Errorf("failed to sign manifest: %w", err)
+	}
+
+	manifest.Signature = hexutil.Encode(signature)
+	return nil
+}
+
+// Verify checks that the manifest was signed by its wallet address
+func Verify(manifest *models.RunnerManifest) error {
+	if manifest.Signature == "" {
+		return fmt.Errorf("manifest is not signed")
+	}
+	if manifest.Version != models.ManifestVersion {
+		return fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+
+	digest, err := digestOf(manifest)
+	if err != nil {
+		return err
+	}
+	if err := signer.Verify(digest, manifest.Signature, manifest.WalletAddress); err != nil {
+		return fmt.Errorf("invalid manifest signature: %w", err)
+	}
+	return nil
+}
+
+func digestOf(manifest *models.RunnerManifest) ([]byte, error) {
+	unsigned := *manifest
+	unsigned.Signature = ""
+	payload, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest payload: %w", err)
+	}
+	return crypto.Keccak256(payload), nil
+}
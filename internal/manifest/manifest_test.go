@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	manifest := &models.RunnerManifest{
+		Version:      models.ManifestVersion,
+		DeviceID:     "device-1",
+		TaskTypes:    []models.TaskType{models.TaskTypeDocker, models.TaskTypeLLM},
+		Models:       []models.ModelCapability{{ModelName: "llama3:8b", IsLoaded: true}},
+		Hardware:     models.RunnerHardware{CPUs: 8, MemoryBytes: 16 << 30, Runtime: "docker"},
+		MinRewards:   models.MinRewards{{Type: models.TaskTypeLLM, Model: "llama3:8b", Reward: 0.05}},
+		Availability: models.RunnerAvailability{Accepting: true, Slots: 2},
+		IssuedAt:     time.Now().UTC().Truncate(time.Second),
+	}
+	if err := Sign(manifest, key); err != nil {
+		t.Fatalf("failed to sign manifest: %v", err)
+	}
+	if manifest.WalletAddress != crypto.PubkeyToAddress(key.PublicKey).Hex() {
+		t.Fatalf("wallet address = %s", manifest.WalletAddress)
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received models.RunnerManifest
+	if err := json.Unmarshal(data, &received); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(&received); err != nil {
+		t.Fatalf("expected signed manifest to verify: %v", err)
+	}
+
+	received.Availability.Slots = 8
+	if err := Verify(&received); err == nil {
+		t.Fatal("expected tampered manifest to fail verification")
+	}
+
+	received = *manifest
+	other, _ := crypto.GenerateKey()
+	received.WalletAddress = crypto.PubkeyToAddress(other.PublicKey).Hex()
+	if err := Verify(&received); err == nil {
+		t.Fatal("expected manifest claiming another wallet to fail verification")
+	}
+
+	received = *manifest
+	received.Signature = ""
+	if err := Verify(&received); err == nil {
+		t.Fatal("expected unsigned manifest to fail verification")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/go-co-op/gocron"
 	"github.com/theblitlabs/gologger"
 
+	"github.com/theblitlabs/parity-runner/internal/chaos"
 	"github.com/theblitlabs/parity-runner/internal/core/models"
 	"github.com/theblitlabs/parity-runner/internal/core/ports"
 	"github.com/theblitlabs/parity-runner/internal/utils"
@@ -26,6 +27,9 @@ type HeartbeatConfig struct {
 	MaxBackoff    time.Duration
 	BaseBackoff   time.Duration
 	MaxRetries    int
+	// GPUs is the GPU inventory reported so the server can route GPU tasks
+	GPUs  []models.GPUInfo
+	Chaos *chaos.Injector
 }
 
 type HeartbeatService struct {
@@ -35,12 +39,17 @@ type HeartbeatService struct {
 	started             bool
 	startTime           time.Time
 	statusProvider      ports.TaskHandler
-	metricsProvider     ports.MetricsProvider
+	metricsProvider     ports.HostMetricsProvider
+	fleet               ports.FleetMember
 	job                 *gocron.Job
 	consecutiveFailures int
+	// lastRun is when the heartbeat job last ran, successful or not
+	lastRun  time.Time
+	health   func() []models.SubsystemHealth
+	manifest ports.ManifestProvider
 }
 
-func NewHeartbeatService(config HeartbeatConfig, statusProvider ports.TaskHandler, metricsProvider ports.MetricsProvider) *HeartbeatService {
+func NewHeartbeatService(config HeartbeatConfig, statusProvider ports.TaskHandler, metricsProvider ports.HostMetricsProvider) *HeartbeatService {
 	return &HeartbeatService{
 		config:              config,
 		scheduler:           gocron.NewScheduler(time.UTC),
@@ -58,6 +67,7 @@ func (h *HeartbeatService) Start() error {
 		return nil
 	}
 	h.started = true
+	h.lastRun = time.Now()
 	h.mu.Unlock()
 
 	log := gologger.WithComponent("heartbeat")
@@ -90,6 +100,15 @@ func (h *HeartbeatService) heartbeatTask() {
 
 	isProcessing := h.statusProvider.IsProcessing()
 
+	h.mu.Lock()
+	h.lastRun = time.Now()
+	delay := h.config.Chaos.HeartbeatDelay()
+	h.mu.Unlock()
+	if delay > 0 {
+		log.Debug().Dur("delay", delay).Msg("Chaos: delaying heartbeat")
+		time.Sleep(delay)
+	}
+
 	if err := h.sendHeartbeatWithRetry(); err != nil {
 		h.mu.Lock()
 		h.consecutiveFailures++
@@ -141,6 +160,17 @@ func (h *HeartbeatService) heartbeatTask() {
 	}
 }
 
+// SendNow sends a heartbeat right away instead of waiting for the next one,
+// so the server learns of a change of status such as draining
+func (h *HeartbeatService) SendNow() {
+	go func() {
+		if err := h.sendHeartbeatWithRetry(); err != nil {
+			log := gologger.WithComponent("heartbeat")
+			log.Warn().Err(err).Msg("Failed to send heartbeat")
+		}
+	}()
+}
+
 func (h *HeartbeatService) sendHeartbeatWithRetry() error {
 	var lastErr error
 	for attempt := 1; attempt <= h.config.MaxRetries; attempt++ {
@@ -160,31 +190,43 @@ func (h *HeartbeatService) sendHeartbeatWithRetry() error {
 func (h *HeartbeatService) sendHeartbeat() error {
 	log := gologger.WithComponent("heartbeat")
 
-	type HeartbeatPayload struct {
-		WalletAddress string              `json:"wallet_address"`
-		Status        models.RunnerStatus `json:"status"`
-		Timestamp     int64               `json:"timestamp"`
-		Uptime        int64               `json:"uptime"`
-		Memory        int64               `json:"memory_usage"`
-		CPU           float64             `json:"cpu_usage"`
-		PublicIP      string              `json:"public_ip,omitempty"`
-	}
-
 	status := models.RunnerStatusOnline
 	if h.statusProvider.IsProcessing() {
 		status = models.RunnerStatusBusy
 	}
 
-	memory, cpu := h.metricsProvider.GetSystemMetrics()
+	metrics := h.metricsProvider.GetHostMetrics()
+
+	h.mu.Lock()
+	gpus := h.config.GPUs
+	fleet := h.fleet
+	health := h.health
+	manifest := h.manifest
+	h.mu.Unlock()
 
-	payload := HeartbeatPayload{
+	payload := models.Heartbeat{
 		WalletAddress: h.config.WalletAddress,
 		Status:        status,
 		Timestamp:     time.Now().Unix(),
 		Uptime:        int64(time.Since(h.startTime).Seconds()),
-		Memory:        memory,
-		CPU:           cpu,
 		PublicIP:      utils.GetWebhookURL(),
+		GPUs:          gpus,
+		HostMetrics:   metrics,
+	}
+	if fleet != nil {
+		settings := fleet.FleetSettings()
+		payload.Settings = &settings
+	}
+	if health != nil {
+		payload.Subsystems = health()
+	}
+	if manifest != nil {
+		// A runner that cannot sign still heartbeats, the server keeps its last manifest
+		signed, err := manifest.Manifest()
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to build runner manifest")
+		}
+		payload.Manifest = signed
 	}
 
 	payloadBytes, err := json.Marshal(payload)
@@ -226,11 +268,23 @@ func (h *HeartbeatService) sendHeartbeat() error {
 		return fmt.Errorf("heartbeat request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
+	if fleet != nil {
+		var reply struct {
+			Directive *models.FleetDirective `json:"directive"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil && err != io.EOF {
+			log.Warn().Err(err).Msg("Failed to decode heartbeat response")
+		} else if reply.Directive != nil {
+			fleet.ApplyFleetDirective(*reply.Directive)
+		}
+	}
+
 	log.Debug().
 		Str("device_id", h.config.DeviceID).
 		Str("status", string(status)).
-		Float64("cpu", cpu).
-		Int64("memory", memory).
+		Float64("cpu", metrics.CPUUsage).
+		Int64("memory", metrics.MemoryUsage).
+		Float64("load", metrics.Load1).
 		Msg("Heartbeat sent successfully")
 
 	return nil
@@ -254,6 +308,46 @@ func (h *HeartbeatService) Stop() {
 	log.Info().Msg("Heartbeat service stopped successfully")
 }
 
+// Healthy returns why heartbeats are not being sent. The job counts as stalled
+// once it has not run for three intervals, or three times the maximum backoff
+// while failing.
+func (h *HeartbeatService) Healthy() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.started {
+		return fmt.Errorf("heartbeat service is not running")
+	}
+	if !h.scheduler.IsRunning() {
+		return fmt.Errorf("heartbeat scheduler stopped")
+	}
+	stalled := 3 * max(h.config.BaseInterval, h.config.MaxBackoff)
+	if since := time.Since(h.lastRun); since > stalled {
+		return fmt.Errorf("no heartbeat for %s", since.Truncate(time.Second))
+	}
+	return nil
+}
+
+// Restart replaces the scheduler and starts sending heartbeats again
+func (h *HeartbeatService) Restart() error {
+	h.mu.Lock()
+	h.scheduler.Stop()
+	h.scheduler = gocron.NewScheduler(time.UTC)
+	h.job = nil
+	h.consecutiveFailures = 0
+	h.started = false
+	h.mu.Unlock()
+
+	return h.Start()
+}
+
+// SetHealthProvider reports the health of the runner's subsystems in heartbeats
+func (h *HeartbeatService) SetHealthProvider(health func() []models.SubsystemHealth) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.health = health
+}
+
 func (h *HeartbeatService) SetInterval(interval time.Duration) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -265,28 +359,48 @@ func (h *HeartbeatService) SetInterval(interval time.Duration) {
 	}
 }
 
+// SetGPUs replaces the GPU inventory sent with the next heartbeats
+func (h *HeartbeatService) SetGPUs(gpus []models.GPUInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.config.GPUs = gpus
+}
+
+// SetManifestProvider sends the runner's manifest with every heartbeat, so the
+// server sees its availability change
+func (h *HeartbeatService) SetManifestProvider(provider ports.ManifestProvider) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.manifest = provider
+}
+
+// SetFleetMember reports the runner's settings in heartbeats and hands fleet
+// directives from the server to member
+func (h *HeartbeatService) SetFleetMember(member ports.FleetMember) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fleet = member
+}
+
+// SetChaos installs the fault injector that delays heartbeats
+func (h *HeartbeatService) SetChaos(injector *chaos.Injector) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.config.Chaos = injector
+}
+
 func (h *HeartbeatService) SendOfflineHeartbeat(ctx context.Context) error {
 	log := gologger.WithComponent("heartbeat")
 	log.Info().Msg("Sending final offline heartbeat...")
 
-	type HeartbeatPayload struct {
-		WalletAddress string              `json:"wallet_address"`
-		Status        models.RunnerStatus `json:"status"`
-		Timestamp     int64               `json:"timestamp"`
-		Uptime        int64               `json:"uptime"`
-		Memory        int64               `json:"memory_usage"`
-		CPU           float64             `json:"cpu_usage"`
-	}
-
-	memory, cpu := h.metricsProvider.GetSystemMetrics()
+	metrics := h.metricsProvider.GetHostMetrics()
 
-	payload := HeartbeatPayload{
+	payload := models.Heartbeat{
 		WalletAddress: h.config.WalletAddress,
 		Status:        models.RunnerStatusOffline,
 		Timestamp:     time.Now().Unix(),
 		Uptime:        int64(time.Since(h.startTime).Seconds()),
-		Memory:        memory,
-		CPU:           cpu,
+		HostMetrics:   metrics,
 	}
 
 	payloadBytes, err := json.Marshal(payload)
@@ -0,0 +1,523 @@
+package socket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/chaos"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/core/ports"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/messaging/heartbeat"
+	"github.com/theblitlabs/parity-runner/internal/messaging/webhook"
+	"github.com/theblitlabs/parity-runner/internal/provenance"
+	"github.com/theblitlabs/parity-runner/internal/sysmetrics"
+)
+
+// Path is where the server accepts runner WebSocket connections
+const Path = "/api/v1/runners/ws"
+
+// Dispatcher handles task notifications, shared with the webhook client so a
+// task is never run twice when the runner switches modes
+type Dispatcher interface {
+	Dispatch(message webhook.WebhookMessage) (webhook.DispatchResult, error)
+}
+
+// Config configures the connection. PongWait is how long the connection may stay
+// silent before it is considered dropped; pings are sent at 9/10 of it.
+type Config struct {
+	ServerURL     string
+	DeviceID      string
+	WalletAddress string
+	AcceptLabels  string
+	GPUs          []models.GPUInfo
+	Chaos         *chaos.Injector
+	// SandboxBenchmark is published when the client registers
+	SandboxBenchmark *models.SandboxBenchmark
+	// Capabilities is the profile published when the client registers
+	Capabilities *models.CapabilityProfile
+	// Benchmark is the signed hardware benchmark published when the client
+	// registers
+	Benchmark *models.BenchmarkReport
+	// SettlementChainID is the chain rewards should be paid on, zero for the
+	// server's default chain
+	SettlementChainID int64
+	// ServerIdentity, when set, drops messages not signed by the pinned server
+	ServerIdentity    *identity.Verifier
+	PongWait          time.Duration
+	WriteWait         time.Duration
+	MaxMessageSize    int64
+	ReconnectAttempts int
+	ReconnectBackoff  time.Duration
+	MaxBackoff        time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PongWait:          60 * time.Second,
+		WriteWait:         10 * time.Second,
+		MaxMessageSize:    4 << 20,
+		ReconnectAttempts: 5,
+		ReconnectBackoff:  time.Second,
+		MaxBackoff:        30 * time.Second,
+	}
+}
+
+type taskAck struct {
+	TaskID string `json:"task_id"`
+	webhook.DispatchResult
+}
+
+// SocketClient keeps an outbound WebSocket connection to the server and receives
+// task notifications and control messages over it, so the runner needs no
+// inbound port or tunnel. When the connection drops and cannot be restored it
+// hands over to the fallback, normally webhook mode.
+type SocketClient struct {
+	config       Config
+	dispatcher   Dispatcher
+	heartbeat    *heartbeat.HeartbeatService
+	conn         *websocket.Conn
+	writeMu      sync.Mutex
+	mu           sync.Mutex
+	started      bool
+	stopChan     chan struct{}
+	done         chan struct{}
+	controls     map[string]func(payload json.RawMessage)
+	onFallback   func(err error)
+	capabilities []webhook.ModelCapabilityInfo
+	manifest     ports.ManifestProvider
+}
+
+func NewSocketClient(config Config, dispatcher Dispatcher, handler ports.TaskHandler) *SocketClient {
+	defaults := DefaultConfig()
+	if config.PongWait <= 0 {
+		config.PongWait = defaults.PongWait
+	}
+	if config.WriteWait <= 0 {
+		config.WriteWait = defaults.WriteWait
+	}
+	if config.MaxMessageSize <= 0 {
+		config.MaxMessageSize = defaults.MaxMessageSize
+	}
+	if config.ReconnectAttempts < 0 {
+		config.ReconnectAttempts = 0
+	}
+	if config.ReconnectBackoff <= 0 {
+		config.ReconnectBackoff = defaults.ReconnectBackoff
+	}
+	if config.MaxBackoff < config.ReconnectBackoff {
+		config.MaxBackoff = config.ReconnectBackoff
+	}
+
+	client := &SocketClient{
+		config:     config,
+		dispatcher: dispatcher,
+		controls:   make(map[string]func(payload json.RawMessage)),
+	}
+	if handler != nil {
+		client.heartbeat = heartbeat.NewHeartbeatService(heartbeat.HeartbeatConfig{
+			ServerURL:     config.ServerURL,
+			DeviceID:      config.DeviceID,
+			WalletAddress: config.WalletAddress,
+			BaseInterval:  30 * time.Second,
+			MaxBackoff:    1 * time.Minute,
+			BaseBackoff:   5 * time.Second,
+			MaxRetries:    3,
+			GPUs:          config.GPUs,
+			Chaos:         config.Chaos,
+		}, handler, sysmetrics.NewCollector(""))
+	}
+	return client
+}
+
+// OnControl registers the handler for a control message type, e.g. abort_task
+func (c *SocketClient) OnControl(messageType string, fn func(payload json.RawMessage)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.controls[messageType] = fn
+}
+
+// OnFallback is called once when the connection is lost for good. The client is
+// stopped by then and does not reconnect.
+func (c *SocketClient) OnFallback(fn func(err error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onFallback = fn
+}
+
+func (c *SocketClient) SetModelCapabilities(capabilities []webhook.ModelCapabilityInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capabilities = capabilities
+}
+
+func (c *SocketClient) SetHeartbeatInterval(interval time.Duration) {
+	if c.heartbeat != nil {
+		c.heartbeat.SetInterval(interval)
+	}
+}
+
+// SendHeartbeat reports the runner's status right away
+func (c *SocketClient) SendHeartbeat() {
+	if c.heartbeat != nil {
+		c.heartbeat.SendNow()
+	}
+}
+
+// SetFleetMember lets the server manage the runner's settings through heartbeats
+func (c *SocketClient) SetFleetMember(member ports.FleetMember) {
+	if c.heartbeat != nil {
+		c.heartbeat.SetFleetMember(member)
+	}
+}
+
+// SetManifestProvider sends the runner's manifest when it registers and with
+// every heartbeat
+func (c *SocketClient) SetManifestProvider(provider ports.ManifestProvider) {
+	c.mu.Lock()
+	c.manifest = provider
+	c.mu.Unlock()
+
+	if c.heartbeat != nil {
+		c.heartbeat.SetManifestProvider(provider)
+	}
+}
+
+// Start connects to the server. An error means the server cannot be reached
+// over WebSocket and the caller should use webhook mode instead.
+func (c *SocketClient) Start() error {
+	c.mu.Lock()
+	if c.started {
+		c.mu.Unlock()
+		return nil
+	}
+	c.mu.Unlock()
+
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.started = true
+	c.conn = conn
+	c.stopChan = make(chan struct{})
+	c.done = make(chan struct{})
+	c.mu.Unlock()
+
+	if c.heartbeat != nil {
+		if err := c.heartbeat.Start(); err != nil {
+			log := gologger.WithComponent("socket")
+			log.Error().Err(err).Msg("Failed to start heartbeat service")
+		}
+	}
+
+	go c.run(conn)
+	return nil
+}
+
+func (c *SocketClient) Stop() error {
+	c.mu.Lock()
+	if !c.started {
+		c.mu.Unlock()
+		return nil
+	}
+	c.started = false
+	close(c.stopChan)
+	conn, done := c.conn, c.done
+	c.mu.Unlock()
+
+	log := gologger.WithComponent("socket")
+
+	if c.heartbeat != nil {
+		c.heartbeat.Stop()
+	}
+
+	c.writeMu.Lock()
+	_ = conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, "runner stopping"),
+		time.Now().Add(c.config.WriteWait))
+	c.writeMu.Unlock()
+	conn.Close()
+
+	<-done
+	log.Info().Msg("WebSocket client stopped")
+	return nil
+}
+
+func (c *SocketClient) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.started
+}
+
+// URL turns the server URL into its WebSocket endpoint
+func URL(serverURL string) (string, error) {
+	parsed, err := url.Parse(strings.TrimRight(serverURL, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL: %w", err)
+	}
+	switch parsed.Scheme {
+	case "http", "ws":
+		parsed.Scheme = "ws"
+	case "https", "wss":
+		parsed.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("unsupported server URL scheme %q", parsed.Scheme)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/api/v1") + Path
+	return parsed.String(), nil
+}
+
+func (c *SocketClient) connect() (*websocket.Conn, error) {
+	log := gologger.WithComponent("socket")
+
+	endpoint, err := URL(c.config.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("X-Device-ID", c.config.DeviceID)
+
+	dialer := websocket.Dialer{HandshakeTimeout: c.config.WriteWait}
+	conn, resp, err := dialer.Dial(endpoint, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("websocket handshake failed with status %d: %w", resp.StatusCode, err)
+		}
+		return nil, fmt.Errorf("failed to connect to %s: %w", endpoint, err)
+	}
+	conn.SetReadLimit(c.config.MaxMessageSize)
+
+	c.mu.Lock()
+	capabilities := append([]webhook.ModelCapabilityInfo{}, c.capabilities...)
+	provider := c.manifest
+	c.mu.Unlock()
+
+	var manifest *models.RunnerManifest
+	if provider != nil {
+		if manifest, err = provider.Manifest(); err != nil {
+			log.Warn().Err(err).Msg("Registering without a runner manifest")
+		}
+	}
+
+	payload, err := json.Marshal(models.RunnerRegistration{
+		WalletAddress:     c.config.WalletAddress,
+		Status:            models.RunnerStatusOnline,
+		ModelCapabilities: capabilities,
+		AcceptLabels:      c.config.AcceptLabels,
+		SandboxBenchmark:  c.config.SandboxBenchmark,
+		Benchmark:         c.config.Benchmark,
+		Capabilities:      c.config.Capabilities,
+		Manifest:          manifest,
+		SettlementChainID: c.config.SettlementChainID,
+		Build:             provenance.Current(),
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to marshal register payload: %w", err)
+	}
+	if err := c.write(conn, webhook.WebhookMessage{Type: "register", Payload: payload}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to register over websocket: %w", err)
+	}
+
+	log.Info().Str("url", endpoint).Str("device_id", c.config.DeviceID).Msg("Connected to server over WebSocket")
+	return conn, nil
+}
+
+func (c *SocketClient) write(conn *websocket.Conn, message webhook.WebhookMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := conn.SetWriteDeadline(time.Now().Add(c.config.WriteWait)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(message)
+}
+
+// run serves connections until the client is stopped, reconnecting with
+// exponential backoff and falling back once the attempts are used up
+func (c *SocketClient) run(conn *websocket.Conn) {
+	log := gologger.WithComponent("socket")
+	defer close(c.done)
+
+	for {
+		err := c.serve(conn)
+		if c.stopping() {
+			return
+		}
+		log.Warn().Err(err).Msg("WebSocket connection lost, reconnecting")
+
+		conn, err = c.reconnect()
+		if err != nil {
+			c.fallback(err)
+			return
+		}
+	}
+}
+
+func (c *SocketClient) reconnect() (*websocket.Conn, error) {
+	log := gologger.WithComponent("socket")
+
+	backoff := c.config.ReconnectBackoff
+	lastErr := errors.New("no reconnect attempts configured")
+	for attempt := 1; attempt <= c.config.ReconnectAttempts; attempt++ {
+		select {
+		case <-c.stopChan:
+			return nil, errors.New("client stopped")
+		case <-time.After(backoff):
+		}
+
+		conn, err := c.connect()
+		if err == nil {
+			c.mu.Lock()
+			if !c.started {
+				c.mu.Unlock()
+				conn.Close()
+				return nil, errors.New("client stopped")
+			}
+			c.conn = conn
+			c.mu.Unlock()
+			return conn, nil
+		}
+
+		lastErr = err
+		log.Debug().Err(err).Int("attempt", attempt).Dur("backoff", backoff).Msg("WebSocket reconnect failed")
+		backoff *= 2
+		if backoff > c.config.MaxBackoff {
+			backoff = c.config.MaxBackoff
+		}
+	}
+	return nil, fmt.Errorf("websocket reconnect failed after %d attempts: %w", c.config.ReconnectAttempts, lastErr)
+}
+
+func (c *SocketClient) fallback(err error) {
+	log := gologger.WithComponent("socket")
+
+	c.mu.Lock()
+	c.started = false
+	fallback := c.onFallback
+	c.mu.Unlock()
+
+	if c.heartbeat != nil {
+		c.heartbeat.Stop()
+	}
+
+	log.Warn().Err(err).Msg("WebSocket connection could not be restored")
+	if fallback != nil {
+		fallback(err)
+	}
+}
+
+func (c *SocketClient) stopping() bool {
+	select {
+	case <-c.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// serve reads messages until the connection fails, keeping it alive with pings
+func (c *SocketClient) serve(conn *websocket.Conn) error {
+	log := gologger.WithComponent("socket")
+	defer conn.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(c.config.PongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(c.config.PongWait))
+	})
+
+	pingDone := make(chan struct{})
+	defer close(pingDone)
+	go func() {
+		ticker := time.NewTicker(c.config.PongWait * 9 / 10)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pingDone:
+				return
+			case <-ticker.C:
+				c.writeMu.Lock()
+				err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.config.WriteWait))
+				c.writeMu.Unlock()
+				if err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var message webhook.WebhookMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				log.Warn().Err(err).Msg("Ignoring malformed WebSocket message")
+				continue
+			}
+			return err
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(c.config.PongWait))
+		c.handleMessage(conn, message)
+	}
+}
+
+func (c *SocketClient) handleMessage(conn *websocket.Conn, message webhook.WebhookMessage) {
+	log := gologger.WithComponent("socket")
+
+	if verifier := c.config.ServerIdentity; verifier != nil {
+		body := identity.MessageBody(message.Type, message.Payload)
+		if err := verifier.Verify(identity.PurposeMessage, body, message.Timestamp, message.Signature); err != nil {
+			log.Warn().Err(err).Str("type", message.Type).Msg("Dropping WebSocket message not signed by the pinned server identity")
+			return
+		}
+	}
+
+	if message.Type == "available_tasks" {
+		var ref struct {
+			ID string `json:"id"`
+		}
+		_ = json.Unmarshal(message.Payload, &ref)
+
+		if c.config.Chaos.DropDelivery() {
+			log.Warn().Str("task_id", ref.ID).Msg("Chaos: dropping task delivery")
+			return
+		}
+
+		result, err := c.dispatcher.Dispatch(message)
+		if err != nil {
+			result = webhook.DispatchResult{Status: "invalid", Reason: err.Error()}
+		}
+
+		payload, err := json.Marshal(taskAck{TaskID: ref.ID, DispatchResult: result})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode task acknowledgement")
+			return
+		}
+		if err := c.write(conn, webhook.WebhookMessage{Type: "task_ack", Payload: payload}); err != nil {
+			log.Error().Err(err).Str("task_id", ref.ID).Msg("Failed to acknowledge task")
+		}
+		return
+	}
+
+	c.mu.Lock()
+	handler, ok := c.controls[message.Type]
+	c.mu.Unlock()
+	if !ok {
+		log.Warn().Str("type", message.Type).Msg("Unknown WebSocket message type")
+		return
+	}
+	handler(message.Payload)
+}
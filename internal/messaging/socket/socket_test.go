@@ -0,0 +1,180 @@
+package socket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/theblitlabs/parity-runner/internal/messaging/webhook"
+)
+
+type recordingDispatcher struct {
+	mu       sync.Mutex
+	messages []webhook.WebhookMessage
+}
+
+func (d *recordingDispatcher) Dispatch(message webhook.WebhookMessage) (webhook.DispatchResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.messages = append(d.messages, message)
+	return webhook.DispatchResult{Status: webhook.DispatchAccepted}, nil
+}
+
+// testServer upgrades runner connections and hands each one to the test. Setting
+// the returned flag makes it refuse new connections.
+func testServer(t *testing.T) (*httptest.Server, chan *websocket.Conn, *atomic.Bool) {
+	t.Helper()
+
+	conns := make(chan *websocket.Conn, 4)
+	down := &atomic.Bool{}
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() || r.URL.Path != Path || r.Header.Get("X-Device-ID") != "device-1" {
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+	return server, conns, down
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) webhook.WebhookMessage {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var message webhook.WebhookMessage
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("failed to read message from runner: %v", err)
+	}
+	return message
+}
+
+func TestSocketClientDispatchesTasksAndControls(t *testing.T) {
+	server, conns, _ := testServer(t)
+	dispatcher := &recordingDispatcher{}
+
+	config := DefaultConfig()
+	config.ServerURL = server.URL + "/api/v1"
+	config.DeviceID = "device-1"
+	config.WalletAddress = "0xabc"
+	client := NewSocketClient(config, dispatcher, nil)
+
+	aborted := make(chan string, 1)
+	client.OnControl("abort_task", func(payload json.RawMessage) {
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		_ = json.Unmarshal(payload, &req)
+		aborted <- req.Reason
+	})
+
+	if err := client.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer client.Stop()
+
+	conn := <-conns
+	if register := readMessage(t, conn); register.Type != "register" {
+		t.Fatalf("first message type = %q, want register", register.Type)
+	}
+
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":    "available_tasks",
+		"payload": map[string]string{"id": "task-1"},
+	}); err != nil {
+		t.Fatalf("failed to send task: %v", err)
+	}
+
+	ack := readMessage(t, conn)
+	var payload struct {
+		TaskID string `json:"task_id"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(ack.Payload, &payload); err != nil || ack.Type != "task_ack" {
+		t.Fatalf("ack = %s %s, err %v", ack.Type, ack.Payload, err)
+	}
+	if payload.TaskID != "task-1" || payload.Status != webhook.DispatchAccepted {
+		t.Fatalf("ack payload = %+v", payload)
+	}
+
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":    "abort_task",
+		"payload": map[string]string{"reason": "cancelled"},
+	}); err != nil {
+		t.Fatalf("failed to send control message: %v", err)
+	}
+	select {
+	case reason := <-aborted:
+		if reason != "cancelled" {
+			t.Fatalf("abort reason = %q", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("abort_task control message was not handled")
+	}
+}
+
+func TestSocketClientReconnectsThenFallsBack(t *testing.T) {
+	server, conns, down := testServer(t)
+
+	config := DefaultConfig()
+	config.ServerURL = server.URL
+	config.DeviceID = "device-1"
+	config.ReconnectAttempts = 2
+	config.ReconnectBackoff = 10 * time.Millisecond
+	client := NewSocketClient(config, &recordingDispatcher{}, nil)
+
+	fellBack := make(chan error, 1)
+	client.OnFallback(func(err error) { fellBack <- err })
+
+	if err := client.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// A dropped connection is restored while the server is reachable
+	(<-conns).Close()
+	var conn *websocket.Conn
+	select {
+	case conn = <-conns:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not reconnect")
+	}
+
+	// Once the server is gone the client gives up and falls back
+	down.Store(true)
+	conn.Close()
+	select {
+	case err := <-fellBack:
+		if err == nil {
+			t.Fatal("fallback called without an error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client did not fall back")
+	}
+	if client.Connected() {
+		t.Fatal("Connected() = true after falling back")
+	}
+}
+
+func TestURL(t *testing.T) {
+	for serverURL, want := range map[string]string{
+		"http://localhost:8080":         "ws://localhost:8080" + Path,
+		"https://api.parity.io/api/v1/": "wss://api.parity.io" + Path,
+	} {
+		if got, err := URL(serverURL); err != nil || got != want {
+			t.Errorf("URL(%q) = %q, %v; want %q", serverURL, got, err, want)
+		}
+	}
+	if _, err := URL("ftp://example.com"); err == nil {
+		t.Error("URL() accepted an ftp server URL")
+	}
+}
@@ -3,25 +3,41 @@ package webhook
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/theblitlabs/gologger"
 
+	"github.com/theblitlabs/parity-runner/internal/bidding"
+	"github.com/theblitlabs/parity-runner/internal/chaos"
 	"github.com/theblitlabs/parity-runner/internal/core/models"
 	"github.com/theblitlabs/parity-runner/internal/core/ports"
+	"github.com/theblitlabs/parity-runner/internal/energy"
+	executiontask "github.com/theblitlabs/parity-runner/internal/execution/task"
+	"github.com/theblitlabs/parity-runner/internal/identity"
 	"github.com/theblitlabs/parity-runner/internal/messaging/heartbeat"
+	"github.com/theblitlabs/parity-runner/internal/provenance"
+	"github.com/theblitlabs/parity-runner/internal/sysmetrics"
 	"github.com/theblitlabs/parity-runner/internal/utils"
 )
 
 type WebhookMessage struct {
 	Type    string          `json:"type"`
 	Payload json.RawMessage `json:"payload"`
+	// Timestamp and Signature sign messages that arrive without HTTP headers,
+	// i.e. over the WebSocket
+	Timestamp string `json:"timestamp,omitempty"`
+	Signature string `json:"signature,omitempty"`
 }
 
 type WebhookClient struct {
@@ -42,14 +58,37 @@ type WebhookClient struct {
 	completedTasksLock sync.RWMutex
 	heartbeat          *heartbeat.HeartbeatService
 	modelCapabilities  []ModelCapabilityInfo
+	sandboxBenchmark   *models.SandboxBenchmark
+	capabilities       *models.CapabilityProfile
+	benchmark          *models.BenchmarkReport
+	settlementChainID  int64
+	energyScheduler    *energy.Scheduler
+	bidder             *bidding.Bidder
 	activeTaskID       string
+	labelSelector      models.LabelSelector
+	policy             models.RunnerPolicy
+	gpus               []models.GPUInfo
+	vmIsolation        bool
+	tee                models.TEETechnology
+	chaos              *chaos.Injector
+	pool               *executiontask.Pool
+	serverIdentity     *identity.Verifier
+	manifest           ports.ManifestProvider
+	// randomize serves the webhook on a random path that requires a bearer token,
+	// both regenerated on every registration
+	randomize    bool
+	webhookPath  string
+	webhookToken string
+	// serveErr is why the webhook server stopped serving on its own
+	serveErr error
+	// onResultEncodings receives the result encodings the server advertised on
+	// every registration
+	onResultEncodings func([]string)
+	// draining answers every task as busy while the runner drains
+	draining bool
 }
 
-type ModelCapabilityInfo struct {
-	ModelName string `json:"model_name"`
-	IsLoaded  bool   `json:"is_loaded"`
-	MaxTokens int    `json:"max_tokens"`
-}
+type ModelCapabilityInfo = models.ModelCapability
 
 func NewWebhookClient(serverURL string, serverPort int, handler ports.TaskHandler, runnerID, deviceID, walletAddress string) *WebhookClient {
 	client := &WebhookClient{
@@ -75,7 +114,7 @@ func NewWebhookClient(serverURL string, serverPort int, handler ports.TaskHandle
 		MaxRetries:    3,
 	}
 
-	client.heartbeat = heartbeat.NewHeartbeatService(heartbeatConfig, handler, &defaultMetricsProvider{})
+	client.heartbeat = heartbeat.NewHeartbeatService(heartbeatConfig, handler, sysmetrics.NewCollector(""))
 	return client
 }
 
@@ -107,14 +146,6 @@ func (w *WebhookClient) Start() error {
 		return fmt.Errorf("webhook registration failed: %w", err)
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/webhook", w.handleWebhook)
-
-	w.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", w.serverPort),
-		Handler: mux,
-	}
-
 	log.Debug().Str("port", fmt.Sprintf("%d", w.serverPort)).Msg("Starting webhook server")
 
 	if w.heartbeat != nil {
@@ -123,15 +154,89 @@ func (w *WebhookClient) Start() error {
 		}
 	}
 
+	return w.serve()
+}
+
+// serve starts a new webhook server on the webhook port
+func (w *WebhookClient) serve() error {
+	log := gologger.WithComponent("webhook")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(utils.DefaultWebhookPath, w.serveWebhook)
+	mux.HandleFunc(utils.DefaultWebhookPath+"/", w.serveWebhook)
+	mux.HandleFunc(models.ManifestPath, w.serveManifest)
+	mux.HandleFunc(models.VersionPath, w.serveVersion)
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", w.serverPort))
+	if err != nil {
+		return fmt.Errorf("webhook port %d is not available: %w", w.serverPort, err)
+	}
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", w.serverPort),
+		Handler: mux,
+	}
+
+	w.mu.Lock()
+	w.server = server
+	w.serveErr = nil
+	w.mu.Unlock()
+
 	go func() {
-		if err := w.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Error().Err(err).Msg("Webhook server error")
+		err := server.Serve(ln)
+		if err == http.ErrServerClosed {
+			return
 		}
+		log.Error().Err(err).Msg("Webhook server error")
+
+		w.mu.Lock()
+		if w.server == server {
+			w.serveErr = err
+		}
+		w.mu.Unlock()
 	}()
+	return nil
+}
 
+// Healthy returns why the webhook server stopped serving
+func (w *WebhookClient) Healthy() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.started {
+		return fmt.Errorf("webhook client is not running")
+	}
+	if w.serveErr != nil {
+		return fmt.Errorf("webhook server stopped: %w", w.serveErr)
+	}
 	return nil
 }
 
+// Restart replaces the webhook server and registers again, so the server
+// learns the current webhook URL. The heartbeat is left running.
+func (w *WebhookClient) Restart() error {
+	w.mu.Lock()
+	server := w.server
+	w.mu.Unlock()
+
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_ = server.Shutdown(ctx)
+		cancel()
+	}
+	if err := w.serve(); err != nil {
+		return err
+	}
+	if err := w.Register(); err != nil {
+		return fmt.Errorf("webhook registration failed: %w", err)
+	}
+	return nil
+}
+
+// Heartbeat returns the heartbeat service the webhook client runs
+func (w *WebhookClient) Heartbeat() *heartbeat.HeartbeatService {
+	return w.heartbeat
+}
+
 func (w *WebhookClient) Stop() error {
 	w.mu.Lock()
 	if !w.started {
@@ -160,8 +265,11 @@ func (w *WebhookClient) Stop() error {
 		log.Warn().Err(err).Msg("Failed to unregister webhook")
 	}
 
-	if w.server != nil {
-		if err := w.server.Shutdown(ctx); err != nil {
+	w.mu.Lock()
+	server := w.server
+	w.mu.Unlock()
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
 			log.Error().Err(err).Msg("Server shutdown error")
 			return fmt.Errorf("server shutdown error: %w", err)
 		}
@@ -245,6 +353,14 @@ func (w *WebhookClient) markTaskCompleted(taskID string) {
 	w.completedTasks[taskID] = time.Now()
 }
 
+// forgetTask drops a task the runner will not run after all from the tasks it
+// keeps across restarts
+func (w *WebhookClient) forgetTask(taskID string) {
+	if recorder, ok := w.handler.(taskRecorder); ok {
+		recorder.ForgetTask(taskID)
+	}
+}
+
 func (w *WebhookClient) releaseTask(taskID string) {
 	w.completedTasksLock.Lock()
 	defer w.completedTasksLock.Unlock()
@@ -254,7 +370,9 @@ func (w *WebhookClient) releaseTask(taskID string) {
 	delete(w.completedTasks, taskID)
 }
 
-func (w *WebhookClient) tryStartTask(taskID string) (bool, bool, string) {
+// tryStartTask marks the task in progress. Without a worker pool only one task
+// may be active at a time.
+func (w *WebhookClient) tryStartTask(taskID string, exclusive bool) (bool, bool, string) {
 	w.completedTasksLock.Lock()
 	defer w.completedTasksLock.Unlock()
 
@@ -262,15 +380,105 @@ func (w *WebhookClient) tryStartTask(taskID string) (bool, bool, string) {
 		return false, true, ""
 	}
 
-	if w.activeTaskID != "" && w.activeTaskID != taskID {
-		return false, false, w.activeTaskID
+	if exclusive {
+		if w.activeTaskID != "" && w.activeTaskID != taskID {
+			return false, false, w.activeTaskID
+		}
+		w.activeTaskID = taskID
 	}
 
-	w.activeTaskID = taskID
 	w.completedTasks[taskID] = time.Time{}
 	return true, false, ""
 }
 
+// SetWorkerPool runs accepted tasks through the pool, so several can execute at
+// once and further ones queue until resources free up
+func (w *WebhookClient) SetWorkerPool(pool *executiontask.Pool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pool = pool
+}
+
+func (w *WebhookClient) workerPool() *executiontask.Pool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.pool
+}
+
+// SetRandomizedEndpoint makes every registration use a fresh random webhook path
+// and a bearer capability token the server must present
+func (w *WebhookClient) SetRandomizedEndpoint(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.randomize = enabled
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// nextEndpoint picks the path and token announced in the next registration.
+// The webhook keeps serving the current ones until the server accepts them.
+func (w *WebhookClient) nextEndpoint() (string, string, error) {
+	w.mu.Lock()
+	randomize := w.randomize
+	w.mu.Unlock()
+
+	if !randomize {
+		return utils.DefaultWebhookPath, "", nil
+	}
+
+	suffix, err := randomHex(16)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate webhook path: %w", err)
+	}
+	token, err := randomHex(32)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate webhook token: %w", err)
+	}
+	return utils.DefaultWebhookPath + "/" + suffix, token, nil
+}
+
+// useEndpoint switches the webhook to the path and token the server registered
+func (w *WebhookClient) useEndpoint(path, token string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.webhookPath = path
+	w.webhookToken = token
+}
+
+// serveWebhook only passes requests for the registered path carrying the
+// registered token on to handleWebhook. Everything else looks like a missing page.
+func (w *WebhookClient) serveWebhook(resp http.ResponseWriter, req *http.Request) {
+	w.mu.Lock()
+	path, token := w.webhookPath, w.webhookToken
+	w.mu.Unlock()
+
+	if path == "" {
+		path = utils.DefaultWebhookPath
+	}
+	if req.URL.Path != path {
+		http.NotFound(resp, req)
+		return
+	}
+
+	if token != "" {
+		presented, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			log := gologger.WithComponent("webhook")
+			log.Warn().Str("remote_addr", req.RemoteAddr).Msg("Rejected webhook request without a valid capability token")
+			http.Error(resp, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	w.handleWebhook(resp, req)
+}
+
 func (w *WebhookClient) handleWebhook(resp http.ResponseWriter, req *http.Request) {
 	log := gologger.WithComponent("webhook")
 
@@ -303,6 +511,14 @@ func (w *WebhookClient) handleWebhook(resp http.ResponseWriter, req *http.Reques
 		log.Debug().Str("body_preview", preview).Msg("Webhook request body preview")
 	}
 
+	if verifier := w.serverVerifier(); verifier != nil {
+		if err := verifier.VerifyWebhook(req.Header, w.deviceID, reqBody); err != nil {
+			log.Warn().Err(err).Str("remote_addr", req.RemoteAddr).Msg("Rejected webhook request not signed by the pinned server identity")
+			http.Error(resp, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
 	req.Body = io.NopCloser(bytes.NewBuffer(reqBody))
 
 	var message WebhookMessage
@@ -312,89 +528,298 @@ func (w *WebhookClient) handleWebhook(resp http.ResponseWriter, req *http.Reques
 		return
 	}
 
+	if message.Type == "available_tasks" && w.chaosInjector().DropDelivery() {
+		log.Warn().Msg("Chaos: dropping webhook delivery")
+		http.Error(resp, "Service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	result, err := w.Dispatch(message)
+	if err != nil {
+		http.Error(resp, "Invalid task payload", http.StatusBadRequest)
+		return
+	}
+	if result.Status == DispatchBusy {
+		http.Error(resp, `{"status":"busy"}`, http.StatusConflict)
+		return
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode webhook response")
+		return
+	}
+	resp.WriteHeader(http.StatusOK)
+	if _, err := resp.Write(body); err != nil {
+		log.Error().Err(err).Msg("Failed to write response")
+	}
+}
+
+const (
+	DispatchAccepted = "ok"
+	DispatchSkipped  = "skipped"
+	DispatchBusy     = "busy"
+)
+
+// taskAborter is implemented by task handlers that can stop a running task
+type taskAborter interface {
+	AbortTask(taskID, reason string) bool
+}
+
+// taskCanceller is implemented by task handlers that can stop a running task
+// its creator cancelled
+type taskCanceller interface {
+	CancelTask(taskID, reason string) bool
+}
+
+// taskRecorder is implemented by task handlers that keep the tasks the runner
+// accepted across restarts
+type taskRecorder interface {
+	RecordAccepted(task *models.Task)
+	ForgetTask(taskID string)
+}
+
+// DispatchResult is the runner's answer to a task notification. Tasks refused
+// by the runner policy name the rule that refused them.
+type DispatchResult struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	Rule   string `json:"rule,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Dispatch handles a message from the server however it was delivered. Tasks
+// are started in the background, so it returns as soon as a task is accepted.
+func (w *WebhookClient) Dispatch(message WebhookMessage) (DispatchResult, error) {
+	log := gologger.WithComponent("webhook")
+
 	switch message.Type {
 	case "available_tasks":
 		var task *models.Task
 		if err := json.Unmarshal(message.Payload, &task); err != nil {
 			log.Error().Err(err).Msg("Failed to parse task from webhook payload")
-			http.Error(resp, "Invalid task payload", http.StatusBadRequest)
-			return
+			return DispatchResult{}, fmt.Errorf("invalid task payload: %w", err)
 		}
 
-		if task != nil {
-			log.Debug().Int("count", 1).Msg("Task received via webhook")
+		if task == nil {
+			log.Warn().Msg("Received empty tasks array in webhook")
+			return DispatchResult{Status: DispatchAccepted}, nil
+		}
 
-			taskID := task.ID.String()
+		log.Debug().Int("count", 1).Msg("Task received via webhook")
 
-			if w.isTaskCompleted(taskID) {
-				log.Debug().
-					Str("id", taskID).
-					Str("type", string(task.Type)).
-					Msg("Skipping already completed or in-progress task")
-				resp.WriteHeader(http.StatusOK)
-				if _, err := resp.Write([]byte(`{"status":"skipped"}`)); err != nil {
-					log.Error().Err(err).Msg("Failed to write response")
-				}
-				return
+		taskID := task.ID.String()
+
+		if w.isDraining() {
+			log.Debug().Str("id", taskID).Msg("Runner is draining, rejecting task notification")
+			return DispatchResult{Status: DispatchBusy}, nil
+		}
+
+		if !w.acceptsLabels(task.Labels) {
+			log.Debug().
+				Str("id", taskID).
+				Str("selector", w.labelSelector.String()).
+				Interface("labels", task.Labels).
+				Msg("Task labels do not match runner selector, skipping")
+			return DispatchResult{Status: DispatchSkipped, Reason: "label_mismatch"}, nil
+		}
+
+		if err := w.checkPolicy(task); err != nil {
+			log.Info().Err(err).
+				Str("id", taskID).
+				Str("creator", task.CreatorAddress).
+				Msg("Task rejected by runner policy, skipping")
+			result := DispatchResult{Status: DispatchSkipped, Reason: "policy", Detail: err.Error()}
+			var violation *models.PolicyViolation
+			if errors.As(err, &violation) {
+				result.Rule = string(violation.Rule)
+				result.Detail = violation.Detail
 			}
+			return result, nil
+		}
+
+		if task.RequiresVM() && !w.hasVMIsolation() {
+			log.Info().
+				Str("id", taskID).
+				Msg("Task needs VM isolation this runner does not offer, skipping")
+			return DispatchResult{Status: DispatchSkipped, Reason: "isolation_unavailable"}, nil
+		}
 
-			started, duplicate, activeTaskID := w.tryStartTask(taskID)
-			if !started && duplicate {
+		if task.RequiresTEE() && !w.teeTechnology().Runs(task.Type) {
+			log.Info().
+				Str("id", taskID).
+				Str("type", string(task.Type)).
+				Msg("Task needs a trusted execution environment this runner does not offer, skipping")
+			return DispatchResult{Status: DispatchSkipped, Reason: "isolation_unavailable"}, nil
+		}
+
+		if task.GPU != nil && !w.hasGPUsFor(task) {
+			log.Info().
+				Str("id", taskID).
+				Int("gpus", task.GPU.Count).
+				Str("gpu_model", task.GPU.Model).
+				Msg("Task needs GPUs this runner does not have, skipping")
+			return DispatchResult{Status: DispatchSkipped, Reason: "insufficient_gpus"}, nil
+		}
+
+		if task.Requirements != nil && !w.meetsRequirements(task) {
+			log.Info().
+				Str("id", taskID).
+				Msg("Task needs more capacity than this runner offers, skipping")
+			return DispatchResult{Status: DispatchSkipped, Reason: "insufficient_capabilities"}, nil
+		}
+
+		if estimate, declined := w.uneconomical(task); declined {
+			log.Info().
+				Str("id", taskID).
+				Float64("reward_value", estimate.Value).
+				Float64("energy_cost", estimate.Cost).
+				Float64("price_per_kwh", estimate.Price).
+				Msg("Task pays less than its electricity at peak prices, skipping")
+			return DispatchResult{Status: DispatchSkipped, Reason: "uneconomical"}, nil
+		}
+
+		quote, bid := w.quote(task)
+		if bid && !quote.Covered(task) {
+			log.Info().
+				Str("id", taskID).
+				Float64("reward", task.Reward).
+				Float64("bid", quote.Price).
+				Msg("Task pays less than this runner's price, skipping")
+			return DispatchResult{Status: DispatchSkipped, Reason: "bid_exceeds_reward"}, nil
+		}
+
+		if w.isTaskCompleted(taskID) {
+			log.Debug().
+				Str("id", taskID).
+				Str("type", string(task.Type)).
+				Msg("Skipping already completed or in-progress task")
+			return DispatchResult{Status: DispatchSkipped}, nil
+		}
+
+		pool := w.workerPool()
+		started, duplicate, activeTaskID := w.tryStartTask(taskID, pool == nil)
+		if !started && duplicate {
+			log.Debug().
+				Str("id", taskID).
+				Str("type", string(task.Type)).
+				Msg("Task is already being processed")
+			return DispatchResult{Status: DispatchSkipped}, nil
+		}
+
+		if !started {
+			log.Warn().
+				Str("id", taskID).
+				Str("active_task_id", activeTaskID).
+				Msg("Runner is busy, rejecting task notification")
+			return DispatchResult{Status: DispatchBusy}, nil
+		}
+
+		log.Debug().
+			Str("id", taskID).
+			Str("title", task.Title).
+			Str("type", string(task.Type)).
+			Float64("reward", task.Reward).
+			Str("network", utils.Network()).
+			Msg("Processing task from webhook")
+
+		// Process task asynchronously so the server gets an answer immediately
+		run := func() {
+			if bid && !w.winAuction(task, quote) {
+				w.releaseTask(taskID)
+				w.forgetTask(taskID)
+				return
+			}
+			began := time.Now()
+			if err := w.handler.HandleTask(task); err != nil {
+				w.releaseTask(taskID)
+				log.Error().Err(err).
+					Str("id", taskID).
+					Str("type", string(task.Type)).
+					Float64("reward", task.Reward).
+					Msg("Task processing failed")
+			} else {
+				w.markTaskCompleted(taskID)
+				w.observeDuration(task, time.Since(began))
 				log.Debug().
 					Str("id", taskID).
 					Str("type", string(task.Type)).
-					Msg("Task is already being processed")
-				resp.WriteHeader(http.StatusOK)
-				if _, err := resp.Write([]byte(`{"status":"skipped"}`)); err != nil {
-					log.Error().Err(err).Msg("Failed to write response")
-				}
-				return
+					Msg("Task processed successfully")
 			}
+		}
 
-			if !started {
-				log.Warn().
-					Str("id", taskID).
-					Str("active_task_id", activeTaskID).
-					Msg("Runner is busy, rejecting task notification")
-				http.Error(resp, `{"status":"busy"}`, http.StatusConflict)
-				return
+		if recorder, ok := w.handler.(taskRecorder); ok {
+			recorder.RecordAccepted(task)
+		}
+		if pool == nil {
+			go run()
+			break
+		}
+		if err := pool.Submit(task, run); err != nil {
+			w.releaseTask(taskID)
+			w.forgetTask(taskID)
+			switch {
+			case errors.Is(err, executiontask.ErrQueueFull):
+				log.Warn().Str("id", taskID).Msg("Worker pool is full, rejecting task notification")
+				return DispatchResult{Status: DispatchBusy}, nil
+			case errors.Is(err, executiontask.ErrExceedsCapacity):
+				log.Warn().Err(err).Str("id", taskID).Msg("Task does not fit in this runner, skipping")
+				return DispatchResult{Status: DispatchSkipped, Reason: "insufficient_resources"}, nil
+			default:
+				log.Warn().Err(err).Str("id", taskID).Msg("Task could not be queued, skipping")
+				return DispatchResult{Status: DispatchSkipped, Reason: "not_queued"}, nil
 			}
-
-			log.Debug().
-				Str("id", taskID).
-				Str("title", task.Title).
-				Str("type", string(task.Type)).
-				Float64("reward", task.Reward).
-				Msg("Processing task from webhook")
-
-			// Process task asynchronously so webhook responds immediately
-			go func() {
-				if err := w.handler.HandleTask(task); err != nil {
-					w.releaseTask(taskID)
-					log.Error().Err(err).
-						Str("id", taskID).
-						Str("type", string(task.Type)).
-						Float64("reward", task.Reward).
-						Msg("Task processing failed")
-				} else {
-					w.markTaskCompleted(taskID)
-					log.Debug().
-						Str("id", taskID).
-						Str("type", string(task.Type)).
-						Msg("Task processed successfully")
-				}
-			}()
+		}
+	case "quarantine":
+		var notice struct {
+			DeviceID         string    `json:"device_id"`
+			Reason           string    `json:"reason"`
+			ProbationEnds    time.Time `json:"probation_ends"`
+			CanariesRequired int       `json:"canaries_required"`
+		}
+		if err := json.Unmarshal(message.Payload, &notice); err != nil {
+			return DispatchResult{}, fmt.Errorf("invalid quarantine payload: %w", err)
+		}
+		log.Error().
+			Str("reason", notice.Reason).
+			Time("probation_ends", notice.ProbationEnds).
+			Int("canaries_required", notice.CanariesRequired).
+			Str("appeal", "POST "+w.serverURL+"/api/v1/quarantine/"+notice.DeviceID+"/appeal").
+			Msg("Runner quarantined for divergent results; only verification tasks will be offered until probation is passed")
+	case "abort_task":
+		var req struct {
+			TaskID string `json:"task_id"`
+			Reason string `json:"reason"`
+		}
+		if err := json.Unmarshal(message.Payload, &req); err != nil || req.TaskID == "" {
+			return DispatchResult{}, errors.New("invalid abort_task payload")
+		}
+		aborter, ok := w.handler.(taskAborter)
+		if ok && aborter.AbortTask(req.TaskID, req.Reason) {
+			log.Info().Str("task_id", req.TaskID).Str("reason", req.Reason).Msg("Aborted task on server request")
+		}
+	case "cancel":
+		var req struct {
+			TaskID string `json:"task_id"`
+			Reason string `json:"reason"`
+		}
+		if err := json.Unmarshal(message.Payload, &req); err != nil || req.TaskID == "" {
+			return DispatchResult{}, errors.New("invalid cancel payload")
+		}
+		if req.Reason == "" {
+			req.Reason = "cancelled by the task creator"
+		}
+		canceller, ok := w.handler.(taskCanceller)
+		if ok && canceller.CancelTask(req.TaskID, req.Reason) {
+			log.Info().Str("task_id", req.TaskID).Str("reason", req.Reason).Msg("Cancelled task on server request")
 		} else {
-			log.Warn().Msg("Received empty tasks array in webhook")
+			log.Debug().Str("task_id", req.TaskID).Msg("Cancelled task is not running here")
 		}
 	default:
 		log.Warn().Str("type", message.Type).Msg("Unknown webhook message type")
 	}
 
-	resp.WriteHeader(http.StatusOK)
-	if _, err := resp.Write([]byte(`{"status":"ok"}`)); err != nil {
-		log.Error().Err(err).Msg("Failed to write response")
-	}
+	return DispatchResult{Status: DispatchAccepted}, nil
 }
 
 func (w *WebhookClient) SetModelCapabilities(capabilities []ModelCapabilityInfo) {
@@ -403,29 +828,362 @@ func (w *WebhookClient) SetModelCapabilities(capabilities []ModelCapabilityInfo)
 	w.modelCapabilities = capabilities
 }
 
-func (w *WebhookClient) Register() error {
+// SetSandboxBenchmark publishes the container overhead measured on this runner
+// when it registers
+func (w *WebhookClient) SetSandboxBenchmark(result *models.SandboxBenchmark) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sandboxBenchmark = result
+}
+
+// SetCapabilities publishes the capability profile measured on this runner when
+// it registers
+func (w *WebhookClient) SetCapabilities(profile *models.CapabilityProfile) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.capabilities = profile
+}
+
+// SetBenchmark publishes the signed report of `parity-runner benchmark` when the
+// runner registers
+func (w *WebhookClient) SetBenchmark(report *models.BenchmarkReport) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.benchmark = report
+}
+
+// SetSettlementChain asks the server to pay this runner's rewards on chainID,
+// zero for the server's default chain
+func (w *WebhookClient) SetSettlementChain(chainID int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.settlementChainID = chainID
+}
+
+func (w *WebhookClient) SetLabelSelector(selector models.LabelSelector) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.labelSelector = selector
+}
+
+// SetDraining makes the runner answer busy to every task, so the server sends
+// them elsewhere while the runner finishes what it has before exiting
+func (w *WebhookClient) SetDraining(draining bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.draining = draining
+}
+
+func (w *WebhookClient) isDraining() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.draining
+}
+
+// SetPolicy skips tasks of types or from creators the operator does not accept
+func (w *WebhookClient) SetPolicy(policy models.RunnerPolicy) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.policy = policy
+}
+
+func (w *WebhookClient) checkPolicy(task *models.Task) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.policy.Check(task)
+}
+
+// SetGPUs records the runner's GPUs, reported in heartbeats and used to skip
+// tasks whose GPU requirements the runner cannot meet
+func (w *WebhookClient) SetGPUs(gpus []models.GPUInfo) {
+	w.mu.Lock()
+	w.gpus = gpus
+	w.mu.Unlock()
+
+	if w.heartbeat != nil {
+		w.heartbeat.SetGPUs(gpus)
+	}
+}
+
+// SetVMIsolation records whether the runner can run tasks that ask for VM
+// isolation. Those tasks are skipped otherwise.
+func (w *WebhookClient) SetVMIsolation(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.vmIsolation = enabled
+}
+
+func (w *WebhookClient) hasVMIsolation() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.vmIsolation
+}
+
+// SetTEE records the trusted execution environment the runner runs in. Tasks
+// that ask for TEE isolation are skipped unless their type runs inside it.
+func (w *WebhookClient) SetTEE(technology models.TEETechnology) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tee = technology
+}
+
+func (w *WebhookClient) teeTechnology() models.TEETechnology {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.tee
+}
+
+// SetFleetMember lets the server manage the runner's settings through heartbeats
+func (w *WebhookClient) SetFleetMember(member ports.FleetMember) {
+	if w.heartbeat != nil {
+		w.heartbeat.SetFleetMember(member)
+	}
+}
+
+// SetManifestProvider publishes the runner's manifest on ManifestPath and sends
+// it with registrations and heartbeats
+func (w *WebhookClient) SetManifestProvider(provider ports.ManifestProvider) {
+	w.mu.Lock()
+	w.manifest = provider
+	w.mu.Unlock()
+
+	if w.heartbeat != nil {
+		w.heartbeat.SetManifestProvider(provider)
+	}
+}
+
+func (w *WebhookClient) serveManifest(resp http.ResponseWriter, req *http.Request) {
+	log := gologger.WithComponent("webhook")
+
+	if req.Method != http.MethodGet {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.mu.Lock()
+	provider := w.manifest
+	w.mu.Unlock()
+	if provider == nil {
+		http.Error(resp, "Manifest not available", http.StatusNotFound)
+		return
+	}
+
+	manifest, err := provider.Manifest()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build runner manifest")
+		http.Error(resp, "Failed to build manifest", http.StatusInternalServerError)
+		return
+	}
+	if manifest == nil {
+		http.Error(resp, "Manifest not available", http.StatusNotFound)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(resp).Encode(manifest); err != nil {
+		log.Debug().Err(err).Msg("Failed to write runner manifest")
+	}
+}
+
+// serveVersion answers with the build provenance of the runner
+func (w *WebhookClient) serveVersion(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(resp, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(resp).Encode(provenance.Current()); err != nil {
+		log := gologger.WithComponent("webhook")
+		log.Debug().Err(err).Msg("Failed to write runner version")
+	}
+}
+
+// SetChaos installs the fault injector that drops task deliveries and delays heartbeats
+// SetResultEncodingsHandler is called with the result encodings the server
+// advertises whenever the runner registers, or nil for servers that advertise
+// none
+func (w *WebhookClient) SetResultEncodingsHandler(fn func([]string)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onResultEncodings = fn
+}
+
+func (w *WebhookClient) SetChaos(injector *chaos.Injector) {
+	w.mu.Lock()
+	w.chaos = injector
+	w.mu.Unlock()
+
+	if w.heartbeat != nil {
+		w.heartbeat.SetChaos(injector)
+	}
+}
+
+// SetServerVerifier makes the webhook reject deliveries that were not signed by
+// the pinned server identity
+func (w *WebhookClient) SetServerVerifier(verifier *identity.Verifier) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.serverIdentity = verifier
+}
+
+func (w *WebhookClient) serverVerifier() *identity.Verifier {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.serverIdentity
+}
+
+func (w *WebhookClient) chaosInjector() *chaos.Injector {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.chaos
+}
+
+func (w *WebhookClient) hasGPUsFor(task *models.Task) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return task.GPU.SatisfiedBy(w.gpus)
+}
+
+// SetEnergyScheduler skips tasks that pay less than the electricity they would
+// use while power is at its peak price
+func (w *WebhookClient) SetEnergyScheduler(scheduler *energy.Scheduler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.energyScheduler = scheduler
+}
+
+// uneconomical prices the task's electricity. Tasks are taken when the price
+// cannot be found.
+func (w *WebhookClient) uneconomical(task *models.Task) (energy.Estimate, bool) {
+	w.mu.Lock()
+	scheduler := w.energyScheduler
+	w.mu.Unlock()
+	if scheduler == nil {
+		return energy.Estimate{}, false
+	}
+
+	estimate, declined, err := scheduler.Declines(context.Background(), task, time.Now())
+	if err != nil {
+		log := gologger.WithComponent("webhook")
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to price task electricity, taking the task")
+		return estimate, false
+	}
+	return estimate, declined
+}
+
+func (w *WebhookClient) observeDuration(task *models.Task, took time.Duration) {
+	w.mu.Lock()
+	scheduler := w.energyScheduler
+	w.mu.Unlock()
+	if scheduler != nil {
+		scheduler.Observe(task, took)
+	}
+}
+
+// SetBidder makes the runner bid its price on each task and only run the
+// tasks the server awards it
+func (w *WebhookClient) SetBidder(bidder *bidding.Bidder) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.bidder = bidder
+}
+
+// quote prices the task when the runner bids, false when it does not
+func (w *WebhookClient) quote(task *models.Task) (bidding.Quote, bool) {
+	w.mu.Lock()
+	bidder := w.bidder
+	w.mu.Unlock()
+	if bidder == nil {
+		return bidding.Quote{}, false
+	}
+
+	quote, err := bidder.Quote(task)
+	if err != nil {
+		log := gologger.WithComponent("webhook")
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to price task, claiming it without a bid")
+		return bidding.Quote{}, false
+	}
+	return quote, true
+}
+
+// winAuction bids the quoted price and reports whether the runner may run the
+// task
+func (w *WebhookClient) winAuction(task *models.Task, quote bidding.Quote) bool {
+	w.mu.Lock()
+	bidder := w.bidder
+	w.mu.Unlock()
 	log := gologger.WithComponent("webhook")
 
-	w.webhookURL = utils.GetWebhookURL()
-	log.Debug().Str("webhook_url", w.webhookURL).Msg("Generated webhook URL")
+	status, won, err := bidder.Win(context.Background(), task, quote)
+	switch {
+	case err != nil:
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Bidding on task failed, leaving it")
+	case !won:
+		log.Info().
+			Str("id", task.ID.String()).
+			Float64("bid", quote.Price).
+			Float64("winning_bid", status.WinningPrice).
+			Msg("Outbid on task")
+	case status.State == models.BidWon:
+		log.Info().Str("id", task.ID.String()).Float64("bid", quote.Price).Msg("Won task auction")
+	}
+	return won
+}
+
+func (w *WebhookClient) meetsRequirements(task *models.Task) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return task.Requirements.SatisfiedBy(w.capabilities)
+}
 
-	type RegisterPayload struct {
-		WalletAddress     string                `json:"wallet_address"`
-		Status            models.RunnerStatus   `json:"status"`
-		Webhook           string                `json:"webhook"`
-		ModelCapabilities []ModelCapabilityInfo `json:"model_capabilities,omitempty"`
+func (w *WebhookClient) acceptsLabels(labels models.Labels) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.labelSelector.Matches(labels)
+}
+
+func (w *WebhookClient) Register() error {
+	log := gologger.WithComponent("webhook")
+
+	webhookPath, webhookToken, err := w.nextEndpoint()
+	if err != nil {
+		return err
 	}
 
 	w.mu.Lock()
 	capabilities := make([]ModelCapabilityInfo, len(w.modelCapabilities))
 	copy(capabilities, w.modelCapabilities)
+	acceptLabels := w.labelSelector.String()
+	sandboxBenchmark := w.sandboxBenchmark
+	capabilityProfile := w.capabilities
+	benchmarkReport := w.benchmark
+	settlementChainID := w.settlementChainID
+	provider := w.manifest
 	w.mu.Unlock()
 
-	payload := RegisterPayload{
+	var manifest *models.RunnerManifest
+	if provider != nil {
+		var err error
+		if manifest, err = provider.Manifest(); err != nil {
+			log.Warn().Err(err).Msg("Registering without a runner manifest")
+		}
+	}
+
+	webhookURL := utils.GetWebhookURLForPath(webhookPath)
+	log.Debug().Str("webhook_url", webhookURL).Msg("Generated webhook URL")
+
+	payload := models.RunnerRegistration{
 		WalletAddress:     w.walletAddress,
 		Status:            models.RunnerStatusOnline,
-		Webhook:           w.webhookURL,
+		Webhook:           webhookURL,
+		WebhookToken:      webhookToken,
 		ModelCapabilities: capabilities,
+		AcceptLabels:      acceptLabels,
+		SandboxBenchmark:  sandboxBenchmark,
+		Capabilities:      capabilityProfile,
+		Benchmark:         benchmarkReport,
+		Manifest:          manifest,
+		SettlementChainID: settlementChainID,
+		Build:             provenance.Current(),
 	}
 
 	registerURL := fmt.Sprintf("%s/api/v1/runners", w.serverURL)
@@ -438,9 +1196,9 @@ func (w *WebhookClient) Register() error {
 		Str("device_id", w.deviceID).
 		Str("wallet_address", w.walletAddress).
 		Str("url", registerURL).
-		Str("webhook_url", w.webhookURL).
+		Str("webhook_url", webhookURL).
 		Int("model_count", len(capabilities)).
-		RawJSON("payload", payloadBytes).
+		Bool("capability_token", webhookToken != "").
 		Msg("Registration payload")
 
 	req, err := http.NewRequest("POST", registerURL, bytes.NewBuffer(payloadBytes))
@@ -466,6 +1224,10 @@ func (w *WebhookClient) Register() error {
 		return fmt.Errorf("register request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
+	// The server now delivers to the new endpoint, so the old one stops working
+	w.useEndpoint(webhookPath, webhookToken)
+	w.webhookURL = webhookURL
+
 	var response map[string]json.RawMessage
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return fmt.Errorf("failed to decode register response: %w", err)
@@ -481,19 +1243,35 @@ func (w *WebhookClient) Register() error {
 		}
 	}
 
+	var resultEncodings []string
+	if rawEncodings, ok := response["result_encodings"]; ok {
+		_ = json.Unmarshal(rawEncodings, &resultEncodings)
+	}
+	var acceptedChainID int64
+	if rawChainID, ok := response["settlement_chain_id"]; ok {
+		_ = json.Unmarshal(rawChainID, &acceptedChainID)
+		if settlementChainID != 0 && acceptedChainID != settlementChainID {
+			log.Warn().
+				Int64("requested_chain_id", settlementChainID).
+				Msg("Server does not pay rewards on the selected chain, they will settle on its default chain")
+		}
+	}
+
+	w.mu.Lock()
+	onResultEncodings := w.onResultEncodings
+	w.mu.Unlock()
+	if onResultEncodings != nil {
+		onResultEncodings(resultEncodings)
+	}
+
 	log.Debug().
 		Str("device_id", w.deviceID).
 		Str("webhook_url", w.webhookURL).
 		Str("webhook_id", w.webhookID).
+		Strs("result_encodings", resultEncodings).
 		Int("status_code", resp.StatusCode).
 		Int("model_count", len(capabilities)).
 		Msg("Runner registered successfully with server")
 
 	return nil
 }
-
-type defaultMetricsProvider struct{}
-
-func (p *defaultMetricsProvider) GetSystemMetrics() (int64, float64) {
-	return 0, 0.0
-}
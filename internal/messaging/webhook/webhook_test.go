@@ -6,12 +6,18 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/google/uuid"
+	"github.com/theblitlabs/parity-runner/internal/bidding"
 	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/energy"
+	executiontask "github.com/theblitlabs/parity-runner/internal/execution/task"
+	"github.com/theblitlabs/parity-runner/internal/identity"
 )
 
 type blockingTaskHandler struct {
@@ -143,3 +149,460 @@ func TestHandleWebhookReleasesTaskAfterFailure(t *testing.T) {
 
 	t.Fatal("expected failed task to be released for future retry")
 }
+
+func TestHandleWebhookSkipsTaskWithMismatchedLabels(t *testing.T) {
+	handler := &blockingTaskHandler{
+		started: make(chan string, 1),
+		release: make(chan struct{}),
+	}
+	defer close(handler.release)
+
+	selector, err := models.ParseLabelSelector("team=ml, tier!=experimental")
+	if err != nil {
+		t.Fatalf("failed to parse selector: %v", err)
+	}
+
+	client := &WebhookClient{
+		handler:         handler,
+		completedTasks:  make(map[string]time.Time),
+		lastCleanupTime: time.Now(),
+		labelSelector:   selector,
+	}
+
+	task := makeWebhookTask(uuid.New(), "experimental")
+	task.Labels = models.Labels{"team": "ml", "tier": "experimental"}
+
+	resp := performWebhookRequest(t, client, task)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("response code = %d, want %d", resp.Code, http.StatusOK)
+	}
+
+	if !bytes.Contains(resp.Body.Bytes(), []byte("label_mismatch")) {
+		t.Fatalf("expected label_mismatch response, got %s", resp.Body.String())
+	}
+
+	if client.activeTaskID != "" || client.isTaskCompleted(task.ID.String()) {
+		t.Fatal("expected mismatched task not to be claimed")
+	}
+
+	select {
+	case id := <-handler.started:
+		t.Fatalf("handler should not have been invoked, got task %s", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHandleWebhookReportsPolicyRule(t *testing.T) {
+	handler := &blockingTaskHandler{
+		started: make(chan string, 1),
+		release: make(chan struct{}),
+	}
+	defer close(handler.release)
+
+	client := &WebhookClient{
+		handler:         handler,
+		completedTasks:  make(map[string]time.Time),
+		lastCleanupTime: time.Now(),
+	}
+	client.SetPolicy(models.RunnerPolicy{MaxDuration: time.Hour})
+
+	task := makeWebhookTask(uuid.New(), "long")
+	task.MaxDurationSecs = 7200
+	resp := performWebhookRequest(t, client, task)
+
+	var result DispatchResult
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid response %s: %v", resp.Body.String(), err)
+	}
+	if result.Status != DispatchSkipped || result.Reason != "policy" || result.Rule != string(models.PolicyRuleMaxDuration) || result.Detail == "" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	select {
+	case id := <-handler.started:
+		t.Fatalf("handler should not have been invoked, got task %s", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHandleWebhookSkipsVMTaskWithoutVMIsolation(t *testing.T) {
+	handler := &blockingTaskHandler{
+		started: make(chan string, 1),
+		release: make(chan struct{}),
+	}
+	defer close(handler.release)
+
+	client := &WebhookClient{
+		handler:         handler,
+		completedTasks:  make(map[string]time.Time),
+		lastCleanupTime: time.Now(),
+	}
+
+	task := makeWebhookTask(uuid.New(), "sensitive")
+	task.IsolationLevel = models.IsolationVM
+
+	resp := performWebhookRequest(t, client, task)
+	if !bytes.Contains(resp.Body.Bytes(), []byte("isolation_unavailable")) {
+		t.Fatalf("expected isolation_unavailable response, got %s", resp.Body.String())
+	}
+
+	client.SetVMIsolation(true)
+	resp = performWebhookRequest(t, client, task)
+	if bytes.Contains(resp.Body.Bytes(), []byte("isolation_unavailable")) {
+		t.Fatalf("expected the task to be accepted with VM isolation, got %s", resp.Body.String())
+	}
+	select {
+	case <-handler.started:
+	case <-time.After(time.Second):
+		t.Fatal("handler was not invoked")
+	}
+}
+
+func TestHandleWebhookSkipsTaskBeyondCapabilities(t *testing.T) {
+	handler := &blockingTaskHandler{
+		started: make(chan string, 1),
+		release: make(chan struct{}),
+	}
+	defer close(handler.release)
+
+	client := &WebhookClient{
+		handler:         handler,
+		completedTasks:  make(map[string]time.Time),
+		lastCleanupTime: time.Now(),
+	}
+	client.SetCapabilities(&models.CapabilityProfile{CPUCores: 4, MemoryBytes: 8 << 30})
+
+	task := makeWebhookTask(uuid.New(), "large")
+	task.Requirements = &models.CapabilityRequirements{MinCPUCores: 8}
+
+	resp := performWebhookRequest(t, client, task)
+	if !bytes.Contains(resp.Body.Bytes(), []byte("insufficient_capabilities")) {
+		t.Fatalf("expected insufficient_capabilities response, got %s", resp.Body.String())
+	}
+
+	client.SetCapabilities(&models.CapabilityProfile{CPUCores: 16, MemoryBytes: 32 << 30})
+	resp = performWebhookRequest(t, client, task)
+	if bytes.Contains(resp.Body.Bytes(), []byte("insufficient_capabilities")) {
+		t.Fatalf("expected the task to be accepted, got %s", resp.Body.String())
+	}
+	select {
+	case <-handler.started:
+	case <-time.After(time.Second):
+		t.Fatal("handler was not invoked")
+	}
+}
+
+func TestHandleWebhookSkipsUneconomicalTask(t *testing.T) {
+	handler := &blockingTaskHandler{
+		started: make(chan string, 1),
+		release: make(chan struct{}),
+	}
+	defer close(handler.release)
+
+	client := &WebhookClient{
+		handler:         handler,
+		completedTasks:  make(map[string]time.Time),
+		lastCleanupTime: time.Now(),
+	}
+	client.SetEnergyScheduler(energy.NewScheduler(energy.Config{
+		Prices:     energy.Tariff{Default: 0.5},
+		TokenPrice: 1,
+		TaskEnergy: energy.TaskEnergy{"docker": 1},
+	}))
+
+	task := makeWebhookTask(uuid.New(), "cheap")
+	task.Reward = 0.1
+	resp := performWebhookRequest(t, client, task)
+	if !bytes.Contains(resp.Body.Bytes(), []byte("uneconomical")) {
+		t.Fatalf("expected uneconomical response, got %s", resp.Body.String())
+	}
+
+	task.Reward = 1
+	resp = performWebhookRequest(t, client, task)
+	if bytes.Contains(resp.Body.Bytes(), []byte("uneconomical")) {
+		t.Fatalf("expected the better paid task to be accepted, got %s", resp.Body.String())
+	}
+	select {
+	case <-handler.started:
+	case <-time.After(time.Second):
+		t.Fatal("handler was not invoked")
+	}
+}
+
+func TestHandleWebhookRunsOnlyAwardedTasks(t *testing.T) {
+	handler := &blockingTaskHandler{
+		started: make(chan string, 1),
+		release: make(chan struct{}),
+	}
+	defer close(handler.release)
+
+	won := uuid.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := models.BidLost
+		if strings.Contains(r.URL.Path, won.String()) {
+			state = models.BidWon
+		}
+		_ = json.NewEncoder(w).Encode(models.BidStatus{State: state})
+	}))
+	defer server.Close()
+
+	client := &WebhookClient{
+		handler:         handler,
+		completedTasks:  make(map[string]time.Time),
+		lastCleanupTime: time.Now(),
+	}
+	client.SetBidder(bidding.NewBidder(bidding.Pricing{Base: 0.5}, bidding.NewClient(server.URL, "device-1")))
+
+	task := makeWebhookTask(uuid.New(), "underpaid")
+	task.Reward = 0.1
+	resp := performWebhookRequest(t, client, task)
+	if !bytes.Contains(resp.Body.Bytes(), []byte("bid_exceeds_reward")) {
+		t.Fatalf("expected bid_exceeds_reward response, got %s", resp.Body.String())
+	}
+
+	lost := makeWebhookTask(uuid.New(), "lost")
+	lost.Reward = 1
+	performWebhookRequest(t, client, lost)
+	// The lost auction frees the runner for the next task
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		client.completedTasksLock.Lock()
+		busy := client.activeTaskID != ""
+		client.completedTasksLock.Unlock()
+		if !busy {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	awarded := makeWebhookTask(won, "won")
+	awarded.Reward = 1
+	performWebhookRequest(t, client, awarded)
+
+	select {
+	case id := <-handler.started:
+		if id != won.String() {
+			t.Fatalf("handler ran %s, want only the awarded task", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler was not invoked for the awarded task")
+	}
+}
+
+func TestServeWebhookRequiresRandomPathAndToken(t *testing.T) {
+	handler := &blockingTaskHandler{
+		started: make(chan string, 1),
+		release: make(chan struct{}),
+	}
+	defer close(handler.release)
+
+	client := &WebhookClient{
+		handler:         handler,
+		completedTasks:  make(map[string]time.Time),
+		lastCleanupTime: time.Now(),
+	}
+	client.SetRandomizedEndpoint(true)
+	path, token, err := client.nextEndpoint()
+	if err != nil {
+		t.Fatalf("nextEndpoint() error = %v", err)
+	}
+	client.useEndpoint(path, token)
+	if client.webhookPath == "/webhook" || client.webhookToken == "" {
+		t.Fatalf("expected a random path and token, got %q / %q", client.webhookPath, client.webhookToken)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"type":    "available_tasks",
+		"payload": makeWebhookTask(uuid.New(), "guarded"),
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal webhook body: %v", err)
+	}
+
+	send := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		client.serveWebhook(rec, req)
+		return rec.Code
+	}
+
+	if code := send("/webhook", client.webhookToken); code != http.StatusNotFound {
+		t.Fatalf("default path response code = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := send(client.webhookPath, ""); code != http.StatusUnauthorized {
+		t.Fatalf("missing token response code = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := send(client.webhookPath, "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong token response code = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := send(client.webhookPath, client.webhookToken); code != http.StatusOK {
+		t.Fatalf("authorized response code = %d, want %d", code, http.StatusOK)
+	}
+
+	if next, _, err := client.nextEndpoint(); err != nil || next == client.webhookPath {
+		t.Fatalf("expected a new path on re-registration, got %q (err %v)", next, err)
+	}
+}
+
+func TestRegisterKeepsEndpointWhenRegistrationFails(t *testing.T) {
+	var registered models.RunnerRegistration
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&registered)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"id":"webhook-1"}`))
+	}))
+	defer server.Close()
+
+	client := NewWebhookClient(server.URL, 0, nil, "runner-1", "device-1", "")
+	client.SetRandomizedEndpoint(true)
+	client.useEndpoint("/webhook/current", "current-token")
+
+	if err := client.Register(); err == nil {
+		t.Fatal("Register() succeeded against a failing server")
+	}
+	if registered.WebhookToken == "" || registered.WebhookToken == "current-token" {
+		t.Fatalf("registration announced token %q, want a fresh one", registered.WebhookToken)
+	}
+	if client.webhookPath != "/webhook/current" || client.webhookToken != "current-token" {
+		t.Fatalf("endpoint = %q / %q after a failed registration, want the current one kept", client.webhookPath, client.webhookToken)
+	}
+
+	status = http.StatusOK
+	if err := client.Register(); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if client.webhookToken != registered.WebhookToken || client.webhookPath == "/webhook/current" {
+		t.Fatalf("endpoint = %q / %q, want the registered one with token %q", client.webhookPath, client.webhookToken, registered.WebhookToken)
+	}
+}
+
+func TestHandleWebhookRunsTasksThroughWorkerPool(t *testing.T) {
+	handler := &blockingTaskHandler{
+		started: make(chan string, 2),
+		release: make(chan struct{}),
+	}
+
+	client := &WebhookClient{
+		handler:         handler,
+		completedTasks:  make(map[string]time.Time),
+		lastCleanupTime: time.Now(),
+	}
+	pool := executiontask.NewPool(executiontask.PoolConfig{MaxConcurrent: 2})
+	client.SetWorkerPool(pool)
+	defer pool.Stop()
+	defer close(handler.release)
+
+	for _, title := range []string{"first", "second"} {
+		if rec := performWebhookRequest(t, client, makeWebhookTask(uuid.New(), title)); rec.Code != http.StatusOK {
+			t.Fatalf("%s task response code = %d, want %d", title, rec.Code, http.StatusOK)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-handler.started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for tasks to run concurrently")
+		}
+	}
+
+	if rec := performWebhookRequest(t, client, makeWebhookTask(uuid.New(), "third")); rec.Code != http.StatusConflict {
+		t.Fatalf("third task response code = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestHandleWebhookRequiresPinnedServerSignature(t *testing.T) {
+	handler := &blockingTaskHandler{
+		started: make(chan string, 1),
+		release: make(chan struct{}),
+	}
+	defer close(handler.release)
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer := identity.NewSigner(key)
+	verifier, err := identity.NewVerifier(signer.Address())
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+
+	client := &WebhookClient{
+		handler:         handler,
+		deviceID:        "device-1",
+		completedTasks:  make(map[string]time.Time),
+		lastCleanupTime: time.Now(),
+	}
+	client.SetServerVerifier(verifier)
+
+	resp := performWebhookRequest(t, client, makeWebhookTask(uuid.New(), "unsigned"))
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("unsigned response code = %d, want %d", resp.Code, http.StatusUnauthorized)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"type":    "available_tasks",
+		"payload": makeWebhookTask(uuid.New(), "signed"),
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal webhook body: %v", err)
+	}
+	send := func(deviceID string, header http.Header) (int, http.Header) {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+		if header == nil {
+			if err := signer.SignWebhook(req.Header, deviceID, body); err != nil {
+				t.Fatalf("failed to sign webhook body: %v", err)
+			}
+		} else {
+			req.Header = header
+		}
+		rec := httptest.NewRecorder()
+		client.handleWebhook(rec, req)
+		return rec.Code, req.Header
+	}
+
+	if code, _ := send("device-2", nil); code != http.StatusUnauthorized {
+		t.Fatalf("delivery signed for another device response code = %d, want %d", code, http.StatusUnauthorized)
+	}
+	code, header := send("device-1", nil)
+	if code != http.StatusOK {
+		t.Fatalf("signed response code = %d, want %d", code, http.StatusOK)
+	}
+	if code, _ := send("device-1", header); code != http.StatusUnauthorized {
+		t.Fatalf("replayed delivery response code = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	select {
+	case <-handler.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for signed task to start")
+	}
+}
+
+func TestHandleWebhookAnswersBusyWhileDraining(t *testing.T) {
+	handler := &blockingTaskHandler{
+		started: make(chan string, 1),
+		release: make(chan struct{}),
+	}
+	defer close(handler.release)
+
+	client := &WebhookClient{
+		handler:         handler,
+		completedTasks:  make(map[string]time.Time),
+		lastCleanupTime: time.Now(),
+	}
+	client.SetDraining(true)
+
+	resp := performWebhookRequest(t, client, makeWebhookTask(uuid.New(), "drained"))
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("response code = %d, want %d", resp.Code, http.StatusConflict)
+	}
+	select {
+	case id := <-handler.started:
+		t.Fatalf("task %s started while draining", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
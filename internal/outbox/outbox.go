@@ -0,0 +1,231 @@
+// Package outbox keeps task results the server could not be reached to accept,
+// so they are delivered, and paid for, once it can be. Results the server
+// refused or that were offered too long are kept as dead letters rather than
+// dropped, for the operator to look at or send again.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/core/ports"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	outboxDirName = "outbox"
+	deadDirName   = "dead"
+)
+
+// ErrRejected is returned by task clients when the server refused a result for
+// good, so offering it again will not help
+var ErrRejected = errors.New("result rejected by the server")
+
+// Why a result became a dead letter
+const (
+	ReasonExpired  = "expired"
+	ReasonRejected = "rejected"
+)
+
+// Entry is a result waiting to be delivered
+type Entry struct {
+	TaskID    string             `json:"task_id"`
+	Status    models.TaskStatus  `json:"status"`
+	Result    *models.TaskResult `json:"result,omitempty"`
+	QueuedAt  time.Time          `json:"queued_at"`
+	Attempts  int                `json:"attempts"`
+	LastError string             `json:"last_error,omitempty"`
+	// DeadAt and Reason are set once the result is no longer offered
+	DeadAt *time.Time `json:"dead_at,omitempty"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+type Store struct {
+	dir string
+}
+
+func DefaultDir() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, outboxDirName), nil
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) path(taskID string) (string, error) {
+	if _, err := uuid.Parse(taskID); err != nil {
+		return "", fmt.Errorf("invalid task ID %q", taskID)
+	}
+	return filepath.Join(s.dir, taskID+".json"), nil
+}
+
+// Put queues a result, replacing one queued earlier for the same task
+func (s *Store) Put(entry Entry) error {
+	path, err := s.path(entry.TaskID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+	if entry.QueuedAt.IsZero() {
+		entry.QueuedAt = time.Now().UTC()
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal queued result: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write queued result: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write queued result: %w", err)
+	}
+	return nil
+}
+
+// List returns the queued results, oldest first
+func (s *Store) List() ([]Entry, error) {
+	files, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queued result: %w", err)
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse queued result %s: %w", file.Name(), err)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].QueuedAt.Before(entries[j].QueuedAt) })
+	return entries, nil
+}
+
+func (s *Store) Remove(taskID string) error {
+	path, err := s.path(taskID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove queued result: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) dead() *Store {
+	return &Store{dir: filepath.Join(s.dir, deadDirName)}
+}
+
+// Bury moves a queued result to the dead letters
+func (s *Store) Bury(entry Entry, reason string, now time.Time) error {
+	deadAt := now.UTC()
+	entry.DeadAt = &deadAt
+	entry.Reason = reason
+	if err := s.dead().Put(entry); err != nil {
+		return err
+	}
+	return s.Remove(entry.TaskID)
+}
+
+// Dead returns the results that are no longer offered, oldest first
+func (s *Store) Dead() ([]Entry, error) {
+	return s.dead().List()
+}
+
+// Revive queues every dead letter again and returns how many there were
+func (s *Store) Revive() (int, error) {
+	entries, err := s.Dead()
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		entry.DeadAt = nil
+		entry.Reason = ""
+		if err := s.Put(entry); err != nil {
+			return 0, err
+		}
+		if err := s.dead().Remove(entry.TaskID); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// DeliveryReport counts what one Deliver did
+type DeliveryReport struct {
+	Delivered int
+	Pending   int
+	Expired   int
+	Rejected  int
+}
+
+// Deliver sends every queued result through client, removing those the server
+// accepted. Results the server rejected, and those queued longer than maxAge,
+// become dead letters; zero maxAge keeps offering them until they are
+// delivered.
+func (s *Store) Deliver(client ports.TaskClient, maxAge time.Duration, now time.Time) (DeliveryReport, error) {
+	var report DeliveryReport
+	entries, err := s.List()
+	if err != nil {
+		return report, err
+	}
+
+	for _, entry := range entries {
+		if maxAge > 0 && now.Sub(entry.QueuedAt) > maxAge {
+			if err := s.Bury(entry, ReasonExpired, now); err != nil {
+				return report, err
+			}
+			report.Expired++
+			continue
+		}
+
+		if err := client.UpdateTaskStatus(entry.TaskID, entry.Status, entry.Result); err != nil {
+			entry.Attempts++
+			entry.LastError = err.Error()
+			if errors.Is(err, ErrRejected) {
+				if err := s.Bury(entry, ReasonRejected, now); err != nil {
+					return report, err
+				}
+				report.Rejected++
+				continue
+			}
+			if err := s.Put(entry); err != nil {
+				return report, err
+			}
+			report.Pending++
+			continue
+		}
+		if err := s.Remove(entry.TaskID); err != nil {
+			return report, err
+		}
+		report.Delivered++
+	}
+	return report, nil
+}
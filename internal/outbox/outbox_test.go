@@ -0,0 +1,120 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+type flakyClient struct {
+	down      bool
+	reject    map[string]bool
+	delivered []string
+}
+
+func (c *flakyClient) FetchTask() (*models.Task, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *flakyClient) UpdateTaskStatus(taskID string, status models.TaskStatus, result *models.TaskResult) error {
+	if c.down {
+		return errors.New("server unreachable")
+	}
+	if c.reject[taskID] {
+		return fmt.Errorf("%w: task assignment was revoked", ErrRejected)
+	}
+	c.delivered = append(c.delivered, taskID)
+	return nil
+}
+
+func TestDeliverKeepsResultsUntilAccepted(t *testing.T) {
+	store := NewStore(t.TempDir())
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	fresh := uuid.NewString()
+	stale := uuid.NewString()
+	for _, entry := range []Entry{
+		{TaskID: fresh, Status: models.TaskStatusCompleted, Result: &models.TaskResult{Output: "ok"}, QueuedAt: now.Add(-time.Hour)},
+		{TaskID: stale, Status: models.TaskStatusCompleted, QueuedAt: now.Add(-48 * time.Hour)},
+	} {
+		if err := store.Put(entry); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	if err := store.Put(Entry{TaskID: "../escape"}); err == nil {
+		t.Fatal("Put() accepted a task ID that is not a UUID")
+	}
+
+	client := &flakyClient{down: true}
+	report, err := store.Deliver(client, 24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if report.Pending != 1 || report.Expired != 1 || report.Delivered != 0 {
+		t.Fatalf("Deliver() with the server down = %+v, want 1 pending and 1 expired", report)
+	}
+	entries, _ := store.List()
+	if len(entries) != 1 || entries[0].TaskID != fresh || entries[0].Attempts != 1 || entries[0].Result.Output != "ok" {
+		t.Fatalf("queued results = %+v, want the fresh one after one attempt", entries)
+	}
+
+	client.down = false
+	if report, _ := store.Deliver(client, 24*time.Hour, now); report.Delivered != 1 {
+		t.Fatalf("Deliver() = %+v, want 1 delivered", report)
+	}
+	if entries, _ := store.List(); len(entries) != 0 || len(client.delivered) != 1 || client.delivered[0] != fresh {
+		t.Fatalf("after delivery the outbox holds %d results and %v were delivered", len(entries), client.delivered)
+	}
+}
+
+func TestDeliverKeepsRejectedAndExpiredResultsAsDeadLetters(t *testing.T) {
+	store := NewStore(t.TempDir())
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	rejected := uuid.NewString()
+	stale := uuid.NewString()
+	for _, entry := range []Entry{
+		{TaskID: rejected, Status: models.TaskStatusCompleted, Result: &models.TaskResult{Output: "ok"}, QueuedAt: now.Add(-time.Hour)},
+		{TaskID: stale, Status: models.TaskStatusFailed, QueuedAt: now.Add(-48 * time.Hour)},
+	} {
+		if err := store.Put(entry); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	client := &flakyClient{reject: map[string]bool{rejected: true}}
+	report, err := store.Deliver(client, 24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if report.Rejected != 1 || report.Expired != 1 || report.Delivered != 0 || report.Pending != 0 {
+		t.Fatalf("Deliver() = %+v, want 1 rejected and 1 expired", report)
+	}
+	if entries, _ := store.List(); len(entries) != 0 {
+		t.Fatalf("queued results = %+v, want none left to offer", entries)
+	}
+	dead, err := store.Dead()
+	if err != nil {
+		t.Fatalf("Dead() error = %v", err)
+	}
+	if len(dead) != 2 || dead[0].TaskID != stale || dead[0].Reason != ReasonExpired || dead[1].Reason != ReasonRejected || dead[1].LastError == "" || dead[1].DeadAt == nil {
+		t.Fatalf("dead letters = %+v, want the expired and the rejected result", dead)
+	}
+
+	// Revived results are offered again, however old they are
+	client.reject = nil
+	if revived, err := store.Revive(); err != nil || revived != 2 {
+		t.Fatalf("Revive() = %d, %v, want 2", revived, err)
+	}
+	if report, _ := store.Deliver(client, 0, now); report.Delivered != 2 {
+		t.Fatalf("Deliver() after Revive() = %+v, want 2 delivered", report)
+	}
+	if dead, _ := store.Dead(); len(dead) != 0 {
+		t.Fatalf("dead letters = %+v after delivery", dead)
+	}
+}
@@ -0,0 +1,84 @@
+package peerchannel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// SocketName is the name ServeLocal's socket is given in the directory mounted
+// into the container
+const SocketName = "channel.sock"
+
+// ServeLocal exposes the channel to the member's container on a unix socket:
+// POST /peers/{rank} sends the request body to a peer and GET /peers/{rank}
+// waits for the next message from it. The socket is reachable by every user in
+// the container, since task images do not all run as root.
+func (c *Channel) ServeLocal(socketPath string) error {
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0o666); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to make %s accessible: %w", socketPath, err)
+	}
+
+	c.mu.Lock()
+	select {
+	case <-c.closed:
+		c.mu.Unlock()
+		listener.Close()
+		return ErrClosed
+	default:
+	}
+	c.local = append(c.local, listener)
+	c.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /peers/{rank}", c.handleSend)
+	mux.HandleFunc("GET /peers/{rank}", c.handleReceive)
+	go http.Serve(listener, mux)
+	return nil
+}
+
+func (c *Channel) handleSend(w http.ResponseWriter, r *http.Request) {
+	rank, err := strconv.Atoi(r.PathValue("rank"))
+	if err != nil {
+		http.Error(w, "invalid rank", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxMessageBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := c.Send(r.Context(), rank, data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Channel) handleReceive(w http.ResponseWriter, r *http.Request) {
+	rank, err := strconv.Atoi(r.PathValue("rank"))
+	if err != nil {
+		http.Error(w, "invalid rank", http.StatusBadRequest)
+		return
+	}
+	data, err := c.Receive(r.Context(), rank)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
@@ -0,0 +1,468 @@
+// Package peerchannel lets the members of a gang task send each other data
+// directly instead of through the coordinator. Each member makes an X25519 key
+// for the task and the server hands the public keys to the other members with
+// the gang, so only the members can read what is sent and a message that
+// decrypts is known to come from the rank it claims.
+//
+// A connection carries messages in one direction. The dialer sends its rank and
+// a random salt, the listener answers with a salt of its own, and both derive
+// an AES-256-GCM key from the X25519 secret of the pair, the gang attempt and
+// the two salts. Messages are then length-prefixed ciphertexts with a counter
+// nonce. Each message starts with an ID that counts up across the sender's
+// connections to the peer, so a message resent on a new connection after the
+// old one broke is delivered once.
+package peerchannel
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	protocolVersion = 2
+	saltSize        = 32
+	messageIDSize   = 8
+	// MaxMessageBytes bounds a single message
+	MaxMessageBytes = 128 << 20
+	// inboxSize is how many messages from a peer are buffered before the peer
+	// is slowed down
+	inboxSize = 16
+)
+
+var (
+	// handshakeTimeout also bounds how long a connection from a peer waits for
+	// this member to learn its gang
+	handshakeTimeout = 30 * time.Second
+	dialTimeout      = 10 * time.Second
+
+	ErrClosed = errors.New("peer channel closed")
+)
+
+// GenerateKey makes the key pair of a member for one task
+func GenerateKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// EncodePublicKey is how public keys travel through the server
+func EncodePublicKey(key *ecdh.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key.Bytes())
+}
+
+func ParsePublicKey(encoded string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel key: %w", err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel key: %w", err)
+	}
+	return key, nil
+}
+
+type peer struct {
+	addr   string
+	shared []byte
+
+	mu   sync.Mutex
+	conn net.Conn
+	aead cipher.AEAD
+	seq  uint64
+	// sent is the ID of the last message sent to the peer, on any connection
+	sent uint64
+}
+
+// inbox holds the messages received from one peer
+type inbox struct {
+	messages chan []byte
+
+	// mu orders deliveries from the old and the new connection of a peer
+	// that reconnected, and delivered is the ID of the last message delivered
+	mu        sync.Mutex
+	delivered uint64
+}
+
+// deliver queues a message unless a message with its ID, or a later one,
+// already arrived
+func (in *inbox) deliver(id uint64, data []byte, closed <-chan struct{}) bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if id <= in.delivered {
+		return true
+	}
+	select {
+	case in.messages <- data:
+		in.delivered = id
+		return true
+	case <-closed:
+		return false
+	}
+}
+
+// Channel is the end of one gang member
+type Channel struct {
+	key      *ecdh.PrivateKey
+	listener net.Listener
+
+	joined  chan struct{}
+	gangID  string
+	attempt int
+	rank    int
+	peers   []*peer
+	inboxes []*inbox
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	conns     map[net.Conn]struct{}
+	local     []net.Listener
+}
+
+// Listen accepts connections from peers on addr. They are held until Join
+// tells the channel who its peers are.
+func Listen(addr string, key *ecdh.PrivateKey) (*Channel, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for peers: %w", err)
+	}
+	c := &Channel{
+		key:      key,
+		listener: listener,
+		joined:   make(chan struct{}),
+		closed:   make(chan struct{}),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go c.acceptLoop()
+	return c, nil
+}
+
+func (c *Channel) Addr() net.Addr {
+	return c.listener.Addr()
+}
+
+// Join sets up the peers of a formed gang. Every member must have reported a
+// channel endpoint and key.
+func (c *Channel) Join(status *models.GangStatus) error {
+	if status.Self == nil {
+		return errors.New("gang status does not say which rank this member has")
+	}
+
+	peers := make([]*peer, len(status.Members))
+	for _, member := range status.Members {
+		if member.Rank < 0 || member.Rank >= len(peers) {
+			return fmt.Errorf("invalid rank %d", member.Rank)
+		}
+		if member.Rank == status.Self.Rank {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(member.ChannelEndpoint); err != nil {
+			return fmt.Errorf("rank %d has no channel endpoint", member.Rank)
+		}
+		public, err := ParsePublicKey(member.ChannelKey)
+		if err != nil {
+			return fmt.Errorf("rank %d: %w", member.Rank, err)
+		}
+		shared, err := c.key.ECDH(public)
+		if err != nil {
+			return fmt.Errorf("rank %d: %w", member.Rank, err)
+		}
+		peers[member.Rank] = &peer{addr: member.ChannelEndpoint, shared: shared}
+	}
+
+	c.gangID = status.GangID
+	c.attempt = status.Attempt
+	c.rank = status.Self.Rank
+	c.peers = peers
+	c.inboxes = make([]*inbox, len(peers))
+	for rank := range c.inboxes {
+		c.inboxes[rank] = &inbox{messages: make(chan []byte, inboxSize)}
+	}
+	close(c.joined)
+	return nil
+}
+
+func (c *Channel) peer(rank int) (*peer, error) {
+	select {
+	case <-c.joined:
+	default:
+		return nil, errors.New("peer channel has not joined its gang")
+	}
+	if rank < 0 || rank >= len(c.peers) || c.peers[rank] == nil {
+		return nil, fmt.Errorf("no peer with rank %d", rank)
+	}
+	return c.peers[rank], nil
+}
+
+// Send delivers data to the member with the given rank
+func (c *Channel) Send(ctx context.Context, rank int, data []byte) error {
+	if len(data) > MaxMessageBytes {
+		return fmt.Errorf("message of %d bytes exceeds the %d byte limit", len(data), MaxMessageBytes)
+	}
+	p, err := c.peer(rank)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A connection that broke since the last message is only noticed when
+	// writing, so a failed write is retried once on a new connection. The
+	// retry keeps the message ID, so the peer drops it if the first write
+	// got through after all.
+	id := p.sent + 1
+	for attempt := 0; ; attempt++ {
+		if p.conn == nil {
+			if err := c.dial(ctx, rank, p); err != nil {
+				return err
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			p.conn.SetWriteDeadline(deadline)
+		} else {
+			p.conn.SetWriteDeadline(time.Time{})
+		}
+		err := writeFrame(p.conn, p.aead, p.seq, id, data)
+		if err == nil {
+			p.seq++
+			p.sent = id
+			return nil
+		}
+		c.forget(p.conn)
+		p.conn = nil
+		if attempt > 0 || ctx.Err() != nil {
+			return fmt.Errorf("failed to send to rank %d: %w", rank, err)
+		}
+	}
+}
+
+// dial opens a connection to p and runs the handshake, with p.mu held
+func (c *Channel) dial(ctx context.Context, rank int, p *peer) error {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to rank %d: %w", rank, err)
+	}
+	if !c.track(conn) {
+		conn.Close()
+		return ErrClosed
+	}
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	hello := make([]byte, 3+saltSize)
+	hello[0] = protocolVersion
+	binary.BigEndian.PutUint16(hello[1:3], uint16(c.rank))
+	if _, err := rand.Read(hello[3:]); err != nil {
+		c.forget(conn)
+		return err
+	}
+	reply := make([]byte, saltSize)
+	if _, err = conn.Write(hello); err == nil {
+		_, err = io.ReadFull(conn, reply)
+	}
+	if err != nil {
+		c.forget(conn)
+		return fmt.Errorf("handshake with rank %d failed: %w", rank, err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	aead, err := c.connectionKey(p.shared, c.rank, rank, hello[3:], reply)
+	if err != nil {
+		c.forget(conn)
+		return err
+	}
+	p.conn, p.aead, p.seq = conn, aead, 0
+	return nil
+}
+
+// Receive returns the next message from the member with the given rank
+func (c *Channel) Receive(ctx context.Context, rank int) ([]byte, error) {
+	if _, err := c.peer(rank); err != nil {
+		return nil, err
+	}
+	select {
+	case data := <-c.inboxes[rank].messages:
+		return data, nil
+	case <-c.closed:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Channel) acceptLoop() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		if !c.track(conn) {
+			conn.Close()
+			return
+		}
+		go c.serve(conn)
+	}
+}
+
+// serve reads the messages of one incoming connection into the inbox of the
+// rank that opened it
+func (c *Channel) serve(conn net.Conn) {
+	log := gologger.WithComponent("peer_channel")
+	defer c.forget(conn)
+
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	hello := make([]byte, 3+saltSize)
+	if _, err := io.ReadFull(conn, hello); err != nil || hello[0] != protocolVersion {
+		return
+	}
+	select {
+	case <-c.joined:
+	case <-c.closed:
+		return
+	case <-time.After(handshakeTimeout):
+		return
+	}
+
+	from := int(binary.BigEndian.Uint16(hello[1:3]))
+	p, err := c.peer(from)
+	if err != nil {
+		log.Warn().Str("remote", conn.RemoteAddr().String()).Int("rank", from).Msg("Refused connection from unknown rank")
+		return
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return
+	}
+	if _, err := conn.Write(salt); err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	aead, err := c.connectionKey(p.shared, from, c.rank, hello[3:], salt)
+	if err != nil {
+		return
+	}
+	for seq := uint64(0); ; seq++ {
+		id, data, err := readFrame(conn, aead, seq)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Warn().Err(err).Int("rank", from).Msg("Dropped connection from peer")
+			}
+			return
+		}
+		if !c.inboxes[from].deliver(id, data, c.closed) {
+			return
+		}
+	}
+}
+
+// connectionKey derives the key of a connection from sender to receiver
+func (c *Channel) connectionKey(shared []byte, sender, receiver int, senderSalt, receiverSalt []byte) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte("parity peer channel v1"))
+	h.Write([]byte(c.gangID))
+	binary.Write(h, binary.BigEndian, uint32(c.attempt))
+	binary.Write(h, binary.BigEndian, uint16(sender))
+	binary.Write(h, binary.BigEndian, uint16(receiver))
+	h.Write(shared)
+	h.Write(senderSalt)
+	h.Write(receiverSalt)
+
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func nonce(aead cipher.AEAD, seq uint64) []byte {
+	n := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(n[len(n)-8:], seq)
+	return n
+}
+
+func writeFrame(w io.Writer, aead cipher.AEAD, seq, id uint64, data []byte) error {
+	plain := make([]byte, messageIDSize, messageIDSize+len(data))
+	binary.BigEndian.PutUint64(plain, id)
+	sealed := aead.Seal(nil, nonce(aead, seq), append(plain, data...), nil)
+	frame := make([]byte, 4, 4+len(sealed))
+	binary.BigEndian.PutUint32(frame, uint32(len(sealed)))
+	_, err := w.Write(append(frame, sealed...))
+	return err
+}
+
+func readFrame(r io.Reader, aead cipher.AEAD, seq uint64) (uint64, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxMessageBytes+messageIDSize+uint32(aead.Overhead()) {
+		return 0, nil, fmt.Errorf("message of %d bytes exceeds the limit", size)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(r, sealed); err != nil {
+		return 0, nil, err
+	}
+	plain, err := aead.Open(nil, nonce(aead, seq), sealed, nil)
+	if err != nil || len(plain) < messageIDSize {
+		return 0, nil, errors.New("message failed authentication")
+	}
+	return binary.BigEndian.Uint64(plain[:messageIDSize]), plain[messageIDSize:], nil
+}
+
+func (c *Channel) track(conn net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closed:
+		return false
+	default:
+	}
+	c.conns[conn] = struct{}{}
+	return true
+}
+
+func (c *Channel) forget(conn net.Conn) {
+	c.mu.Lock()
+	delete(c.conns, conn)
+	c.mu.Unlock()
+	conn.Close()
+}
+
+// Close stops accepting peers and drops every connection
+func (c *Channel) Close() error {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		close(c.closed)
+		conns := c.conns
+		c.conns = make(map[net.Conn]struct{})
+		local := c.local
+		c.mu.Unlock()
+
+		c.listener.Close()
+		for _, listener := range local {
+			listener.Close()
+		}
+		for conn := range conns {
+			conn.Close()
+		}
+	})
+	return nil
+}
@@ -0,0 +1,195 @@
+package peerchannel
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// newGang starts a channel per member on localhost and joins them as one gang
+func newGang(t *testing.T, size int) []*Channel {
+	t.Helper()
+
+	channels := make([]*Channel, size)
+	status := &models.GangStatus{GangID: "gang", Attempt: 1, Size: size, Members: make([]models.GangMember, size)}
+	for rank := range channels {
+		key, err := GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		channel, err := Listen("127.0.0.1:0", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { channel.Close() })
+		channels[rank] = channel
+		status.Members[rank] = models.GangMember{
+			Rank:            rank,
+			ChannelEndpoint: channel.Addr().String(),
+			ChannelKey:      EncodePublicKey(key.PublicKey()),
+		}
+	}
+	for rank, channel := range channels {
+		self := *status
+		self.Self = &status.Members[rank]
+		if err := channel.Join(&self); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return channels
+}
+
+func TestChannelDeliversBetweenMembers(t *testing.T) {
+	channels := newGang(t, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	shard := bytes.Repeat([]byte("masked gradient "), 1<<16)
+	for i := 0; i < 3; i++ {
+		if err := channels[0].Send(ctx, 2, append(shard, byte(i))); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if err := channels[1].Send(ctx, 2, []byte("from rank 1")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		data, err := channels[2].Receive(ctx, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, append(shard, byte(i))) {
+			t.Fatalf("message %d from rank 0 arrived altered", i)
+		}
+	}
+	if data, err := channels[2].Receive(ctx, 1); err != nil || string(data) != "from rank 1" {
+		t.Fatalf("Receive(1) = %q, %v", data, err)
+	}
+	if err := channels[0].Send(ctx, 0, []byte("self")); err == nil {
+		t.Fatal("Send() to own rank succeeded")
+	}
+}
+
+func TestChannelDeliversResentMessageOnce(t *testing.T) {
+	channels := newGang(t, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := channels[0].Send(ctx, 1, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sender believes the write failed and resends the message on a new
+	// connection, although the peer already got it
+	p := channels[0].peers[1]
+	p.mu.Lock()
+	channels[0].forget(p.conn)
+	p.conn = nil
+	if err := channels[0].dial(ctx, 1, p); err != nil {
+		p.mu.Unlock()
+		t.Fatal(err)
+	}
+	if err := writeFrame(p.conn, p.aead, p.seq, p.sent, []byte("first")); err != nil {
+		p.mu.Unlock()
+		t.Fatal(err)
+	}
+	p.seq++
+	p.mu.Unlock()
+
+	if err := channels[0].Send(ctx, 1, []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"first", "second"} {
+		if data, err := channels[1].Receive(ctx, 0); err != nil || string(data) != want {
+			t.Fatalf("Receive() = %q, %v, want %q", data, err, want)
+		}
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelShort()
+	if data, err := channels[1].Receive(short, 0); err == nil {
+		t.Fatalf("resent message was delivered again as %q", data)
+	}
+}
+
+func TestChannelRefusesImpostor(t *testing.T) {
+	channels := newGang(t, 2)
+
+	// An outsider claiming rank 0 knows the public key of rank 1 but not the
+	// private key of rank 0, so the key it derives does not match
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	impostor, err := Listen("127.0.0.1:0", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer impostor.Close()
+	if err := impostor.Join(&models.GangStatus{
+		GangID:  "gang",
+		Attempt: 1,
+		Self:    &models.GangMember{Rank: 0},
+		Members: []models.GangMember{
+			{Rank: 0},
+			{Rank: 1, ChannelEndpoint: channels[1].Addr().String(), ChannelKey: EncodePublicKey(channels[1].key.PublicKey())},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := impostor.Send(context.Background(), 1, []byte("forged")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if data, err := channels[1].Receive(ctx, 0); err == nil {
+		t.Fatalf("impostor message %q was delivered", data)
+	}
+}
+
+func TestServeLocal(t *testing.T) {
+	channels := newGang(t, 2)
+	dir := t.TempDir()
+	for rank, channel := range channels {
+		if err := channel.ServeLocal(filepath.Join(dir, string(rune('a'+rank))+".sock")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := func(rank int) *http.Client {
+		socket := filepath.Join(dir, string(rune('a'+rank))+".sock")
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}}
+	}
+
+	resp, err := client(0).Post("http://channel/peers/1", "application/octet-stream", strings.NewReader("share"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("send = %d", resp.StatusCode)
+	}
+
+	resp, err = client(1).Get("http://channel/peers/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "share" {
+		t.Fatalf("receive = %d %q", resp.StatusCode, body)
+	}
+}
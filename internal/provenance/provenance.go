@@ -0,0 +1,175 @@
+// Package provenance reports the build a runner binary came from and signs and
+// verifies release provenance. Runners report their provenance themselves, so
+// it tells servers which release a runner claims to be; it does not prove the
+// binary was not modified.
+package provenance
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// Set at build time with -ldflags "-X github.com/theblitlabs/parity-runner/internal/provenance.Version=v1.2.3 ...",
+// see the release target of the Makefile
+var (
+	Version     string
+	Commit      string
+	Builder     string
+	Attestation string
+	Signature   string
+)
+
+// Current is the provenance of this binary. Values not set at build time come
+// from the build information Go embeds.
+func Current() *models.BuildProvenance {
+	p := &models.BuildProvenance{
+		RunnerVersion: Version,
+		Commit:        Commit,
+		Builder:       Builder,
+		Attestation:   Attestation,
+		GoVersion:     runtime.Version(),
+		Signature:     Signature,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if p.RunnerVersion == "" && info.Main.Version != "(devel)" {
+			p.RunnerVersion = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if p.Commit == "" {
+					p.Commit = setting.Value
+				}
+			case "vcs.modified":
+				p.Dirty = setting.Value == "true"
+			}
+		}
+	}
+	if p.RunnerVersion == "" {
+		p.RunnerVersion = "devel"
+	}
+	return p
+}
+
+// Sign signs the provenance of a release with the release key
+func Sign(p *models.BuildProvenance, key *ecdsa.PrivateKey) error {
+	if key == nil {
+		return fmt.Errorf("release signing key is required")
+	}
+	signature, err := crypto.Sign(digestOf(p), key)
+	if err != nil {
+		return fmt.Errorf("failed to sign provenance: %w", err)
+	}
+	p.Signature = hexutil.Encode(signature)
+	return nil
+}
+
+// Signer recovers the release key that signed the provenance
+func Signer(p *models.BuildProvenance) (common.Address, error) {
+	if !p.Signed() {
+		return common.Address{}, fmt.Errorf("build %s is not a signed release", p.RunnerVersion)
+	}
+	signature, err := hexutil.Decode(p.Signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to decode signature: %w", err)
+	}
+	publicKey, err := crypto.SigToPub(digestOf(p), signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover signer: %w", err)
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// Verify checks that one of the release keys signed the provenance of a clean
+// build
+func Verify(p *models.BuildProvenance, signers []common.Address) error {
+	if p.Dirty {
+		return fmt.Errorf("build %s was made from a modified tree", p.RunnerVersion)
+	}
+	signer, err := Signer(p)
+	if err != nil {
+		return err
+	}
+	for _, trusted := range signers {
+		if signer == trusted {
+			return nil
+		}
+	}
+	return fmt.Errorf("build %s was signed by %s, which is not a release key", p.RunnerVersion, signer.Hex())
+}
+
+func digestOf(p *models.BuildProvenance) []byte {
+	return accounts.TextHash(crypto.Keccak256(p.Statement()))
+}
+
+// CompareVersions compares two vMAJOR.MINOR.PATCH versions like semver. A
+// pre-release such as v1.4.0-rc.1 comes before its release, and pre-releases of
+// one version compare as text. Build metadata is ignored.
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va.numbers {
+		if va.numbers[i] != vb.numbers[i] {
+			if va.numbers[i] < vb.numbers[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	switch {
+	case va.pre == vb.pre:
+		return 0, nil
+	case va.pre == "":
+		return 1, nil
+	case vb.pre == "":
+		return -1, nil
+	case va.pre < vb.pre:
+		return -1, nil
+	default:
+		return 1, nil
+	}
+}
+
+type version struct {
+	numbers [3]int
+	pre     string
+}
+
+func parseVersion(s string) (version, error) {
+	var v version
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), "v")
+	if !ok {
+		return v, fmt.Errorf("invalid version %q, expected vMAJOR.MINOR.PATCH", s)
+	}
+	rest, _, _ = strings.Cut(rest, "+")
+	rest, v.pre, _ = strings.Cut(rest, "-")
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid version %q, expected vMAJOR.MINOR.PATCH", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q, expected vMAJOR.MINOR.PATCH", s)
+		}
+		v.numbers[i] = n
+	}
+	return v, nil
+}
@@ -0,0 +1,96 @@
+package provenance
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestVerifyAcceptsOnlyCleanReleasesSignedByAReleaseKey(t *testing.T) {
+	releaseKey, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	signers := []common.Address{crypto.PubkeyToAddress(releaseKey.PublicKey)}
+
+	release := func() *models.BuildProvenance {
+		build := &models.BuildProvenance{
+			RunnerVersion: "v1.4.0",
+			Commit:        "4f2c1e9d0a7b",
+			Builder:       "https://github.com/actions/runner",
+			Attestation:   "https://example.com/parity-runner.intoto.jsonl",
+		}
+		if err := Sign(build, releaseKey); err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return build
+	}
+
+	if err := Verify(release(), signers); err != nil {
+		t.Fatalf("Verify() of a signed release error = %v", err)
+	}
+
+	tampered := release()
+	tampered.Commit = "0badc0ffee00"
+	if err := Verify(tampered, signers); err == nil {
+		t.Fatal("Verify() accepted a build claiming another commit")
+	}
+
+	dirty := release()
+	dirty.Dirty = true
+	if err := Verify(dirty, signers); err == nil {
+		t.Fatal("Verify() accepted a build from a modified tree")
+	}
+
+	unsigned := release()
+	unsigned.Signature = ""
+	if err := Verify(unsigned, signers); err == nil {
+		t.Fatal("Verify() accepted an unsigned build")
+	}
+
+	// The tree state is signed, so a dirty build cannot pass itself off as clean
+	passedOff := release()
+	passedOff.Dirty = true
+	if err := Sign(passedOff, releaseKey); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	passedOff.Dirty = false
+	if err := Verify(passedOff, signers); err == nil {
+		t.Fatal("Verify() accepted a dirty build reporting a clean tree")
+	}
+
+	fork := release()
+	if err := Sign(fork, otherKey); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if err := Verify(fork, signers); err == nil {
+		t.Fatal("Verify() accepted a build signed by another key")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.4.0", "v1.4.0", 0},
+		{"v1.4.1", "v1.4.0", 1},
+		{"v1.10.0", "v1.9.3", 1},
+		{"v1.4.0-rc.1", "v1.4.0", -1},
+		{"v1.4.0-rc.1", "v1.4.0-rc.2", -1},
+		{"v2.0.0+build.5", "v2.0.0", 0},
+	}
+	for _, tc := range cases {
+		got, err := CompareVersions(tc.a, tc.b)
+		if err != nil || got != tc.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, %v; want %d", tc.a, tc.b, got, err, tc.want)
+		}
+	}
+
+	for _, invalid := range []string{"1.4.0", "v1.4", "devel", "v1.x.0"} {
+		if _, err := CompareVersions(invalid, "v1.0.0"); err == nil {
+			t.Errorf("CompareVersions(%q) accepted an invalid version", invalid)
+		}
+	}
+}
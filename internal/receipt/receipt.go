@@ -0,0 +1,305 @@
+package receipt
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/signer"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const receiptsDirName = "receipts"
+
+// Build assembles an unsigned receipt for a completed task
+func Build(task *models.Task, result *models.TaskResult, runnerAddress string) *models.ExecutionReceipt {
+	receipt := &models.ExecutionReceipt{
+		Version:         models.ReceiptVersion,
+		TaskID:          task.ID,
+		TaskType:        task.Type,
+		ImageDigest:     result.ImageHashVerified,
+		ModelDigest:     modelFromConfig(task.Config),
+		InputHash:       InputHash(task),
+		OutputHash:      OutputHash(result.Output),
+		ExitCode:        result.ExitCode,
+		ExecutionTimeMs: result.ExecutionTime,
+		Metrics: models.ReceiptMetrics{
+			CPUSeconds:      result.CPUSeconds,
+			EstimatedCycles: result.EstimatedCycles,
+			MemoryGBHours:   result.MemoryGBHours,
+			StorageGB:       result.StorageGB,
+			NetworkDataGB:   result.NetworkDataGB,
+		},
+		DeviceID:      result.DeviceID,
+		RunnerAddress: runnerAddress,
+		IssuedAt:      time.Now().UTC().Truncate(time.Second),
+	}
+	return receipt
+}
+
+// InputHash is what a receipt records as the input of task: its config and
+// nonce. The config is compacted first, as the runner receives it, so the
+// server gets the same hash from the config it stored.
+func InputHash(task *models.Task) string {
+	var config bytes.Buffer
+	if err := json.Compact(&config, task.Config); err != nil {
+		config.Reset()
+		config.Write(task.Config)
+	}
+	return sha256Hex(append(config.Bytes(), []byte(task.Nonce)...))
+}
+
+// OutputHash is what a receipt records as the task's output
+func OutputHash(output string) string {
+	return sha256Hex([]byte(output))
+}
+
+// Sign signs the receipt payload with the runner key
+func Sign(receipt *models.ExecutionReceipt, key *ecdsa.PrivateKey) error {
+	if key == nil {
+		return fmt.Errorf("runner signing key is required")
+	}
+	return SignWith(receipt, signer.FromKey(key))
+}
+
+// SignWith signs the receipt payload with the runner's signer, which may be a
+// hardware wallet or a remote signer
+func SignWith(receipt *models.ExecutionReceipt, s signer.Signer) error {
+	if s == nil {
+		return fmt.Errorf("runner signer is required")
+	}
+
+	receipt.RunnerAddress = s.Address().Hex()
+
+	digest, err := runnerDigest(receipt)
+	if err != nil {
+		return err
+	}
+
+	signature, err := s.SignDigest(digest)
+	if err != nil {
+		return fmt.Errorf("failed to sign receipt: %w", err)
+	}
+
+	receipt.RunnerSignature = hexutil.Encode(signature)
+	return nil
+}
+
+// Countersign adds the server signature over the runner-signed receipt
+func Countersign(receipt *models.ExecutionReceipt, key *ecdsa.PrivateKey) error {
+	if key == nil {
+		return fmt.Errorf("server signing key is required")
+	}
+	if receipt.RunnerSignature == "" {
+		return fmt.Errorf("receipt must be signed by the runner before countersigning")
+	}
+
+	receipt.ServerAddress = crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	digest, err := serverDigest(receipt)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signer.FromKey(key).SignDigest(digest)
+	if err != nil {
+		return fmt.Errorf("failed to countersign receipt: %w", err)
+	}
+
+	receipt.ServerSignature = hexutil.Encode(signature)
+	return nil
+}
+
+// Verify checks the runner signature and, when present, the server
+// countersignature. The signatures are checked against the addresses the
+// receipt names, so on their own they only show the receipt is unchanged since
+// it was signed; use VerifySigners to check who signed it.
+func Verify(receipt *models.ExecutionReceipt) error {
+	if receipt.RunnerSignature == "" {
+		return fmt.Errorf("receipt is not signed")
+	}
+
+	digest, err := runnerDigest(receipt)
+	if err != nil {
+		return err
+	}
+	if err := signer.Verify(digest, receipt.RunnerSignature, receipt.RunnerAddress); err != nil {
+		return fmt.Errorf("invalid runner signature: %w", err)
+	}
+
+	if receipt.ServerSignature == "" {
+		return nil
+	}
+
+	digest, err = serverDigest(receipt)
+	if err != nil {
+		return err
+	}
+	if err := signer.Verify(digest, receipt.ServerSignature, receipt.ServerAddress); err != nil {
+		return fmt.Errorf("invalid server countersignature: %w", err)
+	}
+
+	return nil
+}
+
+// Signers pins the addresses a receipt must be signed by. Empty addresses are
+// not checked.
+type Signers struct {
+	Runner string
+	Server string
+}
+
+// VerifySigners verifies a receipt and checks it was signed by the pinned
+// runner and countersigned by the pinned server. A pinned server requires the
+// countersignature.
+func VerifySigners(receipt *models.ExecutionReceipt, signers Signers) error {
+	if err := Verify(receipt); err != nil {
+		return err
+	}
+	if signers.Runner != "" {
+		if err := sameAddress(receipt.RunnerAddress, signers.Runner); err != nil {
+			return fmt.Errorf("receipt is not signed by the expected runner: %w", err)
+		}
+	}
+	if signers.Server != "" {
+		if receipt.ServerSignature == "" {
+			return fmt.Errorf("receipt has no server countersignature")
+		}
+		if err := sameAddress(receipt.ServerAddress, signers.Server); err != nil {
+			return fmt.Errorf("receipt is not countersigned by the expected server: %w", err)
+		}
+	}
+	return nil
+}
+
+func sameAddress(signed, expected string) error {
+	if !common.IsHexAddress(expected) {
+		return fmt.Errorf("invalid address %q", expected)
+	}
+	if common.HexToAddress(signed) != common.HexToAddress(expected) {
+		return fmt.Errorf("signed by %s, expected %s", signed, expected)
+	}
+	return nil
+}
+
+func DefaultDir() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, receiptsDirName), nil
+}
+
+func Save(dir string, receipt *models.ExecutionReceipt) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create receipts directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(receipt, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal receipt: %w", err)
+	}
+
+	path := filepath.Join(dir, receipt.TaskID.String()+".json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write receipt: %w", err)
+	}
+
+	return path, nil
+}
+
+func Load(path string) (*models.ExecutionReceipt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read receipt: %w", err)
+	}
+
+	var receipt models.ExecutionReceipt
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		return nil, fmt.Errorf("failed to parse receipt: %w", err)
+	}
+
+	return &receipt, nil
+}
+
+// Format renders a receipt as a human-readable document
+func Format(receipt *models.ExecutionReceipt) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Execution Receipt (v%d)\n", receipt.Version)
+	fmt.Fprintf(&b, "  Task ID:         %s\n", receipt.TaskID)
+	fmt.Fprintf(&b, "  Task type:       %s\n", receipt.TaskType)
+	if receipt.ImageDigest != "" {
+		fmt.Fprintf(&b, "  Image digest:    %s\n", receipt.ImageDigest)
+	}
+	if receipt.ModelDigest != "" {
+		fmt.Fprintf(&b, "  Model:           %s\n", receipt.ModelDigest)
+	}
+	fmt.Fprintf(&b, "  Input hash:      %s\n", receipt.InputHash)
+	fmt.Fprintf(&b, "  Output hash:     %s\n", receipt.OutputHash)
+	fmt.Fprintf(&b, "  Exit code:       %d\n", receipt.ExitCode)
+	fmt.Fprintf(&b, "  Execution time:  %dms\n", receipt.ExecutionTimeMs)
+	fmt.Fprintf(&b, "  CPU seconds:     %.4f\n", receipt.Metrics.CPUSeconds)
+	fmt.Fprintf(&b, "  Memory GB-hours: %.6f\n", receipt.Metrics.MemoryGBHours)
+	fmt.Fprintf(&b, "  Storage GB:      %.4f\n", receipt.Metrics.StorageGB)
+	fmt.Fprintf(&b, "  Network GB:      %.4f\n", receipt.Metrics.NetworkDataGB)
+	fmt.Fprintf(&b, "  Device ID:       %s\n", receipt.DeviceID)
+	fmt.Fprintf(&b, "  Runner:          %s\n", receipt.RunnerAddress)
+	fmt.Fprintf(&b, "  Issued at:       %s\n", receipt.IssuedAt.Format(time.RFC3339))
+	if receipt.ServerSignature != "" {
+		fmt.Fprintf(&b, "  Countersigned:   %s\n", receipt.ServerAddress)
+	} else {
+		fmt.Fprintf(&b, "  Countersigned:   no\n")
+	}
+
+	return b.String()
+}
+
+func runnerDigest(receipt *models.ExecutionReceipt) ([]byte, error) {
+	unsigned := *receipt
+	unsigned.RunnerSignature = ""
+	unsigned.ServerAddress = ""
+	unsigned.ServerSignature = ""
+	return digestOf(&unsigned)
+}
+
+func serverDigest(receipt *models.ExecutionReceipt) ([]byte, error) {
+	unsigned := *receipt
+	unsigned.ServerSignature = ""
+	return digestOf(&unsigned)
+}
+
+func digestOf(receipt *models.ExecutionReceipt) ([]byte, error) {
+	payload, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal receipt payload: %w", err)
+	}
+	return crypto.Keccak256(payload), nil
+}
+
+func modelFromConfig(config json.RawMessage) string {
+	var parsed struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(config, &parsed); err != nil {
+		return ""
+	}
+	return parsed.Model
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
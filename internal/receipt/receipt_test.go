@@ -0,0 +1,128 @@
+package receipt
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestSignCountersignAndVerify(t *testing.T) {
+	runnerKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate runner key: %v", err)
+	}
+	serverKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate server key: %v", err)
+	}
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeDocker
+	task.Config = json.RawMessage(`{"image_name":"alpine"}`)
+	task.Nonce = "nonce"
+
+	receipt := Build(task, &models.TaskResult{
+		TaskID:            task.ID,
+		DeviceID:          "device-1",
+		Output:            "hello",
+		ImageHashVerified: "abc123",
+		ExecutionTime:     42,
+		CPUSeconds:        1.5,
+	}, "")
+
+	if err := Sign(receipt, runnerKey); err != nil {
+		t.Fatalf("failed to sign receipt: %v", err)
+	}
+	if err := Verify(receipt); err != nil {
+		t.Fatalf("expected runner-signed receipt to verify: %v", err)
+	}
+
+	if err := Countersign(receipt, serverKey); err != nil {
+		t.Fatalf("failed to countersign receipt: %v", err)
+	}
+
+	path, err := Save(t.TempDir(), receipt)
+	if err != nil {
+		t.Fatalf("failed to save receipt: %v", err)
+	}
+	if filepath.Base(path) != task.ID.String()+".json" {
+		t.Fatalf("unexpected receipt path %s", path)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load receipt: %v", err)
+	}
+	if err := Verify(loaded); err != nil {
+		t.Fatalf("expected countersigned receipt to verify: %v", err)
+	}
+
+	loaded.Metrics.CPUSeconds = 100
+	if err := Verify(loaded); err == nil {
+		t.Fatal("expected tampered receipt to fail verification")
+	}
+}
+
+func TestCountersignRequiresRunnerSignature(t *testing.T) {
+	serverKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate server key: %v", err)
+	}
+
+	if err := Countersign(&models.ExecutionReceipt{}, serverKey); err == nil {
+		t.Fatal("expected countersigning an unsigned receipt to fail")
+	}
+}
+
+func TestVerifySignersRejectsSelfSignedForgery(t *testing.T) {
+	runnerKey, _ := crypto.GenerateKey()
+	serverKey, _ := crypto.GenerateKey()
+	forgerKey, _ := crypto.GenerateKey()
+	runner := crypto.PubkeyToAddress(runnerKey.PublicKey).Hex()
+	server := crypto.PubkeyToAddress(serverKey.PublicKey).Hex()
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeDocker
+	result := &models.TaskResult{TaskID: task.ID, DeviceID: "device-1", Output: "hello"}
+
+	genuine := Build(task, result, "")
+	if err := Sign(genuine, runnerKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := Countersign(genuine, serverKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySigners(genuine, Signers{Runner: runner, Server: server}); err != nil {
+		t.Fatalf("VerifySigners() genuine receipt = %v", err)
+	}
+
+	// A forger signs both parts with their own key, which Verify alone accepts
+	forged := Build(task, result, "")
+	if err := Sign(forged, forgerKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := Countersign(forged, forgerKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(forged); err != nil {
+		t.Fatalf("Verify() forged receipt = %v", err)
+	}
+	if err := VerifySigners(forged, Signers{Server: server}); err == nil {
+		t.Fatal("VerifySigners() accepted a receipt countersigned by another server")
+	}
+	if err := VerifySigners(forged, Signers{Runner: runner}); err == nil {
+		t.Fatal("VerifySigners() accepted a receipt signed by another runner")
+	}
+
+	uncountersigned := Build(task, result, "")
+	if err := Sign(uncountersigned, runnerKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySigners(uncountersigned, Signers{Server: server}); err == nil {
+		t.Fatal("VerifySigners() accepted a receipt without the pinned server's countersignature")
+	}
+}
@@ -0,0 +1,125 @@
+// Package rewards withdraws the task rewards the stake wallet contract has
+// accrued for a device to the wallet that staked it.
+package rewards
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/theblitlabs/parity-runner/internal/gas"
+	"github.com/theblitlabs/parity-runner/internal/signer"
+)
+
+// stakeWalletRewardsABI is the part of the stake wallet contract that holds
+// accrued rewards
+const stakeWalletRewardsABI = `[
+	{"type":"function","name":"getRewardBalance","stateMutability":"view",
+	 "inputs":[{"name":"deviceID","type":"string"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"withdrawRewards","stateMutability":"nonpayable",
+	 "inputs":[{"name":"deviceID","type":"string"},{"name":"amount","type":"uint256"}],"outputs":[]}
+]`
+
+var (
+	ErrNothingToWithdraw = errors.New("no rewards to withdraw")
+	ErrReverted          = gas.ErrReverted
+	ErrNotConfirmed      = gas.ErrNotConfirmed
+)
+
+// Backend is the part of an Ethereum client withdrawals need
+type Backend interface {
+	gas.Backend
+	CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// Options tune how a withdrawal is priced and sent
+type Options = gas.Config
+
+// Withdrawer reads and withdraws the rewards of devices staked by the wallet
+// of its signer
+type Withdrawer struct {
+	backend  Backend
+	contract common.Address
+	sender   *gas.Sender
+	abi      abi.ABI
+}
+
+func NewWithdrawer(backend Backend, contract common.Address, key *ecdsa.PrivateKey, chainID *big.Int, opts Options) (*Withdrawer, error) {
+	if key == nil {
+		return nil, errors.New("wallet key is required")
+	}
+	return NewWithdrawerWithSigner(backend, contract, signer.FromKey(key), chainID, opts)
+}
+
+// NewWithdrawerWithSigner withdraws with transactions signed by s, such as a
+// hardware wallet
+func NewWithdrawerWithSigner(backend Backend, contract common.Address, s signer.Signer, chainID *big.Int, opts Options) (*Withdrawer, error) {
+	sender, err := gas.NewSenderWithSigner(backend, s, chainID, opts)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := abi.JSON(strings.NewReader(stakeWalletRewardsABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stake wallet ABI: %w", err)
+	}
+	return &Withdrawer{
+		backend:  backend,
+		contract: contract,
+		sender:   sender,
+		abi:      parsed,
+	}, nil
+}
+
+// Accrued is the reward balance the contract holds for deviceID
+func (w *Withdrawer) Accrued(ctx context.Context, deviceID string) (*big.Int, error) {
+	data, err := w.abi.Pack("getRewardBalance", deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reward balance call: %w", err)
+	}
+	output, err := w.backend.CallContract(ctx, ethereum.CallMsg{From: w.sender.From(), To: &w.contract, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reward balance: %w", err)
+	}
+	values, err := w.abi.Unpack("getRewardBalance", output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode reward balance: %w", err)
+	}
+	balance, ok := values[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reward balance type %T", values[0])
+	}
+	return balance, nil
+}
+
+// MaxWait is the longest Withdraw waits for confirmations, with every retry
+func (w *Withdrawer) MaxWait() time.Duration {
+	return w.sender.MaxWait()
+}
+
+// Withdraw sends a transaction withdrawing amount of deviceID's rewards and
+// waits for it to be mined, replacing it with higher fees while it is stuck.
+// The receipt is returned with ErrReverted when the transaction failed on
+// chain.
+func (w *Withdrawer) Withdraw(ctx context.Context, deviceID string, amount *big.Int) (*types.Receipt, error) {
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, ErrNothingToWithdraw
+	}
+	data, err := w.abi.Pack("withdrawRewards", deviceID, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode withdrawal: %w", err)
+	}
+	receipt, err := w.sender.Send(ctx, w.contract, data)
+	if err != nil {
+		return receipt, fmt.Errorf("failed to withdraw rewards: %w", err)
+	}
+	return receipt, nil
+}
@@ -0,0 +1,136 @@
+package rewards
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeChain mines the transaction sent on attempt mineAttempt, counted from 1,
+// and ignores the others
+type fakeChain struct {
+	mu          sync.Mutex
+	balance     *big.Int
+	baseFee     *big.Int
+	nonce       uint64
+	mineAttempt int
+	status      uint64
+	sent        []*types.Transaction
+	mined       map[common.Hash]bool
+}
+
+func (f *fakeChain) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return common.LeftPadBytes(f.balance.Bytes(), 32), nil
+}
+
+func (f *fakeChain) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 50_000, nil
+}
+
+func (f *fakeChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: f.baseFee}, nil
+}
+
+func (f *fakeChain) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(2), nil
+}
+
+func (f *fakeChain) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(100), nil
+}
+
+func (f *fakeChain) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return f.nonce, nil
+}
+
+func (f *fakeChain) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, tx)
+	if len(f.sent) == f.mineAttempt {
+		f.mined[tx.Hash()] = true
+	}
+	return nil
+}
+
+func (f *fakeChain) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.mined[txHash] {
+		return nil, ethereum.NotFound
+	}
+	return &types.Receipt{TxHash: txHash, Status: f.status, BlockNumber: big.NewInt(7), GasUsed: 48_000}, nil
+}
+
+func newTestWithdrawer(t *testing.T, chain *fakeChain) *Withdrawer {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	withdrawer, err := NewWithdrawer(chain, common.HexToAddress("0x5"), key, big.NewInt(1337), Options{
+		ConfirmTimeout: 30 * time.Millisecond,
+		PollInterval:   time.Millisecond,
+		Retries:        2,
+	})
+	if err != nil {
+		t.Fatalf("NewWithdrawer() error = %v", err)
+	}
+	return withdrawer
+}
+
+func TestWithdrawReplacesUnminedTransactionWithHigherFees(t *testing.T) {
+	chain := &fakeChain{balance: big.NewInt(9e17), baseFee: big.NewInt(10), nonce: 4, mineAttempt: 2, status: types.ReceiptStatusSuccessful, mined: map[common.Hash]bool{}}
+	withdrawer := newTestWithdrawer(t, chain)
+	ctx := context.Background()
+
+	accrued, err := withdrawer.Accrued(ctx, "device-1")
+	if err != nil || accrued.Cmp(big.NewInt(9e17)) != 0 {
+		t.Fatalf("Accrued() = %v, %v", accrued, err)
+	}
+
+	receipt, err := withdrawer.Withdraw(ctx, "device-1", accrued)
+	if err != nil {
+		t.Fatalf("Withdraw() error = %v", err)
+	}
+	if len(chain.sent) != 2 || receipt.TxHash != chain.sent[1].Hash() {
+		t.Fatalf("sent %d transactions, receipt of %s; want the second of two", len(chain.sent), receipt.TxHash.Hex())
+	}
+	first, second := chain.sent[0], chain.sent[1]
+	if first.Nonce() != 4 || second.Nonce() != 4 {
+		t.Fatalf("nonces = %d, %d; want the replacement to reuse 4", first.Nonce(), second.Nonce())
+	}
+	if first.Gas() != 60_000 || first.GasFeeCap().Int64() != 22 || first.GasTipCap().Int64() != 2 {
+		t.Fatalf("first transaction gas = %d, fee cap %v, tip %v", first.Gas(), first.GasFeeCap(), first.GasTipCap())
+	}
+	if second.GasFeeCap().Cmp(first.GasFeeCap()) <= 0 || second.GasTipCap().Cmp(first.GasTipCap()) <= 0 {
+		t.Fatalf("replacement fees %v/%v are not above %v/%v", second.GasFeeCap(), second.GasTipCap(), first.GasFeeCap(), first.GasTipCap())
+	}
+}
+
+func TestWithdrawReportsRevertsAndUnconfirmedTransactions(t *testing.T) {
+	reverting := &fakeChain{balance: big.NewInt(1), nonce: 0, mineAttempt: 1, status: types.ReceiptStatusFailed, mined: map[common.Hash]bool{}}
+	receipt, err := newTestWithdrawer(t, reverting).Withdraw(context.Background(), "device-1", big.NewInt(1))
+	if !errors.Is(err, ErrReverted) || receipt == nil {
+		t.Fatalf("Withdraw() of a reverting transaction = %v, %v; want the receipt with ErrReverted", receipt, err)
+	}
+	if reverting.sent[0].Type() != types.LegacyTxType || reverting.sent[0].GasPrice().Int64() != 100 {
+		t.Fatalf("transaction without base fee = type %d at %v, want a legacy one at the suggested price", reverting.sent[0].Type(), reverting.sent[0].GasPrice())
+	}
+
+	stuck := &fakeChain{balance: big.NewInt(1), baseFee: big.NewInt(10), mined: map[common.Hash]bool{}}
+	if _, err := newTestWithdrawer(t, stuck).Withdraw(context.Background(), "device-1", big.NewInt(1)); !errors.Is(err, ErrNotConfirmed) || len(stuck.sent) != 3 {
+		t.Fatalf("Withdraw() never mined = %v after %d transactions, want ErrNotConfirmed after 3", err, len(stuck.sent))
+	}
+	if _, err := newTestWithdrawer(t, stuck).Withdraw(context.Background(), "device-1", big.NewInt(0)); !errors.Is(err, ErrNothingToWithdraw) {
+		t.Fatalf("Withdraw() of nothing = %v, want ErrNothingToWithdraw", err)
+	}
+}
@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/theblitlabs/parity-runner/internal/attestation"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// SetAttester attaches an attestation quote to the results of tasks that ask
+// for TEE isolation. Without one those tasks are refused.
+func (h *DefaultTaskHandler) SetAttester(attester *attestation.Attester) {
+	h.attester = attester
+}
+
+func (h *DefaultTaskHandler) runsInTEE(task *models.Task) bool {
+	return h.attester != nil && h.attester.Technology().Runs(task.Type)
+}
+
+// attest quotes the result of a task that asked for TEE isolation, hashing it
+// first when the executor did not
+func (h *DefaultTaskHandler) attest(task *models.Task, result *models.TaskResult) error {
+	if !task.RequiresTEE() {
+		return nil
+	}
+	if !h.runsInTEE(task) {
+		return fmt.Errorf("%s tasks do not run in this runner's trusted execution environment", task.Type)
+	}
+	if result.ResultHash == "" {
+		result.ResultHash = utils.ComputeResultHash(result.Output, "", result.ExitCode)
+	}
+	quote, err := h.attester.Attest(task, result)
+	if err != nil {
+		return err
+	}
+	result.Attestation = quote
+	return nil
+}
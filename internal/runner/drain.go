@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+)
+
+// drainCheckInterval is how often Drain looks whether running tasks finished
+const drainCheckInterval = time.Second
+
+// Drain stops the runner from taking tasks and tells the server it is busy,
+// then waits until the tasks it claimed have finished or ctx is done. Tasks
+// queued but not claimed yet are left for other runners. The runner keeps
+// serving until Stop is called, so results are still delivered.
+func (s *Service) Drain(ctx context.Context) error {
+	log := gologger.WithComponent("runner")
+
+	handler, _ := s.taskHandler.(*DefaultTaskHandler)
+	if handler != nil {
+		handler.SetDraining(true)
+	}
+	if s.webhookClient != nil {
+		s.webhookClient.SetDraining(true)
+	}
+
+	// Heartbeats now report the runner busy, send one rather than wait
+	if s.socketClient != nil {
+		s.socketClient.SendHeartbeat()
+	} else if s.webhookClient != nil {
+		s.webhookClient.Heartbeat().SendNow()
+	}
+	for _, source := range s.coordinatorSources {
+		source.heartbeat.SendNow()
+	}
+
+	running := func() int {
+		n := 0
+		if s.pool != nil {
+			n = s.pool.Status().Running
+		}
+		if handler != nil {
+			n = max(n, handler.Running())
+		}
+		return n
+	}
+	log.Info().Int("running_tasks", running()).Msg("Draining, no new tasks are taken")
+
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for {
+		n := running()
+		if n == 0 {
+			log.Info().Msg("Drained, no tasks are running")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d tasks still running after draining: %w", n, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
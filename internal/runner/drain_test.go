@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestDrainWaitsForRunningTasksAndRefusesNewOnes(t *testing.T) {
+	newTask := func() *models.Task {
+		return &models.Task{
+			ID:    uuid.New(),
+			Type:  models.TaskTypeCommand,
+			Nonce: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		}
+	}
+	taskClient := &recordingTaskClient{}
+	handler := NewTaskHandler(&stubTaskExecutor{
+		delay:  300 * time.Millisecond,
+		result: &models.TaskResult{Output: "done"},
+	}, taskClient)
+	svc := &Service{taskHandler: handler}
+
+	done := make(chan error, 1)
+	go func() { done <- handler.HandleTask(newTask()) }()
+	deadline := time.Now().Add(2 * time.Second)
+	for handler.Running() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := svc.Drain(short); err == nil {
+		t.Fatal("Drain() returned before the running task finished")
+	}
+
+	if err := handler.HandleTask(newTask()); !errors.Is(err, ErrDraining) {
+		t.Fatalf("HandleTask() while draining error = %v, want ErrDraining", err)
+	}
+	if !handler.IsProcessing() {
+		t.Fatal("a draining runner should report itself busy")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := svc.Drain(ctx); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("running task error = %v, want it to finish", err)
+	}
+	for _, update := range taskClient.updates {
+		if update.status == models.TaskStatusRunning && update.taskID != taskClient.updates[0].taskID {
+			t.Fatalf("task %s was claimed while draining", update.taskID)
+		}
+	}
+}
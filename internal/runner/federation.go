@@ -0,0 +1,371 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/core/ports"
+	"github.com/theblitlabs/parity-runner/internal/execution/task"
+	"github.com/theblitlabs/parity-runner/internal/federation"
+	"github.com/theblitlabs/parity-runner/internal/logstream"
+	"github.com/theblitlabs/parity-runner/internal/messaging/heartbeat"
+	"github.com/theblitlabs/parity-runner/internal/messaging/webhook"
+	"github.com/theblitlabs/parity-runner/internal/sysmetrics"
+)
+
+const (
+	defaultCoordinatorPollInterval = 10 * time.Second
+	defaultCoordinatorQueueSize    = 16
+	// federationSlotCheck is how often queued work waits for a free task slot
+	federationSlotCheck = time.Second
+)
+
+// FederatedTaskClient sends task status updates to the coordinator a task came
+// from. Tasks it was not told about belong to the primary server, as do LLM
+// prompts and federated learning updates.
+type FederatedTaskClient struct {
+	primary   string
+	clients   map[string]ports.TaskClient
+	scheduler *federation.Scheduler
+	mu        sync.Mutex
+	owners    map[string]string
+}
+
+func NewFederatedTaskClient(primary string, clients map[string]ports.TaskClient, scheduler *federation.Scheduler) *FederatedTaskClient {
+	return &FederatedTaskClient{
+		primary:   primary,
+		clients:   clients,
+		scheduler: scheduler,
+		owners:    make(map[string]string),
+	}
+}
+
+// Track records which coordinator offered a task
+func (c *FederatedTaskClient) Track(taskID, coordinator string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.owners[taskID] = coordinator
+}
+
+func (c *FederatedTaskClient) Forget(taskID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.owners, taskID)
+}
+
+// clientFor returns the client of the coordinator that owns taskID. The first
+// time a task the primary pushed starts, the primary is charged for it.
+func (c *FederatedTaskClient) clientFor(taskID string, status models.TaskStatus) ports.TaskClient {
+	c.mu.Lock()
+	owner, ok := c.owners[taskID]
+	if !ok && status == models.TaskStatusRunning {
+		owner = c.primary
+		c.owners[taskID] = owner
+		c.scheduler.Record(owner)
+	}
+	if status == models.TaskStatusCompleted || status == models.TaskStatusFailed || status == models.TaskStatusCancelled {
+		delete(c.owners, taskID)
+	}
+	c.mu.Unlock()
+
+	if client, ok := c.clients[owner]; ok {
+		return client
+	}
+	return c.clients[c.primary]
+}
+
+func (c *FederatedTaskClient) FetchTask() (*models.Task, error) {
+	return c.clients[c.primary].FetchTask()
+}
+
+func (c *FederatedTaskClient) UpdateTaskStatus(taskID string, status models.TaskStatus, result *models.TaskResult) error {
+	return c.clientFor(taskID, status).UpdateTaskStatus(taskID, status, result)
+}
+
+func (c *FederatedTaskClient) CompletePrompt(promptID uuid.UUID, response string, promptTokens, responseTokens int, inferenceTime int64) error {
+	client, ok := c.clients[c.primary].(LLMTaskClient)
+	if !ok {
+		return fmt.Errorf("task client does not support LLM completion")
+	}
+	return client.CompletePrompt(promptID, response, promptTokens, responseTokens, inferenceTime)
+}
+
+func (c *FederatedTaskClient) FailPrompt(promptID uuid.UUID, reason string) error {
+	client, ok := c.clients[c.primary].(LLMTaskClient)
+	if !ok {
+		return fmt.Errorf("task client does not support LLM completion")
+	}
+	return client.FailPrompt(promptID, reason)
+}
+
+func (c *FederatedTaskClient) SubmitFLModelUpdate(sessionID, roundID, runnerID string, gradients map[string][]float64, weights map[string][]float64, dataSize int, loss, accuracy float64, trainingTime int) error {
+	client, ok := c.clients[c.primary].(FLTaskClient)
+	if !ok {
+		return fmt.Errorf("task client does not support FL model update submission")
+	}
+	return client.SubmitFLModelUpdate(sessionID, roundID, runnerID, gradients, weights, dataSize, loss, accuracy, trainingTime)
+}
+
+func (c *FederatedTaskClient) FetchTaskCredentials(taskID string) ([]models.TaskCredentials, error) {
+	client, ok := c.clientFor(taskID, models.TaskStatusRunning).(CredentialClient)
+	if !ok {
+		return nil, fmt.Errorf("task client does not support task credentials")
+	}
+	return client.FetchTaskCredentials(taskID)
+}
+
+func (c *FederatedTaskClient) StreamTaskLogs(taskID string, chunks []models.LogChunk) error {
+	client, ok := c.clientFor(taskID, models.TaskStatusRunning).(LogStreamClient)
+	if !ok {
+		return logstream.ErrRejected
+	}
+	return client.StreamTaskLogs(taskID, chunks)
+}
+
+func (c *FederatedTaskClient) JoinGang(taskID string, join models.GangJoin) (*models.GangStatus, error) {
+	client, ok := c.clientFor(taskID, models.TaskStatusRunning).(GangClient)
+	if !ok {
+		return nil, fmt.Errorf("task client does not support gang tasks")
+	}
+	return client.JoinGang(taskID, join)
+}
+
+func (c *FederatedTaskClient) GangStatus(taskID string) (*models.GangStatus, error) {
+	client, ok := c.clientFor(taskID, models.TaskStatusRunning).(GangClient)
+	if !ok {
+		return nil, fmt.Errorf("task client does not support gang tasks")
+	}
+	return client.GangStatus(taskID)
+}
+
+// federatedTaskType reports whether tasks of a type are taken from coordinators
+// other than the primary. LLM and federated learning tasks report to endpoints
+// only the primary is sent to.
+func federatedTaskType(taskType models.TaskType) bool {
+	return taskType != models.TaskTypeLLM && taskType != models.TaskTypeFederatedLearning
+}
+
+// coordinatorSource registers with a secondary coordinator, keeps its own
+// heartbeat to it and polls it for tasks
+type coordinatorSource struct {
+	coordinator  federation.Coordinator
+	client       *HTTPTaskClient
+	heartbeat    *heartbeat.HeartbeatService
+	registration models.RunnerRegistration
+	manifest     ports.ManifestProvider
+	deviceID     string
+	interval     time.Duration
+}
+
+// setManifestProvider sends the runner's manifest to the coordinator with the
+// registration and heartbeats
+func (s *coordinatorSource) setManifestProvider(provider ports.ManifestProvider) {
+	s.manifest = provider
+	s.heartbeat.SetManifestProvider(provider)
+}
+
+func (s *coordinatorSource) register(ctx context.Context) error {
+	registration := s.registration
+	if s.manifest != nil {
+		manifest, err := s.manifest.Manifest()
+		if err != nil {
+			return fmt.Errorf("failed to build runner manifest: %w", err)
+		}
+		registration.Manifest = manifest
+	}
+
+	body, err := json.Marshal(registration)
+	if err != nil {
+		return fmt.Errorf("failed to marshal register payload: %w", err)
+	}
+
+	baseURL := strings.TrimSuffix(s.coordinator.ServerURL, "/api")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/v1/runners", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create register request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Device-ID", s.deviceID)
+
+	resp, err := s.client.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send register request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("register request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var response struct {
+		ResultEncodings []string `json:"result_encodings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err == nil {
+		s.client.SetResultEncodings(response.ResultEncodings)
+	}
+	return nil
+}
+
+// poll queues the coordinator's available tasks until ctx is done
+func (s *coordinatorSource) poll(ctx context.Context, scheduler *federation.Scheduler) {
+	log := gologger.WithComponent("federation")
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		tasks, err := s.client.GetAvailableTasks()
+		if err != nil {
+			log.Warn().Err(err).Str("coordinator", s.coordinator.Name).Msg("Failed to fetch available tasks")
+		}
+		for _, t := range tasks {
+			if !federatedTaskType(t.Type) {
+				continue
+			}
+			if err := scheduler.Enqueue(s.coordinator.Name, t); err != nil {
+				log.Debug().Err(err).Str("coordinator", s.coordinator.Name).Msg("Stopped queueing tasks")
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startFederation registers with the secondary coordinators and starts taking
+// their queued tasks
+func (s *Service) startFederation() {
+	log := gologger.WithComponent("federation")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopFederation = cancel
+
+	for _, source := range s.coordinatorSources {
+		source.heartbeat.SetInterval(s.heartbeatInterval)
+		go func() {
+			if err := source.register(ctx); err != nil {
+				log.Error().Err(err).Str("coordinator", source.coordinator.Name).Msg("Failed to register with coordinator")
+				return
+			}
+			if err := source.heartbeat.Start(); err != nil {
+				log.Error().Err(err).Str("coordinator", source.coordinator.Name).Msg("Failed to start coordinator heartbeat")
+			}
+			log.Info().Str("coordinator", source.coordinator.Name).Int("weight", source.coordinator.Weight).Msg("Registered with coordinator")
+			source.poll(ctx, s.scheduler)
+		}()
+	}
+
+	go dispatchQueued(ctx, s.scheduler, s.federatedClient, s.webhookClient, s.pool)
+}
+
+func (s *Service) stopCoordinatorSources(ctx context.Context) {
+	log := gologger.WithComponent("federation")
+
+	if s.stopFederation != nil {
+		s.stopFederation()
+	}
+	for _, source := range s.coordinatorSources {
+		source.heartbeat.Stop()
+		if err := source.heartbeat.SendOfflineHeartbeat(ctx); err != nil {
+			log.Warn().Err(err).Str("coordinator", source.coordinator.Name).Msg("Failed to send offline heartbeat")
+		}
+	}
+}
+
+// dispatchQueued hands queued tasks to the dispatcher whenever the pool has a
+// free slot, so the order tasks start in is the scheduler's
+func dispatchQueued(ctx context.Context, scheduler *federation.Scheduler, tracker *FederatedTaskClient, dispatcher *webhook.WebhookClient, pool *task.Pool) {
+	log := gologger.WithComponent("federation")
+
+	for {
+		if !waitForSlot(ctx, pool) {
+			return
+		}
+		coordinator, t, err := scheduler.Next(ctx)
+		if err != nil {
+			return
+		}
+
+		payload, err := json.Marshal(t)
+		if err != nil {
+			log.Error().Err(err).Str("task_id", t.ID.String()).Msg("Failed to encode queued task")
+			continue
+		}
+
+		taskID := t.ID.String()
+		tracker.Track(taskID, coordinator)
+		result, err := dispatcher.Dispatch(webhook.WebhookMessage{Type: "available_tasks", Payload: payload})
+		switch {
+		case err != nil:
+			tracker.Forget(taskID)
+			log.Warn().Err(err).Str("task_id", taskID).Str("coordinator", coordinator).Msg("Dropped queued task")
+		case result.Status == webhook.DispatchBusy:
+			tracker.Forget(taskID)
+			scheduler.Requeue(coordinator, t)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(federationSlotCheck):
+			}
+		case result.Status == webhook.DispatchSkipped:
+			tracker.Forget(taskID)
+			log.Debug().Str("task_id", taskID).Str("coordinator", coordinator).Str("reason", result.Reason).Str("rule", result.Rule).Msg("Skipped queued task")
+		default:
+			log.Info().Str("task_id", taskID).Str("coordinator", coordinator).Msg("Started queued task")
+		}
+	}
+}
+
+func waitForSlot(ctx context.Context, pool *task.Pool) bool {
+	for {
+		status := pool.Status()
+		if status.Running+status.Queued < status.MaxConcurrent {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(federationSlotCheck):
+		}
+	}
+}
+
+func newCoordinatorSource(coordinator federation.Coordinator, client *HTTPTaskClient, registration models.RunnerRegistration, deviceID string, gpus []models.GPUInfo, interval time.Duration, statusProvider ports.TaskHandler) *coordinatorSource {
+	hb := heartbeat.NewHeartbeatService(heartbeat.HeartbeatConfig{
+		ServerURL:     coordinator.ServerURL,
+		DeviceID:      deviceID,
+		WalletAddress: registration.WalletAddress,
+		BaseInterval:  30 * time.Second,
+		MaxBackoff:    time.Minute,
+		BaseBackoff:   5 * time.Second,
+		MaxRetries:    3,
+		GPUs:          gpus,
+	}, statusProvider, sysmetrics.NewCollector(""))
+
+	if interval <= 0 {
+		interval = defaultCoordinatorPollInterval
+	}
+	return &coordinatorSource{
+		coordinator:  coordinator,
+		client:       client,
+		heartbeat:    hb,
+		registration: registration,
+		deviceID:     deviceID,
+		interval:     interval,
+	}
+}
@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/core/ports"
+	"github.com/theblitlabs/parity-runner/internal/federation"
+)
+
+func TestFederatedTaskClientRoutesUpdatesToOwningCoordinator(t *testing.T) {
+	primary := &recordingTaskClient{}
+	pool := &recordingTaskClient{}
+	scheduler := federation.NewScheduler([]federation.Coordinator{
+		{Name: "primary", Weight: 1},
+		{Name: "pool", Weight: 1},
+	}, 4)
+	client := NewFederatedTaskClient("primary", map[string]ports.TaskClient{"primary": primary, "pool": pool}, scheduler)
+
+	client.Track("queued", "pool")
+	for _, status := range []models.TaskStatus{models.TaskStatusRunning, models.TaskStatusCompleted} {
+		if err := client.UpdateTaskStatus("queued", status, nil); err != nil {
+			t.Fatalf("UpdateTaskStatus() error = %v", err)
+		}
+		if err := client.UpdateTaskStatus("pushed", status, nil); err != nil {
+			t.Fatalf("UpdateTaskStatus() error = %v", err)
+		}
+	}
+
+	if len(pool.updates) != 2 || pool.updates[0].taskID != "queued" {
+		t.Fatalf("pool updates = %+v, want the queued task's", pool.updates)
+	}
+	if len(primary.updates) != 2 || primary.updates[0].taskID != "pushed" {
+		t.Fatalf("primary updates = %+v, want the pushed task's", primary.updates)
+	}
+	if stats := scheduler.Stats(); stats[0].Started != 1 {
+		t.Fatalf("primary started = %d, want the pushed task charged to it", stats[0].Started)
+	}
+	if len(client.owners) != 0 {
+		t.Fatalf("owners = %v, want finished tasks forgotten", client.owners)
+	}
+}
@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/llm"
+	"github.com/theblitlabs/parity-runner/internal/execution/task"
+)
+
+// fleetApplyTimeout bounds applying one directive, most of which is pulling models
+const fleetApplyTimeout = 30 * time.Minute
+
+// ModelInstaller makes models available to the runner's LLM backend and returns
+// the ones it then serves
+type ModelInstaller func(ctx context.Context, models []string) ([]llm.ModelInfo, error)
+
+// policySetter is implemented by everything that enforces the runner policy
+type policySetter interface {
+	SetPolicy(policy models.RunnerPolicy)
+}
+
+// fleetMember applies the settings a fleet document manages to a running
+// runner. Changes last until the runner restarts, after which the server sends
+// them again. A directive is acted on once per generation, so a setting the
+// runner fails to apply stays reported as drift until the operator applies a
+// new document.
+type fleetMember struct {
+	pool       *task.Pool
+	handler    *DefaultTaskHandler
+	policies   []policySetter
+	setLabels  func(selector models.LabelSelector)
+	setModels  func(models []llm.ModelInfo)
+	mu         sync.Mutex
+	labels     models.LabelSelector
+	policy     models.RunnerPolicy
+	models     []string
+	installer  ModelInstaller
+	generation int64
+	applying   bool
+}
+
+func (m *fleetMember) FleetSettings() models.RunnerSettings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	settings := models.RunnerSettings{
+		AcceptLabels:       m.labels.String(),
+		MaxConcurrentTasks: m.pool.Status().MaxConcurrent,
+		TaskTypes:          []models.TaskType{},
+		Models:             slices.Clone(m.models),
+	}
+	for taskType := range m.policy.TaskTypes {
+		settings.TaskTypes = append(settings.TaskTypes, taskType)
+	}
+	slices.Sort(settings.TaskTypes)
+	if settings.Models == nil {
+		settings.Models = []string{}
+	}
+	return settings
+}
+
+func (m *fleetMember) ApplyFleetDirective(directive models.FleetDirective) {
+	m.mu.Lock()
+	if m.applying || directive.Generation <= m.generation {
+		m.mu.Unlock()
+		return
+	}
+	m.generation = directive.Generation
+	m.applying = true
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			m.applying = false
+			m.mu.Unlock()
+		}()
+		m.apply(directive)
+	}()
+}
+
+func (m *fleetMember) apply(directive models.FleetDirective) {
+	log := gologger.WithComponent("fleet").With().Int64("generation", directive.Generation).Logger()
+	settings := directive.Settings
+
+	if settings.AcceptLabels != nil {
+		selector, err := models.ParseLabelSelector(*settings.AcceptLabels)
+		if err != nil {
+			log.Error().Err(err).Msg("Ignoring invalid accept labels from fleet directive")
+		} else {
+			m.mu.Lock()
+			m.labels = selector
+			m.mu.Unlock()
+			m.setLabels(selector)
+			log.Info().Str("accept_labels", selector.String()).Msg("Applied accept labels")
+		}
+	}
+
+	if settings.MaxConcurrentTasks != nil {
+		n := max(*settings.MaxConcurrentTasks, 1)
+		m.pool.SetMaxConcurrent(n)
+		m.handler.SetMaxConcurrent(n)
+		log.Info().Int("max_concurrent_tasks", n).Msg("Applied task concurrency")
+	}
+
+	if settings.TaskTypes != nil {
+		m.mu.Lock()
+		policy := m.policy
+		policy.TaskTypes = nil
+		for _, taskType := range *settings.TaskTypes {
+			if policy.TaskTypes == nil {
+				policy.TaskTypes = make(map[models.TaskType]bool)
+			}
+			policy.TaskTypes[taskType] = true
+		}
+		m.policy = policy
+		m.mu.Unlock()
+		for _, setter := range m.policies {
+			setter.SetPolicy(policy)
+		}
+		log.Info().Str("policy", policy.String()).Msg("Applied runner policy")
+	}
+
+	if settings.Models != nil {
+		m.mu.Lock()
+		installer := m.installer
+		m.mu.Unlock()
+		if installer == nil {
+			log.Warn().Msg("Cannot apply fleet models: the runner was not started with LLM support")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), fleetApplyTimeout)
+		defer cancel()
+		installed, err := installer(ctx, *settings.Models)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to install fleet models")
+			return
+		}
+		m.setModels(installed)
+		log.Info().Int("models", len(installed)).Msg("Applied model set")
+	}
+}
+
+func (m *fleetMember) setInstaller(installer ModelInstaller) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.installer = installer
+}
+
+// recordModels keeps the names of the models the runner advertises
+func (m *fleetMember) recordModels(infos []llm.ModelInfo) {
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+	}
+	slices.Sort(names)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models = names
+}
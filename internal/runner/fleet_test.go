@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/llm"
+	"github.com/theblitlabs/parity-runner/internal/execution/task"
+)
+
+func TestFleetMemberAppliesDirectiveOncePerGeneration(t *testing.T) {
+	pool := task.NewPool(task.PoolConfig{MaxConcurrent: 1, Capacity: task.Reservation{CPUs: 8}, Default: task.Reservation{CPUs: 1}})
+	defer pool.Stop()
+
+	installs := make(chan []string, 2)
+	var labels models.LabelSelector
+	var advertised []llm.ModelInfo
+	member := &fleetMember{
+		pool:      pool,
+		handler:   NewTaskHandler(&stubTaskExecutor{}, &recordingTaskClient{}),
+		setLabels: func(selector models.LabelSelector) { labels = selector },
+		setModels: func(infos []llm.ModelInfo) { advertised = infos },
+	}
+	member.setInstaller(func(ctx context.Context, names []string) ([]llm.ModelInfo, error) {
+		installs <- names
+		return []llm.ModelInfo{{Name: "llama3:latest"}}, nil
+	})
+
+	three := 3
+	gpu := "gpu=true"
+	taskTypes := []models.TaskType{models.TaskTypeLLM}
+	modelSet := []string{"llama3"}
+	directive := models.FleetDirective{Generation: 1, Settings: models.FleetSettings{
+		AcceptLabels:       &gpu,
+		MaxConcurrentTasks: &three,
+		TaskTypes:          &taskTypes,
+		Models:             &modelSet,
+	}}
+	member.ApplyFleetDirective(directive)
+
+	select {
+	case <-installs:
+	case <-time.After(time.Second):
+		t.Fatal("models were not installed")
+	}
+	waitFor(t, func() bool {
+		member.mu.Lock()
+		defer member.mu.Unlock()
+		return !member.applying
+	})
+	member.recordModels(advertised)
+
+	settings := member.FleetSettings()
+	if settings.MaxConcurrentTasks != 3 || labels.String() != "gpu=true" {
+		t.Fatalf("FleetSettings() = %+v, labels = %q", settings, labels)
+	}
+	if !slices.Equal(settings.TaskTypes, taskTypes) || !slices.Equal(settings.Models, []string{"llama3:latest"}) {
+		t.Fatalf("FleetSettings() = %+v", settings)
+	}
+	if drift := directive.Settings.Drift(settings); len(drift) != 0 {
+		t.Fatalf("drift after applying = %+v", drift)
+	}
+
+	member.ApplyFleetDirective(directive)
+	select {
+	case <-installs:
+		t.Fatal("a directive was applied twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func waitFor(t *testing.T, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if done() {
+			return
+		}
+	}
+	t.Fatal("timed out waiting")
+}
@@ -0,0 +1,221 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/gang"
+	"github.com/theblitlabs/parity-runner/internal/peerchannel"
+)
+
+// ErrGangFailed is the cause of a gang member stopped because another member
+// dropped or the gang did not form in time
+var ErrGangFailed = errors.New("gang failed")
+
+var (
+	// gangPollInterval is how often a member waiting for the rest of its gang
+	// asks the server
+	gangPollInterval = 2 * time.Second
+	// gangWatchInterval is how often a running member checks its gang. It also
+	// tells the server the member is alive, so it stays well below the server's
+	// heartbeat timeout.
+	gangWatchInterval = 20 * time.Second
+)
+
+// GangClient lets the members of a gang task find each other
+type GangClient interface {
+	JoinGang(taskID string, join models.GangJoin) (*models.GangStatus, error)
+	GangStatus(taskID string) (*models.GangStatus, error)
+}
+
+// SetGangAddress sets the host name or IP other members of a gang reach this
+// runner at. Without it the address of the default route is used.
+func (h *DefaultTaskHandler) SetGangAddress(address string) {
+	h.gangAddress = address
+}
+
+func (h *DefaultTaskHandler) advertisedAddress() (string, error) {
+	if h.gangAddress != "" {
+		return h.gangAddress, nil
+	}
+	// Nothing is sent, connecting a UDP socket only picks the outgoing interface
+	conn, err := net.Dial("udp", "192.0.2.1:9")
+	if err != nil {
+		return "", fmt.Errorf("failed to determine the address peers reach this runner at: %w", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+// withGang joins the gang a member task belongs to and waits until every
+// member started. The gang is handed to the executor through ctx and watched
+// while the task runs; abort stops the task if the gang fails.
+func (h *DefaultTaskHandler) withGang(ctx context.Context, task *models.Task, abort context.CancelCauseFunc) (context.Context, error) {
+	if task.Type != models.TaskTypeDocker {
+		return ctx, nil
+	}
+	var config models.TaskConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return ctx, fmt.Errorf("invalid task config: %w", err)
+	}
+	if config.Gang == nil {
+		return ctx, nil
+	}
+
+	client, ok := h.taskClient.(GangClient)
+	if !ok {
+		return ctx, fmt.Errorf("task client does not support gang tasks")
+	}
+	address, err := h.advertisedAddress()
+	if err != nil {
+		return ctx, err
+	}
+
+	taskID := task.ID.String()
+	join := models.GangJoin{Endpoint: net.JoinHostPort(address, strconv.Itoa(config.Gang.ListenPort()))}
+	var channel *peerchannel.Channel
+	if config.Gang.Channel {
+		// Peers may connect as soon as the gang forms, so the channel listens
+		// before joining
+		if channel, err = listenChannel(config.Gang, address, &join); err != nil {
+			return ctx, err
+		}
+		go func() {
+			<-ctx.Done()
+			channel.Close()
+		}()
+	}
+
+	status, err := client.JoinGang(taskID, join)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to join gang: %w", err)
+	}
+	if status, err = waitForGang(ctx, client, taskID, status); err != nil {
+		return ctx, err
+	}
+	if channel != nil {
+		if ctx, err = withChannel(ctx, channel, status); err != nil {
+			return ctx, err
+		}
+	}
+
+	log := gologger.WithComponent("task_handler")
+	log.Info().
+		Str("id", taskID).
+		Str("gang_id", status.GangID).
+		Int("rank", status.Self.Rank).
+		Int("size", status.Size).
+		Msg("Gang formed, starting member")
+
+	go watchGang(ctx, client, taskID, abort)
+	return gang.WithStatus(ctx, status), nil
+}
+
+// listenChannel accepts peer channel connections for a member and adds where
+// and with which key to join
+func listenChannel(config *models.GangConfig, address string, join *models.GangJoin) (*peerchannel.Channel, error) {
+	key, err := peerchannel.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate channel key: %w", err)
+	}
+	port := strconv.Itoa(config.ChannelPort())
+	channel, err := peerchannel.Listen(":"+port, key)
+	if err != nil {
+		return nil, err
+	}
+	join.ChannelEndpoint = net.JoinHostPort(address, port)
+	join.ChannelKey = peerchannel.EncodePublicKey(key.PublicKey())
+	return channel, nil
+}
+
+// withChannel connects the channel to the formed gang and serves it to the
+// container on a socket in a directory that is removed when ctx is done
+func withChannel(ctx context.Context, channel *peerchannel.Channel, status *models.GangStatus) (context.Context, error) {
+	if err := channel.Join(status); err != nil {
+		return ctx, fmt.Errorf("failed to set up gang channel: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "parity-channel-")
+	if err != nil {
+		return ctx, fmt.Errorf("failed to create gang channel directory: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		os.RemoveAll(dir)
+	}()
+	// Containers that do not run as root must reach the socket
+	if err := os.Chmod(dir, 0o711); err != nil {
+		return ctx, fmt.Errorf("failed to create gang channel directory: %w", err)
+	}
+	if err := channel.ServeLocal(filepath.Join(dir, peerchannel.SocketName)); err != nil {
+		return ctx, err
+	}
+	return gang.WithChannelDir(ctx, dir), nil
+}
+
+// waitForGang polls until every member of the gang started
+func waitForGang(ctx context.Context, client GangClient, taskID string, status *models.GangStatus) (*models.GangStatus, error) {
+	ticker := time.NewTicker(gangPollInterval)
+	defer ticker.Stop()
+
+	for {
+		switch status.State {
+		case models.GangRunning:
+			if status.Self == nil {
+				return nil, fmt.Errorf("%w: server did not say which rank this runner has", ErrGangFailed)
+			}
+			return status, nil
+		case models.GangFailed:
+			return nil, fmt.Errorf("%w: %s", ErrGangFailed, status.Reason)
+		case models.GangCompleted:
+			return nil, fmt.Errorf("%w: gang already completed", ErrGangFailed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for the gang to form: %w", context.Cause(ctx))
+		case <-ticker.C:
+		}
+
+		next, err := client.GangStatus(taskID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get gang status: %w", err)
+		}
+		status = next
+	}
+}
+
+// watchGang stops the member when its gang fails, until ctx is done
+func watchGang(ctx context.Context, client GangClient, taskID string, abort context.CancelCauseFunc) {
+	log := gologger.WithComponent("task_handler")
+	ticker := time.NewTicker(gangWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		status, err := client.GangStatus(taskID)
+		if err != nil {
+			log.Warn().Err(err).Str("id", taskID).Msg("Failed to check gang")
+			continue
+		}
+		if status.State == models.GangFailed {
+			log.Warn().Str("id", taskID).Str("reason", status.Reason).Msg("Gang failed, stopping member")
+			abort(fmt.Errorf("%w: %s", ErrGangFailed, status.Reason))
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+type fakeGangClient struct {
+	statuses []*models.GangStatus
+	polls    int
+}
+
+func (c *fakeGangClient) JoinGang(taskID string, join models.GangJoin) (*models.GangStatus, error) {
+	return c.statuses[0], nil
+}
+
+func (c *fakeGangClient) GangStatus(taskID string) (*models.GangStatus, error) {
+	c.polls++
+	return c.statuses[min(c.polls, len(c.statuses)-1)], nil
+}
+
+func TestWaitForGang(t *testing.T) {
+	defer func(interval time.Duration) { gangPollInterval = interval }(gangPollInterval)
+	gangPollInterval = time.Millisecond
+
+	self := &models.GangMember{Rank: 1}
+	client := &fakeGangClient{statuses: []*models.GangStatus{
+		{State: models.GangForming},
+		{State: models.GangForming},
+		{State: models.GangRunning, Self: self},
+	}}
+	status, err := waitForGang(context.Background(), client, "task", client.statuses[0])
+	if err != nil {
+		t.Fatalf("waitForGang() error = %v", err)
+	}
+	if status.Self != self || client.polls != 2 {
+		t.Errorf("got rank %v after %d polls", status.Self, client.polls)
+	}
+
+	client = &fakeGangClient{statuses: []*models.GangStatus{
+		{State: models.GangForming},
+		{State: models.GangFailed, Reason: "only 1 of 2 members started"},
+	}}
+	if _, err := waitForGang(context.Background(), client, "task", client.statuses[0]); !errors.Is(err, ErrGangFailed) {
+		t.Fatalf("waitForGang() error = %v, want ErrGangFailed", err)
+	}
+}
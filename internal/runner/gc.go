@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox"
+)
+
+const (
+	defaultGCInterval = time.Hour
+	gcTimeout         = 2 * time.Minute
+)
+
+// sweep runs one garbage collection of the container runtime
+func sweep(ctx context.Context, collector sandbox.GarbageCollector) {
+	log := gologger.WithComponent("runner.gc")
+
+	ctx, cancel := context.WithTimeout(ctx, gcTimeout)
+	defer cancel()
+
+	report, err := collector.CollectGarbage(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Garbage collection incomplete")
+	}
+	if !report.Empty() {
+		log.Info().
+			Int("containers", report.Containers).
+			Int("images", report.Images).
+			Int("seccomp_profiles", report.SeccompProfiles).
+			Msg("Removed what earlier runs left behind")
+	}
+}
+
+// collectGarbage sweeps every interval until ctx is done
+func collectGarbage(ctx context.Context, collector sandbox.GarbageCollector, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweep(ctx, collector)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
+	"github.com/theblitlabs/parity-runner/internal/runner/runnerpb"
+	"github.com/theblitlabs/parity-runner/pkg/protocol"
+)
+
+// resultChunkSize is the output size above which results are streamed in chunks
+// of this size instead of sent in a single message
+const resultChunkSize = 1 << 20
+
+// GRPCTaskClient talks to the server's gRPC API. LLM prompt completion,
+// federated learning updates, task credentials and gangs have no gRPC
+// counterpart yet and go through the embedded HTTP client.
+type GRPCTaskClient struct {
+	*HTTPTaskClient
+	conn    *grpc.ClientConn
+	client  runnerpb.RunnerServiceClient
+	timeout time.Duration
+}
+
+func NewGRPCTaskClient(address, serverURL string, opts ...grpc.DialOption) (*GRPCTaskClient, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.NewClient(address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client for %s: %w", address, err)
+	}
+	return &GRPCTaskClient{
+		HTTPTaskClient: NewHTTPTaskClient(serverURL),
+		conn:           conn,
+		client:         runnerpb.NewRunnerServiceClient(conn),
+		timeout:        15 * time.Second,
+	}, nil
+}
+
+func (c *GRPCTaskClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *GRPCTaskClient) callContext() (context.Context, context.CancelFunc, error) {
+	deviceID, err := resolveDeviceID()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get device ID: %w", err)
+	}
+	nonce, err := identity.NewNonce()
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	return metadata.AppendToOutgoingContext(ctx, runnerpb.DeviceIDKey, deviceID, runnerpb.NonceKey, nonce), cancel, nil
+}
+
+// verifyCall checks the server identity signature the server put in the
+// trailer of a call, as verifyResponse does over HTTP
+func (c *GRPCTaskClient) verifyCall(ctx context.Context, fullMethod string, trailer metadata.MD, reply proto.Message) error {
+	if c.verifier == nil {
+		return nil
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(reply)
+	if err != nil {
+		return fmt.Errorf("failed to encode response for identity check: %w", err)
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	request := identity.CallRequest(fullMethod, firstValue(md, runnerpb.DeviceIDKey), firstValue(md, runnerpb.NonceKey))
+	err = c.verifier.Verify(identity.PurposeResponse, identity.ResponseBody(request, body), firstValue(trailer, runnerpb.TimestampKey), firstValue(trailer, runnerpb.SignatureKey))
+	if err != nil {
+		return fmt.Errorf("server response failed identity check: %w", err)
+	}
+	return nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c *GRPCTaskClient) FetchTask() (*models.Task, error) {
+	tasks, err := c.GetAvailableTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("no tasks available")
+	}
+
+	task := tasks[0]
+	if err := c.StartTask(task.ID.String()); err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func (c *GRPCTaskClient) UpdateTaskStatus(taskID string, status models.TaskStatus, result *models.TaskResult) error {
+	switch status {
+	case models.TaskStatusRunning:
+		return c.StartTask(taskID)
+	case models.TaskStatusCompleted, models.TaskStatusFailed, models.TaskStatusCancelled:
+		if result != nil {
+			return c.SaveTaskResult(taskID, result)
+		}
+		if status == models.TaskStatusCompleted {
+			return nil
+		}
+		return fmt.Errorf("task result is required when marking a task as %s", status)
+	default:
+		return fmt.Errorf("unsupported status: %s", status)
+	}
+}
+
+func (c *GRPCTaskClient) GetAvailableTasks() ([]*models.Task, error) {
+	ctx, cancel, err := c.callContext()
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := c.client.ListAvailableTasks(ctx, &runnerpb.ListAvailableTasksRequest{}, grpc.Trailer(&trailer))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list available tasks: %w", err)
+	}
+	if err := c.verifyCall(ctx, runnerpb.RunnerService_ListAvailableTasks_FullMethodName, trailer, resp); err != nil {
+		return nil, err
+	}
+
+	tasks := make([]*models.Task, 0, len(resp.GetTasks()))
+	for _, msg := range resp.GetTasks() {
+		task, err := msg.Model()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode task: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+func (c *GRPCTaskClient) StartTask(taskID string) error {
+	ctx, cancel, err := c.callContext()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := c.client.StartTask(ctx, &runnerpb.StartTaskRequest{TaskId: taskID}, grpc.Trailer(&trailer))
+	if err != nil {
+		return fmt.Errorf("failed to start task %s: %w", taskID, err)
+	}
+	return c.verifyCall(ctx, runnerpb.RunnerService_StartTask_FullMethodName, trailer, resp)
+}
+
+func (c *GRPCTaskClient) CompleteTask(taskID string) error {
+	return nil
+}
+
+// SaveTaskResult submits the result, streaming its output when it is larger than
+// resultChunkSize
+func (c *GRPCTaskClient) SaveTaskResult(taskID string, result *models.TaskResult) error {
+	ctx, cancel, err := c.callContext()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if result.TaskID == uuid.Nil {
+		result.TaskID = uuid.MustParse(taskID)
+	}
+	if result.CreatedAt.IsZero() {
+		result.CreatedAt = time.Now()
+	}
+	if result.RunnerAddress == "" {
+		deviceID, err := resolveDeviceID()
+		if err != nil {
+			return fmt.Errorf("failed to get device ID: %w", err)
+		}
+		result.RunnerAddress = deviceID
+	}
+
+	msg, err := protocol.FromTaskResult(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	if len(msg.Output) <= resultChunkSize {
+		if _, err := c.client.SubmitResult(ctx, &runnerpb.SubmitResultRequest{Result: msg}); err != nil {
+			return fmt.Errorf("failed to submit result for task %s: %w", taskID, rejected(err))
+		}
+		return nil
+	}
+
+	if err := c.streamResult(ctx, msg); err != nil {
+		return fmt.Errorf("failed to stream result for task %s: %w", taskID, rejected(err))
+	}
+	return nil
+}
+
+// rejected marks the errors with which the server refuses a result for good
+// as outbox.ErrRejected
+func rejected(err error) error {
+	switch status.Code(err) {
+	case codes.FailedPrecondition, codes.InvalidArgument, codes.NotFound, codes.PermissionDenied:
+		return fmt.Errorf("%w: %w", outbox.ErrRejected, err)
+	}
+	return err
+}
+
+func (c *GRPCTaskClient) streamResult(ctx context.Context, msg *protocol.TaskResult) error {
+	stream, err := c.client.StreamResult(ctx)
+	if err != nil {
+		return err
+	}
+
+	output := []byte(msg.Output)
+	msg.Output = ""
+	if err := stream.Send(&runnerpb.ResultChunk{Chunk: &runnerpb.ResultChunk_Result{Result: msg}}); err != nil {
+		return err
+	}
+	for len(output) > 0 {
+		size := min(len(output), resultChunkSize)
+		if err := stream.Send(&runnerpb.ResultChunk{Chunk: &runnerpb.ResultChunk_Output{Output: output[:size]}}); err != nil {
+			return err
+		}
+		output = output[size:]
+	}
+
+	_, err = stream.CloseAndRecv()
+	return err
+}
@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/runner/runnerpb"
+	"github.com/theblitlabs/parity-runner/pkg/protocol"
+)
+
+type fakeRunnerService struct {
+	runnerpb.UnimplementedRunnerServiceServer
+	tasks    []*protocol.Task
+	started  []string
+	unary    []*protocol.TaskResult
+	streamed []*protocol.TaskResult
+}
+
+func (s *fakeRunnerService) ListAvailableTasks(ctx context.Context, _ *runnerpb.ListAvailableTasksRequest) (*runnerpb.ListAvailableTasksResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(runnerpb.DeviceIDKey); len(ids) == 0 || ids[0] != "runner-1" {
+		return nil, status.Error(codes.InvalidArgument, "missing device ID")
+	}
+	return &runnerpb.ListAvailableTasksResponse{Tasks: s.tasks}, nil
+}
+
+func (s *fakeRunnerService) StartTask(_ context.Context, req *runnerpb.StartTaskRequest) (*runnerpb.StartTaskResponse, error) {
+	s.started = append(s.started, req.GetTaskId())
+	return &runnerpb.StartTaskResponse{}, nil
+}
+
+func (s *fakeRunnerService) SubmitResult(_ context.Context, req *runnerpb.SubmitResultRequest) (*runnerpb.SubmitResultResponse, error) {
+	s.unary = append(s.unary, req.GetResult())
+	return &runnerpb.SubmitResultResponse{PayoutApproved: true}, nil
+}
+
+func (s *fakeRunnerService) StreamResult(stream runnerpb.RunnerService_StreamResultServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	result := first.GetResult()
+	var output strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		output.Write(chunk.GetOutput())
+	}
+	result.Output = output.String()
+	s.streamed = append(s.streamed, result)
+	return stream.SendAndClose(&runnerpb.SubmitResultResponse{PayoutApproved: true})
+}
+
+func newTestGRPCTaskClient(t *testing.T, service *fakeRunnerService, opts ...grpc.ServerOption) *GRPCTaskClient {
+	t.Helper()
+
+	originalResolveDeviceID := resolveDeviceID
+	resolveDeviceID = func() (string, error) { return "runner-1", nil }
+	t.Cleanup(func() { resolveDeviceID = originalResolveDeviceID })
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(opts...)
+	runnerpb.RegisterRunnerServiceServer(server, service)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	client, err := NewGRPCTaskClient("passthrough:///bufnet", "http://localhost:8080",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewGRPCTaskClient() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestGRPCTaskClientFetchTask(t *testing.T) {
+	taskID := uuid.New()
+	service := &fakeRunnerService{tasks: []*protocol.Task{{
+		Id:                 taskID.String(),
+		Type:               protocol.TaskType_TASK_TYPE_DOCKER,
+		Config:             []byte(`{"image_name":"alpine"}`),
+		Environment:        []byte(`{"type":"docker"}`),
+		MaxDurationSeconds: 600,
+	}}}
+	client := newTestGRPCTaskClient(t, service)
+
+	task, err := client.FetchTask()
+	if err != nil {
+		t.Fatalf("FetchTask() error = %v", err)
+	}
+	if task.ID != taskID || task.Type != models.TaskTypeDocker || task.Environment == nil || task.Environment.Type != "docker" {
+		t.Fatalf("FetchTask() = %+v", task)
+	}
+	if task.MaxDurationSecs != 600 {
+		t.Fatalf("max duration = %d, want 600", task.MaxDurationSecs)
+	}
+	if len(service.started) != 1 || service.started[0] != taskID.String() {
+		t.Fatalf("started = %v, want [%s]", service.started, taskID)
+	}
+}
+
+func TestGRPCTaskClientStreamsLargeOutputs(t *testing.T) {
+	service := &fakeRunnerService{}
+	client := newTestGRPCTaskClient(t, service)
+
+	small := &models.TaskResult{TaskID: uuid.New(), Output: "done"}
+	if err := client.UpdateTaskStatus(small.TaskID.String(), models.TaskStatusCompleted, small); err != nil {
+		t.Fatalf("UpdateTaskStatus() small result error = %v", err)
+	}
+
+	large := &models.TaskResult{TaskID: uuid.New(), Output: strings.Repeat("x", resultChunkSize*2+10)}
+	if err := client.UpdateTaskStatus(large.TaskID.String(), models.TaskStatusCompleted, large); err != nil {
+		t.Fatalf("UpdateTaskStatus() large result error = %v", err)
+	}
+
+	if len(service.unary) != 1 || service.unary[0].GetOutput() != "done" || service.unary[0].GetRunnerAddress() != "runner-1" {
+		t.Fatalf("unary results = %v", service.unary)
+	}
+	if len(service.streamed) != 1 || service.streamed[0].GetOutput() != large.Output {
+		t.Fatalf("streamed %d results, want the large output reassembled", len(service.streamed))
+	}
+}
+
+// signingInterceptor signs responses the way the server does, bound to the
+// method, device and nonce of the call
+func signingInterceptor(signer *identity.Signer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(resp.(proto.Message))
+		if err != nil {
+			return nil, err
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		request := identity.CallRequest(info.FullMethod, firstValue(md, runnerpb.DeviceIDKey), firstValue(md, runnerpb.NonceKey))
+		timestamp, signature, err := signer.Sign(identity.PurposeResponse, identity.ResponseBody(request, body))
+		if err != nil {
+			return nil, err
+		}
+		return resp, grpc.SetTrailer(ctx, metadata.Pairs(runnerpb.TimestampKey, timestamp, runnerpb.SignatureKey, signature))
+	}
+}
+
+func TestGRPCTaskClientVerifiesServerIdentity(t *testing.T) {
+	serverKey, _ := crypto.GenerateKey()
+	impostorKey, _ := crypto.GenerateKey()
+	pinned := identity.NewSigner(serverKey)
+	verifier, err := identity.NewVerifier(pinned.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks := []*protocol.Task{{Id: uuid.New().String(), Type: protocol.TaskType_TASK_TYPE_DOCKER, Config: []byte(`{}`)}}
+
+	client := newTestGRPCTaskClient(t, &fakeRunnerService{tasks: tasks}, grpc.ChainUnaryInterceptor(signingInterceptor(pinned)))
+	client.SetServerVerifier(verifier)
+	if _, err := client.FetchTask(); err != nil {
+		t.Fatalf("FetchTask() from the pinned server error = %v", err)
+	}
+
+	impostor := newTestGRPCTaskClient(t, &fakeRunnerService{tasks: tasks}, grpc.ChainUnaryInterceptor(signingInterceptor(identity.NewSigner(impostorKey))))
+	impostor.SetServerVerifier(verifier)
+	if _, err := impostor.GetAvailableTasks(); err == nil {
+		t.Fatal("GetAvailableTasks() accepted tasks signed by another server")
+	}
+
+	unsigned := newTestGRPCTaskClient(t, &fakeRunnerService{tasks: tasks})
+	unsigned.SetServerVerifier(verifier)
+	if err := unsigned.StartTask(tasks[0].Id); err == nil {
+		t.Fatal("StartTask() accepted an unsigned response")
+	}
+}
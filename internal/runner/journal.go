@@ -0,0 +1,194 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/journal"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
+)
+
+// ErrTaskUnknown is returned by TaskStateClient for tasks the server does not
+// hold for this runner
+var ErrTaskUnknown = errors.New("task unknown to the server")
+
+// TaskStateClient asks the server what became of tasks the runner held before
+// it restarted, and hands back those it will not finish
+type TaskStateClient interface {
+	TaskState(taskID string) (*models.RunnerTaskState, error)
+	ReleaseTask(taskID string) error
+}
+
+// RecordAccepted keeps a task the runner accepted and queued, so it is picked
+// up again after a restart
+func (h *DefaultTaskHandler) RecordAccepted(task *models.Task) {
+	h.markAccepted(task.ID.String(), true)
+	if h.journal == nil {
+		return
+	}
+	if err := h.journal.Accept(task, time.Now()); err != nil {
+		log := gologger.WithComponent("task_handler")
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to record accepted task")
+	}
+}
+
+// ForgetTask drops a task whose result was delivered or kept, or which the
+// runner gave up before claiming it, from the journal
+func (h *DefaultTaskHandler) ForgetTask(taskID string) {
+	h.markAccepted(taskID, false)
+	if h.journal == nil {
+		return
+	}
+	if err := h.journal.Remove(taskID); err != nil {
+		log := gologger.WithComponent("task_handler")
+		log.Warn().Err(err).Str("id", taskID).Msg("Failed to remove task from the journal")
+	}
+}
+
+// journalStart records that the runner claimed a task
+func (h *DefaultTaskHandler) journalStart(task *models.Task) {
+	if h.journal == nil {
+		return
+	}
+	if err := h.journal.Start(task, time.Now()); err != nil {
+		log := gologger.WithComponent("task_handler")
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to record started task")
+	}
+}
+
+// reconcileReport counts what one reconcileTasks did
+type reconcileReport struct {
+	Resumed  int
+	Released int
+	Dropped  int
+	Pending  int
+}
+
+// reconcileTasks settles the tasks the journal holds from before the runner
+// restarted. Tasks the server still queues, and claimed tasks that can resume
+// from a checkpoint, are dispatched again; other claimed tasks are released
+// back to the queue. Tasks that finished, went to another runner or whose
+// result waits in the outbox are dropped. Tasks the server could not be asked
+// about stay in the journal for the next attempt.
+func reconcileTasks(store *journal.Store, results *outbox.Store, client TaskStateClient, dispatch func(*models.Task) bool) (reconcileReport, error) {
+	var report reconcileReport
+	entries, err := store.List()
+	if err != nil {
+		return report, err
+	}
+
+	undelivered := make(map[string]bool)
+	if results != nil {
+		queued, err := results.List()
+		if err != nil {
+			return report, err
+		}
+		dead, err := results.Dead()
+		if err != nil {
+			return report, err
+		}
+		for _, entry := range append(queued, dead...) {
+			undelivered[entry.TaskID] = true
+		}
+	}
+
+	release := func(taskID string) error {
+		if err := client.ReleaseTask(taskID); err != nil && !errors.Is(err, ErrTaskUnknown) {
+			report.Pending++
+			return err
+		}
+		report.Released++
+		return store.Remove(taskID)
+	}
+
+	var lastErr error
+	for _, entry := range entries {
+		taskID := entry.Task.ID.String()
+		if undelivered[taskID] {
+			report.Dropped++
+			if err := store.Remove(taskID); err != nil {
+				return report, err
+			}
+			continue
+		}
+
+		state, err := client.TaskState(taskID)
+		switch {
+		case errors.Is(err, ErrTaskUnknown):
+			report.Dropped++
+			if err := store.Remove(taskID); err != nil {
+				return report, err
+			}
+		case err != nil:
+			report.Pending++
+			lastErr = err
+		case state.Status == models.TaskStatusPending,
+			state.Status == models.TaskStatusRunning && state.Assigned && entry.Resumable():
+			// Dispatching records the task again once it is accepted
+			if err := store.Remove(taskID); err != nil {
+				return report, err
+			}
+			switch {
+			case dispatch(entry.Task):
+				report.Resumed++
+			case state.Assigned:
+				if err := store.Accept(entry.Task, entry.AcceptedAt); err != nil {
+					return report, err
+				}
+				if err := release(taskID); err != nil {
+					lastErr = err
+				}
+			default:
+				report.Dropped++
+			}
+		case state.Status == models.TaskStatusRunning && state.Assigned:
+			if err := release(taskID); err != nil {
+				lastErr = err
+			}
+		default:
+			report.Dropped++
+			if err := store.Remove(taskID); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, lastErr
+}
+
+// reconcileJournal reconciles the journal once the runner had time to
+// register, and again every outboxInterval while the server cannot be asked
+// about some of the tasks
+func reconcileJournal(ctx context.Context, store *journal.Store, results *outbox.Store, client TaskStateClient, dispatch func(*models.Task) bool) {
+	log := gologger.WithComponent("runner.journal")
+	timer := time.NewTimer(outboxFirstDelivery)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		report, err := reconcileTasks(store, results, client, dispatch)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to reconcile tasks held before the restart")
+		}
+		if report.Resumed > 0 || report.Released > 0 || report.Dropped > 0 {
+			log.Info().
+				Int("resumed", report.Resumed).
+				Int("released", report.Released).
+				Int("dropped", report.Dropped).
+				Int("pending", report.Pending).
+				Msg("Reconciled tasks held before the restart")
+		}
+		if report.Pending == 0 && err == nil {
+			return
+		}
+		timer.Reset(outboxInterval)
+	}
+}
@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/journal"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
+)
+
+type fakeTaskStateClient struct {
+	states   map[string]*models.RunnerTaskState
+	down     bool
+	released []string
+}
+
+func (c *fakeTaskStateClient) TaskState(taskID string) (*models.RunnerTaskState, error) {
+	if c.down {
+		return nil, errors.New("server unreachable")
+	}
+	state, ok := c.states[taskID]
+	if !ok {
+		return nil, ErrTaskUnknown
+	}
+	return state, nil
+}
+
+func (c *fakeTaskStateClient) ReleaseTask(taskID string) error {
+	c.released = append(c.released, taskID)
+	return nil
+}
+
+func TestReconcileTasksResumesOrReleasesHeldTasks(t *testing.T) {
+	store := journal.NewStore(t.TempDir())
+	results := outbox.NewStore(t.TempDir())
+	now := time.Now()
+
+	hold := func(taskType models.TaskType, config models.TaskConfig, started bool) *models.Task {
+		t.Helper()
+		task := models.NewTask()
+		task.Type = taskType
+		task.Config, _ = json.Marshal(config)
+		if err := store.Accept(task, now); err != nil {
+			t.Fatalf("Accept() = %v", err)
+		}
+		if started {
+			if err := store.Start(task, now); err != nil {
+				t.Fatalf("Start() = %v", err)
+			}
+		}
+		return task
+	}
+	checkpointed := models.TaskConfig{Checkpoint: &models.CheckpointConfig{Enabled: true}}
+
+	queued := hold(models.TaskTypeCommand, models.TaskConfig{}, false)
+	claimed := hold(models.TaskTypeCommand, models.TaskConfig{}, true)
+	resumable := hold(models.TaskTypeDocker, checkpointed, true)
+	reassigned := hold(models.TaskTypeCommand, models.TaskConfig{}, true)
+	forgotten := hold(models.TaskTypeCommand, models.TaskConfig{}, false)
+	finished := hold(models.TaskTypeCommand, models.TaskConfig{}, true)
+	if err := results.Put(outbox.Entry{TaskID: finished.ID.String(), Status: models.TaskStatusCompleted, QueuedAt: now}); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+
+	running := func(assigned bool) *models.RunnerTaskState {
+		return &models.RunnerTaskState{Status: models.TaskStatusRunning, Assigned: assigned}
+	}
+	client := &fakeTaskStateClient{down: true, states: map[string]*models.RunnerTaskState{
+		queued.ID.String():     {Status: models.TaskStatusPending},
+		claimed.ID.String():    running(true),
+		resumable.ID.String():  running(true),
+		reassigned.ID.String(): running(false),
+		finished.ID.String():   running(true),
+	}}
+	var dispatched []string
+	dispatch := func(task *models.Task) bool {
+		dispatched = append(dispatched, task.ID.String())
+		return true
+	}
+
+	// Offline, only the task whose result waits in the outbox is settled
+	report, err := reconcileTasks(store, results, client, dispatch)
+	if err == nil || report.Pending != 5 || report.Dropped != 1 {
+		t.Fatalf("offline reconcile = %+v, %v, want 5 pending and 1 dropped", report, err)
+	}
+	if entries, _ := store.List(); len(entries) != 5 {
+		t.Fatalf("journal holds %d tasks, want the 5 the server was not asked about", len(entries))
+	}
+
+	client.down = false
+	report, err = reconcileTasks(store, results, client, dispatch)
+	if err != nil {
+		t.Fatalf("reconcileTasks() = %v", err)
+	}
+	if report != (reconcileReport{Resumed: 2, Released: 1, Dropped: 2}) {
+		t.Fatalf("report = %+v, want 2 resumed, 1 released and 2 dropped", report)
+	}
+	if len(dispatched) != 2 || len(client.released) != 1 || client.released[0] != claimed.ID.String() {
+		t.Fatalf("dispatched %v and released %v, want the queued and resumable tasks dispatched and the claimed one released", dispatched, client.released)
+	}
+	for _, task := range []*models.Task{queued, claimed, resumable, reassigned, forgotten, finished} {
+		if _, err := store.Get(task.ID.String()); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("task %s is still in the journal: %v", task.ID, err)
+		}
+	}
+}
+
+func TestReconcileTasksReleasesResumableTaskTheRunnerRefuses(t *testing.T) {
+	store := journal.NewStore(t.TempDir())
+	task := models.NewTask()
+	task.Type = models.TaskTypeDocker
+	task.Config, _ = json.Marshal(models.TaskConfig{Checkpoint: &models.CheckpointConfig{Enabled: true}})
+	if err := store.Start(task, time.Now()); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+
+	client := &fakeTaskStateClient{states: map[string]*models.RunnerTaskState{
+		task.ID.String(): {Status: models.TaskStatusRunning, Assigned: true},
+	}}
+	report, err := reconcileTasks(store, nil, client, func(*models.Task) bool { return false })
+	if err != nil || report.Released != 1 {
+		t.Fatalf("reconcileTasks() = %+v, %v, want the task released", report, err)
+	}
+	if entries, _ := store.List(); len(entries) != 0 {
+		t.Fatalf("journal holds %+v, want nothing", entries)
+	}
+}
+
+// journalCheckingTaskClient fails every result and notes whether the journal
+// still held the task when it was offered
+type journalCheckingTaskClient struct {
+	recordingTaskClient
+	journal   *journal.Store
+	journaled bool
+}
+
+func (c *journalCheckingTaskClient) UpdateTaskStatus(taskID string, status models.TaskStatus, result *models.TaskResult) error {
+	if status == models.TaskStatusRunning {
+		return c.recordingTaskClient.UpdateTaskStatus(taskID, status, result)
+	}
+	_, err := c.journal.Get(taskID)
+	c.journaled = err == nil
+	return errors.New("server unreachable")
+}
+
+func TestHandleTaskForgetsTaskOnceItsResultIsKept(t *testing.T) {
+	run := func(t *testing.T, results *outbox.Store) (*models.Task, *journal.Store) {
+		t.Helper()
+		task := &models.Task{
+			ID:    uuid.New(),
+			Type:  models.TaskTypeCommand,
+			Nonce: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		}
+		store := journal.NewStore(t.TempDir())
+		client := &journalCheckingTaskClient{journal: store}
+		handler := NewTaskHandler(&stubTaskExecutor{result: &models.TaskResult{Output: "done"}}, client)
+		handler.SetJournal(store)
+		handler.SetOutbox(results)
+
+		handler.RecordAccepted(task)
+		if err := handler.HandleTask(task); err == nil {
+			t.Fatal("HandleTask() should report the failed submission")
+		}
+		if !client.journaled {
+			t.Fatal("task left the journal before its result was offered")
+		}
+		return task, store
+	}
+
+	t.Run("queued", func(t *testing.T) {
+		results := outbox.NewStore(t.TempDir())
+		task, store := run(t, results)
+		if entries, _ := results.List(); len(entries) != 1 || entries[0].TaskID != task.ID.String() {
+			t.Fatalf("queued results = %+v, want the task's result", entries)
+		}
+		if _, err := store.Get(task.ID.String()); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("task is still in the journal once the outbox holds its result: %v", err)
+		}
+	})
+
+	t.Run("not kept", func(t *testing.T) {
+		// The outbox cannot be written where a file stands in for its directory
+		blocked := filepath.Join(t.TempDir(), "outbox")
+		if err := os.WriteFile(blocked, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		task, store := run(t, outbox.NewStore(blocked))
+		if _, err := store.Get(task.ID.String()); err != nil {
+			t.Fatalf("task left the journal though its result was lost: %v", err)
+		}
+	})
+}
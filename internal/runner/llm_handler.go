@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/theblitlabs/gologger"
 
 	"github.com/theblitlabs/parity-runner/internal/execution/llm"
+	"github.com/theblitlabs/parity-runner/internal/supervisor"
 )
 
 type LLMHandler struct {
@@ -136,3 +138,46 @@ func (h *LLMHandler) IsHealthy(ctx context.Context) bool {
 func (h *LLMHandler) SetupOllama(ctx context.Context) error {
 	return h.manager.SetupComplete(ctx)
 }
+
+// Subsystem lets the runner's supervisor restart the Ollama container when
+// Ollama stops responding
+func (h *LLMHandler) Subsystem() supervisor.Subsystem {
+	return supervisor.Subsystem{
+		Name: "ollama",
+		Check: func(ctx context.Context) error {
+			if !h.manager.IsHealthy(ctx) {
+				return fmt.Errorf("ollama is not responding")
+			}
+			return nil
+		},
+		Restart: h.manager.StartOllama,
+	}
+}
+
+// InstallModels pulls the models that are missing and returns the requested
+// ones Ollama then serves
+func (h *LLMHandler) InstallModels(ctx context.Context, models []string) ([]llm.ModelInfo, error) {
+	h.manager.SetModels(models)
+	if err := h.manager.EnsureModelsAvailable(ctx); err != nil {
+		return nil, err
+	}
+
+	available, err := h.manager.GetAvailableModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list available models: %w", err)
+	}
+	requested := make(map[string]bool, len(models))
+	for _, name := range models {
+		requested[name] = true
+		if !strings.Contains(name, ":") {
+			requested[name+":latest"] = true
+		}
+	}
+	installed := make([]llm.ModelInfo, 0, len(models))
+	for _, model := range available {
+		if requested[model.Name] {
+			installed = append(installed, model)
+		}
+	}
+	return installed, nil
+}
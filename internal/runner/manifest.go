@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/task"
+	"github.com/theblitlabs/parity-runner/internal/idle"
+	"github.com/theblitlabs/parity-runner/internal/manifest"
+	"github.com/theblitlabs/parity-runner/internal/signer"
+)
+
+// manifestBuilder assembles the runner's manifest from what it runs, its policy
+// and how busy its worker pool is, and signs it with the wallet's signer. It
+// follows the policy and models a fleet document applies. A signer that only
+// signs transactions, such as a Trezor, leaves the runner without a manifest.
+type manifestBuilder struct {
+	deviceID  string
+	taskTypes []models.TaskType
+	hardware  models.RunnerHardware
+	pool      *task.Pool
+	idle      *idle.Monitor
+	signer    func() (signer.Signer, error)
+	unsigned  sync.Once
+	mu        sync.Mutex
+	policy    models.RunnerPolicy
+	models    []models.ModelCapability
+}
+
+// SetPolicy limits the advertised task types to the ones the policy accepts and
+// advertises its minimum rewards
+func (b *manifestBuilder) SetPolicy(policy models.RunnerPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.policy = policy
+}
+
+func (b *manifestBuilder) setModels(capabilities []models.ModelCapability) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.models = slices.Clone(capabilities)
+}
+
+func (b *manifestBuilder) Manifest() (*models.RunnerManifest, error) {
+	b.mu.Lock()
+	policy := b.policy
+	m := &models.RunnerManifest{
+		Version:    models.ManifestVersion,
+		DeviceID:   b.deviceID,
+		TaskTypes:  []models.TaskType{},
+		Models:     slices.Clone(b.models),
+		Hardware:   b.hardware,
+		MinRewards: slices.Clone(policy.MinRewards),
+		IssuedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	b.mu.Unlock()
+
+	for _, taskType := range b.taskTypes {
+		if len(policy.TaskTypes) == 0 || policy.TaskTypes[taskType] {
+			m.TaskTypes = append(m.TaskTypes, taskType)
+		}
+	}
+
+	if b.pool != nil {
+		status := b.pool.Status()
+		m.Availability.Running = status.Running
+		m.Availability.Slots = max(status.MaxConcurrent-status.Running-status.Queued, 0)
+	}
+	// An idle-mode runner takes no work while its user is present
+	m.Availability.Accepting = true
+	if b.idle != nil {
+		if state := b.idle.State(); !state.CheckedAt.IsZero() && !state.Idle {
+			m.Availability.Accepting = false
+		}
+	}
+
+	s, err := b.signer()
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.SignWith(m, s); err != nil {
+		if errors.Is(err, signer.ErrDigestNotSupported) {
+			b.unsigned.Do(func() {
+				log := gologger.WithComponent("manifest")
+				log.Warn().Msg("The wallet's signer cannot sign messages, the runner is registering without a manifest")
+			})
+			return nil, nil
+		}
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/execution/task"
+	"github.com/theblitlabs/parity-runner/internal/manifest"
+	"github.com/theblitlabs/parity-runner/internal/signer"
+)
+
+func TestManifestBuilderFollowsPolicyAndPool(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := task.NewPool(task.PoolConfig{MaxConcurrent: 2, Capacity: task.Reservation{CPUs: 8}, Default: task.Reservation{CPUs: 1}})
+	defer pool.Stop()
+
+	builder := &manifestBuilder{
+		deviceID:  "device-1",
+		taskTypes: []models.TaskType{models.TaskTypeDocker, models.TaskTypeLLM, models.TaskTypeWasm},
+		hardware:  models.RunnerHardware{CPUs: 8, Runtime: "docker"},
+		pool:      pool,
+		signer:    func() (signer.Signer, error) { return signer.FromKey(key), nil },
+	}
+	builder.SetPolicy(models.RunnerPolicy{
+		TaskTypes:  map[models.TaskType]bool{models.TaskTypeDocker: true, models.TaskTypeLLM: true},
+		MinRewards: models.MinRewards{{Type: models.TaskTypeLLM, Reward: 0.02}},
+	})
+	builder.setModels([]models.ModelCapability{{ModelName: "llama3:8b", IsLoaded: true}})
+
+	m, err := builder.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manifest.Verify(m); err != nil {
+		t.Fatalf("manifest does not verify: %v", err)
+	}
+	if !slices.Equal(m.TaskTypes, []models.TaskType{models.TaskTypeDocker, models.TaskTypeLLM}) {
+		t.Fatalf("task types = %v, want the ones the policy accepts", m.TaskTypes)
+	}
+	if m.MinRewards.For(models.TaskTypeLLM, "") != 0.02 || len(m.Models) != 1 {
+		t.Fatalf("manifest = %+v", m)
+	}
+	if !m.Availability.Accepting || m.Availability.Slots != 2 {
+		t.Fatalf("availability = %+v, want 2 free slots", m.Availability)
+	}
+}
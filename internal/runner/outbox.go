@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/ports"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
+)
+
+const (
+	// outboxFirstDelivery leaves the runner time to register before queued
+	// results are sent
+	outboxFirstDelivery = 30 * time.Second
+	outboxInterval      = 5 * time.Minute
+	// outboxMaxAge is how long a result is offered before the server is taken
+	// to have reassigned its task and the result becomes a dead letter
+	outboxMaxAge = 7 * 24 * time.Hour
+)
+
+// deliverQueued sends what the outbox holds once
+func deliverQueued(store *outbox.Store, client ports.TaskClient) {
+	log := gologger.WithComponent("runner.outbox")
+
+	report, err := store.Deliver(client, outboxMaxAge, time.Now())
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to deliver queued task results")
+	}
+	if report.Delivered > 0 || report.Expired > 0 || report.Rejected > 0 {
+		log.Info().
+			Int("delivered", report.Delivered).
+			Int("pending", report.Pending).
+			Int("expired", report.Expired).
+			Int("rejected", report.Rejected).
+			Msg("Delivered queued task results")
+	}
+}
+
+// deliverOutbox delivers queued results every outboxInterval until ctx is done
+func deliverOutbox(ctx context.Context, store *outbox.Store, client ports.TaskClient) {
+	timer := time.NewTimer(outboxFirstDelivery)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			deliverQueued(store, client)
+			timer.Reset(outboxInterval)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+)
+
+const outputUploadTimeout = 5 * time.Minute
+
+// SetResultUpload makes task outputs larger than threshold bytes go to content,
+// with only their CID and hash submitted
+func (h *DefaultTaskHandler) SetResultUpload(content ipfs.Backend, threshold int64) {
+	h.uploads = content
+	h.uploadThreshold = threshold
+}
+
+// withUploadedOutput returns the result to submit for a large output: a copy
+// whose output was added to IPFS and replaced by an upload. The local result
+// keeps its output for the artifacts and federated learning updates that read
+// it. If the upload fails the output is sent inline.
+func (h *DefaultTaskHandler) withUploadedOutput(task *models.Task, result *models.TaskResult) *models.TaskResult {
+	if h.uploads == nil || result == nil || int64(len(result.Output)) <= h.uploadThreshold {
+		return result
+	}
+	if _, ok := result.Uploads.Output(); ok {
+		return result
+	}
+	log := gologger.WithComponent("task_handler")
+
+	ctx, cancel := context.WithTimeout(context.Background(), outputUploadTimeout)
+	defer cancel()
+
+	data := []byte(result.Output)
+	cid, err := h.uploads.Add(ctx, data)
+	if err != nil {
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to upload task output, sending it inline")
+		return result
+	}
+
+	submitted := *result
+	submitted.Output = ""
+	uploads := models.ResultUploads{}
+	if result.Uploads != nil {
+		uploads.Files = append(uploads.Files, result.Uploads.Files...)
+	}
+	uploads.Files = append(uploads.Files, models.ResultUpload{
+		Name:   models.OutputUploadName,
+		CID:    cid,
+		SHA256: ipfs.SHA256Hex(data),
+		Size:   int64(len(data)),
+	})
+	submitted.Uploads = &uploads
+
+	log.Info().
+		Str("id", task.ID.String()).
+		Str("cid", cid).
+		Int("bytes", len(data)).
+		Msg("Task output uploaded to IPFS")
+	return &submitted
+}
@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// executeWithRetries runs a task, and runs a Docker task with a retry policy
+// again while its attempts fail for a transient reason. Retrying stops early
+// once the next backoff would run past the task's deadline. The result of the
+// last attempt is returned with the history of all of them.
+func (h *DefaultTaskHandler) executeWithRetries(ctx context.Context, task *models.Task) (*models.TaskResult, error) {
+	policy, err := retryPolicy(task)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return h.executor.ExecuteTask(ctx, task)
+	}
+
+	log := gologger.WithComponent("task_handler")
+	var attempts models.TaskAttempts
+	for attempt := 1; ; attempt++ {
+		startedAt := time.Now()
+		result, err := h.executor.ExecuteTask(ctx, task)
+		record := models.TaskAttempt{
+			Attempt:    attempt,
+			StartedAt:  startedAt.UTC(),
+			DurationMs: durationMilliseconds(time.Since(startedAt)),
+		}
+		if result != nil {
+			record.ExitCode = result.ExitCode
+			record.Error = result.Error
+		}
+		if err != nil {
+			record.ExitCode = -1
+			record.Error = err.Error()
+		}
+		record.Cause = retryCause(ctx, policy, result, err)
+
+		backoff := policy.Delay(attempt)
+		retry := record.Cause != "" && attempt <= policy.MaxRetries
+		if deadline, ok := ctx.Deadline(); retry && ok && time.Now().Add(backoff).After(deadline) {
+			log.Warn().
+				Str("id", task.ID.String()).
+				Int("attempt", attempt).
+				Str("cause", record.Cause).
+				Dur("backoff", backoff).
+				Time("deadline", deadline).
+				Msg("Task attempt failed, no time left to retry before the deadline")
+			retry = false
+		}
+		if !retry {
+			attempts = append(attempts, record)
+			if result == nil {
+				result = &models.TaskResult{TaskID: task.ID}
+			}
+			result.Attempts = attempts
+			return result, err
+		}
+
+		record.BackoffMs = backoff.Milliseconds()
+		attempts = append(attempts, record)
+		h.recordEvent(task, models.ExecutionEventRetrying, map[string]string{
+			"attempt": strconv.Itoa(attempt),
+			"cause":   record.Cause,
+			"error":   record.Error,
+			"backoff": backoff.String(),
+		})
+		log.Warn().
+			Str("id", task.ID.String()).
+			Int("attempt", attempt).
+			Str("cause", record.Cause).
+			Dur("backoff", backoff).
+			Msg("Task attempt failed, retrying")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &models.TaskResult{TaskID: task.ID, Attempts: attempts}, fmt.Errorf("task stopped while waiting to retry: %w", context.Cause(ctx))
+		case <-timer.C:
+		}
+	}
+}
+
+// retryPolicy is the retry policy a Docker task declares, nil for any other
+// task
+func retryPolicy(task *models.Task) (*models.RetryPolicy, error) {
+	if task.Type != models.TaskTypeDocker {
+		return nil, nil
+	}
+	var config models.TaskConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return nil, fmt.Errorf("invalid task config: %w", err)
+	}
+	return config.Retry, nil
+}
+
+// retryCause is why an attempt failed for a reason that may not recur, empty
+// when it succeeded, failed through the task's own fault or was stopped
+func retryCause(ctx context.Context, policy *models.RetryPolicy, result *models.TaskResult, err error) string {
+	switch {
+	case ctx.Err() != nil:
+		return ""
+	case errors.Is(err, models.ErrImagePull):
+		return models.RetryCauseImagePull
+	case err != nil, result == nil, result.ExitCode == 0:
+		return ""
+	case result.Diagnostics != nil && result.Diagnostics.Container != nil && result.Diagnostics.Container.OOMKilled:
+		return models.RetryCauseOOMKilled
+	case policy.RetryableExit(result.ExitCode):
+		return models.RetryCauseExitCode
+	}
+	return ""
+}
@@ -0,0 +1,13 @@
+package runnerpb
+
+const (
+	// DeviceIDKey is the metadata key that carries the runner's device ID
+	DeviceIDKey = "x-device-id"
+	// NonceKey carries the nonce the runner picks for each call, which the
+	// server signs into the trailer of its response
+	NonceKey = "x-parity-request-nonce"
+	// TimestampKey and SignatureKey carry the server identity signature of a
+	// response in its trailer
+	TimestampKey = "x-parity-server-timestamp"
+	SignatureKey = "x-parity-server-signature"
+)
@@ -2,98 +2,473 @@ package runner
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/client"
-	"github.com/google/uuid"
 
 	"github.com/theblitlabs/gologger"
-	"github.com/theblitlabs/keystore"
 
+	"github.com/theblitlabs/parity-runner/internal/artifacts"
+	"github.com/theblitlabs/parity-runner/internal/attestation"
+	"github.com/theblitlabs/parity-runner/internal/benchmark"
+	"github.com/theblitlabs/parity-runner/internal/bidding"
+	"github.com/theblitlabs/parity-runner/internal/capability"
+	"github.com/theblitlabs/parity-runner/internal/chaos"
 	"github.com/theblitlabs/parity-runner/internal/core/config"
 	"github.com/theblitlabs/parity-runner/internal/core/models"
 	"github.com/theblitlabs/parity-runner/internal/core/ports"
+	"github.com/theblitlabs/parity-runner/internal/datacache"
+	"github.com/theblitlabs/parity-runner/internal/diagnostics"
+	"github.com/theblitlabs/parity-runner/internal/energy"
+	"github.com/theblitlabs/parity-runner/internal/events"
 	"github.com/theblitlabs/parity-runner/internal/execution/llm"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox"
 	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/docker"
+	"github.com/theblitlabs/parity-runner/internal/execution/sandbox/firecracker"
 	"github.com/theblitlabs/parity-runner/internal/execution/task"
+	"github.com/theblitlabs/parity-runner/internal/execution/training"
+	"github.com/theblitlabs/parity-runner/internal/execution/wasm"
+	"github.com/theblitlabs/parity-runner/internal/federation"
+	"github.com/theblitlabs/parity-runner/internal/hooks"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/idle"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+	"github.com/theblitlabs/parity-runner/internal/journal"
+	"github.com/theblitlabs/parity-runner/internal/ledger"
+	"github.com/theblitlabs/parity-runner/internal/messaging/socket"
 	"github.com/theblitlabs/parity-runner/internal/messaging/webhook"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
+	"github.com/theblitlabs/parity-runner/internal/provenance"
+	"github.com/theblitlabs/parity-runner/internal/supervisor"
+	"github.com/theblitlabs/parity-runner/internal/sysmetrics"
+	"github.com/theblitlabs/parity-runner/internal/telemetry"
 	"github.com/theblitlabs/parity-runner/internal/tunnel"
 	"github.com/theblitlabs/parity-runner/internal/utils"
+	"github.com/theblitlabs/parity-runner/internal/watchdog"
 )
 
 type Service struct {
 	cfg               *config.Config
 	webhookClient     *webhook.WebhookClient
+	socketClient      *socket.SocketClient
 	tunnelClient      *tunnel.TunnelClient
 	taskHandler       ports.TaskHandler
 	taskClient        ports.TaskClient
-	dockerExecutor    *docker.DockerExecutor
+	containers        sandbox.ContainerRuntime
 	dockerClient      *client.Client
 	deviceID          string
 	heartbeatInterval time.Duration
+	idleMonitor       *idle.Monitor
+	stopIdle          context.CancelFunc
+	stopGC            context.CancelFunc
+	outbox            *outbox.Store
+	stopOutbox        context.CancelFunc
+	journal           *journal.Store
+	stateClient       TaskStateClient
+	stopJournal       context.CancelFunc
+	telemetry         *telemetry.Reporter
+	stopTelemetry     context.CancelFunc
+	pool              *task.Pool
+	fleet             *fleetMember
+	manifest          *manifestBuilder
+	// Set when the runner takes work from more than one coordinator
+	scheduler          *federation.Scheduler
+	federatedClient    *FederatedTaskClient
+	coordinatorSources []*coordinatorSource
+	stopFederation     context.CancelFunc
+	// supervisor restarts subsystems that die while the runner is up
+	supervisor        *supervisor.Supervisor
+	stopSupervisor    context.CancelFunc
+	webhookSupervised bool
+	// watchdog restarts the runner when its own resource use keeps growing
+	watchdog     *watchdog.Watchdog
+	stopWatchdog context.CancelFunc
 }
 
+const (
+	DispatchWebhook   = "webhook"
+	DispatchWebSocket = "websocket"
+)
+
 func NewService(cfg *config.Config) (*Service, error) {
 	log := gologger.WithComponent("runner")
 
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	dockerHost := docker.RemoteHost{
+		Address:   cfg.Runner.Docker.Host,
+		TLSVerify: cfg.Runner.Docker.TLSVerify,
+		CertPath:  cfg.Runner.Docker.CertPath,
+	}
+	clientOpts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if dockerHost.Address == "" {
+		dockerHost = docker.HostFromEnv()
+	} else if strings.HasPrefix(dockerHost.Address, "tcp://") {
+		clientOpts = append(clientOpts, client.WithHost(dockerHost.Address))
+		if dockerHost.TLSVerify {
+			clientOpts = append(clientOpts, client.WithTLSClientConfig(
+				filepath.Join(dockerHost.CertPath, "ca.pem"),
+				filepath.Join(dockerHost.CertPath, "cert.pem"),
+				filepath.Join(dockerHost.CertPath, "key.pem"),
+			))
+		}
+	}
+	dockerClient, err := client.NewClientWithOpts(clientOpts...)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create Docker client")
 		return nil, fmt.Errorf("docker client creation failed: %w", err)
 	}
 
-	if err := checkDockerAvailability(dockerClient); err != nil {
-		// Task execution relies on the docker CLI below, which can still work on
-		// Docker Desktop setups where the Go SDK probe fails against the current
-		// local socket/context.
-		log.Warn().Err(err).Msg("Docker SDK availability check failed; continuing with CLI-based execution")
+	containerRuntime, err := sandbox.ParseRuntime(cfg.Runner.ContainerRuntime)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid container runtime")
+		return nil, err
+	}
+
+	if containerRuntime == sandbox.RuntimeDocker {
+		if err := checkDockerAvailability(dockerClient); err != nil {
+			// Task execution relies on the docker CLI below, which can still work on
+			// Docker Desktop setups where the Go SDK probe fails against the current
+			// local socket/context.
+			log.Warn().Err(err).Msg("Docker SDK availability check failed; continuing with CLI-based execution")
+		}
 	}
 
 	svc := &Service{
 		cfg:               cfg,
 		dockerClient:      dockerClient,
 		heartbeatInterval: cfg.Runner.HeartbeatInterval,
+		supervisor: supervisor.New(supervisor.Config{
+			CheckInterval: cfg.Runner.Supervisor.CheckInterval,
+			BaseBackoff:   cfg.Runner.Supervisor.BaseBackoff,
+			MaxBackoff:    cfg.Runner.Supervisor.MaxBackoff,
+			MaxRestarts:   cfg.Runner.Supervisor.MaxRestarts,
+			Window:        cfg.Runner.Supervisor.Window,
+		}),
+		watchdog: newWatchdog(cfg),
+	}
+
+	// Opening the signer up front catches an unplugged or locked hardware
+	// wallet before the runner registers
+	walletSigner, err := utils.GetSigner()
+	if err != nil {
+		log.Error().Err(err).Msg("No wallet signer available - authentication required")
+		return nil, fmt.Errorf("no wallet signer available - please authenticate first using 'parity auth': %w", err)
+	}
+	log.Debug().Str("address", walletSigner.Address().Hex()).Msg("Wallet signer ready")
+
+	var gpus []models.GPUInfo
+	if dockerHost.Remote() {
+		// GPUs are detected on this machine, not on the Docker host
+		log.Info().Str("docker_host", dockerHost.Address).Msg("Running Docker tasks on a remote host; GPU tasks will not be accepted")
+	} else if gpus, err = sandbox.DetectGPUs(context.Background(), containerRuntime); err != nil {
+		log.Warn().Err(err).Msg("GPU detection failed; GPU tasks will not be accepted")
+	}
+	if len(gpus) > 0 {
+		gpuModels := make([]string, len(gpus))
+		for i, g := range gpus {
+			gpuModels[i] = g.Model
+		}
+		log.Info().Int("count", len(gpus)).Strs("models", gpuModels).Msg("GPUs available to Docker tasks")
+	}
+
+	chaosInjector, err := chaos.New(chaos.Config{
+		WebhookDropRate:    cfg.Runner.Chaos.WebhookDropRate,
+		HeartbeatDelay:     cfg.Runner.Chaos.HeartbeatDelay,
+		ContainerKillRate:  cfg.Runner.Chaos.ContainerKillRate,
+		ContainerKillAfter: cfg.Runner.Chaos.ContainerKillAfter,
+		ResultFailureRate:  cfg.Runner.Chaos.ResultFailureRate,
+		Seed:               cfg.Runner.Chaos.Seed,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid fault injection configuration")
+		return nil, fmt.Errorf("invalid chaos configuration: %w", err)
+	}
+	if chaosInjector.Enabled() {
+		log.Warn().
+			Float64("webhook_drop_rate", cfg.Runner.Chaos.WebhookDropRate).
+			Dur("heartbeat_delay", cfg.Runner.Chaos.HeartbeatDelay).
+			Float64("container_kill_rate", cfg.Runner.Chaos.ContainerKillRate).
+			Float64("result_failure_rate", cfg.Runner.Chaos.ResultFailureRate).
+			Msg("Fault injection enabled, do not use this runner for real work")
+	}
+
+	imagePolicy, err := models.ParseImagePolicy(cfg.Runner.Policy.ImageRegistries, cfg.Runner.Policy.RequireImageDigest, cfg.Runner.Policy.BlockedImageTags)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid image policy")
+		return nil, fmt.Errorf("invalid image policy: %w", err)
+	}
+	if imagePolicy.BlockedImages, err = models.ParseBlockedImages(cfg.Runner.Policy.BlockedImages); err != nil {
+		log.Error().Err(err).Msg("Invalid image policy")
+		return nil, fmt.Errorf("invalid image policy: %w", err)
 	}
 
-	homeDir, err := os.UserHomeDir()
+	imageSignatures, err := docker.ParseImageSignatures(cfg.Runner.Policy.ImageSignatureKeys, cfg.Runner.Policy.ImageSignatureIdentity, cfg.Runner.Policy.ImageSignatureIssuer)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get user home directory")
-		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+		log.Error().Err(err).Msg("Invalid image signature policy")
+		return nil, fmt.Errorf("invalid image signature policy: %w", err)
+	}
+	if !imageSignatures.IsZero() {
+		log.Info().Str("signers", imageSignatures.String()).Msg("Docker task images must be signed")
 	}
 
-	ks, err := keystore.NewKeystore(keystore.Config{
-		DirPath:  filepath.Join(homeDir, ".parity"),
-		FileName: "keystore.json",
+	checkpointMode, err := docker.ParseCheckpointMode(cfg.Runner.Checkpoint.Mode)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid checkpoint configuration")
+		return nil, fmt.Errorf("invalid checkpoint configuration: %w", err)
+	}
+
+	var artifactStore *artifacts.Store
+	if cfg.Runner.Artifacts.Retention > 0 {
+		dir, err := artifacts.DefaultDir()
+		if err != nil {
+			log.Warn().Err(err).Msg("Task artifacts will not be kept")
+		} else {
+			artifactStore = artifacts.NewStore(dir, cfg.Runner.Artifacts.Retention)
+			if _, err := artifactStore.Prune(time.Now()); err != nil {
+				log.Warn().Err(err).Msg("Failed to prune task artifacts")
+			}
+			log.Info().Str("dir", dir).Dur("retention", cfg.Runner.Artifacts.Retention).Msg("Keeping task artifacts")
+		}
+	}
+	var workspaces *artifacts.Store
+	if cfg.Runner.Artifacts.Workspace {
+		workspaces = artifactStore
+	}
+
+	content, err := ipfs.NewBackend(ipfs.BackendConfig{
+		Backend:    cfg.Runner.IPFS.Backend,
+		GatewayURL: cfg.Runner.IPFS.GatewayURL,
+		APIURL:     cfg.Runner.IPFS.APIURL,
+		Token:      cfg.Runner.IPFS.Token,
 	})
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to create keystore")
-		return nil, fmt.Errorf("failed to create keystore: %w", err)
+		log.Error().Err(err).Msg("Invalid IPFS configuration")
+		return nil, fmt.Errorf("invalid ipfs configuration: %w", err)
 	}
+	training.SetContentBackend(content)
 
-	if _, err := ks.LoadPrivateKey(); err != nil {
-		log.Error().Err(err).Msg("No private key found - authentication required")
-		return nil, fmt.Errorf("no private key found - please authenticate first using 'parity auth': %w", err)
+	var uploadThreshold int64
+	if cfg.Runner.ResultUpload.Threshold != "" {
+		if uploadThreshold, err = task.ParseMemory(cfg.Runner.ResultUpload.Threshold); err != nil {
+			return nil, fmt.Errorf("invalid result upload threshold: %w", err)
+		}
 	}
+	outputUpload := cfg.Runner.ResultUpload.Threshold != ""
 
-	dockerExecutor, err := docker.NewDockerExecutor(&docker.ExecutorConfig{
+	cacheSize := datacache.DefaultMaxBytes
+	if cfg.Runner.DatasetCache.MaxSize != "" {
+		if cacheSize, err = task.ParseMemory(cfg.Runner.DatasetCache.MaxSize); err != nil {
+			return nil, fmt.Errorf("invalid dataset cache size: %w", err)
+		}
+	}
+	var datasetCache *datacache.Cache
+	if cacheSize > 0 {
+		if dir, err := datacache.DefaultDir(); err != nil {
+			log.Warn().Err(err).Msg("Datasets will not be cached")
+		} else {
+			datasetCache = datacache.New(dir, cacheSize)
+			training.SetDatasetCache(datasetCache)
+			log.Info().Str("dir", dir).Int64("max_bytes", cacheSize).Msg("Caching federated learning datasets and task inputs")
+		}
+	}
+
+	failureDiagnostics := diagnostics.Config{
+		Disabled: cfg.Runner.Diagnostics.Disabled,
+		LogLines: cfg.Runner.Diagnostics.LogLines,
+		MaxBytes: cfg.Runner.Diagnostics.MaxBytes,
+		Runtime:  containerRuntime,
+		GPUs:     gpus,
+	}
+
+	containers, err := sandbox.NewRuntime(containerRuntime, &docker.ExecutorConfig{
 		MemoryLimit:      cfg.Runner.Docker.MemoryLimit,
 		CPULimit:         cfg.Runner.Docker.CPULimit,
 		Timeout:          cfg.Runner.Docker.Timeout,
 		ExecutionTimeout: cfg.Runner.ExecutionTimeout,
+		GPUs:             gpus,
+		Chaos:            chaosInjector,
+		CheckpointMode:   checkpointMode,
+		CheckpointUpload: cfg.Runner.Checkpoint.Upload,
+		Workspaces:       workspaces,
+		Content:          content,
+		InputCache:       datasetCache,
+		OutputUpload:     outputUpload,
+		Host:             dockerHost,
+		ImagePolicy:      imagePolicy,
+		ImageSignatures:  imageSignatures,
+		FaketimeLibrary:  cfg.Runner.Docker.FaketimeLibrary,
+		Diagnostics:      failureDiagnostics,
 	})
 	if err != nil {
-		log.Warn().Err(err).Msg("Docker executor unavailable; continuing without Docker task support")
-		dockerExecutor = nil
+		log.Warn().Err(err).Str("runtime", containerRuntime).Msg("Container runtime unavailable; continuing without Docker task support")
+		containers = nil
+	}
+	var sandboxBenchmark *models.SandboxBenchmark
+	if containers != nil {
+		sandboxBenchmark = loadSandboxBenchmark(containerRuntime)
 	}
 
 	// Create the enhanced task executor that supports LLM routing
-	executor := task.NewExecutor()
+	executor := task.NewExecutor(containers)
+	executor.SetContent(content)
+	wasmLimits := wasm.DefaultLimits()
+	if cfg.Runner.Wasm.MemoryLimit != "" {
+		if wasmLimits.MemoryBytes, err = task.ParseMemory(cfg.Runner.Wasm.MemoryLimit); err != nil {
+			return nil, fmt.Errorf("invalid wasm memory limit %q: %w", cfg.Runner.Wasm.MemoryLimit, err)
+		}
+	}
+	if cfg.Runner.Wasm.Fuel > 0 {
+		wasmLimits.Fuel = cfg.Runner.Wasm.Fuel
+	}
+	executor.SetWasmLimits(wasmLimits)
+
+	vmIsolation := false
+	if cfg.Runner.Firecracker.Enabled {
+		vms, err := sandbox.NewVMRuntime(containerRuntime, firecracker.Config{
+			Binary:           cfg.Runner.Firecracker.Binary,
+			KernelImage:      cfg.Runner.Firecracker.KernelImage,
+			RootFS:           cfg.Runner.Firecracker.RootFS,
+			VCPUs:            cfg.Runner.Firecracker.VCPUs,
+			MemoryMiB:        cfg.Runner.Firecracker.MemoryMiB,
+			Timeout:          cfg.Runner.Docker.Timeout,
+			ExecutionTimeout: cfg.Runner.ExecutionTimeout,
+			Content:          content,
+			ImagePolicy:      imagePolicy,
+			ImageSignatures:  imageSignatures,
+		})
+		if err != nil {
+			log.Warn().Err(err).Msg("Firecracker unavailable; tasks that ask for VM isolation will be skipped")
+		} else {
+			executor.SetVMRuntime(vms)
+			vmIsolation = true
+		}
+	}
+
+	var tee models.TEETechnology
+	var attester *attestation.Attester
+	if cfg.Runner.TEE.Enabled {
+		if attester, err = attestation.Detect(); err != nil {
+			log.Warn().Err(err).Msg("No trusted execution environment; tasks that ask for TEE isolation will be skipped")
+		} else {
+			tee = attester.Technology()
+			log.Info().Str("tee", string(tee)).Msg("TEE isolation enabled")
+		}
+	}
+
+	serverVerifier, err := loadServerVerifier(cfg.Runner.ServerURL)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load pinned server identity")
+		return nil, err
+	}
+
+	httpTaskClient := NewHTTPTaskClient(cfg.Runner.ServerURL)
+	if serverVerifier != nil {
+		httpTaskClient.SetServerVerifier(serverVerifier)
+	}
+	var taskClient ports.TaskClient = httpTaskClient
+	if cfg.Runner.GRPCAddress != "" {
+		grpcClient, err := NewGRPCTaskClient(cfg.Runner.GRPCAddress, cfg.Runner.ServerURL)
+		if err != nil {
+			log.Error().Err(err).Str("address", cfg.Runner.GRPCAddress).Msg("Failed to create gRPC task client")
+			return nil, err
+		}
+		if serverVerifier != nil {
+			grpcClient.SetServerVerifier(serverVerifier)
+		}
+		log.Info().Str("address", cfg.Runner.GRPCAddress).Msg("Using gRPC for task calls")
+		taskClient = grpcClient
+	}
+
+	coordinators, err := federation.ParseCoordinators(cfg.Runner.ServerURL, cfg.Runner.Federation.Coordinators)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid coordinator list")
+		return nil, err
+	}
+	coordinatorClients := make(map[string]*HTTPTaskClient)
+	if len(coordinators) > 1 {
+		clients := map[string]ports.TaskClient{coordinators[0].Name: taskClient}
+		for _, coordinator := range coordinators[1:] {
+			coordinatorClients[coordinator.Name] = NewHTTPTaskClient(coordinator.ServerURL)
+			clients[coordinator.Name] = coordinatorClients[coordinator.Name]
+		}
+		queueSize := cfg.Runner.Federation.QueueSize
+		if queueSize <= 0 {
+			queueSize = defaultCoordinatorQueueSize
+		}
+		svc.scheduler = federation.NewScheduler(coordinators, queueSize)
+		svc.federatedClient = NewFederatedTaskClient(coordinators[0].Name, clients, svc.scheduler)
+		taskClient = svc.federatedClient
+	}
 
-	taskClient := NewHTTPTaskClient(cfg.Runner.ServerURL)
 	taskHandler := NewTaskHandler(executor, taskClient)
+	taskHandler.SetChaos(chaosInjector)
+	taskHandler.SetGangAddress(cfg.Runner.GangAddress)
+	taskHandler.SetDiagnostics(failureDiagnostics)
+	if attester != nil {
+		taskHandler.SetAttester(attester)
+	}
+	if outputUpload {
+		taskHandler.SetResultUpload(content, uploadThreshold)
+		log.Info().Int64("threshold_bytes", uploadThreshold).Msg("Uploading large task outputs to IPFS")
+	}
+	if artifactStore != nil {
+		taskHandler.SetArtifacts(artifactStore)
+	}
+	if dir, err := outbox.DefaultDir(); err != nil {
+		log.Warn().Err(err).Msg("Results the server does not accept will not be queued")
+	} else {
+		svc.outbox = outbox.NewStore(dir)
+		taskHandler.SetOutbox(svc.outbox)
+	}
+	if dir, err := journal.DefaultDir(); err != nil {
+		log.Warn().Err(err).Msg("Accepted tasks will not be kept across restarts")
+	} else {
+		svc.journal = journal.NewStore(dir)
+		taskHandler.SetJournal(svc.journal)
+	}
+	if dir, err := ledger.DefaultDir(); err != nil {
+		log.Warn().Err(err).Msg("Executed tasks will not be recorded in the earnings ledger")
+	} else {
+		taskHandler.SetLedger(ledger.NewStore(dir))
+	}
+	if !cfg.Runner.LogStream.Disabled {
+		taskHandler.SetLogStream(cfg.Runner.LogStream.Interval)
+	}
+	if dir, err := events.DefaultDir(); err != nil {
+		log.Warn().Err(err).Msg("Task execution events will not be recorded")
+	} else {
+		taskHandler.SetEvents(events.NewStore(dir))
+	}
+
+	hookRegistry, err := loadHooks(cfg.Runner.Hooks)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load task hooks")
+		return nil, fmt.Errorf("failed to load task hooks: %w", err)
+	}
+	if cfg.Runner.Idle.Enabled {
+		monitor := idle.NewMonitor(idle.Config{
+			IdleAfter:     cfg.Runner.Idle.After,
+			MinBattery:    cfg.Runner.Idle.MinBattery,
+			AllowMetered:  cfg.Runner.Idle.AllowMetered,
+			CheckInterval: cfg.Runner.Idle.CheckInterval,
+		}, nil)
+		monitor.OnBusy(func(reason string) {
+			if taskHandler.AbortCurrentTask("host no longer idle: " + reason) {
+				log.Info().Str("reason", reason).Msg("Aborted running task, user returned")
+			}
+		})
+		hookRegistry.Register(hooks.StagePreClaim, monitor.Hook())
+		svc.idleMonitor = monitor
+		log.Info().Dur("idle_after", cfg.Runner.Idle.After).Msg("Idle contribution mode enabled")
+	}
+	if hookRegistry.Len() > 0 {
+		taskHandler.SetHooks(hookRegistry)
+		log.Info().Int("hooks", hookRegistry.Len()).Msg("Task lifecycle hooks enabled")
+	}
 
 	deviceID, err := utils.GetDeviceID()
 	if err != nil {
@@ -101,7 +476,8 @@ func NewService(cfg *config.Config) (*Service, error) {
 		return nil, fmt.Errorf("failed to get device ID: %w", err)
 	}
 
-	runnerID := uuid.New().String()
+	// The session labels the Docker resources this process creates as well
+	runnerID := utils.Session()
 
 	walletAddress, err := utils.GetWalletAddress()
 	if err != nil {
@@ -109,6 +485,18 @@ func NewService(cfg *config.Config) (*Service, error) {
 		return nil, fmt.Errorf("failed to get wallet address: %w", err)
 	}
 
+	capabilities := capability.Measure(context.Background(), capability.Options{
+		TaskTypes: executor.TaskTypes(),
+		GPUs:      gpus,
+		Region:    cfg.Runner.Region,
+		ServerURL: cfg.Runner.ServerURL,
+	})
+	hardwareBenchmark := loadHardwareBenchmark(deviceID, walletAddress)
+	if hardwareBenchmark != nil {
+		capabilities.BenchmarkScore = hardwareBenchmark.Scores.Overall
+	}
+	svc.telemetry = newTelemetry(cfg, taskHandler, capabilities)
+
 	webhookClient := webhook.NewWebhookClient(
 		cfg.Runner.ServerURL,
 		cfg.Runner.WebhookPort,
@@ -117,6 +505,209 @@ func NewService(cfg *config.Config) (*Service, error) {
 		deviceID,
 		walletAddress,
 	)
+	webhookClient.SetRandomizedEndpoint(cfg.Runner.WebhookRandomize)
+	webhookClient.SetGPUs(gpus)
+	webhookClient.SetVMIsolation(vmIsolation)
+	webhookClient.SetTEE(tee)
+	webhookClient.SetChaos(chaosInjector)
+	webhookClient.SetResultEncodingsHandler(httpTaskClient.SetResultEncodings)
+	webhookClient.SetSandboxBenchmark(sandboxBenchmark)
+	webhookClient.SetCapabilities(capabilities)
+	webhookClient.SetBenchmark(hardwareBenchmark)
+	build := provenance.Current()
+	if build.Signed() {
+		log.Info().Str("version", build.RunnerVersion).Str("commit", build.Commit).Msg("Running a signed release")
+	} else {
+		log.Warn().Str("version", build.RunnerVersion).Msg("This build is not a signed release, servers that require attested runners will refuse it")
+	}
+
+	// Rewards settle on the server's default chain unless --chain picked one
+	var settlementChainID int64
+	if cfg.Blockchain.Chain != "" {
+		settlementChainID = cfg.Blockchain.ChainID
+	}
+	webhookClient.SetSettlementChain(settlementChainID)
+	webhookClient.Heartbeat().SetHealthProvider(svc.supervisor.Health)
+	if serverVerifier != nil {
+		webhookClient.SetServerVerifier(serverVerifier)
+	}
+
+	pool, err := newWorkerPool(cfg.Runner)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid worker pool configuration")
+		return nil, fmt.Errorf("invalid worker pool configuration: %w", err)
+	}
+	status := pool.Status()
+	taskHandler.SetMaxConcurrent(status.MaxConcurrent)
+	webhookClient.SetWorkerPool(pool)
+	svc.pool = pool
+	log.Info().
+		Int("max_concurrent_tasks", status.MaxConcurrent).
+		Float64("cpus", status.Capacity.CPUs).
+		Int64("memory_bytes", status.Capacity.MemoryBytes).
+		Msg("Worker pool configured")
+
+	labelSelector, err := models.ParseLabelSelector(cfg.Runner.AcceptLabels)
+	if err != nil {
+		log.Error().Err(err).Str("accept_labels", cfg.Runner.AcceptLabels).Msg("Invalid label selector")
+		return nil, fmt.Errorf("invalid accept labels selector: %w", err)
+	}
+	if len(labelSelector) > 0 {
+		webhookClient.SetLabelSelector(labelSelector)
+		log.Info().Str("accept_labels", labelSelector.String()).Msg("Label routing enabled")
+	}
+
+	policy, err := models.ParseRunnerPolicy(cfg.Runner.Policy.TaskTypes, cfg.Runner.Policy.TrustedCreators, cfg.Runner.Policy.TrustedNamespaces)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid runner policy")
+		return nil, fmt.Errorf("invalid runner policy: %w", err)
+	}
+	policy.Images = imagePolicy
+	if policy.MinRewards, err = models.ParseMinRewards(cfg.Runner.Policy.MinRewards); err != nil {
+		log.Error().Err(err).Msg("Invalid minimum rewards")
+		return nil, fmt.Errorf("invalid minimum rewards: %w", err)
+	}
+	policy.MaxDuration = cfg.Runner.Policy.MaxDuration
+	if policy.WorkingHours, err = models.ParseWorkingHours(cfg.Runner.Policy.WorkingHours); err != nil {
+		log.Error().Err(err).Msg("Invalid working hours")
+		return nil, fmt.Errorf("invalid working hours: %w", err)
+	}
+	if !policy.IsZero() {
+		taskHandler.SetPolicy(policy)
+		webhookClient.SetPolicy(policy)
+		log.Info().Str("policy", policy.String()).Msg("Runner policy enabled")
+	}
+
+	energyScheduler, err := newEnergyScheduler(cfg.Runner.Energy)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid electricity cost configuration")
+		return nil, fmt.Errorf("invalid electricity cost configuration: %w", err)
+	}
+	if energyScheduler != nil {
+		webhookClient.SetEnergyScheduler(energyScheduler)
+		log.Info().
+			Float64("token_price", cfg.Runner.Energy.TokenPrice).
+			Float64("peak_price", cfg.Runner.Energy.PeakPrice).
+			Msg("Declining tasks that do not pay for their electricity")
+	}
+
+	if cfg.Runner.Bidding.Enabled {
+		bidClient := bidding.NewClient(cfg.Runner.ServerURL, deviceID)
+		if serverVerifier != nil {
+			bidClient.SetServerVerifier(serverVerifier)
+		}
+		webhookClient.SetBidder(bidding.NewBidder(biddingPricing(cfg.Runner.Bidding), bidClient))
+		log.Info().
+			Float64("per_cpu_hour", cfg.Runner.Bidding.PerCPUHour).
+			Float64("per_gb_hour", cfg.Runner.Bidding.PerGBHour).
+			Float64("per_gpu_hour", cfg.Runner.Bidding.PerGPUHour).
+			Float64("margin", cfg.Runner.Bidding.Margin).
+			Msg("Bidding for auctioned tasks")
+	}
+
+	hardware := models.RunnerHardware{
+		CPUs:        runtime.NumCPU(),
+		MemoryBytes: sysmetrics.NewCollector("").GetHostMetrics().MemoryTotal,
+		GPUs:        gpus,
+		VMIsolation: vmIsolation,
+		TEE:         tee,
+	}
+	if containers != nil {
+		hardware.Runtime = containerRuntime
+	}
+	svc.manifest = &manifestBuilder{
+		deviceID:  deviceID,
+		taskTypes: executor.TaskTypes(),
+		hardware:  hardware,
+		pool:      pool,
+		idle:      svc.idleMonitor,
+		signer:    utils.GetSigner,
+		policy:    policy,
+	}
+	webhookClient.SetManifestProvider(svc.manifest)
+
+	svc.fleet = &fleetMember{
+		pool:      pool,
+		handler:   taskHandler,
+		policies:  []policySetter{taskHandler, webhookClient, svc.manifest},
+		setLabels: webhookClient.SetLabelSelector,
+		labels:    labelSelector,
+		policy:    policy,
+	}
+	svc.fleet.setModels = func(models []llm.ModelInfo) {
+		if err := svc.SetModelCapabilities(models); err != nil {
+			log.Error().Err(err).Msg("Failed to advertise fleet models")
+		}
+	}
+	webhookClient.SetFleetMember(svc.fleet)
+
+	for _, coordinator := range coordinators[1:] {
+		registration := models.RunnerRegistration{
+			WalletAddress:     walletAddress,
+			Status:            models.RunnerStatusOnline,
+			AcceptLabels:      labelSelector.String(),
+			SandboxBenchmark:  sandboxBenchmark,
+			Capabilities:      capabilities,
+			Benchmark:         hardwareBenchmark,
+			SettlementChainID: settlementChainID,
+			Build:             build,
+		}
+		source := newCoordinatorSource(coordinator, coordinatorClients[coordinator.Name], registration, deviceID, gpus, cfg.Runner.Federation.PollInterval, taskHandler)
+		source.setManifestProvider(svc.manifest)
+		svc.coordinatorSources = append(svc.coordinatorSources, source)
+		log.Info().Str("coordinator", coordinator.Name).Int("weight", coordinator.Weight).Msg("Taking tasks from additional coordinator")
+	}
+
+	switch strings.ToLower(cfg.Runner.Dispatch) {
+	case "", DispatchWebhook:
+	case DispatchWebSocket:
+		socketConfig := socket.DefaultConfig()
+		socketConfig.ServerURL = cfg.Runner.ServerURL
+		socketConfig.DeviceID = deviceID
+		socketConfig.WalletAddress = walletAddress
+		socketConfig.AcceptLabels = labelSelector.String()
+		socketConfig.GPUs = gpus
+		socketConfig.Chaos = chaosInjector
+		socketConfig.SandboxBenchmark = sandboxBenchmark
+		socketConfig.Capabilities = capabilities
+		socketConfig.Benchmark = hardwareBenchmark
+		socketConfig.SettlementChainID = settlementChainID
+		socketConfig.ServerIdentity = serverVerifier
+
+		// The webhook client dispatches socket messages too, so a task is tracked
+		// once whichever way it arrives
+		socketClient := socket.NewSocketClient(socketConfig, webhookClient, taskHandler)
+		socketClient.OnControl("abort_task", func(payload json.RawMessage) {
+			var req struct {
+				TaskID string `json:"task_id"`
+				Reason string `json:"reason"`
+			}
+			_ = json.Unmarshal(payload, &req)
+			if req.Reason == "" {
+				req.Reason = "aborted by server"
+			}
+			aborted := false
+			if req.TaskID != "" {
+				aborted = taskHandler.AbortTask(req.TaskID, req.Reason)
+			} else {
+				aborted = taskHandler.AbortCurrentTask(req.Reason)
+			}
+			if aborted {
+				log.Info().Str("task_id", req.TaskID).Str("reason", req.Reason).Msg("Aborted task on server request")
+			}
+		})
+		socketClient.OnControl("cancel", func(payload json.RawMessage) {
+			// Handled like a webhook, which it also arrives as
+			if _, err := webhookClient.Dispatch(webhook.WebhookMessage{Type: "cancel", Payload: payload}); err != nil {
+				log.Warn().Err(err).Msg("Ignoring cancel message")
+			}
+		})
+		socketClient.SetFleetMember(svc.fleet)
+		socketClient.SetManifestProvider(svc.manifest)
+		svc.socketClient = socketClient
+	default:
+		return nil, fmt.Errorf("invalid dispatch mode %q: use %s or %s", cfg.Runner.Dispatch, DispatchWebhook, DispatchWebSocket)
+	}
 
 	// Initialize tunnel client if enabled
 	var tunnelClient *tunnel.TunnelClient
@@ -157,7 +748,10 @@ func NewService(cfg *config.Config) (*Service, error) {
 	svc.tunnelClient = tunnelClient
 	svc.taskHandler = taskHandler
 	svc.taskClient = taskClient
-	svc.dockerExecutor = dockerExecutor
+	// Held tasks are always the primary server's, whichever protocol carries
+	// task calls
+	svc.stateClient = httpTaskClient
+	svc.containers = containers
 
 	log.Info().
 		Str("server_url", cfg.Runner.ServerURL).
@@ -171,6 +765,9 @@ func (s *Service) SetHeartbeatInterval(interval time.Duration) {
 	if s.webhookClient != nil {
 		s.webhookClient.SetHeartbeatInterval(interval)
 	}
+	if s.socketClient != nil {
+		s.socketClient.SetHeartbeatInterval(interval)
+	}
 }
 
 func (s *Service) SetModelCapabilities(models []llm.ModelInfo) error {
@@ -189,9 +786,25 @@ func (s *Service) SetModelCapabilities(models []llm.ModelInfo) error {
 	}
 
 	s.webhookClient.SetModelCapabilities(capabilities)
+	if s.manifest != nil {
+		s.manifest.setModels(capabilities)
+	}
+	if s.socketClient != nil {
+		s.socketClient.SetModelCapabilities(capabilities)
+	}
+	if s.fleet != nil {
+		s.fleet.recordModels(models)
+	}
 	return nil
 }
 
+// SetModelInstaller lets a fleet document change the models the runner serves
+func (s *Service) SetModelInstaller(installer ModelInstaller) {
+	if s.fleet != nil {
+		s.fleet.setInstaller(installer)
+	}
+}
+
 func (s *Service) SetupWithDeviceID(deviceID string) error {
 	log := gologger.WithComponent("runner")
 
@@ -218,9 +831,143 @@ func (s *Service) HandleTask(task *models.Task) error {
 	return s.taskHandler.HandleTask(task)
 }
 
+// redispatch offers a task the runner held before it restarted to the webhook
+// client like a new notification, so it goes through the same checks and queue
+func (s *Service) redispatch(task *models.Task) bool {
+	log := gologger.WithComponent("runner")
+	payload, err := json.Marshal(task)
+	if err != nil {
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to encode held task")
+		return false
+	}
+	result, err := s.webhookClient.Dispatch(webhook.WebhookMessage{Type: "available_tasks", Payload: payload})
+	if err != nil {
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to dispatch held task")
+		return false
+	}
+	return result.Status == webhook.DispatchAccepted
+}
+
+// Supervise restarts a subsystem started outside the service, such as Ollama,
+// when it dies
+func (s *Service) Supervise(sub supervisor.Subsystem) {
+	s.supervisor.Add(sub)
+}
+
+// OnEscalate registers what to do when a subsystem keeps dying after restarts,
+// normally restarting the process
+func (s *Service) OnEscalate(fn func(name string, err error)) {
+	s.supervisor.OnEscalate(fn)
+}
+
+// OnWatchdogBreach registers what to do when the runner's goroutines, heap or
+// open files stay over their limits, normally draining and restarting the
+// process. Nothing is watched when the watchdog is disabled.
+func (s *Service) OnWatchdogBreach(fn func(breach watchdog.Breach)) {
+	if s.watchdog != nil {
+		s.watchdog.OnBreach(fn)
+	}
+}
+
+// DrainTimeout is how long running tasks may take to finish when the runner
+// drains before a restart the watchdog asked for
+func (s *Service) DrainTimeout() time.Duration {
+	if s.cfg.Runner.Watchdog.DrainTimeout > 0 {
+		return s.cfg.Runner.Watchdog.DrainTimeout
+	}
+	return defaultDrainTimeout
+}
+
+// Health returns the state of the supervised subsystems, as sent in heartbeats
+func (s *Service) Health() []models.SubsystemHealth {
+	return s.supervisor.Health()
+}
+
+func (s *Service) gcInterval() time.Duration {
+	if s.cfg.Runner.Docker.GCInterval == 0 {
+		return defaultGCInterval
+	}
+	return s.cfg.Runner.Docker.GCInterval
+}
+
 func (s *Service) Start() error {
 	log := gologger.WithComponent("runner")
 
+	supervisorCtx, stopSupervisor := context.WithCancel(context.Background())
+	s.stopSupervisor = stopSupervisor
+	go s.supervisor.Run(supervisorCtx)
+
+	if s.watchdog != nil {
+		watchdogCtx, stopWatchdog := context.WithCancel(context.Background())
+		s.stopWatchdog = stopWatchdog
+		go s.watchdog.Run(watchdogCtx)
+	}
+
+	// Containers a crashed run left behind are removed before any task starts
+	if collector, ok := s.containers.(sandbox.GarbageCollector); ok {
+		sweep(context.Background(), collector)
+		if interval := s.gcInterval(); interval > 0 {
+			gcCtx, stopGC := context.WithCancel(context.Background())
+			s.stopGC = stopGC
+			go collectGarbage(gcCtx, collector, interval)
+		}
+	}
+
+	if s.outbox != nil && s.taskClient != nil {
+		outboxCtx, stopOutbox := context.WithCancel(context.Background())
+		s.stopOutbox = stopOutbox
+		go deliverOutbox(outboxCtx, s.outbox, s.taskClient)
+	}
+
+	if s.journal != nil && s.webhookClient != nil {
+		journalCtx, stopJournal := context.WithCancel(context.Background())
+		s.stopJournal = stopJournal
+		go reconcileJournal(journalCtx, s.journal, s.outbox, s.stateClient, s.redispatch)
+	}
+
+	if s.telemetry != nil {
+		telemetryCtx, stopTelemetry := context.WithCancel(context.Background())
+		s.stopTelemetry = stopTelemetry
+		go s.telemetry.Run(telemetryCtx)
+	}
+
+	if s.idleMonitor != nil {
+		idleCtx, stopIdle := context.WithCancel(context.Background())
+		s.stopIdle = stopIdle
+		go s.idleMonitor.Run(idleCtx)
+	}
+
+	if s.scheduler != nil {
+		s.startFederation()
+	}
+
+	if s.socketClient != nil {
+		s.socketClient.SetHeartbeatInterval(s.heartbeatInterval)
+		s.socketClient.OnFallback(func(err error) {
+			log.Warn().Err(err).Msg("Lost the WebSocket connection, falling back to webhook mode")
+			if err := s.startWebhookMode(); err != nil {
+				log.Error().Err(err).Msg("Failed to start webhook mode")
+			}
+		})
+
+		err := s.socketClient.Start()
+		if err == nil {
+			log.Info().
+				Str("server_url", s.cfg.Runner.ServerURL).
+				Str("network", s.cfg.Network).
+				Msg("Runner service started in WebSocket mode")
+			return nil
+		}
+		log.Warn().Err(err).Msg("WebSocket dispatch unavailable, using webhook mode")
+	}
+
+	return s.startWebhookMode()
+}
+
+// startWebhookMode brings up the tunnel, if configured, and the webhook server
+func (s *Service) startWebhookMode() error {
+	log := gologger.WithComponent("runner")
+
 	// Start tunnel if enabled and wait for it to be ready
 	log.Info().
 		Bool("tunnel_client_exists", s.tunnelClient != nil).
@@ -269,10 +1016,13 @@ func (s *Service) Start() error {
 			return err
 		}
 
+		s.superviseWebhookMode()
+
 		finalWebhookURL := utils.GetWebhookURL()
 		log.Info().
 			Str("final_webhook_url", finalWebhookURL).
 			Bool("tunnel_enabled", s.cfg.Runner.Tunnel.Enabled).
+			Str("network", s.cfg.Network).
 			Msg("Runner service started successfully")
 	} else {
 		log.Error().Msg("Webhook client not initialized")
@@ -282,14 +1032,91 @@ func (s *Service) Start() error {
 	return nil
 }
 
+// superviseWebhookMode watches the webhook server, its heartbeat and the tunnel
+// once they are up
+func (s *Service) superviseWebhookMode() {
+	if s.webhookSupervised {
+		return
+	}
+	s.webhookSupervised = true
+
+	webhookClient := s.webhookClient
+	s.supervisor.Add(supervisor.Subsystem{
+		Name:    "webhook",
+		Check:   func(ctx context.Context) error { return webhookClient.Healthy() },
+		Restart: func(ctx context.Context) error { return webhookClient.Restart() },
+	})
+
+	heartbeat := webhookClient.Heartbeat()
+	s.supervisor.Add(supervisor.Subsystem{
+		Name:    "heartbeat",
+		Check:   func(ctx context.Context) error { return heartbeat.Healthy() },
+		Restart: func(ctx context.Context) error { return heartbeat.Restart() },
+	})
+
+	if tunnelClient := s.tunnelClient; tunnelClient != nil {
+		s.supervisor.Add(supervisor.Subsystem{
+			Name:  "tunnel",
+			Check: func(ctx context.Context) error { return tunnelClient.Healthy() },
+			Restart: func(ctx context.Context) error {
+				if _, err := tunnelClient.Restart(); err != nil {
+					return err
+				}
+				// The new tunnel has a new public URL
+				return webhookClient.Register()
+			},
+		})
+	}
+}
+
 func (s *Service) Stop(ctx context.Context) error {
 	log := gologger.WithComponent("runner")
 	log.Info().Msg("Stopping runner service...")
 
+	// Subsystems stopped below must not be restarted
+	if s.stopSupervisor != nil {
+		s.stopSupervisor()
+	}
+
+	if s.stopWatchdog != nil {
+		s.stopWatchdog()
+	}
+
+	if s.stopIdle != nil {
+		s.stopIdle()
+	}
+
+	if s.stopGC != nil {
+		s.stopGC()
+	}
+
+	if s.stopOutbox != nil {
+		s.stopOutbox()
+	}
+
+	if s.stopJournal != nil {
+		s.stopJournal()
+	}
+
+	if s.stopTelemetry != nil {
+		s.stopTelemetry()
+	}
+
 	done := make(chan error, 1)
 	go func() {
 		var err error
 
+		if s.scheduler != nil {
+			s.stopCoordinatorSources(ctx)
+		}
+
+		if s.socketClient != nil {
+			if stopErr := s.socketClient.Stop(); stopErr != nil {
+				log.Error().Err(stopErr).Msg("Failed to stop WebSocket client")
+				err = stopErr
+			}
+		}
+
 		if s.webhookClient != nil {
 			if stopErr := s.webhookClient.Stop(); stopErr != nil {
 				log.Error().Err(stopErr).Msg("Failed to stop webhook client")
@@ -297,6 +1124,11 @@ func (s *Service) Stop(ctx context.Context) error {
 			}
 		}
 
+		// Queued tasks are dropped, running ones are left to report
+		if s.pool != nil {
+			s.pool.Stop()
+		}
+
 		// Stop tunnel
 		if s.tunnelClient != nil {
 			if stopErr := s.tunnelClient.Stop(); stopErr != nil {
@@ -309,6 +1141,15 @@ func (s *Service) Stop(ctx context.Context) error {
 			}
 		}
 
+		if grpcClient, ok := s.taskClient.(*GRPCTaskClient); ok {
+			if closeErr := grpcClient.Close(); closeErr != nil {
+				log.Error().Err(closeErr).Msg("Failed to close gRPC connection")
+				if err == nil {
+					err = closeErr
+				}
+			}
+		}
+
 		if s.dockerClient != nil {
 			if closeErr := s.dockerClient.Close(); closeErr != nil {
 				log.Error().Err(closeErr).Msg("Failed to close Docker client")
@@ -373,6 +1214,165 @@ func (s *Service) startTunnelWithFallback(ctx context.Context) (string, error) {
 	}
 }
 
+// loadServerVerifier returns a verifier for the server identity pinned at auth
+// time, or nil when none was pinned
+func loadServerVerifier(serverURL string) (*identity.Verifier, error) {
+	log := gologger.WithComponent("runner")
+
+	path, err := identity.DefaultPinPath()
+	if err != nil {
+		return nil, err
+	}
+	pin, err := identity.LoadPin(path)
+	if err != nil {
+		return nil, err
+	}
+	if pin == nil {
+		log.Warn().Msg("No server identity pinned; task deliveries are not verified. Run auth again to pin one")
+		return nil, nil
+	}
+	if pin.ServerURL != "" && pin.ServerURL != serverURL {
+		return nil, fmt.Errorf("server identity was pinned for %s, not %s; run auth again to pin the new server", pin.ServerURL, serverURL)
+	}
+
+	verifier, err := identity.NewVerifier(pin.Address)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("server_identity", verifier.Address()).Msg("Verifying server signatures")
+	return verifier, nil
+}
+
+// loadSandboxBenchmark returns the result of `parity-runner bench sandbox` when
+// it was measured with the runtime tasks run in
+func loadSandboxBenchmark(runtime string) *models.SandboxBenchmark {
+	log := gologger.WithComponent("runner")
+
+	result, err := docker.LoadBenchmark()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load sandbox benchmark, registering without it")
+		return nil
+	}
+	if result == nil {
+		return nil
+	}
+	if engine := sandbox.RuntimeEngine(runtime).Command; result.Engine != engine {
+		log.Warn().
+			Str("benchmarked", result.Engine).
+			Str("runtime", engine).
+			Msg("Sandbox benchmark was measured with another container runtime, run bench sandbox again to publish it")
+		return nil
+	}
+	log.Info().Time("measured_at", result.MeasuredAt).Msg("Publishing sandbox benchmark")
+	return result
+}
+
+// loadHardwareBenchmark returns the signed report of `parity-runner benchmark`
+// when it is valid for this runner's device and wallet
+func loadHardwareBenchmark(deviceID, walletAddress string) *models.BenchmarkReport {
+	log := gologger.WithComponent("runner")
+
+	report, err := benchmark.Load()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load hardware benchmark, registering without it")
+		return nil
+	}
+	if report == nil {
+		return nil
+	}
+	if err := benchmark.Verify(report); err != nil {
+		log.Warn().Err(err).Msg("Saved hardware benchmark is invalid, run benchmark again to publish it")
+		return nil
+	}
+	if report.DeviceID != deviceID || !strings.EqualFold(report.WalletAddress, walletAddress) {
+		log.Warn().
+			Str("benchmarked_device", report.DeviceID).
+			Msg("Hardware benchmark was measured for another device or wallet, run benchmark again to publish it")
+		return nil
+	}
+	log.Info().
+		Float64("score", report.Scores.Overall).
+		Time("measured_at", report.MeasuredAt).
+		Msg("Publishing hardware benchmark")
+	return report
+}
+
+// newEnergyScheduler prices task electricity from the price API or the tariff,
+// nil when no token price is configured
+func newEnergyScheduler(cfg config.EnergyConfig) (*energy.Scheduler, error) {
+	if cfg.TokenPrice <= 0 {
+		return nil, nil
+	}
+	tariff, err := energy.ParseTariff(cfg.Tariff, cfg.Price)
+	if err != nil {
+		return nil, err
+	}
+	taskEnergy, err := energy.ParseTaskEnergy(cfg.TaskKWh)
+	if err != nil {
+		return nil, err
+	}
+
+	var prices energy.PriceSource = tariff
+	if cfg.PriceURL != "" {
+		refresh := cfg.PriceRefresh
+		if refresh <= 0 {
+			refresh = 5 * time.Minute
+		}
+		prices = &energy.PriceAPI{URL: cfg.PriceURL, Field: cfg.PriceField, Refresh: refresh, Fallback: tariff}
+	}
+	return energy.NewScheduler(energy.Config{
+		Prices:     prices,
+		TokenPrice: cfg.TokenPrice,
+		PeakPrice:  cfg.PeakPrice,
+		Watts:      cfg.Watts,
+		TaskEnergy: taskEnergy,
+	}), nil
+}
+
+// biddingPricing is the pricing tasks are bid at, 10 minutes of runtime for
+// tasks without a timeout unless configured otherwise
+func biddingPricing(cfg config.BiddingConfig) bidding.Pricing {
+	if cfg.Runtime <= 0 {
+		cfg.Runtime = 10 * time.Minute
+	}
+	return bidding.Pricing{
+		Base:       cfg.Base,
+		PerCPUHour: cfg.PerCPUHour,
+		PerGBHour:  cfg.PerGBHour,
+		PerGPUHour: cfg.PerGPUHour,
+		Margin:     cfg.Margin,
+		Runtime:    cfg.Runtime,
+	}
+}
+
+func newWorkerPool(cfg config.RunnerConfig) (*task.Pool, error) {
+	poolConfig := task.PoolConfig{
+		MaxConcurrent: max(cfg.MaxConcurrentTasks, 1),
+		QueueSize:     cfg.WorkerPool.QueueSize,
+		Capacity:      task.Reservation{CPUs: cfg.WorkerPool.CPUs},
+		Default:       task.Reservation{CPUs: cfg.WorkerPool.TaskCPUs},
+	}
+	if poolConfig.Capacity.CPUs <= 0 {
+		poolConfig.Capacity.CPUs = float64(runtime.NumCPU())
+	}
+	if poolConfig.Default.CPUs <= 0 {
+		poolConfig.Default.CPUs = 1
+	}
+
+	var err error
+	if cfg.WorkerPool.Memory != "" {
+		if poolConfig.Capacity.MemoryBytes, err = task.ParseMemory(cfg.WorkerPool.Memory); err != nil {
+			return nil, fmt.Errorf("invalid memory %q: %w", cfg.WorkerPool.Memory, err)
+		}
+	}
+	if cfg.WorkerPool.TaskMemory != "" {
+		if poolConfig.Default.MemoryBytes, err = task.ParseMemory(cfg.WorkerPool.TaskMemory); err != nil {
+			return nil, fmt.Errorf("invalid task memory %q: %w", cfg.WorkerPool.TaskMemory, err)
+		}
+	}
+	return task.NewPool(poolConfig), nil
+}
+
 func checkDockerAvailability(cli *client.Client) error {
 	log := gologger.WithComponent("docker")
 
@@ -391,3 +1391,22 @@ func checkDockerAvailability(cli *client.Client) error {
 
 	return nil
 }
+
+func loadHooks(cfg config.HooksConfig) (*hooks.Registry, error) {
+	registry := hooks.NewRegistry()
+	stages := []struct {
+		stage hooks.Stage
+		paths string
+	}{
+		{hooks.StagePreClaim, cfg.PreClaim},
+		{hooks.StagePreExecute, cfg.PreExecute},
+		{hooks.StagePostExecute, cfg.PostExecute},
+		{hooks.StagePreSubmit, cfg.PreSubmit},
+	}
+	for _, s := range stages {
+		if err := registry.RegisterPaths(s.stage, s.paths, cfg.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
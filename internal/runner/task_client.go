@@ -7,11 +7,16 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/theblitlabs/parity-runner/internal/compression"
 	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/logstream"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
 	"github.com/theblitlabs/parity-runner/internal/utils"
 )
 
@@ -20,8 +25,12 @@ var resolveDeviceID = func() (string, error) {
 }
 
 type HTTPTaskClient struct {
-	baseURL string
-	client  *http.Client
+	baseURL  string
+	client   *http.Client
+	verifier *identity.Verifier
+	// resultEncoding is the Content-Encoding negotiated with the server at
+	// registration, identity until then
+	resultEncoding atomic.Value
 }
 
 func NewHTTPTaskClient(baseURL string) *HTTPTaskClient {
@@ -33,6 +42,36 @@ func NewHTTPTaskClient(baseURL string) *HTTPTaskClient {
 	}
 }
 
+// SetServerVerifier makes the client reject task listings and task starts that
+// were not signed by the pinned server identity
+func (c *HTTPTaskClient) SetServerVerifier(verifier *identity.Verifier) {
+	c.verifier = verifier
+}
+
+// SetResultEncodings picks how results are compressed from the encodings the
+// server advertised when the runner registered
+func (c *HTTPTaskClient) SetResultEncodings(advertised []string) {
+	c.resultEncoding.Store(compression.Negotiate(advertised))
+}
+
+func (c *HTTPTaskClient) encodingFor(body []byte) string {
+	encoding, _ := c.resultEncoding.Load().(string)
+	if encoding == "" || len(body) < compression.MinBytes {
+		return compression.Identity
+	}
+	return encoding
+}
+
+func (c *HTTPTaskClient) verifyResponse(resp *http.Response, body []byte) error {
+	if c.verifier == nil {
+		return nil
+	}
+	if err := c.verifier.VerifyResponse(resp, body); err != nil {
+		return fmt.Errorf("server response failed identity check: %w", err)
+	}
+	return nil
+}
+
 func (c *HTTPTaskClient) FetchTask() (*models.Task, error) {
 	tasks, err := c.GetAvailableTasks()
 	if err != nil {
@@ -55,7 +94,7 @@ func (c *HTTPTaskClient) UpdateTaskStatus(taskID string, status models.TaskStatu
 	switch status {
 	case models.TaskStatusRunning:
 		return c.StartTask(taskID)
-	case models.TaskStatusCompleted, models.TaskStatusFailed:
+	case models.TaskStatusCompleted, models.TaskStatusFailed, models.TaskStatusCancelled:
 		if result != nil {
 			return c.SaveTaskResult(taskID, result)
 		}
@@ -82,6 +121,9 @@ func (c *HTTPTaskClient) GetAvailableTasks() ([]*models.Task, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("X-Device-ID", deviceID)
+	if err := identity.SetNonce(req); err != nil {
+		return nil, err
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -93,8 +135,16 @@ func (c *HTTPTaskClient) GetAvailableTasks() ([]*models.Task, error) {
 		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := c.verifyResponse(resp, body); err != nil {
+		return nil, err
+	}
+
 	var tasks []*models.Task
-	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+	if err := json.Unmarshal(body, &tasks); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
@@ -116,6 +166,9 @@ func (c *HTTPTaskClient) StartTask(taskID string) error {
 	}
 
 	req.Header.Set("X-Device-ID", deviceID)
+	if err := identity.SetNonce(req); err != nil {
+		return err
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -127,7 +180,7 @@ func (c *HTTPTaskClient) StartTask(taskID string) error {
 
 	switch resp.StatusCode {
 	case http.StatusOK:
-		return nil
+		return c.verifyResponse(resp, body)
 	case http.StatusConflict:
 		return fmt.Errorf("task unavailable: %s", string(body))
 	case http.StatusBadRequest:
@@ -192,31 +245,293 @@ func (c *HTTPTaskClient) SaveTaskResult(taskID string, result *models.TaskResult
 		return fmt.Errorf("failed to marshal result: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	encoding := c.encodingFor(body)
+	resp, err := c.postResult(url, deviceID, body, encoding)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
+	}
+	if resp.StatusCode == http.StatusUnsupportedMediaType && encoding != compression.Identity {
+		// The server no longer accepts what it advertised, so stop compressing
+		resp.Body.Close()
+		c.resultEncoding.Store(compression.Identity)
+		if resp, err = c.postResult(url, deviceID, body, compression.Identity); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if decodeErr := json.NewDecoder(resp.Body).Decode(&errResp); decodeErr == nil && errResp.Error != "" {
+			err = fmt.Errorf("server error: %s", errResp.Error)
+		}
+		if refusedForGood(resp.StatusCode) {
+			return fmt.Errorf("%w: %w", outbox.ErrRejected, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// refusedForGood reports whether the server answered with a status that
+// sending the same request again cannot change. Other client errors, such as
+// expired credentials or a request timeout, may pass on a later attempt.
+func refusedForGood(code int) bool {
+	switch code {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusGone, http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
+func (c *HTTPTaskClient) postResult(url, deviceID string, body []byte, encoding string) (*http.Response, error) {
+	encoded, err := compression.Encode(encoding, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress result: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Device-ID", deviceID)
+	if encoding != compression.Identity {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP POST failed for %s: %w", url, err)
+	}
+	return resp, nil
+}
+
+// FetchTaskCredentials asks the server for the credentials a running task
+// requested. Only the runner the task is assigned to gets them.
+func (c *HTTPTaskClient) FetchTaskCredentials(taskID string) ([]models.TaskCredentials, error) {
+	baseURL := strings.TrimSuffix(c.baseURL, "/api")
+	url := fmt.Sprintf("%s/api/v1/runners/tasks/%s/credentials", baseURL, taskID)
+
+	deviceID, err := resolveDeviceID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device ID: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Device-ID", deviceID)
+	if err := identity.SetNonce(req); err != nil {
+		return nil, err
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("HTTP POST failed for %s: %w", url, err)
+		return nil, fmt.Errorf("HTTP GET failed for %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
 	if resp.StatusCode != http.StatusOK {
 		var errResp struct {
 			Error string `json:"error"`
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil && errResp.Error != "" {
-			return fmt.Errorf("server error: %s", errResp.Error)
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("server error: %s", errResp.Error)
 		}
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if err := c.verifyResponse(resp, body); err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Credentials []models.TaskCredentials `json:"credentials"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return response.Credentials, nil
+}
+
+// StreamTaskLogs pushes output of a running task to the server. Servers
+// without log streaming and tasks the server no longer runs here are
+// reported as logstream.ErrRejected.
+func (c *HTTPTaskClient) StreamTaskLogs(taskID string, chunks []models.LogChunk) error {
+	baseURL := strings.TrimSuffix(c.baseURL, "/api")
+	url := fmt.Sprintf("%s/api/v1/runners/tasks/%s/logs", baseURL, taskID)
+
+	deviceID, err := resolveDeviceID()
+	if err != nil {
+		return fmt.Errorf("failed to get device ID: %w", err)
+	}
+	body, err := json.Marshal(map[string]interface{}{"chunks": chunks})
+	if err != nil {
+		return fmt.Errorf("failed to marshal task logs: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Device-ID", deviceID)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP POST failed for %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case refusedForGood(resp.StatusCode):
+		return fmt.Errorf("%w: status code %d", logstream.ErrRejected, resp.StatusCode)
+	default:
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
+}
+
+// JoinGang reports where this runner's member of a gang task listens for its
+// peers and returns the gang
+func (c *HTTPTaskClient) JoinGang(taskID string, join models.GangJoin) (*models.GangStatus, error) {
+	body, err := json.Marshal(join)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal gang join: %w", err)
+	}
+	return c.gangRequest(http.MethodPost, taskID, body)
+}
 
-	return nil
+// GangStatus returns the gang this runner's member task belongs to
+func (c *HTTPTaskClient) GangStatus(taskID string) (*models.GangStatus, error) {
+	return c.gangRequest(http.MethodGet, taskID, nil)
+}
+
+func (c *HTTPTaskClient) gangRequest(method, taskID string, body []byte) (*models.GangStatus, error) {
+	baseURL := strings.TrimSuffix(c.baseURL, "/api")
+	url := fmt.Sprintf("%s/api/v1/runners/tasks/%s/gang", baseURL, taskID)
+
+	deviceID, err := resolveDeviceID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device ID: %w", err)
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Device-ID", deviceID)
+	if err := identity.SetNonce(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP %s failed for %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(respBody, &errResp); err == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("server error: %s", errResp.Error)
+		}
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if err := c.verifyResponse(resp, respBody); err != nil {
+		return nil, err
+	}
+
+	var status models.GangStatus
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &status, nil
+}
+
+func (c *HTTPTaskClient) TaskState(taskID string) (*models.RunnerTaskState, error) {
+	body, err := c.heldTaskRequest(http.MethodGet, taskID, "")
+	if err != nil {
+		return nil, err
+	}
+	var state models.RunnerTaskState
+	if err := json.Unmarshal(body, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &state, nil
+}
+
+func (c *HTTPTaskClient) ReleaseTask(taskID string) error {
+	_, err := c.heldTaskRequest(http.MethodPost, taskID, "/release")
+	return err
+}
+
+// heldTaskRequest calls an endpoint about a task the runner held before it
+// restarted, reporting tasks the server does not hold for it as ErrTaskUnknown
+func (c *HTTPTaskClient) heldTaskRequest(method, taskID, suffix string) ([]byte, error) {
+	baseURL := strings.TrimSuffix(c.baseURL, "/api")
+	url := fmt.Sprintf("%s/api/v1/runners/tasks/%s%s", baseURL, taskID, suffix)
+
+	deviceID, err := resolveDeviceID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device ID: %w", err)
+	}
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Device-ID", deviceID)
+	if err := identity.SetNonce(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP %s failed for %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := c.verifyResponse(resp, body); err != nil {
+			return nil, err
+		}
+		return body, nil
+	case http.StatusNotFound:
+		return nil, ErrTaskUnknown
+	default:
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("server error: %s", errResp.Error)
+		}
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
 }
 
 func (c *HTTPTaskClient) CompletePrompt(promptID uuid.UUID, response string, promptTokens, responseTokens int, inferenceTime int64) error {
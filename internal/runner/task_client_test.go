@@ -2,13 +2,18 @@ package runner
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/theblitlabs/parity-runner/internal/compression"
 	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/logstream"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
 )
 
 func TestUpdateTaskStatusSkipsCompleteEndpointWhenResultPresent(t *testing.T) {
@@ -60,3 +65,109 @@ func TestUpdateTaskStatusSkipsCompleteEndpointWhenResultPresent(t *testing.T) {
 		t.Fatalf("result endpoint called %d times, want 1", resultCalls.Load())
 	}
 }
+
+func TestSaveTaskResultCompressesAndFallsBack(t *testing.T) {
+	originalResolveDeviceID := resolveDeviceID
+	resolveDeviceID = func() (string, error) { return "runner-1", nil }
+	t.Cleanup(func() {
+		resolveDeviceID = originalResolveDeviceID
+	})
+
+	var encodings []string
+	refuse := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Content-Encoding")
+		encodings = append(encodings, encoding)
+		if refuse && encoding != "" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := compression.Decode(encoding, r.Body, 1<<20)
+		if err != nil {
+			t.Fatalf("failed to decode %q body: %v", encoding, err)
+		}
+		var result models.TaskResult
+		if err := json.Unmarshal(body, &result); err != nil || result.Output == "" {
+			t.Fatalf("decoded result = %+v, %v", result, err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPTaskClient(server.URL + "/api")
+	client.SetResultEncodings([]string{"gzip", "zstd"})
+	large := &models.TaskResult{Output: strings.Repeat("epoch 3 accuracy 0.91\n", 500)}
+	small := &models.TaskResult{Output: "ok"}
+
+	for _, result := range []*models.TaskResult{large, small} {
+		if err := client.SaveTaskResult(uuid.NewString(), result); err != nil {
+			t.Fatalf("SaveTaskResult() error = %v", err)
+		}
+	}
+	refuse = true
+	if err := client.SaveTaskResult(uuid.NewString(), large); err != nil {
+		t.Fatalf("SaveTaskResult() after 415 error = %v", err)
+	}
+	if err := client.SaveTaskResult(uuid.NewString(), large); err != nil {
+		t.Fatalf("SaveTaskResult() error = %v", err)
+	}
+
+	want := []string{"zstd", "", "zstd", "", ""}
+	if strings.Join(encodings, ",") != strings.Join(want, ",") {
+		t.Fatalf("encodings = %q, want %q", encodings, want)
+	}
+}
+
+func TestSaveTaskResultReportsRejections(t *testing.T) {
+	originalResolveDeviceID := resolveDeviceID
+	resolveDeviceID = func() (string, error) { return "runner-1", nil }
+	t.Cleanup(func() {
+		resolveDeviceID = originalResolveDeviceID
+	})
+
+	tests := []struct {
+		code     int
+		rejected bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusNotFound, true},
+		{http.StatusRequestTimeout, false},
+		{http.StatusConflict, true},
+		{http.StatusGone, true},
+		{http.StatusRequestEntityTooLarge, false},
+		{http.StatusUnprocessableEntity, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.code), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+				if strings.HasSuffix(r.URL.Path, "/logs") {
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]string{"error": "Task assignment was revoked"})
+			}))
+			defer server.Close()
+			client := NewHTTPTaskClient(server.URL + "/api")
+
+			err := client.SaveTaskResult(uuid.NewString(), &models.TaskResult{Output: "ok"})
+			if err == nil || !strings.Contains(err.Error(), "assignment was revoked") {
+				t.Fatalf("SaveTaskResult() = %v, want the server's error", err)
+			}
+			if got := errors.Is(err, outbox.ErrRejected); got != tt.rejected {
+				t.Fatalf("SaveTaskResult() rejected = %v, want %v: %v", got, tt.rejected, err)
+			}
+
+			err = client.StreamTaskLogs(uuid.NewString(), []models.LogChunk{{Data: "line"}})
+			if err == nil {
+				t.Fatal("StreamTaskLogs() = nil, want an error")
+			}
+			if got := errors.Is(err, logstream.ErrRejected); got != tt.rejected {
+				t.Fatalf("StreamTaskLogs() rejected = %v, want %v: %v", got, tt.rejected, err)
+			}
+		})
+	}
+}
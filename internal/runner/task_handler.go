@@ -3,49 +3,414 @@ package runner
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/theblitlabs/gologger"
 
+	"github.com/theblitlabs/parity-runner/internal/artifacts"
+	"github.com/theblitlabs/parity-runner/internal/attestation"
+	"github.com/theblitlabs/parity-runner/internal/chaos"
 	"github.com/theblitlabs/parity-runner/internal/core/models"
 	"github.com/theblitlabs/parity-runner/internal/core/ports"
+	"github.com/theblitlabs/parity-runner/internal/credentials"
+	"github.com/theblitlabs/parity-runner/internal/diagnostics"
+	"github.com/theblitlabs/parity-runner/internal/events"
+	"github.com/theblitlabs/parity-runner/internal/hooks"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+	"github.com/theblitlabs/parity-runner/internal/journal"
+	"github.com/theblitlabs/parity-runner/internal/ledger"
+	"github.com/theblitlabs/parity-runner/internal/logstream"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
+	"github.com/theblitlabs/parity-runner/internal/receipt"
+	"github.com/theblitlabs/parity-runner/internal/signer"
+	"github.com/theblitlabs/parity-runner/internal/telemetry"
 	"github.com/theblitlabs/parity-runner/internal/utils"
 )
 
 type DefaultTaskHandler struct {
-	executor     ports.TaskExecutor
-	taskClient   ports.TaskClient
-	isProcessing atomic.Bool
+	executor      ports.TaskExecutor
+	taskClient    ports.TaskClient
+	running       atomic.Int32
+	maxRunning    atomic.Int32
+	receiptSigner func() (signer.Signer, error)
+	receiptDir    string
+	hooks         *hooks.Registry
+	policy        models.RunnerPolicy
+	policyMu      sync.Mutex
+	chaos         *chaos.Injector
+	artifacts     *artifacts.Store
+	abortMu       sync.Mutex
+	aborts        map[string]context.CancelCauseFunc
+	// accepted are the tasks queued for the handler, cancelled those of them
+	// whose creator cancelled them before they started, with the reason
+	accepted  map[string]bool
+	cancelled map[string]string
+	// gangAddress is where other members of a gang reach this runner
+	gangAddress string
+	// uploads receives outputs larger than uploadThreshold instead of the
+	// result submission
+	uploads         ipfs.Backend
+	uploadThreshold int64
+	// attester quotes the results of tasks that ask for TEE isolation
+	attester *attestation.Attester
+	// outbox keeps results the server did not accept for later delivery
+	outbox *outbox.Store
+	// journal keeps the tasks the runner holds until they are finished
+	journal *journal.Store
+	// ledger records every executed task for the operator's accounting
+	ledger *ledger.Store
+	// telemetry counts finished tasks when the operator opted in
+	telemetry *telemetry.Collector
+	// diagnostics shapes the bundle attached to the results of failed tasks
+	diagnostics diagnostics.Config
+	// draining is set once the runner stops taking tasks before it exits
+	draining atomic.Bool
+	// events keeps the execution log of every task
+	events *events.Store
+	// logInterval is how often the output of running Docker tasks is pushed
+	// to the server, zero when it is not streamed
+	logInterval time.Duration
 }
 
+// ErrTaskAborted is the cause of a task stopped by AbortCurrentTask
+var ErrTaskAborted = errors.New("task aborted by runner")
+
+// ErrTaskCancelled is the cause of a task its creator cancelled, which is
+// reported with the cancelled status rather than as failed
+var ErrTaskCancelled = errors.New("task cancelled by its creator")
+
+// ErrDraining rejects tasks that arrive while the runner drains
+var ErrDraining = errors.New("runner is draining and takes no new tasks")
+
 type LLMTaskClient interface {
 	CompletePrompt(promptID uuid.UUID, response string, promptTokens, responseTokens int, inferenceTime int64) error
 	FailPrompt(promptID uuid.UUID, reason string) error
 }
 
+type FLTaskClient interface {
+	SubmitFLModelUpdate(sessionID, roundID, runnerID string, gradients map[string][]float64, weights map[string][]float64, dataSize int, loss, accuracy float64, trainingTime int) error
+}
+
+// LogStreamClient pushes the output of a running task to the server
+type LogStreamClient interface {
+	StreamTaskLogs(taskID string, chunks []models.LogChunk) error
+}
+
+// CredentialClient fetches the credentials brokered for a task assigned to
+// this runner
+type CredentialClient interface {
+	FetchTaskCredentials(taskID string) ([]models.TaskCredentials, error)
+}
+
 func NewTaskHandler(executor ports.TaskExecutor, taskClient ports.TaskClient) *DefaultTaskHandler {
+	receiptDir, err := receipt.DefaultDir()
+	if err != nil {
+		log := gologger.WithComponent("task_handler")
+		log.Warn().Err(err).Msg("Receipts will not be stored locally")
+	}
+
 	return &DefaultTaskHandler{
-		executor:   executor,
-		taskClient: taskClient,
+		executor:      executor,
+		taskClient:    taskClient,
+		receiptSigner: utils.GetSigner,
+		receiptDir:    receiptDir,
+	}
+}
+
+// SetHooks installs the lifecycle hooks run around claiming, executing and submitting tasks
+func (h *DefaultTaskHandler) SetHooks(registry *hooks.Registry) {
+	h.hooks = registry
+}
+
+// SetPolicy restricts the task types and creators the handler runs work for
+func (h *DefaultTaskHandler) SetPolicy(policy models.RunnerPolicy) {
+	h.policyMu.Lock()
+	defer h.policyMu.Unlock()
+	h.policy = policy
+}
+
+func (h *DefaultTaskHandler) checkPolicy(task *models.Task) error {
+	h.policyMu.Lock()
+	defer h.policyMu.Unlock()
+	return h.policy.Check(task)
+}
+
+// SetChaos installs the fault injector that fails result submissions
+func (h *DefaultTaskHandler) SetChaos(injector *chaos.Injector) {
+	h.chaos = injector
+}
+
+// SetArtifacts keeps the task, result and output of every finished task in store
+func (h *DefaultTaskHandler) SetArtifacts(store *artifacts.Store) {
+	h.artifacts = store
+}
+
+// SetOutbox queues results that cannot be submitted in store
+func (h *DefaultTaskHandler) SetOutbox(store *outbox.Store) {
+	h.outbox = store
+}
+
+// SetJournal keeps the tasks the runner accepted and started in store until
+// they are finished, for the runner to reconcile after a restart
+func (h *DefaultTaskHandler) SetJournal(store *journal.Store) {
+	h.journal = store
+}
+
+// SetLedger records every task the handler executes in store
+func (h *DefaultTaskHandler) SetLedger(store *ledger.Store) {
+	h.ledger = store
+}
+
+// SetEvents logs the execution of every task in store and attaches the log
+// to its result
+func (h *DefaultTaskHandler) SetEvents(store *events.Store) {
+	h.events = store
+}
+
+// SetLogStream pushes the output of running Docker tasks to the server every
+// interval, logstream.DefaultInterval when zero
+func (h *DefaultTaskHandler) SetLogStream(interval time.Duration) {
+	if interval <= 0 {
+		interval = logstream.DefaultInterval
 	}
+	h.logInterval = interval
 }
 
+// SetTelemetry counts every task the handler runs in collector
+func (h *DefaultTaskHandler) SetTelemetry(collector *telemetry.Collector) {
+	h.telemetry = collector
+}
+
+// SetDiagnostics sets how the diagnostic bundles of failed tasks are assembled,
+// or turns them off
+func (h *DefaultTaskHandler) SetDiagnostics(config diagnostics.Config) {
+	h.diagnostics = config
+}
+
+// SetMaxConcurrent sets how many tasks HandleTask runs at once, 1 by default
+func (h *DefaultTaskHandler) SetMaxConcurrent(n int) {
+	if n < 1 {
+		n = 1
+	}
+	h.maxRunning.Store(int32(n))
+}
+
+// AbortCurrentTask stops every task being executed, which are then reported as
+// failed with reason. It returns false when no task is executing.
+func (h *DefaultTaskHandler) AbortCurrentTask(reason string) bool {
+	h.abortMu.Lock()
+	defer h.abortMu.Unlock()
+	for _, abort := range h.aborts {
+		abort(fmt.Errorf("%w: %s", ErrTaskAborted, reason))
+	}
+	return len(h.aborts) > 0
+}
+
+// AbortTask stops a single running task. It returns false when the task is not
+// executing.
+func (h *DefaultTaskHandler) AbortTask(taskID, reason string) bool {
+	h.abortMu.Lock()
+	defer h.abortMu.Unlock()
+	abort, ok := h.aborts[taskID]
+	if ok {
+		abort(fmt.Errorf("%w: %s", ErrTaskAborted, reason))
+	}
+	return ok
+}
+
+// CancelTask stops a task on behalf of its creator. A running task's container
+// is stopped gracefully and the task reported as cancelled; a task accepted but
+// not started yet is reported as cancelled once it is claimed instead of run.
+// It returns false when the handler does not hold the task.
+func (h *DefaultTaskHandler) CancelTask(taskID, reason string) bool {
+	h.abortMu.Lock()
+	defer h.abortMu.Unlock()
+	if abort, ok := h.aborts[taskID]; ok {
+		abort(fmt.Errorf("%w: %s", ErrTaskCancelled, reason))
+		return true
+	}
+	if !h.accepted[taskID] {
+		return false
+	}
+	if h.cancelled == nil {
+		h.cancelled = make(map[string]string)
+	}
+	h.cancelled[taskID] = reason
+	return true
+}
+
+// markAccepted records that a task was queued for the handler, so it can be
+// cancelled before it starts
+func (h *DefaultTaskHandler) markAccepted(taskID string, accepted bool) {
+	h.abortMu.Lock()
+	defer h.abortMu.Unlock()
+	if !accepted {
+		delete(h.accepted, taskID)
+		delete(h.cancelled, taskID)
+		return
+	}
+	if h.accepted == nil {
+		h.accepted = make(map[string]bool)
+	}
+	h.accepted[taskID] = true
+}
+
+// cancellation is why a task was cancelled before it started, nil when it
+// was not
+func (h *DefaultTaskHandler) cancellation(taskID string) error {
+	h.abortMu.Lock()
+	defer h.abortMu.Unlock()
+	reason, ok := h.cancelled[taskID]
+	if !ok {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrTaskCancelled, reason)
+}
+
+func (h *DefaultTaskHandler) setAbort(taskID string, abort context.CancelCauseFunc) {
+	h.abortMu.Lock()
+	defer h.abortMu.Unlock()
+	if abort == nil {
+		delete(h.aborts, taskID)
+		return
+	}
+	if h.aborts == nil {
+		h.aborts = make(map[string]context.CancelCauseFunc)
+	}
+	h.aborts[taskID] = abort
+}
+
+// IsProcessing reports whether tasks are running. A draining runner is always
+// busy, so heartbeats keep the server from sending it tasks.
 func (h *DefaultTaskHandler) IsProcessing() bool {
-	return h.isProcessing.Load()
+	return h.draining.Load() || h.running.Load() > 0
+}
+
+// SetDraining makes the handler refuse tasks it has not claimed yet
+func (h *DefaultTaskHandler) SetDraining(draining bool) {
+	h.draining.Store(draining)
+}
+
+// Running is the number of tasks being executed
+func (h *DefaultTaskHandler) Running() int {
+	return int(h.running.Load())
+}
+
+// acquire takes a slot for a task, failing when maxRunning tasks already run
+func (h *DefaultTaskHandler) acquire() bool {
+	limit := max(h.maxRunning.Load(), 1)
+	for {
+		running := h.running.Load()
+		if running >= limit {
+			return false
+		}
+		if h.running.CompareAndSwap(running, running+1) {
+			return true
+		}
+	}
 }
 
 func (h *DefaultTaskHandler) verifyNonce(nonceStr string) error {
 	return utils.VerifyDrandNonce(nonceStr)
 }
 
-func (h *DefaultTaskHandler) HandleTask(task *models.Task) error {
-	if h.isProcessing.Load() {
+// taskSetupAllowance is added to the server's maximum duration so that image
+// pulls and container setup do not count against the task's own run time
+const taskSetupAllowance = time.Minute
+
+// taskDeadline bounds a task by the server's maximum duration, or by fallback
+// when the server did not set one
+func taskDeadline(task *models.Task, fallback time.Duration) time.Duration {
+	if limit := task.MaxDuration(); limit > 0 {
+		return limit + taskSetupAllowance
+	}
+	return fallback
+}
+
+// withTaskCredentials fetches the credentials the task requested and hands them
+// to the executor through ctx
+func (h *DefaultTaskHandler) withTaskCredentials(ctx context.Context, task *models.Task) (context.Context, error) {
+	if task.Type != models.TaskTypeDocker {
+		return ctx, nil
+	}
+	var config models.TaskConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return ctx, fmt.Errorf("invalid task config: %w", err)
+	}
+	if len(config.Credentials) == 0 {
+		return ctx, nil
+	}
+
+	client, ok := h.taskClient.(CredentialClient)
+	if !ok {
+		return ctx, fmt.Errorf("task client does not support task credentials")
+	}
+	issued, err := client.FetchTaskCredentials(task.ID.String())
+	if err != nil {
+		return ctx, fmt.Errorf("failed to fetch task credentials: %w", err)
+	}
+	return credentials.WithTaskCredentials(ctx, issued), nil
+}
+
+// startLogStream streams the output of a Docker task to the server, when
+// enabled and the task client can. It returns nil otherwise.
+func (h *DefaultTaskHandler) startLogStream(task *models.Task) *logstream.Stream {
+	if h.logInterval <= 0 || task.Type != models.TaskTypeDocker {
+		return nil
+	}
+	client, ok := h.taskClient.(LogStreamClient)
+	if !ok {
+		return nil
+	}
+	taskID := task.ID.String()
+	return logstream.New(taskID, func(chunks []models.LogChunk) error {
+		return client.StreamTaskLogs(taskID, chunks)
+	}, h.logInterval)
+}
+
+func (h *DefaultTaskHandler) HandleTask(task *models.Task) (err error) {
+	// A claimed task stays in the journal until its result was delivered or
+	// kept in the outbox, so a restart in between does not lose it. Tasks
+	// never claimed are left for the server to hand out again.
+	claimed := false
+	defer func() {
+		if claimed {
+			h.markAccepted(task.ID.String(), false)
+		} else {
+			h.ForgetTask(task.ID.String())
+		}
+	}()
+
+	// Queued tasks are not claimed yet and are left for other runners
+	if h.draining.Load() {
+		return ErrDraining
+	}
+	if err := h.checkPolicy(task); err != nil {
+		return err
+	}
+	if task.RequiresTEE() && !h.runsInTEE(task) {
+		return fmt.Errorf("task needs a trusted execution environment this runner does not offer")
+	}
+
+	if !h.acquire() {
 		return fmt.Errorf("task already in progress")
 	}
+	defer h.running.Add(-1)
+
+	exitCode := 0
+	defer func() {
+		if err := h.telemetry.RecordTask(task.Type, failureClass(exitCode, err)); err != nil {
+			log := gologger.WithComponent("task_handler")
+			log.Debug().Err(err).Msg("Failed to count task for telemetry")
+		}
+	}()
 
 	log := gologger.WithComponent("task_handler")
 	// Only log federated learning task starts at info level due to their importance
@@ -61,10 +426,12 @@ func (h *DefaultTaskHandler) HandleTask(task *models.Task) error {
 			Msg("Starting task execution")
 	}
 
-	h.isProcessing.Store(true)
-	defer h.isProcessing.Store(false)
+	if err := h.hooks.Run(context.Background(), hooks.StagePreClaim, task, nil); err != nil {
+		return fmt.Errorf("task rejected before claim: %w", err)
+	}
 
 	if task.Type == models.TaskTypeLLM {
+		claimed = true
 		return h.handleLLMTask(task)
 	}
 
@@ -72,39 +439,91 @@ func (h *DefaultTaskHandler) HandleTask(task *models.Task) error {
 		log.Error().Err(err).Str("id", task.ID.String()).Msg("Failed to update task status to running")
 		return fmt.Errorf("failed to claim task for execution: %w", err)
 	}
+	claimed = true
+	h.journalStart(task)
+	h.recordEvent(task, models.ExecutionEventReceived, map[string]string{
+		"type":    string(task.Type),
+		"nonce":   task.Nonce,
+		"creator": task.CreatorAddress,
+	})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), taskDeadline(task, 20*time.Minute))
 	defer cancel()
+	ctx = events.WithTask(ctx, h.events, task.ID.String())
+	ctx, abort := context.WithCancelCause(ctx)
+	h.setAbort(task.ID.String(), abort)
+	defer func() {
+		h.setAbort(task.ID.String(), nil)
+		abort(nil)
+	}()
+
+	// A task cancelled while it waited is reported without running it
+	if cause := h.cancellation(task.ID.String()); cause != nil {
+		log.Info().Err(cause).Str("id", task.ID.String()).Msg("Task cancelled before it started")
+		h.reportCancelled(task, failedResult(task, cause, 0, nil))
+		return cause
+	}
 
 	if err := h.verifyNonce(task.Nonce); err != nil {
 		log.Error().Err(err).Str("id", task.ID.String()).Msg("Nonce verification failed")
-		if updateErr := h.taskClient.UpdateTaskStatus(task.ID.String(), models.TaskStatusFailed, &models.TaskResult{
-			TaskID:        task.ID,
-			Error:         err.Error(),
-			ExecutionTime: 1,
-		}); updateErr != nil {
-			log.Error().Err(updateErr).Str("id", task.ID.String()).Msg("Failed to update task status")
-		}
+		h.reportFailure(task, failedResult(task, err, 1, nil))
 		return err
 	}
 
+	if err := h.hooks.Run(ctx, hooks.StagePreExecute, task, nil); err != nil {
+		h.reportFailure(task, failedResult(task, err, 1, nil))
+		return err
+	}
+
+	ctx, err = h.withTaskCredentials(ctx, task)
+	if err != nil {
+		log.Error().Err(err).Str("id", task.ID.String()).Msg("Failed to get task credentials")
+		h.reportFailure(task, failedResult(task, err, 1, nil))
+		return err
+	}
+
+	ctx, err = h.withGang(ctx, task, abort)
+	if err != nil {
+		log.Error().Err(err).Str("id", task.ID.String()).Msg("Gang did not form")
+		h.reportFailure(task, failedResult(task, err, 1, nil))
+		return err
+	}
+
+	stream := h.startLogStream(task)
+	ctx = logstream.WithStream(ctx, stream)
+
 	executionStartedAt := time.Now()
-	result, err := h.executor.ExecuteTask(ctx, task)
+	result, err := h.executeWithRetries(ctx, task)
+	stream.Close()
+	if cause := context.Cause(ctx); errors.Is(cause, ErrTaskCancelled) {
+		log.Info().Err(cause).Str("id", task.ID.String()).Msg("Task cancelled")
+		h.reportCancelled(task, failedResult(task, cause, durationMilliseconds(time.Since(executionStartedAt)), result))
+		return cause
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrTaskAborted) || errors.Is(cause, ErrGangFailed) {
+		log.Warn().Err(cause).Str("id", task.ID.String()).Msg("Task aborted")
+		h.reportFailure(task, failedResult(task, cause, durationMilliseconds(time.Since(executionStartedAt)), result))
+		return cause
+	}
 	if err != nil {
-		executionTime := durationMilliseconds(time.Since(executionStartedAt))
 		log.Error().Err(err).Str("id", task.ID.String()).Msg("Task execution failed")
-		if updateErr := h.taskClient.UpdateTaskStatus(task.ID.String(), models.TaskStatusFailed, &models.TaskResult{
-			TaskID:        task.ID,
-			Error:         err.Error(),
-			ExecutionTime: executionTime,
-		}); updateErr != nil {
-			log.Error().Err(updateErr).Str("id", task.ID.String()).Msg("Failed to update task status")
-		}
+		h.reportFailure(task, failedResult(task, err, durationMilliseconds(time.Since(executionStartedAt)), result))
 		return err
 	}
 	if result.ExecutionTime <= 0 {
 		result.ExecutionTime = durationMilliseconds(time.Since(executionStartedAt))
 	}
+	exitCode = result.ExitCode
+	h.recordEvent(task, models.ExecutionEventCompleted, map[string]string{
+		"exit_code":         strconv.Itoa(result.ExitCode),
+		"result_hash":       result.ResultHash,
+		"execution_time_ms": strconv.FormatInt(result.ExecutionTime, 10),
+	})
+
+	if err := h.hooks.Run(ctx, hooks.StagePostExecute, task, result); err != nil {
+		h.reportFailure(task, failedResult(task, err, result.ExecutionTime, result))
+		return err
+	}
 
 	deviceID, err := utils.GetDeviceID()
 	if err == nil {
@@ -116,9 +535,22 @@ func (h *DefaultTaskHandler) HandleTask(task *models.Task) error {
 		status = models.TaskStatusFailed
 	}
 
-	if err := h.taskClient.UpdateTaskStatus(task.ID.String(), status, result); err != nil {
+	if err := h.hooks.Run(ctx, hooks.StagePreSubmit, task, result); err != nil {
+		h.reportFailure(task, failedResult(task, err, result.ExecutionTime, result))
+		return err
+	}
+
+	if err := h.attest(task, result); err != nil {
+		log.Error().Err(err).Str("id", task.ID.String()).Msg("Failed to attest task result")
+		h.reportFailure(task, failedResult(task, err, result.ExecutionTime, result))
+		return err
+	}
+
+	result.Receipt = h.issueReceipt(task, result)
+
+	if err := h.submitResult(task, status, result); err != nil {
 		log.Error().Err(err).Str("id", task.ID.String()).Msg("Failed to update task status")
-		return fmt.Errorf("failed to update task status: %w", err)
+		return fmt.Errorf("%w: %w", errResultNotSubmitted, err)
 	}
 
 	// Handle federated learning task completion separately
@@ -146,6 +578,259 @@ func (h *DefaultTaskHandler) HandleTask(task *models.Task) error {
 	return nil
 }
 
+var errResultNotSubmitted = errors.New("failed to update task status")
+
+// failureClass is the coarse reason telemetry counts a task under, empty for
+// tasks that succeeded
+func failureClass(exitCode int, err error) telemetry.FailureClass {
+	switch {
+	case err == nil && exitCode == 0:
+		return ""
+	case err == nil:
+		return telemetry.FailureExitCode
+	case errors.Is(err, context.DeadlineExceeded):
+		return telemetry.FailureTimeout
+	case errors.Is(err, ErrTaskAborted), errors.Is(err, ErrTaskCancelled), errors.Is(err, ErrGangFailed):
+		return telemetry.FailureAborted
+	case errors.Is(err, errResultNotSubmitted):
+		return telemetry.FailureSubmission
+	default:
+		return telemetry.FailureExecution
+	}
+}
+
+// failedResult builds the result reported for a task that did not complete. Resource
+// usage collected before the failure is kept so it can still be accounted for.
+func failedResult(task *models.Task, err error, executionTime int64, partial *models.TaskResult) *models.TaskResult {
+	result := &models.TaskResult{
+		TaskID:        task.ID,
+		Error:         err.Error(),
+		ExecutionTime: executionTime,
+	}
+	if partial != nil {
+		result.CPUSeconds = partial.CPUSeconds
+		result.EstimatedCycles = partial.EstimatedCycles
+		result.MemoryGBHours = partial.MemoryGBHours
+		result.PeakMemoryGB = partial.PeakMemoryGB
+		result.StorageGB = partial.StorageGB
+		result.NetworkDataGB = partial.NetworkDataGB
+		result.Diagnostics = partial.Diagnostics
+		result.Attempts = partial.Attempts
+	}
+	if result.Diagnostics == nil {
+		result.Diagnostics = &models.FailureDiagnostics{}
+	}
+	// The error the runner gave up on classifies the failure better than the
+	// container's exit
+	result.Diagnostics.Class = string(failureClass(-1, err))
+	result.Diagnostics.Error = result.Error
+	return result
+}
+
+func (h *DefaultTaskHandler) reportFailure(task *models.Task, result *models.TaskResult) {
+	h.recordEvent(task, models.ExecutionEventFailed, map[string]string{"error": result.Error})
+	if err := h.submitResult(task, models.TaskStatusFailed, result); err != nil {
+		log := gologger.WithComponent("task_handler")
+		log.Error().Err(err).Str("id", task.ID.String()).Msg("Failed to update task status")
+	}
+}
+
+// reportCancelled reports a task its creator cancelled, with whatever it did
+// until then
+func (h *DefaultTaskHandler) reportCancelled(task *models.Task, result *models.TaskResult) {
+	// Nothing went wrong, so there is nothing to diagnose
+	result.Diagnostics = nil
+	h.recordEvent(task, models.ExecutionEventCancelled, map[string]string{"reason": result.Error})
+	if err := h.submitResult(task, models.TaskStatusCancelled, result); err != nil {
+		log := gologger.WithComponent("task_handler")
+		log.Error().Err(err).Str("id", task.ID.String()).Msg("Failed to update task status")
+	}
+}
+
+// submitResult reports the final status and result of a task to the server.
+// The task leaves the journal once the server took the result or the outbox
+// holds it.
+func (h *DefaultTaskHandler) submitResult(task *models.Task, status models.TaskStatus, result *models.TaskResult) error {
+	result.Status = status
+	if status == models.TaskStatusFailed {
+		h.diagnose(task, result)
+	}
+	result.ExecutionLog = h.executionLog(task)
+	h.keepArtifacts(task, status, result)
+
+	submitted := h.withUploadedOutput(task, result)
+	err := h.chaos.FailResultSubmission()
+	if err == nil {
+		err = h.taskClient.UpdateTaskStatus(task.ID.String(), status, submitted)
+	}
+	if err != nil {
+		if h.queueResult(task, status, submitted, err) {
+			h.ForgetTask(task.ID.String())
+		}
+		h.recordEvent(task, models.ExecutionEventResultQueued, map[string]string{"status": string(status), "error": err.Error()})
+	} else {
+		h.ForgetTask(task.ID.String())
+		h.recordEvent(task, models.ExecutionEventResultSubmitted, map[string]string{"status": string(status)})
+	}
+	h.recordLedger(task, status, result, err == nil)
+	return err
+}
+
+// recordEvent adds an event to the execution log of a task
+func (h *DefaultTaskHandler) recordEvent(task *models.Task, eventType models.ExecutionEventType, details map[string]string) {
+	if h.events == nil {
+		return
+	}
+	if _, err := h.events.Record(task.ID.String(), eventType, details); err != nil {
+		log := gologger.WithComponent("task_handler")
+		log.Warn().Err(err).Str("id", task.ID.String()).Str("event", string(eventType)).Msg("Failed to record task event")
+	}
+}
+
+// executionLog is the execution log of a task so far, attached to its result
+func (h *DefaultTaskHandler) executionLog(task *models.Task) models.ExecutionLog {
+	if h.events == nil {
+		return nil
+	}
+	taskEvents, err := h.events.Load(task.ID.String())
+	if err != nil && !errors.Is(err, events.ErrNoEvents) {
+		log := gologger.WithComponent("task_handler")
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to load task events")
+	}
+	return taskEvents
+}
+
+// diagnose completes the diagnostic bundle of a failed task's result, starting
+// one for tasks whose executor did not
+func (h *DefaultTaskHandler) diagnose(task *models.Task, result *models.TaskResult) {
+	if h.diagnostics.Disabled {
+		result.Diagnostics = nil
+		return
+	}
+	if result.Diagnostics == nil {
+		result.Diagnostics = &models.FailureDiagnostics{}
+	}
+	bundle := result.Diagnostics
+	if bundle.Class == "" {
+		bundle.Class = models.DiagnosticExitCode
+	}
+	if bundle.Error == "" {
+		bundle.Error = result.Error
+	}
+	diagnostics.Finish(bundle, h.diagnostics, diagnostics.Secrets(taskEnv(task)))
+}
+
+// taskEnv is the NAME=value variables a task sets for its container
+func taskEnv(task *models.Task) []string {
+	if task.Environment == nil {
+		return nil
+	}
+	values, _ := task.Environment.Config["env"].([]interface{})
+	env := make([]string, 0, len(values))
+	for _, value := range values {
+		if variable, ok := value.(string); ok {
+			env = append(env, variable)
+		}
+	}
+	return env
+}
+
+// recordLedger adds a finished task to the ledger
+func (h *DefaultTaskHandler) recordLedger(task *models.Task, status models.TaskStatus, result *models.TaskResult, submitted bool) {
+	if h.ledger == nil {
+		return
+	}
+	entry := ledger.NewEntry(task, status, result, time.Now())
+	entry.Submitted = submitted
+	if err := h.ledger.Record(entry); err != nil {
+		log := gologger.WithComponent("task_handler")
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to record task in the earnings ledger")
+	}
+}
+
+// queueResult keeps a result the server did not accept in the outbox, or in
+// its dead letters when the server refused it, and reports whether it did
+func (h *DefaultTaskHandler) queueResult(task *models.Task, status models.TaskStatus, result *models.TaskResult, submitErr error) bool {
+	if h.outbox == nil {
+		return false
+	}
+	log := gologger.WithComponent("task_handler")
+
+	entry := outbox.Entry{TaskID: task.ID.String(), Status: status, Result: result, Attempts: 1, LastError: submitErr.Error()}
+	if errors.Is(submitErr, outbox.ErrRejected) {
+		if err := h.outbox.Bury(entry, outbox.ReasonRejected, time.Now()); err != nil {
+			log.Error().Err(err).Str("id", task.ID.String()).Msg("Failed to keep rejected task result")
+			return false
+		}
+		log.Warn().Str("id", task.ID.String()).Msg("Server rejected the task result, kept as a dead letter")
+		return true
+	}
+	if err := h.outbox.Put(entry); err != nil {
+		log.Error().Err(err).Str("id", task.ID.String()).Msg("Failed to queue task result")
+		return false
+	}
+	log.Warn().Str("id", task.ID.String()).Msg("Task result queued for delivery once the server accepts it")
+	return true
+}
+
+func (h *DefaultTaskHandler) keepArtifacts(task *models.Task, status models.TaskStatus, result *models.TaskResult) {
+	if h.artifacts == nil {
+		return
+	}
+	log := gologger.WithComponent("task_handler")
+
+	if path, err := h.artifacts.Save(task, status, result); err != nil {
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to store task artifacts")
+	} else {
+		log.Debug().Str("id", task.ID.String()).Str("path", path).Msg("Task artifacts stored")
+	}
+
+	if removed, err := h.artifacts.Prune(time.Now()); err != nil {
+		log.Warn().Err(err).Msg("Failed to prune task artifacts")
+	} else if removed > 0 {
+		log.Debug().Int("removed", removed).Msg("Pruned expired task artifacts")
+	}
+}
+
+// issueReceipt signs an execution receipt with the runner's signer and keeps a local copy
+func (h *DefaultTaskHandler) issueReceipt(task *models.Task, result *models.TaskResult) *models.ExecutionReceipt {
+	log := gologger.WithComponent("task_handler")
+
+	if h.receiptSigner == nil {
+		return nil
+	}
+
+	receiptSigner, err := h.receiptSigner()
+	if err == nil && receiptSigner == nil {
+		err = fmt.Errorf("empty signer")
+	}
+	if err != nil {
+		log.Debug().Err(err).Str("id", task.ID.String()).Msg("Skipping execution receipt, no signing key available")
+		return nil
+	}
+
+	executionReceipt := receipt.Build(task, result, "")
+	if err := receipt.SignWith(executionReceipt, receiptSigner); err != nil {
+		if errors.Is(err, signer.ErrDigestNotSupported) {
+			log.Debug().Str("id", task.ID.String()).Msg("Skipping execution receipt, the signer cannot sign messages")
+			return nil
+		}
+		log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to sign execution receipt")
+		return nil
+	}
+
+	if h.receiptDir != "" {
+		path, err := receipt.Save(h.receiptDir, executionReceipt)
+		if err != nil {
+			log.Warn().Err(err).Str("id", task.ID.String()).Msg("Failed to store execution receipt")
+		} else {
+			log.Debug().Str("id", task.ID.String()).Str("path", path).Msg("Execution receipt stored")
+		}
+	}
+
+	return executionReceipt
+}
+
 func durationMilliseconds(duration time.Duration) int64 {
 	if duration <= 0 {
 		return 0
@@ -164,6 +849,7 @@ func (h *DefaultTaskHandler) handleLLMTask(task *models.Task) error {
 	llmClient, ok := h.taskClient.(LLMTaskClient)
 	if !ok {
 		log.Error().Str("id", task.ID.String()).Msg("Task client does not support LLM completion")
+		h.ForgetTask(task.ID.String())
 		return fmt.Errorf("task client does not support LLM completion")
 	}
 
@@ -202,22 +888,47 @@ func (h *DefaultTaskHandler) handleLLMTask(task *models.Task) error {
 			Msg("Failed to update LLM task status to running after retries")
 		// Continue execution despite status update failure
 	}
+	h.journalStart(task)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), taskDeadline(task, 10*time.Minute))
 	defer cancel()
+	ctx, abort := context.WithCancelCause(ctx)
+	h.setAbort(task.ID.String(), abort)
+	defer func() {
+		h.setAbort(task.ID.String(), nil)
+		abort(nil)
+	}()
+
+	// A prompt cancelled while it waited is reported without running it
+	if cause := h.cancellation(task.ID.String()); cause != nil {
+		log.Info().Err(cause).Str("id", task.ID.String()).Msg("LLM task cancelled before it started")
+		h.reportLLMCancelled(task, llmClient, failedResult(task, cause, 0, nil))
+		return cause
+	}
+
+	if err := h.hooks.Run(ctx, hooks.StagePreExecute, task, nil); err != nil {
+		return h.failPrompt(task, llmClient, err.Error(), nil)
+	}
 
 	log.Info().
 		Str("id", task.ID.String()).
 		Str("type", string(task.Type)).
 		Msg("Executing LLM task")
 
+	executionStartedAt := time.Now()
 	result, err := h.executor.ExecuteTask(ctx, task)
+	if cause := context.Cause(ctx); errors.Is(cause, ErrTaskCancelled) {
+		log.Info().Err(cause).Str("id", task.ID.String()).Msg("LLM task cancelled")
+		h.reportLLMCancelled(task, llmClient, failedResult(task, cause, durationMilliseconds(time.Since(executionStartedAt)), result))
+		return cause
+	}
 	if err != nil {
 		log.Error().Err(err).Str("id", task.ID.String()).Msg("LLM task execution failed")
-		if failErr := llmClient.FailPrompt(task.ID, err.Error()); failErr != nil {
-			return fmt.Errorf("failed to mark LLM prompt as failed: %w", failErr)
-		}
-		return nil
+		return h.failPrompt(task, llmClient, err.Error(), nil)
+	}
+
+	if err := h.hooks.Run(ctx, hooks.StagePostExecute, task, result); err != nil {
+		return h.failPrompt(task, llmClient, err.Error(), result)
 	}
 
 	if result.ExitCode != 0 {
@@ -230,10 +941,11 @@ func (h *DefaultTaskHandler) handleLLMTask(task *models.Task) error {
 			Str("id", task.ID.String()).
 			Str("error", failureReason).
 			Msg("LLM task failed")
-		if failErr := llmClient.FailPrompt(task.ID, failureReason); failErr != nil {
-			return fmt.Errorf("failed to mark LLM prompt as failed: %w", failErr)
-		}
-		return nil
+		return h.failPrompt(task, llmClient, failureReason, result)
+	}
+
+	if err := h.hooks.Run(ctx, hooks.StagePreSubmit, task, result); err != nil {
+		return h.failPrompt(task, llmClient, err.Error(), result)
 	}
 
 	err = llmClient.CompletePrompt(
@@ -243,10 +955,12 @@ func (h *DefaultTaskHandler) handleLLMTask(task *models.Task) error {
 		result.ResponseTokens,
 		result.InferenceTime,
 	)
+	h.recordLedger(task, models.TaskStatusCompleted, result, err == nil)
 	if err != nil {
 		log.Error().Err(err).Str("id", task.ID.String()).Msg("Failed to complete LLM prompt")
 		return fmt.Errorf("failed to complete LLM prompt: %w", err)
 	}
+	h.ForgetTask(task.ID.String())
 
 	log.Debug().
 		Str("id", task.ID.String()).
@@ -258,6 +972,27 @@ func (h *DefaultTaskHandler) handleLLMTask(task *models.Task) error {
 	return nil
 }
 
+// failPrompt closes the prompt of an LLM task that failed or a hook rejected
+func (h *DefaultTaskHandler) failPrompt(task *models.Task, llmClient LLMTaskClient, reason string, result *models.TaskResult) error {
+	failErr := llmClient.FailPrompt(task.ID, reason)
+	h.recordLedger(task, models.TaskStatusFailed, result, failErr == nil)
+	if failErr != nil {
+		return fmt.Errorf("failed to mark LLM prompt as failed: %w", failErr)
+	}
+	h.ForgetTask(task.ID.String())
+	return nil
+}
+
+// reportLLMCancelled reports an LLM task its creator cancelled and closes its
+// prompt, so neither is left waiting for a completion that will not come
+func (h *DefaultTaskHandler) reportLLMCancelled(task *models.Task, llmClient LLMTaskClient, result *models.TaskResult) {
+	h.reportCancelled(task, result)
+	if err := llmClient.FailPrompt(task.ID, result.Error); err != nil {
+		log := gologger.WithComponent("task_handler")
+		log.Error().Err(err).Str("id", task.ID.String()).Msg("Failed to mark cancelled LLM prompt as failed")
+	}
+}
+
 func (h *DefaultTaskHandler) handleFederatedLearningCompletion(task *models.Task, result *models.TaskResult) error {
 	log := gologger.WithComponent("task_handler")
 
@@ -266,77 +1001,23 @@ func (h *DefaultTaskHandler) handleFederatedLearningCompletion(task *models.Task
 		Msg("Processing federated learning task completion")
 
 	// Parse the task result to extract FL training results
-	var trainingResult map[string]interface{}
-	if err := json.Unmarshal([]byte(result.Output), &trainingResult); err != nil {
+	output, err := models.ParseTrainingOutput([]byte(result.Output))
+	if err != nil {
 		return fmt.Errorf("failed to parse FL training result: %w", err)
 	}
-
-	// Extract required fields
-	sessionID, ok := trainingResult["session_id"].(string)
-	if !ok {
-		return fmt.Errorf("missing session_id in training result")
-	}
-
-	roundID, ok := trainingResult["round_id"].(string)
-	if !ok {
-		return fmt.Errorf("missing round_id in training result")
-	}
-
-	gradients, ok := trainingResult["gradients"].(map[string]interface{})
-	if !ok {
+	if output.Gradients == nil {
 		return fmt.Errorf("missing gradients in training result")
 	}
-
-	// Convert gradients to the expected format
-	gradientsFloat := make(map[string][]float64)
-	for key, value := range gradients {
-		if valueSlice, ok := value.([]interface{}); ok {
-			floatSlice := make([]float64, len(valueSlice))
-			for i, v := range valueSlice {
-				if floatVal, ok := v.(float64); ok {
-					floatSlice[i] = floatVal
-				}
-			}
-			gradientsFloat[key] = floatSlice
-		}
-	}
-
-	// Extract weights (may be empty if not provided)
-	weightsFloat := make(map[string][]float64)
-	if weights, ok := trainingResult["weights"].(map[string]interface{}); ok {
-		for key, value := range weights {
-			if valueSlice, ok := value.([]interface{}); ok {
-				floatSlice := make([]float64, len(valueSlice))
-				for i, v := range valueSlice {
-					if floatVal, ok := v.(float64); ok {
-						floatSlice[i] = floatVal
-					}
-				}
-				weightsFloat[key] = floatSlice
-			}
-		}
-	}
-
-	// Extract metrics with defaults
-	dataSize := 1000 // Default value
-	if ds, ok := trainingResult["data_size"].(float64); ok {
-		dataSize = int(ds)
-	}
-
-	loss := 0.0
-	if l, ok := trainingResult["loss"].(float64); ok {
-		loss = l
-	}
-
-	accuracy := 0.0
-	if a, ok := trainingResult["accuracy"].(float64); ok {
-		accuracy = a
+	sessionID, roundID := output.SessionID, output.RoundID
+	gradientsFloat, weightsFloat := output.Gradients, output.Weights
+	if weightsFloat == nil {
+		weightsFloat = make(map[string][]float64)
 	}
-
-	trainingTime := 0
-	if tt, ok := trainingResult["training_time"].(float64); ok {
-		trainingTime = int(tt)
+	dataSize := output.DataSize
+	if dataSize == 0 {
+		dataSize = 1000 // Default value
 	}
+	loss, accuracy, trainingTime := output.Loss, output.Accuracy, int(output.TrainingTime)
 
 	// Get the runner's device ID
 	runnerID, err := utils.GetDeviceID()
@@ -346,8 +1027,8 @@ func (h *DefaultTaskHandler) handleFederatedLearningCompletion(task *models.Task
 	}
 
 	// Submit model update to the federated learning service
-	if httpClient, ok := h.taskClient.(*HTTPTaskClient); ok {
-		if err := httpClient.SubmitFLModelUpdate(sessionID, roundID, runnerID, gradientsFloat, weightsFloat, dataSize, loss, accuracy, trainingTime); err != nil {
+	if flClient, ok := h.taskClient.(FLTaskClient); ok {
+		if err := flClient.SubmitFLModelUpdate(sessionID, roundID, runnerID, gradientsFloat, weightsFloat, dataSize, loss, accuracy, trainingTime); err != nil {
 			return fmt.Errorf("failed to submit FL model update: %w", err)
 		}
 
@@ -3,6 +3,8 @@ package runner
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -11,6 +13,13 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/diagnostics"
+	"github.com/theblitlabs/parity-runner/internal/events"
+	"github.com/theblitlabs/parity-runner/internal/hooks"
+	"github.com/theblitlabs/parity-runner/internal/ledger"
+	"github.com/theblitlabs/parity-runner/internal/logstream"
+	"github.com/theblitlabs/parity-runner/internal/outbox"
+	"github.com/theblitlabs/parity-runner/internal/telemetry"
 )
 
 type stubTaskExecutor struct {
@@ -222,3 +231,685 @@ func (c *recordingTaskClientWithFailure) UpdateTaskStatus(taskID string, status
 	}
 	return nil
 }
+
+type rejectingHook struct{}
+
+func (rejectingHook) Name() string { return "policy" }
+
+func (rejectingHook) Run(ctx context.Context, hookCtx *hooks.Context) error {
+	return errors.New("not approved")
+}
+
+func TestHandleTaskPreClaimHookPreventsClaim(t *testing.T) {
+	executor := &countingTaskExecutor{}
+	client := &recordingTaskClient{}
+	handler := NewTaskHandler(executor, client)
+
+	registry := hooks.NewRegistry()
+	registry.Register(hooks.StagePreClaim, rejectingHook{})
+	handler.SetHooks(registry)
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeDocker
+	if err := handler.HandleTask(task); err == nil {
+		t.Fatal("expected pre-claim hook to reject the task")
+	}
+
+	if len(client.updates) != 0 {
+		t.Fatalf("expected task not to be claimed, got %d status updates", len(client.updates))
+	}
+	if executor.calls.Load() != 0 {
+		t.Fatal("expected task not to be executed")
+	}
+	if handler.IsProcessing() {
+		t.Fatal("expected handler to be idle after rejection")
+	}
+}
+
+func TestLLMTaskRunsExecutionHooks(t *testing.T) {
+	for _, stage := range []hooks.Stage{hooks.StagePreExecute, hooks.StagePostExecute, hooks.StagePreSubmit} {
+		executor := &countingTaskExecutor{}
+		taskClient := &recordingLLMTaskClient{}
+		handler := NewTaskHandler(executor, taskClient)
+
+		registry := hooks.NewRegistry()
+		registry.Register(stage, rejectingHook{})
+		handler.SetHooks(registry)
+
+		task := &models.Task{ID: uuid.New(), Type: models.TaskTypeLLM}
+		if err := handler.HandleTask(task); err != nil {
+			t.Fatalf("%s: HandleTask() error = %v", stage, err)
+		}
+
+		if len(taskClient.completed) != 0 {
+			t.Fatalf("%s: prompt completed despite the hook rejecting it", stage)
+		}
+		if len(taskClient.failed) != 1 || !strings.Contains(taskClient.failed[0], "not approved") {
+			t.Fatalf("%s: failed prompt reasons = %q, want the hook's rejection", stage, taskClient.failed)
+		}
+		wantCalls := int32(1)
+		if stage == hooks.StagePreExecute {
+			wantCalls = 0
+		}
+		if calls := executor.calls.Load(); calls != wantCalls {
+			t.Fatalf("%s: executor ran %d times, want %d", stage, calls, wantCalls)
+		}
+	}
+}
+
+func TestAbortCurrentTaskReportsFailure(t *testing.T) {
+	task := &models.Task{
+		ID:    uuid.New(),
+		Type:  models.TaskTypeCommand,
+		Nonce: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	taskClient := &recordingTaskClient{}
+	handler := NewTaskHandler(&stubTaskExecutor{delay: 5 * time.Second}, taskClient)
+
+	if handler.AbortCurrentTask("idle") {
+		t.Fatal("AbortCurrentTask() = true with no task running")
+	}
+
+	go func() {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) && !handler.AbortCurrentTask("user returned") {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	err := handler.HandleTask(task)
+	if !errors.Is(err, ErrTaskAborted) {
+		t.Fatalf("HandleTask() error = %v, want ErrTaskAborted", err)
+	}
+
+	lastUpdate := taskClient.updates[len(taskClient.updates)-1]
+	if lastUpdate.status != models.TaskStatusFailed {
+		t.Fatalf("final status = %s, want %s", lastUpdate.status, models.TaskStatusFailed)
+	}
+	if lastUpdate.result == nil || !strings.Contains(lastUpdate.result.Error, "user returned") {
+		t.Fatalf("final result = %+v, want the abort reason", lastUpdate.result)
+	}
+}
+
+func TestCancelTaskReportsItCancelled(t *testing.T) {
+	task := &models.Task{
+		ID:    uuid.New(),
+		Type:  models.TaskTypeCommand,
+		Nonce: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	taskClient := &recordingTaskClient{}
+	handler := NewTaskHandler(&stubTaskExecutor{delay: 5 * time.Second}, taskClient)
+	store := events.NewStore(t.TempDir())
+	handler.SetEvents(store)
+
+	if handler.CancelTask(task.ID.String(), "not needed") {
+		t.Fatal("CancelTask() = true with the task not running")
+	}
+
+	go func() {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) && !handler.CancelTask(task.ID.String(), "wrong dataset") {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	if err := handler.HandleTask(task); !errors.Is(err, ErrTaskCancelled) {
+		t.Fatalf("HandleTask() error = %v, want ErrTaskCancelled", err)
+	}
+
+	lastUpdate := taskClient.updates[len(taskClient.updates)-1]
+	if lastUpdate.status != models.TaskStatusCancelled || lastUpdate.result.Status != models.TaskStatusCancelled {
+		t.Fatalf("final status = %s, result status = %s, want cancelled", lastUpdate.status, lastUpdate.result.Status)
+	}
+	if !strings.Contains(lastUpdate.result.Error, "wrong dataset") || lastUpdate.result.Diagnostics != nil {
+		t.Fatalf("final result = %+v, want the reason and no diagnostics", lastUpdate.result)
+	}
+
+	history, err := store.Load(task.ID.String())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(history) < 2 || history[len(history)-2].Type != models.ExecutionEventCancelled {
+		t.Fatalf("events = %+v, want cancelled before the submission", history)
+	}
+}
+
+func TestTaskDeadlineFollowsServerMaxDuration(t *testing.T) {
+	task := &models.Task{ID: uuid.New(), Type: models.TaskTypeDocker}
+	if got := taskDeadline(task, 20*time.Minute); got != 20*time.Minute {
+		t.Fatalf("taskDeadline() without a server limit = %s, want 20m", got)
+	}
+
+	task.MaxDurationSecs = 3600
+	if got := taskDeadline(task, 20*time.Minute); got != time.Hour+taskSetupAllowance {
+		t.Fatalf("taskDeadline() = %s, want %s", got, time.Hour+taskSetupAllowance)
+	}
+}
+
+func TestHandleTaskRejectsTaskOutsidePolicy(t *testing.T) {
+	executor := &countingTaskExecutor{}
+	client := &recordingTaskClient{}
+	handler := NewTaskHandler(executor, client)
+
+	policy, err := models.ParseRunnerPolicy("docker", "", "")
+	if err != nil {
+		t.Fatalf("ParseRunnerPolicy() error = %v", err)
+	}
+	handler.SetPolicy(policy)
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	if err := handler.HandleTask(task); !errors.Is(err, models.ErrTaskNotAllowed) {
+		t.Fatalf("HandleTask() error = %v, want ErrTaskNotAllowed", err)
+	}
+	if len(client.updates) != 0 {
+		t.Fatalf("expected task not to be claimed, got %d status updates", len(client.updates))
+	}
+	if executor.calls.Load() != 0 {
+		t.Fatal("expected task not to be executed")
+	}
+}
+
+type memoryContent struct {
+	blobs map[string][]byte
+	err   error
+}
+
+func (m *memoryContent) Fetch(ctx context.Context, cid string, maxBytes int64, expectedSHA256 string) ([]byte, error) {
+	return m.blobs[cid], nil
+}
+
+func (m *memoryContent) Add(ctx context.Context, data []byte) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	cid := "bafy" + strings.Repeat("a", len(m.blobs)+1)
+	m.blobs[cid] = data
+	return cid, nil
+}
+
+func TestHandleTaskUploadsLargeOutput(t *testing.T) {
+	task := &models.Task{
+		ID:    uuid.New(),
+		Type:  models.TaskTypeCommand,
+		Nonce: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	output := strings.Repeat("x", 64)
+	result := &models.TaskResult{Output: output}
+	client := &recordingTaskClient{}
+	handler := NewTaskHandler(&stubTaskExecutor{result: result}, client)
+	content := &memoryContent{blobs: map[string][]byte{}}
+	handler.SetResultUpload(content, 32)
+
+	if err := handler.HandleTask(task); err != nil {
+		t.Fatalf("HandleTask() error = %v", err)
+	}
+
+	submitted := client.updates[len(client.updates)-1].result
+	upload, ok := submitted.Uploads.Output()
+	if submitted.Output != "" || !ok {
+		t.Fatalf("submitted result = %+v, want the output replaced by an upload", submitted)
+	}
+	if string(content.blobs[upload.CID]) != output || upload.Size != 64 {
+		t.Fatalf("upload = %+v does not hold the output", upload)
+	}
+	if result.Output != output {
+		t.Fatal("local result lost its output")
+	}
+
+	content.err = errors.New("node down")
+	if err := handler.HandleTask(task); err != nil {
+		t.Fatalf("HandleTask() error = %v", err)
+	}
+	if submitted := client.updates[len(client.updates)-1].result; submitted.Output != output {
+		t.Fatal("output should be sent inline when the upload fails")
+	}
+}
+
+// submitFailingTaskClient lets tasks be claimed but not their results submitted
+type submitFailingTaskClient struct {
+	recordingTaskClient
+}
+
+func (c *submitFailingTaskClient) UpdateTaskStatus(taskID string, status models.TaskStatus, result *models.TaskResult) error {
+	if status != models.TaskStatusRunning {
+		return errors.New("server unreachable")
+	}
+	return c.recordingTaskClient.UpdateTaskStatus(taskID, status, result)
+}
+
+func TestHandleTaskQueuesResultTheServerDidNotAccept(t *testing.T) {
+	task := &models.Task{
+		ID:    uuid.New(),
+		Type:  models.TaskTypeCommand,
+		Nonce: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	handler := NewTaskHandler(&stubTaskExecutor{result: &models.TaskResult{Output: "done"}}, &submitFailingTaskClient{})
+	store := outbox.NewStore(t.TempDir())
+	handler.SetOutbox(store)
+
+	if err := handler.HandleTask(task); err == nil {
+		t.Fatal("HandleTask() should report the failed submission")
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].TaskID != task.ID.String() || entries[0].Status != models.TaskStatusCompleted || entries[0].Result.Output != "done" {
+		t.Fatalf("queued results = %+v, want the completed result", entries)
+	}
+}
+
+// rejectingTaskClient lets tasks be claimed but refuses their results for good
+type rejectingTaskClient struct {
+	recordingTaskClient
+}
+
+func (c *rejectingTaskClient) UpdateTaskStatus(taskID string, status models.TaskStatus, result *models.TaskResult) error {
+	if status != models.TaskStatusRunning {
+		return fmt.Errorf("%w: server error: Task assignment was revoked", outbox.ErrRejected)
+	}
+	return c.recordingTaskClient.UpdateTaskStatus(taskID, status, result)
+}
+
+func TestHandleTaskKeepsRejectedResultsAsDeadLetters(t *testing.T) {
+	task := &models.Task{
+		ID:    uuid.New(),
+		Type:  models.TaskTypeCommand,
+		Nonce: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	handler := NewTaskHandler(&stubTaskExecutor{result: &models.TaskResult{Output: "done"}}, &rejectingTaskClient{})
+	store := outbox.NewStore(t.TempDir())
+	handler.SetOutbox(store)
+
+	if err := handler.HandleTask(task); err == nil {
+		t.Fatal("HandleTask() should report the rejected submission")
+	}
+
+	if entries, _ := store.List(); len(entries) != 0 {
+		t.Fatalf("queued results = %+v, want a rejected result not to be offered again", entries)
+	}
+	dead, err := store.Dead()
+	if err != nil {
+		t.Fatalf("Dead() error = %v", err)
+	}
+	if len(dead) != 1 || dead[0].Reason != outbox.ReasonRejected || !strings.Contains(dead[0].LastError, "revoked") || dead[0].Result.Output != "done" {
+		t.Fatalf("dead letters = %+v, want the rejected result", dead)
+	}
+}
+
+func TestHandleTaskRecordsTheTaskInTheLedger(t *testing.T) {
+	task := &models.Task{
+		ID:     uuid.New(),
+		Type:   models.TaskTypeCommand,
+		Reward: 2.5,
+		Nonce:  "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	handler := NewTaskHandler(&stubTaskExecutor{result: &models.TaskResult{Output: "done", CPUSeconds: 12}}, &submitFailingTaskClient{})
+	store := ledger.NewStore(t.TempDir())
+	handler.SetLedger(store)
+
+	_ = handler.HandleTask(task)
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].TaskID != task.ID.String() || entries[0].Reward != 2.5 || entries[0].CPUSeconds != 12 || entries[0].Submitted {
+		t.Fatalf("ledger = %+v, want the unsubmitted task with its reward and CPU time", entries)
+	}
+}
+
+func TestHandleTaskLogsExecutionEventsAndAttachesThemToTheResult(t *testing.T) {
+	task := &models.Task{
+		ID:    uuid.New(),
+		Type:  models.TaskTypeCommand,
+		Nonce: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	taskClient := &recordingTaskClient{}
+	handler := NewTaskHandler(&stubTaskExecutor{result: &models.TaskResult{Output: "done", ResultHash: "abc"}}, taskClient)
+	store := events.NewStore(t.TempDir())
+	handler.SetEvents(store)
+
+	if err := handler.HandleTask(task); err != nil {
+		t.Fatalf("HandleTask() error = %v", err)
+	}
+
+	history, err := store.Load(task.ID.String())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var types []models.ExecutionEventType
+	for _, event := range history {
+		types = append(types, event.Type)
+	}
+	want := []models.ExecutionEventType{models.ExecutionEventReceived, models.ExecutionEventCompleted, models.ExecutionEventResultSubmitted}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	if history[1].Details["result_hash"] != "abc" {
+		t.Fatalf("completed details = %v, want the result hash", history[1].Details)
+	}
+
+	attached := taskClient.updates[len(taskClient.updates)-1].result.ExecutionLog
+	if len(attached) != 2 || !reflect.DeepEqual(attached, history[:2]) {
+		t.Fatalf("attached log = %+v, want the events before submission", attached)
+	}
+	if err := events.Verify(history); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
+
+func TestHandleTaskCountsFailureClassesForTelemetry(t *testing.T) {
+	collector, err := telemetry.NewCollector(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewCollector() error = %v", err)
+	}
+	nonce := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	for _, handler := range []*DefaultTaskHandler{
+		NewTaskHandler(&stubTaskExecutor{result: &models.TaskResult{ExitCode: 2}}, &recordingTaskClient{}),
+		NewTaskHandler(&stubTaskExecutor{result: &models.TaskResult{Output: "done"}}, &submitFailingTaskClient{}),
+		NewTaskHandler(&stubTaskExecutor{result: &models.TaskResult{Output: "done"}}, &recordingTaskClient{}),
+	} {
+		handler.SetTelemetry(collector)
+		_ = handler.HandleTask(&models.Task{ID: uuid.New(), Type: models.TaskTypeCommand, Nonce: nonce})
+	}
+
+	report := collector.Report(telemetry.HardwareClass{}, time.Now())
+	if report.Tasks["command"] != 3 || report.Failures[telemetry.FailureExitCode] != 1 || report.Failures[telemetry.FailureSubmission] != 1 || len(report.Failures) != 2 {
+		t.Fatalf("telemetry report = %+v, want one exit code and one submission failure in three tasks", report)
+	}
+}
+
+func TestHandleTaskAttachesDiagnosticsToFailedResults(t *testing.T) {
+	nonce := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	task := &models.Task{
+		ID:          uuid.New(),
+		Type:        models.TaskTypeDocker,
+		Nonce:       nonce,
+		Config:      []byte(`{}`),
+		Environment: &models.EnvironmentConfig{Type: "docker", Config: map[string]interface{}{"env": []interface{}{"HF_TOKEN=hf_abcdef123"}}},
+	}
+	taskClient := &recordingTaskClient{}
+	handler := NewTaskHandler(&stubTaskExecutor{result: &models.TaskResult{
+		ExitCode: 137,
+		Diagnostics: &models.FailureDiagnostics{
+			Class:     models.DiagnosticExitCode,
+			LogTail:   []string{"downloading with hf_abcdef123", "Killed"},
+			Container: &models.ContainerDiagnostics{Status: "exited", ExitCode: 137},
+		},
+	}}, taskClient)
+	handler.SetDiagnostics(diagnostics.Config{Runtime: "docker"})
+
+	if err := handler.HandleTask(task); err != nil {
+		t.Fatalf("HandleTask() error = %v", err)
+	}
+	bundle := taskClient.updates[len(taskClient.updates)-1].result.Diagnostics
+	if bundle == nil || bundle.Class != models.DiagnosticKilled || bundle.Redacted != 1 || bundle.Environment.Runtime != "docker" {
+		t.Fatalf("diagnostics = %+v, want a killed container with the token redacted", bundle)
+	}
+
+	// Tasks the executor gave up on get a bundle of their own
+	taskClient = &recordingTaskClient{}
+	handler = NewTaskHandler(&stubTaskExecutor{err: errors.New("image preparation failed: pull access denied")}, taskClient)
+	_ = handler.HandleTask(&models.Task{ID: uuid.New(), Type: models.TaskTypeCommand, Nonce: nonce})
+	bundle = taskClient.updates[len(taskClient.updates)-1].result.Diagnostics
+	if bundle == nil || bundle.Class != models.DiagnosticExecution || !strings.Contains(bundle.Error, "pull access denied") || bundle.Environment.Version == "" {
+		t.Fatalf("diagnostics = %+v, want the execution error and the runner fingerprint", bundle)
+	}
+
+	taskClient = &recordingTaskClient{}
+	handler = NewTaskHandler(&stubTaskExecutor{result: &models.TaskResult{ExitCode: 1}}, taskClient)
+	handler.SetDiagnostics(diagnostics.Config{Disabled: true})
+	_ = handler.HandleTask(&models.Task{ID: uuid.New(), Type: models.TaskTypeCommand, Nonce: nonce})
+	if bundle := taskClient.updates[len(taskClient.updates)-1].result.Diagnostics; bundle != nil {
+		t.Fatalf("diagnostics = %+v with diagnostics disabled", bundle)
+	}
+}
+
+// loggingTaskExecutor writes to the task's log stream like a container would
+type loggingTaskExecutor struct{}
+
+func (loggingTaskExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*models.TaskResult, error) {
+	if stream := logstream.FromContext(ctx); stream != nil {
+		fmt.Fprint(stream.Writer(models.LogStreamStdout), "epoch 1\n")
+	}
+	return &models.TaskResult{Output: "done"}, nil
+}
+
+type logStreamingTaskClient struct {
+	recordingTaskClient
+	chunks []models.LogChunk
+}
+
+func (c *logStreamingTaskClient) StreamTaskLogs(taskID string, chunks []models.LogChunk) error {
+	c.chunks = append(c.chunks, chunks...)
+	return nil
+}
+
+func TestHandleTaskStreamsDockerOutputBeforeSubmittingTheResult(t *testing.T) {
+	nonce := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	taskClient := &logStreamingTaskClient{}
+	handler := NewTaskHandler(loggingTaskExecutor{}, taskClient)
+	handler.SetLogStream(time.Hour)
+
+	task := &models.Task{ID: uuid.New(), Type: models.TaskTypeDocker, Nonce: nonce, Config: []byte(`{}`)}
+	if err := handler.HandleTask(task); err != nil {
+		t.Fatalf("HandleTask() error = %v", err)
+	}
+	if len(taskClient.chunks) != 1 || taskClient.chunks[0].Data != "epoch 1\n" {
+		t.Fatalf("streamed chunks = %+v, want the container output", taskClient.chunks)
+	}
+
+	// Other task types run without a stream
+	taskClient.chunks = nil
+	if err := handler.HandleTask(&models.Task{ID: uuid.New(), Type: models.TaskTypeCommand, Nonce: nonce}); err != nil {
+		t.Fatalf("HandleTask() error = %v", err)
+	}
+	if len(taskClient.chunks) != 0 {
+		t.Fatalf("streamed chunks = %+v, want none for a command task", taskClient.chunks)
+	}
+}
+
+// scriptedTaskExecutor returns its outcomes in turn, one per attempt
+type scriptedTaskExecutor struct {
+	results []*models.TaskResult
+	errs    []error
+	calls   int
+}
+
+func (e *scriptedTaskExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*models.TaskResult, error) {
+	i := e.calls
+	e.calls++
+	return e.results[i], e.errs[i]
+}
+
+func TestCancelTaskBeforeItStartsSkipsExecution(t *testing.T) {
+	task := &models.Task{
+		ID:    uuid.New(),
+		Type:  models.TaskTypeCommand,
+		Nonce: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	executor := &countingTaskExecutor{}
+	taskClient := &recordingTaskClient{}
+	handler := NewTaskHandler(executor, taskClient)
+
+	// Accepted and waiting for a worker when its creator cancels it
+	handler.RecordAccepted(task)
+	if !handler.CancelTask(task.ID.String(), "wrong dataset") {
+		t.Fatal("CancelTask() = false for an accepted task")
+	}
+
+	if err := handler.HandleTask(task); !errors.Is(err, ErrTaskCancelled) {
+		t.Fatalf("HandleTask() error = %v, want ErrTaskCancelled", err)
+	}
+	if calls := executor.calls.Load(); calls != 0 {
+		t.Fatalf("executor ran %d times, want a cancelled task not to run", calls)
+	}
+	last := taskClient.updates[len(taskClient.updates)-1]
+	if last.status != models.TaskStatusCancelled || !strings.Contains(last.result.Error, "wrong dataset") {
+		t.Fatalf("final status = %s, error = %q, want cancelled with the reason", last.status, last.result.Error)
+	}
+	if handler.CancelTask(task.ID.String(), "again") {
+		t.Fatal("CancelTask() = true for a task the handler is done with")
+	}
+}
+
+func TestCancelQueuedLLMTaskSkipsThePrompt(t *testing.T) {
+	task := &models.Task{
+		ID:   uuid.New(),
+		Type: models.TaskTypeLLM,
+	}
+	executor := &countingTaskExecutor{}
+	taskClient := &recordingLLMTaskClient{}
+	handler := NewTaskHandler(executor, taskClient)
+
+	handler.RecordAccepted(task)
+	if !handler.CancelTask(task.ID.String(), "wrong model") {
+		t.Fatal("CancelTask() = false for an accepted LLM task")
+	}
+
+	if err := handler.HandleTask(task); !errors.Is(err, ErrTaskCancelled) {
+		t.Fatalf("HandleTask() error = %v, want ErrTaskCancelled", err)
+	}
+	if calls := executor.calls.Load(); calls != 0 {
+		t.Fatalf("executor ran %d times, want a cancelled prompt not to run", calls)
+	}
+	if len(taskClient.completed) != 0 {
+		t.Fatalf("completed prompt calls = %d, want 0", len(taskClient.completed))
+	}
+	last := taskClient.updates[len(taskClient.updates)-1]
+	if last.status != models.TaskStatusCancelled || !strings.Contains(last.result.Error, "wrong model") {
+		t.Fatalf("final status = %s, error = %q, want cancelled with the reason", last.status, last.result.Error)
+	}
+	if len(taskClient.failed) != 1 || !strings.Contains(taskClient.failed[0], "wrong model") {
+		t.Fatalf("failed prompt reasons = %q, want the prompt closed with the reason", taskClient.failed)
+	}
+}
+
+// signallingTaskExecutor fails every attempt with a retryable exit code and
+// signals each one on started
+type signallingTaskExecutor struct {
+	started chan struct{}
+}
+
+func (e *signallingTaskExecutor) ExecuteTask(ctx context.Context, task *models.Task) (*models.TaskResult, error) {
+	select {
+	case e.started <- struct{}{}:
+	default:
+	}
+	return &models.TaskResult{TaskID: task.ID, ExitCode: 75}, nil
+}
+
+func TestCancelTaskDuringRetryBackoff(t *testing.T) {
+	task := &models.Task{
+		ID:     uuid.New(),
+		Type:   models.TaskTypeDocker,
+		Nonce:  "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		Config: []byte(`{"retry":{"max_retries":3,"retryable_exit_codes":[75],"backoff":"1m"}}`),
+	}
+	executor := &signallingTaskExecutor{started: make(chan struct{}, 1)}
+	taskClient := &recordingTaskClient{}
+	handler := NewTaskHandler(executor, taskClient)
+
+	go func() {
+		<-executor.started
+		handler.CancelTask(task.ID.String(), "wrong dataset")
+	}()
+
+	began := time.Now()
+	if err := handler.HandleTask(task); !errors.Is(err, ErrTaskCancelled) {
+		t.Fatalf("HandleTask() error = %v, want ErrTaskCancelled", err)
+	}
+	if waited := time.Since(began); waited > 10*time.Second {
+		t.Fatalf("HandleTask() waited %v, want the backoff cut short", waited)
+	}
+	last := taskClient.updates[len(taskClient.updates)-1]
+	if last.status != models.TaskStatusCancelled || len(last.result.Attempts) != 1 {
+		t.Fatalf("final status = %s, attempts = %+v, want cancelled after one attempt", last.status, last.result.Attempts)
+	}
+}
+
+func TestHandleTaskStopsRetryingAtTheDeadline(t *testing.T) {
+	// A one minute limit leaves no room for a five minute backoff
+	task := &models.Task{
+		ID:              uuid.New(),
+		Type:            models.TaskTypeDocker,
+		Nonce:           "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		MaxDurationSecs: 60,
+		Config:          []byte(`{"retry":{"max_retries":3,"retryable_exit_codes":[75],"backoff":"5m"}}`),
+	}
+	executor := &scriptedTaskExecutor{results: []*models.TaskResult{{ExitCode: 75}, {ExitCode: 75}}, errs: []error{nil, nil}}
+	taskClient := &recordingTaskClient{}
+	handler := NewTaskHandler(executor, taskClient)
+
+	began := time.Now()
+	if err := handler.HandleTask(task); err != nil {
+		t.Fatalf("HandleTask() error = %v", err)
+	}
+	if waited := time.Since(began); waited > 10*time.Second {
+		t.Fatalf("HandleTask() waited %v, want no backoff past the deadline", waited)
+	}
+	last := taskClient.updates[len(taskClient.updates)-1]
+	attempts := last.result.Attempts
+	if executor.calls != 1 || last.status != models.TaskStatusFailed || len(attempts) != 1 {
+		t.Fatalf("calls = %d, status = %s, attempts = %+v, want one failed attempt", executor.calls, last.status, attempts)
+	}
+	if attempts[0].Cause != models.RetryCauseExitCode || attempts[0].BackoffMs != 0 {
+		t.Fatalf("attempt = %+v, want the retryable cause kept and no backoff", attempts[0])
+	}
+}
+
+func TestHandleTaskRetriesTransientFailures(t *testing.T) {
+	nonce := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	oomKilled := &models.TaskResult{
+		ExitCode:    137,
+		Diagnostics: &models.FailureDiagnostics{Container: &models.ContainerDiagnostics{ExitCode: 137, OOMKilled: true}},
+	}
+	executor := &scriptedTaskExecutor{
+		results: []*models.TaskResult{nil, oomKilled, {Output: "done"}},
+		errs:    []error{fmt.Errorf("image preparation failed: %w: timeout", models.ErrImagePull), nil, nil},
+	}
+	taskClient := &recordingTaskClient{}
+	handler := NewTaskHandler(executor, taskClient)
+	store := events.NewStore(t.TempDir())
+	handler.SetEvents(store)
+
+	task := &models.Task{ID: uuid.New(), Type: models.TaskTypeDocker, Nonce: nonce, Config: []byte(`{"retry":{"max_retries":3,"backoff":"1ms"}}`)}
+	if err := handler.HandleTask(task); err != nil {
+		t.Fatalf("HandleTask() error = %v", err)
+	}
+	last := taskClient.updates[len(taskClient.updates)-1]
+	if last.status != models.TaskStatusCompleted {
+		t.Fatalf("final status = %s, want completed after the retries", last.status)
+	}
+	attempts := last.result.Attempts
+	if len(attempts) != 3 || attempts[0].Cause != models.RetryCauseImagePull || attempts[1].Cause != models.RetryCauseOOMKilled || attempts[1].BackoffMs != 2 || attempts[2].Cause != "" {
+		t.Fatalf("attempts = %+v, want an image pull and an OOM kill retried before success", attempts)
+	}
+	history, _ := store.Load(task.ID.String())
+	retries := 0
+	for _, event := range history {
+		if event.Type == models.ExecutionEventRetrying {
+			retries++
+		}
+	}
+	if retries != 2 {
+		t.Fatalf("retrying events = %d, want 2", retries)
+	}
+
+	// An exit code the policy does not name is the task's own failure
+	executor = &scriptedTaskExecutor{results: []*models.TaskResult{{ExitCode: 1}, {ExitCode: 1}}, errs: []error{nil, nil}}
+	taskClient = &recordingTaskClient{}
+	handler = NewTaskHandler(executor, taskClient)
+	task = &models.Task{ID: uuid.New(), Type: models.TaskTypeDocker, Nonce: nonce, Config: []byte(`{"retry":{"max_retries":3,"retryable_exit_codes":[75],"backoff":"1ms"}}`)}
+	if err := handler.HandleTask(task); err != nil {
+		t.Fatalf("HandleTask() error = %v", err)
+	}
+	last = taskClient.updates[len(taskClient.updates)-1]
+	if executor.calls != 1 || last.status != models.TaskStatusFailed || len(last.result.Attempts) != 1 {
+		t.Fatalf("calls = %d, status = %s, attempts = %+v, want one failed attempt", executor.calls, last.status, last.result.Attempts)
+	}
+}
@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/telemetry"
+)
+
+// newTelemetry starts counting the tasks of taskHandler when the operator opted
+// into telemetry. Otherwise counts kept by an earlier run are deleted, and nil
+// is returned.
+func newTelemetry(cfg *config.Config, taskHandler *DefaultTaskHandler, capabilities *models.CapabilityProfile) *telemetry.Reporter {
+	log := gologger.WithComponent("telemetry")
+
+	dir, err := telemetry.DefaultDir()
+	if err != nil {
+		log.Warn().Err(err).Msg("Usage telemetry is off, the data directory is unknown")
+		return nil
+	}
+	if !cfg.Runner.Telemetry.Enabled || telemetry.Disabled() {
+		if err := telemetry.Purge(dir); err != nil {
+			log.Warn().Err(err).Msg("Failed to delete usage telemetry counts")
+		}
+		return nil
+	}
+
+	collector, err := telemetry.NewCollector(dir)
+	if err != nil {
+		log.Warn().Err(err).Msg("Usage telemetry is off")
+		return nil
+	}
+	taskHandler.SetTelemetry(collector)
+	reporter := telemetry.NewReporter(collector, cfg.Runner.Telemetry.Endpoint, cfg.Runner.ServerURL,
+		telemetry.Classify(capabilities), cfg.Runner.Telemetry.Interval)
+	log.Info().Str("endpoint", reporter.Endpoint()).Msg("Sending anonymous usage telemetry, see `parity-runner telemetry preview`")
+	return reporter
+}
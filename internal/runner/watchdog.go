@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/watchdog"
+)
+
+// defaultDrainTimeout is how long running tasks may take to finish before the
+// watchdog restarts the runner
+const defaultDrainTimeout = 10 * time.Minute
+
+// newWatchdog returns the leak watchdog configured for the runner, or nil when
+// it is disabled
+func newWatchdog(cfg *config.Config) *watchdog.Watchdog {
+	if !cfg.Runner.Watchdog.Enabled {
+		return nil
+	}
+	wc := cfg.Runner.Watchdog
+	dogConfig := watchdog.Config{
+		Interval:      wc.Interval,
+		MaxGoroutines: wc.MaxGoroutines,
+		MaxHeapBytes:  uint64(max(wc.MaxHeapMB, 0)) << 20,
+		MaxOpenFiles:  wc.MaxOpenFiles,
+		Breaches:      wc.Breaches,
+	}
+	if dir, err := watchdog.DefaultDiagnosticsDir(); err == nil {
+		dogConfig.DiagnosticsDir = dir
+	} else {
+		log := gologger.WithComponent("watchdog")
+		log.Warn().Err(err).Msg("Watchdog diagnostics will not be written, the data directory is unknown")
+	}
+	return watchdog.New(dogConfig)
+}
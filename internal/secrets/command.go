@@ -0,0 +1,28 @@
+//go:build darwin || linux
+
+package secrets
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// runCommand runs a secret store tool with input on its stdin, so secrets
+// never show in the process list
+var runCommand = defaultRunCommand
+
+func defaultRunCommand(input, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(input)
+	return cmd.Output()
+}
+
+// exitCode is the status a tool exited with, or -1 when it did not run
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
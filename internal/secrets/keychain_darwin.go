@@ -0,0 +1,49 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+)
+
+// keychain keeps secrets as generic passwords in the login keychain, through
+// the security tool
+type keychain struct{}
+
+// errItemNotFound is the status security exits with for a missing item
+const errItemNotFound = 44
+
+func native() (Backend, error) {
+	return keychain{}, nil
+}
+
+func (keychain) Name() string {
+	return "macOS Keychain"
+}
+
+func (keychain) Get(name string) (string, error) {
+	output, err := runCommand("", "security", "find-generic-password", "-s", service, "-a", name, "-w")
+	if exitCode(err) == errItemNotFound {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from the keychain: %w", name, err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+func (keychain) Set(name, value string) error {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	command := fmt.Sprintf("add-generic-password -U -s %s -a \"%s\" -w \"%s\"\n", service, quote.Replace(name), quote.Replace(value))
+	if _, err := runCommand(command, "security", "-i"); err != nil {
+		return fmt.Errorf("failed to store %s in the keychain: %w", name, err)
+	}
+	return nil
+}
+
+func (keychain) Delete(name string) error {
+	_, err := runCommand("", "security", "delete-generic-password", "-s", service, "-a", name)
+	if err != nil && exitCode(err) != errItemNotFound {
+		return fmt.Errorf("failed to delete %s from the keychain: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package secrets
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// libsecret keeps secrets in the Secret Service of the desktop session, such
+// as GNOME Keyring or KWallet, through secret-tool
+type libsecret struct{}
+
+func native() (Backend, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil, fmt.Errorf("%w: secret-tool from libsecret is not installed", ErrUnavailable)
+	}
+	return libsecret{}, nil
+}
+
+func (libsecret) Name() string {
+	return "Secret Service"
+}
+
+func (libsecret) Get(name string) (string, error) {
+	output, err := runCommand("", "secret-tool", "lookup", "service", service, "account", name)
+	// secret-tool lookup fails without output when nothing matches
+	if exitCode(err) == 1 && len(output) == 0 {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from the Secret Service: %w", name, err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+func (libsecret) Set(name, value string) error {
+	_, err := runCommand(value, "secret-tool", "store", "--label", "Parity Runner "+name, "service", service, "account", name)
+	if err != nil {
+		return fmt.Errorf("failed to store %s in the Secret Service: %w", name, err)
+	}
+	return nil
+}
+
+func (libsecret) Delete(name string) error {
+	_, err := runCommand("", "secret-tool", "clear", "service", service, "account", name)
+	if err != nil && exitCode(err) != 1 {
+		return fmt.Errorf("failed to delete %s from the Secret Service: %w", name, err)
+	}
+	return nil
+}
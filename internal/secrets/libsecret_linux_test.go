@@ -0,0 +1,49 @@
+package secrets
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestLibsecretPassesSecretsOnStdin(t *testing.T) {
+	stored := map[string]string{}
+	runCommand = func(input, name string, args ...string) ([]byte, error) {
+		if strings.Contains(strings.Join(args, " "), "hunter2") {
+			t.Fatalf("secret passed as an argument: %v", args)
+		}
+		account := args[len(args)-1]
+		switch args[0] {
+		case "store":
+			stored[account] = input
+		case "lookup":
+			if value, ok := stored[account]; ok {
+				return []byte(value + "\n"), nil
+			}
+			// What secret-tool does when nothing matches
+			return nil, exec.Command("sh", "-c", "exit 1").Run()
+		case "clear":
+			delete(stored, account)
+		}
+		return nil, nil
+	}
+	defer func() { runCommand = defaultRunCommand }()
+
+	store := libsecret{}
+	if _, err := store.Get("wallet-key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() of a missing secret error = %v, want ErrNotFound", err)
+	}
+	if err := store.Set("wallet-key", "hunter2"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if value, err := store.Get("wallet-key"); err != nil || value != "hunter2" {
+		t.Fatalf("Get() = %q, %v; want hunter2", value, err)
+	}
+	if err := store.Delete("wallet-key"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get("wallet-key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !darwin && !linux && !windows
+
+package secrets
+
+func native() (Backend, error) {
+	return nil, ErrUnavailable
+}
@@ -0,0 +1,51 @@
+// Package secrets keeps the runner's secrets in the secret store of the
+// operating system instead of files under ~/.parity: the login keychain on
+// macOS, the Credential Manager on Windows and the Secret Service (libsecret)
+// on Linux desktops. Entries belong to the service "parity-runner".
+package secrets
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Backends RUNNER_SECRET_BACKEND selects between
+const (
+	BackendFile     = "file"
+	BackendKeychain = "keychain"
+)
+
+// service names the runner's entries in the OS secret store
+const service = "parity-runner"
+
+var (
+	ErrNotFound    = errors.New("secret not found")
+	ErrUnavailable = errors.New("no OS secret store available")
+)
+
+// Backend stores named secrets
+type Backend interface {
+	// Name says which store the secrets are in, for logs
+	Name() string
+	// Get returns ErrNotFound when no secret is stored under name
+	Get(name string) (string, error)
+	Set(name, value string) error
+	// Delete succeeds when no secret is stored under name
+	Delete(name string) error
+}
+
+// ValidateBackend checks a RUNNER_SECRET_BACKEND value, where empty means file
+func ValidateBackend(kind string) error {
+	switch kind {
+	case "", BackendFile, BackendKeychain:
+		return nil
+	default:
+		return fmt.Errorf("unknown secret backend %q, expected %q or %q", kind, BackendFile, BackendKeychain)
+	}
+}
+
+// Native returns the secret store of this operating system, or
+// ErrUnavailable when it has none the runner can use
+func Native() (Backend, error) {
+	return native()
+}
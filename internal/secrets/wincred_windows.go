@@ -0,0 +1,117 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential is CREDENTIALW
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// wincred keeps secrets as generic credentials of the Windows Credential
+// Manager, named parity-runner:<name>
+type wincred struct{}
+
+func native() (Backend, error) {
+	if err := advapi32.Load(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return wincred{}, nil
+}
+
+func (wincred) Name() string {
+	return "Windows Credential Manager"
+}
+
+func target(name string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(service + ":" + name)
+}
+
+func (wincred) Get(name string) (string, error) {
+	targetName, err := target(name)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	ok, _, callErr := procCredReadW.Call(uintptr(unsafe.Pointer(targetName)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ok == 0 {
+		if errors.Is(callErr, errorNotFound) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("failed to read %s from the Credential Manager: %w", name, callErr)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	if cred.CredentialBlobSize == 0 {
+		return "", nil
+	}
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func (wincred) Set(name, value string) error {
+	targetName, err := target(name)
+	if err != nil {
+		return err
+	}
+	userName, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	blob := []byte(value)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         targetName,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	ok, _, callErr := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if ok == 0 {
+		return fmt.Errorf("failed to store %s in the Credential Manager: %w", name, callErr)
+	}
+	return nil
+}
+
+func (wincred) Delete(name string) error {
+	targetName, err := target(name)
+	if err != nil {
+		return err
+	}
+	ok, _, callErr := procCredDeleteW.Call(uintptr(unsafe.Pointer(targetName)), credTypeGeneric, 0)
+	if ok == 0 && !errors.Is(callErr, errorNotFound) {
+		return fmt.Errorf("failed to delete %s from the Credential Manager: %w", name, callErr)
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// arrayRecord is a task that runs once per index. Indices get a task of their
+// own only when they are queued, one per attempt.
+type arrayRecord struct {
+	task    *models.Task
+	config  models.ArrayConfig
+	indices []models.ArrayIndex
+	// pending are the indices waiting for a slot, in the order they are queued
+	pending  []int
+	inFlight int
+}
+
+// arrayAttempt is the array and index a queued task runs
+type arrayAttempt struct {
+	array *arrayRecord
+	index int
+}
+
+func (a *arrayRecord) done() bool {
+	return a.inFlight == 0 && len(a.pending) == 0
+}
+
+func (a *arrayRecord) status(withIndices bool) *models.ArrayStatus {
+	status := &models.ArrayStatus{
+		ArrayID: a.task.ID.String(),
+		Size:    a.config.Size,
+		Counts:  make(map[models.ArrayIndexState]int),
+		Done:    a.done(),
+	}
+	for _, index := range a.indices {
+		status.Counts[index.State]++
+		if index.State == models.ArrayIndexFailed {
+			status.FailedIndices = append(status.FailedIndices, index.Index)
+		}
+	}
+	if withIndices {
+		status.Indices = slices.Clone(a.indices)
+	}
+	return status
+}
+
+// arrayConfig returns the array settings of a task, or nil for ordinary tasks
+func arrayConfig(task *models.Task) *models.ArrayConfig {
+	if task.Type != models.TaskTypeDocker {
+		return nil
+	}
+	var config models.TaskConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return nil
+	}
+	return config.Array
+}
+
+// scheduleArray queues the first indices of an array task instead of the task
+// itself. The array is tracked under the task's ID.
+func (c *RunnerController) scheduleArray(task *models.Task, config *models.ArrayConfig) {
+	log := gologger.WithComponent("arrays")
+
+	array := &arrayRecord{
+		task:    task,
+		config:  *config,
+		indices: make([]models.ArrayIndex, config.Size),
+		pending: make([]int, config.Size),
+	}
+	for i := range array.indices {
+		array.indices[i] = models.ArrayIndex{Index: i, State: models.ArrayIndexPending}
+		array.pending[i] = i
+	}
+
+	c.mu.Lock()
+	if c.arrays == nil {
+		c.arrays = make(map[string]*arrayRecord)
+		c.arrayAttempts = make(map[string]arrayAttempt)
+	}
+	c.arrays[task.ID.String()] = array
+	queued := c.fillArrayLocked(array)
+	c.mu.Unlock()
+
+	arrayID := task.ID.String()
+	c.recordEvent(arrayID, models.TaskEvent{
+		Type:   models.TaskEventArrayScheduled,
+		Detail: fmt.Sprintf("%d indices, %d at a time", config.Size, config.Slots()),
+	})
+	log.Info().Str("array_id", arrayID).Int("size", config.Size).Int("parallelism", config.Slots()).Msg("Scheduling array")
+
+	for _, task := range queued {
+		c.AddAvailableTask(task)
+	}
+}
+
+// fillArrayLocked creates the tasks of pending indices while the array has
+// free slots and returns them to be queued. c.mu must be held.
+func (c *RunnerController) fillArrayLocked(array *arrayRecord) []*models.Task {
+	var queued []*models.Task
+	for array.inFlight < array.config.Slots() && len(array.pending) > 0 {
+		i := array.pending[0]
+		array.pending = array.pending[1:]
+
+		task, err := array.indexTask(i)
+		if err != nil {
+			array.indices[i].State = models.ArrayIndexFailed
+			array.indices[i].Error = err.Error()
+			continue
+		}
+		index := &array.indices[i]
+		index.State = models.ArrayIndexQueued
+		index.Attempts++
+		index.TaskID = task.ID.String()
+		index.DeviceID = ""
+		array.inFlight++
+		c.arrayAttempts[index.TaskID] = arrayAttempt{array: array, index: i}
+		queued = append(queued, task)
+	}
+	return queued
+}
+
+// indexTask is the task of an attempt at index i: the array task without its
+// array settings and with the index in its environment
+func (a *arrayRecord) indexTask(i int) (*models.Task, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(a.task.Config, &config); err != nil {
+		return nil, fmt.Errorf("invalid array task config: %w", err)
+	}
+	env := make(map[string]string)
+	if raw, ok := config["env"]; ok {
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return nil, fmt.Errorf("invalid array task env: %w", err)
+		}
+	}
+	for key, value := range models.ArrayEnv(a.task.ID.String(), i, a.config.Size) {
+		env[key] = value
+	}
+	delete(config, "array")
+	var err error
+	if config["env"], err = json.Marshal(env); err != nil {
+		return nil, err
+	}
+
+	task := *a.task
+	if task.Config, err = json.Marshal(config); err != nil {
+		return nil, err
+	}
+	task.ID = uuid.New()
+	task.Title = fmt.Sprintf("%s [%d]", a.task.Title, i)
+	task.Status = models.TaskStatusPending
+	task.CreatedAt = time.Now()
+	task.UpdatedAt = task.CreatedAt
+	return &task, nil
+}
+
+// startArrayIndex records the runner of a started index
+func (c *RunnerController) startArrayIndex(taskID, deviceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	attempt, ok := c.arrayAttempts[taskID]
+	if !ok {
+		return
+	}
+	index := &attempt.array.indices[attempt.index]
+	index.State = models.ArrayIndexRunning
+	index.DeviceID = deviceID
+}
+
+// resolveArrayIndex completes the index a result is for, or runs it again if it
+// failed and has attempts left, and queues the indices waiting for its slot.
+// Once every index is done the array task gets a result of its own. Results of
+// other tasks are ignored.
+func (c *RunnerController) resolveArrayIndex(result *models.TaskResult) {
+	log := gologger.WithComponent("arrays")
+	taskID := result.TaskID.String()
+
+	c.mu.Lock()
+	attempt, ok := c.arrayAttempts[taskID]
+	if !ok {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.arrayAttempts, taskID)
+	array := attempt.array
+	index := &array.indices[attempt.index]
+	arrayID := array.task.ID.String()
+
+	array.inFlight--
+	index.ExitCode = result.ExitCode
+	index.Error = result.Error
+	failed := result.ExitCode != 0 || result.Error != ""
+	switch {
+	case !failed:
+		index.State = models.ArrayIndexCompleted
+	case index.Attempts < array.config.Attempts():
+		index.State = models.ArrayIndexPending
+		array.pending = append(array.pending, attempt.index)
+	default:
+		index.State = models.ArrayIndexFailed
+	}
+	queued := c.fillArrayLocked(array)
+	var arrayResult *models.TaskResult
+	if array.done() {
+		arrayResult = array.result()
+		c.results[arrayID] = arrayResult
+	}
+	attempts := index.Attempts
+	c.mu.Unlock()
+
+	if failed {
+		c.recordEvent(arrayID, models.TaskEvent{
+			Type:     models.TaskEventArrayIndexFailed,
+			DeviceID: result.DeviceID,
+			Detail:   fmt.Sprintf("index %d attempt %d: exit code %d %s", attempt.index, attempts, result.ExitCode, result.Error),
+		})
+	}
+	for _, task := range queued {
+		c.AddAvailableTask(task)
+	}
+	if arrayResult != nil {
+		log.Info().Str("array_id", arrayID).Int("exit_code", arrayResult.ExitCode).Msg("Array finished")
+		c.recordEvent(arrayID, models.TaskEvent{Type: models.TaskEventArrayCompleted, Detail: arrayResult.Output})
+	}
+}
+
+// result sums up a finished array. It failed when any index did.
+func (a *arrayRecord) result() *models.TaskResult {
+	status := a.status(false)
+	completed := status.Counts[models.ArrayIndexCompleted]
+	result := &models.TaskResult{
+		TaskID:    a.task.ID,
+		Output:    fmt.Sprintf("%d of %d indices completed", completed, a.config.Size),
+		CreatedAt: time.Now(),
+	}
+	if failed := len(status.FailedIndices); failed > 0 {
+		result.ExitCode = 1
+		result.Error = fmt.Sprintf("%d indices failed: %v", failed, status.FailedIndices)
+	}
+	return result
+}
+
+// retryArray runs failed indices of an array again with a fresh set of
+// attempts for the device that created it. Indices that did not fail are refused.
+func (c *RunnerController) retryArray(arrayID, requesterID string, retry models.ArrayRetry) (*models.ArrayStatus, int, error) {
+	c.mu.Lock()
+	array, ok := c.arrays[arrayID]
+	if !ok {
+		c.mu.Unlock()
+		return nil, http.StatusNotFound, fmt.Errorf("array %s not found", arrayID)
+	}
+	if requesterID == "" || requesterID != array.task.CreatorDeviceID {
+		c.mu.Unlock()
+		return nil, http.StatusForbidden, errNotTaskCreator
+	}
+
+	indices := retry.Indices
+	if len(indices) == 0 {
+		indices = array.status(false).FailedIndices
+	}
+	for _, i := range indices {
+		if i < 0 || i >= len(array.indices) || array.indices[i].State != models.ArrayIndexFailed {
+			c.mu.Unlock()
+			return nil, http.StatusConflict, fmt.Errorf("index %d has not failed", i)
+		}
+	}
+	if len(indices) == 0 {
+		c.mu.Unlock()
+		return nil, http.StatusConflict, fmt.Errorf("array %s has no failed indices", arrayID)
+	}
+
+	for _, i := range slices.Compact(slices.Sorted(slices.Values(indices))) {
+		array.indices[i] = models.ArrayIndex{Index: i, State: models.ArrayIndexPending}
+		array.pending = append(array.pending, i)
+	}
+	delete(c.results, arrayID)
+	queued := c.fillArrayLocked(array)
+	status := array.status(false)
+	c.mu.Unlock()
+
+	c.recordEvent(arrayID, models.TaskEvent{Type: models.TaskEventArrayRetried, Detail: fmt.Sprintf("indices %v", indices)})
+	for _, task := range queued {
+		c.AddAvailableTask(task)
+	}
+	return status, 0, nil
+}
+
+// ArrayStatus returns the progress of an array
+func (c *RunnerController) ArrayStatus(arrayID string, withIndices bool) (*models.ArrayStatus, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	array, ok := c.arrays[arrayID]
+	if !ok {
+		return nil, false
+	}
+	return array.status(withIndices), true
+}
+
+func (c *RunnerController) handleGetArray(ctx *gin.Context) {
+	status, ok := c.ArrayStatus(ctx.Param("arrayID"), ctx.Query("indices") == "true")
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Array not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
+
+func (c *RunnerController) handleRetryArray(ctx *gin.Context) {
+	var retry models.ArrayRetry
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.BindJSON(&retry); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	status, code, err := c.retryArray(ctx.Param("arrayID"), ctx.GetHeader("X-Device-ID"), retry)
+	if err != nil {
+		ctx.JSON(code, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func createArray(t *testing.T, router http.Handler, array map[string]interface{}) models.Task {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"title":       "sweep",
+		"type":        "docker",
+		"nonce":       "n",
+		"environment": map[string]string{"type": "docker"},
+		"config": map[string]interface{}{
+			"image_name": "ghcr.io/acme/sweep:1",
+			"env":        map[string]string{"MODE": "fast"},
+			"array":      array,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", "creator-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create array = %d %s", rec.Code, rec.Body.String())
+	}
+	var task models.Task
+	if err := json.Unmarshal(rec.Body.Bytes(), &task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+// runIndex starts the queued task of an index and submits its result
+func runIndex(t *testing.T, controller *RunnerController, task *models.Task, exitCode int) {
+	t.Helper()
+	if code, message := controller.startTask(context.Background(), task.ID.String(), "device-1"); code != 0 {
+		t.Fatalf("start = %d %s", code, message)
+	}
+	if _, err := controller.submitTaskResult(context.Background(), &models.TaskResult{TaskID: task.ID, DeviceID: "device-1", ExitCode: exitCode}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArrayQueuesIndicesWithinParallelism(t *testing.T) {
+	controller := NewRunnerController(nil)
+	array := createArray(t, newTestRouter(controller), map[string]interface{}{"size": 3, "parallelism": 2})
+
+	queued := controller.availableTasksFor("device-1")
+	if len(queued) != 2 {
+		t.Fatalf("%d indices queued, want 2", len(queued))
+	}
+	var config models.TaskConfig
+	if err := json.Unmarshal(queued[1].Config, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Array != nil || config.Env["PARITY_ARRAY_INDEX"] != "1" || config.Env["PARITY_ARRAY_SIZE"] != "3" || config.Env["MODE"] != "fast" {
+		t.Fatalf("index task config = %+v", config)
+	}
+
+	runIndex(t, controller, queued[0], 0)
+	if next := controller.availableTasksFor("device-1"); len(next) != 2 {
+		t.Fatalf("%d indices queued after one completed, want 2", len(next))
+	}
+	status, _ := controller.ArrayStatus(array.ID.String(), false)
+	if status.Counts[models.ArrayIndexCompleted] != 1 || status.Counts[models.ArrayIndexQueued] != 2 || status.Done {
+		t.Fatalf("status = %+v", status)
+	}
+}
+
+func TestArrayRetriesFailedIndices(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+	array := createArray(t, router, map[string]interface{}{"size": 2, "max_attempts": 2})
+	arrayID := array.ID.String()
+
+	// Index 1 fails on both attempts, index 0 succeeds
+	for attempt := 0; attempt < 2; attempt++ {
+		for _, task := range controller.availableTasksFor("device-1") {
+			exitCode := 1
+			if task.Title == "sweep [0]" {
+				exitCode = 0
+			}
+			runIndex(t, controller, task, exitCode)
+		}
+	}
+	status, _ := controller.ArrayStatus(arrayID, true)
+	if !status.Done || len(status.FailedIndices) != 1 || status.FailedIndices[0] != 1 || status.Indices[1].Attempts != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+	if result, ok := controller.GetTaskResult(arrayID); !ok || result.ExitCode == 0 {
+		t.Fatalf("array result = %+v", result)
+	}
+
+	retry := func(deviceID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/arrays/"+arrayID+"/retry", strings.NewReader(body))
+		req.Header.Set("X-Device-ID", deviceID)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := retry("creator-1", `{"indices":[0]}`); rec.Code != http.StatusConflict {
+		t.Fatalf("retry of a completed index = %d, want 409", rec.Code)
+	}
+	for _, deviceID := range []string{"", "device-1"} {
+		if rec := retry(deviceID, ""); rec.Code != http.StatusForbidden {
+			t.Fatalf("retry as %q = %d, want 403", deviceID, rec.Code)
+		}
+	}
+	if queued := controller.availableTasksFor("device-1"); len(queued) != 0 {
+		t.Fatalf("a refused retry queued %v", queued)
+	}
+	if rec := retry("creator-1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("retry = %d %s", rec.Code, rec.Body.String())
+	}
+
+	queued := controller.availableTasksFor("device-1")
+	if len(queued) != 1 || queued[0].Title != "sweep [1]" {
+		t.Fatalf("queued after retry = %v", queued)
+	}
+	runIndex(t, controller, queued[0], 0)
+	if result, ok := controller.GetTaskResult(arrayID); !ok || result.ExitCode != 0 {
+		t.Fatalf("array result after retry = %+v", result)
+	}
+	if _, ok := controller.ArrayStatus(uuid.NewString(), false); ok {
+		t.Fatal("unknown array should not be found")
+	}
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// defaultClaimLease is how long a runner holds a claim without reporting that
+// it is busy before the task's creator may revoke it
+const defaultClaimLease = 10 * time.Minute
+
+var (
+	errAssignmentRevoked = errors.New("task assignment was revoked")
+	errNotTaskCreator    = errors.New("only the task's creator can do this")
+	errTaskNotRunning    = errors.New("task is not running")
+	errClaimHeld         = errors.New("runner is still making progress on the task")
+	errGangAssignment    = errors.New("members of a gang task cannot be revoked")
+)
+
+// SetClaimLease sets how long a runner holds a claim without progress.
+// Durations of zero or less restore the default.
+func (c *RunnerController) SetClaimLease(lease time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.claimLease = lease
+}
+
+func (c *RunnerController) claimLeaseLocked() time.Duration {
+	if c.claimLease <= 0 {
+		return defaultClaimLease
+	}
+	return c.claimLease
+}
+
+// recordProgress extends the claims of a runner that reported it is busy
+func (c *RunnerController) recordProgress(deviceID string, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for taskID, assigned := range c.assigned {
+		if assigned.deviceID == deviceID && at.After(assigned.progressAt) {
+			assigned.progressAt = at
+			c.assigned[taskID] = assigned
+		}
+	}
+}
+
+// runnerPseudonym names the runner holding a task to the task's creator. It is
+// derived from both, so it says nothing about the runner's other tasks.
+func runnerPseudonym(taskID, deviceID string) string {
+	sum := sha256.Sum256([]byte("parity-assignment\n" + taskID + "\n" + deviceID))
+	return "runner-" + hex.EncodeToString(sum[:6])
+}
+
+// assignmentLocked describes a claim for the task's creator. The lease ends
+// claimLease after the last progress, or when the task runs out of time if that
+// is sooner.
+func (c *RunnerController) assignmentLocked(taskID string, assigned assignment) *models.TaskAssignment {
+	lease := assigned.progressAt.Add(c.claimLeaseLocked())
+	if limit := assigned.task.MaxDuration(); limit > 0 {
+		if deadline := assigned.startedAt.Add(limit + timeoutGrace); deadline.Before(lease) {
+			lease = deadline
+		}
+	}
+
+	reputation := c.reputationsLocked()[assigned.deviceID]
+	reputation.Quarantined = c.quarantine != nil && c.quarantine.IsQuarantined(assigned.deviceID)
+	view := &models.TaskAssignment{
+		Runner:         runnerPseudonym(taskID, assigned.deviceID),
+		ReputationTier: reputation.Tier(),
+		ClaimedAt:      assigned.startedAt.UTC(),
+		LastProgressAt: assigned.progressAt.UTC(),
+		LeaseExpiresAt: lease.UTC(),
+	}
+	if profile := c.capabilities[assigned.deviceID]; profile != nil {
+		view.Region = profile.Region
+	}
+	return view
+}
+
+// TaskFor returns the task as shown to requesterID, which holds the assignment
+// only when requesterID is the device that created the task. Tasks the server
+// no longer holds are reported with the status of their result.
+func (c *RunnerController) TaskFor(taskID, requesterID string) (*models.Task, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result, finished := c.results[taskID]
+	var task models.Task
+	if assigned, ok := c.assigned[taskID]; ok {
+		task = *assigned.task
+		task.Status = models.TaskStatusRunning
+		if !finished && requesterID != "" && requesterID == task.CreatorDeviceID {
+			task.Assignment = c.assignmentLocked(taskID, assigned)
+		}
+	} else if queued := c.findAvailableLocked(taskID); queued != nil {
+		task = *queued
+		task.Status = models.TaskStatusPending
+	} else if finished {
+		task = models.Task{ID: result.TaskID}
+	} else {
+		return nil, false
+	}
+
+	if finished {
+		switch {
+		case result.Status == models.TaskStatusCancelled:
+			task.Status = models.TaskStatusCancelled
+		case result.ExitCode != 0 || result.Error != "":
+			task.Status = models.TaskStatusFailed
+		default:
+			task.Status = models.TaskStatusCompleted
+		}
+	}
+	return &task, true
+}
+
+func (c *RunnerController) findAvailableLocked(taskID string) *models.Task {
+	for _, task := range c.availableTasks {
+		if task.ID.String() == taskID {
+			return task
+		}
+	}
+	return nil
+}
+
+// RevokeAssignment takes a task back from a runner that let its claim lapse and
+// queues it again. Only the device that created the task may revoke it.
+func (c *RunnerController) RevokeAssignment(taskID, requesterID string, now time.Time) (*models.Task, error) {
+	c.mu.Lock()
+	assigned, ok := c.assigned[taskID]
+	_, finished := c.results[taskID]
+	switch {
+	case !ok || finished:
+		c.mu.Unlock()
+		return nil, errTaskNotRunning
+	case requesterID == "" || requesterID != assigned.task.CreatorDeviceID:
+		c.mu.Unlock()
+		return nil, errNotTaskCreator
+	case c.gangRuns[taskID] != nil:
+		c.mu.Unlock()
+		return nil, errGangAssignment
+	case !c.assignmentLocked(taskID, assigned).Lapsed(now):
+		c.mu.Unlock()
+		return nil, errClaimHeld
+	}
+	delete(c.assigned, taskID)
+	if c.revoked == nil {
+		c.revoked = make(map[string]map[string]bool)
+	}
+	if c.revoked[taskID] == nil {
+		c.revoked[taskID] = make(map[string]bool)
+	}
+	c.revoked[taskID][assigned.deviceID] = true
+	c.mu.Unlock()
+
+	c.recordEvent(taskID, models.TaskEvent{
+		Type:     models.TaskEventAssignmentRevoked,
+		Time:     now.UTC(),
+		DeviceID: assigned.deviceID,
+		Detail:   "claim lapsed without progress",
+	})
+	c.abortRevokedTask(taskID, assigned.deviceID)
+	c.AddAvailableTask(assigned.task)
+
+	log := gologger.WithComponent("runner_controller")
+	log.Info().
+		Str("task_id", taskID).
+		Str("device_id", assigned.deviceID).
+		Msg("Task creator revoked a lapsed assignment")
+
+	task, _ := c.TaskFor(taskID, requesterID)
+	return task, nil
+}
+
+// wasRevokedFrom reports whether the task was taken back from the runner, whose
+// results for it are refused and who is not offered it again
+func (c *RunnerController) wasRevokedFrom(taskID, deviceID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.revoked[taskID][deviceID]
+}
+
+// abortRevokedTask tells a runner with a registered webhook to stop the task
+func (c *RunnerController) abortRevokedTask(taskID, deviceID string) {
+	body, err := json.Marshal(map[string]interface{}{
+		"type": "abort_task",
+		"payload": map[string]string{
+			"task_id": taskID,
+			"reason":  "assignment revoked by the task creator",
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		req, err := c.NewRunnerWebhookRequest(ctx, deviceID, body)
+		if err != nil {
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log := gologger.WithComponent("runner_controller")
+			log.Debug().Err(err).Str("device_id", deviceID).Msg("Failed to tell runner its assignment was revoked")
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+func (c *RunnerController) handleGetTask(ctx *gin.Context) {
+	task, ok := c.TaskFor(ctx.Param("taskID"), ctx.GetHeader("X-Device-ID"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, task)
+}
+
+func (c *RunnerController) handleRevokeAssignment(ctx *gin.Context) {
+	task, err := c.RevokeAssignment(ctx.Param("taskID"), ctx.GetHeader("X-Device-ID"), time.Now())
+	switch {
+	case errors.Is(err, errTaskNotRunning):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errNotTaskCreator):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusOK, task)
+	}
+}
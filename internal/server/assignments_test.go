@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestCreatorSeesAssignmentWithoutDeviceID(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+	registerWithCapabilities(t, router, "device-1", &models.CapabilityProfile{CPUCores: 4, Region: "eu-west"})
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	task.CreatorDeviceID = "creator"
+	controller.AddAvailableTask(task)
+	if status, message := controller.startTask(context.Background(), task.ID.String(), "device-1"); status != 0 {
+		t.Fatalf("startTask() = %d %s", status, message)
+	}
+
+	get := func(requester string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+task.ID.String(), nil)
+		if requester != "" {
+			req.Header.Set("X-Device-ID", requester)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := get("creator")
+	if code != http.StatusOK || !strings.Contains(body, `"assignment"`) || !strings.Contains(body, `"region":"eu-west"`) {
+		t.Fatalf("creator view = %d %s", code, body)
+	}
+	if strings.Contains(body, "device-1") {
+		t.Fatalf("creator view names the runner's device: %s", body)
+	}
+	if !strings.Contains(body, `"reputation_tier":"new"`) || !strings.Contains(body, `"status":"running"`) {
+		t.Fatalf("creator view = %s", body)
+	}
+	if _, body := get("someone-else"); strings.Contains(body, `"assignment"`) {
+		t.Fatalf("another device sees the assignment: %s", body)
+	}
+}
+
+func TestRevokeLapsedAssignment(t *testing.T) {
+	controller := NewRunnerController(nil)
+	controller.SetClaimLease(time.Minute)
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	task.CreatorDeviceID = "creator"
+	controller.AddAvailableTask(task)
+	taskID := task.ID.String()
+	if status, message := controller.startTask(context.Background(), taskID, "device-1"); status != 0 {
+		t.Fatalf("startTask() = %d %s", status, message)
+	}
+
+	now := time.Now()
+	if _, err := controller.RevokeAssignment(taskID, "creator", now); err != errClaimHeld {
+		t.Fatalf("revoking a fresh claim = %v, want errClaimHeld", err)
+	}
+	if _, err := controller.RevokeAssignment(taskID, "device-2", now.Add(2*time.Minute)); err != errNotTaskCreator {
+		t.Fatalf("revoking another creator's task = %v, want errNotTaskCreator", err)
+	}
+
+	// A busy heartbeat extends the claim
+	controller.recordProgress("device-1", now.Add(50*time.Second))
+	if _, err := controller.RevokeAssignment(taskID, "creator", now.Add(90*time.Second)); err != errClaimHeld {
+		t.Fatalf("revoking a claim with progress = %v, want errClaimHeld", err)
+	}
+
+	revoked, err := controller.RevokeAssignment(taskID, "creator", now.Add(3*time.Minute))
+	if err != nil {
+		t.Fatalf("RevokeAssignment() = %v", err)
+	}
+	if revoked.Status != models.TaskStatusPending || revoked.Assignment != nil {
+		t.Fatalf("revoked task = %+v, want it queued again", revoked)
+	}
+
+	if tasks := controller.availableTasksFor("device-1"); len(tasks) != 0 {
+		t.Fatalf("runner the task was revoked from was offered %d tasks", len(tasks))
+	}
+	if tasks := controller.availableTasksFor("device-2"); len(tasks) != 1 {
+		t.Fatalf("other runner was offered %d tasks, want the revoked one", len(tasks))
+	}
+	if _, err := controller.submitTaskResult(context.Background(), &models.TaskResult{TaskID: task.ID, DeviceID: "device-1"}); err != errAssignmentRevoked {
+		t.Fatalf("late result from the revoked runner = %v, want errAssignmentRevoked", err)
+	}
+	// The body cannot name another device, or leave it out, to get past the check
+	for bodyDevice, want := range map[string]int{"": http.StatusConflict, "device-2": http.StatusBadRequest} {
+		body, _ := json.Marshal(models.TaskResult{TaskID: task.ID, DeviceID: bodyDevice})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+taskID+"/result", bytes.NewReader(body))
+		req.Header.Set("X-Device-ID", "device-1")
+		rec := httptest.NewRecorder()
+		newTestRouter(controller).ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("late result from the revoked runner naming %q = %d, want %d", bodyDevice, rec.Code, want)
+		}
+	}
+	if status, _ := controller.startTask(context.Background(), taskID, "device-1"); status != http.StatusConflict {
+		t.Fatalf("revoked runner starting the task again = %d, want 409", status)
+	}
+}
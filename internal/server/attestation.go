@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// attestationVerifyTimeout bounds verifying the quote of one result
+const attestationVerifyTimeout = 30 * time.Second
+
+// AttestationVerifier checks the signature of a quote against the vendor's
+// certificate chain, such as AMD's KDS for SEV-SNP or Intel's PCS for TDX and
+// SGX, and that the platform it names is one the operator trusts
+type AttestationVerifier interface {
+	VerifyQuote(ctx context.Context, quote *models.AttestationQuote) error
+}
+
+// SetAttestationVerifier makes results of TEE tasks attested when the verifier
+// accepts their quote. Without one no quote is attested and TEE tasks are paid
+// like any other task.
+func (c *RunnerController) SetAttestationVerifier(verifier AttestationVerifier) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attestationVerifier = verifier
+}
+
+// checkAttestation discards an attestation quote that was not made for the
+// result it came with, marks the quote attested when the attestation verifier
+// accepts it, and reports whether the result may be paid. Only a verified quote
+// can hold back a payout: with no verifier set, quotes stay unattested and the
+// payout is left to result hooks.
+func (c *RunnerController) checkAttestation(ctx context.Context, result *models.TaskResult) bool {
+	log := gologger.WithComponent("runner_controller")
+	taskID := result.TaskID.String()
+
+	// Only the server decides what is attested
+	if result.Attestation != nil {
+		result.Attestation.Attested = false
+	}
+
+	c.mu.RLock()
+	assigned, ok := c.assigned[taskID]
+	verifier := c.attestationVerifier
+	c.mu.RUnlock()
+	if !ok {
+		// Without the task neither the nonce nor whether it needed a TEE is known
+		return true
+	}
+
+	if result.Attestation != nil {
+		if err := result.Attestation.VerifyBinding(assigned.task.ID, assigned.task.Nonce, result.ResultHash); err != nil {
+			log.Warn().Err(err).Str("task_id", taskID).Msg("Discarding attestation quote made for another result")
+			result.Attestation = nil
+		}
+	}
+	if verifier == nil || !assigned.task.RequiresTEE() {
+		return true
+	}
+	if result.Attestation == nil {
+		return false
+	}
+
+	verifyCtx, cancel := context.WithTimeout(ctx, attestationVerifyTimeout)
+	defer cancel()
+	if err := verifier.VerifyQuote(verifyCtx, result.Attestation); err != nil {
+		log.Warn().Err(err).Str("task_id", taskID).Msg("Attestation quote failed verification")
+		return false
+	}
+	result.Attestation.Attested = true
+	return true
+}
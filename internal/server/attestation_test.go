@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// startTEETask queues a wasm task that needs a TEE and starts it on device-1
+func startTEETask(t *testing.T, controller *RunnerController) *models.Task {
+	t.Helper()
+
+	task := &models.Task{ID: uuid.New(), Type: models.TaskTypeWasm, IsolationLevel: models.IsolationTEE, Nonce: "nonce"}
+	controller.AddAvailableTask(task)
+	if code, message := controller.startTask(context.Background(), task.ID.String(), "device-1"); code != 0 {
+		t.Fatalf("start = %d %s", code, message)
+	}
+	return task
+}
+
+// sgxQuote is an SGX quote with the report data of the result in the enclave
+// report, signed by nobody
+func sgxQuote(task *models.Task, resultHash string) *models.AttestationQuote {
+	reportData := models.AttestationReportData(task.ID, task.Nonce, resultHash)
+	raw := make([]byte, 48+384)
+	copy(raw[48+320:], reportData[:])
+	return &models.AttestationQuote{Version: models.AttestationVersion, Technology: models.TEESGX, Quote: raw}
+}
+
+// signedQuotes accepts quotes whose first byte is 1, standing in for a check
+// against the vendor's certificate chain
+type signedQuotes struct{}
+
+func (signedQuotes) VerifyQuote(_ context.Context, quote *models.AttestationQuote) error {
+	if len(quote.Quote) == 0 || quote.Quote[0] != 1 {
+		return errors.New("quote signature does not chain to the vendor root")
+	}
+	return nil
+}
+
+func TestUnverifiedAttestationIsNotAttested(t *testing.T) {
+	controller := NewRunnerController(nil)
+
+	task := startTEETask(t, controller)
+	replayed := &models.TaskResult{TaskID: task.ID, DeviceID: "device-1", ResultHash: "hash", Attestation: sgxQuote(task, "other")}
+	outcome, err := controller.submitTaskResult(context.Background(), replayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Attestation != nil {
+		t.Fatal("quote for another result was kept")
+	}
+	if !outcome.PayoutApproved {
+		t.Fatalf("payout without an attestation verifier = %+v, want it left to result hooks", outcome)
+	}
+
+	// The runner cannot claim the quote is attested, and the bytes at the
+	// report data offset alone do not make it so
+	task = startTEETask(t, controller)
+	quote := sgxQuote(task, "hash")
+	quote.Attested = true
+	bound := &models.TaskResult{TaskID: task.ID, DeviceID: "device-1", ResultHash: "hash", Attestation: quote}
+	if outcome, err = controller.submitTaskResult(context.Background(), bound); err != nil || !outcome.PayoutApproved {
+		t.Fatalf("bound result = %+v, %v", outcome, err)
+	}
+	if bound.Attestation == nil || bound.Attestation.Attested {
+		t.Fatalf("attestation = %+v, want the quote kept and not attested", bound.Attestation)
+	}
+}
+
+func TestAttestationVerifierGatesTEEPayouts(t *testing.T) {
+	controller := NewRunnerController(nil)
+	controller.SetAttestationVerifier(signedQuotes{})
+
+	submit := func(quote func(*models.Task) *models.AttestationQuote) (*models.TaskResult, resultOutcome) {
+		t.Helper()
+		task := startTEETask(t, controller)
+		result := &models.TaskResult{TaskID: task.ID, DeviceID: "device-1", ResultHash: "hash"}
+		if quote != nil {
+			result.Attestation = quote(task)
+		}
+		outcome, err := controller.submitTaskResult(context.Background(), result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result, outcome
+	}
+
+	if _, outcome := submit(nil); outcome.PayoutApproved {
+		t.Fatalf("result without a quote = %+v, want the payout withheld", outcome)
+	}
+
+	forged, outcome := submit(func(task *models.Task) *models.AttestationQuote { return sgxQuote(task, "hash") })
+	if outcome.PayoutApproved || forged.Attestation.Attested {
+		t.Fatalf("unsigned quote = %+v, %+v, want the payout withheld", outcome, forged.Attestation)
+	}
+
+	signed, outcome := submit(func(task *models.Task) *models.AttestationQuote {
+		quote := sgxQuote(task, "hash")
+		quote.Quote[0] = 1
+		return quote
+	})
+	if !outcome.PayoutApproved || !signed.Attestation.Attested {
+		t.Fatalf("signed quote = %+v, %+v, want it attested and paid", outcome, signed.Attestation)
+	}
+
+	wrongResult, outcome := submit(func(task *models.Task) *models.AttestationQuote {
+		quote := sgxQuote(task, "other")
+		quote.Quote[0] = 1
+		return quote
+	})
+	if outcome.PayoutApproved || wrongResult.Attestation != nil {
+		t.Fatalf("signed quote for another result = %+v, want it discarded and the payout withheld", outcome)
+	}
+}
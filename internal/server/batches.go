@@ -0,0 +1,380 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/gas"
+)
+
+// maxSettledBatches bounds the settled batches kept for the reconciliation
+// report
+const maxSettledBatches = 500
+
+// ErrBatchAlreadyPaid is returned by a BatchTransferrer whose contract refused
+// the batch ID because the batch was paid before, so the batch is settled
+var ErrBatchAlreadyPaid = errors.New("batch already paid")
+
+// RewardTransfer is one reward of a payout batch
+type RewardTransfer struct {
+	TaskID   string
+	DeviceID string
+	Amount   float64
+}
+
+// BatchTransferrer is implemented by chains that pay many rewards in one
+// transaction. batchID is the same on every attempt of a batch, so the chain
+// can refuse to pay a batch twice, with ErrBatchAlreadyPaid.
+type BatchTransferrer interface {
+	TransferRewardBatch(ctx context.Context, batchID string, transfers []RewardTransfer) (txHash string, err error)
+}
+
+// PayoutBatch is a group of payouts settled in one transaction
+type PayoutBatch struct {
+	ID            string     `json:"id"`
+	TaskIDs       []string   `json:"task_ids"`
+	Total         float64    `json:"total"`
+	TxHash        string     `json:"tx_hash,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SettledAt     *time.Time `json:"settled_at,omitempty"`
+	Attempts      int        `json:"attempts"`
+	LastErr       string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	// PendingTx is the transaction of a failed attempt that may still be mined
+	PendingTx *gas.Pending `json:"pending_tx,omitempty"`
+
+	payouts []*Payout
+}
+
+// payoutBatchID derives the batch ID from its payouts, so a batch restored
+// after a restart is retried under the ID it was first sent with
+func payoutBatchID(payouts []*Payout) string {
+	taskIDs := make([]string, 0, len(payouts))
+	for _, payout := range payouts {
+		taskIDs = append(taskIDs, payout.TaskID)
+	}
+	sort.Strings(taskIDs)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s", payouts[0].ChainID, strings.Join(taskIDs, "\n"))))
+	return "0x" + hex.EncodeToString(sum[:])
+}
+
+func newPayoutBatch(id string, payouts []*Payout, now time.Time) *PayoutBatch {
+	batch := &PayoutBatch{ID: id, CreatedAt: now, NextAttemptAt: now, payouts: payouts}
+	for _, payout := range payouts {
+		payout.BatchID = id
+		batch.TaskIDs = append(batch.TaskIDs, payout.TaskID)
+		batch.Total += payout.Amount
+	}
+	return batch
+}
+
+// batching reports whether payouts are collected into batches rather than
+// sent one by one
+func (g *ChainGateway) batching() bool {
+	_, ok := g.chain.(BatchTransferrer)
+	return ok && g.config.BatchSize > 1
+}
+
+// collect holds a payout for the next batch, asking Run to settle right away
+// once a full batch is waiting
+func (g *ChainGateway) collect(ctx context.Context, payout Payout) {
+	payout.QueuedAt = g.now()
+	g.mu.Lock()
+	g.collecting = append(g.collecting, &payout)
+	full := len(g.collecting) >= g.config.BatchSize
+	g.mu.Unlock()
+	g.persist(ctx, payout)
+
+	if full {
+		select {
+		case g.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// formBatches turns collected payouts into batches of BatchSize, and a smaller
+// one once the oldest has waited BatchInterval
+func (g *ChainGateway) formBatches(ctx context.Context) {
+	g.mu.Lock()
+	now := g.now()
+	var formed []Payout
+	for len(g.collecting) >= g.config.BatchSize ||
+		(len(g.collecting) > 0 && now.Sub(g.collecting[0].QueuedAt) >= g.config.BatchInterval) {
+		size := min(len(g.collecting), g.config.BatchSize)
+		members := append([]*Payout{}, g.collecting[:size]...)
+		g.collecting = g.collecting[size:]
+		g.batches = append(g.batches, newPayoutBatch(payoutBatchID(members), members, now))
+		for _, payout := range members {
+			formed = append(formed, *payout)
+		}
+	}
+	g.mu.Unlock()
+
+	for _, payout := range formed {
+		g.persist(ctx, payout)
+	}
+}
+
+// SettleBatches forms the batches that are due and pays them, oldest first. A
+// failed batch is retried later under the same ID and with the same payouts,
+// and ends the pass. It returns the number of payouts settled.
+func (g *ChainGateway) SettleBatches(ctx context.Context) int {
+	if !g.batching() {
+		return 0
+	}
+	g.formBatches(ctx)
+
+	g.mu.Lock()
+	now := g.now()
+	var due []*PayoutBatch
+	for _, batch := range g.batches {
+		if !now.Before(batch.NextAttemptAt) {
+			due = append(due, batch)
+		}
+	}
+	g.mu.Unlock()
+
+	settled := 0
+	for _, batch := range due {
+		if err := g.settleBatch(ctx, batch); err != nil {
+			return settled
+		}
+		settled += len(batch.TaskIDs)
+	}
+	return settled
+}
+
+func (g *ChainGateway) settleBatch(ctx context.Context, batch *PayoutBatch) error {
+	log := gologger.WithComponent("chain")
+
+	callCtx, cancel := context.WithTimeout(ctx, g.config.TransferTimeout)
+	defer cancel()
+
+	g.mu.Lock()
+	batch.Attempts++
+	pending := batch.PendingTx
+	transfers := make([]RewardTransfer, 0, len(batch.payouts))
+	for _, payout := range batch.payouts {
+		payout.Attempts++
+		transfers = append(transfers, RewardTransfer{TaskID: payout.TaskID, DeviceID: payout.DeviceID, Amount: payout.Amount})
+	}
+	g.mu.Unlock()
+
+	var txHash string
+	var err error
+	if resumer, ok := g.chain.(ResumableTransferrer); ok && pending != nil {
+		txHash, err = resumer.ResumeRewardBatch(callCtx, *pending, batch.ID, transfers)
+	} else {
+		txHash, err = g.chain.(BatchTransferrer).TransferRewardBatch(callCtx, batch.ID, transfers)
+	}
+	if errors.Is(err, ErrBatchAlreadyPaid) {
+		// An earlier attempt was mined after it was given up on
+		log.Warn().Str("batch_id", batch.ID).Msg("Payout batch was already paid, settling it")
+		txHash, err = "", nil
+	}
+	g.markResult(err)
+
+	g.mu.Lock()
+	batch.PendingTx = pendingAfter(pending, err)
+	for _, payout := range batch.payouts {
+		payout.PendingTx = batch.PendingTx
+	}
+	g.mu.Unlock()
+
+	if err != nil {
+		g.mu.Lock()
+		batch.LastErr = err.Error()
+		batch.NextAttemptAt = g.now().Add(g.retryBackoff(batch.Attempts))
+		members := append([]*Payout{}, batch.payouts...)
+		for _, payout := range members {
+			payout.LastErr = batch.LastErr
+		}
+		g.mu.Unlock()
+		for _, payout := range members {
+			g.scheduleRetry(ctx, payout)
+		}
+
+		log.Error().
+			Str("batch_id", batch.ID).
+			Int("payouts", len(members)).
+			Float64("total", batch.Total).
+			Int("attempts", batch.Attempts).
+			Err(err).
+			Msg("Payout batch failed, retrying it later")
+		return err
+	}
+
+	g.mu.Lock()
+	for i, pending := range g.batches {
+		if pending == batch {
+			g.batches = append(g.batches[:i], g.batches[i+1:]...)
+			break
+		}
+	}
+	settledAt := g.now()
+	batch.TxHash = txHash
+	batch.SettledAt = &settledAt
+	batch.LastErr = ""
+	settledPayouts := make([]Payout, 0, len(batch.payouts))
+	for _, payout := range batch.payouts {
+		payout.TxHash = txHash
+		settledPayouts = append(settledPayouts, *payout)
+	}
+	record := *batch
+	record.payouts = nil
+	g.settledBatches = append(g.settledBatches, record)
+	if len(g.settledBatches) > maxSettledBatches {
+		g.settledBatches = g.settledBatches[len(g.settledBatches)-maxSettledBatches:]
+	}
+	g.mu.Unlock()
+
+	for _, payout := range settledPayouts {
+		g.unpersist(ctx, payout.TaskID)
+		g.paid(payout)
+	}
+
+	log.Info().
+		Str("batch_id", batch.ID).
+		Str("tx_hash", txHash).
+		Int("payouts", len(settledPayouts)).
+		Float64("total", batch.Total).
+		Int("attempts", batch.Attempts).
+		Msg("Payout batch settled")
+	return nil
+}
+
+// restoreBatchesLocked regroups restored payouts that were already part of a
+// batch under their batch ID
+func (g *ChainGateway) restoreBatchesLocked(payouts []*Payout) {
+	byID := make(map[string]*PayoutBatch)
+	for _, payout := range payouts {
+		batch, ok := byID[payout.BatchID]
+		if !ok {
+			batch = &PayoutBatch{ID: payout.BatchID, CreatedAt: payout.QueuedAt, NextAttemptAt: payout.NextAttemptAt}
+			byID[payout.BatchID] = batch
+			g.batches = append(g.batches, batch)
+		}
+		batch.payouts = append(batch.payouts, payout)
+		batch.TaskIDs = append(batch.TaskIDs, payout.TaskID)
+		batch.Total += payout.Amount
+		batch.Attempts = max(batch.Attempts, payout.Attempts)
+		if payout.PendingTx != nil {
+			batch.PendingTx = payout.PendingTx
+		}
+		if payout.NextAttemptAt.After(batch.NextAttemptAt) {
+			batch.NextAttemptAt = payout.NextAttemptAt
+		}
+	}
+}
+
+// PayoutReconciliation accounts for every reward a gateway was asked to pay
+// since it started: settled, still collecting for a batch, in an outstanding
+// batch or queued for a retry on its own
+type PayoutReconciliation struct {
+	RequestedAmount   float64       `json:"requested_amount"`
+	SettledAmount     float64       `json:"settled_amount"`
+	SettledCount      int           `json:"settled_count"`
+	CollectingAmount  float64       `json:"collecting_amount"`
+	Collecting        int           `json:"collecting"`
+	OutstandingAmount float64       `json:"outstanding_amount"`
+	Outstanding       []PayoutBatch `json:"outstanding_batches"`
+	QueuedAmount      float64       `json:"queued_amount"`
+	Queued            int           `json:"queued"`
+	// Unaccounted is what was requested and is neither settled nor owed. It is
+	// zero unless payouts were lost.
+	Unaccounted float64 `json:"unaccounted"`
+	// DuplicateTaskIDs are tasks whose reward was settled more than once
+	DuplicateTaskIDs []string `json:"duplicate_task_ids,omitempty"`
+	// Settled are the most recent settled batches, newest first
+	Settled    []PayoutBatch `json:"settled_batches"`
+	Reconciled bool          `json:"reconciled"`
+}
+
+// Reconciliation reports whether every requested reward is settled or still
+// owed, and none was settled twice
+func (g *ChainGateway) Reconciliation() PayoutReconciliation {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	report := PayoutReconciliation{
+		RequestedAmount:  g.requestedAmount,
+		SettledAmount:    g.settledAmount,
+		SettledCount:     len(g.settledTasks),
+		Collecting:       len(g.collecting),
+		Queued:           len(g.pending),
+		Outstanding:      make([]PayoutBatch, 0, len(g.batches)),
+		Settled:          make([]PayoutBatch, 0, len(g.settledBatches)),
+		DuplicateTaskIDs: append([]string{}, g.duplicates...),
+	}
+	for _, payout := range g.collecting {
+		report.CollectingAmount += payout.Amount
+	}
+	for _, batch := range g.batches {
+		record := *batch
+		record.payouts = nil
+		report.Outstanding = append(report.Outstanding, record)
+		report.OutstandingAmount += batch.Total
+	}
+	for _, payout := range g.pending {
+		report.QueuedAmount += payout.Amount
+	}
+	for i := len(g.settledBatches) - 1; i >= 0; i-- {
+		report.Settled = append(report.Settled, g.settledBatches[i])
+	}
+
+	report.Unaccounted = report.RequestedAmount - report.SettledAmount - report.CollectingAmount - report.OutstandingAmount - report.QueuedAmount
+	// Amounts are summed in a different order on each side
+	if math.Abs(report.Unaccounted) < 1e-9 {
+		report.Unaccounted = 0
+	}
+	report.Reconciled = report.Unaccounted == 0 && len(report.DuplicateTaskIDs) == 0
+	return report
+}
+
+// recordSettledLocked counts a settled payout for the reconciliation report
+func (g *ChainGateway) recordSettledLocked(payout Payout) {
+	if previous, ok := g.settledTasks[payout.TaskID]; ok {
+		g.duplicates = append(g.duplicates, payout.TaskID)
+		log := gologger.WithComponent("chain")
+		log.Error().
+			Str("task_id", payout.TaskID).
+			Str("tx_hash", payout.TxHash).
+			Str("previous_tx_hash", previous).
+			Msg("Reward settled twice for the same task")
+	}
+	g.settledTasks[payout.TaskID] = payout.TxHash
+	g.settledAmount += payout.Amount
+}
+
+func (c *RunnerController) handlePayoutReconciliation(ctx *gin.Context) {
+	c.mu.RLock()
+	gateway := c.chain
+	settlement := make(map[int64]PayoutReconciliation, len(c.settlementChains))
+	for chainID, chainGateway := range c.settlementChains {
+		settlement[chainID] = chainGateway.Reconciliation()
+	}
+	c.mu.RUnlock()
+
+	if gateway == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Chain integration not configured"})
+		return
+	}
+
+	report := gin.H{"reconciliation": gateway.Reconciliation()}
+	if len(settlement) > 0 {
+		report["settlement_chains"] = settlement
+	}
+	ctx.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// batchPayChain adds batch transfers to fakeChain
+type batchPayChain struct {
+	*fakeChain
+	batchIDs  []string
+	transfers [][]RewardTransfer
+}
+
+func (b *batchPayChain) TransferRewardBatch(ctx context.Context, batchID string, transfers []RewardTransfer) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batchIDs = append(b.batchIDs, batchID)
+	if b.down {
+		return "", errors.New("dial tcp: connection refused")
+	}
+	b.transfers = append(b.transfers, transfers)
+	return fmt.Sprintf("0x%064x", len(b.transfers)), nil
+}
+
+func TestPayoutBatchesRetryUnderTheSameIDAndReconcile(t *testing.T) {
+	chain := &batchPayChain{fakeChain: &fakeChain{}}
+	gateway := NewChainGateway(chain, ChainGatewayConfig{BatchSize: 3, BatchInterval: time.Minute})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	gateway.now = func() time.Time { return now }
+	store := &memoryPayoutStore{payouts: make(map[string]Payout)}
+	ctx := context.Background()
+	if err := gateway.SetPayoutStore(ctx, store); err != nil {
+		t.Fatalf("SetPayoutStore() error = %v", err)
+	}
+
+	for i := 1; i <= 4; i++ {
+		if queued := gateway.Distribute(ctx, Payout{TaskID: fmt.Sprintf("task-%d", i), DeviceID: "device-1", Amount: 0.25}); !queued {
+			t.Fatalf("Distribute() sent payout %d on its own", i)
+		}
+	}
+	select {
+	case <-gateway.flush:
+	default:
+		t.Fatal("a full batch did not ask Run to settle")
+	}
+
+	chain.setDown(true)
+	if settled := gateway.SettleBatches(ctx); settled != 0 {
+		t.Fatalf("SettleBatches() while down settled %d", settled)
+	}
+	report := gateway.Reconciliation()
+	if len(report.Outstanding) != 1 || report.Collecting != 1 || !report.Reconciled {
+		t.Fatalf("Reconciliation() after a failed batch = %+v", report)
+	}
+	if len(gateway.PendingPayouts()) != 4 || len(store.payouts) != 4 {
+		t.Fatalf("pending %d payouts, stored %d; want all 4 owed", len(gateway.PendingPayouts()), len(store.payouts))
+	}
+
+	// After a restart the batch is restored under its ID and the fourth payout
+	// keeps waiting for a batch
+	restarted := NewChainGateway(chain, ChainGatewayConfig{BatchSize: 3, BatchInterval: time.Minute})
+	restarted.now = gateway.now
+	if err := restarted.SetPayoutStore(ctx, store); err != nil {
+		t.Fatalf("SetPayoutStore() error = %v", err)
+	}
+
+	chain.setDown(false)
+	now = now.Add(2 * time.Minute)
+	if settled := restarted.SettleBatches(ctx); settled != 4 {
+		t.Fatalf("SettleBatches() settled %d payouts, want 4", settled)
+	}
+	if len(chain.batchIDs) != 3 || chain.batchIDs[0] != chain.batchIDs[1] {
+		t.Fatalf("batch IDs sent = %v, want the failed batch retried under its ID and then a second batch", chain.batchIDs)
+	}
+	if len(chain.transfers[0]) != 3 || len(chain.transfers[1]) != 1 {
+		t.Fatalf("batches paid %d and %d rewards, want 3 and 1", len(chain.transfers[0]), len(chain.transfers[1]))
+	}
+	if len(store.payouts) != 0 || len(restarted.PendingPayouts()) != 0 {
+		t.Fatalf("%d payouts still stored, %d pending", len(store.payouts), len(restarted.PendingPayouts()))
+	}
+
+	report = restarted.Reconciliation()
+	if !report.Reconciled || report.SettledAmount != 1 || report.RequestedAmount != 1 || len(report.Settled) != 2 {
+		t.Fatalf("Reconciliation() after settling = %+v", report)
+	}
+
+	restarted.paid(Payout{TaskID: "task-2", DeviceID: "device-1", Amount: 0.25, TxHash: "0xdup"})
+	if report := restarted.Reconciliation(); report.Reconciled || len(report.DuplicateTaskIDs) != 1 {
+		t.Fatalf("Reconciliation() with a reward paid twice = %+v", report)
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/benchmark"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+var errStaleBenchmark = errors.New("benchmark report is older than the one on record")
+
+// SetBenchmarkRewardWeighting turns on paying runners the share of each reward
+// their verified benchmark score earns them. Runners without a verified report
+// are paid models.MinRewardWeight of the reward.
+func (c *RunnerController) SetBenchmarkRewardWeighting(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.benchmarkRewards = enabled
+}
+
+// recordBenchmark keeps a runner's benchmark report once it is verified to be
+// signed by the runner's wallet and scored from its measurements. The score is
+// copied into the runner's capability profile, where tasks are matched on it.
+func (c *RunnerController) recordBenchmark(deviceID, walletAddress string, report *models.BenchmarkReport) error {
+	if err := benchmark.Verify(report); err != nil {
+		return err
+	}
+	if report.DeviceID != deviceID {
+		return fmt.Errorf("benchmark report is for device %s", report.DeviceID)
+	}
+	if walletAddress != "" && !strings.EqualFold(report.WalletAddress, walletAddress) {
+		return fmt.Errorf("benchmark report is signed by %s, not the runner wallet %s", report.WalletAddress, walletAddress)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if previous, ok := c.benchmarks[deviceID]; ok && report.MeasuredAt.Before(previous.MeasuredAt) {
+		return errStaleBenchmark
+	}
+	if c.benchmarks == nil {
+		c.benchmarks = make(map[string]*models.BenchmarkReport)
+	}
+	c.benchmarks[deviceID] = report
+	if profile := c.capabilities[deviceID]; profile != nil {
+		scored := *profile
+		scored.BenchmarkScore = report.Scores.Overall
+		c.capabilities[deviceID] = &scored
+	}
+	return nil
+}
+
+// benchmarkScoreLocked is the overall score of the runner's verified report,
+// zero without one
+func (c *RunnerController) benchmarkScoreLocked(deviceID string) float64 {
+	if report := c.benchmarks[deviceID]; report != nil {
+		return report.Scores.Overall
+	}
+	return 0
+}
+
+// rewardWeight is the share of a task's reward the runner is paid
+func (c *RunnerController) rewardWeight(deviceID string) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.benchmarkRewards {
+		return 1
+	}
+	if report := c.benchmarks[deviceID]; report != nil {
+		return report.Scores.RewardWeight()
+	}
+	return models.MinRewardWeight
+}
+
+func (c *RunnerController) handleSubmitBenchmark(ctx *gin.Context) {
+	log := gologger.WithComponent("runner_controller")
+	deviceID := ctx.GetHeader("X-Device-ID")
+
+	var report models.BenchmarkReport
+	if err := ctx.ShouldBindJSON(&report); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid benchmark report"})
+		return
+	}
+
+	var walletAddress string
+	c.mu.RLock()
+	if manifest := c.manifests[deviceID]; manifest != nil {
+		walletAddress = manifest.WalletAddress
+	}
+	c.mu.RUnlock()
+
+	if err := c.recordBenchmark(deviceID, walletAddress, &report); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errStaleBenchmark) {
+			status = http.StatusConflict
+		}
+		log.Warn().Err(err).Str("device_id", deviceID).Msg("Rejected benchmark report")
+		ctx.JSON(status, gin.H{"error": "Invalid benchmark report: " + err.Error()})
+		return
+	}
+
+	log.Info().
+		Str("device_id", deviceID).
+		Float64("score", report.Scores.Overall).
+		Msg("Recorded runner benchmark")
+	ctx.JSON(http.StatusOK, gin.H{"scores": report.Scores, "reward_weight": c.rewardWeight(deviceID)})
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/benchmark"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func signedBenchmark(t *testing.T, key *ecdsa.PrivateKey, deviceID string, multiCoreMBps float64) *models.BenchmarkReport {
+	t.Helper()
+	report := &models.BenchmarkReport{
+		Version:    models.BenchmarkVersion,
+		DeviceID:   deviceID,
+		CPU:        models.CPUBenchmark{Cores: 16, SingleCoreMBps: 600, MultiCoreMBps: multiCoreMBps},
+		Memory:     models.MemoryBenchmark{BufferBytes: 256 << 20, CopyGBps: 12},
+		Disk:       models.DiskBenchmark{FileBytes: 512 << 20, WriteMBps: 450, ReadMBps: 900},
+		MeasuredAt: time.Now().UTC(),
+	}
+	report.Scores = benchmark.Score(report)
+	if err := benchmark.Sign(report, key); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return report
+}
+
+func submitBenchmark(router http.Handler, deviceID string, report *models.BenchmarkReport) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(report)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/benchmarks", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", deviceID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestVerifiedBenchmarkScoreIsMatchedOn(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+	key, _ := crypto.GenerateKey()
+
+	// A self-reported score is ignored
+	registerWithCapabilities(t, router, "fast", &models.CapabilityProfile{CPUCores: 16, BenchmarkScore: 5000})
+	registerWithCapabilities(t, router, "slow", &models.CapabilityProfile{CPUCores: 16})
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	task.Requirements = &models.CapabilityRequirements{MinBenchmarkScore: 1000}
+	controller.AddAvailableTask(task)
+	if tasks := controller.availableTasksFor("fast"); len(tasks) != 0 {
+		t.Fatalf("runner with a self-reported score was offered %d tasks", len(tasks))
+	}
+
+	if rec := submitBenchmark(router, "fast", signedBenchmark(t, key, "fast", 8000)); rec.Code != http.StatusOK {
+		t.Fatalf("submit = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := submitBenchmark(router, "slow", signedBenchmark(t, key, "slow", 1000)); rec.Code != http.StatusOK {
+		t.Fatalf("submit = %d: %s", rec.Code, rec.Body.String())
+	}
+	if tasks := controller.availableTasksFor("fast"); len(tasks) != 1 {
+		t.Fatalf("benchmarked runner was offered %d tasks, want 1", len(tasks))
+	}
+	if tasks := controller.availableTasksFor("slow"); len(tasks) != 0 {
+		t.Fatalf("runner below the score was offered %d tasks", len(tasks))
+	}
+
+	// The verified score outlives the runner registering again
+	registerWithCapabilities(t, router, "fast", &models.CapabilityProfile{CPUCores: 16})
+	runners := controller.Runners(RunnerFilter{Requirements: models.CapabilityRequirements{MinBenchmarkScore: 1000}}, time.Now())
+	if len(runners) != 1 || runners[0].DeviceID != "fast" {
+		t.Fatalf("runners with score >= 1000 = %+v, want only fast", runners)
+	}
+}
+
+func TestBenchmarkSubmissionIsVerified(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+	key, _ := crypto.GenerateKey()
+
+	inflated := signedBenchmark(t, key, "device-1", 4000)
+	inflated.Scores.Overall *= 3
+	if rec := submitBenchmark(router, "device-1", inflated); rec.Code != http.StatusBadRequest {
+		t.Fatalf("report with altered scores = %d, want 400", rec.Code)
+	}
+	if rec := submitBenchmark(router, "device-2", signedBenchmark(t, key, "device-1", 4000)); rec.Code != http.StatusBadRequest {
+		t.Fatalf("another device's report = %d, want 400", rec.Code)
+	}
+
+	older := signedBenchmark(t, key, "device-1", 4000)
+	newer := signedBenchmark(t, key, "device-1", 4000)
+	if rec := submitBenchmark(router, "device-1", newer); rec.Code != http.StatusOK {
+		t.Fatalf("submit = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := submitBenchmark(router, "device-1", older); rec.Code != http.StatusConflict {
+		t.Fatalf("older report = %d, want 409", rec.Code)
+	}
+}
+
+func TestRewardWeightFollowsBenchmark(t *testing.T) {
+	controller := NewRunnerController(nil)
+	key, _ := crypto.GenerateKey()
+	if err := controller.recordBenchmark("slow", "", signedBenchmark(t, key, "slow", 500)); err != nil {
+		t.Fatalf("recordBenchmark() error = %v", err)
+	}
+
+	if weight := controller.rewardWeight("slow"); weight != 1 {
+		t.Fatalf("weight without weighting = %v, want 1", weight)
+	}
+	controller.SetBenchmarkRewardWeighting(true)
+	if weight := controller.rewardWeight("slow"); weight >= 1 || weight <= models.MinRewardWeight {
+		t.Fatalf("weight of a slow runner = %v, want between %v and 1", weight, models.MinRewardWeight)
+	}
+	if weight := controller.rewardWeight("unbenchmarked"); weight != models.MinRewardWeight {
+		t.Fatalf("weight of an unbenchmarked runner = %v, want %v", weight, models.MinRewardWeight)
+	}
+}
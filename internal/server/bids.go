@@ -0,0 +1,206 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+var (
+	errBiddingDisabled = errors.New("bidding is not enabled")
+	errNotOpenForBids  = errors.New("task is not open for bids from this runner")
+	errAuctionClosed   = errors.New("bidding on this task has closed")
+)
+
+// auction collects the bids on one task. It opens with the first bid and
+// closes a bidding window later, when the lowest bid wins. The winner has
+// another window to start the task before anyone may take it.
+type auction struct {
+	closesAt time.Time
+	bids     map[string]models.Bid
+	winner   *models.Bid
+	claimBy  time.Time
+}
+
+// settle picks the winner once the auction has closed: the lowest price, then
+// the earliest bid
+func (a *auction) settle(now time.Time, window time.Duration) {
+	if a.winner != nil || now.Before(a.closesAt) {
+		return
+	}
+	for _, bid := range a.bids {
+		if a.winner == nil || bid.Price < a.winner.Price ||
+			(bid.Price == a.winner.Price && bid.SubmittedAt.Before(a.winner.SubmittedAt)) {
+			winner := bid
+			a.winner = &winner
+		}
+	}
+	a.claimBy = a.closesAt.Add(window)
+}
+
+func (a *auction) statusFor(taskID, deviceID string) models.BidStatus {
+	status := models.BidStatus{
+		TaskID:   taskID,
+		State:    models.BidOpen,
+		Price:    a.bids[deviceID].Price,
+		Bids:     len(a.bids),
+		ClosesAt: a.closesAt,
+	}
+	if a.winner != nil {
+		status.WinningPrice = a.winner.Price
+		status.State = models.BidLost
+		if a.winner.DeviceID == deviceID {
+			status.State = models.BidWon
+		}
+	}
+	return status
+}
+
+// SetBiddingWindow turns on task auctions. Runners may bid on the tasks they
+// are offered for window after the first bid; the lowest bid wins the task and
+// is what its runner is paid. Tasks nobody bids on are taken first come. Zero
+// turns auctions off.
+func (c *RunnerController) SetBiddingWindow(window time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.biddingWindow = window
+}
+
+// submitBid records a runner's bid, replacing its earlier one while the
+// auction is open
+func (c *RunnerController) submitBid(taskID, deviceID string, price float64, now time.Time) (models.BidStatus, error) {
+	var task *models.Task
+	for _, offered := range c.availableTasksFor(deviceID) {
+		if offered.ID.String() == taskID {
+			task = offered
+			break
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	window := c.biddingWindow
+	if window <= 0 {
+		return models.BidStatus{}, errBiddingDisabled
+	}
+	if task == nil {
+		return models.BidStatus{}, errNotOpenForBids
+	}
+	if price <= 0 || price > task.Reward {
+		return models.BidStatus{}, fmt.Errorf("bid must be above 0 and at most the reward of %.8f", task.Reward)
+	}
+
+	if c.auctions == nil {
+		c.auctions = make(map[string]*auction)
+	}
+	a, ok := c.auctions[taskID]
+	if !ok {
+		a = &auction{closesAt: now.Add(window), bids: make(map[string]models.Bid)}
+		c.auctions[taskID] = a
+	}
+	a.settle(now, window)
+	if a.winner != nil {
+		return a.statusFor(taskID, deviceID), errAuctionClosed
+	}
+
+	a.bids[deviceID] = models.Bid{TaskID: taskID, DeviceID: deviceID, Price: price, SubmittedAt: now.UTC()}
+	return a.statusFor(taskID, deviceID), nil
+}
+
+// bidStatus reports the auction on a task to one of its bidders
+func (c *RunnerController) bidStatus(taskID, deviceID string, now time.Time) (models.BidStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.auctions[taskID]
+	if !ok {
+		return models.BidStatus{}, false
+	}
+	if _, bid := a.bids[deviceID]; !bid {
+		return models.BidStatus{}, false
+	}
+	a.settle(now, c.biddingWindow)
+	return a.statusFor(taskID, deviceID), true
+}
+
+// checkAward refuses to start a task that is being auctioned, or that was won
+// by another runner which still has time to start it. Like startTask it returns
+// the HTTP status to refuse the start with, or 0.
+func (c *RunnerController) checkAward(taskID, deviceID string, now time.Time) (int, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.auctions[taskID]
+	if !ok {
+		return 0, ""
+	}
+	a.settle(now, c.biddingWindow)
+	switch {
+	case a.winner == nil:
+		return http.StatusConflict, "Task is being auctioned until " + a.closesAt.UTC().Format(time.RFC3339)
+	case a.winner.DeviceID == deviceID:
+		return 0, ""
+	case now.After(a.claimBy):
+		// The winner never started it, so the task goes to whoever takes it
+		delete(c.auctions, taskID)
+		return 0, ""
+	default:
+		return http.StatusConflict, "Task was awarded to another runner"
+	}
+}
+
+// awardedReward is what the runner is paid for a task: its winning bid, or the
+// task's reward when the task was not auctioned. The auction is done with.
+func (c *RunnerController) awardedReward(taskID, deviceID string, reward float64) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.auctions[taskID]
+	if !ok {
+		return reward
+	}
+	delete(c.auctions, taskID)
+	if a.winner != nil && a.winner.DeviceID == deviceID {
+		return a.winner.Price
+	}
+	return reward
+}
+
+func (c *RunnerController) handleSubmitBid(ctx *gin.Context) {
+	log := gologger.WithComponent("runner_controller")
+
+	var req struct {
+		Price float64 `json:"price"`
+	}
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	taskID := ctx.Param("taskID")
+	deviceID := ctx.GetHeader("X-Device-ID")
+	status, err := c.submitBid(taskID, deviceID, req.Price, time.Now())
+	switch {
+	case errors.Is(err, errBiddingDisabled), errors.Is(err, errNotOpenForBids):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errAuctionClosed):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": status})
+	case err != nil:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Debug().Str("task_id", taskID).Str("device_id", deviceID).Float64("price", req.Price).Msg("Bid received")
+		ctx.JSON(http.StatusOK, status)
+	}
+}
+
+func (c *RunnerController) handleGetBid(ctx *gin.Context) {
+	status, ok := c.bidStatus(ctx.Param("taskID"), ctx.GetHeader("X-Device-ID"), time.Now())
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No bid from this runner on the task"})
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
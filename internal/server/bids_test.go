@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestLowestBidWinsTheTask(t *testing.T) {
+	controller := NewRunnerController(nil)
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	task.Reward = 1
+	controller.AddAvailableTask(task)
+	taskID := task.ID.String()
+	now := time.Now()
+
+	if _, err := controller.submitBid(taskID, "cheap", 0.4, now); !errors.Is(err, errBiddingDisabled) {
+		t.Fatalf("submitBid() without bidding = %v, want it disabled", err)
+	}
+	controller.SetBiddingWindow(10 * time.Second)
+
+	if _, err := controller.submitBid(taskID, "greedy", 1.5, now); err == nil {
+		t.Fatal("submitBid() accepted a bid above the reward")
+	}
+	for device, price := range map[string]float64{"cheap": 0.6, "dear": 0.8} {
+		if status, err := controller.submitBid(taskID, device, price, now); err != nil || status.State != models.BidOpen {
+			t.Fatalf("submitBid(%s) = %+v, %v", device, status, err)
+		}
+	}
+	// A runner may lower its bid while the auction is open
+	if status, err := controller.submitBid(taskID, "dear", 0.5, now.Add(time.Second)); err != nil || status.Bids != 2 {
+		t.Fatalf("lowered bid = %+v, %v", status, err)
+	}
+
+	if status, _ := controller.checkAward(taskID, "anyone", now.Add(5*time.Second)); status != http.StatusConflict {
+		t.Fatalf("start during the auction = %d, want 409", status)
+	}
+
+	closed := now.Add(11 * time.Second)
+	if status, ok := controller.bidStatus(taskID, "dear", closed); !ok || status.State != models.BidWon || status.WinningPrice != 0.5 {
+		t.Fatalf("winner's status = %+v, want won at 0.5", status)
+	}
+	if status, _ := controller.bidStatus(taskID, "cheap", closed); status.State != models.BidLost {
+		t.Fatalf("loser's status = %+v, want lost", status)
+	}
+	if _, err := controller.submitBid(taskID, "late", 0.1, closed); !errors.Is(err, errAuctionClosed) {
+		t.Fatalf("bid after the close = %v, want it refused", err)
+	}
+
+	if status, _ := controller.checkAward(taskID, "cheap", closed); status != http.StatusConflict {
+		t.Fatalf("start by the loser = %d, want 409", status)
+	}
+	if status, _ := controller.checkAward(taskID, "dear", closed); status != 0 {
+		t.Fatalf("start by the winner = %d, want allowed", status)
+	}
+	if reward := controller.awardedReward(taskID, "dear", task.Reward); reward != 0.5 {
+		t.Fatalf("winner is paid %v, want its bid of 0.5", reward)
+	}
+}
+
+func TestUnclaimedAwardLapses(t *testing.T) {
+	controller := NewRunnerController(nil)
+	controller.SetBiddingWindow(10 * time.Second)
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	task.Reward = 1
+	controller.AddAvailableTask(task)
+	now := time.Now()
+
+	if _, err := controller.submitBid(task.ID.String(), "winner", 0.5, now); err != nil {
+		t.Fatalf("submitBid() error = %v", err)
+	}
+	if status, _ := controller.checkAward(task.ID.String(), "other", now.Add(15*time.Second)); status != http.StatusConflict {
+		t.Fatalf("start while the winner may still start = %d, want 409", status)
+	}
+	if status, _ := controller.checkAward(task.ID.String(), "other", now.Add(21*time.Second)); status != 0 {
+		t.Fatalf("start after the award lapsed = %d, want allowed", status)
+	}
+	if reward := controller.awardedReward(task.ID.String(), "other", task.Reward); reward != 1 {
+		t.Fatalf("runner taking a lapsed award is paid %v, want the reward", reward)
+	}
+}
+
+func TestBidEndpoints(t *testing.T) {
+	controller := NewRunnerController(nil)
+	controller.SetBiddingWindow(time.Minute)
+	router := newTestRouter(controller)
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	task.Reward = 1
+	controller.AddAvailableTask(task)
+
+	bid := func(taskID string, price float64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]float64{"price": price})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+taskID+"/bids", bytes.NewReader(body))
+		req.Header.Set("X-Device-ID", "device-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := bid(task.ID.String(), 0.7); rec.Code != http.StatusOK {
+		t.Fatalf("bid = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := bid(models.NewTask().ID.String(), 0.7); rec.Code != http.StatusNotFound {
+		t.Fatalf("bid on an unknown task = %d, want 404", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/runners/tasks/"+task.ID.String()+"/bids", nil)
+	req.Header.Set("X-Device-ID", "device-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var status models.BidStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || status.State != models.BidOpen || status.Price != 0.7 {
+		t.Fatalf("bid status = %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,361 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// CanaryNonce is replaced with the canary's nonce in the Config and
+// ExpectedOutput of LLM canary templates
+const CanaryNonce = "{{nonce}}"
+
+// CanaryTemplate describes a small known-answer Docker or LLM task injected into
+// the normal task flow. Every injection gets a fresh nonce, so an answer cannot
+// be replayed. Docker runners write the nonce in front of the output the result
+// hash covers, so the expected hash is computed per canary from ExpectedOutput.
+// LLM canaries carry the nonce in their prompt through CanaryNonce, and pass
+// when the response contains ExpectedOutput with the nonce filled in.
+type CanaryTemplate struct {
+	Title       string
+	Type        models.TaskType
+	Config      json.RawMessage
+	Environment *models.EnvironmentConfig
+	// ExpectedOutput is what the task prints, without the nonce line
+	ExpectedOutput string
+}
+
+// activeCanary is an injected canary awaiting its result. Docker canaries are
+// checked against expectedHash, LLM canaries against expectedOutput.
+type activeCanary struct {
+	expectedHash   string
+	expectedOutput string
+	expiresAt      time.Time
+}
+
+// check returns why result fails the canary, or an empty string when it passes
+func (c activeCanary) check(result *models.TaskResult) string {
+	if c.expectedOutput != "" {
+		if result.ExitCode != 0 || !strings.Contains(result.Output, c.expectedOutput) {
+			return fmt.Sprintf("canary response does not contain the expected answer %q", c.expectedOutput)
+		}
+		return ""
+	}
+	if result.ResultHash != c.expectedHash || result.ExitCode != 0 {
+		return fmt.Sprintf("canary result hash mismatch: expected %s, got %s", c.expectedHash, result.ResultHash)
+	}
+	return ""
+}
+
+// ReputationPenalizer applies reputation penalties to runners that fail canaries
+type ReputationPenalizer interface {
+	Penalize(deviceID string, points int, reason string)
+}
+
+type CanaryAlertFunc func(deviceID, taskID, reason string)
+
+type CanaryConfig struct {
+	Interval      time.Duration
+	PenaltyPoints int
+	Templates     []CanaryTemplate
+	// Expiry is how long an injected canary waits for its result before it is
+	// withdrawn, an hour by default
+	Expiry    time.Duration
+	Penalizer ReputationPenalizer
+	Alert     CanaryAlertFunc
+	// Quarantine, when set, quarantines runners that keep failing canaries
+	Quarantine *QuarantineConfig
+}
+
+// CanaryMonitor injects canary tasks and checks their results. It is registered as a
+// result hook so failed canaries also veto payout.
+type CanaryMonitor struct {
+	controller *RunnerController
+	config     CanaryConfig
+	active     map[string]activeCanary
+	failures   map[string]int
+	quarantine *Quarantine
+	next       int
+	mu         sync.Mutex
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+}
+
+func (t CanaryTemplate) Validate() error {
+	switch t.Type {
+	case models.TaskTypeDocker:
+		return nil
+	case models.TaskTypeLLM:
+		// Without the nonce in the answer, a runner could send a stored answer
+		// without running the model
+		if !strings.Contains(t.ExpectedOutput, CanaryNonce) || !strings.Contains(string(t.Config), CanaryNonce) {
+			return fmt.Errorf("llm canary template %q must use %s in its prompt and expected output", t.Title, CanaryNonce)
+		}
+		return nil
+	}
+	return fmt.Errorf("canary template %q must be a docker or llm task, whose answer can be checked", t.Title)
+}
+
+// active is what a canary of the template with nonce is checked against
+func (t CanaryTemplate) active(nonce string, expiresAt time.Time) activeCanary {
+	if t.Type == models.TaskTypeLLM {
+		return activeCanary{expectedOutput: strings.ReplaceAll(t.ExpectedOutput, CanaryNonce, nonce), expiresAt: expiresAt}
+	}
+	return activeCanary{expectedHash: t.expectedHash(nonce), expiresAt: expiresAt}
+}
+
+// expectedHash is the result hash of a runner that printed the expected output
+// for a canary with nonce
+func (t CanaryTemplate) expectedHash(nonce string) string {
+	return utils.ComputeResultHash(fmt.Sprintf("NONCE: %s\n%s", nonce, t.ExpectedOutput), "", 0)
+}
+
+// CanaryTemplatesFromConfig builds a Docker canary running cfg.DockerImage and
+// an LLM canary asking cfg.LLMModel to repeat its nonce. Either is left out
+// when its setting is empty.
+func CanaryTemplatesFromConfig(cfg config.CanaryConfig) []CanaryTemplate {
+	var templates []CanaryTemplate
+	if cfg.DockerImage != "" {
+		taskConfig, _ := json.Marshal(map[string]interface{}{
+			"image_name": cfg.DockerImage,
+			"command":    []string{"echo", "parity-canary"},
+		})
+		templates = append(templates, CanaryTemplate{
+			Title:          "canary",
+			Type:           models.TaskTypeDocker,
+			Config:         taskConfig,
+			Environment:    &models.EnvironmentConfig{Type: models.EnvironmentTypeDocker},
+			ExpectedOutput: "parity-canary\n",
+		})
+	}
+	if cfg.LLMModel != "" {
+		taskConfig, _ := json.Marshal(map[string]string{
+			"model":  cfg.LLMModel,
+			"prompt": "Reply with this code and nothing else: " + CanaryNonce,
+		})
+		templates = append(templates, CanaryTemplate{
+			Title:          "canary",
+			Type:           models.TaskTypeLLM,
+			Config:         taskConfig,
+			ExpectedOutput: CanaryNonce,
+		})
+	}
+	return templates
+}
+
+// QuarantineFromConfig converts the SERVER_QUARANTINE_* settings, nil when
+// runners are not quarantined
+func QuarantineFromConfig(cfg config.QuarantineConfig) *QuarantineConfig {
+	if cfg.Threshold <= 0 {
+		return nil
+	}
+	return &QuarantineConfig{
+		Threshold:         cfg.Threshold,
+		Window:            cfg.Window,
+		Probation:         cfg.Probation,
+		ProbationCanaries: cfg.ProbationCanaries,
+	}
+}
+
+func NewCanaryMonitor(controller *RunnerController, config CanaryConfig) (*CanaryMonitor, error) {
+	for _, template := range config.Templates {
+		if err := template.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.Interval <= 0 {
+		config.Interval = 15 * time.Minute
+	}
+	if config.PenaltyPoints <= 0 {
+		config.PenaltyPoints = 10
+	}
+	if config.Expiry <= 0 {
+		config.Expiry = time.Hour
+	}
+
+	monitor := &CanaryMonitor{
+		controller: controller,
+		config:     config,
+		active:     make(map[string]activeCanary),
+		failures:   make(map[string]int),
+		stopCh:     make(chan struct{}),
+	}
+	controller.RegisterResultHook(monitor)
+	if config.Quarantine != nil {
+		monitor.quarantine = newQuarantine(*config.Quarantine)
+		controller.mu.Lock()
+		controller.quarantine = monitor.quarantine
+		controller.canaries = monitor
+		controller.mu.Unlock()
+	}
+	return monitor, nil
+}
+
+func (m *CanaryMonitor) Start() {
+	go func() {
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := m.Inject(); err != nil {
+					log := gologger.WithComponent("canary")
+					log.Error().Err(err).Msg("Failed to inject canary task")
+				}
+			case <-m.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (m *CanaryMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+}
+
+// Inject adds the next canary template to the available task pool
+func (m *CanaryMonitor) Inject() (*models.Task, error) {
+	m.mu.Lock()
+	if len(m.config.Templates) == 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("no canary templates configured")
+	}
+	template := m.config.Templates[m.next%len(m.config.Templates)]
+	m.next++
+	m.mu.Unlock()
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate canary nonce: %w", err)
+	}
+
+	task := models.NewTask()
+	task.Title = template.Title
+	task.Type = template.Type
+	task.Environment = template.Environment
+	task.Nonce = hex.EncodeToString(nonce)
+	task.Config = template.Config
+	if template.Type == models.TaskTypeLLM {
+		task.Config = json.RawMessage(strings.ReplaceAll(string(template.Config), CanaryNonce, task.Nonce))
+	}
+
+	now := time.Now()
+	m.expire(now)
+	m.mu.Lock()
+	m.active[task.ID.String()] = template.active(task.Nonce, now.Add(m.config.Expiry))
+	m.mu.Unlock()
+
+	m.controller.AddAvailableTask(task)
+
+	log := gologger.WithComponent("canary")
+	log.Debug().
+		Str("task_id", task.ID.String()).
+		Str("type", string(task.Type)).
+		Msg("Injected canary task")
+
+	return task, nil
+}
+
+// expire withdraws canaries that got no result within the expiry, so they are
+// neither offered any more nor kept around
+func (m *CanaryMonitor) expire(now time.Time) {
+	var expired []string
+	m.mu.Lock()
+	for taskID, canary := range m.active {
+		if now.After(canary.expiresAt) {
+			delete(m.active, taskID)
+			expired = append(expired, taskID)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, taskID := range expired {
+		m.controller.RemoveAvailableTask(taskID)
+	}
+}
+
+func (m *CanaryMonitor) Name() string {
+	return "canary"
+}
+
+// AfterResultSaved checks the result of a canary. The result's DeviceID is the
+// device that submitted it, which the controller binds to the X-Device-ID header
+// before any hook runs, so penalties cannot be pointed at another runner.
+func (m *CanaryMonitor) AfterResultSaved(ctx context.Context, result *models.TaskResult) (ResultHookOutcome, error) {
+	taskID := result.TaskID.String()
+
+	m.mu.Lock()
+	canary, ok := m.active[taskID]
+	if ok {
+		delete(m.active, taskID)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return ResultHookOutcome{}, nil
+	}
+
+	reason := canary.check(result)
+	if reason == "" {
+		if m.quarantine != nil {
+			m.quarantine.RecordPass(result.DeviceID, time.Now())
+		}
+		return ResultHookOutcome{}, nil
+	}
+
+	m.mu.Lock()
+	m.failures[result.DeviceID]++
+	failures := m.failures[result.DeviceID]
+	m.mu.Unlock()
+
+	log := gologger.WithComponent("canary")
+	log.Warn().
+		Str("task_id", taskID).
+		Str("device_id", result.DeviceID).
+		Int("failures", failures).
+		Msg("Runner failed canary task")
+
+	if m.config.Penalizer != nil {
+		m.config.Penalizer.Penalize(result.DeviceID, m.config.PenaltyPoints, reason)
+	}
+	if m.config.Alert != nil {
+		m.config.Alert(result.DeviceID, taskID, reason)
+	}
+	if m.quarantine != nil && m.quarantine.RecordFailure(result.DeviceID, reason, time.Now()) {
+		if record, ok := m.quarantine.Get(result.DeviceID); ok {
+			m.controller.notifyQuarantinedRunner(record)
+		}
+		// Give the runner a canary to start its probation with
+		if _, err := m.Inject(); err != nil {
+			log.Error().Err(err).Msg("Failed to inject probation canary")
+		}
+	}
+
+	return ResultHookOutcome{Veto: true, Reason: reason}, nil
+}
+
+func (m *CanaryMonitor) Failures(deviceID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.failures[deviceID]
+}
+
+// IsCanary reports whether a task was injected by the monitor and is still awaiting its result
+func (m *CanaryMonitor) IsCanary(taskID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	canary, ok := m.active[taskID]
+	return ok && !time.Now().After(canary.expiresAt)
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+var (
+	errTaskNotFound = errors.New("task not found")
+	errTaskFinished = errors.New("task already finished")
+	errGangCancel   = errors.New("members of a gang task cannot be cancelled")
+)
+
+// cancelledReason is what the result of a task withdrawn from the queue says
+const cancelledReason = "task cancelled by its creator"
+
+// CancelTask stops a task for the device that created it. A queued task is
+// withdrawn and finished as cancelled at once. The runner of a running task is
+// told to stop it, and the task finishes as cancelled when the runner reports
+// back with what it did until then.
+func (c *RunnerController) CancelTask(taskID, requesterID string, now time.Time) (*models.Task, error) {
+	c.mu.Lock()
+	_, finished := c.results[taskID]
+	assigned, running := c.assigned[taskID]
+	queued := c.findAvailableLocked(taskID)
+	switch {
+	case finished:
+		c.mu.Unlock()
+		return nil, errTaskFinished
+	case running:
+		if requesterID == "" || requesterID != assigned.task.CreatorDeviceID {
+			c.mu.Unlock()
+			return nil, errNotTaskCreator
+		}
+		if c.gangRuns[taskID] != nil {
+			c.mu.Unlock()
+			return nil, errGangCancel
+		}
+	case queued != nil:
+		if requesterID == "" || requesterID != queued.CreatorDeviceID {
+			c.mu.Unlock()
+			return nil, errNotTaskCreator
+		}
+		for i, task := range c.availableTasks {
+			if task == queued {
+				c.availableTasks = append(c.availableTasks[:i], c.availableTasks[i+1:]...)
+				break
+			}
+		}
+		c.results[taskID] = &models.TaskResult{
+			TaskID:    queued.ID,
+			Status:    models.TaskStatusCancelled,
+			Error:     cancelledReason,
+			CreatedAt: now.UTC(),
+		}
+	default:
+		c.mu.Unlock()
+		return nil, errTaskNotFound
+	}
+	depth := len(c.availableTasks)
+	c.mu.Unlock()
+
+	log := gologger.WithComponent("runner_controller")
+	if running {
+		c.recordEvent(taskID, models.TaskEvent{
+			Type:     models.TaskEventCancelled,
+			Time:     now.UTC(),
+			DeviceID: assigned.deviceID,
+			Detail:   "runner told to stop the task",
+		})
+		c.cancelRunningTask(taskID, assigned.deviceID)
+		log.Info().Str("task_id", taskID).Str("device_id", assigned.deviceID).Msg("Task creator cancelled a running task")
+	} else {
+		c.recordQueueDepth(depth)
+		c.recordEvent(taskID, models.TaskEvent{
+			Type:   models.TaskEventCancelled,
+			Time:   now.UTC(),
+			Detail: "withdrawn from the queue",
+		})
+		c.finishTaskLogs(taskID)
+		log.Info().Str("task_id", taskID).Msg("Task creator cancelled a queued task")
+	}
+
+	task, _ := c.TaskFor(taskID, requesterID)
+	return task, nil
+}
+
+// cancelRunningTask tells a runner with a registered webhook to stop a task
+// its creator cancelled
+func (c *RunnerController) cancelRunningTask(taskID, deviceID string) {
+	body, err := json.Marshal(map[string]interface{}{
+		"type": "cancel",
+		"payload": map[string]string{
+			"task_id": taskID,
+			"reason":  cancelledReason,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		req, err := c.NewRunnerWebhookRequest(ctx, deviceID, body)
+		if err != nil {
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log := gologger.WithComponent("runner_controller")
+			log.Warn().Err(err).Str("task_id", taskID).Str("device_id", deviceID).Msg("Failed to tell runner its task was cancelled")
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+func (c *RunnerController) handleCancelTask(ctx *gin.Context) {
+	task, err := c.CancelTask(ctx.Param("taskID"), ctx.GetHeader("X-Device-ID"), time.Now())
+	switch {
+	case errors.Is(err, errTaskNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errNotTaskCreator):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusOK, task)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestCancelQueuedTaskWithdrawsIt(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	task.CreatorDeviceID = "creator"
+	controller.AddAvailableTask(task)
+	taskID := task.ID.String()
+
+	cancel := func(requester string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/"+taskID+"/cancel", nil)
+		req.Header.Set("X-Device-ID", requester)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := cancel("someone-else"); rec.Code != http.StatusForbidden {
+		t.Fatalf("cancel by another device = %d, want 403", rec.Code)
+	}
+	rec := cancel("creator")
+	var cancelled models.Task
+	if err := json.Unmarshal(rec.Body.Bytes(), &cancelled); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("cancel = %d %s", rec.Code, rec.Body.String())
+	}
+	if cancelled.Status != models.TaskStatusCancelled {
+		t.Fatalf("cancelled task status = %s, want cancelled", cancelled.Status)
+	}
+	if tasks := controller.availableTasksFor("device-1"); len(tasks) != 0 {
+		t.Fatalf("cancelled task is still offered: %d tasks", len(tasks))
+	}
+	if rec := cancel("creator"); rec.Code != http.StatusConflict {
+		t.Fatalf("cancelling again = %d, want 409", rec.Code)
+	}
+}
+
+func TestCancelRunningTaskTellsItsRunner(t *testing.T) {
+	messages := make(chan map[string]interface{}, 1)
+	runner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var message map[string]interface{}
+		json.Unmarshal(body, &message)
+		messages <- message
+	}))
+	defer runner.Close()
+
+	controller := NewRunnerController(nil)
+	controller.runnerWebhooks["device-1"] = RunnerWebhook{URL: runner.URL}
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	task.CreatorDeviceID = "creator"
+	controller.AddAvailableTask(task)
+	taskID := task.ID.String()
+	if status, message := controller.startTask(context.Background(), taskID, "device-1"); status != 0 {
+		t.Fatalf("startTask() = %d %s", status, message)
+	}
+
+	if _, err := controller.CancelTask(taskID, "device-1", time.Now()); err != errNotTaskCreator {
+		t.Fatalf("cancel by the runner = %v, want errNotTaskCreator", err)
+	}
+	running, err := controller.CancelTask(taskID, "creator", time.Now())
+	if err != nil {
+		t.Fatalf("CancelTask() = %v", err)
+	}
+	if running.Status != models.TaskStatusRunning {
+		t.Fatalf("task status = %s, want running until the runner reports", running.Status)
+	}
+
+	select {
+	case message := <-messages:
+		payload, _ := message["payload"].(map[string]interface{})
+		if message["type"] != "cancel" || payload["task_id"] != taskID {
+			t.Fatalf("runner got %v, want a cancel message for the task", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner was not told to cancel the task")
+	}
+
+	outcome, err := controller.submitTaskResult(context.Background(), &models.TaskResult{
+		TaskID:   task.ID,
+		DeviceID: "device-1",
+		Status:   models.TaskStatusCancelled,
+		Error:    "task cancelled by its creator",
+	})
+	if err != nil {
+		t.Fatalf("submitTaskResult() = %v", err)
+	}
+	if outcome.PayoutApproved {
+		t.Fatal("a cancelled task was paid out")
+	}
+	if finished, _ := controller.TaskFor(taskID, "creator"); finished.Status != models.TaskStatusCancelled {
+		t.Fatalf("task status = %s, want cancelled", finished.Status)
+	}
+
+	var types []models.TaskEventType
+	for _, event := range controller.TaskEvents(taskID) {
+		types = append(types, event.Type)
+	}
+	if len(types) != 4 || types[2] != models.TaskEventCancelled {
+		t.Fatalf("events = %v, want cancelled between started and result_submitted", types)
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	defaultBandwidthBytes = 8 << 20
+	maxBandwidthBytes     = 64 << 20
+	bytesPerGB            = 1 << 30
+)
+
+// recordCapabilities keeps the capability profile a runner registered with. A
+// registration without one keeps the profile on record. The profile's benchmark
+// score is replaced with that of the runner's verified report.
+func (c *RunnerController) recordCapabilities(deviceID string, profile *models.CapabilityProfile) {
+	if profile == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	profile.BenchmarkScore = c.benchmarkScoreLocked(deviceID)
+	c.capabilities[deviceID] = profile
+}
+
+// capabilitiesAllow reports whether a runner with the profile can be given the
+// task. Runners without a profile only get tasks without requirements.
+func capabilitiesAllow(profile *models.CapabilityProfile, task *models.Task) bool {
+	if task.Requirements != nil && !task.Requirements.SatisfiedBy(profile) {
+		return false
+	}
+	return profile == nil || len(profile.TaskTypes) == 0 || profile.Supports(task.Type)
+}
+
+// RunnerFilter selects runners by what they offer. Zero fields match every
+// runner.
+type RunnerFilter struct {
+	Requirements   models.CapabilityRequirements
+	TaskType       models.TaskType
+	GPU            bool
+	MinSuccessRate float64
+}
+
+// Matches reports whether the runner passes the filter. Runners without a
+// capability profile only pass filters that ask nothing of it.
+func (f RunnerFilter) Matches(runner models.RunnerSummary) bool {
+	if runner.Reputation.SuccessRate < f.MinSuccessRate {
+		return false
+	}
+	asksCapabilities := f.Requirements != (models.CapabilityRequirements{}) || f.TaskType != "" || f.GPU
+	if !asksCapabilities {
+		return true
+	}
+	profile := runner.Capabilities
+	if profile == nil || !f.Requirements.SatisfiedBy(profile) {
+		return false
+	}
+	if f.TaskType != "" && !profile.Supports(f.TaskType) {
+		return false
+	}
+	return !f.GPU || len(profile.GPUs) > 0
+}
+
+// Runners lists the runners that registered or heartbeated, with their
+// capability profile and reputation, ordered by device ID
+func (c *RunnerController) Runners(filter RunnerFilter, now time.Time) []models.RunnerSummary {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	reputations := c.reputationsLocked()
+
+	devices := make(map[string]bool)
+	for deviceID := range c.runnerWebhooks {
+		devices[deviceID] = true
+	}
+	for deviceID := range c.capabilities {
+		devices[deviceID] = true
+	}
+	for deviceID := range c.lastHeartbeat {
+		devices[deviceID] = true
+	}
+
+	runners := make([]models.RunnerSummary, 0, len(devices))
+	for deviceID := range devices {
+		runner := models.RunnerSummary{DeviceID: deviceID, Capabilities: c.capabilities[deviceID]}
+		if seen, ok := c.lastHeartbeat[deviceID]; ok && now.Sub(seen) <= runnerHeartbeatTimeout {
+			runner.Online = true
+		}
+		runner.Reputation = reputations[deviceID]
+		runner.Reputation.Quarantined = c.quarantine != nil && c.quarantine.IsQuarantined(deviceID)
+		if filter.Matches(runner) {
+			runners = append(runners, runner)
+		}
+	}
+	sort.Slice(runners, func(i, j int) bool { return runners[i].DeviceID < runners[j].DeviceID })
+	return runners
+}
+
+// reputationsLocked counts the results each runner reported. Quarantine is left
+// to the caller, as it is only needed for some runners.
+func (c *RunnerController) reputationsLocked() map[string]models.RunnerReputation {
+	reputations := make(map[string]models.RunnerReputation)
+	for _, result := range c.results {
+		if result.DeviceID == "" {
+			continue
+		}
+		reputation := reputations[result.DeviceID]
+		if result.ExitCode == 0 && result.Error == "" {
+			reputation.Completed++
+		} else {
+			reputation.Failed++
+		}
+		reputation.SuccessRate = float64(reputation.Completed) / float64(reputation.Completed+reputation.Failed)
+		reputations[result.DeviceID] = reputation
+	}
+	return reputations
+}
+
+// parseRunnerFilter reads a RunnerFilter from the query parameters min_cpu_cores,
+// min_memory_gb, min_disk_gb, min_bandwidth_mbps, min_benchmark_score,
+// task_type, gpu and min_success_rate
+func parseRunnerFilter(ctx *gin.Context) (RunnerFilter, string) {
+	var filter RunnerFilter
+	number := func(name string) (float64, bool) {
+		raw := ctx.Query(name)
+		if raw == "" {
+			return 0, true
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		return value, err == nil && value >= 0
+	}
+
+	cores, ok := number("min_cpu_cores")
+	if !ok {
+		return filter, "Invalid min_cpu_cores"
+	}
+	memory, ok := number("min_memory_gb")
+	if !ok {
+		return filter, "Invalid min_memory_gb"
+	}
+	disk, ok := number("min_disk_gb")
+	if !ok {
+		return filter, "Invalid min_disk_gb"
+	}
+	bandwidth, ok := number("min_bandwidth_mbps")
+	if !ok {
+		return filter, "Invalid min_bandwidth_mbps"
+	}
+	score, ok := number("min_benchmark_score")
+	if !ok {
+		return filter, "Invalid min_benchmark_score"
+	}
+	successRate, ok := number("min_success_rate")
+	if !ok || successRate > 1 {
+		return filter, "Invalid min_success_rate, want a fraction between 0 and 1"
+	}
+	if raw := ctx.Query("gpu"); raw != "" {
+		gpu, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, "Invalid gpu"
+		}
+		filter.GPU = gpu
+	}
+
+	filter.Requirements = models.CapabilityRequirements{
+		MinCPUCores:       int(cores),
+		MinMemoryBytes:    int64(memory * bytesPerGB),
+		MinDiskFreeBytes:  int64(disk * bytesPerGB),
+		MinBandwidthMbps:  bandwidth,
+		MinBenchmarkScore: score,
+	}
+	filter.TaskType = models.TaskType(ctx.Query("task_type"))
+	filter.MinSuccessRate = successRate
+	return filter, ""
+}
+
+func (c *RunnerController) handleListRunners(ctx *gin.Context) {
+	filter, problem := parseRunnerFilter(ctx)
+	if problem != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": problem})
+		return
+	}
+	ctx.JSON(http.StatusOK, c.Runners(filter, time.Now()))
+}
+
+// handleBandwidth sends the number of bytes asked for, so runners can time the
+// download to measure their bandwidth
+func (c *RunnerController) handleBandwidth(ctx *gin.Context) {
+	size := int64(defaultBandwidthBytes)
+	if raw := ctx.Query("bytes"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed <= 0 || parsed > maxBandwidthBytes {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "bytes must be between 1 and " + strconv.Itoa(maxBandwidthBytes)})
+			return
+		}
+		size = parsed
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.DataFromReader(http.StatusOK, size, "application/octet-stream", io.LimitReader(zeros{}, size), nil)
+}
+
+// zeros reads an endless run of zero bytes
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
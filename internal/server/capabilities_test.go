@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func registerWithCapabilities(t *testing.T, router http.Handler, deviceID string, profile *models.CapabilityProfile) {
+	t.Helper()
+	body, _ := json.Marshal(models.RunnerRegistration{
+		WalletAddress: "0x0000000000000000000000000000000000000001",
+		Status:        models.RunnerStatusOnline,
+		Capabilities:  profile,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", deviceID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("registration = %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTasksAreMatchedToCapabilities(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	registerWithCapabilities(t, router, "large", &models.CapabilityProfile{
+		CPUCores:      32,
+		MemoryBytes:   128 << 30,
+		DiskFreeBytes: 1 << 40,
+		BandwidthMbps: 900,
+		TaskTypes:     []models.TaskType{models.TaskTypeDocker, models.TaskTypeCommand},
+	})
+	registerWithCapabilities(t, router, "small", &models.CapabilityProfile{
+		CPUCores:      2,
+		MemoryBytes:   4 << 30,
+		DiskFreeBytes: 10 << 30,
+		TaskTypes:     []models.TaskType{models.TaskTypeDocker},
+	})
+	registerWithCapabilities(t, router, "unprofiled", nil)
+
+	heavy := models.NewTask()
+	heavy.Type = models.TaskTypeDocker
+	heavy.Requirements = &models.CapabilityRequirements{MinCPUCores: 16, MinMemoryBytes: 64 << 30}
+	controller.AddAvailableTask(heavy)
+	command := models.NewTask()
+	command.Type = models.TaskTypeCommand
+	controller.AddAvailableTask(command)
+
+	if tasks := controller.availableTasksFor("large"); len(tasks) != 2 {
+		t.Fatalf("large runner was offered %d tasks, want 2", len(tasks))
+	}
+	if tasks := controller.availableTasksFor("small"); len(tasks) != 0 {
+		t.Fatalf("small runner was offered %d tasks, want none", len(tasks))
+	}
+	if tasks := controller.availableTasksFor("unprofiled"); len(tasks) != 1 || tasks[0].ID != command.ID {
+		t.Fatalf("runner without a profile was offered %d tasks, want the one without requirements", len(tasks))
+	}
+}
+
+func TestListRunnersByCapabilityAndReputation(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	registerWithCapabilities(t, router, "gpu", &models.CapabilityProfile{
+		CPUCores:    16,
+		MemoryBytes: 64 << 30,
+		GPUs:        []models.GPUInfo{{Index: 0, Model: "NVIDIA A100"}},
+		TaskTypes:   []models.TaskType{models.TaskTypeDocker},
+	})
+	registerWithCapabilities(t, router, "cpu", &models.CapabilityProfile{
+		CPUCores:    4,
+		MemoryBytes: 8 << 30,
+		TaskTypes:   []models.TaskType{models.TaskTypeDocker, models.TaskTypeCommand},
+	})
+	controller.recordHeartbeat("gpu", nil, time.Now())
+	for i, exitCode := range []int{0, 0, 0, 1} {
+		controller.results[string(rune('a'+i))] = &models.TaskResult{DeviceID: "gpu", ExitCode: exitCode}
+	}
+
+	list := func(query string) []models.RunnerSummary {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/runners"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list runners%s = %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var runners []models.RunnerSummary
+		if err := json.Unmarshal(rec.Body.Bytes(), &runners); err != nil {
+			t.Fatal(err)
+		}
+		return runners
+	}
+
+	runners := list("")
+	if len(runners) != 2 || runners[0].DeviceID != "cpu" || runners[1].DeviceID != "gpu" {
+		t.Fatalf("runners = %+v, want cpu and gpu", runners)
+	}
+	if gpu := runners[1]; !gpu.Online || gpu.Reputation.Completed != 3 || gpu.Reputation.Failed != 1 || gpu.Reputation.SuccessRate != 0.75 {
+		t.Fatalf("gpu runner = %+v", gpu)
+	}
+
+	for query, want := range map[string]string{
+		"?gpu=true":                  "gpu",
+		"?min_memory_gb=32":          "gpu",
+		"?task_type=command":         "cpu",
+		"?min_success_rate=0.5":      "gpu",
+		"?min_cpu_cores=4&gpu=false": "",
+	} {
+		runners := list(query)
+		if want == "" {
+			if len(runners) != 2 {
+				t.Fatalf("list runners%s = %+v, want both", query, runners)
+			}
+			continue
+		}
+		if len(runners) != 1 || runners[0].DeviceID != want {
+			t.Fatalf("list runners%s = %+v, want %s", query, runners, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/runners?min_success_rate=2", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid filter = %d, want 400", rec.Code)
+	}
+}
+
+func TestBandwidthSample(t *testing.T) {
+	router := newTestRouter(NewRunnerController(nil))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/runners/bandwidth?bytes=4096", nil))
+	if rec.Code != http.StatusOK || rec.Body.Len() != 4096 {
+		t.Fatalf("bandwidth sample = %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/runners/bandwidth?bytes=1000000000", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("oversized sample = %d, want 400", rec.Code)
+	}
+}
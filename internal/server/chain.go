@@ -0,0 +1,800 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/gas"
+)
+
+// ErrChainUnavailable is returned when the chain cannot be reached and no cached
+// data is fresh enough to answer instead
+var ErrChainUnavailable = errors.New("chain RPC unavailable")
+
+// Chain is the on-chain functionality the server depends on
+type Chain interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	StakeBalance(ctx context.Context, deviceID string) (*big.Int, error)
+	TransferReward(ctx context.Context, deviceID string, amount float64) error
+}
+
+// TxTransferrer is implemented by chains that report the transaction a reward
+// was paid in, which then shows in earnings reports
+type TxTransferrer interface {
+	TransferRewardTx(ctx context.Context, deviceID string, amount float64) (txHash string, err error)
+}
+
+// ResumableTransferrer is implemented by chains whose reward transactions may
+// still be mined after a transfer gave up, which they report with a
+// *gas.PendingError. The next attempt resumes that transaction under its nonce
+// instead of paying the reward again.
+type ResumableTransferrer interface {
+	ResumeRewardTx(ctx context.Context, pending gas.Pending, deviceID string, amount float64) (txHash string, err error)
+	ResumeRewardBatch(ctx context.Context, pending gas.Pending, batchID string, transfers []RewardTransfer) (txHash string, err error)
+}
+
+// BatchStakeReader is implemented by chains that can read many stakes in one
+// call, e.g. through multicall
+type BatchStakeReader interface {
+	StakeBalances(ctx context.Context, deviceIDs []string) (map[string]*big.Int, error)
+}
+
+// transferTimer is implemented by chains whose transfers wait for their
+// transaction to be mined, which takes longer than CallTimeout
+type transferTimer interface {
+	TransferTimeout() time.Duration
+}
+
+// StakeEvent is a stake change observed by the chain listener. A nil Balance
+// only invalidates the cached snapshot, and an empty DeviceID invalidates every
+// snapshot, for listeners that see the stake contract change without knowing
+// whose stake it was.
+type StakeEvent struct {
+	DeviceID string
+	Balance  *big.Int
+}
+
+type ChainGatewayConfig struct {
+	// StakeFreshTTL is how long a stake snapshot is trusted without asking the chain
+	StakeFreshTTL time.Duration
+	// StakeCacheTTL is how long a stake snapshot may stand in for the chain
+	StakeCacheTTL time.Duration
+	// SettleInterval is how often queued payouts are retried and the RPC probed
+	SettleInterval time.Duration
+	CallTimeout    time.Duration
+	// TransferTimeout bounds a reward transfer. Zero uses the chain's own
+	// transfer timeout when it has one and CallTimeout otherwise.
+	TransferTimeout time.Duration
+	// RetryBackoff is the delay after a failed retry, doubled on every further
+	// failure up to MaxRetryBackoff
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// AlertAfterAttempts raises a payout alert once a payout has failed this often
+	AlertAfterAttempts int
+	// BatchSize collects payouts and pays this many in one transaction, on
+	// chains that implement BatchTransferrer. Below 2 every payout is sent on
+	// its own.
+	BatchSize int
+	// BatchInterval is the longest a payout waits for its batch to fill up
+	BatchInterval time.Duration
+}
+
+func DefaultChainGatewayConfig() ChainGatewayConfig {
+	return ChainGatewayConfig{
+		StakeFreshTTL:      30 * time.Second,
+		StakeCacheTTL:      15 * time.Minute,
+		SettleInterval:     time.Minute,
+		CallTimeout:        10 * time.Second,
+		RetryBackoff:       30 * time.Second,
+		MaxRetryBackoff:    30 * time.Minute,
+		AlertAfterAttempts: 5,
+		BatchInterval:      5 * time.Minute,
+	}
+}
+
+// ChainGatewayConfigFromConfig starts from the defaults and overrides what the
+// SERVER_CHAIN_* settings set
+func ChainGatewayConfigFromConfig(cfg config.ChainConfig) ChainGatewayConfig {
+	gateway := DefaultChainGatewayConfig()
+	if cfg.StakeFreshTTL > 0 {
+		gateway.StakeFreshTTL = cfg.StakeFreshTTL
+	}
+	if cfg.StakeCacheTTL > 0 {
+		gateway.StakeCacheTTL = cfg.StakeCacheTTL
+	}
+	if cfg.SettleInterval > 0 {
+		gateway.SettleInterval = cfg.SettleInterval
+	}
+	if cfg.RetryBackoff > 0 {
+		gateway.RetryBackoff = cfg.RetryBackoff
+	}
+	if cfg.MaxRetryBackoff > 0 {
+		gateway.MaxRetryBackoff = cfg.MaxRetryBackoff
+	}
+	if cfg.AlertAfterAttempts > 0 {
+		gateway.AlertAfterAttempts = cfg.AlertAfterAttempts
+	}
+	if cfg.BatchSize > 0 {
+		gateway.BatchSize = cfg.BatchSize
+	}
+	if cfg.BatchInterval > 0 {
+		gateway.BatchInterval = cfg.BatchInterval
+	}
+	return gateway
+}
+
+// Payout is a reward owed to a runner for a completed task. Queued payouts are
+// persisted in the payout_queue table when a PayoutStore is configured.
+type Payout struct {
+	TaskID        string          `json:"task_id" gorm:"type:varchar(64);primaryKey"`
+	DeviceID      string          `json:"device_id" gorm:"type:varchar(255);index"`
+	WalletAddress string          `json:"wallet_address,omitempty" gorm:"type:varchar(42)"`
+	TaskType      models.TaskType `json:"task_type,omitempty" gorm:"type:varchar(32)"`
+	Amount        float64         `json:"amount" gorm:"type:decimal(20,8)"`
+	TxHash        string          `json:"tx_hash,omitempty" gorm:"type:varchar(66)"`
+	// BatchID is the batch the payout is settled in, once it has one
+	BatchID string `json:"batch_id,omitempty" gorm:"type:varchar(66);index"`
+	// PendingTx is the transaction of a failed attempt that may still be
+	// mined. The next attempt resumes it rather than sending a new one.
+	PendingTx *gas.Pending `json:"pending_tx,omitempty" gorm:"type:text;serializer:json"`
+	// ChainID is the settlement chain the payout goes to, zero for the default
+	// chain
+	ChainID       int64     `json:"chain_id,omitempty" gorm:"type:bigint"`
+	RequestedAt   time.Time `json:"requested_at" gorm:"type:timestamp"`
+	QueuedAt      time.Time `json:"queued_at" gorm:"type:timestamp"`
+	NextAttemptAt time.Time `json:"next_attempt_at" gorm:"type:timestamp"`
+	Attempts      int       `json:"attempts"`
+	LastErr       string    `json:"last_error,omitempty" gorm:"type:text"`
+	Alerted       bool      `json:"alerted,omitempty"`
+}
+
+func (Payout) TableName() string {
+	return "payout_queue"
+}
+
+type ChainStatus struct {
+	Connected      bool      `json:"connected"`
+	LastBlock      uint64    `json:"last_block,omitempty"`
+	LastSuccessAt  time.Time `json:"last_success_at,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	PendingPayouts int       `json:"pending_payouts"`
+}
+
+type stakeSnapshot struct {
+	balance *big.Int
+	at      time.Time
+}
+
+// ChainGateway keeps the server usable while the chain RPC is down. Stake checks
+// fall back to a recent snapshot and payouts are queued until they can be settled.
+type ChainGateway struct {
+	chain  Chain
+	config ChainGatewayConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	status  ChainStatus
+	stakes  map[string]stakeSnapshot
+	pending []*Payout
+	store   PayoutStore
+	onPaid  []func(payout Payout, latency time.Duration)
+	onAlert []func(payout Payout)
+
+	// collecting payouts wait for a batch, and batches wait to be settled
+	collecting     []*Payout
+	batches        []*PayoutBatch
+	settledBatches []PayoutBatch
+	flush          chan struct{}
+
+	requestedAmount float64
+	settledAmount   float64
+	settledTasks    map[string]string
+	duplicates      []string
+}
+
+func NewChainGateway(chain Chain, config ChainGatewayConfig) *ChainGateway {
+	defaults := DefaultChainGatewayConfig()
+	if config.StakeFreshTTL <= 0 {
+		config.StakeFreshTTL = defaults.StakeFreshTTL
+	}
+	if config.StakeCacheTTL <= 0 {
+		config.StakeCacheTTL = defaults.StakeCacheTTL
+	}
+	if config.SettleInterval <= 0 {
+		config.SettleInterval = defaults.SettleInterval
+	}
+	if config.CallTimeout <= 0 {
+		config.CallTimeout = defaults.CallTimeout
+	}
+	if config.TransferTimeout <= 0 {
+		config.TransferTimeout = config.CallTimeout
+		if timer, ok := chain.(transferTimer); ok && timer.TransferTimeout() > 0 {
+			config.TransferTimeout = timer.TransferTimeout()
+		}
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaults.RetryBackoff
+	}
+	if config.MaxRetryBackoff < config.RetryBackoff {
+		config.MaxRetryBackoff = defaults.MaxRetryBackoff
+	}
+	if config.AlertAfterAttempts <= 0 {
+		config.AlertAfterAttempts = defaults.AlertAfterAttempts
+	}
+	if config.BatchInterval <= 0 {
+		config.BatchInterval = defaults.BatchInterval
+	}
+	return &ChainGateway{
+		chain:        chain,
+		config:       config,
+		now:          time.Now,
+		stakes:       make(map[string]stakeSnapshot),
+		flush:        make(chan struct{}, 1),
+		settledTasks: make(map[string]string),
+	}
+}
+
+func (g *ChainGateway) markResult(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err != nil {
+		if g.status.Connected || g.status.LastError == "" {
+			log := gologger.WithComponent("chain")
+			log.Warn().Err(err).Msg("Chain RPC unreachable, running in degraded mode")
+		}
+		g.status.Connected = false
+		g.status.LastError = err.Error()
+		return
+	}
+	if !g.status.Connected && g.status.LastError != "" {
+		log := gologger.WithComponent("chain")
+		log.Info().Msg("Chain RPC reachable again")
+	}
+	g.status.Connected = true
+	g.status.LastError = ""
+	g.status.LastSuccessAt = g.now()
+}
+
+// Probe checks chain connectivity
+func (g *ChainGateway) Probe(ctx context.Context) error {
+	callCtx, cancel := context.WithTimeout(ctx, g.config.CallTimeout)
+	defer cancel()
+
+	block, err := g.chain.BlockNumber(callCtx)
+	g.markResult(err)
+	if err == nil {
+		g.mu.Lock()
+		g.status.LastBlock = block
+		g.mu.Unlock()
+	}
+	return err
+}
+
+func (g *ChainGateway) Status() ChainStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status := g.status
+	status.PendingPayouts = len(g.pending) + len(g.collecting)
+	for _, batch := range g.batches {
+		status.PendingPayouts += len(batch.payouts)
+	}
+	return status
+}
+
+// StakeBalance reads a runner's stake. A snapshot younger than StakeFreshTTL is
+// returned without a chain call, and an older one still answers while the chain is
+// unreachable. cached reports whether a snapshot was used.
+func (g *ChainGateway) StakeBalance(ctx context.Context, deviceID string) (balance *big.Int, cached bool, err error) {
+	g.mu.Lock()
+	snapshot, ok := g.stakes[deviceID]
+	g.mu.Unlock()
+	if ok && g.now().Sub(snapshot.at) <= g.config.StakeFreshTTL {
+		return new(big.Int).Set(snapshot.balance), true, nil
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, g.config.CallTimeout)
+	defer cancel()
+
+	balance, err = g.chain.StakeBalance(callCtx, deviceID)
+	g.markResult(err)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err == nil {
+		g.stakes[deviceID] = stakeSnapshot{balance: new(big.Int).Set(balance), at: g.now()}
+		return balance, false, nil
+	}
+
+	snapshot, ok = g.stakes[deviceID]
+	if !ok || g.now().Sub(snapshot.at) > g.config.StakeCacheTTL {
+		return nil, false, fmt.Errorf("%w: %v", ErrChainUnavailable, err)
+	}
+	return new(big.Int).Set(snapshot.balance), true, nil
+}
+
+// RefreshStakes reloads the given stakes, in a single call when the chain
+// supports batch reads
+func (g *ChainGateway) RefreshStakes(ctx context.Context, deviceIDs []string) error {
+	if len(deviceIDs) == 0 {
+		return nil
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, g.config.CallTimeout)
+	defer cancel()
+
+	balances := make(map[string]*big.Int, len(deviceIDs))
+	var err error
+	if batch, ok := g.chain.(BatchStakeReader); ok {
+		balances, err = batch.StakeBalances(callCtx, deviceIDs)
+	} else {
+		for _, deviceID := range deviceIDs {
+			var balance *big.Int
+			if balance, err = g.chain.StakeBalance(callCtx, deviceID); err != nil {
+				break
+			}
+			balances[deviceID] = balance
+		}
+	}
+	g.markResult(err)
+	if err != nil {
+		return fmt.Errorf("failed to refresh stakes: %w", err)
+	}
+
+	now := g.now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for deviceID, balance := range balances {
+		if balance != nil {
+			g.stakes[deviceID] = stakeSnapshot{balance: new(big.Int).Set(balance), at: now}
+		}
+	}
+	return nil
+}
+
+// staleStakes lists cached devices whose snapshot is no longer fresh but still
+// within the cache TTL, i.e. the ones worth refreshing in the background
+func (g *ChainGateway) staleStakes() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	var stale []string
+	for deviceID, snapshot := range g.stakes {
+		age := now.Sub(snapshot.at)
+		if age > g.config.StakeCacheTTL {
+			delete(g.stakes, deviceID)
+			continue
+		}
+		if age > g.config.StakeFreshTTL {
+			stale = append(stale, deviceID)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// ObserveStakeEvent applies a stake change seen on chain so the cache never
+// serves a balance older than the latest event
+func (g *ChainGateway) ObserveStakeEvent(event StakeEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if event.DeviceID == "" {
+		g.stakes = make(map[string]stakeSnapshot)
+		return
+	}
+	if event.Balance == nil {
+		delete(g.stakes, event.DeviceID)
+		return
+	}
+	g.stakes[event.DeviceID] = stakeSnapshot{balance: new(big.Int).Set(event.Balance), at: g.now()}
+}
+
+// WatchStakeEvents consumes stake events from the chain listener until the channel
+// closes or ctx is cancelled
+func (g *ChainGateway) WatchStakeEvents(ctx context.Context, events <-chan StakeEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			g.ObserveStakeEvent(event)
+		}
+	}
+}
+
+// OnPaid registers a callback run after a payout reaches the chain, with the time
+// since the payout was requested
+func (g *ChainGateway) OnPaid(fn func(payout Payout, latency time.Duration)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onPaid = append(g.onPaid, fn)
+}
+
+func (g *ChainGateway) paid(payout Payout) {
+	g.mu.Lock()
+	g.recordSettledLocked(payout)
+	callbacks := append([]func(Payout, time.Duration){}, g.onPaid...)
+	g.mu.Unlock()
+
+	latency := g.now().Sub(payout.RequestedAt)
+	for _, fn := range callbacks {
+		fn(payout, latency)
+	}
+}
+
+// Distribute pays a reward, queueing it for later settlement when the transfer
+// fails. With batching it is collected for the next batch instead. It reports
+// whether the payout was queued or collected rather than sent.
+func (g *ChainGateway) Distribute(ctx context.Context, payout Payout) (queued bool) {
+	log := gologger.WithComponent("chain")
+
+	if payout.RequestedAt.IsZero() {
+		payout.RequestedAt = g.now()
+	}
+	g.mu.Lock()
+	g.requestedAmount += payout.Amount
+	g.mu.Unlock()
+
+	if g.batching() {
+		g.collect(ctx, payout)
+		return true
+	}
+	if err := g.transfer(ctx, &payout); err == nil {
+		g.paid(payout)
+		return false
+	}
+
+	payout.QueuedAt = g.now()
+	g.mu.Lock()
+	g.pending = append(g.pending, &payout)
+	g.mu.Unlock()
+	g.persist(ctx, payout)
+
+	log.Error().
+		Str("task_id", payout.TaskID).
+		Str("device_id", payout.DeviceID).
+		Float64("amount", payout.Amount).
+		Str("error", payout.LastErr).
+		Msg("Reward transfer failed, payout queued for settlement")
+	return true
+}
+
+func (g *ChainGateway) transfer(ctx context.Context, payout *Payout) error {
+	callCtx, cancel := context.WithTimeout(ctx, g.config.TransferTimeout)
+	defer cancel()
+
+	// Queued payouts are read by PendingPayouts, so updates happen under the lock
+	g.mu.Lock()
+	payout.Attempts++
+	deviceID, amount, pending := payout.DeviceID, payout.Amount, payout.PendingTx
+	g.mu.Unlock()
+
+	var txHash string
+	var err error
+	resumer, resumable := g.chain.(ResumableTransferrer)
+	txChain, hasTx := g.chain.(TxTransferrer)
+	switch {
+	case pending != nil && resumable:
+		txHash, err = resumer.ResumeRewardTx(callCtx, *pending, deviceID, amount)
+	case hasTx:
+		txHash, err = txChain.TransferRewardTx(callCtx, deviceID, amount)
+	default:
+		err = g.chain.TransferReward(callCtx, deviceID, amount)
+	}
+	g.markResult(err)
+	g.mu.Lock()
+	payout.PendingTx = pendingAfter(pending, err)
+	if err != nil {
+		payout.LastErr = err.Error()
+	} else {
+		payout.TxHash = txHash
+	}
+	g.mu.Unlock()
+	return err
+}
+
+// pendingAfter is the transaction an attempt leaves that may still be mined:
+// the one a *gas.PendingError reports, none once a version was mined or the
+// attempt succeeded, and the one before the attempt otherwise
+func pendingAfter(previous *gas.Pending, err error) *gas.Pending {
+	var pendingErr *gas.PendingError
+	switch {
+	case err == nil, errors.Is(err, gas.ErrReverted):
+		return nil
+	case errors.As(err, &pendingErr):
+		return &pendingErr.Pending
+	default:
+		return previous
+	}
+}
+
+// PendingPayouts returns a copy of the settlement queue, including payouts
+// waiting for or in a batch
+func (g *ChainGateway) PendingPayouts() []Payout {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.pendingPayoutsLocked()
+}
+
+func (g *ChainGateway) pendingPayoutsLocked() []Payout {
+	payouts := make([]Payout, 0, len(g.pending)+len(g.collecting))
+	for _, payout := range g.pending {
+		payouts = append(payouts, *payout)
+	}
+	for _, batch := range g.batches {
+		for _, payout := range batch.payouts {
+			payouts = append(payouts, *payout)
+		}
+	}
+	for _, payout := range g.collecting {
+		payouts = append(payouts, *payout)
+	}
+	return payouts
+}
+
+// Settle retries the queued payouts that are due, oldest first. A failed retry
+// is pushed back with exponential backoff and ends the pass, since the chain is
+// most likely still failing. It returns the number settled.
+func (g *ChainGateway) Settle(ctx context.Context) int {
+	log := gologger.WithComponent("chain")
+
+	g.mu.Lock()
+	queue := append([]*Payout{}, g.pending...)
+	g.mu.Unlock()
+
+	settled := 0
+	for _, payout := range queue {
+		g.mu.Lock()
+		due := !g.now().Before(payout.NextAttemptAt)
+		g.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		if err := g.transfer(ctx, payout); err != nil {
+			g.scheduleRetry(ctx, payout)
+			return settled
+		}
+
+		g.mu.Lock()
+		for i, pending := range g.pending {
+			if pending == payout {
+				g.pending = append(g.pending[:i], g.pending[i+1:]...)
+				break
+			}
+		}
+		settledPayout := *payout
+		g.mu.Unlock()
+		settled++
+
+		g.unpersist(ctx, settledPayout.TaskID)
+		g.paid(settledPayout)
+
+		log.Info().
+			Str("task_id", settledPayout.TaskID).
+			Str("device_id", settledPayout.DeviceID).
+			Float64("amount", settledPayout.Amount).
+			Int("attempts", settledPayout.Attempts).
+			Msg("Queued payout settled")
+	}
+	return settled
+}
+
+// retryBackoff is the delay before the next attempt of a payout that failed on
+// its latest retry
+func (g *ChainGateway) retryBackoff(attempts int) time.Duration {
+	backoff := g.config.RetryBackoff
+	for i := 2; i < attempts && backoff < g.config.MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > g.config.MaxRetryBackoff {
+		backoff = g.config.MaxRetryBackoff
+	}
+	return backoff
+}
+
+func (g *ChainGateway) scheduleRetry(ctx context.Context, payout *Payout) {
+	g.mu.Lock()
+	payout.NextAttemptAt = g.now().Add(g.retryBackoff(payout.Attempts))
+	alert := !payout.Alerted && payout.Attempts >= g.config.AlertAfterAttempts
+	if alert {
+		payout.Alerted = true
+	}
+	snapshot := *payout
+	callbacks := append([]func(Payout){}, g.onAlert...)
+	g.mu.Unlock()
+
+	g.persist(ctx, snapshot)
+
+	if !alert {
+		return
+	}
+	log := gologger.WithComponent("chain")
+	log.Error().
+		Str("task_id", snapshot.TaskID).
+		Str("device_id", snapshot.DeviceID).
+		Float64("amount", snapshot.Amount).
+		Int("attempts", snapshot.Attempts).
+		Str("error", snapshot.LastErr).
+		Msg("Payout keeps failing, operator attention needed")
+	for _, fn := range callbacks {
+		fn(snapshot)
+	}
+}
+
+// OnPayoutAlert registers a callback run once for each payout that has failed
+// AlertAfterAttempts times
+func (g *ChainGateway) OnPayoutAlert(fn func(payout Payout)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onAlert = append(g.onAlert, fn)
+}
+
+// FailingPayouts counts queued payouts that have reached the alert threshold
+func (g *ChainGateway) FailingPayouts() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	failing := 0
+	for _, payout := range g.pending {
+		if payout.Attempts >= g.config.AlertAfterAttempts {
+			failing++
+		}
+	}
+	for _, batch := range g.batches {
+		if batch.Attempts >= g.config.AlertAfterAttempts {
+			failing += len(batch.payouts)
+		}
+	}
+	return failing
+}
+
+// Run probes the chain, settles queued payouts and payout batches and
+// refreshes stale stake snapshots in one batch until ctx is cancelled. A full
+// batch is settled without waiting for the next interval.
+func (g *ChainGateway) Run(ctx context.Context) {
+	ticker := time.NewTicker(g.config.SettleInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := g.Probe(ctx); err == nil {
+			g.Settle(ctx)
+			g.SettleBatches(ctx)
+			if err := g.RefreshStakes(ctx, g.staleStakes()); err != nil {
+				log := gologger.WithComponent("chain")
+				log.Debug().Err(err).Msg("Background stake refresh failed")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-g.flush:
+		}
+	}
+}
+
+// SetChainGateway enables stake checks on task start and reward payouts on
+// approved results. A nil minStake disables the stake requirement.
+func (c *RunnerController) SetChainGateway(gateway *ChainGateway, minStake *big.Int) {
+	if gateway != nil {
+		c.watchPayouts(gateway)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chain = gateway
+	c.minStake = minStake
+}
+
+// watchPayouts records the payouts gateway settles in earnings, reports and
+// the payout SLO
+func (c *RunnerController) watchPayouts(gateway *ChainGateway) {
+	gateway.OnPaid(func(payout Payout, latency time.Duration) {
+		now := time.Now()
+		c.recordPaid(payout, now)
+		c.recordPaidReward(payout, now)
+		c.recordSLOLatency(SLOPayoutLatency, latency, now)
+	})
+}
+
+// checkRunnerStake reports the HTTP status to answer a task start with when the
+// runner's stake is below what the task requires or cannot be determined, or 0
+// to proceed
+func (c *RunnerController) checkRunnerStake(ctx context.Context, taskID, deviceID string) (int, string) {
+	c.mu.RLock()
+	gateway, _ := c.gatewayFor(deviceID)
+	required := c.requiredStakeLocked(c.findAvailableLocked(taskID), deviceID)
+	c.mu.RUnlock()
+
+	if gateway == nil || required == nil {
+		return 0, ""
+	}
+
+	balance, cached, err := gateway.StakeBalance(ctx, deviceID)
+	if err != nil {
+		return http.StatusServiceUnavailable, "Stake check unavailable, chain RPC is down"
+	}
+	if balance.Cmp(required) < 0 {
+		return http.StatusForbidden, fmt.Sprintf("Insufficient stake, the task requires %s wei", required)
+	}
+	if cached {
+		log := gologger.WithComponent("runner_controller")
+		log.Debug().Str("device_id", deviceID).Msg("Stake check answered from cached snapshot")
+	}
+	return 0, ""
+}
+
+// distributeReward pays the runner for an approved result. The returned status is
+// "sent", "queued", "batched" or empty when no payout applies.
+func (c *RunnerController) distributeReward(ctx context.Context, taskID string) string {
+	c.mu.Lock()
+	assignment, ok := c.assigned[taskID]
+	delete(c.assigned, taskID)
+	walletAddress := c.wallets[assignment.deviceID]
+	gateway, chainID := c.gatewayFor(assignment.deviceID)
+	c.mu.Unlock()
+
+	if !ok {
+		return ""
+	}
+	reward := c.awardedReward(taskID, assignment.deviceID, assignment.task.Reward)
+	if gateway == nil || reward <= 0 {
+		return ""
+	}
+
+	queued := gateway.Distribute(ctx, Payout{
+		TaskID:        taskID,
+		DeviceID:      assignment.deviceID,
+		WalletAddress: walletAddress,
+		TaskType:      assignment.task.Type,
+		Amount:        reward * c.rewardWeight(assignment.deviceID),
+		ChainID:       chainID,
+	})
+	switch {
+	case queued && gateway.batching():
+		return "batched"
+	case queued:
+		return "queued"
+	}
+	return "sent"
+}
+
+func (c *RunnerController) handleChainStatus(ctx *gin.Context) {
+	c.mu.RLock()
+	gateway := c.chain
+	settlement := make(map[int64]gin.H, len(c.settlementChains))
+	for chainID, chainGateway := range c.settlementChains {
+		settlement[chainID] = gin.H{"chain": chainGateway.Status(), "pending": chainGateway.PendingPayouts()}
+	}
+	c.mu.RUnlock()
+
+	if gateway == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Chain integration not configured"})
+		return
+	}
+
+	status := gin.H{
+		"chain":   gateway.Status(),
+		"pending": gateway.PendingPayouts(),
+	}
+	if len(settlement) > 0 {
+		status["settlement_chains"] = settlement
+	}
+	ctx.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,411 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+type fakeChain struct {
+	mu         sync.Mutex
+	down       bool
+	stakes     map[string]*big.Int
+	transfers  []Payout
+	stakeReads int
+}
+
+func (f *fakeChain) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *fakeChain) BlockNumber(ctx context.Context) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return 0, errors.New("dial tcp: connection refused")
+	}
+	return 42, nil
+}
+
+func (f *fakeChain) StakeBalance(ctx context.Context, deviceID string) (*big.Int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	f.stakeReads++
+	return f.stakes[deviceID], nil
+}
+
+// batchChain adds multicall style batch reads to fakeChain
+type batchChain struct {
+	*fakeChain
+	batches int
+}
+
+func (b *batchChain) StakeBalances(ctx context.Context, deviceIDs []string) (map[string]*big.Int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batches++
+	balances := make(map[string]*big.Int, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		balances[deviceID] = b.stakes[deviceID]
+	}
+	return balances, nil
+}
+
+func (f *fakeChain) TransferReward(ctx context.Context, deviceID string, amount float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("dial tcp: connection refused")
+	}
+	f.transfers = append(f.transfers, Payout{DeviceID: deviceID, Amount: amount})
+	return nil
+}
+
+func TestChainGatewayUsesStakeSnapshotWhileDown(t *testing.T) {
+	chain := &fakeChain{stakes: map[string]*big.Int{"device-1": big.NewInt(100)}}
+	gateway := NewChainGateway(chain, ChainGatewayConfig{})
+	ctx := context.Background()
+
+	if _, _, err := gateway.StakeBalance(ctx, "device-1"); err != nil {
+		t.Fatalf("StakeBalance() error = %v", err)
+	}
+
+	chain.setDown(true)
+	balance, cached, err := gateway.StakeBalance(ctx, "device-1")
+	if err != nil || !cached || balance.Int64() != 100 {
+		t.Fatalf("StakeBalance() while down = %v, %v, %v; want cached 100", balance, cached, err)
+	}
+	if _, _, err := gateway.StakeBalance(ctx, "device-2"); !errors.Is(err, ErrChainUnavailable) {
+		t.Fatalf("StakeBalance() without snapshot error = %v, want ErrChainUnavailable", err)
+	}
+	if gateway.Status().Connected {
+		t.Fatal("expected status to report the chain as disconnected")
+	}
+}
+
+func TestPayoutsQueueWhileChainIsDown(t *testing.T) {
+	chain := &fakeChain{stakes: map[string]*big.Int{"device-1": big.NewInt(100)}}
+	gateway := NewChainGateway(chain, ChainGatewayConfig{})
+	controller := NewRunnerController(nil)
+	controller.SetChainGateway(gateway, big.NewInt(50))
+	router := newTestRouter(controller)
+
+	// Snapshot the stake while the chain is up
+	if _, _, err := gateway.StakeBalance(context.Background(), "device-1"); err != nil {
+		t.Fatalf("StakeBalance() error = %v", err)
+	}
+	chain.setDown(true)
+
+	task := models.NewTask()
+	task.Reward = 1.5
+	controller.AddAvailableTask(task)
+
+	start := func(deviceID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+task.ID.String()+"/start", bytes.NewReader(nil))
+		req.Header.Set("X-Device-ID", deviceID)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := start("device-2"); code != http.StatusServiceUnavailable {
+		t.Fatalf("start without stake snapshot code = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if code := start("device-1"); code != http.StatusOK {
+		t.Fatalf("start with cached stake code = %d, want %d", code, http.StatusOK)
+	}
+
+	response := postResult(t, router, task.ID)
+	if response["payout_status"] != "queued" {
+		t.Fatalf("payout_status = %v, want queued", response["payout_status"])
+	}
+	if pending := gateway.PendingPayouts(); len(pending) != 1 || pending[0].Amount != 1.5 {
+		t.Fatalf("PendingPayouts() = %+v, want the task reward", pending)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/chain/status", nil))
+	var status struct {
+		Chain ChainStatus `json:"chain"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("failed to decode chain status: %v", err)
+	}
+	if status.Chain.Connected || status.Chain.PendingPayouts != 1 {
+		t.Fatalf("chain status = %+v, want disconnected with 1 pending payout", status.Chain)
+	}
+
+	chain.setDown(false)
+	if settled := gateway.Settle(context.Background()); settled != 1 {
+		t.Fatalf("Settle() = %d, want 1", settled)
+	}
+	if len(chain.transfers) != 1 || chain.transfers[0].DeviceID != "device-1" || len(gateway.PendingPayouts()) != 0 {
+		t.Fatalf("unexpected settlement: transfers=%+v pending=%+v", chain.transfers, gateway.PendingPayouts())
+	}
+}
+
+func TestResultFromAnotherDeviceIsRefused(t *testing.T) {
+	chain := &fakeChain{}
+	controller := NewRunnerController(nil)
+	controller.SetChainGateway(NewChainGateway(chain, ChainGatewayConfig{}), nil)
+	router := newTestRouter(controller)
+
+	task := models.NewTask()
+	task.Reward = 2
+	controller.AddAvailableTask(task)
+	if status, message := controller.startTask(context.Background(), task.ID.String(), "device-1"); status != 0 {
+		t.Fatalf("startTask() = %d %s", status, message)
+	}
+
+	post := func(deviceID, output string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.TaskResult{TaskID: task.ID, Output: output})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+task.ID.String()+"/result", bytes.NewReader(body))
+		req.Header.Set("X-Device-ID", deviceID)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("device-2", "forged"); rec.Code != http.StatusConflict {
+		t.Fatalf("result from another device = %d, want 409", rec.Code)
+	}
+	if _, stored := controller.GetTaskResult(task.ID.String()); stored || len(chain.transfers) != 0 {
+		t.Fatalf("refused result was stored or paid: transfers=%+v", chain.transfers)
+	}
+
+	if rec := post("device-1", "done"); rec.Code != http.StatusOK {
+		t.Fatalf("result from the assignee = %d %s", rec.Code, rec.Body.String())
+	}
+	// The settled task keeps the assignee's result
+	if rec := post("device-2", "forged"); rec.Code != http.StatusConflict {
+		t.Fatalf("result from another device after payout = %d, want 409", rec.Code)
+	}
+	if result, _ := controller.GetTaskResult(task.ID.String()); result.Output != "done" {
+		t.Fatalf("stored output = %q, want the assignee's", result.Output)
+	}
+	if len(chain.transfers) != 1 || chain.transfers[0].DeviceID != "device-1" {
+		t.Fatalf("transfers = %+v, want one payout to device-1", chain.transfers)
+	}
+}
+
+func TestStakeCacheServesFreshSnapshotsAndBatchesRefreshes(t *testing.T) {
+	chain := &batchChain{fakeChain: &fakeChain{stakes: map[string]*big.Int{
+		"device-1": big.NewInt(100),
+		"device-2": big.NewInt(200),
+	}}}
+	gateway := NewChainGateway(chain, ChainGatewayConfig{StakeFreshTTL: time.Minute, StakeCacheTTL: time.Hour})
+	now := time.Now()
+	gateway.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := gateway.StakeBalance(ctx, "device-1"); err != nil {
+			t.Fatalf("StakeBalance() error = %v", err)
+		}
+	}
+	if _, _, err := gateway.StakeBalance(ctx, "device-2"); err != nil {
+		t.Fatalf("StakeBalance() error = %v", err)
+	}
+	if chain.stakeReads != 2 {
+		t.Fatalf("chain stake reads = %d, want one per device", chain.stakeReads)
+	}
+
+	now = now.Add(2 * time.Minute)
+	stale := gateway.staleStakes()
+	if len(stale) != 2 {
+		t.Fatalf("staleStakes() = %v, want both devices", stale)
+	}
+	if err := gateway.RefreshStakes(ctx, stale); err != nil {
+		t.Fatalf("RefreshStakes() error = %v", err)
+	}
+	if chain.batches != 1 || chain.stakeReads != 2 {
+		t.Fatalf("batches = %d, single reads = %d; want 1 batch and no extra reads", chain.batches, chain.stakeReads)
+	}
+
+	gateway.ObserveStakeEvent(StakeEvent{DeviceID: "device-1", Balance: big.NewInt(5)})
+	balance, cached, _ := gateway.StakeBalance(ctx, "device-1")
+	if !cached || balance.Int64() != 5 {
+		t.Fatalf("StakeBalance() after event = %v (cached %v), want 5 from the event", balance, cached)
+	}
+
+	gateway.ObserveStakeEvent(StakeEvent{DeviceID: "device-2"})
+	if _, cached, _ := gateway.StakeBalance(ctx, "device-2"); cached || chain.stakeReads != 3 {
+		t.Fatalf("expected an invalidated stake to be read from the chain (cached %v, reads %d)", cached, chain.stakeReads)
+	}
+}
+
+type memoryPayoutStore struct {
+	mu      sync.Mutex
+	payouts map[string]Payout
+}
+
+func (s *memoryPayoutStore) SavePayout(ctx context.Context, payout Payout) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payouts[payout.TaskID] = payout
+	return nil
+}
+
+func (s *memoryPayoutStore) DeletePayout(ctx context.Context, taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.payouts, taskID)
+	return nil
+}
+
+func (s *memoryPayoutStore) LoadPayouts(ctx context.Context) ([]Payout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payouts := make([]Payout, 0, len(s.payouts))
+	for _, payout := range s.payouts {
+		payouts = append(payouts, payout)
+	}
+	return payouts, nil
+}
+
+func TestPayoutRetriesBackOffPersistAndAlert(t *testing.T) {
+	chain := &fakeChain{down: true}
+	store := &memoryPayoutStore{payouts: make(map[string]Payout)}
+	config := ChainGatewayConfig{RetryBackoff: time.Minute, MaxRetryBackoff: 4 * time.Minute, AlertAfterAttempts: 3}
+	gateway := NewChainGateway(chain, config)
+	now := time.Now()
+	gateway.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := gateway.SetPayoutStore(ctx, store); err != nil {
+		t.Fatalf("SetPayoutStore() error = %v", err)
+	}
+	var alerts []Payout
+	gateway.OnPayoutAlert(func(payout Payout) { alerts = append(alerts, payout) })
+
+	if !gateway.Distribute(ctx, Payout{TaskID: "task-1", DeviceID: "device-1", Amount: 2}) {
+		t.Fatal("expected the payout to be queued while the chain is down")
+	}
+	if _, ok := store.payouts["task-1"]; !ok {
+		t.Fatal("queued payout was not persisted")
+	}
+
+	// The first retry happens on the next pass, later ones back off
+	gateway.Settle(ctx)
+	if pending := gateway.PendingPayouts(); pending[0].Attempts != 2 || !pending[0].NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("after first retry = %+v", pending[0])
+	}
+	gateway.Settle(ctx)
+	if attempts := gateway.PendingPayouts()[0].Attempts; attempts != 2 {
+		t.Fatalf("payout retried before its backoff expired, attempts = %d", attempts)
+	}
+
+	now = now.Add(time.Minute)
+	gateway.Settle(ctx)
+	if pending := gateway.PendingPayouts(); pending[0].Attempts != 3 || !pending[0].NextAttemptAt.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("after second retry = %+v", pending[0])
+	}
+	if len(alerts) != 1 || gateway.FailingPayouts() != 1 {
+		t.Fatalf("alerts = %d, failing = %d; want one alert after 3 attempts", len(alerts), gateway.FailingPayouts())
+	}
+
+	// A restarted server restores the queue from the store
+	restarted := NewChainGateway(chain, config)
+	restarted.now = gateway.now
+	if err := restarted.SetPayoutStore(ctx, store); err != nil {
+		t.Fatalf("SetPayoutStore() error = %v", err)
+	}
+	controller := NewRunnerController(nil)
+	controller.SetChainGateway(restarted, nil)
+	if earnings := controller.Earnings("device-1"); earnings.PendingTotal != 2 || len(earnings.Pending) != 1 {
+		t.Fatalf("earnings before settlement = %+v", earnings)
+	}
+
+	chain.setDown(false)
+	now = now.Add(2 * time.Minute)
+	if settled := restarted.Settle(ctx); settled != 1 {
+		t.Fatalf("Settle() = %d, want 1", settled)
+	}
+	if len(store.payouts) != 0 {
+		t.Fatalf("settled payout still persisted: %+v", store.payouts)
+	}
+	if earnings := controller.Earnings("device-1"); earnings.PaidTotal != 2 || earnings.PaidCount != 1 || len(earnings.Pending) != 0 {
+		t.Fatalf("earnings after settlement = %+v", earnings)
+	}
+}
+
+func TestFilePayoutStoreRoundTrips(t *testing.T) {
+	store := NewFilePayoutStore(filepath.Join(t.TempDir(), "payouts"))
+	ctx := context.Background()
+
+	if payouts, err := store.LoadPayouts(ctx); err != nil || len(payouts) != 0 {
+		t.Fatalf("LoadPayouts() on a missing directory = %v, %v", payouts, err)
+	}
+	taskID := uuid.NewString()
+	if err := store.SavePayout(ctx, Payout{TaskID: taskID, DeviceID: "device-1", Amount: 2, Attempts: 3}); err != nil {
+		t.Fatalf("SavePayout() error = %v", err)
+	}
+	payouts, err := store.LoadPayouts(ctx)
+	if err != nil || len(payouts) != 1 || payouts[0].TaskID != taskID || payouts[0].Attempts != 3 {
+		t.Fatalf("LoadPayouts() = %+v, %v", payouts, err)
+	}
+	if err := store.SavePayout(ctx, Payout{TaskID: "../escape"}); err == nil {
+		t.Fatal("SavePayout() accepted a task ID that is not a UUID")
+	}
+
+	if err := store.DeletePayout(ctx, taskID); err != nil {
+		t.Fatalf("DeletePayout() error = %v", err)
+	}
+	if payouts, _ := store.LoadPayouts(ctx); len(payouts) != 0 {
+		t.Fatalf("payout still stored after DeletePayout(): %+v", payouts)
+	}
+}
+
+func TestStakeEventWithoutDeviceInvalidatesEveryStake(t *testing.T) {
+	chain := &fakeChain{stakes: map[string]*big.Int{"device-1": big.NewInt(1), "device-2": big.NewInt(2)}}
+	gateway := NewChainGateway(chain, DefaultChainGatewayConfig())
+	ctx := context.Background()
+
+	for _, deviceID := range []string{"device-1", "device-2"} {
+		if _, _, err := gateway.StakeBalance(ctx, deviceID); err != nil {
+			t.Fatalf("StakeBalance(%s) error = %v", deviceID, err)
+		}
+	}
+
+	events := make(chan StakeEvent, 1)
+	events <- StakeEvent{}
+	close(events)
+	gateway.WatchStakeEvents(ctx, events)
+
+	for _, deviceID := range []string{"device-1", "device-2"} {
+		if _, cached, _ := gateway.StakeBalance(ctx, deviceID); cached {
+			t.Fatalf("StakeBalance(%s) served from cache after the stake contract changed", deviceID)
+		}
+	}
+	if chain.stakeReads != 4 {
+		t.Fatalf("stake reads = %d, want 4", chain.stakeReads)
+	}
+}
+
+func TestChainGatewayConfigFromConfig(t *testing.T) {
+	gateway := ChainGatewayConfigFromConfig(config.ChainConfig{StakeFreshTTL: time.Second})
+	if gateway.StakeFreshTTL != time.Second {
+		t.Fatalf("StakeFreshTTL = %v, want 1s", gateway.StakeFreshTTL)
+	}
+	if gateway.StakeCacheTTL != DefaultChainGatewayConfig().StakeCacheTTL {
+		t.Fatalf("StakeCacheTTL = %v, want the default", gateway.StakeCacheTTL)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+)
+
+// DefaultMaxInlineBytes is the largest prompt or data blob kept inline in a task config
+const DefaultMaxInlineBytes = 64 << 10
+
+// offloadableFields are config keys that runners also accept as <key>_cid references
+var offloadableFields = []string{"prompt", "data"}
+
+// ContentStore stores blobs that are too large to keep inline, e.g. an ipfs.Backend
+type ContentStore interface {
+	Add(ctx context.Context, data []byte) (string, error)
+}
+
+// SetContentStore enables moving oversized inline prompts and data to the store
+// when tasks are created
+func (c *RunnerController) SetContentStore(store ContentStore, maxInlineBytes int) {
+	if maxInlineBytes <= 0 {
+		maxInlineBytes = DefaultMaxInlineBytes
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.contentStore = store
+	c.maxInlineBytes = maxInlineBytes
+}
+
+// offloadInlineContent replaces oversized inline fields of the task config with a
+// CID and a sha256 the runner checks after download
+func (c *RunnerController) offloadInlineContent(ctx context.Context, task *models.Task) (bool, error) {
+	c.mu.RLock()
+	store, limit := c.contentStore, c.maxInlineBytes
+	c.mu.RUnlock()
+
+	if store == nil || len(task.Config) <= limit {
+		return false, nil
+	}
+
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return false, fmt.Errorf("failed to parse task config: %w", err)
+	}
+
+	changed := false
+	for _, field := range offloadableFields {
+		var value string
+		if err := json.Unmarshal(config[field], &value); err != nil || len(value) <= limit {
+			continue
+		}
+
+		cid, err := store.Add(ctx, []byte(value))
+		if err != nil {
+			return false, fmt.Errorf("failed to store %s: %w", field, err)
+		}
+
+		config[field+"_cid"], _ = json.Marshal(cid)
+		config[field+"_sha256"], _ = json.Marshal(ipfs.SHA256Hex([]byte(value)))
+		delete(config, field)
+		changed = true
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	updated, err := json.Marshal(config)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal task config: %w", err)
+	}
+	task.Config = updated
+	return true, nil
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/credentials"
+)
+
+var (
+	errBrokerNotFound = errors.New("credential broker not found")
+	errNotBrokerOwner = errors.New("only the device that registered the broker can do this")
+)
+
+func brokerKey(creatorAddress, name string) string {
+	return strings.ToLower(creatorAddress) + "/" + name
+}
+
+// SetCredentialClient sets the HTTP client the exchanges of credential brokers
+// are called with
+func (c *RunnerController) SetCredentialClient(client *http.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.credentialClient = client
+}
+
+// RegisterBroker adds a creator's credential broker, or replaces one the same
+// device registered before
+func (c *RunnerController) RegisterBroker(broker *models.CredentialBroker) error {
+	if err := broker.Validate(); err != nil {
+		return err
+	}
+	if broker.OwnerDeviceID == "" {
+		return errNotBrokerOwner
+	}
+	if err := credentials.CheckBroker(broker); err != nil {
+		return err
+	}
+	if broker.CreatedAt.IsZero() {
+		broker.CreatedAt = time.Now().UTC()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.brokers == nil {
+		c.brokers = make(map[string]*models.CredentialBroker)
+	}
+	key := brokerKey(broker.CreatorAddress, broker.Name)
+	if existing, ok := c.brokers[key]; ok && existing.OwnerDeviceID != broker.OwnerDeviceID {
+		return errNotBrokerOwner
+	}
+	c.brokers[key] = broker
+	return nil
+}
+
+// RemoveBroker deletes a credential broker for the device that registered it
+func (c *RunnerController) RemoveBroker(creatorAddress, name, requesterID string) error {
+	key := brokerKey(creatorAddress, name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	broker, ok := c.brokers[key]
+	switch {
+	case !ok:
+		return errBrokerNotFound
+	case requesterID == "" || requesterID != broker.OwnerDeviceID:
+		return errNotBrokerOwner
+	}
+	delete(c.brokers, key)
+	return nil
+}
+
+func (c *RunnerController) getBroker(creatorAddress, name string) (*models.CredentialBroker, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	broker, ok := c.brokers[brokerKey(creatorAddress, name)]
+	return broker, ok
+}
+
+// checkCredentialBrokers makes sure every broker a task asks for credentials
+// from is registered by the task's creator, from the device that created it
+func (c *RunnerController) checkCredentialBrokers(task *models.Task) error {
+	if task.Type != models.TaskTypeDocker {
+		return nil
+	}
+	var config models.TaskConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return err
+	}
+	if len(config.Credentials) > 0 && task.CreatorAddress == "" {
+		return errors.New("tasks that request credentials need a creator_address")
+	}
+	for _, request := range config.Credentials {
+		broker, ok := c.getBroker(task.CreatorAddress, request.Broker)
+		if !ok {
+			return fmt.Errorf("creator has no credential broker %q", request.Broker)
+		}
+		if task.CreatorDeviceID == "" || task.CreatorDeviceID != broker.OwnerDeviceID {
+			return fmt.Errorf("credential broker %q: %w", request.Broker, errNotBrokerOwner)
+		}
+	}
+	return nil
+}
+
+// issueCredentials mints the credentials the task asked for. Only the runner the
+// task is assigned to gets them, and only until it submitted a result. Every
+// issued and refused request is recorded in the task's events. On failure it
+// returns the HTTP status to answer with.
+func (c *RunnerController) issueCredentials(ctx context.Context, taskID, deviceID string) ([]models.TaskCredentials, int, error) {
+	log := gologger.WithComponent("credentials")
+
+	c.mu.RLock()
+	assigned, ok := c.assigned[taskID]
+	_, submitted := c.results[taskID]
+	client := c.credentialClient
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, http.StatusNotFound, errors.New("task is not running")
+	}
+	deny := func(status int, err error) ([]models.TaskCredentials, int, error) {
+		log.Warn().Err(err).Str("task_id", taskID).Str("device_id", deviceID).Msg("Refused task credentials")
+		c.recordEvent(taskID, models.TaskEvent{Type: models.TaskEventCredentialsDenied, DeviceID: deviceID, Detail: err.Error()})
+		return nil, status, err
+	}
+	if assigned.deviceID != deviceID {
+		return deny(http.StatusForbidden, errors.New("task is assigned to another runner"))
+	}
+	if submitted {
+		return deny(http.StatusConflict, errors.New("task already submitted its result"))
+	}
+
+	task := assigned.task
+	var config models.TaskConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("invalid task config: %w", err)
+	}
+
+	issued := make([]models.TaskCredentials, 0, len(config.Credentials))
+	for _, request := range config.Credentials {
+		broker, ok := c.getBroker(task.CreatorAddress, request.Broker)
+		if !ok {
+			return deny(http.StatusNotFound, fmt.Errorf("credential broker %q was removed", request.Broker))
+		}
+		exchange, err := credentials.NewExchange(broker, client)
+		if err != nil {
+			return deny(http.StatusInternalServerError, err)
+		}
+		minted, err := exchange.Mint(ctx, "parity-"+taskID, credentials.TTL(broker, task))
+		if err != nil {
+			return deny(http.StatusBadGateway, fmt.Errorf("broker %q: %w", broker.Name, err))
+		}
+		minted.Broker = broker.Name
+		minted = credentials.Prefixed(minted, request.EnvPrefix)
+		issued = append(issued, *minted)
+
+		c.recordEvent(taskID, models.TaskEvent{
+			Type:     models.TaskEventCredentialsIssued,
+			DeviceID: deviceID,
+			Detail:   fmt.Sprintf("broker %s (%s), expires %s", broker.Name, broker.Kind, minted.ExpiresAt.Format(time.RFC3339)),
+		})
+		log.Info().
+			Str("task_id", taskID).
+			Str("device_id", deviceID).
+			Str("broker", broker.Name).
+			Time("expires_at", minted.ExpiresAt).
+			Msg("Issued task credentials")
+	}
+	return issued, 0, nil
+}
+
+func (c *RunnerController) handleRegisterBroker(ctx *gin.Context) {
+	var broker models.CredentialBroker
+	if err := ctx.BindJSON(&broker); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	broker.CreatedAt = time.Time{}
+	broker.OwnerDeviceID = ctx.GetHeader("X-Device-ID")
+	if err := c.RegisterBroker(&broker); errors.Is(err, errNotBrokerOwner) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, broker.Redacted())
+}
+
+func (c *RunnerController) handleListBrokers(ctx *gin.Context) {
+	creator := ctx.Query("creator_address")
+	if creator == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "creator_address is required"})
+		return
+	}
+
+	requesterID := ctx.GetHeader("X-Device-ID")
+	if requesterID == "" {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": errNotBrokerOwner.Error()})
+		return
+	}
+
+	c.mu.RLock()
+	brokers := make([]*models.CredentialBroker, 0)
+	for _, broker := range c.brokers {
+		if strings.EqualFold(broker.CreatorAddress, creator) && broker.OwnerDeviceID == requesterID {
+			brokers = append(brokers, broker.Redacted())
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(brokers, func(i, j int) bool { return brokers[i].Name < brokers[j].Name })
+	ctx.JSON(http.StatusOK, gin.H{"brokers": brokers})
+}
+
+func (c *RunnerController) handleRemoveBroker(ctx *gin.Context) {
+	err := c.RemoveBroker(ctx.Query("creator_address"), ctx.Param("name"), ctx.GetHeader("X-Device-ID"))
+	switch {
+	case errors.Is(err, errBrokerNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Credential broker not found"})
+	case errors.Is(err, errNotBrokerOwner):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+func (c *RunnerController) handleTaskCredentials(ctx *gin.Context) {
+	issued, status, err := c.issueCredentials(ctx.Request.Context(), ctx.Param("taskID"), ctx.GetHeader("X-Device-ID"))
+	if err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"credentials": issued})
+}
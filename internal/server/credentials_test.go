@@ -0,0 +1,277 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	brokerCreator = "0x00000000000000000000000000000000000000c1"
+	brokerDevice  = "creator-device"
+	// brokerTokenURL is a public name the broker is registered with; the
+	// client of newTokenServer dials the test server for it
+	brokerTokenURL = "https://example.com/token"
+)
+
+func newTokenServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "short-lived", "expires_in": 600})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// tokenServerClient connects to server whatever host it is asked for, which
+// the server's certificate covers for example.com
+func tokenServerClient(server *httptest.Server) *http.Client {
+	client := server.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	client.Transport = transport
+	return client
+}
+
+func postBroker(t *testing.T, router http.Handler, deviceID, tokenURL string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := json.Marshal(models.CredentialBroker{
+		Name:           "api",
+		CreatorAddress: brokerCreator,
+		Kind:           models.CredentialBrokerOIDC,
+		OIDC:           &models.OIDCExchange{TokenURL: tokenURL, ClientID: "client", ClientSecret: "long-lived-secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/credentials/brokers", bytes.NewReader(body))
+	if deviceID != "" {
+		req.Header.Set("X-Device-ID", deviceID)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func registerBroker(t *testing.T, router http.Handler) {
+	t.Helper()
+
+	rec := postBroker(t, router, brokerDevice, brokerTokenURL)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register broker = %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "long-lived-secret") {
+		t.Fatal("broker secret was returned")
+	}
+}
+
+func createCredentialTask(t *testing.T, router http.Handler) (*httptest.ResponseRecorder, models.Task) {
+	t.Helper()
+
+	body := []byte(`{"title":"upload","type":"docker","nonce":"n","creator_address":"` + brokerCreator + `",
+		"environment":{"type":"docker"},
+		"config":{"image_name":"alpine","credentials":[{"broker":"api","env_prefix":"UPSTREAM_"}]}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", brokerDevice)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var task models.Task
+	json.Unmarshal(rec.Body.Bytes(), &task)
+	return rec, task
+}
+
+func fetchCredentials(router http.Handler, taskID, deviceID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/runners/tasks/"+taskID+"/credentials", nil)
+	req.Header.Set("X-Device-ID", deviceID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTaskRequiresRegisteredBroker(t *testing.T) {
+	router := newTestRouter(NewRunnerController(nil))
+
+	if rec, _ := createCredentialTask(t, router); rec.Code != http.StatusBadRequest {
+		t.Fatalf("create without broker = %d, want 400", rec.Code)
+	}
+}
+
+func TestCredentialsOnlyForAssignedRunner(t *testing.T) {
+	tokenServer := newTokenServer(t)
+	controller := NewRunnerController(nil)
+	controller.SetCredentialClient(tokenServerClient(tokenServer))
+	router := newTestRouter(controller)
+	registerBroker(t, router)
+
+	rec, task := createCredentialTask(t, router)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body.String())
+	}
+	taskID := task.ID.String()
+
+	if rec := fetchCredentials(router, taskID, "device-1"); rec.Code != http.StatusNotFound {
+		t.Fatalf("fetch before start = %d, want 404", rec.Code)
+	}
+	if status, message := controller.startTask(context.Background(), taskID, "device-1"); status != 0 {
+		t.Fatalf("startTask() = %d %s", status, message)
+	}
+	if rec := fetchCredentials(router, taskID, "device-2"); rec.Code != http.StatusForbidden {
+		t.Fatalf("fetch by another runner = %d, want 403", rec.Code)
+	}
+
+	rec = fetchCredentials(router, taskID, "device-1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fetch = %d %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Credentials []models.TaskCredentials `json:"credentials"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Credentials) != 1 || response.Credentials[0].Env["UPSTREAM_ACCESS_TOKEN"] != "short-lived" {
+		t.Fatalf("credentials = %+v", response.Credentials)
+	}
+
+	var types []models.TaskEventType
+	for _, event := range controller.TaskEvents(taskID) {
+		types = append(types, event.Type)
+		if strings.Contains(event.Detail, "short-lived") {
+			t.Errorf("event %s leaks the token: %s", event.Type, event.Detail)
+		}
+	}
+	want := []models.TaskEventType{
+		models.TaskEventQueued,
+		models.TaskEventStarted,
+		models.TaskEventCredentialsDenied,
+		models.TaskEventCredentialsIssued,
+	}
+	if len(types) != len(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("events = %v, want %v", types, want)
+		}
+	}
+}
+
+func TestBrokersBelongToTheDeviceThatRegisteredThem(t *testing.T) {
+	router := newTestRouter(NewRunnerController(nil))
+	registerBroker(t, router)
+
+	if rec := postBroker(t, router, "", brokerTokenURL); rec.Code != http.StatusForbidden {
+		t.Fatalf("register without a device = %d, want 403", rec.Code)
+	}
+	if rec := postBroker(t, router, "other-device", "https://attacker.example/token"); rec.Code != http.StatusForbidden {
+		t.Fatalf("replace by another device = %d, want 403", rec.Code)
+	}
+	if rec := postBroker(t, router, brokerDevice, "https://example.org/token"); rec.Code != http.StatusCreated {
+		t.Fatalf("replace by its owner = %d %s", rec.Code, rec.Body.String())
+	}
+
+	list := func(deviceID string) []models.CredentialBroker {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/credentials/brokers?creator_address="+brokerCreator, nil)
+		req.Header.Set("X-Device-ID", deviceID)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var response struct {
+			Brokers []models.CredentialBroker `json:"brokers"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return response.Brokers
+	}
+	if brokers := list("other-device"); len(brokers) != 0 {
+		t.Fatalf("brokers listed for another device = %+v", brokers)
+	}
+	if brokers := list(brokerDevice); len(brokers) != 1 || brokers[0].OIDC.TokenURL != "https://example.org/token" {
+		t.Fatalf("brokers listed for the owner = %+v", brokers)
+	}
+
+	remove := func(deviceID string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/credentials/brokers/api?creator_address="+brokerCreator, nil)
+		req.Header.Set("X-Device-ID", deviceID)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := remove("other-device"); code != http.StatusForbidden {
+		t.Fatalf("remove by another device = %d, want 403", code)
+	}
+	if code := remove(brokerDevice); code != http.StatusNoContent {
+		t.Fatalf("remove by its owner = %d, want 204", code)
+	}
+}
+
+func TestTaskCannotUseAnotherDevicesBroker(t *testing.T) {
+	router := newTestRouter(NewRunnerController(nil))
+	registerBroker(t, router)
+
+	// The creator is the device the task is submitted from, whatever the body says
+	body := []byte(`{"title":"upload","type":"docker","nonce":"n","creator_address":"` + brokerCreator + `","creator_device_id":"` + brokerDevice + `",
+		"environment":{"type":"docker"},
+		"config":{"image_name":"alpine","credentials":[{"broker":"api"}]}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", "other-device")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("create with another device's broker = %d, want 400", rec.Code)
+	}
+}
+
+func TestTaskViewsDoNotShowCreatorDevice(t *testing.T) {
+	controller := NewRunnerController(nil)
+	controller.SetCredentialClient(tokenServerClient(newTokenServer(t)))
+	router := newTestRouter(controller)
+	registerBroker(t, router)
+
+	rec, task := createCredentialTask(t, router)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body.String())
+	}
+
+	views := map[string]*http.Request{
+		"task":            httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+task.ID.String(), nil),
+		"available tasks": httptest.NewRequest(http.MethodGet, "/api/v1/runners/tasks/available", nil),
+	}
+	views["available tasks"].Header.Set("X-Device-ID", "runner-1")
+	for name, req := range views {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s = %d %s", name, rec.Code, rec.Body.String())
+		}
+		if strings.Contains(rec.Body.String(), brokerDevice) {
+			t.Fatalf("%s shows the creator's device: %s", name, rec.Body.String())
+		}
+	}
+}
+
+func TestBrokerExchangeMustBePublicHTTPS(t *testing.T) {
+	router := newTestRouter(NewRunnerController(nil))
+
+	for _, tokenURL := range []string{
+		"http://example.com/token",
+		"https://127.0.0.1/token",
+		"https://localhost:8443/token",
+		"https://169.254.169.254/latest/meta-data",
+		"https://10.0.0.8/token",
+		"https://[::1]/token",
+	} {
+		if rec := postBroker(t, router, brokerDevice, tokenURL); rec.Code != http.StatusBadRequest {
+			t.Errorf("register %s = %d, want 400", tokenURL, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// priceOracleTimeout bounds asking the price oracle about one payout
+const priceOracleTimeout = 10 * time.Second
+
+// PriceOracle prices the reward token in a fiat currency
+type PriceOracle interface {
+	TokenPrice(ctx context.Context, at time.Time) (float64, error)
+}
+
+// SetPriceOracle values every reward in currency at the token price of the
+// moment it is paid, for the fiat columns of earnings reports
+func (c *RunnerController) SetPriceOracle(oracle PriceOracle, currency string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.priceOracle = oracle
+	c.fiatCurrency = currency
+}
+
+// recordWallet remembers the wallet a runner registered with, the one its
+// rewards are reported under
+func (c *RunnerController) recordWallet(deviceID, walletAddress string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.wallets == nil {
+		c.wallets = make(map[string]string)
+	}
+	c.wallets[deviceID] = walletAddress
+}
+
+// recordPaidReward adds a payout that reached the chain to the ledger earnings
+// reports are built from
+func (c *RunnerController) recordPaidReward(payout Payout, at time.Time) {
+	c.mu.RLock()
+	oracle, currency := c.priceOracle, c.fiatCurrency
+	c.mu.RUnlock()
+
+	reward := models.PaidReward{
+		PaidAt:        at.UTC(),
+		TaskID:        payout.TaskID,
+		TaskType:      payout.TaskType,
+		DeviceID:      payout.DeviceID,
+		WalletAddress: payout.WalletAddress,
+		Amount:        payout.Amount,
+		TxHash:        payout.TxHash,
+		ChainID:       payout.ChainID,
+	}
+	if oracle != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), priceOracleTimeout)
+		price, err := oracle.TokenPrice(ctx, at)
+		cancel()
+		if err != nil {
+			log := gologger.WithComponent("runner_controller")
+			log.Warn().Err(err).Str("task_id", payout.TaskID).Msg("Failed to price paid reward, reporting it without fiat value")
+		} else {
+			value := price * payout.Amount
+			reward.TokenPrice = &price
+			reward.FiatValue = &value
+			reward.Currency = currency
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paidRewards = append(c.paidRewards, reward)
+}
+
+// EarningsReport is what the runners registered with walletAddress were paid in
+// the month
+func (c *RunnerController) EarningsReport(walletAddress string, month time.Time) *models.EarningsReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return models.NewEarningsReport(walletAddress, month, c.paidRewards)
+}
+
+func (c *RunnerController) handleEarningsReport(ctx *gin.Context) {
+	month, err := models.ParseReportMonth(ctx.Query("month"), time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	report := c.EarningsReport(ctx.Param("address"), month)
+
+	switch ctx.DefaultQuery("format", "json") {
+	case "json":
+		ctx.JSON(http.StatusOK, report)
+	case "csv":
+		ctx.Header("Content-Disposition", `attachment; filename="earnings-`+report.Month+`.csv"`)
+		ctx.Header("Content-Type", "text/csv")
+		ctx.Status(http.StatusOK)
+		if err := report.WriteCSV(ctx.Writer); err != nil {
+			log := gologger.WithComponent("runner_controller")
+			log.Error().Err(err).Msg("Failed to write earnings report")
+		}
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// txChain reports a transaction hash for every transfer of fakeChain
+type txChain struct {
+	*fakeChain
+}
+
+func (c *txChain) TransferRewardTx(ctx context.Context, deviceID string, amount float64) (string, error) {
+	if err := c.TransferReward(ctx, deviceID, amount); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("0xtx%d", len(c.transfers)), nil
+}
+
+type fixedOracle struct {
+	price float64
+	err   error
+}
+
+func (o fixedOracle) TokenPrice(ctx context.Context, at time.Time) (float64, error) {
+	return o.price, o.err
+}
+
+func TestEarningsReportListsPaidRewardsOfTheWallet(t *testing.T) {
+	chain := &txChain{&fakeChain{stakes: map[string]*big.Int{"device-1": big.NewInt(100)}}}
+	controller := NewRunnerController(nil)
+	controller.SetChainGateway(NewChainGateway(chain, ChainGatewayConfig{}), nil)
+	controller.SetPriceOracle(fixedOracle{price: 2.5}, "USD")
+	router := newTestRouter(controller)
+	registerWithCapabilities(t, router, "device-1", nil)
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	task.Reward = 4
+	controller.AddAvailableTask(task)
+	start := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+task.ID.String()+"/start", nil)
+	start.Header.Set("X-Device-ID", "device-1")
+	router.ServeHTTP(httptest.NewRecorder(), start)
+	if response := postResult(t, router, task.ID); response["payout_status"] != "sent" {
+		t.Fatalf("payout_status = %v, want sent", response["payout_status"])
+	}
+
+	wallet := "0x0000000000000000000000000000000000000001"
+	report := controller.EarningsReport(strings.ToUpper(wallet), time.Now())
+	if report.Payouts != 1 || report.TotalAmount != 4 || report.TotalFiatValue != 10 || report.Currency != "USD" {
+		t.Fatalf("report = %+v, want one payout of 4 worth 10 USD", report)
+	}
+	if reward := report.Rewards[0]; reward.TxHash != "0xtx1" || reward.DeviceID != "device-1" || reward.TaskType != models.TaskTypeCommand {
+		t.Fatalf("reward = %+v", reward)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+wallet+"/earnings?format=csv", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Disposition"), "earnings-") {
+		t.Fatalf("csv report = %d %v: %s", rec.Code, rec.Header(), rec.Body.String())
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil || len(rows) != 2 || rows[1][1] != task.ID.String() || rows[1][6] != "0xtx1" || rows[1][8] != "10" {
+		t.Fatalf("csv rows = %v, %v", rows, err)
+	}
+
+	for query, want := range map[string]int{
+		"?month=2020-01":  http.StatusOK,
+		"?month=january":  http.StatusBadRequest,
+		"?format=parquet": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/wallets/"+wallet+"/earnings"+query, nil))
+		if rec.Code != want {
+			t.Fatalf("report%s = %d, want %d", query, rec.Code, want)
+		}
+	}
+}
+
+func TestEarningsReportKeepsRewardsThatCouldNotBePriced(t *testing.T) {
+	controller := NewRunnerController(nil)
+	controller.SetPriceOracle(fixedOracle{err: errors.New("price feed down")}, "USD")
+	controller.recordPaidReward(Payout{TaskID: "task-1", DeviceID: "device-1", WalletAddress: "0xabc", Amount: 3}, time.Now())
+
+	report := controller.EarningsReport("0xabc", time.Now())
+	if report.Payouts != 1 || report.Unpriced != 1 || report.Rewards[0].FiatValue != nil {
+		t.Fatalf("report = %+v, want the reward kept without a fiat value", report)
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// recordEvent appends to the audit log of a task
+func (c *RunnerController) recordEvent(taskID string, event models.TaskEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.events == nil {
+		c.events = make(map[string][]models.TaskEvent)
+	}
+	c.events[taskID] = append(c.events[taskID], event)
+}
+
+// TaskEvents returns the audit log of a task, oldest first
+func (c *RunnerController) TaskEvents(taskID string) []models.TaskEvent {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]models.TaskEvent(nil), c.events[taskID]...)
+}
+
+func (c *RunnerController) handleGetTaskEvents(ctx *gin.Context) {
+	events := c.TaskEvents(ctx.Param("taskID"))
+	if len(events) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"events": events})
+}
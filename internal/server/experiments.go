@@ -0,0 +1,275 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// maxExperimentBatch bounds the tasks submitted in one experiment request
+const maxExperimentBatch = 5000
+
+var (
+	errNotExperimentCreator = errors.New("only the experiment's creator can do this")
+	errExperimentCancelled  = errors.New("experiment is cancelled")
+)
+
+// experimentRecord is an experiment with the IDs of its tasks in submission order
+type experimentRecord struct {
+	experiment *models.Experiment
+	taskIDs    []uuid.UUID
+	// creatorDeviceID is the device the experiment was created from, the only
+	// one that can add tasks to it or cancel it
+	creatorDeviceID string
+	// changes serializes adding tasks with cancelling, so a cancel withdraws
+	// every task an add queued
+	changes sync.Mutex
+}
+
+// ownedBy reports whether deviceID created the experiment
+func (r *experimentRecord) ownedBy(deviceID string) bool {
+	return deviceID != "" && deviceID == r.creatorDeviceID
+}
+
+type experimentRequest struct {
+	Name           string         `json:"name"`
+	Description    string         `json:"description"`
+	CreatorAddress string         `json:"creator_address"`
+	Labels         models.Labels  `json:"labels"`
+	Tasks          []*models.Task `json:"tasks"`
+}
+
+func (c *RunnerController) handleCreateExperiment(ctx *gin.Context) {
+	var req experimentRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	experiment := models.NewExperiment(req.Name)
+	experiment.Description = req.Description
+	experiment.CreatorAddress = req.CreatorAddress
+	experiment.Labels = req.Labels
+	if err := experiment.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	record := &experimentRecord{experiment: experiment, creatorDeviceID: ctx.GetHeader("X-Device-ID")}
+	if status, err := c.addExperimentTasks(ctx.Request.Context(), record, req.Tasks, ctx.GetHeader("X-Device-ID")); err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, c.summarizeExperiment(record))
+}
+
+func (c *RunnerController) handleAddExperimentTasks(ctx *gin.Context) {
+	record, ok := c.getExperiment(ctx.Param("experimentID"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+	if !record.ownedBy(ctx.GetHeader("X-Device-ID")) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": errNotExperimentCreator.Error()})
+		return
+	}
+
+	var req struct {
+		Tasks []*models.Task `json:"tasks"`
+	}
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if status, err := c.addExperimentTasks(ctx.Request.Context(), record, req.Tasks, ctx.GetHeader("X-Device-ID")); err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, c.summarizeExperiment(record))
+}
+
+// addExperimentTasks admits every task before queueing any, so a bad task in a
+// batch does not leave the experiment half submitted. The tasks belong to the
+// device that submitted them. Nothing is added to a cancelled experiment.
+func (c *RunnerController) addExperimentTasks(ctx context.Context, record *experimentRecord, tasks []*models.Task, creatorDeviceID string) (int, error) {
+	if len(tasks) == 0 {
+		return http.StatusBadRequest, fmt.Errorf("at least one task is required")
+	}
+	if len(tasks) > maxExperimentBatch {
+		return http.StatusBadRequest, fmt.Errorf("at most %d tasks can be submitted at once", maxExperimentBatch)
+	}
+
+	experimentID := record.experiment.ID
+	for i, submitted := range tasks {
+		task := models.NewTask()
+		if submitted != nil {
+			*task = *submitted
+			task.ID = uuid.New()
+			task.CreatedAt = time.Now()
+			task.UpdatedAt = task.CreatedAt
+		}
+		if task.CreatorAddress == "" {
+			task.CreatorAddress = record.experiment.CreatorAddress
+		}
+		if task.Labels == nil && record.experiment.Labels != nil {
+			task.Labels = record.experiment.Labels
+		}
+		task.ExperimentID = &experimentID
+		task.CreatorDeviceID = creatorDeviceID
+
+		if status, err := c.admitTask(ctx, task); err != nil {
+			return status, fmt.Errorf("task %d: %w", i, err)
+		}
+		tasks[i] = task
+	}
+
+	record.changes.Lock()
+	defer record.changes.Unlock()
+
+	c.mu.Lock()
+	if record.experiment.Status == models.ExperimentStatusCancelled {
+		c.mu.Unlock()
+		return http.StatusConflict, errExperimentCancelled
+	}
+	if c.experiments[experimentID.String()] == nil {
+		c.experiments[experimentID.String()] = record
+	}
+	for _, task := range tasks {
+		record.taskIDs = append(record.taskIDs, task.ID)
+	}
+	record.experiment.UpdatedAt = time.Now()
+	if record.experiment.Status == models.ExperimentStatusCompleted {
+		record.experiment.Status = models.ExperimentStatusRunning
+	}
+	c.mu.Unlock()
+
+	for _, task := range tasks {
+		c.queueTask(task)
+	}
+	return 0, nil
+}
+
+func (c *RunnerController) getExperiment(id string) (*experimentRecord, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	record, ok := c.experiments[id]
+	return record, ok
+}
+
+func (c *RunnerController) handleGetExperiment(ctx *gin.Context) {
+	record, ok := c.getExperiment(ctx.Param("experimentID"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, c.summarizeExperiment(record))
+}
+
+// handleCancelExperiment withdraws every queued task of the experiment. Tasks a
+// runner has already started run to completion and are still reported. Only
+// the device that created the experiment can cancel it.
+func (c *RunnerController) handleCancelExperiment(ctx *gin.Context) {
+	log := gologger.WithComponent("runner_controller")
+
+	record, ok := c.getExperiment(ctx.Param("experimentID"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+	if !record.ownedBy(ctx.GetHeader("X-Device-ID")) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": errNotExperimentCreator.Error()})
+		return
+	}
+
+	record.changes.Lock()
+	defer record.changes.Unlock()
+
+	c.mu.Lock()
+	if record.experiment.Status == models.ExperimentStatusCancelled {
+		c.mu.Unlock()
+		ctx.JSON(http.StatusOK, c.summarizeExperiment(record))
+		return
+	}
+	now := time.Now()
+	record.experiment.Status = models.ExperimentStatusCancelled
+	record.experiment.CancelledAt = &now
+	record.experiment.UpdatedAt = now
+	taskIDs := append([]uuid.UUID{}, record.taskIDs...)
+	c.mu.Unlock()
+
+	withdrawn := 0
+	for _, taskID := range taskIDs {
+		if c.RemoveAvailableTask(taskID.String()) != nil {
+			withdrawn++
+		}
+	}
+
+	log.Info().
+		Str("experiment_id", record.experiment.ID.String()).
+		Int("withdrawn", withdrawn).
+		Msg("Experiment cancelled")
+
+	ctx.JSON(http.StatusOK, c.summarizeExperiment(record))
+}
+
+func (c *RunnerController) summarizeExperiment(record *experimentRecord) models.ExperimentSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	queued := make(map[uuid.UUID]bool, len(c.availableTasks))
+	for _, task := range c.availableTasks {
+		queued[task.ID] = true
+	}
+
+	experiment := record.experiment
+	summary := models.ExperimentSummary{
+		TaskIDs: append([]uuid.UUID{}, record.taskIDs...),
+	}
+	summary.Progress.Total = len(record.taskIDs)
+
+	for _, taskID := range record.taskIDs {
+		if result, ok := c.results[taskID.String()]; ok {
+			if result.ExitCode != 0 || result.Error != "" {
+				summary.Progress.Failed++
+			} else {
+				summary.Progress.Completed++
+			}
+			summary.Usage.Add(models.MetricsFromResult(result))
+			continue
+		}
+
+		switch _, running := c.assigned[taskID.String()]; {
+		case running:
+			summary.Progress.Running++
+		case queued[taskID]:
+			summary.Progress.Pending++
+		case experiment.Status == models.ExperimentStatusCancelled:
+			summary.Progress.Cancelled++
+		default:
+			// Started by a runner that has not reported back yet
+			summary.Progress.Running++
+		}
+	}
+
+	if experiment.Status == models.ExperimentStatusRunning && summary.Progress.Done() {
+		experiment.Status = models.ExperimentStatusCompleted
+		experiment.UpdatedAt = time.Now()
+	}
+
+	copied := *experiment
+	summary.Experiment = &copied
+	return summary
+}
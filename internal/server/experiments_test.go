@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// sendExperimentRequest sends body as deviceID and returns the response
+func sendExperimentRequest(router *gin.Engine, method, path, deviceID string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("X-Device-ID", deviceID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func doExperimentRequest(t *testing.T, router *gin.Engine, method, path string, body interface{}) models.ExperimentSummary {
+	t.Helper()
+
+	rec := sendExperimentRequest(router, method, path, "creator-1", body)
+	if rec.Code != http.StatusOK && rec.Code != http.StatusCreated {
+		t.Fatalf("%s %s status = %d: %s", method, path, rec.Code, rec.Body.String())
+	}
+
+	var summary models.ExperimentSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("failed to decode experiment summary: %v", err)
+	}
+	return summary
+}
+
+func TestExperimentAggregatesAndCancels(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	shard := func(title string) map[string]interface{} {
+		return map[string]interface{}{
+			"title":  title,
+			"type":   "command",
+			"config": map[string]interface{}{"command": []string{"echo", title}},
+		}
+	}
+
+	created := doExperimentRequest(t, router, http.MethodPost, "/api/v1/experiments", map[string]interface{}{
+		"name":            "sweep",
+		"creator_address": "0xabc",
+		"tasks":           []interface{}{shard("shard-0"), shard("shard-1"), shard("shard-2")},
+	})
+	if created.Progress.Total != 3 || created.Progress.Pending != 3 {
+		t.Fatalf("new experiment progress = %+v", created.Progress)
+	}
+	experimentPath := "/api/v1/experiments/" + created.Experiment.ID.String()
+
+	// One shard succeeds, one is running, one is still queued
+	started := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+created.TaskIDs[0].String()+"/start", nil)
+	started.Header.Set("X-Device-ID", "device-1")
+	router.ServeHTTP(httptest.NewRecorder(), started)
+	postResult(t, router, created.TaskIDs[0])
+
+	started = httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+created.TaskIDs[1].String()+"/start", nil)
+	started.Header.Set("X-Device-ID", "device-1")
+	router.ServeHTTP(httptest.NewRecorder(), started)
+
+	status := doExperimentRequest(t, router, http.MethodGet, experimentPath, nil)
+	if status.Progress.Completed != 1 || status.Progress.Running != 1 || status.Progress.Pending != 1 {
+		t.Fatalf("experiment progress = %+v", status.Progress)
+	}
+	if status.Usage.TaskCount != 1 {
+		t.Fatalf("combined usage task count = %d, want 1", status.Usage.TaskCount)
+	}
+
+	cancelled := doExperimentRequest(t, router, http.MethodPost, experimentPath+"/cancel", nil)
+	if cancelled.Experiment.Status != models.ExperimentStatusCancelled || cancelled.Progress.Cancelled != 1 || cancelled.Progress.Running != 1 {
+		t.Fatalf("cancelled experiment = %+v, progress %+v", cancelled.Experiment, cancelled.Progress)
+	}
+	if task := controller.RemoveAvailableTask(created.TaskIDs[2].String()); task != nil {
+		t.Fatal("queued shard should have been withdrawn on cancel")
+	}
+}
+
+func TestExperimentOnlyChangesForItsCreator(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	shard := map[string]interface{}{"title": "shard", "type": "command", "config": map[string]interface{}{"command": []string{"echo"}}}
+	created := doExperimentRequest(t, router, http.MethodPost, "/api/v1/experiments", map[string]interface{}{
+		"name":  "sweep",
+		"tasks": []interface{}{shard},
+	})
+	experimentPath := "/api/v1/experiments/" + created.Experiment.ID.String()
+	add := map[string]interface{}{"tasks": []interface{}{shard}}
+
+	for _, deviceID := range []string{"", "other-device"} {
+		if rec := sendExperimentRequest(router, http.MethodPost, experimentPath+"/tasks", deviceID, add); rec.Code != http.StatusForbidden {
+			t.Fatalf("add as %q = %d, want %d", deviceID, rec.Code, http.StatusForbidden)
+		}
+		if rec := sendExperimentRequest(router, http.MethodPost, experimentPath+"/cancel", deviceID, nil); rec.Code != http.StatusForbidden {
+			t.Fatalf("cancel as %q = %d, want %d", deviceID, rec.Code, http.StatusForbidden)
+		}
+	}
+	if status := doExperimentRequest(t, router, http.MethodGet, experimentPath, nil); status.Progress.Total != 1 || status.Experiment.Status == models.ExperimentStatusCancelled {
+		t.Fatalf("experiment after refused changes = %+v, progress %+v", status.Experiment, status.Progress)
+	}
+
+	doExperimentRequest(t, router, http.MethodPost, experimentPath+"/cancel", nil)
+	if rec := sendExperimentRequest(router, http.MethodPost, experimentPath+"/tasks", "creator-1", add); rec.Code != http.StatusConflict {
+		t.Fatalf("add after cancel = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// The check is made where tasks are added, not only in the handler
+	record, _ := controller.getExperiment(created.Experiment.ID.String())
+	task := models.NewTask()
+	task.Title, task.Type, task.Config = "shard", models.TaskTypeCommand, json.RawMessage(`{"command":["echo"]}`)
+	if status, err := controller.addExperimentTasks(context.Background(), record, []*models.Task{task}, "creator-1"); status != http.StatusConflict || err != errExperimentCancelled {
+		t.Fatalf("addExperimentTasks after cancel = %d %v, want %d", status, err, http.StatusConflict)
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+)
+
+// ErrFaucetCooldown is returned when an address or device asks again too soon
+var ErrFaucetCooldown = errors.New("faucet already used recently")
+
+// TokenSender transfers test tokens to a wallet and returns the transaction hash
+type TokenSender interface {
+	SendTokens(ctx context.Context, address string, amount float64) (string, error)
+}
+
+type FaucetConfig struct {
+	// Amount is the number of test tokens sent per request
+	Amount float64
+	// Cooldown is how long a wallet or device waits between requests
+	Cooldown time.Duration
+}
+
+func DefaultFaucetConfig() FaucetConfig {
+	return FaucetConfig{
+		Amount:   100,
+		Cooldown: 24 * time.Hour,
+	}
+}
+
+// Faucet hands out testnet tokens so new runners can stake without real funds.
+// It must only be configured on testnet servers.
+type Faucet struct {
+	sender TokenSender
+	config FaucetConfig
+
+	mu        sync.Mutex
+	lastGrant map[string]time.Time
+}
+
+func NewFaucet(sender TokenSender, config FaucetConfig) *Faucet {
+	defaults := DefaultFaucetConfig()
+	if config.Amount <= 0 {
+		config.Amount = defaults.Amount
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = defaults.Cooldown
+	}
+	return &Faucet{
+		sender:    sender,
+		config:    config,
+		lastGrant: make(map[string]time.Time),
+	}
+}
+
+// Grant sends the configured amount to address. Both the address and the device
+// are rate limited so one machine cannot drain the faucet with fresh wallets.
+func (f *Faucet) Grant(ctx context.Context, address, deviceID string) (string, float64, error) {
+	keys := []string{"address:" + strings.ToLower(address)}
+	if deviceID != "" {
+		keys = append(keys, "device:"+deviceID)
+	}
+
+	now := time.Now()
+	f.mu.Lock()
+	for _, key := range keys {
+		if last, ok := f.lastGrant[key]; ok && now.Sub(last) < f.config.Cooldown {
+			f.mu.Unlock()
+			return "", 0, fmt.Errorf("%w, try again in %s", ErrFaucetCooldown, (f.config.Cooldown - now.Sub(last)).Truncate(time.Minute))
+		}
+	}
+	// Reserve before sending so concurrent requests cannot both pass the check
+	for _, key := range keys {
+		f.lastGrant[key] = now
+	}
+	f.mu.Unlock()
+
+	txHash, err := f.sender.SendTokens(ctx, address, f.config.Amount)
+	if err != nil {
+		f.mu.Lock()
+		for _, key := range keys {
+			delete(f.lastGrant, key)
+		}
+		f.mu.Unlock()
+		return "", 0, fmt.Errorf("failed to send test tokens: %w", err)
+	}
+	return txHash, f.config.Amount, nil
+}
+
+// SetFaucet serves testnet tokens on POST /api/v1/faucet
+func (c *RunnerController) SetFaucet(faucet *Faucet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faucet = faucet
+}
+
+func (c *RunnerController) handleFaucet(ctx *gin.Context) {
+	log := gologger.WithComponent("runner_controller")
+
+	c.mu.RLock()
+	faucet := c.faucet
+	c.mu.RUnlock()
+
+	if faucet == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "faucet is not available on this network"})
+		return
+	}
+
+	var req struct {
+		Address  string `json:"address"`
+		DeviceID string `json:"device_id"`
+	}
+	if err := ctx.BindJSON(&req); err != nil || !common.IsHexAddress(req.Address) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "a valid wallet address is required"})
+		return
+	}
+
+	txHash, amount, err := faucet.Grant(ctx.Request.Context(), req.Address, req.DeviceID)
+	if errors.Is(err, ErrFaucetCooldown) {
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("address", req.Address).Msg("Faucet transfer failed")
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "faucet transfer failed"})
+		return
+	}
+
+	log.Info().
+		Str("address", req.Address).
+		Str("device_id", req.DeviceID).
+		Float64("amount", amount).
+		Str("tx_hash", txHash).
+		Msg("Sent testnet tokens")
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"address": req.Address,
+		"amount":  amount,
+		"tx_hash": txHash,
+	})
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeTokenSender struct {
+	sent []string
+}
+
+func (f *fakeTokenSender) SendTokens(ctx context.Context, address string, amount float64) (string, error) {
+	f.sent = append(f.sent, address)
+	return "0xabc", nil
+}
+
+func TestFaucetGrantsOncePerCooldown(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	request := func(address, deviceID string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"address": address, "device_id": deviceID})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/faucet", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	wallet := "0x7465E7a637f66cb7b294B856A25bc84aBfF1d247"
+	if rec := request(wallet, "device-1"); rec.Code != http.StatusNotFound {
+		t.Fatalf("faucet without configuration status = %d, want 404", rec.Code)
+	}
+
+	sender := &fakeTokenSender{}
+	controller.SetFaucet(NewFaucet(sender, FaucetConfig{Amount: 50}))
+
+	rec := request(wallet, "device-1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("faucet status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	var grant map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &grant); err != nil || grant["amount"] != float64(50) || grant["tx_hash"] != "0xabc" {
+		t.Fatalf("faucet response = %v, %v", grant, err)
+	}
+
+	if rec := request(wallet, "device-2"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("repeat request for the same wallet status = %d, want 429", rec.Code)
+	}
+	if rec := request("0x0000000000000000000000000000000000000001", "device-1"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("repeat request from the same device status = %d, want 429", rec.Code)
+	}
+	if rec := request("not-an-address", "device-3"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid address status = %d, want 400", rec.Code)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("sent %d transfers, want 1", len(sender.sent))
+	}
+}
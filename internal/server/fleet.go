@@ -0,0 +1,169 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// fleetState is the fleet document last applied and the settings runners
+// reported in their heartbeats
+type fleetState struct {
+	document   *models.FleetDocument
+	generation int64
+	appliedAt  time.Time
+	reported   map[string]reportedSettings
+}
+
+type reportedSettings struct {
+	settings models.RunnerSettings
+	at       time.Time
+}
+
+// RunnerFleetStatus compares what a runner reported to its desired settings.
+// Reported is false until the runner sends its settings in a heartbeat.
+type RunnerFleetStatus struct {
+	DeviceID   string                `json:"device_id"`
+	Reported   bool                  `json:"reported"`
+	InSync     bool                  `json:"in_sync"`
+	Drift      []models.SettingDrift `json:"drift,omitempty"`
+	ReportedAt *time.Time            `json:"reported_at,omitempty"`
+}
+
+// FleetStatus is the drift of every known runner from a fleet document
+type FleetStatus struct {
+	Generation int64               `json:"generation"`
+	AppliedAt  *time.Time          `json:"applied_at,omitempty"`
+	DryRun     bool                `json:"dry_run,omitempty"`
+	InSync     int                 `json:"in_sync"`
+	Drifted    int                 `json:"drifted"`
+	Runners    []RunnerFleetStatus `json:"runners"`
+}
+
+// handleFleetApply replaces the fleet document. With dry_run=true the document is
+// only checked against what runners last reported. The route requires the
+// operator token.
+func (c *RunnerController) handleFleetApply(ctx *gin.Context) {
+	log := gologger.WithComponent("fleet")
+
+	var document models.FleetDocument
+	if err := ctx.BindJSON(&document); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := document.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(ctx.Query("dry_run"))
+	if dryRun {
+		c.mu.RLock()
+		status := c.fleetStatus(&document, c.fleet.generation+1, time.Time{})
+		c.mu.RUnlock()
+		status.DryRun = true
+		ctx.JSON(http.StatusOK, status)
+		return
+	}
+
+	c.mu.Lock()
+	c.fleet.document = &document
+	c.fleet.generation++
+	c.fleet.appliedAt = time.Now()
+	status := c.fleetStatus(&document, c.fleet.generation, c.fleet.appliedAt)
+	c.mu.Unlock()
+
+	log.Info().
+		Int64("generation", status.Generation).
+		Int("runners", len(status.Runners)).
+		Int("drifted", status.Drifted).
+		Msg("Applied fleet document")
+	ctx.JSON(http.StatusOK, status)
+}
+
+func (c *RunnerController) handleFleetStatus(ctx *gin.Context) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.fleet.document == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No fleet document applied"})
+		return
+	}
+	ctx.JSON(http.StatusOK, c.fleetStatus(c.fleet.document, c.fleet.generation, c.fleet.appliedAt))
+}
+
+// fleetStatus reports every runner that is registered, has reported settings or
+// is named in document. The caller holds c.mu.
+func (c *RunnerController) fleetStatus(document *models.FleetDocument, generation int64, appliedAt time.Time) FleetStatus {
+	status := FleetStatus{Generation: generation, Runners: []RunnerFleetStatus{}}
+	if !appliedAt.IsZero() {
+		status.AppliedAt = &appliedAt
+	}
+
+	devices := make(map[string]bool)
+	for deviceID := range c.runnerSelectors {
+		devices[deviceID] = true
+	}
+	for deviceID := range c.fleet.reported {
+		devices[deviceID] = true
+	}
+	for deviceID := range document.Runners {
+		devices[deviceID] = true
+	}
+
+	for deviceID := range devices {
+		runner := RunnerFleetStatus{DeviceID: deviceID}
+		if reported, ok := c.fleet.reported[deviceID]; ok {
+			at := reported.at
+			runner.Reported = true
+			runner.ReportedAt = &at
+			runner.Drift = document.For(deviceID).Drift(reported.settings)
+			runner.InSync = len(runner.Drift) == 0
+		}
+		if runner.InSync {
+			status.InSync++
+		} else {
+			status.Drifted++
+		}
+		status.Runners = append(status.Runners, runner)
+	}
+	sort.Slice(status.Runners, func(i, j int) bool {
+		return status.Runners[i].DeviceID < status.Runners[j].DeviceID
+	})
+	return status
+}
+
+// recordSettings keeps the settings a runner reported and returns the directive
+// for the heartbeat response, or nil when the runner matches the fleet document.
+// A runner that changed its accept labels is routed by the new ones.
+func (c *RunnerController) recordSettings(deviceID string, settings *models.RunnerSettings, at time.Time) *models.FleetDirective {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if settings != nil {
+		c.fleet.reported[deviceID] = reportedSettings{settings: *settings, at: at}
+		if selector, err := models.ParseLabelSelector(settings.AcceptLabels); err == nil {
+			if _, registered := c.runnerSelectors[deviceID]; registered {
+				c.runnerSelectors[deviceID] = selector
+			}
+		}
+	}
+
+	if c.fleet.document == nil {
+		return nil
+	}
+	desired := c.fleet.document.For(deviceID)
+	if desired == (models.FleetSettings{}) {
+		return nil
+	}
+	if settings != nil && len(desired.Drift(*settings)) == 0 {
+		return nil
+	}
+	return &models.FleetDirective{Generation: c.fleet.generation, Settings: desired}
+}
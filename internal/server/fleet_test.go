@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func sendSettingsHeartbeat(t *testing.T, router *gin.Engine, deviceID string, settings models.RunnerSettings) map[string]json.RawMessage {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"type":    "heartbeat",
+		"payload": map[string]interface{}{"settings": settings},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal heartbeat: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/heartbeat", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", deviceID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("heartbeat code = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode heartbeat response: %v", err)
+	}
+	return response
+}
+
+// testOperatorToken is the operator token fleet tests apply documents with
+const testOperatorToken = "operator-secret"
+
+func applyFleet(t *testing.T, router *gin.Engine, document string, query string) (int, FleetStatus) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/fleet/apply"+query, bytes.NewReader([]byte(document)))
+	req.Header.Set("Authorization", "Bearer "+testOperatorToken)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var status FleetStatus
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("failed to decode fleet status: %v", err)
+		}
+	}
+	return rec.Code, status
+}
+
+func TestFleetApplyPushesDirectivesUntilRunnersConverge(t *testing.T) {
+	controller := NewRunnerController(nil)
+	controller.SetOperatorToken(testOperatorToken)
+	router := newTestRouter(controller)
+
+	current := models.RunnerSettings{AcceptLabels: "", MaxConcurrentTasks: 1, TaskTypes: []models.TaskType{}, Models: []string{}}
+	if response := sendSettingsHeartbeat(t, router, "device-1", current); response["directive"] != nil {
+		t.Fatalf("got a directive before any fleet document: %s", response["directive"])
+	}
+
+	document := `{"defaults":{"max_concurrent_tasks":4,"task_types":["docker","llm"]},"runners":{"device-1":{"models":["llama3"]}}}`
+	code, status := applyFleet(t, router, document, "?dry_run=true")
+	if code != http.StatusOK || !status.DryRun || status.Drifted != 1 {
+		t.Fatalf("dry run = %d %+v, want device-1 drifted", code, status)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/fleet", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("fleet status after a dry run = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	code, status = applyFleet(t, router, document, "")
+	if code != http.StatusOK || status.Generation != 1 {
+		t.Fatalf("apply = %d %+v", code, status)
+	}
+	for _, runner := range status.Runners {
+		if runner.DeviceID == "device-1" && len(runner.Drift) != 3 {
+			t.Fatalf("device-1 drift = %+v, want concurrency, task types and models", runner.Drift)
+		}
+	}
+
+	response := sendSettingsHeartbeat(t, router, "device-1", current)
+	var directive models.FleetDirective
+	if err := json.Unmarshal(response["directive"], &directive); err != nil {
+		t.Fatalf("failed to decode directive %s: %v", response["directive"], err)
+	}
+	if directive.Generation != 1 || *directive.Settings.MaxConcurrentTasks != 4 || (*directive.Settings.Models)[0] != "llama3" {
+		t.Fatalf("unexpected directive %+v", directive)
+	}
+	if directive.Settings.AcceptLabels != nil {
+		t.Fatal("unmanaged settings should stay out of the directive")
+	}
+
+	converged := models.RunnerSettings{MaxConcurrentTasks: 4, TaskTypes: []models.TaskType{"llm", "docker"}, Models: []string{"llama3:latest"}}
+	if response := sendSettingsHeartbeat(t, router, "device-1", converged); response["directive"] != nil {
+		t.Fatalf("got a directive for a runner in sync: %s", response["directive"])
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/fleet", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("failed to decode fleet status: %v", err)
+	}
+	if status.InSync != 1 || status.Drifted != 0 || !status.Runners[0].InSync {
+		t.Fatalf("fleet status = %+v, want device-1 in sync", status)
+	}
+}
+
+func TestFleetApplyRejectsInvalidDocuments(t *testing.T) {
+	controller := NewRunnerController(nil)
+	controller.SetOperatorToken(testOperatorToken)
+	router := newTestRouter(controller)
+
+	for _, document := range []string{
+		`{"defaults":{"max_concurrent_tasks":0}}`,
+		`{"defaults":{"task_types":["bogus"]}}`,
+		`{"runners":{"device-1":{"accept_labels":"gpu in ("}}}`,
+		`not json`,
+	} {
+		if code, _ := applyFleet(t, router, document, ""); code != http.StatusBadRequest {
+			t.Errorf("apply %s = %d, want %d", document, code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestFleetApplyNeedsOperatorToken(t *testing.T) {
+	controller := NewRunnerController(nil)
+	controller.SetOperatorToken(testOperatorToken)
+	router := newTestRouter(controller)
+
+	document := `{"defaults":{"task_types":["docker"]}}`
+	for _, header := range []http.Header{
+		{},
+		{"X-Device-Id": {"device-1"}},
+		{"Authorization": {"Bearer wrong"}},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/fleet/apply", bytes.NewReader([]byte(document)))
+		req.Header = header
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("apply with %v = %d, want %d", header, rec.Code, http.StatusUnauthorized)
+		}
+	}
+
+	controller.mu.RLock()
+	applied, generation := controller.fleet.document, controller.fleet.generation
+	controller.mu.RUnlock()
+	if applied != nil || generation != 0 {
+		t.Fatalf("refused apply replaced the fleet document: generation %d", generation)
+	}
+}
@@ -0,0 +1,515 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/peerchannel"
+)
+
+var errGangRetired = errors.New("task's gang failed and was rescheduled")
+
+// gangRecord is a task that runs on several runners at once. Every attempt to
+// run it gets a fresh set of member tasks.
+type gangRecord struct {
+	task   *models.Task
+	config models.GangConfig
+	runs   []*gangRun
+}
+
+func (g *gangRecord) current() *gangRun {
+	return g.runs[len(g.runs)-1]
+}
+
+// gangRun is one attempt of a gang with a member task per rank
+type gangRun struct {
+	gang    *gangRecord
+	attempt int
+	state   models.GangState
+	members []*gangMember
+	startBy time.Time
+	reason  string
+}
+
+type gangMember struct {
+	task     *models.Task
+	deviceID string
+	endpoint string
+	// channelEndpoint and channelKey are only set for gangs with a channel
+	channelEndpoint string
+	channelKey      string
+	// seenAt is when the member last talked to the server
+	seenAt time.Time
+	done   bool
+	result *models.TaskResult
+}
+
+func (r *gangRun) member(taskID string) (int, *gangMember) {
+	for rank, member := range r.members {
+		if member.task.ID.String() == taskID {
+			return rank, member
+		}
+	}
+	return -1, nil
+}
+
+func (r *gangRun) hasDevice(deviceID string) bool {
+	for _, member := range r.members {
+		if member.deviceID == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *gangRun) status() *models.GangStatus {
+	status := &models.GangStatus{
+		GangID:   r.gang.task.ID.String(),
+		Attempt:  r.attempt,
+		State:    r.state,
+		Size:     len(r.members),
+		Port:     r.gang.config.ListenPort(),
+		Members:  make([]models.GangMember, len(r.members)),
+		Reason:   r.reason,
+		Attempts: r.gang.config.Attempts(),
+	}
+	for rank, member := range r.members {
+		status.Members[rank] = models.GangMember{
+			Rank:            rank,
+			TaskID:          member.task.ID.String(),
+			DeviceID:        member.deviceID,
+			Endpoint:        member.endpoint,
+			ChannelEndpoint: member.channelEndpoint,
+			ChannelKey:      member.channelKey,
+			Done:            member.done,
+		}
+	}
+	if !r.startBy.IsZero() {
+		startBy := r.startBy
+		status.StartBy = &startBy
+	}
+	return status
+}
+
+// gangConfig returns the gang settings of a task, or nil for ordinary tasks
+func gangConfig(task *models.Task) *models.GangConfig {
+	if task.Type != models.TaskTypeDocker {
+		return nil
+	}
+	var config models.TaskConfig
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return nil
+	}
+	return config.Gang
+}
+
+// queueTask makes an admitted task available to runners. Gang tasks are queued
+// as one member task per rank and array tasks as one task per index.
+func (c *RunnerController) queueTask(task *models.Task) {
+	if gang := gangConfig(task); gang != nil {
+		c.scheduleGang(task, gang)
+		return
+	}
+	if array := arrayConfig(task); array != nil {
+		c.scheduleArray(task, array)
+		return
+	}
+	c.AddAvailableTask(task)
+}
+
+// scheduleGang queues the member tasks of a gang task instead of the task
+// itself. The gang is tracked under the task's ID.
+func (c *RunnerController) scheduleGang(task *models.Task, config *models.GangConfig) {
+	gang := &gangRecord{task: task, config: *config}
+
+	c.mu.Lock()
+	if c.gangs == nil {
+		c.gangs = make(map[string]*gangRecord)
+		c.gangRuns = make(map[string]*gangRun)
+	}
+	c.gangs[task.ID.String()] = gang
+	members := c.newGangRunLocked(gang)
+	c.mu.Unlock()
+
+	c.queueGangRun(gang, members)
+}
+
+// newGangRunLocked starts the next attempt of a gang and returns its member
+// tasks. c.mu must be held.
+func (c *RunnerController) newGangRunLocked(gang *gangRecord) []*models.Task {
+	run := &gangRun{gang: gang, attempt: len(gang.runs) + 1, state: models.GangForming}
+	tasks := make([]*models.Task, gang.config.Size)
+	for rank := range tasks {
+		member := *gang.task
+		member.ID = uuid.New()
+		member.Title = fmt.Sprintf("%s [%d/%d]", gang.task.Title, rank+1, gang.config.Size)
+		member.Status = models.TaskStatusPending
+		member.CreatedAt = time.Now()
+		member.UpdatedAt = member.CreatedAt
+		tasks[rank] = &member
+
+		run.members = append(run.members, &gangMember{task: &member})
+		c.gangRuns[member.ID.String()] = run
+	}
+	gang.runs = append(gang.runs, run)
+	return tasks
+}
+
+func (c *RunnerController) queueGangRun(gang *gangRecord, members []*models.Task) {
+	log := gologger.WithComponent("gangs")
+
+	c.mu.RLock()
+	attempt := gang.current().attempt
+	c.mu.RUnlock()
+
+	gangID := gang.task.ID.String()
+	c.recordEvent(gangID, models.TaskEvent{
+		Type:   models.TaskEventGangScheduled,
+		Detail: fmt.Sprintf("attempt %d of %d with %d members", attempt, gang.config.Attempts(), len(members)),
+	})
+	log.Info().Str("gang_id", gangID).Int("attempt", attempt).Int("size", len(members)).Msg("Scheduling gang")
+
+	for _, member := range members {
+		c.AddAvailableTask(member)
+	}
+}
+
+// checkGangStart refuses to start a member of a gang that is no longer forming,
+// or on a runner that already holds one of its members
+func (c *RunnerController) checkGangStart(taskID, deviceID string) (int, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	run, ok := c.gangRuns[taskID]
+	if !ok {
+		return 0, ""
+	}
+	if run.state != models.GangForming {
+		return http.StatusConflict, "Gang is no longer forming"
+	}
+	if run.hasDevice(deviceID) {
+		return http.StatusConflict, "Runner already holds a member of this gang"
+	}
+	return 0, ""
+}
+
+// joinGang records the runner of a started member. The first member to start
+// opens the window the others have to start in.
+func (c *RunnerController) joinGang(taskID, deviceID string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	run, ok := c.gangRuns[taskID]
+	if !ok {
+		return
+	}
+	if _, member := run.member(taskID); member != nil {
+		member.deviceID = deviceID
+		member.seenAt = now
+	}
+	if run.startBy.IsZero() {
+		run.startBy = now.Add(run.gang.config.StartWindow())
+	}
+}
+
+// gangStatusFor returns the attempt a member task belongs to, as seen by the
+// member's runner
+func (c *RunnerController) gangStatusFor(taskID, deviceID string, now time.Time) (*models.GangStatus, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	run, ok := c.gangRuns[taskID]
+	if !ok {
+		return nil, http.StatusNotFound, errors.New("task is not a gang member")
+	}
+	rank, member := run.member(taskID)
+	if member.deviceID != deviceID {
+		return nil, http.StatusForbidden, errors.New("gang member is assigned to another runner")
+	}
+	member.seenAt = now
+
+	status := run.status()
+	status.Self = &status.Members[rank]
+	return status, 0, nil
+}
+
+// reportGangJoin stores where a member listens for its peers. Once every
+// member reported one, the gang runs. For gangs with a channel the server is
+// also what hands each member the channel keys of the others.
+func (c *RunnerController) reportGangJoin(taskID, deviceID string, join models.GangJoin, now time.Time) (*models.GangStatus, int, error) {
+	if _, _, err := net.SplitHostPort(join.Endpoint); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid endpoint %q: %w", join.Endpoint, err)
+	}
+
+	c.mu.Lock()
+	run, ok := c.gangRuns[taskID]
+	if !ok {
+		c.mu.Unlock()
+		return nil, http.StatusNotFound, errors.New("task is not a gang member")
+	}
+	_, member := run.member(taskID)
+	if member.deviceID != deviceID {
+		c.mu.Unlock()
+		return nil, http.StatusForbidden, errors.New("gang member is assigned to another runner")
+	}
+	if run.state != models.GangForming {
+		c.mu.Unlock()
+		return c.gangStatusFor(taskID, deviceID, now)
+	}
+	if run.gang.config.Channel {
+		if err := validateChannelJoin(join); err != nil {
+			c.mu.Unlock()
+			return nil, http.StatusBadRequest, err
+		}
+		member.channelEndpoint = join.ChannelEndpoint
+		member.channelKey = join.ChannelKey
+	}
+	member.endpoint = join.Endpoint
+	member.seenAt = now
+
+	formed := true
+	for _, m := range run.members {
+		if m.endpoint == "" {
+			formed = false
+			break
+		}
+	}
+	if formed {
+		run.state = models.GangRunning
+	}
+	gangID := run.gang.task.ID.String()
+	attempt := run.attempt
+	c.mu.Unlock()
+
+	if formed {
+		log := gologger.WithComponent("gangs")
+		log.Info().Str("gang_id", gangID).Int("attempt", attempt).Msg("Gang formed, all members started")
+		c.recordEvent(gangID, models.TaskEvent{Type: models.TaskEventGangRunning, Detail: fmt.Sprintf("attempt %d", attempt)})
+	}
+	return c.gangStatusFor(taskID, deviceID, now)
+}
+
+func validateChannelJoin(join models.GangJoin) error {
+	if _, _, err := net.SplitHostPort(join.ChannelEndpoint); err != nil {
+		return fmt.Errorf("invalid channel endpoint %q: %w", join.ChannelEndpoint, err)
+	}
+	if _, err := peerchannel.ParsePublicKey(join.ChannelKey); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GangStatus returns the current attempt of a gang
+func (c *RunnerController) GangStatus(gangID string) (*models.GangStatus, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	gang, ok := c.gangs[gangID]
+	if !ok {
+		return nil, false
+	}
+	return gang.current().status(), true
+}
+
+// isRetiredGangMember reports whether taskID belongs to a failed gang attempt
+func (c *RunnerController) isRetiredGangMember(taskID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	run, ok := c.gangRuns[taskID]
+	return ok && run.state == models.GangFailed
+}
+
+// resolveGangMember fails the gang when a member reports a failure and completes
+// it once every member succeeded. The result of rank 0 becomes the result of
+// the gang task. Results of other tasks are ignored.
+func (c *RunnerController) resolveGangMember(result *models.TaskResult) {
+	taskID := result.TaskID.String()
+
+	c.mu.Lock()
+	run, ok := c.gangRuns[taskID]
+	if !ok || (run.state != models.GangForming && run.state != models.GangRunning) {
+		c.mu.Unlock()
+		return
+	}
+	rank, member := run.member(taskID)
+	if result.ExitCode != 0 || result.Error != "" {
+		reason := fmt.Sprintf("rank %d failed with exit code %d", rank, result.ExitCode)
+		if result.Error != "" {
+			reason = fmt.Sprintf("rank %d failed: %s", rank, result.Error)
+		}
+		c.failGangLocked(run, reason)
+		return
+	}
+
+	member.done = true
+	member.result = result
+	for _, m := range run.members {
+		if !m.done {
+			c.mu.Unlock()
+			return
+		}
+	}
+	run.state = models.GangCompleted
+	gangResult := *run.members[0].result
+	gangResult.TaskID = run.gang.task.ID
+	c.results[gangResult.TaskID.String()] = &gangResult
+	gangID := run.gang.task.ID.String()
+	c.mu.Unlock()
+
+	log := gologger.WithComponent("gangs")
+	log.Info().Str("gang_id", gangID).Msg("Gang completed")
+	c.recordEvent(gangID, models.TaskEvent{Type: models.TaskEventGangCompleted, Detail: fmt.Sprintf("attempt %d", run.attempt)})
+}
+
+// failGangLocked ends an attempt, takes its members out of the queue and the
+// running tasks, and schedules the next attempt if the gang has one left. It
+// is called with c.mu held and releases it.
+func (c *RunnerController) failGangLocked(run *gangRun, reason string) {
+	log := gologger.WithComponent("gangs")
+
+	run.state = models.GangFailed
+	run.reason = reason
+	gang := run.gang
+	gangID := gang.task.ID.String()
+
+	retired := make(map[string]bool, len(run.members))
+	for _, member := range run.members {
+		retired[member.task.ID.String()] = true
+		delete(c.assigned, member.task.ID.String())
+	}
+	queued := c.availableTasks[:0]
+	for _, task := range c.availableTasks {
+		if !retired[task.ID.String()] {
+			queued = append(queued, task)
+		}
+	}
+	c.availableTasks = queued
+	depth := len(c.availableTasks)
+
+	var next []*models.Task
+	if len(gang.runs) < gang.config.Attempts() {
+		next = c.newGangRunLocked(gang)
+	} else {
+		c.results[gangID] = &models.TaskResult{
+			TaskID:    gang.task.ID,
+			ExitCode:  -1,
+			Error:     fmt.Sprintf("gang failed after %d attempts: %s", len(gang.runs), reason),
+			CreatedAt: time.Now(),
+		}
+	}
+	c.mu.Unlock()
+
+	log.Warn().Str("gang_id", gangID).Int("attempt", run.attempt).Str("reason", reason).Msg("Gang failed")
+	c.recordEvent(gangID, models.TaskEvent{Type: models.TaskEventGangFailed, Detail: fmt.Sprintf("attempt %d: %s", run.attempt, reason)})
+	c.recordQueueDepth(depth)
+	if next != nil {
+		c.queueGangRun(gang, next)
+	}
+}
+
+// CheckGangs fails gang attempts whose members did not all start within the
+// start window, or one of whose members dropped: its runner went silent or
+// its task ran past its maximum duration. It returns the IDs of the gangs
+// that failed.
+func (c *RunnerController) CheckGangs(now time.Time) []string {
+	var failed []string
+	for {
+		c.mu.Lock()
+		run, reason := c.droppedGangLocked(now)
+		if run == nil {
+			c.mu.Unlock()
+			return failed
+		}
+		failed = append(failed, run.gang.task.ID.String())
+		c.failGangLocked(run, reason)
+	}
+}
+
+func (c *RunnerController) droppedGangLocked(now time.Time) (*gangRun, string) {
+	for _, gang := range c.gangs {
+		run := gang.current()
+		if run.state != models.GangForming && run.state != models.GangRunning {
+			continue
+		}
+
+		started := 0
+		for rank, member := range run.members {
+			if member.deviceID == "" || member.done {
+				continue
+			}
+			started++
+			if c.expired[member.task.ID.String()] {
+				return run, fmt.Sprintf("rank %d exceeded its maximum duration", rank)
+			}
+			seen := member.seenAt
+			if heartbeat, ok := c.lastHeartbeat[member.deviceID]; ok && heartbeat.After(seen) {
+				seen = heartbeat
+			}
+			if now.Sub(seen) > runnerHeartbeatTimeout {
+				return run, fmt.Sprintf("rank %d dropped, its runner has not been seen since %s", rank, seen.UTC().Format(time.RFC3339))
+			}
+		}
+		if run.state == models.GangForming && !run.startBy.IsZero() && now.After(run.startBy) {
+			return run, fmt.Sprintf("only %d of %d members started within %s", started, len(run.members), gang.config.StartWindow())
+		}
+	}
+	return nil, ""
+}
+
+// RunGangMonitor checks gangs every interval until ctx is cancelled
+func (c *RunnerController) RunGangMonitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.CheckGangs(now)
+		}
+	}
+}
+
+func (c *RunnerController) handleGetGang(ctx *gin.Context) {
+	status, ok := c.GangStatus(ctx.Param("gangID"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Gang not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
+
+func (c *RunnerController) handleGangMemberStatus(ctx *gin.Context) {
+	status, code, err := c.gangStatusFor(ctx.Param("taskID"), ctx.GetHeader("X-Device-ID"), time.Now())
+	if err != nil {
+		ctx.JSON(code, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
+
+func (c *RunnerController) handleGangJoin(ctx *gin.Context) {
+	var join models.GangJoin
+	if err := ctx.BindJSON(&join); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	status, code, err := c.reportGangJoin(ctx.Param("taskID"), ctx.GetHeader("X-Device-ID"), join, time.Now())
+	if err != nil {
+		ctx.JSON(code, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
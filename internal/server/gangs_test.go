@@ -0,0 +1,233 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/peerchannel"
+)
+
+func createGang(t *testing.T, router http.Handler, size int) models.Task {
+	t.Helper()
+	return createGangWith(t, router, map[string]interface{}{"size": size, "max_attempts": 2})
+}
+
+func createGangWith(t *testing.T, router http.Handler, gang map[string]interface{}) models.Task {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"title":       "train",
+		"type":        "docker",
+		"nonce":       "n",
+		"environment": map[string]string{"type": "docker"},
+		"config": map[string]interface{}{
+			"image_name": "ghcr.io/acme/ddp:1",
+			"gang":       gang,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create gang = %d %s", rec.Code, rec.Body.String())
+	}
+	var task models.Task
+	if err := json.Unmarshal(rec.Body.Bytes(), &task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+// startGang starts every member of the gang's current attempt on its own
+// runner and reports its endpoint
+func startGang(t *testing.T, controller *RunnerController, gangID string) *models.GangStatus {
+	t.Helper()
+
+	status, ok := controller.GangStatus(gangID)
+	if !ok {
+		t.Fatal("gang not found")
+	}
+	now := time.Now()
+	for rank, member := range status.Members {
+		deviceID := "device-" + string(rune('a'+rank))
+		if code, message := controller.startTask(context.Background(), member.TaskID, deviceID); code != 0 {
+			t.Fatalf("start rank %d = %d %s", rank, code, message)
+		}
+		if _, code, err := controller.reportGangJoin(member.TaskID, deviceID, models.GangJoin{Endpoint: "10.0.0." + string(rune('1'+rank)) + ":29500"}, now); err != nil {
+			t.Fatalf("join rank %d = %d %v", rank, code, err)
+		}
+	}
+	status, _ = controller.GangStatus(gangID)
+	return status
+}
+
+func submitMember(controller *RunnerController, member models.GangMember, exitCode int) error {
+	_, err := controller.submitTaskResult(context.Background(), &models.TaskResult{
+		TaskID:   uuid.MustParse(member.TaskID),
+		DeviceID: member.DeviceID,
+		ExitCode: exitCode,
+		Output:   "rank done",
+	})
+	return err
+}
+
+func TestGangMembersRunOnDistinctRunners(t *testing.T) {
+	controller := NewRunnerController(nil)
+	gangTask := createGang(t, newTestRouter(controller), 2)
+
+	status, _ := controller.GangStatus(gangTask.ID.String())
+	if len(controller.availableTasksFor("device-a")) != 2 {
+		t.Fatal("both members should be offered before any started")
+	}
+	if code, _ := controller.startTask(context.Background(), status.Members[0].TaskID, "device-a"); code != 0 {
+		t.Fatalf("start rank 0 = %d", code)
+	}
+	if tasks := controller.availableTasksFor("device-a"); len(tasks) != 0 {
+		t.Fatalf("runner holding a member was offered %d more", len(tasks))
+	}
+	if code, _ := controller.startTask(context.Background(), status.Members[1].TaskID, "device-a"); code != http.StatusConflict {
+		t.Fatalf("second member on the same runner = %d, want 409", code)
+	}
+	if tasks := controller.availableTasksFor("device-b"); len(tasks) != 1 {
+		t.Fatalf("other runner offered %d members, want 1", len(tasks))
+	}
+}
+
+func TestGangCompletesWhenEveryMemberSucceeds(t *testing.T) {
+	controller := NewRunnerController(nil)
+	gangTask := createGang(t, newTestRouter(controller), 2)
+	gangID := gangTask.ID.String()
+
+	status := startGang(t, controller, gangID)
+	if status.State != models.GangRunning {
+		t.Fatalf("state = %s, want running", status.State)
+	}
+	member, _, err := controller.gangStatusFor(status.Members[1].TaskID, "device-b", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.Self.Rank != 1 || member.Peers()[0] != "10.0.0.1:29500" {
+		t.Fatalf("member status = %+v", member)
+	}
+
+	for _, m := range status.Members {
+		if err := submitMember(controller, m, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status, _ := controller.GangStatus(gangID); status.State != models.GangCompleted {
+		t.Fatalf("state = %s, want completed", status.State)
+	}
+	if result, ok := controller.GetTaskResult(gangID); !ok || result.Output != "rank done" {
+		t.Fatal("gang task should carry the result of rank 0")
+	}
+}
+
+func TestGangIsRescheduledWhenMemberFails(t *testing.T) {
+	controller := NewRunnerController(nil)
+	gangTask := createGang(t, newTestRouter(controller), 2)
+	gangID := gangTask.ID.String()
+
+	first := startGang(t, controller, gangID)
+	if err := submitMember(controller, first.Members[0], 1); err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := controller.GangStatus(gangID)
+	if second.Attempt != 2 || second.State != models.GangForming {
+		t.Fatalf("attempt %d is %s, want attempt 2 forming", second.Attempt, second.State)
+	}
+	if err := submitMember(controller, first.Members[1], 0); !errors.Is(err, errGangRetired) {
+		t.Fatalf("result of the failed attempt = %v, want it refused", err)
+	}
+	if tasks := controller.availableTasksFor("device-z"); len(tasks) != 2 || tasks[0].ID.String() != second.Members[0].TaskID {
+		t.Fatal("only the members of the new attempt should be queued")
+	}
+
+	// The start window runs out with one member started, and the gang is out of attempts
+	controller.startTask(context.Background(), second.Members[0].TaskID, "device-a")
+	if failed := controller.CheckGangs(time.Now().Add(models.DefaultGangStartWindow + time.Second)); len(failed) != 1 {
+		t.Fatalf("CheckGangs() failed %d gangs, want 1", len(failed))
+	}
+	result, ok := controller.GetTaskResult(gangID)
+	if !ok || result.ExitCode == 0 {
+		t.Fatal("gang out of attempts should have a failed result")
+	}
+	if tasks := controller.availableTasksFor("device-z"); len(tasks) != 0 {
+		t.Fatalf("%d members still queued", len(tasks))
+	}
+}
+
+func TestGangFailsWhenMemberDrops(t *testing.T) {
+	controller := NewRunnerController(nil)
+	gangTask := createGang(t, newTestRouter(controller), 3)
+	gangID := gangTask.ID.String()
+	startGang(t, controller, gangID)
+
+	if failed := controller.CheckGangs(time.Now()); len(failed) != 0 {
+		t.Fatal("gang with live members failed")
+	}
+	if failed := controller.CheckGangs(time.Now().Add(runnerHeartbeatTimeout + time.Second)); len(failed) != 1 {
+		t.Fatalf("CheckGangs() failed %d gangs, want 1", len(failed))
+	}
+
+	var types []models.TaskEventType
+	for _, event := range controller.TaskEvents(gangID) {
+		types = append(types, event.Type)
+	}
+	want := []models.TaskEventType{models.TaskEventGangScheduled, models.TaskEventGangRunning, models.TaskEventGangFailed, models.TaskEventGangScheduled}
+	if len(types) != len(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("events = %v, want %v", types, want)
+		}
+	}
+}
+
+func TestGangChannelKeysAreBrokered(t *testing.T) {
+	controller := NewRunnerController(nil)
+	gangTask := createGangWith(t, newTestRouter(controller), map[string]interface{}{"size": 2, "channel": true})
+	status, _ := controller.GangStatus(gangTask.ID.String())
+
+	keys := make([]string, 2)
+	for rank, member := range status.Members {
+		deviceID := "device-" + string(rune('a'+rank))
+		if code, message := controller.startTask(context.Background(), member.TaskID, deviceID); code != 0 {
+			t.Fatalf("start rank %d = %d %s", rank, code, message)
+		}
+		join := models.GangJoin{Endpoint: "10.0.0.1:29500"}
+		if _, code, _ := controller.reportGangJoin(member.TaskID, deviceID, join, time.Now()); code != http.StatusBadRequest {
+			t.Fatalf("join without channel key = %d, want 400", code)
+		}
+		key, err := peerchannel.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[rank] = peerchannel.EncodePublicKey(key.PublicKey())
+		join.ChannelEndpoint, join.ChannelKey = "10.0.0.1:29501", keys[rank]
+		if _, _, err := controller.reportGangJoin(member.TaskID, deviceID, join, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	member, _, err := controller.gangStatusFor(status.Members[0].TaskID, "device-a", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.State != models.GangRunning || member.Members[1].ChannelKey != keys[1] {
+		t.Fatalf("rank 0 sees %+v, want the channel key of rank 1", member.Members[1])
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/theblitlabs/parity-runner/internal/gas"
+)
+
+// stakeWalletDistributeABI is the part of the stake wallet contract rewards
+// are paid through. distributeRewardsBatch is only called when the gateway
+// batches payouts, and reverts with batchAlreadyPaidReason for a batch ID it
+// has paid.
+const stakeWalletDistributeABI = `[
+	{"type":"function","name":"distributeRewards","stateMutability":"nonpayable",
+	 "inputs":[{"name":"deviceID","type":"string"},{"name":"amount","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"distributeRewardsBatch","stateMutability":"nonpayable",
+	 "inputs":[{"name":"batchID","type":"bytes32"},{"name":"deviceIDs","type":"string[]"},{"name":"amounts","type":"uint256[]"}],"outputs":[]}
+]`
+
+const batchAlreadyPaidReason = "batch already paid"
+
+// GasManagedChain pays rewards by calling distributeRewards on the stake wallet
+// contract through a gas.Sender, which prices the transaction within the
+// configured fee caps and replaces it while it is stuck. Stake reads go to the
+// wrapped chain.
+type GasManagedChain struct {
+	Chain
+	contract common.Address
+	sender   *gas.Sender
+	abi      abi.ABI
+}
+
+func NewGasManagedChain(chain Chain, backend gas.Backend, contract common.Address, key *ecdsa.PrivateKey, chainID *big.Int, config gas.Config) (*GasManagedChain, error) {
+	sender, err := gas.NewSender(backend, key, chainID, config)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := abi.JSON(strings.NewReader(stakeWalletDistributeABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stake wallet ABI: %w", err)
+	}
+	return &GasManagedChain{Chain: chain, contract: contract, sender: sender, abi: parsed}, nil
+}
+
+// TransferTimeout is the longest a transfer waits for its transaction and
+// every replacement to be mined
+func (c *GasManagedChain) TransferTimeout() time.Duration {
+	return c.sender.MaxWait()
+}
+
+func (c *GasManagedChain) TransferReward(ctx context.Context, deviceID string, amount float64) error {
+	_, err := c.TransferRewardTx(ctx, deviceID, amount)
+	return err
+}
+
+func (c *GasManagedChain) TransferRewardTx(ctx context.Context, deviceID string, amount float64) (string, error) {
+	return c.distribute(ctx, nil, deviceID, amount)
+}
+
+// ResumeRewardTx continues a reward transaction an earlier transfer gave up on
+func (c *GasManagedChain) ResumeRewardTx(ctx context.Context, pending gas.Pending, deviceID string, amount float64) (string, error) {
+	return c.distribute(ctx, &pending, deviceID, amount)
+}
+
+func (c *GasManagedChain) distribute(ctx context.Context, pending *gas.Pending, deviceID string, amount float64) (string, error) {
+	data, err := c.abi.Pack("distributeRewards", deviceID, rewardWei(amount))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode reward distribution: %w", err)
+	}
+	receipt, err := c.send(ctx, pending, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to distribute reward: %w", err)
+	}
+	return receipt.TxHash.Hex(), nil
+}
+
+// TransferRewardBatch pays a batch of rewards in one distributeRewardsBatch
+// call. The contract is given the batch ID to refuse a batch it already paid,
+// which is reported as ErrBatchAlreadyPaid.
+func (c *GasManagedChain) TransferRewardBatch(ctx context.Context, batchID string, transfers []RewardTransfer) (string, error) {
+	return c.distributeBatch(ctx, nil, batchID, transfers)
+}
+
+// ResumeRewardBatch continues a batch transaction an earlier transfer gave up
+// on
+func (c *GasManagedChain) ResumeRewardBatch(ctx context.Context, pending gas.Pending, batchID string, transfers []RewardTransfer) (string, error) {
+	return c.distributeBatch(ctx, &pending, batchID, transfers)
+}
+
+func (c *GasManagedChain) distributeBatch(ctx context.Context, pending *gas.Pending, batchID string, transfers []RewardTransfer) (string, error) {
+	id := common.FromHex(batchID)
+	if len(id) != common.HashLength {
+		return "", fmt.Errorf("invalid batch ID %q", batchID)
+	}
+	deviceIDs := make([]string, 0, len(transfers))
+	amounts := make([]*big.Int, 0, len(transfers))
+	for _, transfer := range transfers {
+		deviceIDs = append(deviceIDs, transfer.DeviceID)
+		amounts = append(amounts, rewardWei(transfer.Amount))
+	}
+	data, err := c.abi.Pack("distributeRewardsBatch", common.BytesToHash(id), deviceIDs, amounts)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode reward batch: %w", err)
+	}
+	receipt, err := c.send(ctx, pending, data)
+	if err != nil && strings.Contains(err.Error(), batchAlreadyPaidReason) {
+		return "", fmt.Errorf("%w: %s", ErrBatchAlreadyPaid, batchID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to distribute reward batch: %w", err)
+	}
+	return receipt.TxHash.Hex(), nil
+}
+
+// send sends data to the contract, or resumes the pending transaction
+func (c *GasManagedChain) send(ctx context.Context, pending *gas.Pending, data []byte) (*types.Receipt, error) {
+	if pending != nil {
+		return c.sender.Resume(ctx, c.contract, data, *pending)
+	}
+	return c.sender.Send(ctx, c.contract, data)
+}
+
+// StakeBalances reads many stakes in one call when the wrapped chain can
+func (c *GasManagedChain) StakeBalances(ctx context.Context, deviceIDs []string) (map[string]*big.Int, error) {
+	if batch, ok := c.Chain.(BatchStakeReader); ok {
+		return batch.StakeBalances(ctx, deviceIDs)
+	}
+	balances := make(map[string]*big.Int, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		balance, err := c.Chain.StakeBalance(ctx, deviceID)
+		if err != nil {
+			return nil, err
+		}
+		balances[deviceID] = balance
+	}
+	return balances, nil
+}
+
+// rewardWei converts a reward in tokens to the token's 18 decimal base unit
+func rewardWei(amount float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(amount), big.NewFloat(1e18)).Int(nil)
+	return wei
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/gas"
+)
+
+// minedBackend mines every transaction as soon as it is sent. While stuck it
+// only mines the transactions in mined.
+type minedBackend struct {
+	mu          sync.Mutex
+	sent        []*types.Transaction
+	stuck       bool
+	mined       map[common.Hash]bool
+	estimateErr error
+}
+
+func (m *minedBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return 80_000, m.estimateErr
+}
+
+func (m *minedBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: big.NewInt(30)}, nil
+}
+
+func (m *minedBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(5), nil
+}
+
+func (m *minedBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(35), nil
+}
+
+func (m *minedBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return uint64(len(m.sent)), nil
+}
+
+func (m *minedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, tx)
+	return nil
+}
+
+func (m *minedBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stuck && !m.mined[txHash] {
+		return nil, ethereum.NotFound
+	}
+	return &types.Receipt{TxHash: txHash, Status: types.ReceiptStatusSuccessful}, nil
+}
+
+// unstick mines the first transaction sent and every one sent from now on
+func (m *minedBackend) unstick() common.Hash {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stuck = false
+	return m.sent[0].Hash()
+}
+
+func (m *minedBackend) sentCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sent)
+}
+
+func newTestGasChain(t *testing.T, backend *minedBackend) *GasManagedChain {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	chain, err := NewGasManagedChain(&fakeChain{}, backend, common.HexToAddress("0x5"), key, big.NewInt(1337), gas.Config{
+		ConfirmTimeout: 20 * time.Millisecond,
+		PollInterval:   time.Millisecond,
+		Retries:        1,
+	})
+	if err != nil {
+		t.Fatalf("NewGasManagedChain() error = %v", err)
+	}
+	return chain
+}
+
+func TestGasManagedChainPaysThroughDistributeRewards(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	backend := &minedBackend{}
+	contract := common.HexToAddress("0x5")
+	chain, err := NewGasManagedChain(&fakeChain{}, backend, contract, key, big.NewInt(1337), gas.Config{
+		MaxPriorityFeePerGas: big.NewInt(3),
+		ConfirmTimeout:       time.Minute,
+		Retries:              2,
+	})
+	if err != nil {
+		t.Fatalf("NewGasManagedChain() error = %v", err)
+	}
+
+	gateway := NewChainGateway(chain, ChainGatewayConfig{})
+	if gateway.config.TransferTimeout != 4*time.Minute {
+		t.Fatalf("transfer timeout = %v, want the sender's 4m", gateway.config.TransferTimeout)
+	}
+	if queued := gateway.Distribute(context.Background(), Payout{TaskID: "task-1", DeviceID: "device-1", Amount: 1.5}); queued {
+		t.Fatalf("Distribute() queued the payout: %v", gateway.PendingPayouts())
+	}
+
+	if len(backend.sent) != 1 {
+		t.Fatalf("sent %d transactions, want 1", len(backend.sent))
+	}
+	tx := backend.sent[0]
+	if *tx.To() != contract || tx.GasTipCap().Int64() != 3 || tx.Gas() != 96_000 {
+		t.Fatalf("transaction to %s, tip %v, gas %d", tx.To().Hex(), tx.GasTipCap(), tx.Gas())
+	}
+	args, err := chain.abi.Methods["distributeRewards"].Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		t.Fatalf("failed to decode transaction data: %v", err)
+	}
+	if args[0] != "device-1" || args[1].(*big.Int).String() != "1500000000000000000" {
+		t.Fatalf("distributeRewards(%v, %v), want device-1 and 1.5 tokens in wei", args[0], args[1])
+	}
+
+	batchID := payoutBatchID([]*Payout{{TaskID: "task-2"}, {TaskID: "task-3"}})
+	if _, err := chain.TransferRewardBatch(context.Background(), batchID, []RewardTransfer{
+		{TaskID: "task-2", DeviceID: "device-1", Amount: 1},
+		{TaskID: "task-3", DeviceID: "device-2", Amount: 0.5},
+	}); err != nil {
+		t.Fatalf("TransferRewardBatch() error = %v", err)
+	}
+	args, err = chain.abi.Methods["distributeRewardsBatch"].Inputs.Unpack(backend.sent[1].Data()[4:])
+	if err != nil {
+		t.Fatalf("failed to decode batch transaction data: %v", err)
+	}
+	if id := args[0].([32]byte); common.Hash(id).Hex() != batchID || len(args[1].([]string)) != 2 || args[2].([]*big.Int)[1].String() != "500000000000000000" {
+		t.Fatalf("distributeRewardsBatch(%v, %v, %v)", args[0], args[1], args[2])
+	}
+}
+
+func TestGasManagedChainResumesAPayoutInsteadOfPayingAgain(t *testing.T) {
+	backend := &minedBackend{stuck: true}
+	gateway := NewChainGateway(newTestGasChain(t, backend), ChainGatewayConfig{})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	gateway.now = func() time.Time { return now }
+	store := &memoryPayoutStore{payouts: make(map[string]Payout)}
+	ctx := context.Background()
+	if err := gateway.SetPayoutStore(ctx, store); err != nil {
+		t.Fatalf("SetPayoutStore() error = %v", err)
+	}
+	var paid []Payout
+	gateway.OnPaid(func(payout Payout, _ time.Duration) { paid = append(paid, payout) })
+
+	if queued := gateway.Distribute(ctx, Payout{TaskID: "task-1", DeviceID: "device-1", Amount: 1}); !queued {
+		t.Fatal("Distribute() did not queue a payout whose transaction was never mined")
+	}
+	sent := backend.sentCount()
+	if stored := store.payouts["task-1"]; stored.PendingTx == nil || len(stored.PendingTx.Hashes) != sent {
+		t.Fatalf("stored payout pending transaction = %+v, want the %d sent versions", stored.PendingTx, sent)
+	}
+
+	// The first version is mined after the transfer gave up on it
+	first := backend.unstick()
+	now = now.Add(time.Hour)
+	if settled := gateway.Settle(ctx); settled != 1 {
+		t.Fatalf("Settle() = %d, want the payout settled", settled)
+	}
+	if backend.sentCount() != sent {
+		t.Fatalf("sent %d transactions after the first was mined, want none", backend.sentCount()-sent)
+	}
+	if len(paid) != 1 || paid[0].TxHash != first.Hex() || paid[0].PendingTx != nil {
+		t.Fatalf("paid %+v, want task-1 once in the mined transaction %s", paid, first.Hex())
+	}
+	if report := gateway.Reconciliation(); !report.Reconciled || report.SettledCount != 1 {
+		t.Fatalf("Reconciliation() = %+v", report)
+	}
+}
+
+func TestGasManagedChainSettlesABatchThatWasAlreadyPaid(t *testing.T) {
+	backend := &minedBackend{stuck: true}
+	gateway := NewChainGateway(newTestGasChain(t, backend), ChainGatewayConfig{BatchSize: 2})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	gateway.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, taskID := range []string{"task-1", "task-2"} {
+		gateway.Distribute(ctx, Payout{TaskID: taskID, DeviceID: "device-1", Amount: 0.5})
+	}
+	if settled := gateway.SettleBatches(ctx); settled != 0 {
+		t.Fatalf("SettleBatches() with nothing mined = %d", settled)
+	}
+	pending := gateway.PendingPayouts()
+	if len(pending) != 2 || pending[0].PendingTx == nil {
+		t.Fatalf("pending payouts = %+v, want both holding the sent transaction", pending)
+	}
+
+	// Restored from a store written before the transaction was recorded, the
+	// batch is sent again and the contract refuses its ID
+	for _, batch := range gateway.batches {
+		batch.PendingTx = nil
+	}
+	backend.unstick()
+	backend.estimateErr = errors.New("execution reverted: batch already paid")
+	sent := backend.sentCount()
+	now = now.Add(time.Hour)
+	if settled := gateway.SettleBatches(ctx); settled != 2 {
+		t.Fatalf("SettleBatches() of a paid batch = %d, want both payouts settled", settled)
+	}
+	if backend.sentCount() != sent || len(gateway.PendingPayouts()) != 0 {
+		t.Fatalf("sent %d more transactions, %d payouts still pending", backend.sentCount()-sent, len(gateway.PendingPayouts()))
+	}
+	if report := gateway.Reconciliation(); !report.Reconciled || report.SettledCount != 2 {
+		t.Fatalf("Reconciliation() = %+v", report)
+	}
+}
@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/runner/runnerpb"
+	"github.com/theblitlabs/parity-runner/pkg/protocol"
+)
+
+// maxStreamedOutput bounds the output a runner may stream for a single result
+const maxStreamedOutput = 64 << 20
+
+// GRPCService serves the runner API over gRPC on top of the same controller as
+// the REST handlers
+type GRPCService struct {
+	runnerpb.UnimplementedRunnerServiceServer
+	controller *RunnerController
+}
+
+func NewGRPCService(controller *RunnerController) *GRPCService {
+	return &GRPCService{controller: controller}
+}
+
+// NewGRPCServer returns a gRPC server with the runner service registered
+func NewGRPCServer(controller *RunnerController, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(controller.signCall))
+	server := grpc.NewServer(opts...)
+	runnerpb.RegisterRunnerServiceServer(server, NewGRPCService(controller))
+	return server
+}
+
+// signCall signs unary responses with the server identity, as SignResponses
+// does over HTTP. The signature goes in the trailer, bound to the method,
+// device and nonce of the call.
+func (c *RunnerController) signCall(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	signer := c.identitySigner()
+	if err != nil || signer == nil {
+		return resp, err
+	}
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return resp, nil
+	}
+
+	log := gologger.WithComponent("runner_controller")
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		log.Error().Err(err).Str("method", info.FullMethod).Msg("Failed to encode response for signing")
+		return resp, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	request := identity.CallRequest(info.FullMethod, firstValue(md, runnerpb.DeviceIDKey), firstValue(md, runnerpb.NonceKey))
+	timestamp, signature, err := signer.Sign(identity.PurposeResponse, identity.ResponseBody(request, body))
+	if err != nil {
+		log.Error().Err(err).Str("method", info.FullMethod).Msg("Failed to sign response")
+		return resp, nil
+	}
+	if err := grpc.SetTrailer(ctx, metadata.Pairs(runnerpb.TimestampKey, timestamp, runnerpb.SignatureKey, signature)); err != nil {
+		log.Error().Err(err).Str("method", info.FullMethod).Msg("Failed to set response signature")
+	}
+	return resp, nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func deviceIDFromContext(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(runnerpb.DeviceIDKey); len(values) > 0 && values[0] != "" {
+		return values[0], nil
+	}
+	return "", status.Error(codes.InvalidArgument, "missing x-device-id metadata")
+}
+
+// httpStatusCode translates the HTTP statuses the controller refuses requests
+// with into gRPC codes
+func httpStatusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+func (s *GRPCService) Register(ctx context.Context, req *runnerpb.RegisterRequest) (*runnerpb.RegisterResponse, error) {
+	deviceID, err := deviceIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	registration, err := req.GetRegistration().Model()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if registration.WalletAddress == "" {
+		return nil, status.Error(codes.InvalidArgument, "wallet_address is required")
+	}
+
+	selector, err := models.ParseLabelSelector(registration.AcceptLabels)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid accept_labels selector: %v", err)
+	}
+	if err := s.controller.recordBuild(deviceID, registration.Build); err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, "runner build not accepted: %v", err)
+	}
+	if registration.Manifest != nil {
+		if err := s.controller.recordManifest(deviceID, registration.WalletAddress, registration.Manifest); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid manifest: %v", err)
+		}
+	}
+
+	if registration.Benchmark != nil {
+		if err := s.controller.recordBenchmark(deviceID, registration.WalletAddress, registration.Benchmark); err != nil {
+			log := gologger.WithComponent("runner_controller")
+			log.Warn().Err(err).Str("device_id", deviceID).Msg("Ignoring invalid benchmark report in runner registration")
+		}
+	}
+
+	s.controller.recordCapabilities(deviceID, registration.Capabilities)
+	s.controller.recordWallet(deviceID, registration.WalletAddress)
+	s.controller.recordSettlementChain(deviceID, registration.SettlementChainID)
+	s.controller.registerRunner(deviceID, selector, RunnerWebhook{URL: registration.Webhook, Token: registration.WebhookToken})
+	return &runnerpb.RegisterResponse{}, nil
+}
+
+func (s *GRPCService) Heartbeat(ctx context.Context, req *runnerpb.HeartbeatRequest) (*runnerpb.HeartbeatResponse, error) {
+	deviceID, err := deviceIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeat, err := req.GetHeartbeat().Model()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if heartbeat.Manifest != nil {
+		if err := s.controller.recordManifest(deviceID, heartbeat.WalletAddress, heartbeat.Manifest); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid manifest: %v", err)
+		}
+	}
+	now := time.Now()
+	s.controller.recordHeartbeat(deviceID, gin.H{
+		"cpu_usage":    heartbeat.CPUUsage,
+		"memory_usage": float64(heartbeat.MemoryUsage),
+	}, now)
+	s.controller.recordGPUs(deviceID, heartbeat.GPUs)
+	if heartbeat.Status == models.RunnerStatusBusy {
+		s.controller.recordProgress(deviceID, now)
+	}
+
+	response := &runnerpb.HeartbeatResponse{}
+	if directive := s.controller.recordSettings(deviceID, heartbeat.Settings, now); directive != nil {
+		if response.Directive, err = json.Marshal(directive); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encode fleet directive: %v", err)
+		}
+	}
+	return response, nil
+}
+
+func (s *GRPCService) ListAvailableTasks(ctx context.Context, req *runnerpb.ListAvailableTasksRequest) (*runnerpb.ListAvailableTasksResponse, error) {
+	deviceID, err := deviceIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := s.controller.availableTasksFor(deviceID)
+	resp := &runnerpb.ListAvailableTasksResponse{Tasks: make([]*protocol.Task, 0, len(tasks))}
+	for _, task := range tasks {
+		msg, err := protocol.FromTask(task)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encode task %s: %v", task.ID, err)
+		}
+		resp.Tasks = append(resp.Tasks, msg)
+	}
+	return resp, nil
+}
+
+func (s *GRPCService) StartTask(ctx context.Context, req *runnerpb.StartTaskRequest) (*runnerpb.StartTaskResponse, error) {
+	deviceID, err := deviceIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if httpStatus, message := s.controller.startTask(ctx, req.GetTaskId(), deviceID); httpStatus != 0 {
+		return nil, status.Error(httpStatusCode(httpStatus), message)
+	}
+	return &runnerpb.StartTaskResponse{}, nil
+}
+
+func (s *GRPCService) SubmitResult(ctx context.Context, req *runnerpb.SubmitResultRequest) (*runnerpb.SubmitResultResponse, error) {
+	if _, err := deviceIDFromContext(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetResult() == nil {
+		return nil, status.Error(codes.InvalidArgument, "result is required")
+	}
+
+	result, err := req.GetResult().Model()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.submit(ctx, result)
+}
+
+func (s *GRPCService) StreamResult(stream runnerpb.RunnerService_StreamResultServer) error {
+	ctx := stream.Context()
+	if _, err := deviceIDFromContext(ctx); err != nil {
+		return err
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to receive result header: %v", err)
+	}
+	if first.GetResult() == nil {
+		return status.Error(codes.InvalidArgument, "first message must carry the result")
+	}
+	result, err := first.GetResult().Model()
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var output strings.Builder
+	output.WriteString(result.Output)
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if output.Len()+len(chunk.GetOutput()) > maxStreamedOutput {
+			return status.Errorf(codes.ResourceExhausted, "result output exceeds %d bytes", maxStreamedOutput)
+		}
+		output.Write(chunk.GetOutput())
+	}
+	result.Output = output.String()
+
+	resp, err := s.submit(ctx, result)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(resp)
+}
+
+func (s *GRPCService) submit(ctx context.Context, result *models.TaskResult) (*runnerpb.SubmitResultResponse, error) {
+	log := gologger.WithComponent("runner_controller")
+
+	deviceID, err := deviceIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := bindResultDevice(result, deviceID); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	outcome, err := s.controller.submitTaskResult(ctx, result)
+	switch {
+	case errors.Is(err, errGangRetired):
+		return nil, status.Error(codes.FailedPrecondition, "task's gang failed and was rescheduled")
+	case errors.Is(err, errTaskExpired):
+		return nil, status.Error(codes.FailedPrecondition, "task exceeded its maximum duration")
+	case errors.Is(err, errAssignmentRevoked):
+		return nil, status.Error(codes.FailedPrecondition, "task assignment was revoked")
+	case errors.Is(err, errNotAssignee):
+		return nil, status.Error(codes.FailedPrecondition, "task is assigned to another runner")
+	case err != nil:
+		log.Error().Err(err).Str("task_id", result.TaskID.String()).Msg("Failed to protect task result")
+		return nil, status.Error(codes.Unavailable, "result could not be stored, retry later")
+	}
+
+	return &runnerpb.SubmitResultResponse{
+		PayoutApproved: outcome.PayoutApproved,
+		VetoReason:     outcome.VetoReason,
+		PayoutStatus:   outcome.PayoutStatus,
+	}, nil
+}
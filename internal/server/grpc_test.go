@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/runner/runnerpb"
+	"github.com/theblitlabs/parity-runner/pkg/protocol"
+)
+
+func newTestGRPCClient(t *testing.T, controller *RunnerController) runnerpb.RunnerServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(controller)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial test server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return runnerpb.NewRunnerServiceClient(conn)
+}
+
+func deviceContext(t *testing.T, deviceID string) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, runnerpb.DeviceIDKey, deviceID)
+}
+
+func TestGRPCServiceTaskLifecycle(t *testing.T) {
+	controller := NewRunnerController(nil)
+	client := newTestGRPCClient(t, controller)
+	ctx := deviceContext(t, "device-1")
+
+	gpu := models.NewTask()
+	gpu.Type = models.TaskTypeDocker
+	gpu.Labels = models.Labels{"gpu": "true"}
+	cpu := models.NewTask()
+	cpu.Type = models.TaskTypeCommand
+	controller.AddAvailableTask(gpu)
+	controller.AddAvailableTask(cpu)
+
+	if _, err := client.Register(ctx, &runnerpb.RegisterRequest{
+		Registration: &protocol.RunnerRegistration{WalletAddress: "0xabc", AcceptLabels: "gpu=true"},
+	}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	listed, err := client.ListAvailableTasks(ctx, &runnerpb.ListAvailableTasksRequest{})
+	if err != nil {
+		t.Fatalf("ListAvailableTasks() error = %v", err)
+	}
+	if len(listed.GetTasks()) != 1 || listed.GetTasks()[0].GetId() != gpu.ID.String() {
+		t.Fatalf("ListAvailableTasks() = %v, want only the gpu task", listed.GetTasks())
+	}
+	if listed.GetTasks()[0].GetType() != protocol.TaskType_TASK_TYPE_DOCKER {
+		t.Fatalf("task type = %s, want docker", listed.GetTasks()[0].GetType())
+	}
+
+	if _, err := client.StartTask(ctx, &runnerpb.StartTaskRequest{TaskId: gpu.ID.String()}); err != nil {
+		t.Fatalf("StartTask() error = %v", err)
+	}
+
+	stream, err := client.StreamResult(ctx)
+	if err != nil {
+		t.Fatalf("StreamResult() error = %v", err)
+	}
+	header := &protocol.TaskResult{TaskId: gpu.ID.String(), DeviceId: "device-1", Output: "line 1\n"}
+	if err := stream.Send(&runnerpb.ResultChunk{Chunk: &runnerpb.ResultChunk_Result{Result: header}}); err != nil {
+		t.Fatalf("failed to send result header: %v", err)
+	}
+	for _, chunk := range []string{"line 2\n", "line 3\n"} {
+		if err := stream.Send(&runnerpb.ResultChunk{Chunk: &runnerpb.ResultChunk_Output{Output: []byte(chunk)}}); err != nil {
+			t.Fatalf("failed to send output chunk: %v", err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv() error = %v", err)
+	}
+	if !resp.GetPayoutApproved() {
+		t.Fatalf("payout_approved = false, veto reason %q", resp.GetVetoReason())
+	}
+
+	result, ok := controller.GetTaskResult(gpu.ID.String())
+	if !ok || result.Output != "line 1\nline 2\nline 3\n" {
+		t.Fatalf("stored result = %+v", result)
+	}
+}
+
+func TestGRPCServiceRequiresDeviceID(t *testing.T) {
+	client := newTestGRPCClient(t, NewRunnerController(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := client.ListAvailableTasks(ctx, &runnerpb.ListAvailableTasksRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("ListAvailableTasks() without device ID error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGRPCServiceRejectsExpiredResult(t *testing.T) {
+	controller := NewRunnerController(nil)
+	client := newTestGRPCClient(t, controller)
+	ctx := deviceContext(t, "device-1")
+
+	task := models.NewTask()
+	task.MaxDurationSecs = 60
+	controller.AddAvailableTask(task)
+	if _, err := client.StartTask(ctx, &runnerpb.StartTaskRequest{TaskId: task.ID.String()}); err != nil {
+		t.Fatalf("StartTask() error = %v", err)
+	}
+	controller.ExpireOverdueTasks(time.Now().Add(time.Hour))
+
+	_, err := client.SubmitResult(ctx, &runnerpb.SubmitResultRequest{
+		Result: &protocol.TaskResult{TaskId: task.ID.String(), Output: "late"},
+	})
+	if status.Code(err) != codes.FailedPrecondition || !strings.Contains(err.Error(), "maximum duration") {
+		t.Fatalf("SubmitResult() for an expired task error = %v, want FailedPrecondition", err)
+	}
+}
+
+func TestGRPCResponsesAreSignedForTheCall(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	controller := NewRunnerController(nil)
+	controller.SetServerIdentity(key)
+	controller.AddAvailableTask(models.NewTask())
+	client := newTestGRPCClient(t, controller)
+
+	verifier, err := identity.NewVerifier(crypto.PubkeyToAddress(key.PublicKey).Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(deviceContext(t, "device-1"), runnerpb.NonceKey, "nonce-1")
+	var trailer metadata.MD
+	resp, err := client.ListAvailableTasks(ctx, &runnerpb.ListAvailableTasksRequest{}, grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(deviceID, nonce string) error {
+		request := identity.CallRequest(runnerpb.RunnerService_ListAvailableTasks_FullMethodName, deviceID, nonce)
+		return verifier.Verify(identity.PurposeResponse, identity.ResponseBody(request, body), firstValue(trailer, runnerpb.TimestampKey), firstValue(trailer, runnerpb.SignatureKey))
+	}
+	if err := verify("device-1", "nonce-1"); err != nil {
+		t.Fatalf("response failed verification: %v", err)
+	}
+	if err := verify("device-2", "nonce-1"); err == nil {
+		t.Fatal("response verified for another device")
+	}
+	if err := verify("device-1", "nonce-2"); err == nil {
+		t.Fatal("response verified for another call")
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/identity"
+)
+
+// SetServerIdentity configures the key that signs runner-facing responses and
+// webhook deliveries, whose address runners pin at auth time
+func (c *RunnerController) SetServerIdentity(key *ecdsa.PrivateKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serverIdentity = identity.NewSigner(key)
+}
+
+func (c *RunnerController) identitySigner() *identity.Signer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.serverIdentity
+}
+
+func (c *RunnerController) handleIdentity(ctx *gin.Context) {
+	signer := c.identitySigner()
+	if signer == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Server identity not configured"})
+		return
+	}
+	ctx.JSON(http.StatusOK, identity.Document{Address: signer.Address()})
+}
+
+// signedBodyWriter holds the response body back until it can be signed
+type signedBodyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *signedBodyWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *signedBodyWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// SignResponses signs the bodies of the routes it wraps with the server
+// identity, bound to the method, path, device and nonce of the request. Without
+// an identity responses pass through unsigned.
+func (c *RunnerController) SignResponses(ctx *gin.Context) {
+	signer := c.identitySigner()
+	if signer == nil {
+		ctx.Next()
+		return
+	}
+
+	writer := &signedBodyWriter{ResponseWriter: ctx.Writer}
+	ctx.Writer = writer
+	ctx.Next()
+	ctx.Writer = writer.ResponseWriter
+
+	body := writer.body.Bytes()
+	if err := signer.SignResponse(ctx.Writer.Header(), ctx.Request, body); err != nil {
+		log := gologger.WithComponent("runner_controller")
+		log.Error().Err(err).Str("path", ctx.Request.URL.Path).Msg("Failed to sign response")
+	}
+	if _, err := ctx.Writer.Write(body); err != nil {
+		log := gologger.WithComponent("runner_controller")
+		log.Error().Err(err).Str("path", ctx.Request.URL.Path).Msg("Failed to write response")
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+)
+
+func TestRunnerResponsesAndWebhooksAreSigned(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	controller := NewRunnerController(nil)
+	controller.SetServerIdentity(key)
+	router := newTestRouter(controller)
+
+	verifier, err := identity.NewVerifier(crypto.PubkeyToAddress(key.PublicKey).Hex())
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	controller.AddAvailableTask(models.NewTask())
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/runners/tasks/available", nil)
+	req.Header.Set("X-Device-ID", "device-1")
+	req.Header.Set(identity.NonceHeader, "nonce-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("response code = %d, want %d", rec.Code, http.StatusOK)
+	}
+	resp := &http.Response{Header: rec.Header(), Request: req}
+	if err := verifier.VerifyResponse(resp, rec.Body.Bytes()); err != nil {
+		t.Fatalf("available tasks response failed verification: %v", err)
+	}
+
+	// The response cannot be replayed to another device, path or request
+	replays := map[string]func(*http.Request){
+		"device": func(r *http.Request) { r.Header.Set("X-Device-ID", "device-2") },
+		"nonce":  func(r *http.Request) { r.Header.Set(identity.NonceHeader, "nonce-2") },
+		"path":   func(r *http.Request) { r.URL.Path = "/api/v1/runners/tasks/other/start" },
+		"method": func(r *http.Request) { r.Method = http.MethodPost },
+	}
+	for name, replay := range replays {
+		other := req.Clone(context.Background())
+		replay(other)
+		if err := verifier.VerifyResponse(&http.Response{Header: rec.Header(), Request: other}, rec.Body.Bytes()); err == nil {
+			t.Fatalf("response verified for a request with another %s", name)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/identity", nil)
+	req.Header.Set(identity.NonceHeader, "nonce-3")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if err := verifier.VerifyResponse(&http.Response{Header: rec.Header(), Request: req}, rec.Body.Bytes()); err != nil {
+		t.Fatalf("identity response failed verification: %v", err)
+	}
+
+	controller.registerRunner("device-1", nil, RunnerWebhook{URL: "http://runner.example/webhook"})
+	body := []byte(`{"type":"available_tasks","payload":{}}`)
+	webhookReq, err := controller.NewRunnerWebhookRequest(context.Background(), "device-1", body)
+	if err != nil {
+		t.Fatalf("NewRunnerWebhookRequest() error = %v", err)
+	}
+	if err := verifier.VerifyWebhook(webhookReq.Header, "device-2", body); err == nil {
+		t.Fatal("webhook request verified for another device")
+	}
+	if err := verifier.VerifyWebhook(webhookReq.Header, "device-1", body); err != nil {
+		t.Fatalf("webhook request failed verification: %v", err)
+	}
+}
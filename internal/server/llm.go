@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// promptCompletion is what a runner posts when it finished an LLM task
+type promptCompletion struct {
+	Response       string `json:"response"`
+	PromptTokens   int    `json:"prompt_tokens"`
+	ResponseTokens int    `json:"response_tokens"`
+	InferenceTime  int64  `json:"inference_time_ms"`
+}
+
+// promptFailure is what a runner posts when it could not run an LLM task
+type promptFailure struct {
+	Reason string `json:"reason"`
+}
+
+func (c *RunnerController) handleCompletePrompt(ctx *gin.Context) {
+	var completion promptCompletion
+	if err := ctx.BindJSON(&completion); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	c.submitPromptResult(ctx, &models.TaskResult{
+		Output:         completion.Response,
+		ResultHash:     utils.ComputeResultHash(completion.Response, "", 0),
+		PromptTokens:   completion.PromptTokens,
+		ResponseTokens: completion.ResponseTokens,
+		InferenceTime:  completion.InferenceTime,
+		ExecutionTime:  completion.InferenceTime,
+	})
+}
+
+func (c *RunnerController) handleFailPrompt(ctx *gin.Context) {
+	var failure promptFailure
+	if err := ctx.BindJSON(&failure); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	c.submitPromptResult(ctx, &models.TaskResult{
+		Error:      failure.Reason,
+		ExitCode:   1,
+		ResultHash: utils.ComputeResultHash("", failure.Reason, 1),
+	})
+}
+
+// submitPromptResult records an LLM task's answer as its result, through the
+// same checks and hooks as results of other tasks
+func (c *RunnerController) submitPromptResult(ctx *gin.Context, result *models.TaskResult) {
+	log := gologger.WithComponent("runner_controller")
+
+	promptID := ctx.Param("promptID")
+	taskID, err := uuid.Parse(promptID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt ID"})
+		return
+	}
+	task, ok := c.TaskFor(promptID, "")
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		return
+	}
+	if task.Type != models.TaskTypeLLM {
+		log.Warn().Str("task_id", promptID).Str("type", string(task.Type)).Msg("Rejected prompt answer for a task that is not an LLM task")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Task is not an LLM task"})
+		return
+	}
+
+	result.TaskID = taskID
+	result.DeviceID = ctx.GetHeader("X-Device-ID")
+	outcome, err := c.submitTaskResult(ctx.Request.Context(), result)
+	writeResultOutcome(ctx, result, outcome, err)
+}
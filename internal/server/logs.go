@@ -0,0 +1,246 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	// maxTaskLogBytes of a task's streamed output are kept for creators who
+	// start tailing late, older output is dropped
+	maxTaskLogBytes = 1 << 20
+	// maxLogChunksPerRequest bounds a single push from a runner
+	maxLogChunksPerRequest = 1000
+	// logKeepAlive is how often an idle log stream gets a comment, so proxies
+	// keep it open
+	logKeepAlive = 15 * time.Second
+)
+
+// taskLogs is the output a runner streamed for a task
+type taskLogs struct {
+	chunks  []models.LogChunk
+	size    int
+	lastSeq int64
+	// done is set once the task's result is in, after which no output follows
+	done bool
+	// changed is closed and replaced whenever output is added or the task is
+	// done, waking the streams tailing it
+	changed chan struct{}
+}
+
+func newTaskLogs() *taskLogs {
+	return &taskLogs{changed: make(chan struct{})}
+}
+
+func (l *taskLogs) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// add keeps the chunks a runner pushed, skipping ones a retried push delivers
+// again
+func (l *taskLogs) add(chunks []models.LogChunk) {
+	added := false
+	for _, chunk := range chunks {
+		if chunk.Seq <= l.lastSeq {
+			continue
+		}
+		l.chunks = append(l.chunks, chunk)
+		l.size += len(chunk.Data)
+		l.lastSeq = chunk.Seq
+		added = true
+	}
+	for l.size > maxTaskLogBytes && len(l.chunks) > 1 {
+		l.size -= len(l.chunks[0].Data)
+		l.chunks = l.chunks[1:]
+	}
+	if added {
+		l.notify()
+	}
+}
+
+// after returns the chunks following seq
+func (l *taskLogs) after(seq int64) []models.LogChunk {
+	for i, chunk := range l.chunks {
+		if chunk.Seq > seq {
+			return append([]models.LogChunk(nil), l.chunks[i:]...)
+		}
+	}
+	return nil
+}
+
+// AppendTaskLogs adds output the runner deviceID pushed for a task it runs
+func (c *RunnerController) AppendTaskLogs(taskID, deviceID string, chunks []models.LogChunk) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	assigned, ok := c.assigned[taskID]
+	if !ok {
+		return http.StatusNotFound, errTaskNotRunning
+	}
+	if assigned.deviceID != deviceID {
+		return http.StatusForbidden, errors.New("task is assigned to another runner")
+	}
+	if _, submitted := c.results[taskID]; submitted {
+		return http.StatusConflict, errors.New("task already submitted its result")
+	}
+	for _, chunk := range chunks {
+		if chunk.Stream != models.LogStreamStdout && chunk.Stream != models.LogStreamStderr {
+			return http.StatusBadRequest, fmt.Errorf("unknown log stream %q", chunk.Stream)
+		}
+	}
+
+	if c.logs == nil {
+		c.logs = make(map[string]*taskLogs)
+	}
+	logs, ok := c.logs[taskID]
+	if !ok {
+		logs = newTaskLogs()
+		c.logs[taskID] = logs
+	}
+	logs.add(chunks)
+	return http.StatusOK, nil
+}
+
+// finishTaskLogs ends the streams tailing a task once its result is in
+func (c *RunnerController) finishTaskLogs(taskID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if logs, ok := c.logs[taskID]; ok && !logs.done {
+		logs.done = true
+		logs.notify()
+	}
+}
+
+// tailTaskLogs returns the output of a task after seq, whether more can
+// follow, and a channel closed when it does. ok is false for unknown tasks.
+func (c *RunnerController) tailTaskLogs(taskID string, seq int64) (chunks []models.LogChunk, done bool, changed <-chan struct{}, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	logs, hasLogs := c.logs[taskID]
+	_, submitted := c.results[taskID]
+	if !hasLogs {
+		_, running := c.assigned[taskID]
+		if !running && !submitted {
+			return nil, false, nil, false
+		}
+		// Tailing may start before the runner pushed anything
+		if c.logs == nil {
+			c.logs = make(map[string]*taskLogs)
+		}
+		logs = newTaskLogs()
+		logs.done = submitted
+		c.logs[taskID] = logs
+	}
+	return logs.after(seq), logs.done, logs.changed, true
+}
+
+func (c *RunnerController) handlePushTaskLogs(ctx *gin.Context) {
+	log := gologger.WithComponent("runner_controller")
+	taskID := ctx.Param("taskID")
+
+	var req struct {
+		Chunks []models.LogChunk `json:"chunks"`
+	}
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if len(req.Chunks) > maxLogChunksPerRequest {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("At most %d chunks per request", maxLogChunksPerRequest)})
+		return
+	}
+
+	status, err := c.AppendTaskLogs(taskID, ctx.GetHeader("X-Device-ID"), req.Chunks)
+	if err != nil {
+		log.Debug().Err(err).Str("task_id", taskID).Msg("Refused task logs")
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleStreamTaskLogs sends a task's output as Server-Sent Events: the
+// output kept so far, then new output as the runner pushes it, and an end
+// event once the result is in. Each event's ID is the chunk's sequence number,
+// so a client reconnecting with Last-Event-ID resumes where it stopped.
+func (c *RunnerController) handleStreamTaskLogs(ctx *gin.Context) {
+	taskID := ctx.Param("taskID")
+
+	var seq int64
+	if lastID := ctx.GetHeader("Last-Event-ID"); lastID != "" {
+		seq, _ = strconv.ParseInt(lastID, 10, 64)
+	}
+	chunks, done, changed, ok := c.tailTaskLogs(taskID, seq)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	// The server's write timeout would cut the stream off
+	_ = http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{})
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	keepAlive := time.NewTicker(logKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		for _, chunk := range chunks {
+			if err := writeLogEvent(ctx.Writer, chunk); err != nil {
+				return
+			}
+			seq = chunk.Seq
+		}
+		if done {
+			fmt.Fprint(ctx.Writer, "event: end\ndata: {}\n\n")
+			ctx.Writer.Flush()
+			return
+		}
+		ctx.Writer.Flush()
+
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(ctx.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		case <-changed:
+		}
+		chunks, done, changed, _ = c.tailTaskLogs(taskID, seq)
+	}
+}
+
+// lineBreaks turns carriage returns, which end lines in an event stream too,
+// into newlines
+var lineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// writeLogEvent writes a chunk as an event named after its stream. Data is
+// split into data lines, which clients join with newlines again.
+func writeLogEvent(w io.Writer, chunk models.LogChunk) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "id: %d\nevent: %s\n", chunk.Seq, chunk.Stream)
+	for _, line := range strings.Split(lineBreaks.Replace(chunk.Data), "\n") {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
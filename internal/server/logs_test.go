@@ -0,0 +1,148 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func createLogTask(t *testing.T, controller *RunnerController, router http.Handler) uuid.UUID {
+	t.Helper()
+
+	body := []byte(`{"title":"train","type":"docker","nonce":"n","environment":{"type":"docker"},"config":{"image_name":"alpine"}}`)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body.String())
+	}
+	var task models.Task
+	if err := json.Unmarshal(rec.Body.Bytes(), &task); err != nil {
+		t.Fatal(err)
+	}
+	if status, message := controller.startTask(context.Background(), task.ID.String(), "device-1"); status != 0 {
+		t.Fatalf("startTask() = %d %s", status, message)
+	}
+	return task.ID
+}
+
+func pushLogs(router http.Handler, taskID, deviceID string, chunks ...models.LogChunk) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]interface{}{"chunks": chunks})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+taskID+"/logs", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", deviceID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTaskLogsArePushedByTheAssignedRunnerAndReplayed(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+	taskID := createLogTask(t, controller, router)
+	id := taskID.String()
+
+	if rec := pushLogs(router, id, "device-2", models.LogChunk{Seq: 1, Stream: models.LogStreamStdout, Data: "x"}); rec.Code != http.StatusForbidden {
+		t.Fatalf("push by another runner = %d, want 403", rec.Code)
+	}
+	if rec := pushLogs(router, id, "device-1", models.LogChunk{Seq: 1, Stream: "stdin", Data: "x"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("push to an unknown stream = %d, want 400", rec.Code)
+	}
+	if rec := pushLogs(router, id, "device-1",
+		models.LogChunk{Seq: 1, Stream: models.LogStreamStdout, Data: "epoch 1\r\nepoch 2\n"},
+		models.LogChunk{Seq: 2, Stream: models.LogStreamStderr, Data: "warning\n"},
+	); rec.Code != http.StatusOK {
+		t.Fatalf("push = %d %s", rec.Code, rec.Body.String())
+	}
+	// A retried push delivers seq 2 again
+	if rec := pushLogs(router, id, "device-1",
+		models.LogChunk{Seq: 2, Stream: models.LogStreamStderr, Data: "warning\n"},
+		models.LogChunk{Seq: 3, Stream: models.LogStreamStdout, Data: "done\n"},
+	); rec.Code != http.StatusOK {
+		t.Fatalf("retried push = %d %s", rec.Code, rec.Body.String())
+	}
+
+	postResult(t, router, taskID)
+	if rec := pushLogs(router, id, "device-1", models.LogChunk{Seq: 4, Stream: models.LogStreamStdout, Data: "late"}); rec.Code == http.StatusOK {
+		t.Fatal("push after the result was accepted")
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+id+"/logs/stream", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	want := "id: 1\nevent: stdout\ndata: epoch 1\ndata: epoch 2\ndata: \n\n" +
+		"id: 2\nevent: stderr\ndata: warning\ndata: \n\n" +
+		"id: 3\nevent: stdout\ndata: done\ndata: \n\n" +
+		"event: end\ndata: {}\n\n"
+	if rec.Body.String() != want {
+		t.Fatalf("stream body = %q, want %q", rec.Body.String(), want)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+id+"/logs/stream", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if want := "id: 3\nevent: stdout\ndata: done\ndata: \n\nevent: end\ndata: {}\n\n"; rec.Body.String() != want {
+		t.Fatalf("resumed stream body = %q, want %q", rec.Body.String(), want)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+uuid.NewString()+"/logs/stream", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("stream of an unknown task = %d, want 404", rec.Code)
+	}
+}
+
+func TestTaskLogStreamFollowsARunningTask(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+	taskID := createLogTask(t, controller, router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/tasks/"+taskID.String()+"/logs/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	next := func() string {
+		t.Helper()
+		var event []string
+		for lines.Scan() {
+			if lines.Text() == "" {
+				return strings.Join(event, "|")
+			}
+			event = append(event, lines.Text())
+		}
+		t.Fatalf("stream ended early: %v", lines.Err())
+		return ""
+	}
+
+	pushLogs(router, taskID.String(), "device-1", models.LogChunk{Seq: 1, Stream: models.LogStreamStdout, Data: "step 1"})
+	if got := next(); got != "id: 1|event: stdout|data: step 1" {
+		t.Fatalf("first event = %q", got)
+	}
+	pushLogs(router, taskID.String(), "device-1", models.LogChunk{Seq: 2, Stream: models.LogStreamStdout, Data: "step 2"})
+	if got := next(); got != "id: 2|event: stdout|data: step 2" {
+		t.Fatalf("second event = %q", got)
+	}
+
+	postResult(t, router, taskID)
+	if got := next(); got != "event: end|data: {}" {
+		t.Fatalf("last event = %q, want the end event", got)
+	}
+}
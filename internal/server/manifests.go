@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/manifest"
+)
+
+var errStaleManifest = errors.New("manifest is older than the one on record")
+
+// recordManifest keeps the manifest a runner sent once it verifies and belongs
+// to the runner. An empty walletAddress skips the wallet check.
+func (c *RunnerController) recordManifest(deviceID, walletAddress string, m *models.RunnerManifest) error {
+	if err := manifest.Verify(m); err != nil {
+		return err
+	}
+	if m.DeviceID != deviceID {
+		return fmt.Errorf("manifest is for device %s", m.DeviceID)
+	}
+	if walletAddress != "" && !strings.EqualFold(m.WalletAddress, walletAddress) {
+		return fmt.Errorf("manifest is signed by %s, not the runner wallet %s", m.WalletAddress, walletAddress)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if previous, ok := c.manifests[deviceID]; ok && m.IssuedAt.Before(previous.IssuedAt) {
+		return errStaleManifest
+	}
+	c.manifests[deviceID] = m
+	return nil
+}
+
+// manifestFromPayload decodes the manifest of a heartbeat, nil when it has none
+func manifestFromPayload(payload gin.H) (*models.RunnerManifest, error) {
+	raw, ok := payload["manifest"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var m models.RunnerManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// manifestAllows reports whether a runner with the manifest takes the task.
+// Runners that sent none are matched on their other settings alone.
+func manifestAllows(m *models.RunnerManifest, task *models.Task) bool {
+	if m == nil {
+		return true
+	}
+	if !m.Availability.Accepting || !m.Accepts(task.Type) {
+		return false
+	}
+	return task.Reward >= m.MinRewards.ForTask(task)
+}
+
+// runnerOffer is what the manifests of live runners say about a task class
+type runnerOffer struct {
+	minReward float64
+	accepting int
+	available int
+}
+
+// offerFor summarizes the manifests of runners that heartbeated recently and
+// take the class. minReward is the lowest minimum among them.
+func (c *RunnerController) offerFor(class TaskClass, now time.Time) runnerOffer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var offer runnerOffer
+	for deviceID, m := range c.manifests {
+		if seen, ok := c.lastHeartbeat[deviceID]; !ok || now.Sub(seen) > runnerHeartbeatTimeout {
+			continue
+		}
+		if !m.Availability.Accepting || !m.Accepts(class.Type) {
+			continue
+		}
+		min := m.MinRewards.For(class.Type, class.Model)
+		if offer.accepting == 0 || min < offer.minReward {
+			offer.minReward = min
+		}
+		offer.accepting++
+		if m.Availability.Slots > 0 {
+			offer.available++
+		}
+	}
+	return offer
+}
+
+func (c *RunnerController) handleGetManifest(ctx *gin.Context) {
+	c.mu.RLock()
+	m, ok := c.manifests[ctx.Param("deviceID")]
+	c.mu.RUnlock()
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Runner has not sent a manifest"})
+		return
+	}
+	ctx.JSON(http.StatusOK, m)
+}
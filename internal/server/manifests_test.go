@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/manifest"
+)
+
+func signedManifest(t *testing.T, deviceID string, issuedAt time.Time) *models.RunnerManifest {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &models.RunnerManifest{
+		Version:   models.ManifestVersion,
+		DeviceID:  deviceID,
+		TaskTypes: []models.TaskType{models.TaskTypeDocker, models.TaskTypeLLM},
+		MinRewards: models.MinRewards{
+			{Type: models.TaskTypeDocker, Reward: 0.5},
+			{Type: models.TaskTypeLLM, Model: "llama3:70b", Reward: 3},
+		},
+		Availability: models.RunnerAvailability{Accepting: true, Slots: 1},
+		IssuedAt:     issuedAt.UTC().Truncate(time.Second),
+	}
+	if err := manifest.Sign(m, key); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func sendManifestHeartbeat(router http.Handler, deviceID string, m *models.RunnerManifest) int {
+	body, _ := json.Marshal(map[string]interface{}{
+		"type":    "heartbeat",
+		"payload": models.Heartbeat{WalletAddress: m.WalletAddress, Manifest: m},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/heartbeat", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", deviceID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestAvailableTasksFollowRunnerManifest(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	m := signedManifest(t, "device-1", time.Now())
+	if code := sendManifestHeartbeat(router, "device-1", m); code != http.StatusOK {
+		t.Fatalf("heartbeat with manifest = %d", code)
+	}
+
+	newTask := func(taskType models.TaskType, reward float64, config string) *models.Task {
+		task := models.NewTask()
+		task.Type, task.Reward, task.Config = taskType, reward, json.RawMessage(config)
+		controller.AddAvailableTask(task)
+		return task
+	}
+	docker := newTask(models.TaskTypeDocker, 1, `{}`)
+	newTask(models.TaskTypeDocker, 0.1, `{}`)
+	llm := newTask(models.TaskTypeLLM, 1, `{"model":"llama3:8b"}`)
+	newTask(models.TaskTypeLLM, 1, `{"model":"llama3:70b"}`)
+	newTask(models.TaskTypeWasm, 5, `{}`)
+
+	tasks := controller.availableTasksFor("device-1")
+	if len(tasks) != 2 || tasks[0].ID != docker.ID || tasks[1].ID != llm.ID {
+		t.Fatalf("runner was offered %d tasks, want the docker and llm tasks paying its minimum", len(tasks))
+	}
+	if tasks := controller.availableTasksFor("device-2"); len(tasks) != 5 {
+		t.Fatalf("runner without a manifest was offered %d tasks, want all 5", len(tasks))
+	}
+
+	suggestion := controller.SuggestReward(TaskClass{Type: models.TaskTypeLLM, Model: "llama3:70b"}, time.Now())
+	if suggestion.RunnerMinReward != 3 || suggestion.SuggestedMinReward < 3 || suggestion.RunnersAvailable != 1 {
+		t.Fatalf("suggestion = %+v, want it raised to the runner minimum", suggestion)
+	}
+	if suggestion := controller.SuggestReward(TaskClass{Type: models.TaskTypeWasm}, time.Now()); suggestion.RunnersAvailable != 0 {
+		t.Fatalf("suggestion for a class no runner takes = %+v", suggestion)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/manifests/device-1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get manifest = %d", rec.Code)
+	}
+}
+
+func TestInvalidManifestsAreRejected(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	if code := sendManifestHeartbeat(router, "device-2", signedManifest(t, "device-1", time.Now())); code != http.StatusBadRequest {
+		t.Fatalf("manifest of another device = %d, want 400", code)
+	}
+
+	tampered := signedManifest(t, "device-1", time.Now())
+	tampered.Availability.Slots = 10
+	if code := sendManifestHeartbeat(router, "device-1", tampered); code != http.StatusBadRequest {
+		t.Fatalf("tampered manifest = %d, want 400", code)
+	}
+
+	current := signedManifest(t, "device-1", time.Now())
+	if code := sendManifestHeartbeat(router, "device-1", current); code != http.StatusOK {
+		t.Fatalf("valid manifest = %d", code)
+	}
+	if code := sendManifestHeartbeat(router, "device-1", signedManifest(t, "device-1", time.Now().Add(-time.Hour))); code != http.StatusBadRequest {
+		t.Fatalf("older manifest = %d, want 400", code)
+	}
+
+	body, _ := json.Marshal(models.RunnerRegistration{WalletAddress: "0x0000000000000000000000000000000000000001", Manifest: current})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", "device-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("registration with a manifest of another wallet = %d, want 400", rec.Code)
+	}
+}
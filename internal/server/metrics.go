@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func (c *RunnerController) handleGetTaskMetrics(ctx *gin.Context) {
+	result, ok := c.GetTaskResult(ctx.Param("taskID"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task result not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.MetricsFromResult(result))
+}
+
+func (c *RunnerController) handleGetRunnerMetrics(ctx *gin.Context) {
+	deviceID := ctx.Param("deviceID")
+	ctx.JSON(http.StatusOK, gin.H{
+		"device_id": deviceID,
+		"usage": c.summarizeMetrics(func(result *models.TaskResult) bool {
+			return result.DeviceID == deviceID
+		}),
+	})
+}
+
+func (c *RunnerController) handleGetCreatorMetrics(ctx *gin.Context) {
+	address := ctx.Param("address")
+	ctx.JSON(http.StatusOK, gin.H{
+		"creator_address": address,
+		"usage": c.summarizeMetrics(func(result *models.TaskResult) bool {
+			return strings.EqualFold(result.CreatorAddress, address)
+		}),
+	})
+}
+
+func (c *RunnerController) summarizeMetrics(match func(result *models.TaskResult) bool) models.ResourceUsageSummary {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var summary models.ResourceUsageSummary
+	for _, result := range c.results {
+		if match(result) {
+			summary.Add(models.MetricsFromResult(result))
+		}
+	}
+	return summary
+}
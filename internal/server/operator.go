@@ -0,0 +1,38 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetOperatorToken sets the bearer token of operator routes such as fleet
+// apply and quarantine release. Without one those routes are refused.
+func (c *RunnerController) SetOperatorToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.operatorToken = token
+}
+
+// RequireOperator only lets requests carrying the operator token through.
+// Runners identify with X-Device-ID, which never grants operator access.
+func (c *RunnerController) RequireOperator(ctx *gin.Context) {
+	c.mu.RLock()
+	token := c.operatorToken
+	c.mu.RUnlock()
+
+	if token == "" {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Operator routes are disabled, set SERVER_ADMIN_TOKEN"})
+		ctx.Abort()
+		return
+	}
+	presented, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Operator token required"})
+		ctx.Abort()
+		return
+	}
+	ctx.Next()
+}
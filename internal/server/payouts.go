@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/theblitlabs/gologger"
+	"gorm.io/gorm"
+)
+
+// PayoutStore persists the payout queue so rewards owed to runners survive a
+// server restart
+type PayoutStore interface {
+	SavePayout(ctx context.Context, payout Payout) error
+	DeletePayout(ctx context.Context, taskID string) error
+	LoadPayouts(ctx context.Context) ([]Payout, error)
+}
+
+// GormPayoutStore keeps the payout queue in the payout_queue table
+type GormPayoutStore struct {
+	db *gorm.DB
+}
+
+func NewGormPayoutStore(db *gorm.DB) (*GormPayoutStore, error) {
+	if err := db.AutoMigrate(&Payout{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate payout queue: %w", err)
+	}
+	return &GormPayoutStore{db: db}, nil
+}
+
+func (s *GormPayoutStore) SavePayout(ctx context.Context, payout Payout) error {
+	return s.db.WithContext(ctx).Save(&payout).Error
+}
+
+func (s *GormPayoutStore) DeletePayout(ctx context.Context, taskID string) error {
+	return s.db.WithContext(ctx).Delete(&Payout{}, "task_id = ?", taskID).Error
+}
+
+func (s *GormPayoutStore) LoadPayouts(ctx context.Context) ([]Payout, error) {
+	var payouts []Payout
+	if err := s.db.WithContext(ctx).Order("queued_at").Find(&payouts).Error; err != nil {
+		return nil, err
+	}
+	return payouts, nil
+}
+
+// FilePayoutStore keeps the payout queue as one JSON file per payout in dir,
+// for servers that run without a database
+type FilePayoutStore struct {
+	dir string
+}
+
+func NewFilePayoutStore(dir string) *FilePayoutStore {
+	return &FilePayoutStore{dir: dir}
+}
+
+func (s *FilePayoutStore) path(taskID string) (string, error) {
+	if _, err := uuid.Parse(taskID); err != nil {
+		return "", fmt.Errorf("invalid task ID %q", taskID)
+	}
+	return filepath.Join(s.dir, taskID+".json"), nil
+}
+
+func (s *FilePayoutStore) SavePayout(ctx context.Context, payout Payout) error {
+	path, err := s.path(payout.TaskID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create payout queue directory: %w", err)
+	}
+	data, err := json.MarshalIndent(payout, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal payout: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write payout: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write payout: %w", err)
+	}
+	return nil
+}
+
+func (s *FilePayoutStore) DeletePayout(ctx context.Context, taskID string) error {
+	path, err := s.path(taskID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove payout: %w", err)
+	}
+	return nil
+}
+
+func (s *FilePayoutStore) LoadPayouts(ctx context.Context) ([]Payout, error) {
+	files, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payout queue: %w", err)
+	}
+
+	var payouts []Payout
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read payout: %w", err)
+		}
+		var payout Payout
+		if err := json.Unmarshal(data, &payout); err != nil {
+			return nil, fmt.Errorf("failed to parse payout %s: %w", file.Name(), err)
+		}
+		payouts = append(payouts, payout)
+	}
+	return payouts, nil
+}
+
+// SetPayoutStore persists the payout queue in store and restores payouts queued
+// before the last restart
+func (g *ChainGateway) SetPayoutStore(ctx context.Context, store PayoutStore) error {
+	payouts, err := store.LoadPayouts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load payout queue: %w", err)
+	}
+	sort.SliceStable(payouts, func(i, j int) bool { return payouts[i].QueuedAt.Before(payouts[j].QueuedAt) })
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.store = store
+
+	queued := make(map[string]bool)
+	for _, payout := range g.pendingPayoutsLocked() {
+		queued[payout.TaskID] = true
+	}
+	var batched []*Payout
+	for i := range payouts {
+		payout := &payouts[i]
+		if queued[payout.TaskID] {
+			continue
+		}
+		g.requestedAmount += payout.Amount
+		switch {
+		case payout.BatchID != "":
+			batched = append(batched, payout)
+		case payout.Attempts == 0 && g.batching():
+			// Collected for a batch that had not formed yet
+			g.collecting = append(g.collecting, payout)
+		default:
+			g.pending = append(g.pending, payout)
+		}
+	}
+	g.restoreBatchesLocked(batched)
+
+	if len(payouts) > 0 {
+		log := gologger.WithComponent("chain")
+		log.Info().Int("payouts", len(payouts)).Msg("Restored queued payouts")
+	}
+	return nil
+}
+
+// persist and unpersist only log failures, the in-memory queue keeps retrying
+// either way
+func (g *ChainGateway) persist(ctx context.Context, payout Payout) {
+	g.mu.Lock()
+	store := g.store
+	g.mu.Unlock()
+	if store == nil {
+		return
+	}
+	if err := store.SavePayout(ctx, payout); err != nil {
+		log := gologger.WithComponent("chain")
+		log.Error().Err(err).Str("task_id", payout.TaskID).Msg("Failed to persist queued payout")
+	}
+}
+
+func (g *ChainGateway) unpersist(ctx context.Context, taskID string) {
+	g.mu.Lock()
+	store := g.store
+	g.mu.Unlock()
+	if store == nil {
+		return
+	}
+	if err := store.DeletePayout(ctx, taskID); err != nil {
+		log := gologger.WithComponent("chain")
+		log.Error().Err(err).Str("task_id", taskID).Msg("Failed to remove settled payout")
+	}
+}
+
+// RunnerEarnings is what a runner has been paid and what it is still owed
+type RunnerEarnings struct {
+	DeviceID     string     `json:"device_id"`
+	PaidTotal    float64    `json:"paid_total"`
+	PaidCount    int        `json:"paid_count"`
+	LastPaidAt   *time.Time `json:"last_paid_at,omitempty"`
+	PendingTotal float64    `json:"pending_total"`
+	Pending      []Payout   `json:"pending"`
+}
+
+func (c *RunnerController) recordPaid(payout Payout, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	earnings, ok := c.earnings[payout.DeviceID]
+	if !ok {
+		earnings = &RunnerEarnings{DeviceID: payout.DeviceID}
+		c.earnings[payout.DeviceID] = earnings
+	}
+	earnings.PaidTotal += payout.Amount
+	earnings.PaidCount++
+	paidAt := at
+	earnings.LastPaidAt = &paidAt
+}
+
+func (c *RunnerController) Earnings(deviceID string) RunnerEarnings {
+	c.mu.RLock()
+	result := RunnerEarnings{DeviceID: deviceID}
+	if earnings, ok := c.earnings[deviceID]; ok {
+		result = *earnings
+	}
+	c.mu.RUnlock()
+
+	result.Pending = make([]Payout, 0)
+	for _, gateway := range c.gateways() {
+		for _, payout := range gateway.PendingPayouts() {
+			if payout.DeviceID == deviceID {
+				result.Pending = append(result.Pending, payout)
+				result.PendingTotal += payout.Amount
+			}
+		}
+	}
+	return result
+}
+
+func (c *RunnerController) handleRunnerEarnings(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.Earnings(ctx.Param("deviceID")))
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+type PreflightState string
+
+const (
+	PreflightPending PreflightState = "pending"
+	PreflightPassed  PreflightState = "passed"
+	PreflightFailed  PreflightState = "failed"
+)
+
+// PreflightStatus is where a task held for its preflight stands. The task is
+// queued once the preflight passes and dropped if it fails.
+type PreflightStatus struct {
+	TaskID          string                  `json:"task_id"`
+	PreflightTaskID string                  `json:"preflight_task_id"`
+	State           PreflightState          `json:"state"`
+	Report          *models.PreflightReport `json:"report,omitempty"`
+	Problems        []string                `json:"problems,omitempty"`
+
+	task *models.Task
+}
+
+// holdForPreflight queues a preflight task for task and keeps task back until
+// its result is in
+func (c *RunnerController) holdForPreflight(task *models.Task) (*PreflightStatus, error) {
+	preflight, err := models.NewPreflightTask(task)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &PreflightStatus{
+		TaskID:          task.ID.String(),
+		PreflightTaskID: preflight.ID.String(),
+		State:           PreflightPending,
+		task:            task,
+	}
+	c.mu.Lock()
+	if c.preflights == nil {
+		c.preflights = make(map[string]*PreflightStatus)
+	}
+	c.preflights[status.TaskID] = status
+	c.preflights[status.PreflightTaskID] = status
+	c.mu.Unlock()
+
+	c.AddAvailableTask(preflight)
+	return status, nil
+}
+
+// resolvePreflight queues or drops the task a preflight result is for. Results
+// of other tasks are ignored.
+func (c *RunnerController) resolvePreflight(result *models.TaskResult) {
+	log := gologger.WithComponent("preflight")
+
+	c.mu.Lock()
+	status, ok := c.preflights[result.TaskID.String()]
+	if !ok || status.PreflightTaskID != result.TaskID.String() || status.State != PreflightPending {
+		c.mu.Unlock()
+		return
+	}
+
+	report, err := models.ParsePreflightReport(result)
+	switch {
+	case err != nil:
+		status.State = PreflightFailed
+		status.Problems = []string{err.Error()}
+	case report.Ready:
+		status.State = PreflightPassed
+		status.Report = report
+	default:
+		status.State = PreflightFailed
+		status.Report = report
+		status.Problems = report.Problems()
+	}
+	task := status.task
+	status.task = nil
+	state, problems := status.State, status.Problems
+	c.mu.Unlock()
+
+	if state == PreflightPassed {
+		log.Info().Str("task_id", task.ID.String()).Msg("Preflight passed, queueing task")
+		c.queueTask(task)
+		return
+	}
+	log.Warn().
+		Str("task_id", task.ID.String()).
+		Str("problems", strings.Join(problems, "; ")).
+		Msg("Preflight failed, task not queued")
+}
+
+func (c *RunnerController) handleGetPreflight(ctx *gin.Context) {
+	c.mu.RLock()
+	status, ok := c.preflights[ctx.Param("taskID")]
+	var response PreflightStatus
+	if ok {
+		response = *status
+	}
+	c.mu.RUnlock()
+
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task has no preflight"})
+		return
+	}
+	ctx.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func createWithPreflight(t *testing.T, router http.Handler) (string, string) {
+	t.Helper()
+
+	body := []byte(`{"title":"train","type":"command","config":{"command":"echo hi"},"nonce":"n"}`)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks?preflight=true", bytes.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("create with preflight = %d %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Task            models.Task `json:"task"`
+		PreflightTaskID string      `json:"preflight_task_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Task.ID.String(), response.PreflightTaskID
+}
+
+func submitPreflightReport(t *testing.T, controller *RunnerController, preflightTaskID string, report models.PreflightReport) {
+	t.Helper()
+
+	output, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := controller.submitTaskResult(context.Background(), &models.TaskResult{
+		TaskID:   uuid.MustParse(preflightTaskID),
+		DeviceID: "device-1",
+		Output:   string(output),
+	}); err != nil {
+		t.Fatalf("submitTaskResult() error = %v", err)
+	}
+}
+
+func getPreflight(t *testing.T, router http.Handler, taskID string) PreflightStatus {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+taskID+"/preflight", nil))
+	var status PreflightStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("get preflight = %d %s", rec.Code, rec.Body.String())
+	}
+	return status
+}
+
+func TestPreflightHoldsTaskUntilItPasses(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	taskID, preflightTaskID := createWithPreflight(t, router)
+	tasks := controller.availableTasksFor("device-1")
+	if len(tasks) != 1 || tasks[0].ID.String() != preflightTaskID || tasks[0].Type != models.TaskTypePreflight {
+		t.Fatalf("only the preflight task should be queued, got %d tasks", len(tasks))
+	}
+	if status := getPreflight(t, router, taskID); status.State != PreflightPending {
+		t.Fatalf("state = %s, want pending", status.State)
+	}
+
+	controller.RemoveAvailableTask(preflightTaskID)
+	submitPreflightReport(t, controller, preflightTaskID, models.PreflightReport{Ready: true, TaskType: models.TaskTypeCommand})
+
+	tasks = controller.availableTasksFor("device-1")
+	if len(tasks) != 1 || tasks[0].ID.String() != taskID {
+		t.Fatal("the task should be queued once its preflight passed")
+	}
+	if status := getPreflight(t, router, taskID); status.State != PreflightPassed {
+		t.Fatalf("state = %s, want passed", status.State)
+	}
+}
+
+func TestFailedPreflightDropsTask(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	taskID, preflightTaskID := createWithPreflight(t, router)
+	controller.RemoveAvailableTask(preflightTaskID)
+	submitPreflightReport(t, controller, preflightTaskID, models.PreflightReport{
+		TaskType: models.TaskTypeCommand,
+		Checks:   []models.PreflightCheck{{Name: "image", Status: models.PreflightFailed, Detail: "manifest unknown"}},
+	})
+
+	if len(controller.availableTasksFor("device-1")) != 0 {
+		t.Fatal("a task that failed its preflight must not be queued")
+	}
+	status := getPreflight(t, router, taskID)
+	if status.PreflightTaskID != preflightTaskID || status.State != PreflightFailed || len(status.Problems) != 1 || status.Problems[0] != "image: manifest unknown" {
+		t.Fatalf("status = %+v", status)
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// TaskClass groups tasks that cost roughly the same to run
+type TaskClass struct {
+	Type                   models.TaskType `json:"type"`
+	ImageSizeMB            float64         `json:"image_size_mb,omitempty"`
+	ExpectedRuntimeSeconds float64         `json:"expected_runtime_seconds,omitempty"`
+	Model                  string          `json:"model,omitempty"`
+}
+
+// TaskClassFromTask derives the pricing class of a submitted task from its config
+func TaskClassFromTask(task *models.Task) TaskClass {
+	class := TaskClass{Type: task.Type}
+
+	var config struct {
+		models.TaskConfig
+		Model       string  `json:"model"`
+		ImageSizeMB float64 `json:"image_size_mb"`
+	}
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		return class
+	}
+
+	class.Model = config.Model
+	class.ImageSizeMB = config.ImageSizeMB
+	if timeout, err := time.ParseDuration(config.Resources.Timeout); err == nil {
+		class.ExpectedRuntimeSeconds = timeout.Seconds()
+	}
+	return class
+}
+
+type PricingConfig struct {
+	BaseReward          float64
+	PerRuntimeSecond    float64
+	PerImageGB          float64
+	ModelRewards        map[string]float64
+	Window              time.Duration
+	MaxDemandMultiplier float64
+	// EnforceFloor rejects submitted tasks whose reward is below the suggestion
+	EnforceFloor bool
+}
+
+func DefaultPricingConfig() PricingConfig {
+	return PricingConfig{
+		BaseReward:          0.01,
+		PerRuntimeSecond:    0.0001,
+		PerImageGB:          0.005,
+		ModelRewards:        make(map[string]float64),
+		Window:              time.Hour,
+		MaxDemandMultiplier: 3,
+	}
+}
+
+// PricingFromConfig starts from the defaults and overrides what cfg sets
+func PricingFromConfig(cfg config.PricingConfig) (PricingConfig, error) {
+	pricing := DefaultPricingConfig()
+	if cfg.BaseReward > 0 {
+		pricing.BaseReward = cfg.BaseReward
+	}
+	if cfg.PerRuntimeSecond > 0 {
+		pricing.PerRuntimeSecond = cfg.PerRuntimeSecond
+	}
+	if cfg.PerImageGB > 0 {
+		pricing.PerImageGB = cfg.PerImageGB
+	}
+	if cfg.Window > 0 {
+		pricing.Window = cfg.Window
+	}
+	if cfg.MaxDemandMultiplier > 0 {
+		pricing.MaxDemandMultiplier = cfg.MaxDemandMultiplier
+	}
+	pricing.EnforceFloor = cfg.EnforceFloor
+
+	for _, entry := range strings.Split(cfg.ModelRewards, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, value, ok := strings.Cut(entry, "=")
+		model = strings.TrimSpace(model)
+		reward, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || model == "" || err != nil || reward < 0 {
+			return PricingConfig{}, fmt.Errorf("expected model=reward, got %q", entry)
+		}
+		pricing.ModelRewards[model] = reward
+	}
+	return pricing, nil
+}
+
+type PriceSuggestion struct {
+	Class              TaskClass `json:"class"`
+	BaseReward         float64   `json:"base_reward"`
+	DemandMultiplier   float64   `json:"demand_multiplier"`
+	CompletionRate     float64   `json:"completion_rate"`
+	SuggestedMinReward float64   `json:"suggested_min_reward"`
+	FloorEnforced      bool      `json:"floor_enforced"`
+	// RunnerMinReward is the lowest minimum reward among the runners that take
+	// the class, from their manifests. The suggestion never falls below it.
+	RunnerMinReward float64 `json:"runner_min_reward,omitempty"`
+	// RunnersAvailable are the runners taking the class with a free slot
+	RunnersAvailable int `json:"runners_available"`
+}
+
+// SetPricing replaces the pricing configuration used by the estimate endpoint
+func (c *RunnerController) SetPricing(config PricingConfig) {
+	if config.Window <= 0 {
+		config.Window = time.Hour
+	}
+	if config.MaxDemandMultiplier < 1 {
+		config.MaxDemandMultiplier = 1
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pricing = config
+}
+
+// SuggestReward prices a task class from its base cost, scaled up when tasks are
+// queueing faster than runners can take them and when many attempts fail
+func (c *RunnerController) SuggestReward(class TaskClass, now time.Time) PriceSuggestion {
+	c.mu.RLock()
+	config := c.pricing
+	c.mu.RUnlock()
+
+	base := config.BaseReward +
+		config.PerRuntimeSecond*class.ExpectedRuntimeSeconds +
+		config.PerImageGB*class.ImageSizeMB/1024 +
+		config.ModelRewards[class.Model]
+
+	overview := c.Overview(config.Window, now)
+
+	multiplier := 1.0
+	if overview.QueueDepth > 0 {
+		pressure := float64(overview.QueueDepth) / math.Max(float64(overview.RunnersOnline), 1)
+		multiplier = math.Min(math.Max(1, pressure), config.MaxDemandMultiplier)
+	}
+
+	completionRate := 1.0
+	if attempts := overview.TasksCompleted + overview.TasksFailed; attempts > 0 {
+		completionRate = math.Max(float64(overview.TasksCompleted)/float64(attempts), 0.1)
+	}
+
+	suggested := base * multiplier / completionRate
+	offer := c.offerFor(class, now)
+	if offer.accepting > 0 {
+		suggested = math.Max(suggested, offer.minReward)
+	}
+
+	return PriceSuggestion{
+		Class:              class,
+		BaseReward:         roundReward(base),
+		DemandMultiplier:   multiplier,
+		CompletionRate:     completionRate,
+		SuggestedMinReward: roundReward(suggested),
+		FloorEnforced:      config.EnforceFloor,
+		RunnerMinReward:    roundReward(offer.minReward),
+		RunnersAvailable:   offer.available,
+	}
+}
+
+// CheckRewardFloor rejects a task priced below the current suggestion when the
+// deployment enforces the floor
+func (c *RunnerController) CheckRewardFloor(task *models.Task) error {
+	suggestion := c.SuggestReward(TaskClassFromTask(task), time.Now())
+	if !suggestion.FloorEnforced || task.Reward >= suggestion.SuggestedMinReward {
+		return nil
+	}
+	return fmt.Errorf("reward %.8f is below the minimum of %.8f for this task class", task.Reward, suggestion.SuggestedMinReward)
+}
+
+func roundReward(value float64) float64 {
+	return math.Round(value*1e8) / 1e8
+}
+
+func (c *RunnerController) handleEstimate(ctx *gin.Context) {
+	var class TaskClass
+	if err := ctx.BindJSON(&class); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if class.Type == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Task type is required"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, c.SuggestReward(class, time.Now()))
+}
+
+func (c *RunnerController) handleCreateTask(ctx *gin.Context) {
+	task := models.NewTask()
+	if err := ctx.BindJSON(task); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	task.CreatorDeviceID = ctx.GetHeader("X-Device-ID")
+
+	if status, err := c.admitTask(ctx.Request.Context(), task); err != nil {
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// With preflight the task is queued once a runner has checked it
+	if ctx.Query("preflight") == "true" {
+		status, err := c.holdForPreflight(task)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusAccepted, gin.H{"task": task, "preflight_task_id": status.PreflightTaskID})
+		return
+	}
+
+	c.queueTask(task)
+	ctx.JSON(http.StatusCreated, task)
+}
+
+// admitTask validates a submitted task and prepares it for the queue. On failure
+// it returns the HTTP status to answer with.
+func (c *RunnerController) admitTask(ctx context.Context, task *models.Task) (int, error) {
+	log := gologger.WithComponent("runner_controller")
+
+	task.Status = models.TaskStatusPending
+
+	if err := task.Validate(); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := c.checkCredentialBrokers(task); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	c.mu.RLock()
+	timeouts := c.timeouts
+	c.mu.RUnlock()
+	if err := timeouts.Apply(task); err != nil {
+		if errors.Is(err, ErrTimeoutExceedsPolicy) {
+			return http.StatusUnprocessableEntity, err
+		}
+		return http.StatusBadRequest, err
+	}
+
+	if err := c.CheckRewardFloor(task); err != nil {
+		log.Debug().Err(err).Str("task_id", task.ID.String()).Msg("Rejected task below reward floor")
+		return http.StatusUnprocessableEntity, err
+	}
+
+	offloaded, err := c.offloadInlineContent(ctx, task)
+	if err != nil {
+		log.Error().Err(err).Str("task_id", task.ID.String()).Msg("Failed to offload inline task content")
+		return http.StatusBadGateway, errors.New("failed to store task content")
+	}
+	if offloaded {
+		log.Debug().Str("task_id", task.ID.String()).Msg("Moved oversized inline task content to IPFS")
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,354 @@
+package server
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const sealAlgorithm = "AES-256-GCM"
+
+// KeyWrapper wraps the per-result data keys with a deployment key, e.g. one held
+// in a cloud KMS
+type KeyWrapper interface {
+	KeyID() string
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyWrapper wraps data keys with a static AES-256 key from the deployment
+// configuration
+type LocalKeyWrapper struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func NewLocalKeyWrapper(id string, key []byte) (*LocalKeyWrapper, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		id = "local"
+	}
+	return &LocalKeyWrapper{id: id, aead: aead}, nil
+}
+
+func (w *LocalKeyWrapper) KeyID() string {
+	return w.id
+}
+
+func (w *LocalKeyWrapper) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	nonce := make([]byte, w.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return w.aead.Seal(nonce, nonce, dataKey, []byte(w.id)), nil
+}
+
+func (w *LocalKeyWrapper) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != w.id {
+		return nil, fmt.Errorf("result was sealed with key %q, have %q", keyID, w.id)
+	}
+	size := w.aead.NonceSize()
+	if len(wrapped) < size {
+		return nil, errors.New("wrapped key is truncated")
+	}
+	return w.aead.Open(nil, wrapped[:size], wrapped[size:], []byte(w.id))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts plaintext under a fresh data key. The task ID is authenticated
+// with it so a sealed output cannot be moved to another result.
+func Seal(ctx context.Context, keys KeyWrapper, taskID uuid.UUID, plaintext []byte) (*models.SealedPayload, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	wrapped, err := keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	return &models.SealedPayload{
+		Algorithm:  sealAlgorithm,
+		KeyID:      keys.KeyID(),
+		WrappedKey: wrapped,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, taskID[:]),
+	}, nil
+}
+
+func Open(ctx context.Context, keys KeyWrapper, taskID uuid.UUID, sealed *models.SealedPayload) ([]byte, error) {
+	if sealed.Algorithm != sealAlgorithm {
+		return nil, fmt.Errorf("unsupported algorithm %q", sealed.Algorithm)
+	}
+	dataKey, err := keys.UnwrapKey(ctx, sealed.KeyID, sealed.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	plaintext, err := aead.Open(nil, sealed.Nonce, sealed.Ciphertext, taskID[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt result: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Scrubber removes personal data from result text before it is stored. Regex
+// rules ship with the server; NER-based scrubbers implement the same interface.
+type Scrubber interface {
+	Scrub(ctx context.Context, text string) (string, error)
+}
+
+type scrubRule struct {
+	name    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+var builtinScrubRules = map[string]scrubRule{
+	"email": {
+		name:    "email",
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	"phone": {
+		name:    "phone",
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}(?:[ .-]?\d{2,4}){2,4}|\(\d{3}\) ?\d{3}[ .-]\d{4}|\b\d{3}[ .-]\d{3}[ .-]\d{4})\b`),
+	},
+	"ipv4": {
+		name:    "ipv4",
+		pattern: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+	},
+	"credit_card": {
+		name:    "credit_card",
+		pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid:   luhnValid,
+	},
+	"ssn": {
+		name:    "ssn",
+		pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	},
+}
+
+// RegexScrubber replaces every match of its rules with [REDACTED:<rule>]
+type RegexScrubber struct {
+	rules []scrubRule
+}
+
+// NewRegexScrubber builds a scrubber from built-in rule names (email, phone, ipv4,
+// credit_card, ssn) and custom patterns
+func NewRegexScrubber(names []string, patterns []string) (*RegexScrubber, error) {
+	scrubber := &RegexScrubber{}
+	for _, name := range names {
+		rule, ok := builtinScrubRules[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown scrub rule %q", name)
+		}
+		scrubber.rules = append(scrubber.rules, rule)
+	}
+	for _, pattern := range patterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrub pattern %q: %w", pattern, err)
+		}
+		scrubber.rules = append(scrubber.rules, scrubRule{name: "custom", pattern: compiled})
+	}
+	return scrubber, nil
+}
+
+func (s *RegexScrubber) Scrub(ctx context.Context, text string) (string, error) {
+	for _, rule := range s.rules {
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.valid != nil && !rule.valid(match) {
+				return match
+			}
+			return "[REDACTED:" + rule.name + "]"
+		})
+	}
+	return text, nil
+}
+
+// luhnValid keeps long numbers that are not card numbers, e.g. numeric output
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ResultPrivacy is how results are protected before they are stored. Both parts
+// are optional.
+type ResultPrivacy struct {
+	Keys      KeyWrapper
+	Scrubbers []Scrubber
+}
+
+func ResultPrivacyFromConfig(cfg config.PrivacyConfig) (ResultPrivacy, error) {
+	var privacy ResultPrivacy
+
+	if cfg.EncryptionKey != "" {
+		key, err := base64.StdEncoding.DecodeString(cfg.EncryptionKey)
+		if err != nil {
+			return ResultPrivacy{}, fmt.Errorf("encryption key is not valid base64: %w", err)
+		}
+		keys, err := NewLocalKeyWrapper(cfg.EncryptionKeyID, key)
+		if err != nil {
+			return ResultPrivacy{}, err
+		}
+		privacy.Keys = keys
+	}
+
+	var names, patterns []string
+	for _, name := range strings.Split(cfg.ScrubRules, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if cfg.ScrubPattern != "" {
+		patterns = append(patterns, cfg.ScrubPattern)
+	}
+	if len(names) > 0 || len(patterns) > 0 {
+		scrubber, err := NewRegexScrubber(names, patterns)
+		if err != nil {
+			return ResultPrivacy{}, err
+		}
+		privacy.Scrubbers = append(privacy.Scrubbers, scrubber)
+	}
+	return privacy, nil
+}
+
+// SetResultPrivacy enables scrubbing and encryption of submitted results
+func (c *RunnerController) SetResultPrivacy(privacy ResultPrivacy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.privacy = privacy
+}
+
+// protectResult scrubs the result in place and returns the copy to store, which
+// has its output sealed when encryption is enabled
+func (c *RunnerController) protectResult(ctx context.Context, result *models.TaskResult) (*models.TaskResult, error) {
+	c.mu.RLock()
+	privacy := c.privacy
+	c.mu.RUnlock()
+
+	for _, scrubber := range privacy.Scrubbers {
+		output, err := scrubber.Scrub(ctx, result.Output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scrub output: %w", err)
+		}
+		errText, err := scrubber.Scrub(ctx, result.Error)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scrub error: %w", err)
+		}
+		result.Output, result.Error = output, errText
+	}
+
+	stored := *result
+	if privacy.Keys == nil || result.Output == "" {
+		return &stored, nil
+	}
+
+	sealed, err := Seal(ctx, privacy.Keys, result.TaskID, []byte(result.Output))
+	if err != nil {
+		return nil, err
+	}
+	stored.Output = ""
+	stored.Sealed = sealed
+	return &stored, nil
+}
+
+// OpenTaskResult returns a stored result with its output decrypted
+func (c *RunnerController) OpenTaskResult(ctx context.Context, taskID string) (*models.TaskResult, error) {
+	stored, ok := c.GetTaskResult(taskID)
+	if !ok {
+		return nil, nil
+	}
+
+	result := *stored
+	if result.Sealed == nil {
+		return &result, nil
+	}
+
+	c.mu.RLock()
+	keys := c.privacy.Keys
+	c.mu.RUnlock()
+	if keys == nil {
+		return nil, errors.New("result is encrypted and no key is configured")
+	}
+
+	output, err := Open(ctx, keys, result.TaskID, result.Sealed)
+	if err != nil {
+		return nil, err
+	}
+	result.Output = string(output)
+	result.Sealed = nil
+	return &result, nil
+}
+
+func (c *RunnerController) handleGetTaskResult(ctx *gin.Context) {
+	result, err := c.OpenTaskResult(ctx.Request.Context(), ctx.Param("taskID"))
+	if err != nil {
+		log := gologger.WithComponent("runner_controller")
+		log.Error().Err(err).Str("task_id", ctx.Param("taskID")).Msg("Failed to open sealed result")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt result"})
+		return
+	}
+	if result == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Result not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestRegexScrubber(t *testing.T) {
+	scrubber, err := NewRegexScrubber([]string{"email", "phone", "ipv4", "credit_card", "ssn"}, []string{`patient-\d+`})
+	if err != nil {
+		t.Fatalf("NewRegexScrubber() error = %v", err)
+	}
+
+	input := "mail jane.doe@example.com or call (555) 123-4567 from 10.1.2.3, card 4111 1111 1111 1111, ssn 123-45-6789, patient-42, loss 0.123456789012345 step 1700000600000"
+	got, err := scrubber.Scrub(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Scrub() error = %v", err)
+	}
+
+	for _, leaked := range []string{"jane.doe@example.com", "123-4567", "10.1.2.3", "4111 1111", "123-45-6789", "patient-42"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("Scrub() left %q in %q", leaked, got)
+		}
+	}
+	for _, kept := range []string{"0.123456789012345", "1700000600000"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("Scrub() removed numeric output %q: %q", kept, got)
+		}
+	}
+
+	if _, err := NewRegexScrubber([]string{"passport"}, nil); err == nil {
+		t.Error("NewRegexScrubber() accepted an unknown rule")
+	}
+}
+
+func TestSealBindsOutputToTask(t *testing.T) {
+	keys, err := NewLocalKeyWrapper("k1", bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewLocalKeyWrapper() error = %v", err)
+	}
+
+	ctx := context.Background()
+	taskID := uuid.New()
+	sealed, err := Seal(ctx, keys, taskID, []byte("secret output"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if bytes.Contains(sealed.Ciphertext, []byte("secret")) {
+		t.Fatal("ciphertext contains the plaintext")
+	}
+
+	if plaintext, err := Open(ctx, keys, taskID, sealed); err != nil || string(plaintext) != "secret output" {
+		t.Fatalf("Open() = %q, %v", plaintext, err)
+	}
+	if _, err := Open(ctx, keys, uuid.New(), sealed); err == nil {
+		t.Fatal("Open() succeeded for another task ID")
+	}
+
+	other, _ := NewLocalKeyWrapper("k2", bytes.Repeat([]byte{7}, 32))
+	if _, err := Open(ctx, other, taskID, sealed); err == nil {
+		t.Fatal("Open() succeeded with a different key ID")
+	}
+}
+
+func TestHandleTaskResultScrubsAndEncrypts(t *testing.T) {
+	privacy, err := ResultPrivacyFromConfig(config.PrivacyConfig{
+		EncryptionKey: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)),
+		ScrubRules:    "email",
+	})
+	if err != nil {
+		t.Fatalf("ResultPrivacyFromConfig() error = %v", err)
+	}
+
+	controller := NewRunnerController(nil)
+	controller.SetResultPrivacy(privacy)
+
+	var hookOutput string
+	controller.RegisterResultHook(ResultHookFunc{
+		HookName: "export",
+		Fn: func(ctx context.Context, result *models.TaskResult) (ResultHookOutcome, error) {
+			hookOutput = result.Output
+			return ResultHookOutcome{}, nil
+		},
+	})
+	router := newTestRouter(controller)
+
+	taskID := uuid.New()
+	body, _ := json.Marshal(models.TaskResult{TaskID: taskID, Output: "contact ops@example.com"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+taskID.String()+"/result", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", "device-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	stored, ok := controller.GetTaskResult(taskID.String())
+	if !ok || stored.Output != "" || stored.Sealed == nil {
+		t.Fatalf("stored result = %+v, want sealed output", stored)
+	}
+	if hookOutput != "contact [REDACTED:email]" {
+		t.Fatalf("hook saw output %q", hookOutput)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+taskID.String()+"/result", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET result status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var opened models.TaskResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &opened); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if opened.Output != "contact [REDACTED:email]" || opened.Sealed != nil {
+		t.Fatalf("opened result = %+v", opened)
+	}
+}
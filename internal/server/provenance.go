@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/provenance"
+)
+
+// ProvenancePolicy decides which runner builds may register and take tasks
+type ProvenancePolicy struct {
+	// MinVersion is the oldest release accepted, such as v1.4.0. Empty accepts
+	// any signed release.
+	MinVersion string
+	// ReleaseSigners are the addresses of the keys releases are signed with.
+	// Without any every build is accepted.
+	ReleaseSigners []string
+}
+
+type provenancePolicy struct {
+	minVersion string
+	signers    []common.Address
+}
+
+// SetProvenancePolicy makes runners prove with signed build provenance that
+// they run a release of at least the minimum version
+func (c *RunnerController) SetProvenancePolicy(policy ProvenancePolicy) error {
+	var enforced *provenancePolicy
+	if len(policy.ReleaseSigners) > 0 {
+		enforced = &provenancePolicy{minVersion: policy.MinVersion}
+		for _, signer := range policy.ReleaseSigners {
+			if !common.IsHexAddress(signer) {
+				return fmt.Errorf("invalid release signer address %q", signer)
+			}
+			enforced.signers = append(enforced.signers, common.HexToAddress(signer))
+		}
+		if policy.MinVersion != "" {
+			if _, err := provenance.CompareVersions(policy.MinVersion, policy.MinVersion); err != nil {
+				return err
+			}
+		}
+	} else if policy.MinVersion != "" {
+		return fmt.Errorf("a minimum runner version needs release signers to check it against")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.provenancePolicy = enforced
+	return nil
+}
+
+// recordBuild keeps the build provenance a runner registered with once the
+// policy accepts it
+func (c *RunnerController) recordBuild(deviceID string, build *models.BuildProvenance) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.provenancePolicy.check(build); err != nil {
+		return err
+	}
+	if c.builds == nil {
+		c.builds = make(map[string]*models.BuildProvenance)
+	}
+	if build == nil {
+		delete(c.builds, deviceID)
+	} else {
+		c.builds[deviceID] = build
+	}
+	return nil
+}
+
+// checkRunnerBuild reports the HTTP status to refuse a task start with when the
+// runner's build does not satisfy the policy, which may have changed since it
+// registered, or 0 to proceed
+func (c *RunnerController) checkRunnerBuild(deviceID string) (int, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if err := c.provenancePolicy.check(c.builds[deviceID]); err != nil {
+		return http.StatusForbidden, "Runner build not accepted: " + err.Error()
+	}
+	return 0, ""
+}
+
+func (p *provenancePolicy) check(build *models.BuildProvenance) error {
+	if p == nil {
+		return nil
+	}
+	if build == nil {
+		return fmt.Errorf("runner did not report its build provenance")
+	}
+	if err := provenance.Verify(build, p.signers); err != nil {
+		return err
+	}
+	if p.minVersion == "" {
+		return nil
+	}
+	cmp, err := provenance.CompareVersions(build.RunnerVersion, p.minVersion)
+	if err != nil {
+		return err
+	}
+	if cmp < 0 {
+		return fmt.Errorf("runner %s is older than the minimum version %s", build.RunnerVersion, p.minVersion)
+	}
+	return nil
+}
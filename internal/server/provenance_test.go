@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/provenance"
+)
+
+func registerBuild(t *testing.T, router http.Handler, deviceID string, build *models.BuildProvenance) int {
+	t.Helper()
+	body, _ := json.Marshal(models.RunnerRegistration{
+		WalletAddress: "0x0000000000000000000000000000000000000001",
+		Status:        models.RunnerStatusOnline,
+		Build:         build,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", deviceID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestProvenancePolicyRefusesUnattestedAndOldRunners(t *testing.T) {
+	releaseKey, _ := crypto.GenerateKey()
+	release := func(version string) *models.BuildProvenance {
+		build := &models.BuildProvenance{RunnerVersion: version, Commit: "4f2c1e9d0a7b", Builder: "ci"}
+		if err := provenance.Sign(build, releaseKey); err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return build
+	}
+
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	// Without a policy unsigned builds register and take tasks as before
+	if code := registerBuild(t, router, "device-1", &models.BuildProvenance{RunnerVersion: "devel"}); code != http.StatusOK {
+		t.Fatalf("registration without a policy = %d", code)
+	}
+
+	if err := controller.SetProvenancePolicy(ProvenancePolicy{MinVersion: "v1.4.0"}); err == nil {
+		t.Fatal("SetProvenancePolicy() accepted a minimum version without release signers")
+	}
+	if err := controller.SetProvenancePolicy(ProvenancePolicy{
+		MinVersion:     "v1.4.0",
+		ReleaseSigners: []string{crypto.PubkeyToAddress(releaseKey.PublicKey).Hex()},
+	}); err != nil {
+		t.Fatalf("SetProvenancePolicy() error = %v", err)
+	}
+
+	task := models.NewTask()
+	controller.AddAvailableTask(task)
+	start := func(deviceID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+task.ID.String()+"/start", nil)
+		req.Header.Set("X-Device-ID", deviceID)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := start("device-1"); code != http.StatusForbidden {
+		t.Fatalf("start by a runner registered with an unsigned build = %d, want %d", code, http.StatusForbidden)
+	}
+
+	if code := registerBuild(t, router, "device-2", nil); code != http.StatusForbidden {
+		t.Fatalf("registration without provenance = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := registerBuild(t, router, "device-2", release("v1.3.9")); code != http.StatusForbidden {
+		t.Fatalf("registration of an old release = %d, want %d", code, http.StatusForbidden)
+	}
+	tampered := release("v1.4.0")
+	tampered.Commit = "0badc0ffee00"
+	if code := registerBuild(t, router, "device-2", tampered); code != http.StatusForbidden {
+		t.Fatalf("registration with a tampered commit = %d, want %d", code, http.StatusForbidden)
+	}
+
+	if code := registerBuild(t, router, "device-2", release("v1.4.2")); code != http.StatusOK {
+		t.Fatalf("registration of a signed release = %d, want %d", code, http.StatusOK)
+	}
+	if code := start("device-2"); code != http.StatusOK {
+		t.Fatalf("start by a signed release = %d, want %d", code, http.StatusOK)
+	}
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+)
+
+// QuarantineEvent is what happened to a runner's quarantine
+type QuarantineEvent string
+
+const (
+	QuarantineEntered  QuarantineEvent = "quarantined"
+	QuarantineReleased QuarantineEvent = "released"
+	QuarantineAppealed QuarantineEvent = "appealed"
+)
+
+// QuarantineNotifyFunc tells the server operator about quarantine changes
+type QuarantineNotifyFunc func(deviceID string, event QuarantineEvent, reason string)
+
+// QuarantineConfig quarantines runners that fail Threshold canaries within Window.
+// A quarantined runner is only offered canary tasks. It is released once it has
+// been in quarantine for Probation and passed ProbationCanaries canaries in a
+// row; a failed canary starts the count again.
+type QuarantineConfig struct {
+	Threshold         int
+	Window            time.Duration
+	Probation         time.Duration
+	ProbationCanaries int
+	Notify            QuarantineNotifyFunc
+}
+
+// QuarantineAppeal is a runner operator's request to be released early
+type QuarantineAppeal struct {
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+// QuarantineRecord is a runner in quarantine
+type QuarantineRecord struct {
+	DeviceID         string            `json:"device_id"`
+	Reason           string            `json:"reason"`
+	Since            time.Time         `json:"since"`
+	ProbationEnds    time.Time         `json:"probation_ends"`
+	CanariesPassed   int               `json:"canaries_passed"`
+	CanariesRequired int               `json:"canaries_required"`
+	Appeal           *QuarantineAppeal `json:"appeal,omitempty"`
+}
+
+// Quarantine tracks canary failures per runner and the runners they quarantined
+type Quarantine struct {
+	config   QuarantineConfig
+	failures map[string][]time.Time
+	records  map[string]*QuarantineRecord
+	mu       sync.Mutex
+}
+
+func newQuarantine(config QuarantineConfig) *Quarantine {
+	if config.Threshold <= 0 {
+		config.Threshold = 3
+	}
+	if config.Window <= 0 {
+		config.Window = 24 * time.Hour
+	}
+	if config.Probation <= 0 {
+		config.Probation = time.Hour
+	}
+	if config.ProbationCanaries <= 0 {
+		config.ProbationCanaries = 3
+	}
+	return &Quarantine{
+		config:   config,
+		failures: make(map[string][]time.Time),
+		records:  make(map[string]*QuarantineRecord),
+	}
+}
+
+// RecordFailure counts a failed canary and reports whether it put the runner in
+// quarantine
+func (q *Quarantine) RecordFailure(deviceID, reason string, at time.Time) bool {
+	q.mu.Lock()
+	if record, ok := q.records[deviceID]; ok {
+		record.CanariesPassed = 0
+		q.mu.Unlock()
+		return false
+	}
+
+	failures := q.failures[deviceID][:0]
+	for _, failure := range q.failures[deviceID] {
+		if at.Sub(failure) < q.config.Window {
+			failures = append(failures, failure)
+		}
+	}
+	failures = append(failures, at)
+	q.failures[deviceID] = failures
+	if len(failures) < q.config.Threshold {
+		q.mu.Unlock()
+		return false
+	}
+
+	delete(q.failures, deviceID)
+	q.records[deviceID] = &QuarantineRecord{
+		DeviceID:         deviceID,
+		Reason:           reason,
+		Since:            at,
+		ProbationEnds:    at.Add(q.config.Probation),
+		CanariesRequired: q.config.ProbationCanaries,
+	}
+	q.mu.Unlock()
+
+	q.notify(deviceID, QuarantineEntered, reason)
+	return true
+}
+
+// RecordPass counts a passed canary and reports whether it released the runner
+func (q *Quarantine) RecordPass(deviceID string, at time.Time) bool {
+	q.mu.Lock()
+	record, ok := q.records[deviceID]
+	if !ok {
+		q.mu.Unlock()
+		return false
+	}
+	record.CanariesPassed++
+	if record.CanariesPassed < record.CanariesRequired || at.Before(record.ProbationEnds) {
+		q.mu.Unlock()
+		return false
+	}
+	delete(q.records, deviceID)
+	q.mu.Unlock()
+
+	q.notify(deviceID, QuarantineReleased, "passed probation")
+	return true
+}
+
+// Release ends a runner's quarantine early and forgets its recent failures
+func (q *Quarantine) Release(deviceID, reason string) bool {
+	q.mu.Lock()
+	_, ok := q.records[deviceID]
+	delete(q.records, deviceID)
+	delete(q.failures, deviceID)
+	q.mu.Unlock()
+
+	if ok {
+		q.notify(deviceID, QuarantineReleased, reason)
+	}
+	return ok
+}
+
+// Appeal records a runner operator's appeal for the server operator to review
+func (q *Quarantine) Appeal(deviceID, message string, at time.Time) bool {
+	q.mu.Lock()
+	record, ok := q.records[deviceID]
+	if ok {
+		record.Appeal = &QuarantineAppeal{Message: message, At: at}
+	}
+	q.mu.Unlock()
+
+	if ok {
+		q.notify(deviceID, QuarantineAppealed, message)
+	}
+	return ok
+}
+
+func (q *Quarantine) IsQuarantined(deviceID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.records[deviceID]
+	return ok
+}
+
+func (q *Quarantine) Get(deviceID string) (QuarantineRecord, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	record, ok := q.records[deviceID]
+	if !ok {
+		return QuarantineRecord{}, false
+	}
+	return *record, true
+}
+
+// List returns the quarantined runners, longest quarantined first
+func (q *Quarantine) List() []QuarantineRecord {
+	q.mu.Lock()
+	records := make([]QuarantineRecord, 0, len(q.records))
+	for _, record := range q.records {
+		records = append(records, *record)
+	}
+	q.mu.Unlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Since.Before(records[j].Since)
+	})
+	return records
+}
+
+func (q *Quarantine) notify(deviceID string, event QuarantineEvent, reason string) {
+	log := gologger.WithComponent("quarantine")
+	log.Warn().
+		Str("device_id", deviceID).
+		Str("event", string(event)).
+		Str("reason", reason).
+		Msg("Runner quarantine changed")
+
+	if q.config.Notify != nil {
+		q.config.Notify(deviceID, event, reason)
+	}
+}
+
+// runnerQuarantine returns the quarantine and canary monitor the controller
+// enforces, or nil when runners are not quarantined
+func (c *RunnerController) runnerQuarantine() (*Quarantine, *CanaryMonitor) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.quarantine, c.canaries
+}
+
+// checkQuarantine keeps quarantined runners from starting anything but canaries
+func (c *RunnerController) checkQuarantine(taskID, deviceID string) (int, string) {
+	quarantine, canaries := c.runnerQuarantine()
+	if quarantine == nil || !quarantine.IsQuarantined(deviceID) || canaries.IsCanary(taskID) {
+		return 0, ""
+	}
+	return http.StatusForbidden, "Runner is quarantined"
+}
+
+// notifyQuarantinedRunner tells a runner with a registered webhook why it was
+// quarantined and how to appeal
+func (c *RunnerController) notifyQuarantinedRunner(record QuarantineRecord) {
+	body, err := json.Marshal(map[string]interface{}{
+		"type":    "quarantine",
+		"payload": record,
+	})
+	if err != nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		req, err := c.NewRunnerWebhookRequest(ctx, record.DeviceID, body)
+		if err != nil {
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log := gologger.WithComponent("quarantine")
+			log.Debug().Err(err).Str("device_id", record.DeviceID).Msg("Failed to notify quarantined runner")
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+func (c *RunnerController) handleListQuarantine(ctx *gin.Context) {
+	quarantine, _ := c.runnerQuarantine()
+	if quarantine == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Runner quarantine is not enabled"})
+		return
+	}
+	ctx.JSON(http.StatusOK, quarantine.List())
+}
+
+func (c *RunnerController) handleGetQuarantine(ctx *gin.Context) {
+	quarantine, _ := c.runnerQuarantine()
+	if quarantine == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Runner quarantine is not enabled"})
+		return
+	}
+	record, ok := quarantine.Get(ctx.Param("deviceID"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Runner is not quarantined"})
+		return
+	}
+	ctx.JSON(http.StatusOK, record)
+}
+
+// handleQuarantineAppeal lets a runner appeal its own quarantine
+func (c *RunnerController) handleQuarantineAppeal(ctx *gin.Context) {
+	deviceID := ctx.Param("deviceID")
+	if ctx.GetHeader("X-Device-ID") != deviceID {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Runners can only appeal their own quarantine"})
+		return
+	}
+
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := ctx.BindJSON(&req); err != nil || req.Message == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "An appeal needs a message"})
+		return
+	}
+
+	quarantine, _ := c.runnerQuarantine()
+	if quarantine == nil || !quarantine.Appeal(deviceID, req.Message, time.Now()) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Runner is not quarantined"})
+		return
+	}
+	record, _ := quarantine.Get(deviceID)
+	ctx.JSON(http.StatusOK, record)
+}
+
+// handleQuarantineRelease lets the server operator release a runner, for
+// example after upholding its appeal. The route requires the operator token.
+func (c *RunnerController) handleQuarantineRelease(ctx *gin.Context) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	_ = ctx.ShouldBindJSON(&req)
+	if req.Reason == "" {
+		req.Reason = "released by operator"
+	}
+
+	quarantine, _ := c.runnerQuarantine()
+	if quarantine == nil || !quarantine.Release(ctx.Param("deviceID"), req.Reason) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Runner is not quarantined"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "released"})
+}
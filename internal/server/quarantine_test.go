@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+func TestQuarantineProbation(t *testing.T) {
+	var events []QuarantineEvent
+	quarantine := newQuarantine(QuarantineConfig{
+		Threshold:         2,
+		Window:            time.Hour,
+		Probation:         time.Hour,
+		ProbationCanaries: 2,
+		Notify: func(deviceID string, event QuarantineEvent, reason string) {
+			events = append(events, event)
+		},
+	})
+	start := time.Now()
+
+	if quarantine.RecordFailure("device-1", "mismatch", start) {
+		t.Fatal("one failure should not quarantine")
+	}
+	if quarantine.RecordFailure("device-1", "mismatch", start.Add(2*time.Hour)) {
+		t.Fatal("failures further apart than the window should not quarantine")
+	}
+	if !quarantine.RecordFailure("device-1", "mismatch", start.Add(150*time.Minute)) {
+		t.Fatal("expected the second failure within the window to quarantine")
+	}
+
+	at := start.Add(3 * time.Hour)
+	quarantine.RecordPass("device-1", at)
+	quarantine.RecordFailure("device-1", "mismatch", at)
+	if record, _ := quarantine.Get("device-1"); record.CanariesPassed != 0 {
+		t.Fatalf("a failure during probation should reset passes, got %d", record.CanariesPassed)
+	}
+
+	if quarantine.RecordPass("device-1", at) || quarantine.RecordPass("device-1", at) {
+		t.Fatal("the runner should not be released before its probation ends")
+	}
+	if !quarantine.RecordPass("device-1", start.Add(5*time.Hour)) || quarantine.IsQuarantined("device-1") {
+		t.Fatal("expected the runner to be released after probation")
+	}
+
+	if len(events) != 2 || events[0] != QuarantineEntered || events[1] != QuarantineReleased {
+		t.Fatalf("events = %v", events)
+	}
+}
+
+func TestQuarantinedRunnerOnlyGetsCanaries(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+	monitor, err := NewCanaryMonitor(controller, CanaryConfig{
+		Quarantine: &QuarantineConfig{Threshold: 1, ProbationCanaries: 1, Probation: time.Nanosecond},
+		Templates: []CanaryTemplate{{
+			Title:          "canary",
+			Type:           models.TaskTypeDocker,
+			Config:         json.RawMessage(`{"command":["echo","ok"]}`),
+			ExpectedOutput: "ok\n",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewCanaryMonitor() = %v", err)
+	}
+
+	canary, err := monitor.Inject()
+	if err != nil {
+		t.Fatalf("failed to inject canary: %v", err)
+	}
+	controller.RemoveAvailableTask(canary.ID.String())
+	if _, err := monitor.AfterResultSaved(context.Background(), &models.TaskResult{TaskID: canary.ID, DeviceID: "device-1", ResultHash: "wrong"}); err != nil {
+		t.Fatalf("unexpected hook error: %v", err)
+	}
+
+	paid := models.NewTask()
+	controller.AddAvailableTask(paid)
+
+	tasks := controller.availableTasksFor("device-1")
+	if len(tasks) != 1 || !monitor.IsCanary(tasks[0].ID.String()) {
+		t.Fatalf("quarantined runner was offered %d tasks, want only the probation canary", len(tasks))
+	}
+	if len(controller.availableTasksFor("device-2")) != 2 {
+		t.Fatal("other runners should still be offered every task")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+paid.ID.String()+"/start", nil)
+	req.Header.Set("X-Device-ID", "device-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("starting paid work in quarantine = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	appeal := []byte(`{"message":"disk was failing, replaced"}`)
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/quarantine/device-1/appeal", bytes.NewReader(appeal))
+	req.Header.Set("X-Device-ID", "device-2")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("appeal for another runner = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/quarantine/device-1/appeal", bytes.NewReader(appeal))
+	req.Header.Set("X-Device-ID", "device-1")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var record QuarantineRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &record); err != nil || record.Appeal == nil {
+		t.Fatalf("appeal = %d %s", rec.Code, rec.Body.String())
+	}
+
+	probation := tasks[0]
+	controller.RemoveAvailableTask(probation.ID.String())
+	answer := "NONCE: " + probation.Nonce + "\nok\n"
+	if _, err := monitor.AfterResultSaved(context.Background(), &models.TaskResult{TaskID: probation.ID, DeviceID: "device-1", Output: answer, ResultHash: utils.ComputeResultHash(answer, "", 0)}); err != nil {
+		t.Fatalf("unexpected hook error: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/quarantine", nil))
+	if rec.Body.String() != "[]" {
+		t.Fatalf("quarantine list after probation = %s, want empty", rec.Body.String())
+	}
+	if len(controller.availableTasksFor("device-1")) != 1 {
+		t.Fatal("a released runner should be offered paid work again")
+	}
+}
+
+func TestQuarantineReleaseNeedsOperatorToken(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+	monitor, err := NewCanaryMonitor(controller, CanaryConfig{
+		Quarantine: &QuarantineConfig{Threshold: 1, ProbationCanaries: 1, Probation: time.Hour},
+		Templates:  []CanaryTemplate{{Title: "canary", Type: models.TaskTypeDocker, Config: json.RawMessage(`{"command":["echo","ok"]}`), ExpectedOutput: "ok\n"}},
+	})
+	if err != nil {
+		t.Fatalf("NewCanaryMonitor() = %v", err)
+	}
+	canary, err := monitor.Inject()
+	if err != nil {
+		t.Fatalf("failed to inject canary: %v", err)
+	}
+	if _, err := monitor.AfterResultSaved(context.Background(), &models.TaskResult{TaskID: canary.ID, DeviceID: "device-1", ResultHash: "wrong"}); err != nil {
+		t.Fatalf("unexpected hook error: %v", err)
+	}
+
+	release := func(header, value string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/quarantine/device-1/release", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := release("Authorization", "Bearer anything"); code != http.StatusForbidden {
+		t.Fatalf("release without a configured token = %d, want %d", code, http.StatusForbidden)
+	}
+
+	controller.SetOperatorToken("operator-secret")
+	for _, attempt := range []struct{ header, value string }{
+		{},
+		{"X-Device-ID", "device-1"},
+		{"Authorization", "Bearer device-1"},
+	} {
+		if code := release(attempt.header, attempt.value); code != http.StatusUnauthorized {
+			t.Fatalf("release with %s %q = %d, want %d", attempt.header, attempt.value, code, http.StatusUnauthorized)
+		}
+	}
+	quarantine, _ := controller.runnerQuarantine()
+	if !quarantine.IsQuarantined("device-1") {
+		t.Fatal("a refused release freed the runner")
+	}
+
+	if code := release("Authorization", "Bearer operator-secret"); code != http.StatusOK {
+		t.Fatalf("operator release = %d, want %d", code, http.StatusOK)
+	}
+	if quarantine.IsQuarantined("device-1") {
+		t.Fatal("the operator release did not free the runner")
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+var (
+	errNotAssigned = errors.New("task is not assigned to this runner")
+	errGangRelease = errors.New("members of a gang task cannot be released")
+)
+
+// RunnerTaskState tells a runner that restarted what became of a task it held
+func (c *RunnerController) RunnerTaskState(taskID, deviceID string) (*models.RunnerTaskState, bool) {
+	task, ok := c.TaskFor(taskID, "")
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	assigned, running := c.assigned[taskID]
+	_, finished := c.results[taskID]
+	c.mu.RUnlock()
+
+	return &models.RunnerTaskState{
+		TaskID:   taskID,
+		Status:   task.Status,
+		Assigned: running && !finished && assigned.deviceID == deviceID,
+	}, true
+}
+
+// ReleaseTask hands a task the runner claimed but will not finish back to the
+// queue, for the runner itself or another one to take
+func (c *RunnerController) ReleaseTask(taskID, deviceID string, now time.Time) error {
+	c.mu.Lock()
+	assigned, ok := c.assigned[taskID]
+	_, finished := c.results[taskID]
+	switch {
+	case !ok || finished || assigned.deviceID != deviceID:
+		c.mu.Unlock()
+		return errNotAssigned
+	case c.gangRuns[taskID] != nil:
+		c.mu.Unlock()
+		return errGangRelease
+	}
+	delete(c.assigned, taskID)
+	c.mu.Unlock()
+
+	c.recordEvent(taskID, models.TaskEvent{
+		Type:     models.TaskEventReleased,
+		Time:     now.UTC(),
+		DeviceID: deviceID,
+		Detail:   "runner restarted before finishing the task",
+	})
+	c.AddAvailableTask(assigned.task)
+
+	log := gologger.WithComponent("runner_controller")
+	log.Info().Str("task_id", taskID).Str("device_id", deviceID).Msg("Runner released a task back to the queue")
+	return nil
+}
+
+func (c *RunnerController) handleRunnerTaskState(ctx *gin.Context) {
+	state, ok := c.RunnerTaskState(ctx.Param("taskID"), ctx.GetHeader("X-Device-ID"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, state)
+}
+
+func (c *RunnerController) handleReleaseTask(ctx *gin.Context) {
+	err := c.ReleaseTask(ctx.Param("taskID"), ctx.GetHeader("X-Device-ID"), time.Now())
+	switch {
+	case errors.Is(err, errNotAssigned):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestRunnerReleasesTaskItHeldBeforeRestart(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	task := models.NewTask()
+	task.Type = models.TaskTypeCommand
+	controller.AddAvailableTask(task)
+	taskID := task.ID.String()
+	if status, message := controller.startTask(context.Background(), taskID, "device-1"); status != 0 {
+		t.Fatalf("startTask() = %d %s", status, message)
+	}
+
+	request := func(method, suffix, deviceID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/runners/tasks/"+taskID+suffix, nil)
+		req.Header.Set("X-Device-ID", deviceID)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	state := func(deviceID string) models.RunnerTaskState {
+		t.Helper()
+		rec := request(http.MethodGet, "", deviceID)
+		var state models.RunnerTaskState
+		if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("task state = %d %s", rec.Code, rec.Body.String())
+		}
+		return state
+	}
+
+	if held := state("device-1"); held.Status != models.TaskStatusRunning || !held.Assigned {
+		t.Fatalf("state for its runner = %+v, want running and assigned", held)
+	}
+	if other := state("device-2"); other.Assigned {
+		t.Fatal("task is reported assigned to another runner")
+	}
+
+	if rec := request(http.MethodPost, "/release", "device-2"); rec.Code != http.StatusNotFound {
+		t.Fatalf("release by another runner = %d, want 404", rec.Code)
+	}
+	if rec := request(http.MethodPost, "/release", "device-1"); rec.Code != http.StatusOK {
+		t.Fatalf("release = %d %s", rec.Code, rec.Body.String())
+	}
+	if released := state("device-1"); released.Status != models.TaskStatusPending || released.Assigned {
+		t.Fatalf("state after release = %+v, want pending and unassigned", released)
+	}
+	if tasks := controller.availableTasksFor("device-2"); len(tasks) != 1 {
+		t.Fatalf("released task is offered %d times, want once", len(tasks))
+	}
+	if rec := request(http.MethodPost, "/release", "device-1"); rec.Code != http.StatusNotFound {
+		t.Fatalf("releasing again = %d, want 404", rec.Code)
+	}
+
+	events := controller.TaskEvents(taskID)
+	released, requeued := events[len(events)-2], events[len(events)-1]
+	if released.Type != models.TaskEventReleased || released.DeviceID != "device-1" || requeued.Type != models.TaskEventQueued {
+		t.Fatalf("last events = %+v %+v, want released by device-1 and queued", released, requeued)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/runners/tasks/"+models.NewTask().ID.String(), nil)
+	req.Header.Set("X-Device-ID", "device-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("state of an unknown task = %d, want 404", rec.Code)
+	}
+}
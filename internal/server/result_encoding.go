@@ -0,0 +1,55 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/compression"
+)
+
+// maxResultBytes bounds how large a result may decompress to, so a small
+// compressed body cannot expand without limit
+const maxResultBytes = 256 << 20
+
+// DecodeResultBody transparently decompresses result submissions sent with a
+// Content-Encoding the server advertised at registration
+func (c *RunnerController) DecodeResultBody(ctx *gin.Context) {
+	encoding := ctx.GetHeader("Content-Encoding")
+	if encoding == "" || encoding == compression.Identity {
+		ctx.Next()
+		return
+	}
+
+	log := gologger.WithComponent("runner_controller")
+	if compression.Negotiate([]string{encoding}) == compression.Identity {
+		log.Warn().Str("encoding", encoding).Msg("Unsupported result encoding")
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported Content-Encoding", "result_encodings": compression.Supported})
+		ctx.Abort()
+		return
+	}
+
+	body, err := compression.Decode(encoding, ctx.Request.Body, maxResultBytes)
+	ctx.Request.Body.Close()
+	switch {
+	case errors.Is(err, compression.ErrTooLarge):
+		log.Warn().Str("task_id", ctx.Param("taskID")).Msg("Decompressed task result is too large")
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Result is too large"})
+		ctx.Abort()
+		return
+	case err != nil:
+		log.Error().Err(err).Str("task_id", ctx.Param("taskID")).Msg("Failed to decompress task result")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid compressed body"})
+		ctx.Abort()
+		return
+	}
+
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	ctx.Request.ContentLength = int64(len(body))
+	ctx.Request.Header.Del("Content-Encoding")
+	ctx.Next()
+}
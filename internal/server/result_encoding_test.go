@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/compression"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestRegistrationAdvertisesResultEncodings(t *testing.T) {
+	router := newTestRouter(NewRunnerController(nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners", strings.NewReader(`{"wallet_address":"0xabc"}`))
+	req.Header.Set("X-Device-ID", "device-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var response struct {
+		ResultEncodings []string `json:"result_encodings"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if compression.Negotiate(response.ResultEncodings) != compression.Zstd {
+		t.Fatalf("advertised %v, want zstd among them", response.ResultEncodings)
+	}
+}
+
+func TestCompressedResultsAreDecoded(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	taskID := uuid.New()
+	output := strings.Repeat("step 10 loss 0.125\n", 1000)
+	body, _ := json.Marshal(models.TaskResult{TaskID: taskID, Output: output})
+
+	for _, tc := range []struct {
+		encoding string
+		want     int
+	}{
+		{"br", http.StatusUnsupportedMediaType},
+		{compression.Gzip, http.StatusOK},
+		{compression.Zstd, http.StatusOK},
+	} {
+		encoded := body
+		if tc.encoding != "br" {
+			encoded, _ = compression.Encode(tc.encoding, body)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+taskID.String()+"/result", bytes.NewReader(encoded))
+		req.Header.Set("X-Device-ID", "device-1")
+		req.Header.Set("Content-Encoding", tc.encoding)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s result = %d %s, want %d", tc.encoding, rec.Code, rec.Body.String(), tc.want)
+		}
+	}
+
+	if stored, ok := controller.GetTaskResult(taskID.String()); !ok || stored.Output != output {
+		t.Fatal("decoded result was not stored")
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// ResultHookOutcome is returned by a result hook; a veto blocks reward distribution
+type ResultHookOutcome struct {
+	Veto   bool   `json:"veto"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ResultHook runs after a task result has been saved and before rewards are distributed.
+// Typical hooks push metrics to a warehouse, run a validator or call an external scorer.
+type ResultHook interface {
+	Name() string
+	AfterResultSaved(ctx context.Context, result *models.TaskResult) (ResultHookOutcome, error)
+}
+
+type ResultHookFunc struct {
+	HookName string
+	Fn       func(ctx context.Context, result *models.TaskResult) (ResultHookOutcome, error)
+}
+
+func (h ResultHookFunc) Name() string {
+	return h.HookName
+}
+
+func (h ResultHookFunc) AfterResultSaved(ctx context.Context, result *models.TaskResult) (ResultHookOutcome, error) {
+	return h.Fn(ctx, result)
+}
+
+// WebhookResultHook posts the saved result to an external endpoint which answers
+// with a ResultHookOutcome. With FailClosed set, an unreachable endpoint vetoes payout.
+type WebhookResultHook struct {
+	name       string
+	url        string
+	failClosed bool
+	client     *http.Client
+}
+
+func NewWebhookResultHook(name, url string, timeout time.Duration, failClosed bool) *WebhookResultHook {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &WebhookResultHook{
+		name:       name,
+		url:        url,
+		failClosed: failClosed,
+		client:     &http.Client{Timeout: timeout},
+	}
+}
+
+func (h *WebhookResultHook) Name() string {
+	return h.name
+}
+
+func (h *WebhookResultHook) AfterResultSaved(ctx context.Context, result *models.TaskResult) (ResultHookOutcome, error) {
+	outcome, err := h.call(ctx, result)
+	if err != nil && h.failClosed {
+		return ResultHookOutcome{Veto: true, Reason: fmt.Sprintf("hook %s unavailable", h.name)}, err
+	}
+	return outcome, err
+}
+
+func (h *WebhookResultHook) call(ctx context.Context, result *models.TaskResult) (ResultHookOutcome, error) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return ResultHookOutcome{}, fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return ResultHookOutcome{}, fmt.Errorf("failed to create hook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return ResultHookOutcome{}, fmt.Errorf("failed to call result hook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return ResultHookOutcome{}, fmt.Errorf("result hook returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var outcome ResultHookOutcome
+	if err := json.NewDecoder(resp.Body).Decode(&outcome); err != nil && err != io.EOF {
+		return ResultHookOutcome{}, fmt.Errorf("failed to decode hook response: %w", err)
+	}
+
+	return outcome, nil
+}
+
+func (c *RunnerController) RegisterResultHook(hook ResultHook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resultHooks = append(c.resultHooks, hook)
+}
+
+// runResultHooks executes every registered hook in order and reports whether payout may proceed
+func (c *RunnerController) runResultHooks(ctx context.Context, result *models.TaskResult) ResultHookOutcome {
+	log := gologger.WithComponent("result_hooks")
+
+	c.mu.RLock()
+	hooks := make([]ResultHook, len(c.resultHooks))
+	copy(hooks, c.resultHooks)
+	c.mu.RUnlock()
+
+	for _, hook := range hooks {
+		outcome, err := hook.AfterResultSaved(ctx, result)
+		if err != nil {
+			log.Error().Err(err).
+				Str("hook", hook.Name()).
+				Str("task_id", result.TaskID.String()).
+				Msg("Result hook failed")
+		}
+
+		if outcome.Veto {
+			log.Warn().
+				Str("hook", hook.Name()).
+				Str("task_id", result.TaskID.String()).
+				Str("reason", outcome.Reason).
+				Msg("Result hook vetoed payout")
+			return outcome
+		}
+	}
+
+	return ResultHookOutcome{}
+}
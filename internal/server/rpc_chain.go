@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	walletsdk "github.com/theblitlabs/go-wallet-sdk"
+	"github.com/theblitlabs/gologger"
+)
+
+// ErrNoPayoutKey is returned by RPCChain transfers: reading the chain needs no
+// key, paying rewards does
+var ErrNoPayoutKey = errors.New("no payout key configured")
+
+// RPCChain reads block heights and stakes from the chain RPC through the wallet
+// SDK. It cannot pay rewards; wrap it in a GasManagedChain with the payout key.
+type RPCChain struct {
+	client *walletsdk.Client
+}
+
+func NewRPCChain(client *walletsdk.Client) *RPCChain {
+	return &RPCChain{client: client}
+}
+
+func (c *RPCChain) BlockNumber(ctx context.Context) (uint64, error) {
+	header, err := c.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return header.Number.Uint64(), nil
+}
+
+// StakeBalance is zero for devices that were never staked. The SDK call takes
+// no context, so a call outliving ctx is abandoned rather than waited for.
+func (c *RPCChain) StakeBalance(ctx context.Context, deviceID string) (*big.Int, error) {
+	type stakeResult struct {
+		info walletsdk.StakeInfo
+		err  error
+	}
+	done := make(chan stakeResult, 1)
+	go func() {
+		info, err := c.client.GetStakeInfo(deviceID)
+		done <- stakeResult{info: info, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-done:
+		if result.err != nil {
+			return nil, result.err
+		}
+		if !result.info.Exists || result.info.Amount == nil {
+			return new(big.Int), nil
+		}
+		return new(big.Int).Set(result.info.Amount), nil
+	}
+}
+
+func (c *RPCChain) TransferReward(ctx context.Context, deviceID string, amount float64) error {
+	return ErrNoPayoutKey
+}
+
+// StakeEvents polls the stake wallet contract every interval and sends an event
+// invalidating every cached stake whenever the contract logged anything since
+// the previous poll. Logs are not decoded, so any stake, unstake or slash drops
+// the whole cache. The channel closes when ctx is done.
+func (c *RPCChain) StakeEvents(ctx context.Context, contract common.Address, interval time.Duration) <-chan StakeEvent {
+	log := gologger.WithComponent("rpc_chain")
+	events := make(chan StakeEvent, 1)
+
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var from uint64
+		started := false
+		for {
+			head, err := c.client.BlockNumber(ctx)
+			switch {
+			case err != nil:
+				log.Debug().Err(err).Msg("Stake contract poll failed")
+			case !started:
+				from, started = head+1, true
+			case head >= from:
+				logs, err := c.client.FilterLogs(ctx, ethereum.FilterQuery{
+					FromBlock: new(big.Int).SetUint64(from),
+					ToBlock:   new(big.Int).SetUint64(head),
+					Addresses: []common.Address{contract},
+				})
+				if err != nil {
+					log.Debug().Err(err).Msg("Stake contract poll failed")
+					break
+				}
+				from = head + 1
+				if len(logs) > 0 {
+					select {
+					case events <- StakeEvent{}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return events
+}
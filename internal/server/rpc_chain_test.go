@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// fakeNode answers the JSON-RPC calls the stake listener makes. Every
+// eth_blockNumber mines a block; eth_getLogs returns one log from the stake
+// contract once emit is set.
+type fakeNode struct {
+	mu       sync.Mutex
+	head     uint64
+	emit     bool
+	contract common.Address
+	queried  []string
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	switch req.Method {
+	case "eth_blockNumber":
+		n.head++
+		response["result"] = "0x" + strconv.FormatUint(n.head, 16)
+	case "eth_getLogs":
+		n.queried = append(n.queried, string(req.Params[0]))
+		logs := []map[string]interface{}{}
+		if n.emit {
+			n.emit = false
+			logs = append(logs, map[string]interface{}{
+				"address":          n.contract.Hex(),
+				"topics":           []string{common.Hash{1}.Hex()},
+				"data":             "0x",
+				"blockNumber":      "0x" + strconv.FormatUint(n.head, 16),
+				"blockHash":        common.Hash{2}.Hex(),
+				"transactionHash":  common.Hash{3}.Hex(),
+				"transactionIndex": "0x0",
+				"logIndex":         "0x0",
+				"removed":          false,
+			})
+		}
+		response["result"] = logs
+	default:
+		response["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (n *fakeNode) setEmit() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.emit = true
+}
+
+func TestRPCChainStakeEventsFollowTheStakeContract(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	node := &fakeNode{contract: contract}
+	rpc := httptest.NewServer(node)
+	defer rpc.Close()
+
+	client, err := utils.NewReadOnlyClient(&config.Config{Blockchain: config.BlockchainConfig{RPC: rpc.URL, ChainID: 1337}})
+	if err != nil {
+		t.Fatalf("NewReadOnlyClient() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := NewRPCChain(client).StakeEvents(ctx, contract, 10*time.Millisecond)
+
+	select {
+	case event := <-events:
+		t.Fatalf("got %+v before the contract logged anything", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	node.setEmit()
+	select {
+	case event := <-events:
+		if event.DeviceID != "" || event.Balance != nil {
+			t.Fatalf("event = %+v, want one that invalidates every stake", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no stake event after the contract logged")
+	}
+
+	node.mu.Lock()
+	query := node.queried[len(node.queried)-1]
+	node.mu.Unlock()
+	if !strings.Contains(strings.ToLower(query), strings.ToLower(contract.Hex())) {
+		t.Fatalf("eth_getLogs query %s does not filter on the stake contract", query)
+	}
+
+	cancel()
+	for range events {
+	}
+}
@@ -1,24 +1,117 @@
 package server
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/theblitlabs/gologger"
 
+	"github.com/theblitlabs/parity-runner/internal/compression"
+	"github.com/theblitlabs/parity-runner/internal/core/config"
 	"github.com/theblitlabs/parity-runner/internal/core/models"
 	"github.com/theblitlabs/parity-runner/internal/core/services"
+	"github.com/theblitlabs/parity-runner/internal/identity"
+	"github.com/theblitlabs/parity-runner/internal/receipt"
 )
 
 type RunnerController struct {
-	runnerService  services.RunnerService
-	availableTasks []*models.Task
+	runnerService    services.RunnerService
+	availableTasks   []*models.Task
+	runnerSelectors  map[string]models.LabelSelector
+	runnerGPUs       map[string][]models.GPUInfo
+	runnerWebhooks   map[string]RunnerWebhook
+	manifests        map[string]*models.RunnerManifest
+	results          map[string]*models.TaskResult
+	resultHooks      []ResultHook
+	receiptSigner    *ecdsa.PrivateKey
+	serverIdentity   *identity.Signer
+	lastHeartbeat    map[string]time.Time
+	stats            *TimeSeriesStore
+	pricing          PricingConfig
+	contentStore     ContentStore
+	maxInlineBytes   int
+	assigned         map[string]assignment
+	chain            *ChainGateway
+	settlementChains map[int64]*ChainGateway
+	runnerChains     map[string]int64
+	minStake         *big.Int
+	stakePolicy      *StakePolicy
+	faucet           *Faucet
+	slo              *sloTracker
+	experiments      map[string]*experimentRecord
+	earnings         map[string]*RunnerEarnings
+	timeouts         TimeoutPolicy
+	expired          map[string]bool
+	privacy          ResultPrivacy
+	fleet            fleetState
+	quarantine       *Quarantine
+	canaries         *CanaryMonitor
+	preflights       map[string]*PreflightStatus
+	brokers          map[string]*models.CredentialBroker
+	credentialClient *http.Client
+	events           map[string][]models.TaskEvent
+	logs             map[string]*taskLogs
+	gangs            map[string]*gangRecord
+	gangRuns         map[string]*gangRun
+	arrays           map[string]*arrayRecord
+	arrayAttempts    map[string]arrayAttempt
+	capabilities     map[string]*models.CapabilityProfile
+	claimLease       time.Duration
+	revoked          map[string]map[string]bool
+	benchmarks       map[string]*models.BenchmarkReport
+	benchmarkRewards bool
+	biddingWindow    time.Duration
+	auctions         map[string]*auction
+	wallets          map[string]string
+	paidRewards      []models.PaidReward
+	priceOracle      PriceOracle
+	fiatCurrency     string
+	provenancePolicy *provenancePolicy
+	builds           map[string]*models.BuildProvenance
+	// attestationVerifier checks TEE quotes, nil when none is configured
+	attestationVerifier AttestationVerifier
+	// operatorToken authorizes operator routes, empty when they are disabled
+	operatorToken string
+	mu            sync.RWMutex
+}
+
+// assignment is a task a runner has started and not yet reported a result for
+type assignment struct {
+	task      *models.Task
+	deviceID  string
+	startedAt time.Time
+	// progressAt is when the runner last reported it is busy
+	progressAt time.Time
 }
 
 func NewRunnerController(runnerService services.RunnerService) *RunnerController {
 	return &RunnerController{
-		runnerService:  runnerService,
-		availableTasks: make([]*models.Task, 0),
+		runnerService:   runnerService,
+		availableTasks:  make([]*models.Task, 0),
+		runnerSelectors: make(map[string]models.LabelSelector),
+		runnerGPUs:      make(map[string][]models.GPUInfo),
+		runnerWebhooks:  make(map[string]RunnerWebhook),
+		manifests:       make(map[string]*models.RunnerManifest),
+		capabilities:    make(map[string]*models.CapabilityProfile),
+		assigned:        make(map[string]assignment),
+		experiments:     make(map[string]*experimentRecord),
+		earnings:        make(map[string]*RunnerEarnings),
+		expired:         make(map[string]bool),
+		results:         make(map[string]*models.TaskResult),
+		lastHeartbeat:   make(map[string]time.Time),
+		stats:           NewTimeSeriesStore(time.Minute, 7*24*time.Hour),
+		pricing:         DefaultPricingConfig(),
+		slo:             newSLOTracker(SLOsFromConfig(config.SLOConfig{})),
+		fleet:           fleetState{reported: make(map[string]reportedSettings)},
 	}
 }
 
@@ -36,20 +129,82 @@ func (c *RunnerController) RequireDeviceID(ctx *gin.Context) {
 	ctx.Next()
 }
 
+// APIPrefix is where the REST API is served, matching the paths runners and
+// the SDK call
+const APIPrefix = "/api/v1"
+
 func (c *RunnerController) RegisterRoutes(router *gin.Engine) {
-	api := router.Group("/api")
+	router.GET("/metrics", c.handlePrometheusMetrics)
+
+	api := router.Group(APIPrefix)
 	{
-		runners := api.Group("/runners")
+		api.POST("/tasks", c.handleCreateTask)
+		api.POST("/tasks/estimate", c.handleEstimate)
+		api.GET("/tasks/timeouts", c.handleTimeoutPolicy)
+		api.GET("/tasks/:taskID", c.handleGetTask)
+		api.POST("/tasks/:taskID/assignment/revoke", c.handleRevokeAssignment)
+		api.POST("/tasks/:taskID/cancel", c.handleCancelTask)
+		api.GET("/tasks/:taskID/result", c.handleGetTaskResult)
+		api.GET("/tasks/:taskID/receipt", c.handleGetReceipt)
+		api.GET("/tasks/:taskID/metrics", c.handleGetTaskMetrics)
+		api.GET("/tasks/:taskID/preflight", c.handleGetPreflight)
+		api.GET("/tasks/:taskID/events", c.handleGetTaskEvents)
+		api.GET("/tasks/:taskID/logs/stream", c.handleStreamTaskLogs)
+		api.GET("/gangs/:gangID", c.handleGetGang)
+		api.GET("/arrays/:arrayID", c.handleGetArray)
+		api.POST("/arrays/:arrayID/retry", c.handleRetryArray)
+		api.GET("/metrics/runners/:deviceID", c.handleGetRunnerMetrics)
+		api.GET("/metrics/creators/:address", c.handleGetCreatorMetrics)
+		api.GET("/earnings/:deviceID", c.handleRunnerEarnings)
+		api.GET("/wallets/:address/earnings", c.handleEarningsReport)
+		api.GET("/manifests/:deviceID", c.handleGetManifest)
+		api.POST("/experiments", c.handleCreateExperiment)
+		api.GET("/experiments/:experimentID", c.handleGetExperiment)
+		api.POST("/experiments/:experimentID/tasks", c.handleAddExperimentTasks)
+		api.POST("/experiments/:experimentID/cancel", c.handleCancelExperiment)
+		api.GET("/stats/overview", c.handleStatsOverview)
+		api.GET("/chain/status", c.handleChainStatus)
+		api.GET("/chain/payouts/reconciliation", c.handlePayoutReconciliation)
+		api.GET("/slo", c.handleSLOStatus)
+		api.GET("/slo/rules", c.handleSLORules)
+		api.POST("/faucet", c.handleFaucet)
+		api.GET("/fleet", c.handleFleetStatus)
+		api.POST("/fleet/apply", c.RequireOperator, c.handleFleetApply)
+		api.GET("/quarantine", c.handleListQuarantine)
+		api.GET("/quarantine/:deviceID", c.handleGetQuarantine)
+		api.POST("/quarantine/:deviceID/appeal", c.handleQuarantineAppeal)
+		api.POST("/quarantine/:deviceID/release", c.RequireOperator, c.handleQuarantineRelease)
+		api.POST("/credentials/brokers", c.handleRegisterBroker)
+		api.GET("/credentials/brokers", c.handleListBrokers)
+		api.DELETE("/credentials/brokers/:name", c.handleRemoveBroker)
+		api.GET("/identity", c.SignResponses, c.handleIdentity)
+		api.GET("/runners", c.handleListRunners)
+		// Bandwidth samples go unsigned, since signing buffers the whole body
+		api.GET("/runners/bandwidth", c.handleBandwidth)
+		api.POST("/llm/prompts/:promptID/complete", c.RequireDeviceID, c.handleCompletePrompt)
+		api.POST("/llm/prompts/:promptID/fail", c.RequireDeviceID, c.handleFailPrompt)
+
+		runners := api.Group("/runners", c.SignResponses)
 		{
 			runners.POST("", c.handleRunnerRegistration)
 			runners.POST("/heartbeat", c.handleHeartbeat)
+			runners.POST("/benchmarks", c.RequireDeviceID, c.handleSubmitBenchmark)
+			runners.DELETE("/webhooks", c.RequireDeviceID, c.handleUnregisterWebhook)
 
 			tasks := runners.Group("/tasks")
 			{
 				tasks.GET("/available", c.handleAvailableTasks)
+				tasks.GET("/:taskID", c.RequireDeviceID, c.handleRunnerTaskState)
+				tasks.POST("/:taskID/release", c.RequireDeviceID, c.handleReleaseTask)
 				tasks.POST("/:taskID/start", c.RequireDeviceID, c.handleTaskStart)
+				tasks.POST("/:taskID/bids", c.RequireDeviceID, c.handleSubmitBid)
+				tasks.GET("/:taskID/bids", c.RequireDeviceID, c.handleGetBid)
 				tasks.POST("/:taskID/complete", c.handleTaskComplete)
-				tasks.POST("/:taskID/result", c.RequireDeviceID, c.handleTaskResult)
+				tasks.POST("/:taskID/result", c.RequireDeviceID, c.DecodeResultBody, c.handleTaskResult)
+				tasks.GET("/:taskID/credentials", c.RequireDeviceID, c.handleTaskCredentials)
+				tasks.POST("/:taskID/logs", c.RequireDeviceID, c.handlePushTaskLogs)
+				tasks.GET("/:taskID/gang", c.RequireDeviceID, c.handleGangMemberStatus)
+				tasks.POST("/:taskID/gang", c.RequireDeviceID, c.handleGangJoin)
 			}
 		}
 	}
@@ -59,9 +214,17 @@ func (c *RunnerController) handleRunnerRegistration(ctx *gin.Context) {
 	log := gologger.WithComponent("runner_controller")
 
 	var req struct {
-		WalletAddress string              `json:"wallet_address"`
-		Status        models.RunnerStatus `json:"status"`
-		Webhook       string              `json:"webhook"`
+		WalletAddress string                    `json:"wallet_address"`
+		Status        models.RunnerStatus       `json:"status"`
+		Webhook       string                    `json:"webhook"`
+		WebhookToken  string                    `json:"webhook_token"`
+		AcceptLabels  string                    `json:"accept_labels"`
+		Manifest      *models.RunnerManifest    `json:"manifest"`
+		Capabilities  *models.CapabilityProfile `json:"capabilities"`
+		Benchmark     *models.BenchmarkReport   `json:"benchmark"`
+		// SettlementChainID is the chain the runner wants to be paid on
+		SettlementChainID int64                   `json:"settlement_chain_id"`
+		Build             *models.BuildProvenance `json:"build"`
 	}
 
 	if err := ctx.BindJSON(&req); err != nil {
@@ -77,7 +240,53 @@ func (c *RunnerController) handleRunnerRegistration(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"status": "registered"})
+	selector, err := models.ParseLabelSelector(req.AcceptLabels)
+	if err != nil {
+		log.Error().Err(err).Str("device_id", deviceID).Msg("Invalid label selector in runner registration")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid accept_labels selector"})
+		return
+	}
+
+	if err := c.recordBuild(deviceID, req.Build); err != nil {
+		log.Warn().Err(err).Str("device_id", deviceID).Msg("Refusing runner build")
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Runner build not accepted: " + err.Error()})
+		return
+	}
+
+	if req.Manifest != nil {
+		if err := c.recordManifest(deviceID, req.WalletAddress, req.Manifest); err != nil {
+			log.Error().Err(err).Str("device_id", deviceID).Msg("Invalid manifest in runner registration")
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid manifest: " + err.Error()})
+			return
+		}
+	}
+
+	if req.Benchmark != nil {
+		if err := c.recordBenchmark(deviceID, req.WalletAddress, req.Benchmark); err != nil {
+			log.Warn().Err(err).Str("device_id", deviceID).Msg("Ignoring invalid benchmark report in runner registration")
+		}
+	}
+
+	c.recordCapabilities(deviceID, req.Capabilities)
+	c.recordWallet(deviceID, req.WalletAddress)
+	settlementChainID := c.recordSettlementChain(deviceID, req.SettlementChainID)
+	if settlementChainID != req.SettlementChainID {
+		log.Warn().Str("device_id", deviceID).Int64("chain_id", req.SettlementChainID).Msg("Runner asked for a settlement chain this server does not pay on, using the default chain")
+	}
+	c.registerRunner(deviceID, selector, RunnerWebhook{URL: req.Webhook, Token: req.WebhookToken})
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":              "registered",
+		"result_encodings":    compression.Supported,
+		"settlement_chain_id": settlementChainID,
+	})
+}
+
+func (c *RunnerController) registerRunner(deviceID string, selector models.LabelSelector, webhook RunnerWebhook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.runnerSelectors[deviceID] = selector
+	c.runnerWebhooks[deviceID] = webhook
 }
 
 func (c *RunnerController) handleHeartbeat(ctx *gin.Context) {
@@ -95,19 +304,77 @@ func (c *RunnerController) handleHeartbeat(ctx *gin.Context) {
 		Payload gin.H  `json:"payload"`
 	}
 
-	if err := ctx.BindJSON(&msg); err != nil {
+	var body gin.H
+	if err := ctx.BindJSON(&body); err != nil {
 		log.Error().Err(err).Msg("Failed to parse heartbeat message")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
+	// Runners post the heartbeat itself; the offline notice comes wrapped in a
+	// typed message
+	if messageType, wrapped := body["type"]; wrapped {
+		msg.Type, _ = messageType.(string)
+		msg.Payload, _ = body["payload"].(map[string]interface{})
+	} else {
+		msg.Type, msg.Payload = "heartbeat", body
+	}
+
 	if msg.Type != "heartbeat" {
 		log.Error().Str("type", msg.Type).Msg("Invalid message type")
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message type"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	var gpus []models.GPUInfo
+	if raw, ok := msg.Payload["gpus"]; ok {
+		data, err := json.Marshal(raw)
+		if err == nil {
+			err = json.Unmarshal(data, &gpus)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("device_id", deviceID).Msg("Invalid GPU inventory in heartbeat")
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid gpus"})
+			return
+		}
+	}
+
+	var settings *models.RunnerSettings
+	if raw, ok := msg.Payload["settings"]; ok {
+		data, err := json.Marshal(raw)
+		if err == nil {
+			err = json.Unmarshal(data, &settings)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("device_id", deviceID).Msg("Invalid settings in heartbeat")
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings"})
+			return
+		}
+	}
+
+	manifest, err := manifestFromPayload(msg.Payload)
+	if err == nil && manifest != nil {
+		walletAddress, _ := msg.Payload["wallet_address"].(string)
+		err = c.recordManifest(deviceID, walletAddress, manifest)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("device_id", deviceID).Msg("Invalid manifest in heartbeat")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid manifest: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	c.recordHeartbeat(deviceID, msg.Payload, now)
+	c.recordGPUs(deviceID, gpus)
+	if status, _ := msg.Payload["status"].(string); status == string(models.RunnerStatusBusy) {
+		c.recordProgress(deviceID, now)
+	}
+
+	response := gin.H{"status": "ok"}
+	if directive := c.recordSettings(deviceID, settings, now); directive != nil {
+		response["directive"] = directive
+	}
+	ctx.JSON(http.StatusOK, response)
 }
 
 func (c *RunnerController) handleAvailableTasks(ctx *gin.Context) {
@@ -120,21 +387,94 @@ func (c *RunnerController) handleAvailableTasks(ctx *gin.Context) {
 		return
 	}
 
-	// Return available tasks from local storage
-	ctx.JSON(http.StatusOK, c.availableTasks)
+	ctx.JSON(http.StatusOK, c.availableTasksFor(deviceID))
+}
+
+// availableTasksFor only offers tasks whose labels satisfy the runner's selector
+// and whose GPU requirements the runner's last reported GPUs meet. Runners that
+// sent a manifest are only offered tasks of the types they run, paying at least
+// their minimum reward. Quarantined runners are only offered canaries, and no
+// runner is offered two members of one gang.
+func (c *RunnerController) availableTasksFor(deviceID string) []*models.Task {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	selector := c.runnerSelectors[deviceID]
+	gpus := c.runnerGPUs[deviceID]
+	manifest := c.manifests[deviceID]
+	profile := c.capabilities[deviceID]
+	if manifest != nil && len(manifest.Hardware.GPUs) > 0 {
+		gpus = manifest.Hardware.GPUs
+	}
+	quarantined := c.quarantine != nil && c.quarantine.IsQuarantined(deviceID)
+	tasks := make([]*models.Task, 0, len(c.availableTasks))
+	for _, task := range c.availableTasks {
+		if quarantined && !c.canaries.IsCanary(task.ID.String()) {
+			continue
+		}
+		if !selector.Matches(task.Labels) {
+			continue
+		}
+		if task.GPU != nil && !task.GPU.SatisfiedBy(gpus) {
+			continue
+		}
+		if !manifestAllows(manifest, task) {
+			continue
+		}
+		if !capabilitiesAllow(profile, task) {
+			continue
+		}
+		if run, ok := c.gangRuns[task.ID.String()]; ok && run.hasDevice(deviceID) {
+			continue
+		}
+		if c.revoked[task.ID.String()][deviceID] {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// recordGPUs keeps the GPU inventory from a runner's heartbeat. A heartbeat
+// without one clears it, since the runner no longer offers GPUs.
+func (c *RunnerController) recordGPUs(deviceID string, gpus []models.GPUInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(gpus) == 0 {
+		delete(c.runnerGPUs, deviceID)
+		return
+	}
+	c.runnerGPUs[deviceID] = gpus
 }
 
 func (c *RunnerController) AddAvailableTask(task *models.Task) {
+	c.mu.Lock()
 	c.availableTasks = append(c.availableTasks, task)
+	depth := len(c.availableTasks)
+	c.mu.Unlock()
+
+	c.recordEvent(task.ID.String(), models.TaskEvent{Type: models.TaskEventQueued})
+	c.recordQueueDepth(depth)
 }
 
-func (c *RunnerController) RemoveAvailableTask(taskID string) {
+// RemoveAvailableTask takes a task out of the pool and returns it, or nil if it was not queued
+func (c *RunnerController) RemoveAvailableTask(taskID string) *models.Task {
+	c.mu.Lock()
+	var removed *models.Task
 	for i, task := range c.availableTasks {
 		if task.ID.String() == taskID {
+			removed = task
 			c.availableTasks = append(c.availableTasks[:i], c.availableTasks[i+1:]...)
 			break
 		}
 	}
+	depth := len(c.availableTasks)
+	c.mu.Unlock()
+
+	if removed != nil {
+		c.recordQueueDepth(depth)
+	}
+	return removed
 }
 
 func (c *RunnerController) handleTaskStart(ctx *gin.Context) {
@@ -143,12 +483,58 @@ func (c *RunnerController) handleTaskStart(ctx *gin.Context) {
 	taskID := ctx.Param("taskID")
 	log.Debug().Str("task_id", taskID).Msg("Start task request received")
 
-	// Remove task from available tasks when started
-	c.RemoveAvailableTask(taskID)
+	deviceID := ctx.GetHeader("X-Device-ID")
+	if status, message := c.startTask(ctx.Request.Context(), taskID, deviceID); status != 0 {
+		log.Warn().Str("task_id", taskID).Str("device_id", deviceID).Msg(message)
+		ctx.JSON(status, gin.H{"error": message})
+		return
+	}
 
 	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// startTask assigns the task to the runner. Like checkRunnerStake it returns the
+// HTTP status to refuse the start with, or 0.
+func (c *RunnerController) startTask(ctx context.Context, taskID, deviceID string) (int, string) {
+	if status, message := c.checkRunnerStake(ctx, taskID, deviceID); status != 0 {
+		return status, message
+	}
+	if status, message := c.checkRunnerBuild(deviceID); status != 0 {
+		return status, message
+	}
+	if status, message := c.checkQuarantine(taskID, deviceID); status != 0 {
+		return status, message
+	}
+	if status, message := c.checkGangStart(taskID, deviceID); status != 0 {
+		return status, message
+	}
+	if c.wasRevokedFrom(taskID, deviceID) {
+		return http.StatusConflict, "Task assignment was revoked from this runner"
+	}
+	if status, message := c.checkAward(taskID, deviceID, time.Now()); status != 0 {
+		return status, message
+	}
+
+	// Remove task from available tasks when started
+	if task := c.RemoveAvailableTask(taskID); task != nil {
+		now := time.Now()
+		c.recordAssignment(task, now)
+		c.mu.Lock()
+		// The collateral the runner had to hold stays on the task for its creator
+		if gateway, _ := c.gatewayFor(deviceID); gateway != nil {
+			if required := c.requiredStakeLocked(task, deviceID); required != nil {
+				task.RequiredStake = required.String()
+			}
+		}
+		c.assigned[taskID] = assignment{task: task, deviceID: deviceID, startedAt: now, progressAt: now}
+		c.mu.Unlock()
+		c.recordEvent(taskID, models.TaskEvent{Type: models.TaskEventStarted, Time: now.UTC(), DeviceID: deviceID})
+		c.joinGang(taskID, deviceID, now)
+		c.startArrayIndex(taskID, deviceID)
+	}
+	return 0, ""
+}
+
 func (c *RunnerController) handleTaskComplete(ctx *gin.Context) {
 	log := gologger.WithComponent("runner_controller")
 
@@ -171,5 +557,276 @@ func (c *RunnerController) handleTaskResult(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	if result.Uploads != nil {
+		if err := result.Uploads.Validate(); err != nil {
+			log.Error().Err(err).Str("task_id", taskID).Msg("Invalid result uploads")
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if parsedID, err := uuid.Parse(taskID); err == nil {
+		// A result is stored, hooked and paid under its own task ID, so it must be
+		// the task the runner posted it to
+		if result.TaskID != uuid.Nil && result.TaskID != parsedID {
+			log.Warn().Str("task_id", taskID).Str("result_task_id", result.TaskID.String()).Msg("Rejected result for another task")
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Result is for another task"})
+			return
+		}
+		result.TaskID = parsedID
+	}
+	if err := bindResultDevice(&result, ctx.GetHeader("X-Device-ID")); err != nil {
+		log.Warn().Str("task_id", taskID).Str("device_id", ctx.GetHeader("X-Device-ID")).Str("result_device_id", result.DeviceID).Msg("Rejected result naming another device")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	outcome, err := c.submitTaskResult(ctx.Request.Context(), &result)
+	writeResultOutcome(ctx, &result, outcome, err)
+}
+
+// writeResultOutcome answers a result submission with what submitTaskResult
+// decided about it
+func writeResultOutcome(ctx *gin.Context, result *models.TaskResult, outcome resultOutcome, err error) {
+	log := gologger.WithComponent("runner_controller")
+	taskID := result.TaskID.String()
+
+	switch {
+	case errors.Is(err, errGangRetired):
+		log.Warn().Str("task_id", taskID).Msg("Rejected result for a member of a failed gang attempt")
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Task's gang failed and was rescheduled"})
+		return
+	case errors.Is(err, errTaskExpired):
+		log.Warn().Str("task_id", taskID).Msg("Rejected result for a task that exceeded its maximum duration")
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Task exceeded its maximum duration"})
+		return
+	case errors.Is(err, errAssignmentRevoked):
+		log.Warn().Str("task_id", taskID).Str("device_id", result.DeviceID).Msg("Rejected result from a runner whose assignment was revoked")
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Task assignment was revoked"})
+		return
+	case errors.Is(err, errNotAssignee):
+		log.Warn().Str("task_id", taskID).Str("device_id", result.DeviceID).Msg("Rejected result from a runner the task is not assigned to")
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Task is assigned to another runner"})
+		return
+	case err != nil:
+		log.Error().Err(err).Str("task_id", taskID).Msg("Failed to protect task result")
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Result could not be stored, retry later"})
+		return
+	}
+
+	if !outcome.PayoutApproved {
+		ctx.JSON(http.StatusOK, gin.H{
+			"status":          "ok",
+			"payout_approved": false,
+			"veto_reason":     outcome.VetoReason,
+		})
+		return
+	}
+
+	response := gin.H{"status": "ok", "payout_approved": true}
+	if outcome.PayoutStatus != "" {
+		response["payout_status"] = outcome.PayoutStatus
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+var (
+	errTaskExpired          = errors.New("task exceeded its maximum duration")
+	errResultDeviceMismatch = errors.New("result device_id does not match the submitting device")
+	errNotAssignee          = errors.New("task is assigned to another runner")
+)
+
+// bindResultDevice attributes a result to the device that submitted it, so
+// checks against the runner cannot be dodged by naming another device in the
+// body. A result naming another device is refused.
+func bindResultDevice(result *models.TaskResult, deviceID string) error {
+	if result.DeviceID != "" && result.DeviceID != deviceID {
+		return errResultDeviceMismatch
+	}
+	result.DeviceID = deviceID
+	return nil
+}
+
+// resultOutcome is what the server decided about a submitted result
+type resultOutcome struct {
+	PayoutApproved bool
+	VetoReason     string
+	PayoutStatus   string
+}
+
+// submitTaskResult stores a result, runs the result hooks and pays the runner
+// when they approve
+func (c *RunnerController) submitTaskResult(ctx context.Context, result *models.TaskResult) (resultOutcome, error) {
+	if c.isRetiredGangMember(result.TaskID.String()) {
+		return resultOutcome{}, errGangRetired
+	}
+	if c.isExpired(result.TaskID.String()) {
+		return resultOutcome{}, errTaskExpired
+	}
+	if c.wasRevokedFrom(result.TaskID.String(), result.DeviceID) {
+		return resultOutcome{}, errAssignmentRevoked
+	}
+	if c.assignedElsewhere(result.TaskID.String(), result.DeviceID) {
+		return resultOutcome{}, errNotAssignee
+	}
+	c.attributeCreator(result)
+
+	if result.Receipt != nil {
+		c.countersignReceipt(result)
+	}
+	attested := c.checkAttestation(ctx, result)
+
+	// Personal data is scrubbed before anything is stored or handed to hooks
+	stored, err := c.protectResult(ctx, result)
+	if err != nil {
+		return resultOutcome{}, err
+	}
+
+	c.SaveTaskResult(stored)
+	c.recordResult(result, time.Now())
+	c.recordEvent(result.TaskID.String(), models.TaskEvent{Type: models.TaskEventResultSubmitted, DeviceID: result.DeviceID})
+	c.finishTaskLogs(result.TaskID.String())
+	c.resolvePreflight(result)
+	c.resolveGangMember(result)
+	c.resolveArrayIndex(result)
+
+	if result.Status == models.TaskStatusCancelled {
+		return resultOutcome{VetoReason: "task was cancelled by its creator"}, nil
+	}
+	if !attested {
+		return resultOutcome{VetoReason: "task required a TEE attestation the result does not carry or that failed verification"}, nil
+	}
+
+	// Hooks run after the result is stored and before any reward is distributed
+	hooks := c.runResultHooks(ctx, result)
+	if hooks.Veto {
+		return resultOutcome{VetoReason: hooks.Reason}, nil
+	}
+
+	return resultOutcome{
+		PayoutApproved: true,
+		PayoutStatus:   c.distributeReward(ctx, result.TaskID.String()),
+	}, nil
+}
+
+// assignedElsewhere reports whether the task is assigned to another device or,
+// once its assignment is settled, holds another device's result. Only the
+// assignee may store a result and be paid for it.
+func (c *RunnerController) assignedElsewhere(taskID, deviceID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if assigned, ok := c.assigned[taskID]; ok {
+		return assigned.deviceID != deviceID
+	}
+	if stored, ok := c.results[taskID]; ok {
+		return stored.DeviceID != deviceID
+	}
+	return false
+}
+
+// attributeCreator credits a result to the creator of the task it answers, so
+// usage is reported per creator and a runner cannot bill it to another one
+func (c *RunnerController) attributeCreator(result *models.TaskResult) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result.CreatorAddress = ""
+	if assigned, ok := c.assigned[result.TaskID.String()]; ok {
+		result.CreatorAddress = assigned.task.CreatorAddress
+	}
+}
+
+func (c *RunnerController) SaveTaskResult(result *models.TaskResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results[result.TaskID.String()] = result
+}
+
+func (c *RunnerController) GetTaskResult(taskID string) (*models.TaskResult, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result, ok := c.results[taskID]
+	return result, ok
+}
+
+// SetReceiptSigner configures the key used to countersign runner execution receipts
+func (c *RunnerController) SetReceiptSigner(key *ecdsa.PrivateKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.receiptSigner = key
+}
+
+func (c *RunnerController) countersignReceipt(result *models.TaskResult) {
+	log := gologger.WithComponent("runner_controller")
+
+	if err := receipt.Verify(result.Receipt); err != nil {
+		log.Warn().Err(err).Str("task_id", result.TaskID.String()).Msg("Discarding invalid execution receipt")
+		result.Receipt = nil
+		return
+	}
+	if err := c.checkReceipt(result); err != nil {
+		log.Warn().Err(err).Str("task_id", result.TaskID.String()).Str("device_id", result.DeviceID).Msg("Discarding execution receipt that does not match the task")
+		result.Receipt = nil
+		return
+	}
+
+	c.mu.RLock()
+	key := c.receiptSigner
+	c.mu.RUnlock()
+
+	if key == nil {
+		return
+	}
+
+	if err := receipt.Countersign(result.Receipt, key); err != nil {
+		log.Error().Err(err).Str("task_id", result.TaskID.String()).Msg("Failed to countersign execution receipt")
+	}
+}
+
+var errReceiptMismatch = errors.New("receipt does not match the task and result")
+
+// checkReceipt makes sure a receipt describes the task the submitting device
+// was assigned and the result it submitted, so the server never countersigns
+// a receipt for work it did not see
+func (c *RunnerController) checkReceipt(result *models.TaskResult) error {
+	executionReceipt := result.Receipt
+	if executionReceipt.TaskID != result.TaskID {
+		return fmt.Errorf("%w: task id", errReceiptMismatch)
+	}
+	if executionReceipt.DeviceID != result.DeviceID {
+		return fmt.Errorf("%w: device id", errReceiptMismatch)
+	}
+
+	c.mu.RLock()
+	assigned, ok := c.assigned[result.TaskID.String()]
+	c.mu.RUnlock()
+	if !ok || assigned.deviceID != result.DeviceID {
+		return fmt.Errorf("%w: task is not assigned to the device", errReceiptMismatch)
+	}
+	if executionReceipt.TaskType != assigned.task.Type || executionReceipt.InputHash != receipt.InputHash(assigned.task) {
+		return fmt.Errorf("%w: input", errReceiptMismatch)
+	}
+
+	outputHash := receipt.OutputHash(result.Output)
+	if upload, uploaded := result.Uploads.Output(); uploaded {
+		outputHash = upload.SHA256
+	}
+	if executionReceipt.OutputHash != outputHash {
+		return fmt.Errorf("%w: output hash", errReceiptMismatch)
+	}
+	if executionReceipt.ExitCode != result.ExitCode {
+		return fmt.Errorf("%w: exit code", errReceiptMismatch)
+	}
+	return nil
+}
+
+func (c *RunnerController) handleGetReceipt(ctx *gin.Context) {
+	taskID := ctx.Param("taskID")
+
+	result, ok := c.GetTaskResult(taskID)
+	if !ok || result.Receipt == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result.Receipt)
 }
@@ -0,0 +1,755 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/receipt"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+func newTestRouter(controller *RunnerController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	controller.RegisterRoutes(router)
+	return router
+}
+
+func postResult(t *testing.T, router *gin.Engine, taskID uuid.UUID) map[string]interface{} {
+	t.Helper()
+
+	body, err := json.Marshal(models.TaskResult{TaskID: taskID, Output: "done"})
+	if err != nil {
+		t.Fatalf("failed to marshal result: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+taskID.String()+"/result", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", "device-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("response code = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return response
+}
+
+func TestHandleTaskResultRunsHooksAndHonoursVeto(t *testing.T) {
+	controller := NewRunnerController(nil)
+
+	var seen []string
+	controller.RegisterResultHook(ResultHookFunc{
+		HookName: "warehouse",
+		Fn: func(ctx context.Context, result *models.TaskResult) (ResultHookOutcome, error) {
+			seen = append(seen, "warehouse")
+			return ResultHookOutcome{}, errors.New("warehouse offline")
+		},
+	})
+	controller.RegisterResultHook(ResultHookFunc{
+		HookName: "scorer",
+		Fn: func(ctx context.Context, result *models.TaskResult) (ResultHookOutcome, error) {
+			seen = append(seen, "scorer")
+			if _, ok := controller.GetTaskResult(result.TaskID.String()); !ok {
+				t.Fatal("expected result to be saved before hooks run")
+			}
+			return ResultHookOutcome{Veto: true, Reason: "score below threshold"}, nil
+		},
+	})
+
+	response := postResult(t, newTestRouter(controller), uuid.New())
+
+	if len(seen) != 2 {
+		t.Fatalf("expected both hooks to run, got %v", seen)
+	}
+	if response["payout_approved"] != false {
+		t.Fatalf("expected payout to be vetoed, got %v", response)
+	}
+	if response["veto_reason"] != "score below threshold" {
+		t.Fatalf("unexpected veto reason: %v", response["veto_reason"])
+	}
+}
+
+func TestWebhookResultHookFailClosed(t *testing.T) {
+	hookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer hookServer.Close()
+
+	controller := NewRunnerController(nil)
+	controller.RegisterResultHook(NewWebhookResultHook("validator", hookServer.URL, 0, true))
+
+	response := postResult(t, newTestRouter(controller), uuid.New())
+	if response["payout_approved"] != false {
+		t.Fatalf("expected fail-closed hook to veto payout, got %v", response)
+	}
+}
+
+func TestHandleTaskResultRejectsResultForAnotherTask(t *testing.T) {
+	controller := NewRunnerController(nil)
+	var hooked int
+	controller.RegisterResultHook(ResultHookFunc{
+		HookName: "counter",
+		Fn: func(ctx context.Context, result *models.TaskResult) (ResultHookOutcome, error) {
+			hooked++
+			return ResultHookOutcome{}, nil
+		},
+	})
+
+	urlTaskID, bodyTaskID := uuid.New(), uuid.New()
+	body, _ := json.Marshal(models.TaskResult{TaskID: bodyTaskID, Output: "done"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+urlTaskID.String()+"/result", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", "device-1")
+	rec := httptest.NewRecorder()
+	newTestRouter(controller).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("response code = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	for _, taskID := range []uuid.UUID{urlTaskID, bodyTaskID} {
+		if _, ok := controller.GetTaskResult(taskID.String()); ok {
+			t.Fatalf("result was stored under %s", taskID)
+		}
+	}
+	if hooked != 0 {
+		t.Fatalf("hooks ran %d times for a rejected result", hooked)
+	}
+}
+
+func TestCreatorMetricsCountEachCreatorsTasks(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	run := func(creator, claimedCreator string, cpuSeconds float64) {
+		t.Helper()
+		body := []byte(`{"title":"job","type":"command","nonce":"n","creator_address":"` + creator + `","config":{"command":["echo","hi"]}}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(body))
+		req.Header.Set("X-Device-ID", "creator-device")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var task models.Task
+		if rec.Code != http.StatusCreated || json.Unmarshal(rec.Body.Bytes(), &task) != nil {
+			t.Fatalf("create task = %d %s", rec.Code, rec.Body.String())
+		}
+		if status, message := controller.startTask(context.Background(), task.ID.String(), "device-1"); status != 0 {
+			t.Fatalf("startTask() = %d %s", status, message)
+		}
+
+		result, _ := json.Marshal(models.TaskResult{Output: "hi", CPUSeconds: cpuSeconds, CreatorAddress: claimedCreator})
+		req = httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+task.ID.String()+"/result", bytes.NewReader(result))
+		req.Header.Set("X-Device-ID", "device-1")
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("submit result = %d %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	alice := "0x00000000000000000000000000000000000000a1"
+	bob := "0x00000000000000000000000000000000000000b2"
+	run(alice, "", 2)
+	run(bob, "", 3)
+	// The runner cannot bill a task to another creator
+	run(bob, alice, 5)
+
+	usage := func(address string) models.ResourceUsageSummary {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metrics/creators/"+address, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var response struct {
+			Usage models.ResourceUsageSummary `json:"usage"`
+		}
+		if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &response) != nil {
+			t.Fatalf("creator metrics = %d %s", rec.Code, rec.Body.String())
+		}
+		return response.Usage
+	}
+	if got := usage(alice); got.TaskCount != 1 || got.TotalCPUSeconds != 2 {
+		t.Fatalf("alice's usage = %+v, want 1 task and 2 CPU seconds", got)
+	}
+	if got := usage(bob); got.TaskCount != 2 || got.TotalCPUSeconds != 8 {
+		t.Fatalf("bob's usage = %+v, want 2 tasks and 8 CPU seconds", got)
+	}
+}
+
+type recordingPenalizer struct {
+	penalties map[string]int
+}
+
+func (p *recordingPenalizer) Penalize(deviceID string, points int, reason string) {
+	p.penalties[deviceID] += points
+}
+
+func TestCanaryMonitorPenalizesMismatchedResult(t *testing.T) {
+	controller := NewRunnerController(nil)
+	penalizer := &recordingPenalizer{penalties: make(map[string]int)}
+
+	var alerts []string
+	monitor, err := NewCanaryMonitor(controller, CanaryConfig{
+		PenaltyPoints: 5,
+		Penalizer:     penalizer,
+		Alert: func(deviceID, taskID, reason string) {
+			alerts = append(alerts, deviceID)
+		},
+		Templates: []CanaryTemplate{{
+			Title:          "canary",
+			Type:           models.TaskTypeDocker,
+			Config:         json.RawMessage(`{"command":["echo","ok"]}`),
+			ExpectedOutput: "ok\n",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewCanaryMonitor() = %v", err)
+	}
+
+	task, err := monitor.Inject()
+	if err != nil {
+		t.Fatalf("failed to inject canary: %v", err)
+	}
+	if !monitor.IsCanary(task.ID.String()) {
+		t.Fatal("expected injected task to be tracked as canary")
+	}
+
+	outcome, err := monitor.AfterResultSaved(context.Background(), &models.TaskResult{
+		TaskID:     task.ID,
+		DeviceID:   "device-1",
+		ResultHash: "wrong",
+	})
+	if err != nil {
+		t.Fatalf("unexpected hook error: %v", err)
+	}
+
+	if !outcome.Veto {
+		t.Fatal("expected failed canary to veto payout")
+	}
+	if penalizer.penalties["device-1"] != 5 || monitor.Failures("device-1") != 1 {
+		t.Fatalf("expected penalty to be recorded, got %v", penalizer.penalties)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("expected one alert, got %d", len(alerts))
+	}
+
+	outcome, _ = monitor.AfterResultSaved(context.Background(), &models.TaskResult{TaskID: uuid.New(), DeviceID: "device-1"})
+	if outcome.Veto {
+		t.Fatal("expected non-canary results to pass through")
+	}
+}
+
+func TestCanaryMonitorPassesHonestRunner(t *testing.T) {
+	controller := NewRunnerController(nil)
+	penalizer := &recordingPenalizer{penalties: make(map[string]int)}
+
+	monitor, err := NewCanaryMonitor(controller, CanaryConfig{
+		Penalizer: penalizer,
+		Templates: []CanaryTemplate{{
+			Title:          "canary",
+			Type:           models.TaskTypeDocker,
+			Config:         json.RawMessage(`{"command":["echo","ok"]}`),
+			ExpectedOutput: "ok\n",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewCanaryMonitor() = %v", err)
+	}
+
+	earlier, err := monitor.Inject()
+	if err != nil {
+		t.Fatalf("failed to inject canary: %v", err)
+	}
+	task, err := monitor.Inject()
+	if err != nil {
+		t.Fatalf("failed to inject canary: %v", err)
+	}
+	if task.Nonce == earlier.Nonce {
+		t.Fatal("canaries of the same template got the same nonce")
+	}
+
+	// An answer to an earlier canary of the same template does not pass
+	replayed := fmt.Sprintf("NONCE: %s\n%s", earlier.Nonce, "ok\n")
+	outcome, err := monitor.AfterResultSaved(context.Background(), &models.TaskResult{
+		TaskID:     task.ID,
+		DeviceID:   "device-2",
+		Output:     replayed,
+		ResultHash: utils.ComputeResultHash(replayed, "", 0),
+	})
+	if err != nil || !outcome.Veto {
+		t.Fatalf("replayed canary answer = %+v, %v, want a veto", outcome, err)
+	}
+
+	// Runners prefix the output with the task nonce, which the hash covers
+	output := fmt.Sprintf("NONCE: %s\n%s", earlier.Nonce, "ok\n")
+	outcome, err = monitor.AfterResultSaved(context.Background(), &models.TaskResult{
+		TaskID:     earlier.ID,
+		DeviceID:   "device-1",
+		Output:     output,
+		ResultHash: utils.ComputeResultHash(output, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("unexpected hook error: %v", err)
+	}
+	if outcome.Veto || monitor.Failures("device-1") != 0 || penalizer.penalties["device-1"] != 0 {
+		t.Fatalf("honest canary result was failed: %+v", outcome)
+	}
+}
+
+func TestCanaryMonitorRejectsTemplateWithoutKnownOutputFormat(t *testing.T) {
+	_, err := NewCanaryMonitor(NewRunnerController(nil), CanaryConfig{
+		Templates: []CanaryTemplate{{Title: "canary", Type: models.TaskTypeCommand, ExpectedOutput: "ok\n"}},
+	})
+	if err == nil {
+		t.Fatal("NewCanaryMonitor() accepted a template whose hash cannot be computed")
+	}
+}
+
+func TestCanaryMonitorRejectsLLMTemplateWithoutNonce(t *testing.T) {
+	_, err := NewCanaryMonitor(NewRunnerController(nil), CanaryConfig{
+		Templates: []CanaryTemplate{{
+			Title:          "canary",
+			Type:           models.TaskTypeLLM,
+			Config:         json.RawMessage(`{"model":"m","prompt":"Say ok"}`),
+			ExpectedOutput: "ok",
+		}},
+	})
+	if err == nil {
+		t.Fatal("NewCanaryMonitor() accepted an llm template whose answer can be replayed")
+	}
+}
+
+func TestLLMCanaryIsCheckedThroughPromptCompletion(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+	monitor, err := NewCanaryMonitor(controller, CanaryConfig{
+		Templates: []CanaryTemplate{{
+			Title:          "canary",
+			Type:           models.TaskTypeLLM,
+			Config:         json.RawMessage(`{"model":"m","prompt":"Reply with ` + CanaryNonce + `"}`),
+			ExpectedOutput: CanaryNonce,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewCanaryMonitor() = %v", err)
+	}
+
+	complete := func(taskID uuid.UUID, answer string) map[string]interface{} {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{"response": answer})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/llm/prompts/"+taskID.String()+"/complete", bytes.NewReader(body))
+		req.Header.Set("X-Device-ID", "device-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("response code = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var response map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return response
+	}
+
+	canary, err := monitor.Inject()
+	if err != nil {
+		t.Fatalf("failed to inject canary: %v", err)
+	}
+	if string(canary.Config) != `{"model":"m","prompt":"Reply with `+canary.Nonce+`"}` {
+		t.Fatalf("canary config = %s, want the nonce in the prompt", canary.Config)
+	}
+	if response := complete(canary.ID, "Sure: "+canary.Nonce); response["payout_approved"] != true {
+		t.Fatalf("correct answer was vetoed: %v", response)
+	}
+
+	canary, err = monitor.Inject()
+	if err != nil {
+		t.Fatalf("failed to inject canary: %v", err)
+	}
+	response := complete(canary.ID, "I cannot help with that")
+	if response["payout_approved"] != false || response["veto_reason"] == "" {
+		t.Fatalf("wrong answer was not vetoed: %v", response)
+	}
+	if result, ok := controller.GetTaskResult(canary.ID.String()); !ok || result.DeviceID != "device-1" || result.Output != "I cannot help with that" {
+		t.Fatalf("stored result = %+v, want the answer from device-1", result)
+	}
+}
+
+func TestCanaryMonitorExpiresUnansweredCanaries(t *testing.T) {
+	controller := NewRunnerController(nil)
+	monitor, err := NewCanaryMonitor(controller, CanaryConfig{
+		Expiry:    time.Millisecond,
+		Templates: []CanaryTemplate{{Title: "canary", Type: models.TaskTypeDocker, ExpectedOutput: "ok\n"}},
+	})
+	if err != nil {
+		t.Fatalf("NewCanaryMonitor() = %v", err)
+	}
+
+	stale, err := monitor.Inject()
+	if err != nil {
+		t.Fatalf("failed to inject canary: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if monitor.IsCanary(stale.ID.String()) {
+		t.Fatal("expired canary is still tracked")
+	}
+
+	if _, err := monitor.Inject(); err != nil {
+		t.Fatalf("failed to inject canary: %v", err)
+	}
+	monitor.mu.Lock()
+	_, kept := monitor.active[stale.ID.String()]
+	monitor.mu.Unlock()
+	if kept {
+		t.Fatal("expired canary was not dropped")
+	}
+	controller.mu.RLock()
+	offered := controller.findAvailableLocked(stale.ID.String())
+	controller.mu.RUnlock()
+	if offered != nil {
+		t.Fatal("expired canary is still offered to runners")
+	}
+}
+
+func TestCanaryPenaltyGoesToTheSubmittingDevice(t *testing.T) {
+	controller := NewRunnerController(nil)
+	penalizer := &recordingPenalizer{penalties: make(map[string]int)}
+	monitor, err := NewCanaryMonitor(controller, CanaryConfig{
+		PenaltyPoints: 5,
+		Penalizer:     penalizer,
+		Templates:     []CanaryTemplate{{Title: "canary", Type: models.TaskTypeDocker, ExpectedOutput: "ok\n"}},
+	})
+	if err != nil {
+		t.Fatalf("NewCanaryMonitor() = %v", err)
+	}
+	task, err := monitor.Inject()
+	if err != nil {
+		t.Fatalf("failed to inject canary: %v", err)
+	}
+
+	body, _ := json.Marshal(models.TaskResult{TaskID: task.ID, ResultHash: "wrong"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+task.ID.String()+"/result", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", "device-1")
+	rec := httptest.NewRecorder()
+	newTestRouter(controller).ServeHTTP(rec, req)
+
+	if penalizer.penalties["device-1"] != 5 || len(penalizer.penalties) != 1 {
+		t.Fatalf("penalties = %v, want the submitting device penalized", penalizer.penalties)
+	}
+}
+
+func TestMetricsEndpointsAggregatePerRunner(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	first := &models.TaskResult{TaskID: uuid.New(), DeviceID: "device-1", CPUSeconds: 1.5, PeakMemoryGB: 0.5}
+	second := &models.TaskResult{TaskID: uuid.New(), DeviceID: "device-1", CPUSeconds: 2.5, PeakMemoryGB: 1.5}
+	other := &models.TaskResult{TaskID: uuid.New(), DeviceID: "device-2", CPUSeconds: 10}
+	for _, result := range []*models.TaskResult{first, second, other} {
+		controller.SaveTaskResult(result)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tasks/"+first.TaskID.String()+"/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("task metrics response code = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var taskMetrics models.TaskMetrics
+	if err := json.Unmarshal(rec.Body.Bytes(), &taskMetrics); err != nil {
+		t.Fatalf("failed to decode task metrics: %v", err)
+	}
+	if taskMetrics.CPUSeconds != 1.5 {
+		t.Fatalf("task cpu seconds = %v, want 1.5", taskMetrics.CPUSeconds)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metrics/runners/device-1", nil))
+
+	var runnerMetrics struct {
+		Usage models.ResourceUsageSummary `json:"usage"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &runnerMetrics); err != nil {
+		t.Fatalf("failed to decode runner metrics: %v", err)
+	}
+	if runnerMetrics.Usage.TaskCount != 2 || runnerMetrics.Usage.TotalCPUSeconds != 4 || runnerMetrics.Usage.MaxPeakMemoryGB != 1.5 {
+		t.Fatalf("unexpected runner usage summary: %+v", runnerMetrics.Usage)
+	}
+}
+
+func TestStatsOverviewAggregatesSupplyAndDemand(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	heartbeat := []byte(`{"type":"heartbeat","payload":{"cpu_usage":40,"memory_usage":1024}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/heartbeat", bytes.NewReader(heartbeat))
+	req.Header.Set("X-Device-ID", "device-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assigned := models.NewTask()
+	assigned.CreatedAt = assigned.CreatedAt.Add(-2 * time.Second)
+	controller.AddAvailableTask(assigned)
+	controller.AddAvailableTask(models.NewTask())
+	controller.AddAvailableTask(models.NewTask())
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+assigned.ID.String()+"/start", nil)
+	req.Header.Set("X-Device-ID", "device-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	postResult(t, router, assigned.ID)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/overview?window=30m", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("overview response code = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var overview StatsOverview
+	if err := json.Unmarshal(rec.Body.Bytes(), &overview); err != nil {
+		t.Fatalf("failed to decode overview: %v", err)
+	}
+
+	if overview.RunnersOnline != 1 || overview.QueueDepth != 2 {
+		t.Fatalf("unexpected supply/demand: %+v", overview)
+	}
+	if overview.TasksCompleted != 1 || overview.ThroughputPerHour != 2 {
+		t.Fatalf("unexpected throughput: completed=%d per_hour=%v", overview.TasksCompleted, overview.ThroughputPerHour)
+	}
+	if overview.AvgAssignmentLatencyMs < 2000 {
+		t.Fatalf("assignment latency = %v, want at least 2000ms", overview.AvgAssignmentLatencyMs)
+	}
+	if overview.AvgRunnerCPUUsage != 40 || overview.SupplyDemandRatio != 0.5 {
+		t.Fatalf("unexpected runner usage: %+v", overview)
+	}
+	if len(overview.Series[SeriesQueueDepth]) == 0 {
+		t.Fatal("expected queue depth series in overview")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/overview?window=bogus", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid window response code = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAvailableTasksRouteGPUTasksByHeartbeatInventory(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	heartbeat := func(deviceID, payload string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/heartbeat", bytes.NewReader([]byte(`{"type":"heartbeat","payload":`+payload+`}`)))
+		req.Header.Set("X-Device-ID", deviceID)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("heartbeat response code = %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+	heartbeat("gpu-runner", `{"gpus":[{"index":0,"model":"NVIDIA A100-SXM4-80GB","memory_mb":81920}]}`)
+	heartbeat("cpu-runner", `{}`)
+
+	gpuTask := models.NewTask()
+	gpuTask.GPU = &models.GPURequirements{Model: "a100", MinMemoryMB: 40000}
+	cpuTask := models.NewTask()
+	controller.AddAvailableTask(gpuTask)
+	controller.AddAvailableTask(cpuTask)
+
+	if tasks := controller.availableTasksFor("gpu-runner"); len(tasks) != 2 {
+		t.Fatalf("gpu runner got %d tasks, want 2", len(tasks))
+	}
+	tasks := controller.availableTasksFor("cpu-runner")
+	if len(tasks) != 1 || tasks[0].ID != cpuTask.ID {
+		t.Fatalf("cpu runner got %v, want only the CPU task", tasks)
+	}
+
+	heartbeat("gpu-runner", `{}`)
+	if tasks := controller.availableTasksFor("gpu-runner"); len(tasks) != 1 {
+		t.Fatalf("gpu runner without GPUs got %d tasks, want 1", len(tasks))
+	}
+}
+
+func TestEstimateScalesWithDemandAndEnforcesFloor(t *testing.T) {
+	controller := NewRunnerController(nil)
+	config := DefaultPricingConfig()
+	config.BaseReward = 1
+	config.PerRuntimeSecond = 0
+	config.EnforceFloor = true
+	controller.SetPricing(config)
+	router := newTestRouter(controller)
+
+	class := TaskClass{Type: models.TaskTypeDocker}
+	idle := controller.SuggestReward(class, time.Now())
+	if idle.SuggestedMinReward != 1 {
+		t.Fatalf("idle suggestion = %v, want base reward", idle.SuggestedMinReward)
+	}
+
+	heartbeat := []byte(`{"type":"heartbeat","payload":{}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/heartbeat", bytes.NewReader(heartbeat))
+	req.Header.Set("X-Device-ID", "device-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	controller.AddAvailableTask(models.NewTask())
+	controller.AddAvailableTask(models.NewTask())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks/estimate", bytes.NewReader([]byte(`{"type":"docker"}`))))
+	var busy PriceSuggestion
+	if err := json.Unmarshal(rec.Body.Bytes(), &busy); err != nil {
+		t.Fatalf("failed to decode estimate: %v", err)
+	}
+	if busy.DemandMultiplier != 2 || busy.SuggestedMinReward != 2 || !busy.FloorEnforced {
+		t.Fatalf("unexpected busy estimate: %+v", busy)
+	}
+
+	cheap := []byte(`{"title":"cheap","type":"command","config":{},"reward":0.5}`)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(cheap)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("below-floor task response code = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	fair := []byte(`{"title":"fair","type":"command","config":{},"reward":5}`)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(fair)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("task at floor response code = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+}
+
+type memoryContentStore struct {
+	blobs map[string][]byte
+}
+
+func (s *memoryContentStore) Add(ctx context.Context, data []byte) (string, error) {
+	cid := "bafy" + uuid.NewString()[:8]
+	s.blobs[cid] = data
+	return cid, nil
+}
+
+func TestCreateTaskOffloadsOversizedPrompt(t *testing.T) {
+	controller := NewRunnerController(nil)
+	store := &memoryContentStore{blobs: make(map[string][]byte)}
+	controller.SetContentStore(store, 16)
+	router := newTestRouter(controller)
+
+	prompt := "summarise the following very long document"
+	body, _ := json.Marshal(map[string]interface{}{
+		"title":  "llm",
+		"type":   "llm",
+		"config": map[string]string{"model": "llama2", "prompt": prompt},
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("response code = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	var task models.Task
+	if err := json.Unmarshal(rec.Body.Bytes(), &task); err != nil {
+		t.Fatalf("failed to decode task: %v", err)
+	}
+	var config map[string]string
+	if err := json.Unmarshal(task.Config, &config); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+
+	if _, inline := config["prompt"]; inline {
+		t.Fatal("oversized prompt should not stay inline")
+	}
+	if string(store.blobs[config["prompt_cid"]]) != prompt {
+		t.Fatalf("stored prompt = %q, want the original prompt", store.blobs[config["prompt_cid"]])
+	}
+	if config["prompt_sha256"] == "" || config["model"] != "llama2" {
+		t.Fatalf("unexpected offloaded config: %v", config)
+	}
+}
+
+func TestHandleTaskResultValidatesUploads(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	taskID := uuid.New()
+	upload := models.ResultUpload{Name: models.OutputUploadName, CID: "bafyoutput", SHA256: strings.Repeat("ab", 32), Size: 2}
+	for _, tc := range []struct {
+		upload models.ResultUpload
+		want   int
+	}{
+		{models.ResultUpload{Name: "../etc/passwd", CID: upload.CID, SHA256: upload.SHA256}, http.StatusBadRequest},
+		{models.ResultUpload{Name: upload.Name, CID: upload.CID, SHA256: "short"}, http.StatusBadRequest},
+		{upload, http.StatusOK},
+	} {
+		body, _ := json.Marshal(models.TaskResult{TaskID: taskID, Uploads: &models.ResultUploads{Files: []models.ResultUpload{tc.upload}}})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+taskID.String()+"/result", bytes.NewReader(body))
+		req.Header.Set("X-Device-ID", "device-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("upload %+v = %d %s, want %d", tc.upload, rec.Code, rec.Body.String(), tc.want)
+		}
+	}
+
+	stored, ok := controller.GetTaskResult(taskID.String())
+	if got, found := stored.Uploads.Output(); !ok || !found || got.CID != upload.CID {
+		t.Fatal("uploaded output was not stored with the result")
+	}
+}
+
+func TestServerCountersignsOnlyReceiptsMatchingTheTask(t *testing.T) {
+	runnerKey, _ := crypto.GenerateKey()
+	serverKey, _ := crypto.GenerateKey()
+
+	controller := NewRunnerController(nil)
+	controller.SetReceiptSigner(serverKey)
+
+	submit := func(mutate func(*models.ExecutionReceipt)) *models.TaskResult {
+		t.Helper()
+		task := &models.Task{ID: uuid.New(), Type: models.TaskTypeDocker, Config: json.RawMessage(`{"image": "alpine"}`), Nonce: "nonce"}
+		controller.AddAvailableTask(task)
+		if code, message := controller.startTask(context.Background(), task.ID.String(), "device-1"); code != 0 {
+			t.Fatalf("start = %d %s", code, message)
+		}
+
+		result := &models.TaskResult{TaskID: task.ID, DeviceID: "device-1", Output: "done"}
+		executionReceipt := receipt.Build(task, result, "")
+		mutate(executionReceipt)
+		if err := receipt.Sign(executionReceipt, runnerKey); err != nil {
+			t.Fatal(err)
+		}
+		result.Receipt = executionReceipt
+
+		if _, err := controller.submitTaskResult(context.Background(), result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if honest := submit(func(*models.ExecutionReceipt) {}); honest.Receipt == nil || honest.Receipt.ServerSignature == "" {
+		t.Fatal("receipt matching the task was not countersigned")
+	}
+
+	forgeries := map[string]func(*models.ExecutionReceipt){
+		"another task":   func(r *models.ExecutionReceipt) { r.TaskID = uuid.New() },
+		"another device": func(r *models.ExecutionReceipt) { r.DeviceID = "device-2" },
+		"another output": func(r *models.ExecutionReceipt) { r.OutputHash = receipt.OutputHash("forged") },
+		"another input":  func(r *models.ExecutionReceipt) { r.InputHash = receipt.OutputHash("forged") },
+	}
+	for name, forge := range forgeries {
+		if result := submit(forge); result.Receipt != nil {
+			t.Fatalf("%s: server countersigned a receipt that does not match the task", name)
+		}
+	}
+}
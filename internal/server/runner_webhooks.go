@@ -0,0 +1,63 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+)
+
+// RunnerWebhook is where a runner receives task notifications. Token, when set,
+// is the capability the runner issued at registration and must be sent as a bearer token.
+type RunnerWebhook struct {
+	URL   string
+	Token string
+}
+
+func (c *RunnerController) RunnerWebhook(deviceID string) (RunnerWebhook, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	webhook, ok := c.runnerWebhooks[deviceID]
+	return webhook, ok
+}
+
+// NewRunnerWebhookRequest builds a notification request for a runner's registered
+// webhook, signed for that runner with the server identity when one is configured
+func (c *RunnerController) NewRunnerWebhookRequest(ctx context.Context, deviceID string, body []byte) (*http.Request, error) {
+	webhook, ok := c.RunnerWebhook(deviceID)
+	if !ok || webhook.URL == "" {
+		return nil, fmt.Errorf("runner %s has no registered webhook", deviceID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+webhook.Token)
+	}
+	if signer := c.identitySigner(); signer != nil {
+		if err := signer.SignWebhook(req.Header, deviceID, body); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// handleUnregisterWebhook stops notifications to a runner that is shutting down.
+// Tasks still reach it through polling if it comes back without a webhook.
+func (c *RunnerController) handleUnregisterWebhook(ctx *gin.Context) {
+	log := gologger.WithComponent("runner_controller")
+	deviceID := ctx.GetHeader("X-Device-ID")
+
+	c.mu.Lock()
+	delete(c.runnerWebhooks, deviceID)
+	c.mu.Unlock()
+
+	log.Info().Str("device_id", deviceID).Msg("Runner webhook unregistered")
+	ctx.JSON(http.StatusOK, gin.H{"status": "unregistered"})
+}
@@ -3,11 +3,13 @@ package server
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/theblitlabs/gologger"
+	"google.golang.org/grpc"
 
 	"github.com/theblitlabs/parity-runner/internal/core/config"
 )
@@ -17,6 +19,8 @@ type Server struct {
 	router      *gin.Engine
 	cfg         *config.Config
 	controllers []Controller
+	chain       *ChainGateway
+	grpcServer  *grpc.Server
 }
 
 type Controller interface {
@@ -68,6 +72,16 @@ func (s *Server) RegisterController(c Controller) {
 	s.controllers = append(s.controllers, c)
 }
 
+// SetChainGateway reports chain connectivity separately on the health endpoint
+func (s *Server) SetChainGateway(gateway *ChainGateway) {
+	s.chain = gateway
+}
+
+// SetGRPCServer serves the gRPC API next to the REST one when SERVER_GRPC_PORT is set
+func (s *Server) SetGRPCServer(server *grpc.Server) {
+	s.grpcServer = server
+}
+
 func (s *Server) Start() error {
 	log := gologger.WithComponent("server")
 
@@ -75,9 +89,21 @@ func (s *Server) Start() error {
 		controller.RegisterRoutes(s.router)
 	}
 
-	s.router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	s.router.GET("/health", s.handleHealth)
+
+	if s.grpcServer != nil && s.cfg.Server.GRPCPort != "" {
+		grpcAddr := fmt.Sprintf("%s:%s", s.cfg.Server.Host, s.cfg.Server.GRPCPort)
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for gRPC on %s: %w", grpcAddr, err)
+		}
+		log.Info().Str("addr", grpcAddr).Msg("Starting gRPC server")
+		go func() {
+			if err := s.grpcServer.Serve(listener); err != nil {
+				log.Error().Err(err).Msg("gRPC server stopped")
+			}
+		}()
+	}
 
 	serverAddr := s.httpServer.Addr
 	log.Info().Str("addr", serverAddr).Msg("Starting HTTP server")
@@ -85,10 +111,30 @@ func (s *Server) Start() error {
 	return s.httpServer.ListenAndServe()
 }
 
+// handleHealth stays 200 while the chain is down: task handling keeps working and
+// only chain dependent features are degraded
+func (s *Server) handleHealth(c *gin.Context) {
+	if s.chain == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		return
+	}
+
+	chain := s.chain.Status()
+	status := "ok"
+	if !chain.Connected {
+		status = "degraded"
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status, "chain": chain})
+}
+
 func (s *Server) Stop(ctx context.Context) error {
 	log := gologger.WithComponent("server")
 	log.Info().Msg("Shutting down HTTP server...")
 
+	if s.grpcServer != nil {
+		s.grpcServer.GracefulStop()
+	}
+
 	return s.httpServer.Shutdown(ctx)
 }
 
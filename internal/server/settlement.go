@@ -0,0 +1,58 @@
+package server
+
+// AddSettlementChain pays runners that ask for chainID at registration through
+// gateway, and checks their stake there, instead of on the default chain
+func (c *RunnerController) AddSettlementChain(chainID int64, gateway *ChainGateway) {
+	c.watchPayouts(gateway)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.settlementChains == nil {
+		c.settlementChains = make(map[int64]*ChainGateway)
+	}
+	c.settlementChains[chainID] = gateway
+}
+
+// recordSettlementChain remembers the chain a runner asked to be paid on and
+// returns the one it will be paid on, zero for the default chain
+func (c *RunnerController) recordSettlementChain(deviceID string, chainID int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.runnerChains == nil {
+		c.runnerChains = make(map[string]int64)
+	}
+	if _, ok := c.settlementChains[chainID]; !ok {
+		chainID = 0
+	}
+	if chainID == 0 {
+		delete(c.runnerChains, deviceID)
+	} else {
+		c.runnerChains[deviceID] = chainID
+	}
+	return chainID
+}
+
+// gatewayFor is the gateway of the runner's settlement chain, or the default
+// one with chain ID zero. It must be called with c.mu held.
+func (c *RunnerController) gatewayFor(deviceID string) (*ChainGateway, int64) {
+	if chainID, ok := c.runnerChains[deviceID]; ok {
+		if gateway, ok := c.settlementChains[chainID]; ok {
+			return gateway, chainID
+		}
+	}
+	return c.chain, 0
+}
+
+// gateways are the default gateway, if any, and those of settlement chains
+func (c *RunnerController) gateways() []*ChainGateway {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var gateways []*ChainGateway
+	if c.chain != nil {
+		gateways = append(gateways, c.chain)
+	}
+	for _, gateway := range c.settlementChains {
+		gateways = append(gateways, gateway)
+	}
+	return gateways
+}
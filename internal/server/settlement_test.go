@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func registerOnChain(t *testing.T, router http.Handler, deviceID string, chainID int64) int64 {
+	t.Helper()
+	body, _ := json.Marshal(models.RunnerRegistration{
+		WalletAddress:     "0x0000000000000000000000000000000000000001",
+		Status:            models.RunnerStatusOnline,
+		SettlementChainID: chainID,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners", bytes.NewReader(body))
+	req.Header.Set("X-Device-ID", deviceID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("registration = %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		SettlementChainID int64 `json:"settlement_chain_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode registration response: %v", err)
+	}
+	return response.SettlementChainID
+}
+
+func TestRunnersArePaidOnTheirSettlementChain(t *testing.T) {
+	defaultChain := &fakeChain{stakes: map[string]*big.Int{}}
+	base := &fakeChain{stakes: map[string]*big.Int{"device-1": big.NewInt(100)}}
+	controller := NewRunnerController(nil)
+	controller.SetChainGateway(NewChainGateway(defaultChain, ChainGatewayConfig{}), big.NewInt(50))
+	controller.AddSettlementChain(8453, NewChainGateway(base, ChainGatewayConfig{}))
+	router := newTestRouter(controller)
+
+	if chainID := registerOnChain(t, router, "device-2", 10); chainID != 0 {
+		t.Fatalf("settlement chain of unsupported chain = %d, want the default chain", chainID)
+	}
+	if chainID := registerOnChain(t, router, "device-1", 8453); chainID != 8453 {
+		t.Fatalf("settlement chain = %d, want 8453", chainID)
+	}
+
+	task := models.NewTask()
+	task.Reward = 2
+	controller.AddAvailableTask(task)
+	start := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+task.ID.String()+"/start", nil)
+	start.Header.Set("X-Device-ID", "device-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, start)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("start with stake on the settlement chain = %d: %s", rec.Code, rec.Body.String())
+	}
+	if response := postResult(t, router, task.ID); response["payout_status"] != "sent" {
+		t.Fatalf("payout_status = %v, want sent", response["payout_status"])
+	}
+
+	if len(base.transfers) != 1 || len(defaultChain.transfers) != 0 {
+		t.Fatalf("transfers: settlement chain %+v, default chain %+v; want the reward on the settlement chain", base.transfers, defaultChain.transfers)
+	}
+	report := controller.EarningsReport("0x0000000000000000000000000000000000000001", time.Now())
+	if report.Payouts != 1 || report.Rewards[0].ChainID != 8453 {
+		t.Fatalf("report = %+v, want one reward paid on chain 8453", report)
+	}
+}
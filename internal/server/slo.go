@@ -0,0 +1,310 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+)
+
+const (
+	SLOAssignmentLatency = "assignment_latency"
+	SLOCompletionRate    = "completion_rate"
+	SLOPayoutLatency     = "payout_latency"
+)
+
+// burnRateWindows are the windows of the multiwindow burn-rate alerts: a fast
+// burn pages on 1h and 5m, a slow burn opens a ticket on 6h and 30m
+var burnRateWindows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+
+// SLO is an objective over events that are either good or bad. For latency
+// objectives an event is good when it finished within Threshold.
+type SLO struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Objective   float64       `json:"objective"`
+	Threshold   time.Duration `json:"threshold,omitempty"`
+}
+
+// SLOsFromConfig builds the protocol SLOs, using defaults for unset values
+func SLOsFromConfig(cfg config.SLOConfig) []SLO {
+	objective := func(value, fallback float64) float64 {
+		if value <= 0 || value >= 1 {
+			return fallback
+		}
+		return value
+	}
+	threshold := func(value, fallback time.Duration) time.Duration {
+		if value <= 0 {
+			return fallback
+		}
+		return value
+	}
+
+	return []SLO{
+		{
+			Name:        SLOAssignmentLatency,
+			Description: "Tasks are started by a runner soon after they are created",
+			Objective:   objective(cfg.AssignmentObjective, 0.99),
+			Threshold:   threshold(cfg.AssignmentLatency, 2*time.Minute),
+		},
+		{
+			Name:        SLOCompletionRate,
+			Description: "Task results report success",
+			Objective:   objective(cfg.CompletionObjective, 0.95),
+		},
+		{
+			Name:        SLOPayoutLatency,
+			Description: "Rewards reach the chain soon after a result is accepted",
+			Objective:   objective(cfg.PayoutObjective, 0.99),
+			Threshold:   threshold(cfg.PayoutLatency, 10*time.Minute),
+		},
+	}
+}
+
+// SLOStatus is an SLO with its event counts and current burn rates. A burn rate
+// of 1 spends the error budget exactly over the SLO period.
+type SLOStatus struct {
+	SLO
+	Total     int64              `json:"total"`
+	Bad       int64              `json:"bad"`
+	BurnRates map[string]float64 `json:"burn_rates"`
+}
+
+// sloTracker keeps lifetime counters for the Prometheus counters, while the
+// windowed rates come from the controller's time-series store
+type sloTracker struct {
+	mu    sync.RWMutex
+	slos  []SLO
+	total map[string]int64
+	bad   map[string]int64
+}
+
+func newSLOTracker(slos []SLO) *sloTracker {
+	return &sloTracker{
+		slos:  slos,
+		total: make(map[string]int64),
+		bad:   make(map[string]int64),
+	}
+}
+
+func (t *sloTracker) get(name string) (SLO, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, slo := range t.slos {
+		if slo.Name == name {
+			return slo, true
+		}
+	}
+	return SLO{}, false
+}
+
+func sloSeries(name, kind string) string {
+	return "slo." + name + "." + kind
+}
+
+// SetSLOs replaces the tracked objectives
+func (c *RunnerController) SetSLOs(slos []SLO) {
+	c.slo.mu.Lock()
+	defer c.slo.mu.Unlock()
+	c.slo.slos = slos
+}
+
+func (c *RunnerController) recordSLOEvent(name string, good bool, at time.Time) {
+	if _, ok := c.slo.get(name); !ok {
+		return
+	}
+
+	c.slo.mu.Lock()
+	c.slo.total[name]++
+	if !good {
+		c.slo.bad[name]++
+	}
+	c.slo.mu.Unlock()
+
+	c.stats.Record(sloSeries(name, "total"), 1, at)
+	if !good {
+		c.stats.Record(sloSeries(name, "bad"), 1, at)
+	}
+}
+
+func (c *RunnerController) recordSLOLatency(name string, latency time.Duration, at time.Time) {
+	slo, ok := c.slo.get(name)
+	if !ok {
+		return
+	}
+	c.recordSLOEvent(name, latency <= slo.Threshold, at)
+}
+
+// BurnRate is the error rate over the window divided by the error budget. It is
+// 0 when no events were recorded in the window.
+func (c *RunnerController) BurnRate(slo SLO, window time.Duration, now time.Time) float64 {
+	from := now.Add(-window)
+	total := c.stats.Summarize(sloSeries(slo.Name, "total"), from, now).Count
+	if total == 0 {
+		return 0
+	}
+	bad := c.stats.Summarize(sloSeries(slo.Name, "bad"), from, now).Count
+	return (float64(bad) / float64(total)) / (1 - slo.Objective)
+}
+
+func (c *RunnerController) SLOStatuses(now time.Time) []SLOStatus {
+	c.slo.mu.RLock()
+	slos := append([]SLO{}, c.slo.slos...)
+	totals := make(map[string][2]int64, len(slos))
+	for _, slo := range slos {
+		totals[slo.Name] = [2]int64{c.slo.total[slo.Name], c.slo.bad[slo.Name]}
+	}
+	c.slo.mu.RUnlock()
+
+	statuses := make([]SLOStatus, 0, len(slos))
+	for _, slo := range slos {
+		status := SLOStatus{
+			SLO:       slo,
+			Total:     totals[slo.Name][0],
+			Bad:       totals[slo.Name][1],
+			BurnRates: make(map[string]float64, len(burnRateWindows)),
+		}
+		for _, window := range burnRateWindows {
+			status.BurnRates[promDuration(window)] = c.BurnRate(slo, window, now)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func (c *RunnerController) handleSLOStatus(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"slos": c.SLOStatuses(time.Now())})
+}
+
+func (c *RunnerController) handleSLORules(ctx *gin.Context) {
+	c.slo.mu.RLock()
+	slos := append([]SLO{}, c.slo.slos...)
+	c.slo.mu.RUnlock()
+
+	ctx.Data(http.StatusOK, "application/yaml; charset=utf-8", []byte(SLOAlertRules(slos)))
+}
+
+// handlePrometheusMetrics serves the SLO metrics and the main gauges in the
+// Prometheus text exposition format
+func (c *RunnerController) handlePrometheusMetrics(ctx *gin.Context) {
+	now := time.Now()
+	overview := c.Overview(time.Minute, now)
+
+	var b strings.Builder
+	writeMetric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	writeMetric("parity_runners_online", "gauge", "Runners with a recent heartbeat.")
+	fmt.Fprintf(&b, "parity_runners_online %d\n", overview.RunnersOnline)
+	writeMetric("parity_tasks_queued", "gauge", "Tasks waiting for a runner.")
+	fmt.Fprintf(&b, "parity_tasks_queued %d\n", overview.QueueDepth)
+
+	if gateways := c.gateways(); len(gateways) > 0 {
+		pending, failing := 0, 0
+		for _, gateway := range gateways {
+			pending += len(gateway.PendingPayouts())
+			failing += gateway.FailingPayouts()
+		}
+		writeMetric("parity_payouts_pending", "gauge", "Reward payouts queued for settlement.")
+		fmt.Fprintf(&b, "parity_payouts_pending %d\n", pending)
+		writeMetric("parity_payouts_failing", "gauge", "Queued payouts that reached the alert threshold of failed attempts.")
+		fmt.Fprintf(&b, "parity_payouts_failing %d\n", failing)
+	}
+
+	statuses := c.SLOStatuses(now)
+	writeMetric("parity_slo_objective", "gauge", "Target fraction of good events.")
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "parity_slo_objective{slo=%q} %s\n", status.Name, promFloat(status.Objective))
+	}
+	writeMetric("parity_slo_threshold_seconds", "gauge", "Latency under which an event counts as good.")
+	for _, status := range statuses {
+		if status.Threshold > 0 {
+			fmt.Fprintf(&b, "parity_slo_threshold_seconds{slo=%q} %s\n", status.Name, promFloat(status.Threshold.Seconds()))
+		}
+	}
+	writeMetric("parity_slo_events_total", "counter", "Events counted towards the SLO.")
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "parity_slo_events_total{slo=%q} %d\n", status.Name, status.Total)
+	}
+	writeMetric("parity_slo_bad_events_total", "counter", "Events that missed the SLO.")
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "parity_slo_bad_events_total{slo=%q} %d\n", status.Name, status.Bad)
+	}
+	writeMetric("parity_slo_burn_rate", "gauge", "Error rate over the window divided by the error budget.")
+	for _, status := range statuses {
+		for _, window := range burnRateWindows {
+			label := promDuration(window)
+			fmt.Fprintf(&b, "parity_slo_burn_rate{slo=%q,window=%q} %s\n", status.Name, label, promFloat(status.BurnRates[label]))
+		}
+	}
+
+	ctx.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
+
+// SLOAlertRules renders Prometheus alerting rules for the multiwindow burn-rate
+// alerts over parity_slo_burn_rate, plus an alert on payouts that keep failing
+func SLOAlertRules(slos []SLO) string {
+	var b strings.Builder
+	b.WriteString("groups:\n  - name: parity-slo\n    rules:\n")
+	for _, slo := range slos {
+		alert := sloAlertName(slo.Name)
+		for _, rule := range []struct {
+			suffix, severity, long, short string
+			factor                        float64
+		}{
+			{"FastBurn", "page", "1h", "5m", 14.4},
+			{"SlowBurn", "ticket", "6h", "30m", 6},
+		} {
+			fmt.Fprintf(&b, "      - alert: %s%s\n", alert, rule.suffix)
+			fmt.Fprintf(&b, "        expr: parity_slo_burn_rate{slo=%q,window=%q} > %s and parity_slo_burn_rate{slo=%q,window=%q} > %s\n",
+				slo.Name, rule.long, promFloat(rule.factor), slo.Name, rule.short, promFloat(rule.factor))
+			fmt.Fprintf(&b, "        for: 2m\n        labels:\n          severity: %s\n", rule.severity)
+			fmt.Fprintf(&b, "        annotations:\n          summary: %q\n",
+				fmt.Sprintf("%s is burning its error budget %sx faster than sustainable (objective %s)", slo.Name, promFloat(rule.factor), promFloat(slo.Objective)))
+		}
+	}
+	b.WriteString("      - alert: ParityPayoutsFailing\n")
+	b.WriteString("        expr: parity_payouts_failing > 0\n")
+	b.WriteString("        for: 10m\n        labels:\n          severity: page\n")
+	b.WriteString("        annotations:\n          summary: \"Reward payouts keep failing and runners are not being paid\"\n")
+	return b.String()
+}
+
+// sloAlertName turns assignment_latency into ParityAssignmentLatency
+func sloAlertName(name string) string {
+	var b strings.Builder
+	b.WriteString("Parity")
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// promDuration formats a window like Prometheus does, e.g. 5m or 6h
+func promDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
+
+func promFloat(value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "0"
+	}
+	return fmt.Sprintf("%g", value)
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestSLOBurnRateAndPrometheusExport(t *testing.T) {
+	slos := SLOsFromConfig(config.SLOConfig{
+		AssignmentLatency:   time.Minute,
+		AssignmentObjective: 0.9,
+	})
+	controller := NewRunnerController(nil)
+	controller.SetSLOs(slos)
+
+	now := time.Now()
+	controller.recordAssignment(&models.Task{CreatedAt: now.Add(-10 * time.Second)}, now)
+	controller.recordAssignment(&models.Task{CreatedAt: now.Add(-5 * time.Minute)}, now)
+
+	// One bad event out of two is a 50% error rate against a 10% budget
+	if burn := controller.BurnRate(slos[0], 5*time.Minute, now); burn < 4.99 || burn > 5.01 {
+		t.Fatalf("BurnRate() = %v, want 5", burn)
+	}
+
+	router := newTestRouter(controller)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/metrics status = %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`parity_slo_events_total{slo="assignment_latency"} 2`,
+		`parity_slo_bad_events_total{slo="assignment_latency"} 1`,
+		`parity_slo_burn_rate{slo="assignment_latency",window="5m"} 5`,
+		`parity_slo_objective{slo="completion_rate"} 0.95`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("/metrics is missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestSLOAlertRules(t *testing.T) {
+	rules := SLOAlertRules(SLOsFromConfig(config.SLOConfig{}))
+	for _, want := range []string{
+		"alert: ParityPayoutLatencyFastBurn",
+		`parity_slo_burn_rate{slo="completion_rate",window="6h"} > 6`,
+		"severity: page",
+	} {
+		if !strings.Contains(rules, want) {
+			t.Fatalf("rules are missing %q:\n%s", want, rules)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// StakeSizeClass scales the stake needed for tasks expected to run at most
+// MaxDuration
+type StakeSizeClass struct {
+	MaxDuration time.Duration
+	Multiplier  float64
+}
+
+// StakePolicy scales the stake a runner needs to start a task with the task's
+// class and the runner's reputation. The requirement is the minimum stake plus
+// RewardMultiplier times the task's reward, multiplied by the multipliers of
+// the task's type, its size class and the runner's reputation tier. Missing
+// multipliers count as 1.
+type StakePolicy struct {
+	RewardMultiplier float64
+	TypeMultipliers  map[models.TaskType]float64
+	// SizeClasses are matched in order against the task's estimated duration.
+	// Tasks without an estimate, or longer than every class, use the last one.
+	SizeClasses     []StakeSizeClass
+	TierMultipliers map[models.ReputationTier]float64
+}
+
+// StakePolicyFromConfig parses the SERVER_STAKE_POLICY_* settings. It returns
+// nil when none is set, leaving the flat minimum stake in place.
+func StakePolicyFromConfig(cfg config.StakePolicyConfig) (*StakePolicy, error) {
+	if cfg.RewardMultiplier == 0 && cfg.Types == "" && cfg.Sizes == "" && cfg.Tiers == "" {
+		return nil, nil
+	}
+	policy := &StakePolicy{
+		RewardMultiplier: cfg.RewardMultiplier,
+		TypeMultipliers:  make(map[models.TaskType]float64),
+		TierMultipliers:  make(map[models.ReputationTier]float64),
+	}
+
+	err := parseMultiplierList(cfg.Types, func(name string, multiplier float64) error {
+		policy.TypeMultipliers[models.TaskType(name)] = multiplier
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid task type multipliers: %w", err)
+	}
+	err = parseMultiplierList(cfg.Tiers, func(name string, multiplier float64) error {
+		policy.TierMultipliers[models.ReputationTier(name)] = multiplier
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid reputation tier multipliers: %w", err)
+	}
+	err = parseMultiplierList(cfg.Sizes, func(name string, multiplier float64) error {
+		limit, err := time.ParseDuration(name)
+		if err != nil || limit <= 0 {
+			return fmt.Errorf("invalid duration %q", name)
+		}
+		policy.SizeClasses = append(policy.SizeClasses, StakeSizeClass{MaxDuration: limit, Multiplier: multiplier})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid size multipliers: %w", err)
+	}
+	return policy, nil
+}
+
+// parseMultiplierList calls add for each entry of "llm=0.5,docker=2", in order
+func parseMultiplierList(spec string, add func(name string, multiplier float64) error) error {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		multiplier, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || name == "" || err != nil {
+			return fmt.Errorf("expected name=multiplier, got %q", entry)
+		}
+		if err := add(name, multiplier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetStakePolicy replaces the flat minimum stake of SetChainGateway with one
+// that depends on the task and the runner
+func (c *RunnerController) SetStakePolicy(policy StakePolicy) error {
+	if policy.RewardMultiplier < 0 {
+		return fmt.Errorf("reward multiplier must not be negative")
+	}
+	for taskType, multiplier := range policy.TypeMultipliers {
+		if multiplier <= 0 {
+			return fmt.Errorf("stake multiplier of task type %s must be positive", taskType)
+		}
+	}
+	for tier, multiplier := range policy.TierMultipliers {
+		if multiplier <= 0 {
+			return fmt.Errorf("stake multiplier of reputation tier %s must be positive", tier)
+		}
+	}
+	for i, class := range policy.SizeClasses {
+		if class.Multiplier <= 0 {
+			return fmt.Errorf("stake multiplier of size class %d must be positive", i+1)
+		}
+		if i > 0 && class.MaxDuration <= policy.SizeClasses[i-1].MaxDuration {
+			return fmt.Errorf("stake size classes must be ordered by increasing duration")
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stakePolicy = &policy
+	return nil
+}
+
+// requiredStakeLocked is the stake deviceID needs to start task, or nil when no
+// stake is required. task is nil when it is not queued, in which case only the
+// minimum stake and the runner's tier apply.
+func (c *RunnerController) requiredStakeLocked(task *models.Task, deviceID string) *big.Int {
+	policy := c.stakePolicy
+	if policy == nil {
+		if c.minStake == nil || c.minStake.Sign() <= 0 {
+			return nil
+		}
+		return new(big.Int).Set(c.minStake)
+	}
+
+	required := new(big.Float)
+	if c.minStake != nil {
+		required.SetInt(c.minStake)
+	}
+	reputation := c.reputationsLocked()[deviceID]
+	reputation.Quarantined = c.quarantine != nil && c.quarantine.IsQuarantined(deviceID)
+	multiplier := multiplierOr(policy.TierMultipliers[reputation.Tier()])
+
+	if task != nil {
+		required.Add(required, new(big.Float).SetInt(rewardWei(task.Reward*policy.RewardMultiplier)))
+		multiplier *= multiplierOr(policy.TypeMultipliers[task.Type]) * policy.sizeMultiplier(task.EstimatedDuration())
+	}
+
+	stake, _ := required.Mul(required, big.NewFloat(multiplier)).Int(nil)
+	if stake.Sign() <= 0 {
+		return nil
+	}
+	return stake
+}
+
+func (p *StakePolicy) sizeMultiplier(estimate time.Duration) float64 {
+	if len(p.SizeClasses) == 0 {
+		return 1
+	}
+	if estimate > 0 {
+		for _, class := range p.SizeClasses {
+			if estimate <= class.MaxDuration {
+				return class.Multiplier
+			}
+		}
+	}
+	return p.SizeClasses[len(p.SizeClasses)-1].Multiplier
+}
+
+func multiplierOr(multiplier float64) float64 {
+	if multiplier <= 0 {
+		return 1
+	}
+	return multiplier
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestStakePolicyScalesWithTaskClassAndReputation(t *testing.T) {
+	token := big.NewInt(1e18)
+	chain := &fakeChain{stakes: map[string]*big.Int{"device-1": new(big.Int).Mul(big.NewInt(10), token)}}
+	controller := NewRunnerController(nil)
+	controller.SetChainGateway(NewChainGateway(chain, ChainGatewayConfig{}), token)
+	if err := controller.SetStakePolicy(StakePolicy{
+		SizeClasses: []StakeSizeClass{{MaxDuration: time.Hour, Multiplier: 1}, {MaxDuration: 10 * time.Minute, Multiplier: 2}},
+	}); err == nil {
+		t.Fatal("SetStakePolicy() accepted size classes out of order")
+	}
+	if err := controller.SetStakePolicy(StakePolicy{
+		RewardMultiplier: 2,
+		TypeMultipliers:  map[models.TaskType]float64{models.TaskTypeDocker: 3},
+		SizeClasses:      []StakeSizeClass{{MaxDuration: 10 * time.Minute, Multiplier: 1}, {MaxDuration: 24 * time.Hour, Multiplier: 4}},
+		TierMultipliers:  map[models.ReputationTier]float64{models.ReputationTierNew: 2},
+	}); err != nil {
+		t.Fatalf("SetStakePolicy() error = %v", err)
+	}
+	router := newTestRouter(controller)
+
+	start := func(task *models.Task) int {
+		controller.AddAvailableTask(task)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+task.ID.String()+"/start", nil)
+		req.Header.Set("X-Device-ID", "device-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A one minute prompt needs (1 + 2 × 0.5) tokens, doubled for a new runner
+	prompt := models.NewTask()
+	prompt.Type = models.TaskTypeLLM
+	prompt.Reward = 0.5
+	prompt.MaxDurationSecs = 60
+	if code := start(prompt); code != http.StatusOK {
+		t.Fatalf("start of a short prompt = %d, want %d", code, http.StatusOK)
+	}
+	if prompt.RequiredStake != "4000000000000000000" {
+		t.Fatalf("RequiredStake = %q, want 4 tokens in wei", prompt.RequiredStake)
+	}
+
+	// A six hour training job needs (1 + 2 × 1) × 3 × 4 × 2 = 72 tokens
+	training := models.NewTask()
+	training.Type = models.TaskTypeDocker
+	training.Reward = 1
+	training.MaxDurationSecs = int64((6 * time.Hour).Seconds())
+	if code := start(training); code != http.StatusForbidden {
+		t.Fatalf("start of a long training job with 10 tokens staked = %d, want %d", code, http.StatusForbidden)
+	}
+
+	// Without an estimate a task falls in the largest size class
+	unbounded := models.NewTask()
+	unbounded.Type = models.TaskTypeLLM
+	controller.mu.RLock()
+	required := controller.requiredStakeLocked(unbounded, "device-1")
+	controller.mu.RUnlock()
+	if want := new(big.Int).Mul(big.NewInt(8), token); required.Cmp(want) != 0 {
+		t.Fatalf("required stake of a task without estimate = %v, want %v", required, want)
+	}
+}
+
+func TestStakePolicyFromConfig(t *testing.T) {
+	if policy, err := StakePolicyFromConfig(config.StakePolicyConfig{}); err != nil || policy != nil {
+		t.Fatalf("StakePolicyFromConfig() without settings = %+v, %v; want no policy", policy, err)
+	}
+
+	policy, err := StakePolicyFromConfig(config.StakePolicyConfig{
+		RewardMultiplier: 2,
+		Types:            "llm=0.5, docker=2",
+		Sizes:            "10m=1,1h=2,6h=4",
+		Tiers:            "trusted=0.5",
+	})
+	if err != nil {
+		t.Fatalf("StakePolicyFromConfig() error = %v", err)
+	}
+	if policy.RewardMultiplier != 2 || policy.TypeMultipliers[models.TaskTypeLLM] != 0.5 || policy.TypeMultipliers[models.TaskTypeDocker] != 2 {
+		t.Fatalf("policy = %+v", policy)
+	}
+	if len(policy.SizeClasses) != 3 || policy.SizeClasses[1] != (StakeSizeClass{MaxDuration: time.Hour, Multiplier: 2}) {
+		t.Fatalf("size classes = %+v, want them in the configured order", policy.SizeClasses)
+	}
+	if policy.TierMultipliers[models.ReputationTierTrusted] != 0.5 {
+		t.Fatalf("tier multipliers = %+v", policy.TierMultipliers)
+	}
+
+	for _, cfg := range []config.StakePolicyConfig{{Types: "llm"}, {Sizes: "soon=2"}, {Tiers: "trusted=lots"}} {
+		if _, err := StakePolicyFromConfig(cfg); err == nil {
+			t.Fatalf("StakePolicyFromConfig(%+v) accepted an invalid list", cfg)
+		}
+	}
+}
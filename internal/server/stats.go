@@ -0,0 +1,150 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+const (
+	SeriesRunnersOnline     = "runners.online"
+	SeriesRunnerCPUUsage    = "runners.cpu_usage"
+	SeriesRunnerMemoryUsage = "runners.memory_usage"
+	SeriesQueueDepth        = "tasks.queue_depth"
+	SeriesTasksCompleted    = "tasks.completed"
+	SeriesTasksFailed       = "tasks.failed"
+	SeriesAssignmentLatency = "tasks.assignment_latency_ms"
+
+	defaultStatsWindow = time.Hour
+	// runnerHeartbeatTimeout is how long a runner counts as online after its last heartbeat
+	runnerHeartbeatTimeout = 90 * time.Second
+)
+
+// StatsOverview summarises supply and demand over a window for capacity dashboards
+// and the pricing model
+type StatsOverview struct {
+	Window                 string              `json:"window"`
+	Resolution             string              `json:"resolution"`
+	RunnersOnline          int                 `json:"runners_online"`
+	QueueDepth             int                 `json:"queue_depth"`
+	TasksCompleted         int64               `json:"tasks_completed"`
+	TasksFailed            int64               `json:"tasks_failed"`
+	ThroughputPerHour      float64             `json:"throughput_per_hour"`
+	AvgAssignmentLatencyMs float64             `json:"avg_assignment_latency_ms"`
+	MaxAssignmentLatencyMs float64             `json:"max_assignment_latency_ms"`
+	AvgRunnerCPUUsage      float64             `json:"avg_runner_cpu_usage"`
+	AvgRunnerMemoryUsage   float64             `json:"avg_runner_memory_usage"`
+	SupplyDemandRatio      float64             `json:"supply_demand_ratio"`
+	Series                 map[string][]Bucket `json:"series"`
+}
+
+func (c *RunnerController) recordHeartbeat(deviceID string, payload gin.H, at time.Time) {
+	c.mu.Lock()
+	c.lastHeartbeat[deviceID] = at
+	online := 0
+	for id, seen := range c.lastHeartbeat {
+		if at.Sub(seen) > runnerHeartbeatTimeout {
+			delete(c.lastHeartbeat, id)
+			continue
+		}
+		online++
+	}
+	c.mu.Unlock()
+
+	c.stats.Record(SeriesRunnersOnline, float64(online), at)
+	if cpu, ok := payload["cpu_usage"].(float64); ok {
+		c.stats.Record(SeriesRunnerCPUUsage, cpu, at)
+	}
+	if memory, ok := payload["memory_usage"].(float64); ok {
+		c.stats.Record(SeriesRunnerMemoryUsage, memory, at)
+	}
+}
+
+func (c *RunnerController) recordQueueDepth(depth int) {
+	c.stats.Record(SeriesQueueDepth, float64(depth), time.Now())
+}
+
+func (c *RunnerController) recordAssignment(task *models.Task, at time.Time) {
+	if task == nil || task.CreatedAt.IsZero() {
+		return
+	}
+	c.stats.Record(SeriesAssignmentLatency, float64(at.Sub(task.CreatedAt).Milliseconds()), at)
+	c.recordSLOLatency(SLOAssignmentLatency, at.Sub(task.CreatedAt), at)
+}
+
+func (c *RunnerController) recordResult(result *models.TaskResult, at time.Time) {
+	if result.ExitCode != 0 || result.Error != "" {
+		c.stats.Record(SeriesTasksFailed, 1, at)
+		c.recordSLOEvent(SLOCompletionRate, false, at)
+		return
+	}
+	c.stats.Record(SeriesTasksCompleted, 1, at)
+	c.recordSLOEvent(SLOCompletionRate, true, at)
+}
+
+// Stats exposes the time-series store, e.g. for exporting to an external TSDB
+func (c *RunnerController) Stats() *TimeSeriesStore {
+	return c.stats
+}
+
+func (c *RunnerController) Overview(window time.Duration, now time.Time) StatsOverview {
+	from := now.Add(-window)
+
+	c.mu.RLock()
+	queueDepth := len(c.availableTasks)
+	online := 0
+	for _, seen := range c.lastHeartbeat {
+		if now.Sub(seen) <= runnerHeartbeatTimeout {
+			online++
+		}
+	}
+	c.mu.RUnlock()
+
+	completed := c.stats.Summarize(SeriesTasksCompleted, from, now)
+	failed := c.stats.Summarize(SeriesTasksFailed, from, now)
+	latency := c.stats.Summarize(SeriesAssignmentLatency, from, now)
+
+	overview := StatsOverview{
+		Window:                 window.String(),
+		Resolution:             c.stats.Resolution().String(),
+		RunnersOnline:          online,
+		QueueDepth:             queueDepth,
+		TasksCompleted:         completed.Count,
+		TasksFailed:            failed.Count,
+		ThroughputPerHour:      float64(completed.Count+failed.Count) / window.Hours(),
+		AvgAssignmentLatencyMs: latency.Avg(),
+		MaxAssignmentLatencyMs: latency.Max,
+		AvgRunnerCPUUsage:      c.stats.Summarize(SeriesRunnerCPUUsage, from, now).Avg(),
+		AvgRunnerMemoryUsage:   c.stats.Summarize(SeriesRunnerMemoryUsage, from, now).Avg(),
+		Series:                 make(map[string][]Bucket),
+	}
+
+	// Supply is the number of online runners, demand the tasks waiting for one
+	overview.SupplyDemandRatio = float64(online)
+	if queueDepth > 0 {
+		overview.SupplyDemandRatio = float64(online) / float64(queueDepth)
+	}
+
+	for _, name := range c.stats.Names() {
+		overview.Series[name] = c.stats.Range(name, from, now)
+	}
+
+	return overview
+}
+
+func (c *RunnerController) handleStatsOverview(ctx *gin.Context) {
+	window := defaultStatsWindow
+	if raw := ctx.Query("window"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window duration"})
+			return
+		}
+		window = parsed
+	}
+
+	ctx.JSON(http.StatusOK, c.Overview(window, time.Now()))
+}
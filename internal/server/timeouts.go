@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// timeoutGrace is how long past its maximum duration a running task is given to
+// report before the server fails it, covering image pulls and result upload
+const timeoutGrace = 2 * time.Minute
+
+var ErrTimeoutExceedsPolicy = errors.New("requested timeout exceeds the policy limit")
+
+// TimeoutPolicy caps how long tasks may run. The namespace limit takes precedence
+// over the type limit, which takes precedence over Default. A zero limit means
+// the task is not limited.
+type TimeoutPolicy struct {
+	Default    time.Duration                     `json:"default,omitempty"`
+	Types      map[models.TaskType]time.Duration `json:"types,omitempty"`
+	Namespaces map[string]time.Duration          `json:"namespaces,omitempty"`
+}
+
+func TimeoutPolicyFromConfig(cfg config.TaskTimeoutConfig) (TimeoutPolicy, error) {
+	policy := TimeoutPolicy{
+		Default:    cfg.Default,
+		Types:      make(map[models.TaskType]time.Duration),
+		Namespaces: make(map[string]time.Duration),
+	}
+
+	types, err := parseDurationList(cfg.Types)
+	if err != nil {
+		return TimeoutPolicy{}, fmt.Errorf("invalid task type timeouts: %w", err)
+	}
+	for taskType, limit := range types {
+		policy.Types[models.TaskType(taskType)] = limit
+	}
+
+	if policy.Namespaces, err = parseDurationList(cfg.Namespaces); err != nil {
+		return TimeoutPolicy{}, fmt.Errorf("invalid namespace timeouts: %w", err)
+	}
+	return policy, nil
+}
+
+// parseDurationList parses "docker=1h,llm=10m" into a map
+func parseDurationList(spec string) (map[string]time.Duration, error) {
+	limits := make(map[string]time.Duration)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected name=duration, got %q", entry)
+		}
+		limit, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid duration for %s: %q", key, value)
+		}
+		limits[key] = limit
+	}
+	return limits, nil
+}
+
+// Limit is the maximum duration that applies to the task, or 0 if none does
+func (p TimeoutPolicy) Limit(task *models.Task) time.Duration {
+	if namespace := task.Namespace(); namespace != "" {
+		if limit, ok := p.Namespaces[namespace]; ok {
+			return limit
+		}
+	}
+	if limit, ok := p.Types[task.Type]; ok {
+		return limit
+	}
+	return p.Default
+}
+
+// Apply validates the timeout a task asks for in its resources against the
+// policy and stores the effective maximum duration on the task. Tasks that do
+// not ask for a timeout get the policy limit.
+func (p TimeoutPolicy) Apply(task *models.Task) error {
+	requested, err := requestedTimeout(task)
+	if err != nil {
+		return err
+	}
+
+	limit := p.Limit(task)
+	if limit > 0 && requested > limit {
+		return fmt.Errorf("%w: %s is longer than the %s allowed for %s", ErrTimeoutExceedsPolicy, requested, limit, timeoutScope(task, p))
+	}
+
+	effective := requested
+	if effective == 0 {
+		effective = limit
+	}
+	task.MaxDurationSecs = int64((effective + time.Second - 1) / time.Second)
+	return nil
+}
+
+func requestedTimeout(task *models.Task) (time.Duration, error) {
+	var config models.TaskConfig
+	if len(task.Config) == 0 || json.Unmarshal(task.Config, &config) != nil || config.Resources.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(config.Resources.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", config.Resources.Timeout)
+	}
+	return timeout, nil
+}
+
+func timeoutScope(task *models.Task, p TimeoutPolicy) string {
+	if namespace := task.Namespace(); namespace != "" {
+		if _, ok := p.Namespaces[namespace]; ok {
+			return "namespace " + namespace
+		}
+	}
+	if _, ok := p.Types[task.Type]; ok {
+		return string(task.Type) + " tasks"
+	}
+	return "tasks"
+}
+
+// SetTimeoutPolicy replaces the policy applied to newly submitted tasks
+func (c *RunnerController) SetTimeoutPolicy(policy TimeoutPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timeouts = policy
+}
+
+func (c *RunnerController) TimeoutPolicy() TimeoutPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.timeouts
+}
+
+func (c *RunnerController) handleTimeoutPolicy(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.TimeoutPolicy())
+}
+
+// ExpireOverdueTasks fails running tasks whose runner has not reported within
+// their maximum duration plus a grace period. It returns the expired task IDs.
+func (c *RunnerController) ExpireOverdueTasks(now time.Time) []string {
+	log := gologger.WithComponent("runner_controller")
+
+	c.mu.Lock()
+	var expired []*models.TaskResult
+	for taskID, assigned := range c.assigned {
+		limit := assigned.task.MaxDuration()
+		if limit <= 0 || now.Sub(assigned.startedAt) <= limit+timeoutGrace {
+			continue
+		}
+		delete(c.assigned, taskID)
+		c.expired[taskID] = true
+
+		result := &models.TaskResult{
+			TaskID:   assigned.task.ID,
+			DeviceID: assigned.deviceID,
+			ExitCode: -1,
+			Error:    fmt.Sprintf("task exceeded its maximum duration of %s", limit),
+		}
+		c.results[taskID] = result
+		expired = append(expired, result)
+	}
+	c.mu.Unlock()
+
+	taskIDs := make([]string, 0, len(expired))
+	for _, result := range expired {
+		c.recordResult(result, now)
+		c.resolveArrayIndex(result)
+		taskIDs = append(taskIDs, result.TaskID.String())
+		log.Warn().
+			Str("task_id", result.TaskID.String()).
+			Str("device_id", result.DeviceID).
+			Msg("Task exceeded its maximum duration")
+	}
+	return taskIDs
+}
+
+func (c *RunnerController) isExpired(taskID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.expired[taskID]
+}
+
+// RunTimeoutReaper expires overdue tasks every interval until ctx is cancelled
+func (c *RunnerController) RunTimeoutReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.ExpireOverdueTasks(now)
+		}
+	}
+}
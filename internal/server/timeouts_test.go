@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestTimeoutPolicyFromConfig(t *testing.T) {
+	policy, err := TimeoutPolicyFromConfig(config.TaskTimeoutConfig{
+		Default:    30 * time.Minute,
+		Types:      "docker=1h, llm=10m",
+		Namespaces: "research=6h",
+	})
+	if err != nil {
+		t.Fatalf("TimeoutPolicyFromConfig() error = %v", err)
+	}
+
+	docker := &models.Task{Type: models.TaskTypeDocker}
+	research := &models.Task{Type: models.TaskTypeDocker, Labels: models.Labels{models.NamespaceLabel: "research"}}
+	command := &models.Task{Type: models.TaskTypeCommand}
+	for task, want := range map[*models.Task]time.Duration{docker: time.Hour, research: 6 * time.Hour, command: 30 * time.Minute} {
+		if got := policy.Limit(task); got != want {
+			t.Errorf("Limit(%s, %q) = %s, want %s", task.Type, task.Namespace(), got, want)
+		}
+	}
+
+	for _, spec := range []string{"docker", "docker=soon", "=1h", "docker=-1m"} {
+		if _, err := TimeoutPolicyFromConfig(config.TaskTimeoutConfig{Types: spec}); err == nil {
+			t.Errorf("TimeoutPolicyFromConfig(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestTimeoutPolicyApply(t *testing.T) {
+	policy := TimeoutPolicy{Types: map[models.TaskType]time.Duration{models.TaskTypeCommand: 10 * time.Minute}}
+
+	task := &models.Task{Type: models.TaskTypeCommand, Config: json.RawMessage(`{"command":"echo"}`)}
+	if err := policy.Apply(task); err != nil || task.MaxDuration() != 10*time.Minute {
+		t.Fatalf("Apply() without a request = %v, max duration %s", err, task.MaxDuration())
+	}
+
+	task.Config = json.RawMessage(`{"resources":{"timeout":"90s"}}`)
+	if err := policy.Apply(task); err != nil || task.MaxDuration() != 90*time.Second {
+		t.Fatalf("Apply() within the limit = %v, max duration %s", err, task.MaxDuration())
+	}
+
+	task.Config = json.RawMessage(`{"resources":{"timeout":"2h"}}`)
+	if err := policy.Apply(task); !errors.Is(err, ErrTimeoutExceedsPolicy) {
+		t.Fatalf("Apply() above the limit error = %v, want ErrTimeoutExceedsPolicy", err)
+	}
+
+	task.Config = json.RawMessage(`{"resources":{"timeout":"whenever"}}`)
+	if err := policy.Apply(task); err == nil || errors.Is(err, ErrTimeoutExceedsPolicy) {
+		t.Fatalf("Apply() with an invalid timeout error = %v", err)
+	}
+}
+
+func TestCreateTaskEnforcesTimeoutPolicy(t *testing.T) {
+	controller := NewRunnerController(nil)
+	controller.SetTimeoutPolicy(TimeoutPolicy{Default: 5 * time.Minute})
+	router := newTestRouter(controller)
+
+	create := func(timeout string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"title":  "job",
+			"type":   "command",
+			"config": map[string]interface{}{"command": "echo", "resources": map[string]string{"timeout": timeout}},
+		})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(body)))
+		return rec
+	}
+
+	if rec := create("1h"); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("create above the limit status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	rec := create("2m")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create within the limit status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var task models.Task
+	if err := json.Unmarshal(rec.Body.Bytes(), &task); err != nil {
+		t.Fatalf("failed to decode task: %v", err)
+	}
+	if task.MaxDurationSecs != 120 {
+		t.Fatalf("max_duration_seconds = %d, want 120", task.MaxDurationSecs)
+	}
+}
+
+func TestExpireOverdueTasksRejectsLateResult(t *testing.T) {
+	controller := NewRunnerController(nil)
+	router := newTestRouter(controller)
+
+	task := models.NewTask()
+	task.MaxDurationSecs = 60
+	controller.AddAvailableTask(task)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+task.ID.String()+"/start", nil)
+	req.Header.Set("X-Device-ID", "device-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if expired := controller.ExpireOverdueTasks(time.Now().Add(time.Minute)); len(expired) != 0 {
+		t.Fatalf("ExpireOverdueTasks() within the grace period = %v", expired)
+	}
+	expired := controller.ExpireOverdueTasks(time.Now().Add(time.Minute + timeoutGrace + time.Second))
+	if len(expired) != 1 || expired[0] != task.ID.String() {
+		t.Fatalf("ExpireOverdueTasks() = %v, want [%s]", expired, task.ID)
+	}
+
+	result, ok := controller.GetTaskResult(task.ID.String())
+	if !ok || result.ExitCode != -1 || result.Error == "" {
+		t.Fatalf("expired task result = %+v", result)
+	}
+
+	body, _ := json.Marshal(models.TaskResult{TaskID: task.ID, Output: "late"})
+	late := httptest.NewRequest(http.MethodPost, "/api/v1/runners/tasks/"+task.ID.String()+"/result", bytes.NewReader(body))
+	late.Header.Set("X-Device-ID", "device-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, late)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("late result status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
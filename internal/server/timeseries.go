@@ -0,0 +1,132 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bucket aggregates every sample recorded for a series within one resolution step
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+	Sum   float64   `json:"sum"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Last  float64   `json:"last"`
+}
+
+func (b Bucket) Avg() float64 {
+	if b.Count == 0 {
+		return 0
+	}
+	return b.Sum / float64(b.Count)
+}
+
+// TimeSeriesStore is a small in-memory TSDB. Samples are rolled up into fixed
+// resolution buckets and buckets older than the retention are dropped on write.
+type TimeSeriesStore struct {
+	resolution time.Duration
+	retention  time.Duration
+	series     map[string][]Bucket
+	mu         sync.RWMutex
+}
+
+func NewTimeSeriesStore(resolution, retention time.Duration) *TimeSeriesStore {
+	if resolution <= 0 {
+		resolution = time.Minute
+	}
+	if retention < resolution {
+		retention = 24 * time.Hour
+	}
+	return &TimeSeriesStore{
+		resolution: resolution,
+		retention:  retention,
+		series:     make(map[string][]Bucket),
+	}
+}
+
+func (s *TimeSeriesStore) Resolution() time.Duration {
+	return s.resolution
+}
+
+// Record adds a sample to a series
+func (s *TimeSeriesStore) Record(name string, value float64, at time.Time) {
+	start := at.Truncate(s.resolution)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets := s.series[name]
+	idx := sort.Search(len(buckets), func(i int) bool { return !buckets[i].Start.Before(start) })
+	if idx == len(buckets) || !buckets[idx].Start.Equal(start) {
+		buckets = append(buckets, Bucket{})
+		copy(buckets[idx+1:], buckets[idx:])
+		buckets[idx] = Bucket{Start: start, Min: value, Max: value}
+	}
+
+	bucket := &buckets[idx]
+	bucket.Count++
+	bucket.Sum += value
+	bucket.Last = value
+	if value < bucket.Min {
+		bucket.Min = value
+	}
+	if value > bucket.Max {
+		bucket.Max = value
+	}
+
+	cutoff := at.Add(-s.retention)
+	drop := 0
+	for drop < len(buckets) && buckets[drop].Start.Before(cutoff) {
+		drop++
+	}
+	s.series[name] = buckets[drop:]
+}
+
+// Range returns the buckets of a series that start within [from, to]
+func (s *TimeSeriesStore) Range(name string, from, to time.Time) []Bucket {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Bucket, 0)
+	for _, bucket := range s.series[name] {
+		if bucket.Start.Before(from.Truncate(s.resolution)) || bucket.Start.After(to) {
+			continue
+		}
+		result = append(result, bucket)
+	}
+	return result
+}
+
+// Summarize folds the buckets of a series within [from, to] into a single bucket
+func (s *TimeSeriesStore) Summarize(name string, from, to time.Time) Bucket {
+	var summary Bucket
+	for i, bucket := range s.Range(name, from, to) {
+		if i == 0 {
+			summary = Bucket{Start: bucket.Start, Min: bucket.Min, Max: bucket.Max}
+		}
+		summary.Count += bucket.Count
+		summary.Sum += bucket.Sum
+		summary.Last = bucket.Last
+		if bucket.Min < summary.Min {
+			summary.Min = bucket.Min
+		}
+		if bucket.Max > summary.Max {
+			summary.Max = bucket.Max
+		}
+	}
+	return summary
+}
+
+func (s *TimeSeriesStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.series))
+	for name := range s.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,225 @@
+// Package signer signs for the runner's wallet without the runner necessarily
+// holding its key. A Signer is a local key, a Ledger or Trezor reached through
+// go-ethereum's usbwallet, or a remote signer such as Clef.
+//
+// Receipts, manifests and reports are signed over the keccak256 digest of their
+// payload. Local keys and remote signers sign the digest as an EIP-191 text
+// message. Hardware wallets only sign typed data, so they sign the EIP-712
+// message TypedData(digest) instead; Verify accepts either.
+package signer
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Types of signer a runner can be configured with
+const (
+	TypeLedger = "ledger"
+	TypeTrezor = "trezor"
+	TypeClef   = "clef"
+)
+
+// DefaultPath is the derivation path of the first Ethereum account of a
+// hardware wallet
+const DefaultPath = "m/44'/60'/0'/0/0"
+
+// ErrDigestNotSupported is returned by signers that can only sign transactions
+var ErrDigestNotSupported = errors.New("signer cannot sign messages, only transactions")
+
+// Signer signs for one wallet address
+type Signer interface {
+	Address() common.Address
+	// SignDigest signs the keccak256 digest of a payload, returning a 65 byte
+	// [R || S || V] signature with V 0 or 1
+	SignDigest(digest []byte) ([]byte, error)
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// Config is the signer `parity-runner auth` set up instead of storing a key
+type Config struct {
+	Type string `json:"type"`
+	// URL is the endpoint of a remote signer, an IPC path or an http(s) URL
+	URL string `json:"url,omitempty"`
+	// Path is the derivation path of the account on a hardware wallet
+	Path    string `json:"path,omitempty"`
+	Address string `json:"address"`
+}
+
+// Validate checks the type and, for a remote signer, the endpoint
+func (c Config) Validate() error {
+	switch c.Type {
+	case TypeLedger, TypeTrezor:
+		if c.Path != "" {
+			if _, err := accounts.ParseDerivationPath(c.Path); err != nil {
+				return fmt.Errorf("invalid derivation path %q: %w", c.Path, err)
+			}
+		}
+	case TypeClef:
+		if c.URL == "" {
+			return fmt.Errorf("remote signer URL is required")
+		}
+	default:
+		return fmt.Errorf("unknown signer %q, expected %q, %q or %q", c.Type, TypeLedger, TypeTrezor, TypeClef)
+	}
+	if c.Address != "" && !common.IsHexAddress(c.Address) {
+		return fmt.Errorf("invalid signer address %q", c.Address)
+	}
+	return nil
+}
+
+// String names the signer for logs
+func (c Config) String() string {
+	switch c.Type {
+	case TypeClef:
+		return fmt.Sprintf("%s at %s", c.Type, c.URL)
+	default:
+		return strings.TrimSpace(fmt.Sprintf("%s %s", c.Type, c.path()))
+	}
+}
+
+func (c Config) path() string {
+	if c.Path == "" {
+		return DefaultPath
+	}
+	return c.Path
+}
+
+// LoadConfig reads the signer config at path, returning nil when there is none
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signer config: %w", err)
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse signer config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// SaveConfig writes the signer config to path
+func SaveConfig(path string, config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal signer config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create signer config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write signer config: %w", err)
+	}
+	return nil
+}
+
+// keySigner signs with a key the runner holds
+type keySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// FromKey signs with key
+func FromKey(key *ecdsa.PrivateKey) Signer {
+	return keySigner{key: key}
+}
+
+func (s keySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.key.PublicKey)
+}
+
+func (s keySigner) SignDigest(digest []byte) ([]byte, error) {
+	return crypto.Sign(accounts.TextHash(digest), s.key)
+}
+
+func (s keySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+// typedDataDomain separates runner signatures from other EIP-712 messages
+var typedDataDomain = crypto.Keccak256(
+	crypto.Keccak256([]byte("EIP712Domain(string name,string version)")),
+	crypto.Keccak256([]byte("Parity Runner")),
+	crypto.Keccak256([]byte("1")),
+)
+
+var payloadTypeHash = crypto.Keccak256([]byte("Payload(bytes32 digest)"))
+
+// TypedData is the EIP-712 encoding of Payload{digest} in the Parity Runner
+// domain: 0x19 0x01, the domain separator and the struct hash
+func TypedData(digest []byte) []byte {
+	data := []byte{0x19, 0x01}
+	data = append(data, typedDataDomain...)
+	return append(data, crypto.Keccak256(payloadTypeHash, common.LeftPadBytes(digest, 32))...)
+}
+
+// Recover returns the addresses a signature of digest may come from: the
+// signer of the EIP-191 message and the signer of the EIP-712 message
+func Recover(digest, signature []byte) ([]common.Address, error) {
+	if len(signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("signature must be %d bytes", crypto.SignatureLength)
+	}
+	signature = normalize(signature)
+
+	var signers []common.Address
+	for _, hash := range [][]byte{accounts.TextHash(digest), crypto.Keccak256(TypedData(digest))} {
+		publicKey, err := crypto.SigToPub(hash, signature)
+		if err != nil {
+			return nil, fmt.Errorf("failed to recover signer: %w", err)
+		}
+		signers = append(signers, crypto.PubkeyToAddress(*publicKey))
+	}
+	return signers, nil
+}
+
+// Verify checks that the hex encoded signature of digest was made by address,
+// in either form
+func Verify(digest []byte, signatureHex, address string) error {
+	signature, err := hexutil.Decode(signatureHex)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	signers, err := Recover(digest, signature)
+	if err != nil {
+		return err
+	}
+	if !common.IsHexAddress(address) {
+		return fmt.Errorf("invalid signer address %q", address)
+	}
+	for _, signer := range signers {
+		if signer == common.HexToAddress(address) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signed by %s, expected %s", signers[0].Hex(), address)
+}
+
+// normalize moves V from 27 or 28, as wallets return it, to 0 or 1
+func normalize(signature []byte) []byte {
+	if signature[crypto.RecoveryIDOffset] < 27 {
+		return signature
+	}
+	normalized := append([]byte(nil), signature...)
+	normalized[crypto.RecoveryIDOffset] -= 27
+	return normalized
+}
@@ -0,0 +1,129 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeWallet signs typed data the way a Ledger does, with V 27 or 28. An
+// unsupported one only signs transactions, like a Trezor.
+type fakeWallet struct {
+	key         *ecdsa.PrivateKey
+	unsupported bool
+}
+
+func (w *fakeWallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	if w.unsupported || mimeType != accounts.MimetypeTypedData {
+		return nil, accounts.ErrNotSupported
+	}
+	signature, err := crypto.Sign(crypto.Keccak256(data), w.key)
+	if err != nil {
+		return nil, err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+func (w *fakeWallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+func (w *fakeWallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), w.key)
+}
+
+func TestKeyAndHardwareSignaturesVerify(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	digest := crypto.Keccak256([]byte(`{"task_id":"1"}`))
+
+	hardware := &walletSigner{wallet: &fakeWallet{key: key}, account: accounts.Account{Address: address}, typed: true}
+	for name, s := range map[string]Signer{"key": FromKey(key), "hardware": hardware} {
+		signature, err := s.SignDigest(digest)
+		if err != nil {
+			t.Fatalf("%s SignDigest() error = %v", name, err)
+		}
+		if v := signature[crypto.RecoveryIDOffset]; v > 1 {
+			t.Fatalf("%s signature V = %d, want 0 or 1", name, v)
+		}
+		if err := Verify(digest, hexutil.Encode(signature), address.Hex()); err != nil {
+			t.Fatalf("%s signature does not verify: %v", name, err)
+		}
+		if err := Verify(crypto.Keccak256([]byte("tampered")), hexutil.Encode(signature), address.Hex()); err == nil {
+			t.Fatalf("%s signature verifies for another digest", name)
+		}
+	}
+
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := FromKey(other).SignDigest(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(digest, hexutil.Encode(signature), address.Hex()); err == nil {
+		t.Fatal("signature of another key verifies")
+	}
+}
+
+func TestWalletsThatOnlySignTransactions(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	trezor := &walletSigner{wallet: &fakeWallet{key: key, unsupported: true}, account: accounts.Account{Address: address}, typed: true}
+
+	if _, err := trezor.SignDigest(make([]byte, 32)); !errors.Is(err, ErrDigestNotSupported) {
+		t.Fatalf("SignDigest() error = %v, want ErrDigestNotSupported", err)
+	}
+
+	chainID := big.NewInt(8453)
+	tx, err := trezor.SignTx(types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 1, Gas: 21000}), chainID)
+	if err != nil {
+		t.Fatalf("SignTx() error = %v", err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+	if err != nil || sender != address {
+		t.Fatalf("transaction sender = %s, %v; want %s", sender.Hex(), err, address.Hex())
+	}
+}
+
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signer.json")
+	if config, err := LoadConfig(path); err != nil || config != nil {
+		t.Fatalf("LoadConfig() without a file = %v, %v; want nil, nil", config, err)
+	}
+
+	want := Config{Type: TypeLedger, Path: "m/44'/60'/0'/0/1", Address: "0x9965507D1a55bcC2695C58ba16FB37d819B0A4dc"}
+	if err := SaveConfig(path, want); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+	got, err := LoadConfig(path)
+	if err != nil || got == nil || *got != want {
+		t.Fatalf("LoadConfig() = %+v, %v; want %+v", got, err, want)
+	}
+
+	for _, config := range []Config{
+		{Type: "keychain"},
+		{Type: TypeClef},
+		{Type: TypeTrezor, Path: "m/44'/sixty'"},
+		{Type: TypeLedger, Address: "not-an-address"},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted an invalid config", config)
+		}
+	}
+}
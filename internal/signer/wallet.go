@@ -0,0 +1,158 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// wallet is the part of accounts.Wallet a walletSigner uses
+type wallet interface {
+	SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error)
+	SignText(account accounts.Account, text []byte) ([]byte, error)
+	SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// walletSigner signs with an account of a go-ethereum wallet. Hardware wallets
+// sign digests as typed data, remote signers as text.
+type walletSigner struct {
+	wallet  wallet
+	account accounts.Account
+	typed   bool
+}
+
+func (s *walletSigner) Address() common.Address {
+	return s.account.Address
+}
+
+func (s *walletSigner) SignDigest(digest []byte) ([]byte, error) {
+	var signature []byte
+	var err error
+	if s.typed {
+		signature, err = s.wallet.SignData(s.account, accounts.MimetypeTypedData, TypedData(digest))
+	} else {
+		signature, err = s.wallet.SignText(s.account, digest)
+	}
+	if errors.Is(err, accounts.ErrNotSupported) {
+		return nil, ErrDigestNotSupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normalize(signature), nil
+}
+
+func (s *walletSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return s.wallet.SignTx(s.account, tx, chainID)
+}
+
+// Open connects to the configured signer. Hardware wallets must be plugged in
+// and unlocked, with the Ethereum app open on a Ledger; pin is asked for the
+// PIN of a Trezor that needs one entered on the host and may be nil otherwise.
+// When the config names an address, the account must have it.
+func Open(config Config, pin func() (string, error)) (Signer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var signer *walletSigner
+	var err error
+	switch config.Type {
+	case TypeLedger, TypeTrezor:
+		signer, err = openHardware(config, pin)
+	case TypeClef:
+		signer, err = openRemote(config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Address != "" && signer.Address() != common.HexToAddress(config.Address) {
+		return nil, fmt.Errorf("%s holds %s, not the configured %s", config, signer.Address().Hex(), config.Address)
+	}
+	return signer, nil
+}
+
+func openHardware(config Config, pin func() (string, error)) (*walletSigner, error) {
+	path, err := accounts.ParseDerivationPath(config.path())
+	if err != nil {
+		return nil, fmt.Errorf("invalid derivation path %q: %w", config.path(), err)
+	}
+
+	var hubs []*usbwallet.Hub
+	if config.Type == TypeLedger {
+		hub, err := usbwallet.NewLedgerHub()
+		if err != nil {
+			return nil, fmt.Errorf("failed to look for Ledger devices: %w", err)
+		}
+		hubs = append(hubs, hub)
+	} else {
+		// Older Trezors talk HID, newer ones WebUSB
+		if hub, err := usbwallet.NewTrezorHubWithHID(); err == nil {
+			hubs = append(hubs, hub)
+		}
+		if hub, err := usbwallet.NewTrezorHubWithWebUSB(); err == nil {
+			hubs = append(hubs, hub)
+		}
+		if len(hubs) == 0 {
+			return nil, fmt.Errorf("failed to look for Trezor devices: USB access is not available")
+		}
+	}
+
+	var wallets []accounts.Wallet
+	for _, hub := range hubs {
+		wallets = append(wallets, hub.Wallets()...)
+	}
+	if len(wallets) == 0 {
+		return nil, fmt.Errorf("no %s found, check that it is plugged in and unlocked", config.Type)
+	}
+
+	device := wallets[0]
+	err = device.Open("")
+	if errors.Is(err, usbwallet.ErrTrezorPINNeeded) {
+		if pin == nil {
+			return nil, fmt.Errorf("the Trezor needs its PIN, run parity-runner auth to enter it")
+		}
+		entered, pinErr := pin()
+		if pinErr != nil {
+			return nil, pinErr
+		}
+		err = device.Open(entered)
+	}
+	if err != nil && !errors.Is(err, accounts.ErrWalletAlreadyOpen) {
+		return nil, fmt.Errorf("failed to open %s: %w", config.Type, err)
+	}
+
+	account, err := device.Derive(path, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive account %s on the %s: %w", config.path(), config.Type, err)
+	}
+	return &walletSigner{wallet: device, account: account, typed: true}, nil
+}
+
+func openRemote(config Config) (*walletSigner, error) {
+	remote, err := external.NewExternalSigner(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to remote signer at %s: %w", config.URL, err)
+	}
+	accountsList := remote.Accounts()
+	if len(accountsList) == 0 {
+		return nil, fmt.Errorf("remote signer at %s has no accounts, or did not approve listing them", config.URL)
+	}
+
+	account := accountsList[0]
+	if config.Address != "" {
+		for _, candidate := range accountsList {
+			if candidate.Address == common.HexToAddress(config.Address) {
+				account = candidate
+			}
+		}
+	}
+	return &walletSigner{wallet: remote, account: account}, nil
+}
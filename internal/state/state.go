@@ -0,0 +1,305 @@
+// Package state bundles what makes a runner itself, its wallet key, device ID,
+// config, undelivered results and task history, so the runner can be moved to
+// new hardware without losing its reputation or unpaid results.
+package state
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+// BundleVersion is bumped when the layout of a bundle changes
+const BundleVersion = 1
+
+const (
+	manifestName = "bundle.json"
+	keystoreName = "keystore.json"
+	configName   = "config.env"
+	dataPrefix   = "data/"
+)
+
+// scryptN is the cost of the key derivation protecting the exported key
+var scryptN = keystore.StandardScryptN
+
+// Manifest describes a bundle
+type Manifest struct {
+	Version       int       `json:"version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	WalletAddress string    `json:"wallet_address"`
+	DeviceID      string    `json:"device_id"`
+	Network       string    `json:"network"`
+	HasConfig     bool      `json:"has_config"`
+	// Files is how many data files the bundle holds
+	Files int `json:"files"`
+}
+
+// State is what Export bundles
+type State struct {
+	Key      *ecdsa.PrivateKey
+	DeviceID string
+	Network  string
+	// Config is the runner's config file, left out when nil
+	Config []byte
+	// DataDir holds Paths, files or directories that are bundled relative to it
+	DataDir string
+	Paths   []string
+}
+
+// Export writes s to w as a gzipped tar. The key is encrypted with passphrase
+// in the Ethereum keystore format; everything else is stored as is.
+func Export(w io.Writer, s *State, passphrase string) (*Manifest, error) {
+	if s.Key == nil {
+		return nil, errors.New("wallet key is required")
+	}
+	if passphrase == "" {
+		return nil, errors.New("passphrase is required")
+	}
+
+	files, err := dataFiles(s.DataDir, s.Paths)
+	if err != nil {
+		return nil, err
+	}
+
+	address := crypto.PubkeyToAddress(s.Key.PublicKey)
+	encrypted, err := keystore.EncryptKey(&keystore.Key{Id: uuid.New(), Address: address, PrivateKey: s.Key}, passphrase, scryptN, keystore.StandardScryptP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt wallet key: %w", err)
+	}
+
+	manifest := &Manifest{
+		Version:       BundleVersion,
+		ExportedAt:    time.Now().UTC().Truncate(time.Second),
+		WalletAddress: address.Hex(),
+		DeviceID:      s.DeviceID,
+		Network:       s.Network,
+		HasConfig:     s.Config != nil,
+		Files:         len(files),
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bundle manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeEntry(tw, manifestName, manifestJSON); err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, keystoreName, encrypted); err != nil {
+		return nil, err
+	}
+	if s.Config != nil {
+		if err := writeEntry(tw, configName, s.Config); err != nil {
+			return nil, err
+		}
+	}
+	for _, rel := range files {
+		if err := addFile(tw, s.DataDir, rel); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return manifest, nil
+}
+
+// dataFiles lists the regular files under paths, relative to dataDir and in
+// slash form. Paths that do not exist are skipped.
+func dataFiles(dataDir string, paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		root := filepath.Join(dataDir, p)
+		err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) && name == root {
+					return nil
+				}
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(dataDir, name)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", p, err)
+		}
+	}
+	return files, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func addFile(tw *tar.Writer, dataDir, rel string) error {
+	f, err := os.Open(filepath.Join(dataDir, filepath.FromSlash(rel)))
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", rel, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", rel, err)
+	}
+
+	header := &tar.Header{Name: dataPrefix + rel, Mode: 0o600, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", rel, err)
+	}
+	if _, err := io.CopyN(tw, f, info.Size()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", rel, err)
+	}
+	return nil
+}
+
+// Bundle is an opened bundle whose key has been decrypted. Its data files are
+// written by Extract, after the caller has checked the manifest.
+type Bundle struct {
+	Manifest *Manifest
+	Key      *ecdsa.PrivateKey
+	// Config is nil when the bundle has none
+	Config []byte
+
+	gz *gzip.Reader
+	tr *tar.Reader
+}
+
+// maxHeaderEntry bounds the manifest, keystore and config read into memory
+const maxHeaderEntry = 1 << 20
+
+// Open reads the manifest, key and config of a bundle, decrypting the key with
+// passphrase
+func Open(r io.Reader, passphrase string) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a runner state bundle: %w", err)
+	}
+	b := &Bundle{gz: gz, tr: tar.NewReader(gz)}
+
+	manifestJSON, err := b.readEntry(manifestName)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(manifestJSON, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if b.Manifest.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Manifest.Version)
+	}
+
+	encrypted, err := b.readEntry(keystoreName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.DecryptKey(encrypted, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt wallet key: %w", err)
+	}
+	if key.Address.Hex() != b.Manifest.WalletAddress {
+		return nil, fmt.Errorf("bundle key is for %s, not %s", key.Address.Hex(), b.Manifest.WalletAddress)
+	}
+	b.Key = key.PrivateKey
+
+	if b.Manifest.HasConfig {
+		if b.Config, err = b.readEntry(configName); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (b *Bundle) readEntry(name string) ([]byte, error) {
+	header, err := b.tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("bundle has no %s: %w", name, err)
+	}
+	if header.Name != name {
+		return nil, fmt.Errorf("bundle has %s where %s was expected", header.Name, name)
+	}
+	data, err := io.ReadAll(io.LimitReader(b.tr, maxHeaderEntry+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > maxHeaderEntry {
+		return nil, fmt.Errorf("%s is too large", name)
+	}
+	return data, nil
+}
+
+// Extract writes the bundle's data files under dataDir, replacing files of the
+// same name, and returns how many were written
+func (b *Bundle) Extract(dataDir string) (int, error) {
+	defer b.gz.Close()
+
+	written := 0
+	for {
+		header, err := b.tr.Next()
+		if errors.Is(err, io.EOF) {
+			return written, nil
+		}
+		if err != nil {
+			return written, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		rel, ok := strings.CutPrefix(header.Name, dataPrefix)
+		if !ok || !filepath.IsLocal(filepath.FromSlash(rel)) || path.Clean(rel) != rel {
+			return written, fmt.Errorf("bundle has an unexpected entry %q", header.Name)
+		}
+		if err := extractFile(b.tr, filepath.Join(dataDir, filepath.FromSlash(rel))); err != nil {
+			return written, err
+		}
+		written++
+	}
+}
+
+func extractFile(r io.Reader, name string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(name), err)
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
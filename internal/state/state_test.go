@@ -0,0 +1,121 @@
+package state
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func init() {
+	scryptN = keystore.LightScryptN
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	source := t.TempDir()
+	writeFile(t, filepath.Join(source, "outbox", "a.json"), `{"task_id":"a"}`)
+	writeFile(t, filepath.Join(source, "receipts", "b.json"), `{"task_id":"b"}`)
+	writeFile(t, filepath.Join(source, "server_identity.json"), `{}`)
+	writeFile(t, filepath.Join(source, "hardware-benchmark.json"), `{}`)
+
+	var bundle bytes.Buffer
+	manifest, err := Export(&bundle, &State{
+		Key:      key,
+		DeviceID: "device-1",
+		Network:  "mainnet",
+		Config:   []byte("RUNNER_SERVER_URL=http://server\n"),
+		DataDir:  source,
+		Paths:    []string{"outbox", "receipts", "artifacts", "server_identity.json"},
+	}, "correct horse")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if manifest.Files != 3 {
+		t.Fatalf("exported %d files, want 3", manifest.Files)
+	}
+	if bytes.Contains(bundle.Bytes(), crypto.FromECDSA(key)) {
+		t.Fatal("bundle holds the plain wallet key")
+	}
+
+	if _, err := Open(bytes.NewReader(bundle.Bytes()), "wrong"); err == nil {
+		t.Fatal("Open() accepted a wrong passphrase")
+	}
+
+	opened, err := Open(bytes.NewReader(bundle.Bytes()), "correct horse")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !opened.Key.Equal(key) || opened.Manifest.DeviceID != "device-1" || string(opened.Config) != "RUNNER_SERVER_URL=http://server\n" {
+		t.Fatalf("Open() = %+v with config %q, want the exported state", opened.Manifest, opened.Config)
+	}
+
+	target := t.TempDir()
+	written, err := opened.Extract(target)
+	if err != nil || written != 3 {
+		t.Fatalf("Extract() = %d, %v, want 3 files", written, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(target, "outbox", "a.json")); err != nil || string(data) != `{"task_id":"a"}` {
+		t.Fatalf("outbox entry = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "hardware-benchmark.json")); !os.IsNotExist(err) {
+		t.Fatal("a file outside the exported paths was moved")
+	}
+}
+
+func TestExtractRefusesEntriesOutsideDataDir(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var bundle bytes.Buffer
+	if _, err := Export(&bundle, &State{Key: key, DataDir: t.TempDir()}, "pass"); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	// Re-pack the bundle with an entry that climbs out of the data directory
+	gz, _ := gzip.NewReader(&bundle)
+	tr := tar.NewReader(gz)
+	var tampered bytes.Buffer
+	gw := gzip.NewWriter(&tampered)
+	tw := tar.NewWriter(gw)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		data := new(bytes.Buffer)
+		_, _ = data.ReadFrom(tr)
+		_ = tw.WriteHeader(header)
+		_, _ = tw.Write(data.Bytes())
+	}
+	_ = tw.WriteHeader(&tar.Header{Name: "data/../escape", Mode: 0o600, Size: 1, Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte("x"))
+	_ = tw.Close()
+	_ = gw.Close()
+
+	opened, err := Open(&tampered, "pass")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	target := filepath.Join(t.TempDir(), "data")
+	if _, err := opened.Extract(target); err == nil || !strings.Contains(err.Error(), "unexpected entry") {
+		t.Fatalf("Extract() error = %v, want an unexpected entry", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(target), "escape")); !os.IsNotExist(err) {
+		t.Fatal("Extract() wrote outside the data directory")
+	}
+}
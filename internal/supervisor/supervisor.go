@@ -0,0 +1,227 @@
+package supervisor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// Config decides how often subsystems are checked and how hard a failing one is
+// restarted before the supervisor gives up on it
+type Config struct {
+	// CheckInterval is how often every subsystem is checked
+	CheckInterval time.Duration
+	// BaseBackoff is the wait after the first restart, doubled after each further
+	// restart up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// MaxRestarts restarts of one subsystem within Window escalate to a restart
+	// of the whole process
+	MaxRestarts int
+	Window      time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		CheckInterval: 15 * time.Second,
+		BaseBackoff:   5 * time.Second,
+		MaxBackoff:    2 * time.Minute,
+		MaxRestarts:   5,
+		Window:        10 * time.Minute,
+	}
+}
+
+// Subsystem is a long running part of the runner. Check returns why it is not
+// working and Restart brings it back.
+type Subsystem struct {
+	Name    string
+	Check   func(ctx context.Context) error
+	Restart func(ctx context.Context) error
+}
+
+type subsystem struct {
+	Subsystem
+	health models.SubsystemHealth
+	// restarts within the window, oldest first
+	restarts    []time.Time
+	backoff     time.Duration
+	nextRestart time.Time
+}
+
+// Supervisor checks subsystems and restarts those that stopped working, backing
+// off between restarts. A subsystem that keeps failing is reported to the
+// OnEscalate callback, which is expected to restart the process.
+type Supervisor struct {
+	config Config
+
+	mu         sync.Mutex
+	subsystems []*subsystem
+	onEscalate func(name string, err error)
+	escalated  bool
+	now        func() time.Time
+}
+
+func New(config Config) *Supervisor {
+	defaults := DefaultConfig()
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaults.CheckInterval
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = defaults.BaseBackoff
+	}
+	if config.MaxBackoff < config.BaseBackoff {
+		config.MaxBackoff = max(defaults.MaxBackoff, config.BaseBackoff)
+	}
+	if config.MaxRestarts <= 0 {
+		config.MaxRestarts = defaults.MaxRestarts
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	return &Supervisor{config: config, now: time.Now}
+}
+
+// Add starts watching a subsystem. It is assumed healthy until checked.
+func (s *Supervisor) Add(sub Subsystem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subsystems = append(s.subsystems, &subsystem{
+		Subsystem: sub,
+		health:    models.SubsystemHealth{Name: sub.Name, State: models.SubsystemHealthy},
+	})
+}
+
+// OnEscalate registers the callback run, once, when a subsystem failed
+// MaxRestarts times within the window
+func (s *Supervisor) OnEscalate(fn func(name string, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onEscalate = fn
+}
+
+// Health returns the state of every subsystem in the order they were added
+func (s *Supervisor) Health() []models.SubsystemHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	health := make([]models.SubsystemHealth, len(s.subsystems))
+	for i, sub := range s.subsystems {
+		health[i] = sub.health
+	}
+	return health
+}
+
+// Run checks the subsystems every CheckInterval until ctx is done
+func (s *Supervisor) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Check(ctx)
+		}
+	}
+}
+
+// Check checks every subsystem once, restarting those that fail and whose
+// backoff has passed
+func (s *Supervisor) Check(ctx context.Context) {
+	s.mu.Lock()
+	subsystems := append([]*subsystem(nil), s.subsystems...)
+	s.mu.Unlock()
+
+	for _, sub := range subsystems {
+		if ctx.Err() != nil {
+			return
+		}
+		s.check(ctx, sub)
+	}
+}
+
+func (s *Supervisor) check(ctx context.Context, sub *subsystem) {
+	log := gologger.WithComponent("supervisor")
+
+	err := sub.Check(ctx)
+
+	s.mu.Lock()
+	if s.escalated {
+		s.mu.Unlock()
+		return
+	}
+	now := s.now()
+	if err == nil {
+		recovered := sub.health.State != models.SubsystemHealthy
+		sub.health.State = models.SubsystemHealthy
+		sub.health.LastError = ""
+		sub.backoff = 0
+		sub.nextRestart = time.Time{}
+		restarts := sub.health.Restarts
+		s.mu.Unlock()
+
+		if recovered {
+			log.Info().Str("subsystem", sub.Name).Int("restarts", restarts).Msg("Subsystem recovered")
+		}
+		return
+	}
+
+	sub.health.LastError = err.Error()
+	if now.Before(sub.nextRestart) {
+		sub.health.State = models.SubsystemRestarting
+		s.mu.Unlock()
+		return
+	}
+
+	cutoff := now.Add(-s.config.Window)
+	for len(sub.restarts) > 0 && sub.restarts[0].Before(cutoff) {
+		sub.restarts = sub.restarts[1:]
+	}
+	if len(sub.restarts) >= s.config.MaxRestarts {
+		sub.health.State = models.SubsystemFailed
+		s.escalated = true
+		onEscalate := s.onEscalate
+		s.mu.Unlock()
+
+		log.Error().
+			Err(err).
+			Str("subsystem", sub.Name).
+			Int("restarts", len(sub.restarts)).
+			Dur("window", s.config.Window).
+			Msg("Subsystem keeps failing, escalating to a process restart")
+		if onEscalate != nil {
+			onEscalate(sub.Name, err)
+		}
+		return
+	}
+
+	sub.restarts = append(sub.restarts, now)
+	sub.health.Restarts++
+	sub.health.LastRestart = &now
+	sub.health.State = models.SubsystemRestarting
+	if sub.backoff == 0 {
+		sub.backoff = s.config.BaseBackoff
+	} else {
+		sub.backoff = min(sub.backoff*2, s.config.MaxBackoff)
+	}
+	sub.nextRestart = now.Add(sub.backoff)
+	restarts, backoff := sub.health.Restarts, sub.backoff
+	s.mu.Unlock()
+
+	log.Warn().
+		Err(err).
+		Str("subsystem", sub.Name).
+		Int("restarts", restarts).
+		Msg("Subsystem is down, restarting it")
+
+	if restartErr := sub.Restart(ctx); restartErr != nil {
+		log.Error().Err(restartErr).Str("subsystem", sub.Name).Dur("retry_in", backoff).Msg("Failed to restart subsystem")
+		s.mu.Lock()
+		sub.health.LastError = "restart failed: " + restartErr.Error()
+		s.mu.Unlock()
+	}
+}
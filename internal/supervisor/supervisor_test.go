@@ -0,0 +1,127 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+type fakeSubsystem struct {
+	err      error
+	restarts int
+	// heal makes a restart fix the subsystem
+	heal bool
+}
+
+func (f *fakeSubsystem) subsystem(name string) Subsystem {
+	return Subsystem{
+		Name:  name,
+		Check: func(ctx context.Context) error { return f.err },
+		Restart: func(ctx context.Context) error {
+			f.restarts++
+			if f.heal {
+				f.err = nil
+			}
+			return nil
+		},
+	}
+}
+
+func newTestSupervisor(config Config) (*Supervisor, *time.Time) {
+	s := New(config)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestSupervisorRestartsFailedSubsystem(t *testing.T) {
+	s, _ := newTestSupervisor(Config{})
+	healthy := &fakeSubsystem{}
+	crashed := &fakeSubsystem{err: errors.New("server closed"), heal: true}
+	s.Add(healthy.subsystem("heartbeat"))
+	s.Add(crashed.subsystem("webhook"))
+
+	ctx := context.Background()
+	s.Check(ctx)
+	if healthy.restarts != 0 || crashed.restarts != 1 {
+		t.Fatalf("restarts = %d, %d, want 0, 1", healthy.restarts, crashed.restarts)
+	}
+	health := s.Health()
+	if health[0].State != models.SubsystemHealthy || health[1].State != models.SubsystemRestarting || health[1].LastError != "server closed" {
+		t.Fatalf("health = %+v", health)
+	}
+
+	s.Check(ctx)
+	health = s.Health()
+	if health[1].State != models.SubsystemHealthy || health[1].Restarts != 1 || health[1].LastRestart == nil || health[1].LastError != "" {
+		t.Fatalf("health after recovery = %+v", health[1])
+	}
+}
+
+func TestSupervisorBacksOffBetweenRestarts(t *testing.T) {
+	s, now := newTestSupervisor(Config{BaseBackoff: 10 * time.Second, MaxBackoff: 30 * time.Second, MaxRestarts: 10})
+	broken := &fakeSubsystem{err: errors.New("tunnel exited")}
+	s.Add(broken.subsystem("tunnel"))
+
+	ctx := context.Background()
+	s.Check(ctx)
+	// Further restarts are due after 10s, 20s and then 30s, the maximum
+	for i, wait := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+		*now = now.Add(wait - time.Second)
+		s.Check(ctx)
+		if broken.restarts != i+1 {
+			t.Fatalf("restarted %d times a second before the backoff passed, want %d", broken.restarts, i+1)
+		}
+		*now = now.Add(time.Second)
+		s.Check(ctx)
+		if broken.restarts != i+2 {
+			t.Fatalf("restarted %d times once the backoff passed, want %d", broken.restarts, i+2)
+		}
+	}
+}
+
+func TestSupervisorEscalatesRepeatedFailures(t *testing.T) {
+	s, now := newTestSupervisor(Config{BaseBackoff: time.Second, MaxBackoff: time.Second, MaxRestarts: 3, Window: time.Minute})
+	broken := &fakeSubsystem{err: errors.New("ollama unreachable")}
+	s.Add(broken.subsystem("ollama"))
+
+	var escalated []string
+	s.OnEscalate(func(name string, err error) { escalated = append(escalated, name) })
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		s.Check(ctx)
+		*now = now.Add(time.Second)
+	}
+	if broken.restarts != 3 {
+		t.Fatalf("restarts = %d, want 3", broken.restarts)
+	}
+	if len(escalated) != 1 || escalated[0] != "ollama" {
+		t.Fatalf("escalated = %v, want ollama once", escalated)
+	}
+	if state := s.Health()[0].State; state != models.SubsystemFailed {
+		t.Fatalf("state = %s, want failed", state)
+	}
+}
+
+func TestSupervisorForgetsRestartsOutsideWindow(t *testing.T) {
+	s, now := newTestSupervisor(Config{BaseBackoff: time.Second, MaxBackoff: time.Second, MaxRestarts: 2, Window: time.Minute})
+	flaky := &fakeSubsystem{err: errors.New("down"), heal: true}
+	s.Add(flaky.subsystem("webhook"))
+
+	escalated := false
+	s.OnEscalate(func(name string, err error) { escalated = true })
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		flaky.err = errors.New("down")
+		s.Check(ctx)
+		*now = now.Add(45 * time.Second)
+	}
+	if escalated || flaky.restarts != 4 {
+		t.Fatalf("escalated = %v after %d restarts spread over the window", escalated, flaky.restarts)
+	}
+}
@@ -0,0 +1,84 @@
+// Package sysmetrics collects the host's memory, CPU, disk and load for heartbeats
+package sysmetrics
+
+import (
+	"os"
+
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/core/ports"
+)
+
+// Collector reads host metrics. Disk usage is reported for the filesystem
+// holding diskPath.
+type Collector struct {
+	diskPath string
+}
+
+// NewCollector returns a collector for the filesystem holding diskPath, or the
+// user's home directory when diskPath is empty
+func NewCollector(diskPath string) *Collector {
+	if diskPath == "" {
+		diskPath = "/"
+		if home, err := os.UserHomeDir(); err == nil {
+			diskPath = home
+		}
+	}
+	return &Collector{diskPath: diskPath}
+}
+
+// GetHostMetrics reports what can be read; a metric the platform does not
+// provide, such as the load average on Windows, is left at zero
+func (c *Collector) GetHostMetrics() models.HostMetrics {
+	log := gologger.WithComponent("sysmetrics")
+
+	var metrics models.HostMetrics
+
+	if memory, err := mem.VirtualMemory(); err == nil {
+		metrics.MemoryUsage = int64(memory.Used)
+		metrics.MemoryTotal = int64(memory.Total)
+	} else {
+		log.Debug().Err(err).Msg("Failed to read memory usage")
+	}
+
+	// An interval of zero compares against the previous call, so the usage
+	// covers the time since the last heartbeat
+	if percent, err := cpu.Percent(0, false); err == nil && len(percent) > 0 {
+		metrics.CPUUsage = percent[0]
+	} else if err != nil {
+		log.Debug().Err(err).Msg("Failed to read CPU usage")
+	}
+
+	if usage, err := disk.Usage(c.diskPath); err == nil {
+		metrics.DiskUsage = int64(usage.Used)
+		metrics.DiskTotal = int64(usage.Total)
+	} else {
+		log.Debug().Err(err).Str("path", c.diskPath).Msg("Failed to read disk usage")
+	}
+
+	if avg, err := load.Avg(); err == nil {
+		metrics.Load1 = avg.Load1
+		metrics.Load5 = avg.Load5
+		metrics.Load15 = avg.Load15
+	} else {
+		log.Debug().Err(err).Msg("Failed to read load average")
+	}
+
+	return metrics
+}
+
+// GetSystemMetrics reports the memory in use and the CPU usage
+func (c *Collector) GetSystemMetrics() (int64, float64) {
+	metrics := c.GetHostMetrics()
+	return metrics.MemoryUsage, metrics.CPUUsage
+}
+
+var (
+	_ ports.HostMetricsProvider = (*Collector)(nil)
+	_ ports.MetricsProvider     = (*Collector)(nil)
+)
@@ -0,0 +1,17 @@
+package sysmetrics
+
+import "testing"
+
+func TestCollectorReportsHostMetrics(t *testing.T) {
+	metrics := NewCollector(t.TempDir()).GetHostMetrics()
+
+	if metrics.MemoryTotal <= 0 || metrics.MemoryUsage <= 0 || metrics.MemoryUsage > metrics.MemoryTotal {
+		t.Fatalf("unexpected memory usage %d of %d", metrics.MemoryUsage, metrics.MemoryTotal)
+	}
+	if metrics.DiskTotal <= 0 || metrics.DiskUsage > metrics.DiskTotal {
+		t.Fatalf("unexpected disk usage %d of %d", metrics.DiskUsage, metrics.DiskTotal)
+	}
+	if metrics.CPUUsage < 0 || metrics.CPUUsage > 100 {
+		t.Fatalf("CPU usage = %v, want a percentage", metrics.CPUUsage)
+	}
+}
@@ -0,0 +1,91 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+)
+
+// DefaultInterval is how often reports are sent when no interval is configured
+const DefaultInterval = 24 * time.Hour
+
+// Reporter sends the collector's counts to the telemetry endpoint
+type Reporter struct {
+	collector *Collector
+	endpoint  string
+	hardware  HardwareClass
+	interval  time.Duration
+	client    *http.Client
+}
+
+// NewReporter sends reports to endpoint, or the telemetry API of serverURL when
+// endpoint is empty
+func NewReporter(collector *Collector, endpoint, serverURL string, hardware HardwareClass, interval time.Duration) *Reporter {
+	if endpoint == "" {
+		endpoint = strings.TrimSuffix(strings.TrimSuffix(serverURL, "/"), "/api") + "/api/v1/telemetry"
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Reporter{
+		collector: collector,
+		endpoint:  endpoint,
+		hardware:  hardware,
+		interval:  interval,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Endpoint is where reports are sent
+func (r *Reporter) Endpoint() string {
+	return r.endpoint
+}
+
+// Run sends a report every interval until ctx is done. Reports that cannot be
+// sent are retried with the next one.
+func (r *Reporter) Run(ctx context.Context) {
+	log := gologger.WithComponent("telemetry")
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Send(ctx, time.Now()); err != nil {
+				log.Debug().Err(err).Msg("Failed to send usage telemetry")
+			}
+		}
+	}
+}
+
+// Send delivers a report of what was counted until now
+func (r *Reporter) Send(ctx context.Context, now time.Time) error {
+	report := r.collector.Report(r.hardware, now)
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry report: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return r.collector.sent(report, now)
+}
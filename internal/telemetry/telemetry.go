@@ -0,0 +1,262 @@
+// Package telemetry collects anonymous usage statistics of the runner and sends
+// them, only when the operator opted in, to help decide what to work on next.
+//
+// Everything that leaves the machine is a Report. It holds counts and coarse
+// classes only: how many tasks of each type ran, how many failed in each
+// FailureClass, the runner version and platform, and the hardware in buckets.
+// No task IDs, images, outputs, error messages, addresses, device IDs, wallet
+// addresses or host names are collected, and reports carry no identifier, so
+// two reports of the same runner cannot be linked by their content.
+//
+// Counts are kept in the data directory until they are sent, so that
+// `parity-runner telemetry preview` shows exactly what the next report holds.
+// PARITY_NO_TELEMETRY or DO_NOT_TRACK turn telemetry off whatever the config
+// says, and the runner then deletes counts it kept earlier.
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+	"github.com/theblitlabs/parity-runner/internal/provenance"
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+// ReportVersion is the schema version of Report
+const ReportVersion = 1
+
+const (
+	telemetryDirName = "telemetry"
+	pendingFileName  = "pending.json"
+
+	// EnvDisable turns telemetry off when set to anything but 0 or false
+	EnvDisable = "PARITY_NO_TELEMETRY"
+	// envDoNotTrack is the cross-tool opt out, https://consoledonottrack.com
+	envDoNotTrack = "DO_NOT_TRACK"
+)
+
+// FailureClass is the coarse reason a task failed
+type FailureClass string
+
+const (
+	FailureExitCode   FailureClass = "exit_code"
+	FailureTimeout    FailureClass = "timeout"
+	FailureAborted    FailureClass = "aborted"
+	FailureSubmission FailureClass = "submission"
+	FailureExecution  FailureClass = "execution"
+)
+
+// Report is the complete payload of one telemetry submission
+type Report struct {
+	SchemaVersion int    `json:"schema_version"`
+	RunnerVersion string `json:"runner_version"`
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	// PeriodStart and PeriodEnd are rounded to the hour
+	PeriodStart time.Time            `json:"period_start"`
+	PeriodEnd   time.Time            `json:"period_end"`
+	Hardware    HardwareClass        `json:"hardware"`
+	Tasks       map[string]int       `json:"tasks"`
+	Failures    map[FailureClass]int `json:"failures"`
+}
+
+// HardwareClass describes the runner's hardware in ranges, never exact values
+type HardwareClass struct {
+	CPUCores string `json:"cpu_cores"`
+	MemoryGB string `json:"memory_gb"`
+	GPUs     string `json:"gpus"`
+}
+
+// Disabled reports whether the environment turned telemetry off
+func Disabled() bool {
+	for _, name := range []string{EnvDisable, envDoNotTrack} {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+		if off, err := strconv.ParseBool(value); err != nil || off {
+			return true
+		}
+	}
+	return false
+}
+
+// Classify puts the hardware of a capability profile into ranges
+func Classify(profile *models.CapabilityProfile) HardwareClass {
+	if profile == nil {
+		return HardwareClass{CPUCores: "unknown", MemoryGB: "unknown", GPUs: "unknown"}
+	}
+	return HardwareClass{
+		CPUCores: bucket(int64(profile.CPUCores), []int64{2, 4, 8, 16, 32, 64}),
+		MemoryGB: bucket(profile.MemoryBytes>>30, []int64{4, 8, 16, 32, 64, 128, 256}),
+		GPUs:     bucket(int64(len(profile.GPUs)), []int64{0, 1, 2, 4, 8}),
+	}
+}
+
+// bucket names the range between limits that value falls in, such as 5-8 for
+// 6 with limits 4 and 8
+func bucket(value int64, limits []int64) string {
+	lower := int64(1)
+	if limits[0] == 0 {
+		lower = 0
+	} else if value <= 0 {
+		return "unknown"
+	}
+	for _, limit := range limits {
+		if value <= limit {
+			if lower == limit {
+				return strconv.FormatInt(limit, 10)
+			}
+			return fmt.Sprintf("%d-%d", lower, limit)
+		}
+		lower = limit + 1
+	}
+	return fmt.Sprintf("%d+", lower)
+}
+
+func runnerVersion() string {
+	return provenance.Current().RunnerVersion
+}
+
+func DefaultDir() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, telemetryDirName), nil
+}
+
+// Purge deletes the counts kept in dir
+func Purge(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove telemetry data: %w", err)
+	}
+	return nil
+}
+
+// pending is what was counted since the last report was sent
+type pending struct {
+	Since    time.Time            `json:"since"`
+	Tasks    map[string]int       `json:"tasks"`
+	Failures map[FailureClass]int `json:"failures"`
+}
+
+// Collector counts finished tasks until they are reported. A nil Collector
+// counts nothing.
+type Collector struct {
+	mu      sync.Mutex
+	dir     string
+	pending pending
+}
+
+// NewCollector continues the counts kept in dir
+func NewCollector(dir string) (*Collector, error) {
+	c := &Collector{dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, pendingFileName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read telemetry counts: %w", err)
+	default:
+		// Counts that cannot be read are started over rather than failing
+		_ = json.Unmarshal(data, &c.pending)
+	}
+	if c.pending.Since.IsZero() {
+		c.pending.Since = time.Now().UTC()
+	}
+	return c, nil
+}
+
+// RecordTask counts a finished task, failed when class is not empty
+func (c *Collector) RecordTask(taskType models.TaskType, class FailureClass) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending.Tasks == nil {
+		c.pending.Tasks = make(map[string]int)
+	}
+	c.pending.Tasks[string(taskType)]++
+	if class != "" {
+		if c.pending.Failures == nil {
+			c.pending.Failures = make(map[FailureClass]int)
+		}
+		c.pending.Failures[class]++
+	}
+	return c.save()
+}
+
+// Report is the report of everything counted until now
+func (c *Collector) Report(hardware HardwareClass, now time.Time) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := Report{
+		SchemaVersion: ReportVersion,
+		RunnerVersion: runnerVersion(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		PeriodStart:   c.pending.Since.UTC().Truncate(time.Hour),
+		PeriodEnd:     now.UTC().Truncate(time.Hour),
+		Hardware:      hardware,
+		Tasks:         make(map[string]int, len(c.pending.Tasks)),
+		Failures:      make(map[FailureClass]int, len(c.pending.Failures)),
+	}
+	for taskType, count := range c.pending.Tasks {
+		report.Tasks[taskType] = count
+	}
+	for class, count := range c.pending.Failures {
+		report.Failures[class] = count
+	}
+	return report
+}
+
+// sent drops the counts of a report that was delivered, keeping those made
+// since it was built
+func (c *Collector) sent(report Report, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for taskType, count := range report.Tasks {
+		c.pending.Tasks[taskType] -= count
+		if c.pending.Tasks[taskType] <= 0 {
+			delete(c.pending.Tasks, taskType)
+		}
+	}
+	for class, count := range report.Failures {
+		c.pending.Failures[class] -= count
+		if c.pending.Failures[class] <= 0 {
+			delete(c.pending.Failures, class)
+		}
+	}
+	c.pending.Since = now.UTC()
+	return c.save()
+}
+
+func (c *Collector) save() error {
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create telemetry directory: %w", err)
+	}
+	data, err := json.Marshal(c.pending)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry counts: %w", err)
+	}
+	path := filepath.Join(c.dir, pendingFileName)
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return fmt.Errorf("failed to write telemetry counts: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write telemetry counts: %w", err)
+	}
+	return nil
+}
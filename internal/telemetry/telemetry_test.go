@@ -0,0 +1,116 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+func TestClassifyReportsHardwareInRanges(t *testing.T) {
+	hardware := Classify(&models.CapabilityProfile{
+		CPUCores:    12,
+		MemoryBytes: 31 << 30,
+		GPUs:        []models.GPUInfo{{Model: "NVIDIA A100"}, {Model: "NVIDIA A100"}, {Model: "NVIDIA A100"}},
+	})
+	if hardware != (HardwareClass{CPUCores: "9-16", MemoryGB: "17-32", GPUs: "3-4"}) {
+		t.Fatalf("Classify() = %+v", hardware)
+	}
+	if hardware := Classify(&models.CapabilityProfile{CPUCores: 128, MemoryBytes: 1 << 30}); hardware.CPUCores != "65+" || hardware.MemoryGB != "1-4" || hardware.GPUs != "0" {
+		t.Fatalf("Classify() = %+v", hardware)
+	}
+}
+
+func TestDisabledHonoursTheOffSwitches(t *testing.T) {
+	t.Setenv(EnvDisable, "")
+	t.Setenv(envDoNotTrack, "")
+	if Disabled() {
+		t.Fatal("Disabled() without an off switch")
+	}
+	for name, value := range map[string]string{EnvDisable: "1", envDoNotTrack: "true"} {
+		t.Setenv(name, value)
+		if !Disabled() {
+			t.Fatalf("Disabled() ignored %s=%s", name, value)
+		}
+		t.Setenv(name, "0")
+		if Disabled() {
+			t.Fatalf("Disabled() with %s=0", name)
+		}
+	}
+}
+
+func TestReporterSendsOnlyTheDocumentedPayload(t *testing.T) {
+	dir := t.TempDir()
+	collector, err := NewCollector(dir)
+	if err != nil {
+		t.Fatalf("NewCollector() error = %v", err)
+	}
+	_ = collector.RecordTask(models.TaskTypeDocker, "")
+	_ = collector.RecordTask(models.TaskTypeDocker, FailureTimeout)
+
+	// Counts survive a restart until they are sent
+	collector, err = NewCollector(dir)
+	if err != nil {
+		t.Fatalf("NewCollector() error = %v", err)
+	}
+	_ = collector.RecordTask(models.TaskTypeCommand, "")
+
+	var received map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/telemetry" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	hardware := HardwareClass{CPUCores: "5-8", MemoryGB: "9-16", GPUs: "0"}
+	reporter := NewReporter(collector, "", server.URL+"/api", hardware, 0)
+	if err := reporter.Send(context.Background(), time.Now()); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var keys []string
+	for key := range received {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	want := []string{"arch", "failures", "hardware", "os", "period_end", "period_start", "runner_version", "schema_version", "tasks"}
+	if len(keys) != len(want) {
+		t.Fatalf("payload fields = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("payload fields = %v, want %v", keys, want)
+		}
+	}
+	var tasks map[string]int
+	var failures map[FailureClass]int
+	_ = json.Unmarshal(received["tasks"], &tasks)
+	_ = json.Unmarshal(received["failures"], &failures)
+	if tasks["docker"] != 2 || tasks["command"] != 1 || failures[FailureTimeout] != 1 {
+		t.Fatalf("tasks = %v, failures = %v", tasks, failures)
+	}
+
+	if report := collector.Report(hardware, time.Now()); len(report.Tasks) != 0 || len(report.Failures) != 0 {
+		t.Fatalf("counts after sending = %+v, want none", report)
+	}
+
+	server.Close()
+	_ = collector.RecordTask(models.TaskTypeWasm, FailureExitCode)
+	if err := reporter.Send(context.Background(), time.Now()); err == nil {
+		t.Fatal("Send() succeeded with the endpoint down")
+	}
+	if report := collector.Report(hardware, time.Now()); report.Tasks["wasm"] != 1 {
+		t.Fatalf("counts after a failed send = %+v, want them kept", report)
+	}
+}
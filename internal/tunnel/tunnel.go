@@ -41,6 +41,8 @@ type TunnelClient struct {
 	mu        sync.Mutex
 	running   bool
 	publicURL string
+	// exitErr is why an established tunnel went down
+	exitErr error
 }
 
 func NewTunnelClient(config TunnelConfig) *TunnelClient {
@@ -85,7 +87,11 @@ func (t *TunnelClient) Start() (string, error) {
 func (t *TunnelClient) Stop() error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	return t.stop()
+}
 
+// stop kills the tunnel process. The caller holds t.mu.
+func (t *TunnelClient) stop() error {
 	if !t.running {
 		return nil
 	}
@@ -98,11 +104,11 @@ func (t *TunnelClient) Stop() error {
 	}
 
 	if t.cmd != nil && t.cmd.Process != nil {
+		// The process is reaped by the goroutine that watches it exit
 		if err := t.cmd.Process.Kill(); err != nil {
 			log.Error().Err(err).Msg("Failed to kill tunnel process")
 			return err
 		}
-		t.cmd.Wait()
 	}
 
 	t.running = false
@@ -111,6 +117,13 @@ func (t *TunnelClient) Stop() error {
 	return nil
 }
 
+// stopProcess kills a tunnel process that never came up. The caller holds t.mu.
+func (t *TunnelClient) stopProcess() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+}
+
 func (t *TunnelClient) GetPublicURL() string {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -123,6 +136,29 @@ func (t *TunnelClient) IsRunning() bool {
 	return t.running
 }
 
+// Healthy returns why the tunnel is down
+func (t *TunnelClient) Healthy() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.running {
+		return nil
+	}
+	if t.exitErr != nil {
+		return t.exitErr
+	}
+	return fmt.Errorf("tunnel is not running")
+}
+
+// Restart stops the tunnel and starts a new one. The public URL usually changes,
+// so the webhook has to be registered again.
+func (t *TunnelClient) Restart() (string, error) {
+	if err := t.Stop(); err != nil {
+		return "", err
+	}
+	return t.Start()
+}
+
 func (t *TunnelClient) ensureBoreInstalled() error {
 	log := gologger.WithComponent("tunnel")
 
@@ -341,14 +377,28 @@ func (t *TunnelClient) startBoreTunnel() (string, error) {
 	}()
 
 	// Monitor process exit
+	cmd := t.cmd
 	go func() {
-		if err := t.cmd.Wait(); err != nil {
+		err := cmd.Wait()
+		if err != nil {
 			log.Error().Err(err).Msg("Bore process exited with error")
 			select {
 			case errorCh <- fmt.Errorf("bore process failed: %w", err):
 			default:
 			}
 		}
+
+		// An established tunnel that exits was not stopped by us
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.cmd == cmd && t.running {
+			log.Warn().Msg("Tunnel went down")
+			t.running = false
+			t.exitErr = fmt.Errorf("bore process exited: %v", err)
+			if err == nil {
+				t.exitErr = fmt.Errorf("bore process exited")
+			}
+		}
 	}()
 
 	// Wait for URL or timeout
@@ -356,16 +406,17 @@ func (t *TunnelClient) startBoreTunnel() (string, error) {
 	case url := <-publicURL:
 		t.publicURL = url + "/webhook"
 		t.running = true
+		t.exitErr = nil
 		log.Debug().
 			Str("base_url", url).
 			Str("public_url", t.publicURL).
 			Msg("Bore tunnel established successfully")
 		return t.publicURL, nil
 	case err := <-errorCh:
-		t.Stop()
+		t.stopProcess()
 		return "", fmt.Errorf("tunnel failed: %w", err)
 	case <-time.After(60 * time.Second):
-		t.Stop()
+		t.stopProcess()
 		return "", fmt.Errorf("timeout waiting for bore tunnel to establish (60s)")
 	case <-t.ctx.Done():
 		return "", fmt.Errorf("tunnel startup cancelled")
@@ -3,13 +3,17 @@ package utils
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/theblitlabs/parity-runner/internal/core/config"
 )
 
 const (
-	DefaultConfigPath = ".env"
-	EnvConfigPath     = "PARITY_CONFIG_PATH"
+	DefaultConfigPath        = ".env"
+	DefaultTestnetConfigPath = ".env.testnet"
+	EnvConfigPath            = "PARITY_CONFIG_PATH"
+	EnvNetwork               = "PARITY_NETWORK"
+	EnvChain                 = "PARITY_CHAIN"
 )
 
 var configManager = config.GetConfigManager()
@@ -20,6 +24,17 @@ func init() {
 	} else {
 		configManager.SetConfigPath(DefaultConfigPath)
 	}
+
+	if network := strings.TrimSpace(os.Getenv(EnvNetwork)); network != "" {
+		if err := SetNetwork(network); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring %s: %v\n", EnvNetwork, err)
+		}
+	}
+	if chain := os.Getenv(EnvChain); chain != "" {
+		if err := SetChain(chain); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring %s: %v\n", EnvChain, err)
+		}
+	}
 }
 
 func GetConfig() (*config.Config, error) {
@@ -42,3 +57,66 @@ func GetConfigWithPath(configPath string) (*config.Config, error) {
 func GetConfigPath() string {
 	return configManager.GetConfigPath()
 }
+
+// SetNetwork selects mainnet or testnet. On testnet the config is read from
+// .env.testnet unless a path was given explicitly, so test and real settings
+// never share a file.
+func SetNetwork(name string) error {
+	network, err := config.ParseNetwork(name)
+	if err != nil {
+		return err
+	}
+
+	configManager.SetNetwork(network)
+	if os.Getenv(EnvConfigPath) == "" {
+		switch {
+		case network == config.NetworkTestnet && configManager.GetConfigPath() == DefaultConfigPath:
+			configManager.SetConfigPath(DefaultTestnetConfigPath)
+		case network == config.NetworkMainnet && configManager.GetConfigPath() == DefaultTestnetConfigPath:
+			configManager.SetConfigPath(DefaultConfigPath)
+		}
+	}
+	return nil
+}
+
+func Network() string {
+	return configManager.GetNetwork()
+}
+
+// SetChain selects the registry chain to stake and be paid on, overriding
+// BLOCKCHAIN_CHAIN. Whether the chain is configured is checked when the config
+// is loaded.
+func SetChain(name string) error {
+	chain, err := config.ParseChainName(name)
+	if err != nil {
+		return err
+	}
+	configManager.SetChain(chain)
+	ResetClient()
+	return nil
+}
+
+// TokenLabel is the token symbol shown next to amounts. Testnet amounts carry a
+// suffix so they are never mistaken for real funds.
+func TokenLabel(cfg *config.Config) string {
+	symbol := cfg.Blockchain.TokenSymbol
+	if symbol == "" {
+		symbol = "TOKEN"
+	}
+	if cfg.IsTestnet() || cfg.Blockchain.Testnet {
+		symbol += " (testnet, no value)"
+	}
+	return symbol
+}
+
+// ChainLabel names the chain transactions go to, such as "Base (8453)"
+func ChainLabel(cfg *config.Config) string {
+	name := cfg.Blockchain.NetworkName
+	if name == "" {
+		name = cfg.Blockchain.Chain
+	}
+	if name == "" {
+		return fmt.Sprintf("chain %d", cfg.Blockchain.ChainID)
+	}
+	return fmt.Sprintf("%s (%d)", name, cfg.Blockchain.ChainID)
+}
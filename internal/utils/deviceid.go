@@ -1,13 +1,19 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/theblitlabs/deviceid"
 )
 
+// deviceIDFileName holds a device ID imported from another machine, which
+// takes the place of the one derived from this machine's hardware
+const deviceIDFileName = "device_id"
+
 var manager *deviceid.Manager
 
 func GetDeviceID() (string, error) {
@@ -15,6 +21,14 @@ func GetDeviceID() (string, error) {
 		return override, nil
 	}
 
+	imported, err := importedDeviceID()
+	if err != nil {
+		return "", err
+	}
+	if imported != "" {
+		return imported, nil
+	}
+
 	if manager == nil {
 		manager = deviceid.NewManager(deviceid.Config{})
 	}
@@ -24,5 +38,40 @@ func GetDeviceID() (string, error) {
 		return "", fmt.Errorf("failed to verify device ID: %w", err)
 	}
 
-	return deviceID, nil
+	return InstanceScoped(deviceID), nil
+}
+
+func importedDeviceID() (string, error) {
+	dataDir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(dataDir, deviceIDFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read imported device ID: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SaveDeviceID makes deviceID this runner's device ID, so a runner moved to
+// new hardware keeps the identity its reputation and payouts are tied to
+func SaveDeviceID(deviceID string) error {
+	deviceID = strings.TrimSpace(deviceID)
+	if deviceID == "" {
+		return errors.New("device ID is empty")
+	}
+	dataDir, err := DataDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, deviceIDFileName), []byte(deviceID+"\n"), 0o600); err != nil {
+		return fmt.Errorf("failed to save device ID: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+)
+
+// FaucetGrant is a testnet token transfer made by the faucet
+type FaucetGrant struct {
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
+	TxHash  string  `json:"tx_hash"`
+}
+
+// FaucetURL is BLOCKCHAIN_FAUCET_URL, or the faucet served by the configured server
+func FaucetURL(cfg *config.Config) string {
+	if cfg.Blockchain.FaucetURL != "" {
+		return cfg.Blockchain.FaucetURL
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(cfg.Runner.ServerURL, "/"), "/api") + "/api/v1/faucet"
+}
+
+// RequestFaucet asks the faucet to send test tokens to address
+func RequestFaucet(ctx context.Context, url, address, deviceID string) (*FaucetGrant, error) {
+	body, err := json.Marshal(map[string]string{
+		"address":   address,
+		"device_id": deviceID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal faucet request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach faucet at %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read faucet response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &failure) == nil && failure.Error != "" {
+			return nil, fmt.Errorf("faucet refused the request: %s", failure.Error)
+		}
+		return nil, fmt.Errorf("faucet returned status %d", resp.StatusCode)
+	}
+
+	var grant FaucetGrant
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return nil, fmt.Errorf("failed to decode faucet response: %w", err)
+	}
+	return &grant, nil
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+)
+
+func TestRequestFaucet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "limited") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":"faucet already used recently"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"address":"0x01","amount":100,"tx_hash":"0xabc"}`))
+	}))
+	defer server.Close()
+
+	grant, err := RequestFaucet(context.Background(), server.URL+"/api/v1/faucet", "0x01", "device-1")
+	if err != nil || grant.Amount != 100 || grant.TxHash != "0xabc" {
+		t.Fatalf("RequestFaucet() = %+v, %v", grant, err)
+	}
+
+	_, err = RequestFaucet(context.Background(), server.URL+"/limited", "0x01", "device-1")
+	if err == nil || !strings.Contains(err.Error(), "already used recently") {
+		t.Fatalf("RequestFaucet() error = %v, want the faucet's reason", err)
+	}
+}
+
+func TestTokenLabelMarksTestnet(t *testing.T) {
+	cfg := &config.Config{Network: config.NetworkTestnet}
+	cfg.Blockchain.TokenSymbol = "tPRTY"
+	if label := TokenLabel(cfg); !strings.Contains(label, "testnet") {
+		t.Fatalf("TokenLabel() = %q, want a testnet label", label)
+	}
+
+	cfg.Network = config.NetworkMainnet
+	if label := TokenLabel(cfg); label != "tPRTY" {
+		t.Fatalf("TokenLabel() on mainnet = %q", label)
+	}
+}
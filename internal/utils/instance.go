@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+)
+
+const (
+	EnvInstance      = "PARITY_INSTANCE"
+	instancesDirName = "instances"
+	portSearchRange  = 100
+)
+
+var (
+	instanceName    string
+	instanceMu      sync.RWMutex
+	instanceNameRex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,31}$`)
+)
+
+func init() {
+	if name := strings.TrimSpace(os.Getenv(EnvInstance)); name != "" {
+		if err := SetInstance(name); err != nil {
+			fmt.Fprintf(os.Stderr, "ignoring %s: %v\n", EnvInstance, err)
+		}
+	}
+}
+
+// SetInstance selects the named runner instance for this process. Named instances get
+// their own data directory, device ID and Ollama container, while the keystore under
+// ~/.parity stays shared so every instance earns for the same wallet.
+func SetInstance(name string) error {
+	name = strings.TrimSpace(name)
+	if name != "" && !instanceNameRex.MatchString(name) {
+		return fmt.Errorf("invalid instance name %q: use up to 32 letters, digits, '-' or '_'", name)
+	}
+
+	instanceMu.Lock()
+	defer instanceMu.Unlock()
+	instanceName = name
+	return nil
+}
+
+func Instance() string {
+	instanceMu.RLock()
+	defer instanceMu.RUnlock()
+	return instanceName
+}
+
+// InstanceScoped suffixes a host-wide resource name with the instance name, so two
+// instances never fight over the same container or identifier
+func InstanceScoped(name string) string {
+	instance := Instance()
+	if instance == "" {
+		return name
+	}
+	return name + "-" + instance
+}
+
+// DataDir returns the directory holding per-instance state such as receipts. The
+// default mainnet instance keeps using ~/.parity directly, testnet state lives
+// under ~/.parity/testnet.
+func DataDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	dir := filepath.Join(homeDir, KeystoreDirName)
+	if Network() == config.NetworkTestnet {
+		dir = filepath.Join(dir, config.NetworkTestnet)
+	}
+	if instance := Instance(); instance != "" {
+		dir = filepath.Join(dir, instancesDirName, instance)
+	}
+	return dir, nil
+}
+
+// FindAvailablePort returns preferred if it is free, otherwise the next free port
+// above it
+func FindAvailablePort(preferred int) (int, error) {
+	if preferred <= 0 {
+		return listenOnFreePort()
+	}
+
+	for port := preferred; port < preferred+portSearchRange && port <= 65535; port++ {
+		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			continue
+		}
+		ln.Close()
+		return port, nil
+	}
+
+	return 0, fmt.Errorf("no free port found in range %d-%d", preferred, preferred+portSearchRange-1)
+}
+
+func listenOnFreePort() (int, error) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
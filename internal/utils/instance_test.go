@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestNamedInstanceScopesDataDirAndNames(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { _ = SetInstance("") })
+
+	if err := SetInstance("gpu/1"); err == nil {
+		t.Fatal("expected invalid instance name to be rejected")
+	}
+	if err := SetInstance("gpu1"); err != nil {
+		t.Fatalf("SetInstance() error = %v", err)
+	}
+
+	if got := InstanceScoped("ollama-runner"); got != "ollama-runner-gpu1" {
+		t.Fatalf("InstanceScoped() = %q, want %q", got, "ollama-runner-gpu1")
+	}
+
+	dir, err := DataDir()
+	if err != nil {
+		t.Fatalf("DataDir() error = %v", err)
+	}
+	if filepath.Base(dir) != "gpu1" || filepath.Base(filepath.Dir(dir)) != "instances" {
+		t.Fatalf("DataDir() = %q, want per-instance directory", dir)
+	}
+}
+
+func TestFindAvailablePortSkipsPortInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	busy := ln.Addr().(*net.TCPAddr).Port
+
+	port, err := FindAvailablePort(busy)
+	if err != nil {
+		t.Fatalf("FindAvailablePort() error = %v", err)
+	}
+	if port == busy {
+		t.Fatalf("FindAvailablePort() returned busy port %d", busy)
+	}
+
+	free, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatalf("returned port %d is not free: %v", port, err)
+	}
+	free.Close()
+}
@@ -2,13 +2,18 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/theblitlabs/keystore"
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/keyfile"
+	"github.com/theblitlabs/parity-runner/internal/secrets"
 )
 
 const (
@@ -16,34 +21,105 @@ const (
 	KeystoreFileName = "keystore.json"
 )
 
-func GetKeystore() (*keystore.Store, error) {
+// walletKeySecret names the wallet key in the OS secret store
+const walletKeySecret = "wallet-key"
+
+var (
+	keyInstance *ecdsa.PrivateKey
+	keyMutex    sync.Mutex
+)
+
+func GetKeystore() (*keyfile.File, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get home directory: %w", err)
 	}
+	return keyfile.New(filepath.Join(homeDir, KeystoreDirName, KeystoreFileName)), nil
+}
 
-	ks, err := keystore.NewKeystore(keystore.Config{
-		DirPath:  filepath.Join(homeDir, KeystoreDirName),
-		FileName: KeystoreFileName,
-	})
+// GetSecretStore returns the OS secret store when RUNNER_SECRET_BACKEND
+// selects it for the wallet key, and nil when the key is kept in the keystore
+// file
+func GetSecretStore() (secrets.Backend, error) {
+	cfg, err := GetConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create keystore: %w", err)
+		return nil, err
 	}
-
-	return ks, nil
+	if err := secrets.ValidateBackend(cfg.Runner.SecretBackend); err != nil {
+		return nil, err
+	}
+	if cfg.Runner.SecretBackend != secrets.BackendKeychain {
+		return nil, nil
+	}
+	store, err := secrets.Native()
+	if err != nil {
+		return nil, fmt.Errorf("RUNNER_SECRET_BACKEND is %s: %w", secrets.BackendKeychain, err)
+	}
+	return store, nil
 }
 
+// GetPrivateKey loads the wallet key from the configured secret backend,
+// asking for the keystore passphrase at most once per process
 func GetPrivateKey() (*ecdsa.PrivateKey, error) {
-	ks, err := GetKeystore()
+	keyMutex.Lock()
+	defer keyMutex.Unlock()
+
+	if keyInstance != nil {
+		return keyInstance, nil
+	}
+
+	store, err := GetSecretStore()
 	if err != nil {
 		return nil, err
 	}
+	if store != nil {
+		value, err := store.Get(walletKeySecret)
+		if errors.Is(err, secrets.ErrNotFound) {
+			return nil, fmt.Errorf("no private key found in the %s - please authenticate first using 'parity auth': %w", store.Name(), err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		privateKey, err := crypto.HexToECDSA(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key in the %s: %w", store.Name(), err)
+		}
+		keyInstance = privateKey
+		return privateKey, nil
+	}
 
-	privateKey, err := ks.LoadPrivateKey()
+	ks, err := GetKeystore()
 	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := ks.Encrypted()
+	if errors.Is(err, keyfile.ErrNoKey) {
 		return nil, fmt.Errorf("no private key found - please authenticate first using 'parity auth': %w", err)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	var passphrase string
+	if encrypted {
+		address, err := ks.Address()
+		if err != nil {
+			return nil, err
+		}
+		if passphrase, err = keyfile.Passphrase(address.Hex()); err != nil {
+			return nil, err
+		}
+	} else {
+		log := gologger.WithComponent("keystore")
+		log.Warn().Str("keystore", ks.Path()).Msg("The wallet key is stored unencrypted, run 'parity-runner keystore migrate' to encrypt it")
+	}
 
+	privateKey, err := ks.Load(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	keyInstance = privateKey
 	return privateKey, nil
 }
 
@@ -56,11 +132,47 @@ func GetPrivateKeyHex() (string, error) {
 	return common.Bytes2Hex(crypto.FromECDSA(privateKey)), nil
 }
 
-func SavePrivateKey(privateKeyHex string) error {
+// NewKeyPassphrase asks for the passphrase to store a new wallet key with, or
+// returns an empty one when the key goes to the OS secret store
+func NewKeyPassphrase() (string, error) {
+	store, err := GetSecretStore()
+	if err != nil || store != nil {
+		return "", err
+	}
+	return keyfile.NewPassphrase()
+}
+
+// SavePrivateKey stores the wallet key in the OS secret store or, with the
+// file backend, in the keystore encrypted with passphrase
+func SavePrivateKey(privateKeyHex, passphrase string) error {
+	privateKey, err := crypto.HexToECDSA(privateKeyHex)
+	if err != nil {
+		return fmt.Errorf("invalid private key format: %w", err)
+	}
+
+	store, err := GetSecretStore()
+	if err != nil {
+		return err
+	}
 	ks, err := GetKeystore()
 	if err != nil {
 		return err
 	}
+	if store != nil {
+		if err := store.Set(walletKeySecret, privateKeyHex); err != nil {
+			return err
+		}
+		// The key that was in the keystore file has been replaced
+		if err := os.Remove(ks.Path()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove keystore: %w", err)
+		}
+	} else if err := ks.Save(privateKey, passphrase); err != nil {
+		return err
+	}
 
-	return ks.SavePrivateKey(privateKeyHex)
+	keyMutex.Lock()
+	keyInstance = privateKey
+	keyMutex.Unlock()
+	ResetSigner()
+	return nil
 }
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Labels on every container, volume, network and image the runner creates, so
+// operators can audit and remove them with `docker ps --filter label=...` and
+// the runner can tell what it left behind from what belongs to someone else
+const (
+	LabelInstance = "parity.instance"
+	LabelRunnerID = "parity.runner_id"
+	LabelSession  = "parity.session"
+	LabelTaskID   = "parity.task_id"
+
+	defaultInstanceLabel = "default"
+)
+
+var (
+	session = uuid.NewString()
+
+	runnerLabelOnce sync.Once
+	runnerLabel     string
+)
+
+// Session identifies this runner process. It changes every time the runner starts.
+func Session() string {
+	return session
+}
+
+// InstanceLabel is the value of LabelInstance, "default" for the unnamed instance
+func InstanceLabel() string {
+	if instance := Instance(); instance != "" {
+		return instance
+	}
+	return defaultInstanceLabel
+}
+
+// ResourceLabels are the labels of a resource created for taskID, or of a
+// runner-wide resource when taskID is empty. The runner ID is left out when the
+// device ID cannot be read.
+func ResourceLabels(taskID string) map[string]string {
+	runnerLabelOnce.Do(func() {
+		runnerLabel, _ = GetDeviceID()
+	})
+
+	labels := map[string]string{
+		LabelInstance: InstanceLabel(),
+		LabelSession:  session,
+	}
+	if runnerLabel != "" {
+		labels[LabelRunnerID] = runnerLabel
+	}
+	if taskID != "" {
+		labels[LabelTaskID] = taskID
+	}
+	return labels
+}
+
+// LabelArgs turns labels into --label arguments in a stable order
+func LabelArgs(labels map[string]string) []string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	args := make([]string, 0, 2*len(pairs))
+	for _, pair := range pairs {
+		args = append(args, "--label", pair)
+	}
+	return args
+}
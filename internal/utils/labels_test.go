@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestResourceLabels(t *testing.T) {
+	runnerLabelOnce = sync.Once{}
+	t.Cleanup(func() { runnerLabelOnce = sync.Once{} })
+	t.Setenv("RUNNER_DEVICE_ID", "runner-1")
+	t.Cleanup(func() { _ = SetInstance("") })
+	if err := SetInstance("gpu1"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Join(LabelArgs(ResourceLabels("task-1")), " ")
+	want := "--label parity.instance=gpu1 --label parity.runner_id=runner-1 --label parity.session=" + Session() + " --label parity.task_id=task-1"
+	if got != want {
+		t.Fatalf("LabelArgs() = %q, want %q", got, want)
+	}
+	if _, ok := ResourceLabels("")[LabelTaskID]; ok {
+		t.Fatal("runner-wide resources should not name a task")
+	}
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/theblitlabs/parity-runner/internal/signer"
+)
+
+const SignerFileName = "signer.json"
+
+// ErrExternalSigner is returned by commands that need the wallet key on disk
+// when the runner signs with a hardware wallet or remote signer
+var ErrExternalSigner = errors.New("this command needs a wallet key, but the runner signs with an external signer; run 'parity-runner auth --private-key' to use one")
+
+var (
+	signerInstance signer.Signer
+	signerMutex    sync.Mutex
+)
+
+func SignerConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, KeystoreDirName, SignerFileName), nil
+}
+
+// LoadSignerConfig returns the external signer auth set up, or nil when the
+// runner signs with its keystore key
+func LoadSignerConfig() (*signer.Config, error) {
+	path, err := SignerConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return signer.LoadConfig(path)
+}
+
+func SaveSignerConfig(config signer.Config) error {
+	path, err := SignerConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := signer.SaveConfig(path, config); err != nil {
+		return err
+	}
+	ResetSigner()
+	return nil
+}
+
+// RemoveSignerConfig switches the runner back to its keystore key
+func RemoveSignerConfig() error {
+	path, err := SignerConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove signer config: %w", err)
+	}
+	ResetSigner()
+	return nil
+}
+
+// GetSigner returns the signer of the runner's wallet: the external signer
+// auth set up or, without one, the keystore key. An opened hardware wallet is
+// kept for later calls.
+func GetSigner() (signer.Signer, error) {
+	signerMutex.Lock()
+	defer signerMutex.Unlock()
+
+	if signerInstance != nil {
+		return signerInstance, nil
+	}
+
+	config, err := LoadSignerConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		s, err := signer.Open(*config, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", config, err)
+		}
+		signerInstance = s
+		return s, nil
+	}
+
+	key, err := GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no private key found - please authenticate first using 'parity auth'")
+	}
+	signerInstance = signer.FromKey(key)
+	return signerInstance, nil
+}
+
+func ResetSigner() {
+	signerMutex.Lock()
+	defer signerMutex.Unlock()
+	signerInstance = nil
+}
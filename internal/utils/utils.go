@@ -1,19 +1,13 @@
 package utils
 
 import (
-	"encoding/json"
 	"fmt"
 	"math/big"
-	"os"
-	"path/filepath"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
-type KeyStore struct {
-	PrivateKey string `json:"private_key"`
-}
-
 func FormatEther(wei *big.Int) string {
 	ether := new(big.Float).SetInt(wei)
 	ether.Quo(ether, new(big.Float).SetFloat64(1e18))
@@ -21,25 +15,33 @@ func FormatEther(wei *big.Int) string {
 }
 
 func GetWalletAddress() (string, error) {
-	keystoreDir := filepath.Join(os.Getenv("HOME"), ".parity")
-	keystorePath := filepath.Join(keystoreDir, "keystore.json")
-
-	data, err := os.ReadFile(keystorePath)
+	config, err := LoadSignerConfig()
 	if err != nil {
-		return "", fmt.Errorf("failed to read keystore: %w", err)
+		return "", err
 	}
-
-	var keystore KeyStore
-	if err := json.Unmarshal(data, &keystore); err != nil {
-		return "", fmt.Errorf("failed to parse keystore: %w", err)
+	if config != nil && config.Address != "" {
+		return common.HexToAddress(config.Address).Hex(), nil
 	}
 
-	privateKey := keystore.PrivateKey
-	ecdsaKey, err := crypto.HexToECDSA(privateKey)
+	store, err := GetSecretStore()
 	if err != nil {
-		return "", fmt.Errorf("invalid private key in keystore: %w", err)
+		return "", err
+	}
+	if store != nil {
+		key, err := GetPrivateKey()
+		if err != nil {
+			return "", err
+		}
+		return crypto.PubkeyToAddress(key.PublicKey).Hex(), nil
 	}
 
-	address := crypto.PubkeyToAddress(ecdsaKey.PublicKey)
+	ks, err := GetKeystore()
+	if err != nil {
+		return "", err
+	}
+	address, err := ks.Address()
+	if err != nil {
+		return "", fmt.Errorf("failed to read keystore: %w", err)
+	}
 	return address.Hex(), nil
 }
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	walletsdk "github.com/theblitlabs/go-wallet-sdk"
 
 	"github.com/theblitlabs/parity-runner/internal/core/config"
@@ -27,6 +28,12 @@ func NewClient(cfg *config.Config) (*walletsdk.Client, error) {
 		return clientInstance, nil
 	}
 
+	if signerConfig, err := LoadSignerConfig(); err != nil {
+		return nil, err
+	} else if signerConfig != nil {
+		return nil, ErrExternalSigner
+	}
+
 	privateKeyHex, err := GetPrivateKeyHex()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get private key: %w", err)
@@ -52,6 +59,16 @@ func NewClient(cfg *config.Config) (*walletsdk.Client, error) {
 	return client, nil
 }
 
+// NewReadOnlyClient connects to the chain for contract reads without the
+// wallet key. Its Address is a throwaway account, not the runner's wallet.
+func NewReadOnlyClient(cfg *config.Config) (*walletsdk.Client, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate read-only client key: %w", err)
+	}
+	return GetClientWithPrivateKey(cfg, common.Bytes2Hex(crypto.FromECDSA(key)))
+}
+
 func ResetClient() {
 	clientMutex.Lock()
 	defer clientMutex.Unlock()
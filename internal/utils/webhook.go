@@ -2,13 +2,23 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/theblitlabs/parity-runner/internal/tunnel"
 )
 
 var tunnelClient *tunnel.TunnelClient
 
+// DefaultWebhookPath is served when the runner does not randomize its webhook endpoint
+const DefaultWebhookPath = "/webhook"
+
 func GetWebhookURL() string {
+	return GetWebhookURLForPath(DefaultWebhookPath)
+}
+
+// GetWebhookURLForPath returns the URL the server should use to reach the webhook
+// served at path, through the tunnel when one is running
+func GetWebhookURLForPath(path string) string {
 	cfg, err := GetConfig()
 	if err != nil {
 		return ""
@@ -16,11 +26,11 @@ func GetWebhookURL() string {
 
 	// If tunnel is enabled and running, use tunnel URL
 	if cfg.Runner.Tunnel.Enabled && tunnelClient != nil && tunnelClient.IsRunning() {
-		return tunnelClient.GetPublicURL()
+		return strings.TrimSuffix(tunnelClient.GetPublicURL(), DefaultWebhookPath) + path
 	}
 
 	// Fallback to local URL
-	webhookUrl := fmt.Sprintf("http://localhost:%d/webhook", cfg.Runner.WebhookPort)
+	webhookUrl := fmt.Sprintf("http://localhost:%d%s", cfg.Runner.WebhookPort, path)
 	return webhookUrl
 }
 
@@ -0,0 +1,292 @@
+// Package watchdog watches the runner's own goroutines, heap and open files,
+// which grow without bound when something leaks, and asks for the process to be
+// restarted before the leak takes the host down.
+package watchdog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/theblitlabs/gologger"
+
+	"github.com/theblitlabs/parity-runner/internal/utils"
+)
+
+const (
+	diagnosticsDirName = "diagnostics"
+	// keepDiagnostics is how many diagnostics snapshots are kept, oldest
+	// removed first
+	keepDiagnostics = 5
+)
+
+// Config sets the limits of the runner's resource use. A limit must be exceeded
+// in Breaches samples in a row before the watchdog fires, so a burst of work
+// does not restart the runner.
+type Config struct {
+	Interval      time.Duration
+	MaxGoroutines int
+	MaxHeapBytes  uint64
+	MaxOpenFiles  int
+	Breaches      int
+	// DiagnosticsDir receives a snapshot of goroutines and heap when the
+	// watchdog fires
+	DiagnosticsDir string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Interval:      time.Minute,
+		MaxGoroutines: 10000,
+		MaxHeapBytes:  2 << 30,
+		MaxOpenFiles:  4096,
+		Breaches:      3,
+	}
+}
+
+// Sample is the resource use at one point in time. OpenFiles is -1 where the
+// platform does not tell.
+type Sample struct {
+	Goroutines int    `json:"goroutines"`
+	HeapBytes  uint64 `json:"heap_bytes"`
+	OpenFiles  int    `json:"open_files"`
+}
+
+// Breach is what the watchdog reports when it fires
+type Breach struct {
+	Sample Sample
+	// Exceeded names the limits crossed, such as "goroutines"
+	Exceeded []string
+	// Diagnostics is the directory of the snapshot written, empty when it
+	// could not be written
+	Diagnostics string
+}
+
+// Watchdog samples resource use every Interval and calls the OnBreach callback,
+// once, when a limit stays exceeded
+type Watchdog struct {
+	config Config
+
+	mu       sync.Mutex
+	onBreach func(Breach)
+	exceeded int
+	fired    bool
+	sample   func() Sample
+	now      func() time.Time
+}
+
+func New(config Config) *Watchdog {
+	defaults := DefaultConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.MaxGoroutines <= 0 {
+		config.MaxGoroutines = defaults.MaxGoroutines
+	}
+	if config.MaxHeapBytes == 0 {
+		config.MaxHeapBytes = defaults.MaxHeapBytes
+	}
+	if config.MaxOpenFiles <= 0 {
+		config.MaxOpenFiles = defaults.MaxOpenFiles
+	}
+	if config.Breaches <= 0 {
+		config.Breaches = defaults.Breaches
+	}
+	return &Watchdog{config: config, sample: Read, now: time.Now}
+}
+
+// DefaultDiagnosticsDir is where snapshots go unless configured otherwise
+func DefaultDiagnosticsDir() (string, error) {
+	dataDir, err := utils.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, diagnosticsDirName), nil
+}
+
+// OnBreach registers the callback run when a limit stayed exceeded
+func (w *Watchdog) OnBreach(fn func(Breach)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onBreach = fn
+}
+
+// Run samples every Interval until ctx is done or the watchdog fired
+func (w *Watchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if w.Check() {
+				return
+			}
+		}
+	}
+}
+
+// Check takes one sample and reports whether the watchdog fired
+func (w *Watchdog) Check() bool {
+	log := gologger.WithComponent("watchdog")
+
+	w.mu.Lock()
+	if w.fired {
+		w.mu.Unlock()
+		return true
+	}
+	sample := w.sample()
+	exceeded := w.exceededLimits(sample)
+	if len(exceeded) == 0 {
+		w.exceeded = 0
+		w.mu.Unlock()
+		return false
+	}
+	w.exceeded++
+	if w.exceeded < w.config.Breaches {
+		w.mu.Unlock()
+		log.Warn().
+			Strs("exceeded", exceeded).
+			Int("goroutines", sample.Goroutines).
+			Uint64("heap_bytes", sample.HeapBytes).
+			Int("open_files", sample.OpenFiles).
+			Int("samples", w.exceeded).
+			Msg("Runner resource use over its limits")
+		return false
+	}
+	w.fired = true
+	onBreach := w.onBreach
+	at := w.now()
+	w.mu.Unlock()
+
+	breach := Breach{Sample: sample, Exceeded: exceeded}
+	if w.config.DiagnosticsDir != "" {
+		dir, err := writeDiagnostics(w.config.DiagnosticsDir, sample, at)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to write watchdog diagnostics")
+		} else {
+			breach.Diagnostics = dir
+		}
+	}
+	log.Error().
+		Strs("exceeded", exceeded).
+		Int("goroutines", sample.Goroutines).
+		Uint64("heap_bytes", sample.HeapBytes).
+		Int("open_files", sample.OpenFiles).
+		Str("diagnostics", breach.Diagnostics).
+		Msg("Runner resource use stayed over its limits")
+
+	if onBreach != nil {
+		onBreach(breach)
+	}
+	return true
+}
+
+func (w *Watchdog) exceededLimits(sample Sample) []string {
+	var exceeded []string
+	if sample.Goroutines > w.config.MaxGoroutines {
+		exceeded = append(exceeded, "goroutines")
+	}
+	if sample.HeapBytes > w.config.MaxHeapBytes {
+		exceeded = append(exceeded, "heap")
+	}
+	if sample.OpenFiles > w.config.MaxOpenFiles {
+		exceeded = append(exceeded, "open_files")
+	}
+	return exceeded
+}
+
+// Read samples the resource use of this process
+func Read() Sample {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return Sample{
+		Goroutines: runtime.NumGoroutine(),
+		HeapBytes:  memStats.HeapInuse,
+		OpenFiles:  openFiles(),
+	}
+}
+
+// openFiles counts the process's file descriptors where the system lists them
+func openFiles() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := os.ReadDir(dir); err == nil {
+			return len(entries)
+		}
+	}
+	return -1
+}
+
+// writeDiagnostics saves the goroutine stacks, a heap profile and the sample
+// under dir and removes snapshots beyond the last few
+func writeDiagnostics(dir string, sample Sample, at time.Time) (string, error) {
+	snapshot := filepath.Join(dir, at.UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(snapshot, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create diagnostics directory: %w", err)
+	}
+
+	profiles := []struct {
+		name  string
+		file  string
+		debug int
+	}{
+		{"goroutine", "goroutines.txt", 1},
+		{"heap", "heap.pprof", 0},
+	}
+	for _, profile := range profiles {
+		if err := writeProfile(filepath.Join(snapshot, profile.file), profile.name, profile.debug); err != nil {
+			return "", err
+		}
+	}
+
+	data, err := json.MarshalIndent(sample, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal watchdog sample: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(snapshot, "sample.json"), data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write watchdog sample: %w", err)
+	}
+
+	pruneDiagnostics(dir)
+	return snapshot, nil
+}
+
+func writeProfile(path, name string, debug int) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s profile: %w", name, err)
+	}
+	defer file.Close()
+	if err := pprof.Lookup(name).WriteTo(file, debug); err != nil {
+		return fmt.Errorf("failed to write %s profile: %w", name, err)
+	}
+	return nil
+}
+
+func pruneDiagnostics(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var snapshots []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			snapshots = append(snapshots, entry.Name())
+		}
+	}
+	// Snapshot names sort by time
+	sort.Strings(snapshots)
+	for len(snapshots) > keepDiagnostics {
+		_ = os.RemoveAll(filepath.Join(dir, snapshots[0]))
+		snapshots = snapshots[1:]
+	}
+}
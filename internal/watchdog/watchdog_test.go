@@ -0,0 +1,91 @@
+package watchdog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestWatchdog(config Config, samples *[]Sample) *Watchdog {
+	w := New(config)
+	w.sample = func() Sample {
+		sample := (*samples)[0]
+		if len(*samples) > 1 {
+			*samples = (*samples)[1:]
+		}
+		return sample
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	return w
+}
+
+func TestWatchdogFiresAfterConsecutiveBreaches(t *testing.T) {
+	dir := t.TempDir()
+	samples := []Sample{
+		{Goroutines: 500},
+		{Goroutines: 500},
+		{Goroutines: 50},
+		{Goroutines: 500, OpenFiles: 20},
+		{Goroutines: 500, OpenFiles: 20},
+		{Goroutines: 500, OpenFiles: 20},
+	}
+	w := newTestWatchdog(Config{MaxGoroutines: 100, MaxOpenFiles: 10, Breaches: 3, DiagnosticsDir: dir}, &samples)
+
+	var breaches []Breach
+	w.OnBreach(func(breach Breach) { breaches = append(breaches, breach) })
+
+	for i := 0; i < 5; i++ {
+		if w.Check() {
+			t.Fatalf("Check() fired on sample %d; a sample under the limits should reset the count", i)
+		}
+	}
+	if !w.Check() || len(breaches) != 1 {
+		t.Fatalf("Check() did not fire after three breaches in a row, breaches = %+v", breaches)
+	}
+	breach := breaches[0]
+	if len(breach.Exceeded) != 2 || breach.Exceeded[0] != "goroutines" || breach.Exceeded[1] != "open_files" {
+		t.Fatalf("Exceeded = %v, want goroutines and open_files", breach.Exceeded)
+	}
+	for _, name := range []string{"goroutines.txt", "heap.pprof", "sample.json"} {
+		if _, err := os.Stat(filepath.Join(breach.Diagnostics, name)); err != nil {
+			t.Fatalf("diagnostics %s missing: %v", name, err)
+		}
+	}
+
+	if !w.Check() || len(breaches) != 1 {
+		t.Fatalf("Check() after firing ran the callback again, breaches = %d", len(breaches))
+	}
+}
+
+func TestDiagnosticsKeepOnlyTheLatestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < keepDiagnostics+2; i++ {
+		if _, err := writeDiagnostics(dir, Sample{}, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("writeDiagnostics() error = %v", err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != keepDiagnostics || entries[0].Name() != start.Add(2*time.Minute).Format("20060102T150405Z") {
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+		}
+		t.Fatalf("snapshots = %v, want the latest %d", names, keepDiagnostics)
+	}
+}
+
+func TestReadSamplesThisProcess(t *testing.T) {
+	sample := Read()
+	if sample.Goroutines < 1 || sample.HeapBytes == 0 {
+		t.Fatalf("Read() = %+v", sample)
+	}
+	if sample.OpenFiles == 0 {
+		t.Fatalf("Read() found no open files: %+v", sample)
+	}
+}
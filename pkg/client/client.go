@@ -0,0 +1,290 @@
+// Package client is a Go SDK for the Parity coordinator API. It wraps task
+// creation, status polling and result retrieval and exposes the protocol models.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// ProtocolVersion is the coordinator API version this SDK speaks
+	ProtocolVersion = "v1"
+	// Version is the SDK release, bumped together with ProtocolVersion on breaking changes
+	Version = "1.0.0"
+
+	defaultTimeout      = 30 * time.Second
+	defaultPollInterval = 2 * time.Second
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	deviceID   string
+	userAgent  string
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithDeviceID sets the X-Device-ID header sent with every request
+func WithDeviceID(deviceID string) Option {
+	return func(c *Client) {
+		c.deviceID = deviceID
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/api"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		userAgent:  "parity-go-client/" + Version,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is returned when the coordinator answers with a non-success status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("parity api returned status %d: %s", e.StatusCode, e.Body)
+}
+
+func (c *Client) CreateTask(ctx context.Context, req CreateTaskRequest) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPost, "/tasks", req, &task); err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+	return &task, nil
+}
+
+// EstimateTask suggests a minimum reward for a task class from current supply
+// and demand
+func (c *Client) EstimateTask(ctx context.Context, class TaskClass) (*PriceSuggestion, error) {
+	var suggestion PriceSuggestion
+	if err := c.do(ctx, http.MethodPost, "/tasks/estimate", class, &suggestion); err != nil {
+		return nil, fmt.Errorf("failed to estimate task: %w", err)
+	}
+	return &suggestion, nil
+}
+
+// CreateTaskWithPreflight creates a task that is only queued once a runner has
+// checked its config, image and data. It returns the task and the ID of the
+// preflight task; GetPreflight reports the outcome.
+func (c *Client) CreateTaskWithPreflight(ctx context.Context, req CreateTaskRequest) (*Task, string, error) {
+	var response struct {
+		Task            Task   `json:"task"`
+		PreflightTaskID string `json:"preflight_task_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/tasks?preflight=true", req, &response); err != nil {
+		return nil, "", fmt.Errorf("failed to create task: %w", err)
+	}
+	return &response.Task, response.PreflightTaskID, nil
+}
+
+func (c *Client) GetPreflight(ctx context.Context, taskID string) (*PreflightStatus, error) {
+	var status PreflightStatus
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+taskID+"/preflight", nil, &status); err != nil {
+		return nil, fmt.Errorf("failed to get preflight: %w", err)
+	}
+	return &status, nil
+}
+
+func (c *Client) GetTask(ctx context.Context, taskID string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+taskID, nil, &task); err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	return &task, nil
+}
+
+// RevokeAssignment takes a running task back from a runner that let its claim
+// lapse without progress and queues it again. Only the task's creator may revoke;
+// the task's Assignment shows when the claim lapses.
+func (c *Client) RevokeAssignment(ctx context.Context, taskID string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPost, "/tasks/"+taskID+"/assignment/revoke", nil, &task); err != nil {
+		return nil, fmt.Errorf("failed to revoke task assignment: %w", err)
+	}
+	return &task, nil
+}
+
+// CancelTask stops a task. A queued task is withdrawn, a running one is stopped
+// by its runner, which then reports it with the cancelled status. Only the
+// task's creator may cancel it.
+func (c *Client) CancelTask(ctx context.Context, taskID string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPost, "/tasks/"+taskID+"/cancel", nil, &task); err != nil {
+		return nil, fmt.Errorf("failed to cancel task: %w", err)
+	}
+	return &task, nil
+}
+
+func (c *Client) GetTaskResult(ctx context.Context, taskID string) (*TaskResult, error) {
+	var result TaskResult
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+taskID+"/result", nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to get task result: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *Client) GetTaskMetrics(ctx context.Context, taskID string) (*TaskMetrics, error) {
+	var metrics TaskMetrics
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+taskID+"/metrics", nil, &metrics); err != nil {
+		return nil, fmt.Errorf("failed to get task metrics: %w", err)
+	}
+	return &metrics, nil
+}
+
+func (c *Client) GetReceipt(ctx context.Context, taskID string) (*ExecutionReceipt, error) {
+	var receipt ExecutionReceipt
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+taskID+"/receipt", nil, &receipt); err != nil {
+		return nil, fmt.Errorf("failed to get task receipt: %w", err)
+	}
+	return &receipt, nil
+}
+
+func (c *Client) CreateExperiment(ctx context.Context, req CreateExperimentRequest) (*ExperimentSummary, error) {
+	var summary ExperimentSummary
+	if err := c.do(ctx, http.MethodPost, "/experiments", req, &summary); err != nil {
+		return nil, fmt.Errorf("failed to create experiment: %w", err)
+	}
+	return &summary, nil
+}
+
+func (c *Client) AddExperimentTasks(ctx context.Context, experimentID string, tasks []CreateTaskRequest) (*ExperimentSummary, error) {
+	var summary ExperimentSummary
+	body := map[string]interface{}{"tasks": tasks}
+	if err := c.do(ctx, http.MethodPost, "/experiments/"+experimentID+"/tasks", body, &summary); err != nil {
+		return nil, fmt.Errorf("failed to add experiment tasks: %w", err)
+	}
+	return &summary, nil
+}
+
+func (c *Client) GetExperiment(ctx context.Context, experimentID string) (*ExperimentSummary, error) {
+	var summary ExperimentSummary
+	if err := c.do(ctx, http.MethodGet, "/experiments/"+experimentID, nil, &summary); err != nil {
+		return nil, fmt.Errorf("failed to get experiment: %w", err)
+	}
+	return &summary, nil
+}
+
+// CancelExperiment withdraws the experiment's queued tasks. Tasks already running
+// finish and are still reported.
+func (c *Client) CancelExperiment(ctx context.Context, experimentID string) (*ExperimentSummary, error) {
+	var summary ExperimentSummary
+	if err := c.do(ctx, http.MethodPost, "/experiments/"+experimentID+"/cancel", nil, &summary); err != nil {
+		return nil, fmt.Errorf("failed to cancel experiment: %w", err)
+	}
+	return &summary, nil
+}
+
+// WaitForTask polls the task until it reaches a terminal status or the context ends.
+// A non-positive interval uses the default poll interval.
+func (c *Client) WaitForTask(ctx context.Context, taskID string, interval time.Duration) (*Task, error) {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		task, err := c.GetTask(ctx, taskID)
+		if err != nil {
+			return nil, err
+		}
+		if IsTerminal(task.Status) {
+			return task, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return task, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunTask creates a task, waits for it to finish and returns its result
+func (c *Client) RunTask(ctx context.Context, req CreateTaskRequest, interval time.Duration) (*TaskResult, error) {
+	task, err := c.CreateTask(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.WaitForTask(ctx, task.ID.String(), interval); err != nil {
+		return nil, err
+	}
+
+	return c.GetTaskResult(ctx, task.ID.String())
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	url := fmt.Sprintf("%s/api/%s%s", c.baseURL, ProtocolVersion, path)
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.deviceID != "" {
+		req.Header.Set("X-Device-ID", c.deviceID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
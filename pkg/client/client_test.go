@@ -0,0 +1,126 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+
+	"github.com/theblitlabs/parity-runner/internal/identity"
+)
+
+func TestRunTaskPollsUntilCompletion(t *testing.T) {
+	taskID := uuid.New()
+	var polls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Device-ID") != "creator-device" {
+			t.Errorf("missing device header")
+		}
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/tasks":
+			var req CreateTaskRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("failed to decode create request: %v", err)
+			}
+			json.NewEncoder(w).Encode(Task{ID: taskID, Title: req.Title, Type: req.Type, Status: TaskStatusPending})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/tasks/"+taskID.String():
+			status := TaskStatusRunning
+			if polls.Add(1) >= 2 {
+				status = TaskStatusCompleted
+			}
+			json.NewEncoder(w).Encode(Task{ID: taskID, Status: status})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/tasks/"+taskID.String()+"/result":
+			json.NewEncoder(w).Encode(TaskResult{TaskID: taskID, Output: "hello"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL+"/api", WithDeviceID("creator-device"))
+	result, err := c.RunTask(context.Background(), CreateTaskRequest{
+		Title:  "echo",
+		Type:   TaskTypeCommand,
+		Config: json.RawMessage(`{"command":["echo","hello"]}`),
+	}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("RunTask failed: %v", err)
+	}
+
+	if result.Output != "hello" || polls.Load() < 2 {
+		t.Fatalf("unexpected result %q after %d polls", result.Output, polls.Load())
+	}
+}
+
+func TestClientReturnsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).GetTask(context.Background(), "missing")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected APIError with 404, got %v", err)
+	}
+}
+
+func TestParseWebhookRequestVerifiesSignature(t *testing.T) {
+	body := []byte(`{"type":"task_completed","payload":{"id":"123"}}`)
+	serverKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverAddress := crypto.PubkeyToAddress(serverKey.PublicKey).Hex()
+
+	signed := func(key *ecdsa.PrivateKey, deviceID string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/hooks", bytes.NewReader(body))
+		if err := identity.NewSigner(key).SignWebhook(req.Header, deviceID, body); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	req := signed(serverKey, "integrator-1")
+	replay := req.Clone(context.Background())
+	replay.Body = io.NopCloser(bytes.NewReader(body))
+	event, err := ParseWebhookRequest(req, serverAddress, "integrator-1")
+	if err != nil {
+		t.Fatalf("expected valid signature: %v", err)
+	}
+	if event.Type != "task_completed" {
+		t.Fatalf("unexpected event type %q", event.Type)
+	}
+	if _, err := ParseWebhookRequest(replay, serverAddress, "integrator-1"); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for a replayed delivery, got %v", err)
+	}
+
+	if _, err := ParseWebhookRequest(signed(serverKey, "integrator-2"), serverAddress, "integrator-1"); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for a delivery to another device, got %v", err)
+	}
+	if _, err := ParseWebhookRequest(signed(otherKey, "integrator-1"), serverAddress, "integrator-1"); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for another signer, got %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/hooks", bytes.NewReader(body))
+	if _, err := ParseWebhookRequest(req, serverAddress, "integrator-1"); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for an unsigned delivery, got %v", err)
+	}
+}
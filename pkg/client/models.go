@@ -0,0 +1,113 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// Typed models shared with the runner and coordinator. They are aliases so values
+// decoded by the SDK can be passed straight to code that uses the protocol types.
+type (
+	Task              = models.Task
+	TaskType          = models.TaskType
+	TaskStatus        = models.TaskStatus
+	TaskConfig        = models.TaskConfig
+	ResourceConfig    = models.ResourceConfig
+	TaskResult        = models.TaskResult
+	TaskMetrics       = models.TaskMetrics
+	EnvironmentConfig = models.EnvironmentConfig
+	Labels            = models.Labels
+	ExecutionReceipt  = models.ExecutionReceipt
+	Experiment        = models.Experiment
+	ExperimentSummary = models.ExperimentSummary
+	GPURequirements   = models.GPURequirements
+	IsolationLevel    = models.IsolationLevel
+	PreflightReport   = models.PreflightReport
+	PreflightCheck    = models.PreflightCheck
+	TaskAssignment    = models.TaskAssignment
+	ReputationTier    = models.ReputationTier
+)
+
+const (
+	TaskTypeDocker            = models.TaskTypeDocker
+	TaskTypeCommand           = models.TaskTypeCommand
+	TaskTypeLLM               = models.TaskTypeLLM
+	TaskTypeFederatedLearning = models.TaskTypeFederatedLearning
+	TaskTypeWasm              = models.TaskTypeWasm
+	TaskTypePreflight         = models.TaskTypePreflight
+
+	TaskStatusPending   = models.TaskStatusPending
+	TaskStatusRunning   = models.TaskStatusRunning
+	TaskStatusCompleted = models.TaskStatusCompleted
+	TaskStatusFailed    = models.TaskStatusFailed
+	TaskStatusCancelled = models.TaskStatusCancelled
+
+	IsolationContainer = models.IsolationContainer
+	IsolationVM        = models.IsolationVM
+)
+
+// CreateTaskRequest is the payload accepted by the task creation endpoint
+type CreateTaskRequest struct {
+	Title          string             `json:"title"`
+	Description    string             `json:"description,omitempty"`
+	Type           TaskType           `json:"type"`
+	Config         json.RawMessage    `json:"config"`
+	Environment    *EnvironmentConfig `json:"environment,omitempty"`
+	Reward         float64            `json:"reward,omitempty"`
+	Labels         Labels             `json:"labels,omitempty"`
+	CreatorAddress string             `json:"creator_address,omitempty"`
+	GPU            *GPURequirements   `json:"gpu,omitempty"`
+	IsolationLevel IsolationLevel     `json:"isolation_level,omitempty"`
+}
+
+// TaskClass groups tasks that cost roughly the same to run, for reward estimates
+type TaskClass struct {
+	Type                   TaskType `json:"type"`
+	ImageSizeMB            float64  `json:"image_size_mb,omitempty"`
+	ExpectedRuntimeSeconds float64  `json:"expected_runtime_seconds,omitempty"`
+	Model                  string   `json:"model,omitempty"`
+}
+
+// PriceSuggestion is the minimum reward the coordinator suggests for a task
+// class. With FloorEnforced, tasks paying less are refused.
+type PriceSuggestion struct {
+	Class              TaskClass `json:"class"`
+	BaseReward         float64   `json:"base_reward"`
+	DemandMultiplier   float64   `json:"demand_multiplier"`
+	CompletionRate     float64   `json:"completion_rate"`
+	SuggestedMinReward float64   `json:"suggested_min_reward"`
+	FloorEnforced      bool      `json:"floor_enforced"`
+}
+
+// PreflightStatus is where a task created with preflight stands. State is
+// "pending" until a runner has checked the task, then "passed", after which the
+// task is queued, or "failed" with the problems found.
+type PreflightStatus struct {
+	TaskID          string           `json:"task_id"`
+	PreflightTaskID string           `json:"preflight_task_id"`
+	State           string           `json:"state"`
+	Report          *PreflightReport `json:"report,omitempty"`
+	Problems        []string         `json:"problems,omitempty"`
+}
+
+// CreateExperimentRequest groups tasks, e.g. one per dataset shard, under one
+// experiment. Tasks inherit the creator address and labels when they set none.
+type CreateExperimentRequest struct {
+	Name           string              `json:"name"`
+	Description    string              `json:"description,omitempty"`
+	CreatorAddress string              `json:"creator_address,omitempty"`
+	Labels         Labels              `json:"labels,omitempty"`
+	Tasks          []CreateTaskRequest `json:"tasks"`
+}
+
+// WebhookEvent is a notification delivered by the coordinator to an integrator endpoint
+type WebhookEvent struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// IsTerminal reports whether a task has finished executing
+func IsTerminal(status TaskStatus) bool {
+	return status == TaskStatusCompleted || status == TaskStatusFailed || status == TaskStatusCancelled
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/theblitlabs/parity-runner/internal/identity"
+)
+
+const (
+	// SignatureHeader carries the server's secp256k1 signature over the
+	// webhook body and TimestampHeader, made with its identity key
+	SignatureHeader = identity.SignatureHeader
+	TimestampHeader = identity.TimestampHeader
+	// NonceHeader carries the value the server picked for the delivery. Each
+	// nonce is accepted once.
+	NonceHeader = identity.DeliveryNonceHeader
+
+	maxWebhookBodySize = 10 << 20
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// webhookVerifiers keeps one verifier per server address, so the nonces of
+// earlier deliveries are remembered across calls
+var (
+	webhookVerifiersMu sync.Mutex
+	webhookVerifiers   = make(map[string]*identity.Verifier)
+)
+
+func webhookVerifier(serverAddress string) (*identity.Verifier, error) {
+	webhookVerifiersMu.Lock()
+	defer webhookVerifiersMu.Unlock()
+
+	key := strings.ToLower(serverAddress)
+	if verifier, ok := webhookVerifiers[key]; ok {
+		return verifier, nil
+	}
+	verifier, err := identity.NewVerifier(serverAddress)
+	if err != nil {
+		return nil, err
+	}
+	webhookVerifiers[key] = verifier
+	return verifier, nil
+}
+
+// VerifyWebhookSignature checks that the signature headers of a delivery were
+// made over payload for deviceID, the recipient, by serverAddress, the address
+// the server publishes at /api/v1/identity. A delivery whose nonce was already
+// accepted is rejected as a replay.
+func VerifyWebhookSignature(header http.Header, payload []byte, serverAddress, deviceID string) error {
+	verifier, err := webhookVerifier(serverAddress)
+	if err != nil {
+		return err
+	}
+	if err := verifier.VerifyWebhook(header, deviceID, payload); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+// ParseWebhookRequest reads, verifies and decodes a webhook delivery to
+// deviceID signed by serverAddress
+func ParseWebhookRequest(req *http.Request, serverAddress, deviceID string) (*WebhookEvent, error) {
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+	defer req.Body.Close()
+
+	if err := VerifyWebhookSignature(req.Header, body, serverAddress, deviceID); err != nil {
+		return nil, err
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook event: %w", err)
+	}
+	return &event, nil
+}
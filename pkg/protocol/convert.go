@@ -0,0 +1,556 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+var taskTypes = map[models.TaskType]TaskType{
+	models.TaskTypeDocker:            TaskType_TASK_TYPE_DOCKER,
+	models.TaskTypeCommand:           TaskType_TASK_TYPE_COMMAND,
+	models.TaskTypeLLM:               TaskType_TASK_TYPE_LLM,
+	models.TaskTypeFederatedLearning: TaskType_TASK_TYPE_FEDERATED_LEARNING,
+	models.TaskTypeWasm:              TaskType_TASK_TYPE_WASM,
+	models.TaskTypePreflight:         TaskType_TASK_TYPE_PREFLIGHT,
+}
+
+var taskStatuses = map[models.TaskStatus]TaskStatus{
+	models.TaskStatusPending:   TaskStatus_TASK_STATUS_PENDING,
+	models.TaskStatusRunning:   TaskStatus_TASK_STATUS_RUNNING,
+	models.TaskStatusCompleted: TaskStatus_TASK_STATUS_COMPLETED,
+	models.TaskStatusFailed:    TaskStatus_TASK_STATUS_FAILED,
+	models.TaskStatusCancelled: TaskStatus_TASK_STATUS_CANCELLED,
+}
+
+var runnerStatuses = map[models.RunnerStatus]RunnerStatus{
+	models.RunnerStatusOnline:  RunnerStatus_RUNNER_STATUS_ONLINE,
+	models.RunnerStatusOffline: RunnerStatus_RUNNER_STATUS_OFFLINE,
+	models.RunnerStatusBusy:    RunnerStatus_RUNNER_STATUS_BUSY,
+}
+
+func FromTaskType(taskType models.TaskType) TaskType {
+	return taskTypes[taskType]
+}
+
+func (t TaskType) Model() models.TaskType {
+	for model, value := range taskTypes {
+		if value == t {
+			return model
+		}
+	}
+	return ""
+}
+
+func FromTaskStatus(status models.TaskStatus) TaskStatus {
+	return taskStatuses[status]
+}
+
+func (s TaskStatus) Model() models.TaskStatus {
+	for model, value := range taskStatuses {
+		if value == s {
+			return model
+		}
+	}
+	return ""
+}
+
+func FromRunnerStatus(status models.RunnerStatus) RunnerStatus {
+	return runnerStatuses[status]
+}
+
+func (s RunnerStatus) Model() models.RunnerStatus {
+	for model, value := range runnerStatuses {
+		if value == s {
+			return model
+		}
+	}
+	return ""
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// marshalDocument encodes one of the JSON documents carried as bytes, leaving
+// nil documents empty
+func marshalDocument[T any](name string, doc *T) ([]byte, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	return data, nil
+}
+
+func unmarshalDocument[T any](name string, data []byte) (*T, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var doc T
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return &doc, nil
+}
+
+func FromTask(task *models.Task) (*Task, error) {
+	msg := &Task{
+		Id:                 task.ID.String(),
+		Title:              task.Title,
+		Description:        task.Description,
+		Type:               FromTaskType(task.Type),
+		Status:             FromTaskStatus(task.Status),
+		Config:             task.Config,
+		Labels:             task.Labels,
+		MaxDurationSeconds: task.MaxDurationSecs,
+		Reward:             task.Reward,
+		RequiredStake:      task.RequiredStake,
+		CreatorAddress:     task.CreatorAddress,
+		RunnerId:           task.RunnerID,
+		Nonce:              task.Nonce,
+		IsolationLevel:     string(task.IsolationLevel),
+		CreatedAt:          timestamp(task.CreatedAt),
+		UpdatedAt:          timestamp(task.UpdatedAt),
+	}
+
+	environment, err := marshalDocument("environment", task.Environment)
+	if err != nil {
+		return nil, err
+	}
+	msg.Environment = environment
+	if msg.Requirements, err = marshalDocument("requirements", task.Requirements); err != nil {
+		return nil, err
+	}
+	if msg.Assignment, err = marshalDocument("assignment", task.Assignment); err != nil {
+		return nil, err
+	}
+
+	if task.ExperimentID != nil {
+		msg.ExperimentId = task.ExperimentID.String()
+	}
+	if task.GPU != nil {
+		msg.Gpu = &GPURequirements{
+			Count:       int32(task.GPU.Count),
+			Model:       task.GPU.Model,
+			MinMemoryMb: task.GPU.MinMemoryMB,
+		}
+	}
+	if task.CompletedAt != nil {
+		msg.CompletedAt = timestamppb.New(*task.CompletedAt)
+	}
+	return msg, nil
+}
+
+func (t *Task) Model() (*models.Task, error) {
+	id, err := uuid.Parse(t.GetId())
+	if err != nil {
+		return nil, fmt.Errorf("invalid task ID %q: %w", t.GetId(), err)
+	}
+
+	task := &models.Task{
+		ID:              id,
+		Title:           t.GetTitle(),
+		Description:     t.GetDescription(),
+		Type:            t.GetType().Model(),
+		Status:          t.GetStatus().Model(),
+		Config:          t.GetConfig(),
+		Labels:          t.GetLabels(),
+		MaxDurationSecs: t.GetMaxDurationSeconds(),
+		Reward:          t.GetReward(),
+		RequiredStake:   t.GetRequiredStake(),
+		CreatorAddress:  t.GetCreatorAddress(),
+		RunnerID:        t.GetRunnerId(),
+		Nonce:           t.GetNonce(),
+		IsolationLevel:  models.IsolationLevel(t.GetIsolationLevel()),
+	}
+
+	task.Environment, err = unmarshalDocument[models.EnvironmentConfig]("environment", t.GetEnvironment())
+	if err != nil {
+		return nil, err
+	}
+	if task.Requirements, err = unmarshalDocument[models.CapabilityRequirements]("requirements", t.GetRequirements()); err != nil {
+		return nil, err
+	}
+	if task.Assignment, err = unmarshalDocument[models.TaskAssignment]("assignment", t.GetAssignment()); err != nil {
+		return nil, err
+	}
+	if t.GetExperimentId() != "" {
+		experimentID, err := uuid.Parse(t.GetExperimentId())
+		if err != nil {
+			return nil, fmt.Errorf("invalid experiment ID %q: %w", t.GetExperimentId(), err)
+		}
+		task.ExperimentID = &experimentID
+	}
+	if gpu := t.GetGpu(); gpu != nil {
+		task.GPU = &models.GPURequirements{
+			Count:       int(gpu.GetCount()),
+			Model:       gpu.GetModel(),
+			MinMemoryMB: gpu.GetMinMemoryMb(),
+		}
+	}
+	if t.GetCreatedAt() != nil {
+		task.CreatedAt = t.GetCreatedAt().AsTime()
+	}
+	if t.GetUpdatedAt() != nil {
+		task.UpdatedAt = t.GetUpdatedAt().AsTime()
+	}
+	if t.GetCompletedAt() != nil {
+		completedAt := t.GetCompletedAt().AsTime()
+		task.CompletedAt = &completedAt
+	}
+	return task, nil
+}
+
+func FromTaskResult(result *models.TaskResult) (*TaskResult, error) {
+	msg := &TaskResult{
+		TaskId:              result.TaskID.String(),
+		DeviceId:            result.DeviceID,
+		DeviceIdHash:        result.DeviceIDHash,
+		RunnerAddress:       result.RunnerAddress,
+		CreatorAddress:      result.CreatorAddress,
+		CreatorDeviceId:     result.CreatorDeviceID,
+		SolverDeviceId:      result.SolverDeviceID,
+		Output:              result.Output,
+		Error:               result.Error,
+		ExitCode:            int32(result.ExitCode),
+		ExecutionTime:       result.ExecutionTime,
+		ResultHash:          result.ResultHash,
+		ImageHashVerified:   result.ImageHashVerified,
+		CommandHashVerified: result.CommandHashVerified,
+		BuildVerified:       result.BuildVerified,
+		Reward:              result.Reward,
+		CpuSeconds:          result.CPUSeconds,
+		EstimatedCycles:     result.EstimatedCycles,
+		MemoryGbHours:       result.MemoryGBHours,
+		PeakMemoryGb:        result.PeakMemoryGB,
+		StorageGb:           result.StorageGB,
+		NetworkDataGb:       result.NetworkDataGB,
+		PromptTokens:        int32(result.PromptTokens),
+		ResponseTokens:      int32(result.ResponseTokens),
+		InferenceTimeMs:     result.InferenceTime,
+		FuelUsed:            result.FuelUsed,
+		Status:              string(result.Status),
+		CreatedAt:           timestamp(result.CreatedAt),
+	}
+	if result.ID != uuid.Nil {
+		msg.Id = result.ID.String()
+	}
+
+	var err error
+	if msg.Receipt, err = marshalDocument("receipt", result.Receipt); err != nil {
+		return nil, err
+	}
+	if msg.Egress, err = marshalDocument("egress summary", result.Egress); err != nil {
+		return nil, err
+	}
+	if msg.Sealed, err = marshalDocument("sealed payload", result.Sealed); err != nil {
+		return nil, err
+	}
+	if msg.Checkpoint, err = marshalDocument("checkpoint summary", result.Checkpoint); err != nil {
+		return nil, err
+	}
+	if msg.Uploads, err = marshalDocument("result uploads", result.Uploads); err != nil {
+		return nil, err
+	}
+	if msg.Deterministic, err = marshalDocument("deterministic summary", result.Deterministic); err != nil {
+		return nil, err
+	}
+	if msg.Attestation, err = marshalDocument("attestation quote", result.Attestation); err != nil {
+		return nil, err
+	}
+	if msg.Diagnostics, err = marshalDocument("failure diagnostics", result.Diagnostics); err != nil {
+		return nil, err
+	}
+	if len(result.ExecutionLog) > 0 {
+		if msg.ExecutionLog, err = marshalDocument("execution log", &result.ExecutionLog); err != nil {
+			return nil, err
+		}
+	}
+	if len(result.Attempts) > 0 {
+		if msg.Attempts, err = marshalDocument("task attempts", &result.Attempts); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+func (r *TaskResult) Model() (*models.TaskResult, error) {
+	result := &models.TaskResult{
+		DeviceID:            r.GetDeviceId(),
+		DeviceIDHash:        r.GetDeviceIdHash(),
+		RunnerAddress:       r.GetRunnerAddress(),
+		CreatorAddress:      r.GetCreatorAddress(),
+		CreatorDeviceID:     r.GetCreatorDeviceId(),
+		SolverDeviceID:      r.GetSolverDeviceId(),
+		Output:              r.GetOutput(),
+		Error:               r.GetError(),
+		ExitCode:            int(r.GetExitCode()),
+		ExecutionTime:       r.GetExecutionTime(),
+		ResultHash:          r.GetResultHash(),
+		ImageHashVerified:   r.GetImageHashVerified(),
+		CommandHashVerified: r.GetCommandHashVerified(),
+		BuildVerified:       r.GetBuildVerified(),
+		Reward:              r.GetReward(),
+		CPUSeconds:          r.GetCpuSeconds(),
+		EstimatedCycles:     r.GetEstimatedCycles(),
+		MemoryGBHours:       r.GetMemoryGbHours(),
+		PeakMemoryGB:        r.GetPeakMemoryGb(),
+		StorageGB:           r.GetStorageGb(),
+		NetworkDataGB:       r.GetNetworkDataGb(),
+		PromptTokens:        int(r.GetPromptTokens()),
+		ResponseTokens:      int(r.GetResponseTokens()),
+		InferenceTime:       r.GetInferenceTimeMs(),
+		FuelUsed:            r.GetFuelUsed(),
+		Status:              models.TaskStatus(r.GetStatus()),
+	}
+	if r.GetId() != "" {
+		id, err := uuid.Parse(r.GetId())
+		if err != nil {
+			return nil, fmt.Errorf("invalid result ID %q: %w", r.GetId(), err)
+		}
+		result.ID = id
+	}
+	if r.GetTaskId() != "" {
+		taskID, err := uuid.Parse(r.GetTaskId())
+		if err != nil {
+			return nil, fmt.Errorf("invalid task ID %q: %w", r.GetTaskId(), err)
+		}
+		result.TaskID = taskID
+	}
+
+	var err error
+	if result.Receipt, err = unmarshalDocument[models.ExecutionReceipt]("receipt", r.GetReceipt()); err != nil {
+		return nil, err
+	}
+	if result.Egress, err = unmarshalDocument[models.EgressSummary]("egress summary", r.GetEgress()); err != nil {
+		return nil, err
+	}
+	if result.Sealed, err = unmarshalDocument[models.SealedPayload]("sealed payload", r.GetSealed()); err != nil {
+		return nil, err
+	}
+	if result.Checkpoint, err = unmarshalDocument[models.CheckpointSummary]("checkpoint summary", r.GetCheckpoint()); err != nil {
+		return nil, err
+	}
+	if result.Uploads, err = unmarshalDocument[models.ResultUploads]("result uploads", r.GetUploads()); err != nil {
+		return nil, err
+	}
+	if result.Deterministic, err = unmarshalDocument[models.DeterministicSummary]("deterministic summary", r.GetDeterministic()); err != nil {
+		return nil, err
+	}
+	if result.Attestation, err = unmarshalDocument[models.AttestationQuote]("attestation quote", r.GetAttestation()); err != nil {
+		return nil, err
+	}
+	if result.Diagnostics, err = unmarshalDocument[models.FailureDiagnostics]("failure diagnostics", r.GetDiagnostics()); err != nil {
+		return nil, err
+	}
+	executionLog, err := unmarshalDocument[models.ExecutionLog]("execution log", r.GetExecutionLog())
+	if err != nil {
+		return nil, err
+	}
+	if executionLog != nil {
+		result.ExecutionLog = *executionLog
+	}
+	attempts, err := unmarshalDocument[models.TaskAttempts]("task attempts", r.GetAttempts())
+	if err != nil {
+		return nil, err
+	}
+	if attempts != nil {
+		result.Attempts = *attempts
+	}
+	if r.GetCreatedAt() != nil {
+		result.CreatedAt = r.GetCreatedAt().AsTime()
+	}
+	return result, nil
+}
+
+func FromRunnerRegistration(registration *models.RunnerRegistration) (*RunnerRegistration, error) {
+	msg := &RunnerRegistration{
+		WalletAddress: registration.WalletAddress,
+		Status:        FromRunnerStatus(registration.Status),
+		Webhook:       registration.Webhook,
+		WebhookToken:  registration.WebhookToken,
+		AcceptLabels:  registration.AcceptLabels,
+
+		SettlementChainId: registration.SettlementChainID,
+	}
+	for _, capability := range registration.ModelCapabilities {
+		msg.ModelCapabilities = append(msg.ModelCapabilities, &ModelCapability{
+			ModelName: capability.ModelName,
+			IsLoaded:  capability.IsLoaded,
+			MaxTokens: int32(capability.MaxTokens),
+		})
+	}
+	var err error
+	if msg.SandboxBenchmark, err = marshalDocument("sandbox benchmark", registration.SandboxBenchmark); err != nil {
+		return nil, err
+	}
+	if msg.Manifest, err = marshalDocument("manifest", registration.Manifest); err != nil {
+		return nil, err
+	}
+	if msg.Capabilities, err = marshalDocument("capability profile", registration.Capabilities); err != nil {
+		return nil, err
+	}
+	if msg.Benchmark, err = marshalDocument("benchmark report", registration.Benchmark); err != nil {
+		return nil, err
+	}
+	if msg.Build, err = marshalDocument("build provenance", registration.Build); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (r *RunnerRegistration) Model() (*models.RunnerRegistration, error) {
+	registration := &models.RunnerRegistration{
+		WalletAddress: r.GetWalletAddress(),
+		Status:        r.GetStatus().Model(),
+		Webhook:       r.GetWebhook(),
+		WebhookToken:  r.GetWebhookToken(),
+		AcceptLabels:  r.GetAcceptLabels(),
+
+		SettlementChainID: r.GetSettlementChainId(),
+	}
+	for _, capability := range r.GetModelCapabilities() {
+		registration.ModelCapabilities = append(registration.ModelCapabilities, models.ModelCapability{
+			ModelName: capability.GetModelName(),
+			IsLoaded:  capability.GetIsLoaded(),
+			MaxTokens: int(capability.GetMaxTokens()),
+		})
+	}
+	var err error
+	if registration.SandboxBenchmark, err = unmarshalDocument[models.SandboxBenchmark]("sandbox benchmark", r.GetSandboxBenchmark()); err != nil {
+		return nil, err
+	}
+	if registration.Manifest, err = unmarshalDocument[models.RunnerManifest]("manifest", r.GetManifest()); err != nil {
+		return nil, err
+	}
+	if registration.Capabilities, err = unmarshalDocument[models.CapabilityProfile]("capability profile", r.GetCapabilities()); err != nil {
+		return nil, err
+	}
+	if registration.Benchmark, err = unmarshalDocument[models.BenchmarkReport]("benchmark report", r.GetBenchmark()); err != nil {
+		return nil, err
+	}
+	if registration.Build, err = unmarshalDocument[models.BuildProvenance]("build provenance", r.GetBuild()); err != nil {
+		return nil, err
+	}
+	return registration, nil
+}
+
+func FromHeartbeat(heartbeat *models.Heartbeat) (*Heartbeat, error) {
+	msg := &Heartbeat{
+		WalletAddress: heartbeat.WalletAddress,
+		Status:        FromRunnerStatus(heartbeat.Status),
+		Timestamp:     heartbeat.Timestamp,
+		Uptime:        heartbeat.Uptime,
+		PublicIp:      heartbeat.PublicIP,
+		MemoryUsage:   heartbeat.MemoryUsage,
+		MemoryTotal:   heartbeat.MemoryTotal,
+		CpuUsage:      heartbeat.CPUUsage,
+		DiskUsage:     heartbeat.DiskUsage,
+		DiskTotal:     heartbeat.DiskTotal,
+		Load_1:        heartbeat.Load1,
+		Load_5:        heartbeat.Load5,
+		Load_15:       heartbeat.Load15,
+	}
+	for _, gpu := range heartbeat.GPUs {
+		msg.Gpus = append(msg.Gpus, &GPU{
+			Index:    int32(gpu.Index),
+			Uuid:     gpu.UUID,
+			Model:    gpu.Model,
+			MemoryMb: gpu.MemoryMB,
+		})
+	}
+	if settings := heartbeat.Settings; settings != nil {
+		msg.Settings = &RunnerSettings{
+			AcceptLabels:       settings.AcceptLabels,
+			MaxConcurrentTasks: int32(settings.MaxConcurrentTasks),
+			Models:             settings.Models,
+		}
+		for _, taskType := range settings.TaskTypes {
+			msg.Settings.TaskTypes = append(msg.Settings.TaskTypes, string(taskType))
+		}
+	}
+	for _, subsystem := range heartbeat.Subsystems {
+		health := &SubsystemHealth{
+			Name:      subsystem.Name,
+			State:     string(subsystem.State),
+			Restarts:  int32(subsystem.Restarts),
+			LastError: subsystem.LastError,
+		}
+		if subsystem.LastRestart != nil {
+			health.LastRestart = timestamppb.New(*subsystem.LastRestart)
+		}
+		msg.Subsystems = append(msg.Subsystems, health)
+	}
+	var err error
+	if msg.Manifest, err = marshalDocument("manifest", heartbeat.Manifest); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (h *Heartbeat) Model() (*models.Heartbeat, error) {
+	heartbeat := &models.Heartbeat{
+		WalletAddress: h.GetWalletAddress(),
+		Status:        h.GetStatus().Model(),
+		Timestamp:     h.GetTimestamp(),
+		Uptime:        h.GetUptime(),
+		PublicIP:      h.GetPublicIp(),
+		HostMetrics: models.HostMetrics{
+			MemoryUsage: h.GetMemoryUsage(),
+			MemoryTotal: h.GetMemoryTotal(),
+			CPUUsage:    h.GetCpuUsage(),
+			DiskUsage:   h.GetDiskUsage(),
+			DiskTotal:   h.GetDiskTotal(),
+			Load1:       h.GetLoad_1(),
+			Load5:       h.GetLoad_5(),
+			Load15:      h.GetLoad_15(),
+		},
+	}
+	for _, gpu := range h.GetGpus() {
+		heartbeat.GPUs = append(heartbeat.GPUs, models.GPUInfo{
+			Index:    int(gpu.GetIndex()),
+			UUID:     gpu.GetUuid(),
+			Model:    gpu.GetModel(),
+			MemoryMB: gpu.GetMemoryMb(),
+		})
+	}
+	if settings := h.GetSettings(); settings != nil {
+		heartbeat.Settings = &models.RunnerSettings{
+			AcceptLabels:       settings.GetAcceptLabels(),
+			MaxConcurrentTasks: int(settings.GetMaxConcurrentTasks()),
+			Models:             settings.GetModels(),
+		}
+		for _, taskType := range settings.GetTaskTypes() {
+			heartbeat.Settings.TaskTypes = append(heartbeat.Settings.TaskTypes, models.TaskType(taskType))
+		}
+	}
+	for _, subsystem := range h.GetSubsystems() {
+		health := models.SubsystemHealth{
+			Name:      subsystem.GetName(),
+			State:     models.SubsystemState(subsystem.GetState()),
+			Restarts:  int(subsystem.GetRestarts()),
+			LastError: subsystem.GetLastError(),
+		}
+		if subsystem.GetLastRestart() != nil {
+			lastRestart := subsystem.GetLastRestart().AsTime()
+			health.LastRestart = &lastRestart
+		}
+		heartbeat.Subsystems = append(heartbeat.Subsystems, health)
+	}
+	var err error
+	if heartbeat.Manifest, err = unmarshalDocument[models.RunnerManifest]("manifest", h.GetManifest()); err != nil {
+		return nil, err
+	}
+	return heartbeat, nil
+}
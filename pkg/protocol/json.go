@@ -0,0 +1,37 @@
+// Package protocol holds the canonical task, result, registration and heartbeat
+// messages generated from api/proto/parity/v1/protocol.proto. The gRPC transport
+// sends these messages. The REST API and webhooks still encode the models with
+// encoding/json, and the tests keep the models' JSON field names in step with
+// the schema. MarshalJSON and UnmarshalJSON give the messages' proto JSON form
+// under the same snake_case names, but it is not the REST wire format:
+// protojson writes 64-bit integers as strings and enums by name.
+package protocol
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	marshalOptions   = protojson.MarshalOptions{UseProtoNames: true}
+	unmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// MarshalJSON encodes msg with its proto field names
+func MarshalJSON(msg proto.Message) ([]byte, error) {
+	data, err := marshalOptions.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", msg.ProtoReflect().Descriptor().Name(), err)
+	}
+	return data, nil
+}
+
+// UnmarshalJSON decodes data into msg, ignoring fields newer than this build
+func UnmarshalJSON(data []byte, msg proto.Message) error {
+	if err := unmarshalOptions.Unmarshal(data, msg); err != nil {
+		return fmt.Errorf("failed to unmarshal %s: %w", msg.ProtoReflect().Descriptor().Name(), err)
+	}
+	return nil
+}
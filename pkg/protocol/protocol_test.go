@@ -0,0 +1,307 @@
+package protocol
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/theblitlabs/parity-runner/internal/core/models"
+)
+
+// jsonFields lists the JSON names of a struct's fields, flattening embedded structs
+func jsonFields(typ reflect.Type) []string {
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous {
+			names = append(names, jsonFields(field.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func protoFields(desc protoreflect.MessageDescriptor) []string {
+	var names []string
+	for i := 0; i < desc.Fields().Len(); i++ {
+		names = append(names, string(desc.Fields().Get(i).Name()))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestMessagesMatchModels(t *testing.T) {
+	cases := []struct {
+		model any
+		msg   proto.Message
+	}{
+		{models.Task{}, &Task{}},
+		{models.TaskResult{}, &TaskResult{}},
+		{models.RunnerRegistration{}, &RunnerRegistration{}},
+		{models.ModelCapability{}, &ModelCapability{}},
+		{models.Heartbeat{}, &Heartbeat{}},
+		{models.RunnerSettings{}, &RunnerSettings{}},
+		{models.SubsystemHealth{}, &SubsystemHealth{}},
+		{models.GPUInfo{}, &GPU{}},
+		{models.GPURequirements{}, &GPURequirements{}},
+	}
+
+	for _, tc := range cases {
+		model := jsonFields(reflect.TypeOf(tc.model))
+		msg := protoFields(tc.msg.ProtoReflect().Descriptor())
+		if !reflect.DeepEqual(model, msg) {
+			t.Errorf("%T fields %v do not match %s fields %v", tc.model, model, tc.msg.ProtoReflect().Descriptor().FullName(), msg)
+		}
+	}
+}
+
+func TestTaskRoundTrip(t *testing.T) {
+	experimentID := uuid.New()
+	completedAt := time.Now().UTC().Truncate(time.Second)
+	task := &models.Task{
+		ID:           uuid.New(),
+		Title:        "train",
+		Type:         models.TaskTypeDocker,
+		Status:       models.TaskStatusCompleted,
+		Config:       []byte(`{"command":["python","train.py"]}`),
+		Environment:  &models.EnvironmentConfig{Type: "docker", Config: map[string]interface{}{"image": "alpine"}},
+		Labels:       models.Labels{"gpu": "true"},
+		ExperimentID: &experimentID,
+		GPU:          &models.GPURequirements{Count: 1, Model: "A100"},
+		Requirements: &models.CapabilityRequirements{MinCPUCores: 8, MinBandwidthMbps: 100},
+		Assignment: &models.TaskAssignment{
+			Runner:         "runner-4f2a",
+			ReputationTier: models.ReputationTierTrusted,
+			ClaimedAt:      completedAt.Add(-time.Hour),
+			LastProgressAt: completedAt,
+			LeaseExpiresAt: completedAt.Add(10 * time.Minute),
+		},
+		IsolationLevel:  models.IsolationContainer,
+		RequiredStake:   "3000000000000000000",
+		CreatorDeviceID: "creator",
+		RunnerID:        "runner-1",
+		Nonce:           "nonce",
+		CreatedAt:       completedAt.Add(-time.Hour),
+		UpdatedAt:       completedAt,
+		CompletedAt:     &completedAt,
+	}
+
+	msg, err := FromTask(task)
+	if err != nil {
+		t.Fatalf("FromTask() error = %v", err)
+	}
+	// The creating device stays on the server
+	task.CreatorDeviceID = ""
+
+	binary, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("proto.Marshal() error = %v", err)
+	}
+	decoded := &Task{}
+	if err := proto.Unmarshal(binary, decoded); err != nil {
+		t.Fatalf("proto.Unmarshal() error = %v", err)
+	}
+
+	data, err := MarshalJSON(decoded)
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"runner_id":"runner-1"`) {
+		t.Fatalf("JSON %s does not use proto field names", data)
+	}
+	fromJSON := &Task{}
+	if err := UnmarshalJSON(data, fromJSON); err != nil {
+		t.Fatalf("UnmarshalJSON() error = %v", err)
+	}
+
+	got, err := fromJSON.Model()
+	if err != nil {
+		t.Fatalf("Model() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, task) {
+		t.Fatalf("round trip = %+v, want %+v", got, task)
+	}
+}
+
+func TestTaskResultRoundTrip(t *testing.T) {
+	result := &models.TaskResult{
+		ID:             uuid.New(),
+		TaskID:         uuid.New(),
+		DeviceID:       "device",
+		DeviceIDHash:   "hash",
+		SolverDeviceID: "solver",
+		Output:         "done",
+		ExitCode:       1,
+		Reward:         2.5,
+		PromptTokens:   10,
+		Sealed:         &models.SealedPayload{Algorithm: "x25519-aes-256-gcm", KeyID: "key-1", Ciphertext: []byte("sealed")},
+		Checkpoint:     &models.CheckpointSummary{Mode: "criu", Attempt: 2, Resumed: true, CIDs: []string{"bafy1", "bafy2"}},
+		Uploads:        &models.ResultUploads{Files: []models.ResultUpload{{Name: "model.pt", CID: "bafy3", SHA256: "ab", Size: 3}}},
+		Deterministic:  &models.DeterministicSummary{HashVersion: 1, ImageDigest: "sha256:ab", Epoch: 946684800, ClockPinned: true},
+		Attestation:    &models.AttestationQuote{Version: 1, Technology: models.TEETDX, Quote: []byte{1, 2, 3}, Provider: "tdx_guest"},
+		Diagnostics:    &models.FailureDiagnostics{Class: models.DiagnosticOOMKilled, LogTail: []string{"Killed"}, Container: &models.ContainerDiagnostics{Status: "exited", ExitCode: 137, OOMKilled: true}},
+		ExecutionLog:   models.ExecutionLog{{Seq: 1, Type: models.ExecutionEventReceived, Time: time.Unix(1700000000, 0).UTC(), Details: map[string]string{"nonce": "n"}, Hash: "ab"}},
+		Status:         models.TaskStatusCancelled,
+		Attempts:       models.TaskAttempts{{Attempt: 1, StartedAt: time.Unix(1700000000, 0).UTC(), DurationMs: 1200, ExitCode: 137, Cause: models.RetryCauseOOMKilled, BackoffMs: 10000}},
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	msg, err := FromTaskResult(result)
+	if err != nil {
+		t.Fatalf("FromTaskResult() error = %v", err)
+	}
+	data, err := MarshalJSON(msg)
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	decoded := &TaskResult{}
+	if err := UnmarshalJSON(data, decoded); err != nil {
+		t.Fatalf("UnmarshalJSON() error = %v", err)
+	}
+
+	got, err := decoded.Model()
+	if err != nil {
+		t.Fatalf("Model() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, result) {
+		t.Fatalf("round trip = %+v, want %+v", got, result)
+	}
+}
+
+func TestHeartbeatRoundTrip(t *testing.T) {
+	restartedAt := time.Now().UTC().Truncate(time.Second)
+	heartbeat := &models.Heartbeat{
+		WalletAddress: "0xabc",
+		Status:        models.RunnerStatusOnline,
+		Timestamp:     1700000000,
+		Uptime:        60,
+		GPUs:          []models.GPUInfo{{Index: 0, UUID: "GPU-1", Model: "A100", MemoryMB: 40960}},
+		HostMetrics:   models.HostMetrics{MemoryUsage: 1 << 30, CPUUsage: 12.5, Load1: 0.5},
+		Settings: &models.RunnerSettings{
+			AcceptLabels:       "pool=gpu",
+			MaxConcurrentTasks: 2,
+			TaskTypes:          []models.TaskType{models.TaskTypeDocker, models.TaskTypeWasm},
+			Models:             []string{"llama3:8b"},
+		},
+		Subsystems: []models.SubsystemHealth{
+			{Name: "webhook", State: models.SubsystemHealthy},
+			{Name: "tunnel", State: models.SubsystemRestarting, Restarts: 2, LastError: "bore exited", LastRestart: &restartedAt},
+		},
+		Manifest: &models.RunnerManifest{
+			Version:      models.ManifestVersion,
+			DeviceID:     "device-1",
+			TaskTypes:    []models.TaskType{models.TaskTypeDocker},
+			MinRewards:   models.MinRewards{{Type: models.TaskTypeDocker, Reward: 0.01}},
+			Availability: models.RunnerAvailability{Accepting: true, Slots: 2},
+			IssuedAt:     restartedAt,
+			Signature:    "0x01",
+		},
+	}
+
+	msg, err := FromHeartbeat(heartbeat)
+	if err != nil {
+		t.Fatalf("FromHeartbeat() error = %v", err)
+	}
+	binary, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("proto.Marshal() error = %v", err)
+	}
+	decoded := &Heartbeat{}
+	if err := proto.Unmarshal(binary, decoded); err != nil {
+		t.Fatalf("proto.Unmarshal() error = %v", err)
+	}
+	got, err := decoded.Model()
+	if err != nil {
+		t.Fatalf("Model() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, heartbeat) {
+		t.Fatalf("round trip = %+v, want %+v", got, heartbeat)
+	}
+}
+
+func TestRunnerRegistrationRoundTrip(t *testing.T) {
+	registration := &models.RunnerRegistration{
+		WalletAddress:     "0xabc",
+		Status:            models.RunnerStatusOnline,
+		Webhook:           "https://runner.example/webhook",
+		ModelCapabilities: []models.ModelCapability{{ModelName: "llama3:8b", IsLoaded: true, MaxTokens: 4096}},
+		SandboxBenchmark: &models.SandboxBenchmark{
+			Engine:     "docker",
+			Image:      "alpine:latest",
+			MeasuredAt: time.Now().UTC().Truncate(time.Second),
+			Runs:       []models.SandboxBenchmarkRun{{Containers: 4, CreateMs: 80, StatsSampleMs: 1900, StatsSlowdown: 0.12}},
+		},
+		Capabilities: &models.CapabilityProfile{
+			CPUCores:      16,
+			MemoryBytes:   64 << 30,
+			DiskFreeBytes: 500 << 30,
+			BandwidthMbps: 940.5,
+			TaskTypes:     []models.TaskType{models.TaskTypeDocker, models.TaskTypeCommand},
+			MeasuredAt:    time.Now().UTC().Truncate(time.Second),
+		},
+		Benchmark: &models.BenchmarkReport{
+			Version:    models.BenchmarkVersion,
+			DeviceID:   "device-1",
+			CPU:        models.CPUBenchmark{Cores: 16, SingleCoreMBps: 610.2, MultiCoreMBps: 8800},
+			GPU:        &models.GPUBenchmark{Model: "NVIDIA L4", Count: 1, GFLOPS: 2895.1},
+			Scores:     models.BenchmarkScores{CPU: 1035.9, GPU: 723.8, Overall: 517.9},
+			MeasuredAt: time.Now().UTC().Truncate(time.Second),
+			Signature:  "0x01",
+		},
+		SettlementChainID: 8453,
+		Build: &models.BuildProvenance{
+			RunnerVersion: "v1.4.0",
+			Commit:        "4f2c1e9d0a7b",
+			Builder:       "https://github.com/actions/runner",
+			Attestation:   "https://example.com/parity-runner.intoto.jsonl",
+			GoVersion:     "go1.23.4",
+			Signature:     "0x02",
+		},
+	}
+
+	msg, err := FromRunnerRegistration(registration)
+	if err != nil {
+		t.Fatalf("FromRunnerRegistration() error = %v", err)
+	}
+	binary, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("proto.Marshal() error = %v", err)
+	}
+	decoded := &RunnerRegistration{}
+	if err := proto.Unmarshal(binary, decoded); err != nil {
+		t.Fatalf("proto.Unmarshal() error = %v", err)
+	}
+	got, err := decoded.Model()
+	if err != nil {
+		t.Fatalf("Model() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, registration) {
+		t.Fatalf("round trip = %+v, want %+v", got, registration)
+	}
+}
+
+func TestUnmarshalJSONIgnoresUnknownFields(t *testing.T) {
+	registration := &RunnerRegistration{}
+	if err := UnmarshalJSON([]byte(`{"wallet_address":"0xabc","status":"RUNNER_STATUS_ONLINE","added_later":1}`), registration); err != nil {
+		t.Fatalf("UnmarshalJSON() error = %v", err)
+	}
+	got, err := registration.Model()
+	if err != nil {
+		t.Fatalf("Model() error = %v", err)
+	}
+	if got.WalletAddress != "0xabc" || got.Status != models.RunnerStatusOnline {
+		t.Fatalf("Model() = %+v", got)
+	}
+}
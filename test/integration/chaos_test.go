@@ -0,0 +1,66 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/core/config"
+	parity "github.com/theblitlabs/parity-runner/pkg/client"
+)
+
+// TestTaskLifecycleUnderFaults runs tasks on a runner that drops deliveries, delays
+// heartbeats, kills containers and fails result submissions. The server's retries,
+// leases and reassignment must still bring every task to a final status.
+func TestTaskLifecycleUnderFaults(t *testing.T) {
+	env := newEnvironment(t, func(cfg *config.Config) {
+		cfg.Runner.Chaos = config.ChaosConfig{
+			WebhookDropRate:    0.3,
+			HeartbeatDelay:     3 * time.Second,
+			ContainerKillRate:  0.3,
+			ContainerKillAfter: 2 * time.Second,
+			ResultFailureRate:  0.3,
+			Seed:               7,
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), taskTimeout)
+	defer cancel()
+
+	const tasks = 6
+	ids := make([]string, 0, tasks)
+	for i := 0; i < tasks; i++ {
+		task, err := env.client.CreateTask(ctx, parity.CreateTaskRequest{
+			Title:          fmt.Sprintf("integration-chaos-%d", i),
+			Type:           parity.TaskTypeDocker,
+			Config:         json.RawMessage(`{"image_name":"alpine:3.20"}`),
+			Reward:         1,
+			CreatorAddress: creatorAddress,
+			Environment: &parity.EnvironmentConfig{
+				Type:   "docker",
+				Config: map[string]interface{}{"command": []string{"sh", "-c", "sleep 5 && echo parity-chaos"}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("CreateTask() error = %v", err)
+		}
+		ids = append(ids, task.ID.String())
+	}
+
+	statuses := map[parity.TaskStatus]int{}
+	for _, id := range ids {
+		finished, err := env.client.WaitForTask(ctx, id, pollInterval)
+		if err != nil {
+			t.Fatalf("task %s did not finish: %v", id, err)
+		}
+		if !parity.IsTerminal(finished.Status) {
+			t.Fatalf("task %s ended in status %s", id, finished.Status)
+		}
+		statuses[finished.Status]++
+	}
+	t.Logf("final statuses under faults: %v", statuses)
+}
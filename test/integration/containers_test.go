@@ -0,0 +1,163 @@
+//go:build integration
+
+package integration
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// containerRequest describes a dependency of the suite. The container joins the
+// suite network under Alias; ExposedPorts are published on random host ports
+// unless a port is given as "hostPort:containerPort/proto".
+type containerRequest struct {
+	Image        string
+	Alias        string
+	Env          map[string]string
+	Entrypoint   []string
+	Cmd          []string
+	ExposedPorts []string
+	Binds        []string
+	ExtraHosts   []string
+	WaitFor      wait.Strategy
+}
+
+type testContainer struct {
+	testcontainers.Container
+	alias string
+}
+
+type dockerNetwork struct {
+	name string
+}
+
+func newNetwork(ctx context.Context, t *testing.T) *dockerNetwork {
+	t.Helper()
+
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	name := "parity-it-" + uuid.NewString()[:8]
+	network, err := testcontainers.GenericNetwork(ctx, testcontainers.GenericNetworkRequest{
+		NetworkRequest: testcontainers.NetworkRequest{Name: name, CheckDuplicate: true},
+	})
+	if err != nil {
+		t.Fatalf("failed to create network %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		if err := network.Remove(context.Background()); err != nil {
+			t.Logf("failed to remove network %s: %v", name, err)
+		}
+	})
+	return &dockerNetwork{name: name}
+}
+
+// startContainer starts the container on the network and waits for it to be
+// ready. The container is removed when the test ends.
+func (n *dockerNetwork) startContainer(ctx context.Context, t *testing.T, req containerRequest) *testContainer {
+	t.Helper()
+
+	started, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:          req.Image,
+			Name:           n.name + "-" + req.Alias,
+			Env:            req.Env,
+			Entrypoint:     req.Entrypoint,
+			Cmd:            req.Cmd,
+			ExposedPorts:   req.ExposedPorts,
+			Binds:          req.Binds,
+			ExtraHosts:     req.ExtraHosts,
+			Labels:         map[string]string{"parity.integration": "true"},
+			Networks:       []string{n.name},
+			NetworkAliases: map[string][]string{n.name: {req.Alias}},
+			WaitingFor:     req.WaitFor,
+		},
+		Started: true,
+	})
+	// A container that started but never became ready is still returned, so it
+	// is cleaned up and its logs shown like any other
+	var c *testContainer
+	if started != nil {
+		c = &testContainer{Container: started, alias: req.Alias}
+		t.Cleanup(func() {
+			if t.Failed() {
+				c.dumpLogs(t)
+			}
+			if err := c.Terminate(context.Background()); err != nil {
+				t.Logf("failed to remove %s container: %v", req.Alias, err)
+			}
+		})
+	}
+	if err != nil {
+		t.Fatalf("failed to start %s container: %v", req.Alias, err)
+	}
+	return c
+}
+
+func (c *testContainer) mustEndpoint(ctx context.Context, t *testing.T, port string) string {
+	t.Helper()
+
+	host, err := c.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to resolve %s host: %v", c.alias, err)
+	}
+	mapped, err := c.MappedPort(ctx, nat.Port(port))
+	if err != nil {
+		t.Fatalf("failed to resolve %s endpoint: %v", c.alias, err)
+	}
+	return host + ":" + mapped.Port()
+}
+
+func (c *testContainer) dumpLogs(t *testing.T) {
+	reader, err := c.Logs(context.Background())
+	if err != nil {
+		t.Logf("failed to read %s logs: %v", c.alias, err)
+		return
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		t.Logf("[%s] %s", c.alias, scanner.Text())
+	}
+}
+
+// waitForLog waits until line has been logged occurrences times
+func waitForLog(line string, occurrences int) wait.Strategy {
+	return wait.ForLog(line).WithOccurrence(occurrences).WithStartupTimeout(startupTimeout)
+}
+
+// waitForHTTP waits until path on the published port answers with a status below 500
+func waitForHTTP(port, path string) wait.Strategy {
+	return wait.ForHTTP(path).
+		WithPort(nat.Port(port)).
+		WithStatusCodeMatcher(func(status int) bool { return status < http.StatusInternalServerError }).
+		WithStartupTimeout(startupTimeout)
+}
+
+func poll(ctx context.Context, ready func() (bool, error)) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		ok, err := ready()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
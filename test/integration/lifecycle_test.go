@@ -0,0 +1,227 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theblitlabs/parity-runner/internal/ipfs"
+	"github.com/theblitlabs/parity-runner/internal/receipt"
+	"github.com/theblitlabs/parity-runner/internal/server"
+	parity "github.com/theblitlabs/parity-runner/pkg/client"
+)
+
+const (
+	taskTimeout  = 10 * time.Minute
+	pollInterval = time.Second
+
+	// creatorAddress is anvil's first dev account
+	creatorAddress = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+)
+
+// TestTaskLifecycle drives every task type through the whole deployment: the task
+// is created on the server, assigned to the runner, executed, verified through
+// its signed receipt and paid out on chain
+func TestTaskLifecycle(t *testing.T) {
+	env := newEnvironment(t)
+
+	cases := []struct {
+		name   string
+		req    parity.CreateTaskRequest
+		output string
+	}{
+		{
+			name: "docker",
+			req: parity.CreateTaskRequest{
+				Type:   parity.TaskTypeDocker,
+				Config: json.RawMessage(`{"image_name":"alpine:3.20"}`),
+				Environment: &parity.EnvironmentConfig{
+					Type:   "docker",
+					Config: map[string]interface{}{"command": []string{"echo", "parity-docker"}},
+				},
+			},
+			output: "parity-docker",
+		},
+		{
+			name: "command",
+			req: parity.CreateTaskRequest{
+				Type:   parity.TaskTypeCommand,
+				Config: json.RawMessage(`{"command":"echo parity-command"}`),
+			},
+			output: "parity-command",
+		},
+		{
+			name: "llm",
+			req: parity.CreateTaskRequest{
+				Type:   parity.TaskTypeLLM,
+				Config: json.RawMessage(fmt.Sprintf(`{"model":%q,"prompt":"Reply with the single word: pong"}`, env.llmModel)),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), taskTimeout)
+			defer cancel()
+
+			paidBefore := env.earnings(ctx, t).PaidCount
+
+			tc.req.Title = "integration-" + tc.name
+			tc.req.Reward = 1
+			tc.req.CreatorAddress = creatorAddress
+			task, err := env.client.CreateTask(ctx, tc.req)
+			if err != nil {
+				t.Fatalf("CreateTask() error = %v", err)
+			}
+
+			finished, err := env.client.WaitForTask(ctx, task.ID.String(), pollInterval)
+			if err != nil {
+				t.Fatalf("WaitForTask() error = %v", err)
+			}
+			if finished.Status != parity.TaskStatusCompleted {
+				t.Fatalf("task status = %s, want %s", finished.Status, parity.TaskStatusCompleted)
+			}
+			if finished.RunnerID != env.deviceID {
+				t.Fatalf("task ran on %q, want %q", finished.RunnerID, env.deviceID)
+			}
+
+			result, err := env.client.GetTaskResult(ctx, task.ID.String())
+			if err != nil {
+				t.Fatalf("GetTaskResult() error = %v", err)
+			}
+			if result.ExitCode != 0 {
+				t.Fatalf("exit code = %d, output %q", result.ExitCode, result.Output)
+			}
+			if tc.output != "" && !strings.Contains(result.Output, tc.output) {
+				t.Fatalf("output = %q, want it to contain %q", result.Output, tc.output)
+			}
+			if tc.output == "" && strings.TrimSpace(result.Output) == "" {
+				t.Fatal("expected a non-empty output")
+			}
+
+			env.verifyReceipt(ctx, t, task.ID.String())
+			env.waitForPayout(ctx, t, paidBefore)
+		})
+	}
+
+	t.Run("federated_learning", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), taskTimeout)
+		defer cancel()
+
+		dataset := "x1,x2,y\n"
+		for i := 0; i < 200; i++ {
+			x1, x2 := float64(i%20)/10, float64(i%7)/3
+			dataset += fmt.Sprintf("%.2f,%.2f,%.4f\n", x1, x2, 2*x1-x2+0.5)
+		}
+		cid, err := ipfs.NewClient(env.ipfsGateway, env.ipfsAPIURL).Add(ctx, []byte(dataset))
+		if err != nil {
+			t.Fatalf("failed to add dataset to IPFS: %v", err)
+		}
+
+		var session struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		}
+		err = env.api(ctx, http.MethodPost, "/federated-learning/sessions", map[string]interface{}{
+			"name":             "integration-fl",
+			"model_type":       "linear_regression",
+			"total_rounds":     1,
+			"min_participants": 1,
+			"creator_address":  creatorAddress,
+			"training_data": map[string]interface{}{
+				"dataset_cid":    cid,
+				"data_format":    "csv",
+				"split_strategy": "random",
+			},
+			"config": map[string]interface{}{
+				"aggregation_method": "federated_averaging",
+				"learning_rate":      0.01,
+				"batch_size":         16,
+				"local_epochs":       2,
+				"model_config":       map[string]interface{}{"input_size": 2, "output_size": 1},
+			},
+		}, &session)
+		if err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		if err := env.api(ctx, http.MethodPost, "/federated-learning/sessions/"+session.ID+"/start", struct{}{}, nil); err != nil {
+			t.Fatalf("failed to start session: %v", err)
+		}
+
+		err = poll(ctx, func() (bool, error) {
+			if err := env.api(ctx, http.MethodGet, "/federated-learning/sessions/"+session.ID, nil, &session); err != nil {
+				return false, err
+			}
+			if session.Status == "failed" || session.Status == "cancelled" {
+				return false, fmt.Errorf("session ended with status %s", session.Status)
+			}
+			return session.Status == "completed", nil
+		})
+		if err != nil {
+			t.Fatalf("session did not complete: %v", err)
+		}
+
+		var model map[string]interface{}
+		if err := env.api(ctx, http.MethodGet, "/federated-learning/sessions/"+session.ID+"/model", nil, &model); err != nil {
+			t.Fatalf("failed to get the aggregated model: %v", err)
+		}
+		if len(model) == 0 {
+			t.Fatal("expected an aggregated model")
+		}
+	})
+}
+
+// verifyReceipt checks that the runner signed the execution receipt of the task
+func (env *environment) verifyReceipt(ctx context.Context, t *testing.T, taskID string) {
+	t.Helper()
+
+	executionReceipt, err := env.client.GetReceipt(ctx, taskID)
+	if err != nil {
+		t.Fatalf("GetReceipt() error = %v", err)
+	}
+	if err := receipt.Verify(executionReceipt); err != nil {
+		t.Fatalf("receipt verification failed: %v", err)
+	}
+	if !strings.EqualFold(executionReceipt.RunnerAddress, env.runnerAddress) {
+		t.Fatalf("receipt signed by %s, want %s", executionReceipt.RunnerAddress, env.runnerAddress)
+	}
+	if executionReceipt.TaskID.String() != taskID {
+		t.Fatalf("receipt is for task %s, want %s", executionReceipt.TaskID, taskID)
+	}
+}
+
+// waitForPayout waits until the server reports one more reward paid to the runner
+// than before the task. Without the contracts on chain there is nothing to pay
+// with, so the check is skipped.
+func (env *environment) waitForPayout(ctx context.Context, t *testing.T, paidBefore int) {
+	t.Helper()
+
+	if !env.payouts {
+		t.Log("PARITY_IT_ANVIL_STATE is not set, skipping the payout check")
+		return
+	}
+
+	err := poll(ctx, func() (bool, error) {
+		return env.earnings(ctx, t).PaidCount > paidBefore, nil
+	})
+	if err != nil {
+		earnings := env.earnings(context.Background(), t)
+		t.Fatalf("reward was not paid: %v (paid %d, %d pending)", err, earnings.PaidCount, len(earnings.Pending))
+	}
+}
+
+func (env *environment) earnings(ctx context.Context, t *testing.T) server.RunnerEarnings {
+	t.Helper()
+
+	var earnings server.RunnerEarnings
+	if err := env.api(ctx, http.MethodGet, "/earnings/"+env.deviceID, nil, &earnings); err != nil {
+		t.Fatalf("failed to get runner earnings: %v", err)
+	}
+	return earnings
+}